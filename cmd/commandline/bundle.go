@@ -178,4 +178,8 @@ func init() {
 	bundleBumpVersionCommand.MarkFlagRequired("target_version")
 
 	bundlePackageCommand.Flags().StringP("output_path", "o", "", "output path")
+
+	bundleAnalyzeCommand.ValidArgsFunction = completeBundlePath
+	bundlePackageCommand.ValidArgsFunction = completeDirectory
+	bundleAppendPackageDependencyCommand.RegisterFlagCompletionFunc("package_path", completeDifypkg)
 }
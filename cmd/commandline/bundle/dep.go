@@ -3,6 +3,7 @@ package bundle
 import (
 	"os"
 
+	"github.com/langgenius/dify-plugin-daemon/cmd/commandline/output"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/pkg/bundle_packager"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/bundle_entities"
@@ -129,6 +130,11 @@ func ListDependencies(bundlePath string) {
 		return
 	}
 
+	if output.Structured() {
+		output.Print(dependencies)
+		return
+	}
+
 	if len(dependencies) == 0 {
 		log.Info("No dependencies found")
 		return
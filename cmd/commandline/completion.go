@@ -0,0 +1,22 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// shell completions are generated by the completion command cobra adds to the root command,
+// e.g. `dify completion bash > /etc/bash_completion.d/dify`, these narrow down path arguments
+
+// completeDifypkg completes packaged plugins, directories are still offered so plugin
+// directories can be completed too
+func completeDifypkg(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{"difypkg"}, cobra.ShellCompDirectiveFilterFileExt
+}
+
+func completeBundlePath(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{"difybndl"}, cobra.ShellCompDirectiveFilterFileExt
+}
+
+func completeDirectory(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return nil, cobra.ShellCompDirectiveFilterDirs
+}
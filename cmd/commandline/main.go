@@ -4,17 +4,22 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/langgenius/dify-plugin-daemon/cmd/commandline/output"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var (
-	cfgFile string
+	cfgFile      string
+	outputFormat string
 
 	rootCommand = &cobra.Command{
 		Use:   "dify",
 		Short: "Dify",
 		Long:  "Dify is a cli tool to help you develop your Dify projects.",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return output.SetFormat(outputFormat)
+		},
 	}
 
 	pluginCommand = &cobra.Command{
@@ -40,6 +45,10 @@ var (
 		Short: "Version",
 		Long:  "Show the version of dify cli",
 		Run: func(cmd *cobra.Command, args []string) {
+			if output.Structured() {
+				output.Print(map[string]string{"version": VersionX})
+				return
+			}
 			fmt.Println(VersionX)
 		},
 	}
@@ -49,6 +58,9 @@ func init() {
 	cobra.OnInitialize(initConfig)
 
 	rootCommand.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.dify.yaml)")
+	// no shorthand, -o is taken by output_path of package commands
+	rootCommand.PersistentFlags().StringVar(&outputFormat, "output", output.FORMAT_TEXT, "output format of informational commands, one of text, json and yaml")
+	rootCommand.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(output.FORMATS, cobra.ShellCompDirectiveNoFileComp))
 	rootCommand.AddCommand(pluginCommand)
 	rootCommand.AddCommand(bundleCommand)
 	rootCommand.AddCommand(signatureCommand)
@@ -73,7 +85,7 @@ func initConfig() {
 	viper.AutomaticEnv()
 
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 }
 
@@ -0,0 +1,61 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	FORMAT_TEXT = "text"
+	FORMAT_JSON = "json"
+	FORMAT_YAML = "yaml"
+)
+
+var (
+	FORMATS = []string{FORMAT_TEXT, FORMAT_JSON, FORMAT_YAML}
+
+	format = FORMAT_TEXT
+)
+
+// SetFormat switches informational commands to print machine-readable results,
+// logs are moved to stderr so stdout only contains the result
+func SetFormat(f string) error {
+	switch f {
+	case FORMAT_TEXT:
+	case FORMAT_JSON, FORMAT_YAML:
+		log.SetOutput(os.Stderr)
+	default:
+		return fmt.Errorf("unsupported output format %s, use one of text, json and yaml", f)
+	}
+
+	format = f
+	return nil
+}
+
+// Structured returns true if results should be printed with Print instead of human-formatted text
+func Structured() bool {
+	return format != FORMAT_TEXT
+}
+
+// Print writes v to stdout in the selected format
+func Print(v any) {
+	switch format {
+	case FORMAT_YAML:
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(v); err != nil {
+			log.Error("failed to encode output: %s", err)
+		}
+		encoder.Close()
+	default:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(v); err != nil {
+			log.Error("failed to encode output: %s", err)
+		}
+	}
+}
@@ -95,7 +95,14 @@ If no parameters are provided, an interactive mode will be started.`,
 				outputPath = base + ".difypkg"
 			}
 
-			plugin.PackagePlugin(inputPath, outputPath)
+			var bundle *plugin.BundleOptions
+			if bundleDependencies, _ := cmd.Flags().GetBool("bundle-dependencies"); bundleDependencies {
+				python, _ := cmd.Flags().GetString("python")
+				pythonVersion, _ := cmd.Flags().GetString("python-version")
+				bundle = &plugin.BundleOptions{Python: python, PythonVersion: pythonVersion}
+			}
+
+			plugin.PackagePlugin(inputPath, outputPath, bundle)
 		},
 	}
 
@@ -110,6 +117,16 @@ If no parameters are provided, an interactive mode will be started.`,
 		},
 	}
 
+	pluginLintCommand = &cobra.Command{
+		Use:   "lint [plugin_path]",
+		Short: "Lint",
+		Long:  "Lint the manifest and declarations of the plugin, you need specify the plugin path or .difypkg file path",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			plugin.LintPlugin(args[0])
+		},
+	}
+
 	pluginModuleCommand = &cobra.Command{
 		Use:   "module",
 		Short: "Module",
@@ -222,6 +239,7 @@ func init() {
 	pluginCommand.AddCommand(pluginInitCommand)
 	pluginCommand.AddCommand(pluginPackageCommand)
 	pluginCommand.AddCommand(pluginChecksumCommand)
+	pluginCommand.AddCommand(pluginLintCommand)
 	pluginCommand.AddCommand(pluginEditPermissionCommand)
 	pluginCommand.AddCommand(pluginModuleCommand)
 	pluginCommand.AddCommand(pluginReadmeCommand)
@@ -264,4 +282,19 @@ func init() {
 	pluginInitCommand.Flags().BoolVar(&quick, "quick", false, "Skip interactive mode and create plugin directly")
 
 	pluginPackageCommand.Flags().StringP("output_path", "o", "", "output path")
+	pluginPackageCommand.Flags().Bool("bundle-dependencies", false, "download the wheels of requirements.txt into the package for offline installation")
+	pluginPackageCommand.Flags().String("python", "python3", "python running pip download when bundling dependencies")
+	pluginPackageCommand.Flags().String("python-version", "", "python version of the bundled wheels, meta.runner.version by default")
+
+	pluginPackageCommand.ValidArgsFunction = completeDirectory
+	pluginChecksumCommand.ValidArgsFunction = completeDifypkg
+	pluginEditPermissionCommand.ValidArgsFunction = completeDirectory
+	pluginModuleListCommand.ValidArgsFunction = completeDifypkg
+	pluginModuleAppendToolsCommand.ValidArgsFunction = completeDirectory
+	pluginModuleAppendEndpointsCommand.ValidArgsFunction = completeDirectory
+	pluginReadmeListCommand.ValidArgsFunction = completeDifypkg
+	pluginInitCommand.RegisterFlagCompletionFunc("category", cobra.FixedCompletions([]string{
+		"tool", "llm", "text-embedding", "speech2text", "moderation", "rerank", "tts", "extension", "agent-strategy",
+	}, cobra.ShellCompDirectiveNoFileComp))
+	pluginInitCommand.RegisterFlagCompletionFunc("language", cobra.FixedCompletions([]string{"python"}, cobra.ShellCompDirectiveNoFileComp))
 }
@@ -0,0 +1,162 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/constants"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/consts"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+var (
+	// bundled wheels are large, the daemon must allow it with MAX_PLUGIN_PACKAGE_SIZE as well
+	MaxBundledPluginPackageSize = int64(500 * 1024 * 1024) // 500 MB
+
+	// platforms of the wheels downloaded for each arch of the manifest
+	bundlePlatforms = map[constants.Arch]string{
+		constants.AMD64: "manylinux2014_x86_64",
+		constants.ARM64: "manylinux2014_aarch64",
+	}
+)
+
+type BundleOptions struct {
+	// Python runs pip download, it does not need to be the version of the plugin
+	Python string
+	// PythonVersion defaults to meta.runner.version
+	PythonVersion string
+}
+
+// BundleDependencies downloads the wheels of requirements.txt for every arch of the manifest into
+// consts.DEPENDENCY_BUNDLE_DIR of the plugin, so that it installs without an index
+func BundleDependencies(inputPath string, options BundleOptions) error {
+	pluginDecoder, err := decoder.NewFSPluginDecoder(inputPath)
+	if err != nil {
+		return err
+	}
+	manifest, err := pluginDecoder.Manifest()
+	if err != nil {
+		return err
+	}
+	if manifest.Meta.Runner.Language != constants.Python {
+		return fmt.Errorf("bundling dependencies of %s plugins is not supported", manifest.Meta.Runner.Language)
+	}
+
+	if options.Python == "" {
+		options.Python = "python3"
+	}
+	pythonVersion := options.PythonVersion
+	if pythonVersion == "" {
+		pythonVersion = manifest.Meta.Runner.Version
+	}
+
+	bundlePath := filepath.Join(inputPath, consts.DEPENDENCY_BUNDLE_DIR)
+	if err := checkDependencyBundle(bundlePath); err != nil {
+		return err
+	}
+
+	// wheels are downloaded aside, the previous bundle is kept if any download fails
+	downloadPath, err := os.MkdirTemp("", "dify-plugin-wheels-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(downloadPath)
+
+	for _, arch := range manifest.Meta.Arch {
+		platform, ok := bundlePlatforms[arch]
+		if !ok {
+			return fmt.Errorf("unsupported arch of bundled dependencies: %s", arch)
+		}
+		log.Info("downloading dependencies for %s, python %s", platform, pythonVersion)
+
+		cmd := exec.Command(options.Python, "-m", "pip", "download",
+			"-r", "requirements.txt",
+			"-d", downloadPath,
+			"--only-binary=:all:",
+			"--platform", platform,
+			"--python-version", pythonVersion,
+		)
+		cmd.Dir = inputPath
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("failed to download dependencies for %s: %s, output: %s", platform, err, strings.TrimSpace(string(output)))
+		}
+	}
+
+	return replaceDependencyBundle(bundlePath, downloadPath)
+}
+
+// checkDependencyBundle refuses to replace a directory which was not created by bundling
+func checkDependencyBundle(bundlePath string) error {
+	if _, err := os.Stat(bundlePath); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(bundlePath, consts.DEPENDENCY_BUNDLE_MARKER)); err != nil {
+		return fmt.Errorf(
+			"%s exists but was not created by bundling dependencies, remove or rename it to bundle them",
+			bundlePath,
+		)
+	}
+	return nil
+}
+
+// replaceDependencyBundle replaces the bundle with the downloaded wheels, wheels of requirements removed
+// since the last bundle must not be left behind
+func replaceDependencyBundle(bundlePath string, downloadPath string) error {
+	if err := checkDependencyBundle(bundlePath); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(bundlePath); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(bundlePath, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(bundlePath, consts.DEPENDENCY_BUNDLE_MARKER), []byte{}, 0o644); err != nil {
+		return err
+	}
+
+	wheels, err := os.ReadDir(downloadPath)
+	if err != nil {
+		return err
+	}
+	for _, wheel := range wheels {
+		if wheel.IsDir() {
+			continue
+		}
+		source, target := filepath.Join(downloadPath, wheel.Name()), filepath.Join(bundlePath, wheel.Name())
+		// the temporary directory may be on another device
+		if err := os.Rename(source, target); err == nil {
+			continue
+		}
+		if err := copyWheel(source, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyWheel(source string, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
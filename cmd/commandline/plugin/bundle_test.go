@@ -0,0 +1,58 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/consts"
+)
+
+func TestReplaceDependencyBundle(t *testing.T) {
+	pluginPath := t.TempDir()
+	bundlePath := filepath.Join(pluginPath, consts.DEPENDENCY_BUNDLE_DIR)
+	download := func(wheels ...string) string {
+		dir := t.TempDir()
+		for _, wheel := range wheels {
+			if err := os.WriteFile(filepath.Join(dir, wheel), []byte(wheel), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+
+	// a directory of the plugin which happens to be named like the bundle is kept
+	if err := os.MkdirAll(bundlePath, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bundlePath, "wheels.py"), []byte("print()"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := replaceDependencyBundle(bundlePath, download("requests-2.32.0-py3-none-any.whl")); err == nil {
+		t.Fatal("a directory not created by bundling must not be replaced")
+	}
+	if _, err := os.Stat(filepath.Join(bundlePath, "wheels.py")); err != nil {
+		t.Fatalf("the files of the plugin must be kept, got %v", err)
+	}
+
+	os.RemoveAll(bundlePath)
+	if err := replaceDependencyBundle(bundlePath, download("requests-2.32.0-py3-none-any.whl", "idna-3.7-py3-none-any.whl")); err != nil {
+		t.Fatal(err)
+	}
+	// bundling again drops the wheels no longer required
+	if err := replaceDependencyBundle(bundlePath, download("requests-2.32.0-py3-none-any.whl")); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(bundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if len(names) != 2 || names[0] != consts.DEPENDENCY_BUNDLE_MARKER || names[1] != "requests-2.32.0-py3-none-any.whl" {
+		t.Fatalf("unexpected bundle %v", names)
+	}
+}
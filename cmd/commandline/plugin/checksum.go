@@ -3,6 +3,7 @@ package plugin
 import (
 	"os"
 
+	"github.com/langgenius/dify-plugin-daemon/cmd/commandline/output"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
 )
@@ -40,5 +41,10 @@ func CalculateChecksum(pluginPath string) {
 		return
 	}
 
+	if output.Structured() {
+		output.Print(map[string]string{"checksum": checksum})
+		return
+	}
+
 	log.Info("plugin checksum: %s", checksum)
 }
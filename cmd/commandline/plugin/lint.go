@@ -0,0 +1,56 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/langgenius/dify-plugin-daemon/cmd/commandline/output"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/linter"
+)
+
+// LintPlugin lints a plugin directory or .difypkg file and exits with 1 if there is any error
+func LintPlugin(pluginPath string) {
+	stat, err := os.Stat(pluginPath)
+	if err != nil {
+		log.Error("failed to get plugin file info, plugin path: %s, error: %v", pluginPath, err)
+		os.Exit(1)
+	}
+
+	var result linter.Result
+	if stat.IsDir() {
+		result = linter.LintDir(pluginPath)
+	} else {
+		pkg, err := os.ReadFile(pluginPath)
+		if err != nil {
+			log.Error("failed to read plugin file, plugin path: %s, error: %v", pluginPath, err)
+			os.Exit(1)
+		}
+		result = linter.LintPackage(pkg)
+	}
+
+	if output.Structured() {
+		output.Print(result)
+	} else {
+		for _, diagnostic := range result.Diagnostics {
+			location := diagnostic.File
+			if diagnostic.Path != "" {
+				location = fmt.Sprintf("%s#%s", diagnostic.File, diagnostic.Path)
+			}
+			message := fmt.Sprintf("%s [%s] %s", location, diagnostic.Code, diagnostic.Message)
+			if diagnostic.Suggestion != "" {
+				message = fmt.Sprintf("%s, %s", message, diagnostic.Suggestion)
+			}
+			if diagnostic.Severity == linter.SEVERITY_ERROR {
+				log.Error("%s", message)
+			} else {
+				log.Warn("%s", message)
+			}
+		}
+		log.Info("%d errors, %d warnings", result.Errors, result.Warnings)
+	}
+
+	if result.Errors > 0 {
+		os.Exit(1)
+	}
+}
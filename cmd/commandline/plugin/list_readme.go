@@ -3,17 +3,19 @@ package plugin
 import (
 	"fmt"
 	"os"
+	"sort"
 	"text/tabwriter"
 
+	"github.com/langgenius/dify-plugin-daemon/cmd/commandline/output"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
 )
 
 // Language represents supported README languages
 type Language struct {
-	Code      string
-	Name      string
-	Available bool
+	Code      string `json:"code" yaml:"code"`
+	Name      string `json:"name" yaml:"name"`
+	Available bool   `json:"available" yaml:"available"`
 }
 
 // GetLanguageName returns the full language name for a given language code
@@ -68,6 +70,22 @@ func ListReadme(pluginPath string) {
 		return
 	}
 
+	if output.Structured() {
+		languages := []Language{}
+		for code := range availableReadmes {
+			languages = append(languages, Language{
+				Code:      code,
+				Name:      GetLanguageName(code),
+				Available: true,
+			})
+		}
+		sort.Slice(languages, func(i, j int) bool {
+			return languages[i].Code < languages[j].Code
+		})
+		output.Print(languages)
+		return
+	}
+
 	// Create a new tabwriter
 	w := tabwriter.NewWriter(os.Stdout, 0, 8, 3, ' ', 0)
 
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/langgenius/dify-plugin-daemon/cmd/commandline/output"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/constants"
 	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
@@ -159,6 +160,24 @@ func ModuleList(pluginPath string) {
 		return
 	}
 
+	if output.Structured() {
+		modules := map[string]any{}
+		if manifest.Tool != nil {
+			modules["tools"] = manifest.Tool.Tools
+		}
+		if manifest.AgentStrategy != nil {
+			modules["agent_strategies"] = manifest.AgentStrategy.Strategies
+		}
+		if manifest.Model != nil {
+			modules["models"] = manifest.Model.Models
+		}
+		if manifest.Endpoint != nil {
+			modules["endpoints"] = manifest.Endpoint.Endpoints
+		}
+		output.Print(modules)
+		return
+	}
+
 	if manifest.Tool != nil {
 		for _, tool := range manifest.Tool.Tools {
 			tmpl, err := template.New("tool").Parse(TOOL_MODULE_TEMPLATE)
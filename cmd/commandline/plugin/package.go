@@ -12,7 +12,19 @@ var (
 	MaxPluginPackageSize = int64(50 * 1024 * 1024) // 50 MB
 )
 
-func PackagePlugin(inputPath string, outputPath string) {
+// PackagePlugin packs the plugin at inputPath, with bundle its dependencies are downloaded into the
+// package first
+func PackagePlugin(inputPath string, outputPath string, bundle *BundleOptions) {
+	maxSize := MaxPluginPackageSize
+	if bundle != nil {
+		if err := BundleDependencies(inputPath, *bundle); err != nil {
+			log.Error("failed to bundle dependencies: %v", err)
+			os.Exit(1)
+			return
+		}
+		maxSize = MaxBundledPluginPackageSize
+	}
+
 	decoder, err := decoder.NewFSPluginDecoder(inputPath)
 	if err != nil {
 		log.Error("failed to create plugin decoder , plugin path: %s, error: %v", inputPath, err)
@@ -21,7 +33,7 @@ func PackagePlugin(inputPath string, outputPath string) {
 	}
 
 	packager := packager.NewPackager(decoder)
-	zipFile, err := packager.Pack(MaxPluginPackageSize)
+	zipFile, err := packager.Pack(maxSize)
 
 	if err != nil {
 		log.Error("failed to package plugin: %v", err)
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/cmd/commandline/run"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replayPluginPayload run.ReplayPluginPayload
+)
+
+var (
+	replayPluginCommand = &cobra.Command{
+		Use:   "replay [recording_path] [plugin_package_path]",
+		Short: "replay",
+		Long: `Replay the requests of a recorded debugging session against a local build of the plugin
+and compare its responses with the recorded ones, backwards invocations are answered by mocks.
+Sessions are recorded by the daemon when PLUGIN_REMOTE_DEBUGGING_RECORD_DIR is set.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(c *cobra.Command, args []string) {
+			replayPluginPayload.RecordingPath = args[0]
+			replayPluginPayload.PluginPath = args[1]
+			run.ReplayPlugin(replayPluginPayload)
+		},
+	}
+)
+
+func init() {
+	pluginCommand.AddCommand(replayPluginCommand)
+
+	replayPluginCommand.Flags().BoolVarP(&replayPluginPayload.EnableLogs, "enable-logs", "l", false, "enable logs")
+	replayPluginCommand.Flags().StringVarP(&replayPluginPayload.ResponseFormat, "response-format", "r", "text", "response format, text or json")
+	replayPluginCommand.Flags().DurationVarP(&replayPluginPayload.Timeout, "timeout", "t", time.Minute, "max time to wait for the responses of a request")
+
+	replayPluginCommand.RegisterFlagCompletionFunc("response-format", cobra.FixedCompletions([]string{"text", "json"}, cobra.ShellCompDirectiveNoFileComp))
+}
@@ -40,4 +40,8 @@ func init() {
 	runPluginCommand.Flags().StringVarP(&runPluginPayload.RunMode, "mode", "m", "stdio", "run mode, stdio or tcp")
 	runPluginCommand.Flags().BoolVarP(&runPluginPayload.EnableLogs, "enable-logs", "l", false, "enable logs")
 	runPluginCommand.Flags().StringVarP(&runPluginPayload.ResponseFormat, "response-format", "r", "text", "response format, text or json")
+
+	runPluginCommand.ValidArgsFunction = completeDifypkg
+	runPluginCommand.RegisterFlagCompletionFunc("mode", cobra.FixedCompletions([]string{"stdio", "tcp"}, cobra.ShellCompDirectiveNoFileComp))
+	runPluginCommand.RegisterFlagCompletionFunc("response-format", cobra.FixedCompletions([]string{"text", "json"}, cobra.ShellCompDirectiveNoFileComp))
 }
@@ -47,6 +47,8 @@ const (
 	GENERIC_RESPONSE_TYPE_ERROR             GenericResponseType = "error"
 	GENERIC_RESPONSE_TYPE_PLUGIN_RESPONSE   GenericResponseType = "plugin_response"
 	GENERIC_RESPONSE_TYPE_PLUGIN_INVOKE_END GenericResponseType = "plugin_invoke_end"
+	GENERIC_RESPONSE_TYPE_REPLAY_RESULT     GenericResponseType = "replay_result"
+	GENERIC_RESPONSE_TYPE_DIAGNOSTICS       GenericResponseType = "diagnostics"
 )
 
 type GenericResponse struct {
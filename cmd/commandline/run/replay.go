@@ -0,0 +1,227 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation/tester"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/debugging_runtime/recording"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/local_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/standalone_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+type ReplayPluginPayload struct {
+	RecordingPath string
+	PluginPath    string
+	EnableLogs    bool
+	Timeout       time.Duration
+
+	ResponseFormat string
+}
+
+// replayOutcome is what a plugin answered a request with
+type replayOutcome struct {
+	Responses []map[string]any `json:"responses"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// ReplayPlugin feeds the requests of a recorded debugging session to a local build of the plugin and
+// exits with 1 if any of them is answered differently
+func ReplayPlugin(payload ReplayPluginPayload) {
+	if err := replayPlugin(payload); err != nil {
+		systemLog(GenericResponse{
+			Type:     GENERIC_RESPONSE_TYPE_ERROR,
+			Response: map[string]any{"error": err.Error()},
+		}, payload.ResponseFormat)
+		os.Exit(1)
+	}
+}
+
+func replayPlugin(payload ReplayPluginPayload) error {
+	log.SetLogVisibility(payload.EnableLogs)
+	routine.InitPool(10000)
+
+	recorded, err := recording.Load(payload.RecordingPath)
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("load recording error"))
+	}
+
+	dir, err := os.MkdirTemp(os.TempDir(), "plugin-replay-*")
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("create temp directory error"))
+	}
+	defer standalone_runtime.ClearTestingPath(dir)
+	setupSignalHandler(dir)
+
+	pluginFile, err := os.ReadFile(payload.PluginPath)
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("read plugin file error"))
+	}
+	zipDecoder, err := decoder.NewZipPluginDecoder(pluginFile)
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("decode plugin file error"))
+	}
+	declaration, err := zipDecoder.Manifest()
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("get declaration error"))
+	}
+	if declaration.Name != recorded.Header.Declaration.Name {
+		systemLog(GenericResponse{
+			Type: GENERIC_RESPONSE_TYPE_INFO,
+			Response: map[string]any{"info": fmt.Sprintf(
+				"recording is of plugin %s, replaying it against %s", recorded.Header.Declaration.Name, declaration.Name,
+			)},
+		}, payload.ResponseFormat)
+	}
+
+	systemLog(GenericResponse{
+		Type:     GENERIC_RESPONSE_TYPE_INFO,
+		Response: map[string]any{"info": "loading plugin"},
+	}, payload.ResponseFormat)
+
+	runtime, err := standalone_runtime.GetRuntime(pluginFile, dir)
+	if err != nil {
+		return err
+	}
+	pluginUniqueIdentifier, err := runtime.Identity()
+	if err != nil {
+		return err
+	}
+
+	stdout := client{writer: os.Stdout}
+	mismatched := 0
+	for _, session := range recorded.Sessions {
+		expected := recordedOutcome(session)
+		accessType, action, request := splitRecordedRequest(session.Request)
+
+		actual := replaySession(runtime, &declaration, pluginUniqueIdentifier, accessType, action, request, payload.Timeout)
+		matched := reflect.DeepEqual(expected, actual)
+		if !matched {
+			mismatched++
+		}
+
+		logResponse(GenericResponse{
+			InvokeID: session.ID,
+			Type:     GENERIC_RESPONSE_TYPE_REPLAY_RESULT,
+			Response: map[string]any{
+				"type":     accessType,
+				"action":   action,
+				"matched":  matched,
+				"expected": expected,
+				"actual":   actual,
+			},
+		}, payload.ResponseFormat, stdout)
+	}
+
+	systemLog(GenericResponse{
+		Type: GENERIC_RESPONSE_TYPE_INFO,
+		Response: map[string]any{"info": fmt.Sprintf(
+			"replayed %d requests, %d answered differently", len(recorded.Sessions), mismatched,
+		)},
+	}, payload.ResponseFormat)
+
+	if mismatched > 0 {
+		return fmt.Errorf("%d of %d requests were answered differently", mismatched, len(recorded.Sessions))
+	}
+	return nil
+}
+
+// splitRecordedRequest separates the fields the daemon adds to every request from the request itself
+func splitRecordedRequest(recorded map[string]any) (access_types.PluginAccessType, access_types.PluginAccessAction, map[string]any) {
+	request := map[string]any{}
+	for k, v := range recorded {
+		request[k] = v
+	}
+	accessType, _ := request["type"].(string)
+	action, _ := request["action"].(string)
+	delete(request, "type")
+	delete(request, "action")
+	delete(request, "user_id")
+	return access_types.PluginAccessType(accessType), access_types.PluginAccessAction(action), request
+}
+
+// recordedOutcome is what the debugged plugin answered, backwards invocations are left out as they
+// are answered by mocks on replay
+func recordedOutcome(session recording.Session) replayOutcome {
+	outcome := replayOutcome{Responses: []map[string]any{}}
+	for _, message := range session.Messages {
+		switch message.Type {
+		case plugin_entities.SESSION_MESSAGE_TYPE_STREAM:
+			response, err := parser.UnmarshalJsonBytes[map[string]any](message.Data)
+			if err == nil {
+				outcome.Responses = append(outcome.Responses, response)
+			}
+		case plugin_entities.SESSION_MESSAGE_TYPE_ERROR:
+			e, err := parser.UnmarshalJsonBytes[plugin_entities.ErrorResponse](message.Data)
+			if err == nil {
+				outcome.Error = e.Error()
+			}
+		}
+	}
+	return outcome
+}
+
+func replaySession(
+	runtime *local_runtime.LocalPluginRuntime,
+	declaration *plugin_entities.PluginDeclaration,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	accessType access_types.PluginAccessType,
+	action access_types.PluginAccessAction,
+	request map[string]any,
+	timeout time.Duration,
+) replayOutcome {
+	outcome := replayOutcome{Responses: []map[string]any{}}
+
+	session := session_manager.NewSession(
+		session_manager.NewSessionPayload{
+			UserID:                 uuid.New().String(),
+			TenantID:               uuid.New().String(),
+			PluginUniqueIdentifier: pluginUniqueIdentifier,
+			ClusterID:              uuid.New().String(),
+			InvokeFrom:             accessType,
+			Action:                 action,
+			Declaration:            declaration,
+			BackwardsInvocation:    tester.NewMockedDifyInvocation(),
+			IgnoreCache:            true,
+		},
+	)
+
+	response, err := standalone_runtime.RunOnceWithSession[map[string]any, map[string]any](runtime, session, request)
+	if err != nil {
+		outcome.Error = err.Error()
+		return outcome
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for response.Next() {
+			chunk, err := response.Read()
+			if err != nil {
+				outcome.Error = err.Error()
+				continue
+			}
+			outcome.Responses = append(outcome.Responses, chunk)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		response.Close()
+		<-done
+		outcome.Error = fmt.Sprintf("no response in %s", timeout)
+	}
+	return outcome
+}
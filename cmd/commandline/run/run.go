@@ -11,7 +11,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation/tester"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/local_runtime"
-	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/test_utils"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/standalone_runtime"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
@@ -120,7 +120,7 @@ func handleClient(
 			},
 		)
 
-		stream, err := test_utils.RunOnceWithSession[map[string]any, map[string]any](
+		stream, err := standalone_runtime.RunOnceWithSession[map[string]any, map[string]any](
 			runtime,
 			session,
 			invokePayload.Request,
@@ -206,7 +206,7 @@ func runPlugin(payload RunPluginPayload) error {
 	if err != nil {
 		return errors.Join(err, fmt.Errorf("create temp directory error"))
 	}
-	defer test_utils.ClearTestingPath(dir)
+	defer standalone_runtime.ClearTestingPath(dir)
 
 	// remove the temp directory when the program shuts down
 	setupSignalHandler(dir)
@@ -233,7 +233,7 @@ func runPlugin(payload RunPluginPayload) error {
 	}, payload.ResponseFormat)
 
 	// launch the plugin locally and returns a local runtime
-	runtime, err := test_utils.GetRuntime(pluginFile, dir)
+	runtime, err := standalone_runtime.GetRuntime(pluginFile, dir)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,122 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/test_invoke"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+type TestInvokePluginPayload struct {
+	PluginPath  string
+	Kind        string
+	Provider    string
+	Name        string
+	ModelType   string
+	Parameters  string
+	Credentials string
+	EnableLogs  bool
+	Timeout     time.Duration
+
+	ResponseFormat string
+}
+
+// TestInvokePlugin validates an unpacked plugin directory and invokes one of its tools or models,
+// it exits with 1 if the plugin is invalid or the invocation fails
+func TestInvokePlugin(payload TestInvokePluginPayload) {
+	if err := testInvokePlugin(payload); err != nil {
+		systemLog(GenericResponse{
+			Type:     GENERIC_RESPONSE_TYPE_ERROR,
+			Response: map[string]any{"error": err.Error()},
+		}, payload.ResponseFormat)
+		os.Exit(1)
+	}
+}
+
+func parseJsonFlag(name string, value string) (map[string]any, error) {
+	if value == "" {
+		return map[string]any{}, nil
+	}
+	result, err := parser.UnmarshalJsonBytes[map[string]any]([]byte(value))
+	if err != nil {
+		return nil, errors.Join(err, fmt.Errorf("%s must be a json object", name))
+	}
+	return result, nil
+}
+
+func testInvokePlugin(payload TestInvokePluginPayload) error {
+	log.SetLogVisibility(payload.EnableLogs)
+	routine.InitPool(10000)
+
+	parameters, err := parseJsonFlag("parameters", payload.Parameters)
+	if err != nil {
+		return err
+	}
+	credentials, err := parseJsonFlag("credentials", payload.Credentials)
+	if err != nil {
+		return err
+	}
+
+	target := test_invoke.Target{
+		Kind:        payload.Kind,
+		Provider:    payload.Provider,
+		Name:        payload.Name,
+		ModelType:   payload.ModelType,
+		Parameters:  parameters,
+		Credentials: credentials,
+	}
+
+	stdout := client{writer: os.Stdout}
+	declaration, diagnostics := test_invoke.Validate(payload.PluginPath, target)
+	logResponse(GenericResponse{
+		Type:     GENERIC_RESPONSE_TYPE_DIAGNOSTICS,
+		Response: map[string]any{"diagnostics": diagnostics},
+	}, payload.ResponseFormat, stdout)
+	if test_invoke.HasErrors(diagnostics) {
+		return errors.New("plugin is invalid")
+	}
+
+	systemLog(GenericResponse{
+		Type:     GENERIC_RESPONSE_TYPE_INFO,
+		Response: map[string]any{"info": "loading plugin"},
+	}, payload.ResponseFormat)
+
+	response, err := test_invoke.Invoke(payload.PluginPath, declaration, target)
+	if err != nil {
+		return err
+	}
+
+	var invokeErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for response.Next() {
+			chunk, err := response.Read()
+			if err != nil {
+				invokeErr = err
+				continue
+			}
+			logResponse(GenericResponse{
+				Type:     GENERIC_RESPONSE_TYPE_PLUGIN_RESPONSE,
+				Response: chunk,
+			}, payload.ResponseFormat, stdout)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(payload.Timeout):
+		response.Close()
+		<-done
+		return fmt.Errorf("no response in %s", payload.Timeout)
+	}
+
+	// give the plugin a moment to be stopped before the process exits
+	time.Sleep(100 * time.Millisecond)
+	return invokeErr
+}
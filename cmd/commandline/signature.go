@@ -4,6 +4,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/langgenius/dify-plugin-daemon/cmd/commandline/output"
 	"github.com/langgenius/dify-plugin-daemon/cmd/commandline/signature"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
@@ -31,7 +32,7 @@ var (
 	signatureSignCommand = &cobra.Command{
 		Use:   "sign [difypkg_path]",
 		Short: "Sign a difypkg file",
-		Long:  "Sign a difypkg file with the specified private key",
+		Long:  "Sign a difypkg file with the specified private key or a key on a PKCS#11 hardware token",
 		Args:  cobra.ExactArgs(1),
 		Run: func(c *cobra.Command, args []string) {
 			difypkgPath := args[0]
@@ -46,9 +47,35 @@ var (
 				}
 			}
 
-			err := signature.Sign(difypkgPath, privateKeyPath, &decoder.Verification{
+			keyID := c.Flag("key_id").Value.String()
+			verification := &decoder.Verification{
 				AuthorizedCategory: decoder.AuthorizedCategory(authorizedCategory),
-			})
+			}
+
+			pkcs11Module := c.Flag("pkcs11_module").Value.String()
+			if (privateKeyPath == "") == (pkcs11Module == "") {
+				log.Error("exactly one of private_key and pkcs11_module is required")
+				os.Exit(1)
+			}
+
+			var err error
+			if pkcs11Module != "" {
+				options := signature.PKCS11Options{
+					Module:     pkcs11Module,
+					TokenLabel: c.Flag("pkcs11_token_label").Value.String(),
+					KeyLabel:   c.Flag("pkcs11_key_label").Value.String(),
+					KeyID:      c.Flag("pkcs11_key_id").Value.String(),
+					// read the pin from the environment, avoid exposing it in the process list and shell history
+					Pin: os.Getenv("DIFY_PKCS11_PIN"),
+				}
+				if c.Flags().Changed("pkcs11_slot") {
+					slot, _ := c.Flags().GetInt("pkcs11_slot")
+					options.Slot = &slot
+				}
+				err = signature.SignWithPKCS11(difypkgPath, options, keyID, verification)
+			} else {
+				err = signature.SignWithKeyID(difypkgPath, privateKeyPath, keyID, verification)
+			}
 			if err != nil {
 				os.Exit(1)
 			}
@@ -63,7 +90,20 @@ var (
 		Run: func(c *cobra.Command, args []string) {
 			difypkgPath := args[0]
 			publicKeyPath := c.Flag("public_key").Value.String()
-			err := signature.Verify(difypkgPath, publicKeyPath)
+			keyringPath := c.Flag("keyring").Value.String()
+			var err error
+			if keyringPath != "" {
+				err = signature.VerifyWithKeyring(difypkgPath, keyringPath)
+			} else {
+				err = signature.Verify(difypkgPath, publicKeyPath)
+			}
+			if output.Structured() {
+				result := map[string]any{"verified": err == nil}
+				if err != nil {
+					result["error"] = err.Error()
+				}
+				output.Print(result)
+			}
 			if err != nil {
 				os.Exit(1)
 			}
@@ -76,10 +116,19 @@ func init() {
 	signatureCommand.AddCommand(signatureSignCommand)
 	signatureCommand.AddCommand(signatureVerifyCommand)
 
+	signatureSignCommand.ValidArgsFunction = completeDifypkg
+	signatureVerifyCommand.ValidArgsFunction = completeDifypkg
+
 	signatureGenerateCommand.Flags().StringP("filename", "f", "", "filename of the key pair")
 
 	signatureSignCommand.Flags().StringP("private_key", "p", "", "private key file")
-	signatureSignCommand.MarkFlagRequired("private_key")
+	signatureSignCommand.Flags().String("pkcs11_module", "", "PKCS#11 library of a hardware token holding the key instead of private_key, the pin is read from DIFY_PKCS11_PIN")
+	signatureSignCommand.Flags().String("pkcs11_token_label", "", "label of the PKCS#11 token")
+	signatureSignCommand.Flags().Int("pkcs11_slot", 0, "slot of the PKCS#11 token, used instead of the token label")
+	signatureSignCommand.Flags().String("pkcs11_key_label", "", "label of the key pair on the PKCS#11 token")
+	signatureSignCommand.Flags().String("pkcs11_key_id", "", "hex encoded id of the key pair on the PKCS#11 token")
+	signatureSignCommand.MarkFlagsMutuallyExclusive("private_key", "pkcs11_module")
+	signatureSignCommand.MarkFlagsMutuallyExclusive("pkcs11_token_label", "pkcs11_slot")
 
 	signatureSignCommand.Flags().StringP(
 		"authorized_category",
@@ -87,6 +136,13 @@ func init() {
 		string(decoder.AUTHORIZED_CATEGORY_LANGGENIUS),
 		"authorized category",
 	)
+	signatureSignCommand.RegisterFlagCompletionFunc("authorized_category", cobra.FixedCompletions([]string{
+		string(decoder.AUTHORIZED_CATEGORY_LANGGENIUS),
+		string(decoder.AUTHORIZED_CATEGORY_PARTNER),
+		string(decoder.AUTHORIZED_CATEGORY_COMMUNITY),
+	}, cobra.ShellCompDirectiveNoFileComp))
+	signatureSignCommand.Flags().StringP("key_id", "k", "", "id of the key embedded in the signature, defaults to the key fingerprint")
 
 	signatureVerifyCommand.Flags().StringP("public_key", "p", "", "public key file")
+	signatureVerifyCommand.Flags().String("keyring", "", "yaml file of trusted keys, overrides public_key")
 }
@@ -0,0 +1,15 @@
+package signature
+
+// PKCS11Options locates a RSA key pair on a PKCS#11 token
+type PKCS11Options struct {
+	// Module is the path to the PKCS#11 library of the token, e.g. /usr/lib/softhsm/libsofthsm2.so
+	// or /usr/lib/x86_64-linux-gnu/libykcs11.so for YubiKeys
+	Module string
+	// the token is selected by either its label or its slot
+	TokenLabel string
+	Slot       *int
+	Pin        string
+	// the key pair is selected by its label and/or its CKA_ID in hex
+	KeyLabel string
+	KeyID    string
+}
@@ -0,0 +1,55 @@
+//go:build cgo
+
+package signature
+
+import (
+	"crypto"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/ThalesIgnite/crypto11"
+)
+
+func loadPKCS11Signer(options PKCS11Options) (crypto.Signer, func(), error) {
+	if options.Module == "" {
+		return nil, nil, errors.New("PKCS#11 module is required")
+	}
+	if options.KeyLabel == "" && options.KeyID == "" {
+		return nil, nil, errors.New("either label or id of the PKCS#11 key is required")
+	}
+
+	var id []byte
+	if options.KeyID != "" {
+		var err error
+		if id, err = hex.DecodeString(options.KeyID); err != nil {
+			return nil, nil, fmt.Errorf("PKCS#11 key id must be hex encoded: %w", err)
+		}
+	}
+	var label []byte
+	if options.KeyLabel != "" {
+		label = []byte(options.KeyLabel)
+	}
+
+	ctx, err := crypto11.Configure(&crypto11.Config{
+		Path:       options.Module,
+		TokenLabel: options.TokenLabel,
+		SlotNumber: options.Slot,
+		Pin:        options.Pin,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signer, err := ctx.FindKeyPair(id, label)
+	if err != nil {
+		ctx.Close()
+		return nil, nil, err
+	}
+	if signer == nil {
+		ctx.Close()
+		return nil, nil, errors.New("key pair not found on the PKCS#11 token")
+	}
+
+	return signer, func() { ctx.Close() }, nil
+}
@@ -0,0 +1,13 @@
+//go:build !cgo
+
+package signature
+
+import (
+	"crypto"
+	"errors"
+)
+
+// PKCS#11 modules are shared libraries loaded through cgo
+func loadPKCS11Signer(options PKCS11Options) (crypto.Signer, func(), error) {
+	return nil, nil, errors.New("PKCS#11 is not supported by this build, rebuild the cli with CGO_ENABLED=1")
+}
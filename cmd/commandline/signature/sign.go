@@ -1,6 +1,9 @@
 package signature
 
 import (
+	"crypto"
+	"crypto/rsa"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -13,13 +16,11 @@ import (
 )
 
 func Sign(difypkgPath string, privateKeyPath string, verification *decoder.Verification) error {
-	// read the plugin and private key
-	plugin, err := os.ReadFile(difypkgPath)
-	if err != nil {
-		log.Error("Failed to read plugin file: %v", err)
-		return err
-	}
+	return SignWithKeyID(difypkgPath, privateKeyPath, "", verification)
+}
 
+// SignWithKeyID names the key in the signature block, the fingerprint of the key is used if keyID is empty
+func SignWithKeyID(difypkgPath string, privateKeyPath string, keyID string, verification *decoder.Verification) error {
 	privateKeyBytes, err := os.ReadFile(privateKeyPath)
 	if err != nil {
 		log.Error("Failed to read private key file: %v", err)
@@ -32,8 +33,42 @@ func Sign(difypkgPath string, privateKeyPath string, verification *decoder.Verif
 		return err
 	}
 
+	return signWithSigner(difypkgPath, privateKey, keyID, verification)
+}
+
+// SignWithPKCS11 signs with a key on a PKCS#11 token like a HSM or a YubiKey, the key never leaves the token
+func SignWithPKCS11(difypkgPath string, options PKCS11Options, keyID string, verification *decoder.Verification) error {
+	signer, closer, err := loadPKCS11Signer(options)
+	if err != nil {
+		log.Error("Failed to load key from PKCS#11 token: %v", err)
+		return err
+	}
+	defer closer()
+
+	return signWithSigner(difypkgPath, signer, keyID, verification)
+}
+
+func signWithSigner(difypkgPath string, signer crypto.Signer, keyID string, verification *decoder.Verification) error {
+	// read the plugin
+	plugin, err := os.ReadFile(difypkgPath)
+	if err != nil {
+		log.Error("Failed to read plugin file: %v", err)
+		return err
+	}
+
+	publicKey, ok := signer.Public().(*rsa.PublicKey)
+	if !ok {
+		err := errors.New("only RSA keys are supported")
+		log.Error("Failed to sign plugin: %v", err)
+		return err
+	}
+
+	if keyID == "" {
+		keyID = decoder.PublicKeyID(publicKey)
+	}
+
 	// sign the plugin
-	pluginFile, err := withkey.SignPluginWithPrivateKey(plugin, verification, privateKey)
+	pluginFile, err := withkey.SignPluginWithSigner(plugin, verification, signer, keyID)
 	if err != nil {
 		log.Error("Failed to sign plugin: %v", err)
 		return err
@@ -52,7 +87,7 @@ func Sign(difypkgPath string, privateKeyPath string, verification *decoder.Verif
 		return err
 	}
 
-	log.Info("Plugin signed successfully, output path: %s", outputPath)
+	log.Info("Plugin signed successfully with key %s, output path: %s", keyID, outputPath)
 
 	return nil
 }
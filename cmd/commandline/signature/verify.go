@@ -55,3 +55,35 @@ func Verify(difypkgPath string, publicKeyPath string) error {
 	log.Info("Plugin verified successfully")
 	return nil
 }
+
+// VerifyWithKeyring verifies the plugin with the official public key and every key of the keyring
+func VerifyWithKeyring(difypkgPath string, keyringPath string) error {
+	plugin, err := os.ReadFile(difypkgPath)
+	if err != nil {
+		log.Error("Failed to read plugin file: %v", err)
+		return err
+	}
+
+	decoderInstance, err := decoder.NewZipPluginDecoder(plugin)
+	if err != nil {
+		log.Error("Failed to create plugin decoder, plugin path: %s, error: %v", difypkgPath, err)
+		return err
+	}
+
+	keys, err := decoder.TrustedKeys(&decoder.ThirdPartySignatureVerificationConfig{
+		Enabled:     true,
+		KeyringPath: keyringPath,
+	})
+	if err != nil {
+		log.Error("Failed to load keyring: %v", err)
+		return err
+	}
+
+	if err := decoder.VerifyPluginWithTrustedKeys(decoderInstance, keys); err != nil {
+		log.Error("Failed to verify plugin with keyring: %v", err)
+		return err
+	}
+
+	log.Info("Plugin verified successfully")
+	return nil
+}
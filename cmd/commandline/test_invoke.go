@@ -0,0 +1,47 @@
+package main
+
+import (
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/cmd/commandline/run"
+	"github.com/spf13/cobra"
+)
+
+var (
+	testInvokePluginPayload run.TestInvokePluginPayload
+)
+
+var (
+	testInvokePluginCommand = &cobra.Command{
+		Use:   "test-invoke [plugin_dir]",
+		Short: "test-invoke",
+		Long: `Validate an unpacked plugin directory and invoke one of its tools or models without installing it,
+backwards invocations are answered by mocks.
+Parameters of a tool are its tool parameters, parameters of a model are added to the request,
+like prompt_messages and model_parameters of a llm.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(c *cobra.Command, args []string) {
+			testInvokePluginPayload.PluginPath = args[0]
+			run.TestInvokePlugin(testInvokePluginPayload)
+		},
+	}
+)
+
+func init() {
+	pluginCommand.AddCommand(testInvokePluginCommand)
+
+	testInvokePluginCommand.Flags().StringVarP(&testInvokePluginPayload.Kind, "kind", "k", "tool", "kind of the target, tool or model")
+	testInvokePluginCommand.Flags().StringVarP(&testInvokePluginPayload.Provider, "provider", "p", "", "provider of the target")
+	testInvokePluginCommand.Flags().StringVarP(&testInvokePluginPayload.Name, "name", "n", "", "name of the tool or model")
+	testInvokePluginCommand.Flags().StringVar(&testInvokePluginPayload.ModelType, "model-type", "", "type of the model, llm if empty")
+	testInvokePluginCommand.Flags().StringVar(&testInvokePluginPayload.Parameters, "parameters", "", "parameters as a json object")
+	testInvokePluginCommand.Flags().StringVar(&testInvokePluginPayload.Credentials, "credentials", "", "credentials as a json object")
+	testInvokePluginCommand.Flags().BoolVarP(&testInvokePluginPayload.EnableLogs, "enable-logs", "l", false, "enable logs")
+	testInvokePluginCommand.Flags().StringVarP(&testInvokePluginPayload.ResponseFormat, "response-format", "r", "text", "response format, text or json")
+	testInvokePluginCommand.Flags().DurationVarP(&testInvokePluginPayload.Timeout, "timeout", "t", time.Minute, "max time to wait for the response")
+
+	testInvokePluginCommand.MarkFlagRequired("provider")
+	testInvokePluginCommand.MarkFlagRequired("name")
+	testInvokePluginCommand.RegisterFlagCompletionFunc("kind", cobra.FixedCompletions([]string{"tool", "model"}, cobra.ShellCompDirectiveNoFileComp))
+	testInvokePluginCommand.RegisterFlagCompletionFunc("response-format", cobra.FixedCompletions([]string{"text", "json"}, cobra.ShellCompDirectiveNoFileComp))
+}
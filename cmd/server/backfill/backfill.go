@@ -0,0 +1,73 @@
+package backfill
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/analytics"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+)
+
+const (
+	COMMAND = "analytics-backfill"
+)
+
+// parseTime accepts dates and RFC 3339 timestamps, dates are midnight in UTC
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// Run recomputes invocation rollups of a range, args are the command line arguments after the command
+func Run(config *app.Config, args []string) error {
+	var from, to string
+	flags := flag.NewFlagSet(COMMAND, flag.ContinueOnError)
+	flags.StringVar(&from, "from", "", "start of the range, a date like 2025-01-01 or an RFC 3339 timestamp")
+	flags.StringVar(&to, "to", "", "end of the range, exclusive, defaults to now")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	if from == "" {
+		return errors.New("-from is required")
+	}
+	start, err := parseTime(from)
+	if err != nil {
+		return fmt.Errorf("invalid -from: %w", err)
+	}
+	end := time.Now()
+	if to != "" {
+		if end, err = parseTime(to); err != nil {
+			return fmt.Errorf("invalid -to: %w", err)
+		}
+	}
+	if !start.Before(end) {
+		return errors.New("-from must be before -to")
+	}
+
+	// counts before the retention window are purged, recomputing from them would empty the rollups
+	if config.RetentionInvocationAnalyticsDays > 0 {
+		retained := time.Now().UTC().Add(-time.Duration(config.RetentionInvocationAnalyticsDays) * analytics.DAY).Truncate(analytics.DAY)
+		if start.Before(retained) {
+			return fmt.Errorf("invocation counts before %s have been purged, -from must not be earlier", retained.Format(time.DateOnly))
+		}
+	}
+
+	db.Init(config)
+	defer db.Close()
+
+	if err := analytics.Backfill(start, end); err != nil {
+		return err
+	}
+
+	fmt.Printf("recomputed invocation rollups from %s to %s\n", start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339))
+	return nil
+}
@@ -1,29 +1,71 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
+	"github.com/langgenius/dify-plugin-daemon/cmd/server/backfill"
+	"github.com/langgenius/dify-plugin-daemon/cmd/server/top"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/sandbox"
 	"github.com/langgenius/dify-plugin-daemon/internal/server"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/vault"
 )
 
 func main() {
 	var config app.Config
 
+	// the sandbox starts the plugin right away, nothing of the daemon must be loaded before
+	if len(os.Args) > 1 && os.Args[1] == sandbox.EXEC_COMMAND {
+		if err := sandbox.Exec(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	// load env
 	godotenv.Load()
 
-	err := envconfig.Process("", &config)
+	if len(os.Args) > 1 && os.Args[1] == "top" {
+		if err := top.Run(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	// secrets of the daemon may be kept in vault instead of the environment
+	secrets, err := vault.Load()
+	if err != nil {
+		log.Panic("Error loading secrets from vault: %s", err.Error())
+	}
+
+	err = envconfig.Process("", &config)
 	if err != nil {
 		log.Panic("Error processing environment variables: %s", err.Error())
 	}
 
+	if err := secrets.Apply(&config); err != nil {
+		log.Panic("Error applying secrets from vault: %s", err.Error())
+	}
+
 	config.SetDefault()
 
 	if err := config.Validate(); err != nil {
 		log.Panic("Invalid configuration: %s", err.Error())
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == backfill.COMMAND {
+		if err := backfill.Run(&config, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
 	(&server.App{}).Run(&config)
 }
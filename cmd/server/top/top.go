@@ -0,0 +1,311 @@
+package top
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/http_requests"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	RESET  = "\033[0m"
+	BOLD   = "\033[1m"
+	RED    = "\033[31m"
+	GREEN  = "\033[32m"
+	YELLOW = "\033[33m"
+
+	SORT_BY_NAME        = "name"
+	SORT_BY_INVOCATIONS = "invocations"
+	SORT_BY_ERRORS      = "errors"
+)
+
+var sortOrders = []string{SORT_BY_NAME, SORT_BY_INVOCATIONS, SORT_BY_ERRORS}
+
+type options struct {
+	url      string
+	key      string
+	interval time.Duration
+}
+
+// Run starts the dashboard, args are the command line arguments after `top`
+func Run(args []string) error {
+	port := os.Getenv("SERVER_PORT")
+	if port == "" {
+		port = "5002"
+	}
+
+	opts := options{}
+	flags := flag.NewFlagSet("top", flag.ContinueOnError)
+	flags.StringVar(&opts.url, "url", fmt.Sprintf("http://localhost:%s", port), "address of the daemon")
+	flags.StringVar(&opts.key, "key", os.Getenv("ADMIN_API_KEY"), "admin api key, defaults to ADMIN_API_KEY")
+	flags.DurationVar(&opts.interval, "interval", 2*time.Second, "refresh interval")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	if opts.key == "" {
+		return errors.New("admin api key is required, set ADMIN_API_KEY or pass -key")
+	}
+	if opts.interval < 500*time.Millisecond {
+		opts.interval = 500 * time.Millisecond
+	}
+
+	m := model{
+		options: opts,
+		client:  &http.Client{Timeout: 5 * time.Second},
+	}
+	_, err := tea.NewProgram(m, tea.WithAltScreen()).Run()
+	return err
+}
+
+type statsMsg struct {
+	stats *service.NodeStats
+	err   error
+	// manual refreshes must not schedule another tick
+	manual bool
+}
+
+type tickMsg struct{}
+
+type model struct {
+	options options
+	client  *http.Client
+
+	stats     *service.NodeStats
+	err       error
+	updatedAt time.Time
+	sortOrder int
+}
+
+func (m model) fetch() tea.Msg {
+	resp, err := http_requests.Request(
+		m.client,
+		strings.TrimRight(m.options.url, "/")+"/admin/stats",
+		"GET",
+		http_requests.HttpHeader(map[string]string{
+			constants.X_ADMIN_API_KEY: m.options.key,
+		}),
+	)
+	if err != nil {
+		return statsMsg{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return statsMsg{err: fmt.Errorf("daemon responded with status %d", resp.StatusCode)}
+	}
+
+	response := entities.GenericResponse[service.NodeStats]{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return statsMsg{err: err}
+	}
+	if response.Code != 0 {
+		return statsMsg{err: errors.New(response.Message)}
+	}
+	return statsMsg{stats: &response.Data}
+}
+
+func (m model) tick() tea.Cmd {
+	return tea.Tick(m.options.interval, func(time.Time) tea.Msg {
+		return tickMsg{}
+	})
+}
+
+func (m model) Init() tea.Cmd {
+	return m.fetch
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q":
+			return m, tea.Quit
+		case "s":
+			m.sortOrder = (m.sortOrder + 1) % len(sortOrders)
+		case "r":
+			return m, func() tea.Msg {
+				msg := m.fetch().(statsMsg)
+				msg.manual = true
+				return msg
+			}
+		}
+	case tickMsg:
+		return m, m.fetch
+	case statsMsg:
+		m.err = msg.err
+		if msg.err == nil {
+			m.stats = msg.stats
+			m.updatedAt = time.Now()
+		}
+		if msg.manual {
+			return m, nil
+		}
+		return m, m.tick()
+	}
+
+	return m, nil
+}
+
+type row struct {
+	pluginID    string
+	runtimeType string
+	status      string
+	restarts    int
+	sessions    int
+	invocations int64
+	errors      int64
+	errorRate   float64
+}
+
+// rows joins runtimes with the invocation rates, plugins invoked through other nodes
+// have rates but no runtime on current node
+func (m model) rows() []row {
+	rows := []row{}
+	byPluginID := map[string]int{}
+	for _, runtime := range m.stats.Runtimes {
+		pluginID := runtime.PluginUniqueIdentifier.PluginID()
+		byPluginID[pluginID] = len(rows)
+		rows = append(rows, row{
+			pluginID:    pluginID,
+			runtimeType: string(runtime.Type),
+			status:      runtime.Status,
+			restarts:    runtime.Restarts,
+			sessions:    runtime.Sessions,
+		})
+	}
+	for _, rate := range m.stats.Invocations {
+		i, ok := byPluginID[rate.PluginID]
+		if !ok {
+			i = len(rows)
+			rows = append(rows, row{pluginID: rate.PluginID, runtimeType: "-", status: "-"})
+		}
+		rows[i].invocations = rate.Invocations
+		rows[i].errors = rate.Errors
+		rows[i].errorRate = rate.ErrorRate
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		switch sortOrders[m.sortOrder] {
+		case SORT_BY_INVOCATIONS:
+			if rows[i].invocations != rows[j].invocations {
+				return rows[i].invocations > rows[j].invocations
+			}
+		case SORT_BY_ERRORS:
+			if rows[i].errors != rows[j].errors {
+				return rows[i].errors > rows[j].errors
+			}
+		}
+		return rows[i].pluginID < rows[j].pluginID
+	})
+	return rows
+}
+
+func (m model) View() string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "%sdify-plugin-daemon top%s  %s", BOLD, RESET, m.options.url)
+	if !m.updatedAt.IsZero() {
+		fmt.Fprintf(&b, "  updated %s", m.updatedAt.Format(time.TimeOnly))
+	}
+	b.WriteString("\n")
+	if m.err != nil {
+		fmt.Fprintf(&b, "%serror: %s%s\n", RED, m.err.Error(), RESET)
+	}
+	b.WriteString("\n")
+
+	if m.stats == nil {
+		b.WriteString("waiting for stats...\n")
+		return b.String()
+	}
+
+	stats := m.stats
+	pool := routine.PoolStatus{}
+	if stats.Pool != nil {
+		pool = *stats.Pool
+	}
+	fmt.Fprintf(
+		&b,
+		"runtimes %d  sessions %d  requests %d (dispatch %d)  pool %d/%d busy\n",
+		len(stats.Runtimes),
+		stats.Sessions,
+		stats.ActiveRequests,
+		stats.ActiveDispatchRequests,
+		pool.Busy,
+		pool.Total,
+	)
+	fmt.Fprintf(
+		&b,
+		"install queue  running %d  waiting %d  concurrency %d  owned tasks %d\n\n",
+		stats.InstallQueue.Running,
+		stats.InstallQueue.Waiting,
+		stats.InstallQueue.Concurrency,
+		stats.InstallQueue.OwnedTasks,
+	)
+
+	window := fmt.Sprintf("/%ds", stats.RateWindowSeconds)
+	fmt.Fprintf(
+		&b,
+		"%s%-48s %-10s %-10s %8s %8s %10s %10s %7s%s\n",
+		BOLD,
+		"PLUGIN",
+		"TYPE",
+		"STATUS",
+		"RESTARTS",
+		"SESSIONS",
+		"REQ"+window,
+		"ERR"+window,
+		"ERR%",
+		RESET,
+	)
+	for _, r := range m.rows() {
+		color := ""
+		switch {
+		case r.errorRate >= 0.5 || r.status == plugin_entities.PLUGIN_RUNTIME_STATUS_STOPPED:
+			color = RED
+		case r.errors > 0 || r.restarts > 0:
+			color = YELLOW
+		case r.status == plugin_entities.PLUGIN_RUNTIME_STATUS_ACTIVE:
+			color = GREEN
+		}
+		fmt.Fprintf(
+			&b,
+			"%s%-48s %-10s %-10s %8d %8d %10d %10d %6.1f%%%s\n",
+			color,
+			truncate(r.pluginID, 48),
+			r.runtimeType,
+			r.status,
+			r.restarts,
+			r.sessions,
+			r.invocations,
+			r.errors,
+			r.errorRate*100,
+			RESET,
+		)
+	}
+
+	fmt.Fprintf(&b, "\nsorted by %s  [s] sort  [r] refresh  [q] quit\n", sortOrders[m.sortOrder])
+	return b.String()
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
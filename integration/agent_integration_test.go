@@ -5,7 +5,7 @@ import (
 	"testing"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
-	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/test_utils"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/standalone_runtime"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/agent_entities"
@@ -26,14 +26,14 @@ const (
 func TestDifyOfficialAgentIntegration(t *testing.T) {
 	routine.InitPool(10000)
 
-	defer test_utils.ClearTestingPath(_testingPath)
+	defer standalone_runtime.ClearTestingPath(_testingPath)
 
-	runtime, err := test_utils.GetRuntime(difyOfficialAgent, _testingPath)
+	runtime, err := standalone_runtime.GetRuntime(difyOfficialAgent, _testingPath)
 	assert.NoError(t, err)
 
 	invokePayload, err := parser.UnmarshalJsonBytes2Map(invokeAgentStrategyJson)
 	assert.NoError(t, err)
-	response, err := test_utils.RunOnce[requests.RequestInvokeAgentStrategy, agent_entities.AgentStrategyResponseChunk](
+	response, err := standalone_runtime.RunOnce[requests.RequestInvokeAgentStrategy, agent_entities.AgentStrategyResponseChunk](
 		runtime,
 		access_types.PLUGIN_ACCESS_TYPE_AGENT_STRATEGY,
 		access_types.PLUGIN_ACCESS_ACTION_INVOKE_AGENT_STRATEGY,
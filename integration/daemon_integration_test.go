@@ -0,0 +1,100 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/integration/harness"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	FIXTURE_PLUGIN_ID = "integration/fixture"
+	INSTALL_TIMEOUT   = 5 * time.Minute
+)
+
+func counterValue(t *testing.T, chunks []tool_entities.ToolResponseChunk) float64 {
+	t.Helper()
+
+	for _, chunk := range chunks {
+		if chunk.Type != tool_entities.ToolResponseChunkTypeJson {
+			continue
+		}
+		object, ok := chunk.Message["json_object"].(map[string]any)
+		require.True(t, ok, "json chunk without an object: %v", chunk.Message)
+		count, ok := object["count"].(float64)
+		require.True(t, ok, "json chunk without a count: %v", object)
+		return count
+	}
+
+	t.Fatalf("no json chunk in %v", chunks)
+	return 0
+}
+
+func TestDaemonIntegration(t *testing.T) {
+	dependencies := harness.StartDependencies(t)
+	daemon := harness.StartDaemon(t, dependencies, nil)
+	client := daemon.Client
+
+	tenantID := uuid.NewString()
+	pkg := harness.PackFixture(t, "testdata/fixture_plugin")
+
+	uniqueIdentifier, err := client.UploadPackage(tenantID, pkg)
+	require.NoError(t, err)
+	require.NoError(t, client.Install(tenantID, []string{uniqueIdentifier}, INSTALL_TIMEOUT))
+
+	t.Run("invoke", func(t *testing.T) {
+		chunks, err := client.InvokeTool(tenantID, FIXTURE_PLUGIN_ID, "fixture", "counter", map[string]any{"key": "invoke"})
+		require.NoError(t, err)
+		assert.Equal(t, float64(1), counterValue(t, chunks))
+
+		// the counter is kept in the plugin storage, the second invocation sees the first one
+		chunks, err = client.InvokeTool(tenantID, FIXTURE_PLUGIN_ID, "fixture", "counter", map[string]any{"key": "invoke"})
+		require.NoError(t, err)
+		assert.Equal(t, float64(2), counterValue(t, chunks))
+	})
+
+	var hookID string
+	t.Run("endpoint", func(t *testing.T) {
+		hookID, err = client.SetupEndpoint(tenantID, FIXTURE_PLUGIN_ID, uniqueIdentifier, "echo")
+		require.NoError(t, err)
+
+		status, body, err := client.CallEndpoint(hookID, "/echo", []byte("ping"))
+		require.NoError(t, err)
+		require.Equal(t, 200, status, string(body))
+
+		var echoed map[string]string
+		require.NoError(t, json.Unmarshal(body, &echoed))
+		assert.Equal(t, "/echo", echoed["path"])
+		assert.Equal(t, "ping", echoed["body"])
+	})
+
+	t.Run("persistence", func(t *testing.T) {
+		// everything the daemon knows must come back from postgres, redis and minio after a restart
+		daemon.Restart()
+
+		installations, err := client.ListPlugins(tenantID)
+		require.NoError(t, err)
+		require.Len(t, installations, 1)
+		assert.Equal(t, FIXTURE_PLUGIN_ID, installations[0].PluginID)
+		assert.Equal(t, uniqueIdentifier, installations[0].PluginUniqueIdentifier)
+
+		// the plugin is launched again from the package kept in storage, its storage is intact
+		var chunks []tool_entities.ToolResponseChunk
+		require.Eventually(t, func() bool {
+			chunks, err = client.InvokeTool(tenantID, FIXTURE_PLUGIN_ID, "fixture", "counter", map[string]any{"key": "invoke"})
+			return err == nil
+		}, INSTALL_TIMEOUT, time.Second)
+		assert.Equal(t, float64(3), counterValue(t, chunks))
+
+		if hookID != "" {
+			status, body, err := client.CallEndpoint(hookID, "/echo", []byte("pong"))
+			require.NoError(t, err)
+			assert.Equal(t, 200, status, string(body))
+		}
+	})
+}
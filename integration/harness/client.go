@@ -0,0 +1,294 @@
+package harness
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
+)
+
+// Client calls the http api of a daemon the way Dify does
+type Client struct {
+	baseURL   string
+	serverKey string
+	http      *http.Client
+}
+
+type response[T any] struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    T      `json:"data"`
+}
+
+func (r *response[T]) err() error {
+	if r.Code != 0 {
+		return fmt.Errorf("code %d: %s", r.Code, r.Message)
+	}
+	return nil
+}
+
+func (c *Client) do(method string, path string, contentType string, body io.Reader, headers map[string]string) (*http.Response, error) {
+	request, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set(constants.X_API_KEY, c.serverKey)
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+	return c.http.Do(request)
+}
+
+func call[T any](c *Client, method string, path string, payload any) (T, error) {
+	var body io.Reader
+	contentType := ""
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		body = bytes.NewReader(encoded)
+		contentType = "application/json"
+	}
+
+	httpResponse, err := c.do(method, path, contentType, body, nil)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return decode[T](httpResponse)
+}
+
+func decode[T any](httpResponse *http.Response) (T, error) {
+	defer httpResponse.Body.Close()
+
+	var result response[T]
+	if err := json.NewDecoder(httpResponse.Body).Decode(&result); err != nil {
+		return result.Data, fmt.Errorf("failed to decode response with status %d: %w", httpResponse.StatusCode, err)
+	}
+	return result.Data, result.err()
+}
+
+// Healthy reports whether the daemon answers its health check
+func (c *Client) Healthy() bool {
+	httpResponse, err := c.do(http.MethodGet, "/health/check", "", nil, nil)
+	if err != nil {
+		return false
+	}
+	defer httpResponse.Body.Close()
+	return httpResponse.StatusCode == http.StatusOK
+}
+
+// UploadPackage uploads a package and returns its unique identifier
+func (c *Client) UploadPackage(tenantID string, pkg []byte) (string, error) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("dify_pkg", "plugin.difypkg")
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(pkg); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	httpResponse, err := c.do(
+		http.MethodPost,
+		"/plugin/"+tenantID+"/management/install/upload/package",
+		writer.FormDataContentType(),
+		body,
+		nil,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	uploaded, err := decode[struct {
+		UniqueIdentifier string `json:"unique_identifier"`
+	}](httpResponse)
+	return uploaded.UniqueIdentifier, err
+}
+
+// Install installs uploaded packages and waits until the install task finishes
+func (c *Client) Install(tenantID string, uniqueIdentifiers []string, timeout time.Duration) error {
+	metas := make([]map[string]any, len(uniqueIdentifiers))
+	for i := range metas {
+		metas[i] = map[string]any{}
+	}
+
+	installed, err := call[struct {
+		AllInstalled bool   `json:"all_installed"`
+		TaskID       string `json:"task_id"`
+	}](c, http.MethodPost, "/plugin/"+tenantID+"/management/install/identifiers", map[string]any{
+		"plugin_unique_identifiers": uniqueIdentifiers,
+		"source":                    "package",
+		"metas":                     metas,
+	})
+	if err != nil {
+		return err
+	}
+	if installed.AllInstalled {
+		return nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		task, err := call[models.InstallTask](c, http.MethodGet, "/plugin/"+tenantID+"/management/install/tasks/"+installed.TaskID, nil)
+		if err != nil {
+			return err
+		}
+
+		switch task.Status {
+		case models.InstallTaskStatusSuccess:
+			return nil
+		case models.InstallTaskStatusFailed:
+			messages := []string{}
+			for _, plugin := range task.Plugins {
+				if plugin.Message != "" {
+					messages = append(messages, plugin.PluginUniqueIdentifier.String()+": "+plugin.Message)
+				}
+			}
+			return fmt.Errorf("install task failed: %s", strings.Join(messages, "; "))
+		}
+		time.Sleep(time.Second)
+	}
+
+	return fmt.Errorf("install task %s is not finished after %s", installed.TaskID, timeout)
+}
+
+// Installation is an installed plugin as listed by the management api
+type Installation struct {
+	PluginID               string `json:"plugin_id"`
+	PluginUniqueIdentifier string `json:"plugin_unique_identifier"`
+	EndpointsSetups        int    `json:"endpoints_setups"`
+}
+
+// ListPlugins returns the plugins installed for a tenant
+func (c *Client) ListPlugins(tenantID string) ([]Installation, error) {
+	list, err := call[struct {
+		List []Installation `json:"list"`
+	}](c, http.MethodGet, "/plugin/"+tenantID+"/management/list?page=1&page_size=256", nil)
+	return list.List, err
+}
+
+// InvokeTool invokes a tool and collects every chunk of its response
+func (c *Client) InvokeTool(
+	tenantID string,
+	pluginID string,
+	provider string,
+	tool string,
+	parameters map[string]any,
+) ([]tool_entities.ToolResponseChunk, error) {
+	encoded, err := json.Marshal(map[string]any{
+		"user_id": "integration",
+		"data": map[string]any{
+			"provider":        provider,
+			"tool":            tool,
+			"tool_parameters": parameters,
+			"credentials":     map[string]any{},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	httpResponse, err := c.do(
+		http.MethodPost,
+		"/plugin/"+tenantID+"/dispatch/tool/invoke",
+		"application/json",
+		bytes.NewReader(encoded),
+		map[string]string{constants.X_PLUGIN_ID: pluginID},
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(httpResponse.Body)
+		return nil, fmt.Errorf("unexpected status %d: %s", httpResponse.StatusCode, body)
+	}
+
+	chunks := []tool_entities.ToolResponseChunk{}
+	scanner := bufio.NewScanner(httpResponse.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+
+		var chunk response[tool_entities.ToolResponseChunk]
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return chunks, err
+		}
+		if err := chunk.err(); err != nil {
+			return chunks, err
+		}
+		chunks = append(chunks, chunk.Data)
+	}
+
+	return chunks, scanner.Err()
+}
+
+// SetupEndpoint creates an endpoint of a plugin and returns its hook id
+func (c *Client) SetupEndpoint(tenantID string, pluginID string, uniqueIdentifier string, name string) (string, error) {
+	if _, err := call[any](c, http.MethodPost, "/plugin/"+tenantID+"/endpoint/setup", map[string]any{
+		"plugin_unique_identifier": uniqueIdentifier,
+		"user_id":                  "integration",
+		"settings":                 map[string]any{},
+		"name":                     name,
+	}); err != nil {
+		return "", err
+	}
+
+	endpoints, err := call[[]models.Endpoint](c, http.MethodGet, "/plugin/"+tenantID+"/endpoint/list/plugin?"+url.Values{
+		"plugin_id": {pluginID},
+		"page":      {"1"},
+		"page_size": {"100"},
+	}.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	for _, endpoint := range endpoints {
+		if endpoint.Name == name {
+			return endpoint.HookID, nil
+		}
+	}
+	return "", errors.New("the endpoint is not listed after setup")
+}
+
+// CallEndpoint sends a request to an endpoint the way an external service would
+func (c *Client) CallEndpoint(hookID string, path string, body []byte) (int, []byte, error) {
+	request, err := http.NewRequest(http.MethodPost, c.baseURL+"/e/"+hookID+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	httpResponse, err := c.http.Do(request)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer httpResponse.Body.Close()
+
+	responseBody, err := io.ReadAll(httpResponse.Body)
+	return httpResponse.StatusCode, responseBody, err
+}
@@ -0,0 +1,205 @@
+package harness
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/network"
+)
+
+const (
+	SERVER_KEY         = "integration-test-server-key"
+	DIFY_INNER_API_KEY = "integration-test-inner-api-key"
+
+	BOOT_TIMEOUT = 2 * time.Minute
+	STOP_TIMEOUT = 30 * time.Second
+)
+
+var (
+	buildOnce   sync.Once
+	buildBinary string
+	buildErr    error
+)
+
+// moduleRoot is the root of the repository, the harness lives two levels below it
+func moduleRoot() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..")
+}
+
+// daemonBinary builds the daemon once per test process
+func daemonBinary(t testing.TB) string {
+	t.Helper()
+
+	buildOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "dify-plugin-daemon-integration")
+		if err != nil {
+			buildErr = err
+			return
+		}
+		buildBinary = filepath.Join(dir, "dify-plugin-daemon")
+
+		cmd := exec.Command("go", "build", "-o", buildBinary, "./cmd/server")
+		cmd.Dir = moduleRoot()
+		if output, err := cmd.CombinedOutput(); err != nil {
+			buildErr = fmt.Errorf("%s: %s", err.Error(), output)
+		}
+	})
+
+	if buildErr != nil {
+		t.Fatalf("failed to build the daemon: %s", buildErr.Error())
+	}
+	return buildBinary
+}
+
+// Daemon is a plugin daemon process, its working directory survives restarts
+type Daemon struct {
+	t       testing.TB
+	env     map[string]string
+	workDir string
+	port    uint16
+	cmd     *exec.Cmd
+	exited  chan struct{}
+
+	Client *Client
+}
+
+// StartDaemon boots a daemon against the dependencies and waits until it's healthy,
+// extra overrides or adds environment variables
+func StartDaemon(t testing.TB, dependencies *Dependencies, extra map[string]string) *Daemon {
+	t.Helper()
+
+	port, err := network.GetRandomPort()
+	if err != nil {
+		t.Fatalf("failed to get a port: %s", err.Error())
+	}
+
+	env := dependencies.Env()
+	env["SERVER_PORT"] = strconv.Itoa(int(port))
+	env["SERVER_KEY"] = SERVER_KEY
+	env["PLATFORM"] = "local"
+	// backwards invocations into Dify are not exercised, the address only has to be valid
+	env["DIFY_INNER_API_URL"] = "http://127.0.0.1:1"
+	env["DIFY_INNER_API_KEY"] = DIFY_INNER_API_KEY
+	env["PLUGIN_REMOTE_INSTALLING_ENABLED"] = "false"
+	env["SHUTDOWN_DRAIN_TIMEOUT"] = "5"
+	for _, key := range []string{"PYTHON_INTERPRETER_PATH", "UV_PATH", "PIP_MIRROR_URL"} {
+		if value := os.Getenv(key); value != "" {
+			env[key] = value
+		}
+	}
+	for key, value := range extra {
+		env[key] = value
+	}
+
+	daemon := &Daemon{
+		t:       t,
+		env:     env,
+		workDir: t.TempDir(),
+		port:    port,
+	}
+	daemon.Client = &Client{
+		baseURL:   fmt.Sprintf("http://127.0.0.1:%d", port),
+		serverKey: SERVER_KEY,
+		http:      &http.Client{Timeout: BOOT_TIMEOUT},
+	}
+
+	daemon.start()
+	t.Cleanup(daemon.Stop)
+	return daemon
+}
+
+func (d *Daemon) start() {
+	d.t.Helper()
+
+	// relative paths of the config, e.g. the plugin working path, end up in the working directory
+	d.cmd = exec.Command(daemonBinary(d.t))
+	d.cmd.Dir = d.workDir
+	d.cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "HOME=" + os.Getenv("HOME")}
+	for key, value := range d.env {
+		d.cmd.Env = append(d.cmd.Env, key+"="+value)
+	}
+
+	logFile, err := os.OpenFile(filepath.Join(d.workDir, "daemon.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		d.t.Fatalf("failed to open daemon log: %s", err.Error())
+	}
+	d.cmd.Stdout = logFile
+	d.cmd.Stderr = logFile
+
+	if err := d.cmd.Start(); err != nil {
+		logFile.Close()
+		d.t.Fatalf("failed to start the daemon: %s", err.Error())
+	}
+
+	exited := make(chan struct{})
+	d.exited = exited
+	cmd := d.cmd
+	go func() {
+		cmd.Wait()
+		logFile.Close()
+		close(exited)
+	}()
+
+	deadline := time.Now().Add(BOOT_TIMEOUT)
+	for time.Now().Before(deadline) {
+		select {
+		case <-exited:
+			d.t.Fatalf("the daemon exited while booting:\n%s", d.Logs())
+		default:
+		}
+
+		if d.Client.Healthy() {
+			return
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	d.t.Fatalf("the daemon is not healthy after %s:\n%s", BOOT_TIMEOUT, d.Logs())
+}
+
+// Stop terminates the daemon gracefully and kills it if it doesn't exit in time
+func (d *Daemon) Stop() {
+	if d.cmd == nil {
+		return
+	}
+
+	d.cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-d.exited:
+	case <-time.After(STOP_TIMEOUT):
+		d.cmd.Process.Kill()
+		<-d.exited
+	}
+	d.cmd = nil
+
+	if d.t.Failed() {
+		d.t.Logf("daemon logs:\n%s", d.Logs())
+	}
+}
+
+// Restart stops the daemon and boots it again with the same environment and working directory
+func (d *Daemon) Restart() {
+	d.t.Helper()
+
+	d.Stop()
+	d.start()
+}
+
+// Logs returns everything the daemon has written so far
+func (d *Daemon) Logs() string {
+	logs, err := os.ReadFile(filepath.Join(d.workDir, "daemon.log"))
+	if err != nil {
+		return err.Error()
+	}
+	return string(logs)
+}
@@ -0,0 +1,206 @@
+// Package harness provisions the external dependencies of the plugin daemon with dockertest and
+// boots the daemon binary against them, so integration tests exercise the same paths as production
+package harness
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	POSTGRES_IMAGE = "postgres"
+	POSTGRES_TAG   = "15-alpine"
+	REDIS_IMAGE    = "redis"
+	REDIS_TAG      = "7-alpine"
+	MINIO_IMAGE    = "minio/minio"
+	MINIO_TAG      = "RELEASE.2024-06-13T22-53-53Z"
+
+	DB_USERNAME = "postgres"
+	DB_PASSWORD = "difyai123456"
+	DB_DATABASE = "dify_plugin"
+
+	REDIS_PASSWORD = "difyai123456"
+
+	MINIO_ACCESS_KEY = "minioadmin"
+	MINIO_SECRET_KEY = "minioadmin"
+	MINIO_BUCKET     = "dify-plugins"
+	MINIO_REGION     = "us-east-1"
+
+	// containers are removed by docker after this long even if the test process was killed
+	CONTAINER_EXPIRY = 15 * time.Minute
+	READY_TIMEOUT    = 2 * time.Minute
+)
+
+// Endpoint is where a container is reachable from the host
+type Endpoint struct {
+	Host string
+	Port uint16
+}
+
+func (e Endpoint) Address() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(int(e.Port)))
+}
+
+// Dependencies are the external services a daemon needs, they are shared by every daemon booted
+// against them so that restarts find the state left by the previous run
+type Dependencies struct {
+	Postgres Endpoint
+	Redis    Endpoint
+	MinIO    Endpoint
+}
+
+// Env returns the environment variables pointing a daemon at the dependencies
+func (d *Dependencies) Env() map[string]string {
+	return map[string]string{
+		"DB_TYPE":     "postgresql",
+		"DB_HOST":     d.Postgres.Host,
+		"DB_PORT":     strconv.Itoa(int(d.Postgres.Port)),
+		"DB_USERNAME": DB_USERNAME,
+		"DB_PASSWORD": DB_PASSWORD,
+		"DB_DATABASE": DB_DATABASE,
+
+		"REDIS_HOST":     d.Redis.Host,
+		"REDIS_PORT":     strconv.Itoa(int(d.Redis.Port)),
+		"REDIS_PASSWORD": REDIS_PASSWORD,
+
+		"PLUGIN_STORAGE_TYPE":       "aws_s3",
+		"PLUGIN_STORAGE_OSS_BUCKET": MINIO_BUCKET,
+		"S3_USE_AWS":                "false",
+		"S3_ENDPOINT":               "http://" + d.MinIO.Address(),
+		"S3_USE_PATH_STYLE":         "true",
+		"AWS_ACCESS_KEY":            MINIO_ACCESS_KEY,
+		"AWS_SECRET_KEY":            MINIO_SECRET_KEY,
+		"AWS_REGION":                MINIO_REGION,
+	}
+}
+
+// StartDependencies runs Postgres, Redis and MinIO and waits until they accept connections,
+// the test is skipped if docker is unavailable and the containers are purged on cleanup
+func StartDependencies(t testing.TB) *Dependencies {
+	t.Helper()
+
+	if testing.Short() {
+		t.Skip("integration tests are skipped in short mode")
+	}
+
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		t.Skipf("docker is unavailable: %s", err.Error())
+	}
+	if err := pool.Client.Ping(); err != nil {
+		t.Skipf("docker is unavailable: %s", err.Error())
+	}
+	pool.MaxWait = READY_TIMEOUT
+
+	postgres := run(t, pool, &dockertest.RunOptions{
+		Repository: POSTGRES_IMAGE,
+		Tag:        POSTGRES_TAG,
+		Env: []string{
+			"POSTGRES_USER=" + DB_USERNAME,
+			"POSTGRES_PASSWORD=" + DB_PASSWORD,
+			"POSTGRES_DB=" + DB_DATABASE,
+		},
+	}, "5432/tcp")
+
+	redisEndpoint := run(t, pool, &dockertest.RunOptions{
+		Repository: REDIS_IMAGE,
+		Tag:        REDIS_TAG,
+		Cmd:        []string{"redis-server", "--requirepass", REDIS_PASSWORD},
+	}, "6379/tcp")
+
+	minio := run(t, pool, &dockertest.RunOptions{
+		Repository: MINIO_IMAGE,
+		Tag:        MINIO_TAG,
+		Cmd:        []string{"server", "/data"},
+		Env: []string{
+			"MINIO_ROOT_USER=" + MINIO_ACCESS_KEY,
+			"MINIO_ROOT_PASSWORD=" + MINIO_SECRET_KEY,
+		},
+	}, "9000/tcp")
+
+	dependencies := &Dependencies{
+		Postgres: postgres,
+		Redis:    redisEndpoint,
+		MinIO:    minio,
+	}
+
+	if err := pool.Retry(func() error {
+		conn, err := sql.Open("pgx", fmt.Sprintf(
+			"postgres://%s:%s@%s/%s?sslmode=disable", DB_USERNAME, DB_PASSWORD, postgres.Address(), DB_DATABASE,
+		))
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		return conn.Ping()
+	}); err != nil {
+		t.Fatalf("postgres is not ready: %s", err.Error())
+	}
+
+	if err := pool.Retry(func() error {
+		client := redis.NewClient(&redis.Options{Addr: redisEndpoint.Address(), Password: REDIS_PASSWORD})
+		defer client.Close()
+		return client.Ping(context.Background()).Err()
+	}); err != nil {
+		t.Fatalf("redis is not ready: %s", err.Error())
+	}
+
+	if err := pool.Retry(func() error {
+		response, err := http.Get("http://" + minio.Address() + "/minio/health/live")
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status %d", response.StatusCode)
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("minio is not ready: %s", err.Error())
+	}
+
+	return dependencies
+}
+
+func run(t testing.TB, pool *dockertest.Pool, options *dockertest.RunOptions, port string) Endpoint {
+	t.Helper()
+
+	resource, err := pool.RunWithOptions(options, func(config *docker.HostConfig) {
+		config.AutoRemove = true
+		config.RestartPolicy = docker.RestartPolicy{Name: "no"}
+	})
+	if err != nil {
+		t.Fatalf("failed to start %s: %s", options.Repository, err.Error())
+	}
+	t.Cleanup(func() {
+		if err := pool.Purge(resource); err != nil {
+			t.Logf("failed to purge %s: %s", options.Repository, err.Error())
+		}
+	})
+
+	if err := resource.Expire(uint(CONTAINER_EXPIRY.Seconds())); err != nil {
+		t.Fatalf("failed to set expiry of %s: %s", options.Repository, err.Error())
+	}
+
+	host, portString, err := net.SplitHostPort(resource.GetHostPort(port))
+	if err != nil {
+		t.Fatalf("failed to get host port of %s: %s", options.Repository, err.Error())
+	}
+	hostPort, err := strconv.ParseUint(portString, 10, 16)
+	if err != nil {
+		t.Fatalf("invalid host port of %s: %s", options.Repository, err.Error())
+	}
+
+	return Endpoint{Host: host, Port: uint16(hostPort)}
+}
@@ -0,0 +1,28 @@
+package harness
+
+import (
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/packager"
+)
+
+const (
+	MAX_FIXTURE_SIZE = 50 * 1024 * 1024
+)
+
+// PackFixture packs the plugin source in dir into a .difypkg
+func PackFixture(t testing.TB, dir string) []byte {
+	t.Helper()
+
+	fsDecoder, err := decoder.NewFSPluginDecoder(dir)
+	if err != nil {
+		t.Fatalf("failed to decode fixture %s: %s", dir, err.Error())
+	}
+
+	pkg, err := packager.NewPackager(fsDecoder).Pack(MAX_FIXTURE_SIZE)
+	if err != nil {
+		t.Fatalf("failed to pack fixture %s: %s", dir, err.Error())
+	}
+	return pkg
+}
@@ -0,0 +1,15 @@
+import json
+from collections.abc import Mapping
+
+from werkzeug import Request, Response
+
+from dify_plugin import Endpoint
+
+
+class EchoEndpoint(Endpoint):
+    def _invoke(self, r: Request, values: Mapping, settings: Mapping) -> Response:
+        return Response(
+            json.dumps({"path": r.path, "body": r.get_data(as_text=True)}),
+            status=200,
+            content_type="application/json",
+        )
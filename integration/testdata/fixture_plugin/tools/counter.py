@@ -0,0 +1,16 @@
+from collections.abc import Generator
+from typing import Any
+
+from dify_plugin import Tool
+from dify_plugin.entities.tool import ToolInvokeMessage
+
+
+class CounterTool(Tool):
+    def _invoke(self, tool_parameters: dict[str, Any]) -> Generator[ToolInvokeMessage]:
+        key = tool_parameters["key"]
+        count = 0
+        if self.session.storage.exist(key):
+            count = int(self.session.storage.get(key).decode())
+        count += 1
+        self.session.storage.set(key, str(count).encode())
+        yield self.create_json_message({"key": key, "count": count})
@@ -15,8 +15,10 @@ type Cluster struct {
 	// id is the unique id of the cluster
 	id string
 
-	// i_am_master is the flag to indicate whether the current node is the master node
-	iAmMaster bool
+	// i_am_master is the flag to indicate whether the current node is the master node,
+	// it's only trusted until masterLeaseUntil which is in unix nanoseconds
+	iAmMaster        atomic.Bool
+	masterLeaseUntil atomic.Int64
 
 	// main http port of the current node
 	port uint16
@@ -30,6 +32,10 @@ type Cluster struct {
 	// nodes stores all the nodes of the cluster
 	nodes mapping.Map[string, node]
 
+	// ring assigns plugins to the alive nodes, each plugin is assigned to assignmentReplicas nodes
+	ring               atomic.Pointer[hashRing]
+	assignmentReplicas int
+
 	// signals for waiting for the cluster to stop
 	stopChan chan bool
 	stopped  int32
@@ -68,6 +74,7 @@ func NewCluster(config *app.Config, plugin_manager *plugin_manager.PluginManager
 		port:                          uint16(config.ServerPort),
 		stopChan:                      make(chan bool),
 		showLog:                       config.DisplayClusterLog,
+		assignmentReplicas:            max(config.PluginAssignmentReplicas, 1),
 		masterGcInterval:              MASTER_GC_INTERVAL,
 		masterLockingInterval:         MASTER_LOCKING_INTERVAL,
 		masterLockExpiredTime:         MASTER_LOCK_EXPIRED_TIME,
@@ -46,6 +46,9 @@ const (
 // lifetime of the cluster
 func (c *Cluster) clusterLifetime() {
 	defer func() {
+		if err := c.releaseMaster(); err != nil {
+			log.Error("failed to release the master slot: %s", err.Error())
+		}
 		if err := c.removeSelfNode(); err != nil {
 			log.Error("failed to remove the self node from the cluster: %s", err.Error())
 		}
@@ -104,24 +107,24 @@ func (c *Cluster) clusterLifetime() {
 	for {
 		select {
 		case <-tickerLockMaster.C:
-			if !c.iAmMaster {
+			if !c.iAmMaster.Load() {
 				// try lock the slot
 				if success, err := c.lockMaster(); err != nil {
 					log.Error("failed to lock the slot to be the master of the cluster: %s", err.Error())
 				} else if success {
-					c.iAmMaster = true
+					c.iAmMaster.Store(true)
 					log.Info("current node has become the master of the cluster")
 					c.notifyBecomeMaster()
-				} else {
-					if c.iAmMaster {
-						c.iAmMaster = false
-						log.Info("current node has released the master slot")
-					}
 				}
-			} else {
-				// update the master
-				if err := c.updateMaster(); err != nil {
-					log.Error("failed to update the master: %s", err.Error())
+			} else if err := c.updateMaster(); err == ErrMasterLockLost {
+				c.iAmMaster.Store(false)
+				log.Warn("current node has lost the master slot to another node, stepping down")
+			} else if err != nil {
+				log.Error("failed to update the master: %s", err.Error())
+				if !c.IsMaster() {
+					// the lease ran out, another node may already hold the slot
+					c.iAmMaster.Store(false)
+					log.Warn("current node failed to renew the master lease in time, stepping down")
 				}
 			}
 		case <-tickerUpdateNodeStatus.C:
@@ -129,7 +132,7 @@ func (c *Cluster) clusterLifetime() {
 				log.Error("failed to update the status of the node: %s", err.Error())
 			}
 		case <-masterGcTicker.C:
-			if c.iAmMaster {
+			if c.IsMaster() {
 				c.notifyMasterGC()
 				if err := c.autoGCNodes(); err != nil {
 					log.Error("failed to gc the nodes have already deactivated: %s", err.Error())
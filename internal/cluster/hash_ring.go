@@ -0,0 +1,169 @@
+package cluster
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	// every node is placed on the ring this many times to spread plugins evenly
+	HASH_RING_VIRTUAL_NODES = 128
+)
+
+type hashRingPoint struct {
+	hash   uint64
+	nodeId string
+}
+
+// hashRing assigns keys to nodes by consistent hashing, when a node joins or leaves
+// only the keys next to its points move
+type hashRing struct {
+	points  []hashRingPoint
+	nodeIds []string
+}
+
+func hashRingKey(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+func newHashRing(nodeIds []string, virtualNodes int) *hashRing {
+	ring := &hashRing{
+		points:  make([]hashRingPoint, 0, len(nodeIds)*virtualNodes),
+		nodeIds: slices.Clone(nodeIds),
+	}
+	slices.Sort(ring.nodeIds)
+
+	for _, nodeId := range ring.nodeIds {
+		for i := 0; i < virtualNodes; i++ {
+			ring.points = append(ring.points, hashRingPoint{
+				hash:   hashRingKey(nodeId + "#" + strconv.Itoa(i)),
+				nodeId: nodeId,
+			})
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool {
+		if ring.points[i].hash == ring.points[j].hash {
+			return ring.points[i].nodeId < ring.points[j].nodeId
+		}
+		return ring.points[i].hash < ring.points[j].hash
+	})
+
+	return ring
+}
+
+// lookup returns up to n distinct nodes of the key, the first one is the primary
+func (r *hashRing) lookup(key string, n int) []string {
+	if len(r.points) == 0 || n <= 0 {
+		return nil
+	}
+	n = min(n, len(r.nodeIds))
+
+	hash := hashRingKey(key)
+	start := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= hash
+	})
+
+	nodes := make([]string, 0, n)
+	for i := 0; i < len(r.points) && len(nodes) < n; i++ {
+		point := r.points[(start+i)%len(r.points)]
+		if !slices.Contains(nodes, point.nodeId) {
+			nodes = append(nodes, point.nodeId)
+		}
+	}
+	return nodes
+}
+
+func (r *hashRing) members() string {
+	return strings.Join(r.nodeIds, ",")
+}
+
+// updateHashRing rebuilds the ring when the alive nodes changed and rebalances local plugins
+func (c *Cluster) updateHashRing(nodes map[string]node) {
+	nodeIds := make([]string, 0, len(nodes))
+	for nodeId := range nodes {
+		nodeIds = append(nodeIds, nodeId)
+	}
+
+	ring := newHashRing(nodeIds, HASH_RING_VIRTUAL_NODES)
+	if previous := c.ring.Load(); previous != nil && previous.members() == ring.members() {
+		return
+	}
+	c.ring.Store(ring)
+
+	if c.showLog {
+		log.Info("cluster membership changed, %d nodes on the hash ring", len(nodeIds))
+	}
+
+	if c.manager != nil {
+		routine.Submit(map[string]string{
+			"module":   "cluster",
+			"function": "rebalanceLocalPlugins",
+		}, c.manager.RebalanceLocalPlugins)
+	}
+}
+
+// AssignedNodes returns the nodes a plugin is assigned to, nil before the node status is synchronized
+func (c *Cluster) AssignedNodes(identity plugin_entities.PluginUniqueIdentifier) []string {
+	ring := c.ring.Load()
+	if ring == nil {
+		return nil
+	}
+	return ring.lookup(plugin_entities.HashedIdentity(identity.String()), c.assignmentReplicas)
+}
+
+// IsPluginAssignedToCurrentNode reports whether the current node should run the plugin
+func (c *Cluster) IsPluginAssignedToCurrentNode(identity plugin_entities.PluginUniqueIdentifier) bool {
+	return slices.Contains(c.AssignedNodes(identity), c.id)
+}
+
+// IsPluginRunningOnAssignedNodes reports whether all the assigned nodes except the current one
+// are running the plugin, the current node hands the plugin over to them only after that
+func (c *Cluster) IsPluginRunningOnAssignedNodes(identity plugin_entities.PluginUniqueIdentifier) bool {
+	assigned := c.AssignedNodes(identity)
+	if len(assigned) == 0 {
+		return false
+	}
+
+	running, err := c.FetchPluginAvailableNodesById(identity.String())
+	if err != nil {
+		return false
+	}
+
+	for _, nodeId := range assigned {
+		if nodeId != c.id && !slices.Contains(running, nodeId) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortNodesByAssignment moves the assigned nodes of the plugin to the front in ring order
+func (c *Cluster) sortNodesByAssignment(hashedPluginId string, nodeIds []string) []string {
+	ring := c.ring.Load()
+	if ring == nil {
+		return nodeIds
+	}
+
+	sorted := make([]string, 0, len(nodeIds))
+	for _, nodeId := range ring.lookup(hashedPluginId, len(ring.nodeIds)) {
+		if slices.Contains(nodeIds, nodeId) {
+			sorted = append(sorted, nodeId)
+		}
+	}
+	// nodes missing from the ring joined after it was built
+	for _, nodeId := range nodeIds {
+		if !slices.Contains(sorted, nodeId) {
+			sorted = append(sorted, nodeId)
+		}
+	}
+	return sorted
+}
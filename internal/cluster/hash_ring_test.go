@@ -0,0 +1,88 @@
+package cluster
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func TestHashRingLookup(t *testing.T) {
+	ring := newHashRing([]string{"node-a", "node-b", "node-c"}, HASH_RING_VIRTUAL_NODES)
+
+	counts := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		nodes := ring.lookup(fmt.Sprintf("plugin-%d", i), 2)
+		if len(nodes) != 2 || nodes[0] == nodes[1] {
+			t.Fatalf("expected 2 distinct nodes, got %v", nodes)
+		}
+		counts[nodes[0]]++
+	}
+
+	// every node should own roughly a third of the plugins
+	for nodeId, count := range counts {
+		if count < 700 || count > 1300 {
+			t.Fatalf("node %s owns %d of 3000 plugins, the ring is unbalanced", nodeId, count)
+		}
+	}
+
+	if nodes := ring.lookup("plugin", 5); len(nodes) != 3 {
+		t.Fatalf("expected replicas to be capped by the number of nodes, got %v", nodes)
+	}
+
+	if nodes := newHashRing(nil, HASH_RING_VIRTUAL_NODES).lookup("plugin", 1); nodes != nil {
+		t.Fatalf("expected no nodes on an empty ring, got %v", nodes)
+	}
+}
+
+func TestHashRingMembershipChange(t *testing.T) {
+	before := newHashRing([]string{"node-a", "node-b", "node-c"}, HASH_RING_VIRTUAL_NODES)
+	after := newHashRing([]string{"node-c", "node-b", "node-a", "node-d"}, HASH_RING_VIRTUAL_NODES)
+
+	moved := 0
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("plugin-%d", i)
+		previous, current := before.lookup(key, 1)[0], after.lookup(key, 1)[0]
+		if previous != current {
+			if current != "node-d" {
+				t.Fatalf("%s moved from %s to %s, only moves to the new node are expected", key, previous, current)
+			}
+			moved++
+		}
+	}
+
+	// about a quarter of the plugins move to the new node
+	if moved < 450 || moved > 1050 {
+		t.Fatalf("%d of 3000 plugins moved to the new node", moved)
+	}
+}
+
+func TestClusterPluginAssignment(t *testing.T) {
+	identity := plugin_entities.PluginUniqueIdentifier("langgenius/test:1.0.0@0123456789abcdef0123456789abcdef")
+
+	c := &Cluster{id: "node-a", assignmentReplicas: 1}
+	if c.IsPluginAssignedToCurrentNode(identity) {
+		t.Fatal("expected no plugin to be assigned before the ring is built")
+	}
+
+	c.updateHashRing(map[string]node{"node-a": {}, "node-b": {}, "node-c": {}})
+	assigned := c.AssignedNodes(identity)
+	if len(assigned) != 1 {
+		t.Fatalf("expected 1 assigned node, got %v", assigned)
+	}
+
+	c.id = assigned[0]
+	if !c.IsPluginAssignedToCurrentNode(identity) {
+		t.Fatalf("expected plugin to be assigned to %s", c.id)
+	}
+
+	hashedId := plugin_entities.HashedIdentity(identity.String())
+	sorted := c.sortNodesByAssignment(hashedId, []string{"node-a", "node-b", "node-c", "node-d"})
+	if sorted[0] != assigned[0] || len(sorted) != 4 || sorted[3] != "node-d" {
+		t.Fatalf("expected the assigned node first and unknown nodes last, got %v", sorted)
+	}
+	if !slices.Contains(sorted, "node-b") {
+		t.Fatalf("expected all nodes to be kept, got %v", sorted)
+	}
+}
@@ -3,6 +3,7 @@ package cluster
 import (
 	"errors"
 	"net"
+	"sort"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -88,6 +89,7 @@ func (c *Cluster) updateNodeStatus() error {
 	for nodeId, node := range nodes {
 		c.nodes.Store(nodeId, node)
 	}
+	c.updateHashRing(nodes)
 
 	return nil
 }
@@ -112,6 +114,48 @@ func (c *Cluster) GetNodes() (map[string]node, error) {
 	return nodes, nil
 }
 
+// NodeStatus is an available node of the cluster
+type NodeStatus struct {
+	ID         string    `json:"id"`
+	Addresses  []string  `json:"addresses"`
+	LastPingAt time.Time `json:"last_ping_at"`
+	Master     bool      `json:"master"`
+	Current    bool      `json:"current"`
+}
+
+// Nodes lists the available nodes sorted by id, the master is the node holding the preemption lock
+func (c *Cluster) Nodes() ([]NodeStatus, error) {
+	nodes, err := c.GetNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	master, err := cache.Get[string](PREEMPTION_LOCK_KEY)
+	if err != nil && err != cache.ErrNotFound {
+		return nil, err
+	}
+
+	statuses := make([]NodeStatus, 0, len(nodes))
+	for nodeId, node := range nodes {
+		addresses := make([]string, 0, len(node.Addresses))
+		for _, address := range node.Addresses {
+			addresses = append(addresses, address.fullAddress())
+		}
+		statuses = append(statuses, NodeStatus{
+			ID:         nodeId,
+			Addresses:  addresses,
+			LastPingAt: time.Unix(node.LastPingAt, 0),
+			Master:     master != nil && *master == nodeId,
+			Current:    nodeId == c.id,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].ID < statuses[j].ID
+	})
+	return statuses, nil
+}
+
 // FetchPluginAvailableNodesByHashedId fetches the available nodes of the given plugin
 func (c *Cluster) FetchPluginAvailableNodesByHashedId(hashedPluginId string) ([]string, error) {
 	states, err := cache.ScanMap[plugin_entities.PluginRuntimeState](
@@ -132,7 +176,7 @@ func (c *Cluster) FetchPluginAvailableNodesByHashedId(hashedPluginId string) ([]
 		}
 	}
 
-	return nodes, nil
+	return c.sortNodesByAssignment(hashedPluginId, nodes), nil
 }
 
 func (c *Cluster) FetchPluginAvailableNodesById(plugin_id string) ([]string, error) {
@@ -140,8 +184,9 @@ func (c *Cluster) FetchPluginAvailableNodesById(plugin_id string) ([]string, err
 	return c.FetchPluginAvailableNodesByHashedId(hashedPluginId)
 }
 
+// IsMaster reports whether the current node is the master and its lease has not run out
 func (c *Cluster) IsMaster() bool {
-	return c.iAmMaster
+	return c.iAmMaster.Load() && time.Now().UnixNano() < c.masterLeaseUntil.Load()
 }
 
 func (c *Cluster) IsNodeAlive(nodeId string) bool {
@@ -178,6 +223,11 @@ func (c *Cluster) autoGCNodes() error {
 	}
 
 	for nodeId, nodeStatus := range nodes {
+		// stop once the lease ran out, the next master takes over
+		if !c.IsMaster() {
+			return errors.Join(totalErrors, ErrMasterLockLost)
+		}
+
 		// delete the node if it is disconnected
 		if !c.isNodeAvailable(&nodeStatus) {
 			// gc the node
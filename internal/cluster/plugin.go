@@ -2,6 +2,7 @@ package cluster
 
 import (
 	"errors"
+	"sort"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -261,6 +262,11 @@ func (c *Cluster) autoGCPlugins() error {
 		PLUGIN_STATE_MAP_KEY,
 		"*",
 		func(m map[string]pluginState) error {
+			// stop once the lease ran out, the next master takes over
+			if !c.IsMaster() {
+				return ErrMasterLockLost
+			}
+
 			for node_plugin_join, plugin_state := range m {
 				if !c.isPluginActive(&plugin_state) {
 					nodeId, _, err := c.splitNodePluginJoin(node_plugin_join)
@@ -298,3 +304,37 @@ func (c *Cluster) IsPluginOnCurrentNode(identity plugin_entities.PluginUniqueIde
 	}
 	return ok, nil
 }
+
+// PluginNodeState is the runtime state of a plugin reported by a node
+type PluginNodeState struct {
+	NodeID string `json:"node_id"`
+	plugin_entities.PluginRuntimeState
+}
+
+// PluginStates returns the states of the plugins running on the available nodes by plugin unique
+// identifier, states the nodes stopped reporting are left out
+func (c *Cluster) PluginStates() (map[string][]PluginNodeState, error) {
+	states, err := cache.ScanMap[pluginState](PLUGIN_STATE_MAP_KEY, "*")
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string][]PluginNodeState{}
+	for key, state := range states {
+		nodeId, _, err := c.splitNodePluginJoin(key)
+		if err != nil || !c.nodes.Exists(nodeId) || !c.isPluginActive(&state) {
+			continue
+		}
+		result[state.Identity] = append(result[state.Identity], PluginNodeState{
+			NodeID:             nodeId,
+			PluginRuntimeState: state.PluginRuntimeState,
+		})
+	}
+
+	for _, nodes := range result {
+		sort.Slice(nodes, func(i, j int) bool {
+			return nodes[i].NodeID < nodes[j].NodeID
+		})
+	}
+	return result, nil
+}
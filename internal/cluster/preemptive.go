@@ -2,6 +2,7 @@ package cluster
 
 import (
 	"errors"
+	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
 )
@@ -14,6 +15,11 @@ import (
 // Once a node becomes master, It will take responsibility to gc the nodes has already deactivated
 // and all nodes should to maintenance their own status
 //
+// The lock holds the id of the master, it's only renewed or released by the node holding it.
+// The master considers itself the master for a lease shorter than the expiration of the lock,
+// counted from before the lock was acquired or renewed, so that a master cut off from redis
+// steps down before any other node is able to take the slot
+//
 // State:
 //	- hashmap[cluster-status]
 //		- node_id:
@@ -32,6 +38,20 @@ const (
 	PREEMPTION_LOCK_KEY         = "cluster-master-preemption-lock"
 )
 
+var (
+	ErrMasterLockLost = errors.New("master lock is held by another node")
+)
+
+// masterLeaseDrift is subtracted from the lease to tolerate clock drift between nodes and redis
+func (c *Cluster) masterLeaseDrift() time.Duration {
+	return c.masterLockExpiredTime / 10
+}
+
+// extendMasterLease trusts the lock until its expiration counted from start minus the drift
+func (c *Cluster) extendMasterLease(start time.Time) {
+	c.masterLeaseUntil.Store(start.Add(c.masterLockExpiredTime - c.masterLeaseDrift()).UnixNano())
+}
+
 // try lock the slot to be the master of the cluster
 // returns:
 //   - bool: true if the slot is locked by the node
@@ -40,6 +60,7 @@ func (c *Cluster) lockMaster() (bool, error) {
 	var finalError error
 
 	for i := 0; i < 3; i++ {
+		start := time.Now()
 		if success, err := cache.SetNX(PREEMPTION_LOCK_KEY, c.id, c.masterLockExpiredTime); err != nil {
 			// try again
 			if finalError == nil {
@@ -50,6 +71,7 @@ func (c *Cluster) lockMaster() (bool, error) {
 		} else if !success {
 			return false, nil
 		} else {
+			c.extendMasterLease(start)
 			return true, nil
 		}
 	}
@@ -57,12 +79,29 @@ func (c *Cluster) lockMaster() (bool, error) {
 	return false, finalError
 }
 
-// update master
+// update master, the lock is only renewed if it's still held by current node
 func (c *Cluster) updateMaster() error {
-	// update expired time of master key
-	if _, err := cache.Expire(PREEMPTION_LOCK_KEY, c.masterLockExpiredTime); err != nil {
+	start := time.Now()
+	renewed, err := cache.RenewLock(PREEMPTION_LOCK_KEY, c.id, c.masterLockExpiredTime)
+	if err != nil {
+		// the lease is not extended, it runs out unless a later renewal succeeds
 		return err
 	}
+	if !renewed {
+		return ErrMasterLockLost
+	}
 
+	c.extendMasterLease(start)
 	return nil
 }
+
+// releaseMaster gives up the slot so that another node takes over without waiting for expiration
+func (c *Cluster) releaseMaster() error {
+	if !c.iAmMaster.Swap(false) {
+		return nil
+	}
+	c.masterLeaseUntil.Store(0)
+
+	_, err := cache.ReleaseLock(PREEMPTION_LOCK_KEY, c.id)
+	return err
+}
@@ -0,0 +1,66 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+)
+
+func TestMasterElection(t *testing.T) {
+	if err := cache.InitMemoryClient(0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cache.Close() })
+
+	newNode := func(id string) *Cluster {
+		return &Cluster{id: id, masterLockExpiredTime: 200 * time.Millisecond}
+	}
+	a, b := newNode("node-a"), newNode("node-b")
+
+	if ok, err := a.lockMaster(); err != nil || !ok {
+		t.Fatalf("expected node-a to lock the slot, got %v %v", ok, err)
+	}
+	a.iAmMaster.Store(true)
+	if ok, _ := b.lockMaster(); ok {
+		t.Fatal("expected node-b to fail to lock a held slot")
+	}
+	if !a.IsMaster() || b.IsMaster() {
+		t.Fatal("expected node-a to be the only master")
+	}
+	if err := a.updateMaster(); err != nil {
+		t.Fatalf("expected node-a to renew its lock, got %v", err)
+	}
+
+	// node-a is partitioned, its lock expires and node-b takes over
+	time.Sleep(250 * time.Millisecond)
+	if a.IsMaster() {
+		t.Fatal("expected the lease of node-a to run out before its lock expires")
+	}
+	if ok, err := b.lockMaster(); err != nil || !ok {
+		t.Fatalf("expected node-b to lock the expired slot, got %v %v", ok, err)
+	}
+	b.iAmMaster.Store(true)
+
+	// the stale master must not extend the lock of the new one
+	if err := a.updateMaster(); err != ErrMasterLockLost {
+		t.Fatalf("expected node-a to lose the slot, got %v", err)
+	}
+	if err := a.releaseMaster(); err != nil {
+		t.Fatal(err)
+	}
+	if !b.IsMaster() {
+		t.Fatal("expected node-b to still be the master")
+	}
+
+	// releasing lets another node take over at once
+	if err := b.releaseMaster(); err != nil {
+		t.Fatal(err)
+	}
+	if b.IsMaster() {
+		t.Fatal("expected node-b to step down after releasing")
+	}
+	if ok, err := a.lockMaster(); err != nil || !ok {
+		t.Fatalf("expected node-a to lock the released slot, got %v %v", ok, err)
+	}
+}
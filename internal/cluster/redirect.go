@@ -1,13 +1,32 @@
 package cluster
 
 import (
+	"bytes"
+	"crypto/tls"
 	"errors"
 	"io"
 	"net/http"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+// peerScheme and peerClient are how nodes reach each other
+var (
+	peerScheme = "http"
+	peerClient = http.DefaultClient
 )
 
+// UsePeerTLS makes requests to other nodes over tls, the http servers of all nodes serve tls once it's enabled
+func UsePeerTLS(config *tls.Config) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	peerScheme = "https"
+	peerClient = &http.Client{Transport: transport}
+}
+
 func constructRedirectUrl(ip address, request *http.Request) string {
-	url := "http://" + ip.fullAddress() + request.URL.Path
+	url := peerScheme + "://" + ip.fullAddress() + request.URL.Path
 	if request.URL.RawQuery != "" {
 		url += "?" + request.URL.RawQuery
 	}
@@ -18,8 +37,9 @@ func constructRedirectUrl(ip address, request *http.Request) string {
 func redirectRequestToIp(ip address, request *http.Request) (int, http.Header, io.ReadCloser, error) {
 	url := constructRedirectUrl(ip, request)
 
-	// create a new request
-	redirectedRequest, err := http.NewRequest(
+	// create a new request, the peer sees the caller going away through its context
+	redirectedRequest, err := http.NewRequestWithContext(
+		request.Context(),
 		request.Method,
 		url,
 		request.Body,
@@ -36,8 +56,7 @@ func redirectRequestToIp(ip address, request *http.Request) (int, http.Header, i
 		}
 	}
 
-	client := http.DefaultClient
-	resp, err := client.Do(redirectedRequest)
+	resp, err := peerClient.Do(redirectedRequest)
 
 	if err != nil {
 		return 0, nil, nil, err
@@ -62,5 +81,46 @@ func (c *Cluster) RedirectRequest(
 
 	ip := ips[0]
 
+	// the node receiving it must not redirect it again, that would loop on stale plugin states
+	request.Header.Set(constants.X_CLUSTER_REDIRECTED_FROM, c.id)
+
 	return redirectRequestToIp(ip, request)
 }
+
+// RedirectRequestToNodes redirects the request to the first node of node_ids accepting it,
+// the body is buffered to be replayed once a node is unreachable
+func (c *Cluster) RedirectRequestToNodes(
+	node_ids []string, request *http.Request,
+) (int, http.Header, io.ReadCloser, error) {
+	if len(node_ids) == 0 {
+		return 0, nil, nil, errors.New("no available node found")
+	}
+
+	var body []byte
+	if request.Body != nil {
+		var err error
+		body, err = io.ReadAll(request.Body)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		request.Body.Close()
+	}
+
+	var errs error
+	for _, nodeId := range node_ids {
+		request.Body = io.NopCloser(bytes.NewReader(body))
+		statusCode, header, responseBody, err := c.RedirectRequest(nodeId, request)
+		if err == nil {
+			return statusCode, header, responseBody, nil
+		}
+		log.Warn("redirect request to node %s failed: %s", nodeId, err.Error())
+		errs = errors.Join(errs, err)
+	}
+
+	return 0, nil, nil, errs
+}
+
+// IsRedirectedRequest reports whether the request has been redirected by another node
+func IsRedirectedRequest(request *http.Request) bool {
+	return request.Header.Get(constants.X_CLUSTER_REDIRECTED_FROM) != ""
+}
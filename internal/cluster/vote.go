@@ -3,7 +3,6 @@ package cluster
 import (
 	"errors"
 	"fmt"
-	"net/http"
 	"net/url"
 	"sort"
 	"time"
@@ -113,13 +112,13 @@ func (c *Cluster) voteAddress(addr address) error {
 		Status string `json:"status"`
 	}
 
-	healthcheckEndpoint, err := url.JoinPath(fmt.Sprintf("http://%s:%d", addr.Ip, addr.Port), "health/check")
+	healthcheckEndpoint, err := url.JoinPath(fmt.Sprintf("%s://%s:%d", peerScheme, addr.Ip, addr.Port), "health/check")
 	if err != nil {
 		return err
 	}
 
 	resp, err := http_requests.GetAndParse[healthcheck](
-		http.DefaultClient,
+		peerClient,
 		healthcheckEndpoint,
 		http_requests.HttpWriteTimeout(500),
 		http_requests.HttpReadTimeout(500),
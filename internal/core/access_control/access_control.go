@@ -0,0 +1,170 @@
+// Package access_control maps credentials of callers to roles, a credential is the server key, the admin
+// api key, a key managed through the admin api, a json web token signed with the configured secret or a
+// verified client certificate
+package access_control
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	PRINCIPAL_SERVER_KEY = "server_key"
+	PRINCIPAL_ADMIN_KEY  = "admin_key"
+	PRINCIPAL_API_KEY    = "api_key"
+	PRINCIPAL_JWT        = "jwt"
+	// PRINCIPAL_CLIENT_CERT is a verified client certificate standing in for the server key
+	PRINCIPAL_CLIENT_CERT = "client_cert"
+)
+
+var ErrUnauthorized = errors.New("unauthorized")
+
+type Config struct {
+	ServerKey string
+	// AdminKey is empty if the admin api is disabled
+	AdminKey string
+	// JWTSecret verifies tokens signed with HS256, tokens are rejected if it's empty
+	JWTSecret string
+	// RequireClientCertificate rejects requests over connections without verified client certificate
+	RequireClientCertificate bool
+	// ClientCertificateAuth lets verified client certificates authenticate requests without credential
+	ClientCertificateAuth bool
+}
+
+// Principal is who a request is made by
+type Principal struct {
+	Kind string `json:"kind"`
+	// Name is the name of a managed key or the subject of a token
+	Name  string `json:"name"`
+	KeyID string `json:"key_id,omitempty"`
+	Roles []Role `json:"roles"`
+	// TenantID is the only tenant the principal may access, empty for all tenants
+	TenantID string `json:"tenant_id,omitempty"`
+	// Organization is the only organization of the plugin registry the principal may access, empty for all
+	Organization string `json:"organization,omitempty"`
+	permissions  map[Permission]bool
+}
+
+func (p *Principal) Can(permission Permission) bool {
+	return p.permissions[permission]
+}
+
+func (p *Principal) CanAccessTenant(tenantId string) bool {
+	return p.TenantID == "" || p.TenantID == tenantId
+}
+
+func (p *Principal) CanAccessOrganization(organization string) bool {
+	return p.Organization == "" || p.Organization == organization
+}
+
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Roles        []string `json:"roles"`
+	TenantID     string   `json:"tenant_id"`
+	Organization string   `json:"organization"`
+}
+
+var config Config
+
+func Init(c Config) {
+	config = c
+}
+
+// Authenticate resolves the principal of a credential, ErrUnauthorized is returned for unknown, expired
+// and revoked credentials
+func Authenticate(credential string) (*Principal, error) {
+	if credential == "" {
+		return nil, ErrUnauthorized
+	}
+
+	if matches(credential, config.ServerKey) {
+		return serverKeyPrincipal(PRINCIPAL_SERVER_KEY, PRINCIPAL_SERVER_KEY), nil
+	}
+
+	if matches(credential, config.AdminKey) {
+		return newPrincipal(PRINCIPAL_ADMIN_KEY, PRINCIPAL_ADMIN_KEY, []string{string(ROLE_ADMIN)}, "", ""), nil
+	}
+
+	if strings.HasPrefix(credential, API_KEY_PREFIX) {
+		return authenticateAPIKey(credential)
+	}
+
+	if config.JWTSecret != "" && strings.Count(credential, ".") == 2 {
+		return authenticateJWT(credential)
+	}
+
+	return nil, ErrUnauthorized
+}
+
+// AuthenticateConnection is Authenticate for requests over tls, peer certificates of the connection must
+// have been verified during the handshake
+func AuthenticateConnection(credential string, state *tls.ConnectionState) (*Principal, error) {
+	verified := state != nil && len(state.PeerCertificates) > 0
+	if config.RequireClientCertificate && !verified {
+		return nil, ErrUnauthorized
+	}
+
+	if credential == "" && config.ClientCertificateAuth && verified {
+		return serverKeyPrincipal(PRINCIPAL_CLIENT_CERT, state.PeerCertificates[0].Subject.CommonName), nil
+	}
+
+	return Authenticate(credential)
+}
+
+func matches(credential string, key string) bool {
+	return key != "" && subtle.ConstantTimeCompare([]byte(credential), []byte(key)) == 1
+}
+
+func serverKeyPrincipal(kind string, name string) *Principal {
+	permissions := map[Permission]bool{}
+	for _, permission := range serverKeyPermissions {
+		permissions[permission] = true
+	}
+	return &Principal{
+		Kind:        kind,
+		Name:        name,
+		Roles:       []Role{},
+		permissions: permissions,
+	}
+}
+
+// newPrincipal drops unknown roles, they may come from tokens issued for a newer version
+func newPrincipal(kind string, name string, roles []string, tenantId string, organization string) *Principal {
+	principal := &Principal{
+		Kind:         kind,
+		Name:         name,
+		Roles:        []Role{},
+		TenantID:     tenantId,
+		Organization: organization,
+	}
+	for _, role := range roles {
+		if ValidRole(role) && !slices.Contains(principal.Roles, Role(role)) {
+			principal.Roles = append(principal.Roles, Role(role))
+		}
+	}
+	principal.permissions = permissionsOf(principal.Roles)
+	return principal
+}
+
+func authenticateJWT(token string) (*Principal, error) {
+	claims := &tokenClaims{}
+	_, err := jwt.ParseWithClaims(
+		token,
+		claims,
+		func(*jwt.Token) (any, error) {
+			return []byte(config.JWTSecret), nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || claims.Subject == "" {
+		return nil, ErrUnauthorized
+	}
+
+	return newPrincipal(PRINCIPAL_JWT, claims.Subject, claims.Roles, claims.TenantID, claims.Organization), nil
+}
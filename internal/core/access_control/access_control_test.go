@@ -0,0 +1,154 @@
+package access_control
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func initTestDB(t *testing.T) {
+	cfg := &app.Config{
+		DBType:       "sqlite",
+		DBSqlitePath: filepath.Join(t.TempDir(), "access_control.db"),
+	}
+	cfg.SetDefault()
+	db.Init(cfg)
+	t.Cleanup(db.Close)
+	cache.InitMemoryClient(0)
+}
+
+func TestAuthenticateBuiltinKeys(t *testing.T) {
+	Init(Config{ServerKey: "server-key", AdminKey: "admin-key-0123"})
+	t.Cleanup(func() { Init(Config{}) })
+
+	principal, err := Authenticate("server-key")
+	require.NoError(t, err)
+	assert.Equal(t, PRINCIPAL_SERVER_KEY, principal.Kind)
+	assert.True(t, principal.Can(PERMISSION_PLUGIN_INVOKE))
+	assert.True(t, principal.Can(PERMISSION_PLUGIN_MANAGE))
+	assert.False(t, principal.Can(PERMISSION_CLUSTER_READ))
+	assert.False(t, principal.Can(PERMISSION_KEYS_MANAGE))
+
+	principal, err = Authenticate("admin-key-0123")
+	require.NoError(t, err)
+	assert.Equal(t, PRINCIPAL_ADMIN_KEY, principal.Kind)
+	assert.True(t, principal.Can(PERMISSION_KEYS_MANAGE))
+
+	for _, credential := range []string{"", "wrong", "a.b.c"} {
+		_, err = Authenticate(credential)
+		assert.ErrorIs(t, err, ErrUnauthorized)
+	}
+}
+
+func TestAuthenticateJWT(t *testing.T) {
+	Init(Config{ServerKey: "server-key", JWTSecret: "jwt-secret"})
+	t.Cleanup(func() { Init(Config{}) })
+
+	sign := func(method jwt.SigningMethod, secret string, claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(method, claims).SignedString([]byte(secret))
+		require.NoError(t, err)
+		return token
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	principal, err := Authenticate(sign(jwt.SigningMethodHS256, "jwt-secret", jwt.MapClaims{
+		"sub":       "ci",
+		"exp":       exp,
+		"roles":     []string{"invoker", "unknown"},
+		"tenant_id": "tenant-a",
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, PRINCIPAL_JWT, principal.Kind)
+	assert.Equal(t, "ci", principal.Name)
+	assert.Equal(t, []Role{ROLE_INVOKER}, principal.Roles)
+	assert.True(t, principal.Can(PERMISSION_PLUGIN_INVOKE))
+	assert.False(t, principal.Can(PERMISSION_PLUGIN_MANAGE))
+	assert.True(t, principal.CanAccessTenant("tenant-a"))
+	assert.False(t, principal.CanAccessTenant("tenant-b"))
+	assert.False(t, principal.CanAccessTenant(""))
+	assert.True(t, principal.CanAccessOrganization("acme"))
+
+	rejected := []string{
+		sign(jwt.SigningMethodHS256, "other-secret", jwt.MapClaims{"sub": "ci", "exp": exp}),
+		sign(jwt.SigningMethodHS256, "jwt-secret", jwt.MapClaims{"sub": "ci"}),
+		sign(jwt.SigningMethodHS256, "jwt-secret", jwt.MapClaims{"sub": "ci", "exp": time.Now().Add(-time.Minute).Unix()}),
+		sign(jwt.SigningMethodHS512, "jwt-secret", jwt.MapClaims{"sub": "ci", "exp": exp}),
+	}
+	for _, token := range rejected {
+		_, err := Authenticate(token)
+		assert.ErrorIs(t, err, ErrUnauthorized)
+	}
+}
+
+func TestManagedKeys(t *testing.T) {
+	initTestDB(t)
+	Init(Config{ServerKey: "server-key"})
+	t.Cleanup(func() { Init(Config{}) })
+
+	_, _, err := CreateKey("bad", []string{"root"}, "", "", nil)
+	assert.ErrorIs(t, err, ErrInvalidRole)
+
+	key, secret, err := CreateKey("ops", []string{string(ROLE_OPERATOR)}, "", "", nil)
+	require.NoError(t, err)
+	assert.NotContains(t, key.Hash, secret)
+
+	principal, err := Authenticate(secret)
+	require.NoError(t, err)
+	assert.Equal(t, PRINCIPAL_API_KEY, principal.Kind)
+	assert.Equal(t, key.ID, principal.KeyID)
+	assert.True(t, principal.Can(PERMISSION_CLUSTER_MANAGE))
+	assert.False(t, principal.Can(PERMISSION_KEYS_MANAGE))
+
+	// the replaced key keeps working during the grace period only
+	replacement, newSecret, err := RotateKey(key.ID, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, key.Roles, replacement.Roles)
+	_, err = Authenticate(secret)
+	assert.NoError(t, err)
+	_, err = Authenticate(newSecret)
+	assert.NoError(t, err)
+	_, _, err = RotateKey(key.ID, time.Hour)
+	assert.ErrorIs(t, err, ErrAPIKeyRotated)
+
+	_, newerSecret, err := RotateKey(replacement.ID, 0)
+	require.NoError(t, err)
+	_, err = Authenticate(newSecret)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = Authenticate(newerSecret)
+	assert.NoError(t, err)
+
+	scoped, scopedSecret, err := CreateKey("tenant", []string{string(ROLE_INVOKER)}, "tenant-a", "", nil)
+	require.NoError(t, err)
+	principal, err = Authenticate(scopedSecret)
+	require.NoError(t, err)
+	assert.False(t, principal.CanAccessTenant("tenant-b"))
+
+	_, err = RevokeKey(scoped.ID)
+	require.NoError(t, err)
+	_, err = Authenticate(scopedSecret)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = RevokeKey(scoped.ID)
+	assert.ErrorIs(t, err, ErrAPIKeyRevoked)
+	_, err = RevokeKey("missing")
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+
+	_, publisherSecret, err := CreateKey("ci", []string{string(ROLE_PUBLISHER)}, "", "acme", nil)
+	require.NoError(t, err)
+	principal, err = Authenticate(publisherSecret)
+	require.NoError(t, err)
+	assert.True(t, principal.Can(PERMISSION_REGISTRY_PUBLISH))
+	assert.False(t, principal.Can(PERMISSION_PLUGIN_INVOKE))
+	assert.True(t, principal.CanAccessOrganization("acme"))
+	assert.False(t, principal.CanAccessOrganization("other"))
+	assert.False(t, principal.CanAccessOrganization(""))
+
+	_, err = Authenticate(API_KEY_PREFIX + "unknown")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
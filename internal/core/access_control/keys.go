@@ -0,0 +1,223 @@
+package access_control
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"gorm.io/gorm"
+)
+
+const (
+	API_KEY_PREFIX        = "dpk_"
+	API_KEY_SECRET_BYTES  = 32
+	API_KEY_PREFIX_LENGTH = 12
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrAPIKeyRevoked  = errors.New("api key has been revoked")
+	ErrAPIKeyRotated  = errors.New("api key has been rotated already")
+	ErrAPIKeyExpired  = errors.New("api key has expired")
+	ErrInvalidRole    = errors.New("invalid role")
+)
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// newKey generates the secret of a key, the secret is not kept anywhere
+func newKey(name string, roles []string, tenantId string, organization string, expiresAt *time.Time) (models.APIKey, string, error) {
+	for _, role := range roles {
+		if !ValidRole(role) {
+			return models.APIKey{}, "", ErrInvalidRole
+		}
+	}
+
+	random := make([]byte, API_KEY_SECRET_BYTES)
+	if _, err := rand.Read(random); err != nil {
+		return models.APIKey{}, "", err
+	}
+	secret := API_KEY_PREFIX + base64.RawURLEncoding.EncodeToString(random)
+
+	return models.APIKey{
+		Name:         name,
+		Prefix:       secret[:API_KEY_PREFIX_LENGTH],
+		Hash:         hashSecret(secret),
+		Roles:        roles,
+		TenantID:     tenantId,
+		Organization: organization,
+		ExpiresAt:    expiresAt,
+	}, secret, nil
+}
+
+// CreateKey stores a new key, its secret is returned only here
+func CreateKey(name string, roles []string, tenantId string, organization string, expiresAt *time.Time) (*models.APIKey, string, error) {
+	key, secret, err := newKey(name, roles, tenantId, organization, expiresAt)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := db.Create(&key); err != nil {
+		return nil, "", err
+	}
+
+	return &key, secret, nil
+}
+
+func ListKeys() ([]models.APIKey, error) {
+	return db.GetAll[models.APIKey](db.OrderBy("created_at", true))
+}
+
+// RotateKey replaces a key by a new one with the same name, roles, scope and lifetime, the replaced key
+// stays valid for the grace period so that callers can switch over
+func RotateKey(id string, grace time.Duration) (*models.APIKey, string, error) {
+	var replacement models.APIKey
+	var secret string
+	var hash string
+
+	err := db.WithTransaction(func(tx *gorm.DB) error {
+		key, err := db.GetOne[models.APIKey](
+			db.WithTransactionContext(tx),
+			db.Equal("id", id),
+			db.WLock(),
+		)
+		if err == db.ErrDatabaseNotFound {
+			return ErrAPIKeyNotFound
+		} else if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		switch {
+		case key.RevokedAt != nil:
+			return ErrAPIKeyRevoked
+		case key.RotatedTo != "":
+			return ErrAPIKeyRotated
+		case key.ExpiresAt != nil && !key.ExpiresAt.After(now):
+			return ErrAPIKeyExpired
+		}
+
+		var expiresAt *time.Time
+		if key.ExpiresAt != nil {
+			t := now.Add(key.ExpiresAt.Sub(key.CreatedAt))
+			expiresAt = &t
+		}
+
+		replacement, secret, err = newKey(key.Name, key.Roles, key.TenantID, key.Organization, expiresAt)
+		if err != nil {
+			return err
+		}
+		if err := db.Create(&replacement, tx); err != nil {
+			return err
+		}
+
+		graceEnd := now.Add(grace)
+		if key.ExpiresAt == nil || key.ExpiresAt.After(graceEnd) {
+			key.ExpiresAt = &graceEnd
+		}
+		key.RotatedTo = replacement.ID
+		hash = key.Hash
+		return db.Update(&key, tx)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	forgetKey(hash)
+	return &replacement, secret, nil
+}
+
+// RevokeKey invalidates a key at once
+func RevokeKey(id string) (*models.APIKey, error) {
+	var key models.APIKey
+
+	err := db.WithTransaction(func(tx *gorm.DB) error {
+		var err error
+		key, err = db.GetOne[models.APIKey](
+			db.WithTransactionContext(tx),
+			db.Equal("id", id),
+			db.WLock(),
+		)
+		if err == db.ErrDatabaseNotFound {
+			return ErrAPIKeyNotFound
+		} else if err != nil {
+			return err
+		}
+
+		if key.RevokedAt != nil {
+			return ErrAPIKeyRevoked
+		}
+
+		now := time.Now()
+		key.RevokedAt = &now
+		return db.Update(&key, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	forgetKey(key.Hash)
+	return &key, nil
+}
+
+// DeleteTenantKeys removes the keys limited to a tenant
+func DeleteTenantKeys(tenantId string) error {
+	keys, err := db.GetAll[models.APIKey](db.Equal("tenant_id", tenantId))
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if err := db.Delete(&key); err != nil {
+			return err
+		}
+		forgetKey(key.Hash)
+	}
+	return nil
+}
+
+func authenticateAPIKey(secret string) (*Principal, error) {
+	key, err := lookupKey(hashSecret(secret))
+	if err == db.ErrDatabaseNotFound {
+		return nil, ErrUnauthorized
+	} else if err != nil {
+		return nil, err
+	}
+
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && !key.ExpiresAt.After(time.Now())) {
+		return nil, ErrUnauthorized
+	}
+
+	principal := newPrincipal(PRINCIPAL_API_KEY, key.Name, key.Roles, key.TenantID, key.Organization)
+	principal.KeyID = key.ID
+	return principal, nil
+}
+
+// lookupKey reads keys through the cache, the database is used directly if the cache is unavailable
+func lookupKey(hash string) (*models.APIKey, error) {
+	getter := func() (*models.APIKey, error) {
+		key, err := db.GetOne[models.APIKey](db.Equal("hash", hash))
+		if err != nil {
+			return nil, err
+		}
+		return &key, nil
+	}
+
+	key, err := cache.AutoGetWithGetter(hash, getter)
+	if err != nil && err != db.ErrDatabaseNotFound {
+		return getter()
+	}
+	return key, err
+}
+
+func forgetKey(hash string) {
+	_, _ = cache.AutoDelete[models.APIKey](hash)
+}
@@ -0,0 +1,94 @@
+package access_control
+
+import "slices"
+
+type Permission string
+
+const (
+	PERMISSION_PLUGIN_INVOKE Permission = "plugin.invoke"
+	PERMISSION_PLUGIN_MANAGE Permission = "plugin.manage"
+	PERMISSION_PLUGIN_DEBUG  Permission = "plugin.debug"
+	// PERMISSION_PLUGIN_LOGS reads logs of plugins and debugging connections of all tenants
+	PERMISSION_PLUGIN_LOGS    Permission = "plugin.logs"
+	PERMISSION_CLUSTER_READ   Permission = "cluster.read"
+	PERMISSION_CLUSTER_MANAGE Permission = "cluster.manage"
+	PERMISSION_KEYS_MANAGE    Permission = "keys.manage"
+	// PERMISSION_REGISTRY_READ searches and downloads plugins of the private registry
+	PERMISSION_REGISTRY_READ    Permission = "registry.read"
+	PERMISSION_REGISTRY_PUBLISH Permission = "registry.publish"
+)
+
+type Role string
+
+const (
+	ROLE_ADMIN    Role = "admin"
+	ROLE_OPERATOR Role = "operator"
+	ROLE_INVOKER  Role = "invoker"
+	ROLE_DEBUGGER Role = "debugger"
+	// ROLE_PUBLISHER publishes plugins to the registry, keys of it are usually limited to an organization
+	ROLE_PUBLISHER Role = "publisher"
+)
+
+var rolePermissions = map[Role][]Permission{
+	ROLE_ADMIN: {
+		PERMISSION_PLUGIN_INVOKE,
+		PERMISSION_PLUGIN_MANAGE,
+		PERMISSION_PLUGIN_DEBUG,
+		PERMISSION_PLUGIN_LOGS,
+		PERMISSION_CLUSTER_READ,
+		PERMISSION_CLUSTER_MANAGE,
+		PERMISSION_KEYS_MANAGE,
+		PERMISSION_REGISTRY_READ,
+		PERMISSION_REGISTRY_PUBLISH,
+	},
+	ROLE_OPERATOR: {
+		PERMISSION_PLUGIN_INVOKE,
+		PERMISSION_PLUGIN_MANAGE,
+		PERMISSION_PLUGIN_LOGS,
+		PERMISSION_CLUSTER_READ,
+		PERMISSION_CLUSTER_MANAGE,
+		PERMISSION_REGISTRY_READ,
+	},
+	ROLE_INVOKER: {
+		PERMISSION_PLUGIN_INVOKE,
+	},
+	ROLE_DEBUGGER: {
+		PERMISSION_PLUGIN_DEBUG,
+		PERMISSION_PLUGIN_LOGS,
+	},
+	ROLE_PUBLISHER: {
+		PERMISSION_REGISTRY_READ,
+		PERMISSION_REGISTRY_PUBLISH,
+	},
+}
+
+// serverKeyPermissions are what the server key shared with dify was always allowed to do
+var serverKeyPermissions = []Permission{
+	PERMISSION_PLUGIN_INVOKE,
+	PERMISSION_PLUGIN_MANAGE,
+	PERMISSION_PLUGIN_DEBUG,
+}
+
+// Roles returns the permissions granted by each role
+func Roles() map[Role][]Permission {
+	roles := make(map[Role][]Permission, len(rolePermissions))
+	for role, permissions := range rolePermissions {
+		roles[role] = slices.Clone(permissions)
+	}
+	return roles
+}
+
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[Role(role)]
+	return ok
+}
+
+func permissionsOf(roles []Role) map[Permission]bool {
+	permissions := map[Permission]bool{}
+	for _, role := range roles {
+		for _, permission := range rolePermissions[role] {
+			permissions[permission] = true
+		}
+	}
+	return permissions
+}
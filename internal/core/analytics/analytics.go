@@ -0,0 +1,51 @@
+// Package analytics persists invocation counts of plugins and rolls them up into hourly and daily
+// aggregates, see Rollup for how double counting is avoided
+package analytics
+
+import (
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/retention"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/schedule"
+)
+
+const (
+	// only one node of the cluster rolls up in each interval, the watermark lock keeps it safe anyway
+	ROLLUP_LOCK_KEY = "analytics:rollup:lock"
+)
+
+type Config struct {
+	FlushInterval time.Duration
+	// RollupSchedule is an interval or a calendar schedule like a nightly rollup
+	RollupSchedule schedule.Schedule
+}
+
+// Start records invocations and flushes them every FlushInterval, rollups run at every activation of RollupSchedule
+func Start(config Config) {
+	enabled.Store(true)
+	retention.Register(retention.CLASS_INVOCATION_ANALYTICS, Purge)
+
+	schedule.Run("analytics_flush", schedule.Every(config.FlushInterval), func() {
+		if err := Flush(); err != nil {
+			log.Error("failed to flush invocation counts: %s", err.Error())
+		}
+	})
+
+	schedule.Run("analytics_rollup", config.RollupSchedule, func() {
+		// the lock expires before the next activation
+		at := now()
+		ok, err := cache.SetNX(ROLLUP_LOCK_KEY, true, config.RollupSchedule.Next(at).Sub(at)/2)
+		if err != nil {
+			log.Error("failed to acquire analytics rollup lock: %s", err.Error())
+			return
+		}
+		if !ok {
+			return
+		}
+		if err := Rollup(at); err != nil {
+			log.Error("failed to roll up invocation counts: %s", err.Error())
+		}
+	})
+}
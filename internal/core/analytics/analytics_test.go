@@ -0,0 +1,100 @@
+package analytics
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/stretchr/testify/assert"
+)
+
+func initTestDB(t *testing.T) {
+	config := &app.Config{
+		DBType:       "sqlite",
+		DBSqlitePath: filepath.Join(t.TempDir(), "analytics.db"),
+	}
+	config.SetDefault()
+	db.Init(config)
+	t.Cleanup(db.Close)
+}
+
+func recordAt(t time.Time, pluginID string, invocations int, errors int) {
+	now = func() time.Time { return t }
+	for i := 0; i < invocations; i++ {
+		Record(pluginID, false)
+	}
+	for i := 0; i < errors; i++ {
+		Record(pluginID, true)
+	}
+	now = time.Now
+}
+
+func fetchCounts(t *testing.T, granularity string, from time.Time, to time.Time) map[string][2]int64 {
+	rollups, err := FetchRollups(granularity, from, to)
+	assert.NoError(t, err)
+
+	counts := map[string][2]int64{}
+	for _, rollup := range rollups {
+		key := rollup.PluginID + "@" + rollup.PeriodStart.UTC().Format(time.RFC3339)
+		counts[key] = [2]int64{rollup.InvocationCount, rollup.ErrorCount}
+	}
+	return counts
+}
+
+func TestRollupIsIdempotent(t *testing.T) {
+	initTestDB(t)
+
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	recordAt(day.Add(10*time.Minute), "langgenius/openai", 3, 1)
+	recordAt(day.Add(50*time.Minute), "langgenius/openai", 2, 0)
+	recordAt(day.Add(2*time.Hour), "langgenius/openai", 4, 0)
+	recordAt(day.Add(2*time.Hour), "langgenius/anthropic", 1, 1)
+	assert.NoError(t, Flush())
+
+	assert.NoError(t, Rollup(time.Now()))
+	// a rerun, e.g. after a crash before the watermark was seen by another node, changes nothing
+	assert.NoError(t, Rollup(time.Now()))
+
+	assert.Equal(t, map[string][2]int64{
+		"langgenius/openai@2025-01-01T00:00:00Z":    {5, 1},
+		"langgenius/openai@2025-01-01T02:00:00Z":    {4, 0},
+		"langgenius/anthropic@2025-01-01T02:00:00Z": {1, 1},
+	}, fetchCounts(t, GRANULARITY_HOUR, day, day.Add(DAY)))
+	assert.Equal(t, map[string][2]int64{
+		"langgenius/openai@2025-01-01T00:00:00Z":    {9, 1},
+		"langgenius/anthropic@2025-01-01T00:00:00Z": {1, 1},
+	}, fetchCounts(t, GRANULARITY_DAY, day, day.Add(DAY)))
+
+	// counts flushed late by another node are added to the periods already rolled up
+	recordAt(day.Add(20*time.Minute), "langgenius/openai", 1, 0)
+	assert.NoError(t, Flush())
+	assert.NoError(t, Rollup(time.Now()))
+
+	assert.Equal(t, [2]int64{6, 1}, fetchCounts(t, GRANULARITY_HOUR, day, day.Add(DAY))["langgenius/openai@2025-01-01T00:00:00Z"])
+	assert.Equal(t, [2]int64{10, 1}, fetchCounts(t, GRANULARITY_DAY, day, day.Add(DAY))["langgenius/openai@2025-01-01T00:00:00Z"])
+
+	// backfilling recomputes the same aggregates
+	assert.NoError(t, Backfill(day, day.Add(DAY)))
+	assert.Equal(t, [2]int64{10, 1}, fetchCounts(t, GRANULARITY_DAY, day, day.Add(DAY))["langgenius/openai@2025-01-01T00:00:00Z"])
+	assert.Len(t, fetchCounts(t, GRANULARITY_HOUR, day, day.Add(DAY)), 3)
+}
+
+func TestPurgeKeepsDailyRollups(t *testing.T) {
+	initTestDB(t)
+
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	recordAt(day.Add(time.Hour), "langgenius/openai", 2, 0)
+	recordAt(day.Add(DAY+time.Hour), "langgenius/openai", 1, 0)
+	assert.NoError(t, Flush())
+	assert.NoError(t, Rollup(time.Now()))
+
+	// the cutoff is moved to the start of its day so that no day is left partially purged
+	purged, err := Purge(day.Add(DAY + 2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+
+	assert.Len(t, fetchCounts(t, GRANULARITY_HOUR, day, day.Add(2*DAY)), 1)
+	assert.Len(t, fetchCounts(t, GRANULARITY_DAY, day, day.Add(2*DAY)), 2)
+}
@@ -0,0 +1,93 @@
+package analytics
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+type pendingKey struct {
+	pluginID string
+	minute   int64
+}
+
+type pendingCount struct {
+	invocations int
+	errors      int
+}
+
+var (
+	enabled atomic.Bool
+
+	mu      sync.Mutex
+	pending = map[pendingKey]*pendingCount{}
+
+	now = time.Now
+)
+
+// Enabled reports whether invocations are persisted for analytics
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Record counts an invocation of a plugin in the current minute, it's persisted by the next flush
+func Record(pluginID string, failed bool) {
+	key := pendingKey{pluginID: pluginID, minute: now().Truncate(time.Minute).Unix()}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	count, ok := pending[key]
+	if !ok {
+		count = &pendingCount{}
+		pending[key] = count
+	}
+	if failed {
+		count.errors++
+	} else {
+		count.invocations++
+	}
+}
+
+// Flush persists the counts recorded so far, counts failed to be persisted are kept for the next flush
+func Flush() error {
+	mu.Lock()
+	drained := pending
+	pending = map[pendingKey]*pendingCount{}
+	mu.Unlock()
+
+	if len(drained) == 0 {
+		return nil
+	}
+
+	records := make([]models.PluginInvocation, 0, len(drained))
+	for key, count := range drained {
+		records = append(records, models.PluginInvocation{
+			PluginID:        key.pluginID,
+			InvocationCount: count.invocations,
+			ErrorCount:      count.errors,
+			Timestamp:       time.Unix(key.minute, 0).UTC(),
+		})
+	}
+
+	if err := db.Create(&records); err != nil {
+		mu.Lock()
+		for key, count := range drained {
+			if current, ok := pending[key]; ok {
+				current.invocations += count.invocations
+				current.errors += count.errors
+			} else {
+				pending[key] = count
+			}
+		}
+		mu.Unlock()
+		return err
+	}
+
+	log.Debug("flushed %d invocation counts", len(records))
+	return nil
+}
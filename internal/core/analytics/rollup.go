@@ -0,0 +1,202 @@
+package analytics
+
+import (
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"gorm.io/gorm"
+)
+
+const (
+	GRANULARITY_HOUR = "hour"
+	GRANULARITY_DAY  = "day"
+
+	ROLLUP_JOB = "plugin_invocation_rollup"
+
+	// invocation counts created this long before the watermark are processed again, it covers
+	// clock skew between nodes and flushes committed while the previous run was scanning
+	ROLLUP_WATERMARK_OVERLAP = 2 * time.Minute
+
+	DAY = 24 * time.Hour
+)
+
+type aggregate struct {
+	PluginID        string
+	InvocationCount int64
+	ErrorCount      int64
+}
+
+// replaceRollups replaces the rollups of a period with the aggregates of its source
+func replaceRollups(tx *gorm.DB, granularity string, start time.Time, aggregates []aggregate) error {
+	if _, err := db.DeleteBy[models.PluginInvocationRollup](
+		db.WithTransactionContext(tx),
+		db.Equal("granularity", granularity),
+		db.Equal("period_start", start),
+	); err != nil {
+		return err
+	}
+
+	if len(aggregates) == 0 {
+		return nil
+	}
+
+	rollups := make([]models.PluginInvocationRollup, 0, len(aggregates))
+	for _, aggregate := range aggregates {
+		rollups = append(rollups, models.PluginInvocationRollup{
+			Granularity:     granularity,
+			PluginID:        aggregate.PluginID,
+			PeriodStart:     start,
+			InvocationCount: aggregate.InvocationCount,
+			ErrorCount:      aggregate.ErrorCount,
+		})
+	}
+	return db.Create(&rollups, tx)
+}
+
+// rollupHour recomputes the hourly rollups starting at start from the invocation counts
+func rollupHour(tx *gorm.DB, start time.Time) error {
+	aggregates, err := db.GetAll[aggregate](
+		db.WithTransactionContext(tx),
+		db.Model(&models.PluginInvocation{}),
+		db.Fields("plugin_id", "SUM(invocation_count) AS invocation_count", "SUM(error_count) AS error_count"),
+		db.GreaterThanOrEqual("timestamp", start),
+		db.LessThan("timestamp", start.Add(time.Hour)),
+		db.GroupBy("plugin_id"),
+	)
+	if err != nil {
+		return err
+	}
+	return replaceRollups(tx, GRANULARITY_HOUR, start, aggregates)
+}
+
+// rollupDay recomputes the daily rollups starting at start from the hourly rollups
+func rollupDay(tx *gorm.DB, start time.Time) error {
+	aggregates, err := db.GetAll[aggregate](
+		db.WithTransactionContext(tx),
+		db.Model(&models.PluginInvocationRollup{}),
+		db.Fields("plugin_id", "SUM(invocation_count) AS invocation_count", "SUM(error_count) AS error_count"),
+		db.Equal("granularity", GRANULARITY_HOUR),
+		db.GreaterThanOrEqual("period_start", start),
+		db.LessThan("period_start", start.Add(DAY)),
+		db.GroupBy("plugin_id"),
+	)
+	if err != nil {
+		return err
+	}
+	return replaceRollups(tx, GRANULARITY_DAY, start, aggregates)
+}
+
+// Rollup recomputes the hours and days which received invocation counts since the watermark
+// and advances it, all in one transaction holding the watermark row
+//
+// rollups are replaced instead of incremented, processing a period twice after a crash or on
+// two nodes at once yields the same aggregates, and counts flushed late are picked up by the
+// next run as their period is recomputed
+func Rollup(at time.Time) error {
+	return db.WithTransaction(func(tx *gorm.DB) error {
+		watermark, err := db.GetOne[models.AnalyticsWatermark](
+			db.WithTransactionContext(tx),
+			db.Equal("job", ROLLUP_JOB),
+			db.WLock(),
+		)
+		if err == db.ErrDatabaseNotFound {
+			watermark = models.AnalyticsWatermark{Job: ROLLUP_JOB}
+		} else if err != nil {
+			return err
+		}
+
+		minutes, err := db.GetAll[models.PluginInvocation](
+			db.WithTransactionContext(tx),
+			db.Model(&models.PluginInvocation{}),
+			db.Fields("timestamp"),
+			db.GreaterThan("created_at", watermark.Watermark.Add(-ROLLUP_WATERMARK_OVERLAP)),
+			db.GroupBy("timestamp"),
+		)
+		if err != nil {
+			return err
+		}
+
+		hours := map[time.Time]bool{}
+		days := map[time.Time]bool{}
+		for _, minute := range minutes {
+			hour := minute.Timestamp.UTC().Truncate(time.Hour)
+			if !hours[hour] {
+				hours[hour] = true
+				days[hour.Truncate(DAY)] = true
+			}
+		}
+
+		for hour := range hours {
+			if err := rollupHour(tx, hour); err != nil {
+				return err
+			}
+		}
+		for day := range days {
+			if err := rollupDay(tx, day); err != nil {
+				return err
+			}
+		}
+
+		watermark.Watermark = at.UTC()
+		if watermark.ID == "" {
+			return db.Create(&watermark, tx)
+		}
+		return db.Update(&watermark, tx)
+	})
+}
+
+// Backfill recomputes the rollups of every hour between from and to and of the days they belong
+// to, one transaction per day, invocation counts of the range must not have been purged yet
+func Backfill(from time.Time, to time.Time) error {
+	from = from.UTC().Truncate(time.Hour)
+	to = to.UTC()
+
+	for day := from.Truncate(DAY); day.Before(to); day = day.Add(DAY) {
+		if err := db.WithTransaction(func(tx *gorm.DB) error {
+			for hour := day; hour.Before(day.Add(DAY)) && hour.Before(to); hour = hour.Add(time.Hour) {
+				if hour.Before(from) {
+					continue
+				}
+				if err := rollupHour(tx, hour); err != nil {
+					return err
+				}
+			}
+			return rollupDay(tx, day)
+		}); err != nil {
+			return err
+		}
+		log.Info("recomputed invocation rollups of %s", day.Format(time.DateOnly))
+	}
+
+	return nil
+}
+
+// Purge deletes invocation counts and hourly rollups before the day of before, daily rollups are kept
+func Purge(before time.Time) (int64, error) {
+	before = before.UTC().Truncate(DAY)
+
+	invocations, err := db.DeleteBy[models.PluginInvocation](
+		db.LessThan("timestamp", before),
+	)
+	if err != nil {
+		return invocations, err
+	}
+
+	rollups, err := db.DeleteBy[models.PluginInvocationRollup](
+		db.Equal("granularity", GRANULARITY_HOUR),
+		db.LessThan("period_start", before),
+	)
+	return invocations + rollups, err
+}
+
+// FetchRollups returns the rollups of a granularity with periods starting within [from, to)
+func FetchRollups(granularity string, from time.Time, to time.Time) ([]models.PluginInvocationRollup, error) {
+	return db.GetAll[models.PluginInvocationRollup](
+		db.Equal("granularity", granularity),
+		db.GreaterThanOrEqual("period_start", from.UTC()),
+		db.LessThan("period_start", to.UTC()),
+		db.OrderBy("period_start", false),
+	)
+}
@@ -0,0 +1,277 @@
+package anomaly
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+const (
+	METRIC_INVOCATIONS    = "invocations"
+	METRIC_PAYLOAD_BYTES  = "payload_bytes"
+	METRIC_BACKWARDS_PREF = "backwards:"
+
+	// weight of the latest window when updating a baseline
+	BASELINE_SMOOTHING = 0.1
+	// weight of an anomalous window, a burst hardly moves the baseline while a lasting shift is
+	// learned within a few dozen windows
+	ANOMALY_SMOOTHING = 0.02
+	// alerts kept for FetchStatus
+	MAX_RECENT_ALERTS = 100
+)
+
+type Config struct {
+	// Window is the length of an observation window, baselines are updated once per window
+	Window time.Duration
+	// WarmupWindows is the number of windows a plugin is observed before it can be flagged
+	WarmupWindows int
+	// Factor flags a window if a metric exceeds its baseline by this factor
+	Factor float64
+	// MinCount and MinPayloadBytes ignore windows too small to be meaningful, e.g. 1 call growing to 10
+	MinCount        float64
+	MinPayloadBytes float64
+	// Cooldown suppresses repeated alerts of the same plugin and metric
+	Cooldown time.Duration
+}
+
+type Alert struct {
+	PluginID   string    `json:"plugin_id"`
+	Metric     string    `json:"metric"`
+	Observed   float64   `json:"observed"`
+	Baseline   float64   `json:"baseline"`
+	Factor     float64   `json:"factor"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+type PluginBaseline struct {
+	PluginID string             `json:"plugin_id"`
+	Windows  int                `json:"windows"`
+	Baseline map[string]float64 `json:"baseline"`
+}
+
+type Status struct {
+	Config       Config           `json:"config"`
+	Baselines    []PluginBaseline `json:"baselines"`
+	RecentAlerts []Alert          `json:"recent_alerts"`
+}
+
+type pluginStats struct {
+	current  map[string]float64
+	baseline map[string]float64
+	windows  int
+	alerted  map[string]time.Time
+}
+
+type Detector struct {
+	config Config
+
+	mu        sync.Mutex
+	plugins   map[string]*pluginStats
+	alerts    []Alert
+	notifiers []Notifier
+}
+
+var (
+	globalDetector *Detector
+)
+
+func NewDetector(config Config) *Detector {
+	return &Detector{
+		config:  config,
+		plugins: map[string]*pluginStats{},
+	}
+}
+
+// Start enables the detector of current node, every node learns baselines from the traffic it serves
+func Start(config Config, notifiers ...Notifier) {
+	d := NewDetector(config)
+	d.notifiers = notifiers
+	globalDetector = d
+
+	routine.Submit(map[string]string{
+		"module":   "anomaly",
+		"function": "Start",
+	}, func() {
+		ticker := time.NewTicker(config.Window)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			d.Rotate(now)
+		}
+	})
+}
+
+func Enabled() bool {
+	return globalDetector != nil
+}
+
+// RecordInvocation records a request dispatched to a plugin
+func RecordInvocation(pluginID string, payloadSize int) {
+	if globalDetector == nil {
+		return
+	}
+	globalDetector.Record(pluginID, METRIC_INVOCATIONS, 1)
+	globalDetector.Record(pluginID, METRIC_PAYLOAD_BYTES, float64(payloadSize))
+}
+
+// RecordBackwardsInvocation records a request from a plugin to Dify
+func RecordBackwardsInvocation(pluginID string, invokeType string, payloadSize int) {
+	if globalDetector == nil {
+		return
+	}
+	globalDetector.Record(pluginID, METRIC_BACKWARDS_PREF+invokeType, 1)
+	globalDetector.Record(pluginID, METRIC_PAYLOAD_BYTES, float64(payloadSize))
+}
+
+// FetchStatus returns nil if anomaly detection is disabled
+func FetchStatus() *Status {
+	if globalDetector == nil {
+		return nil
+	}
+	return globalDetector.Status()
+}
+
+func (d *Detector) Record(pluginID string, metric string, value float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stats, ok := d.plugins[pluginID]
+	if !ok {
+		stats = &pluginStats{
+			current:  map[string]float64{},
+			baseline: map[string]float64{},
+			alerted:  map[string]time.Time{},
+		}
+		d.plugins[pluginID] = stats
+	}
+	stats.current[metric] += value
+}
+
+// Rotate closes the current window, compares it against the baselines and learns from it
+func (d *Detector) Rotate(now time.Time) {
+	d.mu.Lock()
+	alerts := []Alert{}
+
+	for pluginID, stats := range d.plugins {
+		metrics := map[string]struct{}{}
+		for metric := range stats.current {
+			metrics[metric] = struct{}{}
+		}
+		for metric := range stats.baseline {
+			metrics[metric] = struct{}{}
+		}
+
+		for metric := range metrics {
+			observed := stats.current[metric]
+			baseline, learned := stats.baseline[metric]
+
+			if stats.windows >= d.config.WarmupWindows && d.deviates(metric, observed, baseline) {
+				if last, ok := stats.alerted[metric]; !ok || now.Sub(last) >= d.config.Cooldown {
+					stats.alerted[metric] = now
+					alerts = append(alerts, Alert{
+						PluginID:   pluginID,
+						Metric:     metric,
+						Observed:   observed,
+						Baseline:   baseline,
+						Factor:     d.config.Factor,
+						DetectedAt: now,
+					})
+				}
+				// anomalous windows are learned slowly, otherwise a compromised plugin drifts its own baseline
+				stats.baseline[metric] = ANOMALY_SMOOTHING*observed + (1-ANOMALY_SMOOTHING)*baseline
+				continue
+			}
+
+			if !learned {
+				stats.baseline[metric] = observed
+			} else {
+				stats.baseline[metric] = BASELINE_SMOOTHING*observed + (1-BASELINE_SMOOTHING)*baseline
+			}
+		}
+
+		stats.windows++
+		stats.current = map[string]float64{}
+
+		if idle(stats.baseline) {
+			delete(d.plugins, pluginID)
+		}
+	}
+
+	d.alerts = append(d.alerts, alerts...)
+	if len(d.alerts) > MAX_RECENT_ALERTS {
+		d.alerts = d.alerts[len(d.alerts)-MAX_RECENT_ALERTS:]
+	}
+	notifiers := d.notifiers
+	d.mu.Unlock()
+
+	for _, alert := range alerts {
+		log.Warn(
+			"anomalous behavior of plugin %s: %s is %.0f in the last window, baseline is %.2f",
+			alert.PluginID, alert.Metric, alert.Observed, alert.Baseline,
+		)
+		for _, notifier := range notifiers {
+			routine.Submit(map[string]string{
+				"module":   "anomaly",
+				"function": "Notify",
+			}, func() {
+				if err := notifier.Notify(alert); err != nil {
+					log.Error("failed to notify anomaly of plugin %s: %s", alert.PluginID, err.Error())
+				}
+			})
+		}
+	}
+}
+
+func (d *Detector) deviates(metric string, observed float64, baseline float64) bool {
+	minimum := d.config.MinCount
+	if metric == METRIC_PAYLOAD_BYTES {
+		minimum = d.config.MinPayloadBytes
+	}
+	if observed < minimum {
+		return false
+	}
+	// a metric never seen before has a baseline of 0, compare it against a single occurrence
+	return observed > math.Max(baseline, 1)*d.config.Factor
+}
+
+// idle reports whether a plugin has been quiet long enough for its baseline to vanish
+func idle(baseline map[string]float64) bool {
+	for _, value := range baseline {
+		if value >= 0.01 {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *Detector) Status() *Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	status := &Status{
+		Config:       d.config,
+		Baselines:    make([]PluginBaseline, 0, len(d.plugins)),
+		RecentAlerts: append([]Alert{}, d.alerts...),
+	}
+	for pluginID, stats := range d.plugins {
+		baseline := make(map[string]float64, len(stats.baseline))
+		for metric, value := range stats.baseline {
+			baseline[metric] = value
+		}
+		status.Baselines = append(status.Baselines, PluginBaseline{
+			PluginID: pluginID,
+			Windows:  stats.windows,
+			Baseline: baseline,
+		})
+	}
+	sort.Slice(status.Baselines, func(i, j int) bool {
+		return strings.Compare(status.Baselines[i].PluginID, status.Baselines[j].PluginID) < 0
+	})
+
+	return status
+}
@@ -0,0 +1,108 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestDetector() *Detector {
+	return NewDetector(Config{
+		Window:          time.Minute,
+		WarmupWindows:   3,
+		Factor:          10,
+		MinCount:        20,
+		MinPayloadBytes: 1024,
+		Cooldown:        time.Hour,
+	})
+}
+
+func TestDetectorFlagsDeviation(t *testing.T) {
+	d := newTestDetector()
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 5; j++ {
+			d.Record("langgenius/tool", METRIC_BACKWARDS_PREF+"llm", 1)
+		}
+		d.Rotate(now)
+		now = now.Add(time.Minute)
+	}
+	if alerts := d.Status().RecentAlerts; len(alerts) != 0 {
+		t.Fatalf("steady traffic should not be flagged: %v", alerts)
+	}
+
+	// 100x the usual model calls
+	for j := 0; j < 500; j++ {
+		d.Record("langgenius/tool", METRIC_BACKWARDS_PREF+"llm", 1)
+	}
+	d.Rotate(now)
+
+	alerts := d.Status().RecentAlerts
+	if len(alerts) != 1 || alerts[0].Metric != METRIC_BACKWARDS_PREF+"llm" || alerts[0].Observed != 500 {
+		t.Fatalf("unexpected alerts: %v", alerts)
+	}
+
+	// the anomalous window is learned slowly
+	baseline := d.Status().Baselines[0].Baseline[METRIC_BACKWARDS_PREF+"llm"]
+	if expected := ANOMALY_SMOOTHING*500 + (1-ANOMALY_SMOOTHING)*5; baseline != expected {
+		t.Fatalf("baseline should move to %f, got %f", expected, baseline)
+	}
+
+	// repeated deviations are suppressed during the cooldown
+	for j := 0; j < 500; j++ {
+		d.Record("langgenius/tool", METRIC_BACKWARDS_PREF+"llm", 1)
+	}
+	d.Rotate(now.Add(time.Minute))
+	if alerts := d.Status().RecentAlerts; len(alerts) != 1 {
+		t.Fatalf("alert should be suppressed during cooldown: %v", alerts)
+	}
+}
+
+func TestDetectorWarmupAndMinimum(t *testing.T) {
+	d := newTestDetector()
+	now := time.Now()
+
+	// no alert during warmup even for big jumps
+	for j := 0; j < 1000; j++ {
+		d.Record("langgenius/tool", METRIC_INVOCATIONS, 1)
+	}
+	d.Rotate(now)
+	d.Rotate(now)
+	d.Rotate(now)
+
+	// new backwards invocation type below the minimum is ignored
+	for j := 0; j < 15; j++ {
+		d.Record("langgenius/tool", METRIC_BACKWARDS_PREF+"app", 1)
+	}
+	d.Record("langgenius/tool", METRIC_INVOCATIONS, 1)
+	d.Rotate(now)
+
+	if alerts := d.Status().RecentAlerts; len(alerts) != 0 {
+		t.Fatalf("unexpected alerts: %v", alerts)
+	}
+}
+
+func TestDetectorLearnsLastingShift(t *testing.T) {
+	d := newTestDetector()
+	d.config.Cooldown = 0
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		d.Record("langgenius/tool", METRIC_INVOCATIONS, 5)
+		d.Rotate(now)
+	}
+
+	// the plugin serves 100x the usual traffic from now on
+	windows := 0
+	for ; windows < 200; windows++ {
+		d.Record("langgenius/tool", METRIC_INVOCATIONS, 500)
+		alerts := len(d.Status().RecentAlerts)
+		d.Rotate(now)
+		if len(d.Status().RecentAlerts) == alerts {
+			break
+		}
+	}
+	if windows == 0 || windows == 200 {
+		t.Fatalf("a lasting shift should alert first and be learned eventually, took %d windows", windows)
+	}
+}
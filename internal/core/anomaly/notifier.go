@@ -0,0 +1,48 @@
+package anomaly
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+)
+
+// Notifier delivers alerts outside of the daemon
+type Notifier interface {
+	Notify(alert Alert) error
+}
+
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier posts every alert as json to url
+func NewWebhookNotifier(url string) Notifier {
+	return &webhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (w *webhookNotifier) Notify(alert Alert) error {
+	resp, err := w.client.Post(
+		w.url,
+		"application/json",
+		bytes.NewReader(parser.MarshalJsonBytes(map[string]any{
+			"event": "plugin_anomaly",
+			"alert": alert,
+		})),
+	)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
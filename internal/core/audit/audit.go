@@ -0,0 +1,144 @@
+// Package audit appends administrative and debugging events to the audit table, records are
+// queried and exported but never changed
+package audit
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/retention"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+)
+
+const (
+	EVENT_PLUGIN_INSTALL           = "plugin.install"
+	EVENT_PLUGIN_UPGRADE           = "plugin.upgrade"
+	EVENT_PLUGIN_UPGRADE_SCHEDULE  = "plugin.upgrade.schedule"
+	EVENT_PLUGIN_UNINSTALL         = "plugin.uninstall"
+	EVENT_PLUGIN_VERSION_INSTALL   = "plugin.version.install"
+	EVENT_PLUGIN_VERSION_UNINSTALL = "plugin.version.uninstall"
+	EVENT_PLUGIN_INSTALL_APPROVE   = "plugin.install.approve"
+	EVENT_PLUGIN_INSTALL_REJECT    = "plugin.install.reject"
+	EVENT_PLUGIN_PERMISSION        = "plugin.permission.consent"
+	EVENT_PLUGIN_EGRESS_DENY       = "plugin.egress.deny"
+	EVENT_CREDENTIAL_SET           = "credential.set"
+	EVENT_CREDENTIAL_DELETE        = "credential.delete"
+	EVENT_OAUTH_CREDENTIAL_DELETE  = "credential.oauth.delete"
+	EVENT_ENDPOINT_SETUP           = "endpoint.setup"
+	EVENT_ENDPOINT_UPDATE          = "endpoint.update"
+	EVENT_ENDPOINT_REMOVE          = "endpoint.remove"
+	EVENT_ENDPOINT_ENABLE          = "endpoint.enable"
+	EVENT_ENDPOINT_DISABLE         = "endpoint.disable"
+	EVENT_DEBUGGING_CONNECT        = "debugging.connect"
+	EVENT_DEBUGGING_DISCONNECT     = "debugging.disconnect"
+	EVENT_TENANT_DATA_PURGE        = "tenant.data.purge"
+	EVENT_API_KEY_CREATE           = "api_key.create"
+	EVENT_API_KEY_ROTATE           = "api_key.rotate"
+	EVENT_API_KEY_REVOKE           = "api_key.revoke"
+
+	MAX_MESSAGE_LENGTH = 1023
+	EXPORT_BATCH_SIZE  = 500
+)
+
+var enabled atomic.Bool
+
+// Start records events from now on, records older than the retention of audit records are purged
+func Start() {
+	enabled.Store(true)
+	retention.Register(retention.CLASS_AUDIT_RECORDS, Purge)
+}
+
+// Enabled reports whether events are recorded
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Record appends the record, a record failed to be persisted is logged instead
+func Record(record models.AuditRecord) {
+	if !Enabled() {
+		return
+	}
+
+	if record.Result == "" {
+		record.Result = models.AuditResultSuccess
+	}
+	if len(record.Message) > MAX_MESSAGE_LENGTH {
+		record.Message = record.Message[:MAX_MESSAGE_LENGTH]
+	}
+
+	if err := db.Create(&record); err != nil {
+		log.Error("failed to record audit event %s of tenant %s: %s", record.Event, record.TenantID, err.Error())
+	}
+}
+
+// Filter selects records, zero fields match everything
+type Filter struct {
+	TenantID string
+	Event    string
+	Actor    string
+	Result   models.AuditResult
+	From     time.Time
+	To       time.Time
+}
+
+func (f Filter) queries() []db.GenericQuery {
+	queries := []db.GenericQuery{}
+	if f.TenantID != "" {
+		queries = append(queries, db.Equal("tenant_id", f.TenantID))
+	}
+	if f.Event != "" {
+		queries = append(queries, db.Equal("event", f.Event))
+	}
+	if f.Actor != "" {
+		queries = append(queries, db.Equal("actor", f.Actor))
+	}
+	if f.Result != "" {
+		queries = append(queries, db.Equal("result", string(f.Result)))
+	}
+	if !f.From.IsZero() {
+		queries = append(queries, db.GreaterThanOrEqual("created_at", f.From))
+	}
+	if !f.To.IsZero() {
+		queries = append(queries, db.LessThan("created_at", f.To))
+	}
+	return queries
+}
+
+// List returns a page of the records matching filter, the latest first
+func List(filter Filter, page int, pageSize int) ([]models.AuditRecord, error) {
+	queries := append(filter.queries(), db.OrderBy("created_at", true), db.Page(page, pageSize))
+	return db.GetAll[models.AuditRecord](queries...)
+}
+
+// Export writes the records matching filter as json lines, the oldest first, records appended
+// while exporting are included as they come after the exported ones
+func Export(filter Filter, w io.Writer) error {
+	for page := 1; ; page++ {
+		queries := append(filter.queries(), db.OrderBy("created_at", false), db.Page(page, EXPORT_BATCH_SIZE))
+		records, err := db.GetAll[models.AuditRecord](queries...)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			if _, err := w.Write(append(parser.MarshalJsonBytes(record), '\n')); err != nil {
+				return err
+			}
+		}
+
+		if len(records) < EXPORT_BATCH_SIZE {
+			return nil
+		}
+	}
+}
+
+// Purge deletes records created before the given time, it's the only way records are removed
+func Purge(before time.Time) (int64, error) {
+	return db.DeleteBy[models.AuditRecord](
+		db.LessThan("created_at", before),
+	)
+}
@@ -38,4 +38,12 @@ type BackwardsInvocation interface {
 	UploadFile(payload *UploadFileRequest) (*UploadFileResponse, error)
 	// FetchApp
 	FetchApp(payload *FetchAppRequest) (map[string]any, error)
+	// InvokeWorkflowTool
+	InvokeWorkflowTool(payload *InvokeWorkflowToolRequest) (*stream.Stream[map[string]any], error)
+}
+
+// TraceableBackwardsInvocation is implemented by invocations which are able to
+// propagate trace context to Dify API, it returns a copy bound to the carrier
+type TraceableBackwardsInvocation interface {
+	WithTraceContext(carrier map[string]string) BackwardsInvocation
 }
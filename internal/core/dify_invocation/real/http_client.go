@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/mtls"
 )
 
 type NewDifyInvocationDaemonPayload struct {
@@ -14,6 +15,8 @@ type NewDifyInvocationDaemonPayload struct {
 	CallingKey   string
 	WriteTimeout int64
 	ReadTimeout  int64
+	// TLS enables mutual tls towards dify, nil to use the default verification only
+	TLS *mtls.Files
 }
 
 func NewDifyInvocationDaemon(payload NewDifyInvocationDaemonPayload) (dify_invocation.BackwardsInvocation, error) {
@@ -24,14 +27,23 @@ func NewDifyInvocationDaemon(payload NewDifyInvocationDaemonPayload) (dify_invoc
 		return nil, err
 	}
 
+	transport := &http.Transport{
+		Dial: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 120 * time.Second,
+		}).Dial,
+		IdleConnTimeout: 120 * time.Second,
+	}
+	if payload.TLS != nil {
+		loader, err := mtls.NewLoader(*payload.TLS)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = loader.ClientConfig(baseurl.Hostname())
+	}
+
 	client := &http.Client{
-		Transport: &http.Transport{
-			Dial: (&net.Dialer{
-				Timeout:   5 * time.Second,
-				KeepAlive: 120 * time.Second,
-			}).Dial,
-			IdleConnTimeout: 120 * time.Second,
-		},
+		Transport: transport,
 	}
 
 	invocation.difyInnerApiBaseurl = baseurl
@@ -42,3 +54,9 @@ func NewDifyInvocationDaemon(payload NewDifyInvocationDaemonPayload) (dify_invoc
 
 	return invocation, nil
 }
+
+func (i *RealBackwardsInvocation) WithTraceContext(carrier map[string]string) dify_invocation.BackwardsInvocation {
+	invocation := *i
+	invocation.traceHeaders = carrier
+	return &invocation
+}
@@ -13,12 +13,20 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
 )
 
+func (i *RealBackwardsInvocation) headers() map[string]string {
+	headers := map[string]string{
+		"X-Inner-Api-Key": i.difyInnerApiKey,
+	}
+	for k, v := range i.traceHeaders {
+		headers[k] = v
+	}
+	return headers
+}
+
 // Send a request to dify inner api and validate the response
 func Request[T any](i *RealBackwardsInvocation, method string, path string, options ...http_requests.HttpOptions) (*T, error) {
 	options = append(options,
-		http_requests.HttpHeader(map[string]string{
-			"X-Inner-Api-Key": i.difyInnerApiKey,
-		}),
+		http_requests.HttpHeader(i.headers()),
 		http_requests.HttpWriteTimeout(i.writeTimeout),
 		http_requests.HttpReadTimeout(i.readTimeout),
 	)
@@ -52,9 +60,7 @@ func StreamResponse[T any](i *RealBackwardsInvocation, method string, path strin
 	*stream.Stream[T], error,
 ) {
 	options = append(
-		options, http_requests.HttpHeader(map[string]string{
-			"X-Inner-Api-Key": i.difyInnerApiKey,
-		}),
+		options, http_requests.HttpHeader(i.headers()),
 		http_requests.HttpWriteTimeout(i.writeTimeout),
 		http_requests.HttpReadTimeout(i.readTimeout),
 		http_requests.HttpUsingLengthPrefixed(true),
@@ -149,6 +155,10 @@ func (i *RealBackwardsInvocation) InvokeApp(payload *dify_invocation.InvokeAppRe
 	return StreamResponse[map[string]any](i, "POST", "invoke/app", http_requests.HttpPayloadJson(payload))
 }
 
+func (i *RealBackwardsInvocation) InvokeWorkflowTool(payload *dify_invocation.InvokeWorkflowToolRequest) (*stream.Stream[map[string]any], error) {
+	return StreamResponse[map[string]any](i, "POST", "invoke/workflow-tool", http_requests.HttpPayloadJson(payload))
+}
+
 func (i *RealBackwardsInvocation) InvokeParameterExtractor(payload *dify_invocation.InvokeParameterExtractorRequest) (*dify_invocation.InvokeNodeResponse, error) {
 	return Request[dify_invocation.InvokeNodeResponse](i, "POST", "invoke/parameter-extractor", http_requests.HttpPayloadJson(payload))
 }
@@ -11,6 +11,9 @@ type RealBackwardsInvocation struct {
 	client              *http.Client
 	writeTimeout        int64
 	readTimeout         int64
+
+	// traceHeaders are extra headers which carry the trace context
+	traceHeaders map[string]string
 }
 
 type BaseBackwardsInvocationResponse[T any] struct {
@@ -428,3 +428,21 @@ func (m *MockedDifyInvocation) FetchApp(payload *dify_invocation.FetchAppRequest
 		"name": "test",
 	}, nil
 }
+
+func (m *MockedDifyInvocation) InvokeWorkflowTool(payload *dify_invocation.InvokeWorkflowToolRequest) (*stream.Stream[map[string]any], error) {
+	stream := stream.NewStream[map[string]any](5)
+	routine.Submit(nil, func() {
+		for _, event := range []map[string]any{
+			{"event": "workflow_started", "data": map[string]any{"id": "workflow_run"}},
+			{"event": "node_started", "data": map[string]any{"node_id": "llm", "node_type": "llm"}},
+			{"event": "node_finished", "data": map[string]any{"node_id": "llm", "outputs": map[string]any{"text": "hello world"}}},
+			{"event": "workflow_finished", "data": map[string]any{"outputs": map[string]any{"text": "hello world"}}},
+		} {
+			stream.Write(event)
+			time.Sleep(100 * time.Millisecond)
+		}
+		stream.Close()
+	})
+
+	return stream, nil
+}
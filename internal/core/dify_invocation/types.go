@@ -33,6 +33,7 @@ const (
 	INVOKE_TYPE_SYSTEM_SUMMARY           InvokeType = "system_summary"
 	INVOKE_TYPE_UPLOAD_FILE              InvokeType = "upload_file"
 	INVOKE_TYPE_FETCH_APP                InvokeType = "fetch_app"
+	INVOKE_TYPE_WORKFLOW_TOOL            InvokeType = "workflow_tool"
 )
 
 type InvokeLLMSchema struct {
@@ -111,21 +112,50 @@ const (
 	STORAGE_OPT_SET   StorageOpt = "set"
 	STORAGE_OPT_DEL   StorageOpt = "del"
 	STORAGE_OPT_EXIST StorageOpt = "exist"
+
+	STORAGE_OPT_BATCH_GET StorageOpt = "batch_get"
+	STORAGE_OPT_BATCH_SET StorageOpt = "batch_set"
+	STORAGE_OPT_BATCH_DEL StorageOpt = "batch_del"
 )
 
+func (opt StorageOpt) IsBatch() bool {
+	return opt == STORAGE_OPT_BATCH_GET || opt == STORAGE_OPT_BATCH_SET || opt == STORAGE_OPT_BATCH_DEL
+}
+
 func isStorageOpt(fl validator.FieldLevel) bool {
 	opt := StorageOpt(fl.Field().String())
-	return opt == STORAGE_OPT_GET || opt == STORAGE_OPT_SET || opt == STORAGE_OPT_DEL || opt == STORAGE_OPT_EXIST
+	return opt == STORAGE_OPT_GET || opt == STORAGE_OPT_SET || opt == STORAGE_OPT_DEL || opt == STORAGE_OPT_EXIST || opt.IsBatch()
+}
+
+// isStorageKey requires the key of operations on a single key
+func isStorageKey(fl validator.FieldLevel) bool {
+	request, ok := fl.Parent().Interface().(InvokeStorageRequest)
+	if !ok {
+		return true
+	}
+	return request.Opt.IsBatch() || request.Key != ""
 }
 
 func init() {
 	validators.GlobalEntitiesValidator.RegisterValidation("storage_opt", isStorageOpt)
+	validators.GlobalEntitiesValidator.RegisterValidation("storage_key", isStorageKey)
+}
+
+type StorageItem struct {
+	Key   string `json:"key" validate:"required"`
+	Value string `json:"value"` // encoded in hex
+	TTL   int64  `json:"ttl" validate:"min=0"`
 }
 
 type InvokeStorageRequest struct {
 	Opt   StorageOpt `json:"opt" validate:"required,storage_opt"`
-	Key   string     `json:"key" validate:"required"`
+	Key   string     `json:"key" validate:"storage_key"`
 	Value string     `json:"value"` // encoded in hex, optional
+	// TTL is the seconds until the key set expires, 0 keeps it
+	TTL int64 `json:"ttl" validate:"min=0"`
+	// Keys are read or deleted by batch_get and batch_del, Items are written by batch_set
+	Keys  []string      `json:"keys" validate:"max=100,dive,required"`
+	Items []StorageItem `json:"items" validate:"max=100,dive"`
 }
 
 type InvokeAppRequest struct {
@@ -134,6 +164,45 @@ type InvokeAppRequest struct {
 	InvokeAppSchema
 }
 
+// InvokeWorkflowToolRequest runs a published workflow or chatflow as a tool
+type InvokeWorkflowToolRequest struct {
+	BaseInvokeDifyRequest
+
+	AppId  string         `json:"app_id" validate:"required"`
+	Inputs map[string]any `json:"inputs" validate:"omitempty"`
+	// Query and ConversationId are only used by chatflows
+	Query          string `json:"query" validate:"omitempty"`
+	ConversationId string `json:"conversation_id" validate:"omitempty"`
+	// StreamNodeEvents forwards the events of nodes as they run, otherwise only the events of
+	// the workflow itself and its outputs are forwarded
+	StreamNodeEvents bool `json:"stream_node_events"`
+}
+
+// workflowNodeEvents are the intermediate events streamed while nodes of a workflow run
+var workflowNodeEvents = map[string]bool{
+	"node_started":             true,
+	"node_finished":            true,
+	"node_retry":               true,
+	"iteration_started":        true,
+	"iteration_next":           true,
+	"iteration_completed":      true,
+	"loop_started":             true,
+	"loop_next":                true,
+	"loop_completed":           true,
+	"parallel_branch_started":  true,
+	"parallel_branch_finished": true,
+	"agent_log":                true,
+}
+
+// Forwards reports whether an event streamed by the workflow is forwarded to the plugin
+func (r *InvokeWorkflowToolRequest) Forwards(event map[string]any) bool {
+	if r.StreamNodeEvents {
+		return true
+	}
+	name, _ := event["event"].(string)
+	return !workflowNodeEvents[name]
+}
+
 type ModelConfig struct {
 	Provider         string         `json:"provider" validate:"required"`
 	Name             string         `json:"name" validate:"required"`
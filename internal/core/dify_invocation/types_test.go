@@ -0,0 +1,23 @@
+package dify_invocation
+
+import "testing"
+
+func TestWorkflowToolForwards(t *testing.T) {
+	request := &InvokeWorkflowToolRequest{}
+	for event, expected := range map[string]bool{
+		"workflow_started":  true,
+		"text_chunk":        true,
+		"node_started":      false,
+		"iteration_next":    false,
+		"workflow_finished": true,
+	} {
+		if forwarded := request.Forwards(map[string]any{"event": event}); forwarded != expected {
+			t.Fatalf("expected %s to be forwarded: %v", event, expected)
+		}
+	}
+
+	request.StreamNodeEvents = true
+	if !request.Forwards(map[string]any{"event": "node_finished"}) {
+		t.Fatal("expected node events to be forwarded once requested")
+	}
+}
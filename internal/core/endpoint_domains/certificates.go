@@ -0,0 +1,94 @@
+package endpoint_domains
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Certificates serves the certificate of a custom domain by SNI, ENDPOINT_TLS_CERT_DIR holds
+// `<hostname>.crt` and `<hostname>.key` or `*.<parent>.crt` and `*.<parent>.key` for wildcards,
+// files are read again once they change so renewed certificates are picked up without a restart
+type Certificates struct {
+	dir string
+
+	mu     sync.Mutex
+	loaded map[string]*loadedCertificate
+}
+
+type loadedCertificate struct {
+	certificate *tls.Certificate
+	modified    time.Time
+}
+
+func NewCertificates(dir string) *Certificates {
+	return &Certificates{
+		dir:    dir,
+		loaded: map[string]*loadedCertificate{},
+	}
+}
+
+// GetCertificate is used as tls.Config.GetCertificate
+func (c *Certificates) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	hostname := NormalizeHostname(hello.ServerName)
+	if !Allowed(hostname) {
+		return nil, fmt.Errorf("no certificate for %s", hello.ServerName)
+	}
+
+	names := []string{hostname}
+	if _, parent, ok := strings.Cut(hostname, "."); ok {
+		names = append(names, "*."+parent)
+	}
+
+	for _, name := range names {
+		certificate, err := c.load(name)
+		if err != nil {
+			return nil, err
+		}
+		if certificate != nil {
+			return certificate, nil
+		}
+	}
+	return nil, fmt.Errorf("no certificate for %s", hello.ServerName)
+}
+
+// load returns nil if there is no certificate for the name
+func (c *Certificates) load(name string) (*tls.Certificate, error) {
+	certFile := filepath.Join(c.dir, name+".crt")
+	keyFile := filepath.Join(c.dir, name+".key")
+
+	certInfo, err := os.Stat(certFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	keyInfo, err := os.Stat(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	modified := certInfo.ModTime()
+	if keyInfo.ModTime().After(modified) {
+		modified = keyInfo.ModTime()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if loaded, ok := c.loaded[name]; ok && loaded.modified.Equal(modified) {
+		return loaded.certificate, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate of %s: %v", name, err)
+	}
+	c.loaded[name] = &loadedCertificate{certificate: &certificate, modified: modified}
+	return &certificate, nil
+}
@@ -0,0 +1,268 @@
+// Package endpoint_domains exposes endpoints on custom hostnames and path prefixes, a tenant can only
+// claim hostnames allowed by ENDPOINT_CUSTOM_DOMAINS so names are limited to those the operator points
+// at the daemon
+package endpoint_domains
+
+import (
+	"errors"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+)
+
+var (
+	ErrDisabled           = errors.New("custom endpoint domains are disabled, ENDPOINT_CUSTOM_DOMAINS is not set")
+	ErrHostnameNotAllowed = errors.New("hostname is not allowed by ENDPOINT_CUSTOM_DOMAINS")
+	ErrInvalidPathPrefix  = errors.New("path prefix must look like /hooks/github")
+	ErrDomainTaken        = errors.New("hostname and path prefix are already used by another endpoint")
+	ErrHostnameOwned      = errors.New("hostname is already claimed by another tenant")
+	ErrDomainNotFound     = errors.New("endpoint domain not found")
+
+	pathPrefixPattern = regexp.MustCompile(`^(/[A-Za-z0-9._~-]+)*$`)
+
+	patterns []string
+)
+
+// hostnameDomains is what is cached for a hostname, most hostnames have a single domain
+type hostnameDomains struct {
+	Domains []models.EndpointDomain
+}
+
+// Init sets the hostnames custom domains may use, `*.hooks.example.com` allows any name below it
+func Init(allowed []string) {
+	patterns = []string{}
+	for _, pattern := range allowed {
+		pattern = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(pattern)), ".")
+		if pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+}
+
+func Enabled() bool {
+	return len(patterns) > 0
+}
+
+// Allowed reports whether a normalized hostname matches one of the patterns
+func Allowed(hostname string) bool {
+	if hostname == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+			if strings.HasSuffix(hostname, suffix) && len(hostname) > len(suffix) {
+				return true
+			}
+		} else if hostname == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeHostname removes the port and the trailing dot of a host header
+func NormalizeHostname(host string) string {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// NormalizePathPrefix returns the prefix without trailing slash, an empty prefix matches any path
+func NormalizePathPrefix(prefix string) (string, error) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	if !pathPrefixPattern.MatchString(prefix) || strings.Contains(prefix, "/../") ||
+		strings.HasSuffix(prefix, "/..") || strings.HasSuffix(prefix, "/.") {
+		return "", ErrInvalidPathPrefix
+	}
+	return prefix, nil
+}
+
+// match returns the domain with the longest prefix the path starts with and the rest of the path
+func match(domains []models.EndpointDomain, path string) (*models.EndpointDomain, string) {
+	var matched *models.EndpointDomain
+	for i := range domains {
+		prefix := domains[i].PathPrefix
+		if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+			continue
+		}
+		if matched == nil || len(prefix) > len(matched.PathPrefix) {
+			matched = &domains[i]
+		}
+	}
+	if matched == nil {
+		return nil, ""
+	}
+
+	rest := strings.TrimPrefix(path, matched.PathPrefix)
+	if rest == "" {
+		rest = "/"
+	}
+	return matched, rest
+}
+
+func cacheKey(hostname string) string {
+	return strings.Join([]string{"endpoint_domains", "hostname", hostname}, ":")
+}
+
+// Resolve returns the domain a request is routed to and the path of the endpoint,
+// nil if the host is not a custom domain
+func Resolve(host string, path string) (*models.EndpointDomain, string, error) {
+	hostname := NormalizeHostname(host)
+	if !Allowed(hostname) {
+		return nil, "", nil
+	}
+
+	cached, err := cache.AutoGetWithGetter(cacheKey(hostname), func() (*hostnameDomains, error) {
+		domains, err := db.GetAll[models.EndpointDomain](db.Equal("hostname", hostname))
+		if err != nil {
+			return nil, err
+		}
+		return &hostnameDomains{Domains: domains}, nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	domain, rest := match(cached.Domains, path)
+	return domain, rest, nil
+}
+
+// Set exposes an endpoint on a hostname and path prefix
+func Set(endpoint *models.Endpoint, hostname string, pathPrefix string) (*models.EndpointDomain, error) {
+	if !Enabled() {
+		return nil, ErrDisabled
+	}
+	hostname = NormalizeHostname(hostname)
+	if !Allowed(hostname) {
+		return nil, ErrHostnameNotAllowed
+	}
+	pathPrefix, err := NormalizePathPrefix(pathPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	// a hostname belongs to the tenant which claimed it first, another tenant could otherwise take
+	// a longer prefix of it and receive the traffic of the owner
+	if owned, err := ownedByOtherTenant(hostname, endpoint.TenantID); err != nil {
+		return nil, err
+	} else if owned {
+		return nil, ErrHostnameOwned
+	}
+
+	existing, err := db.GetOne[models.EndpointDomain](
+		db.Equal("hostname", hostname),
+		db.Equal("path_prefix", pathPrefix),
+	)
+	if err == nil {
+		if existing.EndpointID == endpoint.ID {
+			return &existing, nil
+		}
+		return nil, ErrDomainTaken
+	}
+	if err != db.ErrDatabaseNotFound {
+		return nil, err
+	}
+
+	domain := models.EndpointDomain{
+		TenantID:   endpoint.TenantID,
+		EndpointID: endpoint.ID,
+		HookID:     endpoint.HookID,
+		Hostname:   hostname,
+		PathPrefix: pathPrefix,
+	}
+	if err := db.Create(&domain); err != nil {
+		// the unique index rejects a concurrent claim of the same hostname and path prefix
+		if _, getErr := db.GetOne[models.EndpointDomain](
+			db.Equal("hostname", hostname),
+			db.Equal("path_prefix", pathPrefix),
+		); getErr == nil {
+			return nil, ErrDomainTaken
+		}
+		return nil, err
+	}
+
+	// two tenants claiming a new hostname at once both pass the check above, both claims are
+	// withdrawn then rather than routing the hostname to two tenants
+	if owned, err := ownedByOtherTenant(hostname, endpoint.TenantID); err != nil || owned {
+		if deleteErr := db.Delete(&domain); deleteErr != nil {
+			return nil, deleteErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrHostnameOwned
+	}
+
+	_, _ = cache.AutoDelete[hostnameDomains](cacheKey(hostname))
+	return &domain, nil
+}
+
+// ownedByOtherTenant reports whether a domain of another tenant uses the hostname
+func ownedByOtherTenant(hostname string, tenantID string) (bool, error) {
+	_, err := db.GetOne[models.EndpointDomain](
+		db.Equal("hostname", hostname),
+		db.NotEqual("tenant_id", tenantID),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func Remove(tenantID string, domainID string) error {
+	domain, err := db.GetOne[models.EndpointDomain](
+		db.Equal("id", domainID),
+		db.Equal("tenant_id", tenantID),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return ErrDomainNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := db.Delete(&domain); err != nil {
+		return err
+	}
+	_, _ = cache.AutoDelete[hostnameDomains](cacheKey(domain.Hostname))
+	return nil
+}
+
+// RemoveEndpoint removes the domains of an endpoint which is uninstalled
+func RemoveEndpoint(endpointID string) error {
+	return removeAll(db.Equal("endpoint_id", endpointID))
+}
+
+func RemoveTenant(tenantID string) error {
+	return removeAll(db.Equal("tenant_id", tenantID))
+}
+
+func removeAll(query ...db.GenericQuery) error {
+	domains, err := db.GetAll[models.EndpointDomain](query...)
+	if err != nil {
+		return err
+	}
+	for i := range domains {
+		if err := db.Delete(&domains[i]); err != nil {
+			return err
+		}
+		_, _ = cache.AutoDelete[hostnameDomains](cacheKey(domains[i].Hostname))
+	}
+	return nil
+}
+
+func List(tenantID string, endpointID string) ([]models.EndpointDomain, error) {
+	return db.GetAll[models.EndpointDomain](
+		db.Equal("tenant_id", tenantID),
+		db.Equal("endpoint_id", endpointID),
+		db.OrderBy("hostname", false),
+	)
+}
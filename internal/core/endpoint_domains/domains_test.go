@@ -0,0 +1,124 @@
+package endpoint_domains
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+)
+
+func TestAllowed(t *testing.T) {
+	Init([]string{"hooks.example.com", "*.tenants.example.com."})
+	t.Cleanup(func() { Init(nil) })
+
+	cases := map[string]bool{
+		"hooks.example.com":        true,
+		"a.hooks.example.com":      false,
+		"acme.tenants.example.com": true,
+		"tenants.example.com":      false,
+		"evil-tenants.example.com": false,
+		"example.com":              false,
+	}
+	for hostname, allowed := range cases {
+		if Allowed(hostname) != allowed {
+			t.Errorf("%s: expected allowed %v", hostname, allowed)
+		}
+	}
+
+	if NormalizeHostname("Hooks.Example.com.:8443") != "hooks.example.com" {
+		t.Errorf("unexpected hostname %s", NormalizeHostname("Hooks.Example.com.:8443"))
+	}
+}
+
+func TestNormalizePathPrefix(t *testing.T) {
+	valid := map[string]string{"": "", "/": "", "github": "/github", "/hooks/github/": "/hooks/github"}
+	for prefix, expected := range valid {
+		if normalized, err := NormalizePathPrefix(prefix); err != nil || normalized != expected {
+			t.Errorf("%q: expected %q, got %q %v", prefix, expected, normalized, err)
+		}
+	}
+	for _, prefix := range []string{"/hooks/../admin", "/a b", "/hooks?x=1", "//hooks"} {
+		if _, err := NormalizePathPrefix(prefix); err == nil {
+			t.Errorf("%q: expected an error", prefix)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	if err := cache.InitMemoryClient(0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cache.Close() })
+
+	config := &app.Config{DBType: "sqlite", DBSqlitePath: filepath.Join(t.TempDir(), "domains.db")}
+	config.SetDefault()
+	db.Init(config)
+	t.Cleanup(db.Close)
+
+	Init([]string{"*.hooks.example.com"})
+	t.Cleanup(func() { Init(nil) })
+
+	github := &models.Endpoint{Model: models.Model{ID: "e1"}, TenantID: "tenant", HookID: "hook1"}
+	fallback := &models.Endpoint{Model: models.Model{ID: "e2"}, TenantID: "tenant", HookID: "hook2"}
+	if _, err := Set(github, "acme.hooks.example.com", "/github"); err != nil {
+		t.Fatal(err)
+	}
+	// resolved before the second domain exists to check the cache is invalidated
+	if domain, _, _ := Resolve("acme.hooks.example.com", "/other"); domain != nil {
+		t.Fatal("expected no domain for a path outside of the prefix")
+	}
+	if _, err := Set(fallback, "acme.hooks.example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		host string
+		path string
+		hook string
+		rest string
+	}{
+		{"acme.hooks.example.com", "/github/push", "hook1", "/push"},
+		{"ACME.hooks.example.com:443", "/github", "hook1", "/"},
+		{"acme.hooks.example.com", "/githubx", "hook2", "/githubx"},
+		{"acme.hooks.example.com", "/other", "hook2", "/other"},
+		{"daemon.internal", "/e/hook1/push", "", ""},
+	}
+	for _, c := range cases {
+		domain, rest, err := Resolve(c.host, c.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hook := ""
+		if domain != nil {
+			hook = domain.HookID
+		}
+		if hook != c.hook || rest != c.rest {
+			t.Errorf("%s%s: expected %s %s, got %s %s", c.host, c.path, c.hook, c.rest, hook, rest)
+		}
+	}
+
+	if _, err := Set(fallback, "acme.hooks.example.com", "/github"); err != ErrDomainTaken {
+		t.Fatalf("expected the prefix to be taken, got %v", err)
+	}
+	// another tenant can't take a longer prefix of a hostname claimed by the tenant
+	intruder := &models.Endpoint{Model: models.Model{ID: "e3"}, TenantID: "intruder", HookID: "hook3"}
+	if _, err := Set(intruder, "acme.hooks.example.com", "/github/push"); err != ErrHostnameOwned {
+		t.Fatalf("expected the hostname to be owned, got %v", err)
+	}
+	if domain, _, _ := Resolve("acme.hooks.example.com", "/github/push/x"); domain == nil || domain.HookID != "hook1" {
+		t.Fatalf("expected the owner to keep the traffic, got %+v", domain)
+	}
+	if _, err := Set(fallback, "hooks.example.org", ""); err != ErrHostnameNotAllowed {
+		t.Fatalf("expected the hostname to be rejected, got %v", err)
+	}
+
+	if err := RemoveEndpoint(github.ID); err != nil {
+		t.Fatal(err)
+	}
+	if domain, _, _ := Resolve("acme.hooks.example.com", "/github/push"); domain == nil || domain.HookID != "hook2" {
+		t.Fatalf("expected the removed domain to fall back, got %+v", domain)
+	}
+}
@@ -0,0 +1,98 @@
+// Package event_bus publishes lifecycle events of the daemon to external systems, events are
+// delivered in background at most once and dropped if a publisher fails
+package event_bus
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+const (
+	EVENT_PLUGIN_INSTALLED   = "plugin.installed"
+	EVENT_PLUGIN_UPGRADED    = "plugin.upgraded"
+	EVENT_PLUGIN_UNINSTALLED = "plugin.uninstalled"
+	EVENT_RUNTIME_CRASHED    = "runtime.crashed"
+	EVENT_RUNTIME_RESTARTED  = "runtime.restarted"
+	EVENT_ENDPOINT_ERRORED   = "endpoint.errored"
+)
+
+type Event struct {
+	ID                     string         `json:"id"`
+	Type                   string         `json:"type"`
+	Timestamp              time.Time      `json:"timestamp"`
+	TenantID               string         `json:"tenant_id,omitempty"`
+	PluginUniqueIdentifier string         `json:"plugin_unique_identifier,omitempty"`
+	Data                   map[string]any `json:"data,omitempty"`
+}
+
+// Publisher delivers events to one external system
+type Publisher interface {
+	Name() string
+	Publish(event Event) error
+}
+
+var (
+	mu         sync.RWMutex
+	publishers []Publisher
+	// types are the event types published, empty publishes all of them
+	types []string
+)
+
+// Start publishes the events of the given types to publishers from now on, all types if none given
+func Start(eventTypes []string, p ...Publisher) {
+	mu.Lock()
+	defer mu.Unlock()
+	publishers = p
+	types = eventTypes
+}
+
+// Stop drops events from now on
+func Stop() {
+	mu.Lock()
+	defer mu.Unlock()
+	publishers = nil
+	types = nil
+}
+
+func subscribers(eventType string) []Publisher {
+	mu.RLock()
+	defer mu.RUnlock()
+	if len(types) > 0 && !slices.Contains(types, eventType) {
+		return nil
+	}
+	return publishers
+}
+
+// Publish sends an event of eventType to every publisher in background, it never blocks the caller
+func Publish(eventType string, tenantId string, pluginUniqueIdentifier string, data map[string]any) {
+	targets := subscribers(eventType)
+	if len(targets) == 0 {
+		return
+	}
+
+	event := Event{
+		ID:                     uuid.New().String(),
+		Type:                   eventType,
+		Timestamp:              time.Now(),
+		TenantID:               tenantId,
+		PluginUniqueIdentifier: pluginUniqueIdentifier,
+		Data:                   data,
+	}
+
+	for _, publisher := range targets {
+		routine.Submit(map[string]string{
+			"module":    "event_bus",
+			"function":  "Publish",
+			"publisher": publisher.Name(),
+		}, func() {
+			if err := publisher.Publish(event); err != nil {
+				log.Error("failed to publish %s event %s to %s: %s", event.Type, event.ID, publisher.Name(), err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package event_bus
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+type recordingPublisher struct {
+	events chan Event
+}
+
+func (r *recordingPublisher) Name() string {
+	return "recording"
+}
+
+func (r *recordingPublisher) Publish(event Event) error {
+	r.events <- event
+	return nil
+}
+
+func TestPublishFiltersEventTypes(t *testing.T) {
+	routine.InitPool(16)
+	publisher := &recordingPublisher{events: make(chan Event, 4)}
+	Start([]string{EVENT_RUNTIME_CRASHED}, publisher)
+	t.Cleanup(Stop)
+
+	Publish(EVENT_PLUGIN_INSTALLED, "tenant", "author/plugin:0.0.1@abc", nil)
+	Publish(EVENT_RUNTIME_CRASHED, "", "author/plugin:0.0.1@abc", map[string]any{"restarts": 1})
+
+	select {
+	case event := <-publisher.events:
+		if event.Type != EVENT_RUNTIME_CRASHED || event.ID == "" || event.Data["restarts"] != 1 {
+			t.Fatalf("unexpected event %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event was not published")
+	}
+
+	select {
+	case event := <-publisher.events:
+		t.Fatalf("filtered event %s was published", event.Type)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWebhookAndKafkaRestPublishers(t *testing.T) {
+	requests := make(chan *http.Request, 2)
+	bodies := make(chan []byte, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+	}))
+	defer server.Close()
+
+	event := Event{ID: "id", Type: EVENT_ENDPOINT_ERRORED, TenantID: "tenant"}
+
+	if err := NewWebhookPublisher(server.URL + "/hook").Publish(event); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-requests; r.URL.Path != "/hook" {
+		t.Fatalf("unexpected path %s", r.URL.Path)
+	}
+	received, err := parser.UnmarshalJsonBytes[Event](<-bodies)
+	if err != nil || received.Type != EVENT_ENDPOINT_ERRORED || received.TenantID != "tenant" {
+		t.Fatalf("unexpected webhook body %+v, %v", received, err)
+	}
+
+	if err := NewKafkaRestPublisher(server.URL+"/", "events").Publish(event); err != nil {
+		t.Fatal(err)
+	}
+	r := <-requests
+	if r.URL.Path != "/topics/events" || r.Header.Get("Content-Type") != "application/vnd.kafka.json.v2+json" {
+		t.Fatalf("unexpected kafka request %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+	}
+	records, err := parser.UnmarshalJsonBytes[struct {
+		Records []struct {
+			Key   string `json:"key"`
+			Value Event  `json:"value"`
+		} `json:"records"`
+	}](<-bodies)
+	if err != nil || len(records.Records) != 1 || records.Records[0].Key != "id" {
+		t.Fatalf("unexpected kafka body %+v, %v", records, err)
+	}
+}
+
+func TestNatsPublisher(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	connects := make(chan string, 1)
+	payloads := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		conn.Write([]byte("INFO {\"server_id\":\"test\",\"max_payload\":1048576}\r\n"))
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			switch {
+			case strings.HasPrefix(line, "CONNECT "):
+				connects <- line
+			case line == "PING":
+				conn.Write([]byte("PONG\r\n"))
+			case strings.HasPrefix(line, "PUB "):
+				fields := strings.Fields(line)
+				size, _ := strconv.Atoi(fields[2])
+				payload := make([]byte, size+2)
+				io.ReadFull(reader, payload)
+				payloads <- fields[1] + " " + string(payload[:size])
+			}
+		}
+	}()
+
+	publisher, err := NewNatsPublisher("nats://user:secret@"+listener.Addr().String(), "daemon.events", NatsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := publisher.Publish(Event{ID: strconv.Itoa(i), Type: EVENT_PLUGIN_INSTALLED}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if connect := <-connects; !strings.Contains(connect, `"user":"user"`) || !strings.Contains(connect, `"pass":"secret"`) {
+		t.Fatalf("unexpected connect %s", connect)
+	}
+	for i := 0; i < 2; i++ {
+		subject, payload, _ := strings.Cut(<-payloads, " ")
+		event, err := parser.UnmarshalJson[Event](payload)
+		if subject != "daemon.events" || err != nil || event.ID != strconv.Itoa(i) {
+			t.Fatalf("unexpected message %s %s", subject, payload)
+		}
+	}
+
+	if _, err := NewNatsPublisher("http://localhost", "events", NatsOptions{}); err == nil {
+		t.Fatal("expected invalid url to be rejected")
+	}
+}
@@ -0,0 +1,114 @@
+// Package invocation_cache caches responses of backwards model invocations of plugins which opted in,
+// identical requests of a tenant are answered from the cache instead of calling the model again
+package invocation_cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+)
+
+const (
+	CACHE_KEY_PREFIX = "invocation_cache"
+)
+
+type Config struct {
+	Enabled bool
+	TTL     time.Duration
+}
+
+type PluginStats struct {
+	PluginID string  `json:"plugin_id"`
+	Hits     int64   `json:"hits"`
+	Misses   int64   `json:"misses"`
+	HitRate  float64 `json:"hit_rate"`
+}
+
+var (
+	config Config
+
+	mu    sync.Mutex
+	stats = map[string]*PluginStats{}
+)
+
+func Init(c Config) {
+	config = c
+}
+
+func Enabled() bool {
+	return config.Enabled && config.TTL > 0
+}
+
+// Key canonicalizes the payload of a request, users of a tenant share the responses so user_id is
+// left out, keys of json objects are sorted by encoding/json
+func Key(kind string, tenantID string, pluginID string, payload any) (string, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	canonical := map[string]any{}
+	if err := json.Unmarshal(raw, &canonical); err != nil {
+		return "", err
+	}
+	delete(canonical, "user_id")
+
+	raw, err = json.Marshal(canonical)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(raw)
+	return CACHE_KEY_PREFIX + ":" + kind + ":" + tenantID + ":" + pluginID + ":" + hex.EncodeToString(sum[:]), nil
+}
+
+// Get returns the cached response and counts the hit or miss of the plugin
+func Get[T any](pluginID string, key string) (*T, bool) {
+	value, err := cache.Get[T](key)
+	record(pluginID, err == nil)
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+func Store(key string, value any) error {
+	return cache.Store(key, value, config.TTL)
+}
+
+func record(pluginID string, hit bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	s, ok := stats[pluginID]
+	if !ok {
+		s = &PluginStats{PluginID: pluginID}
+		stats[pluginID] = s
+	}
+	if hit {
+		s.Hits++
+	} else {
+		s.Misses++
+	}
+	s.HitRate = float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// FetchStats returns hits and misses of every plugin since the node started
+func FetchStats() []PluginStats {
+	mu.Lock()
+	defer mu.Unlock()
+
+	result := make([]PluginStats, 0, len(stats))
+	for _, s := range stats {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].PluginID < result[j].PluginID
+	})
+	return result
+}
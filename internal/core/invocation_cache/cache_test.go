@@ -0,0 +1,65 @@
+package invocation_cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+)
+
+func TestKeyIgnoresUser(t *testing.T) {
+	a, err := Key("llm", "tenant", "plugin", map[string]any{"user_id": "a", "texts": []string{"x"}, "model": "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Key("llm", "tenant", "plugin", map[string]any{"model": "m", "texts": []string{"x"}, "user_id": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Fatal("expected requests of different users to share the key")
+	}
+
+	for _, other := range []struct {
+		action   string
+		tenantID string
+		text     string
+	}{
+		{"llm", "other", "x"},
+		{"llm", "tenant", "y"},
+		{"text_embedding", "tenant", "x"},
+	} {
+		key, err := Key(other.action, other.tenantID, "plugin", map[string]any{"texts": []string{other.text}, "model": "m"})
+		if err != nil || key == a {
+			t.Fatalf("expected a distinct key, got %s %v", key, err)
+		}
+	}
+}
+
+func TestGetRecordsHits(t *testing.T) {
+	if err := cache.InitMemoryClient(0); err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	Init(Config{Enabled: true, TTL: time.Minute})
+	if !Enabled() {
+		t.Fatal("expected the cache to be enabled")
+	}
+
+	if _, ok := Get[[]string]("plugin", "key"); ok {
+		t.Fatal("expected a miss")
+	}
+	if err := Store("key", []string{"chunk"}); err != nil {
+		t.Fatal(err)
+	}
+	value, ok := Get[[]string]("plugin", "key")
+	if !ok || len(*value) != 1 || (*value)[0] != "chunk" {
+		t.Fatalf("expected a hit, got %v", value)
+	}
+
+	stats := FetchStats()
+	if len(stats) != 1 || stats[0].Hits != 1 || stats[0].Misses != 1 || stats[0].HitRate != 0.5 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,103 @@
+// Package invocation_limit bounds the invocations a plugin runs at the same time on a node,
+// invocations beyond the limit wait in a bounded queue and are rejected once it's full
+package invocation_limit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQueueFull    = errors.New("too many invocations are waiting for the plugin")
+	ErrQueueTimeout = errors.New("timed out waiting for the plugin to finish other invocations")
+)
+
+type Config struct {
+	// MaxConcurrency caps the concurrency declared by plugins and applies to those declaring none, 0 is unlimited
+	MaxConcurrency int
+	// QueueSize is the number of invocations waiting for a plugin, 0 rejects them once the plugin is busy
+	QueueSize    int
+	QueueTimeout time.Duration
+}
+
+type Limiter struct {
+	config Config
+
+	mu      sync.Mutex
+	plugins map[string]*pluginLimit
+}
+
+type pluginLimit struct {
+	slots   chan struct{}
+	waiting int
+}
+
+func NewLimiter(config Config) *Limiter {
+	return &Limiter{config: config, plugins: map[string]*pluginLimit{}}
+}
+
+// limit merges the concurrency declared by the plugin with the maximum of the daemon
+func (l *Limiter) limit(declared int) int {
+	if declared <= 0 || (l.config.MaxConcurrency > 0 && declared > l.config.MaxConcurrency) {
+		return l.config.MaxConcurrency
+	}
+	return declared
+}
+
+// Acquire waits for a slot of the plugin identified by identity, declared is the concurrency its
+// manifest declares, release must be called once the invocation is done
+func (l *Limiter) Acquire(ctx context.Context, identity string, declared int) (func(), error) {
+	limit := l.limit(declared)
+	if limit <= 0 {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	plugin, ok := l.plugins[identity]
+	if !ok || cap(plugin.slots) != limit {
+		// a changed limit applies to new invocations, running ones release the slots they took
+		plugin = &pluginLimit{slots: make(chan struct{}, limit)}
+		l.plugins[identity] = plugin
+	}
+
+	select {
+	case plugin.slots <- struct{}{}:
+		l.mu.Unlock()
+		return release(plugin), nil
+	default:
+	}
+
+	if plugin.waiting >= l.config.QueueSize {
+		l.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+	plugin.waiting++
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		plugin.waiting--
+		l.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(l.config.QueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case plugin.slots <- struct{}{}:
+		return release(plugin), nil
+	case <-timer.C:
+		return nil, ErrQueueTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func release(plugin *pluginLimit) func() {
+	once := sync.Once{}
+	return func() {
+		once.Do(func() { <-plugin.slots })
+	}
+}
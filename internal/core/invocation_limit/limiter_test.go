@@ -0,0 +1,94 @@
+package invocation_limit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimit(t *testing.T) {
+	limiter := NewLimiter(Config{MaxConcurrency: 8})
+	for declared, expected := range map[int]int{0: 8, 4: 4, 16: 8} {
+		if limit := limiter.limit(declared); limit != expected {
+			t.Fatalf("expected limit %d for declared %d, got %d", expected, declared, limit)
+		}
+	}
+
+	unlimited := NewLimiter(Config{})
+	if limit := unlimited.limit(4); limit != 4 {
+		t.Fatalf("expected the declared limit without a maximum, got %d", limit)
+	}
+	release, err := unlimited.Acquire(context.Background(), "plugin", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
+
+func TestAcquireQueues(t *testing.T) {
+	limiter := NewLimiter(Config{QueueSize: 1, QueueTimeout: time.Second})
+
+	release, err := limiter.Acquire(context.Background(), "plugin", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// other plugins are not affected
+	other, err := limiter.Acquire(context.Background(), "other", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other()
+
+	acquired := make(chan error)
+	go func() {
+		release, err := limiter.Acquire(context.Background(), "plugin", 1)
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+
+	// wait for the invocation above to be queued
+	for {
+		limiter.mu.Lock()
+		waiting := limiter.plugins["plugin"].waiting
+		limiter.mu.Unlock()
+		if waiting == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := limiter.Acquire(context.Background(), "plugin", 1); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected the queue to be full, got %v", err)
+	}
+
+	release()
+	// releasing twice must not free a slot of another invocation
+	release()
+	if err := <-acquired; err != nil {
+		t.Fatalf("expected the queued invocation to run, got %v", err)
+	}
+}
+
+func TestAcquireTimesOut(t *testing.T) {
+	limiter := NewLimiter(Config{QueueSize: 1, QueueTimeout: 10 * time.Millisecond})
+
+	release, err := limiter.Acquire(context.Background(), "plugin", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	if _, err := limiter.Acquire(context.Background(), "plugin", 1); !errors.Is(err, ErrQueueTimeout) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := limiter.Acquire(ctx, "plugin", 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancellation of the caller, got %v", err)
+	}
+}
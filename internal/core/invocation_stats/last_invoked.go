@@ -0,0 +1,85 @@
+package invocation_stats
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/mapping"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+const (
+	LAST_INVOKED_KEY_PREFIX = "plugin_last_invoked"
+	// LAST_INVOKED_RESOLUTION limits how often a node refreshes the last invocation of a plugin
+	LAST_INVOKED_RESOLUTION = time.Minute
+	// LAST_INVOKED_TTL is refreshed on every write, tenants idle for longer are forgotten
+	LAST_INVOKED_TTL = 30 * 24 * time.Hour
+)
+
+var lastInvoked mapping.Map[string, time.Time]
+
+func lastInvokedKey(tenantID string) string {
+	return LAST_INVOKED_KEY_PREFIX + ":" + tenantID
+}
+
+// RecordTenantInvocation keeps the last time a tenant invoked a plugin, unlike the rates it's
+// stored in redis and shared by all nodes
+func RecordTenantInvocation(tenantID string, pluginID string) {
+	if tenantID == "" {
+		return
+	}
+
+	t := now()
+	key := tenantID + ":" + pluginID
+	if last, ok := lastInvoked.Load(key); ok && t.Sub(last) < LAST_INVOKED_RESOLUTION {
+		return
+	}
+	lastInvoked.Store(key, t)
+
+	routine.Submit(map[string]string{
+		"module":   "invocation_stats",
+		"function": "RecordTenantInvocation",
+	}, func() {
+		cacheKey := lastInvokedKey(tenantID)
+		if err := cache.SetMapOneField(cacheKey, pluginID, strconv.FormatInt(t.Unix(), 10)); err != nil {
+			log.Error("failed to record last invocation of plugin %s: %s", pluginID, err.Error())
+			return
+		}
+		cache.SetExpire(cacheKey, LAST_INVOKED_TTL)
+	})
+}
+
+// LastInvokedAt returns nil if the tenant has not invoked the plugin within LAST_INVOKED_TTL
+func LastInvokedAt(tenantID string, pluginID string) (*time.Time, error) {
+	value, err := cache.GetMapFieldString(lastInvokedKey(tenantID), pluginID)
+	if err == cache.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	t := time.Unix(seconds, 0)
+	return &t, nil
+}
+
+// TenantLastInvokedAt returns the last invocations of the plugins invoked by the tenant by plugin id
+func TenantLastInvokedAt(tenantID string) (map[string]time.Time, error) {
+	values, err := cache.GetMap[int64](lastInvokedKey(tenantID))
+	if err == cache.ErrNotFound {
+		return map[string]time.Time{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	lastInvokedAt := make(map[string]time.Time, len(values))
+	for pluginID, seconds := range values {
+		lastInvokedAt[pluginID] = time.Unix(seconds, 0)
+	}
+	return lastInvokedAt, nil
+}
@@ -0,0 +1,121 @@
+package invocation_stats
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/analytics"
+)
+
+const (
+	BUCKET_SIZE  = 5 * time.Second
+	BUCKET_COUNT = 12
+
+	// WINDOW is the period rates are computed over
+	WINDOW = BUCKET_SIZE * BUCKET_COUNT
+)
+
+type bucket struct {
+	start       int64
+	invocations int64
+	errors      int64
+}
+
+type counter struct {
+	buckets [BUCKET_COUNT]bucket
+	total   int64
+	errors  int64
+}
+
+type PluginRate struct {
+	PluginID string `json:"plugin_id"`
+	// counts within the last WINDOW
+	Invocations int64   `json:"invocations"`
+	Errors      int64   `json:"errors"`
+	ErrorRate   float64 `json:"error_rate"`
+	// counts since the node started
+	TotalInvocations int64 `json:"total_invocations"`
+	TotalErrors      int64 `json:"total_errors"`
+}
+
+var (
+	mu       sync.Mutex
+	counters = map[string]*counter{}
+
+	now = time.Now
+)
+
+func (c *counter) current(t time.Time) *bucket {
+	start := t.Truncate(BUCKET_SIZE).Unix()
+	b := &c.buckets[(start/int64(BUCKET_SIZE.Seconds()))%BUCKET_COUNT]
+	if b.start != start {
+		*b = bucket{start: start}
+	}
+	return b
+}
+
+func record(pluginID string, failed bool) {
+	if analytics.Enabled() {
+		analytics.Record(pluginID, failed)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	c, ok := counters[pluginID]
+	if !ok {
+		c = &counter{}
+		counters[pluginID] = c
+	}
+
+	b := c.current(now())
+	if failed {
+		b.errors++
+		c.errors++
+	} else {
+		b.invocations++
+		c.total++
+	}
+}
+
+// RecordInvocation counts a request dispatched to a plugin
+func RecordInvocation(pluginID string) {
+	record(pluginID, false)
+}
+
+// RecordError counts an invocation which ended with an error
+func RecordError(pluginID string) {
+	record(pluginID, true)
+}
+
+// FetchRates returns the invocation and error counts of every plugin seen by current node
+func FetchRates() []PluginRate {
+	mu.Lock()
+	defer mu.Unlock()
+
+	oldest := now().Add(-WINDOW).Truncate(BUCKET_SIZE).Unix()
+	rates := make([]PluginRate, 0, len(counters))
+	for pluginID, c := range counters {
+		rate := PluginRate{
+			PluginID:         pluginID,
+			TotalInvocations: c.total,
+			TotalErrors:      c.errors,
+		}
+		for _, b := range c.buckets {
+			if b.start > oldest {
+				rate.Invocations += b.invocations
+				rate.Errors += b.errors
+			}
+		}
+		if rate.Invocations > 0 {
+			rate.ErrorRate = float64(rate.Errors) / float64(rate.Invocations)
+		}
+		rates = append(rates, rate)
+	}
+
+	sort.Slice(rates, func(i, j int) bool {
+		return rates[i].PluginID < rates[j].PluginID
+	})
+	return rates
+}
@@ -0,0 +1,41 @@
+package invocation_stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRatesWithinWindow(t *testing.T) {
+	current := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	t.Cleanup(func() {
+		now = time.Now
+		counters = map[string]*counter{}
+	})
+
+	for i := 0; i < 4; i++ {
+		RecordInvocation("langgenius/openai")
+	}
+	RecordError("langgenius/openai")
+	RecordInvocation("langgenius/anthropic")
+
+	rates := FetchRates()
+	assert.Len(t, rates, 2)
+	assert.Equal(t, "langgenius/anthropic", rates[0].PluginID)
+	assert.Equal(t, int64(4), rates[1].Invocations)
+	assert.Equal(t, int64(1), rates[1].Errors)
+	assert.Equal(t, 0.25, rates[1].ErrorRate)
+
+	// old buckets fall out of the window but totals are kept
+	current = current.Add(WINDOW + BUCKET_SIZE)
+	RecordInvocation("langgenius/openai")
+
+	rates = FetchRates()
+	assert.Equal(t, int64(1), rates[1].Invocations)
+	assert.Equal(t, int64(0), rates[1].Errors)
+	assert.Equal(t, int64(5), rates[1].TotalInvocations)
+	assert.Equal(t, int64(1), rates[1].TotalErrors)
+	assert.Equal(t, int64(0), rates[0].Invocations)
+}
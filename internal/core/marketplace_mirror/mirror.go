@@ -0,0 +1,206 @@
+// Package marketplace_mirror keeps packages downloaded from the upstream marketplace in the storage and
+// serves them to other daemons, packages already mirrored stay available while the upstream is unreachable
+package marketplace_mirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/oss"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/downloader"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/lock"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+var (
+	ErrNotMirrored         = errors.New("package is not mirrored")
+	ErrUpstreamUnavailable = errors.New("upstream marketplace is unavailable")
+)
+
+type Config struct {
+	// UpstreamURL is the marketplace packages are fetched from
+	UpstreamURL string
+	// APIKey is sent to the upstream, the mirror of another daemon requires one
+	APIKey string
+	// Path is the prefix of mirrored packages in the storage
+	Path string
+	// DownloadPath is a local directory for packages being fetched
+	DownloadPath string
+	// Offline serves mirrored packages only, the upstream is never contacted
+	Offline bool
+
+	Concurrency int
+	ChunkSize   int64
+	// MaxSize rejects larger packages, 0 means unlimited
+	MaxSize int64
+}
+
+type Mirror struct {
+	storage oss.StreamingOSS
+	config  Config
+	fetches *lock.GranularityLock
+}
+
+var mirror *Mirror
+
+func Init(storage oss.StreamingOSS, config Config) {
+	mirror = NewMirror(storage, config)
+}
+
+func Enabled() bool {
+	return mirror != nil
+}
+
+func GetMirror() *Mirror {
+	return mirror
+}
+
+func NewMirror(storage oss.StreamingOSS, config Config) *Mirror {
+	config.UpstreamURL = strings.TrimSuffix(config.UpstreamURL, "/")
+	return &Mirror{storage: storage, config: config, fetches: lock.NewGranularityLock()}
+}
+
+// MarketplaceHeader carries the api key downloads from a marketplace mirror are authenticated with
+func MarketplaceHeader(apiKey string) http.Header {
+	header := http.Header{}
+	if apiKey != "" {
+		header.Set("Authorization", "Bearer "+apiKey)
+	}
+	return header
+}
+
+func (m *Mirror) key(pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier) string {
+	return path.Join(m.config.Path, pluginUniqueIdentifier.String())
+}
+
+// Ensure makes sure the package is mirrored, it's fetched from the upstream if not, checksum is the
+// expected sha256 of a fetched package and may be empty
+func (m *Mirror) Ensure(
+	ctx context.Context,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	checksum string,
+) error {
+	key := m.key(pluginUniqueIdentifier)
+	if exists, err := m.storage.Exists(key); err != nil {
+		return err
+	} else if exists {
+		return nil
+	}
+
+	if m.config.Offline {
+		return ErrNotMirrored
+	}
+
+	// concurrent requests of the same package wait for a single fetch
+	m.fetches.Lock(key)
+	defer m.fetches.Unlock(key)
+
+	if exists, err := m.storage.Exists(key); err != nil {
+		return err
+	} else if exists {
+		return nil
+	}
+
+	// waiters share the fetch, it must not fail for all of them if the first caller goes away
+	return m.fetch(context.WithoutCancel(ctx), pluginUniqueIdentifier, checksum)
+}
+
+func (m *Mirror) fetch(
+	ctx context.Context,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	checksum string,
+) error {
+	localPath := filepath.Join(m.config.DownloadPath, pluginUniqueIdentifier.Checksum())
+	url := fmt.Sprintf(
+		"%s/api/v1/plugins/download?unique_identifier=%s",
+		m.config.UpstreamURL,
+		neturl.QueryEscape(pluginUniqueIdentifier.String()),
+	)
+
+	err := downloader.Download(ctx, url, localPath, downloader.Options{
+		Header:      MarketplaceHeader(m.config.APIKey),
+		Concurrency: m.config.Concurrency,
+		ChunkSize:   m.config.ChunkSize,
+		MaxSize:     m.config.MaxSize,
+		Checksum:    checksum,
+	})
+	if err != nil {
+		if errors.Is(err, downloader.ErrChecksumMismatch) || errors.Is(err, downloader.ErrSizeExceeded) {
+			return err
+		}
+		return errors.Join(ErrUpstreamUnavailable, err)
+	}
+	defer os.Remove(localPath)
+
+	// never mirror a package under an identifier it doesn't have, other daemons trust the mirror
+	pkg, err := os.ReadFile(localPath)
+	if err != nil {
+		return err
+	}
+	var pluginDecoder *decoder.ZipPluginDecoder
+	if m.config.MaxSize > 0 {
+		pluginDecoder, err = decoder.NewZipPluginDecoderWithSizeLimit(pkg, m.config.MaxSize)
+	} else {
+		pluginDecoder, err = decoder.NewZipPluginDecoder(pkg)
+	}
+	if err != nil {
+		return err
+	}
+	identity, err := pluginDecoder.UniqueIdentity()
+	if err != nil {
+		return err
+	}
+	if identity != pluginUniqueIdentifier {
+		return fmt.Errorf("upstream returned %s for %s", identity, pluginUniqueIdentifier)
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return m.storage.SaveStream(m.key(pluginUniqueIdentifier), file)
+}
+
+// Open returns the mirrored package and its size, caller must close the reader
+func (m *Mirror) Open(
+	ctx context.Context,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+) (io.ReadCloser, int64, error) {
+	if err := m.Ensure(ctx, pluginUniqueIdentifier, ""); err != nil {
+		return nil, 0, err
+	}
+
+	key := m.key(pluginUniqueIdentifier)
+	state, err := m.storage.State(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	reader, err := m.storage.LoadStream(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	return reader, state.Size, nil
+}
+
+// Load returns the content of the package, fetching it from the upstream if it's not mirrored yet
+func (m *Mirror) Load(
+	ctx context.Context,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	checksum string,
+) ([]byte, error) {
+	if err := m.Ensure(ctx, pluginUniqueIdentifier, checksum); err != nil {
+		return nil, err
+	}
+	return m.storage.Load(m.key(pluginUniqueIdentifier))
+}
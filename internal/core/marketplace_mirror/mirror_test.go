@@ -0,0 +1,130 @@
+package marketplace_mirror
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+	"github.com/langgenius/dify-plugin-daemon/internal/oss"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirror(t *testing.T) {
+	pkg, err := os.ReadFile("testdata/dummy_plugin.difypkg")
+	require.NoError(t, err)
+	pluginDecoder, err := decoder.NewZipPluginDecoder(pkg)
+	require.NoError(t, err)
+	identifier, err := pluginDecoder.UniqueIdentity()
+	require.NoError(t, err)
+
+	requests := atomic.Int32{}
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Query().Get("unique_identifier") != identifier.String() {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(pkg)
+	}))
+
+	storage, err := oss.NewLocalStorage(cloudoss.OSSArgs{Local: &cloudoss.Local{Path: t.TempDir()}})
+	require.NoError(t, err)
+	config := Config{
+		UpstreamURL:  upstream.URL,
+		Path:         "marketplace_mirror",
+		DownloadPath: t.TempDir(),
+		MaxSize:      50 * 1024 * 1024,
+	}
+	mirror := NewMirror(storage, config)
+
+	content, err := mirror.Load(context.Background(), identifier, "")
+	require.NoError(t, err)
+	assert.Equal(t, pkg, content)
+	fetched := requests.Load()
+
+	// mirrored packages are served without the upstream
+	upstream.Close()
+	reader, size, err := mirror.Open(context.Background(), identifier)
+	require.NoError(t, err)
+	reader.Close()
+	assert.Equal(t, int64(len(pkg)), size)
+	assert.Equal(t, fetched, requests.Load())
+
+	other, err := plugin_entities.NewPluginUniqueIdentifier("langgenius/other:0.0.1@" + identifier.Checksum())
+	require.NoError(t, err)
+	_, err = mirror.Load(context.Background(), other, "")
+	assert.True(t, errors.Is(err, ErrUpstreamUnavailable))
+
+	config.Offline = true
+	_, err = NewMirror(storage, config).Load(context.Background(), other, "")
+	assert.True(t, errors.Is(err, ErrNotMirrored))
+}
+
+func TestMirrorRejectsMismatchedPackage(t *testing.T) {
+	pkg, err := os.ReadFile("testdata/dummy_plugin.difypkg")
+	require.NoError(t, err)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pkg)
+	}))
+	defer upstream.Close()
+
+	storage, err := oss.NewLocalStorage(cloudoss.OSSArgs{Local: &cloudoss.Local{Path: t.TempDir()}})
+	require.NoError(t, err)
+	mirror := NewMirror(storage, Config{
+		UpstreamURL:  upstream.URL,
+		Path:         "marketplace_mirror",
+		DownloadPath: t.TempDir(),
+	})
+
+	other, err := plugin_entities.NewPluginUniqueIdentifier(
+		"langgenius/other:0.0.1@0000000000000000000000000000000000000000000000000000000000000000",
+	)
+	require.NoError(t, err)
+	_, err = mirror.Load(context.Background(), other, "")
+	assert.ErrorContains(t, err, "upstream returned")
+
+	exists, err := storage.Exists(mirror.key(other))
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestMirrorFetchOutlivesCaller(t *testing.T) {
+	pkg, err := os.ReadFile("testdata/dummy_plugin.difypkg")
+	require.NoError(t, err)
+	pluginDecoder, err := decoder.NewZipPluginDecoder(pkg)
+	require.NoError(t, err)
+	identifier, err := pluginDecoder.UniqueIdentity()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer mirror-key", r.Header.Get("Authorization"))
+		// the caller goes away while the package is being fetched
+		cancel()
+		w.Write(pkg)
+	}))
+	defer upstream.Close()
+
+	storage, err := oss.NewLocalStorage(cloudoss.OSSArgs{Local: &cloudoss.Local{Path: t.TempDir()}})
+	require.NoError(t, err)
+	mirror := NewMirror(storage, Config{
+		UpstreamURL:  upstream.URL,
+		APIKey:       "mirror-key",
+		Path:         "marketplace_mirror",
+		DownloadPath: t.TempDir(),
+	})
+
+	require.NoError(t, mirror.Ensure(ctx, identifier, ""))
+	exists, err := storage.Exists(mirror.key(identifier))
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
@@ -0,0 +1,87 @@
+package oauth_credentials
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/kms"
+)
+
+const (
+	AUTHORIZATION_CACHE_KEY_PREFIX = "oauth_credentials:authorization"
+	AUTHORIZATION_EXPIRE           = time.Minute * 10
+)
+
+var ErrAuthorizationNotFound = errors.New("authorization not found or expired")
+
+// Authorization is what the callback needs to finish an authorization, it's kept in the cache under
+// the state passed to the oauth provider, system credentials are sealed as they contain client secrets
+type Authorization struct {
+	TenantID          string `json:"tenant_id"`
+	UserID            string `json:"user_id"`
+	PluginID          string `json:"plugin_id"`
+	Provider          string `json:"provider"`
+	RedirectURI       string `json:"redirect_uri"`
+	SystemCredentials string `json:"system_credentials"`
+	// ReturnURL is where the browser is sent once the callback is done, the callback responds with json if empty
+	ReturnURL string `json:"return_url"`
+}
+
+func authorizationKey(state string) string {
+	return AUTHORIZATION_CACHE_KEY_PREFIX + ":" + state
+}
+
+// BeginAuthorization keeps the authorization for AUTHORIZATION_EXPIRE and returns its state
+func BeginAuthorization(authorization Authorization, systemCredentials map[string]any) (string, error) {
+	state := uuid.New().String()
+	sealed, err := seal(systemCredentials, kms.AdditionalData(authorization.TenantID, state))
+	if err != nil {
+		return "", err
+	}
+	authorization.SystemCredentials = sealed
+
+	if err := cache.Store(authorizationKey(state), authorization, AUTHORIZATION_EXPIRE); err != nil {
+		return "", err
+	}
+	return state, nil
+}
+
+// TakeAuthorization returns the authorization of state and forgets it, a state is only ever taken once
+func TakeAuthorization(state string) (*Authorization, map[string]any, error) {
+	authorization, err := cache.Get[Authorization](authorizationKey(state))
+	if err == cache.ErrNotFound {
+		return nil, nil, ErrAuthorizationNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	deleted, err := cache.Del(authorizationKey(state))
+	if err != nil && err != cache.ErrNotFound {
+		return nil, nil, err
+	}
+	if deleted == 0 {
+		return nil, nil, ErrAuthorizationNotFound
+	}
+
+	systemCredentials, err := open(authorization.SystemCredentials, kms.AdditionalData(authorization.TenantID, state))
+	if err != nil {
+		return nil, nil, err
+	}
+	return authorization, systemCredentials, nil
+}
+
+// WithState sets the state parameter of the authorization url returned by a plugin
+func WithState(authorizationURL string, state string) (string, error) {
+	parsed, err := url.Parse(authorizationURL)
+	if err != nil {
+		return "", err
+	}
+	query := parsed.Query()
+	query.Set("state", state)
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
@@ -0,0 +1,171 @@
+// Package oauth_credentials keeps the credentials tool providers of plugins got from OAuth2 authorizations
+// of tenants, they are encrypted at rest and injected into tool invocations asking for oauth2 credentials
+package oauth_credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/kms"
+	"gorm.io/gorm"
+)
+
+const (
+	// REFRESH_MARGIN refreshes access tokens a bit before they expire so they don't during an invocation
+	REFRESH_MARGIN = time.Minute
+)
+
+var (
+	ErrEncryptionKeyNotSet = errors.New("oauth credentials can not be stored, neither KMS_PROVIDER nor OAUTH_CREDENTIALS_ENCRYPTION_KEY is set")
+	ErrCredentialNotFound  = errors.New("oauth credentials not found, the provider has to be authorized first")
+
+	cipher = kms.NewCipher("")
+)
+
+// Credential is the decrypted form of models.OAuthCredential
+type Credential struct {
+	TenantID          string
+	PluginID          string
+	Provider          string
+	RedirectURI       string
+	SystemCredentials map[string]any
+	Credentials       map[string]any
+	ExpiresAt         int64
+}
+
+// NeedsRefresh reports whether the access token expires within REFRESH_MARGIN
+func (c *Credential) NeedsRefresh(now time.Time) bool {
+	return c.ExpiresAt > 0 && !now.Add(REFRESH_MARGIN).Before(time.Unix(c.ExpiresAt, 0))
+}
+
+// Init sets the key credentials are encrypted with unless a kms provider is configured
+func Init(key string) {
+	cipher = kms.NewCipher(key)
+}
+
+// seal encrypts value bound to aad, like the tenant and the row it's stored in
+func seal(value map[string]any, aad []byte) (string, error) {
+	if !cipher.Available() {
+		return "", ErrEncryptionKeyNotSet
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return cipher.Encrypt(raw, aad)
+}
+
+func open(value string, aad []byte) (map[string]any, error) {
+	if !cipher.Available() {
+		return nil, ErrEncryptionKeyNotSet
+	}
+	if value == "" {
+		return map[string]any{}, nil
+	}
+	decrypted, err := cipher.Decrypt(value, aad)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]any{}
+	if err := json.Unmarshal(decrypted, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Save replaces the credentials of the provider of a plugin for the tenant
+func Save(credential *Credential) error {
+	if !cipher.Available() {
+		return ErrEncryptionKeyNotSet
+	}
+
+	return db.WithTransaction(func(tx *gorm.DB) error {
+		record, err := db.GetOne[models.OAuthCredential](
+			db.WithTransactionContext(tx),
+			db.Equal("tenant_id", credential.TenantID),
+			db.Equal("plugin_id", credential.PluginID),
+			db.Equal("provider", credential.Provider),
+			db.WLock(),
+		)
+		created := err == db.ErrDatabaseNotFound
+		if created {
+			record = models.OAuthCredential{
+				Model:    models.Model{ID: uuid.New().String()},
+				TenantID: credential.TenantID,
+				PluginID: credential.PluginID,
+				Provider: credential.Provider,
+			}
+		} else if err != nil {
+			return err
+		}
+
+		aad := kms.AdditionalData(record.TenantID, record.ID)
+		if record.SystemCredentials, err = seal(credential.SystemCredentials, aad); err != nil {
+			return err
+		}
+		if record.Credentials, err = seal(credential.Credentials, aad); err != nil {
+			return err
+		}
+		record.RedirectURI = credential.RedirectURI
+		record.ExpiresAt = credential.ExpiresAt
+
+		if created {
+			return db.Create(&record, tx)
+		}
+		return db.Update(&record, tx)
+	})
+}
+
+func Load(tenantID string, pluginID string, provider string) (*Credential, error) {
+	record, err := db.GetOne[models.OAuthCredential](
+		db.Equal("tenant_id", tenantID),
+		db.Equal("plugin_id", pluginID),
+		db.Equal("provider", provider),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return nil, ErrCredentialNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	aad := kms.AdditionalData(record.TenantID, record.ID)
+	systemCredentials, err := open(record.SystemCredentials, aad)
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := open(record.Credentials, aad)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		TenantID:          record.TenantID,
+		PluginID:          record.PluginID,
+		Provider:          record.Provider,
+		RedirectURI:       record.RedirectURI,
+		SystemCredentials: systemCredentials,
+		Credentials:       credentials,
+		ExpiresAt:         record.ExpiresAt,
+	}, nil
+}
+
+// List returns the authorized providers of a tenant, credentials are never part of it
+func List(tenantID string) ([]models.OAuthCredential, error) {
+	return db.GetAll[models.OAuthCredential](
+		db.Equal("tenant_id", tenantID),
+		db.OrderBy("plugin_id", false),
+	)
+}
+
+func Delete(tenantID string, pluginID string, provider string) error {
+	return db.DeleteByCondition(models.OAuthCredential{
+		TenantID: tenantID,
+		PluginID: pluginID,
+		Provider: provider,
+	})
+}
@@ -0,0 +1,152 @@
+package oauth_credentials
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+)
+
+func initStore(t *testing.T) {
+	if err := cache.InitMemoryClient(0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cache.Close() })
+
+	config := &app.Config{DBType: "sqlite", DBSqlitePath: filepath.Join(t.TempDir(), "oauth.db")}
+	config.SetDefault()
+	db.Init(config)
+	t.Cleanup(db.Close)
+
+	Init("test-key")
+	t.Cleanup(func() { Init("") })
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	initStore(t)
+
+	credential := &Credential{
+		TenantID:          "tenant",
+		PluginID:          "author/plugin",
+		Provider:          "github",
+		RedirectURI:       "https://daemon/oauth/callback",
+		SystemCredentials: map[string]any{"client_secret": "s3cret"},
+		Credentials:       map[string]any{"access_token": "a1", "refresh_token": "r1"},
+		ExpiresAt:         time.Now().Add(time.Hour).Unix(),
+	}
+	if err := Save(credential); err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := db.GetOne[models.OAuthCredential](db.Equal("tenant_id", "tenant"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(record.Credentials, "r1") || strings.Contains(record.SystemCredentials, "s3cret") {
+		t.Fatal("credentials must be stored encrypted")
+	}
+
+	credential.Credentials = map[string]any{"access_token": "a2", "refresh_token": "r1"}
+	if err := Save(credential); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load("tenant", "author/plugin", "github")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Credentials["access_token"] != "a2" || loaded.SystemCredentials["client_secret"] != "s3cret" {
+		t.Fatalf("unexpected credentials %+v", loaded)
+	}
+	if loaded.NeedsRefresh(time.Now()) || !loaded.NeedsRefresh(time.Now().Add(time.Hour)) {
+		t.Fatal("expected a refresh only close to the expiration")
+	}
+
+	if records, _ := List("tenant"); len(records) != 1 {
+		t.Fatalf("expected saving again to replace the credentials, got %d records", len(records))
+	}
+
+	if err := Delete("tenant", "author/plugin", "github"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load("tenant", "author/plugin", "github"); err != ErrCredentialNotFound {
+		t.Fatalf("expected credentials to be deleted, got %v", err)
+	}
+
+	if (&Credential{ExpiresAt: -1}).NeedsRefresh(time.Now()) {
+		t.Fatal("credentials which never expire must not be refreshed")
+	}
+}
+
+func TestCredentialsCanNotBeMovedBetweenRows(t *testing.T) {
+	initStore(t)
+
+	for _, tenant := range []string{"victim", "attacker"} {
+		if err := Save(&Credential{
+			TenantID:    tenant,
+			PluginID:    "author/plugin",
+			Provider:    "github",
+			Credentials: map[string]any{"access_token": tenant},
+			ExpiresAt:   -1,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	victim, err := db.GetOne[models.OAuthCredential](db.Equal("tenant_id", "victim"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attacker, err := db.GetOne[models.OAuthCredential](db.Equal("tenant_id", "attacker"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attacker.Credentials = victim.Credentials
+	if err := db.Update(&attacker); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load("attacker", "author/plugin", "github"); err == nil {
+		t.Fatal("credentials copied from another tenant should not be decrypted")
+	}
+}
+
+func TestAuthorizationIsTakenOnce(t *testing.T) {
+	initStore(t)
+
+	state, err := BeginAuthorization(Authorization{
+		TenantID: "tenant",
+		PluginID: "author/plugin",
+		Provider: "github",
+	}, map[string]any{"client_id": "id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authorization, systemCredentials, err := TakeAuthorization(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authorization.Provider != "github" || systemCredentials["client_id"] != "id" {
+		t.Fatalf("unexpected authorization %+v %v", authorization, systemCredentials)
+	}
+
+	if _, _, err := TakeAuthorization(state); err != ErrAuthorizationNotFound {
+		t.Fatalf("expected a state to be taken only once, got %v", err)
+	}
+
+	authorizationURL, err := WithState("https://github.com/login/oauth/authorize?client_id=id&state=plugin", state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _ := url.Parse(authorizationURL)
+	if parsed.Query().Get("state") != state || parsed.Query().Get("client_id") != "id" {
+		t.Fatalf("unexpected authorization url %s", authorizationURL)
+	}
+}
@@ -0,0 +1,219 @@
+// Package output_schema checks the variables and json messages a tool emits against the
+// output_schema the tool declares
+package output_schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+type Policy string
+
+const (
+	POLICY_OFF Policy = "off"
+	// POLICY_WARN logs violations and leaves the response untouched
+	POLICY_WARN Policy = "warn"
+	// POLICY_ENFORCE fails the invocation with a schema violation error
+	POLICY_ENFORCE Policy = "enforce"
+
+	ERROR_TYPE_OUTPUT_SCHEMA_VIOLATION = "output_schema_violation"
+)
+
+var policy atomic.Value
+
+func Init(p Policy) {
+	policy.Store(p)
+}
+
+func Enabled() bool {
+	p, _ := policy.Load().(Policy)
+	return p == POLICY_WARN || p == POLICY_ENFORCE
+}
+
+type Violation struct {
+	// Source is the variable or json message the violation was found in
+	Source   string `json:"source"`
+	Location string `json:"location"`
+	Message  string `json:"message"`
+}
+
+type ViolationError struct {
+	Provider   string      `json:"provider"`
+	Tool       string      `json:"tool"`
+	Violations []Violation `json:"violations"`
+}
+
+func (e *ViolationError) Error() string {
+	return parser.MarshalJson(map[string]any{
+		"error_type": ERROR_TYPE_OUTPUT_SCHEMA_VIOLATION,
+		"message":    fmt.Sprintf("output of tool %s/%s does not match its output_schema", e.Provider, e.Tool),
+		"args":       e,
+	})
+}
+
+// findOutputSchema returns the output schema of a tool, nil if the tool declares none
+func findOutputSchema(declaration *plugin_entities.PluginDeclaration, provider string, tool string) map[string]any {
+	if declaration == nil || declaration.Tool == nil || declaration.Tool.Identity.Name != provider {
+		return nil
+	}
+	for _, t := range declaration.Tool.Tools {
+		if t.Identity.Name == tool && len(t.OutputSchema) > 0 {
+			return t.OutputSchema
+		}
+	}
+	return nil
+}
+
+// Check wraps the response of a tool invocation, json messages are checked against the schema as they
+// arrive, variables once the response ends as streamed variables are only complete by then
+func Check(
+	declaration *plugin_entities.PluginDeclaration,
+	provider string,
+	tool string,
+	response *stream.Stream[tool_entities.ToolResponseChunk],
+) *stream.Stream[tool_entities.ToolResponseChunk] {
+	p, _ := policy.Load().(Policy)
+	if p != POLICY_WARN && p != POLICY_ENFORCE {
+		return response
+	}
+
+	outputSchema := findOutputSchema(declaration, provider, tool)
+	if outputSchema == nil {
+		return response
+	}
+	schema, err := plugin_entities.CompileJSONSchema(outputSchema)
+	if err != nil {
+		// rejected when the plugin was installed, unless it was installed before the schema was checked
+		log.Warn("output schema of tool %s/%s is invalid: %s", provider, tool, err.Error())
+		return response
+	}
+
+	violated := func(violations []Violation) bool {
+		if len(violations) == 0 {
+			return false
+		}
+		err := &ViolationError{Provider: provider, Tool: tool, Violations: violations}
+		if p == POLICY_WARN {
+			log.Warn("tool output violates its schema: %s", err.Error())
+			return false
+		}
+		return true
+	}
+
+	checked := stream.NewStream[tool_entities.ToolResponseChunk](1024)
+	checked.OnClose(response.Close)
+
+	routine.Submit(map[string]string{
+		"module":   "output_schema",
+		"function": "Check",
+	}, func() {
+		defer checked.Close()
+
+		variables := newVariables()
+		for response.Next() {
+			chunk, err := response.Read()
+			if err != nil {
+				checked.WriteError(err)
+				return
+			}
+
+			switch chunk.Type {
+			case tool_entities.ToolResponseChunkTypeJson:
+				if violations := validate(schema, string(tool_entities.ToolResponseChunkTypeJson), chunk.Message["json_object"]); violated(violations) {
+					checked.WriteError(&ViolationError{Provider: provider, Tool: tool, Violations: violations})
+					return
+				}
+			case tool_entities.ToolResponseChunkTypeVariable:
+				variables.add(chunk.Message)
+			}
+			checked.WriteBlocking(chunk)
+		}
+
+		if !variables.empty() {
+			if violations := validate(schema, string(tool_entities.ToolResponseChunkTypeVariable), variables.values); violated(violations) {
+				checked.WriteError(&ViolationError{Provider: provider, Tool: tool, Violations: violations})
+			}
+		}
+	})
+
+	return checked
+}
+
+// variables assembles the variables of a response the way dify does, chunks of a streamed variable
+// are concatenated
+type variables struct {
+	values map[string]any
+}
+
+func newVariables() *variables {
+	return &variables{values: map[string]any{}}
+}
+
+func (v *variables) add(message map[string]any) {
+	name, ok := message["variable_name"].(string)
+	if !ok {
+		return
+	}
+	value := message["variable_value"]
+	if streamed, _ := message["stream"].(bool); streamed {
+		previous, _ := v.values[name].(string)
+		chunk, _ := value.(string)
+		v.values[name] = previous + chunk
+		return
+	}
+	v.values[name] = value
+}
+
+func (v *variables) empty() bool {
+	return len(v.values) == 0
+}
+
+// validate returns the violations of the value, values are converted to the json types the schema
+// expects first
+func validate(schema *jsonschema.Schema, source string, value any) []Violation {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return []Violation{{Source: source, Message: err.Error()}}
+	}
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return []Violation{{Source: source, Message: err.Error()}}
+	}
+
+	err = schema.Validate(instance)
+	if err == nil {
+		return nil
+	}
+
+	var validationError *jsonschema.ValidationError
+	if !errors.As(err, &validationError) {
+		return []Violation{{Source: source, Message: err.Error()}}
+	}
+
+	violations := []Violation{}
+	for _, unit := range validationError.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+		violations = append(violations, Violation{
+			Source:   source,
+			Location: unit.InstanceLocation,
+			Message:  unit.Error.String(),
+		})
+	}
+	if len(violations) == 0 {
+		violations = append(violations, Violation{Source: source, Message: validationError.Error()})
+	}
+	return violations
+}
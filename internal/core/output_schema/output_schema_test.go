@@ -0,0 +1,97 @@
+package output_schema
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
+)
+
+func declaration() *plugin_entities.PluginDeclaration {
+	declaration := &plugin_entities.PluginDeclaration{}
+	declaration.Tool = &plugin_entities.ToolProviderDeclaration{}
+	declaration.Tool.Identity.Name = "search"
+	declaration.Tool.Tools = []plugin_entities.ToolDeclaration{{
+		OutputSchema: plugin_entities.ToolOutputSchema{
+			"type":     "object",
+			"required": []any{"summary"},
+			"properties": map[string]any{
+				"summary": map[string]any{"type": "string"},
+				"count":   map[string]any{"type": "integer"},
+			},
+		},
+	}}
+	declaration.Tool.Tools[0].Identity.Name = "web"
+	return declaration
+}
+
+func variable(name string, value any, streamed bool) tool_entities.ToolResponseChunk {
+	return tool_entities.ToolResponseChunk{
+		Type:    tool_entities.ToolResponseChunkTypeVariable,
+		Message: map[string]any{"variable_name": name, "variable_value": value, "stream": streamed},
+	}
+}
+
+func run(t *testing.T, chunks ...tool_entities.ToolResponseChunk) (int, error) {
+	response := stream.NewStream[tool_entities.ToolResponseChunk](len(chunks) + 1)
+	for _, chunk := range chunks {
+		response.Write(chunk)
+	}
+	response.Close()
+
+	checked := Check(declaration(), "search", "web", response)
+	read := 0
+	for checked.Next() {
+		if _, err := checked.Read(); err != nil {
+			return read, err
+		}
+		read++
+	}
+	return read, nil
+}
+
+func TestCheck(t *testing.T) {
+	routine.InitPool(100)
+	Init(POLICY_ENFORCE)
+	t.Cleanup(func() { Init(POLICY_OFF) })
+
+	read, err := run(t, variable("summary", "a ", true), variable("summary", "b", true), variable("count", 2, false))
+	if err != nil || read != 3 {
+		t.Fatalf("expected the response to pass, got %d chunks and %v", read, err)
+	}
+
+	_, err = run(t, variable("count", "two", false))
+	var violationError *ViolationError
+	if !errors.As(err, &violationError) {
+		t.Fatalf("expected a violation, got %v", err)
+	}
+	locations := map[string]bool{}
+	for _, violation := range violationError.Violations {
+		locations[violation.Location] = true
+	}
+	if !locations["/count"] || !locations[""] {
+		t.Fatalf("expected the type of count and the missing summary to be violations, got %+v", violationError.Violations)
+	}
+
+	read, err = run(t, tool_entities.ToolResponseChunk{
+		Type:    tool_entities.ToolResponseChunkTypeJson,
+		Message: map[string]any{"json_object": map[string]any{"count": 1}},
+	}, variable("summary", "a", false))
+	if !errors.As(err, &violationError) || read != 0 {
+		t.Fatalf("expected the json message to be rejected, got %d chunks and %v", read, err)
+	}
+}
+
+func TestCheckWarnOnly(t *testing.T) {
+	routine.InitPool(100)
+	Init(POLICY_WARN)
+	t.Cleanup(func() { Init(POLICY_OFF) })
+
+	read, err := run(t, variable("count", "two", false))
+	if err != nil || read != 1 {
+		t.Fatalf("expected violations to be logged only, got %d chunks and %v", read, err)
+	}
+}
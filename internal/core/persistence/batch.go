@@ -0,0 +1,99 @@
+package persistence
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	MAX_BATCH_SIZE = 100
+)
+
+type BatchItem struct {
+	Key  string
+	Data []byte
+	TTL  time.Duration
+}
+
+func checkBatchSize(size int) error {
+	if size > MAX_BATCH_SIZE {
+		return fmt.Errorf("batch size must be at most %d", MAX_BATCH_SIZE)
+	}
+	return nil
+}
+
+// LoadBatch loads the keys which exist, missing and expired ones are left out
+func (c *Persistence) LoadBatch(tenantId string, pluginId string, keys []string) (map[string][]byte, error) {
+	if err := checkBatchSize(len(keys)); err != nil {
+		return nil, err
+	}
+
+	result := map[string][]byte{}
+	for _, key := range keys {
+		exists, err := c.Exist(tenantId, pluginId, key)
+		if err != nil {
+			return nil, err
+		}
+		if exists == 0 {
+			continue
+		}
+
+		data, err := c.Load(tenantId, pluginId, key)
+		if err == ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", key, err)
+		}
+		result[key] = data
+	}
+	return result, nil
+}
+
+// SaveBatch checks all items against the quota before writing any of them
+func (c *Persistence) SaveBatch(tenantId string, pluginId string, maxSize int64, items []BatchItem) error {
+	if err := checkBatchSize(len(items)); err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	writes := make([]*pendingWrite, 0, len(items))
+	for _, item := range items {
+		if seen[item.Key] {
+			return fmt.Errorf("duplicated key %s in batch", item.Key)
+		}
+		seen[item.Key] = true
+
+		write, err := c.prepareWrite(tenantId, pluginId, item.Key, item.Data, item.TTL)
+		if err != nil {
+			return fmt.Errorf("invalid item %s: %w", item.Key, err)
+		}
+		writes = append(writes, write)
+	}
+
+	return c.commitWrites(tenantId, pluginId, maxSize, writes)
+}
+
+// DeleteBatch deletes the keys which exist and returns how many were deleted
+func (c *Persistence) DeleteBatch(tenantId string, pluginId string, keys []string) (int64, error) {
+	if err := checkBatchSize(len(keys)); err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	for _, key := range keys {
+		exists, err := c.Exist(tenantId, pluginId, key)
+		if err != nil {
+			return deleted, err
+		}
+		if exists == 0 {
+			continue
+		}
+
+		if _, err := c.Delete(tenantId, pluginId, key); err != nil {
+			return deleted, fmt.Errorf("failed to delete %s: %w", key, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
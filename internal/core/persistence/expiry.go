@@ -0,0 +1,65 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/schedule"
+)
+
+const (
+	// expired keys are swept every interval unless PERSISTENCE_EXPIRY_SWEEP_SCHEDULE is set
+	EXPIRY_SWEEP_INTERVAL   = time.Minute
+	EXPIRY_SWEEP_BATCH_SIZE = 500
+	EXPIRY_SWEEP_LOCK_KEY   = "persistence:expiry:lock"
+)
+
+// PurgeExpired deletes up to EXPIRY_SWEEP_BATCH_SIZE keys expired before now and returns how many were deleted,
+// expired keys are also deleted once they are read
+func (c *Persistence) PurgeExpired(now time.Time) (int64, error) {
+	records, err := db.GetAll[models.PersistenceKey](
+		db.LessThanOrEqual("expires_at", now),
+		db.Page(1, EXPIRY_SWEEP_BATCH_SIZE),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var purged int64
+	for _, record := range records {
+		if _, err := c.Delete(record.TenantID, record.PluginID, record.StorageKey); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// StartExpirySweeper purges expired keys at every activation of sweepSchedule on one node of the cluster
+func StartExpirySweeper(sweepSchedule schedule.Schedule) {
+	schedule.Run("persistence_expiry", sweepSchedule, func() {
+		if persistence == nil {
+			return
+		}
+
+		now := time.Now()
+		ok, err := cache.SetNX(EXPIRY_SWEEP_LOCK_KEY, true, sweepSchedule.Next(now).Sub(now)/2)
+		if err != nil {
+			log.Error("failed to acquire persistence expiry lock: %s", err.Error())
+			return
+		}
+		if !ok {
+			return
+		}
+
+		purged, err := persistence.PurgeExpired(now)
+		if err != nil {
+			log.Error("failed to purge expired persistence keys: %s", err.Error())
+		} else if purged > 0 {
+			log.Info("purged %d expired persistence keys", purged)
+		}
+	})
+}
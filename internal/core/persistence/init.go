@@ -15,6 +15,8 @@ func InitPersistence(oss oss.OSS, config *app.Config) {
 	persistence = &Persistence{
 		storage:        NewWrapper(oss, config.PersistenceStoragePath),
 		maxStorageSize: config.PersistenceStorageMaxSize,
+		maxKeySize:     config.PersistenceMaxKeySize,
+		maxTenantSize:  config.TenantMaxStorageBytes,
 	}
 
 	log.Info("Persistence initialized")
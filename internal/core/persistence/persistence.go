@@ -2,7 +2,9 @@ package persistence
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"path"
 	"strings"
 	"time"
@@ -14,12 +16,24 @@ import (
 
 type Persistence struct {
 	maxStorageSize int64
+	// maxKeySize limits the size of each key, 0 is unlimited
+	maxKeySize int64
+	// maxTenantSize limits the size of all plugins of a tenant, 0 is unlimited
+	maxTenantSize int64
 
 	storage PersistenceStorage
 }
 
 const (
 	CACHE_KEY_PREFIX = "persistence:cache"
+	CACHE_EXPIRE     = time.Minute * 5
+	MAX_KEY_LENGTH   = 256
+)
+
+var (
+	ErrKeyNotFound   = errors.New("key not found")
+	ErrQuotaExceeded = errors.New("allocated size is greater than max storage size")
+	ErrValueTooLarge = errors.New("value is larger than the max size of a key")
 )
 
 func (c *Persistence) getCacheKey(tenantId string, pluginId string, key string) string {
@@ -34,63 +48,75 @@ func (c *Persistence) checkPathTraversal(key string) error {
 	return nil
 }
 
-func (c *Persistence) Save(tenantId string, pluginId string, maxSize int64, key string, data []byte) error {
+func (c *Persistence) validateKey(key string) error {
 	if err := c.checkPathTraversal(key); err != nil {
 		return err
 	}
 
-	if len(key) > 256 {
-		return fmt.Errorf("key length must be less than 256 characters")
+	if len(key) > MAX_KEY_LENGTH {
+		return fmt.Errorf("key length must be less than %d characters", MAX_KEY_LENGTH)
 	}
+	return nil
+}
 
-	if maxSize == -1 {
-		maxSize = c.maxStorageSize
+func (c *Persistence) Save(tenantId string, pluginId string, maxSize int64, key string, data []byte) error {
+	return c.SaveWithTTL(tenantId, pluginId, maxSize, key, data, 0)
+}
+
+// SaveWithTTL saves data which expires after ttl, 0 keeps it until it's deleted
+func (c *Persistence) SaveWithTTL(tenantId string, pluginId string, maxSize int64, key string, data []byte, ttl time.Duration) error {
+	write, err := c.prepareWrite(tenantId, pluginId, key, data, ttl)
+	if err != nil {
+		return err
 	}
 
-	if err := c.storage.Save(tenantId, pluginId, key, data); err != nil {
+	return c.commitWrites(tenantId, pluginId, maxSize, []*pendingWrite{write})
+}
+
+// SaveStream saves data from reader without buffering it, the write is aborted
+// once the remaining quota of the plugin is exhausted
+func (c *Persistence) SaveStream(tenantId string, pluginId string, maxSize int64, key string, reader io.Reader) error {
+	return c.SaveStreamWithTTL(tenantId, pluginId, maxSize, key, reader, 0)
+}
+
+// SaveStreamWithTTL is SaveStream for data which expires after ttl, 0 keeps it until it's deleted
+func (c *Persistence) SaveStreamWithTTL(tenantId string, pluginId string, maxSize int64, key string, reader io.Reader, ttl time.Duration) error {
+	if err := c.validateKey(key); err != nil {
 		return err
 	}
 
-	allocatedSize := int64(len(data))
+	record, previous, err := c.previous(tenantId, pluginId, key)
+	if err != nil {
+		return err
+	}
 
-	storage, err := db.GetOne[models.TenantStorage](
-		db.Equal("tenant_id", tenantId),
-		db.Equal("plugin_id", pluginId),
-	)
+	remaining, exists, err := c.remainingQuota(tenantId, pluginId, maxSize)
 	if err != nil {
-		if allocatedSize > c.maxStorageSize || allocatedSize > maxSize {
-			return fmt.Errorf("allocated size is greater than max storage size")
-		}
+		return err
+	}
 
-		if err == db.ErrDatabaseNotFound {
-			storage = models.TenantStorage{
-				TenantID: tenantId,
-				PluginID: pluginId,
-				Size:     allocatedSize,
-			}
-			if err := db.Create(&storage); err != nil {
-				return err
-			}
-		} else {
-			return err
-		}
-	} else {
-		if allocatedSize+storage.Size > maxSize || allocatedSize+storage.Size > c.maxStorageSize {
-			return fmt.Errorf("allocated size is greater than max storage size")
-		}
+	// the previous value is replaced, its size is free to use
+	remaining += previous
+	if c.maxKeySize > 0 && c.maxKeySize < remaining {
+		remaining = c.maxKeySize
+	}
 
-		err = db.Run(
-			db.Model(&models.TenantStorage{}),
-			db.Equal("tenant_id", tenantId),
-			db.Equal("plugin_id", pluginId),
-			db.Inc(map[string]int64{"size": allocatedSize}),
-		)
-		if err != nil {
-			return err
+	counter := &quotaReader{reader: reader, remaining: remaining}
+	if err := c.storage.SaveStream(tenantId, pluginId, key, counter); err != nil {
+		if counter.exceeded {
+			// remove the partial object if the storage kept it
+			c.storage.Delete(tenantId, pluginId, key)
 		}
+		return err
+	}
+
+	if err := c.accountSize(tenantId, pluginId, exists, counter.read-previous); err != nil {
+		return err
+	}
+	if err := c.recordKey(tenantId, pluginId, key, record, counter.read, ttl); err != nil {
+		return err
 	}
 
-	// delete from cache
 	if _, err = cache.Del(c.getCacheKey(tenantId, pluginId, key)); err == cache.ErrNotFound {
 		return nil
 	}
@@ -112,18 +138,50 @@ func (c *Persistence) Load(tenantId string, pluginId string, key string) ([]byte
 		return hex.DecodeString(h)
 	}
 
+	record, err := c.record(tenantId, pluginId, key)
+	if err != nil {
+		return nil, err
+	}
+	if expired(record, time.Now()) {
+		c.Delete(tenantId, pluginId, key)
+		return nil, ErrKeyNotFound
+	}
+
 	// load from storage
 	data, err := c.storage.Load(tenantId, pluginId, key)
 	if err != nil {
 		return nil, err
 	}
 
-	// add to cache
-	cache.Store(c.getCacheKey(tenantId, pluginId, key), hex.EncodeToString(data), time.Minute*5)
+	// add to cache, but never beyond the expiration of the key
+	expire := CACHE_EXPIRE
+	if record != nil && record.ExpiresAt != nil && time.Until(*record.ExpiresAt) < expire {
+		expire = time.Until(*record.ExpiresAt)
+	}
+	cache.Store(c.getCacheKey(tenantId, pluginId, key), hex.EncodeToString(data), expire)
 
 	return data, nil
 }
 
+// LoadStream opens the stored data for reading, the cache is bypassed
+// as large objects are not supposed to be cached, caller must close the reader
+func (c *Persistence) LoadStream(tenantId string, pluginId string, key string) (io.ReadCloser, error) {
+	if err := c.checkPathTraversal(key); err != nil {
+		return nil, err
+	}
+
+	record, err := c.record(tenantId, pluginId, key)
+	if err != nil {
+		return nil, err
+	}
+	if expired(record, time.Now()) {
+		c.Delete(tenantId, pluginId, key)
+		return nil, ErrKeyNotFound
+	}
+
+	return c.storage.LoadStream(tenantId, pluginId, key)
+}
+
 func (c *Persistence) Delete(tenantId string, pluginId string, key string) (int64, error) {
 	// delete from cache and storage
 	deletedNum, err := cache.Del(c.getCacheKey(tenantId, pluginId, key))
@@ -131,12 +189,22 @@ func (c *Persistence) Delete(tenantId string, pluginId string, key string) (int6
 		return 0, err
 	}
 
-	// state size
-	size, err := c.storage.StateSize(tenantId, pluginId, key)
+	record, err := c.record(tenantId, pluginId, key)
 	if err != nil {
 		return 0, err
 	}
 
+	// state size of keys without record
+	var size int64
+	if record != nil {
+		size = record.Size
+	} else {
+		size, err = c.storage.StateSize(tenantId, pluginId, key)
+		if err != nil {
+			return 0, err
+		}
+	}
+
 	err = c.storage.Delete(tenantId, pluginId, key)
 	if err != nil {
 		return 0, err
@@ -153,6 +221,12 @@ func (c *Persistence) Delete(tenantId string, pluginId string, key string) (int6
 		return 0, err
 	}
 
+	if record != nil {
+		if err := db.Delete(record); err != nil {
+			return 0, err
+		}
+	}
+
 	return deletedNum, nil
 }
 
@@ -165,6 +239,15 @@ func (c *Persistence) Exist(tenantId string, pluginId string, key string) (int64
 		return existNum, nil
 	}
 
+	record, err := c.record(tenantId, pluginId, key)
+	if err != nil {
+		return 0, err
+	}
+	if expired(record, time.Now()) {
+		c.Delete(tenantId, pluginId, key)
+		return 0, nil
+	}
+
 	isExist, err := c.storage.Exists(tenantId, pluginId, key)
 	if err != nil {
 		return 0, err
@@ -174,3 +257,21 @@ func (c *Persistence) Exist(tenantId string, pluginId string, key string) (int64
 	}
 	return 0, nil
 }
+
+// quotaReader counts bytes read and fails once remaining quota is exceeded
+type quotaReader struct {
+	reader    io.Reader
+	remaining int64
+	read      int64
+	exceeded  bool
+}
+
+func (q *quotaReader) Read(p []byte) (int, error) {
+	n, err := q.reader.Read(p)
+	q.read += int64(n)
+	if q.read > q.remaining {
+		q.exceeded = true
+		return n, ErrQuotaExceeded
+	}
+	return n, err
+}
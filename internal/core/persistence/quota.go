@@ -0,0 +1,197 @@
+package persistence
+
+import (
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+)
+
+type pendingWrite struct {
+	key      string
+	data     []byte
+	ttl      time.Duration
+	record   *models.PersistenceKey
+	previous int64
+}
+
+func expired(record *models.PersistenceKey, now time.Time) bool {
+	return record != nil && record.ExpiresAt != nil && !record.ExpiresAt.After(now)
+}
+
+// record returns nil for keys without record
+func (c *Persistence) record(tenantId string, pluginId string, key string) (*models.PersistenceKey, error) {
+	record, err := db.GetOne[models.PersistenceKey](
+		db.Equal("tenant_id", tenantId),
+		db.Equal("plugin_id", pluginId),
+		db.Equal("storage_key", key),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// previous returns the record of key and the size it takes, keys stored before records
+// were introduced are stated from the storage
+func (c *Persistence) previous(tenantId string, pluginId string, key string) (*models.PersistenceKey, int64, error) {
+	record, err := c.record(tenantId, pluginId, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if record != nil {
+		return record, record.Size, nil
+	}
+
+	exists, err := c.storage.Exists(tenantId, pluginId, key)
+	if err != nil || !exists {
+		return nil, 0, err
+	}
+	size, err := c.storage.StateSize(tenantId, pluginId, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	return nil, size, nil
+}
+
+// remainingQuota returns the bytes the plugin is still allowed to store for the tenant, maxSize is
+// declared by the plugin and -1 if it declares none, it also reports if the plugin has stored anything
+func (c *Persistence) remainingQuota(tenantId string, pluginId string, maxSize int64) (int64, bool, error) {
+	if maxSize == -1 || maxSize > c.maxStorageSize {
+		maxSize = c.maxStorageSize
+	}
+
+	exists := true
+	storage, err := db.GetOne[models.TenantStorage](
+		db.Equal("tenant_id", tenantId),
+		db.Equal("plugin_id", pluginId),
+	)
+	if err == db.ErrDatabaseNotFound {
+		exists = false
+	} else if err != nil {
+		return 0, false, err
+	}
+
+	remaining := maxSize - storage.Size
+	if c.maxTenantSize > 0 {
+		used, err := db.GetSum[models.TenantStorage, int64]("size", db.Equal("tenant_id", tenantId))
+		if err != nil {
+			return 0, false, err
+		}
+		if c.maxTenantSize-used < remaining {
+			remaining = c.maxTenantSize - used
+		}
+	}
+
+	return remaining, exists, nil
+}
+
+func (c *Persistence) prepareWrite(tenantId string, pluginId string, key string, data []byte, ttl time.Duration) (*pendingWrite, error) {
+	if err := c.validateKey(key); err != nil {
+		return nil, err
+	}
+
+	if c.maxKeySize > 0 && int64(len(data)) > c.maxKeySize {
+		return nil, ErrValueTooLarge
+	}
+
+	record, previous, err := c.previous(tenantId, pluginId, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pendingWrite{key: key, data: data, ttl: ttl, record: record, previous: previous}, nil
+}
+
+// commitWrites checks the writes against the quota as a whole before writing any of them,
+// a failed write stops the rest while those before it stay written
+func (c *Persistence) commitWrites(tenantId string, pluginId string, maxSize int64, writes []*pendingWrite) error {
+	var delta int64
+	for _, write := range writes {
+		delta += int64(len(write.data)) - write.previous
+	}
+
+	remaining, exists, err := c.remainingQuota(tenantId, pluginId, maxSize)
+	if err != nil {
+		return err
+	}
+	if delta > remaining {
+		return ErrQuotaExceeded
+	}
+
+	var written int64
+	for _, write := range writes {
+		if err = c.storage.Save(tenantId, pluginId, write.key, write.data); err != nil {
+			break
+		}
+		written += int64(len(write.data)) - write.previous
+
+		if err = c.recordKey(tenantId, pluginId, write.key, write.record, int64(len(write.data)), write.ttl); err != nil {
+			break
+		}
+
+		if _, err = cache.Del(c.getCacheKey(tenantId, pluginId, write.key)); err != nil && err != cache.ErrNotFound {
+			break
+		}
+		err = nil
+	}
+
+	if accountErr := c.accountSize(tenantId, pluginId, exists, written); accountErr != nil {
+		return accountErr
+	}
+	return err
+}
+
+// accountSize adds delta to the storage size of the plugin
+func (c *Persistence) accountSize(tenantId string, pluginId string, exists bool, delta int64) error {
+	if !exists {
+		return db.Create(&models.TenantStorage{
+			TenantID: tenantId,
+			PluginID: pluginId,
+			Size:     delta,
+		})
+	}
+
+	if delta == 0 {
+		return nil
+	}
+	return db.Run(
+		db.Model(&models.TenantStorage{}),
+		db.Equal("tenant_id", tenantId),
+		db.Equal("plugin_id", pluginId),
+		db.Inc(map[string]int64{"size": delta}),
+	)
+}
+
+func (c *Persistence) recordKey(
+	tenantId string,
+	pluginId string,
+	key string,
+	record *models.PersistenceKey,
+	size int64,
+	ttl time.Duration,
+) error {
+	var expiresAt *time.Time
+	if ttl > 0 {
+		at := time.Now().Add(ttl)
+		expiresAt = &at
+	}
+
+	if record == nil {
+		return db.Create(&models.PersistenceKey{
+			TenantID:   tenantId,
+			PluginID:   pluginId,
+			StorageKey: key,
+			Size:       size,
+			ExpiresAt:  expiresAt,
+		})
+	}
+
+	record.Size = size
+	record.ExpiresAt = expiresAt
+	return db.Update(record)
+}
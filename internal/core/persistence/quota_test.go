@@ -0,0 +1,188 @@
+package persistence
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+	"github.com/langgenius/dify-cloud-kit/oss/factory"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+)
+
+func initLocalPersistence(t *testing.T, config *app.Config) *Persistence {
+	if err := cache.InitMemoryClient(0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cache.Close() })
+
+	dbConfig := &app.Config{DBType: "sqlite", DBSqlitePath: filepath.Join(t.TempDir(), "persistence.db")}
+	dbConfig.SetDefault()
+	db.Init(dbConfig)
+	t.Cleanup(db.Close)
+
+	oss, err := factory.Load("local", cloudoss.OSSArgs{Local: &cloudoss.Local{Path: t.TempDir()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.PersistenceStoragePath = "persistence"
+	InitPersistence(oss, config)
+	return persistence
+}
+
+func storedSize(t *testing.T, tenantId string, pluginId string) int64 {
+	storage, err := db.GetOne[models.TenantStorage](db.Equal("tenant_id", tenantId), db.Equal("plugin_id", pluginId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return storage.Size
+}
+
+func TestSaveAccountsOverwrites(t *testing.T) {
+	p := initLocalPersistence(t, &app.Config{PersistenceStorageMaxSize: 10, PersistenceMaxKeySize: 6})
+
+	if err := p.Save("tenant", "plugin", -1, "a", []byte("123456")); err != nil {
+		t.Fatal(err)
+	}
+	// overwriting replaces the size of the previous value instead of adding to it
+	if err := p.Save("tenant", "plugin", -1, "a", []byte("1234")); err != nil {
+		t.Fatal(err)
+	}
+	if size := storedSize(t, "tenant", "plugin"); size != 4 {
+		t.Fatalf("expected 4 bytes to be accounted, got %d", size)
+	}
+
+	if err := p.Save("tenant", "plugin", -1, "b", []byte("1234567")); !errors.Is(err, ErrValueTooLarge) {
+		t.Fatalf("expected the key to be too large, got %v", err)
+	}
+	if err := p.Save("tenant", "plugin", -1, "b", []byte("123456")); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Save("tenant", "plugin", -1, "c", []byte("1")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected the quota of the plugin to be exhausted, got %v", err)
+	}
+
+	if _, err := p.Delete("tenant", "plugin", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if size := storedSize(t, "tenant", "plugin"); size != 6 {
+		t.Fatalf("expected 6 bytes to be left, got %d", size)
+	}
+}
+
+func TestSaveTenantQuota(t *testing.T) {
+	p := initLocalPersistence(t, &app.Config{PersistenceStorageMaxSize: 10, TenantMaxStorageBytes: 8})
+
+	if err := p.Save("tenant", "a", -1, "key", []byte("12345")); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Save("tenant", "b", -1, "key", []byte("12345")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected the quota of the tenant to be exhausted, got %v", err)
+	}
+	if err := p.Save("other", "b", -1, "key", []byte("12345")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSaveWithTTL(t *testing.T) {
+	p := initLocalPersistence(t, &app.Config{PersistenceStorageMaxSize: 100})
+
+	if err := p.SaveWithTTL("tenant", "plugin", -1, "short", []byte("data"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Save("tenant", "plugin", -1, "kept", []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := p.Exist("tenant", "plugin", "short"); err != nil || exists != 1 {
+		t.Fatalf("expected the key to exist before it expires, got %d %v", exists, err)
+	}
+
+	purged, err := p.PurgeExpired(time.Now().Add(2 * time.Hour))
+	if err != nil || purged != 1 {
+		t.Fatalf("expected one expired key to be purged, got %d %v", purged, err)
+	}
+	if exists, err := p.Exist("tenant", "plugin", "short"); err != nil || exists != 0 {
+		t.Fatalf("expected the expired key to be deleted, got %d %v", exists, err)
+	}
+	if size := storedSize(t, "tenant", "plugin"); size != 4 {
+		t.Fatalf("expected the expired key to be released, got %d", size)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	p := initLocalPersistence(t, &app.Config{PersistenceStorageMaxSize: 10})
+
+	// the batch exceeds the quota as a whole, so nothing is written
+	if err := p.SaveBatch("tenant", "plugin", -1, []BatchItem{
+		{Key: "a", Data: []byte("123456")},
+		{Key: "b", Data: []byte("123456")},
+	}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected the batch to exceed the quota, got %v", err)
+	}
+	if exists, _ := p.Exist("tenant", "plugin", "a"); exists != 0 {
+		t.Fatal("expected nothing of a rejected batch to be written")
+	}
+
+	if err := p.SaveBatch("tenant", "plugin", -1, []BatchItem{{Key: "a", Data: []byte("1")}, {Key: "a"}}); err == nil {
+		t.Fatal("expected duplicated keys to be rejected")
+	}
+
+	if err := p.SaveBatch("tenant", "plugin", -1, []BatchItem{
+		{Key: "a", Data: []byte("1")},
+		{Key: "b", Data: []byte("2")},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := p.LoadBatch("tenant", "plugin", []string{"a", "b", "missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2 || string(data["a"]) != "1" || string(data["b"]) != "2" {
+		t.Fatalf("unexpected batch %v", data)
+	}
+
+	deleted, err := p.DeleteBatch("tenant", "plugin", []string{"a", "missing"})
+	if err != nil || deleted != 1 {
+		t.Fatalf("expected one key to be deleted, got %d %v", deleted, err)
+	}
+	if size := storedSize(t, "tenant", "plugin"); size != 1 {
+		t.Fatalf("expected 1 byte to be left, got %d", size)
+	}
+}
+
+func TestSaveStreamWithTTL(t *testing.T) {
+	p := initLocalPersistence(t, &app.Config{PersistenceStorageMaxSize: 10})
+
+	if err := p.SaveStreamWithTTL("tenant", "plugin", -1, "a", strings.NewReader("123456"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SaveStream("tenant", "plugin", -1, "b", strings.NewReader("123456")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected the stream to exceed the quota, got %v", err)
+	}
+	if exists, _ := p.Exist("tenant", "plugin", "b"); exists != 0 {
+		t.Fatal("expected nothing of an aborted stream to be kept")
+	}
+
+	reader, err := p.LoadStream("tenant", "plugin", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil || string(data) != "123456" {
+		t.Fatalf("expected the streamed value to be loaded, got %q %v", data, err)
+	}
+
+	purged, err := p.PurgeExpired(time.Now().Add(2 * time.Hour))
+	if err != nil || purged != 1 {
+		t.Fatalf("expected the streamed key to expire, got %d %v", purged, err)
+	}
+}
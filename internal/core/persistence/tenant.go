@@ -0,0 +1,81 @@
+package persistence
+
+import (
+	"fmt"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+)
+
+type StoredObject struct {
+	PluginID string `json:"plugin_id"`
+	Key      string `json:"key"`
+	Size     int64  `json:"size"`
+}
+
+// ListTenant returns every object stored by plugins on behalf of a tenant
+func (c *Persistence) ListTenant(tenantId string) ([]StoredObject, error) {
+	storages, err := db.GetAll[models.TenantStorage](
+		db.Equal("tenant_id", tenantId),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := []StoredObject{}
+	for _, storage := range storages {
+		paths, err := c.storage.List(tenantId, storage.PluginID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range paths {
+			if p.IsDir {
+				continue
+			}
+
+			size, err := c.storage.StateSize(tenantId, storage.PluginID, p.Path)
+			if err != nil {
+				return nil, err
+			}
+
+			objects = append(objects, StoredObject{
+				PluginID: storage.PluginID,
+				Key:      p.Path,
+				Size:     size,
+			})
+		}
+	}
+
+	return objects, nil
+}
+
+// PurgeTenant deletes every object of a tenant along with its cache and quota records,
+// it returns the number of deleted objects
+func (c *Persistence) PurgeTenant(tenantId string) (int, error) {
+	objects, err := c.ListTenant(tenantId)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, object := range objects {
+		if err := c.storage.Delete(tenantId, object.PluginID, object.Key); err != nil {
+			return 0, fmt.Errorf("failed to delete %s of plugin %s: %s", object.Key, object.PluginID, err.Error())
+		}
+	}
+
+	if _, err := cache.DelByPattern(fmt.Sprintf("%s:%s:*", CACHE_KEY_PREFIX, tenantId)); err != nil {
+		return 0, err
+	}
+
+	if err := db.DeleteByCondition(models.TenantStorage{TenantID: tenantId}); err != nil {
+		return 0, err
+	}
+
+	if err := db.DeleteByCondition(models.PersistenceKey{TenantID: tenantId}); err != nil {
+		return 0, err
+	}
+
+	return len(objects), nil
+}
@@ -1,9 +1,19 @@
 package persistence
 
+import (
+	"io"
+
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+)
+
 type PersistenceStorage interface {
 	Save(tenant_id string, plugin_checksum string, key string, data []byte) error
+	SaveStream(tenant_id string, plugin_checksum string, key string, reader io.Reader) error
 	Load(tenant_id string, plugin_checksum string, key string) ([]byte, error)
+	LoadStream(tenant_id string, plugin_checksum string, key string) (io.ReadCloser, error)
 	Delete(tenant_id string, plugin_checksum string, key string) error
 	StateSize(tenant_id string, plugin_checksum string, key string) (int64, error)
 	Exists(tenant_id string, plugin_checksum string, key string) (bool, error)
+	// List returns the objects of a plugin, paths are relative to its directory
+	List(tenant_id string, plugin_checksum string) ([]cloudoss.OSSPath, error)
 }
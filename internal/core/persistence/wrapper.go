@@ -1,19 +1,22 @@
 package persistence
 
 import (
+	"io"
 	"path"
 
-	"github.com/langgenius/dify-cloud-kit/oss"
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/oss"
 )
 
 type wrapper struct {
-	oss                    oss.OSS
+	oss                    oss.StreamingOSS
 	persistenceStoragePath string
 }
 
-func NewWrapper(oss oss.OSS, persistenceStoragePath string) *wrapper {
+func NewWrapper(storage cloudoss.OSS, persistenceStoragePath string) *wrapper {
 	return &wrapper{
-		oss:                    oss,
+		oss:                    oss.Streaming(storage),
 		persistenceStoragePath: persistenceStoragePath,
 	}
 }
@@ -28,11 +31,21 @@ func (s *wrapper) Save(tenant_id string, plugin_checksum string, key string, dat
 	return s.oss.Save(filePath, data)
 }
 
+func (s *wrapper) SaveStream(tenant_id string, plugin_checksum string, key string, reader io.Reader) error {
+	filePath := s.getFilePath(tenant_id, plugin_checksum, key)
+	return s.oss.SaveStream(filePath, reader)
+}
+
 func (s *wrapper) Load(tenant_id string, plugin_checksum string, key string) ([]byte, error) {
 	filePath := s.getFilePath(tenant_id, plugin_checksum, key)
 	return s.oss.Load(filePath)
 }
 
+func (s *wrapper) LoadStream(tenant_id string, plugin_checksum string, key string) (io.ReadCloser, error) {
+	filePath := s.getFilePath(tenant_id, plugin_checksum, key)
+	return s.oss.LoadStream(filePath)
+}
+
 func (s *wrapper) Exists(tenant_id string, plugin_checksum string, key string) (bool, error) {
 	filePath := s.getFilePath(tenant_id, plugin_checksum, key)
 	return s.oss.Exists(filePath)
@@ -52,3 +65,7 @@ func (s *wrapper) StateSize(tenant_id string, plugin_checksum string, key string
 
 	return state.Size, nil
 }
+
+func (s *wrapper) List(tenant_id string, plugin_checksum string) ([]cloudoss.OSSPath, error) {
+	return s.oss.List(path.Join(s.persistenceStoragePath, tenant_id, plugin_checksum))
+}
@@ -0,0 +1,48 @@
+package backwards_invocation
+
+import (
+	"fmt"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/trust"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
+)
+
+var consentMapping = map[dify_invocation.InvokeType]string{
+	dify_invocation.INVOKE_TYPE_TOOL:                     trust.PERMISSION_TOOL,
+	dify_invocation.INVOKE_TYPE_LLM:                      trust.PERMISSION_MODEL,
+	dify_invocation.INVOKE_TYPE_LLM_STRUCTURED_OUTPUT:    trust.PERMISSION_MODEL,
+	dify_invocation.INVOKE_TYPE_TEXT_EMBEDDING:           trust.PERMISSION_MODEL,
+	dify_invocation.INVOKE_TYPE_RERANK:                   trust.PERMISSION_MODEL,
+	dify_invocation.INVOKE_TYPE_TTS:                      trust.PERMISSION_MODEL,
+	dify_invocation.INVOKE_TYPE_SPEECH2TEXT:              trust.PERMISSION_MODEL,
+	dify_invocation.INVOKE_TYPE_MODERATION:               trust.PERMISSION_MODEL,
+	dify_invocation.INVOKE_TYPE_SYSTEM_SUMMARY:           trust.PERMISSION_MODEL,
+	dify_invocation.INVOKE_TYPE_NODE_PARAMETER_EXTRACTOR: trust.PERMISSION_NODE,
+	dify_invocation.INVOKE_TYPE_NODE_QUESTION_CLASSIFIER: trust.PERMISSION_NODE,
+	dify_invocation.INVOKE_TYPE_APP:                      trust.PERMISSION_APP,
+	dify_invocation.INVOKE_TYPE_FETCH_APP:                trust.PERMISSION_APP,
+	dify_invocation.INVOKE_TYPE_WORKFLOW_TOOL:            trust.PERMISSION_APP,
+	dify_invocation.INVOKE_TYPE_STORAGE:                  trust.PERMISSION_STORAGE,
+}
+
+// checkConsent rejects invocations relying on a permission the tenant has not consented to,
+// permissions added by an upgrade stay unusable until they are consented
+func checkConsent(session *session_manager.Session, requestHandle *BackwardsInvocation) error {
+	permission, ok := consentMapping[requestHandle.Type()]
+	if !ok {
+		return nil
+	}
+
+	consent, err := helper.GetPluginPermissionConsent(session.TenantID, session.PluginUniqueIdentifier.PluginID())
+	if err != nil {
+		return fmt.Errorf("failed to fetch permission consent: %s", err.Error())
+	}
+
+	if !consent.Allows(permission) {
+		return fmt.Errorf("permission denied, %s access has not been consented by the workspace", permission)
+	}
+
+	return nil
+}
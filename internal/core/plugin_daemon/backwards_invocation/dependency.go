@@ -0,0 +1,46 @@
+package backwards_invocation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models/curd"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/mapping"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+const (
+	// DEPENDENCY_OBSERVE_INTERVAL limits how often the same dependency is written to db by a node
+	DEPENDENCY_OBSERVE_INTERVAL = 10 * time.Minute
+)
+
+var observedDependencies mapping.Map[string, time.Time]
+
+// observeDependency records the provider a plugin relies on, it's used to build the dependency graph
+func observeDependency(session *session_manager.Session, requestHandle *BackwardsInvocation, request map[string]any) {
+	provider, _ := request["provider"].(string)
+	if provider == "" || session.TenantID == "" || db.DifyPluginDB == nil {
+		return
+	}
+
+	pluginID := session.PluginUniqueIdentifier.PluginID()
+	invokeType := string(requestHandle.Type())
+	key := fmt.Sprintf("%s:%s:%s:%s", session.TenantID, pluginID, invokeType, provider)
+
+	now := time.Now()
+	if last, ok := observedDependencies.Load(key); ok && now.Sub(last) < DEPENDENCY_OBSERVE_INTERVAL {
+		return
+	}
+	observedDependencies.Store(key, now)
+
+	routine.Submit(map[string]string{
+		"module":   "plugin_daemon",
+		"function": "observeDependency",
+	}, func() {
+		if err := curd.ObservePluginDependency(session.TenantID, pluginID, invokeType, provider, now); err != nil {
+			session.Logger().Error("failed to record dependency of plugin %s on %s: %s", pluginID, provider, err.Error())
+		}
+	})
+}
@@ -1,17 +1,28 @@
 package backwards_invocation
 
 import (
+	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"strings"
+	"time"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/core/anomaly"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/invocation_cache"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/persistence"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/model_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // returns error only if payload is not correct
@@ -55,11 +66,38 @@ func InvokeDify(
 		return nil
 	}
 
+	if err := checkConsent(session, requestHandle); err != nil {
+		requestHandle.WriteError(err)
+		requestHandle.EndResponse()
+		return nil
+	}
+
+	anomaly.RecordBackwardsInvocation(
+		session.PluginUniqueIdentifier.PluginID(),
+		string(requestHandle.Type()),
+		len(data),
+	)
+	observeDependency(session, requestHandle, request)
+
+	ctx, span := tracing.Start(
+		tracing.Extract(context.Background(), session.TraceContext),
+		fmt.Sprintf("backwards_invocation %s", requestHandle.Type()),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("backwards_request_id", requestHandle.GetID()),
+			attribute.String("tenant_id", session.TenantID),
+		),
+	)
+	if traceable, ok := requestHandle.backwardsInvocation.(dify_invocation.TraceableBackwardsInvocation); ok {
+		requestHandle.backwardsInvocation = traceable.WithTraceContext(tracing.Inject(ctx))
+	}
+
 	// dispatch invocation task
 	routine.Submit(map[string]string{
 		"module":   "plugin_daemon",
 		"function": "InvokeDify",
 	}, func() {
+		defer span.End()
 		dispatchDifyInvocationTask(requestHandle)
 		defer requestHandle.EndResponse()
 	})
@@ -159,6 +197,12 @@ var (
 			},
 			"error": "permission denied, you need to enable llm access in plugin manifest",
 		},
+		dify_invocation.INVOKE_TYPE_WORKFLOW_TOOL: {
+			"func": func(declaration *plugin_entities.PluginDeclaration) bool {
+				return declaration.Resource.Permission.AllowInvokeApp()
+			},
+			"error": "permission denied, you need to enable app access in plugin manifest",
+		},
 	}
 )
 
@@ -237,6 +281,9 @@ var (
 		dify_invocation.INVOKE_TYPE_APP: func(handle *BackwardsInvocation) {
 			genericDispatchTask(handle, executeDifyInvocationAppTask)
 		},
+		dify_invocation.INVOKE_TYPE_WORKFLOW_TOOL: func(handle *BackwardsInvocation) {
+			genericDispatchTask(handle, executeDifyInvocationWorkflowToolTask)
+		},
 		dify_invocation.INVOKE_TYPE_NODE_PARAMETER_EXTRACTOR: func(handle *BackwardsInvocation) {
 			genericDispatchTask(handle, executeDifyInvocationParameterExtractor)
 		},
@@ -324,16 +371,45 @@ func executeDifyInvocationToolTask(
 	}
 }
 
+// invocationCacheKey returns the key the response of request is cached with, false if the
+// daemon disables the cache or the plugin did not opt in
+func invocationCacheKey(handle *BackwardsInvocation, kind string, request any) (string, bool) {
+	if !invocation_cache.Enabled() || handle.session == nil || handle.session.Declaration == nil {
+		return "", false
+	}
+	if !handle.session.Declaration.Resource.ModelCache {
+		return "", false
+	}
+
+	key, err := invocation_cache.Key(kind, handle.session.TenantID, handle.session.PluginUniqueIdentifier.PluginID(), request)
+	if err != nil {
+		log.Warn("failed to build invocation cache key: %s", err.Error())
+		return "", false
+	}
+	return key, true
+}
+
 func executeDifyInvocationLLMTask(
 	handle *BackwardsInvocation,
 	request *dify_invocation.InvokeLLMRequest,
 ) {
+	cacheKey, cached := invocationCacheKey(handle, string(dify_invocation.INVOKE_TYPE_LLM), request)
+	if cached {
+		if chunks, ok := invocation_cache.Get[[]model_entities.LLMResultChunk](handle.session.PluginUniqueIdentifier.PluginID(), cacheKey); ok {
+			for _, chunk := range *chunks {
+				handle.WriteResponse("stream", chunk)
+			}
+			return
+		}
+	}
+
 	response, err := handle.backwardsInvocation.InvokeLLM(request)
 	if err != nil {
 		handle.WriteError(fmt.Errorf("invoke llm model failed: %s", err.Error()))
 		return
 	}
 
+	chunks := []model_entities.LLMResultChunk{}
 	for response.Next() {
 		value, err := response.Read()
 		if err != nil {
@@ -341,8 +417,18 @@ func executeDifyInvocationLLMTask(
 			return
 		}
 
+		if cached {
+			chunks = append(chunks, value)
+		}
 		handle.WriteResponse("stream", value)
 	}
+
+	// only complete responses are cached
+	if cached {
+		if err := invocation_cache.Store(cacheKey, chunks); err != nil {
+			log.Warn("failed to cache llm response: %s", err.Error())
+		}
+	}
 }
 
 func executeDifyInvocationLLMStructuredOutputTask(
@@ -369,12 +455,26 @@ func executeDifyInvocationTextEmbeddingTask(
 	handle *BackwardsInvocation,
 	request *dify_invocation.InvokeTextEmbeddingRequest,
 ) {
+	cacheKey, cached := invocationCacheKey(handle, string(dify_invocation.INVOKE_TYPE_TEXT_EMBEDDING), request)
+	if cached {
+		if response, ok := invocation_cache.Get[model_entities.TextEmbeddingResult](handle.session.PluginUniqueIdentifier.PluginID(), cacheKey); ok {
+			handle.WriteResponse("struct", response)
+			return
+		}
+	}
+
 	response, err := handle.backwardsInvocation.InvokeTextEmbedding(request)
 	if err != nil {
 		handle.WriteError(fmt.Errorf("invoke text-embedding model failed: %s", err.Error()))
 		return
 	}
 
+	if cached {
+		if err := invocation_cache.Store(cacheKey, response); err != nil {
+			log.Warn("failed to cache text-embedding response: %s", err.Error())
+		}
+	}
+
 	handle.WriteResponse("struct", response)
 }
 
@@ -467,6 +567,31 @@ func executeDifyInvocationAppTask(
 	}
 }
 
+func executeDifyInvocationWorkflowToolTask(
+	handle *BackwardsInvocation,
+	request *dify_invocation.InvokeWorkflowToolRequest,
+) {
+	response, err := handle.backwardsInvocation.InvokeWorkflowTool(request)
+	if err != nil {
+		handle.WriteError(fmt.Errorf("invoke workflow tool failed: %s", err.Error()))
+		return
+	}
+
+	for response.Next() {
+		value, err := response.Read()
+		if err != nil {
+			handle.WriteError(fmt.Errorf("read workflow tool failed: %s", err.Error()))
+			return
+		}
+
+		if !request.Forwards(value) {
+			continue
+		}
+
+		handle.WriteResponse("stream", value)
+	}
+}
+
 func executeDifyInvocationParameterExtractor(
 	handle *BackwardsInvocation,
 	request *dify_invocation.InvokeParameterExtractorRequest,
@@ -493,6 +618,28 @@ func executeDifyInvocationQuestionClassifier(
 	handle.WriteResponse("struct", response)
 }
 
+// storageMaxSize returns the storage size declared by the plugin, -1 if it declares none
+func storageMaxSize(handle *BackwardsInvocation) (int64, error) {
+	declaration := handle.session.Declaration
+	if declaration == nil {
+		return 0, fmt.Errorf("declaration not found")
+	}
+
+	resource := declaration.Resource.Permission
+	if resource == nil {
+		return 0, fmt.Errorf("resource not found")
+	}
+
+	maxStorageSize := int64(-1)
+
+	storage := resource.Storage
+	if storage != nil {
+		maxStorageSize = int64(storage.Size)
+	}
+
+	return maxStorageSize, nil
+}
+
 func executeDifyInvocationStorageTask(
 	handle *BackwardsInvocation,
 	request *dify_invocation.InvokeStorageRequest,
@@ -502,8 +649,8 @@ func executeDifyInvocationStorageTask(
 		return
 	}
 
-	persistence := persistence.GetPersistence()
-	if persistence == nil {
+	store := persistence.GetPersistence()
+	if store == nil {
 		handle.WriteError(fmt.Errorf("persistence not found"))
 		return
 	}
@@ -517,48 +664,39 @@ func executeDifyInvocationStorageTask(
 	pluginId := handle.session.PluginUniqueIdentifier
 
 	if request.Opt == dify_invocation.STORAGE_OPT_GET {
-		data, err := persistence.Load(tenantId, pluginId.PluginID(), request.Key)
+		reader, err := store.LoadStream(tenantId, pluginId.PluginID(), request.Key)
 		if err != nil {
 			handle.WriteError(errors.New("load data failed, please check if the key is correct or you have not set it"))
 			return
 		}
+		defer reader.Close()
+
+		// encode while reading, the raw value is never held next to its hex form
+		data := strings.Builder{}
+		if _, err := io.Copy(hex.NewEncoder(&data), reader); err != nil {
+			handle.WriteError(fmt.Errorf("load data failed: %s", err.Error()))
+			return
+		}
 
 		handle.WriteResponse("struct", map[string]any{
-			"data": hex.EncodeToString(data),
+			"data": data.String(),
 		})
 	} else if request.Opt == dify_invocation.STORAGE_OPT_SET {
-		data, err := hex.DecodeString(request.Value)
+		maxStorageSize, err := storageMaxSize(handle)
 		if err != nil {
-			handle.WriteError(fmt.Errorf("decode data failed: %s", err.Error()))
+			handle.WriteError(err)
 			return
 		}
 
-		session := handle.session
-		if session == nil {
-			handle.WriteError(fmt.Errorf("session not found"))
-			return
-		}
-
-		declaration := session.Declaration
-		if declaration == nil {
-			handle.WriteError(fmt.Errorf("declaration not found"))
-			return
-		}
-
-		resource := declaration.Resource.Permission
-		if resource == nil {
-			handle.WriteError(fmt.Errorf("resource not found"))
-			return
-		}
-
-		maxStorageSize := int64(-1)
-
-		storage := resource.Storage
-		if storage != nil {
-			maxStorageSize = int64(storage.Size)
-		}
-
-		if err := persistence.Save(tenantId, pluginId.PluginID(), maxStorageSize, request.Key, data); err != nil {
+		// decode while writing, the value goes to the storage as it's decoded
+		ttl := time.Duration(request.TTL) * time.Second
+		data := hex.NewDecoder(strings.NewReader(request.Value))
+		if err := store.SaveStreamWithTTL(tenantId, pluginId.PluginID(), maxStorageSize, request.Key, data, ttl); err != nil {
+			var invalid hex.InvalidByteError
+			if errors.As(err, &invalid) || errors.Is(err, io.ErrUnexpectedEOF) {
+				handle.WriteError(fmt.Errorf("decode data failed: %s", err.Error()))
+				return
+			}
 			handle.WriteError(fmt.Errorf("save data failed: %s", err.Error()))
 			return
 		}
@@ -567,7 +705,7 @@ func executeDifyInvocationStorageTask(
 			"data": "ok",
 		})
 	} else if request.Opt == dify_invocation.STORAGE_OPT_DEL {
-		deletedNum, err := persistence.Delete(tenantId, pluginId.PluginID(), request.Key)
+		deletedNum, err := store.Delete(tenantId, pluginId.PluginID(), request.Key)
 		if err != nil {
 			handle.WriteError(fmt.Errorf("delete data failed: %s", err.Error()))
 			return
@@ -578,7 +716,7 @@ func executeDifyInvocationStorageTask(
 			"deleted_num": deletedNum,
 		})
 	} else if request.Opt == dify_invocation.STORAGE_OPT_EXIST {
-		existNum, err := persistence.Exist(tenantId, pluginId.PluginID(), request.Key)
+		existNum, err := store.Exist(tenantId, pluginId.PluginID(), request.Key)
 		if err != nil {
 			handle.WriteError(fmt.Errorf("exist data failed: %s", err.Error()))
 			return
@@ -589,6 +727,63 @@ func executeDifyInvocationStorageTask(
 			"data":      isExist,
 			"exist_num": existNum,
 		})
+	} else if request.Opt == dify_invocation.STORAGE_OPT_BATCH_GET {
+		items, err := store.LoadBatch(tenantId, pluginId.PluginID(), request.Keys)
+		if err != nil {
+			handle.WriteError(fmt.Errorf("batch load data failed: %s", err.Error()))
+			return
+		}
+
+		// missing keys are left out
+		data := make(map[string]string, len(items))
+		for key, value := range items {
+			data[key] = hex.EncodeToString(value)
+		}
+
+		handle.WriteResponse("struct", map[string]any{
+			"data": data,
+		})
+	} else if request.Opt == dify_invocation.STORAGE_OPT_BATCH_SET {
+		items := make([]persistence.BatchItem, 0, len(request.Items))
+		for _, item := range request.Items {
+			data, err := hex.DecodeString(item.Value)
+			if err != nil {
+				handle.WriteError(fmt.Errorf("decode data of %s failed: %s", item.Key, err.Error()))
+				return
+			}
+			items = append(items, persistence.BatchItem{
+				Key:  item.Key,
+				Data: data,
+				TTL:  time.Duration(item.TTL) * time.Second,
+			})
+		}
+
+		maxStorageSize, err := storageMaxSize(handle)
+		if err != nil {
+			handle.WriteError(err)
+			return
+		}
+
+		if err := store.SaveBatch(tenantId, pluginId.PluginID(), maxStorageSize, items); err != nil {
+			handle.WriteError(fmt.Errorf("batch save data failed: %s", err.Error()))
+			return
+		}
+
+		handle.WriteResponse("struct", map[string]any{
+			"data":      "ok",
+			"saved_num": len(items),
+		})
+	} else if request.Opt == dify_invocation.STORAGE_OPT_BATCH_DEL {
+		deletedNum, err := store.DeleteBatch(tenantId, pluginId.PluginID(), request.Keys)
+		if err != nil {
+			handle.WriteError(fmt.Errorf("batch delete data failed: %s", err.Error()))
+			return
+		}
+
+		handle.WriteResponse("struct", map[string]any{
+			"data":        "ok",
+			"deleted_num": deletedNum,
+		})
 	}
 }
 
@@ -117,6 +117,8 @@ func (h *ServerlessTransactionHandler) Handle(
 			log.Warn("invoke dify failed, received errors: %s", err)
 		},
 		func(message string) {}, //log
+		nil,
+		nil,
 	)
 
 	select {
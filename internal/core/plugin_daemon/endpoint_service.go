@@ -73,8 +73,13 @@ func InvokeEndpoint(
 						return
 					}
 
+					// chunks without result carry nothing once the body started
+					if chunk.Result == nil {
+						continue
+					}
 					dehexed, err := hex.DecodeString(*chunk.Result)
 					if err != nil {
+						response.WriteError(err)
 						return
 					}
 					response.Write(dehexed)
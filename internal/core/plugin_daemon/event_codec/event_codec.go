@@ -0,0 +1,187 @@
+// Package event_codec implements the encodings plugins may negotiate for the event protocol instead of
+// json lines, see PluginHandshakeEvent.Encodings.
+//
+// Events are still handled as json inside the daemon, a codec converts them on the way in and out of
+// the plugin so that the plugin saves on serialization and binary values are not base64 encoded on
+// the wire. Events of a binary encoding are framed as
+//
+//	| payload length uint32 | payload |
+package event_codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/ugorji/go/codec"
+)
+
+const (
+	ENCODING_JSON    = "json"
+	ENCODING_MSGPACK = "msgpack"
+	ENCODING_CBOR    = "cbor"
+
+	FRAME_HEADER_SIZE = 4
+)
+
+type Codec interface {
+	Name() string
+	// Encode converts a json event to the encoding
+	Encode(event []byte) ([]byte, error)
+	// Decode converts an event of the encoding to json, binary values become base64 strings as
+	// []byte does in json
+	Decode(data []byte) ([]byte, error)
+}
+
+var codecs = map[string]Codec{
+	ENCODING_MSGPACK: newMsgpackCodec(),
+	ENCODING_CBOR:    newCBORCodec(),
+}
+
+// Get returns the codec of a binary encoding, json has none
+func Get(name string) (Codec, bool) {
+	c, ok := codecs[strings.ToLower(name)]
+	return c, ok
+}
+
+// Negotiate picks the first encoding the plugin prefers that is accepted, nil means json lines
+func Negotiate(preferred []string, accepted []string) Codec {
+	for _, name := range preferred {
+		for _, a := range accepted {
+			if strings.EqualFold(name, a) {
+				if c, ok := Get(name); ok {
+					return c
+				}
+			}
+		}
+		if strings.EqualFold(name, ENCODING_JSON) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// AppendFrame appends a frame of payload to dst
+func AppendFrame(dst []byte, payload []byte) []byte {
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(payload)))
+	return append(dst, payload...)
+}
+
+// ScanFrames is a bufio.SplitFunc returning the payload of each frame
+func ScanFrames(data []byte, atEOF bool) (int, []byte, error) {
+	if len(data) < FRAME_HEADER_SIZE || len(data) < FRAME_HEADER_SIZE+int(binary.BigEndian.Uint32(data)) {
+		if atEOF && len(data) > 0 {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, nil
+	}
+	size := int(binary.BigEndian.Uint32(data))
+	return FRAME_HEADER_SIZE + size, data[FRAME_HEADER_SIZE : FRAME_HEADER_SIZE+size], nil
+}
+
+// decodeJSON keeps integers, json.Number would be encoded as a string by the codecs
+func decodeJSON(event []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(event))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return numbers(value), nil
+}
+
+func numbers(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for key, item := range v {
+			v[key] = numbers(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = numbers(item)
+		}
+	}
+	return value
+}
+
+type msgpackCodec struct {
+	handle *codec.MsgpackHandle
+}
+
+func newMsgpackCodec() *msgpackCodec {
+	handle := &codec.MsgpackHandle{}
+	handle.MapType = reflect.TypeOf(map[string]any{})
+	// str and bin are told apart, bin is what the plugin sends for bytes
+	handle.WriteExt = true
+	handle.RawToString = true
+	return &msgpackCodec{handle: handle}
+}
+
+func (c *msgpackCodec) Name() string {
+	return ENCODING_MSGPACK
+}
+
+func (c *msgpackCodec) Encode(event []byte) ([]byte, error) {
+	value, err := decodeJSON(event)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	err = codec.NewEncoderBytes(&data, c.handle).Encode(value)
+	return data, err
+}
+
+func (c *msgpackCodec) Decode(data []byte) ([]byte, error) {
+	var value any
+	if err := codec.NewDecoderBytes(data, c.handle).Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+type cborCodec struct {
+	encoder cbor.EncMode
+	decoder cbor.DecMode
+}
+
+func newCBORCodec() *cborCodec {
+	encoder, err := cbor.EncOptions{}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	decoder, err := cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]any{})}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return &cborCodec{encoder: encoder, decoder: decoder}
+}
+
+func (c *cborCodec) Name() string {
+	return ENCODING_CBOR
+}
+
+func (c *cborCodec) Encode(event []byte) ([]byte, error) {
+	value, err := decodeJSON(event)
+	if err != nil {
+		return nil, err
+	}
+	return c.encoder.Marshal(value)
+}
+
+func (c *cborCodec) Decode(data []byte) ([]byte, error) {
+	var value any
+	if err := c.decoder.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
@@ -0,0 +1,234 @@
+package event_codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/ugorji/go/codec"
+)
+
+func sampleEvent() []byte {
+	data, _ := json.Marshal(plugin_entities.PluginUniversalEvent{
+		SessionId: "4f7a6c2e-5a8e-4a50-9d12-0f1c8d7b2a11",
+		Event:     plugin_entities.PLUGIN_EVENT_SESSION,
+		Data: json.RawMessage(`{"type":"stream","data":{"type":"text","message":{"text":"` +
+			strings.Repeat("dify plugin ", 64) + `"},"meta":{"tokens":1234567890123,"score":0.5,"done":false}}}`),
+	})
+	return data
+}
+
+func TestRoundTrip(t *testing.T) {
+	event := sampleEvent()
+	for _, name := range []string{ENCODING_MSGPACK, ENCODING_CBOR} {
+		c, ok := Get(name)
+		if !ok {
+			t.Fatalf("codec %s not found", name)
+		}
+		encoded, err := c.Encode(event)
+		if err != nil {
+			t.Fatalf("%s: failed to encode: %v", name, err)
+		}
+		decoded, err := c.Decode(encoded)
+		if err != nil {
+			t.Fatalf("%s: failed to decode: %v", name, err)
+		}
+
+		var expected, actual any
+		json.Unmarshal(event, &expected)
+		json.Unmarshal(decoded, &actual)
+		expectedJSON, _ := json.Marshal(expected)
+		actualJSON, _ := json.Marshal(actual)
+		if !bytes.Equal(expectedJSON, actualJSON) {
+			t.Fatalf("%s: round trip changed the event\n%s\n%s", name, expectedJSON, actualJSON)
+		}
+		if !bytes.Contains(decoded, []byte("1234567890123")) {
+			t.Fatalf("%s: integers must be kept, got %s", name, decoded)
+		}
+	}
+}
+
+func TestDecodeBinary(t *testing.T) {
+	// plugins send bytes as bin, the daemon sees them as base64 like []byte in json
+	c, _ := Get(ENCODING_CBOR)
+	encoded, err := c.(*cborCodec).encoder.Marshal(map[string]any{"blob": []byte{0, 1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := c.Decode(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"blob":"` + base64.StdEncoding.EncodeToString([]byte{0, 1, 2}) + `"}`
+	if string(decoded) != expected {
+		t.Fatalf("expected %s, got %s", expected, decoded)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	accepted := []string{ENCODING_MSGPACK, ENCODING_CBOR}
+	if c := Negotiate([]string{"cbor", "msgpack"}, accepted); c == nil || c.Name() != ENCODING_CBOR {
+		t.Fatalf("expected cbor, got %v", c)
+	}
+	if c := Negotiate([]string{"json", "msgpack"}, accepted); c != nil {
+		t.Fatalf("expected json to be preferred, got %s", c.Name())
+	}
+	if c := Negotiate([]string{"protobuf", "msgpack"}, accepted); c == nil || c.Name() != ENCODING_MSGPACK {
+		t.Fatalf("expected unknown encodings to be skipped, got %v", c)
+	}
+	if c := Negotiate([]string{"msgpack"}, nil); c != nil {
+		t.Fatalf("expected encodings not accepted to fall back to json, got %s", c.Name())
+	}
+}
+
+func TestScanFrames(t *testing.T) {
+	stream := AppendFrame(AppendFrame(nil, []byte("first")), []byte(""))
+	stream = AppendFrame(stream, []byte("third"))
+	// a frame cut short by the plugin exiting
+	stream = append(stream, 0, 0, 0, 9, 'x')
+
+	scanner := bufio.NewScanner(bytes.NewReader(stream))
+	scanner.Split(ScanFrames)
+	frames := []string{}
+	for scanner.Scan() {
+		frames = append(frames, scanner.Text())
+	}
+	if strings.Join(frames, ",") != "first,,third" {
+		t.Fatalf("unexpected frames %q", frames)
+	}
+	if scanner.Err() == nil {
+		t.Fatal("expected the truncated frame to be reported")
+	}
+}
+
+func benchmarkEncode(b *testing.B, marshal func(any) ([]byte, error)) {
+	var value any
+	json.Unmarshal(sampleEvent(), &value)
+	value.(map[string]any)["data"].(map[string]any)["blob"] = bytes.Repeat([]byte{0xff, 0x00}, 32*1024)
+
+	var size int
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data, err := marshal(value)
+		if err != nil {
+			b.Fatal(err)
+		}
+		size = len(data)
+	}
+	b.ReportMetric(float64(size), "bytes/event")
+}
+
+// the plugin side encodes native values, bytes are base64 encoded by json only
+func BenchmarkEncodeJSON(b *testing.B) {
+	benchmarkEncode(b, json.Marshal)
+}
+
+func BenchmarkEncodeMsgpack(b *testing.B) {
+	c := newMsgpackCodec()
+	benchmarkEncode(b, func(v any) ([]byte, error) {
+		var data []byte
+		err := codec.NewEncoderBytes(&data, c.handle).Encode(v)
+		return data, err
+	})
+}
+
+func BenchmarkEncodeCBOR(b *testing.B) {
+	benchmarkEncode(b, newCBORCodec().encoder.Marshal)
+}
+
+func benchmarkDecode(b *testing.B, c Codec) {
+	event := sampleEvent()
+	if c != nil {
+		event, _ = c.Encode(event)
+	}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(event)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if c == nil {
+			var value any
+			if err := json.Unmarshal(event, &value); err != nil {
+				b.Fatal(err)
+			}
+			continue
+		}
+		if _, err := c.Decode(event); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// decoding includes converting to json for the daemon
+func BenchmarkDecodeJSON(b *testing.B) {
+	benchmarkDecode(b, nil)
+}
+
+func BenchmarkDecodeMsgpack(b *testing.B) {
+	benchmarkDecode(b, newMsgpackCodec())
+}
+
+func BenchmarkDecodeCBOR(b *testing.B) {
+	benchmarkDecode(b, newCBORCodec())
+}
+
+// the daemon converts the json events it sends to the encoding, json lines are written as they are
+func benchmarkCodecEncode(b *testing.B, c Codec) {
+	event := sampleEvent()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(event)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Encode(event); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCodecEncodeMsgpack(b *testing.B) {
+	benchmarkCodecEncode(b, newMsgpackCodec())
+}
+
+func BenchmarkCodecEncodeCBOR(b *testing.B) {
+	benchmarkCodecEncode(b, newCBORCodec())
+}
+
+// a session costs the daemon an encode of the request and a decode of each response
+func benchmarkCodecRoundTrip(b *testing.B, c Codec) {
+	event := sampleEvent()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(event)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if c == nil {
+			var value any
+			if err := json.Unmarshal(event, &value); err != nil {
+				b.Fatal(err)
+			}
+			continue
+		}
+		encoded, err := c.Encode(event)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := c.Decode(encoded); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCodecRoundTripJSON(b *testing.B) {
+	benchmarkCodecRoundTrip(b, nil)
+}
+
+func BenchmarkCodecRoundTripMsgpack(b *testing.B) {
+	benchmarkCodecRoundTrip(b, newMsgpackCodec())
+}
+
+func BenchmarkCodecRoundTripCBOR(b *testing.B) {
+	benchmarkCodecRoundTrip(b, newCBORCodec())
+}
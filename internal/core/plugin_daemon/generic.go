@@ -1,16 +1,24 @@
 package plugin_daemon
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/core/anomaly"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/invocation_stats"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/backwards_invocation"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/backwards_invocation/transaction"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/generic_invoke"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_rollout"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func GenericInvokePlugin[Req any, Rsp any](
@@ -23,6 +31,26 @@ func GenericInvokePlugin[Req any, Rsp any](
 		return nil, errors.New("plugin runtime not found")
 	}
 
+	ctx, span := tracing.Start(
+		tracing.Extract(context.Background(), session.TraceContext),
+		fmt.Sprintf("plugin.invoke %s", session.Action),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("session_id", session.ID),
+			attribute.String("tenant_id", session.TenantID),
+			attribute.String("plugin_unique_identifier", session.PluginUniqueIdentifier.String()),
+			attribute.String("runtime_type", string(runtime.Type())),
+			attribute.String("invoke_from", string(session.InvokeFrom)),
+		),
+	)
+	// plugin and backwards invocations are children of the invoke span
+	session.TraceContext = tracing.Inject(ctx)
+
+	pluginID := session.PluginUniqueIdentifier.PluginID()
+	recordError := func() {
+		invocation_stats.RecordError(pluginID)
+		plugin_rollout.RecordError(session.PluginUniqueIdentifier)
+	}
 	response := stream.NewStream[Rsp](response_buffer_size)
 	listener := runtime.Listen(session.ID)
 	listener.Listen(func(chunk plugin_entities.SessionMessage) {
@@ -30,6 +58,7 @@ func GenericInvokePlugin[Req any, Rsp any](
 		case plugin_entities.SESSION_MESSAGE_TYPE_STREAM:
 			chunk, err := parser.UnmarshalJsonBytes[Rsp](chunk.Data)
 			if err != nil {
+				recordError()
 				response.WriteError(errors.New(parser.MarshalJson(map[string]string{
 					"error_type": "unmarshal_error",
 					"message":    fmt.Sprintf("unmarshal json failed: %s", err.Error()),
@@ -41,6 +70,7 @@ func GenericInvokePlugin[Req any, Rsp any](
 			}
 		case plugin_entities.SESSION_MESSAGE_TYPE_INVOKE:
 			if runtime.Type() == plugin_entities.PLUGIN_RUNTIME_TYPE_SERVERLESS {
+				recordError()
 				response.WriteError(errors.New(parser.MarshalJson(map[string]string{
 					"error_type": "serverless_event_not_supported",
 					"message":    "serverless event is not supported by full duplex",
@@ -55,6 +85,7 @@ func GenericInvokePlugin[Req any, Rsp any](
 				transaction.NewFullDuplexEventWriter(session),
 				chunk.Data,
 			); err != nil {
+				recordError()
 				response.WriteError(errors.New(parser.MarshalJson(map[string]string{
 					"error_type": "invoke_dify_error",
 					"message":    fmt.Sprintf("invoke dify failed: %s", err.Error()),
@@ -63,15 +94,20 @@ func GenericInvokePlugin[Req any, Rsp any](
 				return
 			}
 		case plugin_entities.SESSION_MESSAGE_TYPE_END:
+			session.PluginEnded()
 			response.Close()
 		case plugin_entities.SESSION_MESSAGE_TYPE_ERROR:
+			session.PluginEnded()
 			e, err := parser.UnmarshalJsonBytes[plugin_entities.ErrorResponse](chunk.Data)
 			if err != nil {
 				break
 			}
+			span.SetStatus(codes.Error, e.Error())
+			recordError()
 			response.WriteError(errors.New(e.Error()))
 			response.Close()
 		default:
+			recordError()
 			response.WriteError(errors.New(parser.MarshalJson(map[string]string{
 				"error_type": "unknown_stream_message_type",
 				"message":    "unknown stream message type: " + string(chunk.Type),
@@ -80,11 +116,24 @@ func GenericInvokePlugin[Req any, Rsp any](
 		}
 	})
 
-	// close the listener if stream outside is closed due to close of connection
+	// close the listener if stream outside is closed due to close of connection, the plugin is
+	// asked to stop if it's still generating
 	response.OnClose(func() {
+		session.Abort("stream closed before the plugin ended")
 		listener.Close()
+		span.End()
 	})
 
+	invocation_stats.RecordInvocation(pluginID)
+	plugin_rollout.RecordInvocation(session.PluginUniqueIdentifier)
+	invocation_stats.RecordTenantInvocation(session.TenantID, pluginID)
+	if anomaly.Enabled() {
+		anomaly.RecordInvocation(
+			pluginID,
+			len(parser.MarshalJsonBytes(request)),
+		)
+	}
+
 	session.Write(
 		session_manager.PLUGIN_IN_STREAM_EVENT_REQUEST,
 		session.Action,
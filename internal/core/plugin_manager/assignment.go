@@ -0,0 +1,68 @@
+package plugin_manager
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/local_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// LocalPluginAssignment decides which local plugins run on the current node, invocations of
+// other plugins are proxied to the nodes running them
+type LocalPluginAssignment interface {
+	IsPluginAssignedToCurrentNode(identity plugin_entities.PluginUniqueIdentifier) bool
+	// IsPluginRunningOnAssignedNodes reports whether a plugin no longer assigned to the current
+	// node has been taken over, it keeps running here until then
+	IsPluginRunningOnAssignedNodes(identity plugin_entities.PluginUniqueIdentifier) bool
+}
+
+// SetLocalPluginAssignment limits local plugins to those assigned to the current node,
+// every local plugin is launched on every node without it
+func (p *PluginManager) SetLocalPluginAssignment(assignment LocalPluginAssignment) {
+	p.localPluginAssignment = assignment
+}
+
+func (p *PluginManager) isLocalPluginAssigned(identity plugin_entities.PluginUniqueIdentifier) bool {
+	return p.localPluginAssignment == nil || p.localPluginAssignment.IsPluginAssignedToCurrentNode(identity)
+}
+
+// RebalanceLocalPlugins launches the local plugins newly assigned to the current node and stops
+// those taken over by other nodes, it's called once the members of the cluster changed
+func (p *PluginManager) RebalanceLocalPlugins() {
+	if p.localPluginAssignment == nil {
+		return
+	}
+
+	p.handleNewLocalPlugins(p.config)
+	p.releaseUnassignedLocalPlugins()
+}
+
+// releaseUnassignedLocalPlugins stops the local plugins running on their assigned nodes
+func (p *PluginManager) releaseUnassignedLocalPlugins() {
+	if p.localPluginAssignment == nil {
+		return
+	}
+
+	p.m.Range(func(key string, value plugin_entities.PluginLifetime) bool {
+		runtime, ok := value.(*local_runtime.LocalPluginRuntime)
+		if !ok {
+			return true
+		}
+
+		identity, err := runtime.Identity()
+		if err != nil {
+			log.Error("get plugin identity failed: %s", err.Error())
+			return true
+		}
+
+		if p.localPluginAssignment.IsPluginAssignedToCurrentNode(identity) {
+			return true
+		}
+
+		if p.localPluginAssignment.IsPluginRunningOnAssignedNodes(identity) {
+			log.Info("plugin %s has been taken over by its assigned nodes, stopping it", identity.String())
+			runtime.Stop()
+		}
+
+		return true
+	})
+}
@@ -0,0 +1,581 @@
+// Package blob_channel carries binary payloads between the daemon and a local plugin beside the json
+// lines on stdio, so that images and audio are not base64 encoded into a single line.
+//
+// The plugin reads the file descriptors from BLOB_CHANNEL_ENV, the first one is written by the plugin
+// and the second one read by it. Each side starts with a hello frame announcing the window, the bytes
+// of a blob the peer may send before the receiver read them, more are granted with credit frames as
+// the blob is consumed. Json events refer to blobs by id, see Resolve and Offload.
+//
+// Blobs are sent before the events referring to them, a blob nothing refers to yet is failed once it
+// fills the window as the sender would wait for credit forever, larger blobs have to be referred to
+// by an event first. What is buffered of blobs is capped by the memory limit of the channel, the
+// oldest blobs nothing refers to are evicted to stay below it.
+//
+// A frame is encoded as
+//
+//	| type uint8 | id length uint8 | id | payload length uint32 | payload |
+package blob_channel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+const (
+	BLOB_CHANNEL_ENV = "DIFY_PLUGIN_BLOB_CHANNEL_FDS"
+
+	FRAME_TYPE_HELLO  uint8 = 1
+	FRAME_TYPE_DATA   uint8 = 2
+	FRAME_TYPE_END    uint8 = 3
+	FRAME_TYPE_CREDIT uint8 = 4
+	FRAME_TYPE_ABORT  uint8 = 5
+
+	FRAME_MAX_PAYLOAD    = 64 * 1024
+	DEFAULT_WINDOW       = 1024 * 1024
+	DEFAULT_MEMORY_LIMIT = 64 * 1024 * 1024
+
+	// failed blobs are remembered so that events referring to them fail right away, up to this many
+	MAX_FAILED_BLOBS = 1024
+)
+
+var (
+	ErrChannelClosed  = errors.New("blob channel closed")
+	ErrBlobTimeout    = errors.New("timed out waiting for blob")
+	ErrBlobTooLarge   = errors.New("blob exceeds the maximum size")
+	ErrBlobUnreferred = errors.New("blob fills the window before an event refers to it")
+	ErrWindowExceeded = errors.New("blob exceeds the window")
+	ErrBlobEvicted    = errors.New("blob evicted, the memory limit of the channel was reached")
+	ErrMemoryLimit    = errors.New("blobs exceed the memory limit of the channel")
+)
+
+type Channel struct {
+	reader io.ReadCloser
+	writer io.WriteCloser
+
+	writeLock   sync.Mutex
+	window      uint32
+	memoryLimit int64
+
+	lock       sync.Mutex
+	incoming   map[string]*incomingBlob
+	outgoing   map[string]*outgoingBlob
+	peerWindow uint32
+	// bytes buffered of incoming blobs, sequence numbers order blobs by arrival for eviction
+	buffered int64
+	sequence uint64
+	// events waiting for blobs, by session
+	queues map[string]*eventQueue
+
+	ready     chan struct{}
+	readyOnce sync.Once
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// New creates a channel reading frames of the peer from reader and writing frames to writer, window
+// is what the peer may send of each blob ahead of it being read, DEFAULT_WINDOW if zero, memoryLimit
+// caps what is buffered of all blobs, DEFAULT_MEMORY_LIMIT if zero
+func New(reader io.ReadCloser, writer io.WriteCloser, window uint32, memoryLimit int64) *Channel {
+	if window == 0 {
+		window = DEFAULT_WINDOW
+	}
+	if memoryLimit <= 0 {
+		memoryLimit = DEFAULT_MEMORY_LIMIT
+	}
+	return &Channel{
+		reader:      reader,
+		writer:      writer,
+		window:      window,
+		memoryLimit: memoryLimit,
+		incoming:    map[string]*incomingBlob{},
+		outgoing:    map[string]*outgoingBlob{},
+		queues:      map[string]*eventQueue{},
+		ready:       make(chan struct{}),
+		closed:      make(chan struct{}),
+	}
+}
+
+// Ready reports whether the peer said hello, peers that never do don't support the channel
+func (c *Channel) Ready() bool {
+	select {
+	case <-c.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// Start says hello and reads frames until the peer closes the channel
+func (c *Channel) Start() error {
+	defer c.Close()
+
+	// the peer may say hello first, writing must not hold back reading
+	hello := make([]byte, 4)
+	binary.BigEndian.PutUint32(hello, c.window)
+	routine.Submit(map[string]string{
+		"module":   "blob_channel",
+		"function": "Start",
+	}, func() {
+		c.writeFrame(FRAME_TYPE_HELLO, "", hello)
+	})
+
+	header := make([]byte, 2)
+	for {
+		if _, err := io.ReadFull(c.reader, header); err != nil {
+			return ignoreEOF(err)
+		}
+		id := make([]byte, header[1])
+		if _, err := io.ReadFull(c.reader, id); err != nil {
+			return ignoreEOF(err)
+		}
+		length := make([]byte, 4)
+		if _, err := io.ReadFull(c.reader, length); err != nil {
+			return ignoreEOF(err)
+		}
+		size := binary.BigEndian.Uint32(length)
+		if size > FRAME_MAX_PAYLOAD {
+			return fmt.Errorf("frame of %d bytes exceeds %d bytes", size, FRAME_MAX_PAYLOAD)
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(c.reader, payload); err != nil {
+			return ignoreEOF(err)
+		}
+
+		if err := c.handle(header[0], string(id), payload); err != nil {
+			return err
+		}
+	}
+}
+
+func ignoreEOF(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrClosedPipe) {
+		return nil
+	}
+	return err
+}
+
+func (c *Channel) handle(frameType uint8, id string, payload []byte) error {
+	switch frameType {
+	case FRAME_TYPE_HELLO:
+		if len(payload) != 4 {
+			return errors.New("invalid hello frame")
+		}
+		c.lock.Lock()
+		c.peerWindow = binary.BigEndian.Uint32(payload)
+		c.lock.Unlock()
+		c.readyOnce.Do(func() { close(c.ready) })
+	case FRAME_TYPE_DATA:
+		blob := c.incomingBlob(id)
+		aborted, err := c.hold(blob, int64(len(payload)))
+		if err == nil && !blob.append(payload) {
+			// the blob failed already, what it held is given back
+			c.release(blob, int64(len(payload)))
+		}
+		for _, evicted := range aborted {
+			c.writeFrame(FRAME_TYPE_ABORT, evicted.id, []byte(evicted.err.Error()))
+		}
+	case FRAME_TYPE_END:
+		c.incomingBlob(id).end()
+	case FRAME_TYPE_CREDIT:
+		if len(payload) != 4 {
+			return errors.New("invalid credit frame")
+		}
+		c.lock.Lock()
+		blob := c.outgoing[id]
+		c.lock.Unlock()
+		if blob != nil {
+			blob.grant(int64(binary.BigEndian.Uint32(payload)))
+		}
+	case FRAME_TYPE_ABORT:
+		err := fmt.Errorf("blob aborted by peer: %s", payload)
+		c.lock.Lock()
+		incoming, outgoing := c.incoming[id], c.outgoing[id]
+		c.lock.Unlock()
+		if incoming != nil {
+			incoming.fail(err)
+		}
+		if outgoing != nil {
+			outgoing.abort(err)
+		}
+	}
+	return nil
+}
+
+func (c *Channel) writeFrame(frameType uint8, id string, payload []byte) error {
+	frame := make([]byte, 0, 6+len(id)+len(payload))
+	frame = append(frame, frameType, uint8(len(id)))
+	frame = append(frame, id...)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(payload)))
+	frame = append(frame, payload...)
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	_, err := c.writer.Write(frame)
+	return err
+}
+
+// Close fails blobs in flight and closes both ends of the channel
+func (c *Channel) Close() {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.reader.Close()
+		c.writer.Close()
+
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		for _, blob := range c.incoming {
+			blob.fail(ErrChannelClosed)
+		}
+		for _, blob := range c.outgoing {
+			blob.abort(ErrChannelClosed)
+		}
+	})
+}
+
+func (c *Channel) incomingBlob(id string) *incomingBlob {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.incomingBlobLocked(id)
+}
+
+func (c *Channel) incomingBlobLocked(id string) *incomingBlob {
+	blob, ok := c.incoming[id]
+	if !ok {
+		c.sequence++
+		blob = &incomingBlob{channel: c, id: id, sequence: c.sequence}
+		blob.cond = sync.NewCond(&blob.lock)
+		c.incoming[id] = blob
+		select {
+		case <-c.closed:
+			blob.err = ErrChannelClosed
+		default:
+		}
+		c.forgetFailedLocked()
+	}
+	return blob
+}
+
+// claim marks a blob as referred to, it may exceed the window from now on as it is going to be read
+func (c *Channel) claim(id string) *incomingBlob {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	blob := c.incomingBlobLocked(id)
+	blob.claimed = true
+	return blob
+}
+
+// hold accounts for n more bytes buffered of blob, the blob is failed if it doesn't fit the window
+// or the memory limit even after evicting blobs nothing refers to, the blobs failed are returned so
+// that the peer is told once the lock is released
+func (c *Channel) hold(blob *incomingBlob, n int64) ([]*incomingBlob, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	fail := func(err error) ([]*incomingBlob, error) {
+		c.failLocked(blob, err)
+		return []*incomingBlob{blob}, err
+	}
+
+	switch {
+	case blob.claimed && blob.held+n > int64(c.window):
+		// the peer ignored the window
+		return fail(ErrWindowExceeded)
+	case !blob.claimed && blob.held+n >= int64(c.window):
+		return fail(ErrBlobUnreferred)
+	}
+
+	var evicted []*incomingBlob
+	for c.buffered+n > c.memoryLimit {
+		oldest := c.oldestUnclaimedLocked(blob)
+		if oldest == nil {
+			evicted = append(evicted, blob)
+			c.failLocked(blob, ErrMemoryLimit)
+			return evicted, ErrMemoryLimit
+		}
+		c.failLocked(oldest, ErrBlobEvicted)
+		evicted = append(evicted, oldest)
+	}
+
+	blob.held += n
+	c.buffered += n
+	return evicted, nil
+}
+
+// release gives back n bytes blob held
+func (c *Channel) release(blob *incomingBlob, n int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	blob.held -= n
+	c.buffered -= n
+}
+
+// failLocked fails blob and drops what it buffered, even if it was received completely
+func (c *Channel) failLocked(blob *incomingBlob, err error) {
+	blob.lock.Lock()
+	if blob.err == nil {
+		blob.err = err
+	}
+	blob.buffer = bytes.Buffer{}
+	blob.cond.Broadcast()
+	blob.lock.Unlock()
+	c.buffered -= blob.held
+	blob.held = 0
+}
+
+// oldestUnclaimedLocked returns the oldest blob buffering data nothing refers to, except
+func (c *Channel) oldestUnclaimedLocked(except *incomingBlob) *incomingBlob {
+	var oldest *incomingBlob
+	for _, blob := range c.incoming {
+		if blob == except || blob.claimed || blob.held == 0 {
+			continue
+		}
+		if oldest == nil || blob.sequence < oldest.sequence {
+			oldest = blob
+		}
+	}
+	return oldest
+}
+
+// forgetFailedLocked drops the oldest failed blobs nothing refers to beyond MAX_FAILED_BLOBS
+func (c *Channel) forgetFailedLocked() {
+	var failed []*incomingBlob
+	for _, blob := range c.incoming {
+		if !blob.claimed && blob.failed() {
+			failed = append(failed, blob)
+		}
+	}
+	if len(failed) <= MAX_FAILED_BLOBS {
+		return
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].sequence < failed[j].sequence })
+	for _, blob := range failed[:len(failed)-MAX_FAILED_BLOBS] {
+		delete(c.incoming, blob.id)
+	}
+}
+
+// Receive returns the reader of a blob sent by the peer, it may be called before the blob arrives,
+// the reader must be closed
+func (c *Channel) Receive(id string) io.ReadCloser {
+	return c.claim(id)
+}
+
+// ReadAll reads a whole blob, failing if it takes longer than timeout or exceeds maxSize bytes
+func (c *Channel) ReadAll(id string, timeout time.Duration, maxSize int64) ([]byte, error) {
+	blob := c.claim(id)
+	defer blob.Close()
+
+	if timeout > 0 {
+		timer := time.AfterFunc(timeout, func() { blob.fail(ErrBlobTimeout) })
+		defer timer.Stop()
+	}
+
+	var reader io.Reader = blob
+	if maxSize > 0 {
+		reader = io.LimitReader(blob, maxSize+1)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if maxSize > 0 && int64(len(data)) > maxSize {
+		return nil, ErrBlobTooLarge
+	}
+	return data, nil
+}
+
+// Send writes the content of reader as a blob, it blocks while the peer has not granted credit
+func (c *Channel) Send(id string, reader io.Reader) error {
+	if len(id) > 255 {
+		return errors.New("blob id is too long")
+	}
+
+	c.lock.Lock()
+	select {
+	case <-c.closed:
+		c.lock.Unlock()
+		return ErrChannelClosed
+	default:
+	}
+	blob := &outgoingBlob{credit: int64(c.peerWindow)}
+	blob.cond = sync.NewCond(&blob.lock)
+	c.outgoing[id] = blob
+	c.lock.Unlock()
+
+	defer func() {
+		c.lock.Lock()
+		delete(c.outgoing, id)
+		c.lock.Unlock()
+	}()
+
+	buffer := make([]byte, FRAME_MAX_PAYLOAD)
+	for {
+		credit, err := blob.wait()
+		if err != nil {
+			return err
+		}
+		if credit > int64(len(buffer)) {
+			credit = int64(len(buffer))
+		}
+
+		n, readErr := reader.Read(buffer[:credit])
+		if n > 0 {
+			blob.consume(int64(n))
+			if err := c.writeFrame(FRAME_TYPE_DATA, id, buffer[:n]); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			return c.writeFrame(FRAME_TYPE_END, id, nil)
+		}
+		if readErr != nil {
+			c.writeFrame(FRAME_TYPE_ABORT, id, []byte(readErr.Error()))
+			return readErr
+		}
+	}
+}
+
+type incomingBlob struct {
+	channel *Channel
+	id      string
+
+	// guarded by the lock of the channel
+	sequence uint64
+	claimed  bool
+	held     int64
+
+	lock   sync.Mutex
+	cond   *sync.Cond
+	buffer bytes.Buffer
+	ended  bool
+	err    error
+}
+
+// append buffers data, false if the blob failed or ended already
+func (b *incomingBlob) append(data []byte) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.err != nil || b.ended {
+		return false
+	}
+	b.buffer.Write(data)
+	b.cond.Broadcast()
+	return true
+}
+
+func (b *incomingBlob) failed() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.err != nil
+}
+
+// complete reports whether the blob can be read without waiting for the peer
+func (b *incomingBlob) complete() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.ended || b.err != nil
+}
+
+func (b *incomingBlob) end() {
+	b.lock.Lock()
+	b.ended = true
+	b.cond.Broadcast()
+	b.lock.Unlock()
+}
+
+func (b *incomingBlob) fail(err error) {
+	b.lock.Lock()
+	if b.err == nil && !b.ended {
+		b.err = err
+	}
+	b.cond.Broadcast()
+	b.lock.Unlock()
+}
+
+func (b *incomingBlob) Read(p []byte) (int, error) {
+	b.lock.Lock()
+	for b.buffer.Len() == 0 && !b.ended && b.err == nil {
+		b.cond.Wait()
+	}
+	if b.buffer.Len() == 0 {
+		err := b.err
+		b.lock.Unlock()
+		if err == nil {
+			err = io.EOF
+		}
+		return 0, err
+	}
+	n, _ := b.buffer.Read(p)
+	ended := b.ended
+	b.lock.Unlock()
+	b.channel.release(b, int64(n))
+
+	if !ended {
+		credit := make([]byte, 4)
+		binary.BigEndian.PutUint32(credit, uint32(n))
+		b.channel.writeFrame(FRAME_TYPE_CREDIT, b.id, credit)
+	}
+	return n, nil
+}
+
+// Close forgets the blob, a blob not received completely is aborted so that the peer stops sending
+func (b *incomingBlob) Close() error {
+	b.channel.lock.Lock()
+	delete(b.channel.incoming, b.id)
+	b.channel.buffered -= b.held
+	b.held = 0
+	b.channel.lock.Unlock()
+
+	b.lock.Lock()
+	ended := b.ended
+	b.lock.Unlock()
+	if !ended {
+		b.channel.writeFrame(FRAME_TYPE_ABORT, b.id, []byte("closed by receiver"))
+	}
+	return nil
+}
+
+type outgoingBlob struct {
+	lock   sync.Mutex
+	cond   *sync.Cond
+	credit int64
+	err    error
+}
+
+func (b *outgoingBlob) wait() (int64, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for b.credit <= 0 && b.err == nil {
+		b.cond.Wait()
+	}
+	return b.credit, b.err
+}
+
+func (b *outgoingBlob) consume(n int64) {
+	b.lock.Lock()
+	b.credit -= n
+	b.lock.Unlock()
+}
+
+func (b *outgoingBlob) grant(n int64) {
+	b.lock.Lock()
+	b.credit += n
+	b.cond.Broadcast()
+	b.lock.Unlock()
+}
+
+func (b *outgoingBlob) abort(err error) {
+	b.lock.Lock()
+	if b.err == nil {
+		b.err = err
+	}
+	b.cond.Broadcast()
+	b.lock.Unlock()
+}
@@ -0,0 +1,249 @@
+package blob_channel
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+// connect returns two channels talking to each other and waits for them to say hello
+func connect(t *testing.T, window uint32, memoryLimit int64) (*Channel, *Channel) {
+	routine.InitPool(100)
+	aReader, bWriter := io.Pipe()
+	bReader, aWriter := io.Pipe()
+	a := New(aReader, aWriter, window, memoryLimit)
+	b := New(bReader, bWriter, window, memoryLimit)
+	go a.Start()
+	go b.Start()
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+
+	deadline := time.Now().Add(time.Second)
+	for !a.Ready() || !b.Ready() {
+		if time.Now().After(deadline) {
+			t.Fatal("channels did not say hello")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return a, b
+}
+
+func TestSendWithBackpressure(t *testing.T) {
+	a, b := connect(t, 1024, 0)
+
+	data := bytes.Repeat([]byte("dify"), 64*1024)
+	// blobs larger than the window are only sent once something refers to them
+	blob := b.Receive("blob")
+	defer blob.Close()
+	sent := make(chan error, 1)
+	go func() { sent <- a.Send("blob", bytes.NewReader(data)) }()
+
+	// nothing is read yet, the sender must stop at the window
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case err := <-sent:
+		t.Fatalf("expected the sender to wait for credit, it returned %v", err)
+	default:
+	}
+	incoming := b.incomingBlob("blob")
+	incoming.lock.Lock()
+	buffered := incoming.buffer.Len()
+	incoming.lock.Unlock()
+	if buffered > 1024 {
+		t.Fatalf("expected at most 1024 bytes buffered, got %d", buffered)
+	}
+
+	received, err := io.ReadAll(blob)
+	if err != nil {
+		t.Fatalf("failed to read blob: %v", err)
+	}
+	if !bytes.Equal(received, data) {
+		t.Fatalf("received %d bytes, expected %d", len(received), len(data))
+	}
+	if err := <-sent; err != nil {
+		t.Fatalf("failed to send blob: %v", err)
+	}
+}
+
+func TestReadAllLimits(t *testing.T) {
+	a, b := connect(t, 0, 0)
+
+	go a.Send("large", strings.NewReader(strings.Repeat("a", 100)))
+	if _, err := b.ReadAll("large", time.Second, 10); !errors.Is(err, ErrBlobTooLarge) {
+		t.Fatalf("expected blob too large, got %v", err)
+	}
+
+	if _, err := b.ReadAll("missing", 20*time.Millisecond, 0); !errors.Is(err, ErrBlobTimeout) {
+		t.Fatalf("expected timeout, got %v", err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	a, b := connect(t, 0, 0)
+
+	go a.Send("image", bytes.NewReader([]byte{0, 1, 2}))
+	go a.Send("text", strings.NewReader("hello"))
+
+	event := `{"session_id":"s","data":{"blob":{"$blob":"image"},"text":{"$blob":"text","type":"text"},"n":12345678901234567890}}`
+	resolved, err := b.Resolve([]byte(event), time.Second, 0)
+	if err != nil {
+		t.Fatalf("failed to resolve: %v", err)
+	}
+
+	var value struct {
+		Data struct {
+			Blob []byte      `json:"blob"`
+			Text string      `json:"text"`
+			N    json.Number `json:"n"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(resolved, &value); err != nil {
+		t.Fatalf("invalid json %s: %v", resolved, err)
+	}
+	if !bytes.Equal(value.Data.Blob, []byte{0, 1, 2}) || value.Data.Text != "hello" {
+		t.Fatalf("unexpected resolved event %s", resolved)
+	}
+	if value.Data.N.String() != "12345678901234567890" {
+		t.Fatalf("expected numbers to be kept, got %s", value.Data.N)
+	}
+
+	plain := []byte(`{"session_id":"s","data":{"blob":"AAEC"}}`)
+	if resolved, _ := b.Resolve(plain, time.Second, 0); !bytes.Equal(resolved, plain) {
+		t.Fatalf("expected events without references to be kept, got %s", resolved)
+	}
+}
+
+func TestOffload(t *testing.T) {
+	a, b := connect(t, 0, 0)
+
+	large := strings.Repeat("x", 1000)
+	request, _ := json.Marshal(map[string]any{"file": large, "name": "small"})
+
+	offloaded := a.Offload(request, 100)
+	if bytes.Contains(offloaded, []byte(large)) {
+		t.Fatalf("expected the large string to be offloaded, got %s", offloaded)
+	}
+
+	resolved, err := b.Resolve(offloaded, time.Second, 0)
+	if err != nil {
+		t.Fatalf("failed to resolve: %v", err)
+	}
+	var value map[string]string
+	json.Unmarshal(resolved, &value)
+	if value["file"] != large || value["name"] != "small" {
+		t.Fatalf("unexpected resolved request %s", resolved)
+	}
+}
+
+func TestOffloadWithoutHello(t *testing.T) {
+	reader, _ := io.Pipe()
+	_, writer := io.Pipe()
+	channel := New(reader, writer, 0, 0)
+
+	request := []byte(`{"file":"` + base64.StdEncoding.EncodeToString(make([]byte, 1000)) + `"}`)
+	if offloaded := channel.Offload(request, 100); !bytes.Equal(offloaded, request) {
+		t.Fatal("expected requests to be kept for peers without the channel")
+	}
+}
+
+func TestUnreferredBlobLargerThanWindow(t *testing.T) {
+	a, b := connect(t, 1024, 0)
+
+	// the sender would wait for credit forever, nothing is going to read the blob
+	err := a.Send("large", bytes.NewReader(make([]byte, 4096)))
+	if err == nil {
+		t.Fatal("expected the blob to be aborted")
+	}
+	if _, err := b.ReadAll("large", time.Second, 0); !errors.Is(err, ErrBlobUnreferred) {
+		t.Fatalf("expected the blob to be failed, got %v", err)
+	}
+}
+
+func TestMemoryLimitEvictsOldestBlobs(t *testing.T) {
+	a, b := connect(t, 1024, 1500)
+
+	waitComplete := func(id string) {
+		deadline := time.Now().Add(time.Second)
+		for !b.incomingBlob(id).complete() {
+			if time.Now().After(deadline) {
+				t.Fatalf("blob %s did not arrive", id)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	if err := a.Send("first", bytes.NewReader(make([]byte, 800))); err != nil {
+		t.Fatalf("failed to send blob: %v", err)
+	}
+	waitComplete("first")
+	if err := a.Send("second", bytes.NewReader(make([]byte, 800))); err != nil {
+		t.Fatalf("failed to send blob: %v", err)
+	}
+	waitComplete("second")
+
+	if _, err := b.ReadAll("first", time.Second, 0); !errors.Is(err, ErrBlobEvicted) {
+		t.Fatalf("expected the oldest blob to be evicted, got %v", err)
+	}
+	if second, err := b.ReadAll("second", time.Second, 0); err != nil || len(second) != 800 {
+		t.Fatalf("expected the latest blob to be kept, got %d bytes, %v", len(second), err)
+	}
+
+	b.lock.Lock()
+	buffered := b.buffered
+	b.lock.Unlock()
+	if buffered != 0 {
+		t.Fatalf("expected nothing to be buffered once blobs were read, got %d", buffered)
+	}
+}
+
+func TestDispatchHoldsBackOnlyTheSession(t *testing.T) {
+	a, b := connect(t, 0, 0)
+
+	handled := make(chan string, 3)
+	handle := func(data []byte, err error) {
+		if err != nil {
+			t.Errorf("failed to dispatch: %v", err)
+			return
+		}
+		handled <- string(data)
+	}
+
+	b.Dispatch([]byte(`{"session_id":"a","data":{"$blob":"slow","type":"text"}}`), time.Second, 0, handle)
+	b.Dispatch([]byte(`{"session_id":"a","data":"after"}`), time.Second, 0, handle)
+	b.Dispatch([]byte(`{"session_id":"b","data":"other"}`), time.Second, 0, handle)
+
+	select {
+	case event := <-handled:
+		if event != `{"session_id":"b","data":"other"}` {
+			t.Fatalf("expected the event of the other session first, got %s", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("events of other sessions must not wait for the blob")
+	}
+
+	if err := a.Send("slow", strings.NewReader("content")); err != nil {
+		t.Fatalf("failed to send blob: %v", err)
+	}
+	for _, expected := range []string{
+		`{"session_id":"a","data":"content"}`,
+		`{"session_id":"a","data":"after"}`,
+	} {
+		select {
+		case event := <-handled:
+			if event != expected {
+				t.Fatalf("expected %s, got %s", expected, event)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected %s to be dispatched", expected)
+		}
+	}
+}
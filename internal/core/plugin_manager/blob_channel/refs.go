@@ -0,0 +1,316 @@
+package blob_channel
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+const (
+	// a reference is an object of REF_KEY and optionally REF_TYPE_KEY in place of the value
+	REF_KEY      = "$blob"
+	REF_TYPE_KEY = "type"
+
+	// REF_TYPE_BYTES values are base64 encoded when resolved, as []byte is in json
+	REF_TYPE_BYTES = "bytes"
+	REF_TYPE_TEXT  = "text"
+)
+
+// blobReference is a reference of a json event, start and end are its offsets in the event
+type blobReference struct {
+	id    string
+	text  bool
+	start int64
+	end   int64
+}
+
+// references lists the blob references of a json event in order, nil if it has none
+func references(data []byte) []blobReference {
+	if !bytes.Contains(data, []byte(`"`+REF_KEY+`"`)) {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	token, err := decoder.Token()
+	if err != nil {
+		return nil
+	}
+	var refs []blobReference
+	if err := scanReferences(decoder, token, &refs); err != nil {
+		// not for us to judge, the event is parsed later
+		return nil
+	}
+	return refs
+}
+
+// scanReferences walks the value starting with token without decoding it into a tree
+func scanReferences(decoder *json.Decoder, token json.Token, refs *[]blobReference) error {
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return nil
+	}
+	start := decoder.InputOffset() - 1
+
+	fields := map[string]any{}
+	nested := false
+	for decoder.More() {
+		if delim == '{' {
+			key, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return fmt.Errorf("unexpected key %v", key)
+			}
+			value, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			if _, ok := value.(json.Delim); ok {
+				nested = true
+			} else {
+				fields[name] = value
+			}
+			if err := scanReferences(decoder, value, refs); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if err := scanReferences(decoder, value, refs); err != nil {
+			return err
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+
+	if delim == '{' && !nested {
+		if id, ok := reference(fields); ok {
+			*refs = append(*refs, blobReference{
+				id:    id,
+				text:  fields[REF_TYPE_KEY] == REF_TYPE_TEXT,
+				start: start,
+				end:   decoder.InputOffset(),
+			})
+		}
+	}
+	return nil
+}
+
+// Resolve replaces the blob references of a json event with the content of the blobs, events without
+// references are returned as they are
+func (c *Channel) Resolve(data []byte, timeout time.Duration, maxSize int64) ([]byte, error) {
+	refs := references(data)
+	if len(refs) == 0 {
+		return data, nil
+	}
+	for _, ref := range refs {
+		c.claim(ref.id)
+	}
+	return c.splice(data, refs, timeout, maxSize)
+}
+
+// splice puts the content of the blobs in place of their references, the rest of the event is copied
+// as it is instead of being decoded and encoded again, bytes are base64 encoded as []byte is in json
+func (c *Channel) splice(data []byte, refs []blobReference, timeout time.Duration, maxSize int64) ([]byte, error) {
+	if len(refs) == 0 {
+		return data, nil
+	}
+
+	resolved := make([]byte, 0, len(data))
+	last := int64(0)
+	for _, ref := range refs {
+		content, err := c.ReadAll(ref.id, timeout, maxSize)
+		if err != nil {
+			return nil, fmt.Errorf("blob %s: %w", ref.id, err)
+		}
+		resolved = append(resolved, data[last:ref.start]...)
+		if ref.text {
+			encoded, err := json.Marshal(string(content))
+			if err != nil {
+				return nil, err
+			}
+			resolved = append(resolved, encoded...)
+		} else {
+			resolved = append(resolved, '"')
+			resolved = base64.StdEncoding.AppendEncode(resolved, content)
+			resolved = append(resolved, '"')
+		}
+		last = ref.end
+	}
+	return append(resolved, data[last:]...), nil
+}
+
+// eventQueue holds the events of a session waiting for blobs, in order
+type eventQueue struct {
+	events []queuedEvent
+}
+
+type queuedEvent struct {
+	data []byte
+	refs []blobReference
+}
+
+// Dispatch calls handle with an event once the blobs it refers to arrived, with their content in place
+// of the references, or with the error the blobs failed with. The caller is never blocked: an event
+// waiting for blobs only holds back the later events of its session, handle is called right away for
+// events that don't have to wait and from another goroutine otherwise.
+func (c *Channel) Dispatch(data []byte, timeout time.Duration, maxSize int64, handle func([]byte, error)) {
+	refs := references(data)
+	ready := true
+	for _, ref := range refs {
+		if !c.claim(ref.id).complete() {
+			ready = false
+		}
+	}
+
+	c.lock.Lock()
+	waiting := len(c.queues) > 0
+	c.lock.Unlock()
+	if ready && !waiting {
+		handle(c.splice(data, refs, timeout, maxSize))
+		return
+	}
+
+	session := sessionOf(data)
+	c.lock.Lock()
+	queue, queued := c.queues[session]
+	if !queued && ready {
+		c.lock.Unlock()
+		handle(c.splice(data, refs, timeout, maxSize))
+		return
+	}
+	if !queued {
+		queue = &eventQueue{}
+		c.queues[session] = queue
+	}
+	// the caller may reuse data once Dispatch returned
+	queue.events = append(queue.events, queuedEvent{data: bytes.Clone(data), refs: refs})
+	c.lock.Unlock()
+
+	if !queued {
+		routine.Submit(map[string]string{
+			"module":   "blob_channel",
+			"function": "Dispatch",
+		}, func() {
+			c.drain(session, queue, timeout, maxSize, handle)
+		})
+	}
+}
+
+// drain handles the events of a session in order until none is left
+func (c *Channel) drain(
+	session string,
+	queue *eventQueue,
+	timeout time.Duration,
+	maxSize int64,
+	handle func([]byte, error),
+) {
+	for {
+		c.lock.Lock()
+		if len(queue.events) == 0 {
+			delete(c.queues, session)
+			c.lock.Unlock()
+			return
+		}
+		event := queue.events[0]
+		queue.events = queue.events[1:]
+		c.lock.Unlock()
+
+		handle(c.splice(event.data, event.refs, timeout, maxSize))
+	}
+}
+
+func sessionOf(data []byte) string {
+	var event struct {
+		SessionID string `json:"session_id"`
+	}
+	json.Unmarshal(data, &event)
+	return event.SessionID
+}
+
+func reference(value map[string]any) (string, bool) {
+	id, ok := value[REF_KEY].(string)
+	if !ok || id == "" {
+		return "", false
+	}
+	for key := range value {
+		if key != REF_KEY && key != REF_TYPE_KEY {
+			return "", false
+		}
+	}
+	return id, true
+}
+
+// Offload moves strings of at least threshold bytes in a json request to blobs and replaces them with
+// text references, requests are returned as they are if the peer doesn't support the channel
+func (c *Channel) Offload(data []byte, threshold int) []byte {
+	if threshold <= 0 || len(data) < threshold || !c.Ready() {
+		return data
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return data
+	}
+
+	blobs := map[string]string{}
+	value = offload(value, threshold, blobs)
+	if len(blobs) == 0 {
+		return data
+	}
+	offloaded, err := json.Marshal(value)
+	if err != nil {
+		return data
+	}
+
+	for id, content := range blobs {
+		id, content := id, content
+		routine.Submit(map[string]string{
+			"module":   "blob_channel",
+			"function": "Offload",
+		}, func() {
+			if err := c.Send(id, strings.NewReader(content)); err != nil {
+				log.Error("failed to send blob %s: %s", id, err.Error())
+			}
+		})
+	}
+	return offloaded
+}
+
+func offload(value any, threshold int, blobs map[string]string) any {
+	switch v := value.(type) {
+	case string:
+		if len(v) < threshold {
+			return v
+		}
+		id := uuid.New().String()
+		blobs[id] = v
+		return map[string]any{REF_KEY: id, REF_TYPE_KEY: REF_TYPE_TEXT}
+	case map[string]any:
+		for key, item := range v {
+			v[key] = offload(item, threshold, blobs)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = offload(item, threshold, blobs)
+		}
+	}
+	return value
+}
@@ -0,0 +1,71 @@
+package debugging_runtime
+
+import (
+	"errors"
+	"net"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/audit"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+var errInvalidKey = errors.New("invalid key")
+
+const AUDIT_ACTOR_DEBUGGING = "debugging"
+
+// submitAuditRecord records off the event loop, which must never wait for the database
+func submitAuditRecord(record models.AuditRecord) {
+	if !audit.Enabled() {
+		return
+	}
+
+	routine.Submit(map[string]string{
+		"module":   "debugging_runtime",
+		"function": "submitAuditRecord",
+	}, func() {
+		audit.Record(record)
+	})
+}
+
+func sourceIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// recordConnection audits a connection attempt, err is why it was refused
+func recordConnection(remoteAddr string, tenantId string, transport string, err error) {
+	record := models.AuditRecord{
+		Event:    audit.EVENT_DEBUGGING_CONNECT,
+		Actor:    AUDIT_ACTOR_DEBUGGING,
+		TenantID: tenantId,
+		SourceIP: sourceIP(remoteAddr),
+		Result:   models.AuditResultSuccess,
+		Detail:   map[string]any{"transport": transport},
+	}
+	if err != nil {
+		record.Result = models.AuditResultFailure
+		record.Message = err.Error()
+	}
+	submitAuditRecord(record)
+}
+
+func recordDisconnection(runtime *RemotePluginRuntime) {
+	detail := map[string]any{"transport": runtime.transport}
+	if connected := runtime.connected.Load(); connected != nil {
+		detail["namespace"] = connected.Namespace
+		if connected.PluginUniqueIdentifier != "" {
+			detail["plugin_unique_identifier"] = connected.PluginUniqueIdentifier.String()
+		}
+	}
+
+	submitAuditRecord(models.AuditRecord{
+		Event:    audit.EVENT_DEBUGGING_DISCONNECT,
+		Actor:    AUDIT_ACTOR_DEBUGGING,
+		TenantID: runtime.tenantId,
+		SourceIP: sourceIP(runtime.conn.RemoteAddr()),
+		Result:   models.AuditResultSuccess,
+		Detail:   detail,
+	})
+}
@@ -15,6 +15,6 @@ func (m *RemotePluginRuntime) calculateChecksum() string {
 	buffer := bytes.Buffer{}
 	binary.Write(&buffer, binary.BigEndian, parser.MarshalJsonBytes(configuration))
 	hash := sha256.New()
-	hash.Write(append(buffer.Bytes(), []byte(m.tenantId)...))
+	hash.Write(append(buffer.Bytes(), []byte(m.tenantId+m.namespace)...))
 	return hex.EncodeToString(hash.Sum(nil))
 }
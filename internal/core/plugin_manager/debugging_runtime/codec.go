@@ -3,12 +3,20 @@ package debugging_runtime
 import (
 	"bytes"
 	"errors"
+	"net"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/network"
 	"github.com/panjf2000/gnet/v2"
 )
 
 type codec struct {
 	buf bytes.Buffer
+
+	// proxyHeader is set for connections of trusted proxies until their PROXY header is read, which sets
+	// source, the allow-list is checked against the source afterwards
+	proxyHeader bool
+	allowedIPs  network.IPNets
+	source      net.Addr
 }
 
 func (w *codec) Decode(c gnet.Conn) ([][]byte, error) {
@@ -24,9 +32,37 @@ func (w *codec) Decode(c gnet.Conn) ([][]byte, error) {
 		return nil, errors.New("read less than size")
 	}
 
+	if w.proxyHeader {
+		return w.readProxyHeader(c, buf)
+	}
+
 	return w.getLines(buf), nil
 }
 
+// readProxyHeader buffers data until the PROXY header is complete, the data following it is decoded as lines
+func (w *codec) readProxyHeader(c gnet.Conn, data []byte) ([][]byte, error) {
+	w.buf.Write(data)
+	source, length, err := network.ParseProxyHeader(w.buf.Bytes())
+	if err == network.ErrProxyHeaderIncomplete && w.buf.Len() < network.PROXY_MAX_LENGTH {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	rest := bytes.Clone(w.buf.Bytes()[length:])
+	w.buf.Reset()
+	w.proxyHeader = false
+	w.source = source
+	if w.source == nil {
+		w.source = c.RemoteAddr()
+	}
+	if !w.allowedIPs.Allows(network.AddrIP(w.source)) {
+		return nil, network.ErrIPNotAllowed
+	}
+
+	return w.getLines(rest), nil
+}
+
 func (w *codec) getLines(data []byte) [][]byte {
 	// write to buffer
 	w.buf.Write(data)
@@ -0,0 +1,44 @@
+package debugging_runtime
+
+import (
+	"github.com/panjf2000/gnet/v2"
+)
+
+// connection is the transport a debugging plugin is connected through, messages are lines of json
+type connection interface {
+	// AsyncWrite queues a message without waiting for it to be sent
+	AsyncWrite(data []byte)
+	// Write sends a message before the connection is closed
+	Write(data []byte)
+	Close() error
+	// RemoteAddr is the address the plugin connected from
+	RemoteAddr() string
+}
+
+type gnetConnection struct {
+	conn gnet.Conn
+}
+
+func (c gnetConnection) AsyncWrite(data []byte) {
+	c.conn.AsyncWrite(data, func(c gnet.Conn, err error) error {
+		return nil
+	})
+}
+
+func (c gnetConnection) Write(data []byte) {
+	c.conn.Write(data)
+}
+
+func (c gnetConnection) Close() error {
+	return c.conn.Close()
+}
+
+func (c gnetConnection) RemoteAddr() string {
+	if codec, ok := c.conn.Context().(*codec); ok && codec.source != nil {
+		return codec.source.String()
+	}
+	if addr := c.conn.RemoteAddr(); addr != nil {
+		return addr.String()
+	}
+	return ""
+}
@@ -1,6 +1,8 @@
 package debugging_runtime
 
 import (
+	"errors"
+	"regexp"
 	"strings"
 	"time"
 
@@ -18,10 +20,14 @@ import (
  * $tenant_id => $random_key
  *
  * It's a double mapping for each key, therefore a transaction is needed.
+ *
+ * Developers of a tenant may use their own namespace, every namespace has its own key and
+ * the plugins connected with it are installed under their own plugin id.
  * */
 
 type ConnectionInfo struct {
-	TenantId string `json:"tenant_id" validate:"required"`
+	TenantId  string `json:"tenant_id" validate:"required"`
+	Namespace string `json:"namespace,omitempty"`
 }
 
 type Key struct {
@@ -31,24 +37,41 @@ type Key struct {
 const (
 	CONNECTION_KEY_MANAGER_KEY2ID_PREFIX = "{remote:key:manager}:key2id"
 	CONNECTION_KEY_MANAGER_ID2KEY_PREFIX = "{remote:key:manager}:id2key"
+	CONNECTION_KEY_MANAGER_NAMESPACES    = "{remote:key:manager}:namespaces"
 	CONNECTION_KEY_LOCK                  = "connection_lock"
 	CONNECTION_KEY_EXPIRE_TIME           = time.Minute * 120 // 2 hours
 )
 
+var (
+	ErrInvalidNamespace = errors.New("namespace must be alphanumeric and less than 16 characters: ^[a-z0-9_-]{1,16}$")
+
+	namespaceRegex = regexp.MustCompile(`^[a-z0-9_-]{1,16}$`)
+)
+
+// connectionKeyCacheKey is where the key of a namespace is stored, the default namespace is empty
+func connectionKeyCacheKey(info ConnectionInfo) string {
+	if info.Namespace == "" {
+		return strings.Join([]string{CONNECTION_KEY_MANAGER_ID2KEY_PREFIX, info.TenantId}, ":")
+	}
+	return strings.Join([]string{CONNECTION_KEY_MANAGER_ID2KEY_PREFIX, info.TenantId, info.Namespace}, ":")
+}
+
 // returns a random string, create it if not exists
 func GetConnectionKey(info ConnectionInfo) (string, error) {
+	if info.Namespace != "" && !namespaceRegex.MatchString(info.Namespace) {
+		return "", ErrInvalidNamespace
+	}
+
 	var key *Key
 	var err error
 
-	key, err = cache.Get[Key](
-		strings.Join([]string{CONNECTION_KEY_MANAGER_ID2KEY_PREFIX, info.TenantId}, ":"),
-	)
+	key, err = cache.Get[Key](connectionKeyCacheKey(info))
 
 	if err == cache.ErrNotFound {
 		err := cache.Transaction(func(p redis.Pipeliner) error {
 			k := uuid.New().String()
 			_, err = cache.SetNX(
-				strings.Join([]string{CONNECTION_KEY_MANAGER_ID2KEY_PREFIX, info.TenantId}, ":"),
+				connectionKeyCacheKey(info),
 				Key{Key: k},
 				CONNECTION_KEY_EXPIRE_TIME,
 				p,
@@ -67,6 +90,14 @@ func GetConnectionKey(info ConnectionInfo) (string, error) {
 				return err
 			}
 
+			if info.Namespace != "" {
+				// index the namespaces of a tenant so all of them can be cleared
+				err = cache.SetMapOneField(namespacesCacheKey(info.TenantId), info.Namespace, Key{Key: k}, p)
+				if err != nil {
+					return err
+				}
+			}
+
 			key = &Key{Key: k}
 
 			return nil
@@ -79,7 +110,7 @@ func GetConnectionKey(info ConnectionInfo) (string, error) {
 		return "", err
 	} else {
 		// update expire time
-		_, err = cache.Expire(strings.Join([]string{CONNECTION_KEY_MANAGER_ID2KEY_PREFIX, info.TenantId}, ":"), CONNECTION_KEY_EXPIRE_TIME)
+		_, err = cache.Expire(connectionKeyCacheKey(info), CONNECTION_KEY_EXPIRE_TIME)
 		if err != nil {
 			log.Error("failed to update connection key expire time: %s", err.Error())
 		}
@@ -107,17 +138,66 @@ func GetConnectionInfo(key string) (*ConnectionInfo, error) {
 	return info, nil
 }
 
-// clear connection key
-func ClearConnectionKey(tenant_id string) error {
-	key, err := cache.Get[Key](
-		strings.Join([]string{CONNECTION_KEY_MANAGER_ID2KEY_PREFIX, tenant_id}, ":"),
-	)
+func namespacesCacheKey(tenant_id string) string {
+	return strings.Join([]string{CONNECTION_KEY_MANAGER_NAMESPACES, tenant_id}, ":")
+}
+
+// connectionKeyCacheKeys returns where the keys of all namespaces of a tenant are stored
+func connectionKeyCacheKeys(tenant_id string) ([]string, error) {
+	namespaces, err := cache.GetMap[Key](namespacesCacheKey(tenant_id))
+	if err != nil && err != cache.ErrNotFound {
+		return nil, err
+	}
 
+	keys := []string{connectionKeyCacheKey(ConnectionInfo{TenantId: tenant_id})}
+	for namespace := range namespaces {
+		keys = append(keys, connectionKeyCacheKey(ConnectionInfo{TenantId: tenant_id, Namespace: namespace}))
+	}
+	return keys, nil
+}
+
+// CountConnectionKeys counts the connection keys of a tenant over all namespaces
+func CountConnectionKeys(tenant_id string) (int64, error) {
+	keys, err := connectionKeyCacheKeys(tenant_id)
+	if err != nil {
+		return 0, err
+	}
+
+	count := int64(0)
+	for _, k := range keys {
+		exists, err := cache.Exist(k)
+		if err != nil {
+			return 0, err
+		}
+		count += exists
+	}
+	return count, nil
+}
+
+// clear connection keys of all namespaces of a tenant, cache.ErrNotFound if there is none
+func ClearConnectionKey(tenant_id string) error {
+	keys, err := connectionKeyCacheKeys(tenant_id)
 	if err != nil {
 		return err
 	}
 
-	cache.Del(strings.Join([]string{CONNECTION_KEY_MANAGER_KEY2ID_PREFIX, key.Key}, ":"))
-	cache.Del(strings.Join([]string{CONNECTION_KEY_MANAGER_ID2KEY_PREFIX, tenant_id}, ":"))
+	cleared := false
+	for _, k := range keys {
+		key, err := cache.Get[Key](k)
+		if err == cache.ErrNotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		cache.Del(strings.Join([]string{CONNECTION_KEY_MANAGER_KEY2ID_PREFIX, key.Key}, ":"))
+		cache.Del(k)
+		cleared = true
+	}
+	cache.Del(namespacesCacheKey(tenant_id))
+
+	if !cleared {
+		return cache.ErrNotFound
+	}
 	return nil
 }
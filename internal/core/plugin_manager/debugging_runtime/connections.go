@@ -0,0 +1,101 @@
+package debugging_runtime
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	DEBUGGING_TRANSPORT_TCP  = "tcp"
+	DEBUGGING_TRANSPORT_GRPC = "grpc"
+)
+
+var ErrTooManyTenantConnections = errors.New("too many debugging connections of the tenant, please try again later")
+
+// DebuggingConnection describes a debugging connection which completed the handshake
+type DebuggingConnection struct {
+	TenantID               string                                 `json:"tenant_id"`
+	Namespace              string                                 `json:"namespace"`
+	Transport              string                                 `json:"transport"`
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier,omitempty"`
+	ConnectedAt            time.Time                              `json:"connected_at"`
+}
+
+// onHandshake binds a connection to the tenant and namespace of its key, a tenant has at most
+// maxTenantConn connections on a node so developers sharing it can't exhaust the server
+func (s *DifyServer) onHandshake(runtime *RemotePluginRuntime, info *ConnectionInfo) error {
+	s.pluginsLock.Lock()
+	defer s.pluginsLock.Unlock()
+
+	if s.maxTenantConn > 0 {
+		count := 0
+		s.rangeRuntimes(func(r *RemotePluginRuntime) {
+			if connected := r.connected.Load(); connected != nil && connected.TenantID == info.TenantId {
+				count++
+			}
+		})
+		if count >= s.maxTenantConn {
+			recordConnection(runtime.conn.RemoteAddr(), info.TenantId, runtime.transport, ErrTooManyTenantConnections)
+			return ErrTooManyTenantConnections
+		}
+	}
+
+	runtime.tenantId = info.TenantId
+	runtime.namespace = info.Namespace
+	runtime.handshake = true
+	runtime.connected.Store(&DebuggingConnection{
+		TenantID:    info.TenantId,
+		Namespace:   info.Namespace,
+		Transport:   runtime.transport,
+		ConnectedAt: runtime.connectedAt,
+	})
+	recordConnection(runtime.conn.RemoteAddr(), info.TenantId, runtime.transport, nil)
+	return nil
+}
+
+// onRegistered adds the identity of the plugin once it's installed
+func (r *RemotePluginRuntime) onRegistered() {
+	connected := r.connected.Load()
+	if connected == nil {
+		return
+	}
+	identity, err := r.Identity()
+	if err != nil {
+		return
+	}
+
+	registered := *connected
+	registered.PluginUniqueIdentifier = identity
+	r.connected.Store(&registered)
+}
+
+// rangeRuntimes calls fn for the runtimes of all transports, pluginsLock must be held
+func (s *DifyServer) rangeRuntimes(fn func(*RemotePluginRuntime)) {
+	for _, runtime := range s.plugins {
+		fn(runtime)
+	}
+	for runtime := range s.streams {
+		fn(runtime)
+	}
+}
+
+// Connections lists the debugging connections of current node
+func (s *DifyServer) Connections() []DebuggingConnection {
+	connections := []DebuggingConnection{}
+
+	s.pluginsLock.RLock()
+	s.rangeRuntimes(func(runtime *RemotePluginRuntime) {
+		if connected := runtime.connected.Load(); connected != nil {
+			connections = append(connections, *connected)
+		}
+	})
+	s.pluginsLock.RUnlock()
+
+	sort.Slice(connections, func(i, j int) bool {
+		return connections[i].ConnectedAt.Before(connections[j].ConnectedAt)
+	})
+	return connections
+}
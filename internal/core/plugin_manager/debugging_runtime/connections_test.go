@@ -0,0 +1,140 @@
+package debugging_runtime
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/manifest_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestConnectionKeyNamespaces(t *testing.T) {
+	if err := cache.InitMemoryClient(0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cache.Close() })
+
+	keys := map[string]bool{}
+	for _, namespace := range []string{"", "alice", "bob"} {
+		key, err := GetConnectionKey(ConnectionInfo{TenantId: "tenant", Namespace: namespace})
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[key] = true
+
+		info, err := GetConnectionInfo(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.TenantId != "tenant" || info.Namespace != namespace {
+			t.Fatalf("unexpected connection info %+v", info)
+		}
+	}
+	if len(keys) != 3 {
+		t.Fatalf("expected a key per namespace, got %d", len(keys))
+	}
+
+	if _, err := GetConnectionKey(ConnectionInfo{TenantId: "tenant", Namespace: "Alice:admin"}); err != ErrInvalidNamespace {
+		t.Fatalf("expected an invalid namespace, got %v", err)
+	}
+
+	if count, err := CountConnectionKeys("tenant"); err != nil || count != 3 {
+		t.Fatalf("expected 3 keys, got %d %v", count, err)
+	}
+	if err := ClearConnectionKey("tenant"); err != nil {
+		t.Fatal(err)
+	}
+	for key := range keys {
+		if _, err := GetConnectionInfo(key); err != cache.ErrNotFound {
+			t.Fatalf("expected the key to be cleared, got %v", err)
+		}
+	}
+	if count, err := CountConnectionKeys("tenant"); err != nil || count != 0 {
+		t.Fatalf("expected no keys, got %d %v", count, err)
+	}
+}
+
+func TestIdentityWithNamespace(t *testing.T) {
+	runtime := &RemotePluginRuntime{tenantId: "tenant"}
+	runtime.Config.Author = "author"
+	runtime.Config.Name = "search"
+	runtime.Config.Version = manifest_entities.Version("0.0.1")
+
+	identities := map[plugin_entities.PluginUniqueIdentifier]bool{}
+	for _, namespace := range []string{"", "alice"} {
+		runtime.namespace = namespace
+		runtime.checksum = runtime.calculateChecksum()
+		identity, err := runtime.Identity()
+		if err != nil {
+			t.Fatal(err)
+		}
+		identities[identity] = true
+	}
+
+	if len(identities) != 2 {
+		t.Fatalf("expected the namespaces to have their own identity, got %v", identities)
+	}
+	for identity := range identities {
+		if identity.PluginID() != "tenant/search" && identity.PluginID() != "tenant/search-alice" {
+			t.Errorf("unexpected plugin id %s", identity.PluginID())
+		}
+	}
+}
+
+func TestDebuggingConnectionsPerTenant(t *testing.T) {
+	client, server := prepareGrpcDebugging(t)
+	server.maxTenantConn = 2
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	connect := func(namespace string) error {
+		key, err := GetConnectionKey(ConnectionInfo{TenantId: "tenant", Namespace: namespace})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream, err := client.Connect(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// a connection over the limit is rejected right away, others wait for the registration
+		received := make(chan error, 1)
+		go func() {
+			_, err := stream.Recv()
+			received <- err
+		}()
+		select {
+		case err := <-received:
+			return err
+		case <-time.After(200 * time.Millisecond):
+			return nil
+		}
+	}
+
+	for _, namespace := range []string{"alice", "bob"} {
+		if err := connect(namespace); err != nil {
+			t.Fatalf("%s: unexpected error %v", namespace, err)
+		}
+	}
+	if err := connect("carol"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected the tenant to be limited, got %v", err)
+	}
+
+	connections := server.Connections()
+	namespaces := []string{}
+	for _, connection := range connections {
+		if connection.TenantID != "tenant" || connection.Transport != DEBUGGING_TRANSPORT_GRPC {
+			t.Errorf("unexpected connection %+v", connection)
+		}
+		namespaces = append(namespaces, connection.Namespace)
+	}
+	if strings.Join(namespaces, ",") != "alice,bob" {
+		t.Fatalf("unexpected connections %v", namespaces)
+	}
+}
@@ -23,6 +23,10 @@ func (r *RemotePluginRuntime) Identity() (plugin_entities.PluginUniqueIdentifier
 	}
 	config := r.Config
 	config.Author = r.tenantId
+	if r.namespace != "" {
+		// developers of the same tenant get their own plugin id, so are the identifiers of their tools
+		config.Name = fmt.Sprintf("%s-%s", config.Name, r.namespace)
+	}
 	checksum, _ := r.Checksum()
 	return plugin_entities.NewPluginUniqueIdentifier(fmt.Sprintf("%s@%s", config.Identity(), checksum))
 }
@@ -0,0 +1,227 @@
+package debugging_runtime
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/network"
+	"github.com/langgenius/dify-plugin-daemon/pkg/proto/debugging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// grpcDebuggingServer carries the debugging protocol over a bidirectional grpc stream, so plugins
+// can be debugged through proxies which only pass http/2
+type grpcDebuggingServer struct {
+	debugging.UnimplementedRemoteDebuggingServer
+
+	server *DifyServer
+}
+
+// grpcConnection sends frames of a stream from a single goroutine as grpc streams don't allow
+// concurrent sends
+type grpcConnection struct {
+	stream grpc.BidiStreamingServer[debugging.Frame, debugging.Frame]
+
+	outgoing  chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newGrpcConnection(stream grpc.BidiStreamingServer[debugging.Frame, debugging.Frame]) *grpcConnection {
+	return &grpcConnection{
+		stream:   stream,
+		outgoing: make(chan []byte, 512),
+		closed:   make(chan struct{}),
+	}
+}
+
+func (c *grpcConnection) AsyncWrite(data []byte) {
+	select {
+	case c.outgoing <- data:
+	case <-c.closed:
+	}
+}
+
+func (c *grpcConnection) Write(data []byte) {
+	c.AsyncWrite(data)
+}
+
+func (c *grpcConnection) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
+
+func (c *grpcConnection) RemoteAddr() string {
+	return peerAddr(c.stream)
+}
+
+func peerAddr(stream grpc.ServerStream) string {
+	if p, ok := peer.FromContext(stream.Context()); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// send delivers queued frames until the connection is closed, frames queued before are still sent
+func (c *grpcConnection) send() {
+	deliver := func(data []byte) bool {
+		return c.stream.Send(&debugging.Frame{Data: bytes.TrimSuffix(data, []byte("\n"))}) == nil
+	}
+
+	for {
+		select {
+		case data := <-c.outgoing:
+			if !deliver(data) {
+				c.Close()
+				return
+			}
+		case <-c.closed:
+			for {
+				select {
+				case data := <-c.outgoing:
+					if !deliver(data) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *grpcDebuggingServer) Connect(stream grpc.BidiStreamingServer[debugging.Frame, debugging.Frame]) error {
+	// the key in metadata replaces the handshake frame
+	var info *ConnectionInfo
+	if key := debuggingKey(stream); key != "" {
+		var err error
+		info, err = GetConnectionInfo(key)
+		if err == cache.ErrNotFound {
+			recordConnection(peerAddr(stream), "", DEBUGGING_TRANSPORT_GRPC, errInvalidKey)
+			return status.Error(codes.Unauthenticated, "invalid key")
+		} else if err != nil {
+			log.Error("failed to get connection info: %v", err)
+			return status.Error(codes.Internal, "internal error")
+		}
+	}
+
+	conn := newGrpcConnection(stream)
+	runtime := s.server.newRuntime(conn, DEBUGGING_TRANSPORT_GRPC)
+	s.server.pluginsLock.Lock()
+	s.server.streams[runtime] = struct{}{}
+	s.server.pluginsLock.Unlock()
+	defer func() {
+		s.server.pluginsLock.Lock()
+		delete(s.server.streams, runtime)
+		s.server.pluginsLock.Unlock()
+	}()
+
+	if info != nil {
+		if err := s.server.onHandshake(runtime, info); err != nil {
+			conn.Close()
+			return status.Error(codes.ResourceExhausted, err.Error())
+		}
+	}
+
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		conn.send()
+	}()
+
+	// messages are handled one by one like the tcp server, and never once the connection is released
+	var lock sync.Mutex
+	released := false
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		for {
+			frame, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			lock.Lock()
+			if !released {
+				s.server.onMessage(runtime, frame.Data)
+			}
+			lock.Unlock()
+		}
+	}()
+
+	select {
+	case <-conn.closed:
+	case <-received:
+	case <-stream.Context().Done():
+	}
+
+	conn.Close()
+	<-sent
+
+	lock.Lock()
+	released = true
+	lock.Unlock()
+	s.server.onDisconnected(runtime)
+	return nil
+}
+
+// debuggingKey reads the key from `authorization: Bearer <key>` metadata
+func debuggingKey(stream grpc.ServerStream) string {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	for _, value := range md.Get("authorization") {
+		if key, ok := strings.CutPrefix(value, "Bearer "); ok {
+			return strings.TrimSpace(key)
+		}
+	}
+	return ""
+}
+
+// launchGrpc serves the debugging protocol over grpc with reflection so tools like grpcurl can
+// discover it, tls is used if a certificate is configured
+func (r *RemotePluginServer) launchGrpc() error {
+	options := []grpc.ServerOption{}
+	if r.grpcCertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(r.grpcCertFile, r.grpcKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load debugging grpc certificate: %v", err)
+		}
+		options = append(options, grpc.Creds(credentials.NewTLS(&tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{certificate},
+		})))
+	}
+
+	listener, err := net.Listen("tcp", r.grpcAddr)
+	if err != nil {
+		return err
+	}
+	listener = network.NewListener(listener, network.ListenerConfig{
+		ProxyProtocol:  r.server.proxyProtocol,
+		TrustedProxies: r.server.trustedProxies,
+		AllowedIPs:     r.server.allowedIPs,
+	})
+
+	r.grpcServer = grpc.NewServer(options...)
+	debugging.RegisterRemoteDebuggingServer(r.grpcServer, &grpcDebuggingServer{server: r.server})
+	reflection.Register(r.grpcServer)
+
+	go func() {
+		if err := r.grpcServer.Serve(listener); err != nil && err != grpc.ErrServerStopped {
+			log.Error("debugging grpc server stopped: %s", err.Error())
+		}
+	}()
+	return nil
+}
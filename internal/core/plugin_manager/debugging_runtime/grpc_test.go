@@ -0,0 +1,95 @@
+package debugging_runtime
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/pkg/proto/debugging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func prepareGrpcDebugging(t *testing.T) (debugging.RemoteDebuggingClient, *DifyServer) {
+	if err := cache.InitMemoryClient(0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cache.Close() })
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	difyServer := &DifyServer{
+		plugins:     map[int]*RemotePluginRuntime{},
+		streams:     map[*RemotePluginRuntime]struct{}{},
+		pluginsLock: &sync.RWMutex{},
+	}
+	debugging.RegisterRemoteDebuggingServer(server, &grpcDebuggingServer{server: difyServer})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return debugging.NewRemoteDebuggingClient(conn), difyServer
+}
+
+func TestGrpcDebuggingRejectsInvalidKey(t *testing.T) {
+	client, _ := prepareGrpcDebugging(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.Connect(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer unknown"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected an unauthenticated error, got %v", err)
+	}
+}
+
+func TestGrpcDebuggingClosesWithMessage(t *testing.T) {
+	client, _ := prepareGrpcDebugging(t)
+
+	key, err := GetConnectionKey(ConnectionInfo{TenantId: "tenant"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.Connect(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// frames of the registration are answered like over tcp
+	if err := stream.Send(&debugging.Frame{Data: []byte("not a registration")}); err != nil {
+		t.Fatal(err)
+	}
+	frame, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(frame.Data) != "handshake failed, invalid handshake message" {
+		t.Fatalf("unexpected frame %q", frame.Data)
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Fatalf("expected the stream to be closed, got %v", err)
+	}
+}
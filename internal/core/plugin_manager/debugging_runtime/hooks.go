@@ -12,6 +12,7 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/media_transport"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/network"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
@@ -45,12 +46,24 @@ type DifyServer struct {
 	response *stream.Stream[plugin_entities.PluginFullDuplexLifetime]
 
 	plugins     map[int]*RemotePluginRuntime
+	streams     map[*RemotePluginRuntime]struct{}
 	pluginsLock *sync.RWMutex
 
 	shutdownChan chan bool
 
 	maxConn     int32
 	currentConn int32
+
+	// max connections of a single tenant
+	maxTenantConn int
+
+	// sessions are recorded into this directory if set
+	recordDir string
+
+	// connections of trusted proxies start with a PROXY header if proxyProtocol is set
+	proxyProtocol  bool
+	trustedProxies network.IPNets
+	allowedIPs     network.IPNets
 }
 
 func (s *DifyServer) OnBoot(c gnet.Engine) (action gnet.Action) {
@@ -60,13 +73,33 @@ func (s *DifyServer) OnBoot(c gnet.Engine) (action gnet.Action) {
 
 func (s *DifyServer) OnOpen(c gnet.Conn) (out []byte, action gnet.Action) {
 	// new plugin connected
-	c.SetContext(&codec{})
+	codec := &codec{}
+	if ip := network.AddrIP(c.RemoteAddr()); s.proxyProtocol && s.trustedProxies.Contains(ip) {
+		codec.proxyHeader = true
+		codec.allowedIPs = s.allowedIPs
+	} else if !s.allowedIPs.Allows(ip) {
+		return nil, gnet.Close
+	}
+	c.SetContext(codec)
+	runtime := s.newRuntime(gnetConnection{conn: c}, DEBUGGING_TRANSPORT_TCP)
+
+	// store plugin runtime
+	s.pluginsLock.Lock()
+	s.plugins[c.Fd()] = runtime
+	s.pluginsLock.Unlock()
+
+	return nil, gnet.None
+}
+
+// newRuntime creates the runtime of a new connection, which is closed unless the handshake is
+// completed in 10 seconds
+func (s *DifyServer) newRuntime(conn connection, transport string) *RemotePluginRuntime {
 	runtime := &RemotePluginRuntime{
 		MediaTransport: basic_runtime.NewMediaTransport(
 			s.mediaManager,
 		),
 
-		conn:                      c,
+		conn:                      conn,
 		response:                  stream.NewStream[[]byte](512),
 		messageCallbacks:          make(map[string][]func([]byte)),
 		messageCallbacksLock:      &sync.RWMutex{},
@@ -80,28 +113,19 @@ func (s *DifyServer) OnOpen(c gnet.Conn) (out []byte, action gnet.Action) {
 		waitLaunchedChan: make(chan error),
 
 		alive: true,
-	}
 
-	// store plugin runtime
-	s.pluginsLock.Lock()
-	s.plugins[c.Fd()] = runtime
-	s.pluginsLock.Unlock()
+		transport:   transport,
+		connectedAt: time.Now(),
+	}
 
-	// start a timer to check if handshake is completed in 10 seconds
 	time.AfterFunc(time.Second*10, func() {
 		if !runtime.handshake {
 			// close connection
-			c.Close()
+			conn.Close()
 		}
 	})
 
-	// verified
-	verified := true
-	if verified {
-		return nil, gnet.None
-	}
-
-	return nil, gnet.Close
+	return runtime
 }
 
 func (s *DifyServer) OnClose(c gnet.Conn, err error) (action gnet.Action) {
@@ -115,8 +139,21 @@ func (s *DifyServer) OnClose(c gnet.Conn, err error) (action gnet.Action) {
 		return gnet.None
 	}
 
+	s.onDisconnected(plugin)
+	return gnet.None
+}
+
+// onDisconnected releases the runtime of a closed connection
+func (s *DifyServer) onDisconnected(plugin *RemotePluginRuntime) {
+	if plugin.handshake {
+		recordDisconnection(plugin)
+	}
+
 	// close plugin
 	plugin.onDisconnected()
+	if recorder := plugin.recorder.Load(); recorder != nil {
+		recorder.Close()
+	}
 
 	// uninstall plugin
 	if plugin.assetsTransferred {
@@ -146,8 +183,6 @@ func (s *DifyServer) OnClose(c gnet.Conn, err error) (action gnet.Action) {
 	plugin.waitLaunchedChanOnce.Do(func() {
 		close(plugin.waitLaunchedChan)
 	})
-
-	return gnet.None
 }
 
 func (s *DifyServer) OnShutdown(c gnet.Engine) {
@@ -221,6 +256,7 @@ func (s *DifyServer) onMessage(runtime *RemotePluginRuntime, message []byte) {
 			info, err := GetConnectionInfo(key.Key)
 			if err == cache.ErrNotFound {
 				// close connection if handshake failed
+				recordConnection(runtime.conn.RemoteAddr(), "", runtime.transport, errInvalidKey)
 				closeConn([]byte("handshake failed, invalid key\n"))
 				runtime.handshakeFailed = true
 				return
@@ -231,10 +267,12 @@ func (s *DifyServer) onMessage(runtime *RemotePluginRuntime, message []byte) {
 				return
 			}
 
-			runtime.tenantId = info.TenantId
-
 			// handshake completed
-			runtime.handshake = true
+			if err := s.onHandshake(runtime, info); err != nil {
+				closeConn([]byte(fmt.Sprintf("handshake failed, %s\n", err.Error())))
+				runtime.handshakeFailed = true
+				return
+			}
 		} else if registerPayload.Type == plugin_entities.REGISTER_EVENT_TYPE_ASSET_CHUNK {
 			if runtime.assetsTransferred {
 				return
@@ -313,6 +351,8 @@ func (s *DifyServer) onMessage(runtime *RemotePluginRuntime, message []byte) {
 				closeConn([]byte(fmt.Sprintf("register failed, cannot register: %v\n", err)))
 				return
 			}
+			runtime.onRegistered()
+			s.startRecording(runtime)
 
 			// send started event
 			runtime.waitChanLock.Lock()
@@ -426,6 +466,9 @@ func (s *DifyServer) onMessage(runtime *RemotePluginRuntime, message []byte) {
 		}
 	} else {
 		// continue handle messages if handshake completed
+		if recorder := runtime.recorder.Load(); recorder != nil {
+			recorder.RecordPlugin(message)
+		}
 		runtime.response.WriteBlocking(message)
 	}
 }
@@ -10,7 +10,6 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
-	"github.com/panjf2000/gnet/v2"
 )
 
 func (r *RemotePluginRuntime) Listen(session_id string) *entities.Broadcast[plugin_entities.SessionMessage] {
@@ -53,7 +52,8 @@ func (r *RemotePluginRuntime) Listen(session_id string) *entities.Broadcast[plug
 }
 
 func (r *RemotePluginRuntime) Write(session_id string, action access_types.PluginAccessAction, data []byte) {
-	r.conn.AsyncWrite(append(data, '\n'), func(c gnet.Conn, err error) error {
-		return nil
-	})
+	if recorder := r.recorder.Load(); recorder != nil {
+		recorder.RecordDaemon(data)
+	}
+	r.conn.AsyncWrite(append(data, '\n'))
 }
@@ -0,0 +1,41 @@
+package debugging_runtime
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/debugging_runtime/recording"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+// startRecording records the session of a registered plugin into recordDir if it's set, named after
+// the plugin id and the time it connected
+func (s *DifyServer) startRecording(runtime *RemotePluginRuntime) {
+	if s.recordDir == "" {
+		return
+	}
+
+	identity, err := runtime.Identity()
+	if err != nil {
+		return
+	}
+	path := filepath.Join(s.recordDir, fmt.Sprintf(
+		"%s-%s.jsonl",
+		strings.ReplaceAll(identity.PluginID(), "/", "_"),
+		runtime.connectedAt.UTC().Format("20060102T150405.000000000"),
+	))
+
+	recorder, err := recording.NewRecorder(path, recording.Header{
+		TenantID:               runtime.tenantId,
+		Namespace:              runtime.namespace,
+		PluginUniqueIdentifier: identity,
+		Declaration:            runtime.Config,
+	})
+	if err != nil {
+		log.Error("failed to record debugging session of %s: %s", identity.String(), err.Error())
+		return
+	}
+	runtime.recorder.Store(recorder)
+	log.Info("recording debugging session of %s into %s", identity.String(), path)
+}
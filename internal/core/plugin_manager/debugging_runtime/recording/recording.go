@@ -0,0 +1,255 @@
+// Package recording captures the event stream of a debugging session as json lines, one entry for
+// each message between the daemon and the plugin, so the session can be replayed against a local build
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+type Direction string
+
+const (
+	// DIRECTION_HEADER is the first entry of a recording and describes the plugin
+	DIRECTION_HEADER Direction = "header"
+	// DIRECTION_DAEMON are requests and responses of backwards invocations sent to the plugin
+	DIRECTION_DAEMON Direction = "daemon"
+	// DIRECTION_PLUGIN are responses, backwards invocations and logs sent by the plugin
+	DIRECTION_PLUGIN Direction = "plugin"
+)
+
+type Entry struct {
+	Time      time.Time       `json:"time"`
+	Direction Direction       `json:"direction"`
+	SessionID string          `json:"session_id,omitempty"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type Header struct {
+	TenantID               string                                 `json:"tenant_id"`
+	Namespace              string                                 `json:"namespace,omitempty"`
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier"`
+	Declaration            plugin_entities.PluginDeclaration      `json:"declaration"`
+}
+
+// daemonMessage is the part of a message sent to the plugin a recording needs
+type daemonMessage struct {
+	SessionID string `json:"session_id"`
+	Event     string `json:"event"`
+}
+
+// Recorder appends entries to a recording, every entry is flushed so a recording survives a crash
+type Recorder struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+}
+
+func NewRecorder(path string, header Header) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Recorder{file: file, writer: bufio.NewWriter(file)}
+	r.write(Entry{Direction: DIRECTION_HEADER, Data: parser.MarshalJsonBytes(header)})
+	return r, nil
+}
+
+// RecordDaemon records a message sent to the plugin, pings are skipped and credentials are redacted
+func (r *Recorder) RecordDaemon(data []byte) {
+	message, err := parser.UnmarshalJsonBytes[daemonMessage](data)
+	if err != nil || message.Event == plugin_entities.PLUGIN_IN_STREAM_EVENT_PING {
+		return
+	}
+	r.write(Entry{Direction: DIRECTION_DAEMON, SessionID: message.SessionID, Data: redact(data)})
+}
+
+// REDACTED replaces values of credentials in recordings
+const REDACTED = "[REDACTED]"
+
+// credentialFields hold the resolved credentials and settings of requests, their values are redacted
+// while the keys are kept so a replay shows which credentials a request needs
+var credentialFields = map[string]bool{
+	"credentials":        true,
+	"system_credentials": true,
+	"settings":           true,
+}
+
+func redact(data []byte) json.RawMessage {
+	var message any
+	if err := json.Unmarshal(data, &message); err != nil {
+		return data
+	}
+	return parser.MarshalJsonBytes(redactValue(message, false))
+}
+
+func redactValue(value any, secret bool) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = redactValue(item, secret || credentialFields[key])
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = redactValue(item, secret)
+		}
+		return v
+	case nil:
+		return nil
+	}
+	if secret {
+		return REDACTED
+	}
+	return value
+}
+
+// RecordPlugin records a message sent by the plugin, heartbeats and pongs are skipped
+func (r *Recorder) RecordPlugin(data []byte) {
+	event, err := parser.UnmarshalJsonBytes[plugin_entities.PluginUniversalEvent](data)
+	if err != nil || event.Event == plugin_entities.PLUGIN_EVENT_HEARTBEAT || event.Event == plugin_entities.PLUGIN_EVENT_PONG {
+		return
+	}
+	r.write(Entry{Direction: DIRECTION_PLUGIN, SessionID: event.SessionId, Data: data})
+}
+
+func (r *Recorder) write(entry Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return
+	}
+	entry.Time = time.Now()
+	r.writer.Write(parser.MarshalJsonBytes(entry))
+	r.writer.WriteByte('\n')
+	r.writer.Flush()
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	r.writer.Flush()
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// Purge deletes the recordings in dir last written before the given time, recordings of sessions
+// still connected are flushed with every entry so they are never old enough
+func Purge(dir string, before time.Time) (int64, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return 0, err
+	}
+
+	var purged int64
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Before(before) {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// Session is a request of a recording and the messages the plugin answered it with
+type Session struct {
+	ID       string
+	Request  map[string]any
+	Messages []plugin_entities.SessionMessage
+}
+
+type Recording struct {
+	Header   Header
+	Sessions []Session
+}
+
+// Load reads a recording, sessions are in the order their requests were sent
+func Load(path string) (*Recording, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	recording := &Recording{}
+	sessions := map[string]*Session{}
+	order := []string{}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		entry, err := parser.UnmarshalJsonBytes[Entry](scanner.Bytes())
+		if err != nil {
+			return nil, err
+		}
+
+		switch entry.Direction {
+		case DIRECTION_HEADER:
+			// the declaration was validated when the plugin registered
+			if err := json.Unmarshal(entry.Data, &recording.Header); err != nil {
+				return nil, err
+			}
+		case DIRECTION_DAEMON:
+			message, err := parser.UnmarshalJsonBytes[struct {
+				Event string         `json:"event"`
+				Data  map[string]any `json:"data"`
+			}](entry.Data)
+			if err != nil || message.Event != string(session_manager.PLUGIN_IN_STREAM_EVENT_REQUEST) {
+				continue
+			}
+			if _, ok := sessions[entry.SessionID]; !ok {
+				sessions[entry.SessionID] = &Session{ID: entry.SessionID}
+				order = append(order, entry.SessionID)
+			}
+			sessions[entry.SessionID].Request = message.Data
+		case DIRECTION_PLUGIN:
+			session, ok := sessions[entry.SessionID]
+			if !ok {
+				continue
+			}
+			event, err := parser.UnmarshalJsonBytes[plugin_entities.PluginUniversalEvent](entry.Data)
+			if err != nil || event.Event != plugin_entities.PLUGIN_EVENT_SESSION {
+				continue
+			}
+			message, err := parser.UnmarshalJsonBytes[plugin_entities.SessionMessage](event.Data)
+			if err != nil {
+				continue
+			}
+			session.Messages = append(session.Messages, message)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if recording.Header.PluginUniqueIdentifier == "" {
+		return nil, errors.New("not a recording of a debugging session, the header is missing")
+	}
+
+	for _, id := range order {
+		recording.Sessions = append(recording.Sessions, *sessions[id])
+	}
+	return recording, nil
+}
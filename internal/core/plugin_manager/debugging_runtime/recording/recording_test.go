@@ -0,0 +1,137 @@
+package recording
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func TestRecordAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	recorder, err := NewRecorder(path, Header{
+		TenantID:               "tenant",
+		PluginUniqueIdentifier: "tenant/search:0.0.1@0123456789abcdef0123456789abcdef",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder.RecordDaemon([]byte(`{"event":"ping","data":{"id":"1"}}`))
+	recorder.RecordDaemon([]byte(`{"session_id":"s1","event":"request","data":{"user_id":"u","type":"tool","action":"invoke_tool","tool":"search"}}`))
+	recorder.RecordPlugin([]byte(`{"event":"heartbeat","data":{}}`))
+	recorder.RecordPlugin([]byte(`{"session_id":"s1","event":"session","data":{"type":"invoke","data":{"type":"llm"}}}`))
+	recorder.RecordDaemon([]byte(`{"session_id":"s1","event":"backwards_response","data":{"result":"ok"}}`))
+	recorder.RecordPlugin([]byte(`{"session_id":"s1","event":"session","data":{"type":"stream","data":{"text":"hello"}}}`))
+	recorder.RecordPlugin([]byte(`{"session_id":"s1","event":"session","data":{"type":"end","data":{}}}`))
+	recorder.RecordPlugin([]byte(`{"session_id":"unknown","event":"session","data":{"type":"end","data":{}}}`))
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// entries after closing are dropped
+	recorder.RecordPlugin([]byte(`{"session_id":"s1","event":"session","data":{"type":"end","data":{}}}`))
+
+	recording, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recording.Header.TenantID != "tenant" {
+		t.Fatalf("unexpected header %+v", recording.Header)
+	}
+	if len(recording.Sessions) != 1 {
+		t.Fatalf("expected one session, got %d", len(recording.Sessions))
+	}
+
+	session := recording.Sessions[0]
+	if session.ID != "s1" || session.Request["tool"] != "search" {
+		t.Fatalf("unexpected session %+v", session)
+	}
+	types := []plugin_entities.SESSION_MESSAGE_TYPE{}
+	for _, message := range session.Messages {
+		types = append(types, message.Type)
+	}
+	if len(types) != 3 || types[0] != plugin_entities.SESSION_MESSAGE_TYPE_INVOKE ||
+		types[1] != plugin_entities.SESSION_MESSAGE_TYPE_STREAM || types[2] != plugin_entities.SESSION_MESSAGE_TYPE_END {
+		t.Fatalf("unexpected messages %v", types)
+	}
+}
+
+func TestLoadRejectsOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	recorder, err := NewRecorder(path, Header{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.Close()
+
+	if _, err := Load(path); err == nil {
+		t.Fatal("expected a recording without plugin to be rejected")
+	}
+}
+
+func TestRecordDaemonRedactsCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	recorder, err := NewRecorder(path, Header{PluginUniqueIdentifier: "tenant/search:0.0.1@0123456789abcdef0123456789abcdef"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.RecordDaemon([]byte(`{"session_id":"s1","event":"request","data":{"type":"tool","tool":"search",` +
+		`"credentials":{"api_key":"sk-secret","scopes":["sk-scope"]},"system_credentials":{"client_secret":"cs-secret"}}}`))
+	recorder.Close()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"sk-secret", "sk-scope", "cs-secret"} {
+		if strings.Contains(string(content), secret) {
+			t.Fatalf("%s should be redacted from the recording", secret)
+		}
+	}
+
+	recording, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials, _ := recording.Sessions[0].Request["credentials"].(map[string]any)
+	if credentials["api_key"] != REDACTED || recording.Sessions[0].Request["tool"] != "search" {
+		t.Fatalf("unexpected request %+v", recording.Sessions[0].Request)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.jsonl")
+	recent := filepath.Join(dir, "recent.jsonl")
+	other := filepath.Join(dir, "notes.txt")
+	for _, path := range []string{old, recent, other} {
+		if err := os.WriteFile(path, []byte("{}"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := time.Now().Add(-time.Hour)
+	for _, path := range []string{old, other} {
+		if err := os.Chtimes(path, before.Add(-time.Minute), before.Add(-time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	purged, err := Purge(dir, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 1 {
+		t.Fatalf("expected 1 recording purged, got %d", purged)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatal("old recording should be purged")
+	}
+	for _, path := range []string{recent, other} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("%s should be kept: %v", path, err)
+		}
+	}
+}
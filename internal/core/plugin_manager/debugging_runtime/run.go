@@ -85,6 +85,8 @@ func (r *RemotePluginRuntime) StartPlugin() error {
 			func(message string) {
 				log.Info("plugin %s: %s", r.Configuration().Identity(), message)
 			},
+			nil,
+			nil,
 		)
 	})
 
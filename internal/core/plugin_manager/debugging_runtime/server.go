@@ -11,17 +11,28 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/debugging_runtime/recording"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/media_transport"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/retention"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/network"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 	"github.com/panjf2000/gnet/v2"
+	"google.golang.org/grpc"
 
 	gnet_errors "github.com/panjf2000/gnet/v2/pkg/errors"
 )
 
 type RemotePluginServer struct {
 	server *DifyServer
+
+	// grpc transport, disabled if grpcAddr is empty
+	grpcAddr     string
+	grpcCertFile string
+	grpcKeyFile  string
+	grpcServer   *grpc.Server
 }
 
 type RemotePluginServerInterface interface {
@@ -30,6 +41,7 @@ type RemotePluginServerInterface interface {
 	Wrap(f func(plugin_entities.PluginFullDuplexLifetime))
 	Stop() error
 	Launch() error
+	Connections() []DebuggingConnection
 }
 
 // continue accepting new connections
@@ -55,12 +67,20 @@ func (r *RemotePluginServer) Wrap(f func(plugin_entities.PluginFullDuplexLifetim
 	r.server.response.Async(f)
 }
 
+// Connections lists the debugging connections of current node
+func (r *RemotePluginServer) Connections() []DebuggingConnection {
+	return r.server.Connections()
+}
+
 // Stop stops the server
 func (r *RemotePluginServer) Stop() error {
 	if r.server.response == nil {
 		return errors.New("plugin server not started")
 	}
 	r.server.response.Close()
+	if r.grpcServer != nil {
+		r.grpcServer.Stop()
+	}
 	err := r.server.engine.Stop(context.Background())
 
 	if err == gnet_errors.ErrEmptyEngine || err == gnet_errors.ErrEngineInShutdown {
@@ -77,6 +97,12 @@ func (r *RemotePluginServer) Launch() error {
 
 	time.Sleep(time.Millisecond * 100)
 
+	if r.grpcAddr != "" {
+		if err := r.launchGrpc(); err != nil {
+			return err
+		}
+	}
+
 	err := gnet.Run(
 		r.server, r.server.addr, gnet.WithMulticore(r.server.multicore),
 		gnet.WithNumEventLoop(r.server.numLoops),
@@ -123,16 +149,40 @@ func NewRemotePluginServer(config *app.Config, media_transport *media_transport.
 		response:     response,
 
 		plugins:     make(map[int]*RemotePluginRuntime),
+		streams:     make(map[*RemotePluginRuntime]struct{}),
 		pluginsLock: &sync.RWMutex{},
 
 		shutdownChan: make(chan bool),
 
-		maxConn: int32(config.PluginRemoteInstallingMaxConn),
+		maxConn:       int32(config.PluginRemoteInstallingMaxConn),
+		maxTenantConn: config.PluginRemoteInstallingMaxSingleTenantConn,
+		recordDir:     config.PluginRemoteDebuggingRecordDir,
+
+		proxyProtocol: config.PluginRemoteInstallingProxyProtocolEnabled,
+	}
+
+	var err error
+	if s.trustedProxies, err = network.ParseIPNets(config.TrustedProxies); err != nil {
+		log.Panic("invalid trusted proxies: %s", err.Error())
+	}
+	if s.allowedIPs, err = network.ParseIPNets(config.PluginRemoteInstallingIPAllowlist); err != nil {
+		log.Panic("invalid plugin remote installing ip allowlist: %s", err.Error())
+	}
+	if s.recordDir != "" {
+		// recordings are files of this node, every node purges its own
+		retention.RegisterLocal(retention.CLASS_SESSION_RECORDINGS, func(before time.Time) (int64, error) {
+			return recording.Purge(s.recordDir, before)
+		})
 	}
 
 	manager := &RemotePluginServer{
 		server: s,
 	}
+	if config.PluginRemoteInstallingGrpcPort != 0 {
+		manager.grpcAddr = fmt.Sprintf("%s:%d", config.PluginRemoteInstallingHost, config.PluginRemoteInstallingGrpcPort)
+		manager.grpcCertFile = config.PluginRemoteInstallingGrpcTLSCertFile
+		manager.grpcKeyFile = config.PluginRemoteInstallingGrpcTLSKeyFile
+	}
 
 	return manager
 }
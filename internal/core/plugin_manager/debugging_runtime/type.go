@@ -7,9 +7,9 @@ import (
 	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/basic_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/debugging_runtime/recording"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
-	"github.com/panjf2000/gnet/v2"
 )
 
 type pluginRuntimeMode string
@@ -21,7 +21,7 @@ type RemotePluginRuntime struct {
 	plugin_entities.PluginRuntime
 
 	// connection
-	conn   gnet.Conn
+	conn   connection
 	closed int32
 
 	// response entity to accept new events
@@ -63,6 +63,20 @@ type RemotePluginRuntime struct {
 	// tenant id
 	tenantId string
 
+	// namespace of the developer within the tenant, empty for the default namespace
+	namespace string
+
+	// transport of the connection and when it was opened
+	transport   string
+	connectedAt time.Time
+
+	// connected describes the connection once the handshake completed
+	connected atomic.Pointer[DebuggingConnection]
+
+	// recorder of the session, nil unless PLUGIN_REMOTE_DEBUGGING_RECORD_DIR is set, it's set by the
+	// event loop and used by every goroutine writing to the plugin
+	recorder atomic.Pointer[recording.Recorder]
+
 	alive bool
 
 	// checksum
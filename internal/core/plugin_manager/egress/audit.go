@@ -0,0 +1,70 @@
+package egress
+
+import (
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/audit"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+const (
+	AUDIT_ACTOR_PLUGIN = "plugin"
+	// DENIAL_AUDIT_INTERVAL is how often a denied destination of a plugin is audited, a plugin retrying
+	// in a loop must not flood the audit table
+	DENIAL_AUDIT_INTERVAL = time.Minute
+	MAX_TRACKED_DENIALS   = 4096
+)
+
+type denial struct {
+	at    time.Time
+	count int
+}
+
+// recordDenial audits a denied request off the proxy, destinations denied within the interval are
+// counted into the next record
+func (p *Proxy) recordDenial(identity string, target string, method string) {
+	key := identity + " " + target
+	now := time.Now()
+
+	p.deniedMu.Lock()
+	last, ok := p.denied[key]
+	last.count++
+	if ok && now.Sub(last.at) < DENIAL_AUDIT_INTERVAL {
+		p.denied[key] = last
+		p.deniedMu.Unlock()
+		return
+	}
+	count := last.count
+	p.denied[key] = denial{at: now}
+	// drop destinations not denied recently
+	if len(p.denied) > MAX_TRACKED_DENIALS {
+		for key, entry := range p.denied {
+			if now.Sub(entry.at) >= DENIAL_AUDIT_INTERVAL {
+				delete(p.denied, key)
+			}
+		}
+	}
+	p.deniedMu.Unlock()
+
+	if !audit.Enabled() {
+		return
+	}
+	routine.Submit(map[string]string{
+		"module":   "egress",
+		"function": "recordDenial",
+	}, func() {
+		audit.Record(models.AuditRecord{
+			Event:   audit.EVENT_PLUGIN_EGRESS_DENY,
+			Actor:   AUDIT_ACTOR_PLUGIN + ":" + identity,
+			Result:  models.AuditResultFailure,
+			Message: ErrDenied.Error(),
+			Detail: map[string]any{
+				"plugin":      identity,
+				"destination": target,
+				"method":      method,
+				"count":       count,
+			},
+		})
+	})
+}
@@ -0,0 +1,111 @@
+package egress
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Policy is the set of destinations a plugin may reach, rules are either
+//   - `*` allowing everything
+//   - a domain like `api.openai.com`
+//   - a wildcard domain like `*.openai.com` matching its subdomains but not itself
+//   - an ip address or a cidr like `10.0.0.0/8`
+type Policy struct {
+	any      bool
+	hosts    map[string]bool
+	suffixes []string
+	networks []*net.IPNet
+}
+
+func NewPolicy(rules ...[]string) (*Policy, error) {
+	p := &Policy{hosts: map[string]bool{}}
+	for _, group := range rules {
+		for _, rule := range group {
+			if err := p.add(rule); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return p, nil
+}
+
+func (p *Policy) add(rule string) error {
+	rule = strings.ToLower(strings.TrimSpace(rule))
+	switch {
+	case rule == "":
+	case rule == "*":
+		p.any = true
+	case strings.Contains(rule, "/"):
+		_, network, err := net.ParseCIDR(rule)
+		if err != nil {
+			return fmt.Errorf("invalid egress rule %s: %s", rule, err.Error())
+		}
+		p.networks = append(p.networks, network)
+	case net.ParseIP(rule) != nil:
+		ip := net.ParseIP(rule)
+		bits := 8 * len(ip.To16())
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 32
+		}
+		p.networks = append(p.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	case strings.HasPrefix(rule, "*."):
+		p.suffixes = append(p.suffixes, rule[1:])
+	case strings.Contains(rule, "*"):
+		return fmt.Errorf("invalid egress rule %s: wildcards are only allowed as the first label", rule)
+	default:
+		p.hosts[strings.TrimSuffix(rule, ".")] = true
+	}
+	return nil
+}
+
+// AllowHost checks a host name against the domain rules
+func (p *Policy) AllowHost(host string) bool {
+	if p.any {
+		return true
+	}
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if p.hosts[host] {
+		return true
+	}
+	for _, suffix := range p.suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowIP checks an address against the cidr rules
+func (p *Policy) AllowIP(ip net.IP) bool {
+	if p.any {
+		return true
+	}
+
+	for _, network := range p.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasNetworks reports whether host names need to be resolved to be checked against cidr rules
+func (p *Policy) HasNetworks() bool {
+	return len(p.networks) > 0
+}
+
+// Unrestricted reports whether a rule opens most of the internet, `*` and networks wider than a /8,
+// plugins declaring such rules in manifest need the approval of an admin
+func Unrestricted(rule string) bool {
+	rule = strings.ToLower(strings.TrimSpace(rule))
+	if rule == "*" {
+		return true
+	}
+	if _, network, err := net.ParseCIDR(rule); err == nil {
+		ones, bits := network.Mask.Size()
+		return ones < bits/4
+	}
+	return false
+}
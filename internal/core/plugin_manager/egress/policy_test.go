@@ -0,0 +1,43 @@
+package egress
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	policy, err := NewPolicy([]string{"api.openai.com", "*.anthropic.com"}, []string{"10.0.0.0/8", "192.168.1.1", "::1"})
+	assert.NoError(t, err)
+
+	assert.True(t, policy.AllowHost("api.openai.com"))
+	assert.True(t, policy.AllowHost("API.OPENAI.COM."))
+	assert.False(t, policy.AllowHost("openai.com"))
+	assert.True(t, policy.AllowHost("api.anthropic.com"))
+	assert.False(t, policy.AllowHost("anthropic.com"))
+	assert.False(t, policy.AllowHost("evilanthropic.com"))
+
+	assert.True(t, policy.AllowIP(net.ParseIP("10.1.2.3")))
+	assert.True(t, policy.AllowIP(net.ParseIP("192.168.1.1")))
+	assert.False(t, policy.AllowIP(net.ParseIP("192.168.1.2")))
+	assert.True(t, policy.AllowIP(net.ParseIP("::1")))
+	assert.True(t, policy.HasNetworks())
+
+	policy, err = NewPolicy([]string{"*"})
+	assert.NoError(t, err)
+	assert.True(t, policy.AllowHost("example.com"))
+	assert.True(t, policy.AllowIP(net.ParseIP("8.8.8.8")))
+
+	for rule, unrestricted := range map[string]bool{
+		"*": true, "0.0.0.0/0": true, "0.0.0.0/1": true, "::/0": true,
+		"10.0.0.0/8": false, "api.openai.com": false, "*.openai.com": false,
+	} {
+		assert.Equal(t, unrestricted, Unrestricted(rule), rule)
+	}
+
+	_, err = NewPolicy([]string{"api.*.com"})
+	assert.Error(t, err)
+	_, err = NewPolicy([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,437 @@
+// Package egress enforces the destinations local plugins may connect to, the runtime points
+// HTTP_PROXY and HTTPS_PROXY of a plugin to a proxy served by the daemon which checks every
+// request against the policy of the plugin.
+package egress
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"golang.org/x/net/http/httpproxy"
+)
+
+const (
+	PROXY_AUTHENTICATE_REALM = "dify-plugin-egress"
+	DIAL_TIMEOUT             = 10 * time.Second
+)
+
+var (
+	ErrDenied = errors.New("egress denied by policy")
+)
+
+type Config struct {
+	// Address the proxy listens on, plugins run on the same host so loopback is enough
+	Address string
+	// Allowed rules apply to every plugin in addition to those declared in manifest
+	Allowed []string
+	// Unrestricted are plugin ids an admin approved to declare rules like `*` in manifest, see Unrestricted
+	Unrestricted []string
+}
+
+// Upstream is the proxy the egress proxy forwards allowed traffic of a plugin through, empty dials
+// destinations directly
+type Upstream struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+}
+
+type plugin struct {
+	identity string
+	policy   *Policy
+	upstream func(*url.URL) (*url.URL, error)
+}
+
+type Proxy struct {
+	config Config
+	url    string
+
+	mu      sync.RWMutex
+	plugins map[string]*plugin
+	tokens  map[string]string
+
+	dialer    *net.Dialer
+	transport *http.Transport
+	lookup    func(ctx context.Context, host string) ([]net.IP, error)
+
+	deniedMu sync.Mutex
+	denied   map[string]denial
+}
+
+type upstreamKey struct{}
+
+var (
+	globalProxy *Proxy
+)
+
+func NewProxy(config Config) *Proxy {
+	p := &Proxy{
+		config:  config,
+		plugins: map[string]*plugin{},
+		tokens:  map[string]string{},
+		denied:  map[string]denial{},
+		dialer:  &net.Dialer{Timeout: DIAL_TIMEOUT},
+		lookup: func(ctx context.Context, host string) ([]net.IP, error) {
+			return net.DefaultResolver.LookupIP(ctx, "ip", host)
+		},
+	}
+	p.transport = &http.Transport{
+		// decided by forward on the original host, the url of the request carries the checked address
+		Proxy: func(r *http.Request) (*url.URL, error) {
+			upstream, _ := r.Context().Value(upstreamKey{}).(*url.URL)
+			return upstream, nil
+		},
+		DialContext:     p.dialer.DialContext,
+		MaxIdleConns:    100,
+		IdleConnTimeout: 90 * time.Second,
+	}
+	return p
+}
+
+// Start listens on the configured address, plugins registered afterwards get their proxy url
+func Start(config Config) error {
+	p := NewProxy(config)
+
+	listener, err := net.Listen("tcp", config.Address)
+	if err != nil {
+		return err
+	}
+	p.setURL(listener.Addr())
+
+	server := &http.Server{Handler: p}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Panic("egress proxy stopped: %s", err.Error())
+		}
+	}()
+
+	globalProxy = p
+	log.Info("egress proxy listening on %s", listener.Addr().String())
+	return nil
+}
+
+func Enabled() bool {
+	return globalProxy != nil
+}
+
+// Register binds the rules of a plugin to a credential and returns the proxy url carrying it,
+// registering the same identity again replaces its rules and keeps the credential
+func Register(identity string, rules []string, upstream Upstream) (string, error) {
+	if globalProxy == nil {
+		return "", errors.New("egress proxy is not started")
+	}
+	return globalProxy.Register(identity, rules, upstream)
+}
+
+func (p *Proxy) setURL(addr net.Addr) {
+	host, port, _ := net.SplitHostPort(addr.String())
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	p.url = fmt.Sprintf("http://%s", net.JoinHostPort(host, port))
+}
+
+func (p *Proxy) Register(identity string, rules []string, upstream Upstream) (string, error) {
+	policy, err := NewPolicy(p.config.Allowed, p.approvedRules(identity, rules))
+	if err != nil {
+		return "", err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	token, ok := p.tokens[identity]
+	if !ok {
+		buf := make([]byte, 16)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		token = hex.EncodeToString(buf)
+		p.tokens[identity] = token
+	}
+	p.plugins[token] = &plugin{
+		identity: identity,
+		policy:   policy,
+		upstream: (&httpproxy.Config{
+			HTTPProxy:  upstream.HTTPProxy,
+			HTTPSProxy: upstream.HTTPSProxy,
+			NoProxy:    upstream.NoProxy,
+		}).ProxyFunc(),
+	}
+
+	return strings.Replace(p.url, "://", fmt.Sprintf("://%s:@", token), 1), nil
+}
+
+// approvedRules drops the unrestricted rules of plugins no admin approved, the plugin still starts
+// with the rest
+func (p *Proxy) approvedRules(identity string, rules []string) []string {
+	pluginID, _, _ := strings.Cut(identity, ":")
+	if slices.Contains(p.config.Unrestricted, pluginID) {
+		return rules
+	}
+
+	approved := []string{}
+	for _, rule := range rules {
+		if Unrestricted(rule) {
+			log.Warn("egress rule %s of plugin %s ignored, it needs the approval of an admin", rule, identity)
+			continue
+		}
+		approved = append(approved, rule)
+	}
+	return approved
+}
+
+// authenticate maps the basic credential of a request to the plugin it was issued to
+func (p *Proxy) authenticate(r *http.Request) *plugin {
+	auth, ok := strings.CutPrefix(r.Header.Get("Proxy-Authorization"), "Basic ")
+	if !ok {
+		return nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(auth)
+	if err != nil {
+		return nil
+	}
+	token, _, _ := strings.Cut(string(decoded), ":")
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.plugins[token]
+}
+
+// resolve returns the host to dial if the policy allows the destination, host names are resolved
+// only when cidr rules are involved and the address checked is dialed to avoid dns rebinding
+func (p *Proxy) resolve(ctx context.Context, policy *Policy, host string) (string, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		if policy.AllowIP(ip) {
+			return host, nil
+		}
+		return "", ErrDenied
+	}
+
+	if policy.AllowHost(host) {
+		return host, nil
+	}
+
+	if !policy.HasNetworks() {
+		return "", ErrDenied
+	}
+
+	ips, err := p.lookup(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	for _, ip := range ips {
+		if policy.AllowIP(ip) {
+			return ip.String(), nil
+		}
+	}
+	return "", ErrDenied
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	plugin := p.authenticate(r)
+	if plugin == nil {
+		w.Header().Set("Proxy-Authenticate", fmt.Sprintf(`Basic realm="%s"`, PROXY_AUTHENTICATE_REALM))
+		http.Error(w, "proxy authentication required", http.StatusProxyAuthRequired)
+		return
+	}
+
+	target := r.Host
+	if r.Method != http.MethodConnect {
+		if r.URL.Scheme != "http" {
+			http.Error(w, "only absolute http urls can be forwarded, use CONNECT for https", http.StatusBadRequest)
+			return
+		}
+		target = r.URL.Host
+	}
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		host, port = target, "80"
+	}
+
+	dialHost, err := p.resolve(r.Context(), plugin.policy, host)
+	if errors.Is(err, ErrDenied) {
+		log.Warn("egress of plugin %s to %s denied by policy, method %s", plugin.identity, target, r.Method)
+		p.recordDenial(plugin.identity, target, r.Method)
+		http.Error(w, ErrDenied.Error(), http.StatusForbidden)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	// the upstream is picked on the original host, it's asked for the checked address
+	scheme := "http"
+	if r.Method == http.MethodConnect {
+		scheme = "https"
+	}
+	upstreamURL, err := plugin.upstream(&url.URL{Scheme: scheme, Host: net.JoinHostPort(host, port)})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	if r.Method == http.MethodConnect {
+		p.tunnel(r.Context(), w, upstreamURL, net.JoinHostPort(dialHost, port))
+	} else {
+		p.forward(w, r, upstreamURL, net.JoinHostPort(dialHost, port))
+	}
+}
+
+// dialUpstream opens a tunnel to addr through the upstream proxy
+func (p *Proxy) dialUpstream(ctx context.Context, upstream *url.URL, addr string) (net.Conn, error) {
+	proxyAddr := upstream.Host
+	if upstream.Port() == "" {
+		port := "80"
+		if upstream.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(upstream.Hostname(), port)
+	}
+
+	conn, err := p.dialer.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if upstream.Scheme == "https" {
+		conn = tls.Client(conn, &tls.Config{ServerName: upstream.Hostname()})
+	}
+
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	if upstream.User != nil {
+		password, _ := upstream.User.Password()
+		credential := base64.StdEncoding.EncodeToString([]byte(upstream.User.Username() + ":" + password))
+		request.Header.Set("Proxy-Authorization", "Basic "+credential)
+	}
+
+	conn.SetDeadline(time.Now().Add(DIAL_TIMEOUT))
+	if err := request.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("upstream proxy refused to connect: %s", response.Status)
+	}
+	conn.SetDeadline(time.Time{})
+
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn replays bytes read past the response of the upstream proxy
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// tunnel serves CONNECT, the plugin speaks tls to the destination through it
+func (p *Proxy) tunnel(ctx context.Context, w http.ResponseWriter, upstreamURL *url.URL, addr string) {
+	var upstream net.Conn
+	var err error
+	if upstreamURL != nil {
+		upstream, err = p.dialUpstream(ctx, upstreamURL, addr)
+	} else {
+		upstream, err = p.dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+
+	if _, err := conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		conn.Close()
+		upstream.Close()
+		return
+	}
+
+	// bytes the client sent right after the CONNECT request may already be buffered
+	if n := buffered.Reader.Buffered(); n > 0 {
+		data, _ := buffered.Reader.Peek(n)
+		if _, err := upstream.Write(data); err != nil {
+			conn.Close()
+			upstream.Close()
+			return
+		}
+	}
+
+	go func() {
+		io.Copy(upstream, conn)
+		upstream.Close()
+	}()
+	go func() {
+		io.Copy(conn, upstream)
+		conn.Close()
+	}()
+}
+
+// forward serves plain http requests sent with an absolute url, the address checked against the
+// policy is dialed instead of resolving the host again, an upstream resolves the host of the url
+// sent to it on its own
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request, upstream *url.URL, addr string) {
+	request := r.Clone(context.WithValue(r.Context(), upstreamKey{}, upstream))
+	request.RequestURI = ""
+	request.URL.Host = addr
+	request.Header.Del("Proxy-Authorization")
+	request.Header.Del("Proxy-Connection")
+
+	response, err := p.transport.RoundTrip(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer response.Body.Close()
+
+	for key, values := range response.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(response.StatusCode)
+	io.Copy(w, response.Body)
+}
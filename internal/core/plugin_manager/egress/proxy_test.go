@@ -0,0 +1,161 @@
+package egress
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// get requests target through the proxy and returns the status, 0 if CONNECT failed
+func get(t *testing.T, proxyURL string, target string) int {
+	u, err := url.Parse(proxyURL)
+	assert.NoError(t, err)
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(u),
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+	response, err := client.Get(target)
+	if err != nil {
+		// CONNECT failures surface as errors instead of responses
+		return 0
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+	if response.StatusCode == http.StatusOK {
+		assert.Equal(t, "ok", string(body))
+	}
+	return response.StatusCode
+}
+
+func TestProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Proxy-Authorization"))
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+	tlsBackend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer tlsBackend.Close()
+
+	proxy := NewProxy(Config{})
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+	proxy.setURL(server.Listener.Addr())
+
+	allowed, err := proxy.Register("langgenius/openai:0.0.1", []string{"127.0.0.0/8"}, Upstream{})
+	assert.NoError(t, err)
+	denied, err := proxy.Register("langgenius/search:0.0.1", []string{"api.search.com"}, Upstream{})
+	assert.NoError(t, err)
+
+	get := func(proxyURL string, target string) int {
+		return get(t, proxyURL, target)
+	}
+
+	assert.Equal(t, http.StatusOK, get(allowed, backend.URL))
+	assert.Equal(t, http.StatusOK, get(allowed, tlsBackend.URL))
+	assert.Equal(t, http.StatusForbidden, get(denied, backend.URL))
+	assert.Equal(t, 0, get(denied, tlsBackend.URL))
+	assert.Equal(t, http.StatusProxyAuthRequired, get(server.URL, backend.URL))
+
+	// host names are resolved for cidr rules and the checked address is dialed
+	_, port, _ := net.SplitHostPort(backend.Listener.Addr().String())
+	proxy.lookup = func(_ context.Context, host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("127.0.0.1")}, nil
+	}
+	assert.Equal(t, http.StatusOK, get(allowed, "http://plugin.internal:"+port))
+
+	// registering again keeps the credential
+	again, err := proxy.Register("langgenius/openai:0.0.1", nil, Upstream{})
+	assert.NoError(t, err)
+	assert.Equal(t, allowed, again)
+	assert.Equal(t, http.StatusForbidden, get(allowed, backend.URL))
+}
+
+func TestProxyUpstream(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+	tlsBackend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer tlsBackend.Close()
+
+	// another egress proxy stands in for the upstream, it requires its own credential
+	upstream := NewProxy(Config{})
+	var forwarded atomic.Int32
+	upstreamServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded.Add(1)
+		upstream.ServeHTTP(w, r)
+	}))
+	defer upstreamServer.Close()
+	upstream.setURL(upstreamServer.Listener.Addr())
+	upstreamURL, err := upstream.Register("daemon", []string{"127.0.0.0/8"}, Upstream{})
+	assert.NoError(t, err)
+
+	proxy := NewProxy(Config{})
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+	proxy.setURL(server.Listener.Addr())
+
+	// loopback destinations never use a proxy, a host name resolving to loopback does
+	lookup := func(_ context.Context, host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("127.0.0.1")}, nil
+	}
+	proxy.lookup, upstream.lookup = lookup, lookup
+	plugin, err := proxy.Register("langgenius/openai:0.0.1", []string{"127.0.0.0/8"}, Upstream{
+		HTTPProxy:  upstreamURL,
+		HTTPSProxy: upstreamURL,
+		NoProxy:    "bypass.internal",
+	})
+	assert.NoError(t, err)
+
+	_, port, _ := net.SplitHostPort(backend.Listener.Addr().String())
+	assert.Equal(t, http.StatusOK, get(t, plugin, "http://plugin.internal:"+port))
+	assert.Equal(t, int32(1), forwarded.Load())
+
+	_, tlsPort, _ := net.SplitHostPort(tlsBackend.Listener.Addr().String())
+	assert.Equal(t, http.StatusOK, get(t, plugin, "https://plugin.internal:"+tlsPort))
+	assert.Equal(t, int32(2), forwarded.Load())
+
+	assert.Equal(t, http.StatusOK, get(t, plugin, "http://bypass.internal:"+port))
+	assert.Equal(t, int32(2), forwarded.Load())
+}
+
+func TestUnrestrictedRulesNeedApproval(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	proxy := NewProxy(Config{Unrestricted: []string{"langgenius/crawler"}})
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+	proxy.setURL(server.Listener.Addr())
+
+	unapproved, err := proxy.Register("langgenius/search:0.0.1", []string{"*", "0.0.0.0/0"}, Upstream{})
+	assert.NoError(t, err)
+	approved, err := proxy.Register("langgenius/crawler:0.0.1", []string{"*"}, Upstream{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusForbidden, get(t, unapproved, backend.URL))
+	assert.Equal(t, http.StatusOK, get(t, approved, backend.URL))
+
+	// denials are tracked for the audit, repeated ones are counted into the next record
+	for i := 0; i < 2; i++ {
+		get(t, unapproved, backend.URL)
+	}
+	proxy.deniedMu.Lock()
+	entry := proxy.denied["langgenius/search:0.0.1 "+backend.Listener.Addr().String()]
+	proxy.deniedMu.Unlock()
+	assert.Equal(t, 2, entry.count)
+}
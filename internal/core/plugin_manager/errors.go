@@ -1 +1,7 @@
 package plugin_manager
+
+import "errors"
+
+// ErrUpgradeRolledBack is returned by install tasks whose new version failed its health check, the
+// tenant is back on the previous version and retrying the task would fail the same way
+var ErrUpgradeRolledBack = errors.New("upgrade rolled back")
@@ -1 +1,42 @@
 package plugin_manager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+)
+
+func TestShutdownStopsRuntimes(t *testing.T) {
+	pm := &PluginManager{}
+	runtimes := []*fakePlugin{getRandomPluginRuntime(), getRandomPluginRuntime()}
+	for _, runtime := range runtimes {
+		pm.m.Store(runtime.Config.Name, runtime)
+	}
+
+	pm.Shutdown(context.Background())
+
+	for _, runtime := range runtimes {
+		if !runtime.Stopped() {
+			t.Fatalf("runtime %s is not stopped", runtime.Config.Name)
+		}
+	}
+	if !pm.shuttingDown.Load() {
+		t.Fatal("manager should refuse launching plugins after shutdown")
+	}
+}
+
+func TestInstallQueueRefusesTasksAfterShutdown(t *testing.T) {
+	q := &InstallQueue{slots: make(chan bool, 1)}
+	q.RegisterHandler(models.InstallTaskActionInstall, nil)
+
+	q.Shutdown(context.Background())
+
+	// no redis is configured, claiming the task would fail
+	if err := q.Enqueue(&models.InstallTask{Action: models.InstallTaskActionInstall}); err != nil {
+		t.Fatalf("enqueue after shutdown should be ignored, got %s", err.Error())
+	}
+	if stats := q.Stats(); stats.OwnedTasks != 0 || stats.Waiting != 0 {
+		t.Fatalf("no task should be scheduled after shutdown, got %+v", stats)
+	}
+}
@@ -0,0 +1,167 @@
+package install_hook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"gopkg.in/yaml.v3"
+)
+
+type Stage string
+
+const (
+	// steps of pre_install run before the plugin is launched or bound to the tenant, an error rejects it
+	STAGE_PRE_INSTALL Stage = "pre_install"
+	// steps of post_install run after the plugin is bound to the tenant, errors are only logged
+	STAGE_POST_INSTALL Stage = "post_install"
+
+	DEFAULT_STEP_TIMEOUT = 30 * time.Second
+)
+
+var (
+	ErrRejected = errors.New("plugin installation is rejected")
+)
+
+// Event describes the plugin being installed, it's what a step receives
+type Event struct {
+	Stage                  Stage          `json:"stage"`
+	TaskID                 string         `json:"task_id"`
+	TenantID               string         `json:"tenant_id"`
+	Action                 string         `json:"action"`
+	Source                 string         `json:"source"`
+	PluginUniqueIdentifier string         `json:"plugin_unique_identifier"`
+	PluginID               string         `json:"plugin_id"`
+	Author                 string         `json:"author"`
+	Version                string         `json:"version"`
+	Meta                   map[string]any `json:"meta"`
+}
+
+// Step is a custom step of the install pipeline, returning an error from a pre_install step rejects the plugin
+type Step interface {
+	Run(ctx context.Context, event *Event) error
+}
+
+// StepConfig is an entry of the hooks file, options are passed to the factory of its type
+type StepConfig struct {
+	Name     string         `yaml:"name"`
+	Type     string         `yaml:"type"`
+	Stage    Stage          `yaml:"stage"`
+	Timeout  int            `yaml:"timeout"` // in seconds
+	FailOpen bool           `yaml:"fail_open"`
+	Options  map[string]any `yaml:"options"`
+}
+
+// Factory creates a step from its entry in the hooks file
+type Factory func(config StepConfig) (Step, error)
+
+var (
+	factories     = map[string]Factory{}
+	factoriesLock sync.RWMutex
+)
+
+// Register adds a step type which can be referenced by the hooks file, custom Go steps are
+// registered from an init function of a package linked into the daemon
+func Register(name string, factory Factory) {
+	factoriesLock.Lock()
+	defer factoriesLock.Unlock()
+	factories[name] = factory
+}
+
+type configuredStep struct {
+	config StepConfig
+	step   Step
+}
+
+// Pipeline runs the configured steps of a stage in the order of the hooks file
+type Pipeline struct {
+	steps []configuredStep
+}
+
+type hooksFile struct {
+	Steps []StepConfig `yaml:"steps"`
+}
+
+// Load reads the hooks file at path, an empty path returns a pipeline without steps
+func Load(path string) (*Pipeline, error) {
+	pipeline := &Pipeline{}
+	if path == "" {
+		return pipeline, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := hooksFile{}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	for i, config := range file.Steps {
+		if config.Name == "" {
+			config.Name = fmt.Sprintf("%s#%d", config.Type, i)
+		}
+		if config.Stage != STAGE_PRE_INSTALL && config.Stage != STAGE_POST_INSTALL {
+			return nil, fmt.Errorf("unknown stage %q of install step %s", config.Stage, config.Name)
+		}
+
+		factoriesLock.RLock()
+		factory, ok := factories[config.Type]
+		factoriesLock.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown type %q of install step %s", config.Type, config.Name)
+		}
+
+		step, err := factory(config)
+		if err != nil {
+			return nil, errors.Join(err, fmt.Errorf("failed to create install step %s", config.Name))
+		}
+		pipeline.steps = append(pipeline.steps, configuredStep{config: config, step: step})
+	}
+
+	return pipeline, nil
+}
+
+// Run runs the steps of the stage of event one by one, the first pre_install step failed rejects
+// the plugin with an error wrapping ErrRejected unless the step is fail_open
+func (p *Pipeline) Run(ctx context.Context, event Event) error {
+	if p == nil {
+		return nil
+	}
+
+	for _, configured := range p.steps {
+		if configured.config.Stage != event.Stage {
+			continue
+		}
+
+		timeout := DEFAULT_STEP_TIMEOUT
+		if configured.config.Timeout > 0 {
+			timeout = time.Duration(configured.config.Timeout) * time.Second
+		}
+
+		stepCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := configured.step.Run(stepCtx, &event)
+		cancel()
+		if err == nil {
+			continue
+		}
+
+		if event.Stage == STAGE_POST_INSTALL || configured.config.FailOpen {
+			log.Warn(
+				"install step %s failed on %s of plugin %s: %s",
+				configured.config.Name, event.Stage, event.PluginUniqueIdentifier, err.Error(),
+			)
+			continue
+		}
+
+		return fmt.Errorf("%w by install step %s: %w", ErrRejected, configured.config.Name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package install_hook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type recordingStep struct {
+	name string
+	err  error
+	ran  *[]string
+}
+
+func (s *recordingStep) Run(ctx context.Context, event *Event) error {
+	*s.ran = append(*s.ran, s.name)
+	return s.err
+}
+
+func writeHooksFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "hooks.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPipelineRun(t *testing.T) {
+	ran := []string{}
+	Register("recording", func(config StepConfig) (Step, error) {
+		step := &recordingStep{name: config.Name, ran: &ran}
+		if failure, _ := config.Options["fail"].(string); failure != "" {
+			step.err = errors.New(failure)
+		}
+		return step, nil
+	})
+
+	pipeline, err := Load(writeHooksFile(t, `
+steps:
+  - name: audit
+    type: recording
+    stage: pre_install
+    fail_open: true
+    options:
+      fail: audit service is down
+  - name: policy
+    type: recording
+    stage: pre_install
+    options:
+      fail: author is not allowed
+  - name: never
+    type: recording
+    stage: pre_install
+  - name: cmdb
+    type: recording
+    stage: post_install
+    options:
+      fail: cmdb is down
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = pipeline.Run(context.Background(), Event{Stage: STAGE_PRE_INSTALL})
+	if !errors.Is(err, ErrRejected) || !strings.Contains(err.Error(), "author is not allowed") {
+		t.Fatalf("expected the plugin to be rejected by policy, got %v", err)
+	}
+	if strings.Join(ran, ",") != "audit,policy" {
+		t.Fatalf("expected steps to stop at the rejection, ran %v", ran)
+	}
+
+	ran = ran[:0]
+	if err := pipeline.Run(context.Background(), Event{Stage: STAGE_POST_INSTALL}); err != nil {
+		t.Fatalf("expected failures of post_install steps to be ignored, got %v", err)
+	}
+	if strings.Join(ran, ",") != "cmdb" {
+		t.Fatalf("expected only post_install steps to run, ran %v", ran)
+	}
+
+	if _, err := Load(writeHooksFile(t, "steps:\n  - type: unknown\n    stage: pre_install\n")); err == nil {
+		t.Fatal("expected unknown step types to be refused")
+	}
+	if _, err := Load(writeHooksFile(t, "steps:\n  - type: recording\n    stage: install\n")); err == nil {
+		t.Fatal("expected unknown stages to be refused")
+	}
+}
+
+func TestWebhookStep(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		event := Event{}
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if event.Author != "langgenius" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message": "only official plugins are allowed"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	step, err := newWebhookStep(StepConfig{Options: map[string]any{
+		"url":     server.URL,
+		"headers": map[string]any{"Authorization": "Bearer token"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := step.Run(context.Background(), &Event{Author: "langgenius"}); err != nil {
+		t.Fatalf("expected the plugin to be accepted, got %v", err)
+	}
+	err = step.Run(context.Background(), &Event{Author: "someone"})
+	if err == nil || !strings.Contains(err.Error(), "only official plugins are allowed") {
+		t.Fatalf("expected the reason of the rejection, got %v", err)
+	}
+
+	if _, err := newWebhookStep(StepConfig{}); err == nil {
+		t.Fatal("expected url to be required")
+	}
+}
@@ -0,0 +1,85 @@
+package install_hook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+)
+
+const (
+	STEP_WEBHOOK = "webhook"
+)
+
+func init() {
+	Register(STEP_WEBHOOK, newWebhookStep)
+}
+
+// WebhookStep posts the event as json to URL, a 2xx response accepts the plugin, otherwise the
+// response body, or its message field if it's json, is the reason of the rejection
+type WebhookStep struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client
+}
+
+type webhookResponse struct {
+	Message string `json:"message"`
+}
+
+func newWebhookStep(config StepConfig) (Step, error) {
+	url, _ := config.Options["url"].(string)
+	if url == "" {
+		return nil, errors.New("options.url is required by webhook step")
+	}
+
+	headers := map[string]string{}
+	if values, ok := config.Options["headers"].(map[string]any); ok {
+		for key, value := range values {
+			headers[key] = fmt.Sprint(value)
+		}
+	}
+
+	return &WebhookStep{
+		URL:     url,
+		Headers: headers,
+		Client:  &http.Client{},
+	}, nil
+}
+
+func (s *WebhookStep) Run(ctx context.Context, event *Event) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(parser.MarshalJsonBytes(event)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range s.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return err
+	}
+
+	message := strings.TrimSpace(string(content))
+	if response, err := parser.UnmarshalJsonBytes[webhookResponse](content); err == nil && response.Message != "" {
+		message = response.Message
+	}
+	return fmt.Errorf("webhook responded with status code %d: %s", resp.StatusCode, message)
+}
@@ -0,0 +1,30 @@
+package plugin_manager
+
+import (
+	"context"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/install_hook"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// RunInstallHooks runs the steps of stage configured by PLUGIN_INSTALL_HOOKS_PATH for a plugin of the task
+func (p *PluginManager) RunInstallHooks(
+	stage install_hook.Stage,
+	task *models.InstallTask,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	meta map[string]any,
+) error {
+	return p.installHooks.Run(context.Background(), install_hook.Event{
+		Stage:                  stage,
+		TaskID:                 task.ID,
+		TenantID:               task.TenantID,
+		Action:                 string(task.Action),
+		Source:                 task.Source,
+		PluginUniqueIdentifier: pluginUniqueIdentifier.String(),
+		PluginID:               pluginUniqueIdentifier.PluginID(),
+		Author:                 pluginUniqueIdentifier.Author(),
+		Version:                pluginUniqueIdentifier.Version().String(),
+		Meta:                   meta,
+	})
+}
@@ -0,0 +1,543 @@
+package plugin_manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/install_hook"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/mapping"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	"gorm.io/gorm"
+)
+
+const (
+	INSTALL_TASK_OWNER_KEY          = "install_task_owner"
+	INSTALL_TASK_OWNER_EXPIRE       = 60 * time.Second
+	INSTALL_TASK_RESUME_INTERVAL    = 30 * time.Second
+	INSTALL_TASK_RETRY_BACKOFF      = 5 * time.Second
+	INSTALL_TASK_CLEANUP_AFTER_DONE = 120 * time.Second
+	INSTALL_TASK_SHUTDOWN_POLL      = 100 * time.Millisecond
+)
+
+// ErrInstallQueueFull is returned by Enqueue instead of blocking when the plugins of the task don't fit
+var ErrInstallQueueFull = errors.New("install queue is full, retry later")
+
+// InstallTaskDoneHandler binds the installed plugin runtime to the tenant of the task
+type InstallTaskDoneHandler func(
+	task *models.InstallTask,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	declaration *plugin_entities.PluginDeclaration,
+	meta map[string]any,
+) error
+
+type InstallQueueStats struct {
+	Concurrency int   `json:"concurrency"`
+	Running     int32 `json:"running"`
+	Waiting     int32 `json:"waiting"`
+	QueueSize   int32 `json:"queue_size"`
+	OwnedTasks  int   `json:"owned_tasks"`
+}
+
+// InstallQueue runs install tasks in background with bounded concurrency, task state is persisted
+// in db so that tasks interrupted by a restart are picked up by any alive node
+type InstallQueue struct {
+	manager *PluginManager
+
+	// id identifies the owner of the tasks, it changes every time the daemon restarts
+	id string
+
+	slots      chan bool
+	maxRetries int
+
+	// size bounds the plugins waiting for a slot
+	size int32
+
+	handlers mapping.Map[models.InstallTaskAction, InstallTaskDoneHandler]
+
+	// unfinished plugins of the tasks owned by current node
+	owned mapping.Map[string, *atomic.Int32]
+
+	running atomic.Int32
+	waiting atomic.Int32
+
+	// stopped is set on shutdown, no task is claimed and no install is started afterwards
+	stopped atomic.Bool
+}
+
+func newInstallQueue(manager *PluginManager, configuration *app.Config) *InstallQueue {
+	return &InstallQueue{
+		manager:    manager,
+		id:         uuid.New().String(),
+		slots:      make(chan bool, configuration.PluginInstallConcurrency),
+		maxRetries: configuration.PluginInstallMaxRetries,
+		size:       int32(configuration.PluginInstallQueueSize),
+	}
+}
+
+func (p *PluginManager) InstallQueue() *InstallQueue {
+	return p.installQueue
+}
+
+func (q *InstallQueue) RegisterHandler(action models.InstallTaskAction, handler InstallTaskDoneHandler) {
+	q.handlers.Store(action, handler)
+}
+
+func (q *InstallQueue) Stats() InstallQueueStats {
+	ownedTasks := 0
+	q.owned.Range(func(key string, value *atomic.Int32) bool {
+		ownedTasks++
+		return true
+	})
+
+	return InstallQueueStats{
+		Concurrency: cap(q.slots),
+		Running:     q.running.Load(),
+		Waiting:     q.waiting.Load(),
+		QueueSize:   q.size,
+		OwnedTasks:  ownedTasks,
+	}
+}
+
+// Enqueue claims the task and schedules all its unfinished plugins
+func (q *InstallQueue) Enqueue(task *models.InstallTask) error {
+	if q.stopped.Load() {
+		return nil
+	}
+
+	if _, ok := q.handlers.Load(task.Action); !ok {
+		return fmt.Errorf("no handler registered for install task action: %s", task.Action)
+	}
+
+	if _, ok := q.owned.Load(task.ID); ok {
+		return nil
+	}
+
+	pending := []models.InstallTaskPluginStatus{}
+	for _, plugin := range task.Plugins {
+		if plugin.Status == models.InstallTaskStatusPending || plugin.Status == models.InstallTaskStatusRunning {
+			pending = append(pending, plugin)
+		}
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	if !q.reserve(int32(len(pending))) {
+		return ErrInstallQueueFull
+	}
+
+	claimed, err := cache.SetNX(q.ownerKey(task.ID), q.id, INSTALL_TASK_OWNER_EXPIRE)
+	if err != nil || !claimed {
+		q.waiting.Add(-int32(len(pending)))
+		return err
+	}
+
+	remaining := &atomic.Int32{}
+	remaining.Store(int32(len(pending)))
+	q.owned.Store(task.ID, remaining)
+
+	// plugins of the task finished on current node, dependencies not among them were installed already
+	finished := map[plugin_entities.PluginUniqueIdentifier]*installOutcome{}
+	for _, plugin := range pending {
+		finished[plugin.PluginUniqueIdentifier] = &installOutcome{done: make(chan struct{})}
+	}
+
+	for _, plugin := range pending {
+		pluginUniqueIdentifier := plugin.PluginUniqueIdentifier
+		outcome := finished[pluginUniqueIdentifier]
+		routine.Submit(map[string]string{
+			"module":   "plugin_manager",
+			"function": "InstallQueue",
+			"task_id":  task.ID,
+		}, func() {
+			defer close(outcome.done)
+
+			// waiting for dependencies takes no slot, otherwise they may never get one
+			for _, dependency := range plugin.DependsOn {
+				if dependencyOutcome, ok := finished[dependency]; ok {
+					<-dependencyOutcome.done
+					if !dependencyOutcome.installed && !q.stopped.Load() {
+						q.waiting.Add(-1)
+						q.fail(task.ID, pluginUniqueIdentifier, fmt.Errorf("dependency %s failed to install", dependency))
+						q.finish(task.ID, remaining)
+						return
+					}
+				}
+			}
+
+			q.slots <- true
+			q.waiting.Add(-1)
+			q.running.Add(1)
+			defer func() {
+				q.running.Add(-1)
+				<-q.slots
+			}()
+
+			// left pending, the task is released on shutdown
+			if q.stopped.Load() {
+				return
+			}
+
+			outcome.installed = q.run(task.ID, pluginUniqueIdentifier)
+			q.finish(task.ID, remaining)
+		})
+	}
+
+	return nil
+}
+
+// reserve counts the plugins as waiting if they fit in the queue, a task larger than
+// the queue is still taken when nothing else waits
+func (q *InstallQueue) reserve(plugins int32) bool {
+	for {
+		waiting := q.waiting.Load()
+		if waiting > 0 && waiting+plugins > q.size {
+			return false
+		}
+		if q.waiting.CompareAndSwap(waiting, waiting+plugins) {
+			return true
+		}
+	}
+}
+
+type installOutcome struct {
+	done      chan struct{}
+	installed bool
+}
+
+// finish drops the ownership of the task once all its plugins are done
+func (q *InstallQueue) finish(taskID string, remaining *atomic.Int32) {
+	// ownership may have been released on shutdown and claimed by another node already
+	if remaining.Add(-1) == 0 && !q.stopped.Load() {
+		q.owned.Delete(taskID)
+		cache.Del(q.ownerKey(taskID))
+	}
+}
+
+func (q *InstallQueue) ownerKey(taskID string) string {
+	return fmt.Sprintf("%s:%s", INSTALL_TASK_OWNER_KEY, taskID)
+}
+
+// launch keeps the ownership of running tasks alive and resumes tasks left by dead nodes
+func (q *InstallQueue) launch() {
+	routine.Submit(map[string]string{
+		"module":   "plugin_manager",
+		"function": "InstallQueueResume",
+	}, func() {
+		ticker := time.NewTicker(INSTALL_TASK_RESUME_INTERVAL)
+		defer ticker.Stop()
+
+		for {
+			q.resume()
+			<-ticker.C
+		}
+	})
+}
+
+func (q *InstallQueue) resume() {
+	if q.stopped.Load() {
+		return
+	}
+
+	q.owned.Range(func(taskID string, _ *atomic.Int32) bool {
+		if _, err := cache.Expire(q.ownerKey(taskID), INSTALL_TASK_OWNER_EXPIRE); err != nil {
+			log.Error("failed to refresh install task ownership %s: %s", taskID, err.Error())
+		}
+		return true
+	})
+
+	tasks, err := db.GetAll[models.InstallTask](
+		db.Equal("status", string(models.InstallTaskStatusRunning)),
+		db.NotEqual("action", ""),
+	)
+	if err != nil {
+		log.Error("failed to fetch unfinished install tasks: %s", err.Error())
+		return
+	}
+
+	for i := range tasks {
+		err := q.Enqueue(&tasks[i])
+		if errors.Is(err, ErrInstallQueueFull) {
+			// the rest is resumed on the next round
+			return
+		}
+		if err != nil {
+			log.Error("failed to resume install task %s: %s", tasks[i].ID, err.Error())
+		}
+	}
+}
+
+// run installs a plugin of the task with retries, returns whether it's installed
+func (q *InstallQueue) run(taskID string, pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier) bool {
+	var lastErr error
+	for attempt := 0; attempt <= q.maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * INSTALL_TASK_RETRY_BACKOFF)
+		}
+		if q.stopped.Load() {
+			return false
+		}
+
+		task, ok := q.updateTaskStatus(taskID, pluginUniqueIdentifier, func(task *models.InstallTask, plugin *models.InstallTaskPluginStatus) {
+			plugin.Status = models.InstallTaskStatusRunning
+			plugin.Message = "Installing"
+			plugin.Attempts++
+		})
+		if !ok {
+			// task has been deleted
+			return false
+		}
+
+		lastErr = q.install(task, pluginUniqueIdentifier)
+		if lastErr == nil {
+			q.updateTaskStatus(taskID, pluginUniqueIdentifier, func(task *models.InstallTask, plugin *models.InstallTaskPluginStatus) {
+				plugin.Status = models.InstallTaskStatusSuccess
+				plugin.Message = "Installed"
+				task.CompletedPlugins++
+			})
+			q.manager.RunInstallHooks(install_hook.STAGE_POST_INSTALL, task, pluginUniqueIdentifier, q.metaOf(task, pluginUniqueIdentifier))
+			return true
+		}
+		if errors.Is(lastErr, install_hook.ErrRejected) || errors.Is(lastErr, ErrUpgradeRolledBack) {
+			// rejections are decided by the operator, retrying gives the same answer
+			break
+		}
+
+		log.Warn(
+			"failed to install plugin %s of task %s, attempt %d: %s",
+			pluginUniqueIdentifier, taskID, attempt+1, lastErr.Error(),
+		)
+	}
+
+	q.fail(taskID, pluginUniqueIdentifier, lastErr)
+	return false
+}
+
+func (q *InstallQueue) fail(taskID string, pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier, err error) {
+	q.updateTaskStatus(taskID, pluginUniqueIdentifier, func(task *models.InstallTask, plugin *models.InstallTaskPluginStatus) {
+		task.Status = models.InstallTaskStatusFailed
+		plugin.Status = models.InstallTaskStatusFailed
+		plugin.Message = err.Error()
+	})
+}
+
+// Shutdown stops claiming tasks and waits for running installs until ctx is done, plugins not
+// installed by then are reset to pending and their tasks released for other nodes to resume
+func (q *InstallQueue) Shutdown(ctx context.Context) {
+	q.stopped.Store(true)
+
+	ticker := time.NewTicker(INSTALL_TASK_SHUTDOWN_POLL)
+	defer ticker.Stop()
+
+wait:
+	for q.running.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			log.Warn("%d installs are still running on shutdown, they will be resumed by other nodes", q.running.Load())
+			break wait
+		case <-ticker.C:
+		}
+	}
+
+	q.owned.Range(func(taskID string, _ *atomic.Int32) bool {
+		q.release(taskID)
+		return true
+	})
+}
+
+// release persists the interrupted plugins of an owned task as pending and drops the ownership
+func (q *InstallQueue) release(taskID string) {
+	if err := db.WithTransaction(func(tx *gorm.DB) error {
+		task, err := db.GetOne[models.InstallTask](
+			db.WithTransactionContext(tx),
+			db.Equal("id", taskID),
+			db.WLock(),
+		)
+		if err == db.ErrDatabaseNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for i := range task.Plugins {
+			if task.Plugins[i].Status == models.InstallTaskStatusRunning {
+				task.Plugins[i].Status = models.InstallTaskStatusPending
+				task.Plugins[i].Message = "Interrupted by shutdown"
+			}
+		}
+
+		return db.Update(&task, tx)
+	}); err != nil {
+		log.Error("failed to release install task %s: %s", taskID, err.Error())
+	}
+
+	q.owned.Delete(taskID)
+	if _, err := cache.Del(q.ownerKey(taskID)); err != nil {
+		log.Error("failed to release install task ownership %s: %s", taskID, err.Error())
+	}
+}
+
+func (q *InstallQueue) install(
+	task *models.InstallTask,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+) error {
+	handler, ok := q.handlers.Load(task.Action)
+	if !ok {
+		return fmt.Errorf("no handler registered for install task action: %s", task.Action)
+	}
+
+	meta := q.metaOf(task, pluginUniqueIdentifier)
+	if err := q.manager.RunInstallHooks(install_hook.STAGE_PRE_INSTALL, task, pluginUniqueIdentifier, meta); err != nil {
+		return err
+	}
+
+	runtimeType := plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL
+	if q.manager.config.Platform == app.PLATFORM_SERVERLESS {
+		runtimeType = plugin_entities.PLUGIN_RUNTIME_TYPE_SERVERLESS
+	}
+
+	declaration, err := helper.CombinedGetPluginDeclaration(pluginUniqueIdentifier, runtimeType)
+	if err != nil {
+		return err
+	}
+
+	// the runtime may have been installed before the daemon restarted
+	if _, err := db.GetOne[models.Plugin](
+		db.Equal("plugin_unique_identifier", pluginUniqueIdentifier.String()),
+	); err == nil {
+		return handler(task, pluginUniqueIdentifier, declaration, meta)
+	} else if err != db.ErrDatabaseNotFound {
+		return err
+	}
+
+	var response *stream.Stream[PluginInstallResponse]
+	switch q.manager.config.Platform {
+	case app.PLATFORM_SERVERLESS:
+		pkgFile, err := q.manager.GetPackage(pluginUniqueIdentifier)
+		if err != nil {
+			return errors.Join(err, errors.New("failed to read plugin package"))
+		}
+
+		zipDecoder, err := decoder.NewZipPluginDecoder(pkgFile)
+		if err != nil {
+			return err
+		}
+
+		response, err = q.manager.InstallToServerlessFromPkg(pkgFile, zipDecoder, task.Source, meta)
+		if err != nil {
+			return err
+		}
+	case app.PLATFORM_LOCAL:
+		response, err = q.manager.InstallToLocal(pluginUniqueIdentifier, task.Source, meta)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported platform: %s", q.manager.config.Platform)
+	}
+
+	for response.Next() {
+		message, err := response.Read()
+		if err != nil {
+			return err
+		}
+
+		switch message.Event {
+		case PluginInstallEventError:
+			return errors.New(message.Data)
+		case PluginInstallEventDone:
+			if err := handler(task, pluginUniqueIdentifier, declaration, meta); err != nil {
+				return errors.Join(err, errors.New("failed to create plugin, perhaps it's already installed"))
+			}
+		}
+	}
+
+	return nil
+}
+
+// metaOf returns the meta of a plugin of the task, never nil
+func (q *InstallQueue) metaOf(
+	task *models.InstallTask,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+) map[string]any {
+	for _, plugin := range task.Plugins {
+		if plugin.PluginUniqueIdentifier == pluginUniqueIdentifier && plugin.Meta != nil {
+			return plugin.Meta
+		}
+	}
+	return map[string]any{}
+}
+
+// updateTaskStatus modifies the task under a write lock, returns the updated task
+// and false if the task or the plugin no longer exists
+func (q *InstallQueue) updateTaskStatus(
+	taskID string,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	modifier func(task *models.InstallTask, plugin *models.InstallTaskPluginStatus),
+) (*models.InstallTask, bool) {
+	var updated *models.InstallTask
+	if err := db.WithTransaction(func(tx *gorm.DB) error {
+		task, err := db.GetOne[models.InstallTask](
+			db.WithTransactionContext(tx),
+			db.Equal("id", taskID),
+			db.WLock(), // write lock, multiple plugins can't update the same task
+		)
+		if err == db.ErrDatabaseNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var pluginStatus *models.InstallTaskPluginStatus
+		for i := range task.Plugins {
+			if task.Plugins[i].PluginUniqueIdentifier == pluginUniqueIdentifier {
+				pluginStatus = &task.Plugins[i]
+				break
+			}
+		}
+		if pluginStatus == nil {
+			return nil
+		}
+
+		modifier(&task, pluginStatus)
+
+		successes := 0
+		for _, plugin := range task.Plugins {
+			if plugin.Status == models.InstallTaskStatusSuccess {
+				successes++
+			}
+		}
+
+		if successes == len(task.Plugins) {
+			task.Status = models.InstallTaskStatusSuccess
+			// delete the task after a while without transaction
+			time.AfterFunc(INSTALL_TASK_CLEANUP_AFTER_DONE, func() {
+				db.Delete(&task)
+			})
+		}
+
+		updated = &task
+		return db.Update(&task, tx)
+	}); err != nil {
+		log.Error("failed to update install task status %s", err.Error())
+		return nil, false
+	}
+
+	return updated, updated != nil
+}
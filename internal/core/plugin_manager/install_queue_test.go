@@ -0,0 +1,153 @@
+package plugin_manager
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func newTestInstallQueue(t *testing.T, size int32) *InstallQueue {
+	config := &app.Config{
+		DBType:       "sqlite",
+		DBSqlitePath: filepath.Join(t.TempDir(), "install_queue.db"),
+		Platform:     app.PLATFORM_LOCAL,
+	}
+	config.SetDefault()
+	db.Init(config)
+	t.Cleanup(db.Close)
+	cache.InitMemoryClient(0)
+	routine.InitPool(16)
+
+	q := &InstallQueue{
+		manager: &PluginManager{config: config},
+		id:      "node",
+		slots:   make(chan bool, 1),
+		size:    size,
+	}
+	q.RegisterHandler(models.InstallTaskActionInstall, func(
+		*models.InstallTask,
+		plugin_entities.PluginUniqueIdentifier,
+		*plugin_entities.PluginDeclaration,
+		map[string]any,
+	) error {
+		return nil
+	})
+	return q
+}
+
+func testInstallTask(t *testing.T, plugins ...models.InstallTaskPluginStatus) *models.InstallTask {
+	task := &models.InstallTask{
+		Status:       models.InstallTaskStatusRunning,
+		TenantID:     "0e1b5c8e-7d4a-4f4e-9d33-6c7d9b0a1f21",
+		TotalPlugins: len(plugins),
+		Plugins:      plugins,
+		Action:       models.InstallTaskActionInstall,
+	}
+	if err := db.Create(task); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func testPendingPlugin(name string, dependsOn ...plugin_entities.PluginUniqueIdentifier) models.InstallTaskPluginStatus {
+	return models.InstallTaskPluginStatus{
+		PluginUniqueIdentifier: plugin_entities.PluginUniqueIdentifier("test/" + name + ":0.0.1@" + strings.Repeat("0", 64)),
+		Status:                 models.InstallTaskStatusPending,
+		DependsOn:              dependsOn,
+	}
+}
+
+func TestInstallQueueRefusesTasksWhenFull(t *testing.T) {
+	q := newTestInstallQueue(t, 1)
+	// a plugin already waits for the slot
+	q.waiting.Store(1)
+
+	task := testInstallTask(t, testPendingPlugin("a"))
+	done := make(chan error, 1)
+	go func() { done <- q.Enqueue(task) }()
+
+	select {
+	case err := <-done:
+		if err != ErrInstallQueueFull {
+			t.Fatalf("expected the queue to be full, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("enqueue should not block when the queue is full")
+	}
+
+	if stats := q.Stats(); stats.Waiting != 1 || stats.OwnedTasks != 0 || stats.QueueSize != 1 {
+		t.Fatalf("a refused task should not be scheduled, got %+v", stats)
+	}
+	if exists, _ := cache.Exist(q.ownerKey(task.ID)); exists != 0 {
+		t.Fatal("a refused task should not be claimed")
+	}
+}
+
+func TestInstallQueueTakesLargeTasksWhenEmpty(t *testing.T) {
+	q := newTestInstallQueue(t, 1)
+	if !q.reserve(3) {
+		t.Fatal("a task larger than the queue should be taken when nothing waits")
+	}
+	if q.reserve(1) {
+		t.Fatal("the queue should be full")
+	}
+}
+
+func TestInstallQueueFailsDependentsOfFailedPlugins(t *testing.T) {
+	q := newTestInstallQueue(t, 10)
+
+	dependency := testPendingPlugin("dependency")
+	dependent := testPendingPlugin("dependent", dependency.PluginUniqueIdentifier)
+	// the declaration of the dependency can't be found, its install fails
+	task := testInstallTask(t, dependency, dependent)
+
+	if err := q.Enqueue(task); err != nil {
+		t.Fatal(err)
+	}
+	// enqueueing a task owned already does nothing
+	if err := q.Enqueue(task); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for q.Stats().OwnedTasks > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("task is not finished, got %+v", q.Stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stored, err := db.GetOne[models.InstallTask](db.Equal("id", task.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.InstallTaskStatusFailed {
+		t.Fatalf("task should be failed, got %s", stored.Status)
+	}
+	for _, plugin := range stored.Plugins {
+		if plugin.Status != models.InstallTaskStatusFailed {
+			t.Fatalf("plugin %s should be failed, got %s", plugin.PluginUniqueIdentifier, plugin.Status)
+		}
+	}
+	if stored.Plugins[0].Attempts != 1 || stored.Plugins[1].Attempts != 0 {
+		t.Fatalf("the dependent should not be installed, got %+v", stored.Plugins)
+	}
+	if !strings.Contains(stored.Plugins[1].Message, "dependency") {
+		t.Fatalf("unexpected message %s", stored.Plugins[1].Message)
+	}
+
+	if stats := q.Stats(); stats.Waiting != 0 || stats.Running != 0 {
+		t.Fatalf("queue should be drained, got %+v", stats)
+	}
+	if exists, _ := cache.Exist(q.ownerKey(task.ID)); exists != 0 {
+		t.Fatal("ownership should be dropped once all plugins are done")
+	}
+}
@@ -18,12 +18,13 @@ func (p *PluginManager) InstallToLocal(
 ) (
 	*stream.Stream[PluginInstallResponse], error,
 ) {
-	packageFile, err := p.packageBucket.Get(plugin_unique_identifier.String())
+	packageFile, err := p.packageBucket.GetStream(plugin_unique_identifier.String())
 	if err != nil {
 		return nil, err
 	}
+	defer packageFile.Close()
 
-	err = p.installedBucket.Save(plugin_unique_identifier, packageFile)
+	err = p.installedBucket.SaveStream(plugin_unique_identifier, packageFile)
 	if err != nil {
 		return nil, err
 	}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 
 	serverless "github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector/azure_functions"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector/cloud_run"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector/self_hosted"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
@@ -11,6 +14,32 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
 )
 
+// launchServerless launches the plugin with the connector selected for it by CLOUD_RUN_PLUGINS,
+// AZURE_FUNCTIONS_PLUGINS or SELF_HOSTED_SERVERLESS_PLUGINS, the serverless connector is used otherwise
+func (p *PluginManager) launchServerless(
+	originalPackager []byte,
+	decoder decoder.PluginDecoder,
+	pluginID string,
+	ignoreIdempotent bool,
+) (*stream.Stream[serverless.LaunchFunctionResponse], models.ServerlessRuntimeType, error) {
+	timeout := p.config.DifyPluginServerlessConnectorLaunchTimeout
+	if cloud_run.Selected(pluginID) {
+		response, err := cloud_run.LaunchPlugin(originalPackager, decoder, timeout, ignoreIdempotent)
+		return response, models.SERVERLESS_RUNTIME_TYPE_CLOUD_RUN, err
+	}
+	if azure_functions.Selected(pluginID) {
+		response, err := azure_functions.LaunchPlugin(originalPackager, decoder, timeout, ignoreIdempotent)
+		return response, models.SERVERLESS_RUNTIME_TYPE_AZURE_FUNCTIONS, err
+	}
+	if self_hosted.Selected(pluginID) {
+		response, err := self_hosted.LaunchPlugin(originalPackager, decoder, timeout, ignoreIdempotent)
+		return response, self_hosted.RuntimeType(), err
+	}
+
+	response, err := serverless.LaunchPlugin(originalPackager, decoder, timeout, ignoreIdempotent)
+	return response, models.SERVERLESS_RUNTIME_TYPE_SERVERLESS, err
+}
+
 // InstallToServerlessFromPkg installs a plugin to Serverless
 func (p *PluginManager) InstallToServerlessFromPkg(
 	originalPackager []byte,
@@ -34,8 +63,8 @@ func (p *PluginManager) InstallToServerlessFromPkg(
 		return nil, err
 	}
 
-	// serverless.LaunchPlugin will check if the plugin has already been launched, if so, it returns directly
-	response, err := serverless.LaunchPlugin(originalPackager, decoder, p.config.DifyPluginServerlessConnectorLaunchTimeout, false)
+	// connectors check if the plugin has already been launched, if so, it returns directly
+	response, runtimeType, err := p.launchServerless(originalPackager, decoder, uniqueIdentity.PluginID(), false)
 	if err != nil {
 		return nil, err
 	}
@@ -72,13 +101,13 @@ func (p *PluginManager) InstallToServerlessFromPkg(
 				// check if the plugin is already installed
 				_, err := db.GetOne[models.ServerlessRuntime](
 					db.Equal("checksum", checksum),
-					db.Equal("type", string(models.SERVERLESS_RUNTIME_TYPE_SERVERLESS)),
+					db.Equal("type", string(runtimeType)),
 				)
 				if err == db.ErrDatabaseNotFound {
 					// create a new serverless runtime
 					serverlessModel := &models.ServerlessRuntime{
 						Checksum:               checksum,
-						Type:                   models.SERVERLESS_RUNTIME_TYPE_SERVERLESS,
+						Type:                   runtimeType,
 						FunctionURL:            functionUrl,
 						FunctionName:           functionName,
 						PluginUniqueIdentifier: uniqueIdentity.String(),
@@ -155,12 +184,8 @@ func (p *PluginManager) ReinstallToServerlessFromPkg(
 		return nil, err
 	}
 
-	response, err := serverless.LaunchPlugin(
-		originalPackager,
-		decoder,
-		p.config.DifyPluginServerlessConnectorLaunchTimeout,
-		true, // ignoreIdempotent, true means always reinstall
-	)
+	// ignoreIdempotent, true means always reinstall, the plugin moves if its connector has changed
+	response, runtimeType, err := p.launchServerless(originalPackager, decoder, uniqueIdentity.PluginID(), true)
 	if err != nil {
 		return nil, err
 	}
@@ -197,6 +222,7 @@ func (p *PluginManager) ReinstallToServerlessFromPkg(
 				// update serverless runtime
 				serverlessRuntime.FunctionURL = functionUrl
 				serverlessRuntime.FunctionName = functionName
+				serverlessRuntime.Type = runtimeType
 				err = db.Update(&serverlessRuntime)
 				if err != nil {
 					newResponse.Write(PluginInstallResponse{
@@ -6,9 +6,12 @@ import (
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/basic_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/egress"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/local_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/trust"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
@@ -102,6 +105,30 @@ func (p *PluginManager) launchLocal(pluginUniqueIdentifier plugin_entities.Plugi
 		return lifetime, c, errChan, nil
 	}
 
+	tier := trust.TierOf(plugin.decoder)
+	policy, err := p.applyTrustPolicy(tier, &plugin.runtime.Config)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	plugin.runtime.State.TrustTier = string(tier)
+
+	httpProxy, httpsProxy := p.config.HttpProxy, p.config.HttpsProxy
+	if policy.EgressProxy != "" {
+		httpProxy, httpsProxy = policy.EgressProxy, policy.EgressProxy
+	}
+
+	egressProxy := ""
+	if egress.Enabled() {
+		egressProxy, err = egress.Register(plugin.runtime.Config.Identity(), plugin.runtime.Config.Resource.Egress, egress.Upstream{
+			HTTPProxy:  httpProxy,
+			HTTPSProxy: httpsProxy,
+			NoProxy:    p.config.NoProxy,
+		})
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
 	// extract plugin
 	decoder, ok := plugin.decoder.(*decoder.ZipPluginDecoder)
 	if !ok {
@@ -110,6 +137,9 @@ func (p *PluginManager) launchLocal(pluginUniqueIdentifier plugin_entities.Plugi
 
 	// check if the working directory exists, if not, create it, otherwise, launch it directly
 	if _, err := os.Stat(plugin.runtime.State.WorkingPath); err != nil {
+		if err := p.scanBeforeLaunch(decoder); err != nil {
+			return nil, nil, nil, err
+		}
 		if err := decoder.ExtractTo(plugin.runtime.State.WorkingPath); err != nil {
 			return nil, nil, nil, errors.Join(err, fmt.Errorf("extract plugin to working directory error"))
 		}
@@ -134,14 +164,46 @@ func (p *PluginManager) launchLocal(pluginUniqueIdentifier plugin_entities.Plugi
 		UvPath:                    p.config.UvPath,
 		PythonEnvInitTimeout:      p.config.PythonEnvInitTimeout,
 		PythonCompileAllExtraArgs: p.config.PythonCompileAllExtraArgs,
-		HttpProxy:                 p.config.HttpProxy,
-		HttpsProxy:                p.config.HttpsProxy,
+		HttpProxy:                 httpProxy,
+		HttpsProxy:                httpsProxy,
 		NoProxy:                   p.config.NoProxy,
+		EgressProxy:               egressProxy,
 		PipMirrorUrl:              p.config.PipMirrorUrl,
 		PipPreferBinary:           *p.config.PipPreferBinary,
 		PipExtraArgs:              p.config.PipExtraArgs,
+		PythonEnvCachePath:        p.config.PythonEnvCachePath,
+		NodeInterpreterPath:       p.config.NodeInterpreterPath,
+		NodeEnvInitTimeout:        p.config.NodeEnvInitTimeout,
+		NpmPath:                   p.config.NpmPath,
+		PnpmPath:                  p.config.PnpmPath,
+		NpmRegistryUrl:            p.config.NpmRegistryUrl,
+		PipOfflineWheelsPath:      p.config.PipOfflineWheelsPath,
+		PythonVenvCacheEnabled:    p.config.PythonVenvCacheEnabled,
+		InstallOffline:            p.config.PluginInstallOffline,
 		StdoutBufferSize:          p.config.PluginStdioBufferSize,
 		StdoutMaxBufferSize:       p.config.PluginStdioMaxBufferSize,
+		HotReload:                 p.config.PluginHotReload,
+		ResourceLimitsEnabled:     p.config.PluginResourceLimitsEnabled,
+		MaxResources: local_runtime.ResourceLimits{
+			Memory:          p.config.PluginMaxMemory,
+			CPU:             p.config.PluginMaxCPU,
+			FileDescriptors: p.config.PluginMaxFileDescriptors,
+		},
+		CgroupRoot:             p.config.PluginCgroupRoot,
+		SandboxEnabled:         p.config.PluginSandboxEnabled,
+		SandboxReadOnlyPaths:   p.config.PluginSandboxReadOnlyPaths,
+		SandboxWritablePaths:   p.config.PluginSandboxWritablePaths,
+		SandboxAppArmorProfile: p.config.PluginSandboxAppArmorProfile,
+		LogBufferLines:         p.config.PluginLogBufferLines,
+		HealthCheckInterval:    time.Duration(p.config.PluginHealthCheckInterval) * time.Second,
+		HealthCheckTimeout:     time.Duration(p.config.PluginHealthCheckTimeout) * time.Second,
+		WarmPool:               p.warmPool,
+		BlobChannelEnabled:     p.config.PluginBlobChannelEnabled,
+		BlobOffloadThreshold:   p.config.PluginBlobOffloadThreshold,
+		BlobMaxSize:            p.config.PluginBlobMaxSize,
+		BlobMemoryLimit:        p.config.PluginBlobMemoryLimit,
+		Transport:              p.config.PluginLocalTransport,
+		EventEncodings:         p.config.PluginEventEncodings,
 	})
 	localPluginRuntime.PluginRuntime = plugin.runtime
 	localPluginRuntime.BasicChecksum = basic_runtime.BasicChecksum{
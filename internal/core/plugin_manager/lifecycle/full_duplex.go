@@ -5,10 +5,18 @@ import (
 	"sync"
 	"time"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/core/event_bus"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
+const (
+	RESTART_BACKOFF_MIN = 5 * time.Second
+	RESTART_BACKOFF_MAX = 5 * time.Minute
+	// RESTART_BACKOFF_RESET is how long a plugin must run to be considered recovered from crashes
+	RESTART_BACKOFF_RESET = 10 * time.Minute
+)
+
 func FullDuplex(
 	r plugin_entities.PluginFullDuplexLifetime,
 	launchedChan chan bool,
@@ -82,13 +90,19 @@ func FullDuplex(
 
 	// init environment successfully
 	// once succeed, we consider the plugin is installed successfully
+	crashes := 0
+	restarts := 0
 	for !r.Stopped() {
+		startedAt := time.Now()
+
 		// start plugin
 		if err := r.StartPlugin(); err != nil {
 			if r.Stopped() {
 				// plugin has been stopped, exit
 				break
 			}
+		} else if restarts > 0 {
+			publishRuntimeEvent(r, event_bus.EVENT_RUNTIME_RESTARTED, map[string]any{"restarts": restarts})
 		}
 
 		// wait for plugin to stop normally
@@ -96,13 +110,51 @@ func FullDuplex(
 		if err == nil {
 			<-c
 		}
-
-		// restart plugin in 5s (skip for debugging runtime)
+		if r.Stopped() {
+			break
+		}
+		publishRuntimeEvent(r, event_bus.EVENT_RUNTIME_CRASHED, map[string]any{
+			"restarts":       restarts,
+			"uptime_seconds": time.Since(startedAt).Seconds(),
+		})
+
+		// restart plugin after a backoff growing with consecutive crashes (skip for debugging runtime)
+		if time.Since(startedAt) > RESTART_BACKOFF_RESET {
+			crashes = 0
+		}
 		if r.Type() != plugin_entities.PLUGIN_RUNTIME_TYPE_REMOTE {
-			time.Sleep(5 * time.Second)
+			delay := RestartBackoff(crashes)
+			restartAt := time.Now().Add(delay)
+			r.SetNextRestartAt(&restartAt)
+			log.Info("restarting plugin %s in %s", configuration.Identity(), delay)
+			for time.Now().Before(restartAt) && !r.Stopped() {
+				time.Sleep(min(time.Second, time.Until(restartAt)))
+			}
+			r.SetNextRestartAt(nil)
 		}
+		crashes++
 
 		// add restart times
 		r.AddRestarts()
+		restarts++
+	}
+}
+
+// publishRuntimeEvent publishes crashes and restarts of runtimes, debugging runtimes going away
+// with their developers are not crashes
+func publishRuntimeEvent(r plugin_entities.PluginFullDuplexLifetime, eventType string, data map[string]any) {
+	if r.Type() == plugin_entities.PLUGIN_RUNTIME_TYPE_REMOTE {
+		return
+	}
+	identity, _ := r.Identity()
+	event_bus.Publish(eventType, "", identity.String(), data)
+}
+
+// RestartBackoff is the delay before restarting a plugin which crashed crashes times in a row
+func RestartBackoff(crashes int) time.Duration {
+	delay := RESTART_BACKOFF_MIN
+	for i := 0; i < crashes && delay < RESTART_BACKOFF_MAX; i++ {
+		delay *= 2
 	}
+	return min(delay, RESTART_BACKOFF_MAX)
 }
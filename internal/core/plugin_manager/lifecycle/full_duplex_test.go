@@ -0,0 +1,21 @@
+package lifecycle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRestartBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		0:   5 * time.Second,
+		1:   10 * time.Second,
+		3:   40 * time.Second,
+		6:   RESTART_BACKOFF_MAX,
+		100: RESTART_BACKOFF_MAX,
+	}
+	for crashes, expected := range cases {
+		if delay := RestartBackoff(crashes); delay != expected {
+			t.Fatalf("backoff after %d crashes: expected %s, got %s", crashes, expected, delay)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package local_runtime
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/blob_channel"
+)
+
+// attachBlobChannel passes a pipe written and a pipe read by the plugin as extra file descriptors, it
+// returns the channel of the daemon and the ends of the plugin, which are closed once it started,
+// extra file descriptors are not supported on windows
+func attachBlobChannel(cmd *exec.Cmd, memoryLimit int64) (*blob_channel.Channel, []*os.File, error) {
+	if runtime.GOOS == "windows" {
+		return nil, nil, nil
+	}
+
+	fromPlugin, pluginWriter, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	pluginReader, toPlugin, err := os.Pipe()
+	if err != nil {
+		fromPlugin.Close()
+		pluginWriter.Close()
+		return nil, nil, err
+	}
+
+	fd := 3 + len(cmd.ExtraFiles)
+	cmd.ExtraFiles = append(cmd.ExtraFiles, pluginWriter, pluginReader)
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d,%d", blob_channel.BLOB_CHANNEL_ENV, fd, fd+1))
+
+	return blob_channel.New(fromPlugin, toPlugin, 0, memoryLimit), []*os.File{pluginWriter, pluginReader}, nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, file := range files {
+		file.Close()
+	}
+}
@@ -0,0 +1,29 @@
+package local_runtime
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile creates dst sharing the extents of src copy on write, filesystems without reflinks fail
+func cloneFile(src string, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlFileClone(int(out.Fd()), int(in.Fd())); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
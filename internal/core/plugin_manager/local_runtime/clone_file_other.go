@@ -0,0 +1,9 @@
+//go:build !linux
+
+package local_runtime
+
+import "errors"
+
+func cloneFile(src string, dst string) error {
+	return errors.ErrUnsupported
+}
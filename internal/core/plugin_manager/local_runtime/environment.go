@@ -2,6 +2,7 @@ package local_runtime
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/constants"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
@@ -9,9 +10,12 @@ import (
 
 func (r *LocalPluginRuntime) InitEnvironment() error {
 	var err error
-	if r.Config.Meta.Runner.Language == constants.Python {
+	switch r.Config.Meta.Runner.Language {
+	case constants.Python:
 		err = r.InitPythonEnvironment()
-	} else {
+	case constants.NodeJS:
+		err = r.InitNodeEnvironment()
+	default:
 		return fmt.Errorf("unsupported language: %s", r.Config.Meta.Runner.Language)
 	}
 
@@ -29,3 +33,9 @@ func (r *LocalPluginRuntime) Identity() (plugin_entities.PluginUniqueIdentifier,
 	}
 	return plugin_entities.NewPluginUniqueIdentifier(fmt.Sprintf("%s@%s", r.Config.Identity(), checksum))
 }
+
+// versionMatches reports whether an interpreter of version satisfies the pinned one, 3.11 is
+// satisfied by 3.11.9 but not by 3.110
+func versionMatches(version string, pinned string) bool {
+	return version == pinned || strings.HasPrefix(version, pinned+".")
+}
@@ -0,0 +1,154 @@
+package local_runtime
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+var nodeVersionPattern = regexp.MustCompile(`^\d+(\.\d+){0,2}$`)
+
+// nodeInstallEnv are the variables of the daemon passed to package managers, the environment of the
+// daemon holds its secrets and packages being installed are not trusted
+var nodeInstallEnv = []string{
+	"PATH", "HOME", "TMPDIR", "LANG",
+	"USERPROFILE", "APPDATA", "LOCALAPPDATA", "SYSTEMROOT", "TEMP", "TMP",
+}
+
+// nodeVersion is the node major, minor or exact version pinned by meta.runner.version, empty if the
+// plugin runs on any version
+func (p *LocalPluginRuntime) nodeVersion() string {
+	pinned := strings.TrimPrefix(strings.TrimSpace(p.Config.Meta.Runner.Version), "v")
+	if nodeVersionPattern.MatchString(pinned) {
+		return pinned
+	}
+	if pinned != "" {
+		log.Warn("plugin %s pins an unknown node version %s, using any", p.Config.Identity(), pinned)
+	}
+	return ""
+}
+
+// checkNodeVersion makes sure the node of the daemon is the one the plugin pinned, node has no
+// environment of its own to select an interpreter like uv does
+func (p *LocalPluginRuntime) checkNodeVersion() error {
+	pinned := p.nodeVersion()
+	if pinned == "" {
+		return nil
+	}
+	output, err := exec.Command(p.nodeInterpreterPath, "--version").Output()
+	if err != nil {
+		return fmt.Errorf("failed to find node: %s", err)
+	}
+	installed := strings.TrimPrefix(strings.TrimSpace(string(output)), "v")
+	if !versionMatches(installed, pinned) {
+		return fmt.Errorf("plugin requires node %s but %s is installed", pinned, installed)
+	}
+	return nil
+}
+
+// nodeInstallCommand installs the production dependencies with the package manager the lockfile
+// belongs to, npm if there is none, lifecycle scripts of dependencies would run in the daemon
+// unsandboxed and are skipped, packages building native addons in install scripts are not supported
+func (p *LocalPluginRuntime) nodeInstallCommand(ctx context.Context) *exec.Cmd {
+	var args []string
+	name := p.npmPath
+	if _, err := os.Stat(path.Join(p.State.WorkingPath, "pnpm-lock.yaml")); err == nil {
+		name = p.pnpmPath
+		args = []string{"install", "--prod", "--frozen-lockfile"}
+	} else if _, err := os.Stat(path.Join(p.State.WorkingPath, "package-lock.json")); err == nil {
+		args = []string{"ci", "--omit=dev"}
+	} else {
+		args = []string{"install", "--omit=dev"}
+	}
+	args = append(args, "--ignore-scripts")
+	if p.installOffline {
+		// packages must be in the cache of the package manager already
+		args = append(args, "--offline")
+	} else if p.npmRegistryUrl != "" {
+		args = append(args, "--registry", p.npmRegistryUrl)
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = p.State.WorkingPath
+	cmd.Env = []string{}
+	for _, key := range nodeInstallEnv {
+		if value, ok := os.LookupEnv(key); ok {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
+		}
+	}
+	if p.HttpProxy != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("HTTP_PROXY=%s", p.HttpProxy))
+	}
+	if p.HttpsProxy != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("HTTPS_PROXY=%s", p.HttpsProxy))
+	}
+	if p.NoProxy != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("NO_PROXY=%s", p.NoProxy))
+	}
+	return cmd
+}
+
+func (p *LocalPluginRuntime) InitNodeEnvironment() error {
+	if err := p.checkNodeVersion(); err != nil {
+		return err
+	}
+
+	modulesPath := path.Join(p.State.WorkingPath, "node_modules")
+	pluginJsonPath := path.Join(modulesPath, ".dify/plugin.json")
+	// node_modules is only valid if the install finished
+	if _, err := os.Stat(pluginJsonPath); err == nil {
+		return nil
+	}
+	os.RemoveAll(modulesPath)
+
+	if _, err := os.Stat(path.Join(p.State.WorkingPath, "package.json")); err != nil {
+		return fmt.Errorf("failed to find package.json: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.nodeEnvInitTimeout)*time.Second)
+	defer cancel()
+
+	cmd := p.nodeInstallCommand(ctx)
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+
+	var output strings.Builder
+	done := make(chan struct{})
+	routine.Submit(map[string]string{
+		"module":   "plugin_manager",
+		"function": "InitNodeEnvironment",
+	}, func() {
+		defer close(done)
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			log.Info("installing %s - %s", p.Config.Identity(), scanner.Text())
+			output.WriteString(scanner.Text() + "\n")
+		}
+	})
+
+	err := cmd.Run()
+	writer.Close()
+	<-done
+	if err != nil {
+		os.RemoveAll(modulesPath)
+		if ctx.Err() != nil {
+			return fmt.Errorf("failed to install dependencies in %d seconds, output: %s", p.nodeEnvInitTimeout, output.String())
+		}
+		return fmt.Errorf("failed to install dependencies: %s, output: %s", err, output.String())
+	}
+
+	os.MkdirAll(path.Dir(pluginJsonPath), 0755)
+	return os.WriteFile(pluginJsonPath, []byte(`{"timestamp":`+strconv.FormatInt(time.Now().Unix(), 10)+`}`), 0644)
+}
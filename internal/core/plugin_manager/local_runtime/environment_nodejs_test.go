@@ -0,0 +1,63 @@
+package local_runtime
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTool writes a shell script standing in for node or a package manager
+func fakeTool(t *testing.T, name string, script string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestInitNodeEnvironment(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake tools are shell scripts")
+	}
+	routine.InitPool(100)
+
+	workingPath := t.TempDir()
+	os.WriteFile(filepath.Join(workingPath, "package.json"), []byte(`{"name":"plugin"}`), 0o644)
+	os.WriteFile(filepath.Join(workingPath, "package-lock.json"), []byte(`{}`), 0o644)
+
+	localRuntime := &LocalPluginRuntime{
+		nodeInterpreterPath: fakeTool(t, "node", "echo v20.11.1"),
+		npmPath:             fakeTool(t, "npm", `echo "$@" > args; env > env; mkdir -p node_modules/dify-plugin`),
+		pnpmPath:            fakeTool(t, "pnpm", "exit 1"),
+		nodeEnvInitTimeout:  30,
+	}
+	localRuntime.State.WorkingPath = workingPath
+	localRuntime.Config.Meta.Runner.Version = "20"
+	t.Setenv("DB_PASSWORD", "secret")
+
+	assert.NoError(t, localRuntime.InitNodeEnvironment())
+	args, _ := os.ReadFile(filepath.Join(workingPath, "args"))
+	assert.Equal(t, "ci --omit=dev --ignore-scripts", strings.TrimSpace(string(args)))
+	// package managers only get what they need of the environment of the daemon
+	env, _ := os.ReadFile(filepath.Join(workingPath, "env"))
+	assert.Contains(t, string(env), "PATH=")
+	assert.NotContains(t, string(env), "DB_PASSWORD")
+	assert.FileExists(t, filepath.Join(workingPath, "node_modules/.dify/plugin.json"))
+
+	// a finished install is kept, pnpm would fail
+	os.WriteFile(filepath.Join(workingPath, "pnpm-lock.yaml"), []byte(""), 0o644)
+	assert.NoError(t, localRuntime.InitNodeEnvironment())
+
+	os.RemoveAll(filepath.Join(workingPath, "node_modules"))
+	assert.Error(t, localRuntime.InitNodeEnvironment())
+	assert.NoDirExists(t, filepath.Join(workingPath, "node_modules"))
+
+	localRuntime.Config.Meta.Runner.Version = "22"
+	err := localRuntime.InitNodeEnvironment()
+	assert.ErrorContains(t, err, "requires node 22")
+}
@@ -18,6 +18,7 @@ import (
 	version "github.com/hashicorp/go-version"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/consts"
 )
 
 //go:embed patches/0.0.1b70.ai_model.py.patch
@@ -29,13 +30,78 @@ var python011llmPatches []byte
 //go:embed patches/0.1.1.request_reader.py.patch
 var python011requestReaderPatches []byte
 
+// DEFAULT_PYTHON_VERSION is used if the manifest does not pin a version uv understands
+const DEFAULT_PYTHON_VERSION = "3.12"
+
+var pythonVersionPattern = regexp.MustCompile(`^3\.\d+(\.\d+)?$`)
+
+// pythonVersion is the interpreter pinned by meta.runner.version, 3.11.9 selects exactly that release
+// and 3.11 the latest 3.11 uv finds or downloads
+func (p *LocalPluginRuntime) pythonVersion() string {
+	pinned := strings.TrimSpace(p.Config.Meta.Runner.Version)
+	if pythonVersionPattern.MatchString(pinned) {
+		return pinned
+	}
+	if pinned != "" {
+		log.Warn("plugin %s pins an unknown python version %s, using %s", p.Config.Identity(), pinned, DEFAULT_PYTHON_VERSION)
+	}
+	return DEFAULT_PYTHON_VERSION
+}
+
+// pythonInstallOffline forbids uv to reach the network, dependencies come from the wheels bundled
+// into the package and the offline wheels of the daemon
+func (p *LocalPluginRuntime) pythonInstallOffline() bool {
+	return p.installOffline || p.pipOfflineWheelsPath != ""
+}
+
+// pythonFindLinks are the directories of wheels available to the plugin
+func (p *LocalPluginRuntime) pythonFindLinks() ([]string, error) {
+	links := []string{}
+	bundled := path.Join(p.State.WorkingPath, consts.DEPENDENCY_BUNDLE_DIR)
+	if info, err := os.Stat(bundled); err == nil && info.IsDir() {
+		bundledPath, err := filepath.Abs(bundled)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, bundledPath)
+	}
+	if p.pipOfflineWheelsPath != "" {
+		wheelsPath, err := filepath.Abs(p.pipOfflineWheelsPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find offline wheels: %s", err)
+		}
+		links = append(links, wheelsPath)
+	}
+	if p.installOffline && len(links) == 0 {
+		return nil, fmt.Errorf("installing offline but the plugin bundles no dependencies in %s and PIP_OFFLINE_WHEELS_PATH is not set", consts.DEPENDENCY_BUNDLE_DIR)
+	}
+	return links, nil
+}
+
+// uvEnv shares the cache of wheels and interpreters across plugins, it is linked into each venv
+func (p *LocalPluginRuntime) uvEnv() []string {
+	if p.pythonEnvCachePath == "" {
+		return nil
+	}
+	cachePath, err := filepath.Abs(p.pythonEnvCachePath)
+	if err != nil {
+		return nil
+	}
+	return []string{"UV_CACHE_DIR=" + cachePath}
+}
+
 func (p *LocalPluginRuntime) InitPythonEnvironment() error {
+	venvPath := path.Join(p.State.WorkingPath, ".venv")
 	// check if virtual environment exists
-	if _, err := os.Stat(path.Join(p.State.WorkingPath, ".venv")); err == nil {
+	if _, err := os.Stat(venvPath); err == nil {
 		// check if venv is valid, try to find .venv/dify/plugin.json
+		version, versionErr := venvPythonFullVersion(venvPath)
 		if _, err := os.Stat(path.Join(p.State.WorkingPath, ".venv/dify/plugin.json")); err != nil {
 			// remove the venv and rebuild it
-			os.RemoveAll(path.Join(p.State.WorkingPath, ".venv"))
+			os.RemoveAll(venvPath)
+		} else if versionErr != nil || !versionMatches(version, p.pythonVersion()) {
+			log.Info("rebuilding the environment of %s with python %s instead of %s", p.Config.Identity(), p.pythonVersion(), version)
+			os.RemoveAll(venvPath)
 		} else {
 			// setup python interpreter path
 			pythonPath, err := filepath.Abs(path.Join(p.State.WorkingPath, ".venv/bin/python"))
@@ -71,8 +137,14 @@ func (p *LocalPluginRuntime) InitPythonEnvironment() error {
 		uvPath = strings.TrimSpace(string(output))
 	}
 
-	cmd := exec.Command(uvPath, "venv", ".venv", "--python", "3.12")
+	venvArgs := []string{"venv", ".venv", "--python", p.pythonVersion()}
+	if p.pythonInstallOffline() {
+		// the interpreter must be installed or in the cache already
+		venvArgs = append(venvArgs, "--offline")
+	}
+	cmd := exec.Command(uvPath, venvArgs...)
 	cmd.Dir = p.State.WorkingPath
+	cmd.Env = append(os.Environ(), p.uvEnv()...)
 	b := bytes.NewBuffer(nil)
 	cmd.Stdout = b
 	cmd.Stderr = b
@@ -112,9 +184,151 @@ func (p *LocalPluginRuntime) InitPythonEnvironment() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer cancel()
 
+	virtualEnvPath := path.Join(p.State.WorkingPath, ".venv")
+	cacheKey := ""
+	if p.pythonVenvCacheEnabled {
+		cacheKey, err = p.venvCacheKey(virtualEnvPath, requirementsPath)
+		if err != nil {
+			log.Warn("failed to hash the environment of %s: %s", p.Config.Identity(), err)
+		}
+	}
+	if cacheKey != "" && p.restoreVenvCache(cacheKey, virtualEnvPath) {
+		log.Info("reused a prepared environment for %s", p.Config.Identity())
+	} else {
+		if err := p.installPythonRequirements(ctx, uvPath, virtualEnvPath); err != nil {
+			return err
+		}
+		if cacheKey != "" {
+			p.storeVenvCache(cacheKey, virtualEnvPath)
+		}
+	}
+
+	compileArgs := []string{"-m", "compileall"}
+	if p.pythonCompileAllExtraArgs != "" {
+		compileArgs = append(compileArgs, strings.Split(p.pythonCompileAllExtraArgs, " ")...)
+	}
+	compileArgs = append(compileArgs, ".")
+
+	// pre-compile the plugin to avoid costly compilation on first invocation
+	compileCmd := exec.CommandContext(ctx, pythonPath, compileArgs...)
+	compileCmd.Dir = p.State.WorkingPath
+
+	// get stdout and stderr
+	compileStdout, err := compileCmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get stdout: %s", err)
+	}
+	defer compileStdout.Close()
+
+	compileStderr, err := compileCmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get stderr: %s", err)
+	}
+	defer compileStderr.Close()
+
+	// start command
+	if err := compileCmd.Start(); err != nil {
+		return fmt.Errorf("failed to start command: %s", err)
+	}
+	defer func() {
+		if compileCmd.Process != nil {
+			compileCmd.Process.Kill()
+		}
+	}()
+
+	var compileErrMsg strings.Builder
+	var compileWg sync.WaitGroup
+	compileWg.Add(2)
+
+	routine.Submit(map[string]string{
+		"module":   "plugin_manager",
+		"function": "InitPythonEnvironment",
+	}, func() {
+		defer compileWg.Done()
+		// read compileStdout
+		for {
+			buf := make([]byte, 102400)
+			n, err := compileStdout.Read(buf)
+			if err != nil {
+				break
+			}
+			// split to first line
+			lines := strings.Split(string(buf[:n]), "\n")
+
+			for len(lines) > 0 && len(lines[0]) == 0 {
+				lines = lines[1:]
+			}
+
+			if len(lines) > 0 {
+				if len(lines) > 1 {
+					log.Info("pre-compiling %s - %s...", p.Config.Identity(), lines[0])
+				} else {
+					log.Info("pre-compiling %s - %s", p.Config.Identity(), lines[0])
+				}
+			}
+		}
+	})
+
+	routine.Submit(map[string]string{
+		"module":   "plugin_manager",
+		"function": "InitPythonEnvironment",
+	}, func() {
+		defer compileWg.Done()
+		// read stderr
+		buf := make([]byte, 1024)
+		for {
+			n, err := compileStderr.Read(buf)
+			if err != nil {
+				break
+			}
+			compileErrMsg.WriteString(string(buf[:n]))
+		}
+	})
+
+	compileWg.Wait()
+	if err := compileCmd.Wait(); err != nil {
+		// skip the error if the plugin is not compiled
+		// ISSUE: for some weird reasons, plugins may reference to a broken sdk but it works well itself
+		// we need to skip it but log the messages
+		// https://github.com/langgenius/dify/issues/16292
+		log.Warn("failed to pre-compile the plugin: %s", compileErrMsg.String())
+	}
+
+	log.Info("pre-loaded the plugin %s", p.Config.Identity())
+
+	// import dify_plugin to speedup the first launching
+	// ISSUE: it takes too long to setup all the deps, that's why we choose to preload it
+	importCmd := exec.CommandContext(ctx, pythonPath, "-c", "import dify_plugin")
+	importCmd.Dir = p.State.WorkingPath
+	importCmd.Output()
+
+	// PATCH:
+	//  plugin sdk version less than 0.0.1b70 contains a memory leak bug
+	//  to reach a better user experience, we will patch it here using a patched file
+	// https://github.com/langgenius/dify-plugin-sdks/commit/161045b65f708d8ef0837da24440ab3872821b3b
+	if err := p.patchPluginSdk(requirementsPath); err != nil {
+		log.Error("failed to patch the plugin sdk: %s", err)
+	}
+
+	success = true
+
+	return nil
+}
+
+// installPythonRequirements installs requirements.txt into the virtual environment with uv
+func (p *LocalPluginRuntime) installPythonRequirements(ctx context.Context, uvPath string, virtualEnvPath string) error {
 	args := []string{"install"}
 
-	if p.pipMirrorUrl != "" {
+	findLinks, err := p.pythonFindLinks()
+	if err != nil {
+		return err
+	}
+	for _, link := range findLinks {
+		args = append(args, "--find-links", link)
+	}
+	if p.pythonInstallOffline() {
+		args = append(args, "--offline", "--no-index")
+	} else if p.pipMirrorUrl != "" {
 		args = append(args, "-i", p.pipMirrorUrl)
 	}
 
@@ -130,9 +344,9 @@ func (p *LocalPluginRuntime) InitPythonEnvironment() error {
 
 	args = append([]string{"pip"}, args...)
 
-	virtualEnvPath := path.Join(p.State.WorkingPath, ".venv")
-	cmd = exec.CommandContext(ctx, uvPath, args...)
+	cmd := exec.CommandContext(ctx, uvPath, args...)
 	cmd.Env = append(cmd.Env, "VIRTUAL_ENV="+virtualEnvPath, "PATH="+os.Getenv("PATH"))
+	cmd.Env = append(cmd.Env, p.uvEnv()...)
 	if p.HttpProxy != "" {
 		cmd.Env = append(cmd.Env, fmt.Sprintf("HTTP_PROXY=%s", p.HttpProxy))
 	}
@@ -239,115 +453,6 @@ func (p *LocalPluginRuntime) InitPythonEnvironment() error {
 		return fmt.Errorf("failed to install dependencies: %s, output: %s", err, errMsg.String())
 	}
 
-	compileArgs := []string{"-m", "compileall"}
-	if p.pythonCompileAllExtraArgs != "" {
-		compileArgs = append(compileArgs, strings.Split(p.pythonCompileAllExtraArgs, " ")...)
-	}
-	compileArgs = append(compileArgs, ".")
-
-	// pre-compile the plugin to avoid costly compilation on first invocation
-	compileCmd := exec.CommandContext(ctx, pythonPath, compileArgs...)
-	compileCmd.Dir = p.State.WorkingPath
-
-	// get stdout and stderr
-	compileStdout, err := compileCmd.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("failed to get stdout: %s", err)
-	}
-	defer compileStdout.Close()
-
-	compileStderr, err := compileCmd.StderrPipe()
-	if err != nil {
-		return fmt.Errorf("failed to get stderr: %s", err)
-	}
-	defer compileStderr.Close()
-
-	// start command
-	if err := compileCmd.Start(); err != nil {
-		return fmt.Errorf("failed to start command: %s", err)
-	}
-	defer func() {
-		if compileCmd.Process != nil {
-			compileCmd.Process.Kill()
-		}
-	}()
-
-	var compileErrMsg strings.Builder
-	var compileWg sync.WaitGroup
-	compileWg.Add(2)
-
-	routine.Submit(map[string]string{
-		"module":   "plugin_manager",
-		"function": "InitPythonEnvironment",
-	}, func() {
-		defer compileWg.Done()
-		// read compileStdout
-		for {
-			buf := make([]byte, 102400)
-			n, err := compileStdout.Read(buf)
-			if err != nil {
-				break
-			}
-			// split to first line
-			lines := strings.Split(string(buf[:n]), "\n")
-
-			for len(lines) > 0 && len(lines[0]) == 0 {
-				lines = lines[1:]
-			}
-
-			if len(lines) > 0 {
-				if len(lines) > 1 {
-					log.Info("pre-compiling %s - %s...", p.Config.Identity(), lines[0])
-				} else {
-					log.Info("pre-compiling %s - %s", p.Config.Identity(), lines[0])
-				}
-			}
-		}
-	})
-
-	routine.Submit(map[string]string{
-		"module":   "plugin_manager",
-		"function": "InitPythonEnvironment",
-	}, func() {
-		defer compileWg.Done()
-		// read stderr
-		buf := make([]byte, 1024)
-		for {
-			n, err := compileStderr.Read(buf)
-			if err != nil {
-				break
-			}
-			compileErrMsg.WriteString(string(buf[:n]))
-		}
-	})
-
-	compileWg.Wait()
-	if err := compileCmd.Wait(); err != nil {
-		// skip the error if the plugin is not compiled
-		// ISSUE: for some weird reasons, plugins may reference to a broken sdk but it works well itself
-		// we need to skip it but log the messages
-		// https://github.com/langgenius/dify/issues/16292
-		log.Warn("failed to pre-compile the plugin: %s", compileErrMsg.String())
-	}
-
-	log.Info("pre-loaded the plugin %s", p.Config.Identity())
-
-	// import dify_plugin to speedup the first launching
-	// ISSUE: it takes too long to setup all the deps, that's why we choose to preload it
-	importCmd := exec.CommandContext(ctx, pythonPath, "-c", "import dify_plugin")
-	importCmd.Dir = p.State.WorkingPath
-	importCmd.Output()
-
-	// PATCH:
-	//  plugin sdk version less than 0.0.1b70 contains a memory leak bug
-	//  to reach a better user experience, we will patch it here using a patched file
-	// https://github.com/langgenius/dify-plugin-sdks/commit/161045b65f708d8ef0837da24440ab3872821b3b
-	if err := p.patchPluginSdk(requirementsPath); err != nil {
-		log.Error("failed to patch the plugin sdk: %s", err)
-	}
-
-	success = true
-
 	return nil
 }
 
@@ -387,7 +492,7 @@ func (p *LocalPluginRuntime) patchPluginSdk(requirementsPath string) error {
 			return fmt.Errorf("failed to find the patch file: %s", err)
 		}
 
-		if err := os.WriteFile(patchPath, python001b70aiModelsPatches, 0644); err != nil {
+		if err := replaceFile(patchPath, python001b70aiModelsPatches); err != nil {
 			return fmt.Errorf("failed to write the patch file: %s", err)
 		}
 	}
@@ -409,7 +514,7 @@ func (p *LocalPluginRuntime) patchPluginSdk(requirementsPath string) error {
 			return fmt.Errorf("failed to find the patch file: %s", err)
 		}
 
-		if err := os.WriteFile(patchPath, python011llmPatches, 0644); err != nil {
+		if err := replaceFile(patchPath, python011llmPatches); err != nil {
 			return fmt.Errorf("failed to write the patch file: %s", err)
 		}
 
@@ -418,7 +523,7 @@ func (p *LocalPluginRuntime) patchPluginSdk(requirementsPath string) error {
 			return fmt.Errorf("failed to find the patch file: %s", err)
 		}
 
-		if err := os.WriteFile(patchPath, python011requestReaderPatches, 0644); err != nil {
+		if err := replaceFile(patchPath, python011requestReaderPatches); err != nil {
 			return fmt.Errorf("failed to write the patch file: %s", err)
 		}
 	}
@@ -0,0 +1,107 @@
+package local_runtime
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+const (
+	// changes made within the debounce window trigger only one reload
+	HOT_RELOAD_DEBOUNCE = 500 * time.Millisecond
+	// in-flight sessions are given up to this long to finish before the plugin is restarted
+	HOT_RELOAD_DRAIN_TIMEOUT = 60 * time.Second
+)
+
+// watchSource restarts the plugin process whenever its source under the working path changes,
+// it blocks until stop is closed
+func (r *LocalPluginRuntime) watchSource(stop <-chan bool) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Error("failed to create hot reload watcher for plugin %s: %s", r.Config.Identity(), err.Error())
+		return
+	}
+	defer watcher.Close()
+
+	// fsnotify is not recursive, every directory needs to be watched
+	err = filepath.WalkDir(r.State.WorkingPath, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != r.State.WorkingPath && ignoredByHotReload(d.Name()) {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+	if err != nil {
+		log.Error("failed to watch source of plugin %s: %s", r.Config.Identity(), err.Error())
+		return
+	}
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-stop:
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if ignoredByHotReload(filepath.Base(event.Name)) {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					watcher.Add(event.Name)
+				}
+			}
+			debounce = time.After(HOT_RELOAD_DEBOUNCE)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Warn("hot reload watcher of plugin %s: %s", r.Config.Identity(), err.Error())
+		case <-debounce:
+			log.Info("source of plugin %s changed, reloading", r.Config.Identity())
+			r.drainSessions(stop)
+			// the lifecycle starts the plugin again once the current process exits
+			r.stdioHolder.Stop()
+			return
+		}
+	}
+}
+
+// drainSessions refuses new sessions and waits for in-flight ones to finish
+func (r *LocalPluginRuntime) drainSessions(stop <-chan bool) {
+	r.stdioHolder.drain()
+	deadline := time.After(HOT_RELOAD_DRAIN_TIMEOUT)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for r.stdioHolder.sessions() > 0 {
+		select {
+		case <-stop:
+			return
+		case <-deadline:
+			log.Warn(
+				"plugin %s still has %d sessions after draining, reloading anyway",
+				r.Config.Identity(), r.stdioHolder.sessions(),
+			)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ignoredByHotReload skips hidden files like .venv, python bytecode caches and node dependencies
+func ignoredByHotReload(name string) bool {
+	return strings.HasPrefix(name, ".") || name == "__pycache__" || strings.HasSuffix(name, ".pyc") ||
+		name == "node_modules"
+}
@@ -0,0 +1,100 @@
+package local_runtime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func TestHotReloadRestartsAfterDraining(t *testing.T) {
+	workingPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workingPath, "main.py"), []byte("print(1)"), 0o644); err != nil {
+		t.Fatalf("failed to write source: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(workingPath, ".venv"), 0o755); err != nil {
+		t.Fatalf("failed to create venv: %v", err)
+	}
+
+	stdio := newMockReadWriteCloser()
+	runtime := &LocalPluginRuntime{
+		PluginRuntime: plugin_entities.PluginRuntime{
+			State: plugin_entities.PluginRuntimeState{WorkingPath: workingPath},
+		},
+		stdioHolder: newStdioHolder("test", stdio, stdio, stdio, nil),
+	}
+	runtime.stdioHolder.setupStdioEventListener("session", func([]byte) {})
+
+	stop := make(chan bool)
+	defer close(stop)
+	done := make(chan bool)
+	go func() {
+		runtime.watchSource(stop)
+		close(done)
+	}()
+
+	// wait for the watcher to be ready
+	time.Sleep(100 * time.Millisecond)
+
+	// changes in ignored directories never trigger a reload
+	if err := os.WriteFile(filepath.Join(workingPath, ".venv", "x"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("failed to write venv file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(workingPath, "main.py"), []byte("print(2)"), 0o644); err != nil {
+		t.Fatalf("failed to update source: %v", err)
+	}
+
+	// the plugin must not be stopped while a session is in flight
+	select {
+	case <-done:
+		t.Fatalf("plugin reloaded before sessions drained")
+	case <-time.After(HOT_RELOAD_DEBOUNCE + 300*time.Millisecond):
+	}
+
+	runtime.stdioHolder.removeStdioHandlerListener("session")
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("plugin was not reloaded")
+	}
+
+	if !runtime.stdioHolder.waitingControllerChanClosed {
+		t.Fatalf("stdio should be stopped on reload")
+	}
+}
+
+func TestDrainingRefusesNewSessions(t *testing.T) {
+	stdio := newMockReadWriteCloser()
+	runtime := &LocalPluginRuntime{
+		stdioHolder: newStdioHolder("test", stdio, stdio, stdio, nil),
+	}
+	runtime.stdioHolder.setupStdioEventListener("session", func([]byte) {})
+	runtime.stdioHolder.drain()
+
+	var messages []plugin_entities.SessionMessage
+	listener := runtime.Listen("new")
+	listener.Listen(func(message plugin_entities.SessionMessage) {
+		messages = append(messages, message)
+	})
+	runtime.Write("new", "", []byte(`{"session_id":"new"}`))
+	runtime.Write("new", "", []byte(`{"session_id":"new"}`))
+
+	// the session is answered once and never reaches the plugin
+	if len(messages) != 1 || messages[0].Type != plugin_entities.SESSION_MESSAGE_TYPE_ERROR {
+		t.Fatalf("expected one error, got %+v", messages)
+	}
+	if stdio.writeBuf.Len() != 0 {
+		t.Fatalf("refused session was written to the plugin: %s", stdio.writeBuf.String())
+	}
+	if sessions := runtime.stdioHolder.sessions(); sessions != 1 {
+		t.Fatalf("only the session started before draining should be listening, got %d", sessions)
+	}
+
+	listener.Close()
+	if _, ok := runtime.refusedSessions.Load("new"); ok {
+		t.Fatal("refused session should be forgotten once closed")
+	}
+}
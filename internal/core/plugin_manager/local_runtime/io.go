@@ -1,31 +1,65 @@
 package local_runtime
 
 import (
+	"encoding/json"
+	"sync/atomic"
+
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
+// refusedSession is a session started while the plugin drains for a reload, it's answered with an
+// error instead of reaching the plugin
+type refusedSession struct {
+	listener *entities.Broadcast[plugin_entities.SessionMessage]
+	answered atomic.Bool
+}
+
 func (r *LocalPluginRuntime) Listen(session_id string) *entities.Broadcast[plugin_entities.SessionMessage] {
 	listener := entities.NewBroadcast[plugin_entities.SessionMessage]()
 	listener.OnClose(func() {
 		r.stdioHolder.removeStdioHandlerListener(session_id)
+		r.refusedSessions.Delete(session_id)
 	})
-	r.stdioHolder.setupStdioEventListener(session_id, func(b []byte) {
+	accepted := r.stdioHolder.setupStdioEventListener(session_id, func(b []byte) {
 		// unmarshal the session message
 		data, err := parser.UnmarshalJsonBytes[plugin_entities.SessionMessage](b)
 		if err != nil {
-			log.Error("unmarshal json failed: %s, failed to parse session message", err.Error())
+			r.logger().With(log.Fields{log.FIELD_SESSION_ID: session_id}).Error(
+				"unmarshal json failed: %s, failed to parse session message", err.Error(),
+			)
 			return
 		}
 
 		listener.Send(data)
 	})
+	if !accepted {
+		// the caller listens by the time the request is written, the error is sent from Write
+		r.refusedSessions.Store(session_id, &refusedSession{listener: listener})
+	}
 	return listener
 }
 
 func (r *LocalPluginRuntime) Write(session_id string, action access_types.PluginAccessAction, data []byte) {
-	r.stdioHolder.write(append(data, '\n'))
+	if value, ok := r.refusedSessions.Load(session_id); ok {
+		refused := value.(*refusedSession)
+		if refused.answered.CompareAndSwap(false, true) {
+			refused.listener.Send(plugin_entities.SessionMessage{
+				Type: plugin_entities.SESSION_MESSAGE_TYPE_ERROR,
+				Data: json.RawMessage(parser.MarshalJson(plugin_entities.ErrorResponse{
+					ErrorType: exception.PluginDaemonUnavailableError,
+					Message:   "plugin is reloading, try again later",
+					Args:      map[string]any{},
+				})),
+			})
+		}
+		return
+	}
+
+	data = r.stdioHolder.offload(data, r.blobOffloadThreshold)
+	r.stdioHolder.writeSession(session_id, append(data, '\n'))
 }
@@ -0,0 +1,129 @@
+package local_runtime
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	LOG_STREAM_STDOUT = "stdout"
+	LOG_STREAM_STDERR = "stderr"
+
+	DEFAULT_LOG_BUFFER_LINES = 1000
+	// MAX_LOG_LINE_LEN truncates lines so the memory held per plugin stays bounded
+	MAX_LOG_LINE_LEN = 4096
+	// LOG_SUBSCRIBER_BUFFER_SIZE is how far a tail may fall behind before lines are dropped for it
+	LOG_SUBSCRIBER_BUFFER_SIZE = 256
+)
+
+type LogLine struct {
+	Time    time.Time `json:"time"`
+	Stream  string    `json:"stream"`
+	Message string    `json:"message"`
+}
+
+// LogBuffer keeps the last lines a plugin wrote to stdout and stderr, session messages are not
+// captured, it outlives restarts of the plugin so the output of a crash can still be read
+type LogBuffer struct {
+	mu    sync.Mutex
+	lines []LogLine
+	start int
+	size  int
+
+	subscribers      map[int]chan LogLine
+	nextSubscriberID int
+}
+
+func NewLogBuffer(capacity int) *LogBuffer {
+	if capacity <= 0 {
+		capacity = DEFAULT_LOG_BUFFER_LINES
+	}
+
+	return &LogBuffer{
+		lines:       make([]LogLine, capacity),
+		subscribers: map[int]chan LogLine{},
+	}
+}
+
+// Append splits message into lines and adds them, the oldest lines are overwritten once full
+func (b *LogBuffer) Append(stream string, message string) {
+	if b == nil {
+		return
+	}
+
+	now := time.Now()
+	for _, text := range strings.Split(strings.TrimRight(message, "\n"), "\n") {
+		text = strings.TrimRight(text, "\r")
+		if text == "" {
+			continue
+		}
+		if len(text) > MAX_LOG_LINE_LEN {
+			text = text[:MAX_LOG_LINE_LEN]
+		}
+		b.append(LogLine{Time: now, Stream: stream, Message: text})
+	}
+}
+
+func (b *LogBuffer) append(line LogLine) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	capacity := len(b.lines)
+	if b.size < capacity {
+		b.lines[(b.start+b.size)%capacity] = line
+		b.size++
+	} else {
+		b.lines[b.start] = line
+		b.start = (b.start + 1) % capacity
+	}
+
+	for _, subscriber := range b.subscribers {
+		// a slow reader must never block the plugin
+		select {
+		case subscriber <- line:
+		default:
+		}
+	}
+}
+
+// Lines returns the last n lines from oldest to newest, all of them if n is not positive
+func (b *LogBuffer) Lines(n int) []LogLine {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.linesLocked(n)
+}
+
+func (b *LogBuffer) linesLocked(n int) []LogLine {
+	if n <= 0 || n > b.size {
+		n = b.size
+	}
+
+	lines := make([]LogLine, 0, n)
+	for i := b.size - n; i < b.size; i++ {
+		lines = append(lines, b.lines[(b.start+i)%len(b.lines)])
+	}
+	return lines
+}
+
+// Subscribe returns the last n lines and a channel receiving every line appended afterwards,
+// cancel closes the channel and must be called once the subscriber is gone
+func (b *LogBuffer) Subscribe(n int) ([]LogLine, <-chan LogLine, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.nextSubscriberID
+	b.nextSubscriberID++
+	subscriber := make(chan LogLine, LOG_SUBSCRIBER_BUFFER_SIZE)
+	b.subscribers[id] = subscriber
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[id]; ok {
+			delete(b.subscribers, id)
+			close(subscriber)
+		}
+	}
+	return b.linesLocked(n), subscriber, cancel
+}
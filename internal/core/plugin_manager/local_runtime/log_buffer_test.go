@@ -0,0 +1,76 @@
+package local_runtime
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func messages(lines []LogLine) []string {
+	result := []string{}
+	for _, line := range lines {
+		result = append(result, line.Message)
+	}
+	return result
+}
+
+func TestLogBufferRing(t *testing.T) {
+	buffer := NewLogBuffer(3)
+
+	buffer.Append(LOG_STREAM_STDOUT, "a\nb\n")
+	assert.Equal(t, []string{"a", "b"}, messages(buffer.Lines(0)))
+
+	buffer.Append(LOG_STREAM_STDERR, "c\r\n\nd")
+	assert.Equal(t, []string{"b", "c", "d"}, messages(buffer.Lines(0)))
+	assert.Equal(t, []string{"c", "d"}, messages(buffer.Lines(2)))
+	assert.Equal(t, LOG_STREAM_STDERR, buffer.Lines(1)[0].Stream)
+
+	buffer.Append(LOG_STREAM_STDOUT, strings.Repeat("x", MAX_LOG_LINE_LEN+10))
+	assert.Equal(t, MAX_LOG_LINE_LEN, len(buffer.Lines(1)[0].Message))
+}
+
+func TestLogBufferSubscribe(t *testing.T) {
+	buffer := NewLogBuffer(10)
+	buffer.Append(LOG_STREAM_STDOUT, "before")
+
+	backlog, lines, cancel := buffer.Subscribe(0)
+	assert.Equal(t, []string{"before"}, messages(backlog))
+
+	buffer.Append(LOG_STREAM_STDERR, "after")
+	select {
+	case line := <-lines:
+		assert.Equal(t, "after", line.Message)
+	case <-time.After(time.Second):
+		t.Fatal("line not delivered to subscriber")
+	}
+
+	cancel()
+	cancel()
+	_, ok := <-lines
+	assert.False(t, ok)
+
+	// appending after the subscriber is gone must not panic
+	buffer.Append(LOG_STREAM_STDOUT, "later")
+}
+
+func TestLogBufferSlowSubscriber(t *testing.T) {
+	buffer := NewLogBuffer(10)
+	_, _, cancel := buffer.Subscribe(0)
+	defer cancel()
+
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < LOG_SUBSCRIBER_BUFFER_SIZE*2; i++ {
+			buffer.Append(LOG_STREAM_STDOUT, "line")
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a subscriber not reading blocked the plugin")
+	}
+}
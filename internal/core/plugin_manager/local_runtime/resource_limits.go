@@ -0,0 +1,65 @@
+package local_runtime
+
+import (
+	"os/exec"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// ResourceLimits confines a local plugin process, zero values mean unlimited
+type ResourceLimits struct {
+	// Memory in bytes, plugins exceeding it are killed and restarted
+	Memory int64
+	// CPU in cores
+	CPU float64
+	// FileDescriptors is the max number of open files
+	FileDescriptors uint64
+}
+
+func (l ResourceLimits) empty() bool {
+	return l.Memory <= 0 && l.CPU <= 0 && l.FileDescriptors == 0
+}
+
+// resourceLimiter enforces ResourceLimits on a plugin process
+type resourceLimiter interface {
+	// Prepare makes cmd start under the limits where the platform allows it, Apply is still called
+	// once it started
+	Prepare(cmd *exec.Cmd) error
+	// Apply puts the started process under the limits not set by Prepare
+	Apply(pid int) error
+	// MemoryExceeded reports whether the process was killed for exceeding the memory limit
+	MemoryExceeded() bool
+	// Close releases the limiter after the process exited
+	Close()
+}
+
+// mergeResourceLimits applies the daemon wide maximum to the requirement declared in manifest,
+// the maximum is also used when manifest does not declare a limit
+func mergeResourceLimits(declared plugin_entities.PluginResourceRequirement, max ResourceLimits) ResourceLimits {
+	return ResourceLimits{
+		Memory:          minPositive(declared.Memory, max.Memory),
+		CPU:             minPositive(declared.CPU, max.CPU),
+		FileDescriptors: minPositive(declared.FileDescriptors, max.FileDescriptors),
+	}
+}
+
+func minPositive[T int64 | uint64 | float64](a T, b T) T {
+	switch {
+	case a <= 0:
+		return b
+	case b <= 0:
+		return a
+	case a < b:
+		return a
+	default:
+		return b
+	}
+}
+
+var limiterNameReplacer = strings.NewReplacer("/", "_", ":", "_", "@", "_")
+
+// limiterName is the name of the cgroup or job object of a plugin
+func limiterName(identity string) string {
+	return limiterNameReplacer.Replace(identity)
+}
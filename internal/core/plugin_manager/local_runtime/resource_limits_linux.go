@@ -0,0 +1,154 @@
+package local_runtime
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// CGROUP_CPU_PERIOD is the period of cpu.max in microseconds
+	CGROUP_CPU_PERIOD = 100000
+)
+
+// cgroupLimiter puts the plugin into a cgroup v2 group under root, memory is enforced by the
+// kernel OOM killer which kills the whole group, the runtime restarts the plugin afterwards
+type cgroupLimiter struct {
+	path   string
+	limits ResourceLimits
+	// dir is the cgroup the process is spawned into, set by Prepare
+	dir *os.File
+}
+
+func newResourceLimiter(root string, name string, limits ResourceLimits) (resourceLimiter, error) {
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("create cgroup root failed: %s", err.Error())
+	}
+
+	// children of root need the controllers delegated
+	if err := writeCgroupFile(root, "cgroup.subtree_control", "+memory +cpu"); err != nil {
+		return nil, fmt.Errorf(
+			"enable cgroup controllers failed, %s must be a delegated cgroup v2 directory: %s",
+			root, err.Error(),
+		)
+	}
+
+	path := filepath.Join(root, name)
+	if err := os.Mkdir(path, 0755); err != nil && !os.IsExist(err) {
+		return nil, fmt.Errorf("create cgroup failed: %s", err.Error())
+	}
+
+	l := &cgroupLimiter{path: path, limits: limits}
+
+	memory := "max"
+	if limits.Memory > 0 {
+		memory = strconv.FormatInt(limits.Memory, 10)
+	}
+	if err := writeCgroupFile(path, "memory.max", memory); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if limits.Memory > 0 {
+		// swapping would let the plugin exceed the limit silently, not every kernel has swap accounting
+		writeCgroupFile(path, "memory.swap.max", "0")
+		if err := writeCgroupFile(path, "memory.oom.group", "1"); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+
+	cpu := fmt.Sprintf("max %d", CGROUP_CPU_PERIOD)
+	if limits.CPU > 0 {
+		cpu = fmt.Sprintf("%d %d", max(int64(limits.CPU*CGROUP_CPU_PERIOD), 1000), CGROUP_CPU_PERIOD)
+	}
+	if err := writeCgroupFile(path, "cpu.max", cpu); err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Prepare spawns the process right into the cgroup, it never runs unconstrained
+func (l *cgroupLimiter) Prepare(cmd *exec.Cmd) error {
+	dir, err := os.Open(l.path)
+	if err != nil {
+		return fmt.Errorf("open cgroup failed: %s", err.Error())
+	}
+	l.closeDir()
+	l.dir = dir
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(dir.Fd())
+	return nil
+}
+
+func (l *cgroupLimiter) Apply(pid int) error {
+	if l.dir != nil {
+		// spawned into the cgroup already
+		l.closeDir()
+	} else if err := writeCgroupFile(l.path, "cgroup.procs", strconv.Itoa(pid)); err != nil {
+		return err
+	}
+
+	if l.limits.FileDescriptors > 0 {
+		if err := unix.Prlimit(pid, unix.RLIMIT_NOFILE, &unix.Rlimit{
+			Cur: l.limits.FileDescriptors,
+			Max: l.limits.FileDescriptors,
+		}, nil); err != nil {
+			return fmt.Errorf("set file descriptor limit failed: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (l *cgroupLimiter) MemoryExceeded() bool {
+	data, err := os.ReadFile(filepath.Join(l.path, "memory.events"))
+	if err != nil {
+		return false
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		field, value, ok := strings.Cut(scanner.Text(), " ")
+		if ok && field == "oom_kill" {
+			count, _ := strconv.ParseInt(value, 10, 64)
+			return count > 0
+		}
+	}
+	return false
+}
+
+func (l *cgroupLimiter) Close() {
+	l.closeDir()
+	// kill processes forked by the plugin, the group is recreated on restart
+	// so that oom_kill counts the current process only
+	writeCgroupFile(l.path, "cgroup.kill", "1")
+	os.Remove(l.path)
+}
+
+func (l *cgroupLimiter) closeDir() {
+	if l.dir != nil {
+		l.dir.Close()
+		l.dir = nil
+	}
+}
+
+func writeCgroupFile(dir string, file string, value string) error {
+	if err := os.WriteFile(filepath.Join(dir, file), []byte(value), 0644); err != nil {
+		return fmt.Errorf("write %s failed: %s", file, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package local_runtime
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCgroupLimiter(t *testing.T) {
+	// a plain directory stands in for the cgroup filesystem
+	root := t.TempDir()
+
+	limiter, err := newResourceLimiter(root, "langgenius_openai", ResourceLimits{
+		Memory: 256 * 1024 * 1024,
+		CPU:    0.5,
+	})
+	assert.NoError(t, err)
+
+	path := filepath.Join(root, "langgenius_openai")
+	read := func(file string) string {
+		data, err := os.ReadFile(filepath.Join(path, file))
+		assert.NoError(t, err)
+		return string(data)
+	}
+
+	assert.Equal(t, "268435456", read("memory.max"))
+	assert.Equal(t, "1", read("memory.oom.group"))
+	assert.Equal(t, "50000 100000", read("cpu.max"))
+
+	assert.NoError(t, limiter.Apply(os.Getpid()))
+	assert.Equal(t, strconv.Itoa(os.Getpid()), read("cgroup.procs"))
+	assert.False(t, limiter.MemoryExceeded())
+
+	assert.NoError(t, os.WriteFile(
+		filepath.Join(path, "memory.events"),
+		[]byte("low 0\nhigh 0\nmax 3\noom 1\noom_kill 1\n"),
+		0644,
+	))
+	assert.True(t, limiter.MemoryExceeded())
+}
+
+func TestCgroupLimiterPrepare(t *testing.T) {
+	root := t.TempDir()
+
+	limiter, err := newResourceLimiter(root, "langgenius_openai", ResourceLimits{Memory: 256 * 1024 * 1024})
+	assert.NoError(t, err)
+	defer limiter.Close()
+
+	// the process is spawned into the cgroup instead of being moved once it started
+	cmd := exec.Command("true")
+	assert.NoError(t, limiter.Prepare(cmd))
+	assert.True(t, cmd.SysProcAttr.UseCgroupFD)
+	assert.NotZero(t, cmd.SysProcAttr.CgroupFD)
+
+	assert.NoError(t, limiter.Apply(os.Getpid()))
+	_, err = os.Stat(filepath.Join(root, "langgenius_openai", "cgroup.procs"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !windows
+
+package local_runtime
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func newResourceLimiter(root string, name string, limits ResourceLimits) (resourceLimiter, error) {
+	return nil, fmt.Errorf("resource limits are not supported on %s", runtime.GOOS)
+}
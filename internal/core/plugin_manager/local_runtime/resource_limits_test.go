@@ -0,0 +1,41 @@
+package local_runtime
+
+import (
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeResourceLimits(t *testing.T) {
+	declared := plugin_entities.PluginResourceRequirement{
+		Memory: 512 * 1024 * 1024,
+		CPU:    2,
+	}
+
+	// no daemon maximum, manifest is used as is
+	limits := mergeResourceLimits(declared, ResourceLimits{})
+	assert.Equal(t, ResourceLimits{Memory: 512 * 1024 * 1024, CPU: 2}, limits)
+
+	// daemon maximum caps manifest and fills the undeclared limits
+	limits = mergeResourceLimits(declared, ResourceLimits{
+		Memory:          256 * 1024 * 1024,
+		CPU:             4,
+		FileDescriptors: 1024,
+	})
+	assert.Equal(t, ResourceLimits{
+		Memory:          256 * 1024 * 1024,
+		CPU:             2,
+		FileDescriptors: 1024,
+	}, limits)
+
+	assert.True(t, mergeResourceLimits(plugin_entities.PluginResourceRequirement{}, ResourceLimits{}).empty())
+}
+
+func TestLimiterName(t *testing.T) {
+	assert.Equal(
+		t,
+		"langgenius_openai_0.0.1_ab12",
+		limiterName("langgenius/openai:0.0.1@ab12"),
+	)
+}
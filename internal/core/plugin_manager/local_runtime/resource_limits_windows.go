@@ -0,0 +1,144 @@
+package local_runtime
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	JOB_OBJECT_CPU_RATE_CONTROL_ENABLE   = 0x1
+	JOB_OBJECT_CPU_RATE_CONTROL_HARD_CAP = 0x4
+
+	// JOB_MEMORY_CHECK_INTERVAL is how often the committed memory of a job is checked
+	JOB_MEMORY_CHECK_INTERVAL = 500 * time.Millisecond
+)
+
+type jobObjectCPURateControlInformation struct {
+	ControlFlags uint32
+	CPURate      uint32
+}
+
+// jobLimiter puts the plugin into a job object, windows has no OOM killer for jobs so memory is
+// watched and the job is terminated once its peak usage exceeds the limit.
+// file descriptor limits are not supported and ignored
+type jobLimiter struct {
+	job      windows.Handle
+	limits   ResourceLimits
+	exceeded atomic.Bool
+	closed   chan bool
+}
+
+func newResourceLimiter(root string, name string, limits ResourceLimits) (resourceLimiter, error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create job object failed: %s", err.Error())
+	}
+
+	l := &jobLimiter{job: job, limits: limits, closed: make(chan bool)}
+
+	// kill the plugin together with the daemon
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{}
+	info.BasicLimitInformation.LimitFlags = windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE
+	if _, err := windows.SetInformationJobObject(
+		job,
+		windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)),
+		uint32(unsafe.Sizeof(info)),
+	); err != nil {
+		windows.CloseHandle(job)
+		return nil, fmt.Errorf("set job object limits failed: %s", err.Error())
+	}
+
+	if limits.CPU > 0 {
+		// the rate is the share of all processors in 1/100 percent
+		rate := uint32(limits.CPU / float64(runtime.NumCPU()) * 10000)
+		cpu := jobObjectCPURateControlInformation{
+			ControlFlags: JOB_OBJECT_CPU_RATE_CONTROL_ENABLE | JOB_OBJECT_CPU_RATE_CONTROL_HARD_CAP,
+			CPURate:      min(max(rate, 1), 10000),
+		}
+		if _, err := windows.SetInformationJobObject(
+			job,
+			windows.JobObjectCpuRateControlInformation,
+			uintptr(unsafe.Pointer(&cpu)),
+			uint32(unsafe.Sizeof(cpu)),
+		); err != nil {
+			windows.CloseHandle(job)
+			return nil, fmt.Errorf("set job object cpu rate failed: %s", err.Error())
+		}
+	}
+
+	return l, nil
+}
+
+// Prepare does nothing, processes can only be assigned to a job once they started
+func (l *jobLimiter) Prepare(cmd *exec.Cmd) error {
+	return nil
+}
+
+func (l *jobLimiter) Apply(pid int) error {
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		return fmt.Errorf("open plugin process failed: %s", err.Error())
+	}
+	defer windows.CloseHandle(process)
+
+	if err := windows.AssignProcessToJobObject(l.job, process); err != nil {
+		return fmt.Errorf("assign plugin process to job object failed: %s", err.Error())
+	}
+
+	if l.limits.Memory > 0 {
+		routine.Submit(map[string]string{
+			"module":   "plugin_manager",
+			"type":     "local",
+			"function": "WatchJobMemory",
+		}, l.watchMemory)
+	}
+
+	return nil
+}
+
+func (l *jobLimiter) watchMemory() {
+	ticker := time.NewTicker(JOB_MEMORY_CHECK_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.closed:
+			return
+		case <-ticker.C:
+		}
+
+		info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{}
+		if err := windows.QueryInformationJobObject(
+			l.job,
+			windows.JobObjectExtendedLimitInformation,
+			uintptr(unsafe.Pointer(&info)),
+			uint32(unsafe.Sizeof(info)),
+			nil,
+		); err != nil {
+			continue
+		}
+
+		if int64(info.PeakJobMemoryUsed) > l.limits.Memory {
+			l.exceeded.Store(true)
+			windows.TerminateJobObject(l.job, 1)
+			return
+		}
+	}
+}
+
+func (l *jobLimiter) MemoryExceeded() bool {
+	return l.exceeded.Load()
+}
+
+func (l *jobLimiter) Close() {
+	close(l.closed)
+	windows.CloseHandle(l.job)
+}
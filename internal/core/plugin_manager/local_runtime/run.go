@@ -2,12 +2,22 @@
 package local_runtime
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"sync"
+	"syscall"
+	"time"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/event_codec"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/blob_channel"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/plugin_errors"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/sandbox"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/constants"
@@ -22,6 +32,11 @@ func (r *LocalPluginRuntime) gc() {
 	}
 }
 
+// logger attaches the plugin id to log lines, they are routed to the plugin log file if enabled
+func (r *LocalPluginRuntime) logger() *log.Entry {
+	return log.WithPlugin(fmt.Sprintf("%s/%s", r.Config.Author, r.Config.Name))
+}
+
 // Type returns the runtime type of the plugin
 func (r *LocalPluginRuntime) Type() plugin_entities.PluginRuntimeType {
 	return plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL
@@ -29,30 +44,194 @@ func (r *LocalPluginRuntime) Type() plugin_entities.PluginRuntimeType {
 
 // getCmd prepares the exec.Cmd for the plugin based on its language
 func (r *LocalPluginRuntime) getCmd() (*exec.Cmd, error) {
-	if r.Config.Meta.Runner.Language == constants.Python {
+	switch r.Config.Meta.Runner.Language {
+	case constants.Python:
 		cmd := exec.Command(r.pythonInterpreterPath, "-m", r.Config.Meta.Runner.Entrypoint)
 		cmd.Dir = r.State.WorkingPath
-		cmd.Env = cmd.Environ()
+		cmd.Env = append(cmd.Environ(), r.pluginEnv()...)
+		return cmd, nil
+	case constants.NodeJS:
+		// the entrypoint is a script relative to the plugin like dist/index.js
+		if !filepath.IsLocal(r.Config.Meta.Runner.Entrypoint) {
+			return nil, fmt.Errorf("entrypoint must be inside the plugin: %s", r.Config.Meta.Runner.Entrypoint)
+		}
+		cmd := exec.Command(r.nodeInterpreterPath, r.Config.Meta.Runner.Entrypoint)
+		cmd.Dir = r.State.WorkingPath
+		cmd.Env = append(cmd.Environ(), append(r.pluginEnv(), "NODE_ENV=production")...)
+		return cmd, nil
+	}
+
+	return nil, fmt.Errorf("unsupported language: %s", r.Config.Meta.Runner.Language)
+}
+
+// pluginEnv is the environment of the plugin on top of the one of the daemon
+func (r *LocalPluginRuntime) pluginEnv() []string {
+	env := []string{}
+	if r.egressProxy != "" {
+		// lowercase variants are preferred by some clients and no proxy must not bypass the policy
+		for _, key := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+			env = append(env, fmt.Sprintf("%s=%s", key, r.egressProxy))
+		}
+		env = append(env, "NO_PROXY=", "no_proxy=")
+	} else {
 		if r.HttpsProxy != "" {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("HTTPS_PROXY=%s", r.HttpsProxy))
+			env = append(env, fmt.Sprintf("HTTPS_PROXY=%s", r.HttpsProxy))
 		}
 		if r.HttpProxy != "" {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("HTTP_PROXY=%s", r.HttpProxy))
+			env = append(env, fmt.Sprintf("HTTP_PROXY=%s", r.HttpProxy))
 		}
 		if r.NoProxy != "" {
-			cmd.Env = append(cmd.Env, fmt.Sprintf("NO_PROXY=%s", r.NoProxy))
+			env = append(env, fmt.Sprintf("NO_PROXY=%s", r.NoProxy))
 		}
-		return cmd, nil
 	}
 
-	return nil, fmt.Errorf("unsupported language: %s", r.Config.Meta.Runner.Language)
+	if r.socket != nil {
+		env = append(env, r.socket.env()...)
+	}
+
+	return append(env, "INSTALL_METHOD=local", "PATH="+os.Getenv("PATH"))
+}
+
+// startProcess starts the plugin in an interpreter of its own, under limiter if it's not nil
+func (r *LocalPluginRuntime) startProcess(limiter resourceLimiter) (*exec.Cmd, io.WriteCloser, io.ReadCloser, io.ReadCloser, error) {
+	e, err := r.getCmd()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	if r.sandboxEnabled {
+		readOnlyPaths := r.sandboxReadOnlyPaths
+		if r.socket != nil {
+			// the rest of the host is hidden, the socket must stay reachable
+			readOnlyPaths = append(slices.Clone(readOnlyPaths), r.socket.dir)
+		}
+		profile := sandbox.NewProfile(
+			r.State.WorkingPath,
+			r.Config.Resource.Permission,
+			readOnlyPaths,
+			r.sandboxWritablePaths,
+			r.sandboxAppArmorProfile,
+		)
+		if err := sandbox.Wrap(e, profile); err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("sandbox plugin failed: %s", err.Error())
+		}
+	}
+
+	if limiter != nil {
+		if err := limiter.Prepare(e); err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("limit plugin resources failed: %s", err.Error())
+		}
+	}
+
+	// get writer
+	stdin, err := e.StdinPipe()
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("get stdin pipe failed: %s", err.Error())
+	}
+
+	// get stdout
+	stdout, err := e.StdoutPipe()
+	if err != nil {
+		stdin.Close()
+		return nil, nil, nil, nil, fmt.Errorf("get stdout pipe failed: %s", err.Error())
+	}
+
+	// get stderr
+	stderr, err := e.StderrPipe()
+	if err != nil {
+		stdin.Close()
+		stdout.Close()
+		return nil, nil, nil, nil, fmt.Errorf("get stderr pipe failed: %s", err.Error())
+	}
+
+	var blobChannel *blob_channel.Channel
+	var pluginEnds []*os.File
+	if r.blobChannelEnabled {
+		blobChannel, pluginEnds, err = attachBlobChannel(e, r.blobMemoryLimit)
+		if err != nil {
+			stdin.Close()
+			stdout.Close()
+			stderr.Close()
+			return nil, nil, nil, nil, fmt.Errorf("create blob channel failed: %s", err.Error())
+		}
+	}
+	// the plugin keeps its own ends of the pipes
+	defer closeFiles(pluginEnds)
+
+	if err := e.Start(); err != nil {
+		stdin.Close()
+		stdout.Close()
+		stderr.Close()
+		if blobChannel != nil {
+			blobChannel.Close()
+		}
+		return nil, nil, nil, nil, fmt.Errorf("start plugin failed: %s", err.Error())
+	}
+
+	r.blobChannel = blobChannel
+	return e, stdin, stdout, stderr, nil
+}
+
+// bindWarmWorker binds the plugin into an idle worker of the warm pool, it returns nil if there is
+// no worker for the python version of the plugin, sandboxed plugins are never bound as the sandbox
+// is applied when the process starts
+func (r *LocalPluginRuntime) bindWarmWorker() *warmWorker {
+	if r.warmPool == nil || r.sandboxEnabled || r.Config.Meta.Runner.Language != constants.Python {
+		return nil
+	}
+
+	workingPath, err := filepath.Abs(r.State.WorkingPath)
+	if err != nil {
+		return nil
+	}
+	venv := filepath.Join(workingPath, ".venv")
+	version, err := venvPythonVersion(venv)
+	if err != nil {
+		return nil
+	}
+
+	worker := r.warmPool.take(version)
+	if worker == nil {
+		return nil
+	}
+
+	if err := worker.bind(warmWorkerBind{
+		WorkingPath: workingPath,
+		Venv:        venv,
+		Executable:  r.pythonInterpreterPath,
+		Entrypoint:  r.Config.Meta.Runner.Entrypoint,
+		Env:         envMap(r.pluginEnv()),
+	}); err != nil {
+		r.logger().Warn("failed to bind plugin %s into a warm worker, starting it on its own: %s", r.Config.Identity(), err.Error())
+		worker.kill()
+		return nil
+	}
+
+	return worker
+}
+
+// newLimiter creates the limiter of the limits declared in manifest and capped by the daemon,
+// it returns a nil limiter if there is nothing to limit
+func (r *LocalPluginRuntime) newLimiter() (resourceLimiter, ResourceLimits, error) {
+	limits := mergeResourceLimits(r.Config.Resource, r.maxResources)
+	if limits.empty() {
+		return nil, limits, nil
+	}
+
+	limiter, err := newResourceLimiter(r.cgroupRoot, limiterName(r.Config.Identity()), limits)
+	if err != nil {
+		return nil, limits, err
+	}
+
+	return limiter, limits, nil
 }
 
 // StartPlugin starts the plugin and manages its lifecycle
 func (r *LocalPluginRuntime) StartPlugin() error {
-	defer log.Info("plugin %s stopped", r.Config.Identity())
+	defer r.logger().Info("plugin %s stopped", r.Config.Identity())
 	defer func() {
 		r.waitChanLock.Lock()
+		r.process = nil
 		for _, c := range r.waitStoppedChan {
 			select {
 			case c <- true:
@@ -74,44 +253,109 @@ func (r *LocalPluginRuntime) StartPlugin() error {
 	// reset wait launched chan
 
 	// start plugin
-	e, err := r.getCmd()
-	if err != nil {
-		return err
+	var e *exec.Cmd
+	var stdin io.WriteCloser
+	var stdout, stderr io.ReadCloser
+	var err error
+	r.blobChannel = nil
+	r.socket = nil
+	r.State.SDKVersion = ""
+	r.State.ProtocolVersion = plugin_entities.PLUGIN_PROTOCOL_VERSION_LEGACY
+	r.State.Capabilities = nil
+	r.State.Encoding = event_codec.ENCODING_JSON
+	if r.transport == TRANSPORT_SOCKET {
+		socket, err := newSocketTransport()
+		if err != nil {
+			r.logger().Warn("failed to listen on a socket for plugin %s, using stdio: %s", r.Config.Identity(), err.Error())
+		} else {
+			r.socket = socket
+			defer socket.close()
+		}
 	}
 
-	e.Dir = r.State.WorkingPath
-	// add env INSTALL_METHOD=local
-	e.Env = append(e.Environ(), "INSTALL_METHOD=local", "PATH="+os.Getenv("PATH"))
-
-	// get writer
-	stdin, err := e.StdinPipe()
-	if err != nil {
-		return fmt.Errorf("get stdin pipe failed: %s", err.Error())
+	var limiter resourceLimiter
+	if r.resourceLimitsEnabled {
+		var limits ResourceLimits
+		limiter, limits, err = r.newLimiter()
+		if err != nil {
+			return fmt.Errorf("limit plugin resources failed: %s", err.Error())
+		}
+		if limiter != nil {
+			defer func() {
+				if limiter.MemoryExceeded() {
+					r.logger().Warn("plugin %s killed for exceeding memory limit of %d bytes", r.Config.Identity(), limits.Memory)
+					r.Warn(fmt.Sprintf("killed for exceeding memory limit of %d bytes", limits.Memory))
+				}
+				limiter.Close()
+			}()
+		}
 	}
-	defer stdin.Close()
 
-	// get stdout
-	stdout, err := e.StdoutPipe()
-	if err != nil {
-		return fmt.Errorf("get stdout pipe failed: %s", err.Error())
+	if worker := r.bindWarmWorker(); worker != nil {
+		e, stdin, stdout, stderr = worker.cmd, worker.stdin, worker.stdout, worker.stderr
+		r.logger().Info("plugin %s bound into a warm worker", r.Config.Identity())
+	} else {
+		e, stdin, stdout, stderr, err = r.startProcess(limiter)
+		if err != nil {
+			return err
+		}
 	}
+	defer stdin.Close()
 	defer stdout.Close()
+	defer stderr.Close()
 
-	// get stderr
-	stderr, err := e.StderrPipe()
-	if err != nil {
-		return fmt.Errorf("get stderr pipe failed: %s", err.Error())
+	if r.blobChannel != nil {
+		blobChannel := r.blobChannel
+		defer blobChannel.Close()
+		routine.Submit(map[string]string{
+			"module":   "plugin_manager",
+			"type":     "local",
+			"function": "StartBlobChannel",
+		}, func() {
+			if err := blobChannel.Start(); err != nil {
+				r.logger().Error("blob channel of plugin %s failed: %s", r.Config.Identity(), err.Error())
+			}
+		})
 	}
-	defer stderr.Close()
 
-	if err := e.Start(); err != nil {
-		return fmt.Errorf("start plugin failed: %s", err.Error())
+	r.waitChanLock.Lock()
+	r.process = e.Process
+	r.waitChanLock.Unlock()
+
+	if limiter != nil {
+		// warm workers started before the limiter existed, they are moved under it here
+		if err := limiter.Apply(e.Process.Pid); err != nil {
+			e.Process.Kill()
+			e.Wait()
+			return fmt.Errorf("limit plugin resources failed: %s", err.Error())
+		}
 	}
 
 	// setup stdio
 	r.stdioHolder = newStdioHolder(r.Config.Identity(), stdin, stdout, stderr, &StdioHolderConfig{
 		StdoutBufferSize:    r.stdoutBufferSize,
 		StdoutMaxBufferSize: r.stdoutMaxBufferSize,
+		Logs:                r.logs,
+		Blobs:               r.blobChannel,
+		BlobMaxSize:         r.blobMaxSize,
+		Socket:              r.socket,
+		Encodings:           r.eventEncodings,
+		OnPong: func(t time.Time) {
+			r.State.LastPongAt = &t
+		},
+		OnHandshake: func(handshake plugin_entities.PluginHandshakeEvent) {
+			capabilities := make([]string, 0, len(handshake.Capabilities))
+			for _, capability := range handshake.Capabilities {
+				capabilities = append(capabilities, string(capability))
+			}
+			r.State.SDKVersion = handshake.SDKVersion
+			r.State.ProtocolVersion = handshake.ProtocolVersion
+			r.State.Capabilities = capabilities
+			r.State.Encoding = event_codec.ENCODING_JSON
+			if len(handshake.Encodings) > 0 {
+				r.State.Encoding = handshake.Encodings[0]
+			}
+		},
 	})
 	defer r.stdioHolder.Stop()
 
@@ -132,9 +376,9 @@ func (r *LocalPluginRuntime) StartPlugin() error {
 				err = originalErr
 			}
 			if err != nil {
-				log.Error("plugin %s exited with error: %s", r.Config.Identity(), err.Error())
+				r.logger().Error("plugin %s exited with error: %s", r.Config.Identity(), err.Error())
 			} else {
-				log.Error("plugin %s exited with unknown error", r.Config.Identity())
+				r.logger().Error("plugin %s exited with unknown error", r.Config.Identity())
 			}
 		}
 
@@ -144,7 +388,19 @@ func (r *LocalPluginRuntime) StartPlugin() error {
 	// ensure the plugin process is killed after the plugin exits
 	defer e.Process.Kill()
 
-	log.Info("plugin %s started", r.Config.Identity())
+	r.logger().Info("plugin %s started", r.Config.Identity())
+
+	if r.hotReload {
+		stopWatching := make(chan bool)
+		defer close(stopWatching)
+		routine.Submit(map[string]string{
+			"module":   "plugin_manager",
+			"type":     "local",
+			"function": "WatchSource",
+		}, func() {
+			r.watchSource(stopWatching)
+		})
+	}
 
 	wg := sync.WaitGroup{}
 	wg.Add(2)
@@ -159,6 +415,18 @@ func (r *LocalPluginRuntime) StartPlugin() error {
 		r.stdioHolder.StartStdout(func() {})
 	})
 
+	// serve streams of the plugin on the socket
+	if r.socket != nil {
+		socket, holder := r.socket, r.stdioHolder
+		routine.Submit(map[string]string{
+			"module":   "plugin_manager",
+			"type":     "local",
+			"function": "ServeSocket",
+		}, func() {
+			socket.serve(holder.StartStream)
+		})
+	}
+
 	// listen to plugin stderr
 	routine.Submit(map[string]string{
 		"module":   "plugin_manager",
@@ -169,6 +437,17 @@ func (r *LocalPluginRuntime) StartPlugin() error {
 		r.stdioHolder.StartStderr()
 	})
 
+	// detect plugins alive but stuck
+	if r.healthCheckInterval > 0 {
+		routine.Submit(map[string]string{
+			"module":   "plugin_manager",
+			"type":     "local",
+			"function": "StartHealthCheck",
+		}, func() {
+			r.stdioHolder.StartHealthCheck(r.healthCheckInterval, r.healthCheckTimeout)
+		})
+	}
+
 	// send started event
 	r.waitChanLock.Lock()
 	for _, c := range r.waitStartedChan {
@@ -182,6 +461,10 @@ func (r *LocalPluginRuntime) StartPlugin() error {
 	// wait for plugin to exit
 	err = r.stdioHolder.Wait()
 	if err != nil {
+		if errors.Is(err, plugin_errors.ErrPluginNotResponding) {
+			r.State.Hangs++
+			r.Warn(fmt.Sprintf("restarted for not answering a ping in %s", r.healthCheckTimeout))
+		}
 		return errors.Join(err, r.stdioHolder.Error())
 	}
 	wg.Wait()
@@ -226,3 +509,27 @@ func (r *LocalPluginRuntime) Stop() {
 		r.stdioHolder.Stop()
 	}
 }
+
+// Terminate asks the plugin to exit with SIGTERM and stops it once it has exited or ctx is done,
+// the plugin is not restarted afterwards
+func (r *LocalPluginRuntime) Terminate(ctx context.Context) {
+	r.PluginRuntime.Stop()
+
+	// buffered, the plugin may exit before select below is reached
+	stopped := make(chan bool, 1)
+	r.waitChanLock.Lock()
+	r.waitStoppedChan = append(r.waitStoppedChan, stopped)
+	process := r.process
+	r.waitChanLock.Unlock()
+
+	// signals other than kill are not supported on windows
+	if process != nil && process.Signal(syscall.SIGTERM) == nil {
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			r.logger().Warn("plugin %s did not exit in time, killing it", r.Config.Identity())
+		}
+	}
+
+	r.Stop()
+}
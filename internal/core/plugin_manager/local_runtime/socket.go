@@ -0,0 +1,232 @@
+package local_runtime
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+const (
+	TRANSPORT_STDIO  = "stdio"
+	TRANSPORT_SOCKET = "socket"
+
+	SOCKET_PATH_ENV  = "DIFY_PLUGIN_SOCKET_PATH"
+	SOCKET_TOKEN_ENV = "DIFY_PLUGIN_SOCKET_TOKEN"
+
+	MAX_SOCKET_STREAMS  = 16
+	SOCKET_AUTH_TIMEOUT = 10 * time.Second
+)
+
+// socketTransport is a unix socket the plugin connects to instead of talking over stdio, each
+// connection is a stream of json lines like stdio, starting with the token of the transport, events
+// of a session must stay on the stream its request was written to
+type socketTransport struct {
+	dir      string
+	path     string
+	token    string
+	listener net.Listener
+
+	lock  sync.Mutex
+	conns []net.Conn
+	// sessions are pinned to the stream they started on, whichever streams connect or go away later
+	pins   map[string]net.Conn
+	closed bool
+}
+
+// newSocketTransport listens in a directory only the daemon user can access, unix sockets are
+// supported on windows 10 and later as well
+func newSocketTransport() (*socketTransport, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "dify-plugin-")
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "plugin.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	return &socketTransport{
+		dir:      dir,
+		path:     path,
+		token:    hex.EncodeToString(secret),
+		listener: listener,
+		pins:     map[string]net.Conn{},
+	}, nil
+}
+
+func (t *socketTransport) env() []string {
+	return []string{
+		fmt.Sprintf("%s=%s", SOCKET_PATH_ENV, t.path),
+		fmt.Sprintf("%s=%s", SOCKET_TOKEN_ENV, t.token),
+	}
+}
+
+// serve accepts streams until the transport is closed, handle reads an authenticated stream until
+// it ends
+func (t *socketTransport) serve(handle func(reader io.Reader)) {
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		routine.Submit(map[string]string{
+			"module":   "plugin_manager",
+			"type":     "local",
+			"function": "ServeSocketStream",
+		}, func() {
+			defer conn.Close()
+
+			reader, err := t.authenticate(conn)
+			if err != nil {
+				return
+			}
+			if !t.add(conn) {
+				return
+			}
+			defer t.remove(conn)
+
+			handle(reader)
+		})
+	}
+}
+
+func (t *socketTransport) authenticate(conn net.Conn) (*bufio.Reader, error) {
+	conn.SetReadDeadline(time.Now().Add(SOCKET_AUTH_TIMEOUT))
+	defer conn.SetReadDeadline(time.Time{})
+
+	reader := bufio.NewReader(conn)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(strings.TrimSpace(line)), []byte(t.token)) != 1 {
+		return nil, errors.New("invalid socket token")
+	}
+	return reader, nil
+}
+
+func (t *socketTransport) add(conn net.Conn) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.closed || len(t.conns) >= MAX_SOCKET_STREAMS {
+		return false
+	}
+	t.conns = append(t.conns, conn)
+	return true
+}
+
+func (t *socketTransport) remove(conn net.Conn) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for i, c := range t.conns {
+		if c == conn {
+			t.conns = append(t.conns[:i], t.conns[i+1:]...)
+			break
+		}
+	}
+	// sessions of the stream are broken, they are not moved to another one
+	for key, pinned := range t.pins {
+		if pinned == conn {
+			delete(t.pins, key)
+		}
+	}
+}
+
+// connected reports whether the plugin talks over the socket
+func (t *socketTransport) connected() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return len(t.conns) > 0
+}
+
+// pin assigns the session key to the stream with the fewest sessions, nil if there is no stream,
+// writes without a session go to the first stream
+func (t *socketTransport) pin(key string) net.Conn {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.pinLocked(key)
+}
+
+func (t *socketTransport) pinLocked(key string) net.Conn {
+	if len(t.conns) == 0 {
+		return nil
+	}
+	if key == "" {
+		return t.conns[0]
+	}
+	if conn, ok := t.pins[key]; ok {
+		return conn
+	}
+
+	sessions := map[net.Conn]int{}
+	for _, conn := range t.pins {
+		sessions[conn]++
+	}
+	conn := t.conns[0]
+	for _, c := range t.conns[1:] {
+		if sessions[c] < sessions[conn] {
+			conn = c
+		}
+	}
+	t.pins[key] = conn
+	return conn
+}
+
+// unpin forgets the stream of a session which ended
+func (t *socketTransport) unpin(key string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.pins, key)
+}
+
+// write writes data to the stream of key, it returns false if there is no stream
+func (t *socketTransport) write(key string, data []byte) (bool, error) {
+	t.lock.Lock()
+	conn := t.pinLocked(key)
+	t.lock.Unlock()
+	if conn == nil {
+		return false, nil
+	}
+
+	_, err := conn.Write(data)
+	return true, err
+}
+
+func (t *socketTransport) close() {
+	t.lock.Lock()
+	if t.closed {
+		t.lock.Unlock()
+		return
+	}
+	t.closed = true
+	conns := t.conns
+	t.conns = nil
+	t.pins = map[string]net.Conn{}
+	t.lock.Unlock()
+
+	t.listener.Close()
+	for _, conn := range conns {
+		conn.Close()
+	}
+	os.RemoveAll(t.dir)
+}
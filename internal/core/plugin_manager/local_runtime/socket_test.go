@@ -0,0 +1,130 @@
+package local_runtime
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSocketTransport(t *testing.T) {
+	routine.InitPool(100)
+
+	socket, err := newSocketTransport()
+	if err != nil {
+		t.Skipf("unix sockets are not supported: %v", err)
+	}
+	defer socket.close()
+
+	stdin := newMockReadWriteCloser()
+	stdout := newMockReadWriteCloser()
+	stderr := newMockReadWriteCloser()
+	logs := NewLogBuffer(10)
+	holder := newStdioHolder("test-plugin", stdin, stdout, stderr, &StdioHolderConfig{
+		Logs:   logs,
+		Socket: socket,
+	})
+	defer holder.Stop()
+
+	received := make(chan string, 10)
+	holder.setupStdioEventListener("session", func(data []byte) {
+		received <- string(data)
+	})
+
+	go socket.serve(holder.StartStream)
+	go holder.StartStdout(func() {})
+
+	// a stream with a wrong token is closed
+	rejected, err := net.Dial("unix", socket.path)
+	assert.NoError(t, err)
+	rejected.Write([]byte("wrong\n"))
+	rejected.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = rejected.Read(make([]byte, 1))
+	assert.Error(t, err)
+	rejected.Close()
+	assert.False(t, socket.connected())
+
+	conn, err := net.Dial("unix", socket.path)
+	assert.NoError(t, err)
+	defer conn.Close()
+	conn.Write([]byte(socket.token + "\n"))
+	assert.Eventually(t, socket.connected, time.Second, 10*time.Millisecond)
+
+	conn.Write([]byte(`{"session_id":"session","event":"session","data":{"type":"stream","data":{}}}` + "\n"))
+	select {
+	case data := <-received:
+		assert.Contains(t, data, "stream")
+	case <-time.After(time.Second):
+		t.Fatal("event on the socket was not dispatched")
+	}
+
+	// stdout is only logs once the plugin talks over the socket
+	stdout.WriteToRead([]byte(`{"session_id":"session","event":"session","data":{"type":"end"}}` + "\n"))
+	assert.Eventually(t, func() bool { return len(logs.Lines(10)) == 1 }, time.Second, 10*time.Millisecond)
+	select {
+	case data := <-received:
+		t.Fatalf("stdout must not be dispatched, got %s", data)
+	default:
+	}
+
+	// requests are written to the stream instead of stdin
+	assert.NoError(t, holder.writeSession("session", []byte("request\n")))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "request", strings.TrimSpace(line))
+	assert.Empty(t, stdin.GetWrittenData())
+}
+
+func TestSocketTransportPinsSessions(t *testing.T) {
+	routine.InitPool(100)
+
+	socket, err := newSocketTransport()
+	if err != nil {
+		t.Skipf("unix sockets are not supported: %v", err)
+	}
+	defer socket.close()
+	go socket.serve(func(reader io.Reader) { io.Copy(io.Discard, reader) })
+
+	connect := func(streams int) *bufio.Reader {
+		conn, err := net.Dial("unix", socket.path)
+		assert.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		conn.Write([]byte(socket.token + "\n"))
+		assert.Eventually(t, func() bool {
+			socket.lock.Lock()
+			defer socket.lock.Unlock()
+			return len(socket.conns) == streams
+		}, time.Second, 10*time.Millisecond)
+		return bufio.NewReader(conn)
+	}
+	readLine := func(reader *bufio.Reader) string {
+		line, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		return strings.TrimSpace(line)
+	}
+
+	first := connect(1)
+	assert.NotNil(t, socket.pin("a"))
+
+	// a stream connecting later doesn't move the session, new sessions go to the idle stream
+	second := connect(2)
+	for _, write := range []struct{ session, data string }{{"a", "a1"}, {"b", "b1"}, {"a", "a2"}} {
+		ok, err := socket.write(write.session, []byte(write.data+"\n"))
+		assert.True(t, ok)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, "a1", readLine(first))
+	assert.Equal(t, "a2", readLine(first))
+	assert.Equal(t, "b1", readLine(second))
+
+	socket.unpin("a")
+	socket.lock.Lock()
+	_, pinned := socket.pins["a"]
+	socket.lock.Unlock()
+	assert.False(t, pinned)
+}
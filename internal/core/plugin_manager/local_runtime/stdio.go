@@ -2,14 +2,20 @@ package local_runtime
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/event_codec"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/blob_channel"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/plugin_errors"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
@@ -17,16 +23,23 @@ const (
 	MAX_ERR_MSG_LEN = 1024
 
 	MAX_HEARTBEAT_INTERVAL = 120 * time.Second
+
+	// blobs are sent before the events referring to them, this only covers a slow pipe, events
+	// waiting for blobs don't hold back other sessions
+	BLOB_RESOLVE_TIMEOUT = 60 * time.Second
 )
 
 type stdioHolder struct {
 	pluginUniqueIdentifier string
+	logger                 *log.Entry
 	writer                 io.WriteCloser
 	reader                 io.ReadCloser
 	errReader              io.ReadCloser
 	l                      *sync.Mutex
 	listener               map[string]func([]byte)
 	started                bool
+	// draining refuses new sessions, the process is about to be restarted
+	draining bool
 
 	// error message container
 	errMessage              string
@@ -37,16 +50,59 @@ type stdioHolder struct {
 	waitingControllerChanClosed bool
 	waitControllerChanLock      *sync.Mutex
 
-	// the last time the plugin sent a heartbeat
+	// the last time the plugin sent a heartbeat, stdout and socket streams update it concurrently
 	lastActiveAt time.Time
+	activeLock   sync.Mutex
 
 	stdoutBufferSize    int
 	stdoutMaxBufferSize int
+
+	logs *LogBuffer
+
+	// health check state, see StartHealthCheck
+	healthLock    sync.Mutex
+	pendingPingID string
+	pendingPingAt time.Time
+	pinging       bool
+	// pings are only enforced once the plugin has answered one, older sdks ignore them
+	pongSupported bool
+	unresponsive  bool
+	onPong        func(time.Time)
+
+	blobs       *blob_channel.Channel
+	blobMaxSize int64
+
+	// stdout is only read as logs once the plugin connected to the socket
+	socket *socketTransport
+
+	// nil until the plugin sent a handshake, plugins without one are treated as legacy
+	handshakeLock sync.Mutex
+	handshake     *plugin_entities.PluginHandshakeEvent
+	onHandshake   func(plugin_entities.PluginHandshakeEvent)
+
+	// events are framed in the encoding negotiated in the handshake, nil is json lines, writes hold
+	// the read lock so that none is sent in the old encoding once the plugin switched
+	encodings []string
+	codec     event_codec.Codec
+	codecLock sync.RWMutex
 }
 
 type StdioHolderConfig struct {
 	StdoutBufferSize    int
 	StdoutMaxBufferSize int
+	// Logs captures what the plugin writes apart from session messages, optional
+	Logs *LogBuffer
+	// OnPong is called whenever the plugin answers a ping, optional
+	OnPong func(time.Time)
+	// Blobs resolves blob references of events and offloads large values of requests, optional
+	Blobs       *blob_channel.Channel
+	BlobMaxSize int64
+	// Socket replaces stdin and stdout once the plugin connected to it, optional
+	Socket *socketTransport
+	// OnHandshake is called with the version and the agreed capabilities of the plugin, optional
+	OnHandshake func(plugin_entities.PluginHandshakeEvent)
+	// Encodings are the binary encodings plugins may negotiate, optional
+	Encodings []string
 }
 
 func newStdioHolder(
@@ -67,6 +123,7 @@ func newStdioHolder(
 
 	holder := &stdioHolder{
 		pluginUniqueIdentifier: pluginUniqueIdentifier,
+		logger:                 log.WithPlugin(strings.Split(pluginUniqueIdentifier, ":")[0]),
 		writer:                 writer,
 		reader:                 reader,
 		errReader:              err_reader,
@@ -76,28 +133,91 @@ func newStdioHolder(
 		stdoutMaxBufferSize:    config.StdoutMaxBufferSize,
 		waitControllerChanLock: &sync.Mutex{},
 		waitingControllerChan:  make(chan bool),
+		logs:                   config.Logs,
+		onPong:                 config.OnPong,
+		blobs:                  config.Blobs,
+		blobMaxSize:            config.BlobMaxSize,
+		socket:                 config.Socket,
+		onHandshake:            config.OnHandshake,
+		encodings:              config.Encodings,
 	}
 
 	return holder
 }
 
-func (s *stdioHolder) setupStdioEventListener(session_id string, listener func([]byte)) {
+// setupStdioEventListener registers the session, it returns false if the holder is draining
+func (s *stdioHolder) setupStdioEventListener(session_id string, listener func([]byte)) bool {
 	s.l.Lock()
 	defer s.l.Unlock()
+	if s.draining {
+		return false
+	}
 	if s.listener == nil {
 		s.listener = map[string]func([]byte){}
 	}
 
 	s.listener[session_id] = listener
+	if s.socket != nil {
+		s.socket.pin(session_id)
+	}
+	return true
 }
 
 func (s *stdioHolder) removeStdioHandlerListener(session_id string) {
 	s.l.Lock()
 	defer s.l.Unlock()
 	delete(s.listener, session_id)
+	if s.socket != nil {
+		s.socket.unpin(session_id)
+	}
+}
+
+// drain refuses new sessions, the ones registered already keep listening
+func (s *stdioHolder) drain() {
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.draining = true
+}
+
+// sessions returns the number of sessions still listening to the plugin
+func (s *stdioHolder) sessions() int {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return len(s.listener)
+}
+
+// offload moves large values of a request to the blob channel if the plugin supports it
+func (s *stdioHolder) offload(data []byte, threshold int) []byte {
+	if s.blobs == nil || !s.supports(plugin_entities.PLUGIN_CAPABILITY_BLOB_CHANNEL) {
+		return data
+	}
+	return s.blobs.Offload(data, threshold)
 }
 
 func (s *stdioHolder) write(data []byte) error {
+	return s.writeSession("", data)
+}
+
+// writeSession writes to the socket stream of the session if the plugin connected to the socket
+func (s *stdioHolder) writeSession(session_id string, data []byte) error {
+	s.codecLock.RLock()
+	defer s.codecLock.RUnlock()
+	if s.codec != nil {
+		encoded, err := s.codec.Encode(bytes.TrimSpace(data))
+		if err != nil {
+			return err
+		}
+		data = event_codec.AppendFrame(nil, encoded)
+	}
+	return s.writeRaw(session_id, data)
+}
+
+func (s *stdioHolder) writeRaw(session_id string, data []byte) error {
+	if s.socket != nil {
+		if ok, err := s.socket.write(session_id, data); ok {
+			return err
+		}
+	}
 	_, err := s.writer.Write(data)
 	return err
 }
@@ -118,6 +238,9 @@ func (s *stdioHolder) Stop() {
 	s.writer.Close()
 	s.reader.Close()
 	s.errReader.Close()
+	if s.socket != nil {
+		s.socket.close()
+	}
 
 	s.waitControllerChanLock.Lock()
 	if !s.waitingControllerChanClosed {
@@ -132,13 +255,14 @@ func (s *stdioHolder) Stop() {
 // and parse the stdout data to trigger corresponding listeners
 func (s *stdioHolder) StartStdout(notify_heartbeat func()) {
 	s.started = true
-	s.lastActiveAt = time.Now()
+	s.touch()
 	defer s.Stop()
 
 	scanner := bufio.NewScanner(s.reader)
 
 	// TODO: set a reasonable buffer size or use a reader, this is a temporary solution
 	scanner.Buffer(make([]byte, s.stdoutBufferSize), s.stdoutMaxBufferSize)
+	framed := s.splitEvents(scanner, true)
 
 	for scanner.Scan() {
 		data := scanner.Bytes()
@@ -148,35 +272,123 @@ func (s *stdioHolder) StartStdout(notify_heartbeat func()) {
 		}
 
 		// update the last active time on each time the plugin sends data
-		s.lastActiveAt = time.Now()
-
-		plugin_entities.ParsePluginUniversalEvent(
-			data,
-			"",
-			func(session_id string, data []byte) {
-				// FIX: avoid deadlock to plugin invoke
-				s.l.Lock()
-				listener := s.listener[session_id]
-				s.l.Unlock()
-				if listener != nil {
-					listener(data)
-				}
-			},
-			func() {
-				// notify launched
-				notify_heartbeat()
-			},
-			func(err string) {
-				log.Error("plugin %s: %s", s.pluginUniqueIdentifier, err)
-			},
-			func(message string) {
-				log.Info("plugin %s: %s", s.pluginUniqueIdentifier, message)
-			},
-		)
+		s.touch()
+
+		if s.socket != nil && s.socket.connected() {
+			// what libraries of the plugin print can't break the protocol on the socket
+			s.logs.Append(LOG_STREAM_STDOUT, string(data))
+			s.logger.Info("plugin %s: %s", s.pluginUniqueIdentifier, data)
+			continue
+		}
+
+		if data = s.decodeEvent(data, *framed); data != nil {
+			s.handleEvent(data, notify_heartbeat)
+		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Error("plugin %s has an error on stdout: %s", s.pluginUniqueIdentifier, err)
+		s.logger.Error("plugin %s has an error on stdout: %s", s.pluginUniqueIdentifier, err)
+	}
+}
+
+// handleEvent dispatches an event the plugin sent on stdout or a socket stream, events referring to
+// blobs which didn't arrive yet are dispatched once they did
+func (s *stdioHolder) handleEvent(data []byte, notify_heartbeat func()) {
+	if s.blobs == nil {
+		s.parseEvent(data, notify_heartbeat)
+		return
+	}
+
+	s.blobs.Dispatch(data, BLOB_RESOLVE_TIMEOUT, s.blobMaxSize, func(resolved []byte, err error) {
+		if err != nil {
+			s.logger.Error("plugin %s: failed to resolve blobs: %s", s.pluginUniqueIdentifier, err.Error())
+			return
+		}
+		s.parseEvent(resolved, notify_heartbeat)
+	})
+}
+
+func (s *stdioHolder) parseEvent(data []byte, notify_heartbeat func()) {
+	plugin_entities.ParsePluginUniversalEvent(
+		data,
+		"",
+		func(session_id string, data []byte) {
+			// FIX: avoid deadlock to plugin invoke
+			s.l.Lock()
+			listener := s.listener[session_id]
+			s.l.Unlock()
+			if listener != nil {
+				listener(data)
+			}
+		},
+		func() {
+			// notify launched
+			notify_heartbeat()
+		},
+		func(err string) {
+			s.logs.Append(LOG_STREAM_STDOUT, err)
+			s.logger.Error("plugin %s: %s", s.pluginUniqueIdentifier, err)
+		},
+		func(message string) {
+			s.logs.Append(LOG_STREAM_STDOUT, message)
+			s.logger.Info("plugin %s: %s", s.pluginUniqueIdentifier, message)
+		},
+		s.handlePong,
+		s.handleHandshake,
+	)
+}
+
+// splitEvents splits what the plugin sends into lines or, once an encoding was negotiated, frames,
+// the returned flag tells which the last token was, stdout is kept as lines while it only has logs
+func (s *stdioHolder) splitEvents(scanner *bufio.Scanner, stdout bool) *bool {
+	framed := new(bool)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		s.codecLock.RLock()
+		*framed = s.codec != nil && !(stdout && s.socket != nil && s.socket.connected())
+		s.codecLock.RUnlock()
+		if *framed {
+			return event_codec.ScanFrames(data, atEOF)
+		}
+		return bufio.ScanLines(data, atEOF)
+	})
+	return framed
+}
+
+// decodeEvent converts a frame to json, nil if it is invalid
+func (s *stdioHolder) decodeEvent(data []byte, framed bool) []byte {
+	if !framed {
+		return data
+	}
+	s.codecLock.RLock()
+	codec := s.codec
+	s.codecLock.RUnlock()
+	decoded, err := codec.Decode(data)
+	if err != nil {
+		s.logger.Error("plugin %s: failed to decode %s event: %s", s.pluginUniqueIdentifier, codec.Name(), err.Error())
+		return nil
+	}
+	return decoded
+}
+
+// StartStream reads the events of a socket stream
+func (s *stdioHolder) StartStream(reader io.Reader) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, s.stdoutBufferSize), s.stdoutMaxBufferSize)
+	framed := s.splitEvents(scanner, false)
+
+	for scanner.Scan() {
+		data := scanner.Bytes()
+		if len(data) == 0 {
+			continue
+		}
+		s.touch()
+		if data = s.decodeEvent(data, *framed); data != nil {
+			s.handleEvent(data, func() {})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		s.logger.Error("plugin %s has an error on a socket stream: %s", s.pluginUniqueIdentifier, err)
 	}
 }
 
@@ -209,16 +421,30 @@ func (s *stdioHolder) StartStderr() {
 		if err != nil && err != io.EOF {
 			break
 		} else if err != nil {
+			s.logs.Append(LOG_STREAM_STDERR, string(buf[:n]))
 			s.WriteError(fmt.Sprintf("%s\n", buf[:n]))
 			break
 		}
 
 		if n > 0 {
+			s.logs.Append(LOG_STREAM_STDERR, string(buf[:n]))
 			s.WriteError(fmt.Sprintf("%s\n", buf[:n]))
 		}
 	}
 }
 
+func (s *stdioHolder) touch() {
+	s.activeLock.Lock()
+	s.lastActiveAt = time.Now()
+	s.activeLock.Unlock()
+}
+
+func (s *stdioHolder) inactiveFor() time.Duration {
+	s.activeLock.Lock()
+	defer s.activeLock.Unlock()
+	return time.Since(s.lastActiveAt)
+}
+
 // Wait waits for the plugin to exit
 // it will return an error if the plugin is not active
 // you can also call `Stop()` to stop the waiting process
@@ -243,20 +469,35 @@ func (s *stdioHolder) Wait() error {
 		s.waitControllerChanLock.Unlock()
 		select {
 		case <-ticker.C:
+			if s.isUnresponsive() {
+				s.logger.Error(
+					"plugin %s did not answer a ping in time, it may be hung, killing and restarting it",
+					s.pluginUniqueIdentifier,
+				)
+				return plugin_errors.ErrPluginNotResponding
+			}
+
+			// plugins may announce that they don't send heartbeats, pings cover them instead if they
+			// are sent, heartbeats are still expected otherwise
+			if !s.supports(plugin_entities.PLUGIN_CAPABILITY_HEARTBEAT) && s.pingsCoverLiveness() {
+				continue
+			}
+
 			// check heartbeat
-			if time.Since(s.lastActiveAt) > MAX_HEARTBEAT_INTERVAL {
-				log.Error(
+			inactive := s.inactiveFor()
+			if inactive > MAX_HEARTBEAT_INTERVAL {
+				s.logger.Error(
 					"plugin %s is not active for %f seconds, it may be dead, killing and restarting it",
 					s.pluginUniqueIdentifier,
-					time.Since(s.lastActiveAt).Seconds(),
+					inactive.Seconds(),
 				)
 				return plugin_errors.ErrPluginNotActive
 			}
-			if time.Since(s.lastActiveAt) > MAX_HEARTBEAT_INTERVAL/2 {
-				log.Warn(
+			if inactive > MAX_HEARTBEAT_INTERVAL/2 {
+				s.logger.Warn(
 					"plugin %s is not active for %f seconds, it may be dead",
 					s.pluginUniqueIdentifier,
-					time.Since(s.lastActiveAt).Seconds(),
+					inactive.Seconds(),
 				)
 			}
 		case <-s.waitingControllerChan:
@@ -267,3 +508,159 @@ func (s *stdioHolder) Wait() error {
 
 	return nil
 }
+
+// StartHealthCheck pings the plugin every interval until it stops, the plugin is considered hung
+// if a ping is not answered within timeout, unlike heartbeats this detects a stuck request loop
+func (s *stdioHolder) StartHealthCheck(interval time.Duration, timeout time.Duration) {
+	s.healthLock.Lock()
+	s.pinging = true
+	s.healthLock.Unlock()
+	defer func() {
+		s.healthLock.Lock()
+		s.pinging = false
+		s.healthLock.Unlock()
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.waitingControllerChan:
+			return
+		}
+
+		s.healthLock.Lock()
+		if s.pendingPingID != "" {
+			// keep waiting for the ping sent before instead of moving the deadline
+			if s.pongSupported && time.Since(s.pendingPingAt) > timeout {
+				s.unresponsive = true
+			}
+			s.healthLock.Unlock()
+			continue
+		}
+		s.pendingPingID = strconv.FormatInt(time.Now().UnixNano(), 36)
+		s.pendingPingAt = time.Now()
+		ping := parser.MarshalJsonBytes(map[string]any{
+			"event": plugin_entities.PLUGIN_IN_STREAM_EVENT_PING,
+			"data":  plugin_entities.PluginPingEvent{ID: s.pendingPingID},
+		})
+		s.healthLock.Unlock()
+
+		if err := s.write(append(ping, '\n')); err != nil {
+			return
+		}
+	}
+}
+
+// pingsCoverLiveness reports whether the plugin is pinged and answers pings
+func (s *stdioHolder) pingsCoverLiveness() bool {
+	s.healthLock.Lock()
+	defer s.healthLock.Unlock()
+	return s.pinging && s.pongSupported
+}
+
+func (s *stdioHolder) handlePong(id string) {
+	s.healthLock.Lock()
+	if id == "" || id != s.pendingPingID {
+		s.healthLock.Unlock()
+		return
+	}
+	now := time.Now()
+	s.pendingPingID = ""
+	s.pongSupported = true
+	s.healthLock.Unlock()
+
+	if s.onPong != nil {
+		s.onPong(now)
+	}
+}
+
+func (s *stdioHolder) isUnresponsive() bool {
+	s.healthLock.Lock()
+	defer s.healthLock.Unlock()
+	return s.unresponsive
+}
+
+// handleHandshake agrees on the protocol version and on the capabilities both sides support, and
+// answers the plugin with them
+func (s *stdioHolder) handleHandshake(handshake plugin_entities.PluginHandshakeEvent) {
+	if handshake.ProtocolVersion > plugin_entities.PLUGIN_PROTOCOL_VERSION {
+		handshake.ProtocolVersion = plugin_entities.PLUGIN_PROTOCOL_VERSION
+	}
+	if handshake.ProtocolVersion < plugin_entities.PLUGIN_PROTOCOL_VERSION_LEGACY {
+		handshake.ProtocolVersion = plugin_entities.PLUGIN_PROTOCOL_VERSION_LEGACY
+	}
+
+	capabilities := []plugin_entities.PluginCapability{}
+	for _, capability := range handshake.Capabilities {
+		switch capability {
+		case plugin_entities.PLUGIN_CAPABILITY_HEARTBEAT,
+			plugin_entities.PLUGIN_CAPABILITY_PING,
+			plugin_entities.PLUGIN_CAPABILITY_TRACING,
+			plugin_entities.PLUGIN_CAPABILITY_ABORT:
+		case plugin_entities.PLUGIN_CAPABILITY_BLOB_CHANNEL:
+			if s.blobs == nil {
+				continue
+			}
+		case plugin_entities.PLUGIN_CAPABILITY_SOCKET:
+			if s.socket == nil {
+				continue
+			}
+		default:
+			continue
+		}
+		capabilities = append(capabilities, capability)
+	}
+	handshake.Capabilities = capabilities
+
+	codec := event_codec.Negotiate(handshake.Encodings, s.encodings)
+	encoding := event_codec.ENCODING_JSON
+	if codec != nil {
+		encoding = codec.Name()
+	}
+	handshake.Encodings = []string{encoding}
+
+	s.handshakeLock.Lock()
+	s.handshake = &handshake
+	s.handshakeLock.Unlock()
+
+	if handshake.Supports(plugin_entities.PLUGIN_CAPABILITY_PING) {
+		// no need to wait for the first pong to enforce pings
+		s.healthLock.Lock()
+		s.pongSupported = true
+		s.healthLock.Unlock()
+	}
+
+	reply := parser.MarshalJsonBytes(map[string]any{
+		"event": plugin_entities.PLUGIN_IN_STREAM_EVENT_HANDSHAKE,
+		"data": map[string]any{
+			"protocol_version": handshake.ProtocolVersion,
+			"capabilities":     handshake.Capabilities,
+			"encoding":         encoding,
+		},
+	})
+	// the answer is the last json line, everything after it is in the encoding
+	s.codecLock.Lock()
+	if err := s.writeRaw("", append(reply, '\n')); err != nil {
+		s.logger.Error("plugin %s: failed to answer the handshake: %s", s.pluginUniqueIdentifier, err.Error())
+	}
+	s.codec = codec
+	s.codecLock.Unlock()
+
+	if s.onHandshake != nil {
+		s.onHandshake(handshake)
+	}
+}
+
+// supports reports whether the plugin agreed on a capability, legacy plugins are assumed to have
+// what they had before handshakes
+func (s *stdioHolder) supports(capability plugin_entities.PluginCapability) bool {
+	s.handshakeLock.Lock()
+	defer s.handshakeLock.Unlock()
+	if s.handshake == nil {
+		return true
+	}
+	return s.handshake.Supports(capability)
+}
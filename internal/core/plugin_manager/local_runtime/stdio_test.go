@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/event_codec"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/plugin_errors"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
@@ -441,3 +442,164 @@ func TestMultipleTransactions(t *testing.T) {
 	assert.Equal(t, 0, len(transactionMap))
 	transactionMapLock.Unlock()
 }
+
+// TestStdioHolderHealthCheck tests pings are answered by pongs and unanswered pings are detected
+func TestStdioHolderHealthCheck(t *testing.T) {
+	stdin := newMockReadWriteCloser()
+	stdout := newMockReadWriteCloser()
+	stderr := newMockReadWriteCloser()
+
+	pongs := make(chan time.Time, 1)
+	holder := newStdioHolder("test-plugin", stdin, stdout, stderr, &StdioHolderConfig{
+		OnPong: func(t time.Time) { pongs <- t },
+	})
+	holder.lastActiveAt = time.Now()
+
+	go holder.StartStdout(func() {})
+	go holder.StartHealthCheck(20*time.Millisecond, 50*time.Millisecond)
+
+	// answer the first ping
+	type pingMessage struct {
+		Event string                          `json:"event"`
+		Data  plugin_entities.PluginPingEvent `json:"data"`
+	}
+	var ping pingMessage
+	assert.Eventually(t, func() bool {
+		line, _, _ := bytes.Cut(stdin.GetWrittenData(), []byte("\n"))
+		var err error
+		ping, err = parser.UnmarshalJsonBytes[pingMessage](line)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, plugin_entities.PLUGIN_IN_STREAM_EVENT_PING, ping.Event)
+
+	stdout.WriteToRead([]byte(`{"event":"pong","data":{"id":"` + ping.Data.ID + `"}}` + "\n"))
+	select {
+	case <-pongs:
+	case <-time.After(time.Second):
+		t.Fatal("pong not handled")
+	}
+	assert.False(t, holder.isUnresponsive())
+
+	// stop answering, the plugin must be reported as hung
+	assert.Eventually(t, holder.isUnresponsive, time.Second, 10*time.Millisecond)
+	assert.Equal(t, plugin_errors.ErrPluginNotResponding, holder.Wait())
+	holder.Stop()
+}
+
+// TestStdioHolderHealthCheckUnsupported tests plugins never answering pings are not killed
+func TestStdioHolderHealthCheckUnsupported(t *testing.T) {
+	holder := newStdioHolder("test-plugin", newMockReadWriteCloser(), newMockReadWriteCloser(), newMockReadWriteCloser(), nil)
+
+	go holder.StartHealthCheck(10*time.Millisecond, 20*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, holder.isUnresponsive())
+	holder.Stop()
+}
+
+// TestStdioHolderHandshake tests the daemon answers with the capabilities it agreed on
+func TestStdioHolderHandshake(t *testing.T) {
+	stdin := newMockReadWriteCloser()
+	stdout := newMockReadWriteCloser()
+	stderr := newMockReadWriteCloser()
+
+	handshakes := make(chan plugin_entities.PluginHandshakeEvent, 1)
+	holder := newStdioHolder("test-plugin", stdin, stdout, stderr, &StdioHolderConfig{
+		OnHandshake: func(h plugin_entities.PluginHandshakeEvent) { handshakes <- h },
+	})
+	defer holder.Stop()
+
+	assert.True(t, holder.supports(plugin_entities.PLUGIN_CAPABILITY_HEARTBEAT))
+
+	go holder.StartStdout(func() {})
+	stdout.WriteToRead([]byte(`{"event":"handshake","data":{"sdk_version":"0.3.0","protocol_version":3,` +
+		`"capabilities":["ping","blob_channel","unknown"]}}` + "\n"))
+
+	var handshake plugin_entities.PluginHandshakeEvent
+	select {
+	case handshake = <-handshakes:
+	case <-time.After(time.Second):
+		t.Fatal("handshake not handled")
+	}
+	assert.Equal(t, "0.3.0", handshake.SDKVersion)
+	assert.Equal(t, plugin_entities.PLUGIN_PROTOCOL_VERSION, handshake.ProtocolVersion)
+	// there is no blob channel to agree on
+	assert.Equal(t, []plugin_entities.PluginCapability{plugin_entities.PLUGIN_CAPABILITY_PING}, handshake.Capabilities)
+
+	type handshakeMessage struct {
+		Event string                               `json:"event"`
+		Data  plugin_entities.PluginHandshakeEvent `json:"data"`
+	}
+	line, _, _ := bytes.Cut(stdin.GetWrittenData(), []byte("\n"))
+	reply, err := parser.UnmarshalJsonBytes[handshakeMessage](line)
+	assert.NoError(t, err)
+	assert.Equal(t, plugin_entities.PLUGIN_IN_STREAM_EVENT_HANDSHAKE, reply.Event)
+	assert.Equal(t, handshake.Capabilities, reply.Data.Capabilities)
+
+	// the plugin said it doesn't send heartbeats, but answers pings right away
+	assert.False(t, holder.supports(plugin_entities.PLUGIN_CAPABILITY_HEARTBEAT))
+	holder.healthLock.Lock()
+	assert.True(t, holder.pongSupported)
+	holder.healthLock.Unlock()
+}
+
+// TestStdioHolderEncoding tests events are framed in the encoding negotiated in the handshake
+func TestStdioHolderEncoding(t *testing.T) {
+	stdin := newMockReadWriteCloser()
+	stdout := newMockReadWriteCloser()
+	stderr := newMockReadWriteCloser()
+
+	holder := newStdioHolder("test-plugin", stdin, stdout, stderr, &StdioHolderConfig{
+		Encodings: []string{event_codec.ENCODING_MSGPACK},
+	})
+	defer holder.Stop()
+
+	received := make(chan string, 1)
+	holder.setupStdioEventListener("session", func(data []byte) {
+		received <- string(data)
+	})
+	go holder.StartStdout(func() {})
+
+	stdout.WriteToRead([]byte(`{"event":"handshake","data":{"protocol_version":2,"encodings":["cbor","msgpack"]}}` + "\n"))
+	assert.Eventually(t, func() bool {
+		return bytes.Contains(stdin.GetWrittenData(), []byte(`"encoding":"msgpack"`))
+	}, time.Second, 10*time.Millisecond)
+
+	codec, _ := event_codec.Get(event_codec.ENCODING_MSGPACK)
+	event, err := codec.Encode([]byte(`{"session_id":"session","event":"session","data":{"type":"end"}}`))
+	assert.NoError(t, err)
+	stdout.WriteToRead(event_codec.AppendFrame(nil, event))
+	select {
+	case data := <-received:
+		assert.JSONEq(t, `{"type":"end"}`, data)
+	case <-time.After(time.Second):
+		t.Fatal("framed event was not dispatched")
+	}
+
+	// requests written after the handshake are framed as well
+	written := len(stdin.GetWrittenData())
+	assert.NoError(t, holder.write([]byte(`{"session_id":"session","event":"request"}`+"\n")))
+	frame := stdin.GetWrittenData()[written:]
+	advance, _, err := event_codec.ScanFrames(frame, true)
+	assert.NoError(t, err)
+	assert.Equal(t, len(frame), advance)
+	decoded, err := codec.Decode(frame[event_codec.FRAME_HEADER_SIZE:])
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"session_id":"session","event":"request"}`, string(decoded))
+}
+
+// TestStdioHolderHeartbeatWithoutPings tests plugins without heartbeats are still checked by them
+// while they are not pinged
+func TestStdioHolderHeartbeatWithoutPings(t *testing.T) {
+	holder := newStdioHolder("test-plugin", newMockReadWriteCloser(), newMockReadWriteCloser(), newMockReadWriteCloser(), nil)
+	defer holder.Stop()
+
+	holder.handshake = &plugin_entities.PluginHandshakeEvent{
+		ProtocolVersion: plugin_entities.PLUGIN_PROTOCOL_VERSION,
+		Capabilities:    []plugin_entities.PluginCapability{plugin_entities.PLUGIN_CAPABILITY_PING},
+	}
+	holder.pongSupported = true
+	assert.False(t, holder.pingsCoverLiveness())
+
+	holder.lastActiveAt = time.Now().Add(-(MAX_HEARTBEAT_INTERVAL + time.Second))
+	assert.Equal(t, plugin_errors.ErrPluginNotActive, holder.Wait())
+}
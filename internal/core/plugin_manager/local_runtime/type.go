@@ -1,9 +1,12 @@
 package local_runtime
 
 import (
+	"os"
 	"sync"
+	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/basic_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/blob_channel"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
@@ -32,21 +35,81 @@ type LocalPluginRuntime struct {
 	pipVerbose      bool
 	pipExtraArgs    string
 
+	// node plugins install their dependencies with pnpm if they have a pnpm lockfile, npm otherwise
+	nodeInterpreterPath string
+	nodeEnvInitTimeout  int
+	npmPath             string
+	pnpmPath            string
+	npmRegistryUrl      string
+
+	// uv cache shared by plugins, dependencies are only installed from the offline wheels if set
+	pythonEnvCachePath   string
+	pipOfflineWheelsPath string
+	// environments with the same python and requirements share their packages, see venv_cache.go
+	pythonVenvCacheEnabled bool
+	// nothing is downloaded while installing dependencies
+	installOffline bool
+
 	// proxy settings
 	HttpProxy  string
 	HttpsProxy string
 	NoProxy    string
+	// egressProxy replaces the proxies above for the plugin process, not for installing dependencies
+	egressProxy string
 
 	waitChanLock    sync.Mutex
 	waitStartedChan []chan bool
 	waitStoppedChan []chan bool
+	// process of the running plugin, guarded by waitChanLock
+	process *os.Process
 
 	stdoutBufferSize    int
 	stdoutMaxBufferSize int
 
 	isNotFirstStart bool
 
+	// restart the plugin when its source changes
+	hotReload bool
+
+	// resource limits are enforced through cgroups on linux and job objects on windows
+	resourceLimitsEnabled bool
+	maxResources          ResourceLimits
+	cgroupRoot            string
+
+	// plugins are started through the sandbox package, see sandbox.Wrap
+	sandboxEnabled         bool
+	sandboxReadOnlyPaths   []string
+	sandboxWritablePaths   []string
+	sandboxAppArmorProfile string
+
 	stdioHolder *stdioHolder
+	// sessions refused while draining, see Listen
+	refusedSessions sync.Map
+
+	// output of the plugin kept across restarts, see Logs
+	logs *LogBuffer
+
+	// the plugin is pinged every interval and restarted if it does not answer within timeout,
+	// disabled if interval is zero
+	healthCheckInterval time.Duration
+	healthCheckTimeout  time.Duration
+
+	// plugins are bound into idle interpreters of the pool if possible, nil starts them on their own
+	warmPool *WarmPool
+
+	// binary payloads are exchanged over extra pipes if the plugin supports it, see blob_channel
+	blobChannelEnabled   bool
+	blobOffloadThreshold int
+	blobMaxSize          int64
+	blobMemoryLimit      int64
+	blobChannel          *blob_channel.Channel
+
+	// TRANSPORT_SOCKET offers the plugin a unix socket, plugins not connecting to it keep using stdio
+	transport string
+	socket    *socketTransport
+
+	// binary encodings plugins may negotiate instead of json lines, see event_codec
+	eventEncodings []string
 }
 
 type LocalPluginRuntimeConfig struct {
@@ -57,12 +120,40 @@ type LocalPluginRuntimeConfig struct {
 	HttpProxy                 string
 	HttpsProxy                string
 	NoProxy                   string
+	EgressProxy               string
 	PipMirrorUrl              string
 	PipPreferBinary           bool
 	PipVerbose                bool
 	PipExtraArgs              string
+	PythonEnvCachePath        string
+	NodeInterpreterPath       string
+	NodeEnvInitTimeout        int
+	NpmPath                   string
+	PnpmPath                  string
+	NpmRegistryUrl            string
+	PipOfflineWheelsPath      string
+	PythonVenvCacheEnabled    bool
+	InstallOffline            bool
 	StdoutBufferSize          int
 	StdoutMaxBufferSize       int
+	HotReload                 bool
+	ResourceLimitsEnabled     bool
+	MaxResources              ResourceLimits
+	CgroupRoot                string
+	SandboxEnabled            bool
+	SandboxReadOnlyPaths      []string
+	SandboxWritablePaths      []string
+	SandboxAppArmorProfile    string
+	LogBufferLines            int
+	HealthCheckInterval       time.Duration
+	HealthCheckTimeout        time.Duration
+	WarmPool                  *WarmPool
+	BlobChannelEnabled        bool
+	BlobOffloadThreshold      int
+	BlobMaxSize               int64
+	BlobMemoryLimit           int64
+	Transport                 string
+	EventEncodings            []string
 }
 
 func NewLocalPluginRuntime(config LocalPluginRuntimeConfig) *LocalPluginRuntime {
@@ -74,11 +165,44 @@ func NewLocalPluginRuntime(config LocalPluginRuntimeConfig) *LocalPluginRuntime
 		HttpProxy:                    config.HttpProxy,
 		HttpsProxy:                   config.HttpsProxy,
 		NoProxy:                      config.NoProxy,
+		egressProxy:                  config.EgressProxy,
 		pipMirrorUrl:                 config.PipMirrorUrl,
 		pipPreferBinary:              config.PipPreferBinary,
 		pipVerbose:                   config.PipVerbose,
 		pipExtraArgs:                 config.PipExtraArgs,
+		pythonEnvCachePath:           config.PythonEnvCachePath,
+		nodeInterpreterPath:          config.NodeInterpreterPath,
+		nodeEnvInitTimeout:           config.NodeEnvInitTimeout,
+		npmPath:                      config.NpmPath,
+		pnpmPath:                     config.PnpmPath,
+		npmRegistryUrl:               config.NpmRegistryUrl,
+		pipOfflineWheelsPath:         config.PipOfflineWheelsPath,
+		pythonVenvCacheEnabled:       config.PythonVenvCacheEnabled,
+		installOffline:               config.InstallOffline,
 		stdoutBufferSize:             config.StdoutBufferSize,
 		stdoutMaxBufferSize:          config.StdoutMaxBufferSize,
+		hotReload:                    config.HotReload,
+		resourceLimitsEnabled:        config.ResourceLimitsEnabled,
+		maxResources:                 config.MaxResources,
+		cgroupRoot:                   config.CgroupRoot,
+		sandboxEnabled:               config.SandboxEnabled,
+		sandboxReadOnlyPaths:         config.SandboxReadOnlyPaths,
+		sandboxWritablePaths:         config.SandboxWritablePaths,
+		sandboxAppArmorProfile:       config.SandboxAppArmorProfile,
+		logs:                         NewLogBuffer(config.LogBufferLines),
+		healthCheckInterval:          config.HealthCheckInterval,
+		healthCheckTimeout:           config.HealthCheckTimeout,
+		warmPool:                     config.WarmPool,
+		blobChannelEnabled:           config.BlobChannelEnabled,
+		blobOffloadThreshold:         config.BlobOffloadThreshold,
+		blobMaxSize:                  config.BlobMaxSize,
+		blobMemoryLimit:              config.BlobMemoryLimit,
+		transport:                    config.Transport,
+		eventEncodings:               config.EventEncodings,
 	}
 }
+
+// Logs returns the captured stdout and stderr of the plugin
+func (r *LocalPluginRuntime) Logs() *LogBuffer {
+	return r.logs
+}
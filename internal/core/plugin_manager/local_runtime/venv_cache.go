@@ -0,0 +1,159 @@
+package local_runtime
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/consts"
+)
+
+// venvCacheRoot keeps the lib directories of prepared environments by venvCacheKey, files are cloned
+// into the environments of plugins where the filesystem supports reflinks and copied otherwise, a
+// plugin changing its packages must not change those of others
+func (p *LocalPluginRuntime) venvCacheRoot() (string, error) {
+	return filepath.Abs(filepath.Join(p.pythonEnvCachePath, "venvs"))
+}
+
+// venvCacheKey hashes what decides the installed packages, the python of the environment, the
+// requirements and where they are installed from
+func (p *LocalPluginRuntime) venvCacheKey(venv string, requirementsPath string) (string, error) {
+	pythonVersion, err := venvPythonFullVersion(venv)
+	if err != nil {
+		return "", err
+	}
+	requirements, err := os.ReadFile(requirementsPath)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	for _, part := range []string{pythonVersion, p.pipOfflineWheelsPath, p.pipMirrorUrl, p.pipExtraArgs} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	// wheels bundled into the package pin what is installed
+	bundled, _ := os.ReadDir(filepath.Join(p.State.WorkingPath, consts.DEPENDENCY_BUNDLE_DIR))
+	for _, wheel := range bundled {
+		hash.Write([]byte(wheel.Name()))
+		hash.Write([]byte{0})
+	}
+	hash.Write(requirements)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// restoreVenvCache replaces the packages of a new environment with the prepared ones, console
+// scripts of bin are not restored as plugins are run with python -m
+func (p *LocalPluginRuntime) restoreVenvCache(key string, venv string) bool {
+	root, err := p.venvCacheRoot()
+	if err != nil {
+		return false
+	}
+	cached := filepath.Join(root, key, "lib")
+	if _, err := os.Stat(cached); err != nil {
+		return false
+	}
+
+	restoring := filepath.Join(venv, "lib.restoring")
+	if err := copyTree(cached, restoring); err != nil {
+		log.Warn("failed to restore the prepared environment %s: %s", key, err)
+		os.RemoveAll(restoring)
+		return false
+	}
+	if err := os.RemoveAll(filepath.Join(venv, "lib")); err != nil {
+		os.RemoveAll(restoring)
+		return false
+	}
+	return os.Rename(restoring, filepath.Join(venv, "lib")) == nil
+}
+
+// storeVenvCache keeps the packages of an environment for plugins with the same key, the first one
+// to finish wins
+func (p *LocalPluginRuntime) storeVenvCache(key string, venv string) {
+	root, err := p.venvCacheRoot()
+	if err != nil {
+		return
+	}
+	target := filepath.Join(root, key)
+	if _, err := os.Stat(target); err == nil {
+		return
+	}
+
+	staging := filepath.Join(root, key+".staging-"+uuid.New().String())
+	defer os.RemoveAll(staging)
+	if err := copyTree(filepath.Join(venv, "lib"), filepath.Join(staging, "lib")); err != nil {
+		log.Warn("failed to cache the environment of %s: %s", p.Config.Identity(), err)
+		return
+	}
+	os.Rename(staging, target)
+}
+
+// copyTree recreates src at dst, files are never shared with src
+func copyTree(src string, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, relative)
+
+		switch {
+		case entry.IsDir():
+			return os.MkdirAll(target, 0o755)
+		case entry.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			if err := cloneFile(path, target); err == nil {
+				return nil
+			}
+			return copyFile(path, target)
+		}
+	})
+}
+
+func copyFile(src string, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// replaceFile writes a file of the environment at once, a plugin starting meanwhile never imports a
+// partially written file
+func replaceFile(path string, data []byte) error {
+	temp := path + ".dify-" + uuid.New().String()
+	if err := os.WriteFile(temp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(temp, path); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package local_runtime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/consts"
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeVenv(t *testing.T, packages map[string]string) (string, string) {
+	workingPath := t.TempDir()
+	venv := filepath.Join(workingPath, ".venv")
+	sitePackages := filepath.Join(venv, "lib", "python3.12", "site-packages")
+	assert.NoError(t, os.MkdirAll(sitePackages, 0o755))
+	os.WriteFile(filepath.Join(venv, "pyvenv.cfg"), []byte("version_info = 3.12.3\n"), 0o644)
+	os.WriteFile(filepath.Join(workingPath, "requirements.txt"), []byte("dify_plugin==0.3.0\n"), 0o644)
+	for name, content := range packages {
+		os.WriteFile(filepath.Join(sitePackages, name), []byte(content), 0o644)
+	}
+	return venv, filepath.Join(workingPath, "requirements.txt")
+}
+
+func TestVenvCache(t *testing.T) {
+	localRuntime := &LocalPluginRuntime{pythonEnvCachePath: t.TempDir()}
+
+	prepared, requirements := fakeVenv(t, map[string]string{"dify_plugin.py": "sdk"})
+	key, err := localRuntime.venvCacheKey(prepared, requirements)
+	assert.NoError(t, err)
+	assert.False(t, localRuntime.restoreVenvCache(key, prepared))
+	localRuntime.storeVenvCache(key, prepared)
+
+	venv, otherRequirements := fakeVenv(t, map[string]string{"_virtualenv.py": "uv"})
+	otherKey, err := localRuntime.venvCacheKey(venv, otherRequirements)
+	assert.NoError(t, err)
+	assert.Equal(t, key, otherKey)
+
+	assert.True(t, localRuntime.restoreVenvCache(key, venv))
+	restored := filepath.Join(venv, "lib", "python3.12", "site-packages", "dify_plugin.py")
+	content, err := os.ReadFile(restored)
+	assert.NoError(t, err)
+	assert.Equal(t, "sdk", string(content))
+
+	// writing to the files of a plugin keeps the prepared environment and the one it was made from as they are
+	root, _ := localRuntime.venvCacheRoot()
+	cachedPath := filepath.Join(root, key, "lib", "python3.12", "site-packages", "dify_plugin.py")
+	preparedPath := filepath.Join(prepared, "lib", "python3.12", "site-packages", "dify_plugin.py")
+	assert.NoError(t, replaceFile(restored, []byte("patched")))
+	assert.NoError(t, os.WriteFile(restored, []byte("modified in place"), 0o644))
+	assert.NoError(t, os.WriteFile(preparedPath, []byte("modified in place"), 0o644))
+	cached, _ := os.ReadFile(cachedPath)
+	assert.Equal(t, "sdk", string(cached))
+	for _, path := range []string{restored, preparedPath} {
+		restoredInfo, _ := os.Stat(path)
+		cachedInfo, _ := os.Stat(cachedPath)
+		assert.False(t, os.SameFile(restoredInfo, cachedInfo), "files must not be shared with the cache")
+	}
+
+	os.WriteFile(otherRequirements, []byte("dify_plugin==0.4.0\n"), 0o644)
+	otherKey, err = localRuntime.venvCacheKey(venv, otherRequirements)
+	assert.NoError(t, err)
+	assert.NotEqual(t, key, otherKey)
+}
+
+func TestBundledDependencies(t *testing.T) {
+	venv, requirements := fakeVenv(t, nil)
+	localRuntime := &LocalPluginRuntime{pythonEnvCachePath: t.TempDir(), installOffline: true}
+	localRuntime.State.WorkingPath = filepath.Dir(venv)
+
+	_, err := localRuntime.pythonFindLinks()
+	assert.Error(t, err, "offline installs need wheels")
+	key, err := localRuntime.venvCacheKey(venv, requirements)
+	assert.NoError(t, err)
+
+	bundled := filepath.Join(localRuntime.State.WorkingPath, consts.DEPENDENCY_BUNDLE_DIR)
+	os.MkdirAll(bundled, 0o755)
+	os.WriteFile(filepath.Join(bundled, "dify_plugin-0.3.0-py3-none-any.whl"), []byte("wheel"), 0o644)
+
+	links, err := localRuntime.pythonFindLinks()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{bundled}, links)
+	assert.True(t, localRuntime.pythonInstallOffline())
+
+	bundledKey, err := localRuntime.venvCacheKey(venv, requirements)
+	assert.NoError(t, err)
+	assert.NotEqual(t, key, bundledKey)
+}
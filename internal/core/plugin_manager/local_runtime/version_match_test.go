@@ -72,3 +72,22 @@ func TestCompareVersion(t *testing.T) {
 
 	assert.True(t, v3.LessThan(v4))
 }
+
+func TestPythonVersion(t *testing.T) {
+	localRuntime := &LocalPluginRuntime{}
+	for pinned, expected := range map[string]string{
+		"3.11":    "3.11",
+		"3.11.9 ": "3.11.9",
+		"":        DEFAULT_PYTHON_VERSION,
+		"python3": DEFAULT_PYTHON_VERSION,
+		"2.7":     DEFAULT_PYTHON_VERSION,
+	} {
+		localRuntime.Config.Meta.Runner.Version = pinned
+		assert.Equal(t, expected, localRuntime.pythonVersion(), pinned)
+	}
+
+	assert.True(t, versionMatches("3.11.9", "3.11"))
+	assert.True(t, versionMatches("3.11.9", "3.11.9"))
+	assert.False(t, versionMatches("3.11.10", "3.11.1"))
+	assert.False(t, versionMatches("3.12.3", "3.11"))
+}
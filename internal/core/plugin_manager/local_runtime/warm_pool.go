@@ -0,0 +1,268 @@
+package local_runtime
+
+import (
+	"bufio"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+//go:embed warm_worker.py
+var warmWorkerScript string
+
+const (
+	// preloading may import heavy modules, workers are only handed out once they are ready
+	WARM_WORKER_READY_TIMEOUT = 60 * time.Second
+	WARM_WORKER_BIND_TIMEOUT  = 10 * time.Second
+)
+
+type WarmPoolConfig struct {
+	Size                  int
+	PythonInterpreterPath string
+	Preload               []string
+}
+
+// WarmPool keeps idle python interpreters with common modules imported, local plugins are bound into
+// one of them when they start instead of starting an interpreter of their own
+type WarmPool struct {
+	config WarmPoolConfig
+
+	mu       sync.Mutex
+	idle     []*warmWorker
+	starting int
+	closed   bool
+}
+
+// warmWorker is an idle interpreter running warm_worker.py
+type warmWorker struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stdout  io.ReadCloser
+	stderr  io.ReadCloser
+	version string
+}
+
+type warmWorkerBind struct {
+	WorkingPath string            `json:"working_path"`
+	Venv        string            `json:"venv"`
+	Executable  string            `json:"executable"`
+	Entrypoint  string            `json:"entrypoint"`
+	Env         map[string]string `json:"env"`
+}
+
+func NewWarmPool(config WarmPoolConfig) *WarmPool {
+	pool := &WarmPool{config: config}
+	pool.fill()
+	return pool
+}
+
+// fill starts workers until the pool is full, workers failing to start are not retried until
+// the next one is taken
+func (p *WarmPool) fill() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for ; !p.closed && len(p.idle)+p.starting < p.config.Size; p.starting++ {
+		routine.Submit(map[string]string{
+			"module":   "plugin_manager",
+			"type":     "local",
+			"function": "WarmPool",
+		}, func() {
+			worker, err := p.spawn()
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.starting--
+			if err != nil {
+				log.Warn("failed to start warm worker: %s", err.Error())
+				return
+			}
+			if p.closed {
+				worker.kill()
+				return
+			}
+			p.idle = append(p.idle, worker)
+		})
+	}
+}
+
+func (p *WarmPool) spawn() (*warmWorker, error) {
+	// -S keeps the site-packages of the interpreter out, plugins only see their own virtual environment
+	cmd := exec.Command(p.config.PythonInterpreterPath, append([]string{"-S", "-c", warmWorkerScript}, p.config.Preload...)...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	worker := &warmWorker{cmd: cmd, stdin: stdin, stdout: stdout, stderr: stderr}
+
+	ready := struct {
+		Version string `json:"version"`
+	}{}
+	if err := worker.readLine(WARM_WORKER_READY_TIMEOUT, &ready); err != nil || ready.Version == "" {
+		worker.kill()
+		return nil, fmt.Errorf("warm worker is not ready: %v", err)
+	}
+	worker.version = ready.Version
+
+	return worker, nil
+}
+
+// take hands out an idle worker running the python version and starts a new one in its place,
+// nil is returned if there is none
+func (p *WarmPool) take(version string) *warmWorker {
+	p.mu.Lock()
+	var worker *warmWorker
+	for i, w := range p.idle {
+		if w.version == version {
+			worker = w
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			break
+		}
+	}
+	p.mu.Unlock()
+
+	if worker != nil {
+		p.fill()
+	}
+	return worker
+}
+
+// Close kills the idle workers, workers bound to plugins are stopped with their plugins
+func (p *WarmPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	for _, worker := range p.idle {
+		worker.kill()
+	}
+	p.idle = nil
+}
+
+// readLine reads a single line of stdout byte by byte, anything after it is output of the plugin,
+// the worker must be killed if it fails
+func (w *warmWorker) readLine(timeout time.Duration, out any) error {
+	result := make(chan error, 1)
+	go func() {
+		line := []byte{}
+		b := make([]byte, 1)
+		for {
+			if _, err := w.stdout.Read(b); err != nil {
+				result <- err
+				return
+			}
+			if b[0] == '\n' {
+				result <- json.Unmarshal(line, out)
+				return
+			}
+			line = append(line, b[0])
+		}
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(timeout):
+		return errors.New("timed out")
+	}
+}
+
+// bind runs the plugin in the worker, the worker becomes the process of the plugin
+func (w *warmWorker) bind(request warmWorkerBind) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	if _, err := w.stdin.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	ack := struct {
+		Bound bool `json:"bound"`
+	}{}
+	if err := w.readLine(WARM_WORKER_BIND_TIMEOUT, &ack); err != nil {
+		return err
+	}
+	if !ack.Bound {
+		return errors.New("plugin is not bound")
+	}
+	return nil
+}
+
+func (w *warmWorker) kill() {
+	w.cmd.Process.Kill()
+	w.cmd.Wait()
+}
+
+// venvPythonVersion returns the major and minor version of python the virtual environment is created with
+func venvPythonVersion(venv string) (string, error) {
+	version, err := venvPythonFullVersion(venv)
+	if err != nil {
+		return "", err
+	}
+	parts := strings.Split(version, ".")
+	return parts[0] + "." + parts[1], nil
+}
+
+// venvPythonFullVersion returns the version of python the virtual environment is created with
+func venvPythonFullVersion(venv string) (string, error) {
+	file, err := os.Open(filepath.Join(venv, "pyvenv.cfg"))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	versions := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if ok {
+			versions[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+
+	// uv writes version_info, venv of the standard library writes version
+	version := versions["version_info"]
+	if version == "" {
+		version = versions["version"]
+	}
+	if len(strings.Split(version, ".")) < 2 {
+		return "", fmt.Errorf("unknown python version of %s", venv)
+	}
+	return version, nil
+}
+
+// envMap turns KEY=VALUE pairs into a map, later pairs win like they do for exec.Cmd
+func envMap(env []string) map[string]string {
+	result := map[string]string{}
+	for _, pair := range env {
+		if key, value, ok := strings.Cut(pair, "="); ok {
+			result[key] = value
+		}
+	}
+	return result
+}
@@ -0,0 +1,104 @@
+package local_runtime
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+func TestVenvPythonVersion(t *testing.T) {
+	venv := t.TempDir()
+	if err := os.WriteFile(filepath.Join(venv, "pyvenv.cfg"), []byte("home = /usr/bin\nimplementation = CPython\nversion_info = 3.12.3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	version, err := venvPythonVersion(venv)
+	if err != nil || version != "3.12" {
+		t.Fatalf("expected 3.12, got %s %v", version, err)
+	}
+
+	if _, err := venvPythonVersion(t.TempDir()); err == nil {
+		t.Fatal("expected an error without pyvenv.cfg")
+	}
+}
+
+func waitIdle(t *testing.T, pool *WarmPool, n int) {
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		pool.mu.Lock()
+		idle := len(pool.idle)
+		pool.mu.Unlock()
+		if idle >= n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d idle warm workers", n)
+}
+
+func TestWarmPoolBind(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not available")
+	}
+
+	workingPath := t.TempDir()
+	venv := filepath.Join(workingPath, ".venv")
+	if err := os.MkdirAll(venv, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// the plugin echoes lines with the environment it's bound with
+	if err := os.WriteFile(filepath.Join(workingPath, "main.py"), []byte(`import os, sys
+for line in sys.stdin:
+    print(line.strip(), os.environ["INSTALL_METHOD"], sys.prefix == os.environ["VIRTUAL_ENV"], "json" in sys.modules, flush=True)
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	routine.InitPool(16)
+	pool := NewWarmPool(WarmPoolConfig{Size: 1, PythonInterpreterPath: python, Preload: []string{"json", "not_a_module"}})
+	defer pool.Close()
+	waitIdle(t, pool, 1)
+
+	pool.mu.Lock()
+	version := pool.idle[0].version
+	pool.mu.Unlock()
+
+	if pool.take("2.7") != nil {
+		t.Fatal("expected no worker for another python version")
+	}
+
+	worker := pool.take(version)
+	if worker == nil {
+		t.Fatal("expected an idle worker")
+	}
+	defer worker.kill()
+	// a new worker replaces the one taken
+	waitIdle(t, pool, 1)
+
+	if err := worker.bind(warmWorkerBind{
+		WorkingPath: workingPath,
+		Venv:        venv,
+		Executable:  python,
+		Entrypoint:  "main",
+		Env:         envMap([]string{"INSTALL_METHOD=remote", "INSTALL_METHOD=local"}),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := worker.stdin.Write([]byte("ping\n")); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(worker.stdout).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(line) != "ping local True True" {
+		t.Fatalf("unexpected output of the bound plugin: %q", line)
+	}
+}
@@ -0,0 +1,56 @@
+# a warm worker of the daemon, it's started with -S before any plugin is known, imports the modules
+# given as arguments and waits for the daemon to bind a plugin into it through the first line of stdin
+import importlib
+import json
+import os
+import runpy
+import site
+import sys
+import sysconfig
+
+for module in sys.argv[1:]:
+    try:
+        importlib.import_module(module)
+    except Exception as e:
+        sys.stderr.write("failed to preload %s: %s\n" % (module, e))
+        sys.stderr.flush()
+
+
+def read_line(fd):
+    # read byte by byte, anything after the line belongs to the plugin
+    line = bytearray()
+    while True:
+        b = os.read(fd, 1)
+        if not b:
+            sys.exit(0)
+        if b == b"\n":
+            return bytes(line)
+        line += b
+
+
+def write_line(fd, payload):
+    data = (json.dumps(payload) + "\n").encode()
+    while data:
+        data = data[os.write(fd, data) :]
+
+
+write_line(1, {"version": "%d.%d" % sys.version_info[:2]})
+
+bind = json.loads(read_line(0))
+venv = bind["venv"]
+
+os.chdir(bind["working_path"])
+os.environ.update(bind["env"])
+os.environ["VIRTUAL_ENV"] = venv
+
+sys.prefix = sys.exec_prefix = venv
+sys.executable = bind["executable"]
+paths = sysconfig.get_paths(vars={"base": venv, "platbase": venv})
+for key in ("purelib", "platlib"):
+    site.addsitedir(paths[key])
+sys.path.insert(0, bind["working_path"])
+
+write_line(1, {"bound": True})
+
+sys.argv = [bind["entrypoint"]]
+runpy.run_module(bind["entrypoint"], run_name="__main__", alter_sys=True)
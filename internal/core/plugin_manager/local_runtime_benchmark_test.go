@@ -16,7 +16,7 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/basic_runtime"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/lifecycle"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/local_runtime"
-	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/test_utils"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/standalone_runtime"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/model_entities"
@@ -154,7 +154,7 @@ func BenchmarkLocalOpenAILLMInvocation(b *testing.B) {
 				<-sem
 				wg.Done()
 			}()
-			response, err := test_utils.RunOnce[requests.RequestInvokeLLM, model_entities.LLMResultChunk](
+			response, err := standalone_runtime.RunOnce[requests.RequestInvokeLLM, model_entities.LLMResultChunk](
 				runtime,
 				access_types.PLUGIN_ACCESS_TYPE_MODEL,
 				access_types.PLUGIN_ACCESS_ACTION_INVOKE_LLM,
@@ -0,0 +1,51 @@
+package plugin_manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/malware_scanner"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+// scanBeforeLaunch submits the plugin code to the configured malware scanner,
+// an infected package is never extracted to the working directory
+func (p *PluginManager) scanBeforeLaunch(pluginDecoder decoder.PluginDecoder) error {
+	if p.malwareScanner == nil {
+		return nil
+	}
+
+	checksum, err := pluginDecoder.Checksum()
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("calculate checksum error"))
+	}
+
+	verdict, err := malware_scanner.ScanPackageCached(
+		context.Background(),
+		p.malwareScanner,
+		checksum,
+		pluginDecoder,
+		time.Duration(p.config.MalwareScanVerdictTTL)*time.Second,
+	)
+	if err != nil {
+		if p.config.MalwareScanFailOpen {
+			log.Warn("malware scan of %s failed, launching anyway: %s", checksum, err.Error())
+			return nil
+		}
+		return errors.Join(err, fmt.Errorf("malware scan error"))
+	}
+
+	if !verdict.Clean {
+		threats := make([]string, 0, len(verdict.Threats))
+		for _, threat := range verdict.Threats {
+			threats = append(threats, fmt.Sprintf("%s (%s)", threat.File, threat.Signature))
+		}
+		return fmt.Errorf("plugin package is flagged by %s scanner: %s", verdict.Scanner, strings.Join(threats, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package malware_scanner
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+const (
+	VERDICT_CACHE_KEY_PREFIX = "malware_scan_verdict"
+)
+
+// ScanPackageCached reuses the verdict of a package with the same checksum, verdicts are
+// shared across the cluster and only scan results are cached, errors are retried next time
+func ScanPackageCached(
+	ctx context.Context,
+	scanner Scanner,
+	checksum string,
+	pluginDecoder decoder.PluginDecoder,
+	ttl time.Duration,
+) (*Verdict, error) {
+	key := strings.Join([]string{VERDICT_CACHE_KEY_PREFIX, scanner.Name(), checksum}, ":")
+
+	verdict, err := cache.Get[Verdict](key)
+	if err == nil {
+		return verdict, nil
+	} else if err != cache.ErrNotFound {
+		log.Warn("failed to load malware scan verdict of %s: %s", checksum, err.Error())
+	}
+
+	verdict, err = ScanPackage(ctx, scanner, pluginDecoder)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cache.Store(key, verdict, ttl); err != nil {
+		log.Warn("failed to cache malware scan verdict of %s: %s", checksum, err.Error())
+	}
+
+	return verdict, nil
+}
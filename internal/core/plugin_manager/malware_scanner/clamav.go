@@ -0,0 +1,120 @@
+package malware_scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+)
+
+const (
+	SCANNER_CLAMAV = "clamav"
+
+	// clamd rejects chunks larger than StreamMaxLength, keep them small
+	CLAMAV_CHUNK_SIZE = 64 * 1024
+)
+
+func init() {
+	Register(SCANNER_CLAMAV, func(config *app.Config) (Scanner, error) {
+		if config.MalwareScannerClamAVAddress == "" {
+			return nil, errors.New("MALWARE_SCANNER_CLAMAV_ADDRESS is required by clamav scanner")
+		}
+		network, address := parseClamAVAddress(config.MalwareScannerClamAVAddress)
+		return &ClamAVScanner{
+			Network: network,
+			Address: address,
+			Timeout: time.Duration(config.MalwareScannerTimeout) * time.Second,
+		}, nil
+	})
+}
+
+// ClamAVScanner talks to clamd with the INSTREAM command
+type ClamAVScanner struct {
+	Network string
+	Address string
+	Timeout time.Duration
+}
+
+// parseClamAVAddress accepts unix:///path/to/clamd.sock, tcp://host:port or host:port
+func parseClamAVAddress(address string) (string, string) {
+	if after, ok := strings.CutPrefix(address, "unix://"); ok {
+		return "unix", after
+	}
+	if after, ok := strings.CutPrefix(address, "tcp://"); ok {
+		return "tcp", after
+	}
+	if strings.HasPrefix(address, "/") {
+		return "unix", address
+	}
+	return "tcp", address
+}
+
+func (s *ClamAVScanner) Name() string {
+	return SCANNER_CLAMAV
+}
+
+func (s *ClamAVScanner) Scan(ctx context.Context, filename string, content []byte) (string, error) {
+	dialer := net.Dialer{Timeout: s.Timeout}
+	conn, err := dialer.DialContext(ctx, s.Network, s.Address)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else if s.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(s.Timeout))
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return "", err
+	}
+
+	size := make([]byte, 4)
+	for start := 0; start < len(content); start += CLAMAV_CHUNK_SIZE {
+		chunk := content[start:min(start+CLAMAV_CHUNK_SIZE, len(content))]
+		binary.BigEndian.PutUint32(size, uint32(len(chunk)))
+		if _, err := conn.Write(size); err != nil {
+			return "", err
+		}
+		if _, err := conn.Write(chunk); err != nil {
+			return "", err
+		}
+	}
+
+	// a zero length chunk terminates the stream
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := conn.Write(size); err != nil {
+		return "", err
+	}
+
+	response, err := io.ReadAll(conn)
+	if err != nil {
+		return "", err
+	}
+
+	return parseClamAVResponse(response)
+}
+
+// parseClamAVResponse parses replies like "stream: OK" and "stream: Eicar-Signature FOUND"
+func parseClamAVResponse(response []byte) (string, error) {
+	result := strings.TrimSpace(string(bytes.TrimRight(response, "\x00")))
+	result = strings.TrimPrefix(result, "stream: ")
+
+	switch {
+	case result == "OK":
+		return "", nil
+	case strings.HasSuffix(result, " FOUND"):
+		return strings.TrimSuffix(result, " FOUND"), nil
+	default:
+		return "", fmt.Errorf("clamd error: %s", result)
+	}
+}
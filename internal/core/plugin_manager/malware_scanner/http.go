@@ -0,0 +1,90 @@
+package malware_scanner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+)
+
+const (
+	SCANNER_HTTP = "http"
+)
+
+func init() {
+	Register(SCANNER_HTTP, func(config *app.Config) (Scanner, error) {
+		if config.MalwareScannerHTTPURL == "" {
+			return nil, errors.New("MALWARE_SCANNER_HTTP_URL is required by http scanner")
+		}
+		return &HTTPScanner{
+			URL:    config.MalwareScannerHTTPURL,
+			APIKey: config.MalwareScannerHTTPAPIKey,
+			Client: &http.Client{
+				Timeout: time.Duration(config.MalwareScannerTimeout) * time.Second,
+			},
+		}, nil
+	})
+}
+
+// HTTPScanner posts the raw file content to a scanning service, the file name is
+// sent in X-Filename and the service responds with {"clean": bool, "signature": string}
+type HTTPScanner struct {
+	URL    string
+	APIKey string
+	Client *http.Client
+}
+
+type httpScanResponse struct {
+	Clean     bool   `json:"clean"`
+	Signature string `json:"signature"`
+}
+
+func (s *HTTPScanner) Name() string {
+	return SCANNER_HTTP
+}
+
+func (s *HTTPScanner) Scan(ctx context.Context, filename string, content []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-Filename", filename)
+	if s.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("scanning service responded with status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	result, err := parser.UnmarshalJsonBytes[httpScanResponse](body)
+	if err != nil {
+		return "", err
+	}
+
+	if result.Clean {
+		return "", nil
+	}
+	if result.Signature == "" {
+		return "unknown", nil
+	}
+	return result.Signature, nil
+}
@@ -0,0 +1,109 @@
+package malware_scanner
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+// Scanner inspects a single file extracted from a plugin package
+type Scanner interface {
+	Name() string
+	// Scan returns the name of the detected threat, empty if the content is clean
+	Scan(ctx context.Context, filename string, content []byte) (string, error)
+}
+
+// Factory creates a scanner from the daemon configuration
+type Factory func(config *app.Config) (Scanner, error)
+
+type Threat struct {
+	File      string `json:"file"`
+	Signature string `json:"signature"`
+}
+
+type Verdict struct {
+	Scanner   string    `json:"scanner"`
+	Clean     bool      `json:"clean"`
+	Threats   []Threat  `json:"threats,omitempty"`
+	ScannedAt time.Time `json:"scanned_at"`
+}
+
+var (
+	factories     = map[string]Factory{}
+	factoriesLock sync.RWMutex
+)
+
+// Register adds a scanner type which can be selected by MALWARE_SCANNER
+func Register(name string, factory Factory) {
+	factoriesLock.Lock()
+	defer factoriesLock.Unlock()
+	factories[name] = factory
+}
+
+// New returns the scanner configured by MALWARE_SCANNER, nil if scanning is disabled
+func New(config *app.Config) (Scanner, error) {
+	if config.MalwareScanner == "" {
+		return nil, nil
+	}
+
+	factoriesLock.RLock()
+	factory, ok := factories[config.MalwareScanner]
+	factoriesLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown malware scanner: %s", config.MalwareScanner)
+	}
+
+	return factory(config)
+}
+
+// ScanPackage submits every file of the plugin package to the scanner
+func ScanPackage(ctx context.Context, scanner Scanner, pluginDecoder decoder.PluginDecoder) (*Verdict, error) {
+	verdict := &Verdict{
+		Scanner: scanner.Name(),
+		Clean:   true,
+	}
+
+	err := pluginDecoder.Walk(func(filename string, dir string) error {
+		if filename == "" {
+			return nil
+		}
+		name := path.Join(dir, filename)
+
+		info, err := pluginDecoder.Stat(name)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		content, err := pluginDecoder.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		signature, err := scanner.Scan(ctx, name, content)
+		if err != nil {
+			return fmt.Errorf("failed to scan %s: %w", name, err)
+		}
+		if signature != "" {
+			verdict.Clean = false
+			verdict.Threats = append(verdict.Threats, Threat{
+				File:      name,
+				Signature: signature,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	verdict.ScannedAt = time.Now()
+	return verdict, nil
+}
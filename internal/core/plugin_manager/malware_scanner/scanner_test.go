@@ -0,0 +1,147 @@
+package malware_scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd implements the INSTREAM command and reports EICAR test files
+func fakeClamd(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				command := make([]byte, len("zINSTREAM\x00"))
+				if _, err := io.ReadFull(conn, command); err != nil {
+					return
+				}
+
+				content := bytes.Buffer{}
+				size := make([]byte, 4)
+				for {
+					if _, err := io.ReadFull(conn, size); err != nil {
+						return
+					}
+					length := binary.BigEndian.Uint32(size)
+					if length == 0 {
+						break
+					}
+					if _, err := io.CopyN(&content, conn, int64(length)); err != nil {
+						return
+					}
+				}
+
+				if bytes.Contains(content.Bytes(), []byte("EICAR-STANDARD-ANTIVIRUS-TEST-FILE")) {
+					conn.Write([]byte("stream: Eicar-Signature FOUND\x00"))
+				} else {
+					conn.Write([]byte("stream: OK\x00"))
+				}
+			}(conn)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestClamAVScanner(t *testing.T) {
+	network, address := parseClamAVAddress("tcp://" + fakeClamd(t))
+	scanner := &ClamAVScanner{Network: network, Address: address, Timeout: 5 * time.Second}
+
+	signature, err := scanner.Scan(context.Background(), "main.py", []byte("print('hello')"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signature != "" {
+		t.Fatalf("expected clean, got %s", signature)
+	}
+
+	// spans several chunks
+	content := append(bytes.Repeat([]byte("a"), CLAMAV_CHUNK_SIZE*2), []byte(eicar)...)
+	signature, err = scanner.Scan(context.Background(), "eicar.txt", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signature != "Eicar-Signature" {
+		t.Fatalf("expected Eicar-Signature, got %q", signature)
+	}
+}
+
+func TestParseClamAVAddress(t *testing.T) {
+	cases := map[string][2]string{
+		"unix:///var/run/clamav/clamd.ctl": {"unix", "/var/run/clamav/clamd.ctl"},
+		"/tmp/clamd.sock":                  {"unix", "/tmp/clamd.sock"},
+		"tcp://clamav:3310":                {"tcp", "clamav:3310"},
+		"clamav:3310":                      {"tcp", "clamav:3310"},
+	}
+	for address, expected := range cases {
+		network, addr := parseClamAVAddress(address)
+		if network != expected[0] || addr != expected[1] {
+			t.Fatalf("unexpected result of %s: %s %s", address, network, addr)
+		}
+	}
+}
+
+func TestParseClamAVResponseError(t *testing.T) {
+	if _, err := parseClamAVResponse([]byte("INSTREAM size limit exceeded. ERROR\x00")); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestHTTPScanner(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if bytes.Contains(body, []byte("EICAR")) {
+			w.Write([]byte(`{"clean": false, "signature": "EICAR-Test-File"}`))
+		} else {
+			w.Write([]byte(`{"clean": true}`))
+		}
+	}))
+	defer server.Close()
+
+	scanner := &HTTPScanner{URL: server.URL, APIKey: "key", Client: http.DefaultClient}
+
+	signature, err := scanner.Scan(context.Background(), "main.py", []byte("print('hello')"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signature != "" {
+		t.Fatalf("expected clean, got %s", signature)
+	}
+
+	signature, err = scanner.Scan(context.Background(), "eicar.txt", []byte(eicar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signature != "EICAR-Test-File" {
+		t.Fatalf("expected EICAR-Test-File, got %q", signature)
+	}
+
+	scanner.APIKey = ""
+	if _, err := scanner.Scan(context.Background(), "main.py", []byte("print('hello')")); err == nil {
+		t.Fatal("expected error on unauthorized response")
+	}
+}
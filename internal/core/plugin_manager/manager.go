@@ -3,15 +3,24 @@ package plugin_manager
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"sync/atomic"
 
 	"github.com/langgenius/dify-cloud-kit/oss"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation/real"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/debugging_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/install_hook"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/local_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/malware_scanner"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/media_transport"
 	serverless "github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector/azure_functions"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector/cloud_run"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector/self_hosted"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/trust"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
@@ -20,6 +29,7 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/lock"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/mapping"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/mtls"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
 )
@@ -52,6 +62,27 @@ type PluginManager struct {
 
 	// max launching lock to prevent too many plugins launching at the same time
 	maxLaunchingLock chan bool
+
+	// installQueue runs install tasks in background
+	installQueue *InstallQueue
+
+	// malwareScanner scans plugin code before the first launch, nil if disabled
+	malwareScanner malware_scanner.Scanner
+
+	// trustPolicies limit plugins by the trust tier of their signature
+	trustPolicies trust.Policies
+
+	// installHooks are the custom steps of operators run when plugins are installed
+	installHooks *install_hook.Pipeline
+
+	// shuttingDown stops the watchers from launching plugins, see Shutdown
+	shuttingDown atomic.Bool
+
+	// localPluginAssignment limits the local plugins launched on the current node, nil launches all
+	localPluginAssignment LocalPluginAssignment
+
+	// warmPool keeps idle interpreters local plugins are bound into, nil if disabled
+	warmPool *local_runtime.WarmPool
 }
 
 var (
@@ -78,6 +109,25 @@ func InitGlobalManager(oss oss.OSS, configuration *app.Config) *PluginManager {
 		maxLaunchingLock: make(chan bool, configuration.PluginLocalLaunchingConcurrent),
 		config:           configuration,
 	}
+	manager.installQueue = newInstallQueue(manager, configuration)
+
+	malwareScanner, err := malware_scanner.New(configuration)
+	if err != nil {
+		log.Panic("init malware scanner failed: %s", err.Error())
+	}
+	manager.malwareScanner = malwareScanner
+
+	trustPolicies, err := trust.LoadPolicies(configuration.PluginTrustPolicyPath)
+	if err != nil {
+		log.Panic("load trust policies failed: %s", err.Error())
+	}
+	manager.trustPolicies = trustPolicies
+
+	installHooks, err := install_hook.Load(configuration.PluginInstallHooksPath)
+	if err != nil {
+		log.Panic("load install hooks failed: %s", err.Error())
+	}
+	manager.installHooks = installHooks
 
 	return manager
 }
@@ -114,7 +164,12 @@ func (p *PluginManager) Launch(configuration *app.Config) {
 	log.Info("start plugin manager daemon...")
 
 	// init redis client
-	if configuration.RedisUseSentinel {
+	if configuration.CacheType == app.CACHE_TYPE_MEMORY {
+		if err := cache.InitMemoryClient(configuration.CacheMemoryMaxKeys); err != nil {
+			log.Panic("init memory cache failed: %s", err.Error())
+		}
+		log.Warn("using in-process memory cache, cluster state is not shared, run a single replica only")
+	} else if configuration.RedisUseSentinel {
 		// use Redis Sentinel
 		sentinels := strings.Split(configuration.RedisSentinels, ",")
 		if err := cache.InitRedisSentinelClient(
@@ -130,6 +185,20 @@ func (p *PluginManager) Launch(configuration *app.Config) {
 		); err != nil {
 			log.Panic("init redis sentinel client failed: %s", err.Error())
 		}
+	} else if configuration.RedisUseClusters {
+		// password of the cluster falls back to REDIS_PASSWORD
+		password := configuration.RedisClustersPassword
+		if password == "" {
+			password = configuration.RedisPass
+		}
+		if err := cache.InitRedisClusterClient(
+			strings.Split(configuration.RedisClusters, ","),
+			configuration.RedisUser,
+			password,
+			configuration.RedisUseSsl,
+		); err != nil {
+			log.Panic("init redis cluster client failed: %s", err.Error())
+		}
 	} else {
 		if err := cache.InitRedisClient(
 			fmt.Sprintf("%s:%d", configuration.RedisHost, configuration.RedisPort),
@@ -142,12 +211,21 @@ func (p *PluginManager) Launch(configuration *app.Config) {
 		}
 	}
 
+	var invocationTLS *mtls.Files
+	if configuration.DifyInnerApiTLSCertFile != "" || configuration.DifyInnerApiTLSCAFile != "" {
+		invocationTLS = &mtls.Files{
+			CertFile: configuration.DifyInnerApiTLSCertFile,
+			KeyFile:  configuration.DifyInnerApiTLSKeyFile,
+			CAFile:   configuration.DifyInnerApiTLSCAFile,
+		}
+	}
 	invocation, err := real.NewDifyInvocationDaemon(
 		real.NewDifyInvocationDaemonPayload{
 			BaseUrl:      configuration.DifyInnerApiURL,
 			CallingKey:   configuration.DifyInnerApiKey,
 			WriteTimeout: configuration.DifyInvocationWriteTimeout,
 			ReadTimeout:  configuration.DifyInvocationReadTimeout,
+			TLS:          invocationTLS,
 		},
 	)
 	if err != nil {
@@ -157,27 +235,42 @@ func (p *PluginManager) Launch(configuration *app.Config) {
 
 	// start local watcher
 	if configuration.Platform == app.PLATFORM_LOCAL {
+		if configuration.PluginWarmPoolSize > 0 {
+			p.warmPool = local_runtime.NewWarmPool(local_runtime.WarmPoolConfig{
+				Size:                  configuration.PluginWarmPoolSize,
+				PythonInterpreterPath: configuration.PythonInterpreterPath,
+				Preload:               configuration.PluginWarmPoolPreload,
+			})
+		}
 		p.startLocalWatcher(configuration)
 	}
 
 	// launch serverless connector
 	if configuration.Platform == app.PLATFORM_SERVERLESS {
 		serverless.Init(configuration)
+		cloud_run.Init(configuration)
+		azure_functions.Init(configuration)
+		self_hosted.Init(configuration)
 	}
 
 	// start remote watcher
 	p.startRemoteWatcher(configuration)
+
+	// resume install tasks interrupted by restarts
+	p.installQueue.launch()
 }
 
 func (p *PluginManager) BackwardsInvocation() dify_invocation.BackwardsInvocation {
 	return p.backwardsInvocation
 }
 
-func (p *PluginManager) SavePackage(plugin_unique_identifier plugin_entities.PluginUniqueIdentifier, pkg []byte, thirdPartySignatureVerificationConfig *decoder.ThirdPartySignatureVerificationConfig) (
+// SavePackage decodes the package and streams it into the package bucket, pkg is read on demand
+// so that large packages are never loaded into memory
+func (p *PluginManager) SavePackage(plugin_unique_identifier plugin_entities.PluginUniqueIdentifier, pkg io.ReaderAt, size int64, thirdPartySignatureVerificationConfig *decoder.ThirdPartySignatureVerificationConfig) (
 	*plugin_entities.PluginDeclaration, error,
 ) {
 	// try to decode the package
-	packageDecoder, err := decoder.NewZipPluginDecoderWithThirdPartySignatureVerificationConfig(pkg, thirdPartySignatureVerificationConfig)
+	packageDecoder, err := decoder.NewZipPluginDecoderWithReaderAt(pkg, size, 0, thirdPartySignatureVerificationConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -206,7 +299,7 @@ func (p *PluginManager) SavePackage(plugin_unique_identifier plugin_entities.Plu
 	}
 
 	// save to storage
-	err = p.packageBucket.Save(plugin_unique_identifier.String(), pkg)
+	err = p.packageBucket.SaveStream(plugin_unique_identifier.String(), io.NewSectionReader(pkg, 0, size))
 	if err != nil {
 		return nil, err
 	}
@@ -244,6 +337,22 @@ func (p *PluginManager) GetPackage(
 	return file, nil
 }
 
+// GetPackageSize returns the size of the uploaded package, false if it was never uploaded
+func (p *PluginManager) GetPackageSize(
+	plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
+) (int64, bool, error) {
+	exists, err := p.packageBucket.Exists(plugin_unique_identifier.String())
+	if err != nil || !exists {
+		return 0, false, err
+	}
+
+	size, err := p.packageBucket.Size(plugin_unique_identifier.String())
+	if err != nil {
+		return 0, false, err
+	}
+	return size, true, nil
+}
+
 func (p *PluginManager) GetDeclaration(
 	plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
 	tenant_id string,
@@ -1,22 +1,24 @@
 package media_transport
 
 import (
+	"io"
 	"path/filepath"
 	"regexp"
 	"strings"
 
-	"github.com/langgenius/dify-cloud-kit/oss"
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/oss"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
 type InstalledBucket struct {
-	oss           oss.OSS
+	oss           oss.StreamingOSS
 	installedPath string
 }
 
-func NewInstalledBucket(oss oss.OSS, installedPath string) *InstalledBucket {
+func NewInstalledBucket(storage cloudoss.OSS, installedPath string) *InstalledBucket {
 	// throw a warning if installed_path starts with non-alphanumeric characters
 	if len(installedPath) > 0 {
 		firstChar := installedPath[0]
@@ -26,7 +28,7 @@ func NewInstalledBucket(oss oss.OSS, installedPath string) *InstalledBucket {
 	} else {
 		log.Warn("installed_path is empty")
 	}
-	return &InstalledBucket{oss: oss, installedPath: installedPath}
+	return &InstalledBucket{oss: oss.Streaming(storage), installedPath: installedPath}
 }
 
 // Save saves the plugin to the installed bucket
@@ -37,6 +39,14 @@ func (b *InstalledBucket) Save(
 	return b.oss.Save(filepath.Join(b.installedPath, pluginUniqueIdentifier.String()), file)
 }
 
+// SaveStream saves the plugin to the installed bucket without buffering it
+func (b *InstalledBucket) SaveStream(
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	reader io.Reader,
+) error {
+	return b.oss.SaveStream(filepath.Join(b.installedPath, pluginUniqueIdentifier.String()), reader)
+}
+
 // Exists checks if the plugin exists in the installed bucket
 func (b *InstalledBucket) Exists(
 	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
@@ -58,6 +68,13 @@ func (b *InstalledBucket) Get(
 	return b.oss.Load(filepath.Join(b.installedPath, pluginUniqueIdentifier.String()))
 }
 
+// GetStream opens the plugin in the installed bucket, caller must close it
+func (b *InstalledBucket) GetStream(
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+) (io.ReadCloser, error) {
+	return b.oss.LoadStream(filepath.Join(b.installedPath, pluginUniqueIdentifier.String()))
+}
+
 // List lists all the plugins in the installed bucket
 func (b *InstalledBucket) List() ([]plugin_entities.PluginUniqueIdentifier, error) {
 	paths, err := b.oss.List(b.installedPath)
@@ -1,18 +1,20 @@
 package media_transport
 
 import (
+	"io"
 	"path"
 
-	"github.com/langgenius/dify-cloud-kit/oss"
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+	"github.com/langgenius/dify-plugin-daemon/internal/oss"
 )
 
 type PackageBucket struct {
-	oss         oss.OSS
+	oss         oss.StreamingOSS
 	packagePath string
 }
 
-func NewPackageBucket(oss oss.OSS, package_path string) *PackageBucket {
-	return &PackageBucket{oss: oss, packagePath: package_path}
+func NewPackageBucket(storage cloudoss.OSS, package_path string) *PackageBucket {
+	return &PackageBucket{oss: oss.Streaming(storage), packagePath: package_path}
 }
 
 // Save saves a file to the package bucket
@@ -22,11 +24,35 @@ func (m *PackageBucket) Save(name string, file []byte) error {
 	return m.oss.Save(filePath, file)
 }
 
+// SaveStream saves a file to the package bucket without buffering it
+func (m *PackageBucket) SaveStream(name string, reader io.Reader) error {
+	return m.oss.SaveStream(path.Join(m.packagePath, name), reader)
+}
+
 func (m *PackageBucket) Get(name string) ([]byte, error) {
 	return m.oss.Load(path.Join(m.packagePath, name))
 }
 
+// GetStream opens a file in the package bucket, caller must close it
+func (m *PackageBucket) GetStream(name string) (io.ReadCloser, error) {
+	return m.oss.LoadStream(path.Join(m.packagePath, name))
+}
+
 func (m *PackageBucket) Delete(name string) error {
 	// delete from storage
 	return m.oss.Delete(path.Join(m.packagePath, name))
 }
+
+// Size returns the size in bytes of a file in the package bucket
+func (m *PackageBucket) Size(name string) (int64, error) {
+	state, err := m.oss.State(path.Join(m.packagePath, name))
+	if err != nil {
+		return 0, err
+	}
+	return state.Size, nil
+}
+
+// Exists checks if a file exists in the package bucket
+func (m *PackageBucket) Exists(name string) (bool, error) {
+	return m.oss.Exists(path.Join(m.packagePath, name))
+}
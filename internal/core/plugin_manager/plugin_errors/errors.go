@@ -3,5 +3,6 @@ package plugin_errors
 import "errors"
 
 var (
-	ErrPluginNotActive = errors.New("plugin is not active, does not respond to heartbeat in 20 seconds")
+	ErrPluginNotActive     = errors.New("plugin is not active, does not respond to heartbeat in 20 seconds")
+	ErrPluginNotResponding = errors.New("plugin is hung, does not answer pings")
 )
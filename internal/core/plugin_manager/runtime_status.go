@@ -0,0 +1,67 @@
+package plugin_manager
+
+import (
+	"sort"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/debugging_runtime"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+type RuntimeStatus struct {
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier"`
+	Type                   plugin_entities.PluginRuntimeType      `json:"type"`
+	Status                 string                                 `json:"status"`
+	Restarts               int                                    `json:"restarts"`
+	ActiveAt               *time.Time                             `json:"active_at"`
+	TrustTier              string                                 `json:"trust_tier"`
+	LastPongAt             *time.Time                             `json:"last_pong_at"`
+	Hangs                  int                                    `json:"hangs"`
+	NextRestartAt          *time.Time                             `json:"next_restart_at"`
+	SDKVersion             string                                 `json:"sdk_version"`
+	ProtocolVersion        int                                    `json:"protocol_version"`
+	Capabilities           []string                               `json:"capabilities"`
+	Encoding               string                                 `json:"encoding"`
+}
+
+// Runtimes lists the plugin runtimes managed by current node
+func (p *PluginManager) Runtimes() []RuntimeStatus {
+	runtimes := []RuntimeStatus{}
+	p.m.Range(func(key string, value plugin_entities.PluginLifetime) bool {
+		identity, err := value.Identity()
+		if err != nil {
+			return true
+		}
+
+		state := value.RuntimeState()
+		runtimes = append(runtimes, RuntimeStatus{
+			PluginUniqueIdentifier: identity,
+			Type:                   value.Type(),
+			Status:                 state.Status,
+			Restarts:               state.Restarts,
+			ActiveAt:               state.ActiveAt,
+			TrustTier:              state.TrustTier,
+			LastPongAt:             state.LastPongAt,
+			Hangs:                  state.Hangs,
+			NextRestartAt:          state.NextRestartAt,
+			SDKVersion:             state.SDKVersion,
+			ProtocolVersion:        state.ProtocolVersion,
+			Capabilities:           state.Capabilities,
+			Encoding:               state.Encoding,
+		})
+		return true
+	})
+
+	sort.Slice(runtimes, func(i, j int) bool {
+		return runtimes[i].PluginUniqueIdentifier < runtimes[j].PluginUniqueIdentifier
+	})
+	return runtimes
+}
+
+// DebuggingConnections lists the remote debugging connections of current node
+func (p *PluginManager) DebuggingConnections() []debugging_runtime.DebuggingConnection {
+	if p.remotePluginServer == nil {
+		return []debugging_runtime.DebuggingConnection{}
+	}
+	return p.remotePluginServer.Connections()
+}
@@ -0,0 +1,75 @@
+// Package sandbox launches local plugin processes in namespaces of their own, seeing only the system
+// directories and the directories of the plugin, without capabilities and under a seccomp filter,
+// optionally confined by an AppArmor profile.
+//
+// Go can not run code between fork and exec, so the daemon binary re-executes itself with
+// EXEC_COMMAND as the first argument, sets up the sandbox in the child and then execs the plugin.
+package sandbox
+
+import (
+	"path/filepath"
+	"slices"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	// EXEC_COMMAND is the subcommand of the daemon binary entering the sandbox
+	EXEC_COMMAND = "sandbox-exec"
+	// PROFILE_ENV carries the json encoded profile to the sandboxed child, it's removed before exec
+	PROFILE_ENV = "DIFY_PLUGIN_SANDBOX_PROFILE"
+)
+
+type Profile struct {
+	// WorkingPath is the directory of the plugin, it's visible read-only
+	WorkingPath string `json:"working_path"`
+	// ReadOnlyPaths are visible read-only next to the system directories, the rest of the host is hidden
+	ReadOnlyPaths []string `json:"readonly_paths"`
+	// WritablePaths are visible and writable
+	WritablePaths []string `json:"writable_paths"`
+	// AllowListen permits listen and accept on sockets, only plugins registering endpoints get it
+	AllowListen bool `json:"allow_listen"`
+	// AppArmorProfile confines the plugin if set, the profile must be loaded already
+	AppArmorProfile string `json:"apparmor_profile,omitempty"`
+}
+
+// NewProfile derives the profile of a plugin from the permissions it has been granted
+func NewProfile(
+	workingPath string,
+	permission *plugin_entities.PluginPermissionRequirement,
+	readOnlyPaths []string,
+	writablePaths []string,
+	appArmorProfile string,
+) Profile {
+	return Profile{
+		WorkingPath:     absPath(workingPath),
+		ReadOnlyPaths:   absPaths(readOnlyPaths),
+		WritablePaths:   absPaths(writablePaths),
+		AllowListen:     permission.AllowRegisterEndpoint(),
+		AppArmorProfile: appArmorProfile,
+	}
+}
+
+// absPath resolves relative paths, they would resolve against the working directory of the plugin
+func absPath(path string) string {
+	if path == "" {
+		return path
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+func absPaths(paths []string) []string {
+	result := []string{}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if path = absPath(path); !slices.Contains(result, path) {
+			result = append(result, path)
+		}
+	}
+	return result
+}
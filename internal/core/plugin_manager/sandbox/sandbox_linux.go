@@ -0,0 +1,391 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// systemPaths are needed to run interpreters, they are visible read-only to every plugin
+var systemPaths = []string{"/bin", "/sbin", "/lib", "/lib32", "/lib64", "/libx32", "/usr", "/etc"}
+
+// devices are bound from the host into an otherwise empty /dev
+var devices = []string{"/dev/null", "/dev/zero", "/dev/full", "/dev/random", "/dev/urandom"}
+
+// forwardedSignals are relayed from the init of the sandbox to the plugin
+var forwardedSignals = []os.Signal{
+	unix.SIGTERM, unix.SIGINT, unix.SIGHUP, unix.SIGQUIT, unix.SIGUSR1, unix.SIGUSR2,
+}
+
+// Wrap rewrites cmd to start through the sandbox, it must be called after the environment of cmd is final
+func Wrap(cmd *exec.Cmd, profile Profile) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+
+	cmd.Args = append([]string{self, EXEC_COMMAND, cmd.Path}, cmd.Args...)
+	cmd.Path = self
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", PROFILE_ENV, payload))
+
+	// a pid namespace and a proc of its own keep the daemon and other plugins out of sight
+	attr := &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWNS | syscall.CLONE_NEWPID,
+	}
+	// a user namespace grants the mount privileges to a daemon not running as root
+	if uid, gid := os.Geteuid(), os.Getegid(); uid != 0 {
+		attr.Cloneflags |= syscall.CLONE_NEWUSER
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: uid, HostID: uid, Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: gid, HostID: gid, Size: 1}}
+		attr.GidMappingsEnableSetgroups = false
+	}
+	cmd.SysProcAttr = attr
+
+	return nil
+}
+
+// Exec runs in the child started by Wrap, args are the path of the plugin executable followed by its argv,
+// it only returns if the sandbox could not be set up
+func Exec(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: sandbox-exec <path> <argv...>")
+	}
+
+	payload := os.Getenv(PROFILE_ENV)
+	if payload == "" {
+		return fmt.Errorf("%s is not set", PROFILE_ENV)
+	}
+	var profile Profile
+	if err := json.Unmarshal([]byte(payload), &profile); err != nil {
+		return fmt.Errorf("invalid sandbox profile: %s", err.Error())
+	}
+
+	env := []string{}
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, PROFILE_ENV+"=") {
+			env = append(env, kv)
+		}
+	}
+
+	// capabilities, no_new_privs and the seccomp filter are per thread, the plugin is forked from this one
+	runtime.LockOSThread()
+
+	files, err := inheritedFiles()
+	if err != nil {
+		return fmt.Errorf("collect inherited files failed: %s", err.Error())
+	}
+
+	if err := setupRoot(profile); err != nil {
+		return fmt.Errorf("setup filesystem failed: %s", err.Error())
+	}
+
+	if profile.AppArmorProfile != "" {
+		if err := changeAppArmorProfileOnExec(profile.AppArmorProfile); err != nil {
+			return fmt.Errorf("setup apparmor profile failed: %s", err.Error())
+		}
+	}
+
+	if err := dropCapabilities(); err != nil {
+		return fmt.Errorf("drop capabilities failed: %s", err.Error())
+	}
+
+	if err := installSeccomp(profile); err != nil {
+		return fmt.Errorf("setup seccomp failed: %s", err.Error())
+	}
+
+	return runInit(args, env, files)
+}
+
+// inheritedFiles returns stdio and the descriptors passed on top by the daemon like the blob channel,
+// indexed by their number, descriptors of the runtime are close on exec
+func inheritedFiles() ([]*os.File, error) {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return nil, err
+	}
+
+	files := []*os.File{os.Stdin, os.Stdout, os.Stderr}
+	for _, entry := range entries {
+		fd, err := strconv.Atoi(entry.Name())
+		if err != nil || fd < 3 {
+			continue
+		}
+		flags, err := unix.FcntlInt(uintptr(fd), unix.F_GETFD, 0)
+		if err != nil || flags&unix.FD_CLOEXEC != 0 {
+			continue
+		}
+		for len(files) <= fd {
+			files = append(files, nil)
+		}
+		files[fd] = os.NewFile(uintptr(fd), entry.Name())
+	}
+
+	return files, nil
+}
+
+type bind struct {
+	path     string
+	writable bool
+	// system paths missing on the host are skipped
+	optional bool
+	dir      bool
+}
+
+// setupRoot pivots into an empty tmpfs holding binds of the system paths, the devices and the paths
+// of the profile, private /tmp and /dev/shm and a proc of the pid namespace, the rest of the host is
+// hidden, processes of the daemon included
+func setupRoot(profile Profile) error {
+	// keep the mounts below from propagating to the host
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+
+	// a later bind of the same path wins
+	byPath := map[string]bind{}
+	for _, path := range systemPaths {
+		byPath[path] = bind{path: path, optional: true}
+	}
+	for _, path := range devices {
+		byPath[path] = bind{path: path, writable: true, optional: true}
+	}
+	for _, path := range append(profile.ReadOnlyPaths, profile.WorkingPath) {
+		if path != "" {
+			byPath[path] = bind{path: path}
+		}
+	}
+	for _, path := range profile.WritablePaths {
+		byPath[path] = bind{path: path, writable: true}
+	}
+	// parents are mounted before their children
+	binds := slices.SortedFunc(maps.Values(byPath), func(a, b bind) int {
+		return strings.Compare(a.path, b.path)
+	})
+
+	// clone the trees before the new root is mounted over /tmp, it would hide sources below it
+	trees := make([]int, len(binds))
+	symlinks := map[string]string{}
+	defer func() {
+		for _, fd := range trees {
+			if fd > 0 {
+				unix.Close(fd)
+			}
+		}
+	}()
+	for i := range binds {
+		b := &binds[i]
+		if b.optional {
+			info, err := os.Lstat(b.path)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			// merged /usr, /bin is a symlink to usr/bin
+			if err == nil && info.Mode()&os.ModeSymlink != 0 {
+				if symlinks[b.path], err = os.Readlink(b.path); err == nil {
+					continue
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		info, err := os.Stat(b.path)
+		if err != nil {
+			return err
+		}
+		b.dir = info.IsDir()
+
+		fd, err := unix.OpenTree(unix.AT_FDCWD, b.path, unix.OPEN_TREE_CLONE|unix.O_CLOEXEC|unix.AT_RECURSIVE)
+		if err != nil {
+			return fmt.Errorf("bind %s: %w", b.path, err)
+		}
+		trees[i] = fd
+
+		attr := &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY | unix.MOUNT_ATTR_NOSUID}
+		if b.writable {
+			// only clear read-only, other flags may be locked by the parent user namespace
+			attr = &unix.MountAttr{Attr_clr: unix.MOUNT_ATTR_RDONLY}
+		}
+		if err := unix.MountSetattr(fd, "", unix.AT_EMPTY_PATH|unix.AT_RECURSIVE, attr); err != nil {
+			return fmt.Errorf("set attributes of %s: %w", b.path, err)
+		}
+	}
+
+	root := "/tmp"
+	if err := unix.Mount("tmpfs", root, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=0755"); err != nil {
+		return fmt.Errorf("mount root: %w", err)
+	}
+
+	for _, path := range []string{"/tmp", "/dev/shm"} {
+		if err := os.MkdirAll(root+path, 0o755); err != nil {
+			return err
+		}
+		if err := unix.Mount("tmpfs", root+path, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+			return fmt.Errorf("mount tmpfs on %s: %w", path, err)
+		}
+	}
+
+	if err := os.MkdirAll(root+"/proc", 0o555); err != nil {
+		return err
+	}
+	if err := unix.Mount("proc", root+"/proc", "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("mount proc: %w", err)
+	}
+	for name, target := range map[string]string{
+		"fd":     "/proc/self/fd",
+		"stdin":  "/proc/self/fd/0",
+		"stdout": "/proc/self/fd/1",
+		"stderr": "/proc/self/fd/2",
+	} {
+		if err := os.Symlink(target, root+"/dev/"+name); err != nil {
+			return err
+		}
+	}
+
+	for i, b := range binds {
+		if target, ok := symlinks[b.path]; ok {
+			if err := os.Symlink(target, root+b.path); err != nil {
+				return err
+			}
+			continue
+		}
+		if trees[i] <= 0 {
+			continue
+		}
+		if err := createMountPoint(root+b.path, b.dir); err != nil {
+			return fmt.Errorf("create mount point of %s: %w", b.path, err)
+		}
+		if err := unix.MoveMount(trees[i], "", unix.AT_FDCWD, root+b.path, unix.MOVE_MOUNT_F_EMPTY_PATH); err != nil {
+			return fmt.Errorf("bind %s: %w", b.path, err)
+		}
+	}
+
+	if err := os.Chdir(root); err != nil {
+		return err
+	}
+	if err := unix.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot root: %w", err)
+	}
+	// the old root is stacked below the new one
+	if err := unix.Unmount(".", unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("unmount old root: %w", err)
+	}
+	// nothing but the writable paths and the tmpfs mounts can be written
+	if err := unix.MountSetattr(unix.AT_FDCWD, "/", 0, &unix.MountAttr{
+		Attr_set: unix.MOUNT_ATTR_RDONLY,
+	}); err != nil {
+		return fmt.Errorf("remount root read-only: %w", err)
+	}
+
+	if profile.WorkingPath == "" {
+		return os.Chdir("/")
+	}
+	return os.Chdir(profile.WorkingPath)
+}
+
+// createMountPoint creates target in the new root, files are bound on files
+func createMountPoint(target string, dir bool) error {
+	if dir {
+		return os.MkdirAll(target, 0o755)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_RDONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// dropCapabilities empties the bounding, ambient, inheritable, permitted and effective sets of the
+// calling thread, the plugin starts without capabilities even as root and execve can't grant any
+func dropCapabilities() error {
+	for capability := 0; ; capability++ {
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(capability), 0, 0, 0); err != nil {
+			// past the last capability the kernel knows
+			if errors.Is(err, unix.EINVAL) && capability > 0 {
+				break
+			}
+			return fmt.Errorf("drop capability %d from the bounding set: %w", capability, err)
+		}
+	}
+
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil {
+		return fmt.Errorf("clear ambient capabilities: %w", err)
+	}
+
+	header := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	data := [2]unix.CapUserData{}
+	return unix.Capset(&header, &data[0])
+}
+
+// runInit starts the plugin and stays pid 1 of the namespace, an init without handlers would ignore
+// SIGTERM of the daemon, it forwards signals, reaps orphans and exits with the status of the plugin,
+// which kills the processes left in the namespace
+func runInit(args []string, env []string, files []*os.File) error {
+	signals := make(chan os.Signal, 16)
+	// subscribe before the start, the plugin may exit right away
+	signal.Notify(signals, append([]os.Signal{unix.SIGCHLD}, forwardedSignals...)...)
+
+	process, err := os.StartProcess(args[0], args[1:], &os.ProcAttr{Env: env, Files: files})
+	if err != nil {
+		return err
+	}
+
+	for sig := range signals {
+		if sig != unix.SIGCHLD {
+			process.Signal(sig)
+			continue
+		}
+
+		for {
+			var status unix.WaitStatus
+			pid, err := unix.Wait4(-1, &status, unix.WNOHANG, nil)
+			if err != nil || pid <= 0 {
+				break
+			}
+			if pid != process.Pid {
+				continue
+			}
+			if status.Signaled() {
+				os.Exit(128 + int(status.Signal()))
+			}
+			os.Exit(status.ExitStatus())
+		}
+	}
+
+	return nil
+}
+
+// changeAppArmorProfileOnExec switches to the profile at the next execve like aa-exec does,
+// the attribute belongs to the calling thread
+func changeAppArmorProfileOnExec(profile string) error {
+	command := []byte("exec " + profile)
+	err := os.WriteFile("/proc/thread-self/attr/apparmor/exec", command, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		// kernels before 5.8 only have the shared lsm interface
+		err = os.WriteFile("/proc/thread-self/attr/exec", command, 0)
+	}
+	return err
+}
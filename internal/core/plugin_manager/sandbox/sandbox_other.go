@@ -0,0 +1,17 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+func Wrap(cmd *exec.Cmd, profile Profile) error {
+	return fmt.Errorf("plugin sandbox is not supported on %s", runtime.GOOS)
+}
+
+func Exec(args []string) error {
+	return fmt.Errorf("plugin sandbox is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,22 @@
+package sandbox
+
+import (
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewProfile(t *testing.T) {
+	profile := NewProfile("/app/cwd/openai", nil, []string{"/app/.tiktoken"}, []string{"", "/var/cache/plugins", "/var/cache/plugins"}, "")
+	assert.Equal(t, "/app/cwd/openai", profile.WorkingPath)
+	assert.Equal(t, []string{"/app/.tiktoken"}, profile.ReadOnlyPaths)
+	assert.Equal(t, []string{"/var/cache/plugins"}, profile.WritablePaths)
+	assert.False(t, profile.AllowListen)
+
+	profile = NewProfile("/app/cwd/openai", &plugin_entities.PluginPermissionRequirement{
+		Endpoint: &plugin_entities.PluginPermissionEndpointRequirement{Enabled: true},
+	}, nil, nil, "dify-plugin")
+	assert.True(t, profile.AllowListen)
+	assert.Equal(t, "dify-plugin", profile.AppArmorProfile)
+}
@@ -0,0 +1,171 @@
+//go:build linux && (amd64 || arm64)
+
+package sandbox
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// offsets in struct seccomp_data
+	SECCOMP_DATA_NR_OFFSET   = 0
+	SECCOMP_DATA_ARCH_OFFSET = 4
+	// low 32 bits of the first argument on little endian architectures
+	SECCOMP_DATA_ARG0_OFFSET = 16
+
+	// namespaces plugins are not allowed to create through clone
+	CLONE_NAMESPACE_FLAGS = unix.CLONE_NEWNS | unix.CLONE_NEWUSER | unix.CLONE_NEWPID |
+		unix.CLONE_NEWNET | unix.CLONE_NEWIPC | unix.CLONE_NEWUTS | unix.CLONE_NEWCGROUP
+)
+
+// deniedSyscalls are those a plugin has no business with, most of them help escaping the sandbox
+// or affect the whole host
+var deniedSyscalls = []uint32{
+	unix.SYS_MOUNT,
+	unix.SYS_UMOUNT2,
+	unix.SYS_PIVOT_ROOT,
+	unix.SYS_CHROOT,
+	unix.SYS_MOUNT_SETATTR,
+	unix.SYS_MOVE_MOUNT,
+	unix.SYS_OPEN_TREE,
+	unix.SYS_FSOPEN,
+	unix.SYS_FSMOUNT,
+	unix.SYS_FSPICK,
+	unix.SYS_UNSHARE,
+	unix.SYS_SETNS,
+	unix.SYS_PTRACE,
+	unix.SYS_PROCESS_VM_READV,
+	unix.SYS_PROCESS_VM_WRITEV,
+	unix.SYS_KEXEC_LOAD,
+	unix.SYS_KEXEC_FILE_LOAD,
+	unix.SYS_INIT_MODULE,
+	unix.SYS_FINIT_MODULE,
+	unix.SYS_DELETE_MODULE,
+	unix.SYS_REBOOT,
+	unix.SYS_SWAPON,
+	unix.SYS_SWAPOFF,
+	unix.SYS_BPF,
+	unix.SYS_PERF_EVENT_OPEN,
+	unix.SYS_USERFAULTFD,
+	unix.SYS_KEYCTL,
+	unix.SYS_ADD_KEY,
+	unix.SYS_REQUEST_KEY,
+	unix.SYS_OPEN_BY_HANDLE_AT,
+	unix.SYS_NAME_TO_HANDLE_AT,
+	unix.SYS_ACCT,
+	unix.SYS_QUOTACTL,
+	unix.SYS_LOOKUP_DCOOKIE,
+	unix.SYS_NFSSERVCTL,
+	unix.SYS_SYSLOG,
+	unix.SYS_SETTIMEOFDAY,
+	unix.SYS_CLOCK_SETTIME,
+	unix.SYS_CLOCK_ADJTIME,
+	unix.SYS_ADJTIMEX,
+	unix.SYS_SETHOSTNAME,
+	unix.SYS_SETDOMAINNAME,
+	unix.SYS_VHANGUP,
+}
+
+// listenSyscalls are denied unless the profile allows listening
+var listenSyscalls = []uint32{
+	unix.SYS_LISTEN,
+	unix.SYS_ACCEPT,
+	unix.SYS_ACCEPT4,
+}
+
+// buildSeccompFilter returns a filter failing denied syscalls with EPERM and allowing the rest,
+// processes of another architecture are killed as syscall numbers would not match
+func buildSeccompFilter(profile Profile) ([]bpf.Instruction, error) {
+	denied := append([]uint32{}, deniedSyscalls...)
+	denied = append(denied, archDeniedSyscalls...)
+	if !profile.AllowListen {
+		denied = append(denied, listenSyscalls...)
+	}
+
+	errno := func(errno unix.Errno) bpf.Instruction {
+		return bpf.RetConstant{Val: unix.SECCOMP_RET_ERRNO | uint32(errno)}
+	}
+
+	program := []bpf.Instruction{
+		bpf.LoadAbsolute{Off: SECCOMP_DATA_ARCH_OFFSET, Size: 4},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: AUDIT_ARCH, SkipTrue: 1},
+		bpf.RetConstant{Val: unix.SECCOMP_RET_KILL_PROCESS},
+		bpf.LoadAbsolute{Off: SECCOMP_DATA_NR_OFFSET, Size: 4},
+	}
+	program = append(program, archPrologue...)
+
+	for _, nr := range denied {
+		program = append(program,
+			bpf.JumpIf{Cond: bpf.JumpEqual, Val: nr, SkipFalse: 1},
+			errno(unix.EPERM),
+		)
+	}
+
+	program = append(program,
+		// threads and processes are fine, new namespaces are not
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: unix.SYS_CLONE, SkipFalse: 4},
+		bpf.LoadAbsolute{Off: SECCOMP_DATA_ARG0_OFFSET, Size: 4},
+		bpf.JumpIf{Cond: bpf.JumpBitsSet, Val: CLONE_NAMESPACE_FLAGS, SkipFalse: 1},
+		errno(unix.EPERM),
+		bpf.RetConstant{Val: unix.SECCOMP_RET_ALLOW},
+		// flags of clone3 are behind a pointer, ENOSYS makes libc fall back to clone
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: unix.SYS_CLONE3, SkipFalse: 1},
+		errno(unix.ENOSYS),
+		bpf.RetConstant{Val: unix.SECCOMP_RET_ALLOW},
+	)
+
+	if len(program) > unix.BPF_MAXINSNS {
+		return nil, fmt.Errorf("seccomp filter too long: %d instructions", len(program))
+	}
+
+	return program, nil
+}
+
+// installSeccomp sets no_new_privs and loads the filter, it's inherited through execve
+func installSeccomp(profile Profile) error {
+	program, err := buildSeccompFilter(profile)
+	if err != nil {
+		return err
+	}
+
+	raw, err := bpf.Assemble(program)
+	if err != nil {
+		return err
+	}
+
+	filter := make([]unix.SockFilter, len(raw))
+	for i, instruction := range raw {
+		filter[i] = unix.SockFilter{
+			Code: instruction.Op,
+			Jt:   instruction.Jt,
+			Jf:   instruction.Jf,
+			K:    instruction.K,
+		}
+	}
+	prog := unix.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	}
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("set no_new_privs: %w", err)
+	}
+
+	_, _, errno := unix.Syscall(
+		unix.SYS_SECCOMP,
+		unix.SECCOMP_SET_MODE_FILTER,
+		unix.SECCOMP_FILTER_FLAG_TSYNC,
+		uintptr(unsafe.Pointer(&prog)),
+	)
+	runtime.KeepAlive(filter)
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
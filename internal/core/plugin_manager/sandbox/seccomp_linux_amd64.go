@@ -0,0 +1,23 @@
+package sandbox
+
+import (
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	AUDIT_ARCH = unix.AUDIT_ARCH_X86_64
+	// syscalls of the x32 abi have this bit set and bypass the numbers checked
+	X32_SYSCALL_BIT = 0x40000000
+)
+
+var archDeniedSyscalls = []uint32{
+	unix.SYS_IOPL,
+	unix.SYS_IOPERM,
+	unix.SYS_CREATE_MODULE,
+}
+
+var archPrologue = []bpf.Instruction{
+	bpf.JumpIf{Cond: bpf.JumpGreaterOrEqual, Val: X32_SYSCALL_BIT, SkipFalse: 1},
+	bpf.RetConstant{Val: unix.SECCOMP_RET_ERRNO | uint32(unix.ENOSYS)},
+}
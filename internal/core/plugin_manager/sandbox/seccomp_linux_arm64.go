@@ -0,0 +1,12 @@
+package sandbox
+
+import (
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+const AUDIT_ARCH = unix.AUDIT_ARCH_AARCH64
+
+var archDeniedSyscalls = []uint32{}
+
+var archPrologue = []bpf.Instruction{}
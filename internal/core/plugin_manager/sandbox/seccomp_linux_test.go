@@ -0,0 +1,151 @@
+//go:build linux && (amd64 || arm64)
+
+package sandbox
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+func TestMain(m *testing.M) {
+	// the test binary stands in for the daemon when a test wraps a command
+	if len(os.Args) > 1 && os.Args[1] == EXEC_COMMAND {
+		if err := Exec(os.Args[2:]); err != nil {
+			os.Stderr.WriteString(err.Error() + "\n")
+			os.Exit(1)
+		}
+	}
+	os.Exit(m.Run())
+}
+
+func TestSeccompFilter(t *testing.T) {
+	run := func(profile Profile, arch uint32, nr uint32, arg0 uint32) uint32 {
+		program, err := buildSeccompFilter(profile)
+		assert.NoError(t, err)
+		vm, err := bpf.NewVM(program)
+		assert.NoError(t, err)
+
+		// the vm loads words in network byte order unlike seccomp, encode accordingly
+		data := make([]byte, 64)
+		binary.BigEndian.PutUint32(data[SECCOMP_DATA_NR_OFFSET:], nr)
+		binary.BigEndian.PutUint32(data[SECCOMP_DATA_ARCH_OFFSET:], arch)
+		binary.BigEndian.PutUint32(data[SECCOMP_DATA_ARG0_OFFSET:], arg0)
+		ret, err := vm.Run(data)
+		assert.NoError(t, err)
+		return uint32(ret)
+	}
+
+	eperm := unix.SECCOMP_RET_ERRNO | uint32(unix.EPERM)
+
+	assert.Equal(t, uint32(unix.SECCOMP_RET_ALLOW), run(Profile{}, AUDIT_ARCH, unix.SYS_READ, 0))
+	assert.Equal(t, eperm, run(Profile{}, AUDIT_ARCH, unix.SYS_MOUNT, 0))
+	assert.Equal(t, eperm, run(Profile{}, AUDIT_ARCH, unix.SYS_PTRACE, 0))
+	assert.Equal(t, uint32(unix.SECCOMP_RET_KILL_PROCESS), run(Profile{}, 0, unix.SYS_READ, 0))
+
+	assert.Equal(t, eperm, run(Profile{}, AUDIT_ARCH, unix.SYS_LISTEN, 0))
+	assert.Equal(t, uint32(unix.SECCOMP_RET_ALLOW), run(Profile{AllowListen: true}, AUDIT_ARCH, unix.SYS_LISTEN, 0))
+
+	assert.Equal(t, uint32(unix.SECCOMP_RET_ALLOW), run(Profile{}, AUDIT_ARCH, unix.SYS_CLONE, unix.CLONE_VM|unix.CLONE_THREAD))
+	assert.Equal(t, eperm, run(Profile{}, AUDIT_ARCH, unix.SYS_CLONE, unix.CLONE_NEWUSER))
+	assert.Equal(t, unix.SECCOMP_RET_ERRNO|uint32(unix.ENOSYS), run(Profile{}, AUDIT_ARCH, unix.SYS_CLONE3, 0))
+}
+
+// runSandboxed runs cmd under profile and returns its output, the test is skipped where the sandbox
+// can not be set up
+func runSandboxed(t *testing.T, cmd *exec.Cmd, profile Profile) {
+	assert.NoError(t, Wrap(cmd, profile))
+
+	output, err := cmd.CombinedOutput()
+	if err != nil && (strings.Contains(string(output), "operation not permitted") || errors.Is(err, unix.EPERM)) {
+		// containers without the privileges to create mount namespaces can not run the sandbox
+		t.Skipf("sandbox unavailable: %s", output)
+	}
+	assert.NoError(t, err, string(output))
+}
+
+func TestSandboxExec(t *testing.T) {
+	working := t.TempDir()
+	writable := t.TempDir()
+	hidden := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(working, "main.py"), []byte("print()"), 0o644))
+
+	reader, writer, err := os.Pipe()
+	assert.NoError(t, err)
+	defer reader.Close()
+
+	cmd := exec.Command("/bin/sh", "-c", strings.Join([]string{
+		"touch " + filepath.Join(writable, "ok"),
+		"test -f main.py",
+		"! touch " + filepath.Join(working, "denied"),
+		"! ls " + hidden,
+		"touch /tmp/private",
+		// 2 is the filter mode
+		"grep -q 'Seccomp:.*2' /proc/self/status",
+		"echo inherited >&3",
+	}, " && "))
+	cmd.Dir = working
+	cmd.ExtraFiles = []*os.File{writer}
+	runSandboxed(t, cmd, NewProfile(working, nil, nil, []string{writable}, ""))
+	writer.Close()
+
+	assert.FileExists(t, filepath.Join(writable, "ok"))
+	assert.NoFileExists(t, filepath.Join(working, "denied"))
+	assert.NoFileExists(t, "/tmp/private")
+	inherited, _ := io.ReadAll(reader)
+	assert.Equal(t, "inherited\n", string(inherited))
+}
+
+func TestSandboxHidesDaemon(t *testing.T) {
+	// stands in for the daemon, its environment holds the secrets
+	daemon := exec.Command("/bin/sleep", "30")
+	daemon.Env = []string{"DIFY_SANDBOX_TEST_SECRET=leaked"}
+	assert.NoError(t, daemon.Start())
+	defer daemon.Wait()
+	defer daemon.Process.Kill()
+
+	environ, err := os.ReadFile(fmt.Sprintf("/proc/%d/environ", daemon.Process.Pid))
+	assert.NoError(t, err)
+	assert.Contains(t, string(environ), "DIFY_SANDBOX_TEST_SECRET")
+
+	cmd := exec.Command("/bin/sh", "-c", strings.Join([]string{
+		// environ of processes in the sandbox is readable, the one of the daemon is not there
+		"grep -q PATH= /proc/1/environ",
+		"! grep -qs DIFY_SANDBOX_TEST_SECRET /proc/*/environ",
+		"grep -q 'CapEff:[[:space:]]*0*$' /proc/self/status",
+		"grep -q 'CapBnd:[[:space:]]*0*$' /proc/self/status",
+		"grep -q 'NoNewPrivs:[[:space:]]*1' /proc/self/status",
+	}, " && "))
+	cmd.Dir = t.TempDir()
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH")}
+	runSandboxed(t, cmd, NewProfile(cmd.Dir, nil, nil, nil, ""))
+}
+
+func TestSandboxForwardsSignals(t *testing.T) {
+	cmd := exec.Command("/bin/sh", "-c", "trap 'exit 7' TERM; while true; do sleep 0.1; done")
+	cmd.Dir = t.TempDir()
+	assert.NoError(t, Wrap(cmd, NewProfile(cmd.Dir, nil, nil, nil, "")))
+	assert.NoError(t, cmd.Start())
+
+	time.Sleep(500 * time.Millisecond)
+	assert.NoError(t, cmd.Process.Signal(unix.SIGTERM))
+	err := cmd.Wait()
+
+	var exitErr *exec.ExitError
+	// failures to set up the sandbox exit with 1
+	if assert.ErrorAs(t, err, &exitErr) && exitErr.ExitCode() == 1 {
+		t.Skip("sandbox unavailable")
+	}
+	assert.Equal(t, 7, exitErr.ExitCode())
+}
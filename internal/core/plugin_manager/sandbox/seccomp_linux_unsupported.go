@@ -0,0 +1,12 @@
+//go:build linux && !amd64 && !arm64
+
+package sandbox
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func installSeccomp(profile Profile) error {
+	return fmt.Errorf("seccomp filter is not supported on %s", runtime.GOARCH)
+}
@@ -2,9 +2,13 @@ package plugin_manager
 
 import (
 	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/basic_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector/azure_functions"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector/cloud_run"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector/self_hosted"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_runtime"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
@@ -55,6 +59,21 @@ func (p *PluginManager) getServerlessPluginRuntime(
 		PluginMaxExecutionTimeout: p.config.PluginMaxExecutionTimeout,
 	}
 
+	switch model.Type {
+	case models.SERVERLESS_RUNTIME_TYPE_CLOUD_RUN:
+		pluginRuntime.AuthorizeTransport = func(base http.RoundTripper) (http.RoundTripper, error) {
+			return cloud_run.Transport(model.FunctionURL, base)
+		}
+	case models.SERVERLESS_RUNTIME_TYPE_AZURE_FUNCTIONS:
+		pluginRuntime.AuthorizeTransport = func(base http.RoundTripper) (http.RoundTripper, error) {
+			return azure_functions.Transport(model.FunctionName, base)
+		}
+	case models.SERVERLESS_RUNTIME_TYPE_KNATIVE, models.SERVERLESS_RUNTIME_TYPE_OPENFAAS:
+		pluginRuntime.AuthorizeTransport = func(base http.RoundTripper) (http.RoundTripper, error) {
+			return self_hosted.Transport(model.FunctionURL, base)
+		}
+	}
+
 	if err := pluginRuntime.InitEnvironment(); err != nil {
 		return nil, err
 	}
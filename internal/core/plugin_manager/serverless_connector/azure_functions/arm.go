@@ -0,0 +1,202 @@
+package azure_functions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+const (
+	ARM_ENDPOINT = "https://management.azure.com"
+	ARM_SCOPE    = "https://management.azure.com/.default"
+)
+
+// armClient calls the azure resource manager rest api, long running operations are polled until done
+type armClient struct {
+	endpoint   string
+	credential azcore.TokenCredential
+	client     *http.Client
+}
+
+type armError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type armAsyncOperation struct {
+	Status string `json:"status"`
+	Error  struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (c *armClient) request(ctx context.Context, method string, url string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.credential != nil {
+		token, err := c.credential.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{ARM_SCOPE}})
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token.Token)
+	}
+
+	return c.client.Do(req)
+}
+
+// do calls the resource at path, waits for the operation if it's long running and decodes the final result into out
+func (c *armClient) do(ctx context.Context, method string, path string, apiVersion string, body any, out any) error {
+	url := c.endpoint + path
+	if apiVersion != "" {
+		url += "?api-version=" + apiVersion
+	}
+
+	resp, err := c.request(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp); err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+
+	if asyncOperation := resp.Header.Get("Azure-AsyncOperation"); asyncOperation != "" {
+		if err := c.waitAsyncOperation(ctx, asyncOperation, resp); err != nil {
+			return fmt.Errorf("%s %s: %w", method, path, err)
+		}
+		if out == nil {
+			return nil
+		}
+		// the result of an async operation is the resource itself
+		return c.do(ctx, http.MethodGet, path, apiVersion, nil, out)
+	}
+
+	if resp.StatusCode == http.StatusAccepted && resp.Header.Get("Location") != "" {
+		return c.waitLocation(ctx, resp.Header.Get("Location"), resp, out)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	armErr := armError{}
+	if err := json.Unmarshal(data, &armErr); err == nil && armErr.Error.Message != "" {
+		return fmt.Errorf("status code %d, %s: %s", resp.StatusCode, armErr.Error.Code, armErr.Error.Message)
+	}
+	return fmt.Errorf("status code %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+}
+
+// retryAfter honours the Retry-After header of polling responses
+func retryAfter(resp *http.Response) time.Duration {
+	var seconds int
+	if _, err := fmt.Sscanf(resp.Header.Get("Retry-After"), "%d", &seconds); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return pollInterval
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (c *armClient) waitAsyncOperation(ctx context.Context, url string, resp *http.Response) error {
+	for {
+		if err := sleep(ctx, retryAfter(resp)); err != nil {
+			return err
+		}
+
+		var err error
+		resp, err = c.request(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		if err := checkResponse(resp); err != nil {
+			resp.Body.Close()
+			return err
+		}
+
+		operation := armAsyncOperation{}
+		err = json.NewDecoder(resp.Body).Decode(&operation)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		switch operation.Status {
+		case "Succeeded":
+			return nil
+		case "Failed", "Canceled":
+			return fmt.Errorf("operation %s, %s: %s", strings.ToLower(operation.Status), operation.Error.Code, operation.Error.Message)
+		}
+	}
+}
+
+func (c *armClient) waitLocation(ctx context.Context, url string, resp *http.Response, out any) error {
+	for {
+		if err := sleep(ctx, retryAfter(resp)); err != nil {
+			return err
+		}
+
+		var err error
+		resp, err = c.request(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		if err := checkResponse(resp); err != nil {
+			resp.Body.Close()
+			return err
+		}
+		if resp.StatusCode == http.StatusAccepted {
+			resp.Body.Close()
+			continue
+		}
+
+		defer resp.Body.Close()
+		if out == nil {
+			return nil
+		}
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+}
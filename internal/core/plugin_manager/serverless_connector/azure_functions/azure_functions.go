@@ -0,0 +1,158 @@
+// Package azure_functions deploys serverless plugins to azure functions, the plugin is packaged with
+// the functions python worker into an image built by the container registry and deployed to a
+// function app with an arm template, invocations are authorized with the function key of the app
+package azure_functions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	serverless "github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/schedule"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+const (
+	AZURE_FUNCTIONS_LAUNCH_LOCK_PREFIX = "azure_functions_launch_lock_"
+	AZURE_FUNCTIONS_POLL_INTERVAL      = 5 * time.Second
+	AZURE_FUNCTIONS_SITE_PREFIX        = "dify-"
+)
+
+var (
+	config         *app.Config
+	arm            *armClient
+	pluginPatterns []string
+
+	pollInterval = AZURE_FUNCTIONS_POLL_INTERVAL
+)
+
+// Init creates the arm client and starts the health monitor, it's a no-op if no plugin is
+// selected by AZURE_FUNCTIONS_PLUGINS
+func Init(configuration *app.Config) {
+	pluginPatterns = configuration.AzureFunctionsPlugins
+	if len(pluginPatterns) == 0 {
+		return
+	}
+
+	config = configuration
+	credential, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		log.Panic("failed to init azure credential: %s", err.Error())
+	}
+	arm = &armClient{
+		endpoint:   ARM_ENDPOINT,
+		credential: credential,
+		client:     &http.Client{Timeout: 60 * time.Second},
+	}
+
+	schedule.Run(
+		"azure_functions_health",
+		schedule.Every(time.Duration(config.AzureFunctionsHealthCheckInterval)*time.Second),
+		monitorHealth,
+	)
+
+	log.Info(
+		"azure functions connector initialized, subscription: %s, resource group: %s",
+		config.AzureFunctionsSubscriptionID, config.AzureFunctionsResourceGroup,
+	)
+}
+
+// Selected reports whether the plugin is deployed to azure functions according to AZURE_FUNCTIONS_PLUGINS
+func Selected(pluginID string) bool {
+	return serverless.MatchPlugin(pluginPatterns, pluginID)
+}
+
+func resourceGroupPath() string {
+	return fmt.Sprintf("/subscriptions/%s/resourceGroups/%s", config.AzureFunctionsSubscriptionID, config.AzureFunctionsResourceGroup)
+}
+
+// siteName derives the name of the function app from the checksum, names are global across azure
+// so the subscription and resource group are part of the hash
+func siteName(checksum string) string {
+	sum := sha256.Sum256([]byte(config.AzureFunctionsSubscriptionID + "/" + config.AzureFunctionsResourceGroup + "/" + checksum))
+	return AZURE_FUNCTIONS_SITE_PREFIX + hex.EncodeToString(sum[:16])
+}
+
+// LaunchPlugin builds and deploys the plugin to azure functions, events are the same as the serverless
+// connector, the function is the resource id of the app and the function url is its api base url
+func LaunchPlugin(
+	originPackage []byte,
+	decoder decoder.PluginDecoder,
+	timeout int, // in seconds
+	ignoreIdempotent bool, // if true, always build and deploy again
+) (*stream.Stream[serverless.LaunchFunctionResponse], error) {
+	if arm == nil {
+		return nil, fmt.Errorf("azure functions connector is not initialized")
+	}
+
+	checksum, err := decoder.Checksum()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := decoder.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	response := stream.NewStream[serverless.LaunchFunctionResponse](10)
+	routine.Submit(map[string]string{
+		"module":   "azure_functions",
+		"function": "LaunchPlugin",
+		"checksum": checksum,
+	}, func() {
+		defer response.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+		defer cancel()
+
+		// the same package is deployed by one node at a time
+		if err := cache.Lock(AZURE_FUNCTIONS_LAUNCH_LOCK_PREFIX+checksum, time.Duration(timeout)*time.Second, time.Duration(timeout)*time.Second); err != nil {
+			response.Write(serverless.LaunchFunctionResponse{Event: serverless.Error, Message: err.Error()})
+			return
+		}
+		defer cache.Unlock(AZURE_FUNCTIONS_LAUNCH_LOCK_PREFIX + checksum)
+
+		name := siteName(checksum)
+		if !ignoreIdempotent {
+			if site, err := fetchSite(ctx, name); err == nil && site.running() {
+				writeLaunched(response, site.ID, site.Properties.DefaultHostName)
+				return
+			}
+		}
+
+		response.Write(serverless.LaunchFunctionResponse{Event: serverless.Info, Message: "Building plugin..."})
+		image, err := buildImage(ctx, &manifest, decoder, name, checksum)
+		if err != nil {
+			response.Write(serverless.LaunchFunctionResponse{Event: serverless.Error, Message: err.Error()})
+			return
+		}
+
+		response.Write(serverless.LaunchFunctionResponse{Event: serverless.Info, Message: "Launching plugin..."})
+		siteID, hostName, err := deploySite(ctx, name, image, manifest.Identity())
+		if err != nil {
+			response.Write(serverless.LaunchFunctionResponse{Event: serverless.Error, Message: err.Error()})
+			return
+		}
+
+		writeLaunched(response, siteID, hostName)
+	})
+
+	return response, nil
+}
+
+func writeLaunched(response *stream.Stream[serverless.LaunchFunctionResponse], siteID string, hostName string) {
+	response.Write(serverless.LaunchFunctionResponse{Event: serverless.Function, Message: siteID})
+	response.Write(serverless.LaunchFunctionResponse{Event: serverless.FunctionUrl, Message: "https://" + hostName + "/api"})
+	response.Write(serverless.LaunchFunctionResponse{Event: serverless.Done, Message: "Plugin launched"})
+}
@@ -0,0 +1,242 @@
+package azure_functions
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+func setup(t *testing.T, endpoint string) {
+	config = &app.Config{
+		AzureFunctionsSubscriptionID:      "sub",
+		AzureFunctionsResourceGroup:       "rg",
+		AzureFunctionsHealthCheckInterval: 60,
+		PluginMaxExecutionTimeout:         600,
+	}
+	arm = &armClient{endpoint: endpoint, client: http.DefaultClient}
+	pollInterval = 10 * time.Millisecond
+	t.Cleanup(func() {
+		config = nil
+		arm = nil
+		pollInterval = AZURE_FUNCTIONS_POLL_INTERVAL
+	})
+}
+
+func TestSiteName(t *testing.T) {
+	setup(t, "")
+
+	name := siteName("checksum")
+	if name != siteName("checksum") || len(name) > 60 || !strings.HasPrefix(name, AZURE_FUNCTIONS_SITE_PREFIX) {
+		t.Fatalf("invalid function app name %s", name)
+	}
+
+	config.AzureFunctionsResourceGroup = "another"
+	if siteName("checksum") == name {
+		t.Fatal("expected names to differ across resource groups")
+	}
+}
+
+func TestBuildContext(t *testing.T) {
+	setup(t, "")
+
+	pkg, err := os.ReadFile("../../testdata/openai.difypkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pluginDecoder, err := decoder.NewZipPluginDecoder(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	declaration, err := pluginDecoder.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := buildContext(&declaration, pluginDecoder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(tarReader)
+		files[header.Name] = string(content)
+	}
+
+	if !strings.Contains(files["Dockerfile"], "python:4-python"+declaration.Meta.Runner.Version) {
+		t.Fatalf("expected the functions python worker image, got %q", files["Dockerfile"])
+	}
+	if !strings.Contains(files["Dockerfile"], "DIFY_PLUGIN_UPSTREAM_TIMEOUT=600") {
+		t.Fatalf("expected the upstream timeout of the bridge, got %q", files["Dockerfile"])
+	}
+	if !strings.Contains(files["host.json"], `"functionTimeout": "00:10:00"`) {
+		t.Fatalf("expected the function timeout in host.json, got %q", files["host.json"])
+	}
+	if _, ok := files["function_app.py"]; !ok {
+		t.Fatal("expected the bridge in the context")
+	}
+	if _, ok := files["plugin/manifest.yaml"]; !ok {
+		t.Fatal("expected the files of the plugin under plugin/")
+	}
+
+	declaration.Meta.Runner.Version = "3.8"
+	if _, err := buildContext(&declaration, pluginDecoder); err == nil {
+		t.Fatal("expected python versions without a functions worker to be refused")
+	}
+}
+
+func TestARMAsyncOperation(t *testing.T) {
+	var polls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/resource":
+			w.Header().Set("Azure-AsyncOperation", "http://"+r.Host+"/operation")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{}`))
+		case r.URL.Path == "/operation":
+			if polls.Add(1) < 3 {
+				w.Write([]byte(`{"status": "Running"}`))
+				return
+			}
+			w.Write([]byte(`{"status": "Succeeded"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/resource":
+			w.Write([]byte(`{"id": "/resource", "properties": {"state": "Running", "defaultHostName": "app.azurewebsites.net"}}`))
+		case r.URL.Path == "/failed":
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error": {"code": "Conflict", "message": "the app is being deleted"}}`))
+		}
+	}))
+	defer server.Close()
+	setup(t, server.URL)
+
+	s := site{}
+	if err := arm.do(context.Background(), http.MethodPut, "/resource", WEB_API_VERSION, map[string]any{}, &s); err != nil {
+		t.Fatal(err)
+	}
+	if polls.Load() != 3 || !s.running() {
+		t.Fatalf("expected the resource after 3 polls, got %d polls and %+v", polls.Load(), s)
+	}
+
+	err := arm.do(context.Background(), http.MethodPut, "/failed", WEB_API_VERSION, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "the app is being deleted") {
+		t.Fatalf("expected the arm error, got %v", err)
+	}
+}
+
+func TestFunctionKeyTransport(t *testing.T) {
+	var listed atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sites/app/host/default/listkeys":
+			listed.Add(1)
+			w.Write([]byte(`{"functionKeys": {"default": "secret"}}`))
+		case "/api/invoke":
+			if r.Header.Get("x-functions-key") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+	setup(t, server.URL)
+	t.Cleanup(func() { functionKeys.Delete("/sites/app") })
+
+	transport, err := Transport("/sites/app", http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: transport}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Post(server.URL+"/api/invoke", "application/json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected the request to be authorized, got %d", resp.StatusCode)
+		}
+	}
+	if listed.Load() != 1 {
+		t.Fatalf("expected the key to be fetched once, fetched %d times", listed.Load())
+	}
+}
+
+func TestMonitorHealthRestartsFailingApps(t *testing.T) {
+	var restarted atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/healthz":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/sites/app/restart":
+			restarted.Add(1)
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+	setup(t, server.URL)
+
+	dbConfig := &app.Config{DBType: "sqlite", DBSqlitePath: filepath.Join(t.TempDir(), "azure.db")}
+	dbConfig.SetDefault()
+	db.Init(dbConfig)
+	t.Cleanup(db.Close)
+	if err := cache.InitMemoryClient(0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cache.Close() })
+
+	if err := db.Create(&models.ServerlessRuntime{
+		PluginUniqueIdentifier: "langgenius/openai:0.0.1@checksum",
+		FunctionURL:            server.URL + "/api",
+		FunctionName:           "/sites/app",
+		Type:                   models.SERVERLESS_RUNTIME_TYPE_AZURE_FUNCTIONS,
+		Checksum:               "checksum",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < HEALTH_CHECK_MAX_FAILURES; i++ {
+		cache.Del(HEALTH_CHECK_LOCK_KEY)
+		monitorHealth()
+		if i < HEALTH_CHECK_MAX_FAILURES-1 && restarted.Load() != 0 {
+			t.Fatalf("expected no restart after %d failures", i+1)
+		}
+	}
+	if restarted.Load() != 1 {
+		t.Fatalf("expected the app to be restarted once, restarted %d times", restarted.Load())
+	}
+
+	// another node holding the lock skips the checks
+	monitorHealth()
+	if restarted.Load() != 1 {
+		t.Fatal("expected checks to be skipped while the lock is held")
+	}
+}
@@ -0,0 +1,112 @@
+# bridges the functions python worker to the plugin, the plugin runs in serverless mode as a child
+# process of the worker and every http request to the app is forwarded to it
+import asyncio
+import os
+import socket
+import subprocess
+import sys
+import threading
+import time
+
+import azure.functions as func
+import httpx
+from azurefunctions.extensions.http.fastapi import PlainTextResponse, Request, StreamingResponse
+
+PLUGIN_ROOT = os.environ.get("DIFY_PLUGIN_ROOT", "/home/site/plugin")
+PLUGIN_ENTRYPOINT = os.environ.get("DIFY_PLUGIN_ENTRYPOINT", "main")
+PLUGIN_PORT = int(os.environ.get("DIFY_PLUGIN_PORT", "8081"))
+PLUGIN_STARTUP_TIMEOUT = 60
+# an invocation is cut off once it runs longer than this, in seconds, an idle read of the plugin as well
+PLUGIN_UPSTREAM_TIMEOUT = float(os.environ.get("DIFY_PLUGIN_UPSTREAM_TIMEOUT", "600"))
+ROUTE_PREFIX = "/api"
+HOP_BY_HOP_HEADERS = {"host", "content-length", "connection", "transfer-encoding", "x-functions-key"}
+
+app = func.FunctionApp(http_auth_level=func.AuthLevel.FUNCTION)
+
+_lock = threading.Lock()
+_plugin = None
+_client = httpx.AsyncClient(timeout=httpx.Timeout(PLUGIN_UPSTREAM_TIMEOUT, connect=5))
+
+
+def _plugin_listening() -> bool:
+    try:
+        with socket.create_connection(("127.0.0.1", PLUGIN_PORT), timeout=1):
+            return True
+    except OSError:
+        return False
+
+
+def _ensure_plugin() -> None:
+    global _plugin
+    with _lock:
+        if _plugin is not None and _plugin.poll() is None:
+            return
+
+        env = dict(
+            os.environ,
+            INSTALL_METHOD="serverless",
+            SERVERLESS_HOST="127.0.0.1",
+            SERVERLESS_PORT=str(PLUGIN_PORT),
+        )
+        _plugin = subprocess.Popen([sys.executable, "-m", PLUGIN_ENTRYPOINT], cwd=PLUGIN_ROOT, env=env)
+
+        deadline = time.time() + PLUGIN_STARTUP_TIMEOUT
+        while time.time() < deadline:
+            if _plugin.poll() is not None:
+                raise RuntimeError(f"plugin exited with code {_plugin.returncode}")
+            if _plugin_listening():
+                return
+            time.sleep(0.2)
+        raise RuntimeError("plugin did not start listening in time")
+
+
+@app.route(route="healthz", methods=["GET"], auth_level=func.AuthLevel.ANONYMOUS)
+async def healthz(req: Request) -> PlainTextResponse:
+    try:
+        await asyncio.to_thread(_ensure_plugin)
+    except RuntimeError as e:
+        return PlainTextResponse(str(e), status_code=503)
+    return PlainTextResponse("ok")
+
+
+@app.route(route="{*path}", methods=["GET", "POST"])
+async def forward(req: Request):
+    try:
+        await asyncio.to_thread(_ensure_plugin)
+    except RuntimeError as e:
+        return PlainTextResponse(str(e), status_code=503)
+
+    path = req.url.path
+    if path.startswith(ROUTE_PREFIX):
+        path = path[len(ROUTE_PREFIX):]
+    url = f"http://127.0.0.1:{PLUGIN_PORT}{path}"
+    if req.url.query:
+        url += "?" + req.url.query
+
+    headers = {k: v for k, v in req.headers.items() if k.lower() not in HOP_BY_HOP_HEADERS}
+    request = _client.build_request(req.method, url, headers=headers, content=await req.body())
+    try:
+        response = await _client.send(request, stream=True)
+    except httpx.TimeoutException:
+        return PlainTextResponse("plugin did not respond in time", status_code=504)
+    except httpx.HTTPError as e:
+        return PlainTextResponse(str(e), status_code=502)
+
+    # chunks are sent as the plugin writes them, the invocation ends once the timeout passed
+    async def body():
+        deadline = time.monotonic() + PLUGIN_UPSTREAM_TIMEOUT
+        try:
+            async for chunk in response.aiter_raw():
+                yield chunk
+                if time.monotonic() > deadline:
+                    break
+        except httpx.HTTPError:
+            pass
+        finally:
+            await response.aclose()
+
+    return StreamingResponse(
+        body(),
+        status_code=response.status_code,
+        media_type=response.headers.get("content-type"),
+    )
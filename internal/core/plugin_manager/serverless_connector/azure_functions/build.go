@@ -0,0 +1,202 @@
+package azure_functions
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/constants"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+const (
+	ACR_API_VERSION = "2019-06-01-preview"
+)
+
+var (
+	//go:embed bridge/Dockerfile
+	bridgeDockerfile string
+	//go:embed bridge/function_app.py
+	bridgeFunctionApp string
+	//go:embed bridge/host.json
+	bridgeHost string
+	//go:embed bridge/requirements.txt
+	bridgeRequirements string
+
+	// python versions of the functions python worker
+	supportedPythonVersions = []string{"3.10", "3.11", "3.12"}
+)
+
+// functionTimeout formats the max execution timeout of plugins as the functionTimeout of host.json
+func functionTimeout(seconds int) string {
+	d := time.Duration(seconds) * time.Second
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
+// buildContext packs the plugin under plugin/ together with the functions python worker bridge
+func buildContext(declaration *plugin_entities.PluginDeclaration, pluginDecoder decoder.PluginDecoder) ([]byte, error) {
+	if declaration.Meta.Runner.Language != constants.Python {
+		return nil, fmt.Errorf("unsupported language of azure functions: %s", declaration.Meta.Runner.Language)
+	}
+	version := declaration.Meta.Runner.Version
+	if !slices.Contains(supportedPythonVersions, version) {
+		return nil, fmt.Errorf("unsupported python version of azure functions: %s", version)
+	}
+
+	buffer := bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	writeFile := func(name string, data []byte) error {
+		if err := tarWriter.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0o644,
+			Size:    int64(len(data)),
+			ModTime: time.Unix(0, 0),
+		}); err != nil {
+			return err
+		}
+		_, err := tarWriter.Write(data)
+		return err
+	}
+
+	if err := pluginDecoder.Walk(func(filename string, dir string) error {
+		if filename == "" {
+			return nil
+		}
+		name := path.Join(dir, filename)
+		data, err := pluginDecoder.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		return writeFile(path.Join("plugin", name), data)
+	}); err != nil {
+		return nil, err
+	}
+
+	dockerfile := strings.NewReplacer(
+		"{{python_version}}", version,
+		"{{entrypoint}}", declaration.Meta.Runner.Entrypoint,
+		"{{upstream_timeout}}", fmt.Sprint(config.PluginMaxExecutionTimeout),
+	).Replace(bridgeDockerfile)
+	host := strings.ReplaceAll(bridgeHost, "{{function_timeout}}", functionTimeout(config.PluginMaxExecutionTimeout))
+
+	for name, content := range map[string]string{
+		"Dockerfile":       dockerfile,
+		"function_app.py":  bridgeFunctionApp,
+		"host.json":        host,
+		"requirements.txt": bridgeRequirements,
+	} {
+		if err := writeFile(name, []byte(content)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+type acrSourceUploadURL struct {
+	UploadURL    string `json:"uploadUrl"`
+	RelativePath string `json:"relativePath"`
+}
+
+type acrRun struct {
+	Properties struct {
+		RunID  string `json:"runId"`
+		Status string `json:"status"`
+	} `json:"properties"`
+}
+
+func registryPath() string {
+	return fmt.Sprintf("%s/providers/Microsoft.ContainerRegistry/registries/%s", resourceGroupPath(), config.AzureFunctionsRegistry)
+}
+
+// uploadBlob puts the build context to the sas url returned by the registry
+func uploadBlob(ctx context.Context, url string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-ms-blob-type", "BlockBlob")
+
+	resp, err := arm.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+// buildImage builds the image with a quick task of the container registry, returns the image
+func buildImage(
+	ctx context.Context,
+	declaration *plugin_entities.PluginDeclaration,
+	pluginDecoder decoder.PluginDecoder,
+	name string,
+	checksum string,
+) (string, error) {
+	archive, err := buildContext(declaration, pluginDecoder)
+	if err != nil {
+		return "", err
+	}
+
+	source := acrSourceUploadURL{}
+	if err := arm.do(ctx, http.MethodPost, registryPath()+"/listBuildSourceUploadUrl", ACR_API_VERSION, nil, &source); err != nil {
+		return "", fmt.Errorf("failed to get build source upload url: %w", err)
+	}
+	if err := uploadBlob(ctx, source.UploadURL, archive); err != nil {
+		return "", fmt.Errorf("failed to upload build context: %w", err)
+	}
+
+	tag := checksum
+	if len(tag) > 32 {
+		tag = tag[:32]
+	}
+	image := fmt.Sprintf("%s.azurecr.io/%s:%s", config.AzureFunctionsRegistry, name, tag)
+
+	run := acrRun{}
+	if err := arm.do(ctx, http.MethodPost, registryPath()+"/scheduleRun", ACR_API_VERSION, map[string]any{
+		"type":           "DockerBuildRequest",
+		"sourceLocation": source.RelativePath,
+		"dockerFilePath": "Dockerfile",
+		"imageNames":     []string{image},
+		"isPushEnabled":  true,
+		"platform": map[string]string{
+			"os":           "Linux",
+			"architecture": "amd64",
+		},
+	}, &run); err != nil {
+		return "", fmt.Errorf("failed to schedule build: %w", err)
+	}
+
+	for {
+		switch run.Properties.Status {
+		case "Succeeded":
+			return image, nil
+		case "Failed", "Canceled", "Error", "Timeout":
+			return "", fmt.Errorf("build %s of registry %s is %s", run.Properties.RunID, config.AzureFunctionsRegistry, strings.ToLower(run.Properties.Status))
+		}
+
+		if err := sleep(ctx, pollInterval); err != nil {
+			return "", fmt.Errorf("build %s timed out: %w", run.Properties.RunID, err)
+		}
+		if err := arm.do(ctx, http.MethodGet, registryPath()+"/runs/"+run.Properties.RunID, ACR_API_VERSION, nil, &run); err != nil {
+			return "", fmt.Errorf("failed to fetch build %s: %w", run.Properties.RunID, err)
+		}
+	}
+}
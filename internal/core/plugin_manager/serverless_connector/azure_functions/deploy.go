@@ -0,0 +1,95 @@
+package azure_functions
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const (
+	DEPLOYMENT_API_VERSION = "2021-04-01"
+	WEB_API_VERSION        = "2023-12-01"
+)
+
+//go:embed function_app.json
+var functionAppTemplate []byte
+
+type site struct {
+	ID         string `json:"id"`
+	Properties struct {
+		State           string `json:"state"`
+		DefaultHostName string `json:"defaultHostName"`
+	} `json:"properties"`
+}
+
+func (s *site) running() bool {
+	return s.Properties.State == "Running" && s.Properties.DefaultHostName != ""
+}
+
+type deployment struct {
+	Properties struct {
+		Outputs struct {
+			SiteID struct {
+				Value string `json:"value"`
+			} `json:"siteId"`
+			HostName struct {
+				Value string `json:"value"`
+			} `json:"hostName"`
+		} `json:"outputs"`
+	} `json:"properties"`
+}
+
+func sitePath(name string) string {
+	return fmt.Sprintf("%s/providers/Microsoft.Web/sites/%s", resourceGroupPath(), name)
+}
+
+func fetchSite(ctx context.Context, name string) (*site, error) {
+	s := site{}
+	if err := arm.do(ctx, http.MethodGet, sitePath(name), WEB_API_VERSION, nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// deploySite deploys the function app running image with the embedded arm template, deployments are
+// incremental so that a redeployment only replaces the image of the app
+func deploySite(ctx context.Context, name string, image string, identity string) (string, string, error) {
+	parameters := map[string]any{}
+	for key, value := range map[string]string{
+		"siteName":       name,
+		"location":       config.AzureFunctionsLocation,
+		"planId":         config.AzureFunctionsPlanID,
+		"image":          image,
+		"identityId":     config.AzureFunctionsIdentityID,
+		"storageAccount": config.AzureFunctionsStorageAccount,
+		"pluginIdentity": identity,
+	} {
+		parameters[key] = map[string]string{"value": value}
+	}
+
+	result := deployment{}
+	if err := arm.do(
+		ctx,
+		http.MethodPut,
+		fmt.Sprintf("%s/providers/Microsoft.Resources/deployments/%s", resourceGroupPath(), name),
+		DEPLOYMENT_API_VERSION,
+		map[string]any{
+			"properties": map[string]any{
+				"mode":       "Incremental",
+				"template":   json.RawMessage(functionAppTemplate),
+				"parameters": parameters,
+			},
+		},
+		&result,
+	); err != nil {
+		return "", "", fmt.Errorf("failed to deploy function app %s: %w", name, err)
+	}
+
+	outputs := result.Properties.Outputs
+	if outputs.SiteID.Value == "" || outputs.HostName.Value == "" {
+		return "", "", fmt.Errorf("deployment of function app %s has no outputs", name)
+	}
+	return outputs.SiteID.Value, outputs.HostName.Value, nil
+}
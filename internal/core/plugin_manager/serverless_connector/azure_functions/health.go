@@ -0,0 +1,97 @@
+package azure_functions
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+const (
+	HEALTH_CHECK_LOCK_KEY       = "azure_functions:health:lock"
+	HEALTH_CHECK_FAILURE_PREFIX = "azure_functions:health:failures:"
+	HEALTH_CHECK_TIMEOUT        = 10 * time.Second
+	// apps failing this many checks in a row are restarted
+	HEALTH_CHECK_MAX_FAILURES = 3
+)
+
+// checkHealth probes the anonymous healthz route of the bridge, it starts the plugin if it's not running
+func checkHealth(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/healthz", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := arm.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check responded with status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// monitorHealth checks all function apps of plugins, it runs on one node of the cluster at a time
+func monitorHealth() {
+	interval := time.Duration(config.AzureFunctionsHealthCheckInterval) * time.Second
+	if ok, err := cache.SetNX(HEALTH_CHECK_LOCK_KEY, true, interval/2); err != nil || !ok {
+		return
+	}
+
+	runtimes, err := db.GetAll[models.ServerlessRuntime](
+		db.Equal("type", string(models.SERVERLESS_RUNTIME_TYPE_AZURE_FUNCTIONS)),
+	)
+	if err != nil {
+		log.Error("failed to list azure functions runtimes: %s", err.Error())
+		return
+	}
+
+	for _, runtime := range runtimes {
+		ctx, cancel := context.WithTimeout(context.Background(), HEALTH_CHECK_TIMEOUT)
+		err := checkHealth(ctx, runtime.FunctionURL)
+		cancel()
+
+		failureKey := HEALTH_CHECK_FAILURE_PREFIX + runtime.FunctionName
+		if err == nil {
+			cache.Del(failureKey)
+			continue
+		}
+
+		failures, cacheErr := cache.Increase(failureKey)
+		if cacheErr != nil {
+			log.Error("failed to count health check failures of %s: %s", runtime.FunctionName, cacheErr.Error())
+			continue
+		}
+		// failures are forgotten if the app recovers without checks for a while
+		cache.Expire(failureKey, interval*HEALTH_CHECK_MAX_FAILURES*2)
+
+		log.Warn(
+			"health check of plugin %s on azure functions failed %d times: %s",
+			runtime.PluginUniqueIdentifier, failures, err.Error(),
+		)
+		if failures < HEALTH_CHECK_MAX_FAILURES {
+			continue
+		}
+
+		if err := restartSite(runtime.FunctionName); err != nil {
+			log.Error("failed to restart function app %s: %s", runtime.FunctionName, err.Error())
+			continue
+		}
+		cache.Del(failureKey)
+		log.Info("restarted function app %s of plugin %s", runtime.FunctionName, runtime.PluginUniqueIdentifier)
+	}
+}
+
+func restartSite(siteID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), HEALTH_CHECK_TIMEOUT)
+	defer cancel()
+	return arm.do(ctx, http.MethodPost, siteID+"/restart", WEB_API_VERSION, nil, nil)
+}
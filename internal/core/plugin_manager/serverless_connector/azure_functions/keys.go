@@ -0,0 +1,64 @@
+package azure_functions
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/mapping"
+)
+
+const (
+	FUNCTION_KEY_EXPIRE = 10 * time.Minute
+)
+
+type hostKeys struct {
+	FunctionKeys map[string]string `json:"functionKeys"`
+}
+
+type cachedKey struct {
+	key       string
+	expiresAt time.Time
+}
+
+var (
+	// function keys are kept in memory of the node, they are fetched from arm on every invocation otherwise
+	functionKeys mapping.Map[string, cachedKey]
+)
+
+// functionKey returns the default function key of the app
+func functionKey(ctx context.Context, siteID string) (string, error) {
+	if cached, ok := functionKeys.Load(siteID); ok && time.Now().Before(cached.expiresAt) {
+		return cached.key, nil
+	}
+
+	keys := hostKeys{}
+	if err := arm.do(ctx, http.MethodPost, siteID+"/host/default/listkeys", WEB_API_VERSION, nil, &keys); err != nil {
+		return "", err
+	}
+
+	key := keys.FunctionKeys["default"]
+	functionKeys.Store(siteID, cachedKey{key: key, expiresAt: time.Now().Add(FUNCTION_KEY_EXPIRE)})
+	return key, nil
+}
+
+type functionKeyTransport struct {
+	siteID string
+	base   http.RoundTripper
+}
+
+func (t *functionKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key, err := functionKey(req.Context(), t.siteID)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("x-functions-key", key)
+	return t.base.RoundTrip(req)
+}
+
+// Transport wraps base to authorize requests to the function app of siteID with its function key
+func Transport(siteID string, base http.RoundTripper) (http.RoundTripper, error) {
+	return &functionKeyTransport{siteID: siteID, base: base}, nil
+}
@@ -0,0 +1,30 @@
+package cloud_run
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/mapping"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/idtoken"
+)
+
+var (
+	// token sources are cached per audience, tokens are reused until they are about to expire
+	tokenSources mapping.Map[string, oauth2.TokenSource]
+)
+
+// Transport wraps base to authorize requests to the service at url with an id token of the daemon
+func Transport(url string, base http.RoundTripper) (http.RoundTripper, error) {
+	source, ok := tokenSources.Load(url)
+	if !ok {
+		var err error
+		source, err = idtoken.NewTokenSource(context.Background(), url, clientOptions...)
+		if err != nil {
+			return nil, err
+		}
+		source, _ = tokenSources.LoadOrStore(url, source)
+	}
+
+	return &oauth2.Transport{Source: source, Base: base}, nil
+}
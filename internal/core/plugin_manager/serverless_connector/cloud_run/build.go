@@ -0,0 +1,82 @@
+package cloud_run
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_runtime/dockerfile"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	cloudbuild "google.golang.org/api/cloudbuild/v1"
+)
+
+// buildImage uploads the build context and builds the image with cloud build, returns the image
+func buildImage(
+	ctx context.Context,
+	declaration *plugin_entities.PluginDeclaration,
+	pluginDecoder decoder.PluginDecoder,
+	checksum string,
+) (string, error) {
+	archive, err := dockerfile.BuildContext(declaration, pluginDecoder)
+	if err != nil {
+		return "", err
+	}
+
+	object := fmt.Sprintf("contexts/%s.tar.gz", checksum)
+	writer := storageClient.Bucket(config.CloudRunBuildBucket).Object(object).NewWriter(ctx)
+	if _, err := writer.Write(archive); err != nil {
+		writer.Close()
+		return "", fmt.Errorf("failed to upload build context: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to upload build context: %w", err)
+	}
+
+	image := fmt.Sprintf("%s/%s:%s", config.CloudRunImageRepository, serviceID(checksum), checksum)
+	operation, err := buildService.Projects.Builds.Create(config.CloudRunProject, &cloudbuild.Build{
+		Source: &cloudbuild.Source{
+			StorageSource: &cloudbuild.StorageSource{
+				Bucket: config.CloudRunBuildBucket,
+				Object: object,
+			},
+		},
+		Steps: []*cloudbuild.BuildStep{{
+			Name: "gcr.io/cloud-builders/docker",
+			Args: []string{"build", "-t", image, "."},
+		}},
+		Images: []string{image},
+	}).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to create build: %w", err)
+	}
+
+	metadata := cloudbuild.BuildOperationMetadata{}
+	if err := json.Unmarshal(operation.Metadata, &metadata); err != nil || metadata.Build == nil {
+		return "", fmt.Errorf("unexpected build operation metadata: %s", string(operation.Metadata))
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		build, err := buildService.Projects.Builds.Get(config.CloudRunProject, metadata.Build.Id).Context(ctx).Do()
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch build %s: %w", metadata.Build.Id, err)
+		}
+
+		switch build.Status {
+		case "SUCCESS":
+			return image, nil
+		case "QUEUED", "PENDING", "WORKING", "STATUS_UNKNOWN":
+		default:
+			return "", fmt.Errorf("build %s is %s: %s, logs: %s", build.Id, build.Status, build.StatusDetail, build.LogUrl)
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("build %s timed out: %w", build.Id, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
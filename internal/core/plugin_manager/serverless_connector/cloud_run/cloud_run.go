@@ -0,0 +1,165 @@
+// Package cloud_run deploys serverless plugins to google cloud run, the package is built into an
+// image by cloud build and served by a private service which is invoked with an id token
+package cloud_run
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	serverless "github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	cloudbuild "google.golang.org/api/cloudbuild/v1"
+	"google.golang.org/api/option"
+	run "google.golang.org/api/run/v2"
+)
+
+const (
+	CLOUD_RUN_LAUNCH_LOCK_PREFIX = "cloud_run_launch_lock_"
+	CLOUD_RUN_POLL_INTERVAL      = 5 * time.Second
+	// cloud run service ids are at most 49 characters and start with a letter
+	CLOUD_RUN_SERVICE_PREFIX = "dify-plugin-"
+	CLOUD_RUN_SERVICE_ID_MAX = 49
+)
+
+var (
+	config        *app.Config
+	clientOptions []option.ClientOption
+
+	runService     *run.Service
+	buildService   *cloudbuild.Service
+	storageClient  *storage.Client
+	pluginPatterns []string
+
+	pollInterval = CLOUD_RUN_POLL_INTERVAL
+)
+
+// Init creates the google api clients, it's a no-op if no plugin is selected by CLOUD_RUN_PLUGINS
+func Init(configuration *app.Config) {
+	pluginPatterns = configuration.CloudRunPlugins
+	if len(pluginPatterns) == 0 {
+		return
+	}
+
+	config = configuration
+	if config.CloudRunCredentialsFile != "" {
+		clientOptions = append(clientOptions, option.WithCredentialsFile(config.CloudRunCredentialsFile))
+	}
+
+	var err error
+	ctx := context.Background()
+	if runService, err = run.NewService(ctx, clientOptions...); err != nil {
+		log.Panic("failed to init cloud run client: %s", err.Error())
+	}
+	if buildService, err = cloudbuild.NewService(ctx, clientOptions...); err != nil {
+		log.Panic("failed to init cloud build client: %s", err.Error())
+	}
+	if storageClient, err = storage.NewClient(ctx, clientOptions...); err != nil {
+		log.Panic("failed to init cloud storage client: %s", err.Error())
+	}
+
+	log.Info("cloud run connector initialized, project: %s, region: %s", config.CloudRunProject, config.CloudRunRegion)
+}
+
+// Selected reports whether the plugin is deployed to cloud run according to CLOUD_RUN_PLUGINS
+func Selected(pluginID string) bool {
+	return serverless.MatchPlugin(pluginPatterns, pluginID)
+}
+
+// serviceID derives the id of the service from the checksum, a package always maps to the same service
+func serviceID(checksum string) string {
+	id := CLOUD_RUN_SERVICE_PREFIX + strings.ToLower(checksum)
+	if len(id) > CLOUD_RUN_SERVICE_ID_MAX {
+		id = id[:CLOUD_RUN_SERVICE_ID_MAX]
+	}
+	return strings.TrimRight(id, "-")
+}
+
+func locationName() string {
+	return fmt.Sprintf("projects/%s/locations/%s", config.CloudRunProject, config.CloudRunRegion)
+}
+
+func serviceName(checksum string) string {
+	return fmt.Sprintf("%s/services/%s", locationName(), serviceID(checksum))
+}
+
+// LaunchPlugin builds and deploys the plugin to cloud run, events are the same as the serverless
+// connector, the function is the resource name of the service and the function url is its https url
+func LaunchPlugin(
+	originPackage []byte,
+	decoder decoder.PluginDecoder,
+	timeout int, // in seconds
+	ignoreIdempotent bool, // if true, always build and deploy again
+) (*stream.Stream[serverless.LaunchFunctionResponse], error) {
+	if runService == nil {
+		return nil, fmt.Errorf("cloud run connector is not initialized")
+	}
+
+	checksum, err := decoder.Checksum()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := decoder.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	response := stream.NewStream[serverless.LaunchFunctionResponse](10)
+	routine.Submit(map[string]string{
+		"module":   "cloud_run",
+		"function": "LaunchPlugin",
+		"checksum": checksum,
+	}, func() {
+		defer response.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+		defer cancel()
+
+		// the same package is deployed by one node at a time
+		if err := cache.Lock(CLOUD_RUN_LAUNCH_LOCK_PREFIX+checksum, time.Duration(timeout)*time.Second, time.Duration(timeout)*time.Second); err != nil {
+			response.Write(serverless.LaunchFunctionResponse{Event: serverless.Error, Message: err.Error()})
+			return
+		}
+		defer cache.Unlock(CLOUD_RUN_LAUNCH_LOCK_PREFIX + checksum)
+
+		name := serviceName(checksum)
+		if !ignoreIdempotent {
+			if service, err := runService.Projects.Locations.Services.Get(name).Context(ctx).Do(); err == nil && serviceReady(service) {
+				writeLaunched(response, service)
+				return
+			}
+		}
+
+		response.Write(serverless.LaunchFunctionResponse{Event: serverless.Info, Message: "Building plugin..."})
+		image, err := buildImage(ctx, &manifest, decoder, checksum)
+		if err != nil {
+			response.Write(serverless.LaunchFunctionResponse{Event: serverless.Error, Message: err.Error()})
+			return
+		}
+
+		response.Write(serverless.LaunchFunctionResponse{Event: serverless.Info, Message: "Launching plugin..."})
+		service, err := deployService(ctx, name, image, manifest.Identity())
+		if err != nil {
+			response.Write(serverless.LaunchFunctionResponse{Event: serverless.Error, Message: err.Error()})
+			return
+		}
+
+		writeLaunched(response, service)
+	})
+
+	return response, nil
+}
+
+func writeLaunched(response *stream.Stream[serverless.LaunchFunctionResponse], service *run.GoogleCloudRunV2Service) {
+	response.Write(serverless.LaunchFunctionResponse{Event: serverless.Function, Message: service.Name})
+	response.Write(serverless.LaunchFunctionResponse{Event: serverless.FunctionUrl, Message: service.Uri})
+	response.Write(serverless.LaunchFunctionResponse{Event: serverless.Done, Message: "Plugin launched"})
+}
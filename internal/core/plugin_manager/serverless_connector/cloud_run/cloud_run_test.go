@@ -0,0 +1,146 @@
+package cloud_run
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_runtime/dockerfile"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	"google.golang.org/api/option"
+	run "google.golang.org/api/run/v2"
+)
+
+func TestSelected(t *testing.T) {
+	pluginPatterns = []string{"langgenius/openai", " acme/*"}
+	t.Cleanup(func() { pluginPatterns = nil })
+
+	for pluginID, expected := range map[string]bool{
+		"langgenius/openai":    true,
+		"langgenius/anthropic": false,
+		"acme/crm":             true,
+		"acmecorp/crm":         false,
+	} {
+		if Selected(pluginID) != expected {
+			t.Fatalf("expected Selected(%s) to be %v", pluginID, expected)
+		}
+	}
+
+	pluginPatterns = []string{"*"}
+	if !Selected("langgenius/anthropic") {
+		t.Fatal("expected * to select all plugins")
+	}
+}
+
+func TestServiceID(t *testing.T) {
+	id := serviceID(strings.Repeat("AB", 32))
+	if len(id) > CLOUD_RUN_SERVICE_ID_MAX || !strings.HasPrefix(id, CLOUD_RUN_SERVICE_PREFIX) || id != strings.ToLower(id) {
+		t.Fatalf("invalid cloud run service id %s", id)
+	}
+}
+
+func TestBuildContext(t *testing.T) {
+	pkg, err := os.ReadFile("../../testdata/openai.difypkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pluginDecoder, err := decoder.NewZipPluginDecoder(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	declaration, err := pluginDecoder.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := dockerfile.BuildContext(&declaration, pluginDecoder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(tarReader)
+		files[header.Name] = string(content)
+	}
+
+	if !strings.Contains(files["Dockerfile"], declaration.Meta.Runner.Entrypoint) {
+		t.Fatalf("expected the generated Dockerfile in the context, got %q", files["Dockerfile"])
+	}
+	if _, ok := files["manifest.yaml"]; !ok {
+		t.Fatal("expected the files of the plugin in the context")
+	}
+}
+
+func TestDeployService(t *testing.T) {
+	const name = "projects/p/locations/r/services/dify-plugin-abc"
+
+	var deployed run.GoogleCloudRunV2Service
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPatch && strings.HasSuffix(r.URL.Path, name):
+			if r.URL.Query().Get("allowMissing") != "true" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			json.NewDecoder(r.Body).Decode(&deployed)
+			w.Write([]byte(`{"name": "projects/p/locations/r/operations/1", "done": false}`))
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "operations/1"):
+			w.Write([]byte(`{"name": "projects/p/locations/r/operations/1", "done": true}`))
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, name):
+			w.Write([]byte(`{"name": "` + name + `", "uri": "https://dify-plugin-abc.a.run.app", "terminalCondition": {"state": "CONDITION_SUCCEEDED"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var err error
+	runService, err = run.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	config = &app.Config{CloudRunConcurrency: 80, CloudRunMaxInstances: 10, PluginMaxExecutionTimeout: 600}
+	pollInterval = 10 * time.Millisecond
+	t.Cleanup(func() {
+		runService = nil
+		config = nil
+		pollInterval = CLOUD_RUN_POLL_INTERVAL
+	})
+
+	service, err := deployService(context.Background(), name, "repo/dify-plugin-abc:abc", "langgenius/openai:0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if service.Uri != "https://dify-plugin-abc.a.run.app" {
+		t.Fatalf("unexpected service url %s", service.Uri)
+	}
+
+	container := deployed.Template.Containers[0]
+	if container.Image != "repo/dify-plugin-abc:abc" || deployed.Template.Timeout != "600s" {
+		t.Fatalf("unexpected revision template %+v", deployed.Template)
+	}
+}
@@ -0,0 +1,78 @@
+package cloud_run
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	run "google.golang.org/api/run/v2"
+)
+
+// serviceReady reports whether the latest revision of the service is serving
+func serviceReady(service *run.GoogleCloudRunV2Service) bool {
+	return !service.Reconciling &&
+		service.Uri != "" &&
+		service.TerminalCondition != nil &&
+		service.TerminalCondition.State == "CONDITION_SUCCEEDED"
+}
+
+// deployService creates or updates the service to run image and waits until it's ready
+func deployService(ctx context.Context, name string, image string, identity string) (*run.GoogleCloudRunV2Service, error) {
+	operation, err := runService.Projects.Locations.Services.Patch(name, &run.GoogleCloudRunV2Service{
+		// only the daemon is allowed to invoke, requests without an id token are rejected by cloud run
+		Ingress: "INGRESS_TRAFFIC_ALL",
+		Labels: map[string]string{
+			"managed-by": "dify-plugin-daemon",
+		},
+		Annotations: map[string]string{
+			"dify.ai/plugin": identity,
+		},
+		Template: &run.GoogleCloudRunV2RevisionTemplate{
+			Containers: []*run.GoogleCloudRunV2Container{{
+				Image: image,
+				Env: []*run.GoogleCloudRunV2EnvVar{
+					{Name: "INSTALL_METHOD", Value: "serverless"},
+					{Name: "SERVERLESS_HOST", Value: "0.0.0.0"},
+					{Name: "SERVERLESS_PORT", Value: "8080"},
+				},
+				Ports: []*run.GoogleCloudRunV2ContainerPort{{ContainerPort: 8080}},
+			}},
+			ServiceAccount:                config.CloudRunServiceAccount,
+			MaxInstanceRequestConcurrency: int64(config.CloudRunConcurrency),
+			Scaling: &run.GoogleCloudRunV2RevisionScaling{
+				MaxInstanceCount: int64(config.CloudRunMaxInstances),
+			},
+			Timeout: fmt.Sprintf("%ds", config.PluginMaxExecutionTimeout),
+		},
+	}).AllowMissing(true).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to deploy cloud run service %s: %w", name, err)
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for !operation.Done {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("deploying cloud run service %s timed out: %w", name, ctx.Err())
+		case <-ticker.C:
+		}
+
+		operation, err = runService.Projects.Locations.Operations.Get(operation.Name).Context(ctx).Do()
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch deployment of cloud run service %s: %w", name, err)
+		}
+	}
+	if operation.Error != nil {
+		return nil, fmt.Errorf("failed to deploy cloud run service %s: %s", name, operation.Error.Message)
+	}
+
+	service, err := runService.Projects.Locations.Services.Get(name).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	if !serviceReady(service) {
+		return nil, fmt.Errorf("cloud run service %s is not ready after deployment", name)
+	}
+	return service, nil
+}
@@ -0,0 +1,81 @@
+package self_hosted
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/mtls"
+)
+
+var (
+	// functions are invoked with the token and the client certificate, the gateway or the mesh in front
+	// of the functions verifies them
+	functionToken string
+	functionTLS   *mtls.Loader
+)
+
+// initAuth loads the credentials invocations of functions are authenticated with
+func initAuth(configuration *app.Config) error {
+	functionToken = configuration.SelfHostedServerlessFunctionToken
+	functionTLS = nil
+
+	files := mtls.Files{
+		CertFile: configuration.SelfHostedServerlessTLSCertFile,
+		KeyFile:  configuration.SelfHostedServerlessTLSKeyFile,
+		CAFile:   configuration.SelfHostedServerlessTLSCAFile,
+	}
+	if files.CertFile == "" && files.KeyFile == "" && files.CAFile == "" {
+		return nil
+	}
+
+	loader, err := mtls.NewLoader(files)
+	if err != nil {
+		return err
+	}
+	functionTLS = loader
+	return nil
+}
+
+// tlsTransport presents the client certificate to host if one is configured, base is returned otherwise
+func tlsTransport(host string, base *http.Transport) *http.Transport {
+	if functionTLS == nil {
+		return base
+	}
+	transport := base.Clone()
+	transport.TLSClientConfig = functionTLS.ClientConfig(host)
+	return transport
+}
+
+type functionTokenTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t *functionTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return t.base.RoundTrip(req)
+}
+
+// Transport wraps base to authorize requests to the function at functionURL with the token and the
+// client certificate of SELF_HOSTED_SERVERLESS_FUNCTION_TOKEN and SELF_HOSTED_SERVERLESS_TLS_*
+func Transport(functionURL string, base http.RoundTripper) (http.RoundTripper, error) {
+	if functionTLS != nil {
+		transport, ok := base.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("client certificates need an *http.Transport, got %T", base)
+		}
+		u, err := url.Parse(functionURL)
+		if err != nil {
+			return nil, err
+		}
+		base = tlsTransport(u.Hostname(), transport)
+	}
+
+	if functionToken == "" {
+		return base, nil
+	}
+	return &functionTokenTransport{token: functionToken, base: base}, nil
+}
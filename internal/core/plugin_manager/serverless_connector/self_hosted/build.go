@@ -0,0 +1,204 @@
+package self_hosted
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_runtime/dockerfile"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+const (
+	// configmaps are limited to 1MiB, the build context is split into as many as it takes
+	CONTEXT_CHUNK_SIZE = 900 * 1024
+	CONTEXT_CHUNK_KEY  = "context.part"
+	CONTEXT_IMAGE      = "busybox:1.36"
+	// finished build jobs and their context configmaps are garbage collected after this long
+	BUILD_TTL_SECONDS = 600
+)
+
+type kubeObject struct {
+	Metadata struct {
+		Name string `json:"name"`
+		UID  string `json:"uid"`
+	} `json:"metadata"`
+}
+
+type kubeJob struct {
+	Status struct {
+		Succeeded  int `json:"succeeded"`
+		Failed     int `json:"failed"`
+		Conditions []struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+func splitContext(archive []byte) [][]byte {
+	chunks := [][]byte{}
+	for len(archive) > CONTEXT_CHUNK_SIZE {
+		chunks = append(chunks, archive[:CONTEXT_CHUNK_SIZE])
+		archive = archive[CONTEXT_CHUNK_SIZE:]
+	}
+	return append(chunks, archive)
+}
+
+func chunkName(jobName string, index int) string {
+	return fmt.Sprintf("%s-%03d", jobName, index)
+}
+
+// buildJob assembles the context from its configmaps in an init container and builds it with kaniko,
+// chunks are mounted in order so that the shell glob concatenates them back into the archive
+func buildJob(jobName string, image string, chunks int, timeout time.Duration) map[string]any {
+	labels := map[string]string{
+		"app.kubernetes.io/managed-by": FIELD_MANAGER,
+		"app.kubernetes.io/component":  "plugin-build",
+	}
+
+	volumes := []map[string]any{{"name": "workspace", "emptyDir": map[string]any{}}}
+	contextMounts := []map[string]any{{"name": "workspace", "mountPath": "/workspace"}}
+	for i := 0; i < chunks; i++ {
+		volumes = append(volumes, map[string]any{
+			"name":      fmt.Sprintf("chunk-%03d", i),
+			"configMap": map[string]any{"name": chunkName(jobName, i)},
+		})
+		contextMounts = append(contextMounts, map[string]any{
+			"name":      fmt.Sprintf("chunk-%03d", i),
+			"mountPath": fmt.Sprintf("/chunks/%03d", i),
+		})
+	}
+
+	args := []string{
+		"--context=dir:///workspace",
+		"--dockerfile=Dockerfile",
+		"--destination=" + image,
+	}
+	if config.SelfHostedServerlessInsecureRegistry {
+		args = append(args, "--insecure")
+	}
+
+	kanikoMounts := []map[string]any{{"name": "workspace", "mountPath": "/workspace"}}
+	if config.SelfHostedServerlessRegistrySecret != "" {
+		volumes = append(volumes, map[string]any{
+			"name": "registry",
+			"secret": map[string]any{
+				"secretName": config.SelfHostedServerlessRegistrySecret,
+				"items":      []map[string]any{{"key": ".dockerconfigjson", "path": "config.json"}},
+			},
+		})
+		kanikoMounts = append(kanikoMounts, map[string]any{"name": "registry", "mountPath": "/kaniko/.docker"})
+	}
+
+	return map[string]any{
+		"apiVersion": "batch/v1",
+		"kind":       "Job",
+		"metadata": map[string]any{
+			"name":   jobName,
+			"labels": labels,
+		},
+		"spec": map[string]any{
+			"backoffLimit":            0,
+			"activeDeadlineSeconds":   int64(timeout.Seconds()),
+			"ttlSecondsAfterFinished": BUILD_TTL_SECONDS,
+			"template": map[string]any{
+				"metadata": map[string]any{"labels": labels},
+				"spec": map[string]any{
+					"restartPolicy": "Never",
+					"initContainers": []map[string]any{{
+						"name":         "context",
+						"image":        CONTEXT_IMAGE,
+						"command":      []string{"sh", "-c", "cat /chunks/*/" + CONTEXT_CHUNK_KEY + " | tar xz -C /workspace"},
+						"volumeMounts": contextMounts,
+					}},
+					"containers": []map[string]any{{
+						"name":         "kaniko",
+						"image":        config.SelfHostedServerlessKanikoImage,
+						"args":         args,
+						"volumeMounts": kanikoMounts,
+					}},
+					"volumes": volumes,
+				},
+			},
+		},
+	}
+}
+
+// buildImage builds the image of the plugin with a kaniko job and returns it, the pod of the job
+// waits for its context configmaps which are created afterwards and owned by the job
+func buildImage(
+	ctx context.Context,
+	declaration *plugin_entities.PluginDeclaration,
+	pluginDecoder decoder.PluginDecoder,
+	name string,
+	checksum string,
+	timeout time.Duration,
+) (string, error) {
+	archive, err := dockerfile.BuildContext(declaration, pluginDecoder)
+	if err != nil {
+		return "", err
+	}
+	chunks := splitContext(archive)
+
+	image := fmt.Sprintf("%s/%s:%s", config.SelfHostedServerlessImageRepository, name, checksum)
+	// a job of a previous launch may still exist, names of builds are unique
+	jobName := fmt.Sprintf("%s-build-%s", name, strconv.FormatInt(time.Now().Unix(), 36))
+	jobs := fmt.Sprintf("/apis/batch/v1/namespaces/%s/jobs", buildNamespace)
+
+	job := kubeObject{}
+	if err := kube.create(ctx, jobs, buildJob(jobName, image, len(chunks), timeout), &job); err != nil {
+		return "", fmt.Errorf("failed to create build job: %w", err)
+	}
+
+	for i, chunk := range chunks {
+		if err := kube.create(ctx, fmt.Sprintf("/api/v1/namespaces/%s/configmaps", buildNamespace), map[string]any{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]any{
+				"name": chunkName(jobName, i),
+				"ownerReferences": []map[string]any{{
+					"apiVersion": "batch/v1",
+					"kind":       "Job",
+					"name":       jobName,
+					"uid":        job.Metadata.UID,
+				}},
+			},
+			// byte slices are encoded as base64 which is what binaryData expects
+			"binaryData": map[string]any{CONTEXT_CHUNK_KEY: chunk},
+		}, nil); err != nil {
+			return "", fmt.Errorf("failed to upload build context: %w", err)
+		}
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		status := kubeJob{}
+		if err := kube.get(ctx, jobs+"/"+jobName, &status); err != nil {
+			return "", fmt.Errorf("failed to fetch build job %s: %w", jobName, err)
+		}
+
+		if status.Status.Succeeded > 0 {
+			return image, nil
+		}
+		for _, condition := range status.Status.Conditions {
+			if condition.Type == "Failed" && condition.Status == "True" {
+				return "", fmt.Errorf(
+					"build job %s failed, %s: %s, see kubectl logs -n %s job/%s -c kaniko",
+					jobName, condition.Reason, condition.Message, buildNamespace, jobName,
+				)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("build job %s timed out: %w", jobName, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
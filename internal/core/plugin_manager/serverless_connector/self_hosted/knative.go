@@ -0,0 +1,142 @@
+package self_hosted
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+)
+
+type knativeService struct {
+	Metadata struct {
+		Generation int64 `json:"generation"`
+	} `json:"metadata"`
+	Status struct {
+		ObservedGeneration int64  `json:"observedGeneration"`
+		URL                string `json:"url"`
+		Address            struct {
+			URL string `json:"url"`
+		} `json:"address"`
+		Conditions []struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+// state reports whether the latest generation of the service is ready, or the reason it failed
+func (s *knativeService) state() (bool, error) {
+	if s.Status.ObservedGeneration != s.Metadata.Generation {
+		return false, nil
+	}
+	for _, condition := range s.Status.Conditions {
+		if condition.Type != "Ready" {
+			continue
+		}
+		switch condition.Status {
+		case "True":
+			return true, nil
+		case "False":
+			return false, fmt.Errorf("%s: %s", condition.Reason, condition.Message)
+		}
+	}
+	return false, nil
+}
+
+func (s *knativeService) url() string {
+	if s.Status.Address.URL != "" {
+		return s.Status.Address.URL
+	}
+	return s.Status.URL
+}
+
+// knative runs plugins as knative services visible only inside the cluster, revisions scale to zero
+type knative struct{}
+
+func (knative) runtimeType() models.ServerlessRuntimeType {
+	return models.SERVERLESS_RUNTIME_TYPE_KNATIVE
+}
+
+func (knative) path(name string) string {
+	return fmt.Sprintf("/apis/serving.knative.dev/v1/namespaces/%s/services/%s", functionNamespace, name)
+}
+
+func (k knative) lookup(ctx context.Context, name string) (string, bool, error) {
+	service := knativeService{}
+	if err := kube.get(ctx, k.path(name), &service); err != nil {
+		if isNotFound(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	ready, _ := service.state()
+	return service.url(), ready && service.url() != "", nil
+}
+
+func (k knative) deploy(ctx context.Context, name string, image string, identity string) (string, error) {
+	service := knativeService{}
+	if err := kube.apply(ctx, k.path(name), map[string]any{
+		"apiVersion": "serving.knative.dev/v1",
+		"kind":       "Service",
+		"metadata": map[string]any{
+			"name":      name,
+			"namespace": functionNamespace,
+			"labels": map[string]string{
+				"app.kubernetes.io/managed-by":      FIELD_MANAGER,
+				"networking.knative.dev/visibility": "cluster-local",
+			},
+			"annotations": map[string]string{
+				"dify.ai/plugin": identity,
+			},
+		},
+		"spec": map[string]any{
+			"template": map[string]any{
+				"metadata": map[string]any{
+					"annotations": map[string]string{
+						"autoscaling.knative.dev/min-scale": "0",
+						"autoscaling.knative.dev/max-scale": strconv.Itoa(config.SelfHostedServerlessMaxScale),
+					},
+				},
+				"spec": map[string]any{
+					"containerConcurrency": config.SelfHostedServerlessConcurrency,
+					"timeoutSeconds":       config.PluginMaxExecutionTimeout,
+					"containers": []map[string]any{{
+						"image": image,
+						"env":   functionEnv(),
+						"ports": []map[string]any{{"containerPort": FUNCTION_PORT}},
+					}},
+				},
+			},
+		},
+	}, &service); err != nil {
+		return "", fmt.Errorf("failed to deploy knative service %s: %w", name, err)
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		ready, err := service.state()
+		if err != nil {
+			return "", fmt.Errorf("knative service %s is not ready, %w", name, err)
+		}
+		if ready && service.url() != "" {
+			return service.url(), nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("deploying knative service %s timed out: %w", name, ctx.Err())
+		case <-ticker.C:
+		}
+
+		service = knativeService{}
+		if err := kube.get(ctx, k.path(name), &service); err != nil {
+			return "", fmt.Errorf("failed to fetch knative service %s: %w", name, err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package self_hosted
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	SERVICE_ACCOUNT_DIR = "/var/run/secrets/kubernetes.io/serviceaccount"
+	FIELD_MANAGER       = "dify-plugin-daemon"
+)
+
+// kubeError is a Status returned by the kubernetes api
+type kubeError struct {
+	Code    int    `json:"code"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+func (e *kubeError) Error() string {
+	return fmt.Sprintf("status code %d, %s: %s", e.Code, e.Reason, e.Message)
+}
+
+func isNotFound(err error) bool {
+	var kubeErr *kubeError
+	return errors.As(err, &kubeErr) && kubeErr.Code == http.StatusNotFound
+}
+
+// kubeClient calls the kubernetes api with the service account of the pod
+type kubeClient struct {
+	endpoint string
+	// read on every request, projected service account tokens are rotated
+	tokenFile string
+	client    *http.Client
+}
+
+// newInClusterClient connects to the api server of the cluster the daemon runs in,
+// it returns the namespace of the daemon as well
+func newInClusterClient() (*kubeClient, string, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, "", errors.New("the daemon is not running in a kubernetes cluster")
+	}
+
+	ca, err := os.ReadFile(filepath.Join(SERVICE_ACCOUNT_DIR, "ca.crt"))
+	if err != nil {
+		return nil, "", err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, "", errors.New("invalid service account ca certificate")
+	}
+
+	namespace, err := os.ReadFile(filepath.Join(SERVICE_ACCOUNT_DIR, "namespace"))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &kubeClient{
+		endpoint:  "https://" + net.JoinHostPort(host, port),
+		tokenFile: filepath.Join(SERVICE_ACCOUNT_DIR, "token"),
+		client: &http.Client{
+			Timeout: 60 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool},
+			},
+		},
+	}, strings.TrimSpace(string(namespace)), nil
+}
+
+func (c *kubeClient) do(ctx context.Context, method string, path string, contentType string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.tokenFile != "" {
+		token, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		kubeErr := &kubeError{}
+		if err := json.Unmarshal(data, kubeErr); err != nil || kubeErr.Message == "" {
+			kubeErr = &kubeError{Message: string(data)}
+		}
+		kubeErr.Code = resp.StatusCode
+		return fmt.Errorf("%s %s: %w", method, path, kubeErr)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (c *kubeClient) get(ctx context.Context, path string, out any) error {
+	return c.do(ctx, http.MethodGet, path, "", nil, out)
+}
+
+func (c *kubeClient) create(ctx context.Context, path string, object any, out any) error {
+	return c.do(ctx, http.MethodPost, path, "application/json", object, out)
+}
+
+// apply creates or updates object with server side apply, fields set by others are kept
+func (c *kubeClient) apply(ctx context.Context, path string, object any, out any) error {
+	return c.do(
+		ctx,
+		http.MethodPatch,
+		path+"?fieldManager="+FIELD_MANAGER+"&force=true",
+		"application/apply-patch+yaml",
+		object,
+		out,
+	)
+}
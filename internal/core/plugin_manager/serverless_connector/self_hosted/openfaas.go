@@ -0,0 +1,171 @@
+package self_hosted
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+)
+
+const (
+	// the sdk serves its health check here, faas-netes probes /_/health of the watchdog otherwise
+	OPENFAAS_HEALTH_PATH = "/health"
+)
+
+type openfaasFunction struct {
+	Name              string `json:"name"`
+	Image             string `json:"image"`
+	AvailableReplicas int    `json:"availableReplicas"`
+}
+
+// openfaas runs plugins as functions of an openfaas gateway, they are invoked through the gateway
+// which scales idle functions to zero if the idler is enabled
+type openfaas struct {
+	gateway  string
+	username string
+	password string
+	client   *http.Client
+}
+
+func newOpenFaaS() *openfaas {
+	client := &http.Client{Timeout: 60 * time.Second}
+	// the gateway serves both the functions and its api, so it's reached with the same certificate
+	if gateway, err := url.Parse(config.OpenFaaSGatewayURL); err == nil {
+		client.Transport = tlsTransport(gateway.Hostname(), http.DefaultTransport.(*http.Transport))
+	}
+
+	return &openfaas{
+		gateway:  strings.TrimRight(config.OpenFaaSGatewayURL, "/"),
+		username: config.OpenFaaSUsername,
+		password: config.OpenFaaSPassword,
+		client:   client,
+	}
+}
+
+func (*openfaas) runtimeType() models.ServerlessRuntimeType {
+	return models.SERVERLESS_RUNTIME_TYPE_OPENFAAS
+}
+
+func (o *openfaas) request(ctx context.Context, method string, path string, body any, out any) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, o.gateway+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if o.username != "" {
+		req.SetBasicAuth(o.username, o.password)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return resp.StatusCode, fmt.Errorf("%s %s: status code %d, %s", method, path, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	if out == nil {
+		return resp.StatusCode, nil
+	}
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (o *openfaas) url(name string) string {
+	return fmt.Sprintf("%s/function/%s.%s", o.gateway, name, functionNamespace)
+}
+
+func (o *openfaas) fetch(ctx context.Context, name string) (*openfaasFunction, error) {
+	function := &openfaasFunction{}
+	status, err := o.request(ctx, http.MethodGet, "/system/function/"+name+"?namespace="+url.QueryEscape(functionNamespace), nil, function)
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return function, nil
+}
+
+// lookup treats a deployed function as ready, an idle function has no replicas until it's invoked
+func (o *openfaas) lookup(ctx context.Context, name string) (string, bool, error) {
+	function, err := o.fetch(ctx, name)
+	if err != nil || function == nil {
+		return "", false, err
+	}
+	return o.url(name), true, nil
+}
+
+func (o *openfaas) deploy(ctx context.Context, name string, image string, identity string) (string, error) {
+	env := map[string]string{}
+	for _, variable := range functionEnv() {
+		env[variable["name"]] = variable["value"]
+	}
+
+	deployment := map[string]any{
+		"service":   name,
+		"image":     image,
+		"namespace": functionNamespace,
+		"envVars":   env,
+		"labels": map[string]string{
+			"app.kubernetes.io/managed-by": FIELD_MANAGER,
+			"com.openfaas.scale.zero":      "true",
+			"com.openfaas.scale.max":       strconv.Itoa(config.SelfHostedServerlessMaxScale),
+			"com.openfaas.scale.target":    strconv.Itoa(config.SelfHostedServerlessConcurrency),
+			"com.openfaas.scale.type":      "capacity",
+		},
+		"annotations": map[string]string{
+			"dify.ai/plugin":                identity,
+			"com.openfaas.health.http.path": OPENFAAS_HEALTH_PATH,
+		},
+	}
+
+	// the gateway has no upsert, functions are updated and created if they don't exist
+	status, err := o.request(ctx, http.MethodPut, "/system/functions", deployment, nil)
+	if status == http.StatusNotFound {
+		_, err = o.request(ctx, http.MethodPost, "/system/functions", deployment, nil)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to deploy openfaas function %s: %w", name, err)
+	}
+
+	// a deployment starts with one replica, it's ready once the image is pulled and running
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		function, err := o.fetch(ctx, name)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch openfaas function %s: %w", name, err)
+		}
+		if function != nil && function.Image == image && function.AvailableReplicas > 0 {
+			return o.url(name), nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("deploying openfaas function %s timed out: %w", name, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
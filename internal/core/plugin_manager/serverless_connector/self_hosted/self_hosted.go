@@ -0,0 +1,203 @@
+// Package self_hosted deploys serverless plugins to knative or openfaas in the cluster of the daemon,
+// images are built by kaniko jobs and pushed to a registry the cluster pulls from, so plugins scale
+// to zero without the hosted serverless connector
+package self_hosted
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	serverless "github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/serverless_connector"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+const (
+	SELF_HOSTED_LAUNCH_LOCK_PREFIX = "self_hosted_launch_lock_"
+	SELF_HOSTED_POLL_INTERVAL      = 5 * time.Second
+	// names are dns labels, the build job appends a suffix of its own
+	SELF_HOSTED_NAME_PREFIX = "dify-plugin-"
+	SELF_HOSTED_NAME_MAX    = 40
+
+	BACKEND_KNATIVE  = "knative"
+	BACKEND_OPENFAAS = "openfaas"
+
+	OPENFAAS_DEFAULT_NAMESPACE = "openfaas-fn"
+
+	FUNCTION_PORT = 8080
+)
+
+// backend runs images as functions which scale to zero
+type backend interface {
+	runtimeType() models.ServerlessRuntimeType
+	// lookup returns the url of the function and whether it's deployed and ready
+	lookup(ctx context.Context, name string) (string, bool, error)
+	// deploy creates or updates the function to run image and waits until it's ready, returns its url
+	deploy(ctx context.Context, name string, image string, identity string) (string, error)
+}
+
+var (
+	config         *app.Config
+	kube           *kubeClient
+	functions      backend
+	pluginPatterns []string
+
+	buildNamespace    string
+	functionNamespace string
+
+	pollInterval = SELF_HOSTED_POLL_INTERVAL
+)
+
+// Init connects to the cluster and the selected backend, it's a no-op if no plugin is selected by
+// SELF_HOSTED_SERVERLESS_PLUGINS
+func Init(configuration *app.Config) {
+	pluginPatterns = configuration.SelfHostedServerlessPlugins
+	if len(pluginPatterns) == 0 {
+		return
+	}
+
+	config = configuration
+	if err := initAuth(configuration); err != nil {
+		log.Panic("failed to load credentials of self hosted functions: %s", err.Error())
+	}
+
+	var err error
+	kube, buildNamespace, err = newInClusterClient()
+	if err != nil {
+		log.Panic("failed to init kubernetes client: %s", err.Error())
+	}
+
+	functionNamespace = config.SelfHostedServerlessNamespace
+	switch config.SelfHostedServerlessBackend {
+	case BACKEND_KNATIVE:
+		functions = knative{}
+		if functionNamespace == "" {
+			functionNamespace = buildNamespace
+		}
+	case BACKEND_OPENFAAS:
+		functions = newOpenFaaS()
+		if functionNamespace == "" {
+			functionNamespace = OPENFAAS_DEFAULT_NAMESPACE
+		}
+	default:
+		log.Panic("unsupported self hosted serverless backend: %s", config.SelfHostedServerlessBackend)
+	}
+
+	log.Info(
+		"self hosted serverless connector initialized, backend: %s, namespace: %s",
+		config.SelfHostedServerlessBackend, functionNamespace,
+	)
+}
+
+// Selected reports whether the plugin is deployed to the cluster according to SELF_HOSTED_SERVERLESS_PLUGINS
+func Selected(pluginID string) bool {
+	return serverless.MatchPlugin(pluginPatterns, pluginID)
+}
+
+// RuntimeType is the type serverless runtimes launched by the configured backend are recorded with
+func RuntimeType() models.ServerlessRuntimeType {
+	if functions == nil {
+		return models.SERVERLESS_RUNTIME_TYPE_KNATIVE
+	}
+	return functions.runtimeType()
+}
+
+// functionName derives the name of the function from the checksum, a package always maps to the same function
+func functionName(checksum string) string {
+	name := SELF_HOSTED_NAME_PREFIX + strings.ToLower(checksum)
+	if len(name) > SELF_HOSTED_NAME_MAX {
+		name = name[:SELF_HOSTED_NAME_MAX]
+	}
+	return strings.TrimRight(name, "-")
+}
+
+// functionEnv starts the sdk in serverless mode listening on FUNCTION_PORT
+func functionEnv() []map[string]string {
+	return []map[string]string{
+		{"name": "INSTALL_METHOD", "value": "serverless"},
+		{"name": "SERVERLESS_HOST", "value": "0.0.0.0"},
+		{"name": "SERVERLESS_PORT", "value": fmt.Sprint(FUNCTION_PORT)},
+	}
+}
+
+// LaunchPlugin builds the plugin in the cluster and deploys it to the backend, events are the same as
+// the serverless connector, the function is namespace/name and the function url is its in-cluster url
+func LaunchPlugin(
+	originPackage []byte,
+	decoder decoder.PluginDecoder,
+	timeout int, // in seconds
+	ignoreIdempotent bool, // if true, always build and deploy again
+) (*stream.Stream[serverless.LaunchFunctionResponse], error) {
+	if functions == nil {
+		return nil, fmt.Errorf("self hosted serverless connector is not initialized")
+	}
+
+	checksum, err := decoder.Checksum()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := decoder.Manifest()
+	if err != nil {
+		return nil, err
+	}
+
+	response := stream.NewStream[serverless.LaunchFunctionResponse](10)
+	routine.Submit(map[string]string{
+		"module":   "self_hosted",
+		"function": "LaunchPlugin",
+		"checksum": checksum,
+	}, func() {
+		defer response.Close()
+
+		duration := time.Duration(timeout) * time.Second
+		ctx, cancel := context.WithTimeout(context.Background(), duration)
+		defer cancel()
+
+		// the same package is deployed by one node at a time
+		if err := cache.Lock(SELF_HOSTED_LAUNCH_LOCK_PREFIX+checksum, duration, duration); err != nil {
+			response.Write(serverless.LaunchFunctionResponse{Event: serverless.Error, Message: err.Error()})
+			return
+		}
+		defer cache.Unlock(SELF_HOSTED_LAUNCH_LOCK_PREFIX + checksum)
+
+		name := functionName(checksum)
+		if !ignoreIdempotent {
+			if url, ready, err := functions.lookup(ctx, name); err == nil && ready {
+				writeLaunched(response, name, url)
+				return
+			}
+		}
+
+		response.Write(serverless.LaunchFunctionResponse{Event: serverless.Info, Message: "Building plugin..."})
+		image, err := buildImage(ctx, &manifest, decoder, name, checksum, duration)
+		if err != nil {
+			response.Write(serverless.LaunchFunctionResponse{Event: serverless.Error, Message: err.Error()})
+			return
+		}
+
+		response.Write(serverless.LaunchFunctionResponse{Event: serverless.Info, Message: "Launching plugin..."})
+		url, err := functions.deploy(ctx, name, image, manifest.Identity())
+		if err != nil {
+			response.Write(serverless.LaunchFunctionResponse{Event: serverless.Error, Message: err.Error()})
+			return
+		}
+
+		writeLaunched(response, name, url)
+	})
+
+	return response, nil
+}
+
+func writeLaunched(response *stream.Stream[serverless.LaunchFunctionResponse], name string, url string) {
+	response.Write(serverless.LaunchFunctionResponse{Event: serverless.Function, Message: functionNamespace + "/" + name})
+	response.Write(serverless.LaunchFunctionResponse{Event: serverless.FunctionUrl, Message: url})
+	response.Write(serverless.LaunchFunctionResponse{Event: serverless.Done, Message: "Plugin launched"})
+}
@@ -0,0 +1,337 @@
+package self_hosted
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+)
+
+func TestSelected(t *testing.T) {
+	pluginPatterns = []string{"langgenius/openai", "acme/*"}
+	t.Cleanup(func() { pluginPatterns = nil })
+
+	for pluginID, expected := range map[string]bool{
+		"langgenius/openai":    true,
+		"langgenius/anthropic": false,
+		"acme/crm":             true,
+	} {
+		if Selected(pluginID) != expected {
+			t.Fatalf("expected Selected(%s) to be %v", pluginID, expected)
+		}
+	}
+}
+
+func TestFunctionName(t *testing.T) {
+	name := functionName(strings.Repeat("AB", 32))
+	if len(name) > SELF_HOSTED_NAME_MAX || !strings.HasPrefix(name, SELF_HOSTED_NAME_PREFIX) || name != strings.ToLower(name) {
+		t.Fatalf("invalid function name %s", name)
+	}
+	if functionName(strings.Repeat("AB", 32)) != name {
+		t.Fatal("expected function names to be stable")
+	}
+}
+
+func TestSplitContext(t *testing.T) {
+	archive := bytes.Repeat([]byte{1}, CONTEXT_CHUNK_SIZE*2+1)
+	chunks := splitContext(archive)
+	if len(chunks) != 3 || len(chunks[2]) != 1 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+	if !bytes.Equal(bytes.Join(chunks, nil), archive) {
+		t.Fatal("expected chunks to join back into the archive")
+	}
+
+	if chunks := splitContext([]byte{1}); len(chunks) != 1 {
+		t.Fatalf("expected a single chunk, got %d", len(chunks))
+	}
+}
+
+func useFakes(t *testing.T, handler http.Handler) *httptest.Server {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	config = &app.Config{
+		SelfHostedServerlessMaxScale:    3,
+		SelfHostedServerlessConcurrency: 8,
+		PluginMaxExecutionTimeout:       60,
+		OpenFaaSGatewayURL:              server.URL,
+	}
+	kube = &kubeClient{endpoint: server.URL, client: server.Client()}
+	buildNamespace = "dify"
+	functionNamespace = "plugins"
+	pollInterval = time.Millisecond
+	t.Cleanup(func() {
+		config, kube, functions = nil, nil, nil
+		pollInterval = SELF_HOSTED_POLL_INTERVAL
+	})
+
+	return server
+}
+
+func TestKnativeDeploy(t *testing.T) {
+	var mu sync.Mutex
+	gets := 0
+	var applied map[string]any
+
+	useFakes(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/serving.knative.dev/v1/namespaces/plugins/services/fn" {
+			http.NotFound(w, r)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		service := map[string]any{"metadata": map[string]any{"generation": 2}}
+		switch r.Method {
+		case http.MethodPatch:
+			if r.Header.Get("Content-Type") != "application/apply-patch+yaml" || r.URL.Query().Get("fieldManager") != FIELD_MANAGER {
+				t.Errorf("expected a server side apply, got %s %s", r.Header.Get("Content-Type"), r.URL.RawQuery)
+			}
+			json.NewDecoder(r.Body).Decode(&applied)
+			service["status"] = map[string]any{"observedGeneration": 1}
+		case http.MethodGet:
+			gets++
+			status := map[string]any{"observedGeneration": 2}
+			if gets > 1 {
+				status["address"] = map[string]any{"url": "http://fn.plugins.svc.cluster.local"}
+				status["conditions"] = []map[string]any{{"type": "Ready", "status": "True"}}
+			}
+			service["status"] = status
+		}
+		json.NewEncoder(w).Encode(service)
+	}))
+
+	url, err := knative{}.deploy(context.Background(), "fn", "registry/fn:abc", "acme/crm:0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "http://fn.plugins.svc.cluster.local" {
+		t.Fatalf("unexpected url %s", url)
+	}
+
+	labels := applied["metadata"].(map[string]any)["labels"].(map[string]any)
+	if labels["networking.knative.dev/visibility"] != "cluster-local" {
+		t.Fatalf("expected the service to be cluster local, got %v", labels)
+	}
+
+	url, ready, err := knative{}.lookup(context.Background(), "fn")
+	if err != nil || !ready || url == "" {
+		t.Fatalf("expected the service to be ready, got %s %v %v", url, ready, err)
+	}
+	if _, ready, err := (knative{}).lookup(context.Background(), "missing"); err != nil || ready {
+		t.Fatalf("expected a missing service not to be ready, got %v %v", ready, err)
+	}
+}
+
+func TestKnativeDeployFailed(t *testing.T) {
+	useFakes(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"status": map[string]any{
+				"conditions": []map[string]any{{
+					"type":    "Ready",
+					"status":  "False",
+					"reason":  "RevisionFailed",
+					"message": "image pull failed",
+				}},
+			},
+		})
+	}))
+
+	_, err := knative{}.deploy(context.Background(), "fn", "registry/fn:abc", "acme/crm:0.0.1")
+	if err == nil || !strings.Contains(err.Error(), "image pull failed") {
+		t.Fatalf("expected the reason of the failure, got %v", err)
+	}
+}
+
+func TestOpenFaaSDeployCreatesMissingFunction(t *testing.T) {
+	var mu sync.Mutex
+	methods := []string{}
+	deployed := map[string]any{}
+
+	useFakes(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.URL.Path == "/system/functions":
+			methods = append(methods, r.Method)
+			if r.Method == http.MethodPut {
+				http.Error(w, "function not found", http.StatusNotFound)
+				return
+			}
+			json.NewDecoder(r.Body).Decode(&deployed)
+			w.WriteHeader(http.StatusAccepted)
+		case r.URL.Path == "/system/function/fn" && r.URL.Query().Get("namespace") == "plugins":
+			if len(deployed) == 0 {
+				http.Error(w, "function not found", http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(openfaasFunction{Name: "fn", Image: deployed["image"].(string), AvailableReplicas: 1})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	functions = newOpenFaaS()
+
+	url, err := functions.deploy(context.Background(), "fn", "registry/fn:abc", "acme/crm:0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(url, "/function/fn.plugins") {
+		t.Fatalf("unexpected url %s", url)
+	}
+	if strings.Join(methods, ",") != "PUT,POST" {
+		t.Fatalf("expected an update falling back to create, got %v", methods)
+	}
+
+	env := deployed["envVars"].(map[string]any)
+	if env["INSTALL_METHOD"] != "serverless" || env["SERVERLESS_PORT"] != "8080" {
+		t.Fatalf("unexpected env %v", env)
+	}
+	labels := deployed["labels"].(map[string]any)
+	if labels["com.openfaas.scale.zero"] != "true" || labels["com.openfaas.scale.max"] != "3" {
+		t.Fatalf("unexpected labels %v", labels)
+	}
+}
+
+func TestBuildJob(t *testing.T) {
+	config = &app.Config{
+		SelfHostedServerlessKanikoImage:      "kaniko",
+		SelfHostedServerlessRegistrySecret:   "push",
+		SelfHostedServerlessInsecureRegistry: true,
+	}
+	t.Cleanup(func() { config = nil })
+
+	data, err := json.Marshal(buildJob("fn-build-1", "registry/fn:abc", 2, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := string(data)
+	for _, expected := range []string{
+		`"name":"fn-build-1-000"`,
+		`"name":"fn-build-1-001"`,
+		`"--destination=registry/fn:abc"`,
+		`"--insecure"`,
+		`"secretName":"push"`,
+		`"activeDeadlineSeconds":60`,
+	} {
+		if !strings.Contains(job, expected) {
+			t.Fatalf("expected %s in the build job %s", expected, job)
+		}
+	}
+}
+
+// writeClientCertificate writes a self signed client certificate and its key to dir
+func writeClientCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "daemon"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyRaw, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "daemon.crt"), filepath.Join(dir, "daemon.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyRaw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestTransportAuthenticatesInvocations(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := writeClientCertificate(t, dir)
+
+	invoke := func() int {
+		transport, err := Transport(server.URL, &http.Transport{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL + "/invoke")
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	t.Cleanup(func() { initAuth(&app.Config{}) })
+
+	// the ca is needed to reach the server at all
+	if err := initAuth(&app.Config{SelfHostedServerlessTLSCAFile: caFile}); err != nil {
+		t.Fatal(err)
+	}
+	if status := invoke(); status != 0 {
+		t.Fatalf("expected the handshake to fail without a client certificate, got %d", status)
+	}
+
+	if err := initAuth(&app.Config{
+		SelfHostedServerlessTLSCertFile: certFile,
+		SelfHostedServerlessTLSKeyFile:  keyFile,
+		SelfHostedServerlessTLSCAFile:   caFile,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if status := invoke(); status != http.StatusUnauthorized {
+		t.Fatalf("expected invocations without the token to be refused, got %d", status)
+	}
+
+	if err := initAuth(&app.Config{
+		SelfHostedServerlessFunctionToken: "secret",
+		SelfHostedServerlessTLSCertFile:   certFile,
+		SelfHostedServerlessTLSKeyFile:    keyFile,
+		SelfHostedServerlessTLSCAFile:     caFile,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if status := invoke(); status != http.StatusOK {
+		t.Fatalf("expected an authenticated invocation, got %d", status)
+	}
+}
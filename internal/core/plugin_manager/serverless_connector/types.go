@@ -2,6 +2,7 @@ package serverless
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
@@ -55,3 +56,18 @@ type LaunchFunctionFinalStageMessage struct {
 func getFunctionFilename(manifest plugin_entities.PluginDeclaration, checksum string) string {
 	return fmt.Sprintf("%s@%s@%s@%s.difypkg", manifest.Author, manifest.Name, manifest.Version, checksum)
 }
+
+// MatchPlugin reports whether the plugin id is matched by one of the patterns, author/* matches all
+// plugins of an author and * matches all plugins
+func MatchPlugin(patterns []string, pluginID string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "*" || pattern == pluginID {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && prefix != "" && strings.HasPrefix(pluginID, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package dockerfile
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"path"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+// BuildContext packs the files of the plugin and the generated Dockerfile into a gzipped tarball
+func BuildContext(declaration *plugin_entities.PluginDeclaration, pluginDecoder decoder.PluginDecoder) ([]byte, error) {
+	content, err := GenerateDockerfile(declaration)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	writeFile := func(name string, data []byte) error {
+		if err := tarWriter.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0o644,
+			Size:    int64(len(data)),
+			ModTime: time.Unix(0, 0),
+		}); err != nil {
+			return err
+		}
+		_, err := tarWriter.Write(data)
+		return err
+	}
+
+	if err := pluginDecoder.Walk(func(filename string, dir string) error {
+		if filename == "" {
+			// directories are created along with their files
+			return nil
+		}
+		name := path.Join(dir, filename)
+		if name == "Dockerfile" {
+			return nil
+		}
+		data, err := pluginDecoder.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		return writeFile(name, data)
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := writeFile("Dockerfile", []byte(content)); err != nil {
+		return nil, err
+	}
+	if err := tarWriter.Close(); err != nil {
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
@@ -12,23 +12,32 @@ import (
 
 func (r *ServerlessPluginRuntime) InitEnvironment() error {
 	// init http client
-	r.client = &http.Client{
-		Transport: &http.Transport{
-			TLSHandshakeTimeout: time.Duration(r.PluginMaxExecutionTimeout) * time.Second,
-			IdleConnTimeout:     120 * time.Second,
-			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-				conn, err := (&net.Dialer{
-					Timeout:   time.Duration(r.PluginMaxExecutionTimeout) * time.Second,
-					KeepAlive: 120 * time.Second,
-				}).DialContext(ctx, network, addr)
-				if err != nil {
-					return nil, err
-				}
-				return conn, nil
-			},
+	var transport http.RoundTripper = &http.Transport{
+		TLSHandshakeTimeout: time.Duration(r.PluginMaxExecutionTimeout) * time.Second,
+		IdleConnTimeout:     120 * time.Second,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := (&net.Dialer{
+				Timeout:   time.Duration(r.PluginMaxExecutionTimeout) * time.Second,
+				KeepAlive: 120 * time.Second,
+			}).DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return conn, nil
 		},
 	}
 
+	if r.AuthorizeTransport != nil {
+		var err error
+		if transport, err = r.AuthorizeTransport(transport); err != nil {
+			return err
+		}
+	}
+
+	r.client = &http.Client{
+		Transport: transport,
+	}
+
 	return nil
 }
 
@@ -3,6 +3,7 @@ package serverless_runtime
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/url"
@@ -45,6 +46,11 @@ func (r *ServerlessPluginRuntime) Write(sessionId string, action access_types.Pl
 		return
 	}
 
+	// the request is cancelled once the session stops listening, which is how serverless plugins
+	// are aborted
+	ctx, cancel := context.WithCancel(context.Background())
+	l.OnClose(cancel)
+
 	routine.Submit(map[string]string{
 		"module":     "serverless_runtime",
 		"function":   "Write",
@@ -70,6 +76,7 @@ func (r *ServerlessPluginRuntime) Write(sessionId string, action access_types.Pl
 			}),
 			http_requests.HttpPayloadReader(io.NopCloser(bytes.NewReader(data))),
 			http_requests.HttpReadTimeout(int64(r.PluginMaxExecutionTimeout*1000)),
+			http_requests.HttpContext(ctx),
 		)
 		if err != nil {
 			l.Send(plugin_entities.SessionMessage{
@@ -126,6 +133,8 @@ func (r *ServerlessPluginRuntime) Write(sessionId string, action access_types.Pl
 					})
 				},
 				func(message string) {},
+				nil,
+				nil,
 			)
 		}
 
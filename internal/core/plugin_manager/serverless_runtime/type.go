@@ -22,5 +22,8 @@ type ServerlessPluginRuntime struct {
 
 	client *http.Client
 
+	// AuthorizeTransport wraps the transport of client to authorize requests, e.g. for cloud run
+	AuthorizeTransport func(base http.RoundTripper) (http.RoundTripper, error)
+
 	PluginMaxExecutionTimeout int // in seconds
 }
@@ -0,0 +1,33 @@
+package plugin_manager
+
+import (
+	"context"
+	"sync"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/local_runtime"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// Shutdown stops all the runtimes of current node, local plugins are asked to exit and killed
+// if they are still alive when ctx is done, no plugin is launched afterwards
+func (p *PluginManager) Shutdown(ctx context.Context) {
+	p.shuttingDown.Store(true)
+	if p.warmPool != nil {
+		p.warmPool.Close()
+	}
+
+	wg := sync.WaitGroup{}
+	p.m.Range(func(key string, runtime plugin_entities.PluginLifetime) bool {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if local, ok := runtime.(*local_runtime.LocalPluginRuntime); ok {
+				local.Terminate(ctx)
+			} else {
+				runtime.Stop()
+			}
+		}()
+		return true
+	})
+	wg.Wait()
+}
@@ -1,4 +1,6 @@
-package test_utils
+// Package standalone_runtime launches a plugin outside of the plugin manager to invoke it directly,
+// it backs the run and test-invoke commands and the tests of plugins
+package standalone_runtime
 
 import (
 	"errors"
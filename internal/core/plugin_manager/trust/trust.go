@@ -0,0 +1,217 @@
+package trust
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	"gopkg.in/yaml.v3"
+)
+
+type Tier string
+
+const (
+	// TIER_VERIFIED plugins are signed by the official key
+	TIER_VERIFIED Tier = "verified"
+	// TIER_PARTNER and TIER_COMMUNITY plugins are signed with the corresponding authorized category
+	TIER_PARTNER   Tier = "partner"
+	TIER_COMMUNITY Tier = "community"
+	// TIER_LOCAL plugins are unsigned packages and remote debugging plugins
+	TIER_LOCAL Tier = "local"
+)
+
+const (
+	PERMISSION_TOOL     = "tool"
+	PERMISSION_MODEL    = "model"
+	PERMISSION_NODE     = "node"
+	PERMISSION_ENDPOINT = "endpoint"
+	PERMISSION_APP      = "app"
+	PERMISSION_STORAGE  = "storage"
+)
+
+type Policy struct {
+	// Permissions are the permissions plugins of the tier can be granted, nil grants whatever is requested
+	Permissions []string `json:"permissions" yaml:"permissions"`
+	// MaxStorageSize caps the storage size in bytes a plugin can request, 0 means unlimited
+	MaxStorageSize uint64 `json:"max_storage_size" yaml:"max_storage_size"`
+	// MaxMemory refuses to launch plugins declaring more memory in bytes, 0 means unlimited
+	MaxMemory int64 `json:"max_memory" yaml:"max_memory"`
+	// EgressProxy replaces HTTP_PROXY and HTTPS_PROXY of local plugins, empty keeps the global setting
+	EgressProxy string `json:"egress_proxy" yaml:"egress_proxy"`
+}
+
+type Policies map[Tier]Policy
+
+// DefaultPolicies grants signed plugins whatever they request,
+// community and local plugins get a smaller storage quota
+func DefaultPolicies() Policies {
+	return Policies{
+		TIER_VERIFIED:  {},
+		TIER_PARTNER:   {},
+		TIER_COMMUNITY: {MaxStorageSize: 256 * 1024 * 1024},
+		TIER_LOCAL:     {MaxStorageSize: 256 * 1024 * 1024},
+	}
+}
+
+// LoadPolicies reads a yaml file keyed by tier, a tier in the file replaces its default policy entirely
+func LoadPolicies(path string) (Policies, error) {
+	policies := DefaultPolicies()
+	if path == "" {
+		return policies, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := map[Tier]Policy{}
+	if err := yaml.Unmarshal(data, &overrides); err != nil {
+		return nil, err
+	}
+
+	for tier, policy := range overrides {
+		if _, ok := policies[tier]; !ok {
+			return nil, fmt.Errorf("unknown trust tier: %s", tier)
+		}
+		for _, permission := range policy.Permissions {
+			if !slices.Contains(allPermissions, permission) {
+				return nil, fmt.Errorf("unknown permission %s in trust tier %s", permission, tier)
+			}
+		}
+		policies[tier] = policy
+	}
+
+	return policies, nil
+}
+
+// Of returns the policy of tier, unknown tiers fallback to local
+func (p Policies) Of(tier Tier) Policy {
+	if policy, ok := p[tier]; ok {
+		return policy
+	}
+	return p[TIER_LOCAL]
+}
+
+// TierOf maps the signature of a plugin package to its tier
+//
+// the marketplace records whether a publisher is verified or a partner in the authorized category
+// of the signature, the listing metadata of the marketplace is never queried, so a publisher
+// promoted or demoted there keeps its tier until the package is signed again
+func TierOf(pluginDecoder decoder.PluginDecoder) Tier {
+	if !pluginDecoder.Verified() {
+		return TIER_LOCAL
+	}
+
+	verification, _ := pluginDecoder.Verification()
+	if verification == nil {
+		verification = decoder.DefaultVerification()
+	}
+
+	switch verification.AuthorizedCategory {
+	case decoder.AUTHORIZED_CATEGORY_LANGGENIUS:
+		return TIER_VERIFIED
+	case decoder.AUTHORIZED_CATEGORY_PARTNER:
+		return TIER_PARTNER
+	case decoder.AUTHORIZED_CATEGORY_COMMUNITY:
+		return TIER_COMMUNITY
+	default:
+		return TIER_LOCAL
+	}
+}
+
+var allPermissions = []string{
+	PERMISSION_TOOL,
+	PERMISSION_MODEL,
+	PERMISSION_NODE,
+	PERMISSION_ENDPOINT,
+	PERMISSION_APP,
+	PERMISSION_STORAGE,
+}
+
+// Requested lists the permissions enabled in permission
+func Requested(permission *plugin_entities.PluginPermissionRequirement) []string {
+	requested := []string{}
+	if permission == nil {
+		return requested
+	}
+	if permission.Tool != nil && permission.Tool.Enabled {
+		requested = append(requested, PERMISSION_TOOL)
+	}
+	if permission.Model != nil && permission.Model.Enabled {
+		requested = append(requested, PERMISSION_MODEL)
+	}
+	if permission.Node != nil && permission.Node.Enabled {
+		requested = append(requested, PERMISSION_NODE)
+	}
+	if permission.Endpoint != nil && permission.Endpoint.Enabled {
+		requested = append(requested, PERMISSION_ENDPOINT)
+	}
+	if permission.App != nil && permission.App.Enabled {
+		requested = append(requested, PERMISSION_APP)
+	}
+	if permission.Storage != nil && permission.Storage.Enabled {
+		requested = append(requested, PERMISSION_STORAGE)
+	}
+	return requested
+}
+
+// CheckResource returns an error if the declaration requests more resources than allowed
+func (p Policy) CheckResource(declaration *plugin_entities.PluginDeclaration) error {
+	if p.MaxMemory > 0 && declaration.Resource.Memory > p.MaxMemory {
+		return fmt.Errorf(
+			"plugin requests %d bytes of memory, exceeds the limit %d of its trust tier",
+			declaration.Resource.Memory,
+			p.MaxMemory,
+		)
+	}
+	return nil
+}
+
+// Apply revokes the permissions not allowed by the policy from the declaration,
+// the backwards invocation checks against the declaration so revoked permissions are never granted
+func (p Policy) Apply(declaration *plugin_entities.PluginDeclaration) []string {
+	permission := declaration.Resource.Permission
+	if permission == nil {
+		return nil
+	}
+
+	revoked := []string{}
+	if p.Permissions != nil {
+		allowed := func(name string) bool {
+			return slices.Contains(p.Permissions, name)
+		}
+		if permission.Tool != nil && permission.Tool.Enabled && !allowed(PERMISSION_TOOL) {
+			permission.Tool = nil
+			revoked = append(revoked, PERMISSION_TOOL)
+		}
+		if permission.Model != nil && permission.Model.Enabled && !allowed(PERMISSION_MODEL) {
+			permission.Model = nil
+			revoked = append(revoked, PERMISSION_MODEL)
+		}
+		if permission.Node != nil && permission.Node.Enabled && !allowed(PERMISSION_NODE) {
+			permission.Node = nil
+			revoked = append(revoked, PERMISSION_NODE)
+		}
+		if permission.Endpoint != nil && permission.Endpoint.Enabled && !allowed(PERMISSION_ENDPOINT) {
+			permission.Endpoint = nil
+			revoked = append(revoked, PERMISSION_ENDPOINT)
+		}
+		if permission.App != nil && permission.App.Enabled && !allowed(PERMISSION_APP) {
+			permission.App = nil
+			revoked = append(revoked, PERMISSION_APP)
+		}
+		if permission.Storage != nil && permission.Storage.Enabled && !allowed(PERMISSION_STORAGE) {
+			permission.Storage = nil
+			revoked = append(revoked, PERMISSION_STORAGE)
+		}
+	}
+
+	if p.MaxStorageSize > 0 && permission.Storage != nil && permission.Storage.Size > p.MaxStorageSize {
+		permission.Storage.Size = p.MaxStorageSize
+	}
+
+	return revoked
+}
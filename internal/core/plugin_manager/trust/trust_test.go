@@ -0,0 +1,119 @@
+package trust
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func newDeclaration() *plugin_entities.PluginDeclaration {
+	declaration := &plugin_entities.PluginDeclaration{}
+	declaration.Resource = plugin_entities.PluginResourceRequirement{
+		Memory: 512 * 1024 * 1024,
+		Permission: &plugin_entities.PluginPermissionRequirement{
+			Tool:    &plugin_entities.PluginPermissionToolRequirement{Enabled: true},
+			Model:   &plugin_entities.PluginPermissionModelRequirement{Enabled: true, LLM: true},
+			App:     &plugin_entities.PluginPermissionAppRequirement{Enabled: true},
+			Storage: &plugin_entities.PluginPermissionStorageRequirement{Enabled: true, Size: 1024 * 1024 * 1024},
+		},
+	}
+	return declaration
+}
+
+func TestLoadPolicies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trust.yaml")
+	if err := os.WriteFile(path, []byte(`
+community:
+  permissions: [tool, model, storage]
+  max_storage_size: 1048576
+  max_memory: 268435456
+  egress_proxy: http://egress:3128
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	policies, err := LoadPolicies(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	community := policies.Of(TIER_COMMUNITY)
+	if community.EgressProxy != "http://egress:3128" || community.MaxMemory != 268435456 {
+		t.Fatalf("unexpected community policy: %+v", community)
+	}
+
+	// tiers missing in the file keep their defaults
+	if policies.Of(TIER_LOCAL).MaxStorageSize != DefaultPolicies()[TIER_LOCAL].MaxStorageSize {
+		t.Fatalf("local policy should keep the default")
+	}
+
+	if err := os.WriteFile(path, []byte("unknown:\n  permissions: [tool]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPolicies(path); err == nil {
+		t.Fatalf("expected error for unknown tier")
+	}
+
+	if err := os.WriteFile(path, []byte("local:\n  permissions: [root]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPolicies(path); err == nil {
+		t.Fatalf("expected error for unknown permission")
+	}
+}
+
+func TestApplyPolicy(t *testing.T) {
+	declaration := newDeclaration()
+	policy := Policy{
+		Permissions:    []string{PERMISSION_TOOL, PERMISSION_STORAGE},
+		MaxStorageSize: 1024 * 1024,
+	}
+
+	revoked := policy.Apply(declaration)
+	if len(revoked) != 2 || revoked[0] != PERMISSION_MODEL || revoked[1] != PERMISSION_APP {
+		t.Fatalf("unexpected revoked permissions: %v", revoked)
+	}
+
+	permission := declaration.Resource.Permission
+	if !permission.AllowInvokeTool() || permission.AllowInvokeLLM() || permission.AllowInvokeApp() {
+		t.Fatalf("unexpected permissions after applying policy")
+	}
+	if permission.Storage.Size != 1024*1024 {
+		t.Fatalf("storage size should be capped, got %d", permission.Storage.Size)
+	}
+
+	// nil permissions grant whatever is requested
+	declaration = newDeclaration()
+	if revoked := (Policy{}).Apply(declaration); len(revoked) != 0 {
+		t.Fatalf("expected nothing revoked, got %v", revoked)
+	}
+}
+
+func TestCheckResource(t *testing.T) {
+	declaration := newDeclaration()
+	if err := (Policy{MaxMemory: 256 * 1024 * 1024}).CheckResource(declaration); err == nil {
+		t.Fatalf("expected memory limit error")
+	}
+	if err := (Policy{}).CheckResource(declaration); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestRequested(t *testing.T) {
+	requested := Requested(newDeclaration().Resource.Permission)
+	expected := []string{PERMISSION_TOOL, PERMISSION_MODEL, PERMISSION_APP, PERMISSION_STORAGE}
+	if len(requested) != len(expected) {
+		t.Fatalf("unexpected requested permissions: %v", requested)
+	}
+	for i := range expected {
+		if requested[i] != expected[i] {
+			t.Fatalf("unexpected requested permissions: %v", requested)
+		}
+	}
+
+	if len(Requested(nil)) != 0 {
+		t.Fatalf("nil permission requests nothing")
+	}
+}
@@ -0,0 +1,36 @@
+package plugin_manager
+
+import (
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/trust"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// applyTrustPolicy revokes the permissions not allowed by the trust tier and returns its policy
+func (p *PluginManager) applyTrustPolicy(
+	tier trust.Tier,
+	declaration *plugin_entities.PluginDeclaration,
+) (trust.Policy, error) {
+	policy := p.trustPolicies.Of(tier)
+	if err := policy.CheckResource(declaration); err != nil {
+		return policy, err
+	}
+
+	if revoked := policy.Apply(declaration); len(revoked) > 0 {
+		log.Warn(
+			"permissions %s of plugin %s are revoked by the policy of trust tier %s",
+			strings.Join(revoked, ", "),
+			declaration.Identity(),
+			tier,
+		)
+	}
+
+	return policy, nil
+}
+
+// TrustPolicy returns the policy plugins of the trust tier are launched with
+func (p *PluginManager) TrustPolicy(tier trust.Tier) trust.Policy {
+	return p.trustPolicies.Of(tier)
+}
@@ -0,0 +1,55 @@
+package plugin_manager
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+var upgradeHealthCheckPoll = time.Second
+
+// CheckRuntimeHealth watches the local runtime of a plugin for period, it fails if the runtime stops,
+// restarts or hangs in the meantime or is not active at the end, runtimes of other platforms are
+// checked when they are launched and always pass
+func (p *PluginManager) CheckRuntimeHealth(
+	identity plugin_entities.PluginUniqueIdentifier,
+	period time.Duration,
+) error {
+	if p.config.Platform != app.PLATFORM_LOCAL || period <= 0 {
+		return nil
+	}
+
+	lifetime, ok := p.m.Load(identity.String())
+	if !ok {
+		return errors.New("runtime is not running")
+	}
+	initial := lifetime.RuntimeState()
+
+	deadline := time.Now().Add(period)
+	for {
+		time.Sleep(min(upgradeHealthCheckPoll, time.Until(deadline)))
+
+		current, ok := p.m.Load(identity.String())
+		if !ok || current != lifetime {
+			return errors.New("runtime stopped during the health check")
+		}
+
+		state := current.RuntimeState()
+		if state.Hangs > initial.Hangs {
+			return errors.New("runtime stopped answering pings during the health check")
+		}
+		if state.Restarts > initial.Restarts {
+			return fmt.Errorf("runtime restarted %d times during the health check", state.Restarts-initial.Restarts)
+		}
+
+		if !time.Now().Before(deadline) {
+			if state.Status != plugin_entities.PLUGIN_RUNTIME_STATUS_ACTIVE {
+				return fmt.Errorf("runtime is %s after the health check", state.Status)
+			}
+			return nil
+		}
+	}
+}
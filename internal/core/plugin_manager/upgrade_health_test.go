@@ -0,0 +1,72 @@
+package plugin_manager
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// restartingPlugin reports restarts made by another goroutine
+type restartingPlugin struct {
+	*fakePlugin
+	restarts atomic.Int32
+}
+
+func (r *restartingPlugin) RuntimeState() plugin_entities.PluginRuntimeState {
+	state := r.fakePlugin.RuntimeState()
+	state.Restarts = int(r.restarts.Load())
+	return state
+}
+
+func TestCheckRuntimeHealth(t *testing.T) {
+	upgradeHealthCheckPoll = 10 * time.Millisecond
+	t.Cleanup(func() { upgradeHealthCheckPoll = time.Second })
+
+	manager := &PluginManager{config: &app.Config{Platform: app.PLATFORM_LOCAL}}
+	identity := plugin_entities.PluginUniqueIdentifier("langgenius/openai:0.0.2@1234")
+
+	if err := manager.CheckRuntimeHealth(identity, 50*time.Millisecond); err == nil {
+		t.Fatal("expected a runtime which is not running to fail")
+	}
+
+	runtime := getRandomPluginRuntime()
+	runtime.State.Status = plugin_entities.PLUGIN_RUNTIME_STATUS_ACTIVE
+	manager.m.Store(identity.String(), runtime)
+	if err := manager.CheckRuntimeHealth(identity, 50*time.Millisecond); err != nil {
+		t.Fatalf("expected an active runtime to pass: %v", err)
+	}
+
+	// crash the runtime halfway through the check
+	restarting := &restartingPlugin{fakePlugin: runtime}
+	manager.m.Store(identity.String(), restarting)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		restarting.restarts.Add(1)
+	}()
+	if err := manager.CheckRuntimeHealth(identity, 100*time.Millisecond); err == nil {
+		t.Fatal("expected a restarted runtime to fail")
+	}
+
+	// a new runtime replacing the checked one means it stopped
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		manager.m.Store(identity.String(), runtime)
+	}()
+	if err := manager.CheckRuntimeHealth(identity, 100*time.Millisecond); err == nil {
+		t.Fatal("expected a replaced runtime to fail")
+	}
+
+	runtime.State.Status = plugin_entities.PLUGIN_RUNTIME_STATUS_RESTARTING
+	if err := manager.CheckRuntimeHealth(identity, 20*time.Millisecond); err == nil {
+		t.Fatal("expected an inactive runtime to fail")
+	}
+
+	// serverless runtimes are checked when they are launched
+	manager.config.Platform = app.PLATFORM_SERVERLESS
+	if err := manager.CheckRuntimeHealth(identity, 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,217 @@
+package vulnerability_scanner
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ECOSYSTEM_PYPI = "PyPI"
+	ECOSYSTEM_NPM  = "npm"
+)
+
+// Dependency is a package pinned to an exact version
+type Dependency struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+}
+
+var (
+	requirementPattern = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(\[[^\]]*\])?\s*(.*)$`)
+	pinnedPattern      = regexp.MustCompile(`^===?\s*([A-Za-z0-9.+!_-]+)$`)
+	pypiSeparator      = regexp.MustCompile(`[-_.]+`)
+)
+
+// Dependencies collects the dependencies of the package from requirements.txt, package-lock.json
+// and pnpm-lock.yaml, requirements without an exact version can't be checked and are returned as unpinned
+func Dependencies(pluginDecoder decoder.PluginDecoder) ([]Dependency, []string, error) {
+	dependencies := []Dependency{}
+	unpinned := []string{}
+
+	requirements, err := readOptional(pluginDecoder, "requirements.txt")
+	if err != nil {
+		return nil, nil, err
+	}
+	if requirements != nil {
+		pinned, names := parseRequirements(string(requirements))
+		dependencies = append(dependencies, pinned...)
+		unpinned = append(unpinned, names...)
+	}
+
+	if lock, err := readOptional(pluginDecoder, "package-lock.json"); err != nil {
+		return nil, nil, err
+	} else if lock != nil {
+		pinned, err := parsePackageLock(lock)
+		if err != nil {
+			return nil, nil, errors.Join(err, errors.New("failed to parse package-lock.json"))
+		}
+		dependencies = append(dependencies, pinned...)
+	} else if lock, err := readOptional(pluginDecoder, "pnpm-lock.yaml"); err != nil {
+		return nil, nil, err
+	} else if lock != nil {
+		pinned, err := parsePnpmLock(lock)
+		if err != nil {
+			return nil, nil, errors.Join(err, errors.New("failed to parse pnpm-lock.yaml"))
+		}
+		dependencies = append(dependencies, pinned...)
+	} else if manifest, err := readOptional(pluginDecoder, "package.json"); err != nil {
+		return nil, nil, err
+	} else if manifest != nil {
+		names, err := parsePackageJson(manifest)
+		if err != nil {
+			return nil, nil, errors.Join(err, errors.New("failed to parse package.json"))
+		}
+		unpinned = append(unpinned, names...)
+	}
+
+	return dedupe(dependencies), unpinned, nil
+}
+
+func readOptional(pluginDecoder decoder.PluginDecoder, filename string) ([]byte, error) {
+	content, err := pluginDecoder.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return content, err
+}
+
+func parseRequirements(requirements string) ([]Dependency, []string) {
+	dependencies := []Dependency{}
+	unpinned := []string{}
+
+	for _, line := range strings.Split(requirements, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		line, _, _ = strings.Cut(line, ";")
+		line = strings.TrimSpace(line)
+		// options like -r, --index-url and urls are not packages
+		if line == "" || strings.HasPrefix(line, "-") || strings.Contains(line, "://") {
+			continue
+		}
+
+		match := requirementPattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+
+		name := strings.ToLower(pypiSeparator.ReplaceAllString(match[1], "-"))
+		version := pinnedPattern.FindStringSubmatch(strings.TrimSpace(match[3]))
+		if version == nil || strings.Contains(version[1], "*") {
+			unpinned = append(unpinned, name)
+			continue
+		}
+
+		dependencies = append(dependencies, Dependency{
+			Ecosystem: ECOSYSTEM_PYPI,
+			Name:      name,
+			Version:   version[1],
+		})
+	}
+
+	return dependencies, unpinned
+}
+
+func parsePackageLock(content []byte) ([]Dependency, error) {
+	var lock struct {
+		Packages map[string]struct {
+			Version string `json:"version"`
+			Dev     bool   `json:"dev"`
+			Link    bool   `json:"link"`
+		} `json:"packages"`
+	}
+	if err := json.Unmarshal(content, &lock); err != nil {
+		return nil, err
+	}
+
+	dependencies := []Dependency{}
+	for path, pkg := range lock.Packages {
+		// the root package has an empty path, dev dependencies are not installed
+		index := strings.LastIndex(path, "node_modules/")
+		if index < 0 || pkg.Dev || pkg.Link || pkg.Version == "" {
+			continue
+		}
+		dependencies = append(dependencies, Dependency{
+			Ecosystem: ECOSYSTEM_NPM,
+			Name:      path[index+len("node_modules/"):],
+			Version:   pkg.Version,
+		})
+	}
+
+	return dependencies, nil
+}
+
+func parsePnpmLock(content []byte) ([]Dependency, error) {
+	var lock struct {
+		Packages map[string]struct {
+			Dev bool `yaml:"dev"`
+		} `yaml:"packages"`
+	}
+	if err := yaml.Unmarshal(content, &lock); err != nil {
+		return nil, err
+	}
+
+	dependencies := []Dependency{}
+	for key, pkg := range lock.Packages {
+		if pkg.Dev {
+			continue
+		}
+		// /name@version in lockfile v6, name@version(peer@version) in v9
+		key = strings.TrimPrefix(key, "/")
+		key, _, _ = strings.Cut(key, "(")
+		index := strings.LastIndex(key, "@")
+		if index <= 0 {
+			continue
+		}
+		dependencies = append(dependencies, Dependency{
+			Ecosystem: ECOSYSTEM_NPM,
+			Name:      key[:index],
+			Version:   key[index+1:],
+		})
+	}
+
+	return dependencies, nil
+}
+
+func parsePackageJson(content []byte) ([]string, error) {
+	var manifest struct {
+		Dependencies map[string]string `json:"dependencies"`
+	}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(manifest.Dependencies))
+	for name := range manifest.Dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func dedupe(dependencies []Dependency) []Dependency {
+	seen := map[Dependency]bool{}
+	result := make([]Dependency, 0, len(dependencies))
+	for _, dependency := range dependencies {
+		if !seen[dependency] {
+			seen[dependency] = true
+			result = append(result, dependency)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Ecosystem != result[j].Ecosystem {
+			return result[i].Ecosystem < result[j].Ecosystem
+		}
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].Version < result[j].Version
+	})
+	return result
+}
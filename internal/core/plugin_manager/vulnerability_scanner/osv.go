@@ -0,0 +1,133 @@
+package vulnerability_scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	SCANNER_OSV = "osv"
+
+	// osv.dev accepts at most 1000 queries in a batch
+	OSV_BATCH_SIZE = 1000
+)
+
+// OSVClient looks up advisories in the OSV database, see https://google.github.io/osv.dev/api/
+type OSVClient struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewOSVClient(url string, timeout time.Duration) *OSVClient {
+	return &OSVClient{
+		URL:    strings.TrimSuffix(url, "/"),
+		Client: &http.Client{Timeout: timeout},
+	}
+}
+
+type osvQuery struct {
+	Package struct {
+		Name      string `json:"name"`
+		Ecosystem string `json:"ecosystem"`
+	} `json:"package"`
+	Version string `json:"version"`
+}
+
+type osvBatchResponse struct {
+	Results []struct {
+		Vulns []struct {
+			ID string `json:"id"`
+		} `json:"vulns"`
+	} `json:"results"`
+}
+
+type osvVulnerability struct {
+	ID               string   `json:"id"`
+	Summary          string   `json:"summary"`
+	Details          string   `json:"details"`
+	Aliases          []string `json:"aliases"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+// QueryBatch returns the ids of the advisories affecting each dependency, in the order of dependencies
+func (c *OSVClient) QueryBatch(ctx context.Context, dependencies []Dependency) ([][]string, error) {
+	ids := make([][]string, 0, len(dependencies))
+
+	for start := 0; start < len(dependencies); start += OSV_BATCH_SIZE {
+		batch := dependencies[start:min(start+OSV_BATCH_SIZE, len(dependencies))]
+		queries := make([]osvQuery, len(batch))
+		for i, dependency := range batch {
+			queries[i].Package.Name = dependency.Name
+			queries[i].Package.Ecosystem = dependency.Ecosystem
+			queries[i].Version = dependency.Version
+		}
+
+		var response osvBatchResponse
+		if err := c.do(ctx, http.MethodPost, "/v1/querybatch", map[string]any{"queries": queries}, &response); err != nil {
+			return nil, err
+		}
+		if len(response.Results) != len(batch) {
+			return nil, fmt.Errorf("osv returned %d results for %d queries", len(response.Results), len(batch))
+		}
+
+		for _, result := range response.Results {
+			vulns := make([]string, 0, len(result.Vulns))
+			for _, vuln := range result.Vulns {
+				vulns = append(vulns, vuln.ID)
+			}
+			ids = append(ids, vulns)
+		}
+	}
+
+	return ids, nil
+}
+
+// Vulnerability fetches the details of an advisory, batch queries only return ids
+func (c *OSVClient) Vulnerability(ctx context.Context, id string) (*osvVulnerability, error) {
+	var vulnerability osvVulnerability
+	if err := c.do(ctx, http.MethodGet, "/v1/vulns/"+url.PathEscape(id), nil, &vulnerability); err != nil {
+		return nil, err
+	}
+	return &vulnerability, nil
+}
+
+func (c *OSVClient) do(ctx context.Context, method string, path string, payload any, result any) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("osv responded with status code %d: %s", resp.StatusCode, string(message))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
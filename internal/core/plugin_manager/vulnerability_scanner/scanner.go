@@ -0,0 +1,158 @@
+package vulnerability_scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+type Policy string
+
+const (
+	POLICY_OFF   Policy = "off"
+	POLICY_WARN  Policy = "warn"
+	POLICY_BLOCK Policy = "block"
+)
+
+const (
+	SEVERITY_UNKNOWN  = "UNKNOWN"
+	SEVERITY_LOW      = "LOW"
+	SEVERITY_MEDIUM   = "MEDIUM"
+	SEVERITY_HIGH     = "HIGH"
+	SEVERITY_CRITICAL = "CRITICAL"
+)
+
+// ErrUnavailable wraps failures to reach the advisory database, they tell nothing about the package
+var ErrUnavailable = errors.New("vulnerability database is unavailable")
+
+var severityRanks = map[string]int{
+	SEVERITY_UNKNOWN:  0,
+	SEVERITY_LOW:      1,
+	SEVERITY_MEDIUM:   2,
+	SEVERITY_HIGH:     3,
+	SEVERITY_CRITICAL: 4,
+}
+
+// NormalizeSeverity maps the severities of advisory databases to ours, github calls medium moderate
+func NormalizeSeverity(severity string) string {
+	severity = strings.ToUpper(strings.TrimSpace(severity))
+	if severity == "MODERATE" {
+		return SEVERITY_MEDIUM
+	}
+	if _, ok := severityRanks[severity]; ok {
+		return severity
+	}
+	return SEVERITY_UNKNOWN
+}
+
+// AtLeast reports whether severity is threshold or worse, unknown severities never reach a threshold
+func AtLeast(severity string, threshold string) bool {
+	severity, threshold = NormalizeSeverity(severity), NormalizeSeverity(threshold)
+	if severity == SEVERITY_UNKNOWN {
+		return false
+	}
+	return severityRanks[severity] >= severityRanks[threshold]
+}
+
+// Scan checks the dependencies of the package against the advisory database
+func Scan(
+	ctx context.Context,
+	client *OSVClient,
+	pluginDecoder decoder.PluginDecoder,
+) (*models.PluginSecurityReport, error) {
+	dependencies, unpinned, err := Dependencies(pluginDecoder)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanDependencies(ctx, client, dependencies, unpinned)
+}
+
+func scanDependencies(
+	ctx context.Context,
+	client *OSVClient,
+	dependencies []Dependency,
+	unpinned []string,
+) (*models.PluginSecurityReport, error) {
+	report := &models.PluginSecurityReport{
+		Scanner:         SCANNER_OSV,
+		Dependencies:    len(dependencies),
+		Unpinned:        unpinned,
+		Vulnerabilities: []models.PluginVulnerability{},
+		ScannedAt:       time.Now(),
+	}
+	if len(dependencies) == 0 {
+		return report, nil
+	}
+
+	ids, err := client.QueryBatch(ctx, dependencies)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	details := map[string]*osvVulnerability{}
+	for i, dependency := range dependencies {
+		for _, id := range ids[i] {
+			vulnerability, ok := details[id]
+			if !ok {
+				vulnerability, err = client.Vulnerability(ctx, id)
+				if err != nil {
+					return nil, fmt.Errorf("%w: failed to fetch advisory %s: %w", ErrUnavailable, id, err)
+				}
+				details[id] = vulnerability
+			}
+
+			summary := vulnerability.Summary
+			if summary == "" {
+				summary, _, _ = strings.Cut(vulnerability.Details, "\n")
+			}
+			report.Vulnerabilities = append(report.Vulnerabilities, models.PluginVulnerability{
+				ID:        vulnerability.ID,
+				Aliases:   vulnerability.Aliases,
+				Summary:   summary,
+				Severity:  NormalizeSeverity(vulnerability.DatabaseSpecific.Severity),
+				Ecosystem: dependency.Ecosystem,
+				Package:   dependency.Name,
+				Version:   dependency.Version,
+			})
+		}
+	}
+
+	mergeAliasSeverities(report.Vulnerabilities)
+	return report, nil
+}
+
+// mergeAliasSeverities gives all records of the same advisory the highest severity among them,
+// pypa advisories carry no severity while their github aliases do
+func mergeAliasSeverities(vulnerabilities []models.PluginVulnerability) {
+	for i := range vulnerabilities {
+		for j := range vulnerabilities {
+			a, b := &vulnerabilities[i], &vulnerabilities[j]
+			if a.Package != b.Package || a.Version != b.Version || !slices.Contains(b.Aliases, a.ID) {
+				continue
+			}
+			if severityRanks[a.Severity] > severityRanks[b.Severity] {
+				b.Severity = a.Severity
+			} else {
+				a.Severity = b.Severity
+			}
+		}
+	}
+}
+
+// Blocking returns the vulnerabilities reaching the threshold
+func Blocking(vulnerabilities []models.PluginVulnerability, threshold string) []models.PluginVulnerability {
+	blocking := []models.PluginVulnerability{}
+	for _, vulnerability := range vulnerabilities {
+		if AtLeast(vulnerability.Severity, threshold) {
+			blocking = append(blocking, vulnerability)
+		}
+	}
+	return blocking
+}
@@ -0,0 +1,137 @@
+package vulnerability_scanner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRequirements(t *testing.T) {
+	dependencies, unpinned := parseRequirements(`
+# comment
+--index-url https://pypi.org/simple
+dify_plugin==0.2.1
+Requests[socks] == 2.19.0 ; python_version >= "3.8"
+pyyaml>=6.0
+flask==2.*
+`)
+	assert.Equal(t, []Dependency{
+		{Ecosystem: ECOSYSTEM_PYPI, Name: "dify-plugin", Version: "0.2.1"},
+		{Ecosystem: ECOSYSTEM_PYPI, Name: "requests", Version: "2.19.0"},
+	}, dependencies)
+	assert.Equal(t, []string{"pyyaml", "flask"}, unpinned)
+}
+
+func TestParseLockfiles(t *testing.T) {
+	dependencies, err := parsePackageLock([]byte(`{"packages": {
+		"": {"name": "plugin"},
+		"node_modules/lodash": {"version": "4.17.20"},
+		"node_modules/a/node_modules/@scope/b": {"version": "1.0.0"},
+		"node_modules/jest": {"version": "29.0.0", "dev": true}
+	}}`))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Dependency{
+		{Ecosystem: ECOSYSTEM_NPM, Name: "lodash", Version: "4.17.20"},
+		{Ecosystem: ECOSYSTEM_NPM, Name: "@scope/b", Version: "1.0.0"},
+	}, dependencies)
+
+	dependencies, err = parsePnpmLock([]byte(`
+lockfileVersion: '9.0'
+packages:
+  lodash@4.17.20:
+    resolution: {integrity: sha512-x}
+  '@scope/b@1.0.0(react@18.0.0)':
+    resolution: {integrity: sha512-y}
+`))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Dependency{
+		{Ecosystem: ECOSYSTEM_NPM, Name: "lodash", Version: "4.17.20"},
+		{Ecosystem: ECOSYSTEM_NPM, Name: "@scope/b", Version: "1.0.0"},
+	}, dependencies)
+}
+
+func TestScanDependencies(t *testing.T) {
+	advisories := map[string]map[string]any{
+		"PYSEC-2018-28": {"id": "PYSEC-2018-28", "details": "requests leaks credentials\nmore", "aliases": []string{"GHSA-x84v-xcm2-53pg"}},
+		"GHSA-x84v-xcm2-53pg": {
+			"id":                "GHSA-x84v-xcm2-53pg",
+			"summary":           "Insufficiently protected credentials in requests",
+			"aliases":           []string{"PYSEC-2018-28"},
+			"database_specific": map[string]any{"severity": "HIGH"},
+		},
+		"GHSA-low": {"id": "GHSA-low", "summary": "minor", "database_specific": map[string]any{"severity": "MODERATE"}},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/querybatch" {
+			var request struct {
+				Queries []osvQuery `json:"queries"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+
+			results := []map[string]any{}
+			for _, query := range request.Queries {
+				vulns := []map[string]string{}
+				switch query.Package.Name {
+				case "requests":
+					vulns = append(vulns, map[string]string{"id": "PYSEC-2018-28"}, map[string]string{"id": "GHSA-x84v-xcm2-53pg"})
+				case "lodash":
+					vulns = append(vulns, map[string]string{"id": "GHSA-low"})
+				}
+				results = append(results, map[string]any{"vulns": vulns})
+			}
+			json.NewEncoder(w).Encode(map[string]any{"results": results})
+			return
+		}
+
+		advisory, ok := advisories[r.URL.Path[len("/v1/vulns/"):]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(advisory)
+	}))
+	defer server.Close()
+
+	client := NewOSVClient(server.URL+"/", time.Second)
+	report, err := scanDependencies(context.Background(), client, []Dependency{
+		{Ecosystem: ECOSYSTEM_PYPI, Name: "dify-plugin", Version: "0.2.1"},
+		{Ecosystem: ECOSYSTEM_PYPI, Name: "requests", Version: "2.19.0"},
+		{Ecosystem: ECOSYSTEM_NPM, Name: "lodash", Version: "4.17.20"},
+	}, []string{"pyyaml"})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, report.Dependencies)
+	assert.Equal(t, []string{"pyyaml"}, report.Unpinned)
+	require.Len(t, report.Vulnerabilities, 3)
+
+	// the pypa record has no severity of its own and takes the one of its github alias
+	assert.Equal(t, "requests leaks credentials", report.Vulnerabilities[0].Summary)
+	assert.Equal(t, SEVERITY_HIGH, report.Vulnerabilities[0].Severity)
+	assert.Equal(t, SEVERITY_HIGH, report.Vulnerabilities[1].Severity)
+	assert.Equal(t, SEVERITY_MEDIUM, report.Vulnerabilities[2].Severity)
+
+	assert.Len(t, Blocking(report.Vulnerabilities, "high"), 2)
+	assert.Len(t, Blocking(report.Vulnerabilities, "medium"), 3)
+	assert.Empty(t, Blocking(report.Vulnerabilities, "critical"))
+	assert.False(t, AtLeast(SEVERITY_UNKNOWN, "low"))
+}
+
+func TestScanDependenciesUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "maintenance", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewOSVClient(server.URL+"/", time.Second)
+	_, err := scanDependencies(context.Background(), client, []Dependency{
+		{Ecosystem: ECOSYSTEM_PYPI, Name: "requests", Version: "2.19.0"},
+	}, nil)
+	assert.ErrorIs(t, err, ErrUnavailable)
+}
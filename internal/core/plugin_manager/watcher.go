@@ -6,6 +6,7 @@ import (
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/debugging_runtime"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/local_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/trust"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
@@ -20,6 +21,7 @@ func (p *PluginManager) startLocalWatcher(config *app.Config) {
 		for range time.NewTicker(time.Second * 30).C {
 			p.handleNewLocalPlugins(config)
 			p.removeUninstalledLocalPlugins()
+			p.releaseUnassignedLocalPlugins()
 		}
 	}()
 }
@@ -48,6 +50,11 @@ func (p *PluginManager) startRemoteWatcher(config *app.Config) {
 					log.Error("get remote plugin identity failed: %s", err.Error())
 					return
 				}
+				if _, err := p.applyTrustPolicy(trust.TIER_LOCAL, rpr.Configuration()); err != nil {
+					log.Error("remote plugin %s rejected: %s", identity.String(), err.Error())
+					rpr.Stop()
+					return
+				}
 				p.m.Store(identity.String(), rpr)
 				routine.Submit(map[string]string{
 					"module":    "plugin_manager",
@@ -69,6 +76,10 @@ func (p *PluginManager) startRemoteWatcher(config *app.Config) {
 }
 
 func (p *PluginManager) handleNewLocalPlugins(config *app.Config) {
+	if p.shuttingDown.Load() {
+		return
+	}
+
 	// walk through all plugins
 	plugins, err := p.installedBucket.List()
 	if err != nil {
@@ -82,7 +93,7 @@ func (p *PluginManager) handleNewLocalPlugins(config *app.Config) {
 
 	for _, plugin := range plugins {
 		_, exist := p.m.Load(plugin.String())
-		if exist {
+		if exist || !p.isLocalPluginAssigned(plugin) {
 			continue
 		}
 
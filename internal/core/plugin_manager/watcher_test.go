@@ -10,6 +10,7 @@ import (
 	"github.com/langgenius/dify-cloud-kit/oss/factory"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/basic_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/debugging_runtime"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
@@ -105,6 +106,10 @@ func (f *fakeRemotePluginServer) Stop() error {
 	return nil
 }
 
+func (f *fakeRemotePluginServer) Connections() []debugging_runtime.DebuggingConnection {
+	return nil
+}
+
 func (f *fakeRemotePluginServer) Wrap(fn func(plugin_entities.PluginFullDuplexLifetime)) {
 	fn(getRandomPluginRuntime())
 }
@@ -0,0 +1,261 @@
+// Package plugin_registry is a private registry of plugin packages, organizations publish versions of
+// their plugins to it and daemons sharing the storage install them without the public marketplace
+package plugin_registry
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	version "github.com/hashicorp/go-version"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/oss"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotFound             = errors.New("plugin not found in the registry")
+	ErrVersionExists        = errors.New("the version has been published with a different package")
+	ErrOrganizationMismatch = errors.New("the author of the plugin is not the organization")
+	ErrSignatureRequired    = errors.New("the registry only accepts signed packages")
+)
+
+type Config struct {
+	// Path is the prefix of published packages in the storage
+	Path string
+	// RequireSignature rejects packages not signed by the official key or a third-party key
+	RequireSignature bool
+	Verification     *decoder.ThirdPartySignatureVerificationConfig
+	MaxSize          int64
+}
+
+type Registry struct {
+	storage oss.StreamingOSS
+	config  Config
+}
+
+var registry *Registry
+
+func Init(storage oss.StreamingOSS, config Config) {
+	registry = NewRegistry(storage, config)
+}
+
+func Enabled() bool {
+	return registry != nil
+}
+
+func GetRegistry() *Registry {
+	return registry
+}
+
+func NewRegistry(storage oss.StreamingOSS, config Config) *Registry {
+	return &Registry{storage: storage, config: config}
+}
+
+func (r *Registry) key(pluginUniqueIdentifier string) string {
+	return path.Join(r.config.Path, pluginUniqueIdentifier)
+}
+
+// Publish adds a version of a plugin, publishing the same package again is a no-op
+func (r *Registry) Publish(organization string, pkg []byte) (*models.RegistryPlugin, error) {
+	if r.config.MaxSize > 0 && int64(len(pkg)) > r.config.MaxSize {
+		return nil, errors.New("file size exceeds the maximum limit")
+	}
+
+	pluginDecoder, err := decoder.NewZipPluginDecoderWithThirdPartySignatureVerificationConfig(pkg, r.config.Verification)
+	if err != nil {
+		return nil, err
+	}
+	if r.config.RequireSignature && !pluginDecoder.Verified() {
+		return nil, ErrSignatureRequired
+	}
+
+	manifest, err := pluginDecoder.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Author != organization {
+		return nil, ErrOrganizationMismatch
+	}
+
+	identifier, err := pluginDecoder.UniqueIdentity()
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := db.GetOne[models.RegistryPlugin](
+		db.Equal("organization", organization),
+		db.Equal("name", manifest.Name),
+		db.Equal("version", manifest.Version.String()),
+	)
+	if err == nil {
+		if existing.PluginUniqueIdentifier != identifier.String() {
+			return nil, ErrVersionExists
+		}
+		return &existing, nil
+	} else if err != db.ErrDatabaseNotFound {
+		return nil, err
+	}
+
+	// the package is saved first, a record never points to a missing package
+	if err := r.storage.Save(r.key(identifier.String()), pkg); err != nil {
+		return nil, err
+	}
+
+	plugin := models.RegistryPlugin{
+		Organization:           organization,
+		Name:                   manifest.Name,
+		Version:                manifest.Version.String(),
+		PluginUniqueIdentifier: identifier.String(),
+		Label:                  manifest.Label,
+		Description:            manifest.Description,
+		Tags:                   manifest.Tags,
+		Size:                   int64(len(pkg)),
+		Verified:               pluginDecoder.Verified(),
+	}
+	if err := addVersion(&plugin); err != nil {
+		return nil, err
+	}
+
+	return &plugin, nil
+}
+
+// addVersion creates the record of a version and moves the latest mark to it if it's newer
+func addVersion(plugin *models.RegistryPlugin) error {
+	plugin.Keywords = keywords(plugin)
+
+	return db.WithTransaction(func(tx *gorm.DB) error {
+		current, err := db.GetOne[models.RegistryPlugin](
+			db.WithTransactionContext(tx),
+			db.Equal("organization", plugin.Organization),
+			db.Equal("name", plugin.Name),
+			db.Equal("latest", true),
+			db.WLock(),
+		)
+		if err == db.ErrDatabaseNotFound {
+			plugin.Latest = true
+		} else if err != nil {
+			return err
+		} else if newer(plugin.Version, current.Version) {
+			current.Latest = false
+			if err := db.Update(&current, tx); err != nil {
+				return err
+			}
+			plugin.Latest = true
+		}
+
+		return db.Create(plugin, tx)
+	})
+}
+
+func keywords(plugin *models.RegistryPlugin) string {
+	fields := []string{
+		plugin.Name,
+		plugin.Label.EnUS, plugin.Label.ZhHans, plugin.Label.JaJp, plugin.Label.PtBr,
+		plugin.Description.EnUS, plugin.Description.ZhHans, plugin.Description.JaJp, plugin.Description.PtBr,
+	}
+	for _, tag := range plugin.Tags {
+		fields = append(fields, string(tag))
+	}
+	return strings.ToLower(strings.Join(fields, "\n"))
+}
+
+// Search returns the latest version of each plugin of the organization matching query, all plugins if
+// query is empty, and the number of matched plugins
+func (r *Registry) Search(organization string, query string, page int, pageSize int) ([]models.RegistryPlugin, int, error) {
+	conditions := []db.GenericQuery{
+		db.Equal("organization", organization),
+		db.Equal("latest", true),
+	}
+	if query = strings.ToLower(strings.TrimSpace(query)); query != "" {
+		// ! escapes the wildcards, backslash is not the default escape character of every database
+		pattern := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(query)
+		conditions = append(conditions, db.WhereSQL("keywords LIKE ? ESCAPE '!'", "%"+pattern+"%"))
+	}
+
+	total, err := db.GetCount[models.RegistryPlugin](conditions...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	plugins, err := db.GetAll[models.RegistryPlugin](append(
+		conditions,
+		db.OrderBy("name", false),
+		db.Page(page, pageSize),
+	)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return plugins, int(total), nil
+}
+
+// Versions returns all published versions of a plugin, newest first
+func (r *Registry) Versions(organization string, name string) ([]models.RegistryPlugin, error) {
+	versions, err := db.GetAll[models.RegistryPlugin](
+		db.Equal("organization", organization),
+		db.Equal("name", name),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, ErrNotFound
+	}
+
+	sort.Slice(versions, func(i, j int) bool { return newer(versions[i].Version, versions[j].Version) })
+	return versions, nil
+}
+
+// Get returns a published version, the latest one if version is empty
+func (r *Registry) Get(organization string, name string, version string) (*models.RegistryPlugin, error) {
+	if version == "" {
+		versions, err := r.Versions(organization, name)
+		if err != nil {
+			return nil, err
+		}
+		return &versions[0], nil
+	}
+
+	plugin, err := db.GetOne[models.RegistryPlugin](
+		db.Equal("organization", organization),
+		db.Equal("name", name),
+		db.Equal("version", version),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &plugin, nil
+}
+
+// Load returns the package of a published plugin
+func (r *Registry) Load(pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier) ([]byte, error) {
+	if _, err := db.GetOne[models.RegistryPlugin](
+		db.Equal("plugin_unique_identifier", pluginUniqueIdentifier.String()),
+	); err == db.ErrDatabaseNotFound {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	pkg, err := r.storage.Load(r.key(pluginUniqueIdentifier.String()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load package of %s: %w", pluginUniqueIdentifier, err)
+	}
+	return pkg, nil
+}
+
+func newer(a string, b string) bool {
+	va, errA := version.NewVersion(a)
+	vb, errB := version.NewVersion(b)
+	if errA != nil || errB != nil {
+		return a > b
+	}
+	return va.GreaterThan(vb)
+}
@@ -0,0 +1,100 @@
+package plugin_registry
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/oss"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	config := &app.Config{DBType: "sqlite", DBSqlitePath: filepath.Join(t.TempDir(), "registry.db")}
+	config.SetDefault()
+	db.Init(config)
+	t.Cleanup(db.Close)
+
+	storage, err := oss.NewLocalStorage(cloudoss.OSSArgs{Local: &cloudoss.Local{Path: t.TempDir()}})
+	require.NoError(t, err)
+
+	pkg, err := os.ReadFile("testdata/dummy_plugin.difypkg")
+	require.NoError(t, err)
+
+	// the test package is not signed
+	_, err = NewRegistry(storage, Config{Path: "plugin_registry", RequireSignature: true}).Publish("test", pkg)
+	assert.ErrorIs(t, err, ErrSignatureRequired)
+
+	registry := NewRegistry(storage, Config{Path: "plugin_registry"})
+	_, err = registry.Publish("langgenius", pkg)
+	assert.ErrorIs(t, err, ErrOrganizationMismatch)
+
+	published, err := registry.Publish("test", pkg)
+	require.NoError(t, err)
+	assert.Equal(t, "test", published.Name)
+	assert.Equal(t, "0.0.1", published.Version)
+
+	// publishing the same package again is fine, a different package of the same version is not
+	again, err := registry.Publish("test", pkg)
+	require.NoError(t, err)
+	assert.Equal(t, published.ID, again.ID)
+
+	identifier, err := plugin_entities.NewPluginUniqueIdentifier(published.PluginUniqueIdentifier)
+	require.NoError(t, err)
+	content, err := registry.Load(identifier)
+	require.NoError(t, err)
+	assert.Equal(t, pkg, content)
+
+	for _, plugin := range []models.RegistryPlugin{
+		{Organization: "test", Name: "test", Version: "0.0.10", PluginUniqueIdentifier: "test/test:0.0.10@a"},
+		{Organization: "test", Name: "test", Version: "0.0.9", PluginUniqueIdentifier: "test/test:0.0.9@d"},
+		{Organization: "test", Name: "search", Version: "1.0.0", PluginUniqueIdentifier: "test/search:1.0.0@b",
+			Label: plugin_entities.I18nObject{EnUS: "Web Search"}},
+		{Organization: "test", Name: "percent", Version: "1.0.0", PluginUniqueIdentifier: "test/percent:1.0.0@e",
+			Label: plugin_entities.I18nObject{EnUS: "100% off"}},
+		{Organization: "other", Name: "test", Version: "2.0.0", PluginUniqueIdentifier: "other/test:2.0.0@c"},
+	} {
+		require.NoError(t, addVersion(&plugin))
+	}
+
+	versions, err := registry.Versions("test", "test")
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	assert.Equal(t, "0.0.10", versions[0].Version)
+
+	latest, err := registry.Get("test", "test", "")
+	require.NoError(t, err)
+	assert.Equal(t, "0.0.10", latest.Version)
+
+	plugins, total, err := registry.Search("test", "", 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, "search", plugins[1].Name)
+	assert.Equal(t, "0.0.10", plugins[2].Version)
+
+	plugins, total, err = registry.Search("test", "web", 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, "search", plugins[0].Name)
+
+	// wildcards in queries are matched literally
+	plugins, total, err = registry.Search("test", "%", 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, "percent", plugins[0].Name)
+
+	plugins, total, err = registry.Search("test", "", 3, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	require.Len(t, plugins, 1)
+	assert.Equal(t, "test", plugins[0].Name)
+
+	_, err = registry.Versions("test", "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
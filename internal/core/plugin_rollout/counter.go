@@ -0,0 +1,105 @@
+package plugin_rollout
+
+import (
+	"sync"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+type pendingCount struct {
+	stableInvocations int64
+	stableErrors      int64
+	canaryInvocations int64
+	canaryErrors      int64
+}
+
+func (c *pendingCount) add(other *pendingCount) {
+	c.stableInvocations += other.stableInvocations
+	c.stableErrors += other.stableErrors
+	c.canaryInvocations += other.canaryInvocations
+	c.canaryErrors += other.canaryErrors
+}
+
+var (
+	// counts of current node by rollout id, persisted by the next flush
+	pendingMu sync.Mutex
+	pending   = map[string]*pendingCount{}
+)
+
+func record(identity plugin_entities.PluginUniqueIdentifier, failed bool) {
+	rollout, ok := activeRollout(identity.PluginID())
+	if !ok {
+		return
+	}
+
+	canary := identity.String() == rollout.CanaryPluginUniqueIdentifier
+	if !canary && identity.String() != rollout.StablePluginUniqueIdentifier {
+		return
+	}
+
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+
+	count, ok := pending[rollout.ID]
+	if !ok {
+		count = &pendingCount{}
+		pending[rollout.ID] = count
+	}
+
+	switch {
+	case canary && failed:
+		count.canaryErrors++
+	case canary:
+		count.canaryInvocations++
+	case failed:
+		count.stableErrors++
+	default:
+		count.stableInvocations++
+	}
+}
+
+// RecordInvocation counts a request dispatched to a version in a rollout
+func RecordInvocation(identity plugin_entities.PluginUniqueIdentifier) {
+	record(identity, false)
+}
+
+// RecordError counts an invocation of a version in a rollout which ended with an error
+func RecordError(identity plugin_entities.PluginUniqueIdentifier) {
+	record(identity, true)
+}
+
+// Flush adds the counts of current node to the rollouts, counts failed to be persisted are kept
+func Flush() error {
+	pendingMu.Lock()
+	drained := pending
+	pending = map[string]*pendingCount{}
+	pendingMu.Unlock()
+
+	var failed error
+	for rolloutId, count := range drained {
+		err := db.Run(
+			db.Model(&models.PluginRollout{}),
+			db.Equal("id", rolloutId),
+			db.Inc(map[string]int64{
+				"stable_invocations": count.stableInvocations,
+				"stable_errors":      count.stableErrors,
+				"canary_invocations": count.canaryInvocations,
+				"canary_errors":      count.canaryErrors,
+			}),
+		)
+		if err != nil {
+			failed = err
+			pendingMu.Lock()
+			if current, ok := pending[rolloutId]; ok {
+				current.add(count)
+			} else {
+				pending[rolloutId] = count
+			}
+			pendingMu.Unlock()
+		}
+	}
+
+	return failed
+}
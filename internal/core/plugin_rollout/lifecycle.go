@@ -0,0 +1,217 @@
+package plugin_rollout
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"gorm.io/gorm"
+)
+
+var inProgress = []models.PluginRolloutStatus{
+	models.PluginRolloutStatusPending,
+	models.PluginRolloutStatusActive,
+}
+
+// Create adds a pending rollout, a plugin has at most one rollout in progress
+func Create(rollout *models.PluginRollout) error {
+	return db.WithTransaction(func(tx *gorm.DB) error {
+		rollouts, err := db.GetAll[models.PluginRollout](
+			db.WithTransactionContext(tx),
+			db.Equal("plugin_id", rollout.PluginID),
+			db.WLock(),
+		)
+		if err != nil {
+			return err
+		}
+		for _, existing := range rollouts {
+			if slices.Contains(inProgress, existing.Status) {
+				return ErrInProgress
+			}
+		}
+
+		rollout.Status = models.PluginRolloutStatusPending
+		return db.Create(rollout, tx)
+	})
+}
+
+func Get(rolloutId string) (*models.PluginRollout, error) {
+	rollout, err := db.GetOne[models.PluginRollout](
+		db.Equal("id", rolloutId),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &rollout, nil
+}
+
+// List returns all rollouts, the latest first
+func List() ([]models.PluginRollout, error) {
+	return db.GetAll[models.PluginRollout](
+		db.OrderBy("created_at", true),
+	)
+}
+
+// transition applies fn to a rollout in one of the statuses from, concurrent changes of other nodes wait
+func transition(
+	rolloutId string,
+	from []models.PluginRolloutStatus,
+	fn func(rollout *models.PluginRollout),
+) (*models.PluginRollout, error) {
+	var rollout models.PluginRollout
+	err := db.WithTransaction(func(tx *gorm.DB) error {
+		var err error
+		rollout, err = db.GetOne[models.PluginRollout](
+			db.WithTransactionContext(tx),
+			db.Equal("id", rolloutId),
+			db.WLock(),
+		)
+		if err == db.ErrDatabaseNotFound {
+			return ErrNotFound
+		} else if err != nil {
+			return err
+		}
+
+		if !slices.Contains(from, rollout.Status) {
+			return ErrNotInProgress
+		}
+
+		fn(&rollout)
+		return db.Update(&rollout, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	broadcast(rollout.ID)
+	return &rollout, nil
+}
+
+// Update changes who runs on the canary of a rollout in progress
+func Update(rolloutId string, percentage int, tenants []string) (*models.PluginRollout, error) {
+	return transition(rolloutId, inProgress, func(rollout *models.PluginRollout) {
+		rollout.Percentage = percentage
+		rollout.Tenants = tenants
+	})
+}
+
+// Activate starts routing invocations to the canary once it's launched
+func Activate(rolloutId string) (*models.PluginRollout, error) {
+	return transition(
+		rolloutId,
+		[]models.PluginRolloutStatus{models.PluginRolloutStatusPending},
+		func(rollout *models.PluginRollout) {
+			rollout.Status = models.PluginRolloutStatusActive
+		},
+	)
+}
+
+// Promote moves the installations of the stable version to the canary and completes the rollout
+func Promote(rolloutId string, reason string) (*models.PluginRollout, error) {
+	rollout, err := Get(rolloutId)
+	if err != nil {
+		return nil, err
+	}
+	if rollout.Status != models.PluginRolloutStatusActive {
+		return nil, ErrNotInProgress
+	}
+
+	// the installations are moved first, a failed promotion keeps routing as it was
+	if config.OnPromoted != nil {
+		if err := config.OnPromoted(*rollout); err != nil {
+			return nil, fmt.Errorf("failed to promote %s: %w", rollout.CanaryPluginUniqueIdentifier, err)
+		}
+	}
+
+	return transition(
+		rolloutId,
+		[]models.PluginRolloutStatus{models.PluginRolloutStatusActive},
+		func(rollout *models.PluginRollout) {
+			rollout.Status = models.PluginRolloutStatusPromoted
+			rollout.Reason = reason
+		},
+	)
+}
+
+// Rollback stops routing invocations to the canary and removes it
+func Rollback(rolloutId string, reason string) (*models.PluginRollout, error) {
+	rollout, err := transition(rolloutId, inProgress, func(rollout *models.PluginRollout) {
+		rollout.Status = models.PluginRolloutStatusRolledBack
+		rollout.Reason = reason
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if config.OnRolledBack != nil {
+		if err := config.OnRolledBack(*rollout); err != nil {
+			log.Error("failed to remove canary %s: %s", rollout.CanaryPluginUniqueIdentifier, err.Error())
+		}
+	}
+	return rollout, nil
+}
+
+// Evaluate promotes or rolls back the active rollouts which ran enough invocations on the canary
+func Evaluate() error {
+	// counts of current node are part of the decision
+	if err := Flush(); err != nil {
+		log.Error("failed to flush plugin rollout counts: %s", err.Error())
+	}
+
+	rollouts, err := db.GetAll[models.PluginRollout](
+		db.Equal("status", string(models.PluginRolloutStatusActive)),
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, rollout := range rollouts {
+		promote, reason, decided := decide(rollout)
+		if !decided {
+			continue
+		}
+
+		if promote {
+			_, err = Promote(rollout.ID, reason)
+		} else {
+			_, err = Rollback(rollout.ID, reason)
+		}
+		if err != nil {
+			log.Error("failed to complete rollout %s: %s", rollout.ID, err.Error())
+		} else {
+			log.Info("rollout %s of %s completed: %s", rollout.ID, rollout.CanaryPluginUniqueIdentifier, reason)
+		}
+	}
+
+	return nil
+}
+
+func errorRate(errors int64, invocations int64) float64 {
+	if invocations == 0 {
+		return 0
+	}
+	return float64(errors) / float64(invocations)
+}
+
+func decide(rollout models.PluginRollout) (promote bool, reason string, decided bool) {
+	if rollout.MinInvocations <= 0 || rollout.CanaryInvocations < rollout.MinInvocations {
+		return false, "", false
+	}
+
+	canaryRate := errorRate(rollout.CanaryErrors, rollout.CanaryInvocations)
+	stableRate := errorRate(rollout.StableErrors, rollout.StableInvocations)
+	if canaryRate > stableRate+rollout.MaxErrorRateIncrease {
+		return false, fmt.Sprintf(
+			"error rate of the canary %.4f exceeds %.4f of the stable version", canaryRate, stableRate,
+		), true
+	}
+
+	return true, fmt.Sprintf(
+		"the canary ran %d invocations with an error rate of %.4f, %.4f on the stable version",
+		rollout.CanaryInvocations, canaryRate, stableRate,
+	), true
+}
@@ -0,0 +1,166 @@
+// Package plugin_rollout routes a part of the invocations of a plugin to a new version before all tenants
+// are upgraded to it, every node routes by the rollouts it loaded and the cluster master decides on them
+package plugin_rollout
+
+import (
+	"errors"
+	"math/rand"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/schedule"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	// nodes reload the rollouts once another node changed one
+	PLUGIN_ROLLOUT_CHANNEL = "plugin-rollout-channel"
+)
+
+var (
+	ErrNotFound      = errors.New("rollout not found")
+	ErrInProgress    = errors.New("the plugin has a rollout in progress")
+	ErrNotInProgress = errors.New("the rollout is not in progress")
+)
+
+type Config struct {
+	// SyncSchedule is when counts of current node are persisted and rollouts are reloaded
+	SyncSchedule schedule.Schedule
+	// EvaluateSchedule is when active rollouts are promoted or rolled back by their counts
+	EvaluateSchedule schedule.Schedule
+	// IsLeader reports whether current node decides on rollouts, nil means it always does
+	IsLeader func() bool
+
+	// OnPromoted moves the installations of the stable version to the canary
+	OnPromoted func(rollout models.PluginRollout) error
+	// OnRolledBack removes the canary if nothing else uses it
+	OnRolledBack func(rollout models.PluginRollout) error
+}
+
+type changedEvent struct {
+	RolloutID string `json:"rollout_id"`
+}
+
+var (
+	config  Config
+	enabled atomic.Bool
+
+	// active rollouts by plugin id
+	activeMu sync.RWMutex
+	active   = map[string]models.PluginRollout{}
+
+	random = rand.Intn
+)
+
+func Enabled() bool {
+	return enabled.Load()
+}
+
+func Start(c Config) {
+	config = c
+	enabled.Store(true)
+
+	if err := Reload(); err != nil {
+		log.Error("failed to load plugin rollouts: %s", err.Error())
+	}
+
+	schedule.Run("plugin_rollout_sync", c.SyncSchedule, func() {
+		if err := Flush(); err != nil {
+			log.Error("failed to flush plugin rollout counts: %s", err.Error())
+		}
+		if err := Reload(); err != nil {
+			log.Error("failed to load plugin rollouts: %s", err.Error())
+		}
+	})
+
+	schedule.Run("plugin_rollout_evaluate", c.EvaluateSchedule, func() {
+		if c.IsLeader != nil && !c.IsLeader() {
+			return
+		}
+		if err := Evaluate(); err != nil {
+			log.Error("failed to evaluate plugin rollouts: %s", err.Error())
+		}
+	})
+
+	routine.Submit(map[string]string{
+		"module":   "plugin_rollout",
+		"function": "Start",
+	}, func() {
+		changes, cancel := cache.Subscribe[changedEvent](PLUGIN_ROLLOUT_CHANNEL)
+		defer cancel()
+
+		for range changes {
+			if err := Reload(); err != nil {
+				log.Error("failed to load plugin rollouts: %s", err.Error())
+			}
+		}
+	})
+}
+
+// Reload replaces the active rollouts of current node with those in the database
+func Reload() error {
+	rollouts, err := db.GetAll[models.PluginRollout](
+		db.Equal("status", string(models.PluginRolloutStatusActive)),
+	)
+	if err != nil {
+		return err
+	}
+
+	loaded := make(map[string]models.PluginRollout, len(rollouts))
+	for _, rollout := range rollouts {
+		loaded[rollout.PluginID] = rollout
+	}
+
+	activeMu.Lock()
+	active = loaded
+	activeMu.Unlock()
+	return nil
+}
+
+func broadcast(rolloutId string) {
+	if err := Reload(); err != nil {
+		log.Error("failed to load plugin rollouts: %s", err.Error())
+	}
+	if err := cache.Publish(PLUGIN_ROLLOUT_CHANNEL, changedEvent{RolloutID: rolloutId}); err != nil {
+		log.Error("failed to publish plugin rollout change: %s", err.Error())
+	}
+}
+
+func activeRollout(pluginId string) (models.PluginRollout, bool) {
+	activeMu.RLock()
+	defer activeMu.RUnlock()
+	rollout, ok := active[pluginId]
+	return rollout, ok
+}
+
+// Route returns the version an invocation of the tenant runs on, identity is the installed version and
+// pinned is the version picked by the node which redirected the invocation, it's kept if it's in the rollout
+func Route(
+	tenantId string,
+	identity plugin_entities.PluginUniqueIdentifier,
+	pinned string,
+) plugin_entities.PluginUniqueIdentifier {
+	rollout, ok := activeRollout(identity.PluginID())
+	if !ok || rollout.StablePluginUniqueIdentifier != identity.String() {
+		return identity
+	}
+
+	canary := plugin_entities.PluginUniqueIdentifier(rollout.CanaryPluginUniqueIdentifier)
+	switch pinned {
+	case rollout.CanaryPluginUniqueIdentifier:
+		return canary
+	case rollout.StablePluginUniqueIdentifier:
+		return identity
+	}
+
+	if slices.Contains(rollout.Tenants, tenantId) || random(100) < rollout.Percentage {
+		return canary
+	}
+	return identity
+}
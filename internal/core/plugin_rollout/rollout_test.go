@@ -0,0 +1,139 @@
+package plugin_rollout
+
+import (
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	stable = plugin_entities.PluginUniqueIdentifier("langgenius/openai:0.0.1@0000000000000000000000000000000000000000000000000000000000000001")
+	canary = plugin_entities.PluginUniqueIdentifier("langgenius/openai:0.0.2@0000000000000000000000000000000000000000000000000000000000000002")
+)
+
+func setup(t *testing.T) {
+	dbConfig := &app.Config{
+		DBType:       "sqlite",
+		DBSqlitePath: filepath.Join(t.TempDir(), "rollout.db"),
+	}
+	dbConfig.SetDefault()
+	db.Init(dbConfig)
+	t.Cleanup(db.Close)
+
+	if err := cache.InitMemoryClient(0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cache.Close() })
+
+	t.Cleanup(func() {
+		active = map[string]models.PluginRollout{}
+		pending = map[string]*pendingCount{}
+		config = Config{}
+	})
+}
+
+func startRollout(t *testing.T, rollout models.PluginRollout) models.PluginRollout {
+	rollout.PluginID = stable.PluginID()
+	rollout.StablePluginUniqueIdentifier = stable.String()
+	rollout.CanaryPluginUniqueIdentifier = canary.String()
+	require.NoError(t, Create(&rollout))
+
+	activated, err := Activate(rollout.ID)
+	require.NoError(t, err)
+	return *activated
+}
+
+func TestRoute(t *testing.T) {
+	setup(t)
+	startRollout(t, models.PluginRollout{Percentage: 10, Tenants: []string{"early"}})
+
+	random = func(n int) int { return 50 }
+	defer func() { random = rand.Intn }()
+
+	assert.Equal(t, canary, Route("early", stable, ""))
+	assert.Equal(t, stable, Route("other", stable, ""))
+	assert.Equal(t, canary, Route("other", stable, canary.String()))
+	assert.Equal(t, stable, Route("early", stable, stable.String()))
+
+	random = func(n int) int { return 5 }
+	assert.Equal(t, canary, Route("other", stable, ""))
+
+	// tenants installed another version are not part of the rollout
+	assert.Equal(t, canary, Route("other", canary, ""))
+}
+
+func TestOneRolloutInProgress(t *testing.T) {
+	setup(t)
+	rollout := startRollout(t, models.PluginRollout{Percentage: 10})
+
+	err := Create(&models.PluginRollout{PluginID: stable.PluginID()})
+	assert.ErrorIs(t, err, ErrInProgress)
+
+	_, err = Rollback(rollout.ID, "manual")
+	require.NoError(t, err)
+	assert.Equal(t, stable, Route("other", stable, canary.String()))
+
+	_, err = Rollback(rollout.ID, "manual")
+	assert.ErrorIs(t, err, ErrNotInProgress)
+	assert.NoError(t, Create(&models.PluginRollout{PluginID: stable.PluginID()}))
+}
+
+func TestEvaluate(t *testing.T) {
+	setup(t)
+
+	var promoted, rolledBack []string
+	config = Config{
+		OnPromoted: func(rollout models.PluginRollout) error {
+			promoted = append(promoted, rollout.ID)
+			return nil
+		},
+		OnRolledBack: func(rollout models.PluginRollout) error {
+			rolledBack = append(rolledBack, rollout.ID)
+			return nil
+		},
+	}
+
+	rollout := startRollout(t, models.PluginRollout{Percentage: 50, MinInvocations: 10, MaxErrorRateIncrease: 0.05})
+	for i := 0; i < 20; i++ {
+		RecordInvocation(stable)
+	}
+	RecordError(stable)
+	for i := 0; i < 9; i++ {
+		RecordInvocation(canary)
+	}
+
+	// not enough invocations on the canary yet
+	require.NoError(t, Evaluate())
+	current, err := Get(rollout.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PluginRolloutStatusActive, current.Status)
+	assert.Equal(t, int64(20), current.StableInvocations)
+	assert.Equal(t, int64(1), current.StableErrors)
+
+	RecordInvocation(canary)
+	require.NoError(t, Evaluate())
+	current, err = Get(rollout.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PluginRolloutStatusPromoted, current.Status)
+	assert.Equal(t, []string{rollout.ID}, promoted)
+
+	rollout = startRollout(t, models.PluginRollout{Percentage: 50, MinInvocations: 10, MaxErrorRateIncrease: 0.05})
+	for i := 0; i < 10; i++ {
+		RecordInvocation(canary)
+	}
+	RecordError(canary)
+	require.NoError(t, Evaluate())
+	current, err = Get(rollout.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PluginRolloutStatusRolledBack, current.Status)
+	assert.Equal(t, []string{rollout.ID}, rolledBack)
+	assert.Len(t, promoted, 1)
+}
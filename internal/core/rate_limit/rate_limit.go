@@ -0,0 +1,159 @@
+// Package rate_limit limits invocations of the whole cluster, of each tenant and of each plugin of a
+// tenant with token buckets in the cache, so that all nodes share them
+package rate_limit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+)
+
+const (
+	RATE_LIMIT_KEY_PREFIX = "rate_limit"
+)
+
+// Limit allows Rate requests per second with bursts of up to Burst requests, a zero rate is unlimited
+type Limit struct {
+	Rate  float64
+	Burst int64
+}
+
+func (l Limit) unlimited() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+type Config struct {
+	Global Limit
+	Tenant Limit
+	// Plugin limits each plugin of each tenant
+	Plugin Limit
+	// TenantOverrides replace Tenant for the tenants listed
+	TenantOverrides map[string]Limit
+}
+
+// ParseTenantOverrides parses overrides formatted as tenant_id:rate:burst
+func ParseTenantOverrides(overrides []string) (map[string]Limit, error) {
+	result := map[string]Limit{}
+	for _, override := range overrides {
+		override = strings.TrimSpace(override)
+		if override == "" {
+			continue
+		}
+
+		parts := strings.Split(override, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid rate limit override %s, expected tenant_id:rate:burst", override)
+		}
+		rate, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate of rate limit override %s: %w", override, err)
+		}
+		burst, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid burst of rate limit override %s: %w", override, err)
+		}
+		result[parts[0]] = Limit{Rate: rate, Burst: burst}
+	}
+	return result, nil
+}
+
+// Decision is the outcome of the most restrictive bucket the request was checked against
+type Decision struct {
+	Allowed   bool
+	Limit     int64
+	Remaining int64
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the request would be allowed if it's not
+	RetryAfter time.Duration
+	// Scope is the bucket limiting the request, global, tenant or plugin
+	Scope string
+}
+
+type Limiter struct {
+	config Config
+}
+
+func NewLimiter(config Config) *Limiter {
+	return &Limiter{config: config}
+}
+
+// Enabled reports whether any limit is configured
+func (l *Limiter) Enabled() bool {
+	if !l.config.Global.unlimited() || !l.config.Tenant.unlimited() || !l.config.Plugin.unlimited() {
+		return true
+	}
+	for _, limit := range l.config.TenantOverrides {
+		if !limit.unlimited() {
+			return true
+		}
+	}
+	return false
+}
+
+type bucket struct {
+	scope string
+	key   string
+	limit Limit
+}
+
+func (l *Limiter) buckets(tenantID string, pluginID string) []bucket {
+	tenant := l.config.Tenant
+	if override, ok := l.config.TenantOverrides[tenantID]; ok {
+		tenant = override
+	}
+
+	// the most specific bucket comes first so that a noisy plugin doesn't drain the others
+	buckets := []bucket{}
+	if pluginID != "" && !l.config.Plugin.unlimited() {
+		buckets = append(buckets, bucket{"plugin", strings.Join([]string{RATE_LIMIT_KEY_PREFIX, "plugin", tenantID, pluginID}, ":"), l.config.Plugin})
+	}
+	if !tenant.unlimited() {
+		buckets = append(buckets, bucket{"tenant", strings.Join([]string{RATE_LIMIT_KEY_PREFIX, "tenant", tenantID}, ":"), tenant})
+	}
+	if !l.config.Global.unlimited() {
+		buckets = append(buckets, bucket{"global", strings.Join([]string{RATE_LIMIT_KEY_PREFIX, "global"}, ":"), l.config.Global})
+	}
+	return buckets
+}
+
+// Allow takes a token from each bucket the request of the tenant to the plugin counts against, only if
+// none of them is empty, pluginID may be empty for requests to no plugin. a refused request takes no token,
+// the decision is the first empty bucket or the one with the fewest tokens left
+func (l *Limiter) Allow(tenantID string, pluginID string) (*Decision, error) {
+	buckets := l.buckets(tenantID, pluginID)
+	if len(buckets) == 0 {
+		return &Decision{Allowed: true}, nil
+	}
+
+	limits := make([]cache.TokenBucketLimit, 0, len(buckets))
+	for _, b := range buckets {
+		limits = append(limits, cache.TokenBucketLimit{Key: b.key, Rate: b.limit.Rate, Burst: b.limit.Burst})
+	}
+	states, err := cache.TakeTokens(limits)
+	if err != nil {
+		return nil, err
+	}
+
+	var decision *Decision
+	for i, state := range states {
+		current := &Decision{
+			Allowed:    state.Allowed,
+			Limit:      buckets[i].limit.Burst,
+			Remaining:  state.Remaining,
+			Reset:      state.Reset,
+			RetryAfter: state.RetryAfter,
+			Scope:      buckets[i].scope,
+		}
+		if !current.Allowed {
+			return current, nil
+		}
+		if decision == nil || current.Remaining < decision.Remaining {
+			decision = current
+		}
+	}
+	return decision, nil
+}
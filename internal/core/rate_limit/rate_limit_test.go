@@ -0,0 +1,129 @@
+package rate_limit
+
+import (
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+)
+
+func initCache(t *testing.T) {
+	if err := cache.InitMemoryClient(0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cache.Close() })
+}
+
+func TestParseTenantOverrides(t *testing.T) {
+	overrides, err := ParseTenantOverrides([]string{"a:1.5:3", " ", "b:10:20"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overrides["a"] != (Limit{Rate: 1.5, Burst: 3}) || overrides["b"] != (Limit{Rate: 10, Burst: 20}) {
+		t.Fatalf("unexpected overrides %v", overrides)
+	}
+
+	for _, invalid := range []string{"a", "a:x:1", "a:1:x"} {
+		if _, err := ParseTenantOverrides([]string{invalid}); err == nil {
+			t.Fatalf("expected %s to be invalid", invalid)
+		}
+	}
+}
+
+func TestAllowPluginBucketFirst(t *testing.T) {
+	initCache(t)
+
+	limiter := NewLimiter(Config{
+		Tenant: Limit{Rate: 0.001, Burst: 3},
+		Plugin: Limit{Rate: 0.001, Burst: 1},
+	})
+
+	decision, err := limiter.Allow("tenant", "noisy")
+	if err != nil || !decision.Allowed {
+		t.Fatalf("expected the first request to be allowed, got %v %v", decision, err)
+	}
+	if decision.Scope != "plugin" || decision.Limit != 1 || decision.Remaining != 0 {
+		t.Fatalf("expected the plugin bucket to be the most restrictive, got %+v", decision)
+	}
+
+	decision, err = limiter.Allow("tenant", "noisy")
+	if err != nil || decision.Allowed || decision.Scope != "plugin" || decision.RetryAfter <= 0 {
+		t.Fatalf("expected the plugin to be limited, got %+v %v", decision, err)
+	}
+
+	// the rejected request took no token of the tenant, two are left for other plugins
+	for _, pluginID := range []string{"a", "b"} {
+		if decision, err := limiter.Allow("tenant", pluginID); err != nil || !decision.Allowed {
+			t.Fatalf("expected %s to be allowed, got %+v %v", pluginID, decision, err)
+		}
+	}
+	decision, err = limiter.Allow("tenant", "c")
+	if err != nil || decision.Allowed || decision.Scope != "tenant" {
+		t.Fatalf("expected the tenant to be limited, got %+v %v", decision, err)
+	}
+
+	// other tenants have buckets of their own
+	if decision, err := limiter.Allow("other", "c"); err != nil || !decision.Allowed {
+		t.Fatalf("expected another tenant to be allowed, got %+v %v", decision, err)
+	}
+}
+
+func TestAllowOverridesAndGlobal(t *testing.T) {
+	initCache(t)
+
+	limiter := NewLimiter(Config{
+		Global:          Limit{Rate: 0.001, Burst: 3},
+		Tenant:          Limit{Rate: 0.001, Burst: 1},
+		TenantOverrides: map[string]Limit{"big": {Rate: 0.001, Burst: 10}},
+	})
+	if !limiter.Enabled() {
+		t.Fatal("expected the limiter to be enabled")
+	}
+
+	for i := 0; i < 2; i++ {
+		if decision, err := limiter.Allow("big", ""); err != nil || !decision.Allowed {
+			t.Fatalf("expected the override to allow more requests, got %+v %v", decision, err)
+		}
+	}
+	if decision, err := limiter.Allow("small", ""); err != nil || !decision.Allowed {
+		t.Fatalf("expected the first request of small to be allowed, got %+v %v", decision, err)
+	}
+
+	decision, err := limiter.Allow("big", "")
+	if err != nil || decision.Allowed || decision.Scope != "global" {
+		t.Fatalf("expected the global limit to apply, got %+v %v", decision, err)
+	}
+
+	if NewLimiter(Config{}).Enabled() {
+		t.Fatal("expected a limiter without limits to be disabled")
+	}
+}
+
+func TestRefusedRequestTakesNoToken(t *testing.T) {
+	initCache(t)
+
+	limiter := NewLimiter(Config{
+		Tenant: Limit{Rate: 0.001, Burst: 1},
+		Plugin: Limit{Rate: 0.001, Burst: 3},
+	})
+
+	if decision, err := limiter.Allow("tenant", "a"); err != nil || !decision.Allowed {
+		t.Fatalf("expected the first request to be allowed, got %+v %v", decision, err)
+	}
+
+	// the tenant is out of tokens, refused requests must not drain the bucket of the plugin
+	for i := 0; i < 3; i++ {
+		decision, err := limiter.Allow("tenant", "a")
+		if err != nil || decision.Allowed || decision.Scope != "tenant" {
+			t.Fatalf("expected the tenant to be limited, got %+v %v", decision, err)
+		}
+	}
+
+	states, err := cache.TakeTokens([]cache.TokenBucketLimit{{
+		Key:   RATE_LIMIT_KEY_PREFIX + ":plugin:tenant:a",
+		Rate:  0.001,
+		Burst: 3,
+	}})
+	if err != nil || !states[0].Allowed || states[0].Remaining != 1 {
+		t.Fatalf("expected 2 tokens left in the plugin bucket before this take, got %+v %v", states, err)
+	}
+}
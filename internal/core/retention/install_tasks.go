@@ -0,0 +1,23 @@
+package retention
+
+import (
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+)
+
+func init() {
+	Register(CLASS_INSTALL_TASKS, purgeInstallTasks)
+}
+
+// purgeInstallTasks only deletes finished tasks, pending and running ones are still needed to resume them
+func purgeInstallTasks(before time.Time) (int64, error) {
+	return db.DeleteBy[models.InstallTask](
+		db.InArray("status", []any{
+			models.InstallTaskStatusSuccess,
+			models.InstallTaskStatusFailed,
+		}),
+		db.LessThan("updated_at", before),
+	)
+}
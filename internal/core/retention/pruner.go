@@ -0,0 +1,155 @@
+package retention
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/schedule"
+)
+
+const (
+	CLASS_AUDIT_RECORDS        = "audit_records"
+	CLASS_INVOCATION_ANALYTICS = "invocation_analytics"
+	CLASS_SESSION_RECORDINGS   = "session_recordings"
+	CLASS_INSTALL_TASKS        = "install_tasks"
+
+	// only one node of the cluster prunes in each interval
+	PRUNE_LOCK_KEY = "retention:prune:lock"
+)
+
+// Purger deletes the records of a data class created before the given time and returns how many were deleted
+type Purger func(before time.Time) (int64, error)
+
+type ClassStatus struct {
+	Class string `json:"class"`
+	// WindowSeconds is 0 if records of the class are kept forever
+	WindowSeconds int64     `json:"window_seconds"`
+	Registered    bool      `json:"registered"`
+	Purged        int64     `json:"purged"`
+	LastPurged    int64     `json:"last_purged"`
+	LastRunAt     time.Time `json:"last_run_at"`
+	LastError     string    `json:"last_error,omitempty"`
+}
+
+type pruner struct {
+	mu       sync.Mutex
+	purgers  map[string]Purger
+	windows  map[string]time.Duration
+	statuses map[string]*ClassStatus
+	// local classes are stored on each node, they are purged by every node
+	local map[string]bool
+}
+
+var globalPruner = &pruner{
+	purgers:  map[string]Purger{},
+	windows:  map[string]time.Duration{},
+	statuses: map[string]*ClassStatus{},
+	local:    map[string]bool{},
+}
+
+// Register makes records of a data class subject to its retention window,
+// classes without a window are never purged
+func Register(class string, purger Purger) {
+	globalPruner.mu.Lock()
+	defer globalPruner.mu.Unlock()
+
+	globalPruner.purgers[class] = purger
+	globalPruner.status(class).Registered = true
+}
+
+// RegisterLocal is Register for records kept on the node itself rather than in shared storage
+func RegisterLocal(class string, purger Purger) {
+	Register(class, purger)
+
+	globalPruner.mu.Lock()
+	defer globalPruner.mu.Unlock()
+	globalPruner.local[class] = true
+}
+
+// Start prunes at every activation of the schedule, windows maps data classes to how long their records are kept
+func Start(windows map[string]time.Duration, pruneSchedule schedule.Schedule) {
+	globalPruner.mu.Lock()
+	for class, window := range windows {
+		if window <= 0 {
+			continue
+		}
+		globalPruner.windows[class] = window
+		globalPruner.status(class).WindowSeconds = int64(window.Seconds())
+	}
+	globalPruner.mu.Unlock()
+
+	schedule.Run("retention", pruneSchedule, func() {
+		// the lock expires before the next activation, which is free to run on any node
+		now := time.Now()
+		ok, err := cache.SetNX(PRUNE_LOCK_KEY, true, pruneSchedule.Next(now).Sub(now)/2)
+		if err != nil {
+			log.Error("failed to acquire retention prune lock: %s", err.Error())
+			return
+		}
+		globalPruner.prune(now, ok)
+	})
+}
+
+// FetchStatus returns metrics of every configured or registered data class
+func FetchStatus() []ClassStatus {
+	globalPruner.mu.Lock()
+	defer globalPruner.mu.Unlock()
+
+	statuses := make([]ClassStatus, 0, len(globalPruner.statuses))
+	for _, status := range globalPruner.statuses {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Class < statuses[j].Class
+	})
+	return statuses
+}
+
+func (p *pruner) status(class string) *ClassStatus {
+	status, ok := p.statuses[class]
+	if !ok {
+		status = &ClassStatus{Class: class}
+		p.statuses[class] = status
+	}
+	return status
+}
+
+// prune purges the classes with a window, shared classes are only purged by the node holding the lock
+func (p *pruner) prune(now time.Time, locked bool) {
+	p.mu.Lock()
+	windows := make(map[string]time.Duration, len(p.windows))
+	purgers := make(map[string]Purger, len(p.purgers))
+	for class, window := range p.windows {
+		if !locked && !p.local[class] {
+			continue
+		}
+		if purger, ok := p.purgers[class]; ok {
+			windows[class] = window
+			purgers[class] = purger
+		}
+	}
+	p.mu.Unlock()
+
+	for class, window := range windows {
+		purged, err := purgers[class](now.Add(-window))
+
+		p.mu.Lock()
+		status := p.status(class)
+		status.LastRunAt = now
+		status.LastPurged = purged
+		status.Purged += purged
+		if err != nil {
+			status.LastError = err.Error()
+			log.Error("failed to purge %s: %s", class, err.Error())
+		} else {
+			status.LastError = ""
+			if purged > 0 {
+				log.Info("purged %d %s older than %s", purged, class, window)
+			}
+		}
+		p.mu.Unlock()
+	}
+}
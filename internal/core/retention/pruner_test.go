@@ -0,0 +1,66 @@
+package retention
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrune(t *testing.T) {
+	p := &pruner{
+		purgers:  map[string]Purger{},
+		windows:  map[string]time.Duration{},
+		statuses: map[string]*ClassStatus{},
+		local:    map[string]bool{},
+	}
+
+	now := time.Now()
+	var cutoff time.Time
+	p.purgers[CLASS_INSTALL_TASKS] = func(before time.Time) (int64, error) {
+		cutoff = before
+		return 3, nil
+	}
+	p.purgers[CLASS_AUDIT_RECORDS] = func(before time.Time) (int64, error) {
+		return 0, errors.New("unavailable")
+	}
+	// registered but kept forever
+	p.purgers[CLASS_SESSION_RECORDINGS] = func(before time.Time) (int64, error) {
+		t.Fatal("class without a window must not be purged")
+		return 0, nil
+	}
+	p.windows[CLASS_INSTALL_TASKS] = time.Hour
+	p.windows[CLASS_AUDIT_RECORDS] = time.Hour
+
+	p.prune(now, true)
+	p.prune(now, true)
+
+	assert.Equal(t, now.Add(-time.Hour), cutoff)
+	assert.Equal(t, int64(6), p.statuses[CLASS_INSTALL_TASKS].Purged)
+	assert.Equal(t, int64(3), p.statuses[CLASS_INSTALL_TASKS].LastPurged)
+	assert.Equal(t, "unavailable", p.statuses[CLASS_AUDIT_RECORDS].LastError)
+	assert.Nil(t, p.statuses[CLASS_SESSION_RECORDINGS])
+}
+
+func TestPruneLocalClassesWithoutLock(t *testing.T) {
+	p := &pruner{
+		purgers:  map[string]Purger{},
+		windows:  map[string]time.Duration{},
+		statuses: map[string]*ClassStatus{},
+		local:    map[string]bool{CLASS_SESSION_RECORDINGS: true},
+	}
+
+	p.purgers[CLASS_INSTALL_TASKS] = func(before time.Time) (int64, error) {
+		t.Fatal("shared classes are purged by the node holding the lock only")
+		return 0, nil
+	}
+	p.purgers[CLASS_SESSION_RECORDINGS] = func(before time.Time) (int64, error) {
+		return 1, nil
+	}
+	p.windows[CLASS_INSTALL_TASKS] = time.Hour
+	p.windows[CLASS_SESSION_RECORDINGS] = time.Hour
+
+	p.prune(time.Now(), false)
+	assert.Equal(t, int64(1), p.statuses[CLASS_SESSION_RECORDINGS].Purged)
+}
@@ -0,0 +1,219 @@
+package session_manager
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	SESSION_RECORDS_ACTIVE_KEY = "session_records_active"
+	SESSION_RECORD_KEY         = "session_record"
+	SESSION_HEARTBEAT_INTERVAL = 10 * time.Second
+	SESSION_RECOVERY_INTERVAL  = 30 * time.Second
+	// SESSION_ORPHAN_TIMEOUT is how long a session may miss heartbeats before it's failed,
+	// the node running it is considered dead by then
+	SESSION_ORPHAN_TIMEOUT = 60 * time.Second
+	// SESSION_RECORD_EXPIRE is how long the outcome of a session can be fetched after it finished
+	SESSION_RECORD_EXPIRE = time.Hour
+
+	SESSION_INTERRUPTED_ERROR = "SessionInterruptedError"
+	SESSION_CANCELLED_ERROR   = "SessionCancelledError"
+)
+
+type SessionStatus string
+
+const (
+	SESSION_STATUS_RUNNING     SessionStatus = "running"
+	SESSION_STATUS_COMPLETED   SessionStatus = "completed"
+	SESSION_STATUS_FAILED      SessionStatus = "failed"
+	SESSION_STATUS_INTERRUPTED SessionStatus = "interrupted"
+)
+
+type SessionError struct {
+	ErrorType string `json:"error_type"`
+	Message   string `json:"message"`
+}
+
+// SessionRecord is the persisted state of a session, it outlives the node running the session
+// so that callers whose stream broke learn how the invocation ended
+type SessionRecord struct {
+	ID                     string                                 `json:"id"`
+	TenantID               string                                 `json:"tenant_id"`
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier"`
+	InvokeFrom             access_types.PluginAccessType          `json:"invoke_from"`
+	Action                 access_types.PluginAccessAction        `json:"action"`
+	NodeID                 string                                 `json:"node_id"`
+	Status                 SessionStatus                          `json:"status"`
+	Error                  *SessionError                          `json:"error"`
+	// DeliveredChunks is how many chunks of the response were written to the caller
+	DeliveredChunks int64      `json:"delivered_chunks"`
+	StartedAt       time.Time  `json:"started_at"`
+	HeartbeatAt     time.Time  `json:"heartbeat_at"`
+	FinishedAt      *time.Time `json:"finished_at"`
+}
+
+var (
+	// persistenceNodeID is empty until InitPersistence, sessions are not recorded before
+	persistenceNodeID string
+)
+
+func sessionRecordKey(id string) string {
+	return fmt.Sprintf("%s:%s", SESSION_RECORD_KEY, id)
+}
+
+// InitPersistence records the sessions created on current node from now on, keeps them alive
+// with heartbeats and fails the sessions of nodes which stopped sending them, sessions left by
+// a previous run of current node are failed the same way
+func InitPersistence(nodeID string) {
+	persistenceNodeID = nodeID
+
+	routine.Submit(map[string]string{
+		"module":   "session_manager",
+		"function": "SessionPersistence",
+	}, func() {
+		heartbeatTicker := time.NewTicker(SESSION_HEARTBEAT_INTERVAL)
+		defer heartbeatTicker.Stop()
+		recoveryTicker := time.NewTicker(SESSION_RECOVERY_INTERVAL)
+		defer recoveryTicker.Stop()
+
+		recoverOrphanedSessions(time.Now())
+		for {
+			select {
+			case <-heartbeatTicker.C:
+				heartbeat()
+			case <-recoveryTicker.C:
+				recoverOrphanedSessions(time.Now())
+			}
+		}
+	})
+}
+
+func (s *Session) record(status SessionStatus) SessionRecord {
+	return SessionRecord{
+		ID:                     s.ID,
+		TenantID:               s.TenantID,
+		PluginUniqueIdentifier: s.PluginUniqueIdentifier,
+		InvokeFrom:             s.InvokeFrom,
+		Action:                 s.Action,
+		NodeID:                 persistenceNodeID,
+		Status:                 status,
+		DeliveredChunks:        s.delivered.Load(),
+		StartedAt:              s.StartedAt,
+		HeartbeatAt:            time.Now(),
+	}
+}
+
+// RecordDelivered counts a chunk of the response written to the caller
+func (s *Session) RecordDelivered() {
+	s.delivered.Add(1)
+}
+
+// Fail marks the session as failed, it's persisted once the session is closed
+func (s *Session) Fail(errorType string, message string) {
+	s.failure.CompareAndSwap(nil, &SessionError{ErrorType: errorType, Message: message})
+}
+
+// finish persists the outcome of the session, only the first call takes effect
+func (s *Session) finish(status SessionStatus) {
+	if !s.persisted || !s.finished.CompareAndSwap(false, true) {
+		return
+	}
+
+	record := s.record(status)
+	if failure := s.failure.Load(); failure != nil {
+		record.Error = failure
+		if status == SESSION_STATUS_COMPLETED {
+			record.Status = SESSION_STATUS_FAILED
+		}
+	}
+	saveFinishedRecord(record)
+}
+
+func saveFinishedRecord(record SessionRecord) {
+	now := time.Now()
+	record.FinishedAt = &now
+
+	if err := cache.Store(sessionRecordKey(record.ID), record, SESSION_RECORD_EXPIRE); err != nil {
+		log.Error("failed to persist outcome of session %s: %s", record.ID, err.Error())
+	}
+	if err := cache.DelMapField(SESSION_RECORDS_ACTIVE_KEY, record.ID); err != nil {
+		log.Error("failed to remove active session %s: %s", record.ID, err.Error())
+	}
+}
+
+// heartbeat refreshes the records of the sessions running on current node
+func heartbeat() {
+	session_lock.RLock()
+	records := make([]SessionRecord, 0, len(sessions))
+	for _, session := range sessions {
+		if session.persisted && !session.finished.Load() {
+			records = append(records, session.record(SESSION_STATUS_RUNNING))
+		}
+	}
+	session_lock.RUnlock()
+
+	for _, record := range records {
+		if err := cache.SetMapOneField(SESSION_RECORDS_ACTIVE_KEY, record.ID, record); err != nil {
+			log.Error("failed to refresh session %s: %s", record.ID, err.Error())
+		}
+	}
+}
+
+// recoverOrphanedSessions fails the sessions whose node stopped sending heartbeats, the plugin
+// may still call back with the session id until the session info expires
+func recoverOrphanedSessions(now time.Time) {
+	records, err := cache.GetMap[SessionRecord](SESSION_RECORDS_ACTIVE_KEY)
+	if err != nil && err != cache.ErrNotFound {
+		log.Error("failed to fetch active sessions: %s", err.Error())
+		return
+	}
+
+	for id, record := range records {
+		if now.Sub(record.HeartbeatAt) < SESSION_ORPHAN_TIMEOUT {
+			continue
+		}
+
+		log.Warn("session %s of plugin %s was orphaned by node %s", id, record.PluginUniqueIdentifier, record.NodeID)
+		record.Status = SESSION_STATUS_INTERRUPTED
+		record.Error = &SessionError{
+			ErrorType: SESSION_INTERRUPTED_ERROR,
+			Message:   "the plugin daemon running the session stopped unexpectedly",
+		}
+		saveFinishedRecord(record)
+	}
+}
+
+// InterruptSessions fails all the sessions still running on current node, it's called when the
+// node is stopping and can't wait for them anymore
+func InterruptSessions(reason string) {
+	session_lock.RLock()
+	running := make([]*Session, 0, len(sessions))
+	for _, session := range sessions {
+		running = append(running, session)
+	}
+	session_lock.RUnlock()
+
+	for _, session := range running {
+		session.Fail(SESSION_INTERRUPTED_ERROR, reason)
+		session.finish(SESSION_STATUS_INTERRUPTED)
+	}
+}
+
+// FetchSessionRecord returns the record of a running or recently finished session
+func FetchSessionRecord(id string) (*SessionRecord, error) {
+	record, err := cache.Get[SessionRecord](sessionRecordKey(id))
+	if err == nil {
+		return record, nil
+	}
+	if err != cache.ErrNotFound {
+		return nil, err
+	}
+
+	return cache.GetMapField[SessionRecord](SESSION_RECORDS_ACTIVE_KEY, id)
+}
@@ -0,0 +1,98 @@
+package session_manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+)
+
+func initPersistenceTest(t *testing.T) {
+	if err := cache.InitMemoryClient(0); err != nil {
+		t.Fatal(err)
+	}
+	persistenceNodeID = "node"
+	t.Cleanup(func() {
+		cache.Close()
+		persistenceNodeID = ""
+	})
+}
+
+func TestSessionRecordOutcome(t *testing.T) {
+	initPersistenceTest(t)
+
+	session := NewSession(NewSessionPayload{TenantID: "tenant"})
+	record, err := FetchSessionRecord(session.ID)
+	if err != nil || record.Status != SESSION_STATUS_RUNNING {
+		t.Fatalf("expected a running record, got %+v, %v", record, err)
+	}
+
+	session.RecordDelivered()
+	session.RecordDelivered()
+	session.Fail("PluginInvokeError", "boom")
+	session.Close(CloseSessionPayload{})
+
+	record, err = FetchSessionRecord(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Status != SESSION_STATUS_FAILED || record.Error == nil || record.Error.Message != "boom" {
+		t.Errorf("expected a failed record, got %+v", record)
+	}
+	if record.DeliveredChunks != 2 || record.FinishedAt == nil {
+		t.Errorf("expected 2 delivered chunks and a finish time, got %+v", record)
+	}
+	if _, err := cache.GetMapField[SessionRecord](SESSION_RECORDS_ACTIVE_KEY, session.ID); err != cache.ErrNotFound {
+		t.Errorf("finished session should not be active, got %v", err)
+	}
+}
+
+func TestRecoverOrphanedSessions(t *testing.T) {
+	initPersistenceTest(t)
+
+	now := time.Now()
+	for id, heartbeat := range map[string]time.Time{
+		"orphaned": now.Add(-2 * SESSION_ORPHAN_TIMEOUT),
+		"alive":    now,
+	} {
+		if err := cache.SetMapOneField(SESSION_RECORDS_ACTIVE_KEY, id, SessionRecord{
+			ID:          id,
+			Status:      SESSION_STATUS_RUNNING,
+			HeartbeatAt: heartbeat,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recoverOrphanedSessions(now)
+
+	record, err := FetchSessionRecord("orphaned")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Status != SESSION_STATUS_INTERRUPTED || record.Error == nil || record.Error.ErrorType != SESSION_INTERRUPTED_ERROR {
+		t.Errorf("orphaned session should be interrupted, got %+v", record)
+	}
+
+	record, err = FetchSessionRecord("alive")
+	if err != nil || record.Status != SESSION_STATUS_RUNNING {
+		t.Errorf("alive session should keep running, got %+v, %v", record, err)
+	}
+}
+
+func TestInterruptSessions(t *testing.T) {
+	initPersistenceTest(t)
+
+	session := NewSession(NewSessionPayload{TenantID: "tenant"})
+	InterruptSessions("shutting down")
+	// closing afterwards must not overwrite the interruption
+	session.Close(CloseSessionPayload{})
+
+	record, err := FetchSessionRecord(session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Status != SESSION_STATUS_INTERRUPTED || record.Error.Message != "shutting down" {
+		t.Errorf("expected an interrupted record, got %+v", record)
+	}
+}
@@ -1,9 +1,12 @@
 package session_manager
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -40,6 +43,27 @@ type Session struct {
 	AppID          *string        `json:"app_id"`
 	EndpointID     *string        `json:"endpoint_id"`
 	Context        map[string]any `json:"context"`
+
+	// TraceContext is the W3C trace context of the invocation, it's forwarded to
+	// the plugin within the event headers
+	TraceContext map[string]string `json:"trace_context"`
+
+	StartedAt time.Time `json:"started_at"`
+
+	// state persisted by InitPersistence, only kept by the node running the session
+	persisted bool
+	finished  atomic.Bool
+	delivered atomic.Int64
+	failure   atomic.Pointer[SessionError]
+
+	// the plugin is told to abort the session once the request is cancelled before it ended
+	stopWatching func() bool
+	ended        atomic.Bool
+	aborted      atomic.Bool
+
+	// closed once the session is cancelled, the invocation serving it stops then
+	cancelled  chan struct{}
+	cancelOnce sync.Once
 }
 
 func sessionKey(id string) string {
@@ -61,6 +85,9 @@ type NewSessionPayload struct {
 	AppID                  *string                                `json:"app_id"`
 	EndpointID             *string                                `json:"endpoint_id"`
 	Context                map[string]any                         `json:"context"`
+	TraceContext           map[string]string                      `json:"trace_context"`
+	// RequestContext is the context of the request the session serves, it's optional
+	RequestContext context.Context `json:"-"`
 }
 
 func NewSession(payload NewSessionPayload) *Session {
@@ -79,6 +106,16 @@ func NewSession(payload NewSessionPayload) *Session {
 		AppID:                  payload.AppID,
 		EndpointID:             payload.EndpointID,
 		Context:                payload.Context,
+		TraceContext:           payload.TraceContext,
+		StartedAt:              time.Now(),
+		persisted:              !payload.IgnoreCache && persistenceNodeID != "",
+		cancelled:              make(chan struct{}),
+	}
+
+	if payload.RequestContext != nil {
+		s.stopWatching = context.AfterFunc(payload.RequestContext, func() {
+			s.Abort("request cancelled")
+		})
 	}
 
 	session_lock.Lock()
@@ -87,7 +124,13 @@ func NewSession(payload NewSessionPayload) *Session {
 
 	if !payload.IgnoreCache {
 		if err := cache.Store(sessionKey(s.ID), s, time.Minute*30); err != nil {
-			log.Error("set session info to cache failed, %s", err)
+			s.Logger().Error("set session info to cache failed, %s", err)
+		}
+	}
+
+	if s.persisted {
+		if err := cache.SetMapOneField(SESSION_RECORDS_ACTIVE_KEY, s.ID, s.record(SESSION_STATUS_RUNNING)); err != nil {
+			s.Logger().Error("persist session record failed, %s", err)
 		}
 	}
 
@@ -133,11 +176,51 @@ func DeleteSession(payload DeleteSessionPayload) {
 	}
 }
 
+// CountSessions returns the number of sessions alive on current node by plugin
+func CountSessions() map[plugin_entities.PluginUniqueIdentifier]int {
+	session_lock.RLock()
+	defer session_lock.RUnlock()
+
+	counts := make(map[plugin_entities.PluginUniqueIdentifier]int)
+	for _, session := range sessions {
+		counts[session.PluginUniqueIdentifier]++
+	}
+	return counts
+}
+
+// CancelSession cancels a session of the tenant running on current node, it returns false if
+// there's no such session
+func CancelSession(tenantID string, id string, reason string) bool {
+	session_lock.RLock()
+	session := sessions[id]
+	session_lock.RUnlock()
+
+	if session == nil || session.TenantID != tenantID {
+		return false
+	}
+	session.Cancel(reason)
+	return true
+}
+
 type CloseSessionPayload struct {
 	IgnoreCache bool `json:"ignore_cache"`
 }
 
+// Logger attaches the plugin, tenant and session to log lines
+func (s *Session) Logger() *log.Entry {
+	return log.With(log.Fields{
+		log.FIELD_PLUGIN_ID:  s.PluginUniqueIdentifier.PluginID(),
+		log.FIELD_TENANT_ID:  s.TenantID,
+		log.FIELD_SESSION_ID: s.ID,
+	})
+}
+
 func (s *Session) Close(payload CloseSessionPayload) {
+	if s.stopWatching != nil {
+		s.stopWatching()
+	}
+	s.finish(SESSION_STATUS_COMPLETED)
+
 	DeleteSession(DeleteSessionPayload{
 		ID:          s.ID,
 		IgnoreCache: payload.IgnoreCache,
@@ -165,6 +248,8 @@ type PLUGIN_IN_STREAM_EVENT string
 const (
 	PLUGIN_IN_STREAM_EVENT_REQUEST  PLUGIN_IN_STREAM_EVENT = "request"
 	PLUGIN_IN_STREAM_EVENT_RESPONSE PLUGIN_IN_STREAM_EVENT = "backwards_response"
+	// PLUGIN_IN_STREAM_EVENT_ABORT asks the plugin to stop generating for the session
+	PLUGIN_IN_STREAM_EVENT_ABORT PLUGIN_IN_STREAM_EVENT = "abort"
 )
 
 func (s *Session) Message(event PLUGIN_IN_STREAM_EVENT, data any) []byte {
@@ -175,6 +260,7 @@ func (s *Session) Message(event PLUGIN_IN_STREAM_EVENT, data any) []byte {
 		"app_id":          s.AppID,
 		"endpoint_id":     s.EndpointID,
 		"context":         s.Context,
+		"headers":         s.TraceContext,
 		"event":           event,
 		"data":            data,
 	})
@@ -187,3 +273,43 @@ func (s *Session) Write(event PLUGIN_IN_STREAM_EVENT, action access_types.Plugin
 	s.runtime.Write(s.ID, action, s.Message(event, data))
 	return nil
 }
+
+// PluginEnded records that the plugin ended the session by itself, it's not aborted afterwards
+func (s *Session) PluginEnded() {
+	s.ended.Store(true)
+}
+
+// Abort tells the plugin to stop working on the session, only the first call before the plugin ended
+// takes effect, serverless plugins stop once their request is closed so they are not written to, and
+// plugins whose sdk didn't declare the abort capability would reject the event
+func (s *Session) Abort(reason string) {
+	if s.runtime == nil || s.ended.Load() || !s.aborted.CompareAndSwap(false, true) {
+		return
+	}
+	if s.runtime.Type() == plugin_entities.PLUGIN_RUNTIME_TYPE_SERVERLESS {
+		return
+	}
+	if !slices.Contains(s.runtime.RuntimeState().Capabilities, string(plugin_entities.PLUGIN_CAPABILITY_ABORT)) {
+		return
+	}
+
+	s.Logger().Info("abort session, %s", reason)
+	if err := s.Write(PLUGIN_IN_STREAM_EVENT_ABORT, s.Action, map[string]any{"reason": reason}); err != nil {
+		s.Logger().Error("failed to abort session, %s", err)
+	}
+}
+
+// Cancel fails the session, aborts the plugin and stops the invocation serving the session, which
+// closes it afterwards
+func (s *Session) Cancel(reason string) {
+	s.cancelOnce.Do(func() {
+		s.Fail(SESSION_CANCELLED_ERROR, reason)
+		s.Abort(reason)
+		close(s.cancelled)
+	})
+}
+
+// Cancelled is closed once the session is cancelled
+func (s *Session) Cancelled() <-chan struct{} {
+	return s.cancelled
+}
@@ -0,0 +1,130 @@
+package session_manager
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+type recordingRuntime struct {
+	plugin_entities.PluginLifetime
+	runtimeType  plugin_entities.PluginRuntimeType
+	capabilities []string
+
+	l      sync.Mutex
+	events []string
+}
+
+func (r *recordingRuntime) Type() plugin_entities.PluginRuntimeType {
+	return r.runtimeType
+}
+
+func (r *recordingRuntime) RuntimeState() plugin_entities.PluginRuntimeState {
+	return plugin_entities.PluginRuntimeState{Capabilities: r.capabilities}
+}
+
+func (r *recordingRuntime) Write(sessionId string, action access_types.PluginAccessAction, data []byte) {
+	message, _ := parser.UnmarshalJsonBytes[map[string]any](data)
+	r.l.Lock()
+	defer r.l.Unlock()
+	r.events = append(r.events, message["event"].(string))
+}
+
+func (r *recordingRuntime) written() []string {
+	r.l.Lock()
+	defer r.l.Unlock()
+	return append([]string{}, r.events...)
+}
+
+func newAbortTestSession(ctx context.Context, runtimeType plugin_entities.PluginRuntimeType) (*Session, *recordingRuntime) {
+	runtime := &recordingRuntime{
+		runtimeType:  runtimeType,
+		capabilities: []string{string(plugin_entities.PLUGIN_CAPABILITY_ABORT)},
+	}
+	session := NewSession(NewSessionPayload{TenantID: "tenant", IgnoreCache: true, RequestContext: ctx})
+	session.BindRuntime(runtime)
+	return session, runtime
+}
+
+func waitForEvents(t *testing.T, runtime *recordingRuntime, expected int) []string {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) && len(runtime.written()) < expected {
+		time.Sleep(5 * time.Millisecond)
+	}
+	return runtime.written()
+}
+
+func TestSessionAbort(t *testing.T) {
+	// a cancelled request aborts the session once
+	ctx, cancel := context.WithCancel(context.Background())
+	session, runtime := newAbortTestSession(ctx, plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL)
+	cancel()
+	events := waitForEvents(t, runtime, 1)
+	session.Abort("again")
+	session.Close(CloseSessionPayload{IgnoreCache: true})
+	if len(events) != 1 || events[0] != string(PLUGIN_IN_STREAM_EVENT_ABORT) || len(runtime.written()) != 1 {
+		t.Errorf("expected a single abort event, got %v", runtime.written())
+	}
+
+	// sessions ended by the plugin are not aborted
+	session, runtime = newAbortTestSession(context.Background(), plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL)
+	session.PluginEnded()
+	session.Abort("stream closed")
+	if events := runtime.written(); len(events) != 0 {
+		t.Errorf("expected no event after the plugin ended, got %v", events)
+	}
+
+	// closed sessions stop watching the request
+	ctx, cancel = context.WithCancel(context.Background())
+	session, runtime = newAbortTestSession(ctx, plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL)
+	session.Close(CloseSessionPayload{IgnoreCache: true})
+	cancel()
+	if events := waitForEvents(t, runtime, 1); len(events) != 0 {
+		t.Errorf("expected no event after the session closed, got %v", events)
+	}
+
+	// serverless plugins are aborted by cancelling their request instead
+	session, runtime = newAbortTestSession(context.Background(), plugin_entities.PLUGIN_RUNTIME_TYPE_SERVERLESS)
+	session.Abort("stream closed")
+	if events := runtime.written(); len(events) != 0 {
+		t.Errorf("expected no event for serverless plugins, got %v", events)
+	}
+
+	// sdks which don't know the event would reject it
+	session, runtime = newAbortTestSession(context.Background(), plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL)
+	runtime.capabilities = []string{string(plugin_entities.PLUGIN_CAPABILITY_HEARTBEAT)}
+	session.Abort("stream closed")
+	if events := runtime.written(); len(events) != 0 {
+		t.Errorf("expected no event for plugins without the abort capability, got %v", events)
+	}
+}
+
+func TestCancelSession(t *testing.T) {
+	session, runtime := newAbortTestSession(context.Background(), plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL)
+	defer session.Close(CloseSessionPayload{IgnoreCache: true})
+
+	if CancelSession("another tenant", session.ID, "cancelled by caller") {
+		t.Fatal("sessions of another tenant must not be cancelled")
+	}
+	if !CancelSession("tenant", session.ID, "cancelled by caller") {
+		t.Fatal("expected the session to be cancelled")
+	}
+	session.Cancel("again")
+
+	select {
+	case <-session.Cancelled():
+	default:
+		t.Error("expected the session to be marked as cancelled")
+	}
+	if events := runtime.written(); len(events) != 1 || events[0] != string(PLUGIN_IN_STREAM_EVENT_ABORT) {
+		t.Errorf("expected a single abort event, got %v", events)
+	}
+	if failure := session.failure.Load(); failure == nil || failure.ErrorType != SESSION_CANCELLED_ERROR {
+		t.Errorf("expected the session to fail as cancelled, got %+v", failure)
+	}
+}
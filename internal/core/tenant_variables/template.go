@@ -0,0 +1,92 @@
+package tenant_variables
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	KIND_ENV    = "env"
+	KIND_SECRET = "secret"
+)
+
+var (
+	referencePattern = regexp.MustCompile(`\{\{\s*(env|secret)\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+	namePattern      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// lookupFunc returns the value of a variable, ok is false if the tenant has no such variable
+type lookupFunc func(kind string, name string) (value string, ok bool)
+
+// hasReference reports whether a value may contain references, it's used to skip loading
+// the variables of a tenant for settings without any
+func hasReference(value any) bool {
+	switch v := value.(type) {
+	case string:
+		return strings.Contains(v, "{{")
+	case map[string]any:
+		for _, item := range v {
+			if hasReference(item) {
+				return true
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if hasReference(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// interpolate replaces references in strings nested in maps and slices, other `{{...}}`
+// text is kept as it is, referencing an unknown variable is an error
+func interpolate(value any, lookup lookupFunc) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return interpolateString(v, lookup)
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, item := range v {
+			resolved, err := interpolate(item, lookup)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = resolved
+		}
+		return result, nil
+	case []any:
+		result := make([]any, len(v))
+		for i, item := range v {
+			resolved, err := interpolate(item, lookup)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = resolved
+		}
+		return result, nil
+	}
+	return value, nil
+}
+
+func interpolateString(value string, lookup lookupFunc) (string, error) {
+	if !strings.Contains(value, "{{") {
+		return value, nil
+	}
+
+	var missing error
+	result := referencePattern.ReplaceAllStringFunc(value, func(reference string) string {
+		match := referencePattern.FindStringSubmatch(reference)
+		resolved, ok := lookup(match[1], match[2])
+		if !ok && missing == nil {
+			missing = fmt.Errorf("variable %s.%s is not defined", match[1], match[2])
+		}
+		return resolved
+	})
+	if missing != nil {
+		return "", missing
+	}
+	return result, nil
+}
@@ -0,0 +1,102 @@
+package tenant_variables
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+)
+
+func testLookup(kind string, name string) (string, bool) {
+	values := map[string]string{
+		"env.INTERNAL_API_HOST": "api.internal",
+		"secret.API_KEY":        "sk-123",
+	}
+	value, ok := values[kind+"."+name]
+	return value, ok
+}
+
+func TestInterpolate(t *testing.T) {
+	settings := map[string]any{
+		"url":     "https://{{env.INTERNAL_API_HOST}}/v1",
+		"key":     "{{ secret.API_KEY }}",
+		"prompt":  "keep {{user_name}} as it is",
+		"retries": 3,
+		"headers": []any{"Bearer {{secret.API_KEY}}", map[string]any{"host": "{{env.INTERNAL_API_HOST}}"}},
+	}
+
+	resolved, err := interpolate(settings, testLookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]any{
+		"url":     "https://api.internal/v1",
+		"key":     "sk-123",
+		"prompt":  "keep {{user_name}} as it is",
+		"retries": 3,
+		"headers": []any{"Bearer sk-123", map[string]any{"host": "api.internal"}},
+	}
+	if !reflect.DeepEqual(resolved, expected) {
+		t.Fatalf("unexpected result %v", resolved)
+	}
+
+	if settings["url"] != "https://{{env.INTERNAL_API_HOST}}/v1" {
+		t.Fatal("settings must not be modified")
+	}
+}
+
+func TestInterpolateUnknownVariable(t *testing.T) {
+	_, err := interpolate(map[string]any{"key": "{{secret.MISSING}}"}, testLookup)
+	if err == nil {
+		t.Fatal("unknown variables must be reported")
+	}
+
+	// an env variable doesn't satisfy a secret reference
+	_, err = interpolate("{{secret.INTERNAL_API_HOST}}", testLookup)
+	if err == nil {
+		t.Fatal("kinds must not be mixed")
+	}
+}
+
+func TestHasReference(t *testing.T) {
+	if hasReference(map[string]any{"a": "plain", "b": []any{1, "text"}}) {
+		t.Fatal("no reference expected")
+	}
+	if !hasReference(map[string]any{"a": []any{"{{env.X}}"}}) {
+		t.Fatal("nested reference expected")
+	}
+}
+
+func TestCollectCredentials(t *testing.T) {
+	request := requests.RequestInvokeTool{}
+	request.Credentials.Credentials = map[string]any{"key": "{{secret.API_KEY}}"}
+
+	credentials := []reflect.Value{}
+	collectCredentials(reflect.ValueOf(&request).Elem(), &credentials)
+	if len(credentials) != 1 {
+		t.Fatalf("expected credentials of the embedded struct, got %d", len(credentials))
+	}
+
+	resolved, err := interpolate(credentials[0].Interface(), testLookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials[0].Set(reflect.ValueOf(resolved))
+	if request.Credentials.Credentials["key"] != "sk-123" {
+		t.Fatalf("credentials not replaced: %v", request.Credentials.Credentials)
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"API_KEY", "_x", "a1"} {
+		if err := ValidateName(name); err != nil {
+			t.Fatalf("%s should be valid: %s", name, err)
+		}
+	}
+	for _, name := range []string{"", "1a", "a-b", "a.b"} {
+		if err := ValidateName(name); err == nil {
+			t.Fatalf("%s should be invalid", name)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Package tenant_variables stores variables and secrets of tenants and resolves references to them
+// like {{env.INTERNAL_API_HOST}} or {{secret.API_KEY}} in endpoint settings and credentials when a
+// plugin is invoked, the plugin only ever sees the resolved values.
+package tenant_variables
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models/curd"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/kms"
+)
+
+const (
+	MAX_NAME_LENGTH = 127
+	SECRET_MASK     = "******"
+)
+
+var (
+	ErrEncryptionKeyNotSet = errors.New("secrets can not be stored, neither KMS_PROVIDER nor TENANT_VARIABLES_ENCRYPTION_KEY is set")
+
+	cipher = kms.NewCipher("")
+)
+
+// Init sets the key secrets are encrypted with unless a kms provider is configured
+func Init(key string) {
+	cipher = kms.NewCipher(key)
+}
+
+// EncryptSecret encrypts a secret stored outside of the variables of a tenant with the same key, bound
+// to the tenant and the id of the row it's stored in
+func EncryptSecret(tenantID string, id string, value string) (string, error) {
+	if !cipher.Available() {
+		return "", ErrEncryptionKeyNotSet
+	}
+	return cipher.Encrypt([]byte(value), kms.AdditionalData(tenantID, id))
+}
+
+func DecryptSecret(tenantID string, id string, value string) (string, error) {
+	if !cipher.Available() {
+		return "", ErrEncryptionKeyNotSet
+	}
+	decrypted, err := cipher.Decrypt(value, kms.AdditionalData(tenantID, id))
+	if err != nil {
+		return "", err
+	}
+	return string(decrypted), nil
+}
+
+func ValidateName(name string) error {
+	if len(name) > MAX_NAME_LENGTH || !namePattern.MatchString(name) {
+		return fmt.Errorf(
+			"invalid variable name %s, only letters, digits and underscores are allowed and it must not start with a digit",
+			name,
+		)
+	}
+	return nil
+}
+
+func Set(tenantID string, name string, value string, secret bool) (*models.TenantVariable, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	if secret && !cipher.Available() {
+		return nil, ErrEncryptionKeyNotSet
+	}
+
+	variable, err := curd.UpsertTenantVariable(tenantID, name, secret, func(id string) (string, error) {
+		if !secret {
+			return value, nil
+		}
+		return EncryptSecret(tenantID, id, value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return Mask(*variable), nil
+}
+
+// List returns the variables of a tenant with the values of secrets masked
+func List(tenantID string) ([]models.TenantVariable, error) {
+	variables, err := db.GetAll[models.TenantVariable](
+		db.Equal("tenant_id", tenantID),
+		db.OrderBy("name", false),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range variables {
+		variables[i] = *Mask(variables[i])
+	}
+	return variables, nil
+}
+
+func Delete(tenantID string, name string) error {
+	return curd.DeleteTenantVariable(tenantID, name)
+}
+
+func Mask(variable models.TenantVariable) *models.TenantVariable {
+	if variable.Secret {
+		variable.Value = SECRET_MASK
+	}
+	return &variable
+}
+
+// load fetches and decrypts the variables of a tenant
+func load(tenantID string) (lookupFunc, error) {
+	variables, err := db.GetAll[models.TenantVariable](
+		db.Equal("tenant_id", tenantID),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for _, variable := range variables {
+		kind := KIND_ENV
+		value := variable.Value
+		if variable.Secret {
+			kind = KIND_SECRET
+			if !cipher.Available() {
+				return nil, ErrEncryptionKeyNotSet
+			}
+			decrypted, err := DecryptSecret(variable.TenantID, variable.ID, value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt secret %s: %s", variable.Name, err.Error())
+			}
+			value = decrypted
+		}
+		values[kind+"."+variable.Name] = value
+	}
+
+	return func(kind string, name string) (string, bool) {
+		value, ok := values[kind+"."+name]
+		return value, ok
+	}, nil
+}
+
+// Resolve replaces references to the variables of a tenant in settings
+func Resolve(tenantID string, settings map[string]any) (map[string]any, error) {
+	if !hasReference(settings) {
+		return settings, nil
+	}
+
+	lookup, err := load(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := interpolate(settings, lookup)
+	if err != nil {
+		return nil, err
+	}
+	return resolved.(map[string]any), nil
+}
+
+// ResolveCredentials resolves references in the credentials of an invocation request in place,
+// target is a pointer to a request struct, credentials are the map fields tagged `json:"credentials"`
+func ResolveCredentials(tenantID string, target any) error {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Pointer || value.IsNil() {
+		return nil
+	}
+
+	credentials := []reflect.Value{}
+	collectCredentials(value.Elem(), &credentials)
+
+	for _, field := range credentials {
+		resolved, err := Resolve(tenantID, field.Interface().(map[string]any))
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(resolved))
+	}
+	return nil
+}
+
+var credentialsType = reflect.TypeOf(map[string]any{})
+
+func collectCredentials(value reflect.Value, credentials *[]reflect.Value) {
+	if value.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		if field.Anonymous {
+			collectCredentials(value.Field(i), credentials)
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "credentials" && field.Type == credentialsType && !value.Field(i).IsNil() {
+			*credentials = append(*credentials, value.Field(i))
+		}
+	}
+}
@@ -0,0 +1,252 @@
+// Package test_invoke launches an unpacked plugin directory without installing it and invokes one of
+// its tools or models, it backs the test-invoke developer API and command
+package test_invoke
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation/tester"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/standalone_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/packager"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/secret_scanner"
+)
+
+const (
+	DIAGNOSTIC_LEVEL_ERROR   = "error"
+	DIAGNOSTIC_LEVEL_WARNING = "warning"
+
+	TARGET_KIND_TOOL  = "tool"
+	TARGET_KIND_MODEL = "model"
+
+	EVENT_TYPE_DIAGNOSTICS = "diagnostics"
+	EVENT_TYPE_OUTPUT      = "output"
+
+	MAX_PACKAGE_SIZE = int64(50 * 1024 * 1024)
+)
+
+type Diagnostic struct {
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// Target is the tool or model to invoke, parameters of a tool are its tool_parameters while
+// parameters of a model are added to the request, like prompt_messages and model_parameters of a llm
+type Target struct {
+	Kind        string         `json:"kind"`
+	Provider    string         `json:"provider"`
+	Name        string         `json:"name"`
+	ModelType   string         `json:"model_type"` // llm if empty
+	Parameters  map[string]any `json:"parameters"`
+	Credentials map[string]any `json:"credentials"`
+}
+
+// Event is either the diagnostics of the plugin, sent first, or a chunk of its output
+type Event struct {
+	Type        string         `json:"type"`
+	Diagnostics []Diagnostic   `json:"diagnostics,omitempty"`
+	Output      map[string]any `json:"output,omitempty"`
+}
+
+var modelActions = map[plugin_entities.ModelType]access_types.PluginAccessAction{
+	plugin_entities.MODEL_TYPE_LLM:            access_types.PLUGIN_ACCESS_ACTION_INVOKE_LLM,
+	plugin_entities.MODEL_TYPE_TEXT_EMBEDDING: access_types.PLUGIN_ACCESS_ACTION_INVOKE_TEXT_EMBEDDING,
+	plugin_entities.MODEL_TYPE_RERANKING:      access_types.PLUGIN_ACCESS_ACTION_INVOKE_RERANK,
+	plugin_entities.MODEL_TYPE_TTS:            access_types.PLUGIN_ACCESS_ACTION_INVOKE_TTS,
+	plugin_entities.MODEL_TYPE_SPEECH2TEXT:    access_types.PLUGIN_ACCESS_ACTION_INVOKE_SPEECH2TEXT,
+	plugin_entities.MODEL_TYPE_MODERATION:     access_types.PLUGIN_ACCESS_ACTION_INVOKE_MODERATION,
+}
+
+func (t Target) modelType() plugin_entities.ModelType {
+	if t.ModelType == "" {
+		return plugin_entities.MODEL_TYPE_LLM
+	}
+	return plugin_entities.ModelType(t.ModelType)
+}
+
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Level == DIAGNOSTIC_LEVEL_ERROR {
+			return true
+		}
+	}
+	return false
+}
+
+// diagnose turns an error into diagnostics, one for each field a validation failed on
+func diagnose(err error, level string) []Diagnostic {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		diagnostics := []Diagnostic{}
+		for _, fieldError := range validationErrors {
+			diagnostics = append(diagnostics, Diagnostic{
+				Level:   level,
+				Message: fmt.Sprintf("%s failed on the %s rule", fieldError.Namespace(), fieldError.Tag()),
+			})
+		}
+		return diagnostics
+	}
+	return []Diagnostic{{Level: level, Message: err.Error()}}
+}
+
+// Validate checks the manifest, the assets and the target of a plugin directory, hard-coded secrets
+// are reported as warnings
+func Validate(dir string, target Target) (*plugin_entities.PluginDeclaration, []Diagnostic) {
+	pluginDecoder, err := decoder.NewFSPluginDecoder(dir)
+	if err != nil {
+		return nil, diagnose(err, DIAGNOSTIC_LEVEL_ERROR)
+	}
+
+	declaration, err := pluginDecoder.Manifest()
+	if err != nil {
+		return nil, diagnose(err, DIAGNOSTIC_LEVEL_ERROR)
+	}
+
+	diagnostics := []Diagnostic{}
+	if err := declaration.ManifestValidate(); err != nil {
+		diagnostics = append(diagnostics, diagnose(err, DIAGNOSTIC_LEVEL_ERROR)...)
+	}
+	if err := pluginDecoder.CheckAssetsValid(); err != nil {
+		diagnostics = append(diagnostics, diagnose(err, DIAGNOSTIC_LEVEL_ERROR)...)
+	}
+	if err := validateTarget(&declaration, target); err != nil {
+		diagnostics = append(diagnostics, Diagnostic{Level: DIAGNOSTIC_LEVEL_ERROR, Message: err.Error()})
+	}
+
+	if detectors, err := secret_scanner.Detectors(nil); err == nil {
+		findings, err := secret_scanner.Scan(pluginDecoder, detectors)
+		if err != nil {
+			diagnostics = append(diagnostics, diagnose(err, DIAGNOSTIC_LEVEL_WARNING)...)
+		}
+		for _, finding := range findings {
+			diagnostics = append(diagnostics, Diagnostic{
+				Level:   DIAGNOSTIC_LEVEL_WARNING,
+				Message: fmt.Sprintf("hard-coded secret %s", finding.String()),
+			})
+		}
+	}
+
+	return &declaration, diagnostics
+}
+
+func validateTarget(declaration *plugin_entities.PluginDeclaration, target Target) error {
+	switch target.Kind {
+	case TARGET_KIND_TOOL:
+		if declaration.Tool == nil || declaration.Tool.Identity.Name != target.Provider {
+			return fmt.Errorf("tool provider %s is not declared", target.Provider)
+		}
+		for _, tool := range declaration.Tool.Tools {
+			if tool.Identity.Name == target.Name {
+				return nil
+			}
+		}
+		return fmt.Errorf("tool %s is not declared by provider %s", target.Name, target.Provider)
+	case TARGET_KIND_MODEL:
+		if declaration.Model == nil || declaration.Model.Provider != target.Provider {
+			return fmt.Errorf("model provider %s is not declared", target.Provider)
+		}
+		if _, ok := modelActions[target.modelType()]; !ok {
+			return fmt.Errorf("model type %s is not supported", target.modelType())
+		}
+		return nil
+	default:
+		return fmt.Errorf("kind must be %s or %s", TARGET_KIND_TOOL, TARGET_KIND_MODEL)
+	}
+}
+
+// request builds the access type, action and request of the target
+func request(target Target) (access_types.PluginAccessType, access_types.PluginAccessAction, map[string]any) {
+	if target.Kind == TARGET_KIND_TOOL {
+		return access_types.PLUGIN_ACCESS_TYPE_TOOL, access_types.PLUGIN_ACCESS_ACTION_INVOKE_TOOL, map[string]any{
+			"provider":        target.Provider,
+			"tool":            target.Name,
+			"tool_parameters": target.Parameters,
+			"credentials":     target.Credentials,
+		}
+	}
+
+	req := map[string]any{}
+	for k, v := range target.Parameters {
+		req[k] = v
+	}
+	req["provider"] = target.Provider
+	req["model"] = target.Name
+	req["model_type"] = target.modelType()
+	req["credentials"] = target.Credentials
+	return access_types.PLUGIN_ACCESS_TYPE_MODEL, modelActions[target.modelType()], req
+}
+
+// Invoke launches the plugin in a temporary working directory and invokes the target, the plugin is
+// stopped and the directory removed once the output ends, backwards invocations are answered by mocks
+func Invoke(
+	dir string,
+	declaration *plugin_entities.PluginDeclaration,
+	target Target,
+) (*stream.Stream[map[string]any], error) {
+	pluginDecoder, err := decoder.NewFSPluginDecoder(dir)
+	if err != nil {
+		return nil, err
+	}
+	pluginZip, err := packager.NewPackager(pluginDecoder).Pack(MAX_PACKAGE_SIZE)
+	if err != nil {
+		return nil, errors.Join(err, fmt.Errorf("failed to package plugin"))
+	}
+
+	cwd, err := os.MkdirTemp(os.TempDir(), "plugin-test-invoke-*")
+	if err != nil {
+		return nil, err
+	}
+	runtime, err := standalone_runtime.GetRuntime(pluginZip, cwd)
+	if err != nil {
+		standalone_runtime.ClearTestingPath(cwd)
+		return nil, errors.Join(err, fmt.Errorf("failed to launch plugin"))
+	}
+	release := func() {
+		runtime.Stop()
+		standalone_runtime.ClearTestingPath(cwd)
+	}
+
+	pluginUniqueIdentifier, err := runtime.Identity()
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	accessType, action, req := request(target)
+	session := session_manager.NewSession(
+		session_manager.NewSessionPayload{
+			UserID:                 uuid.New().String(),
+			TenantID:               uuid.New().String(),
+			PluginUniqueIdentifier: pluginUniqueIdentifier,
+			ClusterID:              uuid.New().String(),
+			InvokeFrom:             accessType,
+			Action:                 action,
+			Declaration:            declaration,
+			BackwardsInvocation:    tester.NewMockedDifyInvocation(),
+			IgnoreCache:            true,
+		},
+	)
+
+	output, err := standalone_runtime.RunOnceWithSession[map[string]any, map[string]any](runtime, session, req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	output.OnClose(func() {
+		routine.Submit(map[string]string{
+			"module":   "test_invoke",
+			"function": "Invoke",
+		}, release)
+	})
+	return output, nil
+}
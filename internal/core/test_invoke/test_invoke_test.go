@@ -0,0 +1,53 @@
+package test_invoke
+
+import (
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func TestValidateTarget(t *testing.T) {
+	declaration := &plugin_entities.PluginDeclaration{}
+	declaration.Tool = &plugin_entities.ToolProviderDeclaration{}
+	declaration.Tool.Identity.Name = "search"
+	declaration.Tool.Tools = []plugin_entities.ToolDeclaration{{}}
+	declaration.Tool.Tools[0].Identity.Name = "web"
+	declaration.Model = &plugin_entities.ModelProviderDeclaration{Provider: "openai"}
+
+	cases := []struct {
+		target Target
+		valid  bool
+	}{
+		{Target{Kind: TARGET_KIND_TOOL, Provider: "search", Name: "web"}, true},
+		{Target{Kind: TARGET_KIND_TOOL, Provider: "search", Name: "news"}, false},
+		{Target{Kind: TARGET_KIND_TOOL, Provider: "openai", Name: "web"}, false},
+		{Target{Kind: TARGET_KIND_MODEL, Provider: "openai", Name: "gpt-4o"}, true},
+		{Target{Kind: TARGET_KIND_MODEL, Provider: "openai", Name: "tts-1", ModelType: "tts"}, true},
+		{Target{Kind: TARGET_KIND_MODEL, Provider: "openai", Name: "gpt-4o", ModelType: "video"}, false},
+		{Target{Kind: "agent", Provider: "openai", Name: "gpt-4o"}, false},
+	}
+	for _, c := range cases {
+		if err := validateTarget(declaration, c.target); (err == nil) != c.valid {
+			t.Errorf("%+v: expected valid %v, got %v", c.target, c.valid, err)
+		}
+	}
+}
+
+func TestRequest(t *testing.T) {
+	accessType, action, req := request(Target{
+		Kind:       TARGET_KIND_MODEL,
+		Provider:   "openai",
+		Name:       "gpt-4o",
+		Parameters: map[string]any{"prompt_messages": []any{}, "model": "ignored"},
+	})
+	if accessType != access_types.PLUGIN_ACCESS_TYPE_MODEL || action != access_types.PLUGIN_ACCESS_ACTION_INVOKE_LLM {
+		t.Fatalf("unexpected access %s %s", accessType, action)
+	}
+	if req["model"] != "gpt-4o" || req["model_type"] != plugin_entities.MODEL_TYPE_LLM {
+		t.Fatalf("unexpected request %v", req)
+	}
+	if _, ok := req["prompt_messages"]; !ok {
+		t.Fatalf("expected the parameters to be added to the request, got %v", req)
+	}
+}
@@ -0,0 +1,200 @@
+package tool_files
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	DIFY_FILE_IDENTITY = "__dify__file__"
+)
+
+// fileType maps a mime type to the file types dify distinguishes
+func fileType(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return "image"
+	case strings.HasPrefix(mimeType, "audio/"):
+		return "audio"
+	case strings.HasPrefix(mimeType, "video/"):
+		return "video"
+	case strings.HasPrefix(mimeType, "text/"),
+		mimeType == "application/pdf",
+		mimeType == "application/json",
+		strings.HasPrefix(mimeType, "application/vnd.openxmlformats-officedocument"),
+		strings.HasPrefix(mimeType, "application/vnd.ms-"),
+		mimeType == "application/msword":
+		return "document"
+	default:
+		return "custom"
+	}
+}
+
+// object builds the file object the plugin sdk reads files from, the content is downloaded from url
+func (b *Broker) object(file *File) map[string]any {
+	link := b.URL(file)
+	return map[string]any{
+		"dify_model_identity": DIFY_FILE_IDENTITY,
+		"id":                  file.ID,
+		"tenant_id":           file.TenantID,
+		"type":                fileType(file.MimeType),
+		"transfer_method":     "remote_url",
+		"remote_url":          link,
+		"url":                 link,
+		"filename":            file.Filename,
+		"extension":           path.Ext(file.Filename),
+		"mime_type":           file.MimeType,
+		"size":                file.Size,
+	}
+}
+
+// signDifyURL signs a link to a file of dify the way dify signs file previews, with its secret key
+func (b *Broker) signDifyURL(link string, id string, now time.Time) string {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(b.config.DifySecretKey))
+	mac.Write([]byte("file-preview|" + id + "|" + timestamp + "|" + hex.EncodeToString(nonce)))
+	return fmt.Sprintf(
+		"%s?timestamp=%s&nonce=%s&sign=%s",
+		link, timestamp, hex.EncodeToString(nonce), base64.URLEncoding.EncodeToString(mac.Sum(nil)),
+	)
+}
+
+// difyObject builds the file object of a file uploaded to dify or generated by a tool of dify, the
+// content is downloaded from dify, the other fields are taken from the reference
+func (b *Broker) difyObject(tenantID string, reference map[string]any) (map[string]any, error) {
+	if b.config.DifyFilesURL == "" {
+		return nil, fmt.Errorf("files of dify are not supported")
+	}
+
+	transferMethod, _ := reference["transfer_method"].(string)
+	id, _ := reference["related_id"].(string)
+	extension, _ := reference["extension"].(string)
+	var link string
+	switch transferMethod {
+	case "local_file":
+		if uploadFileID, _ := reference["upload_file_id"].(string); uploadFileID != "" {
+			id = uploadFileID
+		}
+		link = fmt.Sprintf("%s/files/%s/file-preview", b.config.DifyFilesURL, id)
+	case "tool_file":
+		if toolFileID, _ := reference["tool_file_id"].(string); toolFileID != "" {
+			id = toolFileID
+		}
+		if strings.ContainsAny(extension, "/?#") {
+			return nil, fmt.Errorf("invalid extension %s", extension)
+		}
+		link = fmt.Sprintf("%s/files/tools/%s%s", b.config.DifyFilesURL, id, extension)
+	}
+	if id == "" || strings.ContainsAny(id, "/?#") {
+		return nil, fmt.Errorf("expected the id of a file of dify, got %q", id)
+	}
+
+	link = b.signDifyURL(link, id, time.Now())
+	object := map[string]any{
+		"dify_model_identity": DIFY_FILE_IDENTITY,
+		"id":                  id,
+		"tenant_id":           tenantID,
+		"type":                "custom",
+		"transfer_method":     transferMethod,
+		"related_id":          id,
+		"remote_url":          link,
+		"url":                 link,
+		"extension":           extension,
+	}
+	for _, field := range []string{"type", "filename", "mime_type", "size"} {
+		if value, ok := reference[field]; ok {
+			object[field] = value
+		}
+	}
+	return object, nil
+}
+
+// resolve turns a file value into a file object, values are the id of a staged file, either as is or
+// as the upload_file_id of an object, objects with the transfer method of a file of dify which isn't
+// staged reference files of dify, files dify already resolved are passed through
+func (b *Broker) resolve(tenantID string, value any) (any, error) {
+	var id string
+	switch v := value.(type) {
+	case string:
+		id = v
+	case map[string]any:
+		if identity, _ := v["dify_model_identity"].(string); identity == DIFY_FILE_IDENTITY {
+			return v, nil
+		}
+		id, _ = v["upload_file_id"].(string)
+		switch v["transfer_method"] {
+		case "local_file", "tool_file":
+			if id != "" {
+				if file, err := b.Get(tenantID, id); err == nil {
+					return b.object(file), nil
+				}
+			}
+			return b.difyObject(tenantID, v)
+		}
+	}
+	if id == "" {
+		return nil, fmt.Errorf("expected a file id, got %T", value)
+	}
+
+	file, err := b.Get(tenantID, id)
+	if err != nil {
+		return nil, fmt.Errorf("file %s: %s", id, err.Error())
+	}
+	return b.object(file), nil
+}
+
+// ResolveParameters replaces the values of file and files parameters with file objects, a single
+// value of a files parameter becomes a list
+func (b *Broker) ResolveParameters(
+	tenantID string,
+	parameters []plugin_entities.ToolParameter,
+	values map[string]any,
+) (map[string]any, error) {
+	resolved := make(map[string]any, len(values))
+	for name, value := range values {
+		resolved[name] = value
+	}
+
+	for _, parameter := range parameters {
+		value, ok := resolved[parameter.Name]
+		if !ok || value == nil || value == "" {
+			continue
+		}
+
+		switch parameter.Type {
+		case plugin_entities.TOOL_PARAMETER_TYPE_FILE:
+			file, err := b.resolve(tenantID, value)
+			if err != nil {
+				return nil, &plugin_entities.ToolParameterError{Parameter: parameter.Name, Reason: err.Error()}
+			}
+			resolved[parameter.Name] = file
+		case plugin_entities.TOOL_PARAMETER_TYPE_FILES:
+			list, ok := value.([]any)
+			if !ok {
+				list = []any{value}
+			}
+			files := make([]any, 0, len(list))
+			for _, item := range list {
+				file, err := b.resolve(tenantID, item)
+				if err != nil {
+					return nil, &plugin_entities.ToolParameterError{Parameter: parameter.Name, Reason: err.Error()}
+				}
+				files = append(files, file)
+			}
+			resolved[parameter.Name] = files
+		}
+	}
+	return resolved, nil
+}
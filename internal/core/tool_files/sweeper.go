@@ -0,0 +1,41 @@
+package tool_files
+
+import (
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/schedule"
+)
+
+const (
+	// expired files are swept every interval unless TOOL_FILE_EXPIRY_SWEEP_SCHEDULE is set
+	EXPIRY_SWEEP_INTERVAL = 10 * time.Minute
+	EXPIRY_SWEEP_LOCK_KEY = "tool_files:expiry:lock"
+)
+
+// StartExpirySweeper deletes expired files at every activation of sweepSchedule on one node of the cluster
+func StartExpirySweeper(sweepSchedule schedule.Schedule) {
+	schedule.Run("tool_files_expiry", sweepSchedule, func() {
+		if broker == nil {
+			return
+		}
+
+		now := time.Now()
+		ok, err := cache.SetNX(EXPIRY_SWEEP_LOCK_KEY, true, sweepSchedule.Next(now).Sub(now)/2)
+		if err != nil {
+			log.Error("failed to acquire tool files expiry lock: %s", err.Error())
+			return
+		}
+		if !ok {
+			return
+		}
+
+		purged, err := broker.PurgeExpired(now)
+		if err != nil {
+			log.Error("failed to purge expired tool files: %s", err.Error())
+		} else if purged > 0 {
+			log.Info("purged %d expired tool files", purged)
+		}
+	})
+}
@@ -0,0 +1,233 @@
+// Package tool_files stages files uploaded for file parameters of tools in the storage and hands them
+// to plugins as links to the daemon, signed so that plugins can download them without the server key
+package tool_files
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/oss"
+)
+
+const (
+	METADATA_SUFFIX = ".json"
+)
+
+var (
+	ErrFileNotFound     = errors.New("file not found")
+	ErrFileTooLarge     = errors.New("file size exceeds the maximum limit")
+	ErrInvalidSignature = errors.New("invalid or expired signature")
+)
+
+type Config struct {
+	// URLBase is the address of the daemon plugins download files from
+	URLBase    string
+	SigningKey string
+	Path       string
+	MaxSize    int64
+	TTL        time.Duration
+	// DifyFilesURL is FILES_URL of dify, files uploaded to dify are only resolved if it's set
+	DifyFilesURL  string
+	DifySecretKey string
+}
+
+// File is the metadata of a staged file, stored next to its content
+type File struct {
+	ID        string    `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	Filename  string    `json:"filename"`
+	MimeType  string    `json:"mime_type"`
+	Size      int64     `json:"size"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type Broker struct {
+	storage oss.StreamingOSS
+	config  Config
+}
+
+var broker *Broker
+
+func Init(storage oss.StreamingOSS, config Config) {
+	broker = NewBroker(storage, config)
+}
+
+// Enabled reports whether files are brokered, this requires a url plugins can reach the daemon at
+func Enabled() bool {
+	return broker != nil && broker.config.URLBase != ""
+}
+
+func GetBroker() *Broker {
+	return broker
+}
+
+func NewBroker(storage oss.StreamingOSS, config Config) *Broker {
+	config.URLBase = strings.TrimSuffix(config.URLBase, "/")
+	config.DifyFilesURL = strings.TrimSuffix(config.DifyFilesURL, "/")
+	return &Broker{storage: storage, config: config}
+}
+
+func (b *Broker) contentKey(id string) string {
+	return path.Join(b.config.Path, id)
+}
+
+func (b *Broker) metadataKey(id string) string {
+	return path.Join(b.config.Path, id+METADATA_SUFFIX)
+}
+
+// limitedReader fails once more than limit bytes are read instead of truncating like io.LimitReader
+type limitedReader struct {
+	reader io.Reader
+	limit  int64
+	read   int64
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.read += int64(n)
+	if r.limit > 0 && r.read > r.limit {
+		return n, ErrFileTooLarge
+	}
+	return n, err
+}
+
+// Stage saves the content of reader and returns the staged file, it expires after the configured ttl
+func (b *Broker) Stage(tenantID string, filename string, mimeType string, reader io.Reader) (*File, error) {
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	file := &File{
+		ID:        uuid.New().String(),
+		TenantID:  tenantID,
+		Filename:  path.Base(filename),
+		MimeType:  mimeType,
+		ExpiresAt: time.Now().Add(b.config.TTL),
+	}
+
+	limited := &limitedReader{reader: reader, limit: b.config.MaxSize}
+	if err := b.storage.SaveStream(b.contentKey(file.ID), limited); err != nil {
+		b.storage.Delete(b.contentKey(file.ID))
+		if errors.Is(err, ErrFileTooLarge) {
+			return nil, ErrFileTooLarge
+		}
+		return nil, err
+	}
+	file.Size = limited.read
+
+	metadata, err := json.Marshal(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.storage.Save(b.metadataKey(file.ID), metadata); err != nil {
+		b.storage.Delete(b.contentKey(file.ID))
+		return nil, err
+	}
+	return file, nil
+}
+
+func (b *Broker) load(id string) (*File, error) {
+	data, err := b.storage.Load(b.metadataKey(id))
+	if err != nil {
+		return nil, ErrFileNotFound
+	}
+	file := &File{}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Get returns a staged file of the tenant, expired files are deleted
+func (b *Broker) Get(tenantID string, id string) (*File, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrFileNotFound
+	}
+	file, err := b.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if file.TenantID != tenantID {
+		return nil, ErrFileNotFound
+	}
+	if time.Now().After(file.ExpiresAt) {
+		b.delete(id)
+		return nil, ErrFileNotFound
+	}
+	return file, nil
+}
+
+func (b *Broker) delete(id string) {
+	b.storage.Delete(b.contentKey(id))
+	b.storage.Delete(b.metadataKey(id))
+}
+
+func (b *Broker) sign(tenantID string, id string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(b.config.SigningKey))
+	fmt.Fprintf(mac, "%s/%s/%d", tenantID, id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// URL returns the signed download url of a file, it's valid as long as the file
+func (b *Broker) URL(file *File) string {
+	expires := file.ExpiresAt.Unix()
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", b.sign(file.TenantID, file.ID, expires))
+	return fmt.Sprintf("%s/files/%s/%s?%s", b.config.URLBase, file.TenantID, file.ID, query.Encode())
+}
+
+// Open checks the signature of a download url and opens the file, caller must close the reader
+func (b *Broker) Open(tenantID string, id string, expires string, signature string) (*File, io.ReadCloser, error) {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return nil, nil, ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(b.sign(tenantID, id, expiresAt))) {
+		return nil, nil, ErrInvalidSignature
+	}
+
+	file, err := b.Get(tenantID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader, err := b.storage.LoadStream(b.contentKey(id))
+	if err != nil {
+		return nil, nil, ErrFileNotFound
+	}
+	return file, reader, nil
+}
+
+// PurgeExpired deletes the files expired before now and returns how many were deleted
+func (b *Broker) PurgeExpired(now time.Time) (int, error) {
+	paths, err := b.storage.List(b.config.Path)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, p := range paths {
+		name := path.Base(p.Path)
+		if p.IsDir || !strings.HasSuffix(name, METADATA_SUFFIX) {
+			continue
+		}
+		id := strings.TrimSuffix(name, METADATA_SUFFIX)
+		file, err := b.load(id)
+		if err != nil || now.Before(file.ExpiresAt) {
+			continue
+		}
+		b.delete(id)
+		purged++
+	}
+	return purged, nil
+}
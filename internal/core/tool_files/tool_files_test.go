@@ -0,0 +1,209 @@
+package tool_files
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+	"github.com/langgenius/dify-plugin-daemon/internal/oss"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func newTestBroker(t *testing.T, ttl time.Duration) *Broker {
+	storage, err := oss.Load("local", cloudoss.OSSArgs{
+		Local: &cloudoss.Local{Path: t.TempDir()},
+	})
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+	return NewBroker(storage, Config{
+		URLBase:    "http://daemon:5002/",
+		SigningKey: "key",
+		Path:       "tool_files",
+		MaxSize:    16,
+		TTL:        ttl,
+	})
+}
+
+func TestStageAndOpen(t *testing.T) {
+	broker := newTestBroker(t, time.Hour)
+
+	file, err := broker.Stage("tenant", "../report.pdf", "application/pdf", strings.NewReader("content"))
+	if err != nil {
+		t.Fatalf("failed to stage file: %v", err)
+	}
+	if file.Filename != "report.pdf" || file.Size != 7 {
+		t.Fatalf("unexpected file %+v", file)
+	}
+
+	link, err := url.Parse(broker.URL(file))
+	if err != nil {
+		t.Fatalf("invalid url: %v", err)
+	}
+	if link.Host != "daemon:5002" || link.Path != "/files/tenant/"+file.ID {
+		t.Fatalf("unexpected url %s", link)
+	}
+
+	query := link.Query()
+	_, reader, err := broker.Open("tenant", file.ID, query.Get("expires"), query.Get("signature"))
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	defer reader.Close()
+	content, _ := io.ReadAll(reader)
+	if string(content) != "content" {
+		t.Fatalf("unexpected content %q", content)
+	}
+
+	if _, _, err := broker.Open("other", file.ID, query.Get("expires"), query.Get("signature")); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected the signature of another tenant to be rejected, got %v", err)
+	}
+	if _, _, err := broker.Open("tenant", file.ID, query.Get("expires"), "forged"); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected a forged signature to be rejected, got %v", err)
+	}
+	if _, err := broker.Get("other", file.ID); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("expected the file to be hidden from other tenants, got %v", err)
+	}
+}
+
+func TestStageTooLarge(t *testing.T) {
+	broker := newTestBroker(t, time.Hour)
+
+	_, err := broker.Stage("tenant", "large.txt", "text/plain", strings.NewReader(strings.Repeat("a", 17)))
+	if !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("expected file too large, got %v", err)
+	}
+}
+
+func TestPurgeExpired(t *testing.T) {
+	broker := newTestBroker(t, -time.Second)
+
+	file, err := broker.Stage("tenant", "a.txt", "text/plain", strings.NewReader("a"))
+	if err != nil {
+		t.Fatalf("failed to stage file: %v", err)
+	}
+
+	purged, err := broker.PurgeExpired(time.Now())
+	if err != nil || purged != 1 {
+		t.Fatalf("expected 1 purged file, got %d, %v", purged, err)
+	}
+	if _, err := broker.load(file.ID); !errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("expected the file to be deleted, got %v", err)
+	}
+}
+
+func TestResolveParameters(t *testing.T) {
+	broker := newTestBroker(t, time.Hour)
+
+	file, err := broker.Stage("tenant", "a.png", "image/png", strings.NewReader("png"))
+	if err != nil {
+		t.Fatalf("failed to stage file: %v", err)
+	}
+
+	difyFile := map[string]any{"dify_model_identity": DIFY_FILE_IDENTITY, "url": "http://dify/files/1"}
+	parameters := []plugin_entities.ToolParameter{
+		{Name: "image", Type: plugin_entities.TOOL_PARAMETER_TYPE_FILE},
+		{Name: "attachments", Type: plugin_entities.TOOL_PARAMETER_TYPE_FILES},
+		{Name: "query", Type: plugin_entities.TOOL_PARAMETER_TYPE_STRING},
+	}
+
+	resolved, err := broker.ResolveParameters("tenant", parameters, map[string]any{
+		"image":       file.ID,
+		"attachments": map[string]any{"upload_file_id": file.ID},
+		"query":       file.ID,
+	})
+	if err != nil {
+		t.Fatalf("failed to resolve parameters: %v", err)
+	}
+
+	image, ok := resolved["image"].(map[string]any)
+	if !ok || image["type"] != "image" || image["dify_model_identity"] != DIFY_FILE_IDENTITY {
+		t.Fatalf("unexpected image %v", resolved["image"])
+	}
+	if !strings.HasPrefix(image["url"].(string), "http://daemon:5002/files/tenant/") {
+		t.Fatalf("unexpected url %v", image["url"])
+	}
+	attachments, ok := resolved["attachments"].([]any)
+	if !ok || len(attachments) != 1 {
+		t.Fatalf("expected a single file to become a list, got %v", resolved["attachments"])
+	}
+	if resolved["query"] != file.ID {
+		t.Fatalf("expected parameters of other types to be kept, got %v", resolved["query"])
+	}
+
+	resolved, err = broker.ResolveParameters("tenant", parameters, map[string]any{"image": difyFile})
+	if err != nil || resolved["image"].(map[string]any)["url"] != "http://dify/files/1" {
+		t.Fatalf("expected files resolved by dify to be passed through, got %v, %v", resolved["image"], err)
+	}
+
+	_, err = broker.ResolveParameters("other", parameters, map[string]any{"image": file.ID})
+	var parameterError *plugin_entities.ToolParameterError
+	if !errors.As(err, &parameterError) || parameterError.Parameter != "image" {
+		t.Fatalf("expected files of other tenants to be rejected, got %v", err)
+	}
+}
+
+func TestResolveDifyFiles(t *testing.T) {
+	broker := newTestBroker(t, time.Hour)
+	parameters := []plugin_entities.ToolParameter{{Name: "document", Type: plugin_entities.TOOL_PARAMETER_TYPE_FILE}}
+	reference := map[string]any{"transfer_method": "local_file", "upload_file_id": "upload", "filename": "a.pdf"}
+
+	if _, err := broker.ResolveParameters("tenant", parameters, map[string]any{"document": reference}); err == nil {
+		t.Fatal("expected files of dify to be rejected without the files url of dify")
+	}
+
+	broker.config.DifyFilesURL = "http://dify:5001"
+	broker.config.DifySecretKey = "dify-secret"
+	verify := func(value any, prefix string, id string) map[string]any {
+		resolved, err := broker.ResolveParameters("tenant", parameters, map[string]any{"document": value})
+		if err != nil {
+			t.Fatalf("failed to resolve %v: %v", value, err)
+		}
+		object := resolved["document"].(map[string]any)
+		link, err := url.Parse(object["url"].(string))
+		if err != nil || !strings.HasPrefix(object["url"].(string), prefix+"?") {
+			t.Fatalf("unexpected url %v", object["url"])
+		}
+		query := link.Query()
+		mac := hmac.New(sha256.New, []byte("dify-secret"))
+		mac.Write([]byte("file-preview|" + id + "|" + query.Get("timestamp") + "|" + query.Get("nonce")))
+		if query.Get("sign") != base64.URLEncoding.EncodeToString(mac.Sum(nil)) {
+			t.Fatalf("expected the url to be signed like dify signs file previews, got %s", link)
+		}
+		return object
+	}
+
+	object := verify(reference, "http://dify:5001/files/upload/file-preview", "upload")
+	if object["filename"] != "a.pdf" || object["dify_model_identity"] != DIFY_FILE_IDENTITY {
+		t.Fatalf("unexpected file object %v", object)
+	}
+	verify(
+		map[string]any{"transfer_method": "tool_file", "related_id": "generated", "extension": ".png"},
+		"http://dify:5001/files/tools/generated.png", "generated",
+	)
+
+	// staged files keep being served by the daemon
+	file, err := broker.Stage("tenant", "b.txt", "text/plain", strings.NewReader("text"))
+	if err != nil {
+		t.Fatalf("failed to stage file: %v", err)
+	}
+	resolved, err := broker.ResolveParameters("tenant", parameters, map[string]any{
+		"document": map[string]any{"transfer_method": "local_file", "upload_file_id": file.ID},
+	})
+	if err != nil || !strings.HasPrefix(resolved["document"].(map[string]any)["url"].(string), "http://daemon:5002/") {
+		t.Fatalf("expected the staged file to be resolved, got %v, %v", resolved["document"], err)
+	}
+
+	if _, err := broker.ResolveParameters("tenant", parameters, map[string]any{
+		"document": map[string]any{"transfer_method": "local_file", "upload_file_id": "../admin"},
+	}); err == nil {
+		t.Fatal("expected ids with path separators to be rejected")
+	}
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"gorm.io/gorm"
@@ -47,6 +48,17 @@ func DeleteByCondition[T any](condition T, ctx ...*gorm.DB) error {
 	return DifyPluginDB.Where(condition).Delete(&model).Error
 }
 
+// DeleteBy deletes everything matched by query and returns the number of deleted rows
+func DeleteBy[T any](query ...GenericQuery) (int64, error) {
+	var model T
+	tmp := DifyPluginDB
+	for _, q := range query {
+		tmp = q(tmp)
+	}
+	result := tmp.Delete(&model)
+	return result.RowsAffected, result.Error
+}
+
 func ReplaceAssociation[T any, R any](source *T, field string, associations []R, ctx ...*gorm.DB) error {
 	if len(ctx) > 0 {
 		return ctx[0].Model(source).Association(field).Replace(associations)
@@ -65,7 +77,7 @@ type genericComparableConstraint interface {
 	int | int8 | int16 | int32 | int64 |
 		uint | uint8 | uint16 | uint32 | uint64 |
 		float32 | float64 |
-		bool
+		bool | time.Time
 }
 
 type genericEqualConstraint interface {
@@ -182,6 +194,15 @@ func Fields(fields ...string) GenericQuery {
 	}
 }
 
+func GroupBy(fields ...string) GenericQuery {
+	return func(tx *gorm.DB) *gorm.DB {
+		for _, field := range fields {
+			tx = tx.Group(field)
+		}
+		return tx
+	}
+}
+
 func Preload(model string, args ...interface{}) GenericQuery {
 	return func(tx *gorm.DB) *gorm.DB {
 		return tx.Preload(model, args...)
@@ -288,7 +309,8 @@ func GetSum[T any, R genericComparableConstraint](fields string, query ...Generi
 	for _, q := range query {
 		tmp = q(tmp)
 	}
-	err := tmp.Model(&model).Select(fmt.Sprintf("SUM(%s)", fields)).Scan(&sum).Error
+	// nothing matched sums to zero instead of NULL
+	err := tmp.Model(&model).Select(fmt.Sprintf("COALESCE(SUM(%s), 0)", fields)).Scan(&sum).Error
 	return sum, err
 }
 
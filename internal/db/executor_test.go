@@ -1,6 +1,7 @@
 package db
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/google/uuid"
@@ -34,6 +35,13 @@ func TestTransactionOnMySQL(t *testing.T) {
 	})
 }
 
+func TestTransactionOnSQLite(t *testing.T) {
+	testTransaction(t, &app.Config{
+		DBType:       "sqlite",
+		DBSqlitePath: filepath.Join(t.TempDir(), "testing.db"),
+	})
+}
+
 func testTransaction(t *testing.T, config *app.Config) {
 	config.SetDefault()
 	Init(config)
@@ -111,3 +119,21 @@ func testTransaction(t *testing.T, config *app.Config) {
 		t.Fatal(err.Error())
 	}
 }
+
+func TestTenantVariablesAreUnique(t *testing.T) {
+	config := &app.Config{
+		DBType:       "sqlite",
+		DBSqlitePath: filepath.Join(t.TempDir(), "testing.db"),
+	}
+	config.SetDefault()
+	Init(config)
+	defer Close()
+
+	// a variable is unique per tenant, other tenants may use the same name
+	tenantID := uuid.New().String()
+	assert.NoError(t, Create(&models.TenantVariable{TenantID: tenantID, Name: "TOKEN", Value: "value"}))
+	assert.NoError(t, Create(&models.TenantVariable{TenantID: uuid.New().String(), Name: "TOKEN", Value: "value"}))
+
+	assert.Error(t, Create(&models.TenantVariable{TenantID: tenantID, Name: "TOKEN", Value: "again"}))
+	assert.NoError(t, Create(&models.TenantVariable{TenantID: tenantID, Name: "OTHER", Value: "value"}))
+}
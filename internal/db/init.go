@@ -3,35 +3,69 @@ package db
 import (
 	"github.com/langgenius/dify-plugin-daemon/internal/db/mysql"
 	"github.com/langgenius/dify-plugin-daemon/internal/db/pg"
+	"github.com/langgenius/dify-plugin-daemon/internal/db/sqlite"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"gorm.io/gorm"
 )
 
 func autoMigrate() error {
 	err := DifyPluginDB.AutoMigrate(
 		models.Plugin{},
 		models.PluginInstallation{},
+		models.PluginVersionInstallation{},
 		models.PluginDeclaration{},
 		models.Endpoint{},
+		models.EndpointDomain{},
 		models.ServerlessRuntime{},
 		models.ToolInstallation{},
 		models.AIModelInstallation{},
 		models.InstallTask{},
 		models.TenantStorage{},
+		models.PersistenceKey{},
 		models.AgentStrategyInstallation{},
+		models.PluginPermissionConsent{},
+		models.PluginBundle{},
+		models.PluginDependency{},
+		models.TenantVariable{},
+		models.TenantSetting{},
+		models.PluginScheduledUpgrade{},
+		models.OAuthCredential{},
+		models.PluginInvocation{},
+		models.PluginInvocationRollup{},
+		models.AnalyticsWatermark{},
+		models.PluginSecurityReport{},
+		models.RegistryPlugin{},
+		models.PluginRollout{},
+		models.PluginInstallApproval{},
+		models.AuditRecord{},
+		models.APIKey{},
 	)
 
 	if err != nil {
 		return err
 	}
 
+	if err := backfillPluginDeclarationColumns(); err != nil {
+		return err
+	}
+
+	// sqlite is always created by the current models, there is no legacy column to fix
+	if DifyPluginDB.Dialector.Name() == "sqlite" {
+		return nil
+	}
+
 	// check if "declaration" column exists in Plugin/ServerlessRuntime/ToolInstallation/AIModelInstallation/AgentStrategyInstallation
 	// drop the "declaration" column not null constraint if exists
 	ignoreDeclarationColumn := func(table string) error {
 		if DifyPluginDB.Migrator().HasColumn(table, "declaration") {
 			// remove NOT NULL constraint on declaration column
-			if err := DifyPluginDB.Exec("ALTER TABLE " + table + " ALTER COLUMN declaration DROP NOT NULL").Error; err != nil {
+			sql := "ALTER TABLE " + table + " ALTER COLUMN declaration DROP NOT NULL"
+			if DifyPluginDB.Dialector.Name() == "mysql" {
+				sql = "ALTER TABLE " + table + " MODIFY COLUMN declaration LONGTEXT NULL"
+			}
+			if err := DifyPluginDB.Exec(sql).Error; err != nil {
 				return err
 			}
 		}
@@ -55,6 +89,23 @@ func autoMigrate() error {
 	return nil
 }
 
+// backfillPluginDeclarationColumns derives the columns of declarations stored before they were added
+func backfillPluginDeclarationColumns() error {
+	var declarations []models.PluginDeclaration
+	return DifyPluginDB.Where("category = ?", "").FindInBatches(&declarations, 100, func(tx *gorm.DB, batch int) error {
+		for i := range declarations {
+			declarations[i].DeriveColumns()
+			if err := tx.Model(&declarations[i]).UpdateColumns(map[string]any{
+				"category":    declarations[i].Category,
+				"model_types": declarations[i].ModelTypes,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
 func Init(config *app.Config) {
 	var err error
 	if config.DBType == "postgresql" {
@@ -72,7 +123,8 @@ func Init(config *app.Config) {
 			Charset:         config.DBCharset,
 			Extras:          config.DBExtras,
 		})
-	} else if config.DBType == "mysql" {
+	} else if config.DBType == "mysql" || config.DBType == "mariadb" {
+		// mariadb speaks the mysql protocol, differences are handled by the dialector
 		DifyPluginDB, err = mysql.InitPluginDB(&mysql.MySQLConfig{
 			Host:            config.DBHost,
 			Port:            int(config.DBPort),
@@ -87,6 +139,14 @@ func Init(config *app.Config) {
 			Charset:         config.DBCharset,
 			Extras:          config.DBExtras,
 		})
+	} else if config.DBType == "sqlite" {
+		DifyPluginDB, err = sqlite.InitPluginDB(&sqlite.SQLiteConfig{
+			Path:            config.DBSqlitePath,
+			MaxIdleConns:    config.DBMaxIdleConns,
+			MaxOpenConns:    config.DBMaxOpenConns,
+			ConnMaxLifetime: config.DBConnMaxLifetime,
+			BusyTimeout:     config.DBSqliteBusyTimeout,
+		})
 	} else {
 		log.Panic("unsupported database type: %v", config.DBType)
 	}
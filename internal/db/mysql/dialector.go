@@ -1,6 +1,8 @@
 package mysql
 
 import (
+	"strings"
+
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -16,6 +18,10 @@ func (dialector myDialector) Migrator(db *gorm.DB) gorm.Migrator {
 }
 
 func (dialector myDialector) DataTypeOf(field *schema.Field) string {
+	if isJSONField(field) {
+		return jsonDataType(dialector.ServerVersion)
+	}
+
 	dataType := dialector.Dialector.DataTypeOf(field)
 	switch dataType {
 	case "uuid":
@@ -32,7 +38,9 @@ type myMigrator struct {
 }
 
 func (migrator myMigrator) FullDataTypeOf(field *schema.Field) clause.Expr {
-	if field.DataType == "uuid" {
+	if isJSONField(field) {
+		field.DataType = schema.DataType(jsonDataType(migrator.Migrator.Dialector.ServerVersion))
+	} else if field.DataType == "uuid" {
 		field.DataType = "char(36)"
 		if field.HasDefaultValue && field.DefaultValue == "uuid_generate_v4()" {
 			field.HasDefaultValue = false
@@ -50,3 +58,20 @@ func (migrator myMigrator) FullDataTypeOf(field *schema.Field) clause.Expr {
 	}
 	return migrator.Migrator.FullDataTypeOf(field)
 }
+
+func isJSONField(field *schema.Field) bool {
+	return strings.EqualFold(field.TagSettings["SERIALIZER"], "json")
+}
+
+// jsonDataType returns the column type of fields serialized as json, MariaDB implements JSON
+// as an alias of LONGTEXT and reports it as longtext, which would be migrated on every startup
+func jsonDataType(serverVersion string) string {
+	if isMariaDB(serverVersion) {
+		return "longtext"
+	}
+	return "json"
+}
+
+func isMariaDB(serverVersion string) bool {
+	return strings.Contains(serverVersion, "MariaDB")
+}
@@ -0,0 +1,32 @@
+package mysql
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm/schema"
+)
+
+func TestJSONColumnDataType(t *testing.T) {
+	cases := map[string]string{
+		"8.0.36":                    "json",
+		"10.11.6-MariaDB-1:10.11.6": "longtext",
+	}
+
+	for version, expected := range cases {
+		s, err := schema.Parse(&models.PluginDeclaration{}, &sync.Map{}, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dialector := myDialector{Dialector: &mysql.Dialector{Config: &mysql.Config{ServerVersion: version}}}
+		if dataType := dialector.DataTypeOf(s.LookUpField("declaration")); dataType != expected {
+			t.Fatalf("expected %s on %s, got %s", expected, version, dataType)
+		}
+		if dataType := dialector.DataTypeOf(s.LookUpField("id")); dataType != "char(36)" {
+			t.Fatalf("expected char(36) for id, got %s", dataType)
+		}
+	}
+}
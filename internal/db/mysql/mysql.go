@@ -2,6 +2,7 @@ package mysql
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/driver/mysql"
@@ -24,13 +25,14 @@ type MySQLConfig struct {
 }
 
 func InitPluginDB(config *MySQLConfig) (*gorm.DB, error) {
-	// TODO: MySQL dose not support DB_EXTRAS now
 	initializer := mysqlDbInitializer{
 		host:     config.Host,
 		port:     config.Port,
 		user:     config.User,
 		password: config.Pass,
 		sslMode:  config.SSLMode,
+		charset:  config.Charset,
+		extras:   config.Extras,
 	}
 
 	// first try to connect to target database
@@ -74,11 +76,25 @@ type mysqlDbInitializer struct {
 	user     string
 	password string
 	sslMode  string
+	charset  string
+	// extras are appended to the dsn as-is, e.g. "timeout=10s&loc=UTC"
+	extras string
 }
 
 func (m *mysqlDbInitializer) connect(dbName string) (*gorm.DB, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=true&tls=%v", m.user, m.password, m.host, m.port, dbName, m.sslMode == "require")
-	return gorm.Open(myDialector{Dialector: mysql.Open(dsn).(*mysql.Dialector)}, &gorm.Config{})
+	return gorm.Open(myDialector{Dialector: mysql.Open(m.dsn(dbName)).(*mysql.Dialector)}, &gorm.Config{})
+}
+
+func (m *mysqlDbInitializer) dsn(dbName string) string {
+	charset := m.charset
+	if charset == "" {
+		charset = "utf8mb4"
+	}
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=true&tls=%v", m.user, m.password, m.host, m.port, dbName, charset, m.sslMode == "require")
+	if m.extras != "" {
+		dsn = fmt.Sprintf("%s&%s", dsn, strings.TrimPrefix(m.extras, "&"))
+	}
+	return dsn
 }
 
 func (m *mysqlDbInitializer) createDatabaseIfNotExists(db *gorm.DB, dbName string) error {
@@ -0,0 +1,37 @@
+package sqlite
+
+import (
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+type sqliteDialector struct {
+	*sqlite.Dialector
+}
+
+func (dialector sqliteDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return sqliteMigrator{dialector.Dialector.Migrator(db).(sqlite.Migrator)}
+}
+
+type sqliteMigrator struct {
+	sqlite.Migrator
+}
+
+// FullDataTypeOf drops the uuid_generate_v4() default of primary keys which sqlite does not have,
+// ids are generated by models.Model.BeforeCreate instead
+func (migrator sqliteMigrator) FullDataTypeOf(field *schema.Field) clause.Expr {
+	if field.HasDefaultValue && field.DefaultValue == "uuid_generate_v4()" {
+		field.HasDefaultValue = false
+		field.DefaultValue = ""
+		var fieldsWithDefault []*schema.Field
+		for _, fieldWithDefault := range field.Schema.FieldsWithDefaultDBValue {
+			if fieldWithDefault.DBName != field.DBName {
+				fieldsWithDefault = append(fieldsWithDefault, fieldWithDefault)
+			}
+		}
+		field.Schema.FieldsWithDefaultDBValue = fieldsWithDefault
+	}
+	return migrator.Migrator.FullDataTypeOf(field)
+}
@@ -0,0 +1,57 @@
+package sqlite
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+type SQLiteConfig struct {
+	Path            string
+	MaxIdleConns    int
+	MaxOpenConns    int
+	ConnMaxLifetime int
+	// BusyTimeout is the time in milliseconds a connection waits for the write lock
+	BusyTimeout int
+}
+
+func InitPluginDB(config *SQLiteConfig) (*gorm.DB, error) {
+	if dir := filepath.Dir(config.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := gorm.Open(sqliteDialector{Dialector: sqlite.Open(buildDSN(config)).(*sqlite.Dialector)}, &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	pool.SetMaxIdleConns(config.MaxIdleConns)
+	pool.SetMaxOpenConns(config.MaxOpenConns)
+	pool.SetConnMaxLifetime(time.Duration(config.ConnMaxLifetime) * time.Second)
+
+	return db, nil
+}
+
+func buildDSN(config *SQLiteConfig) string {
+	query := url.Values{}
+	// WAL allows readers to proceed while a writer holds the lock
+	query.Add("_pragma", "journal_mode(WAL)")
+	query.Add("_pragma", "foreign_keys(1)")
+	query.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", config.BusyTimeout))
+	// take the write lock at the beginning of a transaction, upgrading a read lock
+	// later fails immediately with SQLITE_BUSY instead of waiting for busy_timeout
+	query.Set("_txlock", "immediate")
+	return "file:" + config.Path + "?" + query.Encode()
+}
@@ -0,0 +1,211 @@
+package oss
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+)
+
+const (
+	FILE_LOCK_TIMEOUT = 30 * time.Second
+	// a lock file older than this is left by a crashed writer, it's only held while replacing a file
+	FILE_LOCK_STALE_AFTER = time.Minute
+	FILE_LOCK_RETRY       = 50 * time.Millisecond
+
+	FILE_LOCK_SUFFIX = ".lock"
+	FILE_TEMP_SUFFIX = ".tmp"
+)
+
+// fileSystem is what a shared filesystem has to offer to store objects as files,
+// all paths are slash separated and relative to the root of the storage
+type fileSystem interface {
+	OpenFile(name string, flag int) (io.ReadWriteCloser, error)
+	Stat(name string) (os.FileInfo, error)
+	ReadDir(name string) ([]os.FileInfo, error)
+	MkdirAll(name string) error
+	// Rename replaces newname if it exists
+	Rename(oldname string, newname string) error
+	RemoveAll(name string) error
+}
+
+// fileStorage stores objects as files on a filesystem shared by all nodes, files are
+// written aside and renamed into place under a lock file, so readers never see partial files
+// and concurrent writers of the same key do not interleave
+type fileStorage struct {
+	fs      fileSystem
+	ossType string
+}
+
+func (f *fileStorage) Save(key string, data []byte) error {
+	return f.SaveStream(key, bytes.NewReader(data))
+}
+
+func (f *fileStorage) SaveStream(key string, reader io.Reader) error {
+	key = path.Clean(key)
+	if err := f.fs.MkdirAll(path.Dir(key)); err != nil {
+		return err
+	}
+
+	temp := path.Join(path.Dir(key), fmt.Sprintf(".%s.%s%s", path.Base(key), uuid.New().String(), FILE_TEMP_SUFFIX))
+	file, err := f.fs.OpenFile(temp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	defer f.fs.RemoveAll(temp)
+
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	unlock, err := f.lock(key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return f.fs.Rename(temp, key)
+}
+
+func (f *fileStorage) Load(key string) ([]byte, error) {
+	reader, err := f.LoadStream(key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+func (f *fileStorage) LoadStream(key string) (io.ReadCloser, error) {
+	return f.fs.OpenFile(path.Clean(key), os.O_RDONLY)
+}
+
+func (f *fileStorage) Exists(key string) (bool, error) {
+	_, err := f.fs.Stat(path.Clean(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (f *fileStorage) State(key string) (cloudoss.OSSState, error) {
+	info, err := f.fs.Stat(path.Clean(key))
+	if err != nil {
+		return cloudoss.OSSState{}, err
+	}
+
+	return cloudoss.OSSState{Size: info.Size(), LastModified: info.ModTime()}, nil
+}
+
+// List returns everything under prefix relative to it like the local storage does,
+// lock files and files being written are skipped
+func (f *fileStorage) List(prefix string) ([]cloudoss.OSSPath, error) {
+	paths := []cloudoss.OSSPath{}
+	prefix = path.Clean(prefix)
+
+	exists, err := f.Exists(prefix)
+	if err != nil || !exists {
+		return paths, err
+	}
+
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		entries, err := f.fs.ReadDir(path.Join(prefix, dir))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if isInternalFile(entry.Name()) {
+				continue
+			}
+			name := path.Join(dir, entry.Name())
+			paths = append(paths, cloudoss.OSSPath{
+				Path:  name,
+				IsDir: entry.IsDir(),
+			})
+			if entry.IsDir() {
+				if err := walk(name); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := walk(""); err != nil {
+		return nil, err
+	}
+
+	return paths, nil
+}
+
+func (f *fileStorage) Delete(key string) error {
+	key = path.Clean(key)
+
+	unlock, err := f.lock(key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return f.fs.RemoveAll(key)
+}
+
+func (f *fileStorage) Type() string {
+	return f.ossType
+}
+
+func isInternalFile(name string) bool {
+	return strings.HasPrefix(name, ".") &&
+		(strings.HasSuffix(name, FILE_LOCK_SUFFIX) || strings.HasSuffix(name, FILE_TEMP_SUFFIX))
+}
+
+// lock creates a lock file next to key exclusively, which is atomic on NFSv3+ and SFTP
+func (f *fileStorage) lock(key string) (func(), error) {
+	lockPath := path.Join(path.Dir(key), "."+path.Base(key)+FILE_LOCK_SUFFIX)
+	deadline := time.Now().Add(FILE_LOCK_TIMEOUT)
+
+	for {
+		file, err := f.fs.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+		if err == nil {
+			hostname, _ := os.Hostname()
+			fmt.Fprintf(file, "%s %d", hostname, os.Getpid())
+			file.Close()
+			return func() {
+				f.fs.RemoveAll(lockPath)
+			}, nil
+		}
+
+		// not every server reports an existing file as such, check it by ourselves
+		info, statErr := f.fs.Stat(lockPath)
+		if statErr != nil && !errors.Is(statErr, fs.ErrNotExist) {
+			return nil, err
+		}
+
+		if statErr == nil && time.Since(info.ModTime()) > FILE_LOCK_STALE_AFTER {
+			f.fs.RemoveAll(lockPath)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timeout waiting for lock of %s", key)
+		}
+		time.Sleep(FILE_LOCK_RETRY)
+	}
+}
@@ -0,0 +1,108 @@
+package oss
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPipeSFTPStorage(t *testing.T, root string) StreamingOSS {
+	return &fileStorage{
+		ossType: OSS_TYPE_SFTP,
+		fs: &sftpFileSystem{
+			root: root,
+			dial: func() (*sftp.Client, io.Closer, error) {
+				serverReader, clientWriter := io.Pipe()
+				clientReader, serverWriter := io.Pipe()
+
+				server, err := sftp.NewServer(struct {
+					io.Reader
+					io.WriteCloser
+				}{serverReader, serverWriter})
+				if err != nil {
+					return nil, nil, err
+				}
+				go server.Serve()
+				t.Cleanup(func() { server.Close() })
+
+				client, err := sftp.NewClientPipe(clientReader, clientWriter)
+				return client, nil, err
+			},
+		},
+	}
+}
+
+func testFileStorage(t *testing.T, storage StreamingOSS, root string) {
+	assert.NoError(t, storage.Save("plugins/a/pkg", []byte("v1")))
+	assert.NoError(t, storage.SaveStream("plugins/a/pkg", strings.NewReader("v2")))
+	assert.NoError(t, storage.Save("plugins/b", []byte("b")))
+
+	data, err := storage.Load("plugins/a/pkg")
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", string(data))
+
+	state, err := storage.State("plugins/a/pkg")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), state.Size)
+
+	// leftovers of writers are never listed
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "plugins", ".b.lock"), nil, 0o644))
+	paths, err := storage.List("plugins")
+	assert.NoError(t, err)
+	listed := map[string]bool{}
+	for _, p := range paths {
+		listed[p.Path] = p.IsDir
+	}
+	assert.Equal(t, map[string]bool{"a": true, "a/pkg": false, "b": false}, listed)
+
+	paths, err = storage.List("missing")
+	assert.NoError(t, err)
+	assert.Empty(t, paths)
+
+	// a stale lock must not block writers forever
+	old := time.Now().Add(-2 * FILE_LOCK_STALE_AFTER)
+	assert.NoError(t, os.Chtimes(filepath.Join(root, "plugins", ".b.lock"), old, old))
+	assert.NoError(t, storage.Delete("plugins/b"))
+
+	exists, err := storage.Exists("plugins/b")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = storage.Exists("plugins/a/pkg")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestNFSStorage(t *testing.T) {
+	root := t.TempDir()
+	storage, err := NewNFSStorage(NFSOptions{MountPath: root})
+	require.NoError(t, err)
+
+	testFileStorage(t, storage, root)
+
+	_, err = NewNFSStorage(NFSOptions{MountPath: filepath.Join(root, "missing")})
+	assert.Error(t, err)
+}
+
+func TestSFTPStorage(t *testing.T) {
+	root := t.TempDir()
+	testFileStorage(t, newPipeSFTPStorage(t, root), root)
+}
+
+func TestSFTPClientConfig(t *testing.T) {
+	_, err := sftpClientConfig(SFTPOptions{Username: "dify", Password: "secret"})
+	assert.Error(t, err, "host key verification must not be skipped silently")
+
+	config, err := sftpClientConfig(SFTPOptions{Username: "dify", Password: "secret", InsecureIgnoreHostKey: true})
+	assert.NoError(t, err)
+	assert.Len(t, config.Auth, 1)
+
+	_, err = sftpClientConfig(SFTPOptions{Username: "dify", InsecureIgnoreHostKey: true})
+	assert.Error(t, err)
+}
@@ -0,0 +1,153 @@
+package oss
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// GCSStorage stores objects in a Google Cloud Storage bucket, it authenticates with
+// GCS_CREDENTIALS if set, otherwise with application default credentials, which covers
+// workload identity on GKE and the attached service account on GCE and Cloud Run
+type GCSStorage struct {
+	bucket string
+	client *storage.Client
+}
+
+func NewGCSStorage(args cloudoss.OSSArgs) (StreamingOSS, error) {
+	if args.GoogleCloudStorage == nil || args.GoogleCloudStorage.Bucket == "" {
+		return nil, cloudoss.ErrArgumentInvalid.WithDetail("bucket of Google Cloud Storage cannot be empty")
+	}
+
+	options, err := gcsClientOptions(args.GoogleCloudStorage.CredentialsB64)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := storage.NewClient(context.Background(), options...)
+	if err != nil {
+		return nil, cloudoss.ErrProviderInit.WithError(err)
+	}
+
+	return &GCSStorage{
+		bucket: args.GoogleCloudStorage.Bucket,
+		client: client,
+	}, nil
+}
+
+func gcsClientOptions(credentialsB64 string) ([]option.ClientOption, error) {
+	if credentialsB64 == "" {
+		return nil, nil
+	}
+
+	credentials, err := base64.StdEncoding.DecodeString(credentialsB64)
+	if err != nil {
+		return nil, cloudoss.ErrProviderInit.WithError(err).WithDetail("credentials must be a base64 encoded string")
+	}
+
+	return []option.ClientOption{option.WithCredentialsJSON(credentials)}, nil
+}
+
+func (g *GCSStorage) object(key string) *storage.ObjectHandle {
+	return g.client.Bucket(g.bucket).Object(key)
+}
+
+func (g *GCSStorage) Save(key string, data []byte) error {
+	return g.SaveStream(key, bytes.NewReader(data))
+}
+
+// SaveStream uploads in chunks, the object is committed when the writer is closed
+func (g *GCSStorage) SaveStream(key string, reader io.Reader) error {
+	writer := g.object(key).NewWriter(context.Background())
+	if _, err := io.Copy(writer, reader); err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+func (g *GCSStorage) Load(key string) ([]byte, error) {
+	reader, err := g.LoadStream(key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+func (g *GCSStorage) LoadStream(key string) (io.ReadCloser, error) {
+	return g.object(key).NewReader(context.Background())
+}
+
+func (g *GCSStorage) Exists(key string) (bool, error) {
+	_, err := g.object(key).Attrs(context.Background())
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (g *GCSStorage) State(key string) (cloudoss.OSSState, error) {
+	attrs, err := g.object(key).Attrs(context.Background())
+	if err != nil {
+		return cloudoss.OSSState{}, err
+	}
+
+	return cloudoss.OSSState{
+		Size:         attrs.Size,
+		LastModified: attrs.Updated,
+	}, nil
+}
+
+// List returns the keys under prefix relative to it, same as the S3 storage
+func (g *GCSStorage) List(prefix string) ([]cloudoss.OSSPath, error) {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix = prefix + "/"
+	}
+
+	paths := []cloudoss.OSSPath{}
+	it := g.client.Bucket(g.bucket).Objects(context.Background(), &storage.Query{
+		Prefix: prefix,
+	})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		key := strings.TrimPrefix(strings.TrimPrefix(attrs.Name, prefix), "/")
+		if key == "" {
+			continue
+		}
+
+		paths = append(paths, cloudoss.OSSPath{
+			Path:  key,
+			IsDir: false,
+		})
+	}
+
+	return paths, nil
+}
+
+func (g *GCSStorage) Delete(key string) error {
+	return g.object(key).Delete(context.Background())
+}
+
+func (g *GCSStorage) Type() string {
+	return cloudoss.OSS_TYPE_GCS
+}
@@ -0,0 +1,34 @@
+package oss
+
+import (
+	"encoding/base64"
+	"testing"
+
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+)
+
+func TestGCSClientOptions(t *testing.T) {
+	// empty credentials fallback to application default credentials
+	options, err := gcsClientOptions("")
+	if err != nil || len(options) != 0 {
+		t.Fatalf("expected no options, got %v %v", options, err)
+	}
+
+	options, err = gcsClientOptions(base64.StdEncoding.EncodeToString([]byte(`{"type":"service_account"}`)))
+	if err != nil || len(options) != 1 {
+		t.Fatalf("expected credentials option, got %v %v", options, err)
+	}
+
+	if _, err := gcsClientOptions("not base64!"); err == nil {
+		t.Fatalf("expected error for invalid credentials")
+	}
+}
+
+func TestGCSStorageRequiresBucket(t *testing.T) {
+	_, err := Load("gcs", cloudoss.OSSArgs{
+		GoogleCloudStorage: &cloudoss.GoogleCloudStorage{},
+	})
+	if err == nil {
+		t.Fatalf("expected error for empty bucket")
+	}
+}
@@ -0,0 +1,57 @@
+package oss
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+	"github.com/langgenius/dify-cloud-kit/oss/local"
+)
+
+type LocalStorage struct {
+	cloudoss.OSS
+	root string
+}
+
+func NewLocalStorage(args cloudoss.OSSArgs) (StreamingOSS, error) {
+	storage, err := local.NewLocalStorage(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalStorage{OSS: storage, root: args.Local.Path}, nil
+}
+
+func (l *LocalStorage) SaveStream(key string, reader io.Reader) error {
+	path := filepath.Join(l.root, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// write to a temporary file first, avoid readers seeing a partial object
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(file.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+func (l *LocalStorage) LoadStream(key string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(l.root, key))
+}
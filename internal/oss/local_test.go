@@ -0,0 +1,79 @@
+package oss
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+)
+
+func TestLocalStorageStream(t *testing.T) {
+	root := t.TempDir()
+	storage, err := Load("local", cloudoss.OSSArgs{
+		Local: &cloudoss.Local{Path: root},
+	})
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+
+	if _, ok := storage.(*LocalStorage); !ok {
+		t.Fatalf("local storage should support streaming natively")
+	}
+
+	data := bytes.Repeat([]byte("dify"), 1024*1024)
+	if err := storage.SaveStream("packages/test.difypkg", bytes.NewReader(data)); err != nil {
+		t.Fatalf("failed to save stream: %v", err)
+	}
+
+	reader, err := storage.LoadStream("packages/test.difypkg")
+	if err != nil {
+		t.Fatalf("failed to load stream: %v", err)
+	}
+	defer reader.Close()
+
+	loaded, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read stream: %v", err)
+	}
+	if !bytes.Equal(loaded, data) {
+		t.Fatalf("loaded data mismatch")
+	}
+
+	// no temporary files should be left
+	entries, err := os.ReadDir(root + "/packages")
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 file, got %d", len(entries))
+	}
+}
+
+func TestBufferedStorageStream(t *testing.T) {
+	local, err := NewLocalStorage(cloudoss.OSSArgs{
+		Local: &cloudoss.Local{Path: t.TempDir()},
+	})
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+
+	// strip the streaming implementation, the buffered fallback should be used
+	storage := Streaming(local.(*LocalStorage).OSS)
+	if _, ok := storage.(*bufferedStorage); !ok {
+		t.Fatalf("expected buffered storage")
+	}
+
+	if err := storage.SaveStream("key", bytes.NewReader([]byte("value"))); err != nil {
+		t.Fatalf("failed to save stream: %v", err)
+	}
+
+	data, err := storage.Load("key")
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	if string(data) != "value" {
+		t.Fatalf("expected value, got %s", data)
+	}
+}
@@ -0,0 +1,85 @@
+package oss
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+)
+
+const OSS_TYPE_NFS = "nfs"
+
+type NFSOptions struct {
+	// MountPath is where the share is mounted on every node
+	MountPath string
+}
+
+// NewNFSStorage stores objects on a network filesystem mounted on every node of the cluster
+func NewNFSStorage(options NFSOptions) (StreamingOSS, error) {
+	if options.MountPath == "" {
+		return nil, cloudoss.ErrArgumentInvalid.WithDetail("mount path of NFS cannot be empty")
+	}
+
+	info, err := os.Stat(options.MountPath)
+	if err != nil {
+		return nil, cloudoss.ErrProviderInit.WithError(err).WithDetail("NFS mount path is not accessible")
+	}
+	if !info.IsDir() {
+		return nil, cloudoss.ErrArgumentInvalid.WithDetail("NFS mount path is not a directory")
+	}
+
+	return &fileStorage{
+		fs:      &osFileSystem{root: options.MountPath},
+		ossType: OSS_TYPE_NFS,
+	}, nil
+}
+
+type osFileSystem struct {
+	root string
+}
+
+func (o *osFileSystem) path(name string) string {
+	return filepath.Join(o.root, filepath.FromSlash(name))
+}
+
+func (o *osFileSystem) OpenFile(name string, flag int) (io.ReadWriteCloser, error) {
+	return os.OpenFile(o.path(name), flag, 0o644)
+}
+
+func (o *osFileSystem) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(o.path(name))
+}
+
+func (o *osFileSystem) ReadDir(name string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(o.path(name))
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			// removed in the meantime
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (o *osFileSystem) MkdirAll(name string) error {
+	return os.MkdirAll(o.path(name), 0o755)
+}
+
+func (o *osFileSystem) Rename(oldname string, newname string) error {
+	return os.Rename(o.path(oldname), o.path(newname))
+}
+
+func (o *osFileSystem) RemoveAll(name string) error {
+	return os.RemoveAll(o.path(name))
+}
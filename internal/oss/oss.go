@@ -0,0 +1,110 @@
+package oss
+
+import (
+	"bytes"
+	"io"
+
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+	"github.com/langgenius/dify-cloud-kit/oss/factory"
+)
+
+// StreamingOSS extends the whole-object storage of dify-cloud-kit with streaming
+// reads and writes, so large objects like plugin packages never have to be held in memory
+type StreamingOSS interface {
+	cloudoss.OSS
+
+	// SaveStream writes everything from reader to key, the object is visible only after
+	// the reader is fully consumed
+	SaveStream(key string, reader io.Reader) error
+	// LoadStream opens key for reading, caller must close the returned reader
+	LoadStream(key string) (io.ReadCloser, error)
+}
+
+// Options configures the storages implemented in this package beyond what dify-cloud-kit accepts
+type Options struct {
+	S3   S3Options
+	NFS  NFSOptions
+	SFTP SFTPOptions
+}
+
+var streamingFactory = map[string]func(cloudoss.OSSArgs, Options) (StreamingOSS, error){
+	"local":      withoutOptions(NewLocalStorage),
+	"local_file": withoutOptions(NewLocalStorage),
+
+	"s3":     newS3Storage,
+	"aws_s3": newS3Storage,
+	"aws-s3": newS3Storage,
+
+	"gcs":            withoutOptions(NewGCSStorage),
+	"google-storage": withoutOptions(NewGCSStorage),
+	"google_storage": withoutOptions(NewGCSStorage),
+
+	"nfs":  newNFSStorage,
+	"sftp": newSFTPStorage,
+}
+
+func withoutOptions(f func(cloudoss.OSSArgs) (StreamingOSS, error)) func(cloudoss.OSSArgs, Options) (StreamingOSS, error) {
+	return func(args cloudoss.OSSArgs, _ Options) (StreamingOSS, error) {
+		return f(args)
+	}
+}
+
+func newS3Storage(args cloudoss.OSSArgs, options Options) (StreamingOSS, error) {
+	return NewS3Storage(args, options.S3)
+}
+
+func newNFSStorage(_ cloudoss.OSSArgs, options Options) (StreamingOSS, error) {
+	return NewNFSStorage(options.NFS)
+}
+
+func newSFTPStorage(_ cloudoss.OSSArgs, options Options) (StreamingOSS, error) {
+	return NewSFTPStorage(options.SFTP)
+}
+
+// Load creates the storage by name, backends without native streaming support
+// are wrapped by a buffered fallback
+func Load(name string, args cloudoss.OSSArgs, options ...Options) (StreamingOSS, error) {
+	if f, ok := streamingFactory[name]; ok {
+		opts := Options{}
+		if len(options) > 0 {
+			opts = options[0]
+		}
+		return f(args, opts)
+	}
+
+	storage, err := factory.Load(name, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return Streaming(storage), nil
+}
+
+// Streaming returns storage itself if it supports streaming, otherwise
+// a wrapper which buffers the whole object in memory
+func Streaming(storage cloudoss.OSS) StreamingOSS {
+	if s, ok := storage.(StreamingOSS); ok {
+		return s
+	}
+	return &bufferedStorage{OSS: storage}
+}
+
+type bufferedStorage struct {
+	cloudoss.OSS
+}
+
+func (b *bufferedStorage) SaveStream(key string, reader io.Reader) error {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	return b.Save(key, data)
+}
+
+func (b *bufferedStorage) LoadStream(key string) (io.ReadCloser, error) {
+	data, err := b.Load(key)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
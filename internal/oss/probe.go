@@ -0,0 +1,185 @@
+package oss
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+)
+
+const (
+	PROBE_STATUS_OK       = "ok"
+	PROBE_STATUS_DEGRADED = "degraded"
+	PROBE_STATUS_DOWN     = "down"
+
+	PROBE_KEY_PREFIX = ".probe"
+)
+
+type ProbeConfig struct {
+	Interval time.Duration
+	// LatencyThreshold marks the storage as degraded if a read or write takes longer
+	LatencyThreshold time.Duration
+	// ErrorThreshold marks the storage as down after this many consecutive failed probes
+	ErrorThreshold int
+}
+
+type ProbeStatus struct {
+	Status string `json:"status"`
+
+	LastProbedAt     time.Time `json:"last_probed_at"`
+	WriteLatencyMs   int64     `json:"write_latency_ms"`
+	ReadLatencyMs    int64     `json:"read_latency_ms"`
+	Probes           int64     `json:"probes"`
+	Errors           int64     `json:"errors"`
+	ConsecutiveError int       `json:"consecutive_errors"`
+	LastError        string    `json:"last_error,omitempty"`
+	// SlowProbes counts the probes above the latency threshold
+	SlowProbes int64 `json:"slow_probes"`
+	// ProbingSince is set while a probe is in flight
+	ProbingSince *time.Time `json:"probing_since,omitempty"`
+
+	LatencyThresholdMs int64 `json:"latency_threshold_ms"`
+	ErrorThreshold     int   `json:"error_threshold"`
+}
+
+type prober struct {
+	storage cloudoss.OSS
+	config  ProbeConfig
+	key     string
+
+	mu     sync.RWMutex
+	status ProbeStatus
+}
+
+var (
+	globalProber atomic.Pointer[prober]
+)
+
+// StartProbe periodically writes, reads back and deletes a small object on storage,
+// the results are available through FetchProbeStatus
+func StartProbe(storage cloudoss.OSS, config ProbeConfig) {
+	p := &prober{
+		storage: storage,
+		config:  config,
+		// every node probes its own key, avoid racing with each other
+		key: fmt.Sprintf("%s/%s", PROBE_KEY_PREFIX, uuid.New().String()),
+		status: ProbeStatus{
+			Status:             PROBE_STATUS_OK,
+			LatencyThresholdMs: config.LatencyThreshold.Milliseconds(),
+			ErrorThreshold:     config.ErrorThreshold,
+		},
+	}
+	globalProber.Store(p)
+
+	routine.Submit(map[string]string{
+		"module":   "oss",
+		"function": "StartProbe",
+	}, func() {
+		ticker := time.NewTicker(config.Interval)
+		defer ticker.Stop()
+
+		for {
+			p.probe()
+			<-ticker.C
+		}
+	})
+}
+
+// FetchProbeStatus returns nil if probing is disabled
+func FetchProbeStatus() *ProbeStatus {
+	p := globalProber.Load()
+	if p == nil {
+		return nil
+	}
+	return p.fetchStatus()
+}
+
+// StorageDown reports whether the probes failed ErrorThreshold times in a row
+func StorageDown() bool {
+	status := FetchProbeStatus()
+	return status != nil && status.Status == PROBE_STATUS_DOWN
+}
+
+func (p *prober) fetchStatus() *ProbeStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	status := p.status
+
+	// a hanging storage never fails a probe, it's down once the probe took as long as the failed
+	// probes marking it down would
+	if status.ProbingSince != nil && p.config.ErrorThreshold > 0 &&
+		time.Since(*status.ProbingSince) > time.Duration(p.config.ErrorThreshold)*p.config.Interval {
+		status.Status = PROBE_STATUS_DOWN
+		status.LastError = fmt.Sprintf("probe in flight since %s", status.ProbingSince.Format(time.RFC3339))
+	}
+	return &status
+}
+
+func (p *prober) probe() {
+	p.mu.Lock()
+	probingSince := time.Now()
+	p.status.ProbingSince = &probingSince
+	p.mu.Unlock()
+
+	payload := []byte(time.Now().UTC().Format(time.RFC3339Nano))
+
+	start := time.Now()
+	err := p.storage.Save(p.key, payload)
+	writeLatency := time.Since(start)
+
+	var readLatency time.Duration
+	if err == nil {
+		start = time.Now()
+		var data []byte
+		data, err = p.storage.Load(p.key)
+		readLatency = time.Since(start)
+		if err == nil && !bytes.Equal(data, payload) {
+			err = errors.New("probe object read back mismatched")
+		}
+	}
+
+	if err == nil {
+		err = p.storage.Delete(p.key)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.status.ProbingSince = nil
+	p.status.LastProbedAt = time.Now()
+	p.status.Probes++
+	p.status.WriteLatencyMs = writeLatency.Milliseconds()
+	p.status.ReadLatencyMs = readLatency.Milliseconds()
+
+	if err != nil {
+		p.status.Errors++
+		p.status.ConsecutiveError++
+		p.status.LastError = err.Error()
+		log.Warn("storage probe failed: %s", err.Error())
+	} else {
+		p.status.ConsecutiveError = 0
+		p.status.LastError = ""
+	}
+
+	slow := p.config.LatencyThreshold > 0 &&
+		(writeLatency > p.config.LatencyThreshold || readLatency > p.config.LatencyThreshold)
+	if slow {
+		p.status.SlowProbes++
+	}
+
+	switch {
+	case p.config.ErrorThreshold > 0 && p.status.ConsecutiveError >= p.config.ErrorThreshold:
+		p.status.Status = PROBE_STATUS_DOWN
+	case p.status.ConsecutiveError > 0 || slow:
+		p.status.Status = PROBE_STATUS_DEGRADED
+	default:
+		p.status.Status = PROBE_STATUS_OK
+	}
+}
@@ -0,0 +1,100 @@
+package oss
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+)
+
+type failingStorage struct {
+	cloudoss.OSS
+}
+
+func (f *failingStorage) Save(key string, data []byte) error {
+	return errors.New("storage unavailable")
+}
+
+func TestProbeThresholds(t *testing.T) {
+	local, err := NewLocalStorage(cloudoss.OSSArgs{
+		Local: &cloudoss.Local{Path: t.TempDir()},
+	})
+	if err != nil {
+		t.Fatalf("failed to create local storage: %v", err)
+	}
+
+	p := &prober{
+		storage: local,
+		config:  ProbeConfig{LatencyThreshold: time.Second, ErrorThreshold: 2},
+		key:     PROBE_KEY_PREFIX + "/test",
+	}
+
+	p.probe()
+	if p.status.Status != PROBE_STATUS_OK || p.status.Errors != 0 {
+		t.Fatalf("expected healthy storage, got %+v", p.status)
+	}
+
+	if exists, _ := local.Exists(p.key); exists {
+		t.Fatalf("probe object should be deleted")
+	}
+
+	p.storage = &failingStorage{OSS: local}
+	p.probe()
+	if p.status.Status != PROBE_STATUS_DEGRADED {
+		t.Fatalf("expected degraded storage after one failure, got %s", p.status.Status)
+	}
+
+	p.probe()
+	if p.status.Status != PROBE_STATUS_DOWN || p.status.ConsecutiveError != 2 {
+		t.Fatalf("expected storage down after two failures, got %+v", p.status)
+	}
+
+	p.storage = local
+	p.probe()
+	if p.status.Status != PROBE_STATUS_OK || p.status.Errors != 2 {
+		t.Fatalf("expected storage to recover, got %+v", p.status)
+	}
+}
+
+type hangingStorage struct {
+	cloudoss.OSS
+	release chan bool
+}
+
+func (h *hangingStorage) Save(key string, data []byte) error {
+	<-h.release
+	return errors.New("storage unavailable")
+}
+
+func TestProbeReportsHangingStorageDown(t *testing.T) {
+	storage := &hangingStorage{release: make(chan bool)}
+	p := &prober{
+		storage: storage,
+		config:  ProbeConfig{Interval: 10 * time.Millisecond, ErrorThreshold: 2},
+		key:     PROBE_KEY_PREFIX + "/test",
+		status:  ProbeStatus{Status: PROBE_STATUS_OK},
+	}
+	globalProber.Store(p)
+	defer globalProber.Store(nil)
+
+	done := make(chan bool)
+	go func() {
+		p.probe()
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if status := FetchProbeStatus(); status.Status != PROBE_STATUS_DOWN || status.ProbingSince == nil {
+		t.Fatalf("expected storage down while the probe hangs, got %+v", status)
+	}
+	if !StorageDown() {
+		t.Fatal("storage should be reported down")
+	}
+
+	close(storage.release)
+	<-done
+	if status := FetchProbeStatus(); status.Status != PROBE_STATUS_DEGRADED || status.ProbingSince != nil {
+		t.Fatalf("expected one failed probe once it returned, got %+v", status)
+	}
+}
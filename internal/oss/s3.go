@@ -0,0 +1,290 @@
+package oss
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+)
+
+const (
+	// S3_DEFAULT_COMPATIBLE_REGION is used to sign requests to S3-compatible providers without regions, e.g. Cloudflare R2
+	S3_DEFAULT_COMPATIBLE_REGION = "auto"
+	S3_DEFAULT_MULTIPART_SIZE    = 16 * 1024 * 1024
+)
+
+// S3Options tunes the S3 backend for S3-compatible providers like Cloudflare R2, Backblaze B2 and Ceph RGW
+type S3Options struct {
+	// DisableChecksum only sends and validates checksums if the operation requires them,
+	// providers not supporting the flexible checksums of AWS reject the default CRC32 headers
+	DisableChecksum bool
+	// MultipartThreshold uploads objects of at least this size in parts, 0 means S3_DEFAULT_MULTIPART_SIZE
+	MultipartThreshold int64
+	// PartSize of multipart uploads, 0 means MultipartThreshold
+	PartSize int64
+	// Concurrency of multipart uploads and downloads, 0 means the default of the sdk
+	Concurrency int
+}
+
+type S3Storage struct {
+	bucket     string
+	client     *s3.Client
+	uploader   *manager.Uploader
+	downloader *manager.Downloader
+	threshold  int64
+}
+
+func NewS3Storage(args cloudoss.OSSArgs, options S3Options) (StreamingOSS, error) {
+	if args.S3 == nil {
+		return nil, cloudoss.ErrArgumentInvalid.WithDetail("can't find s3 argument in OSSArgs")
+	}
+	if args.S3.Bucket == "" {
+		return nil, cloudoss.ErrArgumentInvalid.WithDetail("bucket cannot be empty")
+	}
+
+	if !args.S3.UseAws && args.S3.Endpoint == "" {
+		return nil, cloudoss.ErrArgumentInvalid.WithDetail("endpoint cannot be empty for S3-compatible storage")
+	}
+
+	s3Args := *args.S3
+	if s3Args.Region == "" {
+		if s3Args.UseAws {
+			return nil, cloudoss.ErrArgumentInvalid.WithDetail("region cannot be empty")
+		}
+		s3Args.Region = S3_DEFAULT_COMPATIBLE_REGION
+	}
+
+	client, err := newS3Client(&s3Args, options)
+	if err != nil {
+		return nil, cloudoss.ErrProviderInit.WithError(err)
+	}
+
+	if err := ensureS3Bucket(client, s3Args.Bucket); err != nil {
+		return nil, cloudoss.ErrProviderInit.WithError(err)
+	}
+
+	threshold := options.MultipartThreshold
+	if threshold <= 0 {
+		threshold = S3_DEFAULT_MULTIPART_SIZE
+	}
+	partSize := options.PartSize
+	if partSize <= 0 {
+		partSize = threshold
+	}
+	if partSize < manager.MinUploadPartSize {
+		partSize = manager.MinUploadPartSize
+	}
+
+	return &S3Storage{
+		bucket: s3Args.Bucket,
+		client: client,
+		uploader: manager.NewUploader(client, func(u *manager.Uploader) {
+			u.PartSize = partSize
+			if options.Concurrency > 0 {
+				u.Concurrency = options.Concurrency
+			}
+		}),
+		downloader: manager.NewDownloader(client, func(d *manager.Downloader) {
+			if options.Concurrency > 0 {
+				d.Concurrency = options.Concurrency
+			}
+		}),
+		threshold: threshold,
+	}, nil
+}
+
+func newS3Client(args *cloudoss.S3, options S3Options) (*s3.Client, error) {
+	configure := func(o *s3.Options) {
+		if args.Endpoint != "" {
+			o.BaseEndpoint = aws.String(args.Endpoint)
+		}
+		o.UsePathStyle = args.UsePathStyle
+		if options.DisableChecksum {
+			o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+			o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+		}
+	}
+
+	if !args.UseAws {
+		return s3.New(s3.Options{
+			Credentials: credentials.NewStaticCredentialsProvider(args.AccessKey, args.SecretKey, ""),
+			Region:      args.Region,
+		}, configure), nil
+	}
+
+	loadOptions := []func(*config.LoadOptions) error{
+		config.WithRegion(args.Region),
+	}
+	if (args.AccessKey != "" || args.SecretKey != "") && !args.UseIamRole {
+		loadOptions = append(loadOptions, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(args.AccessKey, args.SecretKey, ""),
+		))
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.TODO(), loadOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s3.NewFromConfig(cfg, configure), nil
+}
+
+// ensureS3Bucket creates the bucket if it does not exist, other errors are left to the first real request
+// since some providers deny HeadBucket to keys scoped to objects
+func ensureS3Bucket(client *s3.Client, bucket string) error {
+	_, err := client.HeadBucket(context.TODO(), &s3.HeadBucketInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil && isS3NotFound(err) {
+		_, err = client.CreateBucket(context.TODO(), &s3.CreateBucketInput{
+			Bucket: aws.String(bucket),
+		})
+		return err
+	}
+	return nil
+}
+
+func isS3NotFound(err error) bool {
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return true
+	}
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return true
+	}
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && (apiErr.ErrorCode() == "NotFound" || apiErr.ErrorCode() == "NoSuchKey")
+}
+
+// Save puts small objects in a single request, larger ones are uploaded in parts
+func (s *S3Storage) Save(key string, data []byte) error {
+	if int64(len(data)) >= s.threshold {
+		return s.SaveStream(key, bytes.NewReader(data))
+	}
+
+	_, err := s.client.PutObject(context.TODO(), &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+// SaveStream uploads the object in parts, memory usage is bounded by part size and concurrency
+func (s *S3Storage) SaveStream(key string, reader io.Reader) error {
+	_, err := s.uploader.Upload(context.TODO(), &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   reader,
+	})
+	return err
+}
+
+// Load fetches large objects in concurrent byte ranges instead of a single GetObject
+func (s *S3Storage) Load(key string) ([]byte, error) {
+	buffer := manager.NewWriteAtBuffer(nil)
+	_, err := s.downloader.Download(context.TODO(), buffer, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func (s *S3Storage) LoadStream(key string) (io.ReadCloser, error) {
+	resp, err := s.client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+func (s *S3Storage) Exists(key string) (bool, error) {
+	_, err := s.client.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isS3NotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *S3Storage) Delete(key string) error {
+	_, err := s.client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (s *S3Storage) State(key string) (cloudoss.OSSState, error) {
+	resp, err := s.client.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return cloudoss.OSSState{}, err
+	}
+
+	state := cloudoss.OSSState{}
+	if resp.ContentLength != nil {
+		state.Size = *resp.ContentLength
+	}
+	if resp.LastModified != nil {
+		state.LastModified = *resp.LastModified
+	}
+	return state, nil
+}
+
+// List returns the keys under prefix relative to it
+func (s *S3Storage) List(prefix string) ([]cloudoss.OSSPath, error) {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix = prefix + "/"
+	}
+
+	paths := []cloudoss.OSSPath{}
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			paths = append(paths, cloudoss.OSSPath{
+				Path:  strings.TrimPrefix(strings.TrimPrefix(*obj.Key, prefix), "/"),
+				IsDir: false,
+			})
+		}
+	}
+
+	return paths, nil
+}
+
+func (s *S3Storage) Type() string {
+	return cloudoss.OSS_TYPE_S3
+}
@@ -0,0 +1,128 @@
+package oss
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+)
+
+// newFakeR2 serves path-style object requests and rejects the flexible checksum headers like R2 used to
+func newFakeR2(t *testing.T) *httptest.Server {
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name := range r.Header {
+			if strings.HasPrefix(strings.ToLower(name), "x-amz-checksum-") ||
+				strings.EqualFold(name, "x-amz-sdk-checksum-algorithm") ||
+				strings.EqualFold(name, "x-amz-trailer") {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		// /bucket or /bucket/key
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+		if len(parts) == 1 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		key := parts[1]
+
+		switch r.Method {
+		case http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			objects[key] = data
+			w.WriteHeader(http.StatusOK)
+		case http.MethodGet, http.MethodHead:
+			data, ok := objects[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.Method == http.MethodGet {
+				w.Write(data)
+			}
+		case http.MethodDelete:
+			delete(objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestS3CompatibleWithoutChecksum(t *testing.T) {
+	server := newFakeR2(t)
+
+	storage, err := Load("s3", cloudoss.OSSArgs{
+		S3: &cloudoss.S3{
+			UseAws:       false,
+			Endpoint:     server.URL,
+			UsePathStyle: true,
+			AccessKey:    "ak",
+			SecretKey:    "sk",
+			Bucket:       "plugins",
+		},
+	}, Options{S3: S3Options{DisableChecksum: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := storage.Save("a/b", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := storage.Load("a/b")
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("unexpected load result: %q %v", data, err)
+	}
+
+	exists, err := storage.Exists("a/c")
+	if err != nil || exists {
+		t.Fatalf("missing object should not exist: %v %v", exists, err)
+	}
+
+	if err := storage.Delete("a/b"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestS3CompatibleRequiresEndpoint(t *testing.T) {
+	_, err := NewS3Storage(cloudoss.OSSArgs{
+		S3: &cloudoss.S3{UseAws: false, Bucket: "plugins"},
+	}, S3Options{})
+	if err == nil {
+		t.Fatalf("expected error without endpoint")
+	}
+}
+
+func TestS3ClientChecksumOptions(t *testing.T) {
+	args := &cloudoss.S3{Endpoint: "http://localhost:9000", Region: "auto"}
+
+	client, err := newS3Client(args, S3Options{DisableChecksum: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Options().RequestChecksumCalculation != aws.RequestChecksumCalculationWhenRequired ||
+		client.Options().ResponseChecksumValidation != aws.ResponseChecksumValidationWhenRequired {
+		t.Fatalf("checksums should only be calculated when required")
+	}
+
+	client, err = newS3Client(args, S3Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.Options().RequestChecksumCalculation == aws.RequestChecksumCalculationWhenRequired {
+		t.Fatalf("checksums should keep the sdk default")
+	}
+}
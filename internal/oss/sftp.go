@@ -0,0 +1,237 @@
+package oss
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	OSS_TYPE_SFTP = "sftp"
+
+	SFTP_DIAL_TIMEOUT = 10 * time.Second
+)
+
+type SFTPOptions struct {
+	Host     string
+	Port     int
+	Username string
+	// Password and PrivateKeyPath can be used together, the key is tried first
+	Password             string
+	PrivateKeyPath       string
+	PrivateKeyPassphrase string
+	// KnownHostsPath verifies the host key of the server, it's only allowed to be
+	// empty with InsecureIgnoreHostKey set
+	KnownHostsPath        string
+	InsecureIgnoreHostKey bool
+	// Root is the directory on the server all objects are stored under
+	Root string
+}
+
+// NewSFTPStorage stores objects on a SFTP server, the connection is established lazily
+// and reestablished after it's lost
+func NewSFTPStorage(options SFTPOptions) (StreamingOSS, error) {
+	if options.Host == "" || options.Username == "" {
+		return nil, cloudoss.ErrArgumentInvalid.WithDetail("host and username of SFTP cannot be empty")
+	}
+	if options.Port == 0 {
+		options.Port = 22
+	}
+
+	config, err := sftpClientConfig(options)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &sftpFileSystem{
+		root: options.Root,
+		dial: func() (*sftp.Client, io.Closer, error) {
+			conn, err := ssh.Dial("tcp", net.JoinHostPort(options.Host, strconv.Itoa(options.Port)), config)
+			if err != nil {
+				return nil, nil, err
+			}
+			client, err := sftp.NewClient(conn)
+			if err != nil {
+				conn.Close()
+				return nil, nil, err
+			}
+			return client, conn, nil
+		},
+	}
+
+	// fail fast on wrong credentials
+	if _, err := s.client(); err != nil {
+		return nil, cloudoss.ErrProviderInit.WithError(err).WithDetail("failed to connect to SFTP server")
+	}
+
+	return &fileStorage{
+		fs:      s,
+		ossType: OSS_TYPE_SFTP,
+	}, nil
+}
+
+func sftpClientConfig(options SFTPOptions) (*ssh.ClientConfig, error) {
+	auth := []ssh.AuthMethod{}
+	if options.PrivateKeyPath != "" {
+		key, err := os.ReadFile(options.PrivateKeyPath)
+		if err != nil {
+			return nil, cloudoss.ErrProviderInit.WithError(err).WithDetail("failed to read SFTP private key")
+		}
+
+		var signer ssh.Signer
+		if options.PrivateKeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(options.PrivateKeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, cloudoss.ErrProviderInit.WithError(err).WithDetail("failed to parse SFTP private key")
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if options.Password != "" {
+		auth = append(auth, ssh.Password(options.Password))
+	}
+	if len(auth) == 0 {
+		return nil, cloudoss.ErrArgumentInvalid.WithDetail("either password or private key of SFTP is required")
+	}
+
+	var hostKeyCallback ssh.HostKeyCallback
+	switch {
+	case options.KnownHostsPath != "":
+		callback, err := knownhosts.New(options.KnownHostsPath)
+		if err != nil {
+			return nil, cloudoss.ErrProviderInit.WithError(err).WithDetail("failed to load SFTP known hosts")
+		}
+		hostKeyCallback = callback
+	case options.InsecureIgnoreHostKey:
+		hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		return nil, cloudoss.ErrArgumentInvalid.WithDetail("known hosts of SFTP is required to verify the server")
+	}
+
+	return &ssh.ClientConfig{
+		User:            options.Username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         SFTP_DIAL_TIMEOUT,
+	}, nil
+}
+
+type sftpFileSystem struct {
+	root string
+	dial func() (*sftp.Client, io.Closer, error)
+
+	mu     sync.Mutex
+	conn   *sftp.Client
+	closer io.Closer
+}
+
+func (s *sftpFileSystem) client() (*sftp.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		return s.conn, nil
+	}
+
+	client, closer, err := s.dial()
+	if err != nil {
+		return nil, err
+	}
+	s.conn = client
+	s.closer = closer
+
+	// forget the connection once it's gone, the next operation dials again
+	go func() {
+		client.Wait()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.conn == client {
+			s.conn = nil
+			if s.closer != nil {
+				s.closer.Close()
+			}
+		}
+	}()
+
+	return client, nil
+}
+
+func (s *sftpFileSystem) path(name string) string {
+	return path.Join(s.root, name)
+}
+
+func (s *sftpFileSystem) OpenFile(name string, flag int) (io.ReadWriteCloser, error) {
+	client, err := s.client()
+	if err != nil {
+		return nil, err
+	}
+	return client.OpenFile(s.path(name), flag)
+}
+
+func (s *sftpFileSystem) Stat(name string) (os.FileInfo, error) {
+	client, err := s.client()
+	if err != nil {
+		return nil, err
+	}
+	return client.Stat(s.path(name))
+}
+
+func (s *sftpFileSystem) ReadDir(name string) ([]os.FileInfo, error) {
+	client, err := s.client()
+	if err != nil {
+		return nil, err
+	}
+	return client.ReadDir(s.path(name))
+}
+
+func (s *sftpFileSystem) MkdirAll(name string) error {
+	client, err := s.client()
+	if err != nil {
+		return err
+	}
+	return client.MkdirAll(s.path(name))
+}
+
+// Rename replaces newname atomically if the server supports posix-rename, otherwise newname
+// is removed first, which is still safe against other writers as it happens under the lock
+func (s *sftpFileSystem) Rename(oldname string, newname string) error {
+	client, err := s.client()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := client.HasExtension("posix-rename@openssh.com"); ok {
+		return client.PosixRename(s.path(oldname), s.path(newname))
+	}
+
+	if err := client.Remove(s.path(newname)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to replace %s: %w", newname, err)
+	}
+	return client.Rename(s.path(oldname), s.path(newname))
+}
+
+func (s *sftpFileSystem) RemoveAll(name string) error {
+	client, err := s.client()
+	if err != nil {
+		return err
+	}
+
+	err = client.RemoveAll(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -1,8 +1,14 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"net/http"
+
 	"github.com/langgenius/dify-plugin-daemon/internal/cluster"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/invocation_limit"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/backwards_invocation/transaction"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/rate_limit"
 )
 
 type App struct {
@@ -17,4 +23,19 @@ type App struct {
 	// serverless transaction handler
 	// accept serverless transaction request and forward to the plugin daemon
 	serverlessTransactionHandler *transaction.ServerlessTransactionHandler
+
+	// invocationLimiter bounds the concurrent invocations of plugins running on this node
+	invocationLimiter *invocation_limit.Limiter
+
+	// rateLimiter limits requests of tenants and plugins across the cluster, nil if no limit is configured
+	rateLimiter *rate_limit.Limiter
+
+	// tlsConfig of the http and grpc servers, nil if they serve plain text
+	tlsConfig *tls.Config
+
+	// endpoints serves the http api, endpoint requests received by grpc go through it
+	endpoints http.Handler
+
+	// shutdownTracing flushes buffered spans and stops the exporter, nil if tracing is disabled
+	shutdownTracing func(context.Context) error
 }
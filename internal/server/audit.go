@@ -0,0 +1,157 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/access_control"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/audit"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+const (
+	AUDIT_MAX_REQUEST_BYTES  = 1 << 20
+	AUDIT_MAX_RESPONSE_BYTES = 64 << 10
+
+	AUDIT_ACTOR_ADMIN = "admin"
+	AUDIT_ACTOR_DIFY  = "dify"
+)
+
+// auditDetailFields are the fields of request bodies identifying what an event is about,
+// anything else such as values of variables and settings of endpoints is never recorded
+var auditDetailFields = []string{
+	"plugin_unique_identifier",
+	"plugin_unique_identifiers",
+	"original_plugin_unique_identifier",
+	"new_plugin_unique_identifier",
+	"plugin_installation_id",
+	"plugin_id",
+	"version",
+	"source",
+	"force",
+	"endpoint_id",
+	"name",
+	"secret",
+	"provider",
+	"permissions",
+	"reason",
+}
+
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditResponseWriter) Write(data []byte) (int, error) {
+	if w.body.Len() < AUDIT_MAX_RESPONSE_BYTES {
+		w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *auditResponseWriter) WriteString(data string) (int, error) {
+	if w.body.Len() < AUDIT_MAX_RESPONSE_BYTES {
+		w.body.WriteString(data)
+	}
+	return w.ResponseWriter.WriteString(data)
+}
+
+// Audit records the request as event once it's handled, the result is taken from the response
+func Audit(event string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !audit.Enabled() {
+			c.Next()
+			return
+		}
+
+		detail := auditDetail(c)
+		writer := &auditResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		record := models.AuditRecord{
+			Event:    event,
+			Actor:    auditActor(c, detail),
+			TenantID: c.Param("tenant_id"),
+			SourceIP: c.ClientIP(),
+			Result:   models.AuditResultSuccess,
+			Detail:   detail,
+		}
+
+		response, err := parser.UnmarshalJsonBytes[entities.Response](writer.body.Bytes())
+		if writer.Status() >= 400 {
+			record.Result = models.AuditResultFailure
+			record.Message = fmt.Sprintf("status %d", writer.Status())
+		}
+		if err == nil && response.Code != 0 {
+			record.Result = models.AuditResultFailure
+			record.Message = response.Message
+		}
+
+		audit.Record(record)
+	}
+}
+
+// auditDetail picks the identifying fields of a json body and the path parameters, the body is
+// restored for the handler
+func auditDetail(c *gin.Context) map[string]any {
+	detail := map[string]any{}
+	for _, param := range c.Params {
+		if param.Key != "tenant_id" {
+			detail[param.Key] = param.Value
+		}
+	}
+
+	if c.Request.Body == nil || !strings.HasPrefix(c.ContentType(), "application/json") {
+		return detail
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, AUDIT_MAX_REQUEST_BYTES))
+	c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+	if err != nil {
+		return detail
+	}
+
+	fields, err := parser.UnmarshalJsonBytes[map[string]any](body)
+	if err != nil {
+		return detail
+	}
+	for _, field := range auditDetailFields {
+		if value, ok := fields[field]; ok {
+			detail[field] = value
+		}
+	}
+	if userId, ok := fields["user_id"].(string); ok && userId != "" {
+		detail["user_id"] = userId
+	}
+
+	return detail
+}
+
+// auditActor is the principal a request is made with, only dify calling with the server key may name the
+// actor with the header or the user a request is made for
+func auditActor(c *gin.Context, detail map[string]any) string {
+	if principal, ok := requestPrincipal(c); ok {
+		switch principal.Kind {
+		case access_control.PRINCIPAL_SERVER_KEY, access_control.PRINCIPAL_CLIENT_CERT:
+		case access_control.PRINCIPAL_ADMIN_KEY:
+			return AUDIT_ACTOR_ADMIN
+		default:
+			return principal.Kind + ":" + principal.Name
+		}
+	}
+	if actor := c.GetHeader(constants.X_AUDIT_ACTOR); actor != "" {
+		return actor
+	}
+	if userId, ok := detail["user_id"].(string); ok {
+		return userId
+	}
+	return AUDIT_ACTOR_DIFY
+}
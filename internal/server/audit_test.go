@@ -0,0 +1,107 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/access_control"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/audit"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditMiddleware(t *testing.T) {
+	dbConfig := &app.Config{
+		DBType:       "sqlite",
+		DBSqlitePath: filepath.Join(t.TempDir(), "audit.db"),
+	}
+	dbConfig.SetDefault()
+	db.Init(dbConfig)
+	t.Cleanup(db.Close)
+
+	audit.Start()
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/plugin/:tenant_id/variables", Audit(audit.EVENT_CREDENTIAL_SET), func(c *gin.Context) {
+		var request struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		}
+		require.NoError(t, c.ShouldBindJSON(&request))
+		if request.Value == "" {
+			c.JSON(http.StatusOK, exception.BadRequestError(errors.New("value is required")).ToResponse())
+			return
+		}
+		c.JSON(http.StatusOK, entities.NewSuccessResponse(true))
+	})
+
+	const tenantId = "00000000-0000-0000-0000-000000000001"
+	send := func(body string, actor string) {
+		request := httptest.NewRequest(http.MethodPost, "/plugin/"+tenantId+"/variables", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		if actor != "" {
+			request.Header.Set(constants.X_AUDIT_ACTOR, actor)
+		}
+		engine.ServeHTTP(httptest.NewRecorder(), request)
+	}
+	send(`{"name":"API_KEY","value":"sk-secret"}`, "alice")
+	send(`{"name":"API_KEY","value":"","user_id":"bob"}`, "")
+
+	records, err := audit.List(audit.Filter{TenantID: tenantId}, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	byActor := map[string]models.AuditRecord{}
+	for _, record := range records {
+		byActor[record.Actor] = record
+		assert.Equal(t, audit.EVENT_CREDENTIAL_SET, record.Event)
+		assert.Equal(t, "API_KEY", record.Detail["name"])
+		assert.NotContains(t, record.Detail, "value")
+	}
+	assert.Equal(t, models.AuditResultSuccess, byActor["alice"].Result)
+	assert.Equal(t, models.AuditResultFailure, byActor["bob"].Result)
+
+	failures, err := audit.List(audit.Filter{Result: models.AuditResultFailure}, 1, 10)
+	require.NoError(t, err)
+	assert.Len(t, failures, 1)
+
+	var exported bytes.Buffer
+	require.NoError(t, audit.Export(audit.Filter{TenantID: tenantId}, &exported))
+	lines := 0
+	scanner := bufio.NewScanner(&exported)
+	for scanner.Scan() {
+		assert.NotContains(t, scanner.Text(), "sk-secret")
+		lines++
+	}
+	assert.Equal(t, 2, lines)
+}
+
+func TestAuditActorIgnoresHeaderOfOtherPrincipals(t *testing.T) {
+	actor := func(principal *access_control.Principal, header string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+		c.Request.Header.Set(constants.X_AUDIT_ACTOR, header)
+		c.Set(constants.CONTEXT_KEY_PRINCIPAL, principal)
+		return auditActor(c, map[string]any{"user_id": "bob"})
+	}
+
+	assert.Equal(t, "alice", actor(&access_control.Principal{Kind: access_control.PRINCIPAL_SERVER_KEY}, "alice"))
+	assert.Equal(t, "bob", actor(&access_control.Principal{Kind: access_control.PRINCIPAL_CLIENT_CERT}, ""))
+	assert.Equal(t, "api_key:ci", actor(&access_control.Principal{Kind: access_control.PRINCIPAL_API_KEY, Name: "ci"}, "alice"))
+	assert.Equal(t, "jwt:ops", actor(&access_control.Principal{Kind: access_control.PRINCIPAL_JWT, Name: "ops"}, ""))
+	assert.Equal(t, AUDIT_ACTOR_ADMIN, actor(&access_control.Principal{Kind: access_control.PRINCIPAL_ADMIN_KEY}, "alice"))
+}
@@ -4,8 +4,18 @@ const (
 	X_PLUGIN_ID     = "X-Plugin-ID"
 	X_API_KEY       = "X-Api-Key"
 	X_ADMIN_API_KEY = "X-Admin-Api-Key"
+	// X_AUDIT_ACTOR is who the caller acts for, it's recorded as the actor of audit events
+	X_AUDIT_ACTOR = "X-Dify-Actor"
+
+	// X_CLUSTER_REDIRECTED_FROM is the id of the node a request was redirected from
+	X_CLUSTER_REDIRECTED_FROM = "X-Dify-Cluster-Redirected-From"
+	// X_PLUGIN_UNIQUE_IDENTIFIER is the version a rollout picked for a request, the node it's redirected to keeps it
+	X_PLUGIN_UNIQUE_IDENTIFIER = "X-Dify-Plugin-Unique-Identifier"
 
 	CONTEXT_KEY_PLUGIN_INSTALLATION      = "plugin_installation"
 	CONTEXT_KEY_PLUGIN_UNIQUE_IDENTIFIER = "plugin_unique_identifier"
 	CONTEXT_KEY_CLUSTER_ID               = "cluster_id"
+	CONTEXT_KEY_PRINCIPAL                = "principal"
+	// CONTEXT_KEY_STREAM_IDLE_TIMEOUT is the time.Duration a stream of the route may stay silent
+	CONTEXT_KEY_STREAM_IDLE_TIMEOUT = "stream_idle_timeout"
 )
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/cluster"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+)
+
+func ListAllPluginInstallations(cluster *cluster.Cluster) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			TenantID    string `form:"tenant_id"`
+			PluginID    string `form:"plugin_id"`
+			RuntimeType string `form:"runtime_type" validate:"omitempty,oneof=local remote serverless"`
+			Source      string `form:"source"`
+			Status      string `form:"status" validate:"omitempty,oneof=pending launching active restarting stopped not_running serverless"`
+			Category    string `form:"category" validate:"omitempty,oneof=tool model extension agent-strategy"`
+			ModelType   string `form:"model_type" validate:"omitempty,oneof=llm text-embedding rerank speech2text moderation tts text2img"`
+			SortBy      string `form:"sort_by" validate:"omitempty,oneof=installed_at last_invoked_at"`
+			Order       string `form:"order" validate:"omitempty,oneof=asc desc"`
+			Page        int    `form:"page" validate:"required,min=1"`
+			PageSize    int    `form:"page_size" validate:"required,min=1,max=256"`
+		}) {
+			c.JSON(http.StatusOK, service.ListAllPluginInstallations(cluster, service.PluginInstallationQuery{
+				TenantID:    request.TenantID,
+				PluginID:    request.PluginID,
+				RuntimeType: request.RuntimeType,
+				Source:      request.Source,
+				Status:      request.Status,
+				Category:    request.Category,
+				ModelType:   request.ModelType,
+				SortBy:      request.SortBy,
+				// the latest come first unless asked otherwise
+				Descending: request.Order != "asc",
+				Page:       request.Page,
+				PageSize:   request.PageSize,
+			}))
+		})
+	}
+}
+
+func FetchPluginInstallationDetail(cluster *cluster.Cluster) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			InstallationID string `uri:"installation_id" validate:"required"`
+		}) {
+			c.JSON(http.StatusOK, service.FetchPluginInstallationDetail(cluster, request.InstallationID))
+		})
+	}
+}
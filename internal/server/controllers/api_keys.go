@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+)
+
+func ListRoles(c *gin.Context) {
+	c.JSON(http.StatusOK, service.ListRoles())
+}
+
+func ListAPIKeys(c *gin.Context) {
+	c.JSON(http.StatusOK, service.ListAPIKeys())
+}
+
+func CreateAPIKey(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		Name     string   `json:"name" validate:"required,max=127"`
+		Roles    []string `json:"roles" validate:"required,min=1,dive,oneof=admin operator invoker debugger publisher"`
+		TenantID string   `json:"tenant_id" validate:"omitempty,max=64"`
+		// Organization limits the key to an organization of the plugin registry
+		Organization string `json:"organization" validate:"omitempty,max=64"`
+		// seconds until the key expires, zero for never
+		ExpiresIn int64 `json:"expires_in" validate:"min=0"`
+	}) {
+		c.JSON(http.StatusOK, service.CreateAPIKey(
+			request.Name,
+			request.Roles,
+			request.TenantID,
+			request.Organization,
+			time.Duration(request.ExpiresIn)*time.Second,
+		))
+	})
+}
+
+func RotateAPIKey(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		ID string `uri:"id" validate:"required"`
+		// seconds the replaced key remains valid
+		GracePeriod int64 `json:"grace_period" validate:"min=0"`
+	}) {
+		c.JSON(http.StatusOK, service.RotateAPIKey(request.ID, time.Duration(request.GracePeriod)*time.Second))
+	})
+}
+
+func RevokeAPIKey(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		ID string `uri:"id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.RevokeAPIKey(request.ID))
+	})
+}
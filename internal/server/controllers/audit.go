@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/audit"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+)
+
+type auditFilterRequest struct {
+	TenantID string    `form:"tenant_id"`
+	Event    string    `form:"event"`
+	Actor    string    `form:"actor"`
+	Result   string    `form:"result" validate:"omitempty,oneof=success failure"`
+	From     time.Time `form:"from"`
+	To       time.Time `form:"to"`
+}
+
+func (r auditFilterRequest) filter() audit.Filter {
+	return audit.Filter{
+		TenantID: r.TenantID,
+		Event:    r.Event,
+		Actor:    r.Actor,
+		Result:   models.AuditResult(r.Result),
+		From:     r.From,
+		To:       r.To,
+	}
+}
+
+func ListAuditRecords(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		auditFilterRequest
+		Page     int `form:"page" validate:"required,min=1"`
+		PageSize int `form:"page_size" validate:"required,min=1,max=256"`
+	}) {
+		c.JSON(http.StatusOK, service.ListAuditRecords(request.filter(), request.Page, request.PageSize))
+	})
+}
+
+func ExportAuditRecords(c *gin.Context) {
+	BindRequest(c, func(request auditFilterRequest) {
+		service.ExportAuditRecords(c, request.filter())
+	})
+}
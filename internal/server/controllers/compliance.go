@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+)
+
+func FetchTenantDataInventory(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.FetchTenantDataInventory(request.TenantID))
+	})
+}
+
+func PurgeTenantData(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.PurgeTenantData(request.TenantID))
+	})
+}
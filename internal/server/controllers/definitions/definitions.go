@@ -241,7 +241,7 @@ var PluginDispatchers = []PluginDispatcher{
 		ResponseType:       dynamic_select_entities.DynamicSelectResult{},
 		AccessType:         access_types.PLUGIN_ACCESS_TYPE_DYNAMIC_PARAMETER,
 		AccessAction:       access_types.PLUGIN_ACCESS_ACTION_DYNAMIC_PARAMETER_FETCH_OPTIONS,
-		AccessTypeString:   "access_types.PLUGIN_ACCESS_TYPE_DYNAMIC_SELECT",
+		AccessTypeString:   "access_types.PLUGIN_ACCESS_TYPE_DYNAMIC_PARAMETER",
 		AccessActionString: "access_types.PLUGIN_ACCESS_ACTION_DYNAMIC_PARAMETER_FETCH_OPTIONS",
 		BufferSize:         1,
 		Path:               "/dynamic_select/fetch_parameter_options",
@@ -3,6 +3,7 @@ package controllers
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/endpoint_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
@@ -111,3 +112,42 @@ func DisableEndpoint(ctx *gin.Context) {
 		ctx.JSON(200, service.DisableEndpoint(endpointId, tenantId))
 	})
 }
+
+func SetEndpointMiddlewares(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		EndpointID  string                                 `json:"endpoint_id" validate:"required"`
+		TenantID    string                                 `uri:"tenant_id" validate:"required"`
+		Middlewares []endpoint_entities.EndpointMiddleware `json:"middlewares" validate:"max=16,dive"`
+	}) {
+		ctx.JSON(200, service.SetEndpointMiddlewares(request.EndpointID, request.TenantID, request.Middlewares))
+	})
+}
+
+func SetEndpointDomain(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		EndpointID string `json:"endpoint_id" validate:"required"`
+		TenantID   string `uri:"tenant_id" validate:"required"`
+		Hostname   string `json:"hostname" validate:"required,hostname"`
+		PathPrefix string `json:"path_prefix" validate:"omitempty,max=255"`
+	}) {
+		ctx.JSON(200, service.SetEndpointDomain(request.EndpointID, request.TenantID, request.Hostname, request.PathPrefix))
+	})
+}
+
+func RemoveEndpointDomain(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		DomainID string `json:"domain_id" validate:"required"`
+		TenantID string `uri:"tenant_id" validate:"required"`
+	}) {
+		ctx.JSON(200, service.RemoveEndpointDomain(request.DomainID, request.TenantID))
+	})
+}
+
+func ListEndpointDomains(ctx *gin.Context) {
+	BindRequest(ctx, func(request struct {
+		EndpointID string `form:"endpoint_id" validate:"required"`
+		TenantID   string `uri:"tenant_id" validate:"required"`
+	}) {
+		ctx.JSON(200, service.ListEndpointDomains(request.EndpointID, request.TenantID))
+	})
+}
@@ -198,6 +198,76 @@ func GenerateHTTPServer(dispatchers []*definitions.PluginDispatcher) error {
 	return nil
 }
 
+// GenerateProto generates the grpc service definition for all dispatchers
+func GenerateProto(dispatchers []*definitions.PluginDispatcher) error {
+	// Create template
+	tmpl := template.Must(template.New("proto").Parse(protoTemplate))
+
+	// Create output file
+	outputPath := filepath.Join("pkg", "proto", "dispatch", "dispatch.proto")
+
+	// Execute template
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, struct {
+		Dispatchers []*definitions.PluginDispatcher
+	}{
+		Dispatchers: dispatchers,
+	}); err != nil {
+		return fmt.Errorf("failed to execute template: %v", err)
+	}
+
+	// protobuf is not go code, no formatting is needed
+	if err := os.WriteFile(outputPath, []byte(buf.String()), 0o644); err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+
+	return nil
+}
+
+// GenerateGRPCServer generates the grpc server implementation for all dispatchers
+func GenerateGRPCServer(dispatchers []*definitions.PluginDispatcher) error {
+	// Create template
+	tmpl := template.Must(template.New("grpcServer").Parse(grpcServerTemplate))
+
+	// Create output file
+	outputPath := filepath.Join("internal", "server", "grpc_server.gen.go")
+
+	// Execute template
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, struct {
+		Dispatchers []*definitions.PluginDispatcher
+	}{
+		Dispatchers: dispatchers,
+	}); err != nil {
+		return fmt.Errorf("failed to execute template: %v", err)
+	}
+
+	// Format code
+	src, err := format.Source([]byte(buf.String()))
+	if err != nil {
+		return fmt.Errorf("failed to format code: %v", err)
+	}
+
+	// imports necessary packages
+	output, err := imports.Process(outputPath, src, nil)
+	if err != nil {
+		return fmt.Errorf("failed to process imports: %v", err)
+	}
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %v", err)
+	}
+	defer f.Close()
+
+	// Write to file
+	if _, err := f.Write(output); err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+
+	return nil
+}
+
 // GenerateAll generates all controller and service files based on dispatchers
 func GenerateAll() error {
 	// Group dispatchers by access type
@@ -227,16 +297,28 @@ func GenerateAll() error {
 		}
 	}
 
-	if err := GenerateHTTPServer(
-		mapping.MapArray(
-			definitions.PluginDispatchers,
-			func(dispatcher definitions.PluginDispatcher) *definitions.PluginDispatcher {
-				return &dispatcher
-			},
-		),
-	); err != nil {
+	allDispatchers := mapping.MapArray(
+		definitions.PluginDispatchers,
+		func(dispatcher definitions.PluginDispatcher) *definitions.PluginDispatcher {
+			return &dispatcher
+		},
+	)
+
+	if err := GenerateHTTPServer(allDispatchers); err != nil {
 		return fmt.Errorf("failed to generate http server: %v", err)
 	}
 
+	if err := GenerateProto(allDispatchers); err != nil {
+		return fmt.Errorf("failed to generate proto: %v", err)
+	}
+
+	if err := GenerateGRPCServer(allDispatchers); err != nil {
+		return fmt.Errorf("failed to generate grpc server: %v", err)
+	}
+
+	if err := GenerateEventSchema(); err != nil {
+		return fmt.Errorf("failed to generate event schema: %v", err)
+	}
+
 	return nil
 }
@@ -0,0 +1,238 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/backwards_invocation"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/controllers/definitions"
+	jsonschema "github.com/langgenius/dify-plugin-daemon/internal/utils/json_schema"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	EVENT_SCHEMA_PATH = "pkg/schema/events.schema.json"
+
+	// the entry points of the event schema, everything else is referenced from them
+	EVENT_SCHEMA_PLUGIN_EVENT    = "PluginEvent"
+	EVENT_SCHEMA_SESSION_MESSAGE = "SessionMessage"
+	EVENT_SCHEMA_DAEMON_EVENT    = "DaemonEvent"
+	EVENT_SCHEMA_STREAM_RESPONSE = "StreamResponse"
+)
+
+// EventSchemaRequestDefinition and EventSchemaResponseDefinition name the payloads of a dispatcher
+func EventSchemaRequestDefinition(dispatcher definitions.PluginDispatcher) string {
+	return dispatcher.Name + "Request"
+}
+
+func EventSchemaResponseDefinition(dispatcher definitions.PluginDispatcher) string {
+	return dispatcher.Name + "Response"
+}
+
+func enum[T ~string](values ...T) map[string]any {
+	items := make([]any, 0, len(values))
+	for _, value := range values {
+		items = append(items, string(value))
+	}
+	return map[string]any{"type": "string", "enum": items}
+}
+
+// when returns a draft-07 conditional applying then when property of the object equals value
+func when(property string, value any, then map[string]any) map[string]any {
+	return map[string]any{
+		"if": map[string]any{
+			"properties": map[string]any{property: map[string]any{"const": value}},
+			"required":   []any{property},
+		},
+		"then": then,
+	}
+}
+
+// BuildEventSchema describes the events exchanged with plugins over stdio or http and the
+// events streamed to callers, the schemas are derived from the go entities
+func BuildEventSchema() map[string]any {
+	reflector := jsonschema.NewReflector()
+	ref := func(v any) map[string]any {
+		return reflector.Ref(reflect.TypeOf(v))
+	}
+
+	// agent strategies and endpoints are dispatched outside of the generated routes, their
+	// requests are not described but must still be accepted
+	requestConditions := []any{}
+	for _, dispatcher := range definitions.PluginDispatchers {
+		request := reflector.Reflect(reflect.TypeOf(dispatcher.RequestType))
+		reflector.Definitions[EventSchemaRequestDefinition(dispatcher)] = request
+		reflector.Definitions[EventSchemaResponseDefinition(dispatcher)] = reflector.Reflect(
+			reflect.TypeOf(dispatcher.ResponseType),
+		)
+		requestConditions = append(requestConditions, when("action", string(dispatcher.AccessAction), map[string]any{
+			"$ref": "#/definitions/" + EventSchemaRequestDefinition(dispatcher),
+		}))
+	}
+
+	reflector.Definitions[EVENT_SCHEMA_PLUGIN_EVENT] = map[string]any{
+		"description": "an event written by a plugin to stdout or to the daemon connection, one json object per line",
+		"type":        "object",
+		"properties": map[string]any{
+			"session_id": map[string]any{"type": "string"},
+			"event": enum(
+				plugin_entities.PLUGIN_EVENT_LOG,
+				plugin_entities.PLUGIN_EVENT_SESSION,
+				plugin_entities.PLUGIN_EVENT_ERROR,
+				plugin_entities.PLUGIN_EVENT_HEARTBEAT,
+				plugin_entities.PLUGIN_EVENT_PONG,
+				plugin_entities.PLUGIN_EVENT_HANDSHAKE,
+			),
+			"data": map[string]any{},
+		},
+		"required": []any{"event"},
+		"allOf": []any{
+			when("event", string(plugin_entities.PLUGIN_EVENT_LOG), map[string]any{
+				"properties": map[string]any{"data": ref(plugin_entities.PluginLogEvent{})},
+			}),
+			when("event", string(plugin_entities.PLUGIN_EVENT_SESSION), map[string]any{
+				"properties": map[string]any{
+					"data": map[string]any{"$ref": "#/definitions/" + EVENT_SCHEMA_SESSION_MESSAGE},
+				},
+				"required": []any{"session_id", "data"},
+			}),
+			when("event", string(plugin_entities.PLUGIN_EVENT_PONG), map[string]any{
+				"properties": map[string]any{"data": ref(plugin_entities.PluginPingEvent{})},
+				"required":   []any{"data"},
+			}),
+			when("event", string(plugin_entities.PLUGIN_EVENT_HANDSHAKE), map[string]any{
+				"properties": map[string]any{"data": ref(plugin_entities.PluginHandshakeEvent{})},
+				"required":   []any{"data"},
+			}),
+		},
+	}
+
+	reflector.Definitions[EVENT_SCHEMA_SESSION_MESSAGE] = map[string]any{
+		"description": "a message of a session, data of stream messages is the response of the action of the session",
+		"type":        "object",
+		"properties": map[string]any{
+			"type": enum(
+				plugin_entities.SESSION_MESSAGE_TYPE_STREAM,
+				plugin_entities.SESSION_MESSAGE_TYPE_END,
+				plugin_entities.SESSION_MESSAGE_TYPE_ERROR,
+				plugin_entities.SESSION_MESSAGE_TYPE_INVOKE,
+			),
+			"data": map[string]any{},
+		},
+		"required": []any{"type", "data"},
+		"allOf": []any{
+			when("type", string(plugin_entities.SESSION_MESSAGE_TYPE_ERROR), map[string]any{
+				"properties": map[string]any{"data": ref(plugin_entities.ErrorResponse{})},
+			}),
+			when("type", string(plugin_entities.SESSION_MESSAGE_TYPE_INVOKE), map[string]any{
+				"properties": map[string]any{"data": map[string]any{
+					"description": "a backwards invocation of dify",
+					"type":        "object",
+					"properties": map[string]any{
+						"type":                 map[string]any{"type": "string"},
+						"backwards_request_id": map[string]any{"type": "string"},
+						"request":              map[string]any{"type": "object"},
+					},
+					"required": []any{"type", "backwards_request_id", "request"},
+				}},
+			}),
+		},
+	}
+
+	reflector.Definitions[EVENT_SCHEMA_DAEMON_EVENT] = map[string]any{
+		"description": "an event written by the daemon to stdin of a plugin or to the daemon connection",
+		"type":        "object",
+		"properties": map[string]any{
+			"session_id":      map[string]any{"type": "string"},
+			"conversation_id": map[string]any{"type": []any{"string", "null"}},
+			"message_id":      map[string]any{"type": []any{"string", "null"}},
+			"app_id":          map[string]any{"type": []any{"string", "null"}},
+			"endpoint_id":     map[string]any{"type": []any{"string", "null"}},
+			"context":         map[string]any{"type": []any{"object", "null"}},
+			"headers":         map[string]any{"type": []any{"object", "null"}},
+			"event": enum(
+				session_manager.PLUGIN_IN_STREAM_EVENT_REQUEST,
+				session_manager.PLUGIN_IN_STREAM_EVENT_RESPONSE,
+			),
+			"data": map[string]any{},
+		},
+		"required": []any{"session_id", "event", "data"},
+		"allOf": []any{
+			when("event", string(session_manager.PLUGIN_IN_STREAM_EVENT_REQUEST), map[string]any{
+				"properties": map[string]any{"data": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"user_id": map[string]any{"type": "string"},
+						"type":    map[string]any{"type": "string"},
+						"action":  map[string]any{"type": "string"},
+					},
+					"required": []any{"type", "action"},
+					"allOf":    requestConditions,
+				}},
+			}),
+			when("event", string(session_manager.PLUGIN_IN_STREAM_EVENT_RESPONSE), map[string]any{
+				"properties": map[string]any{"data": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"backwards_request_id": map[string]any{"type": "string"},
+						"event": enum(
+							backwards_invocation.REQUEST_EVENT_RESPONSE,
+							backwards_invocation.REQUEST_EVENT_ERROR,
+							backwards_invocation.REQUEST_EVENT_END,
+						),
+						"message": map[string]any{"type": "string"},
+						"data":    map[string]any{},
+					},
+					"required": []any{"backwards_request_id", "event"},
+				}},
+			}),
+		},
+	}
+
+	reflector.Definitions[EVENT_SCHEMA_STREAM_RESPONSE] = map[string]any{
+		"description": "a server sent event of the dispatch api, data is the response of the action " +
+			"if code is 0, otherwise message is a json encoded error",
+		"allOf": []any{ref(entities.Response{})},
+	}
+
+	return map[string]any{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"title":   "dify plugin daemon events",
+		"description": fmt.Sprintf(
+			"generated by cmd/codegen, entry points are %s, %s, %s and %s",
+			EVENT_SCHEMA_PLUGIN_EVENT,
+			EVENT_SCHEMA_SESSION_MESSAGE,
+			EVENT_SCHEMA_DAEMON_EVENT,
+			EVENT_SCHEMA_STREAM_RESPONSE,
+		),
+		"definitions": reflector.Definitions,
+	}
+}
+
+// MarshalEventSchema renders the schema the way it's committed
+func MarshalEventSchema() ([]byte, error) {
+	content, err := json.MarshalIndent(BuildEventSchema(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(content, '\n'), nil
+}
+
+// GenerateEventSchema writes the json schema of events for SDKs in other languages
+func GenerateEventSchema() error {
+	content, err := MarshalEventSchema()
+	if err != nil {
+		return fmt.Errorf("failed to marshal event schema: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.FromSlash(EVENT_SCHEMA_PATH), content, 0o644); err != nil {
+		return fmt.Errorf("failed to write file: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/schema"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+func TestEventSchemaIsUpToDate(t *testing.T) {
+	content, err := MarshalEventSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(content, schema.Events) {
+		t.Fatalf("%s is outdated, run `go run ./cmd/codegen` to regenerate it", EVENT_SCHEMA_PATH)
+	}
+}
+
+// validateEvent validates an event against a definition of the published schema
+func validateEvent(t *testing.T, definition string, event any) bool {
+	document := map[string]any{}
+	if err := json.Unmarshal(schema.Events, &document); err != nil {
+		t.Fatal(err)
+	}
+	document["$ref"] = "#/definitions/" + definition
+
+	result, err := gojsonschema.Validate(
+		gojsonschema.NewGoLoader(document),
+		gojsonschema.NewBytesLoader(parser.MarshalJsonBytes(event)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result.Valid()
+}
+
+func TestEventSchemaConformance(t *testing.T) {
+	chunk := tool_entities.ToolResponseChunk{
+		Type:    tool_entities.ToolResponseChunkTypeText,
+		Message: map[string]any{"text": "hello"},
+	}
+
+	cases := []struct {
+		name       string
+		definition string
+		event      any
+		valid      bool
+	}{
+		{"log", EVENT_SCHEMA_PLUGIN_EVENT, map[string]any{
+			"event": plugin_entities.PLUGIN_EVENT_LOG,
+			"data":  plugin_entities.PluginLogEvent{Level: "INFO", Message: "started", Timestamp: 1},
+		}, true},
+		{"heartbeat", EVENT_SCHEMA_PLUGIN_EVENT, map[string]any{
+			"event": plugin_entities.PLUGIN_EVENT_HEARTBEAT,
+		}, true},
+		{"unknown event", EVENT_SCHEMA_PLUGIN_EVENT, map[string]any{
+			"event": "unknown",
+		}, false},
+		{"session stream", EVENT_SCHEMA_PLUGIN_EVENT, map[string]any{
+			"event":      plugin_entities.PLUGIN_EVENT_SESSION,
+			"session_id": "session",
+			"data": map[string]any{
+				"type": plugin_entities.SESSION_MESSAGE_TYPE_STREAM,
+				"data": chunk,
+			},
+		}, true},
+		{"session without id", EVENT_SCHEMA_PLUGIN_EVENT, map[string]any{
+			"event": plugin_entities.PLUGIN_EVENT_SESSION,
+			"data": map[string]any{
+				"type": plugin_entities.SESSION_MESSAGE_TYPE_END,
+				"data": map[string]any{},
+			},
+		}, false},
+		{"session error", EVENT_SCHEMA_SESSION_MESSAGE, map[string]any{
+			"type": plugin_entities.SESSION_MESSAGE_TYPE_ERROR,
+			"data": plugin_entities.ErrorResponse{Message: "failed", ErrorType: "PluginInvokeError"},
+		}, true},
+		{"invoke without request", EVENT_SCHEMA_SESSION_MESSAGE, map[string]any{
+			"type": plugin_entities.SESSION_MESSAGE_TYPE_INVOKE,
+			"data": map[string]any{"type": "tool", "backwards_request_id": "request"},
+		}, false},
+		{"tool chunk", "InvokeToolResponse", chunk, true},
+		{"tool chunk without type", "InvokeToolResponse", map[string]any{"message": map[string]any{}}, false},
+		{"tool request", EVENT_SCHEMA_DAEMON_EVENT, map[string]any{
+			"session_id": "session",
+			"event":      session_manager.PLUGIN_IN_STREAM_EVENT_REQUEST,
+			"data": map[string]any{
+				"user_id":  "user",
+				"type":     "tool",
+				"action":   "invoke_tool",
+				"provider": "provider",
+				"tool":     "tool",
+			},
+		}, true},
+		{"tool request without tool", EVENT_SCHEMA_DAEMON_EVENT, map[string]any{
+			"session_id": "session",
+			"event":      session_manager.PLUGIN_IN_STREAM_EVENT_REQUEST,
+			"data": map[string]any{
+				"type":     "tool",
+				"action":   "invoke_tool",
+				"provider": "provider",
+			},
+		}, false},
+		{"endpoint request", EVENT_SCHEMA_DAEMON_EVENT, map[string]any{
+			"session_id": "session",
+			"event":      session_manager.PLUGIN_IN_STREAM_EVENT_REQUEST,
+			"data": map[string]any{
+				"type":   "endpoint",
+				"action": "invoke_endpoint",
+				"raw":    "",
+			},
+		}, true},
+		{"stream response", EVENT_SCHEMA_STREAM_RESPONSE, entities.NewSuccessResponse(chunk), true},
+		{"stream error", EVENT_SCHEMA_STREAM_RESPONSE, exception.ErrPluginNotFound().ToResponse(), true},
+		{"stream response without code", EVENT_SCHEMA_STREAM_RESPONSE, map[string]any{"code": "0"}, false},
+	}
+
+	for _, c := range cases {
+		if valid := validateEvent(t, c.definition, c.event); valid != c.valid {
+			t.Errorf("%s: expected valid to be %v, got %v", c.name, c.valid, valid)
+		}
+	}
+}
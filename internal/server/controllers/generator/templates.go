@@ -106,3 +106,87 @@ func (app *App) setupGeneratedRoutes(group *gin.RouterGroup, config *app.Config)
 	{{- end}}
 }
 `
+
+// protoTemplate is the template for generating the grpc service definition
+const protoTemplate = `// Code generated by controller generator. DO NOT EDIT.
+
+syntax = "proto3";
+
+package dify_plugin_daemon.dispatch;
+
+option go_package = "github.com/langgenius/dify-plugin-daemon/pkg/proto/dispatch";
+
+import "google/protobuf/struct.proto";
+
+// DispatchRequest is the grpc equivalent of the json body of /plugin/{tenant_id}/dispatch/*
+message DispatchRequest {
+  string tenant_id = 1;
+  string plugin_id = 2;
+  string user_id = 3;
+  optional string conversation_id = 4;
+  optional string message_id = 5;
+  optional string app_id = 6;
+  optional string endpoint_id = 7;
+  google.protobuf.Struct context = 8;
+  google.protobuf.Struct data = 9;
+}
+
+// DispatchResponse wraps a chunk of the plugin response, errors are returned as grpc status
+message DispatchResponse {
+  google.protobuf.Struct data = 1;
+}
+
+// EndpointRequest is the grpc equivalent of a request to /e/{hook_id}/*, path is below the hook
+// and may carry a query string
+message EndpointRequest {
+  string hook_id = 1;
+  string method = 2;
+  string path = 3;
+  map<string, string> headers = 4;
+  bytes body = 5;
+}
+
+// EndpointResponse carries the status and headers in the first message, chunks of the body follow
+message EndpointResponse {
+  int32 status = 1;
+  map<string, string> headers = 2;
+  bytes body = 3;
+}
+
+service PluginDispatch {
+{{- range .Dispatchers}}
+  rpc {{.Name}}(DispatchRequest) returns (stream DispatchResponse);
+{{- end}}
+  rpc InvokeAgentStrategy(DispatchRequest) returns (stream DispatchResponse);
+  rpc InvokeEndpoint(EndpointRequest) returns (stream EndpointResponse);
+}
+`
+
+const grpcServerTemplate = `// Code generated by controller generator. DO NOT EDIT.
+package server
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+	"github.com/langgenius/dify-plugin-daemon/pkg/proto/dispatch"
+	"google.golang.org/grpc"
+)
+
+{{range .Dispatchers}}
+func (s *grpcDispatchServer) {{.Name}}(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		{{.AccessTypeString}},
+		{{.AccessActionString}},
+		"{{.Path}}",
+		plugin_daemon.{{.Name}},
+	)
+}
+{{end}}
+`
@@ -4,16 +4,37 @@ import (
 	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/cluster"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/anomaly"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/retention"
 	"github.com/langgenius/dify-plugin-daemon/internal/manifest"
+	"github.com/langgenius/dify-plugin-daemon/internal/oss"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
 )
 
 var (
 	activeRequests         int32 = 0 // how many requests are active
 	activeDispatchRequests int32 = 0 // how many plugin dispatching requests are active
+
+	// draining is set on shutdown, new invocations are rejected while active ones finish
+	draining atomic.Bool
 )
 
+func StartDraining() {
+	draining.Store(true)
+}
+
+func Draining() bool {
+	return draining.Load()
+}
+
+func ActiveDispatchRequests() int32 {
+	return atomic.LoadInt32(&activeDispatchRequests)
+}
+
 func CollectActiveRequests() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		atomic.AddInt32(&activeRequests, 1)
@@ -32,14 +53,46 @@ func CollectActiveDispatchRequests() gin.HandlerFunc {
 
 func HealthCheck(app *app.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status":                   "ok",
+		// load balancers stop routing to a draining node
+		code, status := 200, "ok"
+		if Draining() {
+			code, status = 503, "draining"
+		} else if oss.StorageDown() {
+			// plugins can't be installed or run without storage, see STORAGE_PROBE_ERROR_THRESHOLD
+			code, status = 503, "storage_down"
+		}
+
+		c.JSON(code, gin.H{
+			"status":                   status,
 			"pool_status":              routine.FetchRoutineStatus(),
 			"version":                  manifest.VersionX,
 			"build_time":               manifest.BuildTimeX,
 			"platform":                 app.Platform,
 			"active_requests":          activeRequests,
 			"active_dispatch_requests": activeDispatchRequests,
+			"storage":                  oss.FetchProbeStatus(),
 		})
 	}
 }
+
+// FetchAnomalyStatus returns the baselines learned by current node and the recent alerts
+func FetchAnomalyStatus(c *gin.Context) {
+	c.JSON(200, entities.NewSuccessResponse(anomaly.FetchStatus()))
+}
+
+func FetchRetentionStatus(c *gin.Context) {
+	c.JSON(200, entities.NewSuccessResponse(retention.FetchStatus()))
+}
+
+func FetchNodeStats(c *gin.Context) {
+	c.JSON(200, service.FetchNodeStats(
+		atomic.LoadInt32(&activeRequests),
+		atomic.LoadInt32(&activeDispatchRequests),
+	))
+}
+
+func ListClusterNodes(cluster *cluster.Cluster) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, service.ListClusterNodes(cluster))
+	}
+}
@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+)
+
+func ListPluginInstallApprovals(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `form:"tenant_id"`
+		Status   string `form:"status" validate:"omitempty,oneof=pending approved rejected failed"`
+		Page     int    `form:"page" validate:"required,min=1"`
+		PageSize int    `form:"page_size" validate:"required,min=1,max=256"`
+	}) {
+		c.JSON(http.StatusOK, service.ListPluginInstallApprovals(request.TenantID, request.Status, request.Page, request.PageSize))
+	})
+}
+
+func ListTenantPluginInstallApprovals(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+		Status   string `form:"status" validate:"omitempty,oneof=pending approved rejected failed"`
+		Page     int    `form:"page" validate:"required,min=1"`
+		PageSize int    `form:"page_size" validate:"required,min=1,max=256"`
+	}) {
+		c.JSON(http.StatusOK, service.ListPluginInstallApprovals(request.TenantID, request.Status, request.Page, request.PageSize))
+	})
+}
+
+func ApprovePluginInstall(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			ApprovalID string `uri:"id" validate:"required"`
+		}) {
+			c.JSON(http.StatusOK, service.ApprovePluginInstall(app, request.ApprovalID))
+		})
+	}
+}
+
+func RejectPluginInstall(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			ApprovalID string `uri:"id" validate:"required"`
+			Reason     string `json:"reason" validate:"max=1023"`
+		}) {
+			c.JSON(http.StatusOK, service.RejectPluginInstall(app, request.ApprovalID, request.Reason))
+		})
+	}
+}
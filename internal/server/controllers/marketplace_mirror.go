@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/marketplace_mirror"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// DownloadMirroredPackage serves the download api of the marketplace from the mirror, daemons pointing
+// MARKETPLACE_URL to this one download packages through it
+func DownloadMirroredPackage(c *gin.Context) {
+	pluginUniqueIdentifier, err := plugin_entities.NewPluginUniqueIdentifier(c.Query("unique_identifier"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, exception.UniqueIdentifierError(err).ToResponse())
+		return
+	}
+
+	reader, size, err := marketplace_mirror.GetMirror().Open(c, pluginUniqueIdentifier)
+	if errors.Is(err, marketplace_mirror.ErrNotMirrored) {
+		c.JSON(http.StatusNotFound, exception.NotFoundError(err).ToResponse())
+		return
+	} else if errors.Is(err, marketplace_mirror.ErrUpstreamUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, exception.UnavailableError(err.Error()).ToResponse())
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, exception.InternalServerError(err).ToResponse())
+		return
+	}
+	defer reader.Close()
+
+	// range requests let the downloader of other daemons fetch large packages in parallel
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, pluginUniqueIdentifier.String()+".difypkg", time.Time{}, seeker)
+		return
+	}
+
+	c.Header("Content-Type", "application/octet-stream")
+	c.Header("Content-Length", strconv.FormatInt(size, 10))
+	c.Status(http.StatusOK)
+	if c.Request.Method != http.MethodHead {
+		// the status is sent already, a daemon downloading it fails on the short body
+		if _, err := io.Copy(c.Writer, reader); err != nil {
+			log.Warn("failed to serve mirrored package %s: %s", pluginUniqueIdentifier, err.Error())
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+)
+
+func AuthorizeOAuth(config *app.Config) gin.HandlerFunc {
+	type request = plugin_entities.InvokePluginRequest[requests.RequestOAuthAuthorize]
+
+	return func(c *gin.Context) {
+		BindPluginDispatchRequest(c, func(itr request) {
+			c.JSON(http.StatusOK, service.AuthorizeOAuth(c, &itr, config.PluginMaxExecutionTimeout))
+		})
+	}
+}
+
+func OAuthCallback(config *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		service.OAuthCallback(c, config.PluginMaxExecutionTimeout)
+	}
+}
+
+func ListOAuthCredentials(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.ListOAuthCredentials(request.TenantID))
+	})
+}
+
+func DeleteOAuthCredentials(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+		PluginID string `json:"plugin_id" validate:"required"`
+		Provider string `json:"provider" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.DeleteOAuthCredentials(request.TenantID, request.PluginID, request.Provider))
+	})
+}
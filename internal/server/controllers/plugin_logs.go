@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// FetchPluginLogs returns the stdout and stderr captured from a local plugin on current node
+func FetchPluginLogs(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `form:"plugin_unique_identifier" validate:"required,plugin_unique_identifier"`
+		Lines                  int                                    `form:"lines" validate:"omitempty,min=1"`
+	}) {
+		c.JSON(http.StatusOK, service.FetchPluginLogs(request.PluginUniqueIdentifier, request.Lines))
+	})
+}
+
+func TailPluginLogs(config *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `form:"plugin_unique_identifier" validate:"required,plugin_unique_identifier"`
+			Lines                  int                                    `form:"lines" validate:"omitempty,min=1"`
+		}) {
+			service.TailPluginLogs(request.PluginUniqueIdentifier, request.Lines, c, config.PluginMaxExecutionTimeout)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func PublishRegistryPlugin(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		difyPkgFileHeader, err := c.FormFile("dify_pkg")
+		if err != nil {
+			c.JSON(http.StatusOK, exception.BadRequestError(err).ToResponse())
+			return
+		}
+
+		if difyPkgFileHeader.Size > app.MaxPluginPackageSize {
+			c.JSON(http.StatusOK, exception.BadRequestError(errors.New("file size exceeds the maximum limit")).ToResponse())
+			return
+		}
+
+		difyPkgFile, err := difyPkgFileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusOK, exception.BadRequestError(err).ToResponse())
+			return
+		}
+		defer difyPkgFile.Close()
+
+		pkg, err := io.ReadAll(difyPkgFile)
+		if err != nil {
+			c.JSON(http.StatusOK, exception.InternalServerError(err).ToResponse())
+			return
+		}
+
+		c.JSON(http.StatusOK, service.PublishRegistryPlugin(c.Param("organization"), pkg))
+	}
+}
+
+func SearchRegistryPlugins(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		Organization string `uri:"organization" validate:"required"`
+		Query        string `form:"query"`
+		Page         int    `form:"page" validate:"required,min=1"`
+		PageSize     int    `form:"page_size" validate:"required,min=1,max=256"`
+	}) {
+		c.JSON(http.StatusOK, service.SearchRegistryPlugins(request.Organization, request.Query, request.Page, request.PageSize))
+	})
+}
+
+func ListRegistryPluginVersions(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		Organization string `uri:"organization" validate:"required"`
+		Name         string `uri:"name" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.ListRegistryPluginVersions(request.Organization, request.Name))
+	})
+}
+
+// DownloadRegistryPlugin serves the package of a published version, latest is the newest version
+func DownloadRegistryPlugin(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		Organization string `uri:"organization" validate:"required"`
+		Name         string `uri:"name" validate:"required"`
+		Version      string `uri:"version" validate:"required"`
+	}) {
+		version := request.Version
+		if version == "latest" {
+			version = ""
+		}
+
+		plugin, pkg, response := service.FetchRegistryPluginPackage(request.Organization, request.Name, version)
+		if response != nil {
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", plugin.Name+"-"+plugin.Version+".difypkg"))
+		c.Header("X-Plugin-Unique-Identifier", plugin.PluginUniqueIdentifier)
+		c.Data(http.StatusOK, "application/octet-stream", pkg)
+	})
+}
+
+func DownloadPluginFromRegistry(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			TenantID               string                                 `uri:"tenant_id" validate:"required"`
+			PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier" validate:"required,plugin_unique_identifier"`
+			VerifySignature        bool                                   `json:"verify_signature"`
+		}) {
+			c.JSON(http.StatusOK, service.DownloadPluginPkgFromRegistry(app, request.PluginUniqueIdentifier, request.VerifySignature))
+		})
+	}
+}
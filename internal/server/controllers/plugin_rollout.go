@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func StartPluginRollout(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			StablePluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"stable_plugin_unique_identifier" validate:"required,plugin_unique_identifier"`
+			CanaryPluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"canary_plugin_unique_identifier" validate:"required,plugin_unique_identifier"`
+			Percentage                   int                                    `json:"percentage" validate:"min=0,max=100"`
+			Tenants                      []string                               `json:"tenants"`
+			MinInvocations               int64                                  `json:"min_invocations" validate:"min=0"`
+			MaxErrorRateIncrease         float64                                `json:"max_error_rate_increase" validate:"min=0,max=1"`
+		}) {
+			c.JSON(http.StatusOK, service.StartPluginRollout(app, service.PluginRolloutRequest{
+				StablePluginUniqueIdentifier: request.StablePluginUniqueIdentifier,
+				CanaryPluginUniqueIdentifier: request.CanaryPluginUniqueIdentifier,
+				Percentage:                   request.Percentage,
+				Tenants:                      request.Tenants,
+				MinInvocations:               request.MinInvocations,
+				MaxErrorRateIncrease:         request.MaxErrorRateIncrease,
+			}))
+		})
+	}
+}
+
+func ListPluginRollouts(c *gin.Context) {
+	c.JSON(http.StatusOK, service.ListPluginRollouts())
+}
+
+func FetchPluginRollout(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		RolloutID string `uri:"id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.FetchPluginRollout(request.RolloutID))
+	})
+}
+
+func UpdatePluginRollout(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		RolloutID  string   `uri:"id" validate:"required"`
+		Percentage int      `json:"percentage" validate:"min=0,max=100"`
+		Tenants    []string `json:"tenants"`
+	}) {
+		c.JSON(http.StatusOK, service.UpdatePluginRollout(request.RolloutID, request.Percentage, request.Tenants))
+	})
+}
+
+func PromotePluginRollout(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		RolloutID string `uri:"id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.PromotePluginRollout(request.RolloutID))
+	})
+}
+
+func RollbackPluginRollout(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		RolloutID string `uri:"id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.RollbackPluginRollout(request.RolloutID))
+	})
+}
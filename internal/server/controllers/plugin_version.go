@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func InstallPluginVersion(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			TenantID               string                                 `uri:"tenant_id" validate:"required"`
+			PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier" validate:"required,plugin_unique_identifier"`
+			Source                 string                                 `json:"source" validate:"required"`
+			Meta                   map[string]any                         `json:"meta" validate:"omitempty"`
+		}) {
+			if request.Meta == nil {
+				request.Meta = map[string]any{}
+			}
+
+			c.JSON(http.StatusOK, service.InstallPluginVersion(
+				app, request.TenantID, request.PluginUniqueIdentifier, request.Source, request.Meta,
+			))
+		})
+	}
+}
+
+func ListPluginVersions(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+		PluginID string `form:"plugin_id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.ListPluginVersions(request.TenantID, request.PluginID))
+	})
+}
+
+func UninstallPluginVersion(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+		PluginID string `json:"plugin_id" validate:"required"`
+		Version  string `json:"version" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.UninstallPluginVersion(request.TenantID, request.PluginID, request.Version))
+	})
+}
@@ -57,6 +57,62 @@ func UploadPlugin(app *app.Config) gin.HandlerFunc {
 	}
 }
 
+func DryRunPluginInstall(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		difyPkgFileHeader, err := c.FormFile("dify_pkg")
+		if err != nil {
+			c.JSON(http.StatusOK, exception.BadRequestError(err).ToResponse())
+			return
+		}
+
+		tenantId := c.Param("tenant_id")
+		if tenantId == "" {
+			c.JSON(http.StatusOK, exception.BadRequestError(errors.New("tenant ID is required")).ToResponse())
+			return
+		}
+
+		if difyPkgFileHeader.Size > app.MaxPluginPackageSize {
+			c.JSON(http.StatusOK, exception.BadRequestError(errors.New("file size exceeds the maximum limit")).ToResponse())
+			return
+		}
+
+		verifySignature := c.PostForm("verify_signature") == "true"
+
+		difyPkgFile, err := difyPkgFileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusOK, exception.BadRequestError(err).ToResponse())
+			return
+		}
+		defer difyPkgFile.Close()
+
+		c.JSON(http.StatusOK, service.DryRunPluginInstall(app, tenantId, difyPkgFile, verifySignature))
+	}
+}
+
+func LintPlugin(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		difyPkgFileHeader, err := c.FormFile("dify_pkg")
+		if err != nil {
+			c.JSON(http.StatusOK, exception.BadRequestError(err).ToResponse())
+			return
+		}
+
+		if difyPkgFileHeader.Size > app.MaxPluginPackageSize {
+			c.JSON(http.StatusOK, exception.BadRequestError(errors.New("file size exceeds the maximum limit")).ToResponse())
+			return
+		}
+
+		difyPkgFile, err := difyPkgFileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusOK, exception.BadRequestError(err).ToResponse())
+			return
+		}
+		defer difyPkgFile.Close()
+
+		c.JSON(http.StatusOK, service.LintPluginPkg(difyPkgFile))
+	}
+}
+
 func UploadBundle(app *app.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		difyBundleFileHeader, err := c.FormFile("dify_bundle")
@@ -110,6 +166,58 @@ func UpgradePlugin(app *app.Config) gin.HandlerFunc {
 	}
 }
 
+func SchedulePluginUpgrade(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID                       string                                 `uri:"tenant_id" validate:"required"`
+		OriginalPluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"original_plugin_unique_identifier" validate:"required,plugin_unique_identifier"`
+		NewPluginUniqueIdentifier      plugin_entities.PluginUniqueIdentifier `json:"new_plugin_unique_identifier" validate:"required,plugin_unique_identifier"`
+		Source                         string                                 `json:"source" validate:"required"`
+		Meta                           map[string]any                         `json:"meta" validate:"omitempty"`
+		Schedule                       string                                 `json:"schedule" validate:"required,max=255"`
+	}) {
+		c.JSON(http.StatusOK, service.SchedulePluginUpgrade(
+			request.TenantID,
+			request.Source,
+			request.Meta,
+			request.OriginalPluginUniqueIdentifier,
+			request.NewPluginUniqueIdentifier,
+			request.Schedule,
+		))
+	})
+}
+
+func ListScheduledPluginUpgrades(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.ListScheduledPluginUpgrades(request.TenantID))
+	})
+}
+
+func CancelScheduledPluginUpgrade(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+		ID       string `uri:"id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.CancelScheduledPluginUpgrade(request.TenantID, request.ID))
+	})
+}
+
+func DownloadPluginFromMarketplace(app *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			TenantID               string                                 `uri:"tenant_id" validate:"required"`
+			PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier" validate:"required,plugin_unique_identifier"`
+			Checksum               string                                 `json:"checksum" validate:"omitempty,len=64,hexadecimal"`
+			VerifySignature        bool                                   `json:"verify_signature"`
+		}) {
+			c.JSON(http.StatusOK, service.DownloadPluginPkgFromMarketplace(
+				app, c, request.TenantID, request.PluginUniqueIdentifier, request.Checksum, request.VerifySignature,
+			))
+		})
+	}
+}
+
 func InstallPluginFromIdentifiers(app *app.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		BindRequest(c, func(request struct {
@@ -170,6 +278,17 @@ func FetchPluginInstallationTasks(c *gin.Context) {
 	})
 }
 
+func FetchPluginInstallQueue(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+		Status   string `form:"status" validate:"omitempty,oneof=pending running success failed"`
+		Page     int    `form:"page" validate:"required,min=1"`
+		PageSize int    `form:"page_size" validate:"required,min=1,max=256"`
+	}) {
+		c.JSON(http.StatusOK, service.FetchPluginInstallQueue(request.TenantID, request.Status, request.Page, request.PageSize))
+	})
+}
+
 func FetchPluginInstallationTask(c *gin.Context) {
 	BindRequest(c, func(request struct {
 		TenantID string `uri:"tenant_id" validate:"required"`
@@ -222,12 +341,31 @@ func FetchPluginManifest(c *gin.Context) {
 	})
 }
 
+func FetchPluginSecurityReport(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID               string                                 `uri:"tenant_id" validate:"required"`
+		PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `form:"plugin_unique_identifier" validate:"required,plugin_unique_identifier"`
+	}) {
+		c.JSON(http.StatusOK, service.FetchPluginSecurityReport(request.PluginUniqueIdentifier))
+	})
+}
+
 func UninstallPlugin(c *gin.Context) {
 	BindRequest(c, func(request struct {
 		TenantID             string `uri:"tenant_id" validate:"required"`
 		PluginInstallationID string `json:"plugin_installation_id" validate:"required"`
+		Force                bool   `json:"force"`
 	}) {
-		c.JSON(http.StatusOK, service.UninstallPlugin(request.TenantID, request.PluginInstallationID))
+		c.JSON(http.StatusOK, service.UninstallPlugin(request.TenantID, request.PluginInstallationID, request.Force))
+	})
+}
+
+func UninstallPluginPreflight(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID             string `uri:"tenant_id" validate:"required"`
+		PluginInstallationID string `form:"plugin_installation_id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.UninstallPluginPreflight(request.TenantID, request.PluginInstallationID))
 	})
 }
 
@@ -266,3 +404,31 @@ func FetchMissingPluginInstallations(c *gin.Context) {
 		c.JSON(http.StatusOK, service.FetchMissingPluginInstallations(request.TenantID, request.PluginUniqueIdentifiers))
 	})
 }
+
+func FetchPluginPermissionConsent(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+		PluginID string `form:"plugin_id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.FetchPluginPermissionConsent(request.TenantID, request.PluginID))
+	})
+}
+
+func FetchPluginDependencyGraph(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+		PluginID string `form:"plugin_id"`
+	}) {
+		c.JSON(http.StatusOK, service.FetchPluginDependencyGraph(request.TenantID, request.PluginID))
+	})
+}
+
+func ConsentPluginPermissions(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID    string   `uri:"tenant_id" validate:"required"`
+		PluginID    string   `json:"plugin_id" validate:"required"`
+		Permissions []string `json:"permissions" validate:"required,min=1,dive,required"`
+	}) {
+		c.JSON(http.StatusOK, service.ConsentPluginPermissions(request.TenantID, request.PluginID, request.Permissions))
+	})
+}
@@ -3,13 +3,26 @@ package controllers
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
 )
 
 func GetRemoteDebuggingKey(c *gin.Context) {
 	BindRequest(
 		c, func(request requests.RequestGetRemoteDebuggingKey) {
-			c.JSON(200, service.GetRemoteDebuggingKey(request.TenantID))
+			c.JSON(200, service.GetRemoteDebuggingKey(request.TenantID, request.Namespace))
 		},
 	)
 }
+
+func ListDebuggingConnections(c *gin.Context) {
+	c.JSON(200, service.ListDebuggingConnections())
+}
+
+func TestInvokePlugin(config *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request requests.RequestTestInvokePlugin) {
+			service.TestInvokePlugin(c, config, request)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+)
+
+func FetchSessionStatus(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID  string `uri:"tenant_id" validate:"required"`
+		SessionID string `uri:"session_id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.FetchSessionStatus(request.TenantID, request.SessionID))
+	})
+}
+
+func CancelSession(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID  string `uri:"tenant_id" validate:"required"`
+		SessionID string `uri:"session_id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.CancelSession(request.TenantID, request.SessionID))
+	})
+}
@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+)
+
+func FetchTenantSettings(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.FetchTenantSettings(request.TenantID))
+	})
+}
+
+func SetTenantTimezone(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+		Timezone string `json:"timezone" validate:"max=64"`
+	}) {
+		c.JSON(http.StatusOK, service.SetTenantTimezone(request.TenantID, request.Timezone))
+	})
+}
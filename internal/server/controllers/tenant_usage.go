@@ -0,0 +1,19 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+)
+
+func FetchTenantUsage(config *app.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		BindRequest(c, func(request struct {
+			TenantID string `uri:"tenant_id" validate:"required"`
+		}) {
+			c.JSON(http.StatusOK, service.FetchTenantUsage(config, request.TenantID))
+		})
+	}
+}
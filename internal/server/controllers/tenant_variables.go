@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+)
+
+func ListTenantVariables(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.ListTenantVariables(request.TenantID))
+	})
+}
+
+func SetTenantVariable(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+		Name     string `json:"name" validate:"required,max=127"`
+		Value    string `json:"value"`
+		Secret   bool   `json:"secret"`
+	}) {
+		c.JSON(http.StatusOK, service.SetTenantVariable(request.TenantID, request.Name, request.Value, request.Secret))
+	})
+}
+
+func DeleteTenantVariable(c *gin.Context) {
+	BindRequest(c, func(request struct {
+		TenantID string `uri:"tenant_id" validate:"required"`
+		Name     string `json:"name" validate:"required"`
+	}) {
+		c.JSON(http.StatusOK, service.DeleteTenantVariable(request.TenantID, request.Name))
+	})
+}
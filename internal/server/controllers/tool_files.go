@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/tool_files"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+)
+
+func UploadToolFile(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusOK, exception.BadRequestError(err).ToResponse())
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusOK, exception.BadRequestError(err).ToResponse())
+		return
+	}
+	defer file.Close()
+
+	c.JSON(http.StatusOK, service.UploadToolFile(
+		c.Param("tenant_id"),
+		fileHeader.Filename,
+		fileHeader.Header.Get("Content-Type"),
+		file,
+	))
+}
+
+// DownloadToolFile is reached by plugins with a signed url instead of the server key
+func DownloadToolFile(c *gin.Context) {
+	if !tool_files.Enabled() {
+		c.JSON(http.StatusNotFound, exception.NotFoundError(tool_files.ErrFileNotFound).ToResponse())
+		return
+	}
+
+	file, reader, err := tool_files.GetBroker().Open(
+		c.Param("tenant_id"),
+		c.Param("id"),
+		c.Query("expires"),
+		c.Query("signature"),
+	)
+	if errors.Is(err, tool_files.ErrInvalidSignature) {
+		c.JSON(http.StatusForbidden, exception.PermissionDeniedError(err.Error()).ToResponse())
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, exception.NotFoundError(err).ToResponse())
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Content-Type", file.MimeType)
+	c.Header("Content-Length", strconv.FormatInt(file.Size, 10))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Filename))
+	c.Status(http.StatusOK)
+	io.Copy(c.Writer, reader)
+}
@@ -0,0 +1,42 @@
+// Package dashboard serves the admin dashboard bundled into the binary, the page itself holds no
+// data, it fetches everything from the admin api with the key entered by the operator
+package dashboard
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed static
+var static embed.FS
+
+const CONTENT_SECURITY_POLICY = "default-src 'self'; frame-ancestors 'none'; base-uri 'none'; form-action 'self'"
+
+// Handler serves the dashboard mounted at prefix, e.g. /admin/dashboard
+func Handler(prefix string) gin.HandlerFunc {
+	assets, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err)
+	}
+	fileServer := http.StripPrefix(prefix, http.FileServer(http.FS(assets)))
+
+	return func(c *gin.Context) {
+		// relative urls of the assets resolve against the directory
+		if !strings.HasPrefix(c.Request.URL.Path, prefix+"/") {
+			c.Redirect(http.StatusMovedPermanently, prefix+"/")
+			return
+		}
+
+		header := c.Writer.Header()
+		header.Set("Content-Security-Policy", CONTENT_SECURITY_POLICY)
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("Referrer-Policy", "no-referrer")
+		header.Set("Cache-Control", "no-cache")
+		fileServer.ServeHTTP(c.Writer, c.Request)
+	}
+}
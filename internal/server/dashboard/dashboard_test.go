@@ -0,0 +1,37 @@
+package dashboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlerServesAssetsBesideAdminApi(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Group("/admin").GET("/stats", func(c *gin.Context) { c.String(http.StatusOK, "stats") })
+	engine.GET("/admin/dashboard/*filepath", Handler("/admin/dashboard"))
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		return recorder
+	}
+
+	index := serve("/admin/dashboard/")
+	assert.Equal(t, http.StatusOK, index.Code)
+	assert.Contains(t, index.Body.String(), `<script src="app.js"></script>`)
+	assert.Equal(t, CONTENT_SECURITY_POLICY, index.Header().Get("Content-Security-Policy"))
+
+	script := serve("/admin/dashboard/app.js")
+	assert.Equal(t, http.StatusOK, script.Code)
+	assert.True(t, strings.Contains(script.Header().Get("Content-Type"), "javascript"))
+
+	assert.Equal(t, http.StatusMovedPermanently, serve("/admin/dashboard").Code)
+	assert.Equal(t, http.StatusNotFound, serve("/admin/dashboard/missing.js").Code)
+	assert.Equal(t, "stats", serve("/admin/stats").Body.String())
+}
@@ -0,0 +1,409 @@
+"use strict";
+
+// the dashboard is served under the admin api, e.g. /admin/dashboard/, every request carries the key
+// entered by the operator, it's kept for the browser session only
+const API_BASE = location.pathname.replace(/\/dashboard(\/.*)?$/, "");
+// apis of tenants are served next to the admin api
+const TENANT_API_BASE = API_BASE.replace(/\/admin$/, "");
+const KEY_STORAGE = "dify-plugin-daemon-admin-key";
+const REFRESH_INTERVAL = 5000;
+const PAGE_SIZE = 50;
+
+const state = {
+  view: "overview",
+  pages: { plugins: 1, tasks: 1 },
+  totals: { plugins: 0, tasks: 0 },
+  timer: null,
+  logAbort: null,
+};
+
+const $ = (selector) => document.querySelector(selector);
+const $$ = (selector) => Array.from(document.querySelectorAll(selector));
+
+class UnauthorizedError extends Error {}
+
+function apiKey() {
+  return sessionStorage.getItem(KEY_STORAGE);
+}
+
+async function request(path, params, signal, base = API_BASE) {
+  const url = new URL(base + path, location.origin);
+  for (const [key, value] of Object.entries(params || {})) {
+    if (value !== "" && value !== undefined && value !== null) {
+      url.searchParams.set(key, value);
+    }
+  }
+
+  const response = await fetch(url, {
+    headers: { "X-Admin-Api-Key": apiKey() || "" },
+    signal,
+  });
+  if (response.status === 401) {
+    throw new UnauthorizedError("unauthorized");
+  }
+  return response;
+}
+
+async function api(path, params, base = API_BASE) {
+  const response = await request(path, params, undefined, base);
+  const body = await response.json();
+  if (body.code !== 0) {
+    throw new Error(body.message || `request to ${path} failed with status ${response.status}`);
+  }
+  return body.data;
+}
+
+// el builds elements from text only, values from plugins and tenants are never parsed as html
+function el(tag, attributes, ...children) {
+  const element = document.createElement(tag);
+  for (const [key, value] of Object.entries(attributes || {})) {
+    if (key === "class") {
+      element.className = value;
+    } else {
+      element.setAttribute(key, value);
+    }
+  }
+  for (const child of children.flat()) {
+    if (child === null || child === undefined) {
+      continue;
+    }
+    element.append(child instanceof Node ? child : String(child));
+  }
+  return element;
+}
+
+function formatTime(value) {
+  if (!value) {
+    return "-";
+  }
+  const date = typeof value === "number" ? new Date(value * 1000) : new Date(value);
+  if (isNaN(date.getTime()) || date.getFullYear() < 2000) {
+    return "-";
+  }
+  return date.toLocaleString();
+}
+
+function status(value) {
+  return el("span", { class: `status-${value}` }, value || "-");
+}
+
+// progressBar sets the width through the style object, inline style attributes are refused by the policy
+function progressBar(ratio) {
+  const bar = el("div");
+  bar.style.width = `${Math.round(ratio * 100)}%`;
+  return bar;
+}
+
+function renderTable(table, columns, rows) {
+  table.replaceChildren(
+    el("thead", null, el("tr", null, columns.map((column) => el("th", null, column.title)))),
+    el(
+      "tbody",
+      null,
+      rows.length === 0
+        ? el("tr", null, el("td", { class: "empty", colspan: columns.length }, "Nothing to show"))
+        : rows.map((row) => el("tr", null, columns.map((column) => el("td", null, column.render(row))))),
+    ),
+  );
+}
+
+function renderPager(name) {
+  const pager = $(`[data-pager="${name}"]`);
+  const pages = Math.max(1, Math.ceil(state.totals[name] / PAGE_SIZE));
+  pager.querySelector("span").textContent = `Page ${state.pages[name]} of ${pages}`;
+  pager.querySelector('[data-page="-1"]').disabled = state.pages[name] <= 1;
+  pager.querySelector('[data-page="1"]').disabled = state.pages[name] >= pages;
+}
+
+function formParams(form) {
+  return Object.fromEntries(new FormData(form).entries());
+}
+
+async function loadOverview() {
+  const [nodes, stats] = await Promise.all([api("/cluster/nodes"), api("/stats")]);
+
+  renderTable($("#nodes"), [
+    { title: "Node", render: (node) => node.id + (node.current ? " (this node)" : "") },
+    { title: "Role", render: (node) => (node.master ? "master" : "worker") },
+    { title: "Addresses", render: (node) => node.addresses.join(", ") },
+    { title: "Last ping", render: (node) => formatTime(node.last_ping_at) },
+  ], nodes);
+
+  const runtimes = stats.runtimes || [];
+  const summary = [
+    ["Runtimes", runtimes.length],
+    ["Active runtimes", runtimes.filter((runtime) => runtime.status === "active").length],
+    ["Sessions", stats.sessions],
+    ["Active requests", stats.active_requests],
+    ["Active dispatch requests", stats.active_dispatch_requests],
+    ["Install queue", `${stats.install_queue.running} running, ${stats.install_queue.waiting} waiting`],
+  ];
+  if (stats.pool) {
+    summary.push(["Routine pool", `${stats.pool.busy} busy of ${stats.pool.total}`]);
+  }
+  $("#node-summary").replaceChildren(...summary.flatMap(([term, value]) => [el("dt", null, term), el("dd", null, value)]));
+}
+
+async function loadPlugins() {
+  const params = formParams($("#plugin-filters"));
+  const data = await api("/plugins", { ...params, page: state.pages.plugins, page_size: PAGE_SIZE });
+  state.totals.plugins = data.total;
+
+  renderTable($("#plugins"), [
+    { title: "Plugin", render: (plugin) => [el("div", null, plugin.plugin_id), el("small", null, plugin.version)] },
+    { title: "Tenant", render: (plugin) => plugin.tenant_id },
+    { title: "Category", render: (plugin) => plugin.category || "-" },
+    { title: "Model types", render: (plugin) => plugin.model_types.join(", ") || "-" },
+    { title: "Runtime", render: (plugin) => plugin.runtime_type },
+    { title: "Health", render: (plugin) => status(plugin.health.status) },
+    { title: "Restarts", render: (plugin) => (plugin.health.runtime ? plugin.health.runtime.restarts : "-") },
+    { title: "Installed", render: (plugin) => formatTime(plugin.created_at) },
+    { title: "Last invoked", render: (plugin) => formatTime(plugin.last_invoked_at) },
+  ], data.list);
+  renderPager("plugins");
+}
+
+async function loadRuntimes() {
+  const stats = await api("/stats");
+  renderTable($("#runtimes"), [
+    { title: "Plugin", render: (runtime) => runtime.plugin_unique_identifier },
+    { title: "Type", render: (runtime) => runtime.type },
+    { title: "Status", render: (runtime) => status(runtime.status) },
+    { title: "Restarts", render: (runtime) => runtime.restarts },
+    { title: "Next restart", render: (runtime) => formatTime(runtime.next_restart_at) },
+    { title: "Hangs", render: (runtime) => runtime.hangs },
+    { title: "Last pong", render: (runtime) => formatTime(runtime.last_pong_at) },
+    { title: "Sessions", render: (runtime) => runtime.sessions },
+    { title: "Trust tier", render: (runtime) => runtime.trust_tier || "-" },
+    { title: "SDK", render: (runtime) => runtime.sdk_version || "-" },
+  ], stats.runtimes || []);
+}
+
+async function loadMetrics() {
+  const stats = await api("/stats");
+  $("#rate-window").textContent = stats.rate_window_seconds;
+
+  const cache = Object.fromEntries((stats.invocation_cache || []).map((entry) => [entry.plugin_id, entry]));
+  const rates = (stats.invocations || []).slice().sort((a, b) => b.invocations - a.invocations);
+  renderTable($("#metrics"), [
+    { title: "Plugin", render: (rate) => rate.plugin_id },
+    { title: "Invocations", render: (rate) => rate.invocations },
+    { title: "Errors", render: (rate) => rate.errors },
+    { title: "Error rate", render: (rate) => `${(rate.error_rate * 100).toFixed(1)}%` },
+    { title: "Total invocations", render: (rate) => rate.total_invocations },
+    { title: "Total errors", render: (rate) => rate.total_errors },
+    { title: "Cache hits", render: (rate) => (cache[rate.plugin_id] ? cache[rate.plugin_id].hits : "-") },
+  ], rates);
+}
+
+async function loadTasks() {
+  const { tenant_id: tenantID, ...params } = formParams($("#task-filters"));
+  // install tasks are listed per tenant
+  const data = tenantID
+    ? await api(
+      `/plugin/${encodeURIComponent(tenantID)}/management/install/queue`,
+      { ...params, page: state.pages.tasks, page_size: PAGE_SIZE },
+      TENANT_API_BASE,
+    )
+    : { tasks: [] };
+  const tasks = data.tasks || [];
+  // the api doesn't count tasks, another page is offered as long as this one is full
+  state.totals.tasks = (state.pages.tasks - 1) * PAGE_SIZE + tasks.length + (tasks.length === PAGE_SIZE ? 1 : 0);
+
+  renderTable($("#tasks"), [
+    { title: "Task", render: (task) => task.id },
+    { title: "Tenant", render: (task) => task.tenant_id },
+    { title: "Status", render: (task) => status(task.status) },
+    {
+      title: "Progress",
+      render: (task) => [
+        el("div", { class: "progress" }, progressBar(task.total_plugins ? task.completed_plugins / task.total_plugins : 0)),
+        el("small", null, `${task.completed_plugins} of ${task.total_plugins}`),
+      ],
+    },
+    {
+      title: "Plugins",
+      render: (task) => (task.plugins || []).map((plugin) => el(
+        "div",
+        null,
+        status(plugin.status),
+        ` ${plugin.plugin_id}`,
+        plugin.message ? el("small", null, ` ${plugin.message}`) : null,
+      )),
+    },
+    { title: "Created", render: (task) => formatTime(task.created_at) },
+  ], tasks);
+  renderPager("tasks");
+}
+
+async function loadLogTargets() {
+  const select = $("#log-form select");
+  const selected = select.value;
+  const stats = await api("/stats");
+  const identifiers = (stats.runtimes || [])
+    .filter((runtime) => runtime.type === "local")
+    .map((runtime) => runtime.plugin_unique_identifier);
+  select.replaceChildren(...identifiers.map((identifier) => el("option", null, identifier)));
+  if (identifiers.includes(selected)) {
+    select.value = selected;
+  }
+}
+
+function appendLog(line) {
+  const output = $("#log-output");
+  const follow = output.scrollTop + output.clientHeight >= output.scrollHeight - 4;
+  output.append(el("span", { class: line.stream }, `${formatTime(line.time)} ${line.message}\n`));
+  if (follow) {
+    output.scrollTop = output.scrollHeight;
+  }
+}
+
+function stopTail() {
+  if (state.logAbort) {
+    state.logAbort.abort();
+    state.logAbort = null;
+  }
+}
+
+// EventSource can't send the key, the stream is read with fetch and split into events by hand
+async function tailLogs(params) {
+  stopTail();
+  const abort = new AbortController();
+  state.logAbort = abort;
+  $("#log-output").replaceChildren();
+
+  try {
+    const response = await request("/plugin/logs/tail", params, abort.signal);
+    const reader = response.body.pipeThrough(new TextDecoderStream()).getReader();
+    let buffer = "";
+    for (;;) {
+      const { value, done } = await reader.read();
+      if (done) {
+        break;
+      }
+      buffer += value;
+      let end;
+      while ((end = buffer.indexOf("\n\n")) >= 0) {
+        const event = buffer.slice(0, end);
+        buffer = buffer.slice(end + 2);
+        const data = event.split("\n").filter((line) => line.startsWith("data: ")).map((line) => line.slice(6)).join("");
+        if (!data) {
+          continue;
+        }
+        const message = JSON.parse(data);
+        if (message.code !== 0) {
+          appendLog({ stream: "stderr", message: message.message });
+        } else {
+          appendLog(message.data);
+        }
+      }
+    }
+    appendLog({ stream: "stdout", message: "-- stream ended --" });
+  } catch (error) {
+    if (!abort.signal.aborted) {
+      handleError(error);
+    }
+  } finally {
+    if (state.logAbort === abort) {
+      state.logAbort = null;
+    }
+  }
+}
+
+const loaders = {
+  overview: loadOverview,
+  plugins: loadPlugins,
+  runtimes: loadRuntimes,
+  metrics: loadMetrics,
+  tasks: loadTasks,
+  logs: loadLogTargets,
+};
+
+function handleError(error) {
+  if (error instanceof UnauthorizedError) {
+    signOut("The admin API key was rejected.");
+    return;
+  }
+  $("#error").textContent = error.message;
+  $("#error").hidden = false;
+}
+
+async function refresh() {
+  clearTimeout(state.timer);
+  try {
+    await loaders[state.view]();
+    $("#error").hidden = true;
+    $("#updated-at").textContent = `Updated ${new Date().toLocaleTimeString()}`;
+  } catch (error) {
+    handleError(error);
+  }
+  // logs are streamed, the list of runtimes to tail is only loaded when the view opens
+  if (apiKey() && state.view !== "logs") {
+    state.timer = setTimeout(refresh, REFRESH_INTERVAL);
+  }
+}
+
+function show(view) {
+  state.view = view;
+  $$("nav button").forEach((button) => button.classList.toggle("active", button.dataset.view === view));
+  $$("main > [data-view]").forEach((section) => {
+    section.hidden = section.dataset.view !== view;
+  });
+  if (view !== "logs") {
+    stopTail();
+  }
+  refresh();
+}
+
+function signOut(message) {
+  clearTimeout(state.timer);
+  stopTail();
+  sessionStorage.removeItem(KEY_STORAGE);
+  $("#dashboard").hidden = true;
+  $("#login").hidden = false;
+  $("#login-error").textContent = message || "";
+  $("#login-error").hidden = !message;
+  $("#api-key").value = "";
+  $("#api-key").focus();
+}
+
+function signIn() {
+  $("#login").hidden = true;
+  $("#dashboard").hidden = false;
+  show(state.view);
+}
+
+$("#login-form").addEventListener("submit", (event) => {
+  event.preventDefault();
+  sessionStorage.setItem(KEY_STORAGE, $("#api-key").value);
+  signIn();
+});
+
+$("#logout").addEventListener("click", () => signOut());
+
+$$("nav button").forEach((button) => button.addEventListener("click", () => show(button.dataset.view)));
+
+for (const name of ["plugins", "tasks"]) {
+  $(`#${name === "plugins" ? "plugin" : "task"}-filters`).addEventListener("submit", (event) => {
+    event.preventDefault();
+    state.pages[name] = 1;
+    refresh();
+  });
+  $$(`[data-pager="${name}"] button`).forEach((button) => button.addEventListener("click", () => {
+    state.pages[name] = Math.max(1, state.pages[name] + Number(button.dataset.page));
+    refresh();
+  }));
+}
+
+$("#log-form").addEventListener("submit", (event) => {
+  event.preventDefault();
+  tailLogs(formParams(event.target));
+});
+
+$("#log-stop").addEventListener("click", stopTail);
+
+if (apiKey()) {
+  signIn();
+} else {
+  signOut();
+}
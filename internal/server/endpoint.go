@@ -1,10 +1,14 @@
 package server
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
+	"github.com/langgenius/dify-plugin-daemon/internal/core/endpoint_domains"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
 
@@ -98,6 +102,13 @@ func (app *App) EndpointHandler(ctx *gin.Context, hookId string, maxExecutionTim
 		return
 	}
 
+	// the endpoint runs on the version a rollout picked, the cached installation is left untouched
+	if routed := routePluginInvocation(ctx.Request, endpoint.TenantID, pluginUniqueIdentifier); routed != pluginUniqueIdentifier {
+		installation := *pluginInstallation
+		installation.PluginUniqueIdentifier = routed.String()
+		pluginInstallation, pluginUniqueIdentifier = &installation, routed
+	}
+
 	// check if plugin exists in current node
 	if ok, originalError := app.cluster.IsPluginOnCurrentNode(pluginUniqueIdentifier); !ok {
 		app.redirectPluginInvokeByPluginIdentifier(ctx, pluginUniqueIdentifier, originalError)
@@ -105,3 +116,32 @@ func (app *App) EndpointHandler(ctx *gin.Context, hookId string, maxExecutionTim
 		service.Endpoint(ctx, endpoint, pluginInstallation, maxExecutionTime, path)
 	}
 }
+
+// routeEndpointDomains rewrites requests on custom domains of endpoints to the hook id based url before
+// gin matches routes, so they go through the same handlers and redirects between nodes
+func routeEndpointDomains(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		domain, path, err := endpoint_domains.Resolve(r.Host, r.URL.Path)
+		if err != nil {
+			log.Error("resolve endpoint domain %s error %v", r.Host, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(exception.InternalServerError(errors.New("internal server error")).ToResponse())
+			return
+		}
+		if domain == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		// the plugin is told the url the request was sent to instead of the hook id based one
+		r.Header.Set("Dify-Hook-Url", fmt.Sprintf("%s://%s%s", scheme, r.Host, r.URL.Path))
+		r.URL.Path = "/e/" + domain.HookID + path
+		r.URL.RawPath = ""
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"net/http"
 	"strconv"
 	"testing"
@@ -35,7 +36,7 @@ func TestEndpointParams(t *testing.T) {
 		PluginEndpointEnabled: parser.ToPtr(true),
 		HealthApiLogEnabled:   parser.ToPtr(true),
 	})
-	defer cancel()
+	defer cancel(context.Background())
 
 	// test endpoint params
 	client := &http.Client{}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/access_control"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/proto/dispatch"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// InvokeEndpoint sends the request through the same handlers as /e/{hook_id}/*, the response of the
+// endpoint is returned as it is, whatever its status
+func (s *grpcDispatchServer) InvokeEndpoint(
+	request *dispatch.EndpointRequest,
+	srv grpc.ServerStreamingServer[dispatch.EndpointResponse],
+) error {
+	if s.config.PluginEndpointEnabled == nil || !*s.config.PluginEndpointEnabled || s.app.endpoints == nil {
+		return status.Error(codes.Unimplemented, "endpoints are disabled")
+	}
+	if request.HookId == "" {
+		return status.Error(codes.InvalidArgument, "hook_id is required")
+	}
+
+	endpoint, err := db.GetOne[models.Endpoint](db.Equal("hook_id", request.HookId))
+	if err == db.ErrDatabaseNotFound {
+		return status.Error(codes.NotFound, "endpoint not found")
+	} else if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	principal, ok := srv.Context().Value(grpcPrincipalKey{}).(*access_control.Principal)
+	if ok && (!principal.CanAccessTenant(endpoint.TenantID) || !principal.CanAccessOrganization("")) {
+		return status.Error(codes.PermissionDenied, "tenant is not accessible")
+	}
+
+	method := request.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	path := request.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	req, err := http.NewRequestWithContext(srv.Context(), method, "/e/"+request.HookId+path, bytes.NewReader(request.Body))
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	for key, value := range request.Headers {
+		req.Header.Set(key, value)
+	}
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+	}
+	// ip filters of endpoints see the caller of the grpc api
+	if p, ok := peer.FromContext(srv.Context()); ok {
+		req.RemoteAddr = p.Addr.String()
+	}
+
+	writer := &grpcEndpointWriter{srv: srv, header: http.Header{}}
+	s.app.endpoints.ServeHTTP(writer, req)
+	return writer.finish()
+}
+
+// grpcEndpointWriter streams an http response, the status and headers are sent with the first
+// flush or write and every write is sent as a chunk of the body
+type grpcEndpointWriter struct {
+	srv    grpc.ServerStreamingServer[dispatch.EndpointResponse]
+	header http.Header
+	status int
+	sent   bool
+	err    error
+}
+
+func (w *grpcEndpointWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *grpcEndpointWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+func (w *grpcEndpointWriter) Write(body []byte) (int, error) {
+	if err := w.sendHeader(); err != nil {
+		return 0, err
+	}
+	if len(body) == 0 {
+		return 0, nil
+	}
+	if err := w.srv.Send(&dispatch.EndpointResponse{Body: body}); err != nil {
+		w.err = err
+		return 0, err
+	}
+	return len(body), nil
+}
+
+func (w *grpcEndpointWriter) Flush() {
+	w.sendHeader()
+}
+
+// CloseNotify is required by gin, the caller is gone once the grpc stream is done
+func (w *grpcEndpointWriter) CloseNotify() <-chan bool {
+	closed := make(chan bool, 1)
+	go func() {
+		<-w.srv.Context().Done()
+		closed <- true
+	}()
+	return closed
+}
+
+func (w *grpcEndpointWriter) sendHeader() error {
+	if w.err != nil || w.sent {
+		return w.err
+	}
+	w.sent = true
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	headers := map[string]string{}
+	for key, values := range w.header {
+		headers[key] = strings.Join(values, ", ")
+	}
+
+	w.err = w.srv.Send(&dispatch.EndpointResponse{Status: int32(w.status), Headers: headers})
+	return w.err
+}
+
+// finish sends the status and headers of responses without a body
+func (w *grpcEndpointWriter) finish() error {
+	if err := w.sendHeader(); err != nil {
+		return err
+	}
+	if err := w.srv.Context().Err(); err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return nil
+}
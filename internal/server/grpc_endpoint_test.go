@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/access_control"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/pkg/proto/dispatch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type grpcEndpointTestStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent []*dispatch.EndpointResponse
+}
+
+func (s *grpcEndpointTestStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *grpcEndpointTestStream) Send(response *dispatch.EndpointResponse) error {
+	s.sent = append(s.sent, response)
+	return nil
+}
+
+func TestGrpcInvokeEndpoint(t *testing.T) {
+	config := &app.Config{
+		DBType:                "sqlite",
+		DBSqlitePath:          filepath.Join(t.TempDir(), "grpc_endpoint.db"),
+		PluginEndpointEnabled: parser.ToPtr(true),
+	}
+	config.SetDefault()
+	db.Init(config)
+	t.Cleanup(db.Close)
+	require.NoError(t, db.Create(&models.Endpoint{HookID: "hook", TenantID: "tenant", PluginID: "acme/hook"}))
+
+	// the endpoint echoes the request in two chunks
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/e/:hook_id/*path", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Header("X-Path", c.Param("path")+"?"+c.Request.URL.RawQuery)
+		c.Header("X-Host", c.Request.Host)
+		c.Status(http.StatusCreated)
+		c.Writer.Flush()
+		c.Writer.Write([]byte(c.GetHeader("X-Signature") + ":"))
+		c.Writer.Write(body)
+	})
+	server := &grpcDispatchServer{app: &App{endpoints: engine}, config: config}
+
+	invoke := func(ctx context.Context, request *dispatch.EndpointRequest) (*grpcEndpointTestStream, error) {
+		srv := &grpcEndpointTestStream{ctx: ctx}
+		return srv, server.InvokeEndpoint(request, srv)
+	}
+
+	srv, err := invoke(context.Background(), &dispatch.EndpointRequest{
+		HookId:  "hook",
+		Path:    "webhook?event=push",
+		Headers: map[string]string{"X-Signature": "sha256=abc", "Host": "hooks.example.com"},
+		Body:    []byte("payload"),
+	})
+	require.NoError(t, err)
+	require.Len(t, srv.sent, 3)
+	assert.Equal(t, int32(http.StatusCreated), srv.sent[0].Status)
+	assert.Equal(t, "/webhook?event=push", srv.sent[0].Headers["X-Path"])
+	assert.Equal(t, "hooks.example.com", srv.sent[0].Headers["X-Host"])
+	assert.Equal(t, "sha256=abc:", string(srv.sent[1].Body))
+	assert.Equal(t, "payload", string(srv.sent[2].Body))
+
+	// the status of routes the endpoint doesn't serve is returned as it is
+	srv, err = invoke(context.Background(), &dispatch.EndpointRequest{HookId: "hook", Method: http.MethodGet, Path: "/"})
+	require.NoError(t, err)
+	require.NotEmpty(t, srv.sent)
+	assert.Equal(t, int32(http.StatusNotFound), srv.sent[0].Status)
+
+	_, err = invoke(context.Background(), &dispatch.EndpointRequest{HookId: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	ctx := context.WithValue(context.Background(), grpcPrincipalKey{}, &access_control.Principal{TenantID: "other"})
+	_, err = invoke(ctx, &dispatch.EndpointRequest{HookId: "hook"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	config.PluginEndpointEnabled = parser.ToPtr(false)
+	_, err = invoke(context.Background(), &dispatch.EndpointRequest{HookId: "hook"})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
@@ -0,0 +1,294 @@
+// Code generated by controller generator. DO NOT EDIT.
+package server
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/pkg/proto/dispatch"
+	"google.golang.org/grpc"
+)
+
+func (s *grpcDispatchServer) InvokeTool(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		access_types.PLUGIN_ACCESS_TYPE_TOOL,
+		access_types.PLUGIN_ACCESS_ACTION_INVOKE_TOOL,
+		"/tool/invoke",
+		plugin_daemon.InvokeTool,
+	)
+}
+
+func (s *grpcDispatchServer) ValidateToolCredentials(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		access_types.PLUGIN_ACCESS_TYPE_TOOL,
+		access_types.PLUGIN_ACCESS_ACTION_VALIDATE_TOOL_CREDENTIALS,
+		"/tool/validate_credentials",
+		plugin_daemon.ValidateToolCredentials,
+	)
+}
+
+func (s *grpcDispatchServer) GetToolRuntimeParameters(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		access_types.PLUGIN_ACCESS_TYPE_TOOL,
+		access_types.PLUGIN_ACCESS_ACTION_GET_TOOL_RUNTIME_PARAMETERS,
+		"/tool/get_runtime_parameters",
+		plugin_daemon.GetToolRuntimeParameters,
+	)
+}
+
+func (s *grpcDispatchServer) InvokeLLM(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		access_types.PLUGIN_ACCESS_TYPE_MODEL,
+		access_types.PLUGIN_ACCESS_ACTION_INVOKE_LLM,
+		"/llm/invoke",
+		plugin_daemon.InvokeLLM,
+	)
+}
+
+func (s *grpcDispatchServer) GetLLMNumTokens(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		access_types.PLUGIN_ACCESS_TYPE_MODEL,
+		access_types.PLUGIN_ACCESS_ACTION_GET_LLM_NUM_TOKENS,
+		"/llm/num_tokens",
+		plugin_daemon.GetLLMNumTokens,
+	)
+}
+
+func (s *grpcDispatchServer) InvokeTextEmbedding(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		access_types.PLUGIN_ACCESS_TYPE_MODEL,
+		access_types.PLUGIN_ACCESS_ACTION_INVOKE_TEXT_EMBEDDING,
+		"/text_embedding/invoke",
+		plugin_daemon.InvokeTextEmbedding,
+	)
+}
+
+func (s *grpcDispatchServer) GetTextEmbeddingNumTokens(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		access_types.PLUGIN_ACCESS_TYPE_MODEL,
+		access_types.PLUGIN_ACCESS_ACTION_GET_TEXT_EMBEDDING_NUM_TOKENS,
+		"/text_embedding/num_tokens",
+		plugin_daemon.GetTextEmbeddingNumTokens,
+	)
+}
+
+func (s *grpcDispatchServer) InvokeRerank(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		access_types.PLUGIN_ACCESS_TYPE_MODEL,
+		access_types.PLUGIN_ACCESS_ACTION_INVOKE_RERANK,
+		"/rerank/invoke",
+		plugin_daemon.InvokeRerank,
+	)
+}
+
+func (s *grpcDispatchServer) InvokeTTS(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		access_types.PLUGIN_ACCESS_TYPE_MODEL,
+		access_types.PLUGIN_ACCESS_ACTION_INVOKE_TTS,
+		"/tts/invoke",
+		plugin_daemon.InvokeTTS,
+	)
+}
+
+func (s *grpcDispatchServer) GetTTSModelVoices(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		access_types.PLUGIN_ACCESS_TYPE_MODEL,
+		access_types.PLUGIN_ACCESS_ACTION_GET_TTS_MODEL_VOICES,
+		"/tts/model/voices",
+		plugin_daemon.GetTTSModelVoices,
+	)
+}
+
+func (s *grpcDispatchServer) InvokeSpeech2Text(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		access_types.PLUGIN_ACCESS_TYPE_MODEL,
+		access_types.PLUGIN_ACCESS_ACTION_INVOKE_SPEECH2TEXT,
+		"/speech2text/invoke",
+		plugin_daemon.InvokeSpeech2Text,
+	)
+}
+
+func (s *grpcDispatchServer) InvokeModeration(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		access_types.PLUGIN_ACCESS_TYPE_MODEL,
+		access_types.PLUGIN_ACCESS_ACTION_INVOKE_MODERATION,
+		"/moderation/invoke",
+		plugin_daemon.InvokeModeration,
+	)
+}
+
+func (s *grpcDispatchServer) ValidateProviderCredentials(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		access_types.PLUGIN_ACCESS_TYPE_MODEL,
+		access_types.PLUGIN_ACCESS_ACTION_VALIDATE_PROVIDER_CREDENTIALS,
+		"/model/validate_provider_credentials",
+		plugin_daemon.ValidateProviderCredentials,
+	)
+}
+
+func (s *grpcDispatchServer) ValidateModelCredentials(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		access_types.PLUGIN_ACCESS_TYPE_MODEL,
+		access_types.PLUGIN_ACCESS_ACTION_VALIDATE_MODEL_CREDENTIALS,
+		"/model/validate_model_credentials",
+		plugin_daemon.ValidateModelCredentials,
+	)
+}
+
+func (s *grpcDispatchServer) GetAIModelSchema(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		access_types.PLUGIN_ACCESS_TYPE_MODEL,
+		access_types.PLUGIN_ACCESS_ACTION_GET_AI_MODEL_SCHEMAS,
+		"/model/schema",
+		plugin_daemon.GetAIModelSchema,
+	)
+}
+
+func (s *grpcDispatchServer) GetAuthorizationURL(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		access_types.PLUGIN_ACCESS_TYPE_OAUTH,
+		access_types.PLUGIN_ACCESS_ACTION_GET_AUTHORIZATION_URL,
+		"/oauth/get_authorization_url",
+		plugin_daemon.GetAuthorizationURL,
+	)
+}
+
+func (s *grpcDispatchServer) GetCredentials(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		access_types.PLUGIN_ACCESS_TYPE_OAUTH,
+		access_types.PLUGIN_ACCESS_ACTION_GET_CREDENTIALS,
+		"/oauth/get_credentials",
+		plugin_daemon.GetCredentials,
+	)
+}
+
+func (s *grpcDispatchServer) RefreshCredentials(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		access_types.PLUGIN_ACCESS_TYPE_OAUTH,
+		access_types.PLUGIN_ACCESS_ACTION_REFRESH_CREDENTIALS,
+		"/oauth/refresh_credentials",
+		plugin_daemon.RefreshCredentials,
+	)
+}
+
+func (s *grpcDispatchServer) FetchDynamicParameterOptions(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		access_types.PLUGIN_ACCESS_TYPE_DYNAMIC_PARAMETER,
+		access_types.PLUGIN_ACCESS_ACTION_DYNAMIC_PARAMETER_FETCH_OPTIONS,
+		"/dynamic_select/fetch_parameter_options",
+		plugin_daemon.FetchDynamicParameterOptions,
+	)
+}
@@ -0,0 +1,455 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/access_control"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/invocation_limit"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_rollout"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/controllers"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/network"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/proto/dispatch"
+	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// grpcDispatchServer serves the same dispatch api as /plugin/{tenant_id}/dispatch/*
+// with server-streaming responses
+type grpcDispatchServer struct {
+	dispatch.UnimplementedPluginDispatchServer
+
+	app    *App
+	config *app.Config
+}
+
+// grpcServer starts a grpc server and returns a function to stop it
+func (app *App) grpcServer(config *app.Config) func(ctx context.Context) {
+	options := []grpc.ServerOption{
+		grpc.ChainStreamInterceptor(grpcAuthorize(access_control.PERMISSION_PLUGIN_INVOKE), grpcRejectWhileDraining),
+		grpc.MaxRecvMsgSize(config.GrpcMaxRecvMsgSize),
+	}
+	if app.tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(app.tlsConfig)))
+	}
+	server := grpc.NewServer(options...)
+
+	dispatch.RegisterPluginDispatchServer(server, &grpcDispatchServer{
+		app:    app,
+		config: config,
+	})
+
+	trustedProxies, err := network.ParseIPNets(config.TrustedProxies)
+	if err != nil {
+		log.Panic("invalid trusted proxies: %s", err.Error())
+	}
+	allowed, err := network.ParseIPNets(config.ServerIPAllowlist)
+	if err != nil {
+		log.Panic("invalid server ip allowlist: %s", err.Error())
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", config.GrpcPort))
+	if err != nil {
+		log.Panic("grpc listen: %s", err)
+	}
+	// there is no health check on the grpc port, connections of other ips are closed right away
+	listener = network.NewListener(listener, network.ListenerConfig{
+		ProxyProtocol:  config.ServerProxyProtocolEnabled,
+		TrustedProxies: trustedProxies,
+		AllowedIPs:     allowed,
+	})
+
+	go func() {
+		if err := server.Serve(listener); err != nil && err != grpc.ErrServerStopped {
+			log.Panic("grpc serve: %s", err)
+		}
+	}()
+
+	return func(ctx context.Context) {
+		stopped := make(chan bool)
+		go func() {
+			server.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			server.Stop()
+		}
+	}
+}
+
+type grpcPrincipalKey struct{}
+
+type grpcAuthenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcAuthenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcAuthorize is the grpc equivalent of Authenticate and Authorize, the credential is read from metadata
+// authorization or x-api-key, the tenant of requests is checked once they are received
+func grpcAuthorize(permission access_control.Permission) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md, _ := metadata.FromIncomingContext(ss.Context())
+		header := http.Header{}
+		for _, key := range []string{"Authorization", constants.X_API_KEY} {
+			if values := md.Get(strings.ToLower(key)); len(values) > 0 {
+				header.Set(key, values[0])
+			}
+		}
+
+		var state *tls.ConnectionState
+		if p, ok := peer.FromContext(ss.Context()); ok {
+			if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+				state = &info.State
+			}
+		}
+
+		principal, err := access_control.AuthenticateConnection(requestCredential(header), state)
+		if err == access_control.ErrUnauthorized {
+			return status.Error(codes.Unauthenticated, "unauthorized")
+		} else if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		if !principal.Can(permission) {
+			return status.Error(codes.PermissionDenied, fmt.Sprintf("permission %s is required", permission))
+		}
+
+		return handler(srv, &grpcAuthenticatedStream{
+			ServerStream: ss,
+			ctx:          context.WithValue(ss.Context(), grpcPrincipalKey{}, principal),
+		})
+	}
+}
+
+// grpcRejectWhileDraining is the grpc equivalent of RejectWhileDraining
+func grpcRejectWhileDraining(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if controllers.Draining() {
+		return status.Error(codes.Unavailable, "plugin daemon is shutting down")
+	}
+	return handler(srv, ss)
+}
+
+func grpcDispatch[Req any, Rsp any](
+	s *grpcDispatchServer,
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+	accessType access_types.PluginAccessType,
+	accessAction access_types.PluginAccessAction,
+	path string,
+	invoke func(*session_manager.Session, *Req) (*stream.Stream[Rsp], error),
+) error {
+	if request.PluginId == "" {
+		return status.Error(codes.InvalidArgument, "plugin_id is required")
+	}
+
+	principal, ok := srv.Context().Value(grpcPrincipalKey{}).(*access_control.Principal)
+	if ok && (!principal.CanAccessTenant(request.TenantId) || !principal.CanAccessOrganization("")) {
+		return status.Error(codes.PermissionDenied, "tenant is not accessible")
+	}
+
+	if s.app.rateLimiter != nil {
+		pluginId, _ := plugin_entities.SplitPluginVersion(request.PluginId)
+		decision, err := s.app.rateLimiter.Allow(request.TenantId, pluginId)
+		if err != nil {
+			log.Warn("failed to check rate limit: %s", err.Error())
+		} else if !decision.Allowed {
+			header := metadata.MD{}
+			for key, value := range rateLimitHeaders(decision) {
+				header.Set(strings.ToLower(key), value)
+			}
+			srv.SetHeader(header)
+			return status.Error(codes.ResourceExhausted, fmt.Sprintf("rate limit of %s exceeded", decision.Scope))
+		}
+	}
+
+	body, err := grpcRequestBody(request)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	r, err := parser.UnmarshalJsonBytes[plugin_entities.InvokePluginRequest[Req]](body)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := validators.GlobalEntitiesValidator.Struct(r); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	installation, pinned, err := resolvePluginInstallation(request.TenantId, request.PluginId)
+	if err == db.ErrDatabaseNotFound {
+		return status.Error(codes.NotFound, "plugin not found")
+	} else if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	identity, err := plugin_entities.NewPluginUniqueIdentifier(installation.PluginUniqueIdentifier)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if plugin_rollout.Enabled() && !pinned {
+		identity = plugin_rollout.Route(request.TenantId, identity, "")
+	}
+	r.UniqueIdentifier = identity
+
+	// plugin is running on another node, forward the request through the http api of that node
+	if ok, originalError := s.app.cluster.IsPluginOnCurrentNode(identity); !ok {
+		return s.redirect(request, srv, identity, path, body, originalError)
+	}
+
+	release, err := s.app.acquireInvocationSlot(srv.Context(), *installation, identity)
+	if err != nil {
+		if errors.Is(err, invocation_limit.ErrQueueFull) || errors.Is(err, invocation_limit.ErrQueueTimeout) {
+			srv.SetHeader(metadata.Pairs("retry-after", strconv.Itoa(s.config.PluginInvocationRetryAfter)))
+			return status.Error(codes.ResourceExhausted, err.Error())
+		}
+		return status.FromContextError(err).Err()
+	}
+	defer release()
+
+	session, err := service.CreateSession(&r, accessType, accessAction, s.app.cluster.ID(), srv.Context())
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	defer session.Close(session_manager.CloseSessionPayload{
+		IgnoreCache: false,
+	})
+
+	return grpcServeSession(srv, session, &r, s.config.PluginMaxExecutionTimeout, invoke)
+}
+
+// grpcServeSession prepares the invocation of a session the same way as the http dispatch api and
+// streams the responses of the plugin, the timeout may be shortened with metadata of INVOKE_TIMEOUT_HEADER
+func grpcServeSession[Req any, Rsp any](
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+	session *session_manager.Session,
+	request *plugin_entities.InvokePluginRequest[Req],
+	max_timeout_seconds int,
+	invoke func(*session_manager.Session, *Req) (*stream.Stream[Rsp], error),
+) error {
+	timeoutHeader := ""
+	md, _ := metadata.FromIncomingContext(srv.Context())
+	if values := md.Get(strings.ToLower(service.INVOKE_TIMEOUT_HEADER)); len(values) > 0 {
+		timeoutHeader = values[0]
+	}
+
+	timeout, err := service.PrepareInvocation(srv.Context(), session, request, timeoutHeader, max_timeout_seconds)
+	if err != nil {
+		var invalid *service.InvalidInvocationError
+		if errors.As(err, &invalid) {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	// callers whose stream broke can fetch the outcome of the session with it, or cancel it
+	srv.SetHeader(metadata.Pairs(strings.ToLower(service.SESSION_ID_HEADER), session.ID))
+
+	ctx, cancel := context.WithTimeout(srv.Context(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	response, err := service.Invoke(session, request, func(session *session_manager.Session) (*stream.Stream[Rsp], error) {
+		return invoke(session, &request.Data)
+	})
+	if err != nil {
+		session.Fail(exception.PluginDaemonInternalServerError, err.Error())
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	// the response stream would be closed by the plugin, the client, the timeout or a cancellation
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-session.Cancelled():
+		}
+		response.Close()
+	}()
+
+	for response.Next() {
+		chunk, err := response.Read()
+		if err != nil {
+			session.Fail(exception.PluginInvokeError, err.Error())
+			return status.Error(codes.Aborted, err.Error())
+		}
+
+		data := &structpb.Struct{}
+		if err := protojson.Unmarshal(parser.MarshalJsonBytes(chunk), data); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err := srv.Send(&dispatch.DispatchResponse{Data: data}); err != nil {
+			session.Fail(exception.PluginConnectionClosedError, "caller disconnected")
+			return err
+		}
+		session.RecordDelivered()
+	}
+
+	select {
+	case <-session.Cancelled():
+		return status.Error(codes.Canceled, "session cancelled")
+	default:
+	}
+
+	if srv.Context().Err() != nil {
+		session.Fail(exception.PluginConnectionClosedError, "caller disconnected")
+		return status.FromContextError(srv.Context().Err()).Err()
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		session.Fail(exception.PluginDaemonInternalServerError, "killed by timeout")
+		return status.Error(codes.DeadlineExceeded, "killed by timeout")
+	}
+
+	return nil
+}
+
+// grpcRequestBody converts the grpc request to the json body of http dispatch api
+func grpcRequestBody(request *dispatch.DispatchRequest) ([]byte, error) {
+	body := map[string]any{
+		"tenant_id":       request.TenantId,
+		"user_id":         request.UserId,
+		"plugin_id":       request.PluginId,
+		"conversation_id": request.ConversationId,
+		"message_id":      request.MessageId,
+		"app_id":          request.AppId,
+		"endpoint_id":     request.EndpointId,
+		"context":         request.GetContext().AsMap(),
+		"data":            request.GetData().AsMap(),
+	}
+
+	if request.Data == nil {
+		return nil, errors.New("data is required")
+	}
+
+	return parser.MarshalJsonBytes(body), nil
+}
+
+func (s *grpcDispatchServer) redirect(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+	identity plugin_entities.PluginUniqueIdentifier,
+	path string,
+	body []byte,
+	originalError error,
+) error {
+	nodes, err := s.app.cluster.FetchPluginAvailableNodesById(identity.String())
+	if err != nil {
+		return status.Error(codes.Internal, "failed to fetch plugin available nodes, "+originalError.Error()+", "+err.Error())
+	}
+	nodes = slices.DeleteFunc(nodes, func(nodeId string) bool {
+		return nodeId == s.app.cluster.ID()
+	})
+	if len(nodes) == 0 {
+		return status.Error(codes.Unavailable, "no available node, "+originalError.Error())
+	}
+
+	req, err := http.NewRequestWithContext(
+		srv.Context(),
+		http.MethodPost,
+		fmt.Sprintf("/plugin/%s/dispatch%s", request.TenantId, path),
+		bytes.NewReader(body),
+	)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(constants.X_API_KEY, s.config.ServerKey)
+	req.Header.Set(constants.X_PLUGIN_ID, request.PluginId)
+	req.Header.Set(constants.X_PLUGIN_UNIQUE_IDENTIFIER, identity.String())
+
+	statusCode, _, responseBody, err := s.app.cluster.RedirectRequestToNodes(nodes, req)
+	if err != nil {
+		return status.Error(codes.Unavailable, "redirect request failed: "+err.Error())
+	}
+	defer responseBody.Close()
+
+	if statusCode != http.StatusOK {
+		return status.Error(codes.Internal, fmt.Sprintf("redirect request failed with status code %d", statusCode))
+	}
+
+	// http dispatch api responds with sse, each event is a serialized entities.Response
+	scanner := bufio.NewScanner(responseBody)
+	scanner.Buffer(make([]byte, 1024), s.config.GrpcMaxRecvMsgSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !bytes.HasPrefix(line, []byte("data: ")) {
+			continue
+		}
+
+		event, err := parser.UnmarshalJsonBytes[entities.GenericResponse[map[string]any]](
+			bytes.TrimPrefix(line, []byte("data: ")),
+		)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if event.Code != 0 {
+			return status.Error(codes.Aborted, event.Message)
+		}
+
+		data, err := structpb.NewStruct(event.Data)
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+
+		if err := srv.Send(&dispatch.DispatchResponse{Data: data}); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+
+	return nil
+}
+
+func (s *grpcDispatchServer) InvokeAgentStrategy(
+	request *dispatch.DispatchRequest,
+	srv grpc.ServerStreamingServer[dispatch.DispatchResponse],
+) error {
+	return grpcDispatch(
+		s,
+		request,
+		srv,
+		access_types.PLUGIN_ACCESS_TYPE_AGENT_STRATEGY,
+		access_types.PLUGIN_ACCESS_ACTION_INVOKE_AGENT_STRATEGY,
+		"/agent_strategy/invoke",
+		plugin_daemon.InvokeAgentStrategy,
+	)
+}
@@ -0,0 +1,324 @@
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/oauth_credentials"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/output_schema"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/tenant_variables"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/tool_files"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/oss"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/proto/dispatch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type grpcTestStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	header metadata.MD
+	sent   []*dispatch.DispatchResponse
+}
+
+func (s *grpcTestStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *grpcTestStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *grpcTestStream) Send(response *dispatch.DispatchResponse) error {
+	s.sent = append(s.sent, response)
+	return nil
+}
+
+func newGrpcTestStream(ctx context.Context, pairs ...string) *grpcTestStream {
+	return &grpcTestStream{ctx: metadata.NewIncomingContext(ctx, metadata.Pairs(pairs...))}
+}
+
+func newGrpcTestSession(
+	t *testing.T,
+	srv *grpcTestStream,
+	declaration *plugin_entities.PluginDeclaration,
+) *session_manager.Session {
+	session := session_manager.NewSession(session_manager.NewSessionPayload{
+		TenantID:       "tenant",
+		Action:         access_types.PLUGIN_ACCESS_ACTION_INVOKE_TOOL,
+		Declaration:    declaration,
+		IgnoreCache:    true,
+		RequestContext: srv.Context(),
+	})
+	t.Cleanup(func() { session.Close(session_manager.CloseSessionPayload{IgnoreCache: true}) })
+	return session
+}
+
+// grpcTestDeclaration declares the tool search/web invoked by the tests
+func grpcTestDeclaration() *plugin_entities.PluginDeclaration {
+	declaration := &plugin_entities.PluginDeclaration{}
+	declaration.Tool = &plugin_entities.ToolProviderDeclaration{}
+	declaration.Tool.Identity.Name = "search"
+	declaration.Tool.Tools = []plugin_entities.ToolDeclaration{{
+		OutputSchema: plugin_entities.ToolOutputSchema{
+			"type":     "object",
+			"required": []any{"summary"},
+			"properties": map[string]any{
+				"summary": map[string]any{"type": "string"},
+			},
+		},
+	}}
+	declaration.Tool.Tools[0].Identity.Name = "web"
+	declaration.Tool.Tools[0].Parameters = []plugin_entities.ToolParameter{{
+		Name: "count",
+		Type: plugin_entities.TOOL_PARAMETER_TYPE_NUMBER,
+		Form: plugin_entities.TOOL_PARAMETER_FORM_LLM,
+		Min:  parser.ToPtr(1.0),
+	}, {
+		Name: "document",
+		Type: plugin_entities.TOOL_PARAMETER_TYPE_FILE,
+		Form: plugin_entities.TOOL_PARAMETER_FORM_LLM,
+	}}
+	return declaration
+}
+
+func newGrpcToolRequest(credentials map[string]any) *plugin_entities.InvokePluginRequest[requests.RequestInvokeTool] {
+	request := &plugin_entities.InvokePluginRequest[requests.RequestInvokeTool]{}
+	request.TenantId = "tenant"
+	request.Data.Provider = "search"
+	request.Data.Tool = "web"
+	request.Data.ToolParameters = map[string]any{}
+	request.Data.Credentials.Credentials = credentials
+	return request
+}
+
+// respondWith is a plugin answering invocations with chunks, the request it received is kept in received
+func respondWith(
+	received *requests.RequestInvokeTool,
+	chunks ...tool_entities.ToolResponseChunk,
+) func(*session_manager.Session, *requests.RequestInvokeTool) (*stream.Stream[tool_entities.ToolResponseChunk], error) {
+	return func(session *session_manager.Session, request *requests.RequestInvokeTool) (*stream.Stream[tool_entities.ToolResponseChunk], error) {
+		*received = *request
+		response := stream.NewStream[tool_entities.ToolResponseChunk](len(chunks) + 1)
+		for _, chunk := range chunks {
+			response.Write(chunk)
+		}
+		response.Close()
+		return response, nil
+	}
+}
+
+func TestGrpcServeSessionResolvesTenantVariables(t *testing.T) {
+	config := &app.Config{DBType: "sqlite", DBSqlitePath: filepath.Join(t.TempDir(), "grpc.db")}
+	config.SetDefault()
+	db.Init(config)
+	t.Cleanup(db.Close)
+
+	_, err := tenant_variables.Set("tenant", "SEARCH_API_KEY", "sk-resolved", false)
+	require.NoError(t, err)
+
+	srv := newGrpcTestStream(context.Background())
+	session := newGrpcTestSession(t, srv, nil)
+	request := newGrpcToolRequest(map[string]any{"api_key": "{{env.SEARCH_API_KEY}}"})
+
+	var received requests.RequestInvokeTool
+	err = grpcServeSession(srv, session, request, 60, respondWith(&received, tool_entities.ToolResponseChunk{
+		Type:    tool_entities.ToolResponseChunkTypeText,
+		Message: map[string]any{"text": "done"},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "sk-resolved", received.Credentials.Credentials["api_key"])
+	assert.Len(t, srv.sent, 1)
+	assert.Equal(t, []string{session.ID}, srv.header.Get("x-dify-plugin-session-id"))
+
+	// references to unknown variables never reach the plugin
+	srv = newGrpcTestStream(context.Background())
+	session = newGrpcTestSession(t, srv, nil)
+	received = requests.RequestInvokeTool{}
+	err = grpcServeSession(srv, session, newGrpcToolRequest(map[string]any{"api_key": "{{secret.MISSING}}"}), 60, respondWith(&received))
+	assert.Error(t, err)
+	assert.Empty(t, received.Provider)
+}
+
+func TestGrpcServeSessionChecksToolOutput(t *testing.T) {
+	routine.InitPool(16)
+	output_schema.Init(output_schema.POLICY_ENFORCE)
+	t.Cleanup(func() { output_schema.Init(output_schema.POLICY_OFF) })
+
+	summary := func(value any) tool_entities.ToolResponseChunk {
+		return tool_entities.ToolResponseChunk{
+			Type:    tool_entities.ToolResponseChunkTypeVariable,
+			Message: map[string]any{"variable_name": "summary", "variable_value": value, "stream": false},
+		}
+	}
+
+	srv := newGrpcTestStream(context.Background())
+	session := newGrpcTestSession(t, srv, grpcTestDeclaration())
+	var received requests.RequestInvokeTool
+	err := grpcServeSession(srv, session, newGrpcToolRequest(nil), 60, respondWith(&received, summary("found")))
+	require.NoError(t, err)
+	assert.Len(t, srv.sent, 1)
+
+	srv = newGrpcTestStream(context.Background())
+	session = newGrpcTestSession(t, srv, grpcTestDeclaration())
+	err = grpcServeSession(srv, session, newGrpcToolRequest(nil), 60, respondWith(&received, summary(42)))
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	assert.Contains(t, status.Convert(err).Message(), output_schema.ERROR_TYPE_OUTPUT_SCHEMA_VIOLATION)
+}
+
+func TestGrpcServeSessionCoercesToolParameters(t *testing.T) {
+	service.SetToolParameterCoercion(true)
+	t.Cleanup(func() { service.SetToolParameterCoercion(false) })
+
+	invoke := func(parameters map[string]any) (requests.RequestInvokeTool, error) {
+		srv := newGrpcTestStream(context.Background())
+		session := newGrpcTestSession(t, srv, grpcTestDeclaration())
+		request := newGrpcToolRequest(nil)
+		request.Data.ToolParameters = parameters
+
+		var received requests.RequestInvokeTool
+		err := grpcServeSession(srv, session, request, 60, respondWith(&received))
+		return received, err
+	}
+
+	received, err := invoke(map[string]any{"count": "3"})
+	require.NoError(t, err)
+	assert.Equal(t, 3.0, received.ToolParameters["count"])
+
+	received, err = invoke(map[string]any{"count": "0"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Empty(t, received.Provider)
+}
+
+func TestGrpcServeSessionResolvesToolFiles(t *testing.T) {
+	storage, err := oss.NewLocalStorage(cloudoss.OSSArgs{Local: &cloudoss.Local{Path: t.TempDir()}})
+	require.NoError(t, err)
+	tool_files.Init(storage, tool_files.Config{
+		URLBase:    "http://daemon:5002",
+		SigningKey: "signing-key",
+		Path:       "tool_files",
+		TTL:        time.Hour,
+	})
+	t.Cleanup(func() { tool_files.Init(storage, tool_files.Config{}) })
+
+	file, err := tool_files.GetBroker().Stage("tenant", "report.pdf", "application/pdf", strings.NewReader("content"))
+	require.NoError(t, err)
+
+	srv := newGrpcTestStream(context.Background())
+	session := newGrpcTestSession(t, srv, grpcTestDeclaration())
+	request := newGrpcToolRequest(nil)
+	request.Data.ToolParameters = map[string]any{"document": file.ID}
+
+	var received requests.RequestInvokeTool
+	require.NoError(t, grpcServeSession(srv, session, request, 60, respondWith(&received)))
+	document, ok := received.ToolParameters["document"].(map[string]any)
+	require.True(t, ok, "expected a file object, got %v", received.ToolParameters["document"])
+	assert.Equal(t, tool_files.DIFY_FILE_IDENTITY, document["dify_model_identity"])
+	assert.Equal(t, tool_files.GetBroker().URL(file), document["url"])
+
+	srv = newGrpcTestStream(context.Background())
+	session = newGrpcTestSession(t, srv, grpcTestDeclaration())
+	request = newGrpcToolRequest(nil)
+	request.Data.ToolParameters = map[string]any{"document": "00000000-0000-0000-0000-000000000000"}
+	err = grpcServeSession(srv, session, request, 60, respondWith(&received))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGrpcServeSessionInjectsOAuthCredentials(t *testing.T) {
+	require.NoError(t, cache.InitMemoryClient(0))
+	t.Cleanup(func() { cache.Close() })
+	config := &app.Config{DBType: "sqlite", DBSqlitePath: filepath.Join(t.TempDir(), "grpc.db")}
+	config.SetDefault()
+	db.Init(config)
+	t.Cleanup(db.Close)
+	oauth_credentials.Init("test-key")
+	t.Cleanup(func() { oauth_credentials.Init("") })
+
+	require.NoError(t, oauth_credentials.Save(&oauth_credentials.Credential{
+		TenantID:    "tenant",
+		PluginID:    "langgenius/search",
+		Provider:    "search",
+		Credentials: map[string]any{"access_token": "a1"},
+		ExpiresAt:   time.Now().Add(time.Hour).Unix(),
+	}))
+
+	invoke := func(credentials map[string]any) (requests.RequestInvokeTool, error) {
+		srv := newGrpcTestStream(context.Background())
+		session := newGrpcTestSession(t, srv, nil)
+		request := newGrpcToolRequest(credentials)
+		request.UniqueIdentifier = "langgenius/search:0.0.1"
+		request.Data.Credentials.CredentialType = requests.CREDENTIAL_TYPE_OAUTH2
+
+		var received requests.RequestInvokeTool
+		err := grpcServeSession(srv, session, request, 60, respondWith(&received))
+		return received, err
+	}
+
+	received, err := invoke(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "a1", received.Credentials.Credentials["access_token"])
+
+	// credentials passed by the caller are kept
+	received, err = invoke(map[string]any{"access_token": "passed"})
+	require.NoError(t, err)
+	assert.Equal(t, "passed", received.Credentials.Credentials["access_token"])
+
+	oauth_credentials.Delete("tenant", "langgenius/search", "search")
+	received, err = invoke(nil)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Empty(t, received.Provider)
+}
+
+func TestGrpcServeSessionStopsOnCancellationAndTimeout(t *testing.T) {
+	// the plugin answers once and never finishes
+	hang := func(session *session_manager.Session, request *requests.RequestInvokeTool) (*stream.Stream[tool_entities.ToolResponseChunk], error) {
+		response := stream.NewStream[tool_entities.ToolResponseChunk](1)
+		response.Write(tool_entities.ToolResponseChunk{
+			Type:    tool_entities.ToolResponseChunkTypeText,
+			Message: map[string]any{"text": "working"},
+		})
+		return response, nil
+	}
+
+	srv := newGrpcTestStream(context.Background())
+	session := newGrpcTestSession(t, srv, nil)
+	time.AfterFunc(100*time.Millisecond, func() { session.Cancel("cancelled by the caller") })
+	err := grpcServeSession(srv, session, newGrpcToolRequest(nil), 60, hang)
+	assert.Equal(t, codes.Canceled, status.Code(err))
+	assert.Len(t, srv.sent, 1)
+
+	srv = newGrpcTestStream(context.Background(), "x-dify-plugin-invoke-timeout", "1")
+	session = newGrpcTestSession(t, srv, nil)
+	started := time.Now()
+	err = grpcServeSession(srv, session, newGrpcToolRequest(nil), 60, hang)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Less(t, time.Since(started), 10*time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	srv = newGrpcTestStream(ctx)
+	session = newGrpcTestSession(t, srv, nil)
+	time.AfterFunc(100*time.Millisecond, cancel)
+	err = grpcServeSession(srv, session, newGrpcToolRequest(nil), 60, hang)
+	assert.Equal(t, codes.Canceled, status.Code(err))
+}
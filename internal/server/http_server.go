@@ -2,23 +2,34 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/access_control"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/audit"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/endpoint_domains"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/backwards_invocation/transaction"
 	"github.com/langgenius/dify-plugin-daemon/internal/server/controllers"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/dashboard"
 	"github.com/langgenius/dify-plugin-daemon/internal/service"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/network"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
 
 	sentrygin "github.com/getsentry/sentry-go/gin"
 )
 
 // server starts a http server and returns a function to stop it
-func (app *App) server(config *app.Config) func() {
+func (app *App) server(config *app.Config) func(ctx context.Context) {
 	engine := gin.New()
+	if err := engine.SetTrustedProxies(config.TrustedProxies); err != nil {
+		log.Panic("failed to set trusted proxies: %s", err.Error())
+	}
 	if *config.HealthApiLogEnabled {
 		engine.Use(gin.Logger())
 	} else {
@@ -29,10 +40,47 @@ func (app *App) server(config *app.Config) func() {
 	engine.Use(gin.Recovery())
 	engine.Use(controllers.CollectActiveRequests())
 	engine.GET("/health/check", controllers.HealthCheck(config))
+	if len(config.ServerIPAllowlist) > 0 {
+		allowed, err := network.ParseIPNets(config.ServerIPAllowlist)
+		if err != nil {
+			log.Panic("invalid server ip allowlist: %s", err.Error())
+		}
+		// registered after the health check, which stays reachable by probes
+		engine.Use(AllowIPs(allowed))
+	}
 
 	endpointGroup := engine.Group("/e")
 	serverlessTransactionGroup := engine.Group("/backwards-invocation")
 	pluginGroup := engine.Group("/plugin/:tenant_id")
+	engine.POST(
+		"/plugin/lint",
+		Authenticate(),
+		Authorize(access_control.PERMISSION_PLUGIN_MANAGE),
+		LimitRequests(installLimits(config)),
+		controllers.LintPlugin(config),
+	)
+	if config.PluginTestInvokeRoot != "" {
+		engine.POST(
+			"/plugin/test-invoke",
+			Authenticate(),
+			Authorize(access_control.PERMISSION_PLUGIN_DEBUG),
+			controllers.TestInvokePlugin(config),
+		)
+	}
+	oauthGroup := engine.Group("/oauth")
+	engine.GET("/files/:tenant_id/:id", controllers.DownloadToolFile)
+	if config.MarketplaceMirrorEnabled {
+		mirrorGroup := engine.Group(
+			"/marketplace",
+			Authenticate(),
+			Authorize(access_control.PERMISSION_PLUGIN_MANAGE),
+		)
+		mirrorGroup.GET("/api/v1/plugins/download", controllers.DownloadMirroredPackage)
+		mirrorGroup.HEAD("/api/v1/plugins/download", controllers.DownloadMirroredPackage)
+	}
+	if config.PluginRegistryEnabled {
+		app.registryGroup(engine.Group("/registry/:organization", Authenticate()), config)
+	}
 	pprofGroup := engine.Group("/debug/pprof")
 
 	if config.AdminApiEnabled {
@@ -41,9 +89,13 @@ func (app *App) server(config *app.Config) func() {
 		}
 
 		adminGroup := engine.Group("/admin")
-		adminGroup.Use(app.AdminAPIKey(config.AdminApiKey))
+		adminGroup.Use(Authenticate())
 
 		app.adminGroup(adminGroup, config)
+
+		if config.AdminDashboardEnabled {
+			engine.GET("/admin/dashboard/*filepath", dashboard.Handler("/admin/dashboard"))
+		}
 	}
 
 	if config.SentryEnabled {
@@ -52,6 +104,7 @@ func (app *App) server(config *app.Config) func() {
 			endpointGroup,
 			serverlessTransactionGroup,
 			pluginGroup,
+			oauthGroup,
 		}
 		for _, group := range sentryGroup {
 			group.Use(sentrygin.New(sentrygin.Options{
@@ -60,58 +113,143 @@ func (app *App) server(config *app.Config) func() {
 		}
 	}
 
+	if config.OtelEnabled {
+		for _, group := range []*gin.RouterGroup{
+			endpointGroup,
+			serverlessTransactionGroup,
+			pluginGroup,
+			oauthGroup,
+		} {
+			group.Use(tracing.GinMiddleware())
+		}
+	}
+
 	app.endpointGroup(endpointGroup, config)
 	app.serverlessTransactionGroup(serverlessTransactionGroup, config)
 	app.pluginGroup(pluginGroup, config)
+	app.oauthGroup(oauthGroup, config)
 	app.pprofGroup(pprofGroup, config)
 
+	app.endpoints = engine
+
+	var handler http.Handler = engine
+	if config.PluginEndpointEnabled != nil && *config.PluginEndpointEnabled && endpoint_domains.Enabled() {
+		handler = routeEndpointDomains(engine)
+	}
+
 	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", config.ServerPort),
-		Handler: engine,
+		Addr:              fmt.Sprintf(":%d", config.ServerPort),
+		Handler:           handler,
+		ReadHeaderTimeout: seconds(config.ServerReadHeaderTimeout),
+		IdleTimeout:       seconds(config.ServerIdleTimeout),
 	}
 
+	trustedProxies, err := network.ParseIPNets(config.TrustedProxies)
+	if err != nil {
+		log.Panic("invalid trusted proxies: %s", err.Error())
+	}
+	listener, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		log.Panic("listen: %s\n", err)
+	}
+	listener = network.NewListener(listener, network.ListenerConfig{
+		ProxyProtocol:  config.ServerProxyProtocolEnabled,
+		TrustedProxies: trustedProxies,
+	})
+
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if app.tlsConfig != nil {
+			srv.TLSConfig = app.tlsConfig
+			err = srv.ServeTLS(listener, "", "")
+		} else {
+			err = srv.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Panic("listen: %s\n", err)
 		}
 	}()
 
-	return func() {
-		if err := srv.Shutdown(context.Background()); err != nil {
-			log.Panic("Server Shutdown: %s\n", err)
+	// custom domains of endpoints are served with their own certificates picked by SNI
+	var tlsSrv *http.Server
+	if config.EndpointTLSPort != 0 {
+		tlsSrv = &http.Server{
+			Addr:              fmt.Sprintf(":%d", config.EndpointTLSPort),
+			Handler:           handler,
+			ReadHeaderTimeout: seconds(config.ServerReadHeaderTimeout),
+			IdleTimeout:       seconds(config.ServerIdleTimeout),
+			TLSConfig: &tls.Config{
+				MinVersion:     tls.VersionTLS12,
+				GetCertificate: endpoint_domains.NewCertificates(config.EndpointTLSCertDir).GetCertificate,
+			},
+		}
+
+		go func() {
+			if err := tlsSrv.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+				log.Panic("listen tls: %s\n", err)
+			}
+		}()
+	}
+
+	return func(ctx context.Context) {
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Error("Server Shutdown: %s\n", err)
+		}
+		if tlsSrv != nil {
+			if err := tlsSrv.Shutdown(ctx); err != nil {
+				log.Error("TLS Server Shutdown: %s\n", err)
+			}
 		}
 	}
 }
 
 func (app *App) pluginGroup(group *gin.RouterGroup, config *app.Config) {
-	group.Use(CheckingKey(config.ServerKey))
+	group.Use(Authenticate())
 
-	app.remoteDebuggingGroup(group.Group("/debugging"), config)
-	app.pluginDispatchGroup(group.Group("/dispatch"), config)
-	app.pluginManagementGroup(group.Group("/management"), config)
-	app.endpointManagementGroup(group.Group("/endpoint"))
-	app.pluginAssetGroup(group.Group("/asset"))
+	app.remoteDebuggingGroup(group.Group("/debugging", Authorize(access_control.PERMISSION_PLUGIN_DEBUG)), config)
+	app.pluginDispatchGroup(group.Group("/dispatch", Authorize(access_control.PERMISSION_PLUGIN_INVOKE)), config)
+	app.pluginManagementGroup(group.Group("/management", Authorize(access_control.PERMISSION_PLUGIN_MANAGE)), config)
+	app.endpointManagementGroup(group.Group("/endpoint", Authorize(access_control.PERMISSION_PLUGIN_MANAGE)))
+	app.pluginAssetGroup(group.Group("/asset", Authorize(access_control.PERMISSION_PLUGIN_MANAGE)))
+	app.toolFileGroup(group.Group("/files", Authorize(access_control.PERMISSION_PLUGIN_INVOKE)))
+	app.invocationGroup(group.Group("/invoke", Authorize(access_control.PERMISSION_PLUGIN_INVOKE)))
 }
 
 func (app *App) pluginDispatchGroup(group *gin.RouterGroup, config *app.Config) {
+	group.Use(LimitRequests(invokeLimits(config)))
+	group.Use(RejectWhileDraining())
 	group.Use(controllers.CollectActiveDispatchRequests())
+	group.Use(app.RateLimit())
 	group.Use(app.FetchPluginInstallation())
 	group.Use(app.RedirectPluginInvoke())
+	group.Use(app.LimitPluginConcurrency(config))
 	group.Use(app.InitClusterID())
 
 	group.POST("/agent_strategy/invoke", controllers.InvokeAgentStrategy(config))
+	group.POST("/oauth/authorize", controllers.AuthorizeOAuth(config))
 
 	app.setupGeneratedRoutes(group, config)
 }
 
+// oauthGroup is reached by browsers redirected by oauth providers, requests carry no key and are
+// recognized by the state the daemon put into the authorization url
+func (app *App) oauthGroup(group *gin.RouterGroup, config *app.Config) {
+	group.Use(RejectWhileDraining())
+	group.Use(app.InitClusterID())
+	group.GET("/callback", controllers.OAuthCallback(config))
+	group.POST("/callback", controllers.OAuthCallback(config))
+}
+
 func (app *App) remoteDebuggingGroup(group *gin.RouterGroup, config *app.Config) {
 	if config.PluginRemoteInstallingEnabled != nil && *config.PluginRemoteInstallingEnabled {
-		group.POST("/key", CheckingKey(config.ServerKey), controllers.GetRemoteDebuggingKey)
+		group.POST("/key", controllers.GetRemoteDebuggingKey)
 	}
 }
 
 func (app *App) endpointGroup(group *gin.RouterGroup, config *app.Config) {
 	if config.PluginEndpointEnabled != nil && *config.PluginEndpointEnabled {
+		group.Use(RejectWhileDraining())
+		group.Use(LimitRequests(endpointLimits(config)))
 		group.HEAD("/:hook_id/*path", app.Endpoint(config))
 		group.POST("/:hook_id/*path", app.Endpoint(config))
 		group.GET("/:hook_id/*path", app.Endpoint(config))
@@ -134,51 +272,131 @@ func (appRef *App) serverlessTransactionGroup(group *gin.RouterGroup, config *ap
 }
 
 func (app *App) endpointManagementGroup(group *gin.RouterGroup) {
-	group.POST("/setup", controllers.SetupEndpoint)
-	group.POST("/remove", controllers.RemoveEndpoint)
-	group.POST("/update", controllers.UpdateEndpoint)
+	group.POST("/setup", Audit(audit.EVENT_ENDPOINT_SETUP), controllers.SetupEndpoint)
+	group.POST("/remove", Audit(audit.EVENT_ENDPOINT_REMOVE), controllers.RemoveEndpoint)
+	group.POST("/update", Audit(audit.EVENT_ENDPOINT_UPDATE), controllers.UpdateEndpoint)
+	group.POST("/middlewares", controllers.SetEndpointMiddlewares)
+	group.GET("/domains", controllers.ListEndpointDomains)
+	group.POST("/domains", controllers.SetEndpointDomain)
+	group.POST("/domains/remove", controllers.RemoveEndpointDomain)
 	group.GET("/list", controllers.ListEndpoints)
 	group.GET("/list/plugin", controllers.ListPluginEndpoints)
-	group.POST("/enable", controllers.EnableEndpoint)
-	group.POST("/disable", controllers.DisableEndpoint)
+	group.POST("/enable", Audit(audit.EVENT_ENDPOINT_ENABLE), controllers.EnableEndpoint)
+	group.POST("/disable", Audit(audit.EVENT_ENDPOINT_DISABLE), controllers.DisableEndpoint)
 }
 
 func (app *App) pluginManagementGroup(group *gin.RouterGroup, config *app.Config) {
-	group.POST("/install/upload/package", controllers.UploadPlugin(config))
-	group.POST("/install/upload/bundle", controllers.UploadBundle(config))
-	group.POST("/install/identifiers", controllers.InstallPluginFromIdentifiers(config))
-	group.POST("/install/upgrade", controllers.UpgradePlugin(config))
+	uploadLimits := LimitRequests(installLimits(config))
+	group.POST("/install/upload/package", uploadLimits, controllers.UploadPlugin(config))
+	group.POST("/install/upload/bundle", uploadLimits, controllers.UploadBundle(config))
+	group.POST("/install/dry-run", uploadLimits, controllers.DryRunPluginInstall(config))
+	group.POST("/install/download/marketplace", controllers.DownloadPluginFromMarketplace(config))
+	group.POST("/install/download/registry", controllers.DownloadPluginFromRegistry(config))
+	group.POST("/install/identifiers", Audit(audit.EVENT_PLUGIN_INSTALL), controllers.InstallPluginFromIdentifiers(config))
+	group.POST("/install/upgrade", Audit(audit.EVENT_PLUGIN_UPGRADE), controllers.UpgradePlugin(config))
+	group.POST("/install/upgrade/schedule", Audit(audit.EVENT_PLUGIN_UPGRADE_SCHEDULE), controllers.SchedulePluginUpgrade)
+	group.GET("/install/upgrade/schedules", controllers.ListScheduledPluginUpgrades)
+	group.POST("/install/upgrade/schedules/:id/cancel", controllers.CancelScheduledPluginUpgrade)
+	group.POST("/install/version", Audit(audit.EVENT_PLUGIN_VERSION_INSTALL), controllers.InstallPluginVersion(config))
 	group.GET("/install/tasks/:id", controllers.FetchPluginInstallationTask)
 	group.POST("/install/tasks/delete_all", controllers.DeleteAllPluginInstallationTasks)
 	group.POST("/install/tasks/:id/delete", controllers.DeletePluginInstallationTask)
 	group.POST("/install/tasks/:id/delete/*identifier", controllers.DeletePluginInstallationItemFromTask)
 	group.GET("/install/tasks", controllers.FetchPluginInstallationTasks)
+	group.GET("/install/queue", controllers.FetchPluginInstallQueue)
+	group.GET("/install/approvals", controllers.ListTenantPluginInstallApprovals)
 	group.GET("/decode/from_identifier", controllers.DecodePluginFromIdentifier(config))
 	group.GET("/fetch/manifest", controllers.FetchPluginManifest)
+	group.GET("/fetch/security_report", controllers.FetchPluginSecurityReport)
 	group.GET("/fetch/identifier", controllers.FetchPluginFromIdentifier)
-	group.POST("/uninstall", controllers.UninstallPlugin)
+	group.POST("/uninstall", Audit(audit.EVENT_PLUGIN_UNINSTALL), controllers.UninstallPlugin)
+	group.GET("/uninstall/preflight", controllers.UninstallPluginPreflight)
+	group.GET("/versions", controllers.ListPluginVersions)
+	group.POST("/versions/uninstall", Audit(audit.EVENT_PLUGIN_VERSION_UNINSTALL), controllers.UninstallPluginVersion)
 	group.GET("/list", controllers.ListPlugins)
 	group.POST("/installation/fetch/batch", controllers.BatchFetchPluginInstallationByIDs)
 	group.POST("/installation/missing", controllers.FetchMissingPluginInstallations)
+	group.GET("/permissions", controllers.FetchPluginPermissionConsent)
+	group.POST("/permissions/consent", Audit(audit.EVENT_PLUGIN_PERMISSION), controllers.ConsentPluginPermissions)
+	group.GET("/dependency_graph", controllers.FetchPluginDependencyGraph)
+	group.GET("/variables", controllers.ListTenantVariables)
+	group.POST("/variables", Audit(audit.EVENT_CREDENTIAL_SET), controllers.SetTenantVariable)
+	group.POST("/variables/delete", Audit(audit.EVENT_CREDENTIAL_DELETE), controllers.DeleteTenantVariable)
+	group.GET("/settings", controllers.FetchTenantSettings)
+	group.POST("/settings/timezone", controllers.SetTenantTimezone)
+	group.GET("/oauth/credentials", controllers.ListOAuthCredentials)
+	group.POST("/oauth/credentials/delete", Audit(audit.EVENT_OAUTH_CREDENTIAL_DELETE), controllers.DeleteOAuthCredentials)
 	group.GET("/models", controllers.ListModels)
 	group.GET("/tools", controllers.ListTools)
 	group.GET("/tool", controllers.GetTool)
 	group.POST("/tools/check_existence", controllers.CheckToolExistence)
 	group.GET("/agent_strategies", controllers.ListAgentStrategies)
 	group.GET("/agent_strategy", controllers.GetAgentStrategy)
+	group.GET("/sessions/:session_id", controllers.FetchSessionStatus)
+}
+
+func (app *App) registryGroup(group *gin.RouterGroup, config *app.Config) {
+	read := Authorize(access_control.PERMISSION_REGISTRY_READ)
+	publish := Authorize(access_control.PERMISSION_REGISTRY_PUBLISH)
+
+	group.POST("/plugins", publish, LimitRequests(installLimits(config)), controllers.PublishRegistryPlugin(config))
+	group.GET("/plugins", read, controllers.SearchRegistryPlugins)
+	group.GET("/plugins/:name", read, controllers.ListRegistryPluginVersions)
+	group.GET("/plugins/:name/:version/download", read, controllers.DownloadRegistryPlugin)
 }
 
 func (app *App) adminGroup(group *gin.RouterGroup, config *app.Config) {
-	group.POST("/plugin/serverless/reinstall", controllers.ReinstallPluginFromIdentifier(config))
+	read := Authorize(access_control.PERMISSION_CLUSTER_READ)
+	manage := Authorize(access_control.PERMISSION_CLUSTER_MANAGE)
+	logs := Authorize(access_control.PERMISSION_PLUGIN_LOGS)
+	keys := Authorize(access_control.PERMISSION_KEYS_MANAGE)
+
+	group.POST("/plugin/serverless/reinstall", manage, controllers.ReinstallPluginFromIdentifier(config))
+	group.GET("/plugins", read, controllers.ListAllPluginInstallations(app.cluster))
+	group.GET("/plugins/:installation_id", read, controllers.FetchPluginInstallationDetail(app.cluster))
+	group.GET("/plugin/install/approvals", read, controllers.ListPluginInstallApprovals)
+	group.POST("/plugin/install/approvals/:id/approve", manage, Audit(audit.EVENT_PLUGIN_INSTALL_APPROVE), controllers.ApprovePluginInstall(config))
+	group.POST("/plugin/install/approvals/:id/reject", manage, Audit(audit.EVENT_PLUGIN_INSTALL_REJECT), controllers.RejectPluginInstall(config))
+	group.GET("/plugin/anomalies", read, controllers.FetchAnomalyStatus)
+	group.POST("/plugin/rollouts", manage, controllers.StartPluginRollout(config))
+	group.GET("/plugin/rollouts", read, controllers.ListPluginRollouts)
+	group.GET("/plugin/rollouts/:id", read, controllers.FetchPluginRollout)
+	group.POST("/plugin/rollouts/:id/update", manage, controllers.UpdatePluginRollout)
+	group.POST("/plugin/rollouts/:id/promote", manage, controllers.PromotePluginRollout)
+	group.POST("/plugin/rollouts/:id/rollback", manage, controllers.RollbackPluginRollout)
+	group.GET("/plugin/logs", logs, controllers.FetchPluginLogs)
+	group.GET("/plugin/logs/tail", logs, controllers.TailPluginLogs(config))
+	group.GET("/debugging/connections", logs, controllers.ListDebuggingConnections)
+	group.GET("/retention", read, controllers.FetchRetentionStatus)
+	group.GET("/stats", read, controllers.FetchNodeStats)
+	group.GET("/cluster/nodes", read, controllers.ListClusterNodes(app.cluster))
+	group.GET("/tenant/:tenant_id/data", read, controllers.FetchTenantDataInventory)
+	group.POST("/tenant/:tenant_id/data/purge", manage, Audit(audit.EVENT_TENANT_DATA_PURGE), controllers.PurgeTenantData)
+	group.GET("/tenants/:tenant_id/usage", read, controllers.FetchTenantUsage(config))
+	group.GET("/audit", read, controllers.ListAuditRecords)
+	group.GET("/audit/export", read, controllers.ExportAuditRecords)
+	group.GET("/roles", keys, controllers.ListRoles)
+	group.GET("/keys", keys, controllers.ListAPIKeys)
+	group.POST("/keys", keys, Audit(audit.EVENT_API_KEY_CREATE), controllers.CreateAPIKey)
+	group.POST("/keys/:id/rotate", keys, Audit(audit.EVENT_API_KEY_ROTATE), controllers.RotateAPIKey)
+	group.POST("/keys/:id/revoke", keys, Audit(audit.EVENT_API_KEY_REVOKE), controllers.RevokeAPIKey)
 }
 
 func (app *App) pluginAssetGroup(group *gin.RouterGroup) {
 	group.GET("/:id", controllers.GetAsset)
 }
 
+func (app *App) toolFileGroup(group *gin.RouterGroup) {
+	group.POST("/upload", controllers.UploadToolFile)
+}
+
+func (app *App) invocationGroup(group *gin.RouterGroup) {
+	group.POST("/:session_id/cancel", app.RedirectSessionCancel(), controllers.CancelSession)
+}
+
 func (app *App) pprofGroup(group *gin.RouterGroup, config *app.Config) {
 	if config.PPROFEnabled {
-		group.Use(CheckingKey(config.ServerKey))
+		group.Use(Authenticate(), Authorize(access_control.PERMISSION_PLUGIN_DEBUG))
 
 		group.GET("/", controllers.PprofIndex)
 		group.GET("/cmdline", controllers.PprofCmdline)
@@ -1,17 +1,36 @@
 package server
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/cluster"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/access_control"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/invocation_limit"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_rollout"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/rate_limit"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/controllers"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/network"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
@@ -27,6 +46,131 @@ func CheckingKey(key string) gin.HandlerFunc {
 	}
 }
 
+// AllowIPs rejects clients outside the allow-list, the client ip is resolved through the trusted proxies
+func AllowIPs(allowed network.IPNets) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !allowed.Allows(net.ParseIP(c.ClientIP())) {
+			c.AbortWithStatusJSON(403, exception.PermissionDeniedError("ip is not allowed").ToResponse())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RejectWhileDraining refuses new invocations once the node started shutting down
+func RejectWhileDraining() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if controllers.Draining() {
+			c.AbortWithStatusJSON(503, exception.UnavailableError("plugin daemon is shutting down").ToResponse())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RateLimit takes a token of the buckets of the tenant and the plugin invoked, requests over the limit are
+// rejected with 429, requests redirected by other nodes were counted there already
+func (app *App) RateLimit() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if app.rateLimiter == nil || cluster.IsRedirectedRequest(ctx.Request) {
+			ctx.Next()
+			return
+		}
+
+		pluginId, _ := plugin_entities.SplitPluginVersion(ctx.Request.Header.Get(constants.X_PLUGIN_ID))
+		decision, err := app.rateLimiter.Allow(ctx.Param("tenant_id"), pluginId)
+		if err != nil {
+			// the cache is unavailable, requests are not limited rather than rejected
+			log.Warn("failed to check rate limit: %s", err.Error())
+			ctx.Next()
+			return
+		}
+
+		for key, value := range rateLimitHeaders(decision) {
+			ctx.Header(key, value)
+		}
+		if !decision.Allowed {
+			ctx.AbortWithStatusJSON(429, exception.TooManyRequestsError(
+				fmt.Sprintf("rate limit of %s exceeded", decision.Scope),
+			).ToResponse())
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+// rateLimitHeaders are the RateLimit headers of the ietf draft, Retry-After is added to rejections
+func rateLimitHeaders(decision *rate_limit.Decision) map[string]string {
+	if decision.Limit == 0 {
+		return nil
+	}
+
+	seconds := func(d time.Duration) string {
+		return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+	}
+	headers := map[string]string{
+		"RateLimit-Limit":     strconv.FormatInt(decision.Limit, 10),
+		"RateLimit-Remaining": strconv.FormatInt(decision.Remaining, 10),
+		"RateLimit-Reset":     seconds(decision.Reset),
+	}
+	if !decision.Allowed {
+		headers["Retry-After"] = seconds(max(decision.RetryAfter, time.Second))
+	}
+	return headers
+}
+
+// LimitPluginConcurrency bounds the invocations a plugin runs at the same time on the current node,
+// it must come after RedirectPluginInvoke so that only the node running the plugin counts them
+func (app *App) LimitPluginConcurrency(config *app.Config) gin.HandlerFunc {
+	retryAfter := strconv.Itoa(config.PluginInvocationRetryAfter)
+
+	return func(ctx *gin.Context) {
+		identity := ctx.MustGet(constants.CONTEXT_KEY_PLUGIN_UNIQUE_IDENTIFIER).(plugin_entities.PluginUniqueIdentifier)
+		installation := ctx.MustGet(constants.CONTEXT_KEY_PLUGIN_INSTALLATION).(models.PluginInstallation)
+
+		release, err := app.acquireInvocationSlot(ctx.Request.Context(), installation, identity)
+		if err != nil {
+			if errors.Is(err, invocation_limit.ErrQueueFull) || errors.Is(err, invocation_limit.ErrQueueTimeout) {
+				ctx.Header("Retry-After", retryAfter)
+				ctx.AbortWithStatusJSON(429, exception.TooManyRequestsError(err.Error()).ToResponse())
+				return
+			}
+			// the caller is gone
+			ctx.Abort()
+			return
+		}
+		defer release()
+
+		ctx.Next()
+	}
+}
+
+// acquireInvocationSlot waits until the plugin may run one more invocation, the limit is the
+// max_concurrency of its manifest capped by the daemon
+func (app *App) acquireInvocationSlot(
+	ctx context.Context,
+	installation models.PluginInstallation,
+	identity plugin_entities.PluginUniqueIdentifier,
+) (func(), error) {
+	if app.invocationLimiter == nil {
+		return func() {}, nil
+	}
+
+	declared := 0
+	if declaration, err := plugin_manager.Manager().GetDeclaration(
+		identity,
+		installation.TenantID,
+		plugin_entities.PluginRuntimeType(installation.RuntimeType),
+	); err == nil {
+		declared = declaration.Resource.MaxConcurrency
+	}
+
+	return app.invocationLimiter.Acquire(ctx, identity.String(), declared)
+}
+
 func (app *App) FetchPluginInstallation() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		pluginId := ctx.Request.Header.Get(constants.X_PLUGIN_ID)
@@ -41,22 +185,7 @@ func (app *App) FetchPluginInstallation() gin.HandlerFunc {
 			return
 		}
 
-		// fetch plugin installation with caching
-		cacheKey := helper.PluginInstallationCacheKey(pluginId, tenantId)
-		installation, err := cache.AutoGetWithGetter(
-			cacheKey,
-			func() (*models.PluginInstallation, error) {
-				inst, err := db.GetOne[models.PluginInstallation](
-					db.Equal("tenant_id", tenantId),
-					db.Equal("plugin_id", pluginId),
-				)
-				if err != nil {
-					return nil, err
-				}
-				return &inst, nil
-			},
-		)
-
+		installation, pinned, err := resolvePluginInstallation(tenantId, pluginId)
 		if err == db.ErrDatabaseNotFound {
 			ctx.AbortWithStatusJSON(404, exception.ErrPluginNotFound().ToResponse())
 			return
@@ -72,6 +201,9 @@ func (app *App) FetchPluginInstallation() gin.HandlerFunc {
 			ctx.AbortWithStatusJSON(400, exception.UniqueIdentifierError(err).ToResponse())
 			return
 		}
+		if !pinned {
+			identity = routePluginInvocation(ctx.Request, tenantId, identity)
+		}
 
 		ctx.Set(constants.CONTEXT_KEY_PLUGIN_INSTALLATION, *installation)
 		ctx.Set(constants.CONTEXT_KEY_PLUGIN_UNIQUE_IDENTIFIER, identity)
@@ -79,6 +211,91 @@ func (app *App) FetchPluginInstallation() gin.HandlerFunc {
 	}
 }
 
+// fetchPluginInstallation fetches plugin installation with caching
+func fetchPluginInstallation(tenantId string, pluginId string) (*models.PluginInstallation, error) {
+	cacheKey := helper.PluginInstallationCacheKey(pluginId, tenantId)
+	return cache.AutoGetWithGetter(
+		cacheKey,
+		func() (*models.PluginInstallation, error) {
+			inst, err := db.GetOne[models.PluginInstallation](
+				db.Equal("tenant_id", tenantId),
+				db.Equal("plugin_id", pluginId),
+			)
+			if err != nil {
+				return nil, err
+			}
+			return &inst, nil
+		},
+	)
+}
+
+// resolvePluginInstallation returns the installation an invocation runs on, a plugin id qualified with a
+// version like author/name:1.0.0 pins it to a version the tenant installed besides its installation
+func resolvePluginInstallation(tenantId string, qualifiedPluginId string) (*models.PluginInstallation, bool, error) {
+	pluginId, version := plugin_entities.SplitPluginVersion(qualifiedPluginId)
+	installation, err := fetchPluginInstallation(tenantId, pluginId)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if version == "" || plugin_entities.PluginUniqueIdentifier(installation.PluginUniqueIdentifier).Version() == version {
+		return installation, version != "", nil
+	}
+
+	versionInstallation, err := fetchPluginVersionInstallation(tenantId, pluginId, version.String())
+	if err != nil {
+		return nil, false, err
+	}
+
+	pinned := *installation
+	pinned.PluginUniqueIdentifier = versionInstallation.PluginUniqueIdentifier
+	pinned.RuntimeType = versionInstallation.RuntimeType
+	pinned.Meta = versionInstallation.Meta
+	return &pinned, true, nil
+}
+
+func fetchPluginVersionInstallation(
+	tenantId string,
+	pluginId string,
+	version string,
+) (*models.PluginVersionInstallation, error) {
+	cacheKey := helper.PluginVersionInstallationCacheKey(pluginId, version, tenantId)
+	return cache.AutoGetWithGetter(
+		cacheKey,
+		func() (*models.PluginVersionInstallation, error) {
+			versionInstallation, err := db.GetOne[models.PluginVersionInstallation](
+				db.Equal("tenant_id", tenantId),
+				db.Equal("plugin_id", pluginId),
+				db.Equal("version", version),
+			)
+			if err != nil {
+				return nil, err
+			}
+			return &versionInstallation, nil
+		},
+	)
+}
+
+// routePluginInvocation returns the version of a rollout the request runs on, the version picked by the
+// node the request was redirected from is kept so that both nodes agree
+func routePluginInvocation(
+	request *http.Request,
+	tenantId string,
+	identity plugin_entities.PluginUniqueIdentifier,
+) plugin_entities.PluginUniqueIdentifier {
+	if !plugin_rollout.Enabled() {
+		return identity
+	}
+
+	pinned := ""
+	if cluster.IsRedirectedRequest(request) {
+		pinned = request.Header.Get(constants.X_PLUGIN_UNIQUE_IDENTIFIER)
+	}
+	identity = plugin_rollout.Route(tenantId, identity, pinned)
+	request.Header.Set(constants.X_PLUGIN_UNIQUE_IDENTIFIER, identity.String())
+	return identity
+}
+
 // RedirectPluginInvoke redirects the request to the correct cluster node
 func (app *App) RedirectPluginInvoke() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
@@ -116,7 +333,16 @@ func (app *App) redirectPluginInvokeByPluginIdentifier(
 	plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
 	originalError error,
 ) {
-	// try find the correct node
+	// the node redirecting it believed the plugin runs here, redirecting again may loop
+	if cluster.IsRedirectedRequest(ctx.Request) {
+		ctx.AbortWithStatusJSON(
+			404,
+			exception.ErrPluginNotFound().ToResponse(),
+		)
+		return
+	}
+
+	// try find the correct node, the nodes the plugin is assigned to come first
 	nodes, err := app.cluster.FetchPluginAvailableNodesById(plugin_unique_identifier.String())
 	if err != nil {
 		ctx.AbortWithStatusJSON(
@@ -126,7 +352,11 @@ func (app *App) redirectPluginInvokeByPluginIdentifier(
 			).ToResponse(),
 		)
 		return
-	} else if len(nodes) == 0 {
+	}
+	nodes = slices.DeleteFunc(nodes, func(nodeId string) bool {
+		return nodeId == app.cluster.ID()
+	})
+	if len(nodes) == 0 {
 		ctx.AbortWithStatusJSON(
 			404,
 			exception.InternalServerError(
@@ -137,8 +367,12 @@ func (app *App) redirectPluginInvokeByPluginIdentifier(
 	}
 
 	// redirect to the correct node
-	nodeId := nodes[0]
-	statusCode, header, body, err := app.cluster.RedirectRequest(nodeId, ctx.Request)
+	app.redirectRequestToNodes(ctx, nodes)
+}
+
+// redirectRequestToNodes writes the response of the first node accepting the request
+func (app *App) redirectRequestToNodes(ctx *gin.Context, nodes []string) {
+	statusCode, header, body, err := app.cluster.RedirectRequestToNodes(nodes, ctx.Request)
 	if err != nil {
 		log.Error("redirect request failed: %s", err.Error())
 		ctx.AbortWithStatusJSON(
@@ -147,17 +381,18 @@ func (app *App) redirectPluginInvokeByPluginIdentifier(
 		)
 		return
 	}
+	defer body.Close()
 
-	// set status code
-	ctx.Writer.WriteHeader(statusCode)
-
-	// set header
+	// set header, it must be done before writing the status code
 	for key, values := range header {
 		for _, value := range values {
-			ctx.Writer.Header().Set(key, value)
+			ctx.Writer.Header().Add(key, value)
 		}
 	}
 
+	// set status code
+	ctx.Writer.WriteHeader(statusCode)
+
 	for {
 		buf := make([]byte, 1024)
 		n, err := body.Read(buf)
@@ -170,12 +405,32 @@ func (app *App) redirectPluginInvokeByPluginIdentifier(
 		}
 
 		if n > 0 {
-			ctx.Writer.Write(buf[:n])
+			// the caller is gone, closing the body cancels the request to the peer
+			if _, err := ctx.Writer.Write(buf[:n]); err != nil {
+				break
+			}
 			ctx.Writer.Flush()
 		}
 	}
 }
 
+// RedirectSessionCancel redirects the cancellation of a session to the node running it
+func (app *App) RedirectSessionCancel() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		record, err := session_manager.FetchSessionRecord(ctx.Param("session_id"))
+		if err != nil ||
+			record.Status != session_manager.SESSION_STATUS_RUNNING ||
+			record.NodeID == app.cluster.ID() ||
+			cluster.IsRedirectedRequest(ctx.Request) {
+			ctx.Next()
+			return
+		}
+
+		app.redirectRequestToNodes(ctx, []string{record.NodeID})
+		ctx.Abort()
+	}
+}
+
 func (app *App) InitClusterID() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		ctx.Set(constants.CONTEXT_KEY_CLUSTER_ID, app.cluster.ID())
@@ -183,13 +438,64 @@ func (app *App) InitClusterID() gin.HandlerFunc {
 	}
 }
 
-func (app *App) AdminAPIKey(key string) gin.HandlerFunc {
-	return func(ctx *gin.Context) {
-		if ctx.GetHeader(constants.X_ADMIN_API_KEY) != key {
-			ctx.AbortWithStatusJSON(401, gin.H{"message": "unauthorized"})
+// Authenticate resolves who the request is made by, principals limited to a tenant or an organization
+// are rejected on routes of others and routes without one
+func Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := access_control.AuthenticateConnection(requestCredential(c.Request.Header), c.Request.TLS)
+		if err == access_control.ErrUnauthorized {
+			c.AbortWithStatusJSON(401, exception.UnauthorizedError().ToResponse())
+			return
+		} else if err != nil {
+			c.AbortWithStatusJSON(500, exception.InternalServerError(err).ToResponse())
 			return
 		}
 
-		ctx.Next()
+		if !principal.CanAccessTenant(c.Param("tenant_id")) {
+			c.AbortWithStatusJSON(403, exception.PermissionDeniedError("tenant is not accessible").ToResponse())
+			return
+		}
+		if !principal.CanAccessOrganization(c.Param("organization")) {
+			c.AbortWithStatusJSON(403, exception.PermissionDeniedError("organization is not accessible").ToResponse())
+			return
+		}
+
+		c.Set(constants.CONTEXT_KEY_PRINCIPAL, principal)
+		c.Next()
+	}
+}
+
+// Authorize rejects principals without the permission, it must follow Authenticate
+func Authorize(permission access_control.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := requestPrincipal(c)
+		if !ok || !principal.Can(permission) {
+			c.AbortWithStatusJSON(403, exception.PermissionDeniedError(
+				fmt.Sprintf("permission %s is required", permission),
+			).ToResponse())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func requestPrincipal(c *gin.Context) (*access_control.Principal, bool) {
+	value, ok := c.Get(constants.CONTEXT_KEY_PRINCIPAL)
+	if !ok {
+		return nil, false
+	}
+	principal, ok := value.(*access_control.Principal)
+	return principal, ok
+}
+
+// requestCredential is the bearer token, otherwise the admin api key or the api key header
+func requestCredential(header http.Header) string {
+	if token, ok := strings.CutPrefix(header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	if key := header.Get(constants.X_ADMIN_API_KEY); key != "" {
+		return key
 	}
+	return header.Get(constants.X_API_KEY)
 }
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvePluginInstallation(t *testing.T) {
+	dbConfig := &app.Config{
+		DBType:       "sqlite",
+		DBSqlitePath: filepath.Join(t.TempDir(), "version.db"),
+	}
+	dbConfig.SetDefault()
+	db.Init(dbConfig)
+	t.Cleanup(db.Close)
+
+	require.NoError(t, cache.InitMemoryClient(0))
+	t.Cleanup(func() { cache.Close() })
+
+	const (
+		tenantId = "00000000-0000-0000-0000-000000000001"
+		current  = "langgenius/openai:0.0.2@0000000000000000000000000000000000000000000000000000000000000002"
+		pinned   = "langgenius/openai:0.0.1@0000000000000000000000000000000000000000000000000000000000000001"
+	)
+	require.NoError(t, db.Create(&models.PluginInstallation{
+		TenantID:               tenantId,
+		PluginID:               "langgenius/openai",
+		PluginUniqueIdentifier: current,
+		RuntimeType:            "local",
+	}))
+	require.NoError(t, db.Create(&models.PluginVersionInstallation{
+		TenantID:               tenantId,
+		PluginID:               "langgenius/openai",
+		Version:                "0.0.1",
+		PluginUniqueIdentifier: pinned,
+		RuntimeType:            "local",
+	}))
+
+	installation, isPinned, err := resolvePluginInstallation(tenantId, "langgenius/openai")
+	require.NoError(t, err)
+	assert.Equal(t, current, installation.PluginUniqueIdentifier)
+	assert.False(t, isPinned)
+
+	installation, isPinned, err = resolvePluginInstallation(tenantId, "langgenius/openai:0.0.2")
+	require.NoError(t, err)
+	assert.Equal(t, current, installation.PluginUniqueIdentifier)
+	assert.True(t, isPinned)
+
+	installation, isPinned, err = resolvePluginInstallation(tenantId, "langgenius/openai:0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, pinned, installation.PluginUniqueIdentifier)
+	assert.True(t, isPinned)
+
+	_, _, err = resolvePluginInstallation(tenantId, "langgenius/openai:0.0.3")
+	assert.ErrorIs(t, err, db.ErrDatabaseNotFound)
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/access_control"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryAccess(t *testing.T) {
+	access_control.Init(access_control.Config{ServerKey: "server-key", JWTSecret: "jwt-secret"})
+	t.Cleanup(func() { access_control.Init(access_control.Config{}) })
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	group := engine.Group("/registry/:organization", Authenticate())
+	group.GET("/plugins", Authorize(access_control.PERMISSION_REGISTRY_READ), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	group.POST("/plugins", Authorize(access_control.PERMISSION_REGISTRY_PUBLISH), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	engine.GET("/plugin/:tenant_id/list", Authenticate(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	token := func(roles []string, organization string) string {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub":          "ci",
+			"exp":          time.Now().Add(time.Hour).Unix(),
+			"roles":        roles,
+			"organization": organization,
+		}).SignedString([]byte("jwt-secret"))
+		require.NoError(t, err)
+		return signed
+	}
+	send := func(method string, path string, credential string) int {
+		request := httptest.NewRequest(method, path, nil)
+		request.Header.Set("Authorization", "Bearer "+credential)
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	publisher := token([]string{string(access_control.ROLE_PUBLISHER)}, "acme")
+	assert.Equal(t, http.StatusOK, send(http.MethodPost, "/registry/acme/plugins", publisher))
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "/registry/other/plugins", publisher))
+	assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "/registry/other/plugins", publisher))
+	assert.Equal(t, http.StatusForbidden, send(http.MethodGet, "/plugin/tenant/list", publisher))
+
+	operator := token([]string{string(access_control.ROLE_OPERATOR)}, "")
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/registry/other/plugins", operator))
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "/registry/other/plugins", operator))
+
+	// the server key shared with dify no longer publishes to the registry
+	assert.Equal(t, http.StatusForbidden, send(http.MethodPost, "/registry/acme/plugins", "server-key"))
+	assert.Equal(t, http.StatusUnauthorized, send(http.MethodGet, "/registry/acme/plugins", ""))
+}
@@ -0,0 +1,249 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+)
+
+// requestLimits bound the requests of a kind of route, zero disables a limit, the write timeout of
+// streaming routes bounds each write rather than the whole response
+type requestLimits struct {
+	maxBodySize       int64
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	streamIdleTimeout time.Duration
+	streaming         bool
+}
+
+func invokeLimits(config *app.Config) requestLimits {
+	return requestLimits{
+		maxBodySize:       config.ServerInvokeMaxBodySize,
+		readTimeout:       seconds(config.ServerInvokeReadTimeout),
+		writeTimeout:      seconds(config.ServerInvokeWriteTimeout),
+		streamIdleTimeout: seconds(config.ServerInvokeStreamIdleTimeout),
+		streaming:         true,
+	}
+}
+
+func installLimits(config *app.Config) requestLimits {
+	return requestLimits{
+		maxBodySize:  config.ServerInstallMaxBodySize,
+		readTimeout:  seconds(config.ServerInstallReadTimeout),
+		writeTimeout: seconds(config.ServerInstallWriteTimeout),
+	}
+}
+
+func endpointLimits(config *app.Config) requestLimits {
+	return requestLimits{
+		maxBodySize:       config.ServerEndpointMaxBodySize,
+		readTimeout:       seconds(config.ServerEndpointReadTimeout),
+		writeTimeout:      seconds(config.ServerEndpointWriteTimeout),
+		streamIdleTimeout: seconds(config.ServerEndpointStreamIdleTimeout),
+		streaming:         true,
+	}
+}
+
+func seconds(n int) time.Duration {
+	return time.Duration(n) * time.Second
+}
+
+var deadlinesNotSupported sync.Once
+
+// setDeadline applies a deadline of the connection, limits are silently lost if the writer can't
+// set deadlines, which is reported once rather than for every request
+func setDeadline(set func(time.Time) error, kind string, deadline time.Time) {
+	err := set(deadline)
+	switch {
+	case err == nil:
+	case errors.Is(err, http.ErrNotSupported):
+		deadlinesNotSupported.Do(func() {
+			log.Warn("request limits are not applied, the writer doesn't support %s deadlines", kind)
+		})
+	default:
+		log.Warn("failed to set %s deadline: %s", kind, err.Error())
+	}
+}
+
+// LimitRequests applies limits to the requests of a route, handlers failing to read a body over the size
+// or past the read timeout answer with 413 or 408 in place of their own error
+func LimitRequests(limits requestLimits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limits.maxBodySize > 0 && c.Request.ContentLength > limits.maxBodySize {
+			c.AbortWithStatusJSON(413, exception.RequestTooLargeError(limits.maxBodySize).ToResponse())
+			return
+		}
+
+		if limits.streamIdleTimeout > 0 {
+			c.Set(constants.CONTEXT_KEY_STREAM_IDLE_TIMEOUT, limits.streamIdleTimeout)
+		}
+
+		// deadlines outlive the request on keep-alive connections unless they are cleared
+		controller := http.NewResponseController(c.Writer)
+		var writer http.ResponseWriter = c.Writer
+		if unwrapper, ok := writer.(interface{ Unwrap() http.ResponseWriter }); ok {
+			writer = unwrapper.Unwrap()
+		}
+		if limits.writeTimeout > 0 {
+			setDeadline(controller.SetWriteDeadline, "write", time.Now().Add(limits.writeTimeout))
+			defer setDeadline(controller.SetWriteDeadline, "write", time.Time{})
+			if limits.streaming {
+				// a fixed deadline would cut streams which are still sending, it's moved along with every write
+				c.Writer = &streamDeadlineWriter{ResponseWriter: c.Writer, controller: controller, timeout: limits.writeTimeout}
+			}
+		}
+
+		if !hasBody(c.Request) || (limits.maxBodySize <= 0 && limits.readTimeout <= 0) {
+			c.Next()
+			return
+		}
+
+		body := &limitedBody{ReadCloser: c.Request.Body, controller: controller, limits: limits}
+		if limits.maxBodySize > 0 {
+			// net/http closes the connection rather than draining the rest of a body over the limit if
+			// it's given its own writer
+			body.ReadCloser = http.MaxBytesReader(writer, c.Request.Body, limits.maxBodySize)
+		}
+		if limits.readTimeout > 0 {
+			setDeadline(controller.SetReadDeadline, "read", time.Now().Add(limits.readTimeout))
+			defer func() {
+				// the expired deadline stops net/http from waiting for the rest of a body which timed out
+				if body.err == nil {
+					setDeadline(controller.SetReadDeadline, "read", time.Time{})
+				}
+			}()
+		}
+
+		c.Request.Body = body
+		c.Writer = &limitedBodyWriter{ResponseWriter: c.Writer, body: body}
+		c.Next()
+	}
+}
+
+// bodies of requests without one are not read, net/http watches their connection for the client going
+// away from the start, which a read deadline would cut short
+func hasBody(request *http.Request) bool {
+	return request.Body != nil && request.Body != http.NoBody && request.ContentLength != 0
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	controller *http.ResponseController
+	limits     requestLimits
+
+	status int
+	err    exception.PluginDaemonError
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	var tooLarge *http.MaxBytesError
+	var netErr net.Error
+	switch {
+	case err == nil:
+	case err == io.EOF:
+		// net/http reads the connection from now on to notice the client going away
+		setDeadline(b.controller.SetReadDeadline, "read", time.Time{})
+	case errors.As(err, &tooLarge):
+		b.status, b.err = 413, exception.RequestTooLargeError(b.limits.maxBodySize)
+	case errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		b.status, b.err = 408, exception.RequestTimeoutError("request body was not received in time")
+	}
+	return n, err
+}
+
+// limitedBodyWriter writes the error of the body instead of the response of the handler, unless the
+// handler responded before the body failed
+type limitedBodyWriter struct {
+	gin.ResponseWriter
+	body     *limitedBody
+	replaced bool
+}
+
+func (w *limitedBodyWriter) intercept() bool {
+	if w.replaced {
+		return true
+	}
+	if w.body.err == nil || w.ResponseWriter.Written() {
+		return false
+	}
+
+	w.replaced = true
+	w.ResponseWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.ResponseWriter.WriteHeader(w.body.status)
+	w.ResponseWriter.Write(parser.MarshalJsonBytes(w.body.err.ToResponse()))
+	return true
+}
+
+func (w *limitedBodyWriter) WriteHeader(code int) {
+	if !w.intercept() {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *limitedBodyWriter) WriteHeaderNow() {
+	if !w.intercept() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *limitedBodyWriter) Write(data []byte) (int, error) {
+	if w.intercept() {
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *limitedBodyWriter) WriteString(s string) (int, error) {
+	if w.intercept() {
+		return len(s), nil
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *limitedBodyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// streamDeadlineWriter gives every write of a stream the write timeout, so that it only bounds a
+// caller which stopped reading and not how long the stream lasts
+type streamDeadlineWriter struct {
+	gin.ResponseWriter
+	controller *http.ResponseController
+	timeout    time.Duration
+}
+
+func (w *streamDeadlineWriter) extend() {
+	setDeadline(w.controller.SetWriteDeadline, "write", time.Now().Add(w.timeout))
+}
+
+func (w *streamDeadlineWriter) Write(data []byte) (int, error) {
+	w.extend()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *streamDeadlineWriter) WriteString(s string) (int, error) {
+	w.extend()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *streamDeadlineWriter) Flush() {
+	w.extend()
+	w.ResponseWriter.Flush()
+}
+
+func (w *streamDeadlineWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/upload", LimitRequests(requestLimits{maxBodySize: 16, readTimeout: 200 * time.Millisecond}), func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, exception.BadRequestError(errors.New("failed to read body")).ToResponse())
+			return
+		}
+		c.String(http.StatusOK, string(body))
+	})
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+
+	responseError := func(response *http.Response) string {
+		defer response.Body.Close()
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		result, err := parser.UnmarshalJsonBytes[entities.Response](body)
+		require.NoError(t, err)
+		return result.Message
+	}
+
+	response, err := http.Post(server.URL+"/upload", "text/plain", strings.NewReader("small"))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	response.Body.Close()
+
+	// rejected by the content length before the handler runs
+	response, err = http.Post(server.URL+"/upload", "text/plain", strings.NewReader(strings.Repeat("x", 17)))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, response.StatusCode)
+	assert.Contains(t, responseError(response), "exceeds 16 bytes")
+
+	// a chunked body has no length, the error of the handler is replaced once it reads too much
+	response, err = http.Post(server.URL+"/upload", "text/plain", io.MultiReader(strings.NewReader(strings.Repeat("x", 32))))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, response.StatusCode)
+	assert.Contains(t, responseError(response), "exceeds 16 bytes")
+
+	// a body which doesn't arrive within the read timeout
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	require.NoError(t, err)
+	defer conn.Close()
+	fmt.Fprint(conn, "POST /upload HTTP/1.1\r\nHost: test\r\nContent-Length: 10\r\n\r\nslow")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	response, err = http.ReadResponse(bufio.NewReader(conn), nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusRequestTimeout, response.StatusCode)
+	assert.Contains(t, responseError(response), "not received in time")
+}
+
+func TestLimitRequestsStreamOutlastsWriteTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/stream", LimitRequests(requestLimits{writeTimeout: 200 * time.Millisecond, streaming: true}), func(c *gin.Context) {
+		for i := 0; i < 5; i++ {
+			fmt.Fprintf(c.Writer, "data: %d\n\n", i)
+			c.Writer.Flush()
+			time.Sleep(100 * time.Millisecond)
+		}
+	})
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+
+	// the stream lasts longer than the write timeout, which only bounds each of its writes
+	response, err := http.Get(server.URL + "/stream")
+	require.NoError(t, err)
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Equal(t, 5, strings.Count(string(body), "data: "))
+}
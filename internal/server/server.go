@@ -1,27 +1,57 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"path/filepath"
+	"time"
+
 	"github.com/getsentry/sentry-go"
-	"github.com/langgenius/dify-cloud-kit/oss"
-	"github.com/langgenius/dify-cloud-kit/oss/factory"
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
 	"github.com/langgenius/dify-plugin-daemon/internal/cluster"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/access_control"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/analytics"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/anomaly"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/audit"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/endpoint_domains"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/event_bus"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/invocation_cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/invocation_limit"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/marketplace_mirror"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/oauth_credentials"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/output_schema"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/persistence"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/egress"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_registry"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_rollout"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/rate_limit"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/retention"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/tenant_variables"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/tool_files"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/oss"
+	"github.com/langgenius/dify-plugin-daemon/internal/service"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/kms"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/mtls"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/schedule"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
 )
 
-func initOSS(config *app.Config) oss.OSS {
+func initOSS(config *app.Config) oss.StreamingOSS {
 	// init storage
-	var storage oss.OSS
+	var storage oss.StreamingOSS
 	var err error
-	storage, err = factory.Load(config.PluginStorageType, oss.OSSArgs{
-		Local: &oss.Local{
+	storage, err = oss.Load(config.PluginStorageType, cloudoss.OSSArgs{
+		Local: &cloudoss.Local{
 			Path: config.PluginStorageLocalRoot,
 		},
-		S3: &oss.S3{
+		S3: &cloudoss.S3{
 			UseAws:       config.S3UseAWS,
 			Endpoint:     config.S3Endpoint,
 			UsePathStyle: config.S3UsePathStyle,
@@ -31,21 +61,21 @@ func initOSS(config *app.Config) oss.OSS {
 			Region:       config.AWSRegion,
 			UseIamRole:   config.S3UseAwsManagedIam,
 		},
-		TencentCOS: &oss.TencentCOS{
+		TencentCOS: &cloudoss.TencentCOS{
 			Region:    config.TencentCOSRegion,
 			SecretID:  config.TencentCOSSecretId,
 			SecretKey: config.TencentCOSSecretKey,
 			Bucket:    config.PluginStorageOSSBucket,
 		},
-		AzureBlob: &oss.AzureBlob{
+		AzureBlob: &cloudoss.AzureBlob{
 			ConnectionString: config.AzureBlobStorageConnectionString,
 			ContainerName:    config.AzureBlobStorageContainerName,
 		},
-		GoogleCloudStorage: &oss.GoogleCloudStorage{
+		GoogleCloudStorage: &cloudoss.GoogleCloudStorage{
 			Bucket:         config.PluginStorageOSSBucket,
 			CredentialsB64: config.GoogleCloudStorageCredentialsB64,
 		},
-		AliyunOSS: &oss.AliyunOSS{
+		AliyunOSS: &cloudoss.AliyunOSS{
 			Region:      config.AliyunOSSRegion,
 			Endpoint:    config.AliyunOSSEndpoint,
 			AccessKey:   config.AliyunOSSAccessKeyID,
@@ -54,19 +84,40 @@ func initOSS(config *app.Config) oss.OSS {
 			Path:        config.AliyunOSSPath,
 			Bucket:      config.PluginStorageOSSBucket,
 		},
-		HuaweiOBS: &oss.HuaweiOBS{
+		HuaweiOBS: &cloudoss.HuaweiOBS{
 			AccessKey: config.HuaweiOBSAccessKey,
 			SecretKey: config.HuaweiOBSSecretKey,
 			Server:    config.HuaweiOBSServer,
 			Bucket:    config.PluginStorageOSSBucket,
 		},
-		VolcengineTOS: &oss.VolcengineTOS{
+		VolcengineTOS: &cloudoss.VolcengineTOS{
 			Region:    config.VolcengineTOSRegion,
 			Endpoint:  config.VolcengineTOSEndpoint,
 			AccessKey: config.VolcengineTOSAccessKey,
 			SecretKey: config.VolcengineTOSSecretKey,
 			Bucket:    config.PluginStorageOSSBucket,
 		},
+	}, oss.Options{
+		S3: oss.S3Options{
+			DisableChecksum:    config.S3DisableChecksum,
+			MultipartThreshold: config.S3MultipartThreshold,
+			PartSize:           config.S3MultipartPartSize,
+			Concurrency:        config.S3MultipartConcurrency,
+		},
+		NFS: oss.NFSOptions{
+			MountPath: config.NFSMountPath,
+		},
+		SFTP: oss.SFTPOptions{
+			Host:                  config.SFTPHost,
+			Port:                  config.SFTPPort,
+			Username:              config.SFTPUsername,
+			Password:              config.SFTPPassword,
+			PrivateKeyPath:        config.SFTPPrivateKeyPath,
+			PrivateKeyPassphrase:  config.SFTPPrivateKeyPassphrase,
+			KnownHostsPath:        config.SFTPKnownHostsPath,
+			InsecureIgnoreHostKey: config.SFTPInsecureIgnoreHostKey,
+			Root:                  config.SFTPRoot,
+		},
 	})
 	if err != nil {
 		log.Panic("Failed to create storage: %s", err)
@@ -75,7 +126,175 @@ func initOSS(config *app.Config) oss.OSS {
 	return storage
 }
 
+func probeStorage(storage oss.StreamingOSS, config *app.Config) {
+	oss.StartProbe(storage, oss.ProbeConfig{
+		Interval:         time.Duration(config.StorageProbeInterval) * time.Second,
+		LatencyThreshold: time.Duration(config.StorageProbeLatencyThresholdMs) * time.Millisecond,
+		ErrorThreshold:   config.StorageProbeErrorThreshold,
+	})
+}
+
+func initKMS(config *app.Config) {
+	if config.KMSProvider == "" {
+		return
+	}
+
+	region := config.KMSAWSRegion
+	if region == "" {
+		region = config.AWSRegion
+	}
+
+	provider, err := kms.NewProvider(kms.Config{
+		Provider:          config.KMSProvider,
+		LocalMasterKey:    config.KMSLocalMasterKey,
+		AWSKeyID:          config.KMSAWSKeyID,
+		AWSRegion:         region,
+		AWSEndpoint:       config.KMSAWSEndpoint,
+		GCPKeyName:        config.KMSGCPKeyName,
+		VaultAddress:      config.KMSVaultAddress,
+		VaultToken:        config.KMSVaultToken,
+		VaultTransitMount: config.KMSVaultTransitMount,
+		VaultKeyName:      config.KMSVaultKeyName,
+	})
+	if err != nil {
+		log.Panic("failed to init kms: %s", err.Error())
+	}
+	kms.Init(provider)
+}
+
+func newRateLimiter(config *app.Config) *rate_limit.Limiter {
+	overrides, err := rate_limit.ParseTenantOverrides(config.RateLimitTenantOverrides)
+	if err != nil {
+		log.Panic("failed to parse rate limit overrides: %s", err.Error())
+	}
+
+	limiter := rate_limit.NewLimiter(rate_limit.Config{
+		Global:          rate_limit.Limit{Rate: config.RateLimitGlobalRate, Burst: config.RateLimitGlobalBurst},
+		Tenant:          rate_limit.Limit{Rate: config.RateLimitTenantRate, Burst: config.RateLimitTenantBurst},
+		Plugin:          rate_limit.Limit{Rate: config.RateLimitPluginRate, Burst: config.RateLimitPluginBurst},
+		TenantOverrides: overrides,
+	})
+	if !limiter.Enabled() {
+		return nil
+	}
+	return limiter
+}
+
+func detectAnomaly(config *app.Config) {
+	notifiers := []anomaly.Notifier{}
+	if config.AnomalyAlertWebhookURL != "" {
+		notifiers = append(notifiers, anomaly.NewWebhookNotifier(config.AnomalyAlertWebhookURL))
+	}
+
+	anomaly.Start(anomaly.Config{
+		Window:          time.Duration(config.AnomalyDetectionWindow) * time.Second,
+		WarmupWindows:   config.AnomalyDetectionWarmupWindows,
+		Factor:          config.AnomalyDetectionFactor,
+		MinCount:        float64(config.AnomalyDetectionMinCount),
+		MinPayloadBytes: float64(config.AnomalyDetectionMinPayloadBytes),
+		Cooldown:        time.Duration(config.AnomalyAlertCooldown) * time.Second,
+	}, notifiers...)
+}
+
+func publishEvents(config *app.Config) {
+	publishers := []event_bus.Publisher{}
+	if config.EventBusWebhookURL != "" {
+		publishers = append(publishers, event_bus.NewWebhookPublisher(config.EventBusWebhookURL))
+	}
+	if config.EventBusNatsURL != "" {
+		publisher, err := event_bus.NewNatsPublisher(config.EventBusNatsURL, config.EventBusNatsSubject, event_bus.NatsOptions{
+			CredentialsFile: config.EventBusNatsCredentialsFile,
+			NkeySeedFile:    config.EventBusNatsNkeySeedFile,
+			TLSCAFile:       config.EventBusNatsTLSCAFile,
+			TLSCertFile:     config.EventBusNatsTLSCertFile,
+			TLSKeyFile:      config.EventBusNatsTLSKeyFile,
+		})
+		if err != nil {
+			log.Panic("failed to init nats publisher: %s", err.Error())
+		}
+		publishers = append(publishers, publisher)
+	}
+	if config.EventBusKafkaRestURL != "" {
+		publishers = append(publishers, event_bus.NewKafkaRestPublisher(config.EventBusKafkaRestURL, config.EventBusKafkaTopic))
+	}
+
+	if len(publishers) > 0 {
+		event_bus.Start(config.EventBusEvents, publishers...)
+	}
+}
+
+// serverTLSConfig returns nil without server certificate, nodes verify each other against the peer ca
+// and server name, or only accept their own certificate without peer ca, never the roots of the system
+func serverTLSConfig(config *app.Config) *tls.Config {
+	if config.ServerTLSCertFile == "" {
+		return nil
+	}
+
+	loader, err := mtls.NewLoader(mtls.Files{
+		CertFile: config.ServerTLSCertFile,
+		KeyFile:  config.ServerTLSKeyFile,
+		CAFile:   config.ServerTLSClientCAFile,
+	})
+	if err != nil {
+		log.Panic("failed to load server tls files: %s", err.Error())
+	}
+
+	peerFiles := mtls.Files{
+		CertFile: config.ServerTLSCertFile,
+		KeyFile:  config.ServerTLSKeyFile,
+		CAFile:   config.ServerTLSPeerCAFile,
+	}
+	if config.ServerTLSPeerCertFile != "" {
+		peerFiles.CertFile = config.ServerTLSPeerCertFile
+		peerFiles.KeyFile = config.ServerTLSPeerKeyFile
+	}
+	peerLoader, err := mtls.NewLoader(peerFiles)
+	if err != nil {
+		log.Panic("failed to load server tls peer files: %s", err.Error())
+	}
+	if config.ServerTLSPeerCAFile != "" {
+		cluster.UsePeerTLS(peerLoader.ClientConfig(config.ServerTLSPeerServerName))
+	} else {
+		cluster.UsePeerTLS(peerLoader.PinnedClientConfig(loader))
+	}
+
+	return loader.ServerConfig(config.ServerTLSClientAuth == app.SERVER_TLS_CLIENT_AUTH_OPTIONAL)
+}
+
+// parseSchedule returns the schedule of a job configured by a cron expression which overrides its interval
+func parseSchedule(name string, expression string, interval time.Duration) schedule.Schedule {
+	s, err := schedule.ParseOrEvery(expression, interval)
+	if err != nil {
+		log.Panic("failed to parse %s schedule: %s", name, err.Error())
+	}
+	return s
+}
+
+func pruneRecords(config *app.Config) {
+	pruneSchedule := parseSchedule(
+		"retention prune",
+		config.RetentionPruneSchedule,
+		time.Duration(config.RetentionPruneInterval)*time.Second,
+	)
+
+	day := 24 * time.Hour
+	retention.Start(map[string]time.Duration{
+		retention.CLASS_AUDIT_RECORDS:        time.Duration(config.RetentionAuditRecordsDays) * day,
+		retention.CLASS_INVOCATION_ANALYTICS: time.Duration(config.RetentionInvocationAnalyticsDays) * day,
+		retention.CLASS_SESSION_RECORDINGS:   time.Duration(config.RetentionSessionRecordingsDays) * day,
+		retention.CLASS_INSTALL_TASKS:        time.Duration(config.RetentionInstallTasksDays) * day,
+	}, pruneSchedule)
+}
+
 func (app *App) Run(config *app.Config) {
+	// init logger
+	if err := log.SetFormat(config.LogFormat); err != nil {
+		log.Panic("failed to set log format: %s", err.Error())
+	}
+	if err := log.SetPluginLogDir(config.LogPluginDir); err != nil {
+		log.Panic("failed to set plugin log dir: %s", err.Error())
+	}
+
 	// init routine pool
 	if config.SentryEnabled {
 		routine.InitPool(config.RoutinePoolSize, sentry.ClientOptions{
@@ -89,33 +308,221 @@ func (app *App) Run(config *app.Config) {
 		routine.InitPool(config.RoutinePoolSize)
 	}
 
+	// init tracing
+	if config.OtelEnabled {
+		shutdownTracing, err := tracing.Init(tracing.TracingConfig{
+			ServiceName: config.OtelServiceName,
+			Endpoint:    config.OtelExporterEndpoint,
+			SampleRate:  config.OtelTracesSampleRate,
+		})
+		if err != nil {
+			log.Panic("Failed to init tracing: %s", err)
+		}
+		app.shutdownTracing = shutdownTracing
+	}
+
 	// init db
 	db.Init(config)
 
+	// secrets referenced in endpoint settings and credentials
+	initKMS(config)
+	tenant_variables.Init(config.TenantVariablesEncryptionKey)
+	oauth_credentials.Init(config.OAuthCredentialsEncryptionKey)
+
+	endpoint_domains.Init(config.EndpointCustomDomains)
+	output_schema.Init(output_schema.Policy(config.ToolOutputSchemaPolicy))
+	service.SetToolParameterCoercion(config.ToolParameterCoercionEnabled)
+
 	// init oss
 	oss := initOSS(config)
 
+	tool_files.Init(oss, tool_files.Config{
+		URLBase:       config.ToolFileURLBase,
+		SigningKey:    config.ToolFileSigningKey,
+		Path:          config.ToolFileStoragePath,
+		MaxSize:       config.ToolFileMaxSize,
+		TTL:           time.Duration(config.ToolFileTTL) * time.Second,
+		DifyFilesURL:  config.ToolFileDifyFilesURL,
+		DifySecretKey: config.ToolFileDifySecretKey,
+	})
+	if tool_files.Enabled() {
+		tool_files.StartExpirySweeper(parseSchedule(
+			"tool file expiry sweep",
+			config.ToolFileExpirySweepSchedule,
+			tool_files.EXPIRY_SWEEP_INTERVAL,
+		))
+	}
+
+	if config.MarketplaceMirrorEnabled {
+		marketplace_mirror.Init(oss, marketplace_mirror.Config{
+			UpstreamURL:  config.MarketplaceURL,
+			APIKey:       config.MarketplaceAPIKey,
+			Path:         config.MarketplaceMirrorPath,
+			DownloadPath: filepath.Join(config.PluginPackageCachePath, "mirror"),
+			Offline:      config.MarketplaceMirrorOffline,
+			Concurrency:  config.PluginDownloadConcurrency,
+			ChunkSize:    config.PluginDownloadChunkSize,
+			MaxSize:      config.MaxPluginPackageSize,
+		})
+	}
+
+	if config.PluginRegistryEnabled {
+		plugin_registry.Init(oss, plugin_registry.Config{
+			Path:             config.PluginRegistryPath,
+			RequireSignature: config.PluginRegistryRequireSignature,
+			Verification: &decoder.ThirdPartySignatureVerificationConfig{
+				Enabled:        config.ThirdPartySignatureVerificationEnabled,
+				PublicKeyPaths: config.ThirdPartySignatureVerificationPublicKeys,
+				KeyringPath:    config.ThirdPartySignatureVerificationKeyring,
+			},
+			MaxSize: config.MaxPluginPackageSize,
+		})
+	}
+
+	// watch storage health
+	if config.StorageProbeEnabled {
+		probeStorage(oss, config)
+	}
+
+	// learn plugin behavior baselines
+	if config.AnomalyDetectionEnabled {
+		detectAnomaly(config)
+	}
+
+	// restrict outbound traffic of local plugins
+	if config.PluginEgressPolicyEnabled {
+		if err := egress.Start(egress.Config{
+			Address:      config.PluginEgressProxyAddress,
+			Allowed:      config.PluginEgressAllowed,
+			Unrestricted: config.PluginEgressUnrestrictedPlugins,
+		}); err != nil {
+			log.Panic("failed to start egress proxy: %s", err.Error())
+		}
+	}
+
+	// timezone of schedules
+	if err := schedule.SetDefaultTimezone(config.ScheduleTimezone); err != nil {
+		log.Panic("failed to set schedule timezone: %s", err.Error())
+	}
+
+	// map credentials of callers to roles
+	adminKey := ""
+	if config.AdminApiEnabled {
+		adminKey = config.AdminApiKey
+	}
+	access_control.Init(access_control.Config{
+		ServerKey:                config.ServerKey,
+		AdminKey:                 adminKey,
+		JWTSecret:                config.ApiJwtSecret,
+		RequireClientCertificate: config.ServerTLSClientCAFile != "",
+		ClientCertificateAuth:    config.ServerTLSClientCertAuthEnabled,
+	})
+
+	// serve tls and verify client certificates
+	app.tlsConfig = serverTLSConfig(config)
+
+	// record administrative and debugging events
+	if config.AuditLogEnabled {
+		audit.Start()
+	}
+
+	// publish lifecycle events to external systems
+	publishEvents(config)
+
+	// purge records out of their retention windows
+	pruneRecords(config)
+
 	// create manager
 	manager := plugin_manager.InitGlobalManager(oss, config)
 
 	// create cluster
 	app.cluster = cluster.NewCluster(config, manager)
 
+	// limit requests of tenants and plugins
+	app.rateLimiter = newRateLimiter(config)
+
+	// bound the concurrent invocations of each plugin
+	app.invocationLimiter = invocation_limit.NewLimiter(invocation_limit.Config{
+		MaxConcurrency: config.PluginMaxConcurrency,
+		QueueSize:      config.PluginInvocationQueueSize,
+		QueueTimeout:   time.Duration(config.PluginInvocationQueueTimeout) * time.Second,
+	})
+
 	// register plugin lifetime event
 	manager.AddPluginRegisterHandler(app.cluster.RegisterPlugin)
 
+	// run each local plugin only on the nodes it's assigned to
+	if config.PluginAssignmentEnabled {
+		manager.SetLocalPluginAssignment(app.cluster)
+	}
+
+	// register install task handlers before resuming tasks
+	service.RegisterInstallTaskHandlers(config, manager.InstallQueue())
+
+	// start the upgrades tenants scheduled for their maintenance windows
+	service.StartScheduledPluginUpgrades(config)
+
+	// route invocations to canaries of plugins, the master of the cluster decides on them
+	if config.PluginRolloutEnabled {
+		plugin_rollout.Start(plugin_rollout.Config{
+			SyncSchedule: parseSchedule(
+				"plugin rollout sync",
+				config.PluginRolloutSyncSchedule,
+				time.Duration(config.PluginRolloutSyncInterval)*time.Second,
+			),
+			EvaluateSchedule: parseSchedule(
+				"plugin rollout evaluate",
+				config.PluginRolloutEvaluateSchedule,
+				time.Duration(config.PluginRolloutEvaluateInterval)*time.Second,
+			),
+			IsLeader:     app.cluster.IsMaster,
+			OnPromoted:   service.OnPluginRolloutPromoted,
+			OnRolledBack: service.OnPluginRolloutRolledBack,
+		})
+	}
+
 	// init manager
 	manager.Launch(config)
 
+	// record sessions so that those orphaned by a crash are failed
+	session_manager.InitPersistence(app.cluster.ID())
+
 	// init persistence
 	persistence.InitPersistence(oss, config)
+	persistence.StartExpirySweeper(parseSchedule(
+		"persistence expiry sweep",
+		config.PersistenceExpirySweepSchedule,
+		persistence.EXPIRY_SWEEP_INTERVAL,
+	))
+
+	invocation_cache.Init(invocation_cache.Config{
+		Enabled: config.BackwardsInvocationCacheEnabled,
+		TTL:     time.Duration(config.BackwardsInvocationCacheTTL) * time.Second,
+	})
+
+	// persist and roll up invocation counts
+	if config.AnalyticsEnabled {
+		analytics.Start(analytics.Config{
+			FlushInterval: time.Duration(config.AnalyticsFlushInterval) * time.Second,
+			RollupSchedule: parseSchedule(
+				"analytics rollup",
+				config.AnalyticsRollupSchedule,
+				time.Duration(config.AnalyticsRollupInterval)*time.Second,
+			),
+		})
+	}
 
 	// launch cluster
 	app.cluster.Launch()
 
 	// start http server
-	app.server(config)
+	stopServers := []func(ctx context.Context){app.server(config)}
+
+	// start grpc server
+	if config.GrpcEnabled {
+		stopServers = append(stopServers, app.grpcServer(config))
+	}
 
-	// block
-	select {}
+	// block until terminated
+	app.waitForShutdown(config, manager, stopServers...)
 }
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/analytics"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/controllers"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+const (
+	SHUTDOWN_DRAIN_POLL = 200 * time.Millisecond
+	// SHUTDOWN_TERMINATE_TIMEOUT is how long servers and plugins get to stop once draining is over
+	SHUTDOWN_TERMINATE_TIMEOUT = 5 * time.Second
+	// SHUTDOWN_TRACING_TIMEOUT is how long buffered spans get to be exported
+	SHUTDOWN_TRACING_TIMEOUT = 5 * time.Second
+)
+
+// waitForShutdown blocks until SIGINT or SIGTERM, a second signal kills the daemon immediately
+func (app *App) waitForShutdown(
+	config *app.Config,
+	manager *plugin_manager.PluginManager,
+	stopServers ...func(ctx context.Context),
+) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-signals
+	signal.Stop(signals)
+
+	log.Info("received %s, draining for at most %ds", sig, config.ShutdownDrainTimeout)
+	app.shutdown(config, manager, stopServers...)
+}
+
+// shutdown rejects new invocations, waits for active sessions and installs until the drain
+// timeout and then stops servers and plugins
+func (app *App) shutdown(
+	config *app.Config,
+	manager *plugin_manager.PluginManager,
+	stopServers ...func(ctx context.Context),
+) {
+	drainCtx, cancel := context.WithTimeout(context.Background(), time.Duration(config.ShutdownDrainTimeout)*time.Second)
+	defer cancel()
+
+	controllers.StartDraining()
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		manager.InstallQueue().Shutdown(drainCtx)
+	}()
+	if sessions := waitForSessions(drainCtx); sessions > 0 {
+		log.Warn("%d sessions are still active after draining, they will be interrupted", sessions)
+		session_manager.InterruptSessions("the plugin daemon was shut down before the session finished")
+	}
+	wg.Wait()
+
+	terminateCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TERMINATE_TIMEOUT)
+	defer cancel()
+
+	for _, stop := range stopServers {
+		stop(terminateCtx)
+	}
+
+	manager.Shutdown(terminateCtx)
+
+	// invocations counted since the last flush would be lost otherwise
+	if analytics.Enabled() {
+		if err := analytics.Flush(); err != nil {
+			log.Error("failed to flush invocation counts: %s", err.Error())
+		}
+	}
+
+	stopped := app.cluster.NotifyClusterStopped()
+	app.cluster.Close()
+	select {
+	case <-stopped:
+	case <-terminateCtx.Done():
+	}
+
+	// spans are recorded until everything stopped, they are exported last
+	if app.shutdownTracing != nil {
+		tracingCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TRACING_TIMEOUT)
+		defer cancel()
+		if err := app.shutdownTracing(tracingCtx); err != nil {
+			log.Error("failed to flush traces: %s", err.Error())
+		}
+	}
+
+	log.Info("plugin daemon stopped")
+}
+
+// waitForSessions returns the number of sessions and dispatch requests still active when they
+// are all done or ctx is done
+func waitForSessions(ctx context.Context) int {
+	ticker := time.NewTicker(SHUTDOWN_DRAIN_POLL)
+	defer ticker.Stop()
+
+	for {
+		active := int(controllers.ActiveDispatchRequests())
+		for _, count := range session_manager.CountSessions() {
+			active += count
+		}
+		if active == 0 {
+			return 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return active
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,404 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/cluster"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/invocation_stats"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	INSTALLATION_SORT_INSTALLED_AT    = "installed_at"
+	INSTALLATION_SORT_LAST_INVOKED_AT = "last_invoked_at"
+
+	// INSTALLATION_STATUS_NOT_RUNNING is the status of an installation without runtime on any node
+	INSTALLATION_STATUS_NOT_RUNNING = "not_running"
+	// INSTALLATION_STATUS_SERVERLESS is the status of serverless installations, their runtimes are not
+	// managed by the daemon
+	INSTALLATION_STATUS_SERVERLESS = "serverless"
+)
+
+// PluginInstallationQuery selects installations across tenants, zero fields match everything
+type PluginInstallationQuery struct {
+	TenantID    string
+	PluginID    string
+	RuntimeType string
+	Source      string
+	Status      string
+	Category    string
+	ModelType   string
+	SortBy      string
+	Descending  bool
+	Page        int
+	PageSize    int
+}
+
+type PluginInstallationHealth struct {
+	Status string `json:"status"`
+	// Runtime is the runtime of current node
+	Runtime *plugin_manager.RuntimeStatus `json:"runtime"`
+	// Nodes are the states reported by every node running the plugin
+	Nodes []cluster.PluginNodeState `json:"nodes"`
+}
+
+type AdminPluginInstallation struct {
+	models.PluginInstallation
+	Name          string                         `json:"name"`
+	Version       string                         `json:"version"`
+	Category      plugin_entities.PluginCategory `json:"category"`
+	ModelTypes    []plugin_entities.ModelType    `json:"model_types"`
+	LastInvokedAt *time.Time                     `json:"last_invoked_at"`
+	Health        PluginInstallationHealth       `json:"health"`
+}
+
+type AdminPluginInstallationDetail struct {
+	AdminPluginInstallation
+	Declaration *plugin_entities.PluginDeclaration `json:"declaration"`
+}
+
+// ListAllPluginInstallations lists the installations of all tenants, only the listed page is joined with
+// declarations and health, the health is aggregated across the nodes of the cluster.
+// category and model type are matched against stored declarations, remote debugging plugins don't match
+// them, sorting by last invocation is only supported within a tenant
+func ListAllPluginInstallations(c *cluster.Cluster, query PluginInstallationQuery) *entities.Response {
+	type responseData struct {
+		List  []AdminPluginInstallation `json:"list"`
+		Total int64                     `json:"total"`
+	}
+
+	if query.SortBy == INSTALLATION_SORT_LAST_INVOKED_AT && query.TenantID == "" {
+		return exception.BadRequestError(errors.New("sorting by last invocation requires tenant_id")).ToResponse()
+	}
+
+	health, err := loadPluginHealth(c)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	queries := append(query.conditions(), health.conditions(query.Status)...)
+	total, err := db.GetCount[models.PluginInstallation](queries...)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	var installations []models.PluginInstallation
+	if query.SortBy == INSTALLATION_SORT_LAST_INVOKED_AT {
+		installations, err = pageByLastInvocation(query, queries)
+	} else {
+		installations, err = db.GetAll[models.PluginInstallation](append(
+			queries,
+			db.OrderBy("created_at", query.Descending),
+			db.Page(query.Page, query.PageSize),
+		)...)
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	list, err := describePluginInstallations(installations, health)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(responseData{
+		List:  list,
+		Total: total,
+	})
+}
+
+// conditions translates the query into sql conditions, except for the status
+func (q PluginInstallationQuery) conditions() []db.GenericQuery {
+	queries := []db.GenericQuery{}
+	if q.TenantID != "" {
+		queries = append(queries, db.Equal("tenant_id", q.TenantID))
+	}
+	if q.PluginID != "" {
+		queries = append(queries, db.Equal("plugin_id", q.PluginID))
+	}
+	if q.RuntimeType != "" {
+		queries = append(queries, db.Equal("runtime_type", q.RuntimeType))
+	}
+	if q.Source != "" {
+		queries = append(queries, db.Equal("source", q.Source))
+	}
+	if q.Category != "" {
+		queries = append(queries, db.WhereSQL(
+			"plugin_unique_identifier IN (SELECT plugin_unique_identifier FROM plugin_declarations WHERE category = ?)",
+			q.Category,
+		))
+	}
+	if q.ModelType != "" {
+		// ! escapes the wildcards, backslash is not the default escape character of every database
+		modelType := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(q.ModelType)
+		queries = append(queries, db.WhereSQL(
+			"plugin_unique_identifier IN (SELECT plugin_unique_identifier FROM plugin_declarations WHERE model_types LIKE ? ESCAPE '!')",
+			"%,"+modelType+",%",
+		))
+	}
+	return queries
+}
+
+// pageByLastInvocation loads the matching installations of a tenant and returns the asked page of them
+// ordered by last invocation
+func pageByLastInvocation(query PluginInstallationQuery, queries []db.GenericQuery) ([]models.PluginInstallation, error) {
+	installations, err := db.GetAll[models.PluginInstallation](append(queries, db.OrderBy("created_at", query.Descending))...)
+	if err != nil {
+		return nil, err
+	}
+
+	lastInvokedAt, err := invocation_stats.TenantLastInvokedAt(query.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]AdminPluginInstallation, 0, len(installations))
+	for _, installation := range installations {
+		described := AdminPluginInstallation{PluginInstallation: installation}
+		if t, ok := lastInvokedAt[installation.PluginID]; ok {
+			described.LastInvokedAt = &t
+		}
+		list = append(list, described)
+	}
+	sortByLastInvocation(list, query.Descending)
+
+	start := min((query.Page-1)*query.PageSize, len(list))
+	page := make([]models.PluginInstallation, 0, query.PageSize)
+	for _, installation := range list[start:min(start+query.PageSize, len(list))] {
+		page = append(page, installation.PluginInstallation)
+	}
+	return page, nil
+}
+
+func FetchPluginInstallationDetail(c *cluster.Cluster, installation_id string) *entities.Response {
+	installation, err := db.GetOne[models.PluginInstallation](
+		db.Equal("id", installation_id),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return exception.ErrPluginNotFound().ToResponse()
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	health, err := loadPluginHealth(c)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	list, err := describePluginInstallations([]models.PluginInstallation{installation}, health)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	detail := AdminPluginInstallationDetail{AdminPluginInstallation: list[0]}
+	if identifier, err := plugin_entities.NewPluginUniqueIdentifier(installation.PluginUniqueIdentifier); err == nil {
+		detail.Declaration, _ = helper.CombinedGetPluginDeclaration(
+			identifier,
+			plugin_entities.PluginRuntimeType(installation.RuntimeType),
+		)
+	}
+
+	return entities.NewSuccessResponse(detail)
+}
+
+// sortByLastInvocation keeps the order of installation time among plugins invoked at the same time,
+// plugins never invoked go last
+func sortByLastInvocation(list []AdminPluginInstallation, descending bool) {
+	slices.SortStableFunc(list, func(a, b AdminPluginInstallation) int {
+		if a.LastInvokedAt == nil || b.LastInvokedAt == nil {
+			switch {
+			case a.LastInvokedAt != nil:
+				return -1
+			case b.LastInvokedAt != nil:
+				return 1
+			default:
+				return 0
+			}
+		}
+		if descending {
+			return b.LastInvokedAt.Compare(*a.LastInvokedAt)
+		}
+		return a.LastInvokedAt.Compare(*b.LastInvokedAt)
+	})
+}
+
+// pluginHealth holds the runtimes of current node and the states reported by the nodes of the cluster
+type pluginHealth struct {
+	runtimes map[string]plugin_manager.RuntimeStatus
+	nodes    map[string][]cluster.PluginNodeState
+}
+
+// pluginStatusPriority orders the statuses of the runtimes of a plugin on different nodes, the first one
+// found is the status of the plugin
+var pluginStatusPriority = []string{
+	plugin_entities.PLUGIN_RUNTIME_STATUS_ACTIVE,
+	plugin_entities.PLUGIN_RUNTIME_STATUS_LAUNCHING,
+	plugin_entities.PLUGIN_RUNTIME_STATUS_RESTARTING,
+	plugin_entities.PLUGIN_RUNTIME_STATUS_PENDING,
+	plugin_entities.PLUGIN_RUNTIME_STATUS_STOPPED,
+}
+
+func loadPluginHealth(c *cluster.Cluster) (*pluginHealth, error) {
+	health := &pluginHealth{
+		runtimes: map[string]plugin_manager.RuntimeStatus{},
+		nodes:    map[string][]cluster.PluginNodeState{},
+	}
+	if manager := plugin_manager.Manager(); manager != nil {
+		for _, runtime := range manager.Runtimes() {
+			health.runtimes[runtime.PluginUniqueIdentifier.String()] = runtime
+		}
+	}
+	if c != nil {
+		nodes, err := c.PluginStates()
+		if err != nil {
+			return nil, err
+		}
+		health.nodes = nodes
+	}
+	return health, nil
+}
+
+// status aggregates the statuses of a plugin on every node, a plugin active on any node is active
+func (h *pluginHealth) status(identifier string) string {
+	statuses := []string{}
+	if runtime, ok := h.runtimes[identifier]; ok {
+		statuses = append(statuses, runtime.Status)
+	}
+	for _, node := range h.nodes[identifier] {
+		statuses = append(statuses, node.Status)
+	}
+	for _, status := range pluginStatusPriority {
+		if slices.Contains(statuses, status) {
+			return status
+		}
+	}
+	if len(statuses) != 0 {
+		return statuses[0]
+	}
+	return INSTALLATION_STATUS_NOT_RUNNING
+}
+
+// identifiers returns the plugins having a runtime on any node, with the given status if not empty
+func (h *pluginHealth) identifiers(status string) []interface{} {
+	identifiers := []interface{}{}
+	seen := map[string]bool{}
+	collect := func(identifier string) {
+		if seen[identifier] {
+			return
+		}
+		seen[identifier] = true
+		if status == "" || h.status(identifier) == status {
+			identifiers = append(identifiers, identifier)
+		}
+	}
+	for identifier := range h.runtimes {
+		collect(identifier)
+	}
+	for identifier := range h.nodes {
+		collect(identifier)
+	}
+	return identifiers
+}
+
+// conditions translates a status filter into sql conditions on the plugins running in the cluster
+func (h *pluginHealth) conditions(status string) []db.GenericQuery {
+	serverless := string(plugin_entities.PLUGIN_RUNTIME_TYPE_SERVERLESS)
+	switch status {
+	case "":
+		return nil
+	case INSTALLATION_STATUS_SERVERLESS:
+		return []db.GenericQuery{db.Equal("runtime_type", serverless)}
+	case INSTALLATION_STATUS_NOT_RUNNING:
+		queries := []db.GenericQuery{db.NotEqual("runtime_type", serverless)}
+		if running := h.identifiers(""); len(running) != 0 {
+			queries = append(queries, db.WhereSQL("plugin_unique_identifier NOT IN ?", running))
+		}
+		return queries
+	default:
+		matched := h.identifiers(status)
+		if len(matched) == 0 {
+			return []db.GenericQuery{db.WhereSQL("1 = 0")}
+		}
+		return []db.GenericQuery{
+			db.NotEqual("runtime_type", serverless),
+			db.InArray("plugin_unique_identifier", matched),
+		}
+	}
+}
+
+// of returns the health of an installation
+func (h *pluginHealth) of(installation *models.PluginInstallation) PluginInstallationHealth {
+	if installation.RuntimeType == string(plugin_entities.PLUGIN_RUNTIME_TYPE_SERVERLESS) {
+		return PluginInstallationHealth{Status: INSTALLATION_STATUS_SERVERLESS, Nodes: []cluster.PluginNodeState{}}
+	}
+
+	health := PluginInstallationHealth{
+		Status: h.status(installation.PluginUniqueIdentifier),
+		Nodes:  h.nodes[installation.PluginUniqueIdentifier],
+	}
+	if runtime, ok := h.runtimes[installation.PluginUniqueIdentifier]; ok {
+		health.Runtime = &runtime
+	}
+	if health.Nodes == nil {
+		health.Nodes = []cluster.PluginNodeState{}
+	}
+	return health
+}
+
+// describePluginInstallations joins installations with their declarations, last invocations and health,
+// an installation whose declaration is gone is listed without it
+func describePluginInstallations(installations []models.PluginInstallation, health *pluginHealth) ([]AdminPluginInstallation, error) {
+	lastInvokedAt := map[string]map[string]time.Time{}
+	list := make([]AdminPluginInstallation, 0, len(installations))
+	for _, installation := range installations {
+		described := AdminPluginInstallation{
+			PluginInstallation: installation,
+			ModelTypes:         []plugin_entities.ModelType{},
+			Health:             health.of(&installation),
+		}
+
+		identifier, err := plugin_entities.NewPluginUniqueIdentifier(installation.PluginUniqueIdentifier)
+		if err == nil {
+			described.Version = identifier.Version().String()
+			declaration, err := helper.CombinedGetPluginDeclaration(
+				identifier,
+				plugin_entities.PluginRuntimeType(installation.RuntimeType),
+			)
+			if err != nil {
+				log.Warn("failed to fetch declaration of %s: %s", identifier, err.Error())
+			} else {
+				described.Name = declaration.Name
+				described.Category = declaration.Category()
+				if declaration.Model != nil && declaration.Model.SupportedModelTypes != nil {
+					described.ModelTypes = declaration.Model.SupportedModelTypes
+				}
+			}
+		}
+
+		if _, ok := lastInvokedAt[installation.TenantID]; !ok {
+			tenantLastInvokedAt, err := invocation_stats.TenantLastInvokedAt(installation.TenantID)
+			if err != nil {
+				return nil, err
+			}
+			lastInvokedAt[installation.TenantID] = tenantLastInvokedAt
+		}
+		if t, ok := lastInvokedAt[installation.TenantID][installation.PluginID]; ok {
+			described.LastInvokedAt = &t
+		}
+
+		list = append(list, described)
+	}
+
+	return list, nil
+}
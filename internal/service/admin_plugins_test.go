@@ -0,0 +1,150 @@
+package service
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/cluster"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListAllPluginInstallations(t *testing.T) {
+	cfg := &app.Config{DBType: "sqlite", DBSqlitePath: filepath.Join(t.TempDir(), "admin.db")}
+	cfg.SetDefault()
+	db.Init(cfg)
+	t.Cleanup(db.Close)
+	cache.InitMemoryClient(0)
+
+	search := "acme/search:0.0.1@" + strings.Repeat("a", 32)
+	llm := "acme/llm:0.0.1@" + strings.Repeat("b", 32)
+	declarations := map[string]plugin_entities.PluginDeclaration{
+		search: {Tool: &plugin_entities.ToolProviderDeclaration{}},
+		llm: {Model: &plugin_entities.ModelProviderDeclaration{
+			SupportedModelTypes: []plugin_entities.ModelType{plugin_entities.MODEL_TYPE_LLM, plugin_entities.MODEL_TYPE_TTS},
+		}},
+	}
+	for identifier, declaration := range declarations {
+		declaration.Name = strings.Split(identifier, ":")[0]
+		require.NoError(t, db.Create(&models.PluginDeclaration{
+			PluginUniqueIdentifier: identifier,
+			PluginID:               declaration.Name,
+			Declaration:            declaration,
+		}))
+	}
+
+	tenant := uuid.New().String()
+	for _, installation := range []models.PluginInstallation{
+		{TenantID: tenant, PluginID: "acme/search", PluginUniqueIdentifier: search, RuntimeType: "local"},
+		{TenantID: tenant, PluginID: "acme/llm", PluginUniqueIdentifier: llm, RuntimeType: "local"},
+		{TenantID: uuid.New().String(), PluginID: "acme/llm", PluginUniqueIdentifier: llm, RuntimeType: "serverless"},
+	} {
+		require.NoError(t, db.Create(&installation))
+	}
+
+	list := func(query PluginInstallationQuery) (int, []map[string]any) {
+		query.Page, query.PageSize = 1, 10
+		response := ListAllPluginInstallations(nil, query)
+		require.Zero(t, response.Code, response.Message)
+
+		var data struct {
+			List  []map[string]any `json:"list"`
+			Total int              `json:"total"`
+		}
+		encoded, _ := json.Marshal(response.Data)
+		require.NoError(t, json.Unmarshal(encoded, &data))
+		return data.Total, data.List
+	}
+
+	total, items := list(PluginInstallationQuery{Category: "model"})
+	assert.Equal(t, 2, total)
+	assert.Equal(t, "acme/llm", items[0]["name"])
+	assert.NotContains(t, items[0], "declaration")
+
+	total, _ = list(PluginInstallationQuery{Category: "tool"})
+	assert.Equal(t, 1, total)
+	total, _ = list(PluginInstallationQuery{ModelType: "tts", TenantID: tenant})
+	assert.Equal(t, 1, total)
+	total, _ = list(PluginInstallationQuery{ModelType: "rerank"})
+	assert.Zero(t, total)
+	// wildcards in the model type are matched literally
+	total, _ = list(PluginInstallationQuery{ModelType: "%"})
+	assert.Zero(t, total)
+	total, _ = list(PluginInstallationQuery{ModelType: "t_s"})
+	assert.Zero(t, total)
+	total, _ = list(PluginInstallationQuery{Status: INSTALLATION_STATUS_NOT_RUNNING})
+	assert.Equal(t, 2, total)
+
+	response := ListAllPluginInstallations(nil, PluginInstallationQuery{SortBy: INSTALLATION_SORT_LAST_INVOKED_AT, Page: 1, PageSize: 10})
+	assert.NotZero(t, response.Code, "sorting by last invocation across tenants should be rejected")
+
+	// the runtimes of a plugin on different nodes make up its health
+	health := &pluginHealth{
+		runtimes: map[string]plugin_manager.RuntimeStatus{},
+		nodes: map[string][]cluster.PluginNodeState{
+			search: {
+				{NodeID: "a", PluginRuntimeState: plugin_entities.PluginRuntimeState{Status: plugin_entities.PLUGIN_RUNTIME_STATUS_STOPPED}},
+				{NodeID: "b", PluginRuntimeState: plugin_entities.PluginRuntimeState{Status: plugin_entities.PLUGIN_RUNTIME_STATUS_ACTIVE}},
+			},
+			llm: {
+				{NodeID: "a", PluginRuntimeState: plugin_entities.PluginRuntimeState{Status: plugin_entities.PLUGIN_RUNTIME_STATUS_STOPPED}},
+			},
+		},
+	}
+	count := func(status string) int64 {
+		count, err := db.GetCount[models.PluginInstallation](health.conditions(status)...)
+		require.NoError(t, err)
+		return count
+	}
+	assert.Equal(t, int64(1), count(plugin_entities.PLUGIN_RUNTIME_STATUS_ACTIVE))
+	assert.Equal(t, int64(1), count(plugin_entities.PLUGIN_RUNTIME_STATUS_STOPPED))
+	assert.Equal(t, int64(1), count(INSTALLATION_STATUS_SERVERLESS))
+	assert.Zero(t, count(INSTALLATION_STATUS_NOT_RUNNING))
+	assert.Zero(t, count(plugin_entities.PLUGIN_RUNTIME_STATUS_RESTARTING))
+
+	of := health.of(&models.PluginInstallation{PluginUniqueIdentifier: search, RuntimeType: "local"})
+	assert.Equal(t, plugin_entities.PLUGIN_RUNTIME_STATUS_ACTIVE, of.Status)
+	assert.Len(t, of.Nodes, 2)
+}
+
+func TestSortByLastInvocation(t *testing.T) {
+	at := func(minutes int) *time.Time {
+		t := time.Date(2025, 1, 1, 0, minutes, 0, 0, time.UTC)
+		return &t
+	}
+	installation := func(id string, lastInvokedAt *time.Time) AdminPluginInstallation {
+		described := AdminPluginInstallation{LastInvokedAt: lastInvokedAt}
+		described.PluginInstallation = models.PluginInstallation{PluginID: id}
+		return described
+	}
+	ids := func(list []AdminPluginInstallation) []string {
+		result := []string{}
+		for _, installation := range list {
+			result = append(result, installation.PluginID)
+		}
+		return result
+	}
+
+	list := []AdminPluginInstallation{
+		installation("never", nil),
+		installation("old", at(1)),
+		installation("recent", at(5)),
+		installation("never_2", nil),
+	}
+
+	sortByLastInvocation(list, true)
+	assert.Equal(t, []string{"recent", "old", "never", "never_2"}, ids(list))
+
+	sortByLastInvocation(list, false)
+	assert.Equal(t, []string{"old", "recent", "never", "never_2"}, ids(list))
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/access_control"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+// APIKeySecret is a key along with its secret, the secret can't be fetched again
+type APIKeySecret struct {
+	Key    *models.APIKey `json:"key"`
+	Secret string         `json:"secret"`
+}
+
+func ListRoles() *entities.Response {
+	return entities.NewSuccessResponse(access_control.Roles())
+}
+
+func ListAPIKeys() *entities.Response {
+	keys, err := access_control.ListKeys()
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	return entities.NewSuccessResponse(keys)
+}
+
+// CreateAPIKey creates a key never expiring if expiresIn is zero
+func CreateAPIKey(name string, roles []string, tenant_id string, organization string, expiresIn time.Duration) *entities.Response {
+	var expiresAt *time.Time
+	if expiresIn > 0 {
+		t := time.Now().Add(expiresIn)
+		expiresAt = &t
+	}
+
+	key, secret, err := access_control.CreateKey(name, roles, tenant_id, organization, expiresAt)
+	if err != nil {
+		return apiKeyError(err)
+	}
+	return entities.NewSuccessResponse(APIKeySecret{Key: key, Secret: secret})
+}
+
+func RotateAPIKey(id string, grace time.Duration) *entities.Response {
+	key, secret, err := access_control.RotateKey(id, grace)
+	if err != nil {
+		return apiKeyError(err)
+	}
+	return entities.NewSuccessResponse(APIKeySecret{Key: key, Secret: secret})
+}
+
+func RevokeAPIKey(id string) *entities.Response {
+	key, err := access_control.RevokeKey(id)
+	if err != nil {
+		return apiKeyError(err)
+	}
+	return entities.NewSuccessResponse(key)
+}
+
+func apiKeyError(err error) *entities.Response {
+	switch {
+	case errors.Is(err, access_control.ErrAPIKeyNotFound):
+		return exception.NotFoundError(err).ToResponse()
+	case errors.Is(err, access_control.ErrAPIKeyRevoked),
+		errors.Is(err, access_control.ErrAPIKeyRotated),
+		errors.Is(err, access_control.ErrAPIKeyExpired),
+		errors.Is(err, access_control.ErrInvalidRole):
+		return exception.BadRequestError(err).ToResponse()
+	default:
+		return exception.InternalServerError(err).ToResponse()
+	}
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/audit"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+func ListAuditRecords(filter audit.Filter, page int, page_size int) *entities.Response {
+	records, err := audit.List(filter, page, page_size)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(records)
+}
+
+// ExportAuditRecords streams the records as json lines, an error after the first line only ends the stream
+func ExportAuditRecords(ctx *gin.Context, filter audit.Filter) {
+	ctx.Header("Content-Type", "application/x-ndjson")
+	ctx.Header("Content-Disposition", `attachment; filename="audit.jsonl"`)
+	ctx.Status(http.StatusOK)
+
+	if err := audit.Export(filter, ctx.Writer); err != nil {
+		log.Error("failed to export audit records: %s", err.Error())
+	}
+}
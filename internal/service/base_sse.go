@@ -2,12 +2,15 @@ package service
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
 	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
@@ -16,12 +19,20 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
+const (
+	SESSION_ID_HEADER = "X-Dify-Plugin-Session-Id"
+	// INVOKE_TIMEOUT_HEADER shortens the timeout of an invocation, in seconds
+	INVOKE_TIMEOUT_HEADER = "X-Dify-Plugin-Invoke-Timeout"
+)
+
 // baseSSEService is a helper function to handle SSE service
-// it accepts a generator function that returns a stream response to gin context
+// it accepts a generator function that returns a stream response to gin context,
+// the outcome is recorded to session if it's not nil
 func baseSSEService[R any](
 	generator func() (*stream.Stream[R], error),
 	ctx *gin.Context,
 	max_timeout_seconds int,
+	session *session_manager.Session,
 ) {
 	writer := ctx.Writer
 	writer.WriteHeader(200)
@@ -30,20 +41,28 @@ func baseSSEService[R any](
 	done := make(chan bool)
 	doneClosed := new(int32)
 	closed := new(int32)
+	activity := make(chan struct{}, 1)
 
-	writeData := func(data interface{}) {
+	// writeData reports whether the caller is still there, the stream stops once a write failed
+	writeData := func(data interface{}) bool {
 		if atomic.LoadInt32(closed) == 1 {
-			return
+			return false
+		}
+		payload := append([]byte("data: "), parser.MarshalJsonBytes(data)...)
+		if _, err := writer.Write(append(payload, "\n\n"...)); err != nil {
+			atomic.StoreInt32(closed, 1)
+			return false
 		}
-		writer.Write([]byte("data: "))
-		writer.Write(parser.MarshalJsonBytes(data))
-		writer.Write([]byte("\n\n"))
 		writer.Flush()
+		return true
 	}
 
 	pluginDaemonResponse, err := generator()
 
 	if err != nil {
+		if session != nil {
+			session.Fail(exception.PluginDaemonInternalServerError, err.Error())
+		}
 		writeData(exception.InternalServerError(err).ToResponse())
 		close(done)
 		return
@@ -56,10 +75,28 @@ func baseSSEService[R any](
 		for pluginDaemonResponse.Next() {
 			chunk, err := pluginDaemonResponse.Read()
 			if err != nil {
+				if session != nil {
+					session.Fail(exception.PluginInvokeError, err.Error())
+				}
 				writeData(exception.InvokePluginError(err).ToResponse())
 				break
 			}
-			writeData(entities.NewSuccessResponse(chunk))
+			if !writeData(entities.NewSuccessResponse(chunk)) {
+				// chunks of a dead connection are dropped and the plugin is aborted by closing the stream
+				if session != nil {
+					session.Fail(exception.PluginConnectionClosedError, "caller disconnected")
+				}
+				pluginDaemonResponse.Close()
+				break
+			}
+			if session != nil {
+				session.RecordDelivered()
+			}
+
+			select {
+			case activity <- struct{}{}:
+			default:
+			}
 		}
 
 		if atomic.CompareAndSwapInt32(doneClosed, 0, 1) {
@@ -70,22 +107,67 @@ func baseSSEService[R any](
 	timer := time.NewTimer(time.Duration(max_timeout_seconds) * time.Second)
 	defer timer.Stop()
 
+	var cancelled <-chan struct{}
+	if session != nil {
+		cancelled = session.Cancelled()
+	}
+
 	defer func() {
 		atomic.StoreInt32(closed, 1)
 	}()
 
-	select {
-	case <-writer.CloseNotify():
-		pluginDaemonResponse.Close()
-		return
-	case <-done:
-		return
-	case <-timer.C:
-		writeData(exception.InternalServerError(errors.New("killed by timeout")).ToResponse())
-		if atomic.CompareAndSwapInt32(doneClosed, 0, 1) {
-			close(done)
+	// streams of routes with an idle timeout are closed once no chunk was sent for that long
+	idleTimeout := ctx.GetDuration(constants.CONTEXT_KEY_STREAM_IDLE_TIMEOUT)
+	var idleTimer *time.Timer
+	var idle <-chan time.Time
+	if idleTimeout > 0 {
+		idleTimer = time.NewTimer(idleTimeout)
+		defer idleTimer.Stop()
+		idle = idleTimer.C
+	}
+
+	for {
+		select {
+		case <-writer.CloseNotify():
+			if session != nil {
+				session.Fail(exception.PluginConnectionClosedError, "caller disconnected")
+			}
+			pluginDaemonResponse.Close()
+			return
+		case <-done:
+			return
+		case <-cancelled:
+			writeData(exception.SessionCancelledError("session cancelled").ToResponse())
+			pluginDaemonResponse.Close()
+			if atomic.CompareAndSwapInt32(doneClosed, 0, 1) {
+				close(done)
+			}
+			return
+		case <-activity:
+			if idleTimer != nil {
+				idleTimer.Reset(idleTimeout)
+			}
+		case <-idle:
+			if session != nil {
+				session.Fail(exception.PluginDaemonRequestTimeoutError, "stream idle timeout")
+			}
+			writeData(exception.RequestTimeoutError("stream idle timeout").ToResponse())
+			pluginDaemonResponse.Close()
+			if atomic.CompareAndSwapInt32(doneClosed, 0, 1) {
+				close(done)
+			}
+			return
+		case <-timer.C:
+			if session != nil {
+				session.Fail(exception.PluginDaemonInternalServerError, "killed by timeout")
+			}
+			writeData(exception.InternalServerError(errors.New("killed by timeout")).ToResponse())
+			pluginDaemonResponse.Close()
+			if atomic.CompareAndSwapInt32(doneClosed, 0, 1) {
+				close(done)
+			}
+			return
 		}
-		return
 	}
 }
 
@@ -97,11 +179,12 @@ func baseSSEWithSession[T any, R any](
 	ctx *gin.Context,
 	max_timeout_seconds int,
 ) {
-	session, err := createSession(
+	session, err := CreateSession(
 		request,
 		access_type,
 		access_action,
 		ctx.GetString("cluster_id"),
+		ctx.Request.Context(),
 	)
 	if err != nil {
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
@@ -111,11 +194,55 @@ func baseSSEWithSession[T any, R any](
 		IgnoreCache: false,
 	})
 
+	timeout, err := PrepareInvocation(
+		ctx.Request.Context(),
+		session,
+		request,
+		ctx.GetHeader(INVOKE_TIMEOUT_HEADER),
+		max_timeout_seconds,
+	)
+	if err != nil {
+		var invalid *InvalidInvocationError
+		if errors.As(err, &invalid) {
+			ctx.JSON(400, exception.BadRequestError(err).ToResponse())
+		} else {
+			ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		}
+		return
+	}
+
+	// callers whose stream broke can fetch the outcome of the session with it, or cancel it
+	ctx.Header(SESSION_ID_HEADER, session.ID)
+
 	baseSSEService(
 		func() (*stream.Stream[R], error) {
-			return generator(session)
+			return Invoke(session, request, generator)
 		},
 		ctx,
-		max_timeout_seconds,
+		timeout,
+		session,
 	)
 }
+
+// invokeTimeout is the timeout of an invocation in seconds, the caller and the manifest of the
+// plugin may only shorten the max execution timeout
+func invokeTimeout(header string, declaration *plugin_entities.PluginDeclaration, max_timeout_seconds int) (int, error) {
+	timeout := max_timeout_seconds
+	shorten := func(seconds int) {
+		if seconds > 0 && seconds < timeout {
+			timeout = seconds
+		}
+	}
+
+	if declaration != nil {
+		shorten(declaration.Meta.InvokeTimeout)
+	}
+	if header != "" {
+		seconds, err := strconv.Atoi(header)
+		if err != nil || seconds <= 0 {
+			return 0, fmt.Errorf("invalid %s header, expected a positive number of seconds", INVOKE_TIMEOUT_HEADER)
+		}
+		shorten(seconds)
+	}
+	return timeout, nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvokeTimeout(t *testing.T) {
+	declaration := &plugin_entities.PluginDeclaration{}
+	declaration.Meta.InvokeTimeout = 60
+
+	timeout, err := invokeTimeout("", nil, 600)
+	require.NoError(t, err)
+	assert.Equal(t, 600, timeout)
+
+	timeout, err = invokeTimeout("", declaration, 600)
+	require.NoError(t, err)
+	assert.Equal(t, 60, timeout)
+
+	timeout, err = invokeTimeout("30", declaration, 600)
+	require.NoError(t, err)
+	assert.Equal(t, 30, timeout)
+
+	// neither the caller nor the manifest can extend the max execution timeout
+	timeout, err = invokeTimeout("900", nil, 600)
+	require.NoError(t, err)
+	assert.Equal(t, 600, timeout)
+
+	for _, invalid := range []string{"soon", "0", "-5"} {
+		_, err = invokeTimeout(invalid, nil, 600)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestBaseSSEServiceCancel(t *testing.T) {
+	routine.InitPool(16)
+	gin.SetMode(gin.TestMode)
+
+	session := session_manager.NewSession(session_manager.NewSessionPayload{TenantID: "tenant", IgnoreCache: true})
+	defer session.Close(session_manager.CloseSessionPayload{IgnoreCache: true})
+
+	// the plugin never answers, the stream ends with the cancellation
+	response := stream.NewStream[string](1)
+	engine := gin.New()
+	engine.POST("/invoke", func(ctx *gin.Context) {
+		baseSSEService(func() (*stream.Stream[string], error) { return response, nil }, ctx, 60, session)
+	})
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+
+	time.AfterFunc(50*time.Millisecond, func() {
+		session_manager.CancelSession("tenant", session.ID, "cancelled by caller")
+	})
+	client := &http.Client{Timeout: 5 * time.Second}
+	result, err := client.Post(server.URL+"/invoke", "application/json", nil)
+	require.NoError(t, err)
+	defer result.Body.Close()
+	body, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+
+	assert.True(t, response.IsClosed())
+	assert.Contains(t, string(body), exception.PluginDaemonSessionCancelledError)
+}
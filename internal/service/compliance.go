@@ -0,0 +1,329 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/access_control"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/endpoint_domains"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/persistence"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/debugging_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/tenant_variables"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+// tenantDataSource is a kind of data the daemon stores on behalf of tenants,
+// new kinds of tenant data must be added to tenantDataSources to be covered by exports and purges
+type tenantDataSource struct {
+	name string
+	// inventory returns the records and their count
+	inventory func(tenant_id string) (any, int64, error)
+	// purge is nil for data the purge keeps, it's exported but only removed by retention
+	purge func(tenant_id string) error
+}
+
+type TenantDataInventoryItem struct {
+	Name    string `json:"name"`
+	Count   int64  `json:"count"`
+	Records any    `json:"records"`
+}
+
+type TenantDataPurgeItem struct {
+	Name      string `json:"name"`
+	Deleted   int64  `json:"deleted"`
+	Remaining int64  `json:"remaining"`
+	// Retained is true for data kept by purges, it does not fail the verification
+	Retained bool   `json:"retained,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type TenantDataPurgeReport struct {
+	TenantID string                `json:"tenant_id"`
+	Items    []TenantDataPurgeItem `json:"items"`
+	// Verified is true only if every kind of data was inventoried again after the purge and nothing was left
+	Verified bool `json:"verified"`
+}
+
+func tenantRecords[T any](tenant_id string) (any, int64, error) {
+	records, err := db.GetAll[T](db.Equal("tenant_id", tenant_id))
+	if err != nil {
+		return nil, 0, err
+	}
+	return records, int64(len(records)), nil
+}
+
+var tenantDataSources = []tenantDataSource{
+	{
+		name:      "plugin_installations",
+		inventory: tenantRecords[models.PluginInstallation],
+		purge: func(tenant_id string) error {
+			installations, err := db.GetAll[models.PluginInstallation](db.Equal("tenant_id", tenant_id))
+			if err != nil {
+				return err
+			}
+			// uninstall one by one to keep the references of plugins right
+			for _, installation := range installations {
+				if err := uninstallPlugin(tenant_id, installation.ID); err != nil {
+					return fmt.Errorf("failed to uninstall %s: %s", installation.PluginID, err.Error())
+				}
+			}
+			return nil
+		},
+	},
+	{
+		name:      "plugin_version_installations",
+		inventory: tenantRecords[models.PluginVersionInstallation],
+		purge: func(tenant_id string) error {
+			versions, err := db.GetAll[models.PluginVersionInstallation](db.Equal("tenant_id", tenant_id))
+			if err != nil {
+				return err
+			}
+			for _, version := range versions {
+				if err := uninstallPluginVersion(tenant_id, version.PluginID, version.Version); err != nil {
+					return fmt.Errorf("failed to uninstall %s:%s: %s", version.PluginID, version.Version, err.Error())
+				}
+			}
+			return nil
+		},
+	},
+	{
+		name:      "tool_installations",
+		inventory: tenantRecords[models.ToolInstallation],
+		purge: func(tenant_id string) error {
+			return db.DeleteByCondition(models.ToolInstallation{TenantID: tenant_id})
+		},
+	},
+	{
+		name:      "model_installations",
+		inventory: tenantRecords[models.AIModelInstallation],
+		purge: func(tenant_id string) error {
+			return db.DeleteByCondition(models.AIModelInstallation{TenantID: tenant_id})
+		},
+	},
+	{
+		name:      "agent_strategy_installations",
+		inventory: tenantRecords[models.AgentStrategyInstallation],
+		purge: func(tenant_id string) error {
+			return db.DeleteByCondition(models.AgentStrategyInstallation{TenantID: tenant_id})
+		},
+	},
+	{
+		name: "endpoints",
+		inventory: func(tenant_id string) (any, int64, error) {
+			endpoints, err := db.GetAll[models.Endpoint](db.Equal("tenant_id", tenant_id))
+			if err != nil {
+				return nil, 0, err
+			}
+			// settings are encrypted by Dify, they are meaningless in an export
+			for i := range endpoints {
+				endpoints[i].Settings = nil
+			}
+			return endpoints, int64(len(endpoints)), nil
+		},
+		purge: func(tenant_id string) error {
+			endpoints, err := db.GetAll[models.Endpoint](db.Equal("tenant_id", tenant_id))
+			if err != nil {
+				return err
+			}
+			if err := db.DeleteByCondition(models.Endpoint{TenantID: tenant_id}); err != nil {
+				return err
+			}
+			for _, endpoint := range endpoints {
+				_, _ = cache.AutoDelete[models.Endpoint](helper.EndpointCacheKey(endpoint.HookID))
+			}
+			return nil
+		},
+	},
+	{
+		name:      "endpoint_domains",
+		inventory: tenantRecords[models.EndpointDomain],
+		purge:     endpoint_domains.RemoveTenant,
+	},
+	{
+		name:      "install_tasks",
+		inventory: tenantRecords[models.InstallTask],
+		purge: func(tenant_id string) error {
+			return db.DeleteByCondition(models.InstallTask{TenantID: tenant_id})
+		},
+	},
+	{
+		name:      "plugin_install_approvals",
+		inventory: tenantRecords[models.PluginInstallApproval],
+		purge: func(tenant_id string) error {
+			return db.DeleteByCondition(models.PluginInstallApproval{TenantID: tenant_id})
+		},
+	},
+	{
+		// audit records are append-only, the purge itself is audited
+		name:      "audit_records",
+		inventory: tenantRecords[models.AuditRecord],
+	},
+	{
+		name:      "plugin_bundles",
+		inventory: tenantRecords[models.PluginBundle],
+		purge: func(tenant_id string) error {
+			return db.DeleteByCondition(models.PluginBundle{TenantID: tenant_id})
+		},
+	},
+	{
+		name:      "plugin_dependencies",
+		inventory: tenantRecords[models.PluginDependency],
+		purge: func(tenant_id string) error {
+			return db.DeleteByCondition(models.PluginDependency{TenantID: tenant_id})
+		},
+	},
+	{
+		name:      "permission_consents",
+		inventory: tenantRecords[models.PluginPermissionConsent],
+		purge: func(tenant_id string) error {
+			consents, err := db.GetAll[models.PluginPermissionConsent](db.Equal("tenant_id", tenant_id))
+			if err != nil {
+				return err
+			}
+			if err := db.DeleteByCondition(models.PluginPermissionConsent{TenantID: tenant_id}); err != nil {
+				return err
+			}
+			for _, consent := range consents {
+				helper.InvalidatePluginPermissionConsent(tenant_id, consent.PluginID)
+			}
+			return nil
+		},
+	},
+	{
+		name: "tenant_variables",
+		inventory: func(tenant_id string) (any, int64, error) {
+			variables, err := tenant_variables.List(tenant_id)
+			if err != nil {
+				return nil, 0, err
+			}
+			return variables, int64(len(variables)), nil
+		},
+		purge: func(tenant_id string) error {
+			return db.DeleteByCondition(models.TenantVariable{TenantID: tenant_id})
+		},
+	},
+	{
+		name:      "tenant_settings",
+		inventory: tenantRecords[models.TenantSetting],
+		purge: func(tenant_id string) error {
+			return db.DeleteByCondition(models.TenantSetting{TenantID: tenant_id})
+		},
+	},
+	{
+		name:      "scheduled_upgrades",
+		inventory: tenantRecords[models.PluginScheduledUpgrade],
+		purge: func(tenant_id string) error {
+			return db.DeleteByCondition(models.PluginScheduledUpgrade{TenantID: tenant_id})
+		},
+	},
+	{
+		name:      "oauth_credentials",
+		inventory: tenantRecords[models.OAuthCredential],
+		purge: func(tenant_id string) error {
+			return db.DeleteByCondition(models.OAuthCredential{TenantID: tenant_id})
+		},
+	},
+	{
+		name: "persistence_objects",
+		inventory: func(tenant_id string) (any, int64, error) {
+			objects, err := persistence.GetPersistence().ListTenant(tenant_id)
+			if err != nil {
+				return nil, 0, err
+			}
+			return objects, int64(len(objects)), nil
+		},
+		purge: func(tenant_id string) error {
+			_, err := persistence.GetPersistence().PurgeTenant(tenant_id)
+			return err
+		},
+	},
+	{
+		name: "debugging_keys",
+		inventory: func(tenant_id string) (any, int64, error) {
+			count, err := debugging_runtime.CountConnectionKeys(tenant_id)
+			if err != nil {
+				return nil, 0, err
+			}
+			return nil, count, nil
+		},
+		purge: func(tenant_id string) error {
+			if err := debugging_runtime.ClearConnectionKey(tenant_id); err != nil && err != cache.ErrNotFound {
+				return err
+			}
+			return nil
+		},
+	},
+	{
+		name:      "api_keys",
+		inventory: tenantRecords[models.APIKey],
+		purge:     access_control.DeleteTenantKeys,
+	},
+}
+
+// FetchTenantDataInventory enumerates everything the daemon stores on behalf of a tenant
+func FetchTenantDataInventory(tenant_id string) *entities.Response {
+	items := []TenantDataInventoryItem{}
+	for _, source := range tenantDataSources {
+		records, count, err := source.inventory(tenant_id)
+		if err != nil {
+			return exception.InternalServerError(fmt.Errorf("failed to inventory %s: %s", source.name, err.Error())).ToResponse()
+		}
+		items = append(items, TenantDataInventoryItem{
+			Name:    source.name,
+			Count:   count,
+			Records: records,
+		})
+	}
+
+	return entities.NewSuccessResponse(map[string]any{
+		"tenant_id": tenant_id,
+		"items":     items,
+	})
+}
+
+// PurgeTenantData deletes everything the daemon stores on behalf of a tenant, sources are purged in order
+// and each of them is inventoried again afterwards, a failed source does not stop the rest
+func PurgeTenantData(tenant_id string) *entities.Response {
+	report := TenantDataPurgeReport{
+		TenantID: tenant_id,
+		Items:    []TenantDataPurgeItem{},
+		Verified: true,
+	}
+
+	for _, source := range tenantDataSources {
+		item := TenantDataPurgeItem{Name: source.name}
+
+		_, before, err := source.inventory(tenant_id)
+		if err == nil && source.purge == nil {
+			item.Retained = true
+			item.Remaining = before
+			report.Items = append(report.Items, item)
+			continue
+		}
+		if err == nil {
+			err = source.purge(tenant_id)
+		}
+
+		if err == nil {
+			var remaining int64
+			_, remaining, err = source.inventory(tenant_id)
+			item.Remaining = remaining
+			item.Deleted = before - remaining
+		}
+
+		if err != nil {
+			item.Error = err.Error()
+			report.Verified = false
+		} else if item.Remaining > 0 {
+			report.Verified = false
+		}
+
+		report.Items = append(report.Items, item)
+	}
+
+	return entities.NewSuccessResponse(report)
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/persistence"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/debugging_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/oss"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models/curd"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seedTenantData stores one record of every kind of tenant data
+func seedTenantData(t *testing.T, tenant string) {
+	identifier := plugin_entities.PluginUniqueIdentifier("acme/" + tenant[:8] + ":0.0.1@" + strings.Repeat("a", 32))
+	declaration := plugin_entities.PluginDeclaration{
+		Tool:          &plugin_entities.ToolProviderDeclaration{},
+		Model:         &plugin_entities.ModelProviderDeclaration{},
+		AgentStrategy: &plugin_entities.AgentStrategyProviderDeclaration{},
+	}
+	require.NoError(t, db.Create(&models.PluginDeclaration{
+		PluginUniqueIdentifier: identifier.String(),
+		PluginID:               identifier.PluginID(),
+		Declaration:            declaration,
+	}))
+	_, _, err := curd.InstallPlugin(tenant, identifier, plugin_entities.PLUGIN_RUNTIME_TYPE_SERVERLESS, &declaration, "test", nil)
+	require.NoError(t, err)
+
+	version := plugin_entities.PluginUniqueIdentifier("acme/" + tenant[:8] + ":0.0.2@" + strings.Repeat("b", 32))
+	_, err = curd.InstallPluginVersion(tenant, version, plugin_entities.PLUGIN_RUNTIME_TYPE_SERVERLESS, &declaration, "test", nil)
+	require.NoError(t, err)
+
+	for _, record := range []any{
+		&models.Endpoint{HookID: uuid.New().String(), TenantID: tenant, PluginID: identifier.PluginID()},
+		&models.EndpointDomain{TenantID: tenant, Hostname: tenant + ".example.com"},
+		&models.InstallTask{TenantID: tenant, Status: models.InstallTaskStatusFailed},
+		&models.PluginInstallApproval{TenantID: tenant, Status: "pending"},
+		&models.AuditRecord{TenantID: tenant, Event: "plugin_install"},
+		&models.PluginBundle{TenantID: tenant, Name: "bundle"},
+		&models.PluginDependency{TenantID: tenant, PluginID: identifier.PluginID()},
+		&models.PluginPermissionConsent{TenantID: tenant, PluginID: identifier.PluginID()},
+		&models.TenantVariable{TenantID: tenant, Name: "region", Value: "eu"},
+		&models.TenantSetting{TenantID: tenant, Timezone: "Europe/Berlin"},
+		&models.PluginScheduledUpgrade{TenantID: tenant, OriginalPluginUniqueIdentifier: identifier.String()},
+		&models.OAuthCredential{TenantID: tenant, PluginID: identifier.PluginID(), Provider: "github"},
+		&models.APIKey{TenantID: tenant, Name: "ci", Hash: uuid.New().String()},
+	} {
+		require.NoError(t, db.Create(record))
+	}
+
+	require.NoError(t, persistence.GetPersistence().Save(tenant, identifier.PluginID(), -1, "state", []byte("value")))
+	_, err = debugging_runtime.GetConnectionKey(debugging_runtime.ConnectionInfo{TenantId: tenant})
+	require.NoError(t, err)
+}
+
+func decodeResponse[T any](t *testing.T, data any) T {
+	var decoded T
+	encoded, err := json.Marshal(data)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	return decoded
+}
+
+func TestTenantDataInventoryAndPurge(t *testing.T) {
+	config := &app.Config{DBType: "sqlite", DBSqlitePath: filepath.Join(t.TempDir(), "compliance.db")}
+	config.SetDefault()
+	db.Init(config)
+	t.Cleanup(db.Close)
+	cache.InitMemoryClient(0)
+
+	storage, err := oss.NewLocalStorage(cloudoss.OSSArgs{Local: &cloudoss.Local{Path: t.TempDir()}})
+	require.NoError(t, err)
+	persistence.InitPersistence(storage, config)
+
+	tenant, other := uuid.New().String(), uuid.New().String()
+	seedTenantData(t, tenant)
+	seedTenantData(t, other)
+
+	inventory := func(tenant string) map[string]int64 {
+		response := FetchTenantDataInventory(tenant)
+		require.Zero(t, response.Code, response.Message)
+		data := decodeResponse[struct {
+			Items []TenantDataInventoryItem `json:"items"`
+		}](t, response.Data)
+
+		counts := map[string]int64{}
+		for _, item := range data.Items {
+			counts[item.Name] = item.Count
+		}
+		return counts
+	}
+
+	// every kind of data is seeded, a new source has to be covered here too
+	counts := inventory(tenant)
+	require.Len(t, counts, len(tenantDataSources))
+	for _, source := range tenantDataSources {
+		assert.Equal(t, int64(1), counts[source.name], source.name)
+	}
+
+	response := PurgeTenantData(tenant)
+	require.Zero(t, response.Code, response.Message)
+	report := decodeResponse[TenantDataPurgeReport](t, response.Data)
+	assert.True(t, report.Verified, "%+v", report.Items)
+	require.Len(t, report.Items, len(tenantDataSources))
+	for _, item := range report.Items {
+		assert.Empty(t, item.Error, item.Name)
+		if item.Name == "audit_records" {
+			assert.True(t, item.Retained, "audit records are removed by retention only")
+			assert.Equal(t, int64(1), item.Remaining)
+			continue
+		}
+		assert.False(t, item.Retained, item.Name)
+		assert.Zero(t, item.Remaining, item.Name)
+	}
+
+	for name, count := range inventory(tenant) {
+		if name == "audit_records" {
+			assert.Equal(t, int64(1), count)
+			continue
+		}
+		assert.Zero(t, count, name)
+	}
+
+	// data of other tenants is left alone
+	for name, count := range inventory(other) {
+		assert.Equal(t, int64(1), count, name)
+	}
+}
@@ -1,21 +1,25 @@
 package service
 
 import (
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/debugging_runtime"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
 )
 
-func GetRemoteDebuggingKey(tenant_id string) *entities.Response {
+func GetRemoteDebuggingKey(tenant_id string, namespace string) *entities.Response {
 	type response struct {
 		Key string `json:"key"`
 	}
 
 	key, err := debugging_runtime.GetConnectionKey(debugging_runtime.ConnectionInfo{
-		TenantId: tenant_id,
+		TenantId:  tenant_id,
+		Namespace: namespace,
 	})
 
-	if err != nil {
+	if err == debugging_runtime.ErrInvalidNamespace {
+		return exception.BadRequestError(err).ToResponse()
+	} else if err != nil {
 		return exception.InternalServerError(err).ToResponse()
 	}
 
@@ -23,3 +27,8 @@ func GetRemoteDebuggingKey(tenant_id string) *entities.Response {
 		Key: key,
 	})
 }
+
+// ListDebuggingConnections lists the remote debugging connections of current node
+func ListDebuggingConnections() *entities.Response {
+	return entities.NewSuccessResponse(plugin_manager.Manager().DebuggingConnections())
+}
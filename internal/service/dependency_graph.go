@@ -0,0 +1,332 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/trust"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	DEPENDENCY_NODE_BUNDLE   = "bundle"
+	DEPENDENCY_NODE_PLUGIN   = "plugin"
+	DEPENDENCY_NODE_PROVIDER = "provider"
+
+	PROVIDER_KIND_TOOL           = "tool"
+	PROVIDER_KIND_MODEL          = "model"
+	PROVIDER_KIND_AGENT_STRATEGY = "agent_strategy"
+
+	// bundle to plugin
+	DEPENDENCY_EDGE_CONTAINS = "contains"
+	// plugin to the providers it declares
+	DEPENDENCY_EDGE_PROVIDES = "provides"
+	// plugin to the providers it has been observed invoking
+	DEPENDENCY_EDGE_USES = "uses"
+	// plugin to the plugins its manifest depends on
+	DEPENDENCY_EDGE_REQUIRES = "requires"
+)
+
+type DependencyGraphNode struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Name string `json:"name"`
+	// Kind is set for providers, one of tool, model and agent_strategy
+	Kind                   string   `json:"kind,omitempty"`
+	PluginID               string   `json:"plugin_id,omitempty"`
+	PluginUniqueIdentifier string   `json:"plugin_unique_identifier,omitempty"`
+	Version                string   `json:"version,omitempty"`
+	RequestedPermissions   []string `json:"requested_permissions,omitempty"`
+	// Installed is false for plugins of a bundle and providers used by plugins which are not installed
+	Installed bool `json:"installed"`
+}
+
+type DependencyGraphEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Relation string `json:"relation"`
+	// Constraint is the versions accepted by a requires edge
+	Constraint string     `json:"constraint,omitempty"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+type DependencyGraph struct {
+	Nodes []DependencyGraphNode `json:"nodes"`
+	Edges []DependencyGraphEdge `json:"edges"`
+	// Dependents are the bundles and plugins relying on the plugin queried
+	Dependents []DependencyGraphNode `json:"dependents"`
+}
+
+type dependencyGraphBuilder struct {
+	nodes map[string]*DependencyGraphNode
+	edges map[string]DependencyGraphEdge
+	// providers by kind and name, names are not unique across plugins
+	providersByName map[string]map[string][]string
+}
+
+func pluginNodeID(pluginID string) string {
+	return fmt.Sprintf("%s:%s", DEPENDENCY_NODE_PLUGIN, pluginID)
+}
+
+func providerNodeID(kind string, name string) string {
+	return fmt.Sprintf("%s:%s:%s", DEPENDENCY_NODE_PROVIDER, kind, name)
+}
+
+func (b *dependencyGraphBuilder) addEdge(edge DependencyGraphEdge) {
+	key := fmt.Sprintf("%s>%s>%s", edge.From, edge.Relation, edge.To)
+	if existing, ok := b.edges[key]; ok && existing.LastSeenAt != nil &&
+		(edge.LastSeenAt == nil || existing.LastSeenAt.After(*edge.LastSeenAt)) {
+		return
+	}
+	b.edges[key] = edge
+}
+
+func (b *dependencyGraphBuilder) addProvider(kind string, pluginID string, pluginUniqueIdentifier string, name string) {
+	id := providerNodeID(kind, fmt.Sprintf("%s/%s", pluginID, name))
+	b.nodes[id] = &DependencyGraphNode{
+		ID:                     id,
+		Type:                   DEPENDENCY_NODE_PROVIDER,
+		Name:                   name,
+		Kind:                   kind,
+		PluginID:               pluginID,
+		PluginUniqueIdentifier: pluginUniqueIdentifier,
+		Installed:              true,
+	}
+	if b.providersByName[kind] == nil {
+		b.providersByName[kind] = map[string][]string{}
+	}
+	b.providersByName[kind][name] = append(b.providersByName[kind][name], id)
+	b.addEdge(DependencyGraphEdge{From: pluginNodeID(pluginID), To: id, Relation: DEPENDENCY_EDGE_PROVIDES})
+}
+
+// resolveProvider maps a provider of a backwards invocation to the installed providers, plugins pass
+// either the full `author/plugin/provider` or the provider name only
+func (b *dependencyGraphBuilder) resolveProvider(kind string, provider string) []string {
+	if parts := strings.Split(provider, "/"); len(parts) == 3 {
+		id := providerNodeID(kind, provider)
+		if _, ok := b.nodes[id]; ok {
+			return []string{id}
+		}
+	} else if ids, ok := b.providersByName[kind][provider]; ok {
+		return ids
+	}
+
+	id := providerNodeID(kind, provider)
+	b.nodes[id] = &DependencyGraphNode{
+		ID:   id,
+		Type: DEPENDENCY_NODE_PROVIDER,
+		Name: provider,
+		Kind: kind,
+	}
+	return []string{id}
+}
+
+func buildDependencyGraph(tenant_id string) (*DependencyGraph, error) {
+	b := &dependencyGraphBuilder{
+		nodes:           map[string]*DependencyGraphNode{},
+		edges:           map[string]DependencyGraphEdge{},
+		providersByName: map[string]map[string][]string{},
+	}
+
+	installations, err := db.GetAll[models.PluginInstallation](db.Equal("tenant_id", tenant_id))
+	if err != nil {
+		return nil, err
+	}
+	// declared dependencies by the id of the plugin node requiring them
+	requires := map[string][]plugin_entities.PluginDependencyDeclaration{}
+	for _, installation := range installations {
+		node := &DependencyGraphNode{
+			ID:                     pluginNodeID(installation.PluginID),
+			Type:                   DEPENDENCY_NODE_PLUGIN,
+			Name:                   installation.PluginID,
+			PluginID:               installation.PluginID,
+			PluginUniqueIdentifier: installation.PluginUniqueIdentifier,
+			Installed:              true,
+		}
+
+		if identifier, err := plugin_entities.NewPluginUniqueIdentifier(installation.PluginUniqueIdentifier); err == nil {
+			node.Version = identifier.Version().String()
+			declaration, err := helper.CombinedGetPluginDeclaration(
+				identifier,
+				plugin_entities.PluginRuntimeType(installation.RuntimeType),
+			)
+			if err == nil {
+				node.RequestedPermissions = trust.Requested(declaration.Resource.Permission)
+				requires[node.ID] = declaration.Meta.Dependencies
+			}
+		}
+
+		b.nodes[node.ID] = node
+	}
+
+	for from, dependencies := range requires {
+		for _, dependency := range dependencies {
+			to := pluginNodeID(dependency.PluginID)
+			if _, ok := b.nodes[to]; !ok {
+				b.nodes[to] = &DependencyGraphNode{
+					ID:       to,
+					Type:     DEPENDENCY_NODE_PLUGIN,
+					Name:     dependency.PluginID,
+					PluginID: dependency.PluginID,
+				}
+			}
+			b.addEdge(DependencyGraphEdge{
+				From:       from,
+				To:         to,
+				Relation:   DEPENDENCY_EDGE_REQUIRES,
+				Constraint: dependency.Version.String(),
+			})
+		}
+	}
+
+	tools, err := db.GetAll[models.ToolInstallation](db.Equal("tenant_id", tenant_id))
+	if err != nil {
+		return nil, err
+	}
+	for _, tool := range tools {
+		b.addProvider(PROVIDER_KIND_TOOL, tool.PluginID, tool.PluginUniqueIdentifier, tool.Provider)
+	}
+
+	aiModels, err := db.GetAll[models.AIModelInstallation](db.Equal("tenant_id", tenant_id))
+	if err != nil {
+		return nil, err
+	}
+	for _, model := range aiModels {
+		b.addProvider(PROVIDER_KIND_MODEL, model.PluginID, model.PluginUniqueIdentifier, model.Provider)
+	}
+
+	agentStrategies, err := db.GetAll[models.AgentStrategyInstallation](db.Equal("tenant_id", tenant_id))
+	if err != nil {
+		return nil, err
+	}
+	for _, strategy := range agentStrategies {
+		b.addProvider(PROVIDER_KIND_AGENT_STRATEGY, strategy.PluginID, strategy.PluginUniqueIdentifier, strategy.Provider)
+	}
+
+	bundles, err := db.GetAll[models.PluginBundle](db.Equal("tenant_id", tenant_id))
+	if err != nil {
+		return nil, err
+	}
+	for _, bundle := range bundles {
+		id := fmt.Sprintf("%s:%s", DEPENDENCY_NODE_BUNDLE, bundle.Name)
+		b.nodes[id] = &DependencyGraphNode{
+			ID:        id,
+			Type:      DEPENDENCY_NODE_BUNDLE,
+			Name:      bundle.Name,
+			Version:   bundle.Version,
+			Installed: true,
+		}
+		for _, pluginID := range bundle.PluginIDs {
+			if _, ok := b.nodes[pluginNodeID(pluginID)]; !ok {
+				b.nodes[pluginNodeID(pluginID)] = &DependencyGraphNode{
+					ID:       pluginNodeID(pluginID),
+					Type:     DEPENDENCY_NODE_PLUGIN,
+					Name:     pluginID,
+					PluginID: pluginID,
+				}
+			}
+			b.addEdge(DependencyGraphEdge{From: id, To: pluginNodeID(pluginID), Relation: DEPENDENCY_EDGE_CONTAINS})
+		}
+	}
+
+	dependencies, err := db.GetAll[models.PluginDependency](db.Equal("tenant_id", tenant_id))
+	if err != nil {
+		return nil, err
+	}
+	for _, dependency := range dependencies {
+		// dependencies of uninstalled plugins are kept but not shown
+		if node, ok := b.nodes[pluginNodeID(dependency.PluginID)]; !ok || !node.Installed {
+			continue
+		}
+
+		kind := PROVIDER_KIND_MODEL
+		if dependency.InvokeType == string(dify_invocation.INVOKE_TYPE_TOOL) {
+			kind = PROVIDER_KIND_TOOL
+		}
+
+		lastSeenAt := dependency.LastSeenAt
+		for _, id := range b.resolveProvider(kind, dependency.Provider) {
+			b.addEdge(DependencyGraphEdge{
+				From:       pluginNodeID(dependency.PluginID),
+				To:         id,
+				Relation:   DEPENDENCY_EDGE_USES,
+				LastSeenAt: &lastSeenAt,
+			})
+		}
+	}
+
+	graph := &DependencyGraph{
+		Nodes: make([]DependencyGraphNode, 0, len(b.nodes)),
+		Edges: make([]DependencyGraphEdge, 0, len(b.edges)),
+	}
+	for _, node := range b.nodes {
+		graph.Nodes = append(graph.Nodes, *node)
+	}
+	for _, edge := range b.edges {
+		graph.Edges = append(graph.Edges, edge)
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		return graph.Nodes[i].ID < graph.Nodes[j].ID
+	})
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].From != graph.Edges[j].From {
+			return graph.Edges[i].From < graph.Edges[j].From
+		}
+		return graph.Edges[i].To < graph.Edges[j].To
+	})
+
+	return graph, nil
+}
+
+// dependents returns the bundles containing the plugin and the other plugins requiring it or using its providers
+func (g *DependencyGraph) dependents(pluginID string) []DependencyGraphNode {
+	target := pluginNodeID(pluginID)
+
+	provided := map[string]bool{}
+	for _, edge := range g.Edges {
+		if edge.From == target && edge.Relation == DEPENDENCY_EDGE_PROVIDES {
+			provided[edge.To] = true
+		}
+	}
+
+	dependents := map[string]bool{}
+	for _, edge := range g.Edges {
+		switch {
+		case edge.To == target && (edge.Relation == DEPENDENCY_EDGE_CONTAINS || edge.Relation == DEPENDENCY_EDGE_REQUIRES):
+			dependents[edge.From] = true
+		case provided[edge.To] && edge.Relation == DEPENDENCY_EDGE_USES && edge.From != target:
+			dependents[edge.From] = true
+		}
+	}
+
+	nodes := []DependencyGraphNode{}
+	for _, node := range g.Nodes {
+		if dependents[node.ID] {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// FetchPluginDependencyGraph returns the dependency graph of the tenant, dependents of plugin_id
+// are listed as well if it's given
+func FetchPluginDependencyGraph(tenant_id string, plugin_id string) *entities.Response {
+	graph, err := buildDependencyGraph(tenant_id)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	if plugin_id != "" {
+		graph.Dependents = graph.dependents(plugin_id)
+	}
+
+	return entities.NewSuccessResponse(graph)
+}
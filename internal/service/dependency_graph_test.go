@@ -0,0 +1,68 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDependencyGraphDependents(t *testing.T) {
+	b := &dependencyGraphBuilder{
+		nodes:           map[string]*DependencyGraphNode{},
+		edges:           map[string]DependencyGraphEdge{},
+		providersByName: map[string]map[string][]string{},
+	}
+	for _, pluginID := range []string{"langgenius/openai", "langgenius/agent", "langgenius/search"} {
+		b.nodes[pluginNodeID(pluginID)] = &DependencyGraphNode{
+			ID:        pluginNodeID(pluginID),
+			Type:      DEPENDENCY_NODE_PLUGIN,
+			PluginID:  pluginID,
+			Installed: true,
+		}
+	}
+	b.addProvider(PROVIDER_KIND_MODEL, "langgenius/openai", "", "openai")
+	b.addProvider(PROVIDER_KIND_TOOL, "langgenius/search", "", "search")
+
+	// both the short name and the full name resolve to the installed provider
+	assert.Equal(t, []string{"provider:model:langgenius/openai/openai"}, b.resolveProvider(PROVIDER_KIND_MODEL, "openai"))
+	assert.Equal(t, []string{"provider:model:langgenius/openai/openai"}, b.resolveProvider(PROVIDER_KIND_MODEL, "langgenius/openai/openai"))
+
+	// unknown providers become nodes which are not installed
+	ids := b.resolveProvider(PROVIDER_KIND_MODEL, "anthropic")
+	assert.Equal(t, []string{"provider:model:anthropic"}, ids)
+	assert.False(t, b.nodes[ids[0]].Installed)
+
+	b.addEdge(DependencyGraphEdge{
+		From:     pluginNodeID("langgenius/agent"),
+		To:       "provider:model:langgenius/openai/openai",
+		Relation: DEPENDENCY_EDGE_USES,
+	})
+	b.nodes["bundle:langgenius/starter"] = &DependencyGraphNode{ID: "bundle:langgenius/starter", Type: DEPENDENCY_NODE_BUNDLE}
+	b.addEdge(DependencyGraphEdge{
+		From:     "bundle:langgenius/starter",
+		To:       pluginNodeID("langgenius/openai"),
+		Relation: DEPENDENCY_EDGE_CONTAINS,
+	})
+
+	b.addEdge(DependencyGraphEdge{
+		From:     pluginNodeID("langgenius/search"),
+		To:       pluginNodeID("langgenius/openai"),
+		Relation: DEPENDENCY_EDGE_REQUIRES,
+	})
+
+	graph := &DependencyGraph{}
+	for _, node := range b.nodes {
+		graph.Nodes = append(graph.Nodes, *node)
+	}
+	for _, edge := range b.edges {
+		graph.Edges = append(graph.Edges, edge)
+	}
+
+	dependents := graph.dependents("langgenius/openai")
+	ids = []string{}
+	for _, node := range dependents {
+		ids = append(ids, node.ID)
+	}
+	assert.ElementsMatch(t, []string{"bundle:langgenius/starter", "plugin:langgenius/agent", "plugin:langgenius/search"}, ids)
+	assert.Empty(t, graph.dependents("langgenius/search"))
+}
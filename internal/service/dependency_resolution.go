@@ -0,0 +1,209 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+var ErrDependencyConflict = errors.New("plugin dependencies can't be resolved")
+
+type resolvingPlugin struct {
+	identifier  plugin_entities.PluginUniqueIdentifier
+	declaration *plugin_entities.PluginDeclaration
+}
+
+func (p resolvingPlugin) dependencies() []plugin_entities.PluginDependencyDeclaration {
+	if p.declaration == nil {
+		return nil
+	}
+	return p.declaration.Meta.Dependencies
+}
+
+type dependencyResolution struct {
+	// Order lists the plugins being installed, dependencies come before the plugins requiring them
+	Order []plugin_entities.PluginUniqueIdentifier
+	// DependsOn are the plugins being installed together which a plugin waits for
+	DependsOn map[plugin_entities.PluginUniqueIdentifier][]plugin_entities.PluginUniqueIdentifier
+}
+
+type dependencyResolver struct {
+	// installed plugins of the tenant by plugin id
+	installed map[string]resolvingPlugin
+	// whether dependencies neither installed nor being installed are left to be checked at install time
+	allowMissing bool
+	// whether the plugins replace the installed versions, the installed plugins requiring them must accept them
+	replacing bool
+}
+
+// resolve checks the version constraints of the plugins being installed against each other and the
+// installed plugins, every conflict found is reported at once
+func (r *dependencyResolver) resolve(installing []resolvingPlugin) (*dependencyResolution, error) {
+	conflicts := []string{}
+	byPluginID := map[string]resolvingPlugin{}
+	for _, plugin := range installing {
+		if existing, ok := byPluginID[plugin.identifier.PluginID()]; ok && existing.identifier != plugin.identifier {
+			conflicts = append(conflicts, fmt.Sprintf(
+				"%s is installed with both %s and %s",
+				plugin.identifier.PluginID(), existing.identifier.Version(), plugin.identifier.Version(),
+			))
+			continue
+		}
+		byPluginID[plugin.identifier.PluginID()] = plugin
+	}
+
+	resolution := &dependencyResolution{
+		DependsOn: map[plugin_entities.PluginUniqueIdentifier][]plugin_entities.PluginUniqueIdentifier{},
+	}
+
+	for _, plugin := range installing {
+		for _, dependency := range plugin.dependencies() {
+			required := fmt.Sprintf("%s requires %s", plugin.identifier.PluginID(), dependency.PluginID)
+			if dependency.Version != "" {
+				required += " " + dependency.Version.String()
+			}
+
+			if dependency.PluginID == plugin.identifier.PluginID() {
+				conflicts = append(conflicts, fmt.Sprintf("%s requires itself", dependency.PluginID))
+			} else if provider, ok := byPluginID[dependency.PluginID]; ok {
+				if !dependency.Version.Match(provider.identifier.Version()) {
+					conflicts = append(conflicts, fmt.Sprintf("%s but %s is being installed", required, provider.identifier.Version()))
+					continue
+				}
+				resolution.DependsOn[plugin.identifier] = append(resolution.DependsOn[plugin.identifier], provider.identifier)
+			} else if provider, ok := r.installed[dependency.PluginID]; ok {
+				if !dependency.Version.Match(provider.identifier.Version()) {
+					conflicts = append(conflicts, fmt.Sprintf("%s but %s is installed", required, provider.identifier.Version()))
+				}
+			} else if !r.allowMissing {
+				conflicts = append(conflicts, fmt.Sprintf("%s which is not installed", required))
+			}
+		}
+	}
+
+	if r.replacing {
+		for _, pluginId := range slices.Sorted(maps.Keys(r.installed)) {
+			dependent := r.installed[pluginId]
+			if _, ok := byPluginID[pluginId]; ok {
+				continue
+			}
+			for _, dependency := range dependent.dependencies() {
+				replacement, ok := byPluginID[dependency.PluginID]
+				if ok && !dependency.Version.Match(replacement.identifier.Version()) {
+					conflicts = append(conflicts, fmt.Sprintf(
+						"%s requires %s %s which %s does not satisfy",
+						pluginId, dependency.PluginID, dependency.Version, replacement.identifier.Version(),
+					))
+				}
+			}
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrDependencyConflict, strings.Join(conflicts, "; "))
+	}
+
+	// plugins without pending dependencies go first, the order given is kept otherwise
+	ordered := map[plugin_entities.PluginUniqueIdentifier]bool{}
+	for len(resolution.Order) < len(byPluginID) {
+		progressed := false
+		for _, plugin := range installing {
+			if ordered[plugin.identifier] {
+				continue
+			}
+
+			ready := true
+			for _, dependency := range resolution.DependsOn[plugin.identifier] {
+				if !ordered[dependency] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ordered[plugin.identifier] = true
+				resolution.Order = append(resolution.Order, plugin.identifier)
+				progressed = true
+			}
+		}
+
+		if !progressed {
+			cycle := []string{}
+			for _, plugin := range installing {
+				if !ordered[plugin.identifier] {
+					cycle = append(cycle, plugin.identifier.PluginID())
+				}
+			}
+			return nil, fmt.Errorf("%w: dependency cycle among %s", ErrDependencyConflict, strings.Join(cycle, ", "))
+		}
+	}
+
+	return resolution, nil
+}
+
+// resolvePluginDependencies resolves the install order of plugins being installed to the tenant
+func resolvePluginDependencies(
+	tenant_id string,
+	plugin_unique_identifiers []plugin_entities.PluginUniqueIdentifier,
+	runtimeType plugin_entities.PluginRuntimeType,
+	action models.InstallTaskAction,
+) (*dependencyResolution, error) {
+	installing := make([]resolvingPlugin, 0, len(plugin_unique_identifiers))
+	for _, identifier := range plugin_unique_identifiers {
+		declaration, err := helper.CombinedGetPluginDeclaration(identifier, runtimeType)
+		if err != nil {
+			return nil, err
+		}
+		installing = append(installing, resolvingPlugin{identifier: identifier, declaration: declaration})
+	}
+
+	installed, err := installedPlugins(tenant_id)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver := &dependencyResolver{
+		installed: installed,
+		// a version installed besides the installation replaces nothing
+		replacing: action != models.InstallTaskActionInstallVersion,
+	}
+	return resolver.resolve(installing)
+}
+
+// installedPlugins returns the installations of the tenant by plugin id, the declaration is nil if
+// it's not available anymore
+func installedPlugins(tenant_id string) (map[string]resolvingPlugin, error) {
+	installations, err := db.GetAll[models.PluginInstallation](db.Equal("tenant_id", tenant_id))
+	if err != nil {
+		return nil, err
+	}
+
+	installed := map[string]resolvingPlugin{}
+	for _, installation := range installations {
+		identifier, err := plugin_entities.NewPluginUniqueIdentifier(installation.PluginUniqueIdentifier)
+		if err != nil {
+			continue
+		}
+
+		plugin := resolvingPlugin{identifier: identifier}
+		declaration, err := helper.CombinedGetPluginDeclaration(
+			identifier,
+			plugin_entities.PluginRuntimeType(installation.RuntimeType),
+		)
+		if err != nil {
+			log.Warn("failed to fetch declaration of %s to resolve dependencies: %s", identifier, err.Error())
+		} else {
+			plugin.declaration = declaration
+		}
+		installed[installation.PluginID] = plugin
+	}
+
+	return installed, nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/manifest_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resolvingPluginOf(identifier string, dependencies ...plugin_entities.PluginDependencyDeclaration) resolvingPlugin {
+	declaration := &plugin_entities.PluginDeclaration{}
+	declaration.Meta.Dependencies = dependencies
+	return resolvingPlugin{
+		identifier:  plugin_entities.PluginUniqueIdentifier(identifier),
+		declaration: declaration,
+	}
+}
+
+func requires(pluginId string, constraint string) plugin_entities.PluginDependencyDeclaration {
+	return plugin_entities.PluginDependencyDeclaration{
+		PluginID: pluginId,
+		Version:  manifest_entities.VersionConstraint(constraint),
+	}
+}
+
+const (
+	agentV1  = "langgenius/agent:1.0.0@00000000000000000000000000000001"
+	openaiV1 = "langgenius/openai:1.2.0@00000000000000000000000000000002"
+	openaiV2 = "langgenius/openai:2.0.0@00000000000000000000000000000003"
+	searchV1 = "langgenius/search:1.0.0@00000000000000000000000000000004"
+)
+
+func TestResolveDependenciesOrder(t *testing.T) {
+	resolver := &dependencyResolver{}
+	resolution, err := resolver.resolve([]resolvingPlugin{
+		resolvingPluginOf(agentV1, requires("langgenius/openai", "^1.0.0"), requires("langgenius/search", "")),
+		resolvingPluginOf(searchV1, requires("langgenius/openai", "1.x.x")),
+		resolvingPluginOf(openaiV1),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []plugin_entities.PluginUniqueIdentifier{openaiV1, searchV1, agentV1}, resolution.Order)
+	assert.ElementsMatch(t, []plugin_entities.PluginUniqueIdentifier{openaiV1, searchV1}, resolution.DependsOn[agentV1])
+}
+
+func TestResolveDependenciesConflicts(t *testing.T) {
+	resolver := &dependencyResolver{}
+	_, err := resolver.resolve([]resolvingPlugin{
+		resolvingPluginOf(agentV1, requires("langgenius/openai", "^1.0.0"), requires("langgenius/search", "")),
+		resolvingPluginOf(openaiV2),
+	})
+	assert.ErrorIs(t, err, ErrDependencyConflict)
+	assert.ErrorContains(t, err, "langgenius/agent requires langgenius/openai ^1.0.0 but 2.0.0 is being installed")
+	assert.ErrorContains(t, err, "langgenius/agent requires langgenius/search which is not installed")
+
+	// upgrading a plugin must not break the installed plugins requiring it
+	resolver = &dependencyResolver{
+		installed: map[string]resolvingPlugin{
+			"langgenius/agent":  resolvingPluginOf(agentV1, requires("langgenius/openai", "~1.2.0")),
+			"langgenius/openai": resolvingPluginOf(openaiV1),
+		},
+		replacing: true,
+	}
+	_, err = resolver.resolve([]resolvingPlugin{resolvingPluginOf(openaiV2)})
+	assert.ErrorContains(t, err, "langgenius/agent requires langgenius/openai ~1.2.0 which 2.0.0 does not satisfy")
+
+	resolver.replacing = false
+	_, err = resolver.resolve([]resolvingPlugin{resolvingPluginOf(openaiV2)})
+	assert.NoError(t, err)
+}
+
+func TestResolveDependenciesCycle(t *testing.T) {
+	resolver := &dependencyResolver{}
+	_, err := resolver.resolve([]resolvingPlugin{
+		resolvingPluginOf(agentV1, requires("langgenius/search", "")),
+		resolvingPluginOf(searchV1, requires("langgenius/agent", "")),
+	})
+	assert.ErrorIs(t, err, ErrDependencyConflict)
+	assert.ErrorContains(t, err, "dependency cycle among langgenius/agent, langgenius/search")
+}
@@ -7,17 +7,23 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/event_bus"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/tenant_variables"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/server/constants"
 	"github.com/langgenius/dify-plugin-daemon/internal/service/install_service"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
@@ -25,12 +31,18 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/encryption"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/endpoint_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
 )
 
+const (
+	// ENDPOINT_SSE_KEEPALIVE_INTERVAL keeps idle event streams from being closed by proxies
+	ENDPOINT_SSE_KEEPALIVE_INTERVAL = 15 * time.Second
+)
+
 func copyRequest(req *http.Request, hookId string, path string) (*bytes.Buffer, error) {
 	newReq := req.Clone(context.Background())
 	// get query params
@@ -97,12 +109,6 @@ func Endpoint(
 		return
 	}
 
-	buffer, err := copyRequest(ctx.Request, endpoint.HookID, path)
-	if err != nil {
-		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
-		return
-	}
-
 	identifier, err := plugin_entities.NewPluginUniqueIdentifier(pluginInstallation.PluginUniqueIdentifier)
 	if err != nil {
 		ctx.JSON(400, exception.UniqueIdentifierError(err).ToResponse())
@@ -124,6 +130,23 @@ func Endpoint(
 		return
 	}
 
+	// preflight requests and disallowed origins are answered without invoking the plugin
+	cors, answered := handleEndpointCORS(ctx, endpointDeclaration, path)
+	if answered {
+		return
+	}
+
+	// the middleware chain of the endpoint may reject or rewrite the request
+	middlewares, err := openEndpointMiddlewares(endpoint)
+	if err != nil {
+		ctx.JSON(500, exception.InternalServerError(fmt.Errorf("failed to decrypt endpoint middlewares: %v", err)).ToResponse())
+		return
+	}
+	tenantMiddlewares, answered := applyEndpointMiddlewares(ctx, endpoint.TenantID, nil, middlewares)
+	if answered {
+		return
+	}
+
 	// decrypt settings
 	settings, err := manager.BackwardsInvocation().InvokeEncrypt(&dify_invocation.InvokeEncryptRequest{
 		BaseInvokeDifyRequest: dify_invocation.BaseInvokeDifyRequest{
@@ -145,6 +168,28 @@ func Endpoint(
 		return
 	}
 
+	settings, err = tenant_variables.Resolve(endpoint.TenantID, settings)
+	if err != nil {
+		ctx.JSON(400, exception.BadRequestError(err).ToResponse())
+		return
+	}
+
+	// middlewares declared by the plugin for the route may refer to the settings of the endpoint
+	route, params := endpointDeclaration.Match(ctx.Request.Method, path)
+	var routeMiddlewares []endpoint_entities.EndpointMiddleware
+	if route != nil {
+		routeMiddlewares, answered = applyEndpointMiddlewares(ctx, endpoint.TenantID, settings, route.Middlewares)
+		if answered {
+			return
+		}
+	}
+
+	buffer, err := copyRequest(ctx.Request, endpoint.HookID, path)
+	if err != nil {
+		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
+		return
+	}
+
 	session := session_manager.NewSession(
 		session_manager.NewSessionPayload{
 			TenantID:               endpoint.TenantID,
@@ -157,6 +202,8 @@ func Endpoint(
 			BackwardsInvocation:    manager.BackwardsInvocation(),
 			IgnoreCache:            false,
 			EndpointID:             &endpoint.ID,
+			TraceContext:           tracing.Inject(ctx.Request.Context()),
+			RequestContext:         ctx.Request.Context(),
 		},
 	)
 	defer session.Close(session_manager.CloseSessionPayload{
@@ -165,13 +212,19 @@ func Endpoint(
 
 	session.BindRuntime(runtime)
 
-	statusCode, headers, response, err := plugin_daemon.InvokeEndpoint(
-		session, &requests.RequestInvokeEndpoint{
-			RawHttpRequest: hex.EncodeToString(buffer.Bytes()),
-			Settings:       settings,
-		},
-	)
+	invokeRequest := &requests.RequestInvokeEndpoint{
+		RawHttpRequest: hex.EncodeToString(buffer.Bytes()),
+		Settings:       settings,
+	}
+	if route != nil {
+		invokeRequest.Route = route.Path
+		invokeRequest.PathParams = params
+	}
+
+	statusCode, headers, response, err := plugin_daemon.InvokeEndpoint(session, invokeRequest)
 	if err != nil {
+		session.Fail(exception.PluginInvokeError, err.Error())
+		publishEndpointError(endpoint, identifier, path, err.Error())
 		ctx.JSON(500, exception.InternalServerError(err).ToResponse())
 		return
 	}
@@ -186,6 +239,15 @@ func Endpoint(
 			ctx.Writer.Header().Set(k, v[0])
 		}
 	}
+	applyEndpointResponseMiddlewares(ctx.Writer.Header(), tenantMiddlewares, routeMiddlewares)
+	applyEndpointCORS(ctx.Writer.Header(), cors, ctx.GetHeader("Origin"))
+
+	streaming, sse := prepareEndpointStream(ctx.Writer.Header())
+	if streaming {
+		// clients of a stream get the headers before the first chunk
+		ctx.Writer.WriteHeaderNow()
+		ctx.Writer.Flush()
+	}
 
 	close := func() {
 		if atomic.CompareAndSwapInt32(closed, 0, 1) {
@@ -194,6 +256,12 @@ func Endpoint(
 	}
 	defer close()
 
+	// writes of chunks and keepalives of event streams must not interleave, keepalives are only
+	// written between events so that an event split into chunks stays intact
+	var writeLock sync.Mutex
+	betweenEvents := true
+	activity := make(chan struct{}, 1)
+
 	routine.Submit(map[string]string{
 		"module":   "service",
 		"function": "Endpoint",
@@ -201,22 +269,121 @@ func Endpoint(
 		defer close()
 		for response.Next() {
 			chunk, err := response.Read()
+			writeLock.Lock()
 			if err != nil {
+				session.Fail(exception.PluginInvokeError, err.Error())
+				publishEndpointError(endpoint, identifier, path, err.Error())
 				ctx.Writer.Write([]byte(err.Error()))
 				ctx.Writer.Flush()
+				writeLock.Unlock()
+				return
+			}
+			if _, err := ctx.Writer.Write(chunk); err != nil {
+				// the caller is gone, the plugin is aborted rather than buffering chunks nobody reads
+				writeLock.Unlock()
+				session.Fail(exception.PluginConnectionClosedError, "caller disconnected")
+				response.Close()
 				return
 			}
-			ctx.Writer.Write(chunk)
 			ctx.Writer.Flush()
+			if len(chunk) > 0 {
+				betweenEvents = bytes.HasSuffix(chunk, []byte("\n\n")) || bytes.HasSuffix(chunk, []byte("\r\n\r\n"))
+			}
+			writeLock.Unlock()
+			session.RecordDelivered()
+
+			select {
+			case activity <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	// streams are only killed once the plugin has been silent for maxExecutionTime, or for the stream
+	// idle timeout of the route once it started
+	idleTimeout := maxExecutionTime
+	if d := ctx.GetDuration(constants.CONTEXT_KEY_STREAM_IDLE_TIMEOUT); d > 0 {
+		idleTimeout = d
+	}
+	timeout := time.NewTimer(maxExecutionTime)
+	defer timeout.Stop()
+
+	var keepalive <-chan time.Time
+	if sse {
+		ticker := time.NewTicker(ENDPOINT_SSE_KEEPALIVE_INTERVAL)
+		defer ticker.Stop()
+		keepalive = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Writer.CloseNotify():
+			session.Fail(exception.PluginConnectionClosedError, "caller disconnected")
+			return
+		case <-done:
+			return
+		case <-session.Cancelled():
+			response.Close()
+			publishEndpointError(endpoint, identifier, path, "session cancelled")
+			writeLock.Lock()
+			if !ctx.Writer.Written() {
+				ctx.JSON(409, exception.SessionCancelledError("session cancelled").ToResponse())
+			}
+			writeLock.Unlock()
+			return
+		case <-activity:
+			if streaming {
+				timeout.Reset(idleTimeout)
+			}
+		case <-keepalive:
+			writeLock.Lock()
+			if betweenEvents {
+				ctx.Writer.Write([]byte(": keepalive\n\n"))
+				ctx.Writer.Flush()
+			}
+			writeLock.Unlock()
+		case <-timeout.C:
+			session.Fail(exception.PluginDaemonInternalServerError, "killed by timeout")
+			publishEndpointError(endpoint, identifier, path, "killed by timeout")
+			if !streaming {
+				ctx.JSON(500, exception.InternalServerError(errors.New("killed by timeout")).ToResponse())
+			}
+			return
 		}
+	}
+}
+
+func publishEndpointError(
+	endpoint *models.Endpoint,
+	identifier plugin_entities.PluginUniqueIdentifier,
+	path string,
+	message string,
+) {
+	event_bus.Publish(event_bus.EVENT_ENDPOINT_ERRORED, endpoint.TenantID, identifier.String(), map[string]any{
+		"endpoint_id": endpoint.ID,
+		"hook_id":     endpoint.HookID,
+		"path":        path,
+		"error":       message,
 	})
+}
 
-	select {
-	case <-ctx.Writer.CloseNotify():
-	case <-done:
-	case <-time.After(maxExecutionTime):
-		ctx.JSON(500, exception.InternalServerError(errors.New("killed by timeout")).ToResponse())
+// prepareEndpointStream reports whether the plugin streams the response as server-sent events or
+// chunks, a stream has no length and proxies in front of the daemon are told not to buffer it
+func prepareEndpointStream(header http.Header) (streaming bool, sse bool) {
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	sse = mediaType == "text/event-stream"
+	streaming = sse || strings.EqualFold(header.Get("Transfer-Encoding"), "chunked")
+	if !streaming {
+		return false, false
 	}
+
+	header.Del("Content-Length")
+	header.Del("Transfer-Encoding")
+	header.Set("X-Accel-Buffering", "no")
+	if sse && header.Get("Cache-Control") == "" {
+		header.Set("Cache-Control", "no-cache")
+	}
+	return streaming, sse
 }
 
 func EnableEndpoint(endpoint_id string, tenant_id string) *entities.Response {
@@ -258,6 +425,8 @@ func ListEndpoints(tenant_id string, page int, page_size int) *entities.Response
 
 	// decrypt settings
 	for i, endpoint := range endpoints {
+		endpoint.Middlewares = maskEndpointMiddlewares(endpoint.Middlewares)
+
 		pluginInstallation, err := db.GetOne[models.PluginInstallation](
 			db.Equal("plugin_id", endpoint.PluginID),
 			db.Equal("tenant_id", tenant_id),
@@ -352,6 +521,8 @@ func ListPluginEndpoints(tenant_id string, plugin_id string, page int, page_size
 
 	// decrypt settings
 	for i, endpoint := range endpoints {
+		endpoint.Middlewares = maskEndpointMiddlewares(endpoint.Middlewares)
+
 		// get installation
 		pluginInstallation, err := db.GetOne[models.PluginInstallation](
 			db.Equal("plugin_id", plugin_id),
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// handleEndpointCORS enforces the cors settings of the declaration a request matches, it returns
+// the settings to apply to the response of the plugin, answered is true if the request was answered
+func handleEndpointCORS(
+	ctx *gin.Context,
+	declaration *plugin_entities.EndpointProviderDeclaration,
+	path string,
+) (cors *plugin_entities.EndpointCORS, answered bool) {
+	origin := ctx.GetHeader("Origin")
+	if origin == "" {
+		return nil, false
+	}
+
+	requestedMethod := ctx.GetHeader("Access-Control-Request-Method")
+	if ctx.Request.Method == http.MethodOptions && requestedMethod != "" {
+		route, _ := declaration.Match(requestedMethod, path)
+		if route == nil || route.CORS == nil {
+			return nil, false
+		}
+		answerEndpointPreflight(ctx, route, origin, requestedMethod)
+		return nil, true
+	}
+
+	route, _ := declaration.Match(ctx.Request.Method, path)
+	if route == nil || route.CORS == nil {
+		return nil, false
+	}
+	if !route.CORS.AllowOrigin(origin) {
+		ctx.JSON(http.StatusForbidden, exception.PermissionDeniedError("origin not allowed").ToResponse())
+		return nil, true
+	}
+
+	return route.CORS, false
+}
+
+func answerEndpointPreflight(
+	ctx *gin.Context,
+	route *plugin_entities.EndpointDeclaration,
+	origin string,
+	requestedMethod string,
+) {
+	cors := route.CORS
+	requestedHeaders := ctx.GetHeader("Access-Control-Request-Headers")
+
+	header := ctx.Writer.Header()
+	header.Add("Vary", "Origin")
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+
+	if !cors.AllowOrigin(origin) || !cors.AllowMethod(route.Method, requestedMethod) || !cors.AllowHeaders(requestedHeaders) {
+		ctx.Status(http.StatusForbidden)
+		return
+	}
+
+	setEndpointCORSOrigin(header, cors, origin)
+
+	methods := []string{}
+	for _, method := range cors.Methods(route.Method) {
+		methods = append(methods, string(method))
+	}
+	header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if requestedHeaders != "" {
+		header.Set("Access-Control-Allow-Headers", requestedHeaders)
+	}
+	if cors.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(cors.MaxAge))
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+// applyEndpointCORS overrides whatever cors headers the plugin responded with
+func applyEndpointCORS(header http.Header, cors *plugin_entities.EndpointCORS, origin string) {
+	if cors == nil {
+		return
+	}
+
+	setEndpointCORSOrigin(header, cors, origin)
+	if len(cors.ExposedHeaders) > 0 {
+		header.Set("Access-Control-Expose-Headers", strings.Join(cors.ExposedHeaders, ", "))
+	} else {
+		header.Del("Access-Control-Expose-Headers")
+	}
+	header.Add("Vary", "Origin")
+}
+
+func setEndpointCORSOrigin(header http.Header, cors *plugin_entities.EndpointCORS, origin string) {
+	header.Set("Access-Control-Allow-Origin", origin)
+	if cors.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	} else {
+		header.Del("Access-Control-Allow-Credentials")
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func corsTestDeclaration() *plugin_entities.EndpointProviderDeclaration {
+	return &plugin_entities.EndpointProviderDeclaration{
+		Endpoints: []plugin_entities.EndpointDeclaration{
+			{
+				Path:   "/items/:id",
+				Method: plugin_entities.EndpointMethodPost,
+				CORS: &plugin_entities.EndpointCORS{
+					AllowedOrigins:   []string{"https://app.example.com"},
+					AllowedHeaders:   []string{"Content-Type"},
+					ExposedHeaders:   []string{"X-Request-Id"},
+					AllowCredentials: true,
+					MaxAge:           600,
+				},
+			},
+			{Path: "/webhook", Method: plugin_entities.EndpointMethodPost},
+		},
+	}
+}
+
+func corsTestContext(method string, headers map[string]string) (*gin.Context, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Request = httptest.NewRequest(method, "/e/hook/items/1", nil)
+	for key, value := range headers {
+		ctx.Request.Header.Set(key, value)
+	}
+	return ctx, recorder
+}
+
+func TestEndpointPreflight(t *testing.T) {
+	ctx, recorder := corsTestContext(http.MethodOptions, map[string]string{
+		"Origin":                         "https://app.example.com",
+		"Access-Control-Request-Method":  "POST",
+		"Access-Control-Request-Headers": "content-type",
+	})
+	_, answered := handleEndpointCORS(ctx, corsTestDeclaration(), "/items/1")
+	ctx.Writer.WriteHeaderNow()
+
+	if !answered || recorder.Code != http.StatusNoContent {
+		t.Fatalf("preflight should be answered with 204, got %v %d", answered, recorder.Code)
+	}
+	header := recorder.Header()
+	if header.Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		header.Get("Access-Control-Allow-Methods") != "POST" ||
+		header.Get("Access-Control-Allow-Headers") != "content-type" ||
+		header.Get("Access-Control-Allow-Credentials") != "true" ||
+		header.Get("Access-Control-Max-Age") != "600" {
+		t.Fatalf("unexpected preflight headers %v", header)
+	}
+
+	ctx, recorder = corsTestContext(http.MethodOptions, map[string]string{
+		"Origin":                         "https://app.example.com",
+		"Access-Control-Request-Method":  "POST",
+		"Access-Control-Request-Headers": "authorization",
+	})
+	_, answered = handleEndpointCORS(ctx, corsTestDeclaration(), "/items/1")
+	ctx.Writer.WriteHeaderNow()
+	if !answered || recorder.Code != http.StatusForbidden || recorder.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("preflight with a header not allowed should be rejected, got %d", recorder.Code)
+	}
+}
+
+func TestEndpointCORSRequest(t *testing.T) {
+	ctx, recorder := corsTestContext(http.MethodPost, map[string]string{"Origin": "https://evil.example.com"})
+	_, answered := handleEndpointCORS(ctx, corsTestDeclaration(), "/items/1")
+	if !answered || recorder.Code != http.StatusForbidden {
+		t.Fatalf("disallowed origin should be rejected, got %d", recorder.Code)
+	}
+
+	ctx, _ = corsTestContext(http.MethodPost, map[string]string{"Origin": "https://app.example.com"})
+	cors, answered := handleEndpointCORS(ctx, corsTestDeclaration(), "/items/1")
+	if answered || cors == nil {
+		t.Fatal("allowed origin should be passed to the plugin")
+	}
+	header := http.Header{"Access-Control-Allow-Origin": []string{"*"}}
+	applyEndpointCORS(header, cors, "https://app.example.com")
+	if header.Get("Access-Control-Allow-Origin") != "https://app.example.com" || header.Get("Access-Control-Expose-Headers") != "X-Request-Id" {
+		t.Fatalf("cors headers of the plugin should be overridden, got %v", header)
+	}
+
+	// declarations without cors keep passing everything to the plugin
+	ctx, _ = corsTestContext(http.MethodOptions, map[string]string{
+		"Origin":                        "https://evil.example.com",
+		"Access-Control-Request-Method": "POST",
+	})
+	cors, answered = handleEndpointCORS(ctx, corsTestDeclaration(), "/webhook")
+	if answered || cors != nil {
+		t.Fatal("requests to endpoints without cors should be passed through")
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/endpoint_domains"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+// SetEndpointDomain exposes an endpoint of the tenant on a custom hostname and path prefix
+func SetEndpointDomain(endpoint_id string, tenant_id string, hostname string, path_prefix string) *entities.Response {
+	endpoint, err := db.GetOne[models.Endpoint](
+		db.Equal("id", endpoint_id),
+		db.Equal("tenant_id", tenant_id),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return exception.NotFoundError(errors.New("endpoint not found")).ToResponse()
+	}
+	if err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to find endpoint: %v", err)).ToResponse()
+	}
+
+	domain, err := endpoint_domains.Set(&endpoint, hostname, path_prefix)
+	switch {
+	case errors.Is(err, endpoint_domains.ErrDisabled),
+		errors.Is(err, endpoint_domains.ErrHostnameNotAllowed),
+		errors.Is(err, endpoint_domains.ErrInvalidPathPrefix),
+		errors.Is(err, endpoint_domains.ErrDomainTaken),
+		errors.Is(err, endpoint_domains.ErrHostnameOwned):
+		return exception.BadRequestError(err).ToResponse()
+	case err != nil:
+		return exception.InternalServerError(fmt.Errorf("failed to set endpoint domain: %v", err)).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(domain)
+}
+
+func RemoveEndpointDomain(domain_id string, tenant_id string) *entities.Response {
+	err := endpoint_domains.Remove(tenant_id, domain_id)
+	if errors.Is(err, endpoint_domains.ErrDomainNotFound) {
+		return exception.NotFoundError(err).ToResponse()
+	}
+	if err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to remove endpoint domain: %v", err)).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(true)
+}
+
+func ListEndpointDomains(endpoint_id string, tenant_id string) *entities.Response {
+	domains, err := endpoint_domains.List(tenant_id, endpoint_id)
+	if err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to list endpoint domains: %v", err)).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(domains)
+}
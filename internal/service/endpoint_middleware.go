@@ -0,0 +1,416 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/tenant_variables"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/service/install_service"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/endpoint_entities"
+)
+
+const (
+	ENDPOINT_BASIC_AUTH_DEFAULT_REALM = "endpoint"
+	// ENDPOINT_SIGNATURE_DEFAULT_MAX_SKEW is the age in seconds of the oldest signed timestamp accepted
+	ENDPOINT_SIGNATURE_DEFAULT_MAX_SKEW = 300
+	// ENDPOINT_MIDDLEWARE_SECRET_PREFIX marks stored passwords and secrets which are encrypted
+	ENDPOINT_MIDDLEWARE_SECRET_PREFIX = "encrypted:"
+)
+
+var settingsReferencePattern = regexp.MustCompile(`\{\{\s*settings\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// applyEndpointMiddlewares runs the middleware chain of an endpoint in order on the request and
+// returns it resolved for the response, answered is true if a middleware rejected the request,
+// settings are those of the endpoint for chains declared by the plugin and nil otherwise
+func applyEndpointMiddlewares(
+	ctx *gin.Context,
+	tenantID string,
+	settings map[string]any,
+	middlewares []endpoint_entities.EndpointMiddleware,
+) (resolved []endpoint_entities.EndpointMiddleware, answered bool) {
+	if len(middlewares) == 0 {
+		return nil, false
+	}
+
+	middlewares, err := resolveEndpointMiddlewares(tenantID, settings, middlewares)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, exception.BadRequestError(err).ToResponse())
+		return nil, true
+	}
+
+	for _, middleware := range middlewares {
+		passed := true
+		switch middleware.Type {
+		case endpoint_entities.ENDPOINT_MIDDLEWARE_SET_HEADERS:
+			setEndpointHeaders(ctx.Request.Header, middleware.Headers)
+		case endpoint_entities.ENDPOINT_MIDDLEWARE_BASIC_AUTH:
+			passed = checkEndpointBasicAuth(ctx, middleware)
+		case endpoint_entities.ENDPOINT_MIDDLEWARE_BODY_LIMIT:
+			passed = limitEndpointBody(ctx, middleware.MaxBodyBytes)
+		case endpoint_entities.ENDPOINT_MIDDLEWARE_IP_FILTER:
+			passed = filterEndpointIP(ctx, middleware)
+		case endpoint_entities.ENDPOINT_MIDDLEWARE_HMAC_SIGNATURE:
+			passed = verifyEndpointSignature(ctx, middleware, time.Now())
+		}
+		if !passed {
+			return nil, true
+		}
+	}
+
+	return middlewares, false
+}
+
+// applyEndpointResponseMiddlewares sets the response headers of the chains on the response of the plugin
+func applyEndpointResponseMiddlewares(header http.Header, chains ...[]endpoint_entities.EndpointMiddleware) {
+	for _, middlewares := range chains {
+		for _, middleware := range middlewares {
+			if middleware.Type == endpoint_entities.ENDPOINT_MIDDLEWARE_SET_RESPONSE_HEADERS {
+				setEndpointHeaders(header, middleware.Headers)
+			}
+		}
+	}
+}
+
+// resolveEndpointMiddlewares replaces references to tenant variables in the chain of the tenant, chains
+// declared by the plugin may only reference the settings of the endpoint
+func resolveEndpointMiddlewares(
+	tenantID string,
+	settings map[string]any,
+	middlewares []endpoint_entities.EndpointMiddleware,
+) ([]endpoint_entities.EndpointMiddleware, error) {
+	raw, err := parser.UnmarshalJsonBytes2Map(parser.MarshalJsonBytes(map[string]any{
+		"middlewares": middlewares,
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	var resolved map[string]any
+	if settings != nil {
+		// plugins must not get secrets of the tenant into headers or checks they control
+		if err := endpoint_entities.CheckDeclaredReferences(middlewares); err != nil {
+			return nil, err
+		}
+		interpolated, err := interpolateEndpointSettings(raw, settings)
+		if err != nil {
+			return nil, err
+		}
+		resolved = interpolated.(map[string]any)
+	} else {
+		resolved, err = tenant_variables.Resolve(tenantID, raw)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return parser.UnmarshalJsonBytes2Slice[endpoint_entities.EndpointMiddleware](
+		parser.MarshalJsonBytes(resolved["middlewares"]),
+	)
+}
+
+// interpolateEndpointSettings replaces {{settings.NAME}} in strings nested in value, a setting which
+// is not set is an error so that checks like signatures never run with an empty secret
+func interpolateEndpointSettings(value any, settings map[string]any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		var missing error
+		result := settingsReferencePattern.ReplaceAllStringFunc(v, func(reference string) string {
+			name := settingsReferencePattern.FindStringSubmatch(reference)[1]
+			setting, ok := settings[name]
+			if !ok || setting == nil || setting == "" {
+				if missing == nil {
+					missing = fmt.Errorf("setting %s of the endpoint is not set", name)
+				}
+				return ""
+			}
+			return fmt.Sprint(setting)
+		})
+		return result, missing
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, item := range v {
+			interpolated, err := interpolateEndpointSettings(item, settings)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = interpolated
+		}
+		return result, nil
+	case []any:
+		result := make([]any, len(v))
+		for i, item := range v {
+			interpolated, err := interpolateEndpointSettings(item, settings)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = interpolated
+		}
+		return result, nil
+	}
+	return value, nil
+}
+
+func setEndpointHeaders(header http.Header, headers map[string]string) {
+	for key, value := range headers {
+		if value == "" {
+			header.Del(key)
+		} else {
+			header.Set(key, value)
+		}
+	}
+}
+
+// checkEndpointBasicAuth terminates basic auth, the plugin never sees the credential
+func checkEndpointBasicAuth(ctx *gin.Context, middleware endpoint_entities.EndpointMiddleware) bool {
+	username, password, ok := ctx.Request.BasicAuth()
+	usernameMatched := subtle.ConstantTimeCompare([]byte(username), []byte(middleware.Username)) == 1
+	passwordMatched := subtle.ConstantTimeCompare([]byte(password), []byte(middleware.Password)) == 1
+	if !ok || !usernameMatched || !passwordMatched {
+		realm := middleware.Realm
+		if realm == "" {
+			realm = ENDPOINT_BASIC_AUTH_DEFAULT_REALM
+		}
+		ctx.Header("WWW-Authenticate", fmt.Sprintf(`Basic realm="%s"`, realm))
+		ctx.JSON(http.StatusUnauthorized, exception.UnauthorizedError().ToResponse())
+		return false
+	}
+
+	ctx.Request.Header.Del("Authorization")
+	return true
+}
+
+// limitEndpointBody reads the body up to the limit, larger bodies are rejected instead of truncated
+func limitEndpointBody(ctx *gin.Context, maxBytes int64) bool {
+	tooLarge := func() bool {
+		ctx.JSON(http.StatusRequestEntityTooLarge, exception.RequestTooLargeError(maxBytes).ToResponse())
+		return false
+	}
+
+	if ctx.Request.ContentLength > maxBytes {
+		return tooLarge()
+	}
+	if ctx.Request.Body == nil {
+		return true
+	}
+
+	body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, maxBytes+1))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, exception.BadRequestError(err).ToResponse())
+		return false
+	}
+	if int64(len(body)) > maxBytes {
+		return tooLarge()
+	}
+
+	ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+	return true
+}
+
+func filterEndpointIP(ctx *gin.Context, middleware endpoint_entities.EndpointMiddleware) bool {
+	ip := net.ParseIP(ctx.ClientIP())
+	allowed := ip != nil &&
+		!endpoint_entities.MatchIP(ip, middleware.DeniedIPs) &&
+		(len(middleware.AllowedIPs) == 0 || endpoint_entities.MatchIP(ip, middleware.AllowedIPs))
+	if !allowed {
+		ctx.JSON(http.StatusForbidden, exception.PermissionDeniedError("ip not allowed").ToResponse())
+		return false
+	}
+	return true
+}
+
+// verifyEndpointSignature checks the hmac of the body, the body is kept for the plugin
+func verifyEndpointSignature(ctx *gin.Context, middleware endpoint_entities.EndpointMiddleware, now time.Time) bool {
+	reject := func(reason string) bool {
+		ctx.JSON(http.StatusUnauthorized, exception.PermissionDeniedError(reason).ToResponse())
+		return false
+	}
+
+	signature := strings.TrimPrefix(ctx.GetHeader(middleware.SignatureHeader), middleware.SignaturePrefix)
+	if signature == "" || middleware.Secret == "" {
+		return reject("missing signature")
+	}
+
+	var body []byte
+	if ctx.Request.Body != nil {
+		var err error
+		body, err = io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, exception.BadRequestError(err).ToResponse())
+			return false
+		}
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	payload := body
+	if middleware.TimestampHeader != "" {
+		timestamp := ctx.GetHeader(middleware.TimestampHeader)
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return reject("missing timestamp")
+		}
+		maxSkew := middleware.MaxSkewSeconds
+		if maxSkew == 0 {
+			maxSkew = ENDPOINT_SIGNATURE_DEFAULT_MAX_SKEW
+		}
+		if skew := now.Unix() - seconds; skew > maxSkew || skew < -maxSkew {
+			return reject("timestamp out of range")
+		}
+		payload = append([]byte(timestamp+"."), body...)
+	}
+
+	algorithm := sha256.New
+	switch middleware.Algorithm {
+	case endpoint_entities.HMAC_ALGORITHM_SHA1:
+		algorithm = sha1.New
+	case endpoint_entities.HMAC_ALGORITHM_SHA512:
+		algorithm = sha512.New
+	}
+	mac := hmac.New(algorithm, []byte(middleware.Secret))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+
+	var received []byte
+	var err error
+	if middleware.Encoding == endpoint_entities.HMAC_ENCODING_BASE64 {
+		received, err = base64.StdEncoding.DecodeString(signature)
+	} else {
+		received, err = hex.DecodeString(strings.ToLower(signature))
+	}
+	if err != nil || !hmac.Equal(received, expected) {
+		return reject("invalid signature")
+	}
+	return true
+}
+
+func maskEndpointMiddlewares(middlewares []endpoint_entities.EndpointMiddleware) []endpoint_entities.EndpointMiddleware {
+	masked := make([]endpoint_entities.EndpointMiddleware, 0, len(middlewares))
+	for _, middleware := range middlewares {
+		masked = append(masked, middleware.Masked())
+	}
+	return masked
+}
+
+// SetEndpointMiddlewares replaces the middleware chain of an endpoint, a masked password or secret keeps
+// the one of the middleware at the same position
+func SetEndpointMiddlewares(
+	endpoint_id string,
+	tenant_id string,
+	middlewares []endpoint_entities.EndpointMiddleware,
+) *entities.Response {
+	endpoint, err := db.GetOne[models.Endpoint](
+		db.Equal("id", endpoint_id),
+		db.Equal("tenant_id", tenant_id),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return exception.NotFoundError(errors.New("endpoint not found")).ToResponse()
+	}
+	if err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to find endpoint: %v", err)).ToResponse()
+	}
+
+	for i := range middlewares {
+		maskedPassword := middlewares[i].Password == endpoint_entities.ENDPOINT_MIDDLEWARE_PASSWORD_MASK
+		maskedSecret := middlewares[i].Secret == endpoint_entities.ENDPOINT_MIDDLEWARE_PASSWORD_MASK
+		if !maskedPassword && !maskedSecret {
+			continue
+		}
+		if i >= len(endpoint.Middlewares) || endpoint.Middlewares[i].Type != middlewares[i].Type {
+			return exception.BadRequestError(
+				fmt.Errorf("middleware %d has a masked password or secret but nothing to keep", i),
+			).ToResponse()
+		}
+		if maskedPassword {
+			middlewares[i].Password = endpoint.Middlewares[i].Password
+		}
+		if maskedSecret {
+			middlewares[i].Secret = endpoint.Middlewares[i].Secret
+		}
+	}
+
+	if err := sealEndpointMiddlewares(&endpoint, middlewares); err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+
+	if err := install_service.UpdateEndpointMiddlewares(&endpoint, middlewares); err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to update endpoint: %v", err)).ToResponse()
+	}
+
+	// invalidate endpoint cache
+	_, _ = cache.AutoDelete[models.Endpoint](helper.EndpointCacheKey(endpoint.HookID))
+
+	return entities.NewSuccessResponse(true)
+}
+
+// sealEndpointMiddlewares encrypts passwords and secrets of a chain before it's stored in the endpoint,
+// the ones kept from the stored chain are encrypted already
+func sealEndpointMiddlewares(endpoint *models.Endpoint, middlewares []endpoint_entities.EndpointMiddleware) error {
+	seal := func(value *string) error {
+		if *value == "" || strings.HasPrefix(*value, ENDPOINT_MIDDLEWARE_SECRET_PREFIX) {
+			return nil
+		}
+		encrypted, err := tenant_variables.EncryptSecret(endpoint.TenantID, endpoint.ID, *value)
+		if err != nil {
+			return err
+		}
+		*value = ENDPOINT_MIDDLEWARE_SECRET_PREFIX + encrypted
+		return nil
+	}
+
+	for i := range middlewares {
+		if err := seal(&middlewares[i].Password); err != nil {
+			return err
+		}
+		if err := seal(&middlewares[i].Secret); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openEndpointMiddlewares decrypts passwords and secrets of the chain of an endpoint into a copy, chains
+// stored before they were encrypted are read as they are
+func openEndpointMiddlewares(endpoint *models.Endpoint) ([]endpoint_entities.EndpointMiddleware, error) {
+	open := func(value string) (string, error) {
+		encrypted, ok := strings.CutPrefix(value, ENDPOINT_MIDDLEWARE_SECRET_PREFIX)
+		if !ok {
+			return value, nil
+		}
+		return tenant_variables.DecryptSecret(endpoint.TenantID, endpoint.ID, encrypted)
+	}
+
+	opened := slices.Clone(endpoint.Middlewares)
+	for i := range opened {
+		var err error
+		if opened[i].Password, err = open(opened[i].Password); err != nil {
+			return nil, err
+		}
+		if opened[i].Secret, err = open(opened[i].Secret); err != nil {
+			return nil, err
+		}
+	}
+	return opened, nil
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/tenant_variables"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/endpoint_entities"
+)
+
+func middlewareTestContext(body string) (*gin.Context, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/e/hook/webhook", strings.NewReader(body))
+	ctx.Request.RemoteAddr = "192.168.1.20:40000"
+	return ctx, recorder
+}
+
+func TestEndpointMiddlewaresRewriteRequest(t *testing.T) {
+	ctx, _ := middlewareTestContext("payload")
+	ctx.Request.SetBasicAuth("dify", "hunter2")
+	ctx.Request.Header.Set("X-Debug", "1")
+
+	_, answered := applyEndpointMiddlewares(ctx, "tenant", nil, []endpoint_entities.EndpointMiddleware{
+		{Type: endpoint_entities.ENDPOINT_MIDDLEWARE_IP_FILTER, AllowedIPs: []string{"192.168.1.0/24"}},
+		{Type: endpoint_entities.ENDPOINT_MIDDLEWARE_BASIC_AUTH, Username: "dify", Password: "hunter2"},
+		{Type: endpoint_entities.ENDPOINT_MIDDLEWARE_BODY_LIMIT, MaxBodyBytes: 16},
+		{Type: endpoint_entities.ENDPOINT_MIDDLEWARE_SET_HEADERS, Headers: map[string]string{
+			"X-Tenant": "tenant",
+			"X-Debug":  "",
+		}},
+	})
+	if answered {
+		t.Fatal("request should pass the chain")
+	}
+
+	header := ctx.Request.Header
+	if header.Get("Authorization") != "" {
+		t.Error("basic credential should be removed")
+	}
+	if header.Get("X-Tenant") != "tenant" || header.Get("X-Debug") != "" {
+		t.Errorf("headers not rewritten: %v", header)
+	}
+	if body, _ := io.ReadAll(ctx.Request.Body); string(body) != "payload" {
+		t.Errorf("body should be kept, got %s", body)
+	}
+}
+
+func TestEndpointMiddlewaresReject(t *testing.T) {
+	cases := []struct {
+		name       string
+		middleware endpoint_entities.EndpointMiddleware
+		status     int
+	}{
+		{
+			"denied ip",
+			endpoint_entities.EndpointMiddleware{Type: endpoint_entities.ENDPOINT_MIDDLEWARE_IP_FILTER, DeniedIPs: []string{"192.168.1.20"}},
+			http.StatusForbidden,
+		},
+		{
+			"ip not allowed",
+			endpoint_entities.EndpointMiddleware{Type: endpoint_entities.ENDPOINT_MIDDLEWARE_IP_FILTER, AllowedIPs: []string{"10.0.0.0/8"}},
+			http.StatusForbidden,
+		},
+		{
+			"missing credential",
+			endpoint_entities.EndpointMiddleware{Type: endpoint_entities.ENDPOINT_MIDDLEWARE_BASIC_AUTH, Username: "dify", Password: "hunter2"},
+			http.StatusUnauthorized,
+		},
+		{
+			"body too large",
+			endpoint_entities.EndpointMiddleware{Type: endpoint_entities.ENDPOINT_MIDDLEWARE_BODY_LIMIT, MaxBodyBytes: 4},
+			http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, c := range cases {
+		ctx, recorder := middlewareTestContext("payload")
+		if _, answered := applyEndpointMiddlewares(ctx, "tenant", nil, []endpoint_entities.EndpointMiddleware{c.middleware}); !answered {
+			t.Errorf("%s: request should be rejected", c.name)
+			continue
+		}
+		if recorder.Code != c.status {
+			t.Errorf("%s: expected status %d, got %d", c.name, c.status, recorder.Code)
+		}
+	}
+
+	ctx, recorder := middlewareTestContext("")
+	applyEndpointMiddlewares(ctx, "tenant", nil, []endpoint_entities.EndpointMiddleware{
+		{Type: endpoint_entities.ENDPOINT_MIDDLEWARE_BASIC_AUTH, Username: "dify", Password: "hunter2", Realm: "hooks"},
+	})
+	if recorder.Header().Get("WWW-Authenticate") != `Basic realm="hooks"` {
+		t.Errorf("unexpected challenge %s", recorder.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestEndpointSignature(t *testing.T) {
+	sign := func(payload string) string {
+		mac := hmac.New(sha256.New, []byte("whsec"))
+		mac.Write([]byte(payload))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	middleware := endpoint_entities.EndpointMiddleware{
+		Type:            endpoint_entities.ENDPOINT_MIDDLEWARE_HMAC_SIGNATURE,
+		SignatureHeader: "X-Hub-Signature-256",
+		SignaturePrefix: "sha256=",
+		Secret:          "{{settings.webhook_secret}}",
+	}
+	settings := map[string]any{"webhook_secret": "whsec"}
+
+	ctx, _ := middlewareTestContext("payload")
+	ctx.Request.Header.Set("X-Hub-Signature-256", sign("payload"))
+	if _, answered := applyEndpointMiddlewares(ctx, "tenant", settings, []endpoint_entities.EndpointMiddleware{middleware}); answered {
+		t.Fatal("a valid signature should pass")
+	}
+	if body, _ := io.ReadAll(ctx.Request.Body); string(body) != "payload" {
+		t.Errorf("body should be kept, got %s", body)
+	}
+
+	ctx, recorder := middlewareTestContext("tampered")
+	ctx.Request.Header.Set("X-Hub-Signature-256", sign("payload"))
+	if _, answered := applyEndpointMiddlewares(ctx, "tenant", settings, []endpoint_entities.EndpointMiddleware{middleware}); !answered ||
+		recorder.Code != http.StatusUnauthorized {
+		t.Errorf("a tampered body should be rejected, got %d", recorder.Code)
+	}
+
+	// a secret the tenant never configured must not be verified as empty
+	ctx, recorder = middlewareTestContext("payload")
+	ctx.Request.Header.Set("X-Hub-Signature-256", sign("payload"))
+	if _, answered := applyEndpointMiddlewares(ctx, "tenant", map[string]any{}, []endpoint_entities.EndpointMiddleware{middleware}); !answered ||
+		recorder.Code != http.StatusBadRequest {
+		t.Errorf("a missing setting should be rejected, got %d", recorder.Code)
+	}
+
+	middleware.TimestampHeader = "X-Timestamp"
+	middleware.MaxSkewSeconds = 60
+	now := time.Now()
+	for offset, valid := range map[time.Duration]bool{0: true, -time.Hour: false} {
+		timestamp := strconv.FormatInt(now.Add(offset).Unix(), 10)
+		ctx, _ := middlewareTestContext("payload")
+		ctx.Request.Header.Set("X-Timestamp", timestamp)
+		ctx.Request.Header.Set("X-Hub-Signature-256", sign(timestamp+".payload"))
+		resolved, _ := resolveEndpointMiddlewares("tenant", settings, []endpoint_entities.EndpointMiddleware{middleware})
+		if verifyEndpointSignature(ctx, resolved[0], now) != valid {
+			t.Errorf("timestamp offset %s: expected valid %v", offset, valid)
+		}
+	}
+}
+
+func TestEndpointResponseMiddlewares(t *testing.T) {
+	ctx, _ := middlewareTestContext("")
+	resolved, _ := applyEndpointMiddlewares(ctx, "tenant", map[string]any{"origin": "dify"}, []endpoint_entities.EndpointMiddleware{
+		{Type: endpoint_entities.ENDPOINT_MIDDLEWARE_SET_RESPONSE_HEADERS, Headers: map[string]string{
+			"X-Served-By": "{{settings.origin}}",
+			"Server":      "",
+		}},
+	})
+	if ctx.Request.Header.Get("X-Served-By") != "" {
+		t.Error("response headers should not be set on the request")
+	}
+
+	header := http.Header{"Server": {"plugin"}}
+	applyEndpointResponseMiddlewares(header, nil, resolved)
+	if header.Get("X-Served-By") != "dify" || header.Get("Server") != "" {
+		t.Errorf("unexpected response headers %v", header)
+	}
+}
+
+func TestDeclaredEndpointMiddlewaresRejectTenantVariables(t *testing.T) {
+	ctx, recorder := middlewareTestContext("")
+	_, answered := applyEndpointMiddlewares(ctx, "tenant", map[string]any{"origin": "dify"}, []endpoint_entities.EndpointMiddleware{
+		{Type: endpoint_entities.ENDPOINT_MIDDLEWARE_SET_RESPONSE_HEADERS, Headers: map[string]string{
+			"X-Leak": "{{secret.api_key}}",
+		}},
+	})
+	if !answered || recorder.Code != http.StatusBadRequest {
+		t.Errorf("declared middlewares referencing tenant variables should be rejected, got %d", recorder.Code)
+	}
+}
+
+func TestEndpointMiddlewareSecretsAreEncrypted(t *testing.T) {
+	endpoint := &models.Endpoint{Model: models.Model{ID: "endpoint"}, TenantID: "tenant"}
+	middlewares := []endpoint_entities.EndpointMiddleware{
+		{Type: endpoint_entities.ENDPOINT_MIDDLEWARE_BASIC_AUTH, Username: "dify", Password: "hunter2"},
+	}
+	if err := sealEndpointMiddlewares(endpoint, slices.Clone(middlewares)); err != tenant_variables.ErrEncryptionKeyNotSet {
+		t.Fatalf("passwords must not be stored without a key, got %v", err)
+	}
+
+	tenant_variables.Init("key")
+	t.Cleanup(func() { tenant_variables.Init("") })
+
+	if err := sealEndpointMiddlewares(endpoint, middlewares); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(middlewares[0].Password, ENDPOINT_MIDDLEWARE_SECRET_PREFIX) ||
+		strings.Contains(middlewares[0].Password, "hunter2") {
+		t.Fatalf("password should be encrypted, got %s", middlewares[0].Password)
+	}
+	sealed := middlewares[0].Password
+	if err := sealEndpointMiddlewares(endpoint, middlewares); err != nil || middlewares[0].Password != sealed {
+		t.Fatal("an encrypted password should be kept as it is")
+	}
+
+	endpoint.Middlewares = middlewares
+	opened, err := openEndpointMiddlewares(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, _ := middlewareTestContext("")
+	ctx.Request.SetBasicAuth("dify", "hunter2")
+	if _, answered := applyEndpointMiddlewares(ctx, "tenant", nil, opened); answered {
+		t.Fatal("the decrypted password should be checked")
+	}
+	if endpoint.Middlewares[0].Password != sealed {
+		t.Fatal("the stored chain must stay encrypted")
+	}
+
+	// an encrypted password copied into another endpoint is not decrypted
+	other := &models.Endpoint{Model: models.Model{ID: "other"}, TenantID: "tenant", Middlewares: middlewares}
+	if _, err := openEndpointMiddlewares(other); err == nil {
+		t.Fatal("a password of another endpoint should not be decrypted")
+	}
+
+	// chains stored before passwords were encrypted keep working
+	opened, err = openEndpointMiddlewares(&models.Endpoint{Middlewares: []endpoint_entities.EndpointMiddleware{
+		{Type: endpoint_entities.ENDPOINT_MIDDLEWARE_BASIC_AUTH, Username: "dify", Password: "hunter2"},
+	}})
+	if err != nil || opened[0].Password != "hunter2" {
+		t.Fatalf("a plaintext password should be read as it is, got %v", err)
+	}
+}
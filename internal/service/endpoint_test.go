@@ -46,3 +46,27 @@ func TestEndpointWithOriginalHost(t *testing.T) {
 		t.Fatal("request body is not equal, ", str)
 	}
 }
+
+func TestPrepareEndpointStream(t *testing.T) {
+	header := http.Header{
+		"Content-Type":   {"text/event-stream; charset=utf-8"},
+		"Content-Length": {"42"},
+	}
+	streaming, sse := prepareEndpointStream(header)
+	if !streaming || !sse {
+		t.Fatalf("expected an event stream, got streaming %v sse %v", streaming, sse)
+	}
+	if header.Get("Content-Length") != "" || header.Get("X-Accel-Buffering") != "no" || header.Get("Cache-Control") != "no-cache" {
+		t.Errorf("unexpected stream headers %v", header)
+	}
+
+	header = http.Header{"Content-Type": {"application/x-ndjson"}, "Transfer-Encoding": {"chunked"}}
+	if streaming, sse := prepareEndpointStream(header); !streaming || sse {
+		t.Fatalf("expected a chunked stream, got streaming %v sse %v", streaming, sse)
+	}
+
+	header = http.Header{"Content-Type": {"application/json"}, "Content-Length": {"2"}}
+	if streaming, _ := prepareEndpointStream(header); streaming || header.Get("Content-Length") != "2" {
+		t.Errorf("expected a buffered response to be kept, got %v", header)
+	}
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models/curd"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	INSTALL_APPROVAL_EVENT_REQUESTED = "plugin_install_approval_requested"
+	INSTALL_APPROVAL_EVENT_APPROVED  = "plugin_install_approval_approved"
+	INSTALL_APPROVAL_EVENT_REJECTED  = "plugin_install_approval_rejected"
+	INSTALL_APPROVAL_EVENT_FAILED    = "plugin_install_approval_failed"
+)
+
+var installApprovalWebhookClient = &http.Client{Timeout: 10 * time.Second}
+
+func installApprovalRequired(config *app.Config, tenant_id string) bool {
+	return config.InstallApprovalEnabled && !slices.Contains(config.InstallApprovalAdminTenants, tenant_id)
+}
+
+// requestPluginInstallApproval records the install for an admin to review, quotas and dependencies are
+// checked at once so that only installable requests wait for approval
+func requestPluginInstallApproval(
+	config *app.Config,
+	tenant_id string,
+	plugin_unique_identifiers []plugin_entities.PluginUniqueIdentifier,
+	source string,
+	metas []map[string]any,
+	action models.InstallTaskAction,
+	original_plugin_unique_identifier string,
+) (*InstallPluginResponse, error) {
+	runtimeType := plugin_entities.PluginRuntimeType("")
+	if config.Platform == app.PLATFORM_SERVERLESS {
+		runtimeType = plugin_entities.PLUGIN_RUNTIME_TYPE_SERVERLESS
+	} else if config.Platform == app.PLATFORM_LOCAL {
+		runtimeType = plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL
+	} else {
+		return nil, fmt.Errorf("unsupported platform: %s", config.Platform)
+	}
+
+	if err := checkTenantQuota(config, tenant_id, plugin_unique_identifiers, original_plugin_unique_identifier); err != nil {
+		return nil, err
+	}
+
+	if _, err := resolvePluginDependencies(tenant_id, plugin_unique_identifiers, runtimeType, action); err != nil {
+		return nil, err
+	}
+
+	identifiers := make([]string, 0, len(plugin_unique_identifiers))
+	for _, identifier := range plugin_unique_identifiers {
+		identifiers = append(identifiers, identifier.String())
+	}
+
+	approval := &models.PluginInstallApproval{
+		TenantID:                       tenant_id,
+		Status:                         models.PluginInstallApprovalStatusPending,
+		Action:                         action,
+		PluginUniqueIdentifiers:        identifiers,
+		Metas:                          metas,
+		Source:                         source,
+		OriginalPluginUniqueIdentifier: original_plugin_unique_identifier,
+	}
+	if err := db.Create(approval); err != nil {
+		return nil, err
+	}
+
+	notifyPluginInstallApproval(config, INSTALL_APPROVAL_EVENT_REQUESTED, approval)
+
+	return &InstallPluginResponse{
+		PendingApproval: true,
+		ApprovalID:      approval.ID,
+	}, nil
+}
+
+// notifyPluginInstallApproval posts the event to the webhook in background, failures are only logged
+func notifyPluginInstallApproval(config *app.Config, event string, approval *models.PluginInstallApproval) {
+	if config.InstallApprovalWebhookURL == "" {
+		return
+	}
+
+	payload := parser.MarshalJsonBytes(map[string]any{
+		"event":    event,
+		"approval": approval,
+	})
+
+	routine.Submit(map[string]string{
+		"module":   "service",
+		"function": "notifyPluginInstallApproval",
+	}, func() {
+		resp, err := installApprovalWebhookClient.Post(
+			config.InstallApprovalWebhookURL,
+			"application/json",
+			bytes.NewReader(payload),
+		)
+		if err != nil {
+			log.Error("failed to notify %s of approval %s: %s", event, approval.ID, err.Error())
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			log.Error("failed to notify %s of approval %s: webhook responded with status %d", event, approval.ID, resp.StatusCode)
+		}
+	})
+}
+
+func installApprovalError(err error) *entities.Response {
+	switch {
+	case errors.Is(err, curd.ErrPluginInstallApprovalNotFound):
+		return exception.NotFoundError(err).ToResponse()
+	case errors.Is(err, curd.ErrPluginInstallApprovalReviewed):
+		return exception.BadRequestError(err).ToResponse()
+	default:
+		return exception.InternalServerError(err).ToResponse()
+	}
+}
+
+func ListPluginInstallApprovals(
+	tenant_id string,
+	status string,
+	page int,
+	page_size int,
+) *entities.Response {
+	queries := []db.GenericQuery{
+		db.OrderBy("created_at", true),
+		db.Page(page, page_size),
+	}
+	if tenant_id != "" {
+		queries = append(queries, db.Equal("tenant_id", tenant_id))
+	}
+	if status != "" {
+		queries = append(queries, db.Equal("status", status))
+	}
+
+	approvals, err := db.GetAll[models.PluginInstallApproval](queries...)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(approvals)
+}
+
+// ApprovePluginInstall starts the install of a pending approval, the approval fails if the install can't be
+// started anymore, e.g. the quota has been reached in the meantime
+func ApprovePluginInstall(config *app.Config, approval_id string) *entities.Response {
+	approval, err := curd.ReviewPluginInstallApproval(approval_id, models.PluginInstallApprovalStatusApproved, "")
+	if err != nil {
+		return installApprovalError(err)
+	}
+
+	identifiers := make([]plugin_entities.PluginUniqueIdentifier, 0, len(approval.PluginUniqueIdentifiers))
+	for _, identifier := range approval.PluginUniqueIdentifiers {
+		identifiers = append(identifiers, plugin_entities.PluginUniqueIdentifier(identifier))
+	}
+
+	response, err := installPluginRuntimeToTenant(
+		config,
+		approval.TenantID,
+		identifiers,
+		approval.Source,
+		approval.Metas,
+		approval.Action,
+		approval.OriginalPluginUniqueIdentifier,
+	)
+	if err != nil {
+		approval.Status = models.PluginInstallApprovalStatusFailed
+		approval.Reason = err.Error()
+	} else {
+		approval.TaskID = response.TaskID
+	}
+	if updateErr := db.Update(approval); updateErr != nil {
+		log.Error("failed to update approval %s: %s", approval.ID, updateErr.Error())
+	}
+
+	if err != nil {
+		notifyPluginInstallApproval(config, INSTALL_APPROVAL_EVENT_FAILED, approval)
+		if errors.Is(err, curd.ErrPluginAlreadyInstalled) || errors.Is(err, curd.ErrPluginVersionAlreadyInstalled) {
+			return exception.BadRequestError(err).ToResponse()
+		}
+		if errors.Is(err, ErrTenantQuotaExceeded) {
+			return exception.QuotaExceededError(err).ToResponse()
+		}
+		if errors.Is(err, ErrDependencyConflict) {
+			return exception.BadRequestError(err).ToResponse()
+		}
+		if errors.Is(err, plugin_manager.ErrInstallQueueFull) {
+			return exception.TooManyRequestsError(err.Error()).ToResponse()
+		}
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	notifyPluginInstallApproval(config, INSTALL_APPROVAL_EVENT_APPROVED, approval)
+	return entities.NewSuccessResponse(approval)
+}
+
+func RejectPluginInstall(config *app.Config, approval_id string, reason string) *entities.Response {
+	approval, err := curd.ReviewPluginInstallApproval(approval_id, models.PluginInstallApprovalStatusRejected, reason)
+	if err != nil {
+		return installApprovalError(err)
+	}
+
+	notifyPluginInstallApproval(config, INSTALL_APPROVAL_EVENT_REJECTED, approval)
+	return entities.NewSuccessResponse(approval)
+}
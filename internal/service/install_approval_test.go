@@ -0,0 +1,60 @@
+package service
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models/curd"
+)
+
+func TestInstallApprovalRequired(t *testing.T) {
+	config := &app.Config{InstallApprovalAdminTenants: []string{"admin"}}
+	if installApprovalRequired(config, "tenant") {
+		t.Fatal("expected no approval to be required while disabled")
+	}
+
+	config.InstallApprovalEnabled = true
+	if !installApprovalRequired(config, "tenant") {
+		t.Fatal("expected installs of other tenants to require approval")
+	}
+	if installApprovalRequired(config, "admin") {
+		t.Fatal("expected installs of admin tenants to skip approval")
+	}
+}
+
+func TestReviewPluginInstallApproval(t *testing.T) {
+	cfg := &app.Config{DBType: "sqlite", DBSqlitePath: filepath.Join(t.TempDir(), "approval.db")}
+	cfg.SetDefault()
+	db.Init(cfg)
+	t.Cleanup(db.Close)
+
+	approval := &models.PluginInstallApproval{
+		TenantID:                "0e1b5c8e-7d4a-4f4e-9d33-6c7d9b0a1f21",
+		Status:                  models.PluginInstallApprovalStatusPending,
+		Action:                  models.InstallTaskActionInstall,
+		PluginUniqueIdentifiers: []string{"langgenius/openai:1.0.0@0123456789abcdef"},
+		Metas:                   []map[string]any{{}},
+	}
+	if err := db.Create(approval); err != nil {
+		t.Fatal(err)
+	}
+
+	rejected, err := curd.ReviewPluginInstallApproval(approval.ID, models.PluginInstallApprovalStatusRejected, "not allowed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rejected.Status != models.PluginInstallApprovalStatusRejected || rejected.Reason != "not allowed" || rejected.ReviewedAt == nil {
+		t.Fatalf("unexpected review %+v", rejected)
+	}
+
+	if _, err := curd.ReviewPluginInstallApproval(approval.ID, models.PluginInstallApprovalStatusApproved, ""); !errors.Is(err, curd.ErrPluginInstallApprovalReviewed) {
+		t.Fatalf("expected a reviewed approval not to be reviewed again, got %v", err)
+	}
+	if _, err := curd.ReviewPluginInstallApproval("missing", models.PluginInstallApprovalStatusApproved, ""); !errors.Is(err, curd.ErrPluginInstallApprovalNotFound) {
+		t.Fatalf("expected a missing approval to be not found, got %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"slices"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/trust"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/secret_scanner"
+)
+
+type InstallPlanAction string
+
+const (
+	INSTALL_PLAN_ACTION_INSTALL InstallPlanAction = "install"
+	INSTALL_PLAN_ACTION_UPGRADE InstallPlanAction = "upgrade"
+	// INSTALL_PLAN_ACTION_NONE means the tenant has installed the package already
+	INSTALL_PLAN_ACTION_NONE InstallPlanAction = "none"
+)
+
+// InstallPlan is what installing a package would change, nothing is saved to compute it
+type InstallPlan struct {
+	PluginUniqueIdentifier         plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier"`
+	Action                         InstallPlanAction                      `json:"action"`
+	OriginalPluginUniqueIdentifier string                                 `json:"original_plugin_unique_identifier"`
+	RuntimeType                    plugin_entities.PluginRuntimeType      `json:"runtime_type"`
+	PackageSize                    int64                                  `json:"package_size"`
+	Manifest                       *plugin_entities.PluginDeclaration     `json:"manifest"`
+	Verified                       bool                                   `json:"verified"`
+	Verification                   *decoder.Verification                  `json:"verification"`
+	TrustTier                      trust.Tier                             `json:"trust_tier"`
+	RequestedPermissions           []string                               `json:"requested_permissions"`
+	RevokedPermissions             []string                               `json:"revoked_permissions"`
+	// PendingPermissions are granted to the new version but not consented, they are denied until consented
+	PendingPermissions []string `json:"pending_permissions"`
+	// InstallOrder lists the plugins installed together in order, dependencies come first
+	InstallOrder        []plugin_entities.PluginUniqueIdentifier `json:"install_order"`
+	Usage               *TenantUsage                             `json:"usage"`
+	SecretFindings      []secret_scanner.Finding                 `json:"secret_findings"`
+	VulnerabilityReport *models.PluginSecurityReport             `json:"vulnerability_report"`
+	// Problems would fail the install, Installable is false if any
+	Problems    []string `json:"problems"`
+	Installable bool     `json:"installable"`
+}
+
+func (p *InstallPlan) problem(err error) {
+	p.Problems = append(p.Problems, err.Error())
+}
+
+// DryRunPluginInstall checks the package the way uploading and installing it would and returns the plan,
+// the package is neither saved nor installed
+func DryRunPluginInstall(
+	config *app.Config,
+	tenantId string,
+	difyPkgFile multipart.File,
+	verifySignature bool,
+) *entities.Response {
+	pluginFile, err := io.ReadAll(difyPkgFile)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	plan, planErr := planPluginInstall(config, tenantId, pluginFile, verifySignature)
+	if planErr != nil {
+		return planErr.ToResponse()
+	}
+
+	return entities.NewSuccessResponse(plan)
+}
+
+func planPluginInstall(
+	config *app.Config,
+	tenantId string,
+	pluginFile []byte,
+	verifySignature bool,
+) (*InstallPlan, exception.PluginDaemonError) {
+	if _, err := decoder.NewZipPluginDecoderWithSizeLimit(pluginFile, config.MaxPluginPackageSize); err != nil {
+		return nil, exception.BadRequestError(err)
+	}
+
+	decoderInstance, err := decoder.NewZipPluginDecoderWithThirdPartySignatureVerificationConfig(
+		pluginFile,
+		&decoder.ThirdPartySignatureVerificationConfig{
+			Enabled:        config.ThirdPartySignatureVerificationEnabled,
+			PublicKeyPaths: config.ThirdPartySignatureVerificationPublicKeys,
+			KeyringPath:    config.ThirdPartySignatureVerificationKeyring,
+		},
+	)
+	if err != nil {
+		return nil, exception.BadRequestError(err)
+	}
+
+	pluginUniqueIdentifier, err := decoderInstance.UniqueIdentity()
+	if err != nil {
+		return nil, exception.BadRequestError(err)
+	}
+
+	declaration, err := decoderInstance.Manifest()
+	if err != nil {
+		return nil, exception.BadRequestError(err)
+	}
+
+	plan := &InstallPlan{
+		PluginUniqueIdentifier: pluginUniqueIdentifier,
+		Action:                 INSTALL_PLAN_ACTION_INSTALL,
+		PackageSize:            int64(len(pluginFile)),
+		Manifest:               &declaration,
+		Verified:               decoderInstance.Verified(),
+		TrustTier:              trust.TierOf(decoderInstance),
+		RevokedPermissions:     []string{},
+		PendingPermissions:     []string{},
+		InstallOrder:           []plugin_entities.PluginUniqueIdentifier{},
+		Problems:               []string{},
+	}
+
+	switch config.Platform {
+	case app.PLATFORM_SERVERLESS:
+		plan.RuntimeType = plugin_entities.PLUGIN_RUNTIME_TYPE_SERVERLESS
+	case app.PLATFORM_LOCAL:
+		plan.RuntimeType = plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL
+	default:
+		return nil, exception.BadRequestError(fmt.Errorf("unsupported platform: %s", config.Platform))
+	}
+
+	// avoid author to be a uuid
+	if pluginUniqueIdentifier.RemoteLike() {
+		plan.problem(errors.New("author cannot be a uuid"))
+	}
+
+	plan.Verification, _ = decoderInstance.Verification()
+	if plan.Verification == nil && plan.Verified {
+		plan.Verification = decoder.DefaultVerification()
+	}
+	if config.ForceVerifyingSignature != nil && *config.ForceVerifyingSignature || verifySignature {
+		if !plan.Verified {
+			plan.problem(errors.New(
+				"plugin verification has been enabled, and the plugin you want to install has a bad signature",
+			))
+		}
+	}
+
+	plan.SecretFindings, err = scanPluginSecrets(config, pluginUniqueIdentifier, decoderInstance)
+	if err != nil {
+		plan.problem(err)
+	}
+
+	plan.VulnerabilityReport, err = inspectPluginVulnerabilities(config, pluginUniqueIdentifier, decoderInstance)
+	if err != nil {
+		plan.problem(err)
+	}
+
+	// the permissions are requested before the trust tier revokes any
+	plan.RequestedPermissions = trust.Requested(declaration.Resource.Permission)
+	policy := plugin_manager.Manager().TrustPolicy(plan.TrustTier)
+	if err := policy.CheckResource(&declaration); err != nil {
+		plan.problem(err)
+	}
+	if revoked := policy.Apply(&declaration); len(revoked) > 0 {
+		plan.RevokedPermissions = revoked
+	}
+
+	installation, err := db.GetOne[models.PluginInstallation](
+		db.Equal("tenant_id", tenantId),
+		db.Equal("plugin_id", pluginUniqueIdentifier.PluginID()),
+	)
+	if err == nil {
+		if installation.PluginUniqueIdentifier == pluginUniqueIdentifier.String() {
+			plan.Action = INSTALL_PLAN_ACTION_NONE
+		} else {
+			plan.Action = INSTALL_PLAN_ACTION_UPGRADE
+			plan.OriginalPluginUniqueIdentifier = installation.PluginUniqueIdentifier
+
+			pending, err := pendingPluginPermissions(tenantId, &installation, trust.Requested(declaration.Resource.Permission))
+			if err != nil {
+				return nil, exception.InternalServerError(err)
+			}
+			plan.PendingPermissions = pending
+		}
+	} else if err != db.ErrDatabaseNotFound {
+		return nil, exception.InternalServerError(err)
+	}
+
+	if plan.Action != INSTALL_PLAN_ACTION_NONE {
+		installed, err := installedPlugins(tenantId)
+		if err != nil {
+			return nil, exception.InternalServerError(err)
+		}
+		resolver := &dependencyResolver{installed: installed, replacing: true}
+		resolution, err := resolver.resolve([]resolvingPlugin{{identifier: pluginUniqueIdentifier, declaration: &declaration}})
+		if err != nil {
+			plan.problem(err)
+		} else {
+			plan.InstallOrder = resolution.Order
+		}
+	}
+
+	plan.Usage, err = projectTenantUsage(
+		tenantId,
+		[]plugin_entities.PluginUniqueIdentifier{pluginUniqueIdentifier},
+		plan.OriginalPluginUniqueIdentifier,
+		map[plugin_entities.PluginUniqueIdentifier]int64{pluginUniqueIdentifier: plan.PackageSize},
+	)
+	if err != nil {
+		return nil, exception.InternalServerError(err)
+	}
+	plan.Usage.Quota = tenantQuota(config)
+	if err := plan.Usage.Quota.check(plan.Usage); err != nil {
+		plan.problem(err)
+	}
+
+	plan.Installable = len(plan.Problems) == 0
+	return plan, nil
+}
+
+// pendingPluginPermissions lists the permissions an upgrade leaves to be consented, a tenant without consent
+// record consents to what the installed version requests on upgrade
+func pendingPluginPermissions(
+	tenantId string,
+	installation *models.PluginInstallation,
+	granted []string,
+) ([]string, error) {
+	consent, err := helper.GetPluginPermissionConsent(tenantId, installation.PluginID)
+	if err != nil {
+		return nil, err
+	}
+
+	consented := consent.Permissions
+	if consent.ID == "" {
+		original, err := plugin_entities.NewPluginUniqueIdentifier(installation.PluginUniqueIdentifier)
+		if err != nil {
+			return nil, err
+		}
+		originalDeclaration, err := helper.CombinedGetPluginDeclaration(
+			original,
+			plugin_entities.PluginRuntimeType(installation.RuntimeType),
+		)
+		if err != nil {
+			return nil, err
+		}
+		consented = trust.Requested(originalDeclaration.Resource.Permission)
+	}
+
+	return unconsentedPermissions(granted, consented), nil
+}
+
+func unconsentedPermissions(granted []string, consented []string) []string {
+	pending := []string{}
+	for _, permission := range granted {
+		if !slices.Contains(consented, permission) {
+			pending = append(pending, permission)
+		}
+	}
+	return pending
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	cloudoss "github.com/langgenius/dify-cloud-kit/oss"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/oss"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnconsentedPermissions(t *testing.T) {
+	pending := unconsentedPermissions([]string{"tool", "model", "storage"}, []string{"tool"})
+	if !slices.Equal(pending, []string{"model", "storage"}) {
+		t.Fatalf("expected model and storage to be pending, got %v", pending)
+	}
+
+	pending = unconsentedPermissions([]string{"tool"}, []string{"tool", "model"})
+	if len(pending) != 0 {
+		t.Fatalf("expected nothing to be pending, got %v", pending)
+	}
+}
+
+// dryRunPackage renames the dummy plugin and sets its version, edit changes the rest of its manifest
+func dryRunPackage(t *testing.T, name string, version string, edit func(manifest string) string) []byte {
+	original, err := os.ReadFile("testdata/dummy_plugin.difypkg")
+	require.NoError(t, err)
+	reader, err := zip.NewReader(bytes.NewReader(original), int64(len(original)))
+	require.NoError(t, err)
+
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for _, file := range reader.File {
+		content, err := file.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(content)
+		content.Close()
+		require.NoError(t, err)
+
+		if file.Name == "manifest.yaml" {
+			lines := strings.Split(string(data), "\n")
+			lines[0] = "version: " + version
+			manifest := strings.ReplaceAll(strings.Join(lines, "\n"), "\nname: test\n", "\nname: "+name+"\n")
+			if edit != nil {
+				manifest = edit(manifest)
+			}
+			data = []byte(manifest)
+		}
+		entry, err := writer.Create(file.Name)
+		require.NoError(t, err)
+		_, err = entry.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	return buffer.Bytes()
+}
+
+// requiring adds a dependency on test/test to the manifest
+func requiring(constraint string) func(string) string {
+	return func(manifest string) string {
+		return strings.Replace(manifest, "\nmeta:\n", "\nmeta:\n  dependencies:\n    - plugin_id: test/test\n      version: \""+constraint+"\"\n", 1)
+	}
+}
+
+// installDryRunPackage installs a package to the tenant the way the plan reads installations
+func installDryRunPackage(t *testing.T, tenantId string, pluginFile []byte) plugin_entities.PluginUniqueIdentifier {
+	pluginDecoder, err := decoder.NewZipPluginDecoder(pluginFile)
+	require.NoError(t, err)
+	identifier, err := pluginDecoder.UniqueIdentity()
+	require.NoError(t, err)
+	declaration, err := pluginDecoder.Manifest()
+	require.NoError(t, err)
+
+	require.NoError(t, db.Create(&models.PluginDeclaration{
+		PluginUniqueIdentifier: identifier.String(),
+		PluginID:               identifier.PluginID(),
+		Declaration:            declaration,
+	}))
+	require.NoError(t, db.Create(&models.PluginInstallation{
+		TenantID:               tenantId,
+		PluginID:               identifier.PluginID(),
+		PluginUniqueIdentifier: identifier.String(),
+		RuntimeType:            string(plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL),
+	}))
+	return identifier
+}
+
+func TestPlanPluginInstall(t *testing.T) {
+	config := &app.Config{
+		DBType:       "sqlite",
+		DBSqlitePath: filepath.Join(t.TempDir(), "dry_run.db"),
+		Platform:     app.PLATFORM_LOCAL,
+	}
+	config.SetDefault()
+	forceVerifyingSignature := false
+	config.ForceVerifyingSignature = &forceVerifyingSignature
+	db.Init(config)
+	t.Cleanup(db.Close)
+	cache.InitMemoryClient(0)
+	routine.InitPool(16)
+
+	storage, err := oss.NewLocalStorage(cloudoss.OSSArgs{Local: &cloudoss.Local{Path: t.TempDir()}})
+	require.NoError(t, err)
+	plugin_manager.InitGlobalManager(storage, config)
+
+	tenantId := "0e1b5c8e-7d4a-4f4e-9d33-6c7d9b0a1f21"
+	plan := func(pluginFile []byte) *InstallPlan {
+		plan, err := planPluginInstall(config, tenantId, pluginFile, false)
+		require.Nil(t, err)
+		return plan
+	}
+
+	// a plugin without dependencies is installed alone
+	first := dryRunPackage(t, "test", "0.0.1", nil)
+	installing := plan(first)
+	assert.Equal(t, INSTALL_PLAN_ACTION_INSTALL, installing.Action)
+	assert.True(t, installing.Installable, installing.Problems)
+	assert.Equal(t, []plugin_entities.PluginUniqueIdentifier{installing.PluginUniqueIdentifier}, installing.InstallOrder)
+
+	// a dependency which is not installed fails the install
+	dependent := dryRunPackage(t, "dependent", "1.0.0", requiring("^0.0.1"))
+	missing := plan(dependent)
+	assert.False(t, missing.Installable)
+	require.Len(t, missing.Problems, 1)
+	assert.Contains(t, missing.Problems[0], "test/dependent requires test/test ^0.0.1 which is not installed")
+
+	// installing the same package again changes nothing
+	installed := installDryRunPackage(t, tenantId, first)
+	again := plan(first)
+	assert.Equal(t, INSTALL_PLAN_ACTION_NONE, again.Action)
+	assert.Empty(t, again.InstallOrder)
+
+	// the dependency is satisfied once it's installed
+	satisfied := plan(dependent)
+	assert.True(t, satisfied.Installable, satisfied.Problems)
+	assert.Equal(t, []plugin_entities.PluginUniqueIdentifier{satisfied.PluginUniqueIdentifier}, satisfied.InstallOrder)
+
+	// a version the installed plugin doesn't accept
+	tooOld := plan(dryRunPackage(t, "dependent", "1.0.0", requiring("^1.0.0")))
+	assert.False(t, tooOld.Installable)
+	require.Len(t, tooOld.Problems, 1)
+	assert.Contains(t, tooOld.Problems[0], "test/dependent requires test/test ^1.0.0 but 0.0.1 is installed")
+
+	// upgrading to a version the installed dependents reject is a conflict too
+	installDryRunPackage(t, tenantId, dryRunPackage(t, "dependent", "1.0.0", requiring("0.0.1-0.0.9")))
+	conflicting := plan(dryRunPackage(t, "test", "0.1.0", nil))
+	assert.Equal(t, INSTALL_PLAN_ACTION_UPGRADE, conflicting.Action)
+	assert.False(t, conflicting.Installable)
+	require.Len(t, conflicting.Problems, 1)
+	assert.Contains(t, conflicting.Problems[0], "test/dependent requires test/test 0.0.1-0.0.9 which 0.1.0 does not satisfy")
+
+	// an upgrade replaces the installed version and asks for the permissions it adds
+	upgrade := plan(dryRunPackage(t, "test", "0.0.2", func(manifest string) string {
+		return strings.Replace(manifest, "permission: {}", "permission:\n    storage:\n      enabled: true\n      size: 1048576", 1)
+	}))
+	assert.Equal(t, INSTALL_PLAN_ACTION_UPGRADE, upgrade.Action)
+	assert.Equal(t, installed.String(), upgrade.OriginalPluginUniqueIdentifier)
+	assert.True(t, upgrade.Installable, upgrade.Problems)
+	assert.Equal(t, []string{"storage"}, upgrade.PendingPermissions)
+	assert.Equal(t, []plugin_entities.PluginUniqueIdentifier{upgrade.PluginUniqueIdentifier}, upgrade.InstallOrder)
+}
@@ -3,10 +3,14 @@ package service
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/event_bus"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/install_hook"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/trust"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
@@ -15,7 +19,6 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
-	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
@@ -26,13 +29,29 @@ import (
 type InstallPluginResponse struct {
 	AllInstalled bool   `json:"all_installed"`
 	TaskID       string `json:"task_id"`
+	// PendingApproval is true if nothing is installed until an admin approves ApprovalID
+	PendingApproval bool   `json:"pending_approval"`
+	ApprovalID      string `json:"approval_id"`
 }
 
-type InstallPluginOnDoneHandler func(
-	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
-	declaration *plugin_entities.PluginDeclaration,
-	meta map[string]any,
-) error
+// RegisterInstallTaskHandlers binds the actions of install tasks to their handlers,
+// it must be called before the plugin manager launches, otherwise resumed tasks have nothing to run
+func RegisterInstallTaskHandlers(config *app.Config, queue *plugin_manager.InstallQueue) {
+	queue.RegisterHandler(models.InstallTaskActionInstall, onPluginInstalled(config))
+	queue.RegisterHandler(models.InstallTaskActionUpgrade, onPluginUpgraded(config))
+	queue.RegisterHandler(models.InstallTaskActionInstallVersion, onPluginVersionInstalled(config))
+}
+
+func installTaskHandler(config *app.Config, action models.InstallTaskAction) plugin_manager.InstallTaskDoneHandler {
+	switch action {
+	case models.InstallTaskActionUpgrade:
+		return onPluginUpgraded(config)
+	case models.InstallTaskActionInstallVersion:
+		return onPluginVersionInstalled(config)
+	default:
+		return onPluginInstalled(config)
+	}
+}
 
 func InstallPluginRuntimeToTenant(
 	config *app.Config,
@@ -40,10 +59,31 @@ func InstallPluginRuntimeToTenant(
 	plugin_unique_identifiers []plugin_entities.PluginUniqueIdentifier,
 	source string,
 	metas []map[string]any,
-	onDone InstallPluginOnDoneHandler, // since installing plugin is a async task, we need to call it asynchronously
+	action models.InstallTaskAction,
+	original_plugin_unique_identifier string,
+) (*InstallPluginResponse, error) {
+	if installApprovalRequired(config, tenant_id) {
+		return requestPluginInstallApproval(
+			config, tenant_id, plugin_unique_identifiers, source, metas, action, original_plugin_unique_identifier,
+		)
+	}
+
+	return installPluginRuntimeToTenant(
+		config, tenant_id, plugin_unique_identifiers, source, metas, action, original_plugin_unique_identifier,
+	)
+}
+
+func installPluginRuntimeToTenant(
+	config *app.Config,
+	tenant_id string,
+	plugin_unique_identifiers []plugin_entities.PluginUniqueIdentifier,
+	source string,
+	metas []map[string]any,
+	action models.InstallTaskAction,
+	original_plugin_unique_identifier string,
 ) (*InstallPluginResponse, error) {
 	response := &InstallPluginResponse{}
-	pluginsWaitForInstallation := []plugin_entities.PluginUniqueIdentifier{}
+	pluginsWaitForInstallation := 0
 
 	runtimeType := plugin_entities.PluginRuntimeType("")
 	if config.Platform == app.PLATFORM_SERVERLESS {
@@ -54,15 +94,38 @@ func InstallPluginRuntimeToTenant(
 		return nil, fmt.Errorf("unsupported platform: %s", config.Platform)
 	}
 
+	if err := checkTenantQuota(config, tenant_id, plugin_unique_identifiers, original_plugin_unique_identifier); err != nil {
+		return nil, err
+	}
+
+	resolution, err := resolvePluginDependencies(tenant_id, plugin_unique_identifiers, runtimeType, action)
+	if err != nil {
+		return nil, err
+	}
+
 	task := &models.InstallTask{
-		Status:           models.InstallTaskStatusRunning,
-		TenantID:         tenant_id,
-		TotalPlugins:     len(plugin_unique_identifiers),
-		CompletedPlugins: 0,
-		Plugins:          []models.InstallTaskPluginStatus{},
+		Status:                         models.InstallTaskStatusRunning,
+		TenantID:                       tenant_id,
+		TotalPlugins:                   len(resolution.Order),
+		CompletedPlugins:               0,
+		Plugins:                        []models.InstallTaskPluginStatus{},
+		Action:                         action,
+		Source:                         source,
+		OriginalPluginUniqueIdentifier: original_plugin_unique_identifier,
 	}
 
+	metaOf := map[plugin_entities.PluginUniqueIdentifier]map[string]any{}
 	for i, pluginUniqueIdentifier := range plugin_unique_identifiers {
+		metaOf[pluginUniqueIdentifier] = metas[i]
+	}
+
+	onDone := installTaskHandler(config, action)
+	queued := map[plugin_entities.PluginUniqueIdentifier]bool{}
+
+	for i, pluginUniqueIdentifier := range resolution.Order {
+		meta := metaOf[pluginUniqueIdentifier]
+		dependsOn := resolution.DependsOn[pluginUniqueIdentifier]
+
 		// fetch plugin declaration first, before installing, we need to ensure pkg is uploaded
 		pluginDeclaration, err := helper.CombinedGetPluginDeclaration(
 			pluginUniqueIdentifier,
@@ -85,207 +148,111 @@ func InstallPluginRuntimeToTenant(
 			IconDark:               pluginDeclaration.IconDark,
 			Labels:                 pluginDeclaration.Label,
 			Message:                "",
+			Meta:                   meta,
+			DependsOn:              dependsOn,
+		})
+
+		// plugins waiting for dependencies in the queue are bound to the tenant by the queue as well
+		waiting := slices.ContainsFunc(dependsOn, func(dependency plugin_entities.PluginUniqueIdentifier) bool {
+			return queued[dependency]
 		})
 
-		if err == nil {
-			if err := onDone(pluginUniqueIdentifier, pluginDeclaration, metas[i]); err != nil {
+		if err == nil && !waiting {
+			// the runtime is already installed, only the steps of operators stand before binding it to the tenant
+			if err := plugin_manager.Manager().RunInstallHooks(install_hook.STAGE_PRE_INSTALL, task, pluginUniqueIdentifier, meta); err != nil {
+				return nil, err
+			}
+			if err := onDone(task, pluginUniqueIdentifier, pluginDeclaration, meta); err != nil {
 				return nil, errors.Join(err, errors.New("failed on plugin installation"))
 			} else {
 				task.CompletedPlugins++
 				task.Plugins[i].Status = models.InstallTaskStatusSuccess
 				task.Plugins[i].Message = "Installed"
 			}
+			plugin_manager.Manager().RunInstallHooks(install_hook.STAGE_POST_INSTALL, task, pluginUniqueIdentifier, meta)
 
 			continue
 		}
 
-		if err != db.ErrDatabaseNotFound {
+		if err != nil && err != db.ErrDatabaseNotFound {
 			return nil, err
 		}
 
-		pluginsWaitForInstallation = append(pluginsWaitForInstallation, pluginUniqueIdentifier)
+		queued[pluginUniqueIdentifier] = true
+		pluginsWaitForInstallation++
 	}
 
-	if len(pluginsWaitForInstallation) == 0 {
+	if pluginsWaitForInstallation == 0 {
 		response.AllInstalled = true
 		response.TaskID = ""
 		return response, nil
 	}
 
-	err := db.Create(task)
-	if err != nil {
+	if err := db.Create(task); err != nil {
 		return nil, err
 	}
 
 	response.TaskID = task.ID
-	manager := plugin_manager.Manager()
 
-	tasks := []func(){}
-	for i, pluginUniqueIdentifier := range pluginsWaitForInstallation {
-		// copy the variable to avoid race condition
-		pluginUniqueIdentifier := pluginUniqueIdentifier
+	// installation runs in background, the progress is tracked by the task
+	if err := plugin_manager.Manager().InstallQueue().Enqueue(task); err != nil {
+		if errors.Is(err, plugin_manager.ErrInstallQueueFull) {
+			// nothing is scheduled, the task would otherwise be resumed later
+			db.Delete(task)
+		}
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func onPluginInstalled(config *app.Config) plugin_manager.InstallTaskDoneHandler {
+	return func(
+		task *models.InstallTask,
+		pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+		declaration *plugin_entities.PluginDeclaration,
+		meta map[string]any,
+	) error {
+		runtimeType := plugin_entities.PluginRuntimeType("")
+
+		switch config.Platform {
+		case app.PLATFORM_SERVERLESS:
+			runtimeType = plugin_entities.PLUGIN_RUNTIME_TYPE_SERVERLESS
+		case app.PLATFORM_LOCAL:
+			runtimeType = plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL
+		default:
+			return fmt.Errorf("unsupported platform: %s", config.Platform)
+		}
 
-		declaration, err := helper.CombinedGetPluginDeclaration(
+		_, _, err := curd.InstallPlugin(
+			task.TenantID,
 			pluginUniqueIdentifier,
 			runtimeType,
+			declaration,
+			task.Source,
+			meta,
 		)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		i := i
-		tasks = append(tasks, func() {
-			updateTaskStatus := func(modifier func(task *models.InstallTask, plugin *models.InstallTaskPluginStatus)) {
-				if err := db.WithTransaction(func(tx *gorm.DB) error {
-					task, err := db.GetOne[models.InstallTask](
-						db.WithTransactionContext(tx),
-						db.Equal("id", task.ID),
-						db.WLock(), // write lock, multiple tasks can't update the same task
-					)
-
-					if err == db.ErrDatabaseNotFound {
-						return nil
-					}
-
-					if err != nil {
-						return err
-					}
-
-					taskPointer := &task
-					var pluginStatus *models.InstallTaskPluginStatus
-					for i := range task.Plugins {
-						if task.Plugins[i].PluginUniqueIdentifier == pluginUniqueIdentifier {
-							pluginStatus = &task.Plugins[i]
-							break
-						}
-					}
-
-					if pluginStatus == nil {
-						return nil
-					}
-
-					modifier(taskPointer, pluginStatus)
-
-					successes := 0
-					for _, plugin := range taskPointer.Plugins {
-						if plugin.Status == models.InstallTaskStatusSuccess {
-							successes++
-						}
-					}
-
-					if successes == len(taskPointer.Plugins) {
-						// update status
-						taskPointer.Status = models.InstallTaskStatusSuccess
-						// delete the task after 120 seconds without transaction
-						time.AfterFunc(120*time.Second, func() {
-							db.Delete(taskPointer)
-						})
-					}
-					return db.Update(taskPointer, tx)
-				}); err != nil {
-					log.Error("failed to update install task status %s", err.Error())
-				}
-			}
-
-			updateTaskStatus(func(task *models.InstallTask, plugin *models.InstallTaskPluginStatus) {
-				plugin.Status = models.InstallTaskStatusRunning
-				plugin.Message = "Installing"
-			})
-
-			var stream *stream.Stream[plugin_manager.PluginInstallResponse]
-			if config.Platform == app.PLATFORM_SERVERLESS {
-				var zipDecoder *decoder.ZipPluginDecoder
-				var pkgFile []byte
-
-				pkgFile, err = manager.GetPackage(pluginUniqueIdentifier)
-				if err != nil {
-					updateTaskStatus(func(task *models.InstallTask, plugin *models.InstallTaskPluginStatus) {
-						task.Status = models.InstallTaskStatusFailed
-						plugin.Status = models.InstallTaskStatusFailed
-						plugin.Message = "Failed to read plugin package"
-					})
-					return
-				}
-
-				zipDecoder, err = decoder.NewZipPluginDecoder(pkgFile)
-				if err != nil {
-					updateTaskStatus(func(task *models.InstallTask, plugin *models.InstallTaskPluginStatus) {
-						task.Status = models.InstallTaskStatusFailed
-						plugin.Status = models.InstallTaskStatusFailed
-						plugin.Message = err.Error()
-					})
-					return
-				}
-				stream, err = manager.InstallToServerlessFromPkg(pkgFile, zipDecoder, source, metas[i])
-			} else if config.Platform == app.PLATFORM_LOCAL {
-				stream, err = manager.InstallToLocal(pluginUniqueIdentifier, source, metas[i])
-			} else {
-				updateTaskStatus(func(task *models.InstallTask, plugin *models.InstallTaskPluginStatus) {
-					task.Status = models.InstallTaskStatusFailed
-					plugin.Status = models.InstallTaskStatusFailed
-					plugin.Message = "Unsupported platform"
-				})
-				return
-			}
-
-			if err != nil {
-				updateTaskStatus(func(task *models.InstallTask, plugin *models.InstallTaskPluginStatus) {
-					task.Status = models.InstallTaskStatusFailed
-					plugin.Status = models.InstallTaskStatusFailed
-					plugin.Message = err.Error()
-				})
-				return
-			}
-
-			for stream.Next() {
-				message, err := stream.Read()
-				if err != nil {
-					updateTaskStatus(func(task *models.InstallTask, plugin *models.InstallTaskPluginStatus) {
-						task.Status = models.InstallTaskStatusFailed
-						plugin.Status = models.InstallTaskStatusFailed
-						plugin.Message = err.Error()
-					})
-					return
-				}
-
-				if message.Event == plugin_manager.PluginInstallEventError {
-					updateTaskStatus(func(task *models.InstallTask, plugin *models.InstallTaskPluginStatus) {
-						task.Status = models.InstallTaskStatusFailed
-						plugin.Status = models.InstallTaskStatusFailed
-						plugin.Message = message.Data
-					})
-					return
-				}
-
-				if message.Event == plugin_manager.PluginInstallEventDone {
-					if err := onDone(pluginUniqueIdentifier, declaration, metas[i]); err != nil {
-						updateTaskStatus(func(task *models.InstallTask, plugin *models.InstallTaskPluginStatus) {
-							task.Status = models.InstallTaskStatusFailed
-							plugin.Status = models.InstallTaskStatusFailed
-							plugin.Message = "Failed to create plugin, perhaps it's already installed"
-						})
-						return
-					}
-				}
-			}
-
-			updateTaskStatus(func(task *models.InstallTask, plugin *models.InstallTaskPluginStatus) {
-				plugin.Status = models.InstallTaskStatusSuccess
-				plugin.Message = "Installed"
-				task.CompletedPlugins++
+		// installing a plugin consents to the permissions it requests
+		_, err = curd.ConsentPluginPermissions(
+			task.TenantID,
+			pluginUniqueIdentifier.PluginID(),
+			trust.Requested(declaration.Resource.Permission),
+		)
+		helper.InvalidatePluginPermissionConsent(task.TenantID, pluginUniqueIdentifier.PluginID())
+		if err != nil {
+			return err
+		}
 
-				// check if all plugins are installed
-				if task.CompletedPlugins == task.TotalPlugins {
-					task.Status = models.InstallTaskStatusSuccess
-				}
-			})
+		event_bus.Publish(event_bus.EVENT_PLUGIN_INSTALLED, task.TenantID, pluginUniqueIdentifier.String(), map[string]any{
+			"action": task.Action,
+			"source": task.Source,
 		})
+		return nil
 	}
-
-	// submit async tasks
-	routine.WithMaxRoutine(5, tasks)
-
-	return response, nil
 }
 
 func InstallPluginFromIdentifiers(
@@ -301,37 +268,22 @@ func InstallPluginFromIdentifiers(
 		plugin_unique_identifiers,
 		source,
 		metas,
-		func(
-			pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
-			declaration *plugin_entities.PluginDeclaration,
-			meta map[string]any,
-		) error {
-			runtimeType := plugin_entities.PluginRuntimeType("")
-
-			switch config.Platform {
-			case app.PLATFORM_SERVERLESS:
-				runtimeType = plugin_entities.PLUGIN_RUNTIME_TYPE_SERVERLESS
-			case app.PLATFORM_LOCAL:
-				runtimeType = plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL
-			default:
-				return fmt.Errorf("unsupported platform: %s", config.Platform)
-			}
-
-			_, _, err := curd.InstallPlugin(
-				tenant_id,
-				pluginUniqueIdentifier,
-				runtimeType,
-				declaration,
-				source,
-				meta,
-			)
-			return err
-		},
+		models.InstallTaskActionInstall,
+		"",
 	)
 	if err != nil {
 		if errors.Is(err, curd.ErrPluginAlreadyInstalled) {
 			return exception.BadRequestError(err).ToResponse()
 		}
+		if errors.Is(err, ErrTenantQuotaExceeded) {
+			return exception.QuotaExceededError(err).ToResponse()
+		}
+		if errors.Is(err, ErrDependencyConflict) {
+			return exception.BadRequestError(err).ToResponse()
+		}
+		if errors.Is(err, plugin_manager.ErrInstallQueueFull) {
+			return exception.TooManyRequestsError(err.Error()).ToResponse()
+		}
 		return exception.InternalServerError(err).ToResponse()
 	}
 
@@ -367,7 +319,7 @@ func ReinstallPluginFromIdentifier(
 		}
 
 		return stream, nil
-	}, ctx, 1800)
+	}, ctx, 1800, nil)
 }
 
 /*
@@ -391,6 +343,7 @@ func DecodePluginFromIdentifier(
 		&decoder.ThirdPartySignatureVerificationConfig{
 			Enabled:        config.ThirdPartySignatureVerificationEnabled,
 			PublicKeyPaths: config.ThirdPartySignatureVerificationPublicKeys,
+			KeyringPath:    config.ThirdPartySignatureVerificationKeyring,
 		},
 	)
 	if err != nil {
@@ -421,6 +374,49 @@ func UpgradePlugin(
 	meta map[string]any,
 	original_plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
 	new_plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
+) *entities.Response {
+	if errResponse := checkPluginUpgrade(
+		tenant_id,
+		source,
+		original_plugin_unique_identifier,
+		new_plugin_unique_identifier,
+	); errResponse != nil {
+		return errResponse
+	}
+
+	// install the new plugin runtime
+	response, err := InstallPluginRuntimeToTenant(
+		config,
+		tenant_id,
+		[]plugin_entities.PluginUniqueIdentifier{new_plugin_unique_identifier},
+		source,
+		[]map[string]any{meta},
+		models.InstallTaskActionUpgrade,
+		original_plugin_unique_identifier.String(),
+	)
+
+	if err != nil {
+		if errors.Is(err, ErrTenantQuotaExceeded) {
+			return exception.QuotaExceededError(err).ToResponse()
+		}
+		if errors.Is(err, ErrDependencyConflict) {
+			return exception.BadRequestError(err).ToResponse()
+		}
+		if errors.Is(err, plugin_manager.ErrInstallQueueFull) {
+			return exception.TooManyRequestsError(err.Error()).ToResponse()
+		}
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(response)
+}
+
+// checkPluginUpgrade returns the error response of an upgrade which can't be started, nil if it can
+func checkPluginUpgrade(
+	tenant_id string,
+	source string,
+	original_plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
+	new_plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
 ) *entities.Response {
 	if original_plugin_unique_identifier == new_plugin_unique_identifier {
 		return exception.BadRequestError(errors.New("original and new plugin unique identifier are the same")).ToResponse()
@@ -430,8 +426,7 @@ func UpgradePlugin(
 		return exception.BadRequestError(errors.New("original and new plugin id are different")).ToResponse()
 	}
 
-	// uninstall the original plugin
-	installation, err := db.GetOne[models.PluginInstallation](
+	_, err := db.GetOne[models.PluginInstallation](
 		db.Equal("tenant_id", tenant_id),
 		db.Equal("plugin_unique_identifier", original_plugin_unique_identifier.String()),
 		db.Equal("source", source),
@@ -445,78 +440,175 @@ func UpgradePlugin(
 		return exception.InternalServerError(err).ToResponse()
 	}
 
-	// install the new plugin runtime
-	response, err := InstallPluginRuntimeToTenant(
-		config,
-		tenant_id,
-		[]plugin_entities.PluginUniqueIdentifier{new_plugin_unique_identifier},
-		source,
-		[]map[string]any{meta},
-		func(
-			pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
-			declaration *plugin_entities.PluginDeclaration,
-			meta map[string]any,
-		) error {
-			originalDeclaration, err := helper.CombinedGetPluginDeclaration(
-				original_plugin_unique_identifier,
-				plugin_entities.PluginRuntimeType(installation.RuntimeType),
-			)
-			if err != nil {
-				return err
-			}
+	return nil
+}
 
-			newDeclaration, err := helper.CombinedGetPluginDeclaration(
-				new_plugin_unique_identifier,
-				plugin_entities.PluginRuntimeType(installation.RuntimeType),
-			)
-			if err != nil {
-				return err
-			}
+// onPluginUpgraded moves the installation of the tenant to the new version, endpoints and credentials are
+// bound by plugin id and follow it, the tenant is moved back if the new runtime fails its health check
+func onPluginUpgraded(config *app.Config) plugin_manager.InstallTaskDoneHandler {
+	return func(
+		task *models.InstallTask,
+		new_plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
+		declaration *plugin_entities.PluginDeclaration,
+		meta map[string]any,
+	) error {
+		original_plugin_unique_identifier, err := plugin_entities.NewPluginUniqueIdentifier(
+			task.OriginalPluginUniqueIdentifier,
+		)
+		if err != nil {
+			return err
+		}
 
-			// uninstall the original plugin
-			upgradeResponse, err := curd.UpgradePlugin(
-				tenant_id,
-				original_plugin_unique_identifier,
-				new_plugin_unique_identifier,
-				originalDeclaration,
-				newDeclaration,
-				plugin_entities.PluginRuntimeType(installation.RuntimeType),
-				source,
-				meta,
-			)
+		installation, err := db.GetOne[models.PluginInstallation](
+			db.Equal("tenant_id", task.TenantID),
+			db.Equal("plugin_unique_identifier", original_plugin_unique_identifier.String()),
+			db.Equal("source", task.Source),
+		)
+		if err != nil {
+			return err
+		}
+		runtimeType := plugin_entities.PluginRuntimeType(installation.RuntimeType)
 
-			if err != nil {
-				return err
-			}
+		originalDeclaration, err := helper.CombinedGetPluginDeclaration(original_plugin_unique_identifier, runtimeType)
+		if err != nil {
+			return err
+		}
 
-			// invalidate plugin installation cache
-			pluginInstallationCacheKey := helper.PluginInstallationCacheKey(original_plugin_unique_identifier.PluginID(), tenant_id)
-			_, _ = cache.AutoDelete[models.PluginInstallation](pluginInstallationCacheKey)
-
-			if upgradeResponse.IsOriginalPluginDeleted {
-				// delete the plugin if no installation left
-				manager := plugin_manager.Manager()
-				if string(upgradeResponse.DeletedPlugin.InstallType) == string(
-					plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL,
-				) {
-					err = manager.UninstallFromLocal(
-						plugin_entities.PluginUniqueIdentifier(upgradeResponse.DeletedPlugin.PluginUniqueIdentifier),
-					)
-					if err != nil {
-						return err
-					}
-				}
+		newDeclaration, err := helper.CombinedGetPluginDeclaration(new_plugin_unique_identifier, runtimeType)
+		if err != nil {
+			return err
+		}
+
+		upgradeResponse, err := upgradePluginInstallation(
+			task.TenantID,
+			task.Source,
+			meta,
+			original_plugin_unique_identifier,
+			new_plugin_unique_identifier,
+			originalDeclaration,
+			newDeclaration,
+			runtimeType,
+		)
+		if err != nil {
+			return err
+		}
+
+		// the previous version keeps running until the new one proved healthy
+		manager := plugin_manager.Manager()
+		period := time.Duration(config.PluginUpgradeHealthCheckPeriod) * time.Second
+		if err := manager.CheckRuntimeHealth(new_plugin_unique_identifier, period); err != nil {
+			if rollbackErr := rollbackPluginUpgrade(
+				task, installation, original_plugin_unique_identifier, new_plugin_unique_identifier,
+				originalDeclaration, newDeclaration, runtimeType,
+			); rollbackErr != nil {
+				return errors.Join(err, rollbackErr, errors.New("failed to roll back the upgrade"))
 			}
+			return fmt.Errorf("%w, %s failed its health check: %s", plugin_manager.ErrUpgradeRolledBack, new_plugin_unique_identifier, err.Error())
+		}
 
-			return nil
-		},
+		event_bus.Publish(event_bus.EVENT_PLUGIN_UPGRADED, task.TenantID, new_plugin_unique_identifier.String(), map[string]any{
+			"original_plugin_unique_identifier": original_plugin_unique_identifier.String(),
+			"source":                            task.Source,
+		})
+
+		if upgradeResponse.IsOriginalPluginDeleted {
+			return uninstallLocalRuntime(upgradeResponse.DeletedPlugin)
+		}
+
+		return nil
+	}
+}
+
+// upgradePluginInstallation moves the installation of the tenant from original to new, permissions
+// added by the new version are not consented until the tenant approves them
+func upgradePluginInstallation(
+	tenantId string,
+	source string,
+	meta map[string]any,
+	original_plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
+	new_plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
+	originalDeclaration *plugin_entities.PluginDeclaration,
+	newDeclaration *plugin_entities.PluginDeclaration,
+	runtimeType plugin_entities.PluginRuntimeType,
+) (*curd.UpgradePluginResponse, error) {
+	err := curd.EnsurePluginPermissionConsent(
+		tenantId,
+		original_plugin_unique_identifier.PluginID(),
+		trust.Requested(originalDeclaration.Resource.Permission),
+	)
+	if err != nil {
+		return nil, err
+	}
+	helper.InvalidatePluginPermissionConsent(tenantId, original_plugin_unique_identifier.PluginID())
+
+	upgradeResponse, err := curd.UpgradePlugin(
+		tenantId,
+		original_plugin_unique_identifier,
+		new_plugin_unique_identifier,
+		originalDeclaration,
+		newDeclaration,
+		runtimeType,
+		source,
+		meta,
+	)
+	if err != nil {
+		return nil, err
+	}
+	invalidatePluginInstallation(tenantId, original_plugin_unique_identifier.PluginID())
+
+	return upgradeResponse, nil
+}
+
+// rollbackPluginUpgrade moves the installation back to the original version with its original meta and
+// removes the new runtime if no other tenant uses it
+func rollbackPluginUpgrade(
+	task *models.InstallTask,
+	installation models.PluginInstallation,
+	original_plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
+	new_plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
+	originalDeclaration *plugin_entities.PluginDeclaration,
+	newDeclaration *plugin_entities.PluginDeclaration,
+	runtimeType plugin_entities.PluginRuntimeType,
+) error {
+	log.Warn(
+		"rolling back the upgrade of tenant %s from %s to %s",
+		task.TenantID, original_plugin_unique_identifier, new_plugin_unique_identifier,
 	)
 
+	rollbackResponse, err := curd.UpgradePlugin(
+		task.TenantID,
+		new_plugin_unique_identifier,
+		original_plugin_unique_identifier,
+		newDeclaration,
+		originalDeclaration,
+		runtimeType,
+		task.Source,
+		installation.Meta,
+	)
 	if err != nil {
-		return exception.InternalServerError(err).ToResponse()
+		return err
 	}
+	invalidatePluginInstallation(task.TenantID, original_plugin_unique_identifier.PluginID())
 
-	return entities.NewSuccessResponse(response)
+	if rollbackResponse.IsOriginalPluginDeleted {
+		return uninstallLocalRuntime(rollbackResponse.DeletedPlugin)
+	}
+	return nil
+}
+
+func invalidatePluginInstallation(tenantId string, pluginId string) {
+	pluginInstallationCacheKey := helper.PluginInstallationCacheKey(pluginId, tenantId)
+	_, _ = cache.AutoDelete[models.PluginInstallation](pluginInstallationCacheKey)
+}
+
+// uninstallLocalRuntime removes the runtime of a plugin no installation refers to anymore
+func uninstallLocalRuntime(plugin *models.Plugin) error {
+	if string(plugin.InstallType) != string(plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL) {
+		return nil
+	}
+	return plugin_manager.Manager().UninstallFromLocal(
+		plugin_entities.PluginUniqueIdentifier(plugin.PluginUniqueIdentifier),
+	)
 }
 
 func FetchPluginInstallationTasks(
@@ -536,6 +628,33 @@ func FetchPluginInstallationTasks(
 	return entities.NewSuccessResponse(tasks)
 }
 
+// FetchPluginInstallQueue lists install tasks of the tenant along with the queue of current node
+func FetchPluginInstallQueue(
+	tenant_id string,
+	status string,
+	page int,
+	page_size int,
+) *entities.Response {
+	queries := []db.GenericQuery{
+		db.Equal("tenant_id", tenant_id),
+		db.OrderBy("created_at", true),
+		db.Page(page, page_size),
+	}
+	if status != "" {
+		queries = append(queries, db.Equal("status", status))
+	}
+
+	tasks, err := db.GetAll[models.InstallTask](queries...)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(map[string]any{
+		"tasks": tasks,
+		"queue": plugin_manager.Manager().InstallQueue().Stats(),
+	})
+}
+
 func FetchPluginInstallationTask(
 	tenant_id string,
 	task_id string,
@@ -651,25 +770,44 @@ func FetchPluginFromIdentifier(
 	return entities.NewSuccessResponse(true)
 }
 
+// UninstallPlugin refuses to remove a plugin still referenced unless force is set, see UninstallPluginPreflight
 func UninstallPlugin(
 	tenant_id string,
 	plugin_installation_id string,
+	force bool,
 ) *entities.Response {
+	if !force {
+		if err := checkUninstallPreflight(tenant_id, plugin_installation_id); err != nil {
+			return err.ToResponse()
+		}
+	}
+
+	if err := uninstallPlugin(tenant_id, plugin_installation_id); err != nil {
+		return err.ToResponse()
+	}
+
+	return entities.NewSuccessResponse(true)
+}
+
+func uninstallPlugin(
+	tenant_id string,
+	plugin_installation_id string,
+) exception.PluginDaemonError {
 	// Check if the plugin exists for the tenant
 	installation, err := db.GetOne[models.PluginInstallation](
 		db.Equal("tenant_id", tenant_id),
 		db.Equal("id", plugin_installation_id),
 	)
 	if err == db.ErrDatabaseNotFound {
-		return exception.ErrPluginNotFound().ToResponse()
+		return exception.ErrPluginNotFound()
 	}
 	if err != nil {
-		return exception.InternalServerError(err).ToResponse()
+		return exception.InternalServerError(err)
 	}
 
 	pluginUniqueIdentifier, err := plugin_entities.NewPluginUniqueIdentifier(installation.PluginUniqueIdentifier)
 	if err != nil {
-		return exception.UniqueIdentifierError(err).ToResponse()
+		return exception.UniqueIdentifierError(err)
 	}
 
 	// get declaration
@@ -678,7 +816,12 @@ func UninstallPlugin(
 		plugin_entities.PluginRuntimeType(installation.RuntimeType),
 	)
 	if err != nil {
-		return exception.InternalServerError(err).ToResponse()
+		return exception.InternalServerError(err)
+	}
+
+	// versions installed besides it go along with the plugin
+	if err := uninstallPluginVersions(tenant_id, pluginUniqueIdentifier.PluginID()); err != nil {
+		return err
 	}
 
 	// Uninstall the plugin
@@ -689,13 +832,18 @@ func UninstallPlugin(
 		declaration,
 	)
 	if err != nil {
-		return exception.InternalServerError(fmt.Errorf("failed to uninstall plugin: %s", err.Error())).ToResponse()
+		return exception.InternalServerError(fmt.Errorf("failed to uninstall plugin: %s", err.Error()))
 	}
 
 	// invalidate plugin installation cache
 	pluginInstallationCacheKey := helper.PluginInstallationCacheKey(pluginUniqueIdentifier.PluginID(), tenant_id)
 	_, _ = cache.AutoDelete[models.PluginInstallation](pluginInstallationCacheKey)
 
+	if err := curd.DeletePluginPermissionConsent(tenant_id, pluginUniqueIdentifier.PluginID()); err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to delete permission consent: %s", err.Error()))
+	}
+	helper.InvalidatePluginPermissionConsent(tenant_id, pluginUniqueIdentifier.PluginID())
+
 	if deleteResponse.IsPluginDeleted {
 		// delete the plugin if no installation left
 		manager := plugin_manager.Manager()
@@ -704,10 +852,13 @@ func UninstallPlugin(
 		) {
 			err = manager.UninstallFromLocal(pluginUniqueIdentifier)
 			if err != nil {
-				return exception.InternalServerError(fmt.Errorf("failed to uninstall plugin: %s", err.Error())).ToResponse()
+				return exception.InternalServerError(fmt.Errorf("failed to uninstall plugin: %s", err.Error()))
 			}
 		}
 	}
 
-	return entities.NewSuccessResponse(true)
+	event_bus.Publish(event_bus.EVENT_PLUGIN_UNINSTALLED, tenant_id, pluginUniqueIdentifier.String(), map[string]any{
+		"plugin_installation_id": installation.ID,
+	})
+	return nil
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/internal/types/models/curd"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/strings"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/endpoint_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 	"gorm.io/gorm"
 )
@@ -236,6 +237,12 @@ func DisabledEndpoint(endpoint_id string, tenant_id string) (*models.Endpoint, e
 	return &endpoint, err
 }
 
+func UpdateEndpointMiddlewares(endpoint *models.Endpoint, middlewares []endpoint_entities.EndpointMiddleware) error {
+	endpoint.Middlewares = middlewares
+
+	return db.Update(endpoint)
+}
+
 func UpdateEndpoint(endpoint *models.Endpoint, name string, settings map[string]any) error {
 	endpoint.Name = name
 	endpoint.Settings = settings
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/tenant_variables"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// InvalidInvocationError is returned by PrepareInvocation for invocations rejected because of the request
+type InvalidInvocationError struct {
+	err error
+}
+
+func (e *InvalidInvocationError) Error() string {
+	return e.err.Error()
+}
+
+func (e *InvalidInvocationError) Unwrap() error {
+	return e.err
+}
+
+// PrepareInvocation runs the steps every invocation goes through before reaching the plugin, whichever
+// api it came from: references to tenant variables and oauth credentials are resolved, tool parameters
+// are coerced and the timeout in seconds is returned, timeout_header is the timeout asked by the caller
+func PrepareInvocation[T any](
+	ctx context.Context,
+	session *session_manager.Session,
+	request *plugin_entities.InvokePluginRequest[T],
+	timeout_header string,
+	max_timeout_seconds int,
+) (int, error) {
+	reject := func(err error) (int, error) {
+		session.Fail(exception.PluginDaemonBadRequestError, err.Error())
+		return 0, &InvalidInvocationError{err: err}
+	}
+
+	if err := tenant_variables.ResolveCredentials(request.TenantId, &request.Data); err != nil {
+		return reject(err)
+	}
+
+	if err := injectOAuthCredentials(ctx, session.ClusterID, request, max_timeout_seconds); err != nil {
+		return reject(err)
+	}
+
+	if err := coerceToolParameters(session, request); err != nil {
+		return reject(err)
+	}
+
+	timeout, err := invokeTimeout(timeout_header, session.Declaration, max_timeout_seconds)
+	if err != nil {
+		return reject(err)
+	}
+
+	return timeout, nil
+}
+
+// Invoke sends a prepared invocation to the plugin, responses of tools are checked against their
+// output schema
+func Invoke[T any, R any](
+	session *session_manager.Session,
+	request *plugin_entities.InvokePluginRequest[T],
+	generator func(*session_manager.Session) (*stream.Stream[R], error),
+) (*stream.Stream[R], error) {
+	response, err := generator(session)
+	if err != nil {
+		return nil, err
+	}
+	return checkToolOutput(session, request, response), nil
+}
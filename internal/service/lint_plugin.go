@@ -0,0 +1,20 @@
+package service
+
+import (
+	"io"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/linter"
+)
+
+// LintPluginPkg lints a package without installing it, a package with errors is linted successfully
+// as well, it's up to the caller to reject it
+func LintPluginPkg(difyPkgFile io.Reader) *entities.Response {
+	pkg, err := io.ReadAll(difyPkgFile)
+	if err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(linter.LintPackage(pkg))
+}
@@ -0,0 +1,338 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/oauth_credentials"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/oauth_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+)
+
+const (
+	OAUTH_REFRESH_LOCK_PREFIX = "oauth_credentials:refresh"
+	OAUTH_REFRESH_WAIT        = time.Second * 10
+)
+
+// invokeOAuth invokes an oauth action of a plugin and waits for its only result
+func invokeOAuth[Req any, Rsp any](
+	ctx context.Context,
+	cluster_id string,
+	tenant_id string,
+	user_id string,
+	identifier plugin_entities.PluginUniqueIdentifier,
+	action access_types.PluginAccessAction,
+	data Req,
+	invoke func(*session_manager.Session, *Req) (*stream.Stream[Rsp], error),
+	max_timeout_seconds int,
+) (*Rsp, error) {
+	request := plugin_entities.InvokePluginRequest[Req]{
+		InvokePluginUserIdentity: plugin_entities.InvokePluginUserIdentity{
+			TenantId: tenant_id,
+			UserId:   user_id,
+		},
+		BasePluginIdentifier: plugin_entities.BasePluginIdentifier{
+			PluginID: identifier.PluginID(),
+		},
+		UniqueIdentifier: identifier,
+		Data:             data,
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, time.Duration(max_timeout_seconds)*time.Second)
+	defer cancel()
+
+	session, err := CreateSession(&request, access_types.PLUGIN_ACCESS_TYPE_OAUTH, action, cluster_id, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close(session_manager.CloseSessionPayload{
+		IgnoreCache: false,
+	})
+
+	response, err := invoke(session, &request.Data)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		<-timeout.Done()
+		response.Close()
+	}()
+
+	var result *Rsp
+	for response.Next() {
+		chunk, err := response.Read()
+		if err != nil {
+			return nil, err
+		}
+		result = &chunk
+	}
+
+	if errors.Is(timeout.Err(), context.DeadlineExceeded) {
+		return nil, errors.New("killed by timeout")
+	}
+	if result == nil {
+		return nil, errors.New("plugin returned no result")
+	}
+	return result, nil
+}
+
+// AuthorizeOAuth asks the plugin for the authorization url of the provider, the state of the url is
+// replaced by one the daemon recognizes the callback with
+func AuthorizeOAuth(
+	ctx *gin.Context,
+	r *plugin_entities.InvokePluginRequest[requests.RequestOAuthAuthorize],
+	max_timeout_seconds int,
+) *entities.Response {
+	result, err := invokeOAuth(
+		ctx.Request.Context(),
+		ctx.GetString("cluster_id"),
+		r.TenantId,
+		r.UserId,
+		r.UniqueIdentifier,
+		access_types.PLUGIN_ACCESS_ACTION_GET_AUTHORIZATION_URL,
+		requests.RequestOAuthGetAuthorizationURL{
+			Provider:          r.Data.Provider,
+			RedirectURI:       r.Data.RedirectURI,
+			SystemCredentials: r.Data.SystemCredentials,
+		},
+		plugin_daemon.GetAuthorizationURL,
+		max_timeout_seconds,
+	)
+	if err != nil {
+		return exception.InvokePluginError(err).ToResponse()
+	}
+
+	state, err := oauth_credentials.BeginAuthorization(oauth_credentials.Authorization{
+		TenantID:    r.TenantId,
+		UserID:      r.UserId,
+		PluginID:    r.UniqueIdentifier.PluginID(),
+		Provider:    r.Data.Provider,
+		RedirectURI: r.Data.RedirectURI,
+		ReturnURL:   r.Data.ReturnURL,
+	}, r.Data.SystemCredentials)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	authorizationURL, err := oauth_credentials.WithState(result.AuthorizationURL, state)
+	if err != nil {
+		return exception.InvokePluginError(fmt.Errorf("invalid authorization url: %s", err.Error())).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(oauth_entities.OAuthAuthorizeResult{
+		AuthorizationURL: authorizationURL,
+		State:            state,
+	})
+}
+
+// OAuthCallback passes the request the oauth provider redirected the browser with to the plugin
+// and stores the credentials it exchanged the code for
+func OAuthCallback(ctx *gin.Context, max_timeout_seconds int) {
+	authorization, systemCredentials, err := oauth_credentials.TakeAuthorization(ctx.Query("state"))
+	if err == oauth_credentials.ErrAuthorizationNotFound {
+		ctx.JSON(http.StatusBadRequest, exception.BadRequestError(err).ToResponse())
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, exception.InternalServerError(err).ToResponse())
+		return
+	}
+
+	installation, err := db.GetOne[models.PluginInstallation](
+		db.Equal("tenant_id", authorization.TenantID),
+		db.Equal("plugin_id", authorization.PluginID),
+	)
+	if err == db.ErrDatabaseNotFound {
+		ctx.JSON(http.StatusNotFound, exception.ErrPluginNotFound().ToResponse())
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, exception.InternalServerError(err).ToResponse())
+		return
+	}
+	identifier, err := plugin_entities.NewPluginUniqueIdentifier(installation.PluginUniqueIdentifier)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, exception.UniqueIdentifierError(err).ToResponse())
+		return
+	}
+
+	raw, err := httputil.DumpRequest(ctx.Request, true)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, exception.BadRequestError(err).ToResponse())
+		return
+	}
+
+	result, err := invokeOAuth(
+		ctx.Request.Context(),
+		ctx.GetString("cluster_id"),
+		authorization.TenantID,
+		authorization.UserID,
+		identifier,
+		access_types.PLUGIN_ACCESS_ACTION_GET_CREDENTIALS,
+		requests.RequestOAuthGetCredentials{
+			Provider:          authorization.Provider,
+			RedirectURI:       authorization.RedirectURI,
+			SystemCredentials: systemCredentials,
+			RawHttpRequest:    hex.EncodeToString(raw),
+		},
+		plugin_daemon.GetCredentials,
+		max_timeout_seconds,
+	)
+	if err != nil {
+		ctx.JSON(http.StatusOK, exception.InvokePluginError(err).ToResponse())
+		return
+	}
+
+	if err := oauth_credentials.Save(&oauth_credentials.Credential{
+		TenantID:          authorization.TenantID,
+		PluginID:          authorization.PluginID,
+		Provider:          authorization.Provider,
+		RedirectURI:       authorization.RedirectURI,
+		SystemCredentials: systemCredentials,
+		Credentials:       result.Credentials,
+		ExpiresAt:         result.ExpiresAt,
+	}); err != nil {
+		ctx.JSON(http.StatusInternalServerError, exception.InternalServerError(err).ToResponse())
+		return
+	}
+
+	if authorization.ReturnURL != "" {
+		ctx.Redirect(http.StatusFound, authorization.ReturnURL)
+		return
+	}
+	ctx.JSON(http.StatusOK, entities.NewSuccessResponse(map[string]any{
+		"provider":   authorization.Provider,
+		"expires_at": result.ExpiresAt,
+	}))
+}
+
+// freshOAuthCredentials returns the stored credentials of the provider, refreshing them through the
+// plugin first if the access token is about to expire, one node refreshes while others wait for it
+func freshOAuthCredentials(
+	ctx context.Context,
+	cluster_id string,
+	tenant_id string,
+	user_id string,
+	identifier plugin_entities.PluginUniqueIdentifier,
+	provider string,
+	max_timeout_seconds int,
+) (map[string]any, error) {
+	credential, err := oauth_credentials.Load(tenant_id, identifier.PluginID(), provider)
+	if err != nil {
+		return nil, err
+	}
+	if !credential.NeedsRefresh(time.Now()) {
+		return credential.Credentials, nil
+	}
+
+	lock := fmt.Sprintf("%s:%s:%s:%s", OAUTH_REFRESH_LOCK_PREFIX, tenant_id, identifier.PluginID(), provider)
+	locked, err := cache.SetNX(lock, true, time.Duration(max_timeout_seconds)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		deadline := time.Now().Add(OAUTH_REFRESH_WAIT)
+		for time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond * 200)
+			credential, err = oauth_credentials.Load(tenant_id, identifier.PluginID(), provider)
+			if err != nil {
+				return nil, err
+			}
+			if !credential.NeedsRefresh(time.Now()) {
+				return credential.Credentials, nil
+			}
+		}
+		return nil, errors.New("oauth credentials are being refreshed, try again later")
+	}
+	defer cache.Del(lock)
+
+	result, err := invokeOAuth(
+		ctx,
+		cluster_id,
+		tenant_id,
+		user_id,
+		identifier,
+		access_types.PLUGIN_ACCESS_ACTION_REFRESH_CREDENTIALS,
+		requests.RequestOAuthRefreshCredentials{
+			Provider:          provider,
+			RedirectURI:       credential.RedirectURI,
+			SystemCredentials: credential.SystemCredentials,
+			Credentials:       credential.Credentials,
+		},
+		plugin_daemon.RefreshCredentials,
+		max_timeout_seconds,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh oauth credentials: %s", err.Error())
+	}
+
+	// providers may leave the refresh token out if it doesn't rotate, keep the one stored
+	for key, value := range result.Credentials {
+		credential.Credentials[key] = value
+	}
+	credential.ExpiresAt = result.ExpiresAt
+	if err := oauth_credentials.Save(credential); err != nil {
+		return nil, err
+	}
+	return credential.Credentials, nil
+}
+
+// injectOAuthCredentials fills in the credentials of tool invocations asking for oauth2 credentials
+// without carrying any, those the caller passed are kept as they are
+func injectOAuthCredentials[T any](
+	ctx context.Context,
+	cluster_id string,
+	request *plugin_entities.InvokePluginRequest[T],
+	max_timeout_seconds int,
+) error {
+	invocation, ok := any(&request.Data).(*requests.RequestInvokeTool)
+	if !ok || invocation.CredentialType != requests.CREDENTIAL_TYPE_OAUTH2 || len(invocation.Credentials.Credentials) > 0 {
+		return nil
+	}
+
+	credentials, err := freshOAuthCredentials(
+		ctx,
+		cluster_id,
+		request.TenantId,
+		request.UserId,
+		request.UniqueIdentifier,
+		invocation.Provider,
+		max_timeout_seconds,
+	)
+	if err != nil {
+		return err
+	}
+	invocation.Credentials.Credentials = credentials
+	return nil
+}
+
+func ListOAuthCredentials(tenant_id string) *entities.Response {
+	credentials, err := oauth_credentials.List(tenant_id)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	return entities.NewSuccessResponse(credentials)
+}
+
+func DeleteOAuthCredentials(tenant_id string, plugin_id string, provider string) *entities.Response {
+	if err := oauth_credentials.Delete(tenant_id, plugin_id, provider); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	return entities.NewSuccessResponse(true)
+}
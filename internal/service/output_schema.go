@@ -0,0 +1,35 @@
+package service
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/core/output_schema"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
+)
+
+// checkToolOutput checks the response of a tool invocation against the output schema of the tool,
+// responses of other actions are returned as they are
+func checkToolOutput[T any, R any](
+	session *session_manager.Session,
+	request *plugin_entities.InvokePluginRequest[T],
+	response *stream.Stream[R],
+) *stream.Stream[R] {
+	if session.Action != access_types.PLUGIN_ACCESS_ACTION_INVOKE_TOOL || !output_schema.Enabled() {
+		return response
+	}
+
+	toolResponse, ok := any(response).(*stream.Stream[tool_entities.ToolResponseChunk])
+	if !ok {
+		return response
+	}
+	toolRequest, ok := any(&request.Data).(*requests.RequestInvokeTool)
+	if !ok {
+		return response
+	}
+
+	checked := output_schema.Check(session.Declaration, toolRequest.Provider, toolRequest.Tool, toolResponse)
+	return any(checked).(*stream.Stream[R])
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/trust"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models/curd"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+type PluginPermissionConsentResponse struct {
+	PluginID               string   `json:"plugin_id"`
+	PluginUniqueIdentifier string   `json:"plugin_unique_identifier"`
+	Requested              []string `json:"requested"`
+	Consented              []string `json:"consented"`
+	// Pending are requested by the installed version but not consented, they are denied until consented
+	Pending []string `json:"pending"`
+}
+
+func fetchRequestedPermissions(tenant_id string, plugin_id string) (*models.PluginInstallation, []string, error) {
+	installation, err := db.GetOne[models.PluginInstallation](
+		db.Equal("tenant_id", tenant_id),
+		db.Equal("plugin_id", plugin_id),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pluginUniqueIdentifier, err := plugin_entities.NewPluginUniqueIdentifier(installation.PluginUniqueIdentifier)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	declaration, err := helper.CombinedGetPluginDeclaration(
+		pluginUniqueIdentifier,
+		plugin_entities.PluginRuntimeType(installation.RuntimeType),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &installation, trust.Requested(declaration.Resource.Permission), nil
+}
+
+func buildPluginPermissionConsentResponse(
+	installation *models.PluginInstallation,
+	requested []string,
+	consent *models.PluginPermissionConsent,
+) *PluginPermissionConsentResponse {
+	response := &PluginPermissionConsentResponse{
+		PluginID:               installation.PluginID,
+		PluginUniqueIdentifier: installation.PluginUniqueIdentifier,
+		Requested:              requested,
+		Consented:              []string{},
+		Pending:                []string{},
+	}
+
+	for _, permission := range requested {
+		if consent.Allows(permission) {
+			response.Consented = append(response.Consented, permission)
+		} else {
+			response.Pending = append(response.Pending, permission)
+		}
+	}
+
+	return response
+}
+
+func FetchPluginPermissionConsent(tenant_id string, plugin_id string) *entities.Response {
+	installation, requested, err := fetchRequestedPermissions(tenant_id, plugin_id)
+	if err == db.ErrDatabaseNotFound {
+		return exception.ErrPluginNotFound().ToResponse()
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	consent, err := helper.GetPluginPermissionConsent(tenant_id, plugin_id)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(buildPluginPermissionConsentResponse(installation, requested, consent))
+}
+
+func ConsentPluginPermissions(tenant_id string, plugin_id string, permissions []string) *entities.Response {
+	installation, requested, err := fetchRequestedPermissions(tenant_id, plugin_id)
+	if err == db.ErrDatabaseNotFound {
+		return exception.ErrPluginNotFound().ToResponse()
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	for _, permission := range permissions {
+		if !slices.Contains(requested, permission) {
+			return exception.BadRequestError(
+				fmt.Errorf("permission %s is not requested by plugin %s", permission, plugin_id),
+			).ToResponse()
+		}
+	}
+
+	consent, err := helper.GetPluginPermissionConsent(tenant_id, plugin_id)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	// a tenant without consent record has implicitly consented to everything it has been using
+	if consent.ID == "" {
+		permissions = append(slices.Clone(requested), permissions...)
+	}
+
+	consent, err = curd.ConsentPluginPermissions(tenant_id, plugin_id, permissions)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	helper.InvalidatePluginPermissionConsent(tenant_id, plugin_id)
+
+	return entities.NewSuccessResponse(buildPluginPermissionConsentResponse(installation, requested, consent))
+}
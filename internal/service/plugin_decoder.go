@@ -1,15 +1,26 @@
 package service
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"mime/multipart"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/marketplace_mirror"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models/curd"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/downloader"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/lock"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/pkg/bundle_packager"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/bundle_entities"
@@ -17,6 +28,9 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
 )
 
+// downloadLock serializes downloads of the same package, keyed by checksum
+var downloadLock = lock.NewGranularityLock()
+
 func UploadPluginPkg(
 	config *app.Config,
 	c *gin.Context,
@@ -24,12 +38,92 @@ func UploadPluginPkg(
 	difyPkgFile multipart.File,
 	verifySignature bool,
 ) *entities.Response {
-	pluginFile, err := io.ReadAll(difyPkgFile)
+	// the package is decoded and saved right from the uploaded file, it's never loaded into memory
+	size, err := difyPkgFile.Seek(0, io.SeekEnd)
 	if err != nil {
 		return exception.InternalServerError(err).ToResponse()
 	}
 
-	decoderInstance, err := decoder.NewZipPluginDecoderWithSizeLimit(pluginFile, config.MaxPluginPackageSize)
+	return savePluginPkg(config, difyPkgFile, size, verifySignature, nil)
+}
+
+// DownloadPluginPkgFromMarketplace fetches the package from the marketplace in parallel byte ranges,
+// an interrupted download is resumed on the next request of the same identifier, packages go through the
+// marketplace mirror if it's enabled
+func DownloadPluginPkgFromMarketplace(
+	config *app.Config,
+	c *gin.Context,
+	tenantId string,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	checksum string,
+	verifySignature bool,
+) *entities.Response {
+	if marketplace_mirror.Enabled() {
+		pluginFile, err := marketplace_mirror.GetMirror().Load(c, pluginUniqueIdentifier, checksum)
+		if err != nil {
+			if errors.Is(err, downloader.ErrChecksumMismatch) || errors.Is(err, downloader.ErrSizeExceeded) {
+				return exception.BadRequestError(err).ToResponse()
+			} else if errors.Is(err, marketplace_mirror.ErrNotMirrored) {
+				return exception.NotFoundError(err).ToResponse()
+			} else if errors.Is(err, marketplace_mirror.ErrUpstreamUnavailable) {
+				return exception.UnavailableError(err.Error()).ToResponse()
+			}
+			return exception.InternalServerError(errors.Join(err, errors.New("failed to download package"))).ToResponse()
+		}
+		return savePluginPkg(config, bytes.NewReader(pluginFile), int64(len(pluginFile)), verifySignature, &pluginUniqueIdentifier)
+	}
+
+	// the path is fixed so that interrupted downloads are resumed, installs of the same package
+	// on this node take turns instead of writing the same partial file
+	downloadLock.Lock(pluginUniqueIdentifier.Checksum())
+	defer downloadLock.Unlock(pluginUniqueIdentifier.Checksum())
+
+	path := filepath.Join(config.PluginPackageCachePath, "downloads", pluginUniqueIdentifier.Checksum())
+	url := fmt.Sprintf(
+		"%s/api/v1/plugins/download?unique_identifier=%s",
+		strings.TrimSuffix(config.MarketplaceURL, "/"),
+		neturl.QueryEscape(pluginUniqueIdentifier.String()),
+	)
+
+	err := downloader.Download(c, url, path, downloader.Options{
+		Header:      marketplace_mirror.MarketplaceHeader(config.MarketplaceAPIKey),
+		Concurrency: config.PluginDownloadConcurrency,
+		ChunkSize:   config.PluginDownloadChunkSize,
+		MaxSize:     config.MaxPluginPackageSize,
+		Checksum:    checksum,
+	})
+	if err != nil {
+		if errors.Is(err, downloader.ErrChecksumMismatch) || errors.Is(err, downloader.ErrSizeExceeded) {
+			return exception.BadRequestError(err).ToResponse()
+		}
+		return exception.InternalServerError(errors.Join(err, errors.New("failed to download package"))).ToResponse()
+	}
+	defer os.Remove(path)
+
+	// the downloaded file is decoded and saved in place, it's never loaded into memory
+	pluginFile, err := os.Open(path)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	defer pluginFile.Close()
+
+	state, err := pluginFile.Stat()
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return savePluginPkg(config, pluginFile, state.Size(), verifySignature, &pluginUniqueIdentifier)
+}
+
+// savePluginPkg decodes the package from pluginFile and streams it into the package bucket
+func savePluginPkg(
+	config *app.Config,
+	pluginFile io.ReaderAt,
+	size int64,
+	verifySignature bool,
+	expectedIdentifier *plugin_entities.PluginUniqueIdentifier,
+) *entities.Response {
+	decoderInstance, err := decoder.NewZipPluginDecoderWithReaderAt(pluginFile, size, config.MaxPluginPackageSize, nil)
 	if err != nil {
 		return exception.BadRequestError(err).ToResponse()
 	}
@@ -39,15 +133,32 @@ func UploadPluginPkg(
 		return exception.BadRequestError(err).ToResponse()
 	}
 
+	if expectedIdentifier != nil && *expectedIdentifier != pluginUniqueIdentifier {
+		return exception.BadRequestError(fmt.Errorf(
+			"plugin unique identifier mismatch, expected %s, got %s", expectedIdentifier, pluginUniqueIdentifier,
+		)).ToResponse()
+	}
+
 	// avoid author to be a uuid
 	if pluginUniqueIdentifier.RemoteLike() {
 		return exception.BadRequestError(errors.New("author cannot be a uuid")).ToResponse()
 	}
 
+	secretFindings, err := scanPluginSecrets(config, pluginUniqueIdentifier, decoderInstance)
+	if err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+
+	vulnerabilityReport, err := scanPluginVulnerabilities(config, pluginUniqueIdentifier, decoderInstance)
+	if err != nil {
+		return vulnerabilityScanErrorResponse(err)
+	}
+
 	manager := plugin_manager.Manager()
-	declaration, err := manager.SavePackage(pluginUniqueIdentifier, pluginFile, &decoder.ThirdPartySignatureVerificationConfig{
+	declaration, err := manager.SavePackage(pluginUniqueIdentifier, pluginFile, size, &decoder.ThirdPartySignatureVerificationConfig{
 		Enabled:        config.ThirdPartySignatureVerificationEnabled,
 		PublicKeyPaths: config.ThirdPartySignatureVerificationPublicKeys,
+		KeyringPath:    config.ThirdPartySignatureVerificationKeyring,
 	})
 	if err != nil {
 		return exception.BadRequestError(errors.Join(err, errors.New("failed to save package"))).ToResponse()
@@ -67,9 +178,11 @@ func UploadPluginPkg(
 	}
 
 	return entities.NewSuccessResponse(map[string]any{
-		"unique_identifier": pluginUniqueIdentifier,
-		"manifest":          declaration,
-		"verification":      verification,
+		"unique_identifier":    pluginUniqueIdentifier,
+		"manifest":             declaration,
+		"verification":         verification,
+		"secret_findings":      secretFindings,
+		"vulnerability_report": vulnerabilityReport,
 	})
 }
 
@@ -80,12 +193,13 @@ func UploadPluginBundle(
 	dify_bundle_file multipart.File,
 	verify_signature bool,
 ) *entities.Response {
-	bundleFile, err := io.ReadAll(dify_bundle_file)
+	// the bundle is read right from the uploaded file, it's never loaded into memory
+	size, err := dify_bundle_file.Seek(0, io.SeekEnd)
 	if err != nil {
 		return exception.InternalServerError(err).ToResponse()
 	}
 
-	packager, err := bundle_packager.NewMemoryZipBundlePackager(bundleFile)
+	packager, err := bundle_packager.NewZipBundleReader(dify_bundle_file, size)
 	if err != nil {
 		return exception.BadRequestError(errors.Join(err, errors.New("failed to decode bundle"))).ToResponse()
 	}
@@ -99,6 +213,11 @@ func UploadPluginBundle(
 	manager := plugin_manager.Manager()
 
 	result := []map[string]any{}
+	pluginIds := []string{}
+	// packages of the bundle and their positions in result
+	packages := []resolvingPlugin{}
+	packageEntries := map[plugin_entities.PluginUniqueIdentifier]map[string]any{}
+	packageSlots := []int{}
 
 	for _, dependency := range bundle.Dependencies {
 		if dependency.Type == bundle_entities.DEPENDENCY_TYPE_GITHUB {
@@ -115,6 +234,11 @@ func UploadPluginBundle(
 			}
 		} else if dependency.Type == bundle_entities.DEPENDENCY_TYPE_MARKETPLACE {
 			if dep, ok := dependency.Value.(bundle_entities.MarketplaceDependency); ok {
+				pluginIds = append(pluginIds, fmt.Sprintf(
+					"%s/%s",
+					dep.MarketplacePattern.Organization(),
+					dep.MarketplacePattern.Plugin(),
+				))
 				result = append(result, map[string]any{
 					"type": "marketplace",
 					"value": map[string]any{
@@ -128,11 +252,14 @@ func UploadPluginBundle(
 			if dep, ok := dependency.Value.(bundle_entities.PackageDependency); ok {
 				// fetch package
 				path := dep.Path
-				if asset, err := packager.FetchAsset(path); err != nil {
+				if asset, assetSize, err := extractBundleAsset(packager, path); err != nil {
 					return exception.InternalServerError(errors.Join(errors.New("failed to fetch package from bundle"), err)).ToResponse()
 				} else {
+					defer os.Remove(asset.Name())
+					defer asset.Close()
+
 					// decode and save
-					decoderInstance, err := decoder.NewZipPluginDecoder(asset)
+					decoderInstance, err := decoder.NewZipPluginDecoderWithReaderAt(asset, assetSize, 0, nil)
 					if err != nil {
 						return exception.BadRequestError(errors.Join(errors.New("failed to create package decoder"), err)).ToResponse()
 					}
@@ -142,9 +269,20 @@ func UploadPluginBundle(
 						return exception.BadRequestError(errors.Join(errors.New("failed to get package unique identifier"), err)).ToResponse()
 					}
 
-					declaration, err := manager.SavePackage(pluginUniqueIdentifier, asset, &decoder.ThirdPartySignatureVerificationConfig{
+					secretFindings, err := scanPluginSecrets(config, pluginUniqueIdentifier, decoderInstance)
+					if err != nil {
+						return exception.BadRequestError(err).ToResponse()
+					}
+
+					vulnerabilityReport, err := scanPluginVulnerabilities(config, pluginUniqueIdentifier, decoderInstance)
+					if err != nil {
+						return vulnerabilityScanErrorResponse(err)
+					}
+
+					declaration, err := manager.SavePackage(pluginUniqueIdentifier, asset, assetSize, &decoder.ThirdPartySignatureVerificationConfig{
 						Enabled:        config.ThirdPartySignatureVerificationEnabled,
 						PublicKeyPaths: config.ThirdPartySignatureVerificationPublicKeys,
+						KeyringPath:    config.ThirdPartySignatureVerificationKeyring,
 					})
 					if err != nil {
 						return exception.InternalServerError(errors.Join(errors.New("failed to save package"), err)).ToResponse()
@@ -158,21 +296,81 @@ func UploadPluginBundle(
 						}
 					}
 
-					result = append(result, map[string]any{
+					pluginIds = append(pluginIds, pluginUniqueIdentifier.PluginID())
+					entry := map[string]any{
 						"type": "package",
 						"value": map[string]any{
-							"unique_identifier": pluginUniqueIdentifier,
-							"manifest":          declaration,
+							"unique_identifier":    pluginUniqueIdentifier,
+							"manifest":             declaration,
+							"secret_findings":      secretFindings,
+							"vulnerability_report": vulnerabilityReport,
 						},
-					})
+					}
+					packages = append(packages, resolvingPlugin{identifier: pluginUniqueIdentifier, declaration: declaration})
+					packageEntries[pluginUniqueIdentifier] = entry
+					packageSlots = append(packageSlots, len(result))
+					result = append(result, entry)
 				}
 			}
 		}
 	}
 
+	// conflicts are reported before anything is installed, packages are listed in the order to install them
+	installed, err := installedPlugins(tenant_id)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	resolver := &dependencyResolver{
+		installed: installed,
+		// marketplace and github dependencies are resolved by Dify, their versions are not known yet
+		allowMissing: true,
+		replacing:    true,
+	}
+	resolution, err := resolver.resolve(packages)
+	if err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+	for i, pluginUniqueIdentifier := range resolution.Order {
+		result[packageSlots[i]] = packageEntries[pluginUniqueIdentifier]
+	}
+
+	// the dependency graph shows which installed plugins came with the bundle
+	if err := curd.RecordPluginBundle(
+		tenant_id,
+		fmt.Sprintf("%s/%s", bundle.Author, bundle.Name),
+		bundle.Version.String(),
+		pluginIds,
+	); err != nil {
+		log.Error("failed to record bundle %s: %s", bundle.Name, err.Error())
+	}
+
 	return entities.NewSuccessResponse(result)
 }
 
+// extractBundleAsset copies a package of the bundle to a temporary file, entries of a zip are compressed
+// and can't be decoded in place, caller must close and remove the file
+func extractBundleAsset(packager *bundle_packager.ZipBundleReader, path string) (*os.File, int64, error) {
+	reader, err := packager.OpenAsset(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer reader.Close()
+
+	file, err := os.CreateTemp("", "dify-bundle-asset-*")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	size, err := io.Copy(file, reader)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, 0, err
+	}
+
+	return file, size, nil
+}
+
 func FetchPluginManifest(
 	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
 ) *entities.Response {
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/local_runtime"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// pluginLogs returns the captured output of a plugin running on current node
+func pluginLogs(plugin_unique_identifier plugin_entities.PluginUniqueIdentifier) (*local_runtime.LogBuffer, exception.PluginDaemonError) {
+	manager := plugin_manager.Manager()
+	if manager == nil {
+		return nil, exception.InternalServerError(errors.New("plugin manager is not initialized"))
+	}
+
+	runtime, err := manager.Get(plugin_unique_identifier)
+	if err != nil {
+		return nil, exception.ErrPluginNotFound()
+	}
+
+	localRuntime, ok := runtime.(*local_runtime.LocalPluginRuntime)
+	if !ok {
+		return nil, exception.BadRequestError(errors.New("output is only captured for local plugins"))
+	}
+
+	return localRuntime.Logs(), nil
+}
+
+func FetchPluginLogs(plugin_unique_identifier plugin_entities.PluginUniqueIdentifier, lines int) *entities.Response {
+	logs, err := pluginLogs(plugin_unique_identifier)
+	if err != nil {
+		return err.ToResponse()
+	}
+
+	return entities.NewSuccessResponse(logs.Lines(lines))
+}
+
+// TailPluginLogs streams the last lines of a plugin followed by every new line until the client
+// disconnects or max_timeout_seconds is reached
+func TailPluginLogs(
+	plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
+	lines int,
+	ctx *gin.Context,
+	max_timeout_seconds int,
+) {
+	logs, err := pluginLogs(plugin_unique_identifier)
+	if err != nil {
+		ctx.JSON(200, err.ToResponse())
+		return
+	}
+
+	response := stream.NewStream[local_runtime.LogLine](local_runtime.LOG_SUBSCRIBER_BUFFER_SIZE)
+	defer response.Close()
+
+	backlog, subscriber, cancel := logs.Subscribe(lines)
+	response.OnClose(cancel)
+
+	baseSSEService(
+		func() (*stream.Stream[local_runtime.LogLine], error) {
+			routine.Submit(map[string]string{
+				"module":   "service",
+				"function": "TailPluginLogs",
+			}, func() {
+				for _, line := range backlog {
+					response.WriteBlocking(line)
+				}
+				for line := range subscriber {
+					response.WriteBlocking(line)
+				}
+			})
+			return response, nil
+		},
+		ctx,
+		max_timeout_seconds,
+		nil,
+	)
+}
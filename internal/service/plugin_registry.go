@@ -0,0 +1,90 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_registry"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func registryError(err error) *entities.Response {
+	switch {
+	case errors.Is(err, plugin_registry.ErrNotFound):
+		return exception.NotFoundError(err).ToResponse()
+	case errors.Is(err, plugin_registry.ErrSignatureRequired):
+		return exception.PermissionDeniedError(err.Error()).ToResponse()
+	default:
+		return exception.BadRequestError(err).ToResponse()
+	}
+}
+
+func PublishRegistryPlugin(organization string, pkg []byte) *entities.Response {
+	plugin, err := plugin_registry.GetRegistry().Publish(organization, pkg)
+	if err != nil {
+		return registryError(err)
+	}
+	return entities.NewSuccessResponse(plugin)
+}
+
+func SearchRegistryPlugins(organization string, query string, page int, pageSize int) *entities.Response {
+	plugins, total, err := plugin_registry.GetRegistry().Search(organization, query, page, pageSize)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	return entities.NewSuccessResponse(map[string]any{
+		"list":  plugins,
+		"total": total,
+	})
+}
+
+func ListRegistryPluginVersions(organization string, name string) *entities.Response {
+	versions, err := plugin_registry.GetRegistry().Versions(organization, name)
+	if err != nil {
+		return registryError(err)
+	}
+	return entities.NewSuccessResponse(versions)
+}
+
+// FetchRegistryPluginPackage returns a published version and its package, the latest one if version is empty
+func FetchRegistryPluginPackage(organization string, name string, version string) (*models.RegistryPlugin, []byte, *entities.Response) {
+	registry := plugin_registry.GetRegistry()
+	plugin, err := registry.Get(organization, name, version)
+	if err != nil {
+		return nil, nil, registryError(err)
+	}
+
+	identifier, err := plugin_entities.NewPluginUniqueIdentifier(plugin.PluginUniqueIdentifier)
+	if err != nil {
+		return nil, nil, exception.InternalServerError(err).ToResponse()
+	}
+
+	pkg, err := registry.Load(identifier)
+	if err != nil {
+		return nil, nil, registryError(err)
+	}
+	return plugin, pkg, nil
+}
+
+// DownloadPluginPkgFromRegistry saves a package published to the registry like an uploaded one, so
+// that it can be installed by its identifier
+func DownloadPluginPkgFromRegistry(
+	config *app.Config,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	verifySignature bool,
+) *entities.Response {
+	if !plugin_registry.Enabled() {
+		return exception.BadRequestError(errors.New("plugin registry is not enabled")).ToResponse()
+	}
+
+	pkg, err := plugin_registry.GetRegistry().Load(pluginUniqueIdentifier)
+	if err != nil {
+		return registryError(err)
+	}
+
+	return savePluginPkg(config, bytes.NewReader(pkg), int64(len(pkg)), verifySignature, &pluginUniqueIdentifier)
+}
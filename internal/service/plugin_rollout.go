@@ -0,0 +1,242 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_rollout"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	PLUGIN_ROLLOUT_SOURCE = "rollout"
+)
+
+func rolloutError(err error) *entities.Response {
+	switch {
+	case errors.Is(err, plugin_rollout.ErrNotFound):
+		return exception.NotFoundError(err).ToResponse()
+	case errors.Is(err, plugin_rollout.ErrInProgress), errors.Is(err, plugin_rollout.ErrNotInProgress):
+		return exception.BadRequestError(err).ToResponse()
+	default:
+		return exception.InternalServerError(err).ToResponse()
+	}
+}
+
+type PluginRolloutRequest struct {
+	StablePluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier
+	CanaryPluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier
+	Percentage                   int
+	Tenants                      []string
+	MinInvocations               int64
+	MaxErrorRateIncrease         float64
+}
+
+// StartPluginRollout launches the canary and starts routing invocations to it once it's running,
+// the package of the canary must have been uploaded
+func StartPluginRollout(config *app.Config, request PluginRolloutRequest) *entities.Response {
+	if !plugin_rollout.Enabled() {
+		return exception.BadRequestError(errors.New("plugin rollouts are not enabled")).ToResponse()
+	}
+	if config.Platform != app.PLATFORM_LOCAL {
+		return exception.BadRequestError(errors.New("plugin rollouts are only supported on the local platform")).ToResponse()
+	}
+
+	stable, canary := request.StablePluginUniqueIdentifier, request.CanaryPluginUniqueIdentifier
+	if stable.PluginID() != canary.PluginID() {
+		return exception.BadRequestError(errors.New("the canary must be a version of the same plugin")).ToResponse()
+	}
+	if stable == canary {
+		return exception.BadRequestError(errors.New("the canary must be another version")).ToResponse()
+	}
+
+	if _, err := db.GetOne[models.Plugin](
+		db.Equal("plugin_unique_identifier", stable.String()),
+	); err == db.ErrDatabaseNotFound {
+		return exception.NotFoundError(errors.New("the stable version is not installed")).ToResponse()
+	} else if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	manager := plugin_manager.Manager()
+	if _, exists, err := manager.GetPackageSize(canary); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	} else if !exists {
+		return exception.BadRequestError(errors.New("plugin package not found, please upload it firstly")).ToResponse()
+	}
+
+	rollout := models.PluginRollout{
+		PluginID:                     stable.PluginID(),
+		StablePluginUniqueIdentifier: stable.String(),
+		CanaryPluginUniqueIdentifier: canary.String(),
+		Percentage:                   request.Percentage,
+		Tenants:                      request.Tenants,
+		MinInvocations:               request.MinInvocations,
+		MaxErrorRateIncrease:         request.MaxErrorRateIncrease,
+	}
+	if err := plugin_rollout.Create(&rollout); err != nil {
+		return rolloutError(err)
+	}
+
+	routine.Submit(map[string]string{
+		"module":   "service",
+		"function": "StartPluginRollout",
+	}, func() {
+		if err := launchPluginRolloutCanary(manager, canary); err != nil {
+			log.Error("failed to launch canary %s: %s", canary, err.Error())
+			if _, err := plugin_rollout.Rollback(rollout.ID, "failed to launch the canary: "+err.Error()); err != nil {
+				log.Error("failed to roll back rollout %s: %s", rollout.ID, err.Error())
+			}
+			return
+		}
+
+		if _, err := plugin_rollout.Activate(rollout.ID); err != nil {
+			log.Error("failed to activate rollout %s: %s", rollout.ID, err.Error())
+		}
+	})
+
+	return entities.NewSuccessResponse(rollout)
+}
+
+func launchPluginRolloutCanary(
+	manager *plugin_manager.PluginManager,
+	canary plugin_entities.PluginUniqueIdentifier,
+) error {
+	// tenants may have installed the canary already
+	if _, err := db.GetOne[models.Plugin](
+		db.Equal("plugin_unique_identifier", canary.String()),
+	); err == nil {
+		return nil
+	} else if err != db.ErrDatabaseNotFound {
+		return err
+	}
+
+	response, err := manager.InstallToLocal(canary, PLUGIN_ROLLOUT_SOURCE, map[string]any{})
+	if err != nil {
+		return err
+	}
+
+	for response.Next() {
+		message, err := response.Read()
+		if err != nil {
+			return err
+		}
+
+		switch message.Event {
+		case plugin_manager.PluginInstallEventError:
+			return errors.New(message.Data)
+		case plugin_manager.PluginInstallEventDone:
+			return nil
+		}
+	}
+
+	return errors.New("the canary did not start in time")
+}
+
+func ListPluginRollouts() *entities.Response {
+	rollouts, err := plugin_rollout.List()
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	return entities.NewSuccessResponse(rollouts)
+}
+
+func FetchPluginRollout(rolloutId string) *entities.Response {
+	rollout, err := plugin_rollout.Get(rolloutId)
+	if err != nil {
+		return rolloutError(err)
+	}
+	return entities.NewSuccessResponse(rollout)
+}
+
+func UpdatePluginRollout(rolloutId string, percentage int, tenants []string) *entities.Response {
+	rollout, err := plugin_rollout.Update(rolloutId, percentage, tenants)
+	if err != nil {
+		return rolloutError(err)
+	}
+	return entities.NewSuccessResponse(rollout)
+}
+
+func PromotePluginRollout(rolloutId string) *entities.Response {
+	rollout, err := plugin_rollout.Promote(rolloutId, "promoted manually")
+	if err != nil {
+		return rolloutError(err)
+	}
+	return entities.NewSuccessResponse(rollout)
+}
+
+func RollbackPluginRollout(rolloutId string) *entities.Response {
+	rollout, err := plugin_rollout.Rollback(rolloutId, "rolled back manually")
+	if err != nil {
+		return rolloutError(err)
+	}
+	return entities.NewSuccessResponse(rollout)
+}
+
+// OnPluginRolloutPromoted upgrades every installation of the stable version to the canary
+func OnPluginRolloutPromoted(rollout models.PluginRollout) error {
+	stable := plugin_entities.PluginUniqueIdentifier(rollout.StablePluginUniqueIdentifier)
+	canary := plugin_entities.PluginUniqueIdentifier(rollout.CanaryPluginUniqueIdentifier)
+
+	stableDeclaration, err := helper.CombinedGetPluginDeclaration(stable, plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL)
+	if err != nil {
+		return err
+	}
+	canaryDeclaration, err := helper.CombinedGetPluginDeclaration(canary, plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL)
+	if err != nil {
+		return err
+	}
+
+	installations, err := db.GetAll[models.PluginInstallation](
+		db.Equal("plugin_unique_identifier", stable.String()),
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, installation := range installations {
+		upgradeResponse, err := upgradePluginInstallation(
+			installation.TenantID,
+			installation.Source,
+			installation.Meta,
+			stable,
+			canary,
+			stableDeclaration,
+			canaryDeclaration,
+			plugin_entities.PluginRuntimeType(installation.RuntimeType),
+		)
+		if err != nil {
+			return err
+		}
+
+		if upgradeResponse.IsOriginalPluginDeleted {
+			if err := uninstallLocalRuntime(upgradeResponse.DeletedPlugin); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// OnPluginRolloutRolledBack removes the canary unless a tenant installed it
+func OnPluginRolloutRolledBack(rollout models.PluginRollout) error {
+	canary := plugin_entities.PluginUniqueIdentifier(rollout.CanaryPluginUniqueIdentifier)
+	if _, err := db.GetOne[models.Plugin](
+		db.Equal("plugin_unique_identifier", canary.String()),
+	); err == nil {
+		return nil
+	} else if err != db.ErrDatabaseNotFound {
+		return err
+	}
+
+	return plugin_manager.Manager().UninstallFromLocal(canary)
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/event_bus"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/trust"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models/curd"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+func onPluginVersionInstalled(config *app.Config) plugin_manager.InstallTaskDoneHandler {
+	return func(
+		task *models.InstallTask,
+		pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+		declaration *plugin_entities.PluginDeclaration,
+		meta map[string]any,
+	) error {
+		runtimeType := plugin_entities.PluginRuntimeType("")
+
+		switch config.Platform {
+		case app.PLATFORM_SERVERLESS:
+			runtimeType = plugin_entities.PLUGIN_RUNTIME_TYPE_SERVERLESS
+		case app.PLATFORM_LOCAL:
+			runtimeType = plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL
+		default:
+			return fmt.Errorf("unsupported platform: %s", config.Platform)
+		}
+
+		// the permissions of the installed version are consented first if the tenant never recorded
+		// a consent, so that permissions only this version requests are denied until consented
+		if err := ensureInstalledPermissionConsent(task.TenantID, pluginUniqueIdentifier.PluginID()); err != nil {
+			return err
+		}
+
+		_, err := curd.InstallPluginVersion(
+			task.TenantID,
+			pluginUniqueIdentifier,
+			runtimeType,
+			declaration,
+			task.Source,
+			meta,
+		)
+		if err != nil {
+			return err
+		}
+
+		invalidatePluginVersionInstallation(task.TenantID, pluginUniqueIdentifier.PluginID(), pluginUniqueIdentifier.Version().String())
+		event_bus.Publish(event_bus.EVENT_PLUGIN_INSTALLED, task.TenantID, pluginUniqueIdentifier.String(), map[string]any{
+			"action": task.Action,
+			"source": task.Source,
+		})
+		return nil
+	}
+}
+
+// ensureInstalledPermissionConsent records the permissions of the installation of the tenant as consented
+// if there is no consent yet, a tenant without a consent record is allowed everything otherwise
+func ensureInstalledPermissionConsent(tenantId string, pluginId string) error {
+	installation, err := db.GetOne[models.PluginInstallation](
+		db.Equal("tenant_id", tenantId),
+		db.Equal("plugin_id", pluginId),
+	)
+	if err != nil {
+		return err
+	}
+
+	declaration, err := helper.CombinedGetPluginDeclaration(
+		plugin_entities.PluginUniqueIdentifier(installation.PluginUniqueIdentifier),
+		plugin_entities.PluginRuntimeType(installation.RuntimeType),
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := curd.EnsurePluginPermissionConsent(
+		tenantId,
+		pluginId,
+		trust.Requested(declaration.Resource.Permission),
+	); err != nil {
+		return err
+	}
+	helper.InvalidatePluginPermissionConsent(tenantId, pluginId)
+	return nil
+}
+
+// InstallPluginVersion installs a version of a plugin the tenant has installed besides its installation,
+// invocations run on it once they qualify the plugin id with the version
+func InstallPluginVersion(
+	config *app.Config,
+	tenantId string,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	source string,
+	meta map[string]any,
+) *entities.Response {
+	installation, err := db.GetOne[models.PluginInstallation](
+		db.Equal("tenant_id", tenantId),
+		db.Equal("plugin_id", pluginUniqueIdentifier.PluginID()),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return exception.BadRequestError(errors.New("the plugin must be installed before installing another version")).ToResponse()
+	} else if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	installed := plugin_entities.PluginUniqueIdentifier(installation.PluginUniqueIdentifier)
+	if installed.Version() == pluginUniqueIdentifier.Version() {
+		return exception.BadRequestError(curd.ErrPluginVersionAlreadyInstalled).ToResponse()
+	}
+
+	if _, err := db.GetOne[models.PluginVersionInstallation](
+		db.Equal("tenant_id", tenantId),
+		db.Equal("plugin_id", pluginUniqueIdentifier.PluginID()),
+		db.Equal("version", pluginUniqueIdentifier.Version().String()),
+	); err == nil {
+		return exception.BadRequestError(curd.ErrPluginVersionAlreadyInstalled).ToResponse()
+	} else if err != db.ErrDatabaseNotFound {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	response, err := InstallPluginRuntimeToTenant(
+		config,
+		tenantId,
+		[]plugin_entities.PluginUniqueIdentifier{pluginUniqueIdentifier},
+		source,
+		[]map[string]any{meta},
+		models.InstallTaskActionInstallVersion,
+		"",
+	)
+	if err != nil {
+		if errors.Is(err, curd.ErrPluginVersionAlreadyInstalled) {
+			return exception.BadRequestError(err).ToResponse()
+		}
+		if errors.Is(err, ErrTenantQuotaExceeded) {
+			return exception.QuotaExceededError(err).ToResponse()
+		}
+		if errors.Is(err, ErrDependencyConflict) {
+			return exception.BadRequestError(err).ToResponse()
+		}
+		if errors.Is(err, plugin_manager.ErrInstallQueueFull) {
+			return exception.TooManyRequestsError(err.Error()).ToResponse()
+		}
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(response)
+}
+
+func ListPluginVersions(tenantId string, pluginId string) *entities.Response {
+	versions, err := db.GetAll[models.PluginVersionInstallation](
+		db.Equal("tenant_id", tenantId),
+		db.Equal("plugin_id", pluginId),
+		db.OrderBy("created_at", true),
+	)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(versions)
+}
+
+func UninstallPluginVersion(tenantId string, pluginId string, version string) *entities.Response {
+	if err := uninstallPluginVersion(tenantId, pluginId, version); err != nil {
+		return err.ToResponse()
+	}
+
+	return entities.NewSuccessResponse(true)
+}
+
+func uninstallPluginVersion(tenantId string, pluginId string, version string) exception.PluginDaemonError {
+	response, err := curd.UninstallPluginVersion(tenantId, pluginId, version)
+	if errors.Is(err, curd.ErrPluginVersionNotInstalled) {
+		return exception.NotFoundError(err)
+	} else if err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to uninstall plugin version: %s", err.Error()))
+	}
+
+	invalidatePluginVersionInstallation(tenantId, pluginId, version)
+
+	if response.IsPluginDeleted {
+		if err := uninstallLocalRuntime(response.Plugin); err != nil {
+			return exception.InternalServerError(fmt.Errorf("failed to uninstall plugin version: %s", err.Error()))
+		}
+	}
+
+	return nil
+}
+
+// uninstallPluginVersions removes the versions of a plugin the tenant installed besides its installation
+func uninstallPluginVersions(tenantId string, pluginId string) exception.PluginDaemonError {
+	versions, err := db.GetAll[models.PluginVersionInstallation](
+		db.Equal("tenant_id", tenantId),
+		db.Equal("plugin_id", pluginId),
+	)
+	if err != nil {
+		return exception.InternalServerError(err)
+	}
+
+	for _, version := range versions {
+		if err := uninstallPluginVersion(tenantId, pluginId, version.Version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func invalidatePluginVersionInstallation(tenantId string, pluginId string, version string) {
+	cacheKey := helper.PluginVersionInstallationCacheKey(pluginId, version, tenantId)
+	_, _ = cache.AutoDelete[models.PluginVersionInstallation](cacheKey)
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/trust"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models/curd"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache/helper"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPluginVersionDoesNotGainPermissionsWithoutConsent(t *testing.T) {
+	config := &app.Config{DBType: "sqlite", DBSqlitePath: filepath.Join(t.TempDir(), "version.db")}
+	config.SetDefault()
+	config.Platform = app.PLATFORM_LOCAL
+	db.Init(config)
+	t.Cleanup(db.Close)
+	require.NoError(t, cache.InitMemoryClient(0))
+	t.Cleanup(func() { cache.Close() })
+
+	declare := func(identifier plugin_entities.PluginUniqueIdentifier, permission *plugin_entities.PluginPermissionRequirement) *plugin_entities.PluginDeclaration {
+		declaration := plugin_entities.PluginDeclaration{Tool: &plugin_entities.ToolProviderDeclaration{}}
+		declaration.Resource.Permission = permission
+		require.NoError(t, db.Create(&models.PluginDeclaration{
+			PluginUniqueIdentifier: identifier.String(),
+			PluginID:               identifier.PluginID(),
+			Declaration:            declaration,
+		}))
+		return &declaration
+	}
+
+	// the tenant installed the plugin before consents were recorded
+	tenant := uuid.New().String()
+	installed := plugin_entities.PluginUniqueIdentifier("acme/versions:0.0.1@" + strings.Repeat("a", 32))
+	installedDeclaration := declare(installed, &plugin_entities.PluginPermissionRequirement{
+		Tool: &plugin_entities.PluginPermissionToolRequirement{Enabled: true},
+	})
+	_, _, err := curd.InstallPlugin(tenant, installed, plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL, installedDeclaration, "test", nil)
+	require.NoError(t, err)
+
+	version := plugin_entities.PluginUniqueIdentifier("acme/versions:0.0.2@" + strings.Repeat("b", 32))
+	versionDeclaration := declare(version, &plugin_entities.PluginPermissionRequirement{
+		Tool:    &plugin_entities.PluginPermissionToolRequirement{Enabled: true},
+		Storage: &plugin_entities.PluginPermissionStorageRequirement{Enabled: true, Size: 1024},
+	})
+
+	require.NoError(t, onPluginVersionInstalled(config)(
+		&models.InstallTask{TenantID: tenant, Source: "test"},
+		version,
+		versionDeclaration,
+		nil,
+	))
+
+	consent, err := helper.GetPluginPermissionConsent(tenant, installed.PluginID())
+	require.NoError(t, err)
+	assert.True(t, consent.Allows(trust.PERMISSION_TOOL), "permissions of the installed version stay allowed")
+	assert.False(t, consent.Allows(trust.PERMISSION_STORAGE), "permissions only the new version requests need a consent")
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/schedule"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+const (
+	// due upgrades are started within a minute of their time
+	SCHEDULED_UPGRADE_INTERVAL = time.Minute
+	SCHEDULED_UPGRADE_LOCK_KEY = "plugin:scheduled_upgrade:lock"
+)
+
+// SchedulePluginUpgrade upgrades the installation at the next activation of expression, like "0 2 * * *"
+// for the next 2 am, expressions without a CRON_TZ prefix are evaluated in the timezone of the tenant
+func SchedulePluginUpgrade(
+	tenant_id string,
+	source string,
+	meta map[string]any,
+	original_plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
+	new_plugin_unique_identifier plugin_entities.PluginUniqueIdentifier,
+	expression string,
+) *entities.Response {
+	if errResponse := checkPluginUpgrade(
+		tenant_id,
+		source,
+		original_plugin_unique_identifier,
+		new_plugin_unique_identifier,
+	); errResponse != nil {
+		return errResponse
+	}
+
+	location, err := tenantLocation(tenant_id)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	upgradeSchedule, err := schedule.ParseIn(expression, location)
+	if err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+
+	runAt := upgradeSchedule.Next(time.Now())
+	if runAt.IsZero() {
+		return exception.BadRequestError(errors.New("the schedule never activates")).ToResponse()
+	}
+
+	upgrade := &models.PluginScheduledUpgrade{
+		TenantID:                       tenant_id,
+		OriginalPluginUniqueIdentifier: original_plugin_unique_identifier.String(),
+		NewPluginUniqueIdentifier:      new_plugin_unique_identifier.String(),
+		Source:                         source,
+		Meta:                           meta,
+		Schedule:                       expression,
+		RunAt:                          runAt,
+	}
+	if err := db.Create(upgrade); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(upgrade)
+}
+
+func ListScheduledPluginUpgrades(tenant_id string) *entities.Response {
+	upgrades, err := db.GetAll[models.PluginScheduledUpgrade](
+		db.Equal("tenant_id", tenant_id),
+		db.OrderBy("run_at", false),
+	)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(upgrades)
+}
+
+func CancelScheduledPluginUpgrade(tenant_id string, id string) *entities.Response {
+	deleted, err := db.DeleteBy[models.PluginScheduledUpgrade](
+		db.Equal("tenant_id", tenant_id),
+		db.Equal("id", id),
+	)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	if deleted == 0 {
+		return exception.NotFoundError(errors.New("scheduled upgrade not found")).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(true)
+}
+
+// runScheduledPluginUpgrades starts the upgrades due at now, each one is removed before it starts so
+// that it runs once even if nodes race for it, failures are logged as the upgrade is not retried
+func runScheduledPluginUpgrades(config *app.Config, now time.Time) error {
+	upgrades, err := db.GetAll[models.PluginScheduledUpgrade](
+		db.LessThanOrEqual("run_at", now),
+		db.OrderBy("run_at", false),
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, upgrade := range upgrades {
+		deleted, err := db.DeleteBy[models.PluginScheduledUpgrade](db.Equal("id", upgrade.ID))
+		if err != nil {
+			return err
+		}
+		if deleted == 0 {
+			continue
+		}
+
+		response := UpgradePlugin(
+			config,
+			upgrade.TenantID,
+			upgrade.Source,
+			upgrade.Meta,
+			plugin_entities.PluginUniqueIdentifier(upgrade.OriginalPluginUniqueIdentifier),
+			plugin_entities.PluginUniqueIdentifier(upgrade.NewPluginUniqueIdentifier),
+		)
+		if response.Code != 0 {
+			log.Error(
+				"failed to start scheduled upgrade of %s to %s for tenant %s: %s",
+				upgrade.OriginalPluginUniqueIdentifier,
+				upgrade.NewPluginUniqueIdentifier,
+				upgrade.TenantID,
+				response.Message,
+			)
+		}
+	}
+
+	return nil
+}
+
+// StartScheduledPluginUpgrades starts due upgrades on one node of the cluster
+func StartScheduledPluginUpgrades(config *app.Config) {
+	schedule.Run("plugin_scheduled_upgrades", schedule.Every(SCHEDULED_UPGRADE_INTERVAL), func() {
+		ok, err := cache.SetNX(SCHEDULED_UPGRADE_LOCK_KEY, true, SCHEDULED_UPGRADE_INTERVAL/2)
+		if err != nil {
+			log.Error("failed to acquire scheduled upgrade lock: %s", err.Error())
+			return
+		}
+		if !ok {
+			return
+		}
+
+		if err := runScheduledPluginUpgrades(config, time.Now()); err != nil {
+			log.Error("failed to run scheduled plugin upgrades: %s", err.Error())
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models/curd"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/schedule"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduledUpgradeFollowsTenantTimezone(t *testing.T) {
+	config := &app.Config{DBType: "sqlite", DBSqlitePath: filepath.Join(t.TempDir(), "schedule.db")}
+	config.SetDefault()
+	db.Init(config)
+	t.Cleanup(db.Close)
+	require.NoError(t, cache.InitMemoryClient(0))
+	t.Cleanup(func() { cache.Close() })
+
+	install := func(tenant string) plugin_entities.PluginUniqueIdentifier {
+		identifier := plugin_entities.PluginUniqueIdentifier("acme/" + tenant[:8] + ":0.0.1@" + strings.Repeat("a", 32))
+		declaration := plugin_entities.PluginDeclaration{Tool: &plugin_entities.ToolProviderDeclaration{}}
+		require.NoError(t, db.Create(&models.PluginDeclaration{
+			PluginUniqueIdentifier: identifier.String(),
+			PluginID:               identifier.PluginID(),
+			Declaration:            declaration,
+		}))
+		_, _, err := curd.InstallPlugin(tenant, identifier, plugin_entities.PLUGIN_RUNTIME_TYPE_LOCAL, &declaration, "test", nil)
+		require.NoError(t, err)
+		return identifier
+	}
+
+	scheduleUpgrade := func(tenant string, original plugin_entities.PluginUniqueIdentifier, expression string) models.PluginScheduledUpgrade {
+		upgraded := plugin_entities.PluginUniqueIdentifier(strings.Replace(original.String(), "0.0.1", "0.0.2", 1))
+		response := SchedulePluginUpgrade(tenant, "test", nil, original, upgraded, expression)
+		require.Zero(t, response.Code, response.Message)
+		return decodeResponse[models.PluginScheduledUpgrade](t, response.Data)
+	}
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	nextAt := func(location *time.Location) time.Time {
+		s, err := schedule.ParseIn("0 2 * * *", location)
+		require.NoError(t, err)
+		return s.Next(time.Now())
+	}
+
+	// 2 am is the night of the tenant
+	tenant := uuid.New().String()
+	identifier := install(tenant)
+	response := SetTenantTimezone(tenant, "Asia/Tokyo")
+	require.Zero(t, response.Code, response.Message)
+	upgrade := scheduleUpgrade(tenant, identifier, "0 2 * * *")
+	assert.Equal(t, nextAt(tokyo).Unix(), upgrade.RunAt.Unix())
+	assert.Equal(t, 2, upgrade.RunAt.In(tokyo).Hour())
+
+	// tenants without a timezone follow the daemon, qualified expressions keep their own
+	other := uuid.New().String()
+	otherIdentifier := install(other)
+	assert.Equal(t, nextAt(schedule.DefaultLocation()).Unix(), scheduleUpgrade(other, otherIdentifier, "0 2 * * *").RunAt.Unix())
+	assert.Equal(t, nextAt(time.UTC).Unix(), scheduleUpgrade(tenant, identifier, "CRON_TZ=UTC 0 2 * * *").RunAt.Unix())
+
+	assert.NotZero(t, SetTenantTimezone(tenant, "Mars/Olympus").Code)
+	assert.NotZero(t, SchedulePluginUpgrade(tenant, "test", nil, identifier, identifier, "0 2 * * *").Code)
+
+	// cancelled upgrades never start, only the tenant can cancel its own
+	assert.NotZero(t, CancelScheduledPluginUpgrade(other, upgrade.ID).Code)
+	require.Zero(t, CancelScheduledPluginUpgrade(tenant, upgrade.ID).Code)
+	upgrades := decodeResponse[[]models.PluginScheduledUpgrade](t, ListScheduledPluginUpgrades(tenant).Data)
+	require.Len(t, upgrades, 1)
+	assert.NotEqual(t, upgrade.ID, upgrades[0].ID)
+}
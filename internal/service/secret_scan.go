@@ -0,0 +1,53 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/secret_scanner"
+)
+
+// scanPluginSecrets reports hard-coded secrets in the package, an error is returned
+// if secrets were found and the policy is block
+func scanPluginSecrets(
+	config *app.Config,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	pluginDecoder decoder.PluginDecoder,
+) ([]secret_scanner.Finding, error) {
+	policy := secret_scanner.Policy(config.SecretScanPolicy)
+	if policy == secret_scanner.POLICY_OFF {
+		return nil, nil
+	}
+
+	detectors, err := secret_scanner.Detectors(config.SecretScanDetectors)
+	if err != nil {
+		return nil, err
+	}
+
+	findings, err := secret_scanner.Scan(pluginDecoder, detectors)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(findings) == 0 {
+		return nil, nil
+	}
+
+	summary := make([]string, 0, len(findings))
+	for _, finding := range findings {
+		summary = append(summary, finding.String())
+	}
+
+	if policy == secret_scanner.POLICY_BLOCK {
+		return findings, fmt.Errorf(
+			"plugin package contains hard-coded secrets: %s", strings.Join(summary, ", "),
+		)
+	}
+
+	log.Warn("plugin %s contains hard-coded secrets: %s", pluginUniqueIdentifier, strings.Join(summary, ", "))
+	return findings, nil
+}
@@ -1,19 +1,26 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/tracing"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
-func createSession[T any](
+func CreateSession[T any](
 	r *plugin_entities.InvokePluginRequest[T],
 	access_type access_types.PluginAccessType,
 	access_action access_types.PluginAccessAction,
 	cluster_id string,
+	ctx context.Context,
 ) (*session_manager.Session, error) {
 	manager := plugin_manager.Manager()
 	if manager == nil {
@@ -43,9 +50,45 @@ func createSession[T any](
 			AppID:                  r.AppID,
 			EndpointID:             r.EndpointID,
 			Context:                r.Context,
+			TraceContext:           tracing.Inject(ctx),
+			RequestContext:         ctx,
 		},
 	)
 
 	session.BindRuntime(runtime)
 	return session, nil
 }
+
+// FetchSessionStatus returns how a session of the tenant is doing or how it ended
+func FetchSessionStatus(tenant_id string, session_id string) *entities.Response {
+	record, err := session_manager.FetchSessionRecord(session_id)
+	if err == cache.ErrNotFound || (err == nil && record.TenantID != tenant_id) {
+		return exception.NotFoundError(errors.New("session not found")).ToResponse()
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(record)
+}
+
+// CancelSession cancels a running session of the tenant, sessions running on other nodes are
+// cancelled by the node they run on
+func CancelSession(tenant_id string, session_id string) *entities.Response {
+	if session_manager.CancelSession(tenant_id, session_id, "cancelled by caller") {
+		return entities.NewSuccessResponse(true)
+	}
+
+	record, err := session_manager.FetchSessionRecord(session_id)
+	if err == cache.ErrNotFound || (err == nil && record.TenantID != tenant_id) {
+		return exception.NotFoundError(errors.New("session not found")).ToResponse()
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	if record.Status != session_manager.SESSION_STATUS_RUNNING {
+		return exception.BadRequestError(fmt.Errorf("session is already %s", record.Status)).ToResponse()
+	}
+
+	return exception.NotFoundError(errors.New("session is not running on current node")).ToResponse()
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/core/dify_invocation"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/endpoint_domains"
 	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/trust"
 	"github.com/langgenius/dify-plugin-daemon/internal/db"
 	"github.com/langgenius/dify-plugin-daemon/internal/service/install_service"
 	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
@@ -47,6 +49,14 @@ func SetupEndpoint(
 		return exception.PermissionDeniedError("permission denied, you need to enable endpoint access in plugin manifest").ToResponse()
 	}
 
+	consent, err := helper.GetPluginPermissionConsent(tenant_id, pluginUniqueIdentifier.PluginID())
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+	if !consent.Allows(trust.PERMISSION_ENDPOINT) {
+		return exception.PermissionDeniedError("permission denied, endpoint access has not been consented by the workspace").ToResponse()
+	}
+
 	if pluginDeclaration.Endpoint == nil {
 		return exception.BadRequestError(errors.New("plugin does not have an endpoint")).ToResponse()
 	}
@@ -112,6 +122,10 @@ func RemoveEndpoint(endpoint_id string, tenant_id string) *entities.Response {
 	endpointCacheKey := helper.EndpointCacheKey(endpoint.HookID)
 	_, _ = cache.AutoDelete[models.Endpoint](endpointCacheKey)
 
+	if err := endpoint_domains.RemoveEndpoint(endpoint.ID); err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to remove endpoint domains: %v", err)).ToResponse()
+	}
+
 	manager := plugin_manager.Manager()
 	if manager == nil {
 		return exception.InternalServerError(errors.New("failed to get plugin manager")).ToResponse()
@@ -258,6 +272,10 @@ func UpdateEndpoint(endpoint_id string, tenant_id string, user_id string, name s
 	endpointCacheKey := helper.EndpointCacheKey(endpoint.HookID)
 	_, _ = cache.AutoDelete[models.Endpoint](endpointCacheKey)
 
+	if err := endpoint_domains.RemoveEndpoint(endpoint.ID); err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to remove endpoint domains: %v", err)).ToResponse()
+	}
+
 	// clear credentials cache
 	if _, err := manager.BackwardsInvocation().InvokeEncrypt(&dify_invocation.InvokeEncryptRequest{
 		BaseInvokeDifyRequest: dify_invocation.BaseInvokeDifyRequest{
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/cluster"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/invocation_cache"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/invocation_stats"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/oss"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+type RuntimeStats struct {
+	plugin_manager.RuntimeStatus
+	Sessions int `json:"sessions"`
+}
+
+type NodeStats struct {
+	Runtimes []RuntimeStats `json:"runtimes"`
+	Sessions int            `json:"sessions"`
+
+	Invocations       []invocation_stats.PluginRate    `json:"invocations"`
+	InvocationCache   []invocation_cache.PluginStats   `json:"invocation_cache"`
+	RateWindowSeconds int64                            `json:"rate_window_seconds"`
+	InstallQueue      plugin_manager.InstallQueueStats `json:"install_queue"`
+	Pool              *routine.PoolStatus              `json:"pool"`
+	// Storage is nil unless STORAGE_PROBE_ENABLED is set
+	Storage *oss.ProbeStatus `json:"storage"`
+
+	ActiveRequests         int32 `json:"active_requests"`
+	ActiveDispatchRequests int32 `json:"active_dispatch_requests"`
+}
+
+// FetchNodeStats returns a snapshot of the runtimes, sessions and queues of current node
+func FetchNodeStats(activeRequests int32, activeDispatchRequests int32) *entities.Response {
+	manager := plugin_manager.Manager()
+	sessions := session_manager.CountSessions()
+
+	stats := NodeStats{
+		Invocations:            invocation_stats.FetchRates(),
+		InvocationCache:        invocation_cache.FetchStats(),
+		RateWindowSeconds:      int64(invocation_stats.WINDOW.Seconds()),
+		InstallQueue:           manager.InstallQueue().Stats(),
+		Pool:                   routine.FetchRoutineStatus(),
+		Storage:                oss.FetchProbeStatus(),
+		ActiveRequests:         activeRequests,
+		ActiveDispatchRequests: activeDispatchRequests,
+	}
+
+	for _, runtime := range manager.Runtimes() {
+		stats.Runtimes = append(stats.Runtimes, RuntimeStats{
+			RuntimeStatus: runtime,
+			Sessions:      sessions[runtime.PluginUniqueIdentifier],
+		})
+	}
+	for _, count := range sessions {
+		stats.Sessions += count
+	}
+
+	return entities.NewSuccessResponse(stats)
+}
+
+func ListClusterNodes(c *cluster.Cluster) *entities.Response {
+	nodes, err := c.Nodes()
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(nodes)
+}
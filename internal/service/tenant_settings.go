@@ -0,0 +1,53 @@
+package service
+
+import (
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models/curd"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/schedule"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+// tenantLocation returns the timezone of schedules of a tenant, the default timezone of the daemon
+// if the tenant didn't set one
+func tenantLocation(tenant_id string) (*time.Location, error) {
+	setting, err := db.GetOne[models.TenantSetting](db.Equal("tenant_id", tenant_id))
+	if err == db.ErrDatabaseNotFound || (err == nil && setting.Timezone == "") {
+		return schedule.DefaultLocation(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return time.LoadLocation(setting.Timezone)
+}
+
+func FetchTenantSettings(tenant_id string) *entities.Response {
+	setting, err := db.GetOne[models.TenantSetting](db.Equal("tenant_id", tenant_id))
+	if err == db.ErrDatabaseNotFound {
+		setting = models.TenantSetting{TenantID: tenant_id}
+	} else if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(setting)
+}
+
+// SetTenantTimezone sets the timezone of schedules of a tenant, an empty timezone falls back to the
+// default timezone of the daemon
+func SetTenantTimezone(tenant_id string, timezone string) *entities.Response {
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return exception.BadRequestError(err).ToResponse()
+		}
+	}
+
+	setting, err := curd.SetTenantTimezone(tenant_id, timezone)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(setting)
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+var ErrTenantQuotaExceeded = errors.New("tenant quota exceeded")
+
+// TenantQuota limits what a tenant installs, 0 is unlimited
+type TenantQuota struct {
+	MaxPlugins      int64 `json:"max_plugins"`
+	MaxPackageBytes int64 `json:"max_package_bytes"`
+	MaxStorageBytes int64 `json:"max_storage_bytes"`
+}
+
+type TenantUsage struct {
+	TenantID string `json:"tenant_id"`
+	// Plugins counts installations, remote debugging ones are excluded
+	Plugins int64 `json:"plugins"`
+	// PackageBytes is the size of the packages of installed plugins in the package bucket
+	PackageBytes int64 `json:"package_bytes"`
+	// StorageBytes is what plugins stored in persistence on behalf of the tenant
+	StorageBytes int64       `json:"storage_bytes"`
+	Quota        TenantQuota `json:"quota"`
+}
+
+func tenantQuota(config *app.Config) TenantQuota {
+	return TenantQuota{
+		MaxPlugins:      config.TenantMaxPlugins,
+		MaxPackageBytes: config.TenantMaxPackageBytes,
+		MaxStorageBytes: config.TenantMaxStorageBytes,
+	}
+}
+
+// check returns the first quota exceeded by usage
+func (q TenantQuota) check(usage *TenantUsage) error {
+	if q.MaxPlugins > 0 && usage.Plugins > q.MaxPlugins {
+		return fmt.Errorf("%w: %d plugins exceed the quota of %d", ErrTenantQuotaExceeded, usage.Plugins, q.MaxPlugins)
+	}
+	if q.MaxPackageBytes > 0 && usage.PackageBytes > q.MaxPackageBytes {
+		return fmt.Errorf("%w: %d bytes of packages exceed the quota of %d", ErrTenantQuotaExceeded, usage.PackageBytes, q.MaxPackageBytes)
+	}
+	if q.MaxStorageBytes > 0 && usage.StorageBytes > q.MaxStorageBytes {
+		return fmt.Errorf("%w: %d bytes of storage exceed the quota of %d", ErrTenantQuotaExceeded, usage.StorageBytes, q.MaxStorageBytes)
+	}
+	return nil
+}
+
+// packageSize is 0 for packages never uploaded, such as those of remote debugging
+func packageSize(identifier plugin_entities.PluginUniqueIdentifier) (int64, error) {
+	size, _, err := plugin_manager.Manager().GetPackageSize(identifier)
+	return size, err
+}
+
+func computeTenantUsage(tenant_id string) (*TenantUsage, []models.PluginInstallation, error) {
+	installations, err := db.GetAll[models.PluginInstallation](
+		db.Equal("tenant_id", tenant_id),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	usage := &TenantUsage{TenantID: tenant_id}
+	for _, installation := range installations {
+		if installation.RuntimeType == string(plugin_entities.PLUGIN_RUNTIME_TYPE_REMOTE) {
+			continue
+		}
+		usage.Plugins++
+
+		identifier, err := plugin_entities.NewPluginUniqueIdentifier(installation.PluginUniqueIdentifier)
+		if err != nil {
+			return nil, nil, err
+		}
+		size, err := packageSize(identifier)
+		if err != nil {
+			return nil, nil, err
+		}
+		usage.PackageBytes += size
+	}
+
+	versions, err := db.GetAll[models.PluginVersionInstallation](
+		db.Equal("tenant_id", tenant_id),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, version := range versions {
+		if version.RuntimeType == string(plugin_entities.PLUGIN_RUNTIME_TYPE_REMOTE) {
+			continue
+		}
+		size, err := packageSize(plugin_entities.PluginUniqueIdentifier(version.PluginUniqueIdentifier))
+		if err != nil {
+			return nil, nil, err
+		}
+		usage.PackageBytes += size
+	}
+
+	storages, err := db.GetAll[models.TenantStorage](
+		db.Equal("tenant_id", tenant_id),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, storage := range storages {
+		usage.StorageBytes += storage.Size
+	}
+
+	return usage, installations, nil
+}
+
+// checkTenantQuota checks the usage the tenant reaches once the plugins are installed
+func checkTenantQuota(
+	config *app.Config,
+	tenant_id string,
+	plugin_unique_identifiers []plugin_entities.PluginUniqueIdentifier,
+	original_plugin_unique_identifier string,
+) error {
+	quota := tenantQuota(config)
+	if quota == (TenantQuota{}) {
+		return nil
+	}
+
+	usage, err := projectTenantUsage(tenant_id, plugin_unique_identifiers, original_plugin_unique_identifier, nil)
+	if err != nil {
+		return err
+	}
+
+	return quota.check(usage)
+}
+
+// projectTenantUsage computes the usage the tenant reaches once the plugins are installed, an upgrade replaces
+// the package of original_plugin_unique_identifier instead of adding a plugin, sizes overrides the size of
+// packages not uploaded yet
+func projectTenantUsage(
+	tenant_id string,
+	plugin_unique_identifiers []plugin_entities.PluginUniqueIdentifier,
+	original_plugin_unique_identifier string,
+	sizes map[plugin_entities.PluginUniqueIdentifier]int64,
+) (*TenantUsage, error) {
+	usage, installations, err := computeTenantUsage(tenant_id)
+	if err != nil {
+		return nil, err
+	}
+
+	installed := map[string]string{}
+	for _, installation := range installations {
+		installed[installation.PluginID] = installation.PluginUniqueIdentifier
+	}
+
+	for _, identifier := range plugin_unique_identifiers {
+		if original_plugin_unique_identifier == "" {
+			current, ok := installed[identifier.PluginID()]
+			if current == identifier.String() {
+				continue
+			}
+			// another version of an installed plugin adds its package only
+			if !ok {
+				usage.Plugins++
+			}
+		}
+
+		size, ok := sizes[identifier]
+		if !ok {
+			size, err = packageSize(identifier)
+			if err != nil {
+				return nil, err
+			}
+		}
+		usage.PackageBytes += size
+	}
+
+	if original_plugin_unique_identifier != "" {
+		original, err := plugin_entities.NewPluginUniqueIdentifier(original_plugin_unique_identifier)
+		if err != nil {
+			return nil, err
+		}
+		size, err := packageSize(original)
+		if err != nil {
+			return nil, err
+		}
+		usage.PackageBytes -= size
+	}
+
+	return usage, nil
+}
+
+// FetchTenantUsage reports what a tenant uses against its quotas
+func FetchTenantUsage(config *app.Config, tenant_id string) *entities.Response {
+	usage, _, err := computeTenantUsage(tenant_id)
+	if err != nil {
+		return exception.InternalServerError(fmt.Errorf("failed to compute usage: %s", err.Error())).ToResponse()
+	}
+	usage.Quota = tenantQuota(config)
+
+	return entities.NewSuccessResponse(usage)
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTenantQuotaCheck(t *testing.T) {
+	usage := &TenantUsage{Plugins: 3, PackageBytes: 1024, StorageBytes: 2048}
+
+	if err := (TenantQuota{}).check(usage); err != nil {
+		t.Fatalf("expected no quota to be unlimited, got %v", err)
+	}
+	if err := (TenantQuota{MaxPlugins: 3, MaxPackageBytes: 1024, MaxStorageBytes: 2048}).check(usage); err != nil {
+		t.Fatalf("expected the usage to fit a quota it reaches, got %v", err)
+	}
+
+	for _, quota := range []TenantQuota{
+		{MaxPlugins: 2},
+		{MaxPackageBytes: 1023},
+		{MaxStorageBytes: 2047},
+	} {
+		if err := quota.check(usage); !errors.Is(err, ErrTenantQuotaExceeded) {
+			t.Fatalf("expected %+v to be exceeded, got %v", quota, err)
+		}
+	}
+}
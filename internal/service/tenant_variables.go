@@ -0,0 +1,40 @@
+package service
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/core/tenant_variables"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+func ListTenantVariables(tenant_id string) *entities.Response {
+	variables, err := tenant_variables.List(tenant_id)
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(variables)
+}
+
+func SetTenantVariable(tenant_id string, name string, value string, secret bool) *entities.Response {
+	if err := tenant_variables.ValidateName(name); err != nil {
+		return exception.BadRequestError(err).ToResponse()
+	}
+
+	variable, err := tenant_variables.Set(tenant_id, name, value, secret)
+	if err == tenant_variables.ErrEncryptionKeyNotSet {
+		return exception.BadRequestError(err).ToResponse()
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(variable)
+}
+
+func DeleteTenantVariable(tenant_id string, name string) *entities.Response {
+	if err := tenant_variables.Delete(tenant_id, name); err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(true)
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/test_invoke"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+)
+
+// resolveTestInvokePath returns the plugin directory if it's below root, symlinks are resolved so
+// they can't point outside of it
+func resolveTestInvokePath(root string, pluginPath string) (string, error) {
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Join(root, pluginPath))
+	if err != nil {
+		return "", fmt.Errorf("plugin path %s not found", pluginPath)
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.New("plugin path must be below PLUGIN_TEST_INVOKE_ROOT")
+	}
+	return dir, nil
+}
+
+// TestInvokePlugin validates an unpacked plugin and invokes it without installing it, the diagnostics
+// are streamed first and the plugin is only launched if there is no error among them
+func TestInvokePlugin(ctx *gin.Context, config *app.Config, request requests.RequestTestInvokePlugin) {
+	dir, err := resolveTestInvokePath(config.PluginTestInvokeRoot, request.PluginPath)
+	if err != nil {
+		ctx.JSON(200, exception.BadRequestError(err).ToResponse())
+		return
+	}
+
+	target := test_invoke.Target{
+		Kind:        request.Kind,
+		Provider:    request.Provider,
+		Name:        request.Name,
+		ModelType:   request.ModelType,
+		Parameters:  request.Parameters,
+		Credentials: request.Credentials,
+	}
+
+	timeout := request.Timeout
+	if timeout == 0 {
+		timeout = config.PluginMaxExecutionTimeout
+	}
+
+	baseSSEService(func() (*stream.Stream[test_invoke.Event], error) {
+		declaration, diagnostics := test_invoke.Validate(dir, target)
+
+		events := stream.NewStream[test_invoke.Event](128)
+		events.Write(test_invoke.Event{Type: test_invoke.EVENT_TYPE_DIAGNOSTICS, Diagnostics: diagnostics})
+		if test_invoke.HasErrors(diagnostics) {
+			events.Close()
+			return events, nil
+		}
+
+		output, err := test_invoke.Invoke(dir, declaration, target)
+		if err != nil {
+			return nil, err
+		}
+		events.OnClose(output.Close)
+
+		routine.Submit(map[string]string{
+			"module":   "service",
+			"function": "TestInvokePlugin",
+		}, func() {
+			defer events.Close()
+			for output.Next() {
+				chunk, err := output.Read()
+				if err != nil {
+					events.WriteError(err)
+					return
+				}
+				events.WriteBlocking(test_invoke.Event{Type: test_invoke.EVENT_TYPE_OUTPUT, Output: chunk})
+			}
+		})
+		return events, nil
+	}, ctx, timeout, nil)
+}
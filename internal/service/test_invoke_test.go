@@ -0,0 +1,42 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveTestInvokePath(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	for _, dir := range []string{filepath.Join(root, "plugins", "weather"), filepath.Join(outside, "secret")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(root, "plugins", "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "plugins", "weather"), filepath.Join(root, "current")); err != nil {
+		t.Fatal(err)
+	}
+
+	resolvedRoot, _ := filepath.EvalSymlinks(root)
+	for _, pluginPath := range []string{"plugins/weather", "plugins/../plugins/weather/", "current", "/plugins/weather"} {
+		dir, err := resolveTestInvokePath(root, pluginPath)
+		if err != nil {
+			t.Errorf("%s should be resolved, got %v", pluginPath, err)
+			continue
+		}
+		if dir != filepath.Join(resolvedRoot, "plugins", "weather") {
+			t.Errorf("%s resolved to %s", pluginPath, dir)
+		}
+	}
+
+	relOutside, _ := filepath.Rel(root, filepath.Join(outside, "secret"))
+	for _, pluginPath := range []string{"..", "../", relOutside, "plugins/escape", "plugins/missing"} {
+		if dir, err := resolveTestInvokePath(root, pluginPath); err == nil {
+			t.Errorf("%s should be rejected, got %s", pluginPath, dir)
+		}
+	}
+}
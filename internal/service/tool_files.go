@@ -0,0 +1,34 @@
+package service
+
+import (
+	"errors"
+	"io"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/tool_files"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+type StagedToolFile struct {
+	*tool_files.File
+	URL string `json:"url"`
+}
+
+// UploadToolFile stages a file for a file parameter of a tool, the id it returns is passed as the
+// value of the parameter
+func UploadToolFile(tenant_id string, filename string, mimeType string, reader io.Reader) *entities.Response {
+	if !tool_files.Enabled() {
+		return exception.BadRequestError(errors.New("file parameters are not brokered")).ToResponse()
+	}
+
+	broker := tool_files.GetBroker()
+	file, err := broker.Stage(tenant_id, filename, mimeType, reader)
+	if errors.Is(err, tool_files.ErrFileTooLarge) {
+		return exception.BadRequestError(err).ToResponse()
+	}
+	if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(StagedToolFile{File: file, URL: broker.URL(file)})
+}
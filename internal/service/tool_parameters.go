@@ -0,0 +1,63 @@
+package service
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_daemon/access_types"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/session_manager"
+	"github.com/langgenius/dify-plugin-daemon/internal/core/tool_files"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+)
+
+var toolParameterCoercion = false
+
+func SetToolParameterCoercion(enabled bool) {
+	toolParameterCoercion = enabled
+}
+
+// coerceToolParameters replaces the parameters of a tool invocation with the coerced ones and the ids
+// of staged files with file objects, requests of other actions and tools that aren't declared are
+// left to the plugin
+func coerceToolParameters[T any](
+	session *session_manager.Session,
+	request *plugin_entities.InvokePluginRequest[T],
+) error {
+	if !toolParameterCoercion && !tool_files.Enabled() {
+		return nil
+	}
+	if session.Action != access_types.PLUGIN_ACCESS_ACTION_INVOKE_TOOL {
+		return nil
+	}
+
+	toolRequest, ok := any(&request.Data).(*requests.RequestInvokeTool)
+	if !ok || session.Declaration == nil || session.Declaration.Tool == nil {
+		return nil
+	}
+	if session.Declaration.Tool.Identity.Name != toolRequest.Provider {
+		return nil
+	}
+
+	for i := range session.Declaration.Tool.Tools {
+		tool := &session.Declaration.Tool.Tools[i]
+		if tool.Identity.Name != toolRequest.Tool {
+			continue
+		}
+		parameters := toolRequest.ToolParameters
+		if toolParameterCoercion {
+			coerced, err := tool.CoerceParameters(parameters)
+			if err != nil {
+				return err
+			}
+			parameters = coerced
+		}
+		if tool_files.Enabled() {
+			resolved, err := tool_files.GetBroker().ResolveParameters(session.TenantID, tool.Parameters, parameters)
+			if err != nil {
+				return err
+			}
+			parameters = resolved
+		}
+		toolRequest.ToolParameters = parameters
+		return nil
+	}
+	return nil
+}
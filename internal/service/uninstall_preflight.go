@@ -0,0 +1,143 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/invocation_stats"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+const (
+	// UNINSTALL_RECENT_INVOCATION_WINDOW is how long after its last invocation a plugin counts as in use
+	UNINSTALL_RECENT_INVOCATION_WINDOW = 24 * time.Hour
+)
+
+type UninstallPreflightEndpoint struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type UninstallPreflight struct {
+	PluginID string `json:"plugin_id"`
+	// Safe is false if anything below is set, uninstalling then requires force
+	Safe             bool                         `json:"safe"`
+	EnabledEndpoints []UninstallPreflightEndpoint `json:"enabled_endpoints"`
+	LastInvokedAt    *time.Time                   `json:"last_invoked_at"`
+	Bundles          []string                     `json:"bundles"`
+	DependentPlugins []string                     `json:"dependent_plugins"`
+	Warnings         []string                     `json:"warnings"`
+}
+
+func uninstallPreflight(tenant_id string, plugin_installation_id string) (*UninstallPreflight, exception.PluginDaemonError) {
+	installation, err := db.GetOne[models.PluginInstallation](
+		db.Equal("tenant_id", tenant_id),
+		db.Equal("id", plugin_installation_id),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return nil, exception.ErrPluginNotFound()
+	}
+	if err != nil {
+		return nil, exception.InternalServerError(err)
+	}
+
+	preflight := &UninstallPreflight{
+		PluginID:         installation.PluginID,
+		EnabledEndpoints: []UninstallPreflightEndpoint{},
+		Bundles:          []string{},
+		DependentPlugins: []string{},
+		Warnings:         []string{},
+	}
+
+	endpoints, err := db.GetAll[models.Endpoint](
+		db.Equal("tenant_id", tenant_id),
+		db.Equal("plugin_id", installation.PluginID),
+		db.Equal("enabled", true),
+	)
+	if err != nil {
+		return nil, exception.InternalServerError(err)
+	}
+	for _, endpoint := range endpoints {
+		preflight.EnabledEndpoints = append(preflight.EnabledEndpoints, UninstallPreflightEndpoint{
+			ID:   endpoint.ID,
+			Name: endpoint.Name,
+		})
+	}
+
+	// the last invocation is best effort, redis being unavailable must not block uninstalling
+	lastInvokedAt, err := invocation_stats.LastInvokedAt(tenant_id, installation.PluginID)
+	if err != nil {
+		log.Warn("failed to fetch last invocation of plugin %s: %s", installation.PluginID, err.Error())
+	}
+	preflight.LastInvokedAt = lastInvokedAt
+
+	graph, err := buildDependencyGraph(tenant_id)
+	if err != nil {
+		return nil, exception.InternalServerError(err)
+	}
+	for _, node := range graph.dependents(installation.PluginID) {
+		switch node.Type {
+		case DEPENDENCY_NODE_BUNDLE:
+			preflight.Bundles = append(preflight.Bundles, node.Name)
+		case DEPENDENCY_NODE_PLUGIN:
+			preflight.DependentPlugins = append(preflight.DependentPlugins, node.PluginID)
+		}
+	}
+
+	preflight.evaluate(time.Now())
+	return preflight, nil
+}
+
+// evaluate fills the warnings and decides whether the plugin can be removed without force
+func (p *UninstallPreflight) evaluate(now time.Time) {
+	p.Warnings = []string{}
+	if len(p.EnabledEndpoints) > 0 {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("%d endpoints are enabled", len(p.EnabledEndpoints)))
+	}
+	if p.LastInvokedAt != nil && now.Sub(*p.LastInvokedAt) < UNINSTALL_RECENT_INVOCATION_WINDOW {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("invoked at %s", p.LastInvokedAt.Format(time.RFC3339)))
+	}
+	if len(p.Bundles) > 0 {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("part of bundles %s", strings.Join(p.Bundles, ", ")))
+	}
+	if len(p.DependentPlugins) > 0 {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("used by plugins %s", strings.Join(p.DependentPlugins, ", ")))
+	}
+	p.Safe = len(p.Warnings) == 0
+}
+
+// UninstallPluginPreflight reports what relies on a plugin before it's uninstalled
+func UninstallPluginPreflight(tenant_id string, plugin_installation_id string) *entities.Response {
+	preflight, err := uninstallPreflight(tenant_id, plugin_installation_id)
+	if err != nil {
+		return err.ToResponse()
+	}
+
+	return entities.NewSuccessResponse(preflight)
+}
+
+// checkUninstallPreflight refuses to uninstall a plugin which is still referenced
+func checkUninstallPreflight(tenant_id string, plugin_installation_id string) exception.PluginDaemonError {
+	preflight, err := uninstallPreflight(tenant_id, plugin_installation_id)
+	if err != nil {
+		return err
+	}
+	if preflight.Safe {
+		return nil
+	}
+
+	return exception.ErrPluginReferenced(
+		fmt.Sprintf(
+			"plugin %s is still in use: %s, uninstall with force to remove it anyway",
+			preflight.PluginID,
+			strings.Join(preflight.Warnings, "; "),
+		),
+		parser.StructToMap(preflight),
+	)
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUninstallPreflightEvaluate(t *testing.T) {
+	now := time.Now()
+
+	preflight := &UninstallPreflight{PluginID: "langgenius/openai"}
+	preflight.evaluate(now)
+	assert.True(t, preflight.Safe)
+	assert.Empty(t, preflight.Warnings)
+
+	// invocations out of the window do not count
+	lastInvokedAt := now.Add(-2 * UNINSTALL_RECENT_INVOCATION_WINDOW)
+	preflight.LastInvokedAt = &lastInvokedAt
+	preflight.evaluate(now)
+	assert.True(t, preflight.Safe)
+
+	lastInvokedAt = now.Add(-time.Hour)
+	preflight.EnabledEndpoints = []UninstallPreflightEndpoint{{ID: "1", Name: "webhook"}}
+	preflight.Bundles = []string{"langgenius/starter"}
+	preflight.DependentPlugins = []string{"langgenius/agent"}
+	preflight.evaluate(now)
+	assert.False(t, preflight.Safe)
+	assert.Len(t, preflight.Warnings, 4)
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/plugin_manager/vulnerability_scanner"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/app"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models/curd"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+// scanPluginVulnerabilities checks the dependencies of the package for known vulnerabilities and records
+// the report, an error is returned if the policy is block and an advisory reaches the block severity
+func scanPluginVulnerabilities(
+	config *app.Config,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	pluginDecoder decoder.PluginDecoder,
+) (*models.PluginSecurityReport, error) {
+	report, err := inspectPluginVulnerabilities(config, pluginUniqueIdentifier, pluginDecoder)
+	if report != nil {
+		if err := curd.SavePluginSecurityReport(report); err != nil {
+			log.Error("failed to save security report of plugin %s: %s", pluginUniqueIdentifier, err.Error())
+		}
+	}
+	return report, err
+}
+
+// inspectPluginVulnerabilities is scanPluginVulnerabilities without recording the report
+func inspectPluginVulnerabilities(
+	config *app.Config,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	pluginDecoder decoder.PluginDecoder,
+) (*models.PluginSecurityReport, error) {
+	policy := vulnerability_scanner.Policy(config.VulnerabilityScanPolicy)
+	if policy == "" || policy == vulnerability_scanner.POLICY_OFF {
+		return nil, nil
+	}
+
+	timeout := time.Duration(config.VulnerabilityScanTimeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client := vulnerability_scanner.NewOSVClient(config.VulnerabilityScanOSVURL, timeout)
+	report, err := vulnerability_scanner.Scan(ctx, client, pluginDecoder)
+	if err != nil {
+		if !config.VulnerabilityScanFailOpen {
+			return nil, errors.Join(err, errors.New("failed to scan plugin dependencies for vulnerabilities"))
+		}
+		log.Warn("failed to scan dependencies of plugin %s, accepting it anyway: %s", pluginUniqueIdentifier, err.Error())
+		report = &models.PluginSecurityReport{
+			Scanner:         vulnerability_scanner.SCANNER_OSV,
+			Unpinned:        []string{},
+			Vulnerabilities: []models.PluginVulnerability{},
+			Error:           err.Error(),
+			ScannedAt:       time.Now(),
+		}
+	}
+	report.PluginUniqueIdentifier = pluginUniqueIdentifier.String()
+
+	blocking := vulnerability_scanner.Blocking(report.Vulnerabilities, config.VulnerabilityScanBlockSeverity)
+	summary := make([]string, 0, len(blocking))
+	for _, vulnerability := range blocking {
+		summary = append(summary, fmt.Sprintf(
+			"%s (%s) in %s@%s", vulnerability.ID, vulnerability.Severity, vulnerability.Package, vulnerability.Version,
+		))
+	}
+
+	report.Blocked = policy == vulnerability_scanner.POLICY_BLOCK && len(blocking) > 0
+
+	if report.Blocked {
+		return report, fmt.Errorf(
+			"plugin dependencies have known vulnerabilities: %s", strings.Join(summary, ", "),
+		)
+	}
+	if len(blocking) > 0 {
+		log.Warn("plugin %s has dependencies with known vulnerabilities: %s", pluginUniqueIdentifier, strings.Join(summary, ", "))
+	}
+
+	return report, nil
+}
+
+// vulnerabilityScanErrorResponse refuses the package if it's blocked by the policy, a scanner which can't
+// be reached is an outage of the daemon rather than a bad package and the install may be retried
+func vulnerabilityScanErrorResponse(err error) *entities.Response {
+	if errors.Is(err, vulnerability_scanner.ErrUnavailable) {
+		return exception.UnavailableError(err.Error()).ToResponse()
+	}
+	return exception.BadRequestError(err).ToResponse()
+}
+
+func FetchPluginSecurityReport(
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+) *entities.Response {
+	report, err := db.GetOne[models.PluginSecurityReport](
+		db.Equal("plugin_unique_identifier", pluginUniqueIdentifier.String()),
+	)
+	if err == db.ErrDatabaseNotFound {
+		return exception.NotFoundError(errors.New("the package has not been scanned")).ToResponse()
+	} else if err != nil {
+		return exception.InternalServerError(err).ToResponse()
+	}
+
+	return entities.NewSuccessResponse(report)
+}
@@ -2,8 +2,11 @@ package app
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/network"
 )
 
 type Config struct {
@@ -11,14 +14,79 @@ type Config struct {
 	ServerPort uint16 `envconfig:"SERVER_PORT" validate:"required"`
 	ServerKey  string `envconfig:"SERVER_KEY" validate:"required"`
 
+	// tls of the http and grpc servers, clients must present a certificate signed by SERVER_TLS_CLIENT_CA_FILE
+	// if it's set, with SERVER_TLS_CLIENT_AUTH optional connections without certificate are accepted but
+	// requests to the apis are still rejected, files are read again once they change
+	ServerTLSCertFile     string `envconfig:"SERVER_TLS_CERT_FILE"`
+	ServerTLSKeyFile      string `envconfig:"SERVER_TLS_KEY_FILE"`
+	ServerTLSClientCAFile string `envconfig:"SERVER_TLS_CLIENT_CA_FILE"`
+	ServerTLSClientAuth   string `envconfig:"SERVER_TLS_CLIENT_AUTH" validate:"omitempty,oneof=require optional"`
+	// verified client certificates stand in for SERVER_KEY on requests without key
+	ServerTLSClientCertAuthEnabled bool `envconfig:"SERVER_TLS_CLIENT_CERT_AUTH_ENABLED"`
+	// nodes verify each other against the peer ca and server name, or else only accept their own certificate,
+	// they present the peer certificate to each other, the server certificate if it's not set
+	ServerTLSPeerCAFile     string `envconfig:"SERVER_TLS_PEER_CA_FILE"`
+	ServerTLSPeerServerName string `envconfig:"SERVER_TLS_PEER_SERVER_NAME"`
+	ServerTLSPeerCertFile   string `envconfig:"SERVER_TLS_PEER_CERT_FILE"`
+	ServerTLSPeerKeyFile    string `envconfig:"SERVER_TLS_PEER_KEY_FILE"`
+
+	// ips or cidrs of load balancers trusted to set X-Forwarded-For and to send PROXY protocol headers,
+	// forwarded headers of other peers are ignored
+	TrustedProxies []string `envconfig:"TRUSTED_PROXIES"`
+	// read a PROXY protocol v1 or v2 header from connections of TRUSTED_PROXIES to the http and grpc servers
+	ServerProxyProtocolEnabled bool `envconfig:"SERVER_PROXY_PROTOCOL_ENABLED"`
+	// ips or cidrs of clients allowed to reach the http and grpc servers except the health check, all if empty
+	ServerIPAllowlist []string `envconfig:"SERVER_IP_ALLOWLIST"`
+
+	// slow clients can't hold connections of the http server open for longer than these seconds
+	ServerReadHeaderTimeout int `envconfig:"SERVER_READ_HEADER_TIMEOUT" default:"10" validate:"min=0"`
+	ServerIdleTimeout       int `envconfig:"SERVER_IDLE_TIMEOUT" default:"120" validate:"min=0"`
+
+	// limits of the invoke (/plugin/{tenant_id}/dispatch), install (package uploads) and endpoint (/e)
+	// routes, body sizes are in bytes and timeouts in seconds, 0 disables a limit, streams are closed
+	// once nothing was sent for the stream idle timeout
+	ServerInvokeMaxBodySize         int64 `envconfig:"SERVER_INVOKE_MAX_BODY_SIZE" default:"33554432" validate:"min=0"`
+	ServerInvokeReadTimeout         int   `envconfig:"SERVER_INVOKE_READ_TIMEOUT" default:"60" validate:"min=0"`
+	ServerInvokeWriteTimeout        int   `envconfig:"SERVER_INVOKE_WRITE_TIMEOUT" validate:"min=0"`
+	ServerInvokeStreamIdleTimeout   int   `envconfig:"SERVER_INVOKE_STREAM_IDLE_TIMEOUT" validate:"min=0"`
+	ServerInstallMaxBodySize        int64 `envconfig:"SERVER_INSTALL_MAX_BODY_SIZE" validate:"min=0"`
+	ServerInstallReadTimeout        int   `envconfig:"SERVER_INSTALL_READ_TIMEOUT" default:"600" validate:"min=0"`
+	ServerInstallWriteTimeout       int   `envconfig:"SERVER_INSTALL_WRITE_TIMEOUT" validate:"min=0"`
+	ServerEndpointMaxBodySize       int64 `envconfig:"SERVER_ENDPOINT_MAX_BODY_SIZE" default:"33554432" validate:"min=0"`
+	ServerEndpointReadTimeout       int   `envconfig:"SERVER_ENDPOINT_READ_TIMEOUT" default:"60" validate:"min=0"`
+	ServerEndpointWriteTimeout      int   `envconfig:"SERVER_ENDPOINT_WRITE_TIMEOUT" validate:"min=0"`
+	ServerEndpointStreamIdleTimeout int   `envconfig:"SERVER_ENDPOINT_STREAM_IDLE_TIMEOUT" validate:"min=0"`
+
+	// seconds to wait for active sessions and installs on SIGTERM before plugins are stopped
+	ShutdownDrainTimeout int `envconfig:"SHUTDOWN_DRAIN_TIMEOUT" default:"30" validate:"min=0"`
+
+	// grpc dispatch server, serves the same api as /plugin/{tenant_id}/dispatch
+	GrpcEnabled        bool   `envconfig:"GRPC_ENABLED"`
+	GrpcPort           uint16 `envconfig:"GRPC_PORT" default:"5004"`
+	GrpcMaxRecvMsgSize int    `envconfig:"GRPC_MAX_RECV_MSG_SIZE" default:"16777216"`
+
 	// admin api enable
 	AdminApiEnabled bool   `envconfig:"ADMIN_API_ENABLED" default:"false"`
 	AdminApiKey     string `envconfig:"ADMIN_API_KEY"`
 
+	// secret of HS256 tokens accepted as `Authorization: Bearer`, they carry the roles and the tenant
+	// of the caller in the claims roles and tenant_id, tokens are rejected if it's empty
+	ApiJwtSecret string `envconfig:"API_JWT_SECRET"`
+
+	// serve the admin dashboard at /admin/dashboard/, it's available with the admin api only and signs
+	// in with the admin api key
+	AdminDashboardEnabled bool `envconfig:"ADMIN_DASHBOARD_ENABLED"`
+
 	// dify inner api
 	DifyInnerApiURL string `envconfig:"DIFY_INNER_API_URL" validate:"required"`
 	DifyInnerApiKey string `envconfig:"DIFY_INNER_API_KEY" validate:"required"`
 
+	// mutual tls towards the dify inner api, the daemon presents the certificate and verifies dify against
+	// DIFY_INNER_API_TLS_CA_FILE, files are read again once they change
+	DifyInnerApiTLSCertFile string `envconfig:"DIFY_INNER_API_TLS_CERT_FILE"`
+	DifyInnerApiTLSKeyFile  string `envconfig:"DIFY_INNER_API_TLS_KEY_FILE"`
+	DifyInnerApiTLSCAFile   string `envconfig:"DIFY_INNER_API_TLS_CA_FILE"`
+
 	// storage config
 	// https://github.com/langgenius/dify-cloud-kit/blob/main/oss/factory/factory.go
 	PluginStorageType      string `envconfig:"PLUGIN_STORAGE_TYPE" validate:"required"`
@@ -33,6 +101,24 @@ type Config struct {
 	AWSSecretKey       string `envconfig:"AWS_SECRET_KEY"`
 	AWSRegion          string `envconfig:"AWS_REGION"`
 
+	// s3-compatible providers, e.g. Cloudflare R2, Backblaze B2 and Ceph RGW
+	S3DisableChecksum      bool  `envconfig:"S3_DISABLE_CHECKSUM"`
+	S3MultipartThreshold   int64 `envconfig:"S3_MULTIPART_THRESHOLD"`
+	S3MultipartPartSize    int64 `envconfig:"S3_MULTIPART_PART_SIZE"`
+	S3MultipartConcurrency int   `envconfig:"S3_MULTIPART_CONCURRENCY"`
+
+	// network filesystems for environments without object storage
+	NFSMountPath              string `envconfig:"NFS_MOUNT_PATH"`
+	SFTPHost                  string `envconfig:"SFTP_HOST"`
+	SFTPPort                  int    `envconfig:"SFTP_PORT"`
+	SFTPUsername              string `envconfig:"SFTP_USERNAME"`
+	SFTPPassword              string `envconfig:"SFTP_PASSWORD"`
+	SFTPPrivateKeyPath        string `envconfig:"SFTP_PRIVATE_KEY_PATH"`
+	SFTPPrivateKeyPassphrase  string `envconfig:"SFTP_PRIVATE_KEY_PASSPHRASE"`
+	SFTPKnownHostsPath        string `envconfig:"SFTP_KNOWN_HOSTS_PATH"`
+	SFTPInsecureIgnoreHostKey bool   `envconfig:"SFTP_INSECURE_IGNORE_HOST_KEY"`
+	SFTPRoot                  string `envconfig:"SFTP_ROOT"`
+
 	// tencent cos
 	TencentCOSSecretKey string `envconfig:"TENCENT_COS_SECRET_KEY"`
 	TencentCOSSecretId  string `envconfig:"TENCENT_COS_SECRET_ID"`
@@ -74,9 +160,27 @@ type Config struct {
 	PluginRemoteInstallingMaxConn             int    `envconfig:"PLUGIN_REMOTE_INSTALLING_MAX_CONN"`
 	PluginRemoteInstallingMaxSingleTenantConn int    `envconfig:"PLUGIN_REMOTE_INSTALLING_MAX_SINGLE_TENANT_CONN"`
 	PluginRemoteInstallServerEventLoopNums    int    `envconfig:"PLUGIN_REMOTE_INSTALL_SERVER_EVENT_LOOP_NUMS"`
+	// debugging over grpc for plugins behind proxies which only pass http/2, disabled if the port is 0
+	PluginRemoteInstallingGrpcPort        uint16 `envconfig:"PLUGIN_REMOTE_INSTALLING_GRPC_PORT"`
+	PluginRemoteInstallingGrpcTLSCertFile string `envconfig:"PLUGIN_REMOTE_INSTALLING_GRPC_TLS_CERT_FILE"`
+	PluginRemoteInstallingGrpcTLSKeyFile  string `envconfig:"PLUGIN_REMOTE_INSTALLING_GRPC_TLS_KEY_FILE"`
+
+	// the tcp and grpc debugging listeners read PROXY headers from TRUSTED_PROXIES and close connections
+	// of ips outside the allow-list, all ips are allowed if it's empty
+	PluginRemoteInstallingProxyProtocolEnabled bool     `envconfig:"PLUGIN_REMOTE_INSTALLING_PROXY_PROTOCOL_ENABLED"`
+	PluginRemoteInstallingIPAllowlist          []string `envconfig:"PLUGIN_REMOTE_INSTALLING_IP_ALLOWLIST"`
+	// record the event stream of every debugging session into this directory, disabled if empty
+	PluginRemoteDebuggingRecordDir string `envconfig:"PLUGIN_REMOTE_DEBUGGING_RECORD_DIR"`
+
+	// unpacked plugins below this directory may be invoked through /plugin/test-invoke, disabled if empty
+	PluginTestInvokeRoot string `envconfig:"PLUGIN_TEST_INVOKE_ROOT"`
 
 	// plugin endpoint
 	PluginEndpointEnabled *bool `envconfig:"PLUGIN_ENDPOINT_ENABLED"`
+	// custom domains tenants may expose endpoints on, like hooks.example.com or *.hooks.example.com
+	EndpointCustomDomains []string `envconfig:"ENDPOINT_CUSTOM_DOMAINS"`
+	EndpointTLSPort       uint16   `envconfig:"ENDPOINT_TLS_PORT"`
+	EndpointTLSCertDir    string   `envconfig:"ENDPOINT_TLS_CERT_DIR"`
 
 	// storage
 	PluginWorkingPath      string `envconfig:"PLUGIN_WORKING_PATH"` // where the plugin finally running
@@ -91,12 +195,21 @@ type Config struct {
 	// local launching max concurrent
 	PluginLocalLaunchingConcurrent int `envconfig:"PLUGIN_LOCAL_LAUNCHING_CONCURRENT" validate:"required"`
 
+	// install task queue, failed installations are retried before the task is marked as failed
+	PluginInstallConcurrency int `envconfig:"PLUGIN_INSTALL_CONCURRENCY"`
+	PluginInstallMaxRetries  int `envconfig:"PLUGIN_INSTALL_MAX_RETRIES"`
+	PluginInstallQueueSize   int `envconfig:"PLUGIN_INSTALL_QUEUE_SIZE"`
+
 	// platform like local or aws lambda
 	Platform PlatformType `envconfig:"PLATFORM" validate:"required"`
 
 	// routine pool
 	RoutinePoolSize int `envconfig:"ROUTINE_POOL_SIZE" validate:"required"`
 
+	// cache, redis by default, memory keeps everything in process and only works for a single node
+	CacheType          string `envconfig:"CACHE_TYPE" default:"redis" validate:"oneof=redis memory"`
+	CacheMemoryMaxKeys int    `envconfig:"CACHE_MEMORY_MAX_KEYS"`
+
 	// redis
 	RedisHost   string `envconfig:"REDIS_HOST"`
 	RedisPort   uint16 `envconfig:"REDIS_PORT"`
@@ -113,15 +226,24 @@ type Config struct {
 	RedisSentinelPassword      string  `envconfig:"REDIS_SENTINEL_PASSWORD"`
 	RedisSentinelSocketTimeout float64 `envconfig:"REDIS_SENTINEL_SOCKET_TIMEOUT"`
 
+	// redis cluster, REDIS_CLUSTERS are comma separated host:port of the seed nodes, REDIS_DB is ignored
+	RedisUseClusters      bool   `envconfig:"REDIS_USE_CLUSTERS"`
+	RedisClusters         string `envconfig:"REDIS_CLUSTERS"`
+	RedisClustersPassword string `envconfig:"REDIS_CLUSTERS_PASSWORD"`
+
 	// database
-	DBType            string `envconfig:"DB_TYPE" default:"postgresql"`
-	DBUsername        string `envconfig:"DB_USERNAME" validate:"required"`
-	DBPassword        string `envconfig:"DB_PASSWORD" validate:"required"`
-	DBHost            string `envconfig:"DB_HOST" validate:"required"`
-	DBPort            uint16 `envconfig:"DB_PORT" validate:"required"`
-	DBDatabase        string `envconfig:"DB_DATABASE" validate:"required"`
-	DBDefaultDatabase string `envconfig:"DB_DEFAULT_DATABASE" validate:"required"`
-	DBSslMode         string `envconfig:"DB_SSL_MODE" validate:"required,oneof=disable require"`
+	DBType            string `envconfig:"DB_TYPE" default:"postgresql" validate:"oneof=postgresql mysql mariadb sqlite"`
+	DBUsername        string `envconfig:"DB_USERNAME" validate:"required_unless=DBType sqlite"`
+	DBPassword        string `envconfig:"DB_PASSWORD" validate:"required_unless=DBType sqlite"`
+	DBHost            string `envconfig:"DB_HOST" validate:"required_unless=DBType sqlite"`
+	DBPort            uint16 `envconfig:"DB_PORT" validate:"required_unless=DBType sqlite"`
+	DBDatabase        string `envconfig:"DB_DATABASE" validate:"required_unless=DBType sqlite"`
+	DBDefaultDatabase string `envconfig:"DB_DEFAULT_DATABASE" validate:"required_unless=DBType sqlite"`
+	DBSslMode         string `envconfig:"DB_SSL_MODE" validate:"required_unless=DBType sqlite,omitempty,oneof=disable require"`
+
+	// sqlite, used when DB_TYPE is sqlite
+	DBSqlitePath        string `envconfig:"DB_SQLITE_PATH"`
+	DBSqliteBusyTimeout int    `envconfig:"DB_SQLITE_BUSY_TIMEOUT"`
 
 	// database connection pool settings
 	DBMaxIdleConns    int    `envconfig:"DB_MAX_IDLE_CONNS" default:"10"`
@@ -133,6 +255,15 @@ type Config struct {
 	// persistence storage
 	PersistenceStoragePath    string `envconfig:"PERSISTENCE_STORAGE_PATH"`
 	PersistenceStorageMaxSize int64  `envconfig:"PERSISTENCE_STORAGE_MAX_SIZE"`
+	// bytes of the value of each key, 0 is unlimited
+	PersistenceMaxKeySize int64 `envconfig:"PERSISTENCE_MAX_KEY_SIZE" default:"0" validate:"min=0"`
+	// cron expression of the sweep of expired keys, every minute if empty
+	PersistenceExpirySweepSchedule string `envconfig:"PERSISTENCE_EXPIRY_SWEEP_SCHEDULE"`
+
+	// quotas of each tenant checked when plugins are installed, 0 is unlimited
+	TenantMaxPlugins      int64 `envconfig:"TENANT_MAX_PLUGINS" default:"0" validate:"min=0"`
+	TenantMaxPackageBytes int64 `envconfig:"TENANT_MAX_PACKAGE_BYTES" default:"0" validate:"min=0"`
+	TenantMaxStorageBytes int64 `envconfig:"TENANT_MAX_STORAGE_BYTES" default:"0" validate:"min=0"`
 
 	// force verifying signature for all plugins, not allowing install plugin not signed
 	ForceVerifyingSignature *bool `envconfig:"FORCE_VERIFYING_SIGNATURE"`
@@ -141,6 +272,61 @@ type Config struct {
 	ThirdPartySignatureVerificationEnabled bool `envconfig:"THIRD_PARTY_SIGNATURE_VERIFICATION_ENABLED"  default:"false"`
 	// a comma-separated list of file paths to public keys in addition to the official public key for signature verification
 	ThirdPartySignatureVerificationPublicKeys []string `envconfig:"THIRD_PARTY_SIGNATURE_VERIFICATION_PUBLIC_KEYS"  default:""`
+	// a yaml file of trusted keys with ids and retirement times, keys can be rotated without re-signing plugins
+	ThirdPartySignatureVerificationKeyring string `envconfig:"THIRD_PARTY_SIGNATURE_VERIFICATION_KEYRING"`
+
+	// scan uploaded packages for hard-coded secrets, policy is one of off, warn and block
+	SecretScanPolicy string `envconfig:"SECRET_SCAN_POLICY" validate:"omitempty,oneof=off warn block"`
+	// a comma-separated list of detectors, all built-in detectors are used if empty
+	SecretScanDetectors []string `envconfig:"SECRET_SCAN_DETECTORS"`
+
+	// check pinned python and node dependencies of uploaded packages against the OSV database,
+	// policy is one of off, warn and block, packages are blocked by advisories of at least the block severity
+	VulnerabilityScanPolicy        string `envconfig:"VULNERABILITY_SCAN_POLICY" validate:"omitempty,oneof=off warn block"`
+	VulnerabilityScanBlockSeverity string `envconfig:"VULNERABILITY_SCAN_BLOCK_SEVERITY" validate:"omitempty,oneof=low medium high critical"`
+	VulnerabilityScanOSVURL        string `envconfig:"VULNERABILITY_SCAN_OSV_URL"`
+	VulnerabilityScanTimeout       int    `envconfig:"VULNERABILITY_SCAN_TIMEOUT" validate:"min=0"` // seconds
+	// accept the package anyway if the database is unavailable
+	VulnerabilityScanFailOpen bool `envconfig:"VULNERABILITY_SCAN_FAIL_OPEN"`
+
+	// coerce tool parameters to their declared types, fill in defaults and check their constraints
+	// before invoking a tool
+	ToolParameterCoercionEnabled bool `envconfig:"TOOL_PARAMETER_COERCION_ENABLED"`
+
+	// files uploaded for file parameters of tools are staged in the storage and downloaded by plugins
+	// from TOOL_FILE_URL_BASE, a url of the daemon they can reach, brokering is disabled if it's empty
+	ToolFileURLBase     string `envconfig:"TOOL_FILE_URL_BASE"`
+	ToolFileSigningKey  string `envconfig:"TOOL_FILE_SIGNING_KEY"`
+	ToolFileStoragePath string `envconfig:"TOOL_FILE_STORAGE_PATH"`
+	ToolFileMaxSize     int64  `envconfig:"TOOL_FILE_MAX_SIZE" validate:"min=0"`
+	ToolFileTTL         int    `envconfig:"TOOL_FILE_TTL" validate:"min=0"` // seconds
+	// cron expression of the sweep of expired files, every 10 minutes if empty
+	ToolFileExpirySweepSchedule string `envconfig:"TOOL_FILE_EXPIRY_SWEEP_SCHEDULE"`
+	// files uploaded to dify are referenced by their ids and downloaded by plugins from FILES_URL of dify,
+	// the urls are signed with SECRET_KEY of dify like dify signs file previews
+	ToolFileDifyFilesURL  string `envconfig:"TOOL_FILE_DIFY_FILES_URL"`
+	ToolFileDifySecretKey string `envconfig:"TOOL_FILE_DIFY_SECRET_KEY"`
+
+	// check variables and json messages of tools against their output_schema, one of off, warn and enforce
+	ToolOutputSchemaPolicy string `envconfig:"TOOL_OUTPUT_SCHEMA_POLICY" validate:"omitempty,oneof=off warn enforce"`
+
+	// yaml file overriding the default policies of trust tiers verified, partner, community and local
+	PluginTrustPolicyPath string `envconfig:"PLUGIN_TRUST_POLICY_PATH"`
+
+	// yaml file of custom steps run before and after plugins are installed, see install_hook
+	PluginInstallHooksPath string `envconfig:"PLUGIN_INSTALL_HOOKS_PATH"`
+
+	// scan plugin code before it's launched for the first time, one of clamav and http, empty to disable
+	MalwareScanner              string `envconfig:"MALWARE_SCANNER"`
+	MalwareScannerClamAVAddress string `envconfig:"MALWARE_SCANNER_CLAMAV_ADDRESS"`
+	MalwareScannerHTTPURL       string `envconfig:"MALWARE_SCANNER_HTTP_URL"`
+	MalwareScannerHTTPAPIKey    string `envconfig:"MALWARE_SCANNER_HTTP_API_KEY"`
+	// timeout of scanning a single file in seconds
+	MalwareScannerTimeout int `envconfig:"MALWARE_SCANNER_TIMEOUT"`
+	// verdicts are cached by package checksum, in seconds
+	MalwareScanVerdictTTL int `envconfig:"MALWARE_SCAN_VERDICT_TTL"`
+	// launch the plugin anyway if the scanner is unavailable
+	MalwareScanFailOpen bool `envconfig:"MALWARE_SCAN_FAIL_OPEN"`
 
 	// lifetime state management
 	LifetimeCollectionHeartbeatInterval int `envconfig:"LIFETIME_COLLECTION_HEARTBEAT_INTERVAL"  validate:"required"`
@@ -153,10 +339,74 @@ type Config struct {
 	DifyPluginServerlessConnectorAPIKey        *string `envconfig:"DIFY_PLUGIN_SERVERLESS_CONNECTOR_API_KEY"`
 	DifyPluginServerlessConnectorLaunchTimeout int     `envconfig:"DIFY_PLUGIN_SERVERLESS_CONNECTOR_LAUNCH_TIMEOUT"`
 
+	// plugins deployed to google cloud run instead of the serverless connector, a comma-separated list of
+	// plugin ids, author/* selects all plugins of an author and * selects all plugins
+	CloudRunPlugins         []string `envconfig:"CLOUD_RUN_PLUGINS"`
+	CloudRunProject         string   `envconfig:"CLOUD_RUN_PROJECT"`
+	CloudRunRegion          string   `envconfig:"CLOUD_RUN_REGION"`
+	CloudRunImageRepository string   `envconfig:"CLOUD_RUN_IMAGE_REPOSITORY"` // e.g. us-central1-docker.pkg.dev/project/dify-plugins
+	CloudRunBuildBucket     string   `envconfig:"CLOUD_RUN_BUILD_BUCKET"`     // gcs bucket where build contexts are uploaded
+	CloudRunServiceAccount  string   `envconfig:"CLOUD_RUN_SERVICE_ACCOUNT"`  // identity of the plugin services, the default compute account if empty
+	CloudRunCredentialsFile string   `envconfig:"CLOUD_RUN_CREDENTIALS_FILE"` // application default credentials if empty
+	CloudRunMaxInstances    int      `envconfig:"CLOUD_RUN_MAX_INSTANCES"`
+	CloudRunConcurrency     int      `envconfig:"CLOUD_RUN_CONCURRENCY"`
+
+	// plugins deployed to azure functions instead of the serverless connector, selected like CLOUD_RUN_PLUGINS
+	AzureFunctionsPlugins             []string `envconfig:"AZURE_FUNCTIONS_PLUGINS"`
+	AzureFunctionsSubscriptionID      string   `envconfig:"AZURE_FUNCTIONS_SUBSCRIPTION_ID"`
+	AzureFunctionsResourceGroup       string   `envconfig:"AZURE_FUNCTIONS_RESOURCE_GROUP"`
+	AzureFunctionsLocation            string   `envconfig:"AZURE_FUNCTIONS_LOCATION"`
+	AzureFunctionsPlanID              string   `envconfig:"AZURE_FUNCTIONS_PLAN_ID"`         // resource id of a linux elastic premium or dedicated plan
+	AzureFunctionsRegistry            string   `envconfig:"AZURE_FUNCTIONS_REGISTRY"`        // container registry in the resource group, images are built by its tasks
+	AzureFunctionsIdentityID          string   `envconfig:"AZURE_FUNCTIONS_IDENTITY_ID"`     // user assigned identity of the function apps
+	AzureFunctionsStorageAccount      string   `envconfig:"AZURE_FUNCTIONS_STORAGE_ACCOUNT"` // host storage of the function apps
+	AzureFunctionsHealthCheckInterval int      `envconfig:"AZURE_FUNCTIONS_HEALTH_CHECK_INTERVAL"`
+
+	// plugins deployed to knative or openfaas in the cluster of the daemon, selected like CLOUD_RUN_PLUGINS,
+	// images are built by kaniko jobs in the namespace of the daemon
+	SelfHostedServerlessPlugins          []string `envconfig:"SELF_HOSTED_SERVERLESS_PLUGINS"`
+	SelfHostedServerlessBackend          string   `envconfig:"SELF_HOSTED_SERVERLESS_BACKEND"`   // knative or openfaas
+	SelfHostedServerlessNamespace        string   `envconfig:"SELF_HOSTED_SERVERLESS_NAMESPACE"` // namespace of the functions
+	SelfHostedServerlessImageRepository  string   `envconfig:"SELF_HOSTED_SERVERLESS_IMAGE_REPOSITORY"`
+	SelfHostedServerlessRegistrySecret   string   `envconfig:"SELF_HOSTED_SERVERLESS_REGISTRY_SECRET"` // docker config secret kaniko pushes with
+	SelfHostedServerlessInsecureRegistry bool     `envconfig:"SELF_HOSTED_SERVERLESS_INSECURE_REGISTRY"`
+	SelfHostedServerlessKanikoImage      string   `envconfig:"SELF_HOSTED_SERVERLESS_KANIKO_IMAGE"`
+	SelfHostedServerlessMaxScale         int      `envconfig:"SELF_HOSTED_SERVERLESS_MAX_SCALE"`
+	SelfHostedServerlessConcurrency      int      `envconfig:"SELF_HOSTED_SERVERLESS_CONCURRENCY"`
+	OpenFaaSGatewayURL                   string   `envconfig:"OPENFAAS_GATEWAY_URL"`
+	OpenFaaSUsername                     string   `envconfig:"OPENFAAS_USERNAME"`
+	OpenFaaSPassword                     string   `envconfig:"OPENFAAS_PASSWORD"`
+
+	// functions are invoked with the bearer token and the client certificate, the gateway or the mesh
+	// in front of them is expected to verify them, the certificate is verified against the ca
+	SelfHostedServerlessFunctionToken string `envconfig:"SELF_HOSTED_SERVERLESS_FUNCTION_TOKEN"`
+	SelfHostedServerlessTLSCertFile   string `envconfig:"SELF_HOSTED_SERVERLESS_TLS_CERT_FILE"`
+	SelfHostedServerlessTLSKeyFile    string `envconfig:"SELF_HOSTED_SERVERLESS_TLS_KEY_FILE"`
+	SelfHostedServerlessTLSCAFile     string `envconfig:"SELF_HOSTED_SERVERLESS_TLS_CA_FILE"`
+
 	MaxPluginPackageSize            int64 `envconfig:"MAX_PLUGIN_PACKAGE_SIZE" validate:"required"`
 	MaxBundlePackageSize            int64 `envconfig:"MAX_BUNDLE_PACKAGE_SIZE" validate:"required"`
 	MaxServerlessTransactionTimeout int   `envconfig:"MAX_SERVERLESS_TRANSACTION_TIMEOUT"`
 
+	// large packages from the marketplace are downloaded in concurrent byte ranges
+	MarketplaceURL            string `envconfig:"MARKETPLACE_URL"`
+	MarketplaceAPIKey         string `envconfig:"MARKETPLACE_API_KEY"`
+	PluginDownloadConcurrency int    `envconfig:"PLUGIN_DOWNLOAD_CONCURRENCY"`
+	PluginDownloadChunkSize   int64  `envconfig:"PLUGIN_DOWNLOAD_CHUNK_SIZE"`
+
+	// packages downloaded from the marketplace are kept in the storage and served to other daemons under
+	// /marketplace, mirrored packages are still installable while the marketplace is unreachable
+	MarketplaceMirrorEnabled bool   `envconfig:"MARKETPLACE_MIRROR_ENABLED"`
+	MarketplaceMirrorPath    string `envconfig:"MARKETPLACE_MIRROR_PATH"`
+	// never contact the marketplace, only mirrored packages can be downloaded
+	MarketplaceMirrorOffline bool `envconfig:"MARKETPLACE_MIRROR_OFFLINE"`
+
+	// a private registry organizations publish their plugins to under /registry, packages are stored in
+	// PLUGIN_REGISTRY_PATH and have to be signed unless PLUGIN_REGISTRY_REQUIRE_SIGNATURE is false
+	PluginRegistryEnabled          bool   `envconfig:"PLUGIN_REGISTRY_ENABLED"`
+	PluginRegistryPath             string `envconfig:"PLUGIN_REGISTRY_PATH"`
+	PluginRegistryRequireSignature bool   `envconfig:"PLUGIN_REGISTRY_REQUIRE_SIGNATURE" default:"true"`
+
 	PythonInterpreterPath     string `envconfig:"PYTHON_INTERPRETER_PATH"`
 	UvPath                    string `envconfig:"UV_PATH"  default:""`
 	PythonEnvInitTimeout      int    `envconfig:"PYTHON_ENV_INIT_TIMEOUT" validate:"required"`
@@ -166,11 +416,118 @@ type Config struct {
 	PipVerbose                *bool  `envconfig:"PIP_VERBOSE"`
 	PipExtraArgs              string `envconfig:"PIP_EXTRA_ARGS"`
 
+	// uv caches wheels and interpreters here for all plugins, with offline wheels dependencies are
+	// installed from that directory only and nothing is downloaded
+	PythonEnvCachePath   string `envconfig:"PYTHON_ENV_CACHE_PATH"`
+	PipOfflineWheelsPath string `envconfig:"PIP_OFFLINE_WHEELS_PATH"`
+	// plugins with the same requirements and python share the packages installed for the first of them,
+	// unpinned requirements stay at what was resolved then
+	PythonVenvCacheEnabled bool `envconfig:"PYTHON_VENV_CACHE_ENABLED" default:"false"`
+
+	// air gapped deployments install dependencies only from the wheels bundled into packages, the
+	// offline wheels and the caches of uv and npm, installs needing anything else fail
+	PluginInstallOffline bool `envconfig:"PLUGIN_INSTALL_OFFLINE" default:"false"`
+
+	// node plugins run on this node and install their dependencies with npm, or pnpm for a pnpm lockfile
+	NodeInterpreterPath string `envconfig:"NODE_INTERPRETER_PATH"`
+	NodeEnvInitTimeout  int    `envconfig:"NODE_ENV_INIT_TIMEOUT"`
+	NpmPath             string `envconfig:"NPM_PATH"`
+	PnpmPath            string `envconfig:"PNPM_PATH"`
+	NpmRegistryUrl      string `envconfig:"NPM_REGISTRY_URL"`
+
+	// restart local plugins when their source changes, for development installs
+	PluginHotReload bool `envconfig:"PLUGIN_HOT_RELOAD"`
+
+	// enforce cpu, memory and file descriptor limits on local plugins, the daemon maximum caps
+	// the resource section of manifest and applies when manifest does not declare a limit
+	PluginResourceLimitsEnabled bool    `envconfig:"PLUGIN_RESOURCE_LIMITS_ENABLED"`
+	PluginMaxMemory             int64   `envconfig:"PLUGIN_MAX_MEMORY"`
+	PluginMaxCPU                float64 `envconfig:"PLUGIN_MAX_CPU"`
+	PluginMaxFileDescriptors    uint64  `envconfig:"PLUGIN_MAX_FILE_DESCRIPTORS"`
+	PluginCgroupRoot            string  `envconfig:"PLUGIN_CGROUP_ROOT"`
+
+	// route http and https traffic of local plugins through a proxy of the daemon allowing only the
+	// destinations declared in manifest or allowed globally
+	PluginEgressPolicyEnabled bool     `envconfig:"PLUGIN_EGRESS_POLICY_ENABLED"`
+	PluginEgressProxyAddress  string   `envconfig:"PLUGIN_EGRESS_PROXY_ADDRESS"`
+	PluginEgressAllowed       []string `envconfig:"PLUGIN_EGRESS_ALLOWED"`
+	// plugin ids allowed to declare `*` and networks wider than a /8 in manifest
+	PluginEgressUnrestrictedPlugins []string `envconfig:"PLUGIN_EGRESS_UNRESTRICTED_PLUGINS"`
+
+	// run local plugins in namespaces seeing only system directories and their own, linux only
+	PluginSandboxEnabled         bool     `envconfig:"PLUGIN_SANDBOX_ENABLED"`
+	PluginSandboxReadOnlyPaths   []string `envconfig:"PLUGIN_SANDBOX_READONLY_PATHS"`
+	PluginSandboxWritablePaths   []string `envconfig:"PLUGIN_SANDBOX_WRITABLE_PATHS"`
+	PluginSandboxAppArmorProfile string   `envconfig:"PLUGIN_SANDBOX_APPARMOR_PROFILE"`
+
 	PluginStdioBufferSize    int `envconfig:"PLUGIN_STDIO_BUFFER_SIZE" default:"1024"`
 	PluginStdioMaxBufferSize int `envconfig:"PLUGIN_STDIO_MAX_BUFFER_SIZE" default:"5242880"`
+	// lines of stdout and stderr kept per local plugin for the logs api
+	PluginLogBufferLines int `envconfig:"PLUGIN_LOG_BUFFER_LINES" default:"1000"`
+
+	// stdio or socket, local plugins connecting to the socket talk over it instead of stdin and stdout,
+	// others keep using stdio
+	PluginLocalTransport string `envconfig:"PLUGIN_LOCAL_TRANSPORT" validate:"omitempty,oneof=stdio socket"`
+
+	// exchange binary payloads with local plugins supporting it over extra pipes instead of base64 in json
+	// lines, strings of requests of at least the threshold in bytes are offloaded, blobs are capped by max size
+	// and what is buffered of the blobs of a plugin by the memory limit
+	PluginBlobChannelEnabled   bool  `envconfig:"PLUGIN_BLOB_CHANNEL_ENABLED" default:"false"`
+	PluginBlobOffloadThreshold int   `envconfig:"PLUGIN_BLOB_OFFLOAD_THRESHOLD" default:"65536" validate:"min=0"`
+	PluginBlobMaxSize          int64 `envconfig:"PLUGIN_BLOB_MAX_SIZE" default:"104857600" validate:"min=0"`
+	PluginBlobMemoryLimit      int64 `envconfig:"PLUGIN_BLOB_MEMORY_LIMIT" default:"67108864" validate:"min=0"`
+
+	// encodings local plugins may negotiate in their handshake instead of json lines, msgpack and cbor are
+	// supported, empty keeps every plugin on json. converting costs the daemon more than handling json,
+	// see the benchmarks of event_codec, so it is only worth it for plugins sending large binary values
+	PluginEventEncodings []string `envconfig:"PLUGIN_EVENT_ENCODINGS"`
+
+	// ping local plugins every interval seconds and restart those not answering within timeout seconds,
+	// 0 disables it, plugins are only checked once they answered a ping
+	PluginHealthCheckInterval int `envconfig:"PLUGIN_HEALTH_CHECK_INTERVAL" default:"0" validate:"min=0"`
+	PluginHealthCheckTimeout  int `envconfig:"PLUGIN_HEALTH_CHECK_TIMEOUT" default:"30" validate:"min=1"`
+
+	// upgraded local plugins are watched for this many seconds before the previous version is removed,
+	// the tenant is rolled back to it if the new runtime stops, restarts or hangs, 0 disables it
+	PluginUpgradeHealthCheckPeriod int `envconfig:"PLUGIN_UPGRADE_HEALTH_CHECK_PERIOD" default:"30" validate:"min=0"`
+
+	// invocations a plugin runs at the same time on a node, the max_concurrency of manifest is capped by
+	// PLUGIN_MAX_CONCURRENCY which applies if manifest declares none, 0 is unlimited, invocations beyond it
+	// wait in a queue and are answered with 429 once it's full or they waited for the timeout in seconds
+	PluginMaxConcurrency         int `envconfig:"PLUGIN_MAX_CONCURRENCY" default:"0" validate:"min=0"`
+	PluginInvocationQueueSize    int `envconfig:"PLUGIN_INVOCATION_QUEUE_SIZE" default:"100" validate:"min=0"`
+	PluginInvocationQueueTimeout int `envconfig:"PLUGIN_INVOCATION_QUEUE_TIMEOUT" default:"30" validate:"min=1"`
+	// seconds rejected callers are asked to wait with Retry-After
+	PluginInvocationRetryAfter int `envconfig:"PLUGIN_INVOCATION_RETRY_AFTER" default:"5" validate:"min=1"`
+
+	// token buckets of requests per second and bursts shared by the cluster, for all requests, for each
+	// tenant and for each plugin of a tenant, a zero rate is unlimited, overrides are tenant_id:rate:burst
+	RateLimitGlobalRate      float64  `envconfig:"RATE_LIMIT_GLOBAL_RATE" validate:"min=0"`
+	RateLimitGlobalBurst     int64    `envconfig:"RATE_LIMIT_GLOBAL_BURST" validate:"min=0"`
+	RateLimitTenantRate      float64  `envconfig:"RATE_LIMIT_TENANT_RATE" validate:"min=0"`
+	RateLimitTenantBurst     int64    `envconfig:"RATE_LIMIT_TENANT_BURST" validate:"min=0"`
+	RateLimitPluginRate      float64  `envconfig:"RATE_LIMIT_PLUGIN_RATE" validate:"min=0"`
+	RateLimitPluginBurst     int64    `envconfig:"RATE_LIMIT_PLUGIN_BURST" validate:"min=0"`
+	RateLimitTenantOverrides []string `envconfig:"RATE_LIMIT_TENANT_OVERRIDES"`
+
+	// cache responses of backwards llm and text embedding invocations for seconds of ttl, only plugins
+	// declaring resource.model_cache in manifest are cached
+	BackwardsInvocationCacheEnabled bool `envconfig:"BACKWARDS_INVOCATION_CACHE_ENABLED"`
+	BackwardsInvocationCacheTTL     int  `envconfig:"BACKWARDS_INVOCATION_CACHE_TTL" default:"3600" validate:"min=1"`
+
+	// keep idle interpreters of PYTHON_INTERPRETER_PATH with the preloaded modules imported, local plugins
+	// of the same python version are bound into them when they start, 0 disables it, nothing is preloaded
+	// unless it's listed as plugins monkey-patching with gevent break if ssl or socket are imported first
+	PluginWarmPoolSize    int      `envconfig:"PLUGIN_WARM_POOL_SIZE" default:"0" validate:"min=0"`
+	PluginWarmPoolPreload []string `envconfig:"PLUGIN_WARM_POOL_PRELOAD"`
 
 	DisplayClusterLog bool `envconfig:"DISPLAY_CLUSTER_LOG"`
 
+	// assign local plugins to nodes by consistent hashing instead of launching all of them on every node,
+	// each plugin runs on PLUGIN_ASSIGNMENT_REPLICAS nodes and invocations on other nodes are proxied
+	PluginAssignmentEnabled  bool `envconfig:"PLUGIN_ASSIGNMENT_ENABLED"`
+	PluginAssignmentReplicas int  `envconfig:"PLUGIN_ASSIGNMENT_REPLICAS" default:"1" validate:"min=1"`
+
 	PPROFEnabled bool `envconfig:"PPROF_ENABLED"`
 
 	SentryEnabled          bool    `envconfig:"SENTRY_ENABLED"`
@@ -180,13 +537,107 @@ type Config struct {
 	SentryTracesSampleRate float64 `envconfig:"SENTRY_TRACES_SAMPLE_RATE"`
 	SentrySampleRate       float64 `envconfig:"SENTRY_SAMPLE_RATE"`
 
+	// synthetic storage probes, results are reported in /health/check
+	StorageProbeEnabled            bool `envconfig:"STORAGE_PROBE_ENABLED"`
+	StorageProbeInterval           int  `envconfig:"STORAGE_PROBE_INTERVAL"`
+	StorageProbeLatencyThresholdMs int  `envconfig:"STORAGE_PROBE_LATENCY_THRESHOLD_MS"`
+	StorageProbeErrorThreshold     int  `envconfig:"STORAGE_PROBE_ERROR_THRESHOLD"`
+
+	// anomaly detection learns per-plugin baselines of traffic and alerts on dramatic deviations
+	AnomalyDetectionEnabled         bool    `envconfig:"ANOMALY_DETECTION_ENABLED"`
+	AnomalyDetectionWindow          int     `envconfig:"ANOMALY_DETECTION_WINDOW"`
+	AnomalyDetectionWarmupWindows   int     `envconfig:"ANOMALY_DETECTION_WARMUP_WINDOWS"`
+	AnomalyDetectionFactor          float64 `envconfig:"ANOMALY_DETECTION_FACTOR"`
+	AnomalyDetectionMinCount        int     `envconfig:"ANOMALY_DETECTION_MIN_COUNT"`
+	AnomalyDetectionMinPayloadBytes int     `envconfig:"ANOMALY_DETECTION_MIN_PAYLOAD_BYTES"`
+	AnomalyAlertCooldown            int     `envconfig:"ANOMALY_ALERT_COOLDOWN"`
+	AnomalyAlertWebhookURL          string  `envconfig:"ANOMALY_ALERT_WEBHOOK_URL"`
+
+	// timezone of cron expressions without a CRON_TZ prefix, the local timezone is used if empty
+	ScheduleTimezone string `envconfig:"SCHEDULE_TIMEZONE"`
+
+	// persist invocation counts every flush interval seconds and roll them up into hourly and daily
+	// aggregates every rollup interval seconds, or by the rollup schedule if it's set
+	AnalyticsEnabled        bool   `envconfig:"ANALYTICS_ENABLED"`
+	AnalyticsFlushInterval  int    `envconfig:"ANALYTICS_FLUSH_INTERVAL" default:"60" validate:"min=1"`
+	AnalyticsRollupInterval int    `envconfig:"ANALYTICS_ROLLUP_INTERVAL" default:"300" validate:"min=1"`
+	AnalyticsRollupSchedule string `envconfig:"ANALYTICS_ROLLUP_SCHEDULE"`
+
+	// route a part of the invocations of a plugin to a new version before all tenants are upgraded to it,
+	// counts are persisted every sync interval seconds and the master decides on rollouts every evaluate
+	// interval seconds, the schedules override the intervals
+	PluginRolloutEnabled          bool   `envconfig:"PLUGIN_ROLLOUT_ENABLED"`
+	PluginRolloutSyncInterval     int    `envconfig:"PLUGIN_ROLLOUT_SYNC_INTERVAL" default:"5" validate:"min=1"`
+	PluginRolloutSyncSchedule     string `envconfig:"PLUGIN_ROLLOUT_SYNC_SCHEDULE"`
+	PluginRolloutEvaluateInterval int    `envconfig:"PLUGIN_ROLLOUT_EVALUATE_INTERVAL" default:"30" validate:"min=1"`
+	PluginRolloutEvaluateSchedule string `envconfig:"PLUGIN_ROLLOUT_EVALUATE_SCHEDULE"`
+
+	// installations of tenants other than the admin tenants wait for an admin to approve them,
+	// requests and decisions are posted to the webhook
+	InstallApprovalEnabled      bool     `envconfig:"INSTALL_APPROVAL_ENABLED"`
+	InstallApprovalAdminTenants []string `envconfig:"INSTALL_APPROVAL_ADMIN_TENANTS"`
+	InstallApprovalWebhookURL   string   `envconfig:"INSTALL_APPROVAL_WEBHOOK_URL"`
+
+	// installs, credential changes, endpoint changes and debugging connections are appended to the
+	// audit table, it's purged by the retention of audit records only
+	AuditLogEnabled bool `envconfig:"AUDIT_LOG_ENABLED"`
+
+	// lifecycle events such as installs, runtime crashes and endpoint errors are published to every
+	// configured target, kafka is only reached through a confluent rest proxy
+	EventBusEvents              []string `envconfig:"EVENT_BUS_EVENTS"`
+	EventBusWebhookURL          string   `envconfig:"EVENT_BUS_WEBHOOK_URL"`
+	EventBusNatsURL             string   `envconfig:"EVENT_BUS_NATS_URL"`
+	EventBusNatsSubject         string   `envconfig:"EVENT_BUS_NATS_SUBJECT"`
+	EventBusNatsCredentialsFile string   `envconfig:"EVENT_BUS_NATS_CREDENTIALS_FILE"`
+	EventBusNatsNkeySeedFile    string   `envconfig:"EVENT_BUS_NATS_NKEY_SEED_FILE"`
+	EventBusNatsTLSCAFile       string   `envconfig:"EVENT_BUS_NATS_TLS_CA_FILE"`
+	EventBusNatsTLSCertFile     string   `envconfig:"EVENT_BUS_NATS_TLS_CERT_FILE"`
+	EventBusNatsTLSKeyFile      string   `envconfig:"EVENT_BUS_NATS_TLS_KEY_FILE"`
+	EventBusKafkaRestURL        string   `envconfig:"EVENT_BUS_KAFKA_REST_URL"`
+	EventBusKafkaTopic          string   `envconfig:"EVENT_BUS_KAFKA_TOPIC"`
+
+	// retention windows of each data class in days, 0 keeps the records forever
+	RetentionPruneInterval           int    `envconfig:"RETENTION_PRUNE_INTERVAL"`
+	RetentionPruneSchedule           string `envconfig:"RETENTION_PRUNE_SCHEDULE"`
+	RetentionAuditRecordsDays        int    `envconfig:"RETENTION_AUDIT_RECORDS_DAYS"`
+	RetentionInvocationAnalyticsDays int    `envconfig:"RETENTION_INVOCATION_ANALYTICS_DAYS"`
+	RetentionSessionRecordingsDays   int    `envconfig:"RETENTION_SESSION_RECORDINGS_DAYS"`
+	RetentionInstallTasksDays        int    `envconfig:"RETENTION_INSTALL_TASKS_DAYS"`
+
+	// opentelemetry settings
+	OtelEnabled          bool    `envconfig:"OTEL_ENABLED"`
+	OtelServiceName      string  `envconfig:"OTEL_SERVICE_NAME"`
+	OtelExporterEndpoint string  `envconfig:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	OtelTracesSampleRate float64 `envconfig:"OTEL_TRACES_SAMPLE_RATE" default:"1.0"`
+
 	// proxy settings
 	HttpProxy  string `envconfig:"HTTP_PROXY"`
 	HttpsProxy string `envconfig:"HTTPS_PROXY"`
 	NoProxy    string `envconfig:"NO_PROXY"`
 
 	// log settings
-	HealthApiLogEnabled *bool `envconfig:"HEALTH_API_LOG_ENABLED"`
+	HealthApiLogEnabled *bool  `envconfig:"HEALTH_API_LOG_ENABLED"`
+	LogFormat           string `envconfig:"LOG_FORMAT" default:"text" validate:"oneof=text json"`
+	// lines about a plugin are appended to <LOG_PLUGIN_DIR>/<author>_<name>.log as json as well
+	LogPluginDir string `envconfig:"LOG_PLUGIN_DIR"`
+
+	// secrets of tenants referenced as {{secret.NAME}} are encrypted with a key derived from it
+	TenantVariablesEncryptionKey string `envconfig:"TENANT_VARIABLES_ENCRYPTION_KEY"`
+	// credentials granted to tool providers by oauth authorizations are encrypted with a key derived from it
+	OAuthCredentialsEncryptionKey string `envconfig:"OAUTH_CREDENTIALS_ENCRYPTION_KEY"`
+
+	// envelope encryption of secrets and oauth credentials, data keys are wrapped by the provider,
+	// the keys above are only used without a provider and to decrypt what they encrypted
+	KMSProvider          string `envconfig:"KMS_PROVIDER" validate:"omitempty,oneof=local aws gcp vault"`
+	KMSLocalMasterKey    string `envconfig:"KMS_LOCAL_MASTER_KEY"`
+	KMSAWSKeyID          string `envconfig:"KMS_AWS_KEY_ID"`
+	KMSAWSRegion         string `envconfig:"KMS_AWS_REGION"`
+	KMSAWSEndpoint       string `envconfig:"KMS_AWS_ENDPOINT"`
+	KMSGCPKeyName        string `envconfig:"KMS_GCP_KEY_NAME"`
+	KMSVaultAddress      string `envconfig:"KMS_VAULT_ADDRESS"`
+	KMSVaultToken        string `envconfig:"KMS_VAULT_TOKEN"`
+	KMSVaultTransitMount string `envconfig:"KMS_VAULT_TRANSIT_MOUNT" default:"transit"`
+	KMSVaultKeyName      string `envconfig:"KMS_VAULT_KEY_NAME"`
 
 	// dify invocation write timeout in milliseconds
 	DifyInvocationWriteTimeout int64 `envconfig:"DIFY_BACKWARDS_INVOCATION_WRITE_TIMEOUT" default:"5000"`
@@ -214,6 +665,68 @@ func (c *Config) Validate() error {
 		if c.PluginRemoteInstallServerEventLoopNums == 0 {
 			return fmt.Errorf("plugin remote install server event loop nums is empty")
 		}
+		if (c.PluginRemoteInstallingGrpcTLSCertFile == "") != (c.PluginRemoteInstallingGrpcTLSKeyFile == "") {
+			return fmt.Errorf("plugin remote installing grpc tls needs both a cert file and a key file")
+		}
+	}
+
+	if c.PluginTestInvokeRoot != "" {
+		if info, err := os.Stat(c.PluginTestInvokeRoot); err != nil || !info.IsDir() {
+			return fmt.Errorf("plugin test invoke root %s is not a directory", c.PluginTestInvokeRoot)
+		}
+	}
+
+	for _, module := range c.PluginWarmPoolPreload {
+		for _, patched := range geventPatchedModules {
+			if module == patched || strings.HasPrefix(module, patched+".") {
+				return fmt.Errorf("plugin warm pool preload must not import %s, gevent patches it", module)
+			}
+		}
+	}
+
+	if c.ToolFileURLBase != "" && (c.ToolFileSigningKey == "" || c.ToolFileSigningKey == c.ServerKey) {
+		return fmt.Errorf("tool file signing key must be set and differ from the server key to broker tool files")
+	}
+	if (c.ToolFileDifyFilesURL == "") != (c.ToolFileDifySecretKey == "") {
+		return fmt.Errorf("tool file dify files url and secret key must be set together")
+	}
+
+	if (c.ServerTLSCertFile == "") != (c.ServerTLSKeyFile == "") {
+		return fmt.Errorf("server tls needs both a cert file and a key file")
+	}
+	if c.ServerTLSClientCAFile != "" && c.ServerTLSCertFile == "" {
+		return fmt.Errorf("server tls client ca file needs server tls cert and key files")
+	}
+	if (c.ServerTLSPeerCertFile == "") != (c.ServerTLSPeerKeyFile == "") {
+		return fmt.Errorf("server tls peer cert file and key file must be set together")
+	}
+	if (c.ServerTLSPeerCAFile != "" || c.ServerTLSPeerCertFile != "") && c.ServerTLSCertFile == "" {
+		return fmt.Errorf("server tls peer files need a server tls cert file")
+	}
+	if c.ServerTLSPeerCAFile != "" && c.ServerTLSPeerServerName == "" {
+		return fmt.Errorf("server tls peer server name is required to verify peers against the peer ca")
+	}
+	if c.ServerTLSClientCertAuthEnabled && c.ServerTLSClientCAFile == "" {
+		return fmt.Errorf("client certificate authentication needs a server tls client ca file")
+	}
+	for name, rules := range map[string][]string{
+		"trusted proxies":                       c.TrustedProxies,
+		"server ip allowlist":                   c.ServerIPAllowlist,
+		"plugin remote installing ip allowlist": c.PluginRemoteInstallingIPAllowlist,
+	} {
+		if _, err := network.ParseIPNets(rules); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	if (c.ServerProxyProtocolEnabled || c.PluginRemoteInstallingProxyProtocolEnabled) && len(c.TrustedProxies) == 0 {
+		return fmt.Errorf("proxy protocol needs trusted proxies")
+	}
+	if (c.DifyInnerApiTLSCertFile == "") != (c.DifyInnerApiTLSKeyFile == "") {
+		return fmt.Errorf("dify inner api tls needs both a cert file and a key file")
+	}
+
+	if c.EndpointTLSPort != 0 && c.EndpointTLSCertDir == "" {
+		return fmt.Errorf("endpoint tls cert dir is empty")
 	}
 
 	if c.Platform == PLATFORM_SERVERLESS {
@@ -228,6 +741,36 @@ func (c *Config) Validate() error {
 		if c.MaxServerlessTransactionTimeout == 0 {
 			return fmt.Errorf("max serverless transaction timeout is empty")
 		}
+
+		if len(c.CloudRunPlugins) > 0 {
+			if c.CloudRunProject == "" || c.CloudRunRegion == "" {
+				return fmt.Errorf("cloud run project and region are required by cloud run plugins")
+			}
+			if c.CloudRunImageRepository == "" || c.CloudRunBuildBucket == "" {
+				return fmt.Errorf("cloud run image repository and build bucket are required by cloud run plugins")
+			}
+		}
+
+		if len(c.AzureFunctionsPlugins) > 0 {
+			if c.AzureFunctionsSubscriptionID == "" || c.AzureFunctionsResourceGroup == "" || c.AzureFunctionsLocation == "" {
+				return fmt.Errorf("azure functions subscription, resource group and location are required by azure functions plugins")
+			}
+			if c.AzureFunctionsPlanID == "" || c.AzureFunctionsRegistry == "" || c.AzureFunctionsIdentityID == "" || c.AzureFunctionsStorageAccount == "" {
+				return fmt.Errorf("azure functions plan, registry, identity and storage account are required by azure functions plugins")
+			}
+		}
+
+		if len(c.SelfHostedServerlessPlugins) > 0 {
+			if c.SelfHostedServerlessBackend != "knative" && c.SelfHostedServerlessBackend != "openfaas" {
+				return fmt.Errorf("self hosted serverless backend must be knative or openfaas")
+			}
+			if c.SelfHostedServerlessImageRepository == "" {
+				return fmt.Errorf("self hosted serverless image repository is required by self hosted serverless plugins")
+			}
+			if (c.SelfHostedServerlessTLSCertFile == "") != (c.SelfHostedServerlessTLSKeyFile == "") {
+				return fmt.Errorf("self hosted serverless tls cert file and key file must be set together")
+			}
+		}
 	} else if c.Platform == PLATFORM_LOCAL {
 		if c.PluginWorkingPath == "" {
 			return fmt.Errorf("plugin working path is empty")
@@ -240,12 +783,46 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("plugin package cache path is empty")
 	}
 
+	if c.CacheType == CACHE_TYPE_MEMORY && c.RedisUseSentinel {
+		return fmt.Errorf("redis sentinel can not be used with memory cache")
+	}
+
+	if c.CacheType == CACHE_TYPE_MEMORY && c.RedisUseClusters {
+		return fmt.Errorf("redis cluster can not be used with memory cache")
+	}
+
+	if c.RedisUseClusters {
+		if c.RedisUseSentinel {
+			return fmt.Errorf("redis cluster and sentinel can not be enabled at the same time")
+		}
+		if c.RedisClusters == "" {
+			return fmt.Errorf("redis clusters is empty")
+		}
+	}
+
 	return nil
 }
 
+const (
+	CACHE_TYPE_REDIS  = "redis"
+	CACHE_TYPE_MEMORY = "memory"
+)
+
 type PlatformType string
 
 const (
 	PLATFORM_LOCAL      PlatformType = "local"
 	PLATFORM_SERVERLESS PlatformType = "serverless"
 )
+
+const (
+	SERVER_TLS_CLIENT_AUTH_REQUIRE  = "require"
+	SERVER_TLS_CLIENT_AUTH_OPTIONAL = "optional"
+)
+
+// geventPatchedModules must not be imported by warm interpreters, plugins monkey-patching them with gevent
+// break once they were imported
+var geventPatchedModules = []string{
+	"socket", "ssl", "select", "selectors", "threading", "subprocess",
+	"asyncio", "concurrent.futures", "http.client", "urllib.request",
+}
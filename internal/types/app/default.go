@@ -16,33 +16,91 @@ func (config *Config) SetDefault() {
 	setDefaultInt(&config.PluginRemoteInstallingMaxConn, 256)
 	setDefaultInt(&config.MaxPluginPackageSize, 52428800)
 	setDefaultInt(&config.MaxBundlePackageSize, 52428800*12)
+	// bundles are the largest uploads, the rest is room for the multipart form
+	setDefaultInt(&config.ServerInstallMaxBodySize, config.MaxBundlePackageSize+1024*1024)
 	setDefaultInt(&config.MaxServerlessTransactionTimeout, 300)
+	setDefaultString(&config.MarketplaceURL, "https://marketplace.dify.ai")
+	setDefaultString(&config.ServerTLSClientAuth, SERVER_TLS_CLIENT_AUTH_OPTIONAL)
+	setDefaultInt(&config.PluginDownloadConcurrency, 4)
+	setDefaultInt(&config.PluginDownloadChunkSize, 8*1024*1024)
+	setDefaultString(&config.MarketplaceMirrorPath, "marketplace_mirror")
+	setDefaultString(&config.PluginRegistryPath, "plugin_registry")
 	setDefaultInt(&config.PluginMaxExecutionTimeout, 10*60)
 	setDefaultString(&config.PluginStorageType, oss.OSS_TYPE_LOCAL)
 	setDefaultInt(&config.PluginMediaCacheSize, 1024)
 	setDefaultInt(&config.DifyPluginServerlessConnectorLaunchTimeout, 240)
+	setDefaultInt(&config.CloudRunMaxInstances, 10)
+	setDefaultInt(&config.CloudRunConcurrency, 80)
+	setDefaultInt(&config.AzureFunctionsHealthCheckInterval, 60)
+	setDefaultString(&config.SelfHostedServerlessBackend, "knative")
+	setDefaultString(&config.SelfHostedServerlessKanikoImage, "gcr.io/kaniko-project/executor:v1.23.2")
+	setDefaultInt(&config.SelfHostedServerlessMaxScale, 10)
+	setDefaultInt(&config.SelfHostedServerlessConcurrency, 80)
+	setDefaultString(&config.OpenFaaSGatewayURL, "http://gateway.openfaas:8080")
 	setDefaultInt(&config.PluginRemoteInstallingMaxSingleTenantConn, 5)
 	setDefaultBoolPtr(&config.PluginRemoteInstallingEnabled, true)
 	setDefaultBoolPtr(&config.PluginEndpointEnabled, true)
 	setDefaultString(&config.DBSslMode, "disable")
 	setDefaultString(&config.PluginStorageLocalRoot, "storage")
+	setDefaultString(&config.OtelServiceName, "dify-plugin-daemon")
+	setDefaultInt(&config.StorageProbeInterval, 30)
+	setDefaultInt(&config.StorageProbeLatencyThresholdMs, 1000)
+	setDefaultInt(&config.StorageProbeErrorThreshold, 3)
+	setDefaultInt(&config.AnomalyDetectionWindow, 60)
+	setDefaultInt(&config.AnomalyDetectionWarmupWindows, 60)
+	setDefaultInt(&config.AnomalyDetectionMinCount, 50)
+	setDefaultInt(&config.AnomalyDetectionMinPayloadBytes, 10*1024*1024)
+	setDefaultInt(&config.AnomalyAlertCooldown, 3600)
+	setDefaultFloat(&config.AnomalyDetectionFactor, 10)
+	setDefaultInt(&config.RetentionPruneInterval, 3600)
+	setDefaultString(&config.EventBusNatsSubject, "dify.plugin_daemon.events")
+	setDefaultString(&config.EventBusKafkaTopic, "dify-plugin-daemon-events")
 	setDefaultString(&config.PluginInstalledPath, "plugin")
 	setDefaultString(&config.PluginMediaCachePath, "assets")
 	setDefaultString(&config.PersistenceStoragePath, "persistence")
 	setDefaultInt(&config.PluginLocalLaunchingConcurrent, 2)
+	setDefaultInt(&config.PluginInstallConcurrency, 5)
+	setDefaultInt(&config.PluginInstallMaxRetries, 2)
+	setDefaultInt(&config.PluginInstallQueueSize, 100)
 	setDefaultInt(&config.PersistenceStorageMaxSize, 100*1024*1024)
 	setDefaultString(&config.PluginPackageCachePath, "plugin_packages")
+	setDefaultString(&config.PluginCgroupRoot, "/sys/fs/cgroup/dify-plugin-daemon")
+	setDefaultString(&config.PluginEgressProxyAddress, "127.0.0.1:0")
+	setDefaultString(&config.LogFormat, "text")
+	setDefaultInt(&config.PluginHealthCheckTimeout, 30)
+	setDefaultInt(&config.ShutdownDrainTimeout, 30)
 	setDefaultString(&config.PythonInterpreterPath, "/usr/bin/python3")
 	setDefaultInt(&config.PythonEnvInitTimeout, 120)
+	setDefaultString(&config.PythonEnvCachePath, "python_env_cache")
+	setDefaultString(&config.NodeInterpreterPath, "node")
+	setDefaultInt(&config.NodeEnvInitTimeout, 300)
+	setDefaultString(&config.NpmPath, "npm")
+	setDefaultString(&config.PnpmPath, "pnpm")
 	setDefaultBoolPtr(&config.ForceVerifyingSignature, true)
+	setDefaultString(&config.SecretScanPolicy, "warn")
+	setDefaultString(&config.VulnerabilityScanPolicy, "off")
+	setDefaultString(&config.VulnerabilityScanBlockSeverity, "high")
+	setDefaultString(&config.VulnerabilityScanOSVURL, "https://api.osv.dev")
+	setDefaultInt(&config.VulnerabilityScanTimeout, 30)
+	setDefaultString(&config.ToolOutputSchemaPolicy, "off")
+	setDefaultString(&config.PluginLocalTransport, "stdio")
+	setDefaultString(&config.ToolFileStoragePath, "tool_files")
+	setDefaultInt(&config.ToolFileMaxSize, 100*1024*1024)
+	setDefaultInt(&config.ToolFileTTL, 3600)
+	setDefaultInt(&config.CacheMemoryMaxKeys, 100000)
+	setDefaultInt(&config.MalwareScannerTimeout, 60)
+	setDefaultInt(&config.MalwareScanVerdictTTL, 7*24*60*60)
 	setDefaultBoolPtr(&config.PipPreferBinary, true)
 	setDefaultBoolPtr(&config.PipVerbose, true)
 	setDefaultInt(&config.DifyInvocationWriteTimeout, 5000)
 	setDefaultInt(&config.DifyInvocationReadTimeout, 240000)
 	if config.DBType == "postgresql" {
 		setDefaultString(&config.DBDefaultDatabase, "postgres")
-	} else if config.DBType == "mysql" {
+	} else if config.DBType == "mysql" || config.DBType == "mariadb" {
 		setDefaultString(&config.DBDefaultDatabase, "mysql")
+	} else if config.DBType == "sqlite" {
+		setDefaultString(&config.DBSqlitePath, "storage/dify_plugin.db")
+		setDefaultInt(&config.DBSqliteBusyTimeout, 5000)
 	}
 	setDefaultBoolPtr(&config.HealthApiLogEnabled, true)
 }
@@ -53,6 +111,12 @@ func setDefaultInt[T constraints.Integer](value *T, defaultValue T) {
 	}
 }
 
+func setDefaultFloat[T constraints.Float](value *T, defaultValue T) {
+	if *value == 0 {
+		*value = defaultValue
+	}
+}
+
 func setDefaultString(value *string, defaultValue string) {
 	if *value == "" {
 		*value = defaultValue
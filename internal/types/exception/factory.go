@@ -1,6 +1,7 @@
 package exception
 
 import (
+	"fmt"
 	"runtime/debug"
 
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
@@ -19,6 +20,13 @@ const (
 	PluginPermissionDeniedError       = "PluginPermissionDeniedError"
 	PluginInvokeError                 = "PluginInvokeError"
 	PluginConnectionClosedError       = "ConnectionClosedError"
+	PluginReferencedError             = "PluginReferencedError"
+	PluginDaemonUnavailableError      = "PluginDaemonUnavailableError"
+	PluginDaemonTooManyRequestsError  = "PluginDaemonTooManyRequestsError"
+	PluginDaemonQuotaExceededError    = "PluginDaemonQuotaExceededError"
+	PluginDaemonRequestTooLargeError  = "PluginDaemonRequestTooLargeError"
+	PluginDaemonRequestTimeoutError   = "PluginDaemonRequestTimeoutError"
+	PluginDaemonSessionCancelledError = "PluginDaemonSessionCancelledError"
 )
 
 func InternalServerError(err error) PluginDaemonError {
@@ -60,8 +68,38 @@ func InvokePluginError(err error) PluginDaemonError {
 	return ErrorWithTypeAndCode(err.Error(), PluginInvokeError, -500)
 }
 
+func UnavailableError(msg string) PluginDaemonError {
+	return ErrorWithTypeAndCode(msg, PluginDaemonUnavailableError, -503)
+}
+
+func TooManyRequestsError(msg string) PluginDaemonError {
+	return ErrorWithTypeAndCode(msg, PluginDaemonTooManyRequestsError, -429)
+}
+
+func QuotaExceededError(err error) PluginDaemonError {
+	return ErrorWithTypeAndCode(err.Error(), PluginDaemonQuotaExceededError, -403)
+}
+
+func RequestTooLargeError(maxBytes int64) PluginDaemonError {
+	return ErrorWithTypeAndCode(fmt.Sprintf("request body exceeds %d bytes", maxBytes), PluginDaemonRequestTooLargeError, -413)
+}
+
+func RequestTimeoutError(msg string) PluginDaemonError {
+	return ErrorWithTypeAndCode(msg, PluginDaemonRequestTimeoutError, -408)
+}
+
+func SessionCancelledError(reason string) PluginDaemonError {
+	return ErrorWithTypeAndCode(reason, PluginDaemonSessionCancelledError, -409)
+}
+
 // ConnectionClosedError is designed to be used when the connection was closed unexpectedly
 // but the session is not closed yet.
 func ConnectionClosedError() PluginDaemonError {
 	return ErrorWithTypeAndCode("connection closed", PluginConnectionClosedError, -500)
 }
+
+// ErrPluginReferenced is returned when removing a plugin would break what relies on it,
+// args describe the references so the caller can ask for confirmation
+func ErrPluginReferenced(msg string, args map[string]any) PluginDaemonError {
+	return &genericError{Message: msg, code: -409, ErrorType: PluginReferencedError, Args: args}
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// APIKey is a key managed through the admin api, only the hash of the secret is stored
+type APIKey struct {
+	Model
+	Name string `json:"name" gorm:"size:127"`
+	// Prefix is the beginning of the secret to tell keys apart
+	Prefix string   `json:"prefix" gorm:"size:16"`
+	Hash   string   `json:"-" gorm:"uniqueIndex;size:64"`
+	Roles  []string `json:"roles" gorm:"serializer:json"`
+	// TenantID limits the key to the apis of a tenant, empty for all tenants
+	TenantID string `json:"tenant_id" gorm:"index;size:64"`
+	// Organization limits the key to an organization of the plugin registry, empty for all organizations
+	Organization string     `json:"organization" gorm:"size:64"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	// RotatedTo is the key replacing this one, the key remains valid until it expires
+	RotatedTo string `json:"rotated_to" gorm:"size:36"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+type PluginInstallApprovalStatus string
+
+const (
+	PluginInstallApprovalStatusPending  PluginInstallApprovalStatus = "pending"
+	PluginInstallApprovalStatusApproved PluginInstallApprovalStatus = "approved"
+	PluginInstallApprovalStatusRejected PluginInstallApprovalStatus = "rejected"
+	// approved but the install could not be started, Reason tells why
+	PluginInstallApprovalStatusFailed PluginInstallApprovalStatus = "failed"
+)
+
+// PluginInstallApproval is an install requested by a tenant which waits for an admin,
+// it keeps everything needed to start the install once approved
+type PluginInstallApproval struct {
+	Model
+	TenantID                       string                      `json:"tenant_id" gorm:"index;type:uuid"`
+	Status                         PluginInstallApprovalStatus `json:"status" gorm:"size:32;index"`
+	Action                         InstallTaskAction           `json:"action" gorm:"size:32"`
+	PluginUniqueIdentifiers        []string                    `json:"plugin_unique_identifiers" gorm:"serializer:json"`
+	Metas                          []map[string]any            `json:"metas" gorm:"serializer:json"`
+	Source                         string                      `json:"source" gorm:"size:64"`
+	OriginalPluginUniqueIdentifier string                      `json:"original_plugin_unique_identifier" gorm:"size:255"`
+	Reason                         string                      `json:"reason" gorm:"size:1023"`
+	ReviewedAt                     *time.Time                  `json:"reviewed_at"`
+	// TaskID is the install task started on approval, empty if the plugins were bound at once
+	TaskID string `json:"task_id" gorm:"size:36"`
+}
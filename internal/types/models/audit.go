@@ -0,0 +1,22 @@
+package models
+
+type AuditResult string
+
+const (
+	AuditResultSuccess AuditResult = "success"
+	AuditResultFailure AuditResult = "failure"
+)
+
+// AuditRecord is an administrative or debugging event, records are only appended and
+// never updated, they are removed by the retention of audit records only
+type AuditRecord struct {
+	Model
+	Event    string      `json:"event" gorm:"size:64;index"`
+	Actor    string      `json:"actor" gorm:"size:255;index"`
+	TenantID string      `json:"tenant_id" gorm:"size:64;index"`
+	SourceIP string      `json:"source_ip" gorm:"size:64"`
+	Result   AuditResult `json:"result" gorm:"size:16;index"`
+	Message  string      `json:"message" gorm:"size:1023"`
+	// Detail identifies what the event is about, such as plugins and endpoints, never credentials
+	Detail map[string]any `json:"detail" gorm:"serializer:json"`
+}
@@ -14,7 +14,7 @@ type Model struct {
 }
 
 func (m *Model) BeforeCreate(tx *gorm.DB) (err error) {
-	if tx.Dialector.Name() == "mysql" && m.ID == "" {
+	if tx.Dialector.Name() != "postgres" && m.ID == "" {
 		m.ID = uuid.New().String()
 	}
 	return
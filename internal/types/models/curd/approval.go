@@ -0,0 +1,48 @@
+package curd
+
+import (
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"gorm.io/gorm"
+)
+
+// ReviewPluginInstallApproval decides on a pending approval, concurrent reviews of the same approval
+// wait for each other and only the first one succeeds
+func ReviewPluginInstallApproval(
+	approvalId string,
+	status models.PluginInstallApprovalStatus,
+	reason string,
+) (*models.PluginInstallApproval, error) {
+	var approval models.PluginInstallApproval
+
+	err := db.WithTransaction(func(tx *gorm.DB) error {
+		var err error
+		approval, err = db.GetOne[models.PluginInstallApproval](
+			db.WithTransactionContext(tx),
+			db.Equal("id", approvalId),
+			db.WLock(),
+		)
+		if err == db.ErrDatabaseNotFound {
+			return ErrPluginInstallApprovalNotFound
+		} else if err != nil {
+			return err
+		}
+
+		if approval.Status != models.PluginInstallApprovalStatusPending {
+			return ErrPluginInstallApprovalReviewed
+		}
+
+		now := time.Now()
+		approval.Status = status
+		approval.Reason = reason
+		approval.ReviewedAt = &now
+		return db.Update(&approval, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &approval, nil
+}
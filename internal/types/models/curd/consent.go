@@ -0,0 +1,92 @@
+package curd
+
+import (
+	"slices"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"gorm.io/gorm"
+)
+
+// ConsentPluginPermissions adds permissions to the consent of a tenant to a plugin,
+// the permissions consented before are kept
+func ConsentPluginPermissions(
+	tenantId string,
+	pluginId string,
+	permissions []string,
+) (*models.PluginPermissionConsent, error) {
+	var consent models.PluginPermissionConsent
+
+	err := db.WithTransaction(func(tx *gorm.DB) error {
+		var err error
+		consent, err = db.GetOne[models.PluginPermissionConsent](
+			db.WithTransactionContext(tx),
+			db.Equal("tenant_id", tenantId),
+			db.Equal("plugin_id", pluginId),
+			db.WLock(),
+		)
+
+		if err == db.ErrDatabaseNotFound {
+			consent = models.PluginPermissionConsent{
+				TenantID:    tenantId,
+				PluginID:    pluginId,
+				Permissions: mergePermissions(nil, permissions),
+			}
+			return db.Create(&consent, tx)
+		} else if err != nil {
+			return err
+		}
+
+		consent.Permissions = mergePermissions(consent.Permissions, permissions)
+		return db.Update(&consent, tx)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &consent, nil
+}
+
+// EnsurePluginPermissionConsent records permissions as consented only if the tenant has no consent record
+// for the plugin yet, it's used to adopt installations made before consent was recorded
+func EnsurePluginPermissionConsent(
+	tenantId string,
+	pluginId string,
+	permissions []string,
+) error {
+	_, err := db.GetOne[models.PluginPermissionConsent](
+		db.Equal("tenant_id", tenantId),
+		db.Equal("plugin_id", pluginId),
+	)
+	if err == nil {
+		return nil
+	}
+	if err != db.ErrDatabaseNotFound {
+		return err
+	}
+
+	_, err = ConsentPluginPermissions(tenantId, pluginId, permissions)
+	return err
+}
+
+func DeletePluginPermissionConsent(tenantId string, pluginId string) error {
+	return db.DeleteByCondition(models.PluginPermissionConsent{
+		TenantID: tenantId,
+		PluginID: pluginId,
+	})
+}
+
+func mergePermissions(consented []string, permissions []string) []string {
+	merged := slices.Clone(consented)
+	if merged == nil {
+		merged = []string{}
+	}
+	for _, permission := range permissions {
+		if !slices.Contains(merged, permission) {
+			merged = append(merged, permission)
+		}
+	}
+	slices.Sort(merged)
+	return merged
+}
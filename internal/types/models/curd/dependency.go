@@ -0,0 +1,77 @@
+package curd
+
+import (
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"gorm.io/gorm"
+)
+
+// RecordPluginBundle replaces the plugins recorded for a bundle of the tenant
+func RecordPluginBundle(
+	tenantId string,
+	name string,
+	version string,
+	pluginIds []string,
+) error {
+	return db.WithTransaction(func(tx *gorm.DB) error {
+		bundle, err := db.GetOne[models.PluginBundle](
+			db.WithTransactionContext(tx),
+			db.Equal("tenant_id", tenantId),
+			db.Equal("name", name),
+			db.WLock(),
+		)
+
+		if err == db.ErrDatabaseNotFound {
+			return db.Create(&models.PluginBundle{
+				TenantID:  tenantId,
+				Name:      name,
+				Version:   version,
+				PluginIDs: pluginIds,
+			}, tx)
+		} else if err != nil {
+			return err
+		}
+
+		bundle.Version = version
+		bundle.PluginIDs = pluginIds
+		return db.Update(&bundle, tx)
+	})
+}
+
+// ObservePluginDependency records that a plugin invoked a provider, the last seen time is refreshed
+// if it has been recorded before
+func ObservePluginDependency(
+	tenantId string,
+	pluginId string,
+	invokeType string,
+	provider string,
+	seenAt time.Time,
+) error {
+	return db.WithTransaction(func(tx *gorm.DB) error {
+		dependency, err := db.GetOne[models.PluginDependency](
+			db.WithTransactionContext(tx),
+			db.Equal("tenant_id", tenantId),
+			db.Equal("plugin_id", pluginId),
+			db.Equal("invoke_type", invokeType),
+			db.Equal("provider", provider),
+			db.WLock(),
+		)
+
+		if err == db.ErrDatabaseNotFound {
+			return db.Create(&models.PluginDependency{
+				TenantID:   tenantId,
+				PluginID:   pluginId,
+				InvokeType: invokeType,
+				Provider:   provider,
+				LastSeenAt: seenAt,
+			}, tx)
+		} else if err != nil {
+			return err
+		}
+
+		dependency.LastSeenAt = seenAt
+		return db.Update(&dependency, tx)
+	})
+}
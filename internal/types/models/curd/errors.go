@@ -3,5 +3,9 @@ package curd
 import "errors"
 
 var (
-	ErrPluginAlreadyInstalled = errors.New("plugin already installed")
+	ErrPluginAlreadyInstalled        = errors.New("plugin already installed")
+	ErrPluginVersionAlreadyInstalled = errors.New("plugin version already installed")
+	ErrPluginVersionNotInstalled     = errors.New("plugin version has not been installed")
+	ErrPluginInstallApprovalNotFound = errors.New("plugin install approval not found")
+	ErrPluginInstallApprovalReviewed = errors.New("plugin install approval has been reviewed already")
 )
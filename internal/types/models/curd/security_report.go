@@ -0,0 +1,28 @@
+package curd
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"gorm.io/gorm"
+)
+
+// SavePluginSecurityReport replaces the report of the package with a new scan
+func SavePluginSecurityReport(report *models.PluginSecurityReport) error {
+	return db.WithTransaction(func(tx *gorm.DB) error {
+		existing, err := db.GetOne[models.PluginSecurityReport](
+			db.WithTransactionContext(tx),
+			db.Equal("plugin_unique_identifier", report.PluginUniqueIdentifier),
+			db.WLock(),
+		)
+
+		if err == db.ErrDatabaseNotFound {
+			return db.Create(report, tx)
+		} else if err != nil {
+			return err
+		}
+
+		report.ID = existing.ID
+		report.CreatedAt = existing.CreatedAt
+		return db.Update(report, tx)
+	})
+}
@@ -0,0 +1,46 @@
+package curd
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"gorm.io/gorm"
+)
+
+// SetTenantTimezone creates the settings of a tenant or replaces the timezone of existing ones
+func SetTenantTimezone(tenantId string, timezone string) (*models.TenantSetting, error) {
+	var setting models.TenantSetting
+	var created bool
+
+	upsert := func(tx *gorm.DB) error {
+		var err error
+		setting, err = db.GetOne[models.TenantSetting](
+			db.WithTransactionContext(tx),
+			db.Equal("tenant_id", tenantId),
+			db.WLock(),
+		)
+
+		created = err == db.ErrDatabaseNotFound
+		if created {
+			setting = models.TenantSetting{TenantID: tenantId}
+		} else if err != nil {
+			return err
+		}
+
+		setting.Timezone = timezone
+		if created {
+			return db.Create(&setting, tx)
+		}
+		return db.Update(&setting, tx)
+	}
+
+	// a concurrent set may create the row first, the unique index of tenant_id refuses the second one
+	err := db.WithTransaction(upsert)
+	if err != nil && created {
+		err = db.WithTransaction(upsert)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &setting, nil
+}
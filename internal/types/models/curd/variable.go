@@ -0,0 +1,70 @@
+package curd
+
+import (
+	"github.com/google/uuid"
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"gorm.io/gorm"
+)
+
+// UpsertTenantVariable creates the variable of a tenant or replaces the value of an existing one, value
+// gets the id of the row so the value can be bound to it
+func UpsertTenantVariable(
+	tenantId string,
+	name string,
+	secret bool,
+	value func(id string) (string, error),
+) (*models.TenantVariable, error) {
+	var variable models.TenantVariable
+	var created bool
+
+	upsert := func(tx *gorm.DB) error {
+		var err error
+		variable, err = db.GetOne[models.TenantVariable](
+			db.WithTransactionContext(tx),
+			db.Equal("tenant_id", tenantId),
+			db.Equal("name", name),
+			db.WLock(),
+		)
+
+		created = err == db.ErrDatabaseNotFound
+		if created {
+			variable = models.TenantVariable{
+				Model:    models.Model{ID: uuid.New().String()},
+				TenantID: tenantId,
+				Name:     name,
+			}
+		} else if err != nil {
+			return err
+		}
+
+		variable.Value, err = value(variable.ID)
+		if err != nil {
+			return err
+		}
+		variable.Secret = secret
+		if created {
+			return db.Create(&variable, tx)
+		}
+		return db.Update(&variable, tx)
+	}
+
+	// a concurrent set of the same name may create it first, idx_tenant_variable refuses the second
+	// row and the value is written to the existing one instead
+	err := db.WithTransaction(upsert)
+	if err != nil && created {
+		err = db.WithTransaction(upsert)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &variable, nil
+}
+
+func DeleteTenantVariable(tenantId string, name string) error {
+	return db.DeleteByCondition(models.TenantVariable{
+		TenantID: tenantId,
+		Name:     name,
+	})
+}
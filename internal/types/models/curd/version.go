@@ -0,0 +1,143 @@
+package curd
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"gorm.io/gorm"
+)
+
+// InstallPluginVersion adds a version of a plugin to the tenant besides its installation, the plugin is
+// created if it has never been created before
+func InstallPluginVersion(
+	tenantId string,
+	pluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier,
+	installType plugin_entities.PluginRuntimeType,
+	declaration *plugin_entities.PluginDeclaration,
+	source string,
+	meta map[string]any,
+) (*models.PluginVersionInstallation, error) {
+	var versionInstallation *models.PluginVersionInstallation
+
+	err := db.WithTransaction(func(tx *gorm.DB) error {
+		_, err := db.GetOne[models.PluginVersionInstallation](
+			db.WithTransactionContext(tx),
+			db.Equal("tenant_id", tenantId),
+			db.Equal("plugin_id", pluginUniqueIdentifier.PluginID()),
+			db.Equal("version", pluginUniqueIdentifier.Version().String()),
+			db.WLock(),
+		)
+		if err == nil {
+			return ErrPluginVersionAlreadyInstalled
+		} else if err != db.ErrDatabaseNotFound {
+			return err
+		}
+
+		plugin, err := db.GetOne[models.Plugin](
+			db.WithTransactionContext(tx),
+			db.Equal("plugin_unique_identifier", pluginUniqueIdentifier.String()),
+			db.Equal("plugin_id", pluginUniqueIdentifier.PluginID()),
+			db.Equal("install_type", string(installType)),
+			db.WLock(),
+		)
+		if err == db.ErrDatabaseNotFound {
+			plugin = models.Plugin{
+				PluginID:               pluginUniqueIdentifier.PluginID(),
+				PluginUniqueIdentifier: pluginUniqueIdentifier.String(),
+				InstallType:            installType,
+				Refers:                 1,
+			}
+			if installType == plugin_entities.PLUGIN_RUNTIME_TYPE_REMOTE {
+				plugin.RemoteDeclaration = *declaration
+			}
+			if err := db.Create(&plugin, tx); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		} else {
+			plugin.Refers++
+			if err := db.Update(&plugin, tx); err != nil {
+				return err
+			}
+		}
+
+		versionInstallation = &models.PluginVersionInstallation{
+			TenantID:               tenantId,
+			PluginID:               plugin.PluginID,
+			Version:                pluginUniqueIdentifier.Version().String(),
+			PluginUniqueIdentifier: plugin.PluginUniqueIdentifier,
+			RuntimeType:            string(installType),
+			Source:                 source,
+			Meta:                   meta,
+		}
+		return db.Create(versionInstallation, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return versionInstallation, nil
+}
+
+type UninstallPluginVersionResponse struct {
+	Plugin              *models.Plugin
+	VersionInstallation *models.PluginVersionInstallation
+
+	// whether the refers of the plugin has been decreased to 0
+	IsPluginDeleted bool
+}
+
+// UninstallPluginVersion removes a version of a plugin from the tenant, the plugin is deleted once
+// nothing refers to it
+func UninstallPluginVersion(
+	tenantId string,
+	pluginId string,
+	version string,
+) (*UninstallPluginVersionResponse, error) {
+	var response UninstallPluginVersionResponse
+
+	err := db.WithTransaction(func(tx *gorm.DB) error {
+		versionInstallation, err := db.GetOne[models.PluginVersionInstallation](
+			db.WithTransactionContext(tx),
+			db.Equal("tenant_id", tenantId),
+			db.Equal("plugin_id", pluginId),
+			db.Equal("version", version),
+			db.WLock(),
+		)
+		if err == db.ErrDatabaseNotFound {
+			return ErrPluginVersionNotInstalled
+		} else if err != nil {
+			return err
+		}
+		if err := db.Delete(&versionInstallation, tx); err != nil {
+			return err
+		}
+		response.VersionInstallation = &versionInstallation
+
+		plugin, err := db.GetOne[models.Plugin](
+			db.WithTransactionContext(tx),
+			db.Equal("plugin_unique_identifier", versionInstallation.PluginUniqueIdentifier),
+			db.WLock(),
+		)
+		if err == db.ErrDatabaseNotFound {
+			// nothing refers to it anymore
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		plugin.Refers--
+		response.Plugin = &plugin
+		if plugin.Refers <= 0 {
+			response.IsPluginDeleted = true
+			return db.Delete(&plugin, tx)
+		}
+		return db.Update(&plugin, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// PluginBundle records the plugins a tenant received through a bundle, plugins from github
+// dependencies are resolved at install time and not recorded
+type PluginBundle struct {
+	Model
+	TenantID  string   `json:"tenant_id" gorm:"index;type:uuid;"`
+	Name      string   `json:"name" gorm:"index;size:255"`
+	Version   string   `json:"version" gorm:"size:127"`
+	PluginIDs []string `json:"plugin_ids" gorm:"column:plugin_ids;serializer:json"`
+}
+
+// PluginDependency is a provider a plugin has been observed invoking through backwards invocation
+type PluginDependency struct {
+	Model
+	TenantID   string    `json:"tenant_id" gorm:"index;type:uuid;"`
+	PluginID   string    `json:"plugin_id" gorm:"index;size:255"`
+	InvokeType string    `json:"invoke_type" gorm:"size:63"`
+	Provider   string    `json:"provider" gorm:"size:255"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
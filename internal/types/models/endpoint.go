@@ -3,6 +3,7 @@ package models
 import (
 	"time"
 
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/endpoint_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
 )
 
@@ -17,5 +18,17 @@ type Endpoint struct {
 	ExpiredAt   time.Time                                    `json:"expired_at" gorm:"column:expired_at"`
 	Enabled     bool                                         `json:"enabled" gorm:"column:enabled"`
 	Settings    map[string]any                               `json:"settings" gorm:"column:settings;serializer:json"`
+	Middlewares []endpoint_entities.EndpointMiddleware       `json:"middlewares" gorm:"column:middlewares;serializer:json"`
 	Declaration *plugin_entities.EndpointProviderDeclaration `json:"declaration" gorm:"-"` // not stored in db
 }
+
+// EndpointDomain exposes an endpoint on a custom hostname, requests whose path starts with PathPrefix
+// are routed to the endpoint with the prefix removed
+type EndpointDomain struct {
+	Model
+	TenantID   string `json:"tenant_id" gorm:"index;size:64;column:tenant_id"`
+	EndpointID string `json:"endpoint_id" gorm:"index;size:64;column:endpoint_id"`
+	HookID     string `json:"hook_id" gorm:"size:127;column:hook_id"`
+	Hostname   string `json:"hostname" gorm:"size:255;column:hostname;not null;uniqueIndex:idx_endpoint_domain"`
+	PathPrefix string `json:"path_prefix" gorm:"size:255;column:path_prefix;not null;default:'';uniqueIndex:idx_endpoint_domain"`
+}
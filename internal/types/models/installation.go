@@ -13,3 +13,17 @@ type PluginInstallation struct {
 	Source                 string         `json:"source" gorm:"column:source;size:63"`
 	Meta                   map[string]any `json:"meta" gorm:"column:meta;serializer:json"`
 }
+
+// PluginVersionInstallation is a version of a plugin installed by the tenant besides the one of its
+// PluginInstallation, invocations pin it by qualifying the plugin id with the version like author/name:1.0.0
+// and it refers to the plugin like an installation
+type PluginVersionInstallation struct {
+	Model
+	TenantID               string         `json:"tenant_id" gorm:"uniqueIndex:idx_plugin_version_installation;type:uuid"`
+	PluginID               string         `json:"plugin_id" gorm:"uniqueIndex:idx_plugin_version_installation;size:255"`
+	Version                string         `json:"version" gorm:"uniqueIndex:idx_plugin_version_installation;size:128"`
+	PluginUniqueIdentifier string         `json:"plugin_unique_identifier" gorm:"index;size:255"`
+	RuntimeType            string         `json:"runtime_type" gorm:"size:127"`
+	Source                 string         `json:"source" gorm:"column:source;size:63"`
+	Meta                   map[string]any `json:"meta" gorm:"column:meta;serializer:json"`
+}
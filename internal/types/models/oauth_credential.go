@@ -0,0 +1,16 @@
+package models
+
+// OAuthCredential keeps what a tool provider of a plugin got from an OAuth2 authorization of a
+// tenant, credentials hold access and refresh tokens and are encrypted by the daemon, so are the
+// system credentials like client_id and client_secret which are needed to refresh them
+type OAuthCredential struct {
+	Model
+	TenantID          string `json:"tenant_id" gorm:"column:tenant_id;type:varchar(255);not null;uniqueIndex:idx_oauth_credential"`
+	PluginID          string `json:"plugin_id" gorm:"column:plugin_id;type:varchar(255);not null;uniqueIndex:idx_oauth_credential"`
+	Provider          string `json:"provider" gorm:"column:provider;type:varchar(255);not null;uniqueIndex:idx_oauth_credential"`
+	RedirectURI       string `json:"redirect_uri" gorm:"column:redirect_uri;type:text"`
+	SystemCredentials string `json:"-" gorm:"column:system_credentials;type:text"`
+	Credentials       string `json:"-" gorm:"column:credentials;type:text"`
+	// ExpiresAt is the unix timestamp the access token expires at, -1 if it never does
+	ExpiresAt int64 `json:"expires_at" gorm:"column:expires_at;not null;default:-1"`
+}
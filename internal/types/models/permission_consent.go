@@ -0,0 +1,17 @@
+package models
+
+import "slices"
+
+// PluginPermissionConsent records the permissions of a plugin a tenant has consented to
+type PluginPermissionConsent struct {
+	Model
+	TenantID    string   `json:"tenant_id" gorm:"index;type:uuid;"`
+	PluginID    string   `json:"plugin_id" gorm:"index;size:255"`
+	Permissions []string `json:"permissions" gorm:"column:permissions;serializer:json"`
+}
+
+// Allows reports whether permission is consented, installations made before consent
+// was recorded have no consent record and keep the permissions they were granted
+func (c *PluginPermissionConsent) Allows(permission string) bool {
+	return c.ID == "" || slices.Contains(c.Permissions, permission)
+}
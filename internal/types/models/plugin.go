@@ -1,8 +1,11 @@
 package models
 
 import (
+	"strings"
+
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/manifest_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"gorm.io/gorm"
 )
 
 type Plugin struct {
@@ -20,7 +23,11 @@ type Plugin struct {
 type ServerlessRuntimeType string
 
 const (
-	SERVERLESS_RUNTIME_TYPE_SERVERLESS ServerlessRuntimeType = "serverless"
+	SERVERLESS_RUNTIME_TYPE_SERVERLESS      ServerlessRuntimeType = "serverless"
+	SERVERLESS_RUNTIME_TYPE_CLOUD_RUN       ServerlessRuntimeType = "cloud_run"
+	SERVERLESS_RUNTIME_TYPE_AZURE_FUNCTIONS ServerlessRuntimeType = "azure_functions"
+	SERVERLESS_RUNTIME_TYPE_KNATIVE         ServerlessRuntimeType = "knative"
+	SERVERLESS_RUNTIME_TYPE_OPENFAAS        ServerlessRuntimeType = "openfaas"
 )
 
 type ServerlessRuntime struct {
@@ -37,4 +44,26 @@ type PluginDeclaration struct {
 	PluginUniqueIdentifier string                            `json:"plugin_unique_identifier" gorm:"size:255;unique"`
 	PluginID               string                            `json:"plugin_id" gorm:"size:255;index"`
 	Declaration            plugin_entities.PluginDeclaration `json:"declaration" gorm:"serializer:json;type:text;size:65535"`
+	// Category and ModelTypes are derived from the declaration to filter installations in sql, model types
+	// are stored as ",llm,tts,"
+	Category   string `json:"-" gorm:"size:32;index"`
+	ModelTypes string `json:"-" gorm:"size:255"`
+}
+
+// DeriveColumns fills the columns derived from the declaration
+func (p *PluginDeclaration) DeriveColumns() {
+	p.Category = string(p.Declaration.Category())
+	p.ModelTypes = ""
+	if p.Declaration.Model != nil && len(p.Declaration.Model.SupportedModelTypes) != 0 {
+		types := make([]string, 0, len(p.Declaration.Model.SupportedModelTypes))
+		for _, modelType := range p.Declaration.Model.SupportedModelTypes {
+			types = append(types, string(modelType))
+		}
+		p.ModelTypes = "," + strings.Join(types, ",") + ","
+	}
+}
+
+func (p *PluginDeclaration) BeforeSave(tx *gorm.DB) error {
+	p.DeriveColumns()
+	return nil
 }
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/manifest_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// RegistryPlugin is a version of a plugin published to the private registry, the organization is the
+// author of the plugin and published versions can't be replaced
+type RegistryPlugin struct {
+	Model
+	Organization           string                        `json:"organization" gorm:"uniqueIndex:idx_registry_plugin_version;size:64"`
+	Name                   string                        `json:"name" gorm:"uniqueIndex:idx_registry_plugin_version;size:127"`
+	Version                string                        `json:"version" gorm:"uniqueIndex:idx_registry_plugin_version;size:128"`
+	PluginUniqueIdentifier string                        `json:"plugin_unique_identifier" gorm:"uniqueIndex;size:255"`
+	Label                  plugin_entities.I18nObject    `json:"label" gorm:"serializer:json"`
+	Description            plugin_entities.I18nObject    `json:"description" gorm:"serializer:json"`
+	Tags                   []manifest_entities.PluginTag `json:"tags" gorm:"serializer:json"`
+	Size                   int64                         `json:"size"`
+	Verified               bool                          `json:"verified"`
+	// Latest marks the newest version of the plugin, searches only go through those
+	Latest bool `json:"latest" gorm:"index"`
+	// Keywords is the lowercase name, labels, descriptions and tags matched by searches
+	Keywords string `json:"-" gorm:"type:text"`
+}
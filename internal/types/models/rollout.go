@@ -0,0 +1,36 @@
+package models
+
+type PluginRolloutStatus string
+
+const (
+	// the canary is being launched, nothing is routed to it yet
+	PluginRolloutStatusPending    PluginRolloutStatus = "pending"
+	PluginRolloutStatusActive     PluginRolloutStatus = "active"
+	PluginRolloutStatusPromoted   PluginRolloutStatus = "promoted"
+	PluginRolloutStatusRolledBack PluginRolloutStatus = "rolled_back"
+)
+
+// PluginRollout routes a part of the invocations of tenants running StablePluginUniqueIdentifier to
+// CanaryPluginUniqueIdentifier, counts are summed up from all nodes of the cluster
+type PluginRollout struct {
+	Model
+	PluginID                     string              `json:"plugin_id" gorm:"index;size:255"`
+	StablePluginUniqueIdentifier string              `json:"stable_plugin_unique_identifier" gorm:"size:255"`
+	CanaryPluginUniqueIdentifier string              `json:"canary_plugin_unique_identifier" gorm:"size:255"`
+	Status                       PluginRolloutStatus `json:"status" gorm:"size:32;index"`
+	Reason                       string              `json:"reason" gorm:"size:1023"`
+
+	// invocations of Tenants always run on the canary, Percentage of the others do
+	Percentage int      `json:"percentage"`
+	Tenants    []string `json:"tenants" gorm:"serializer:json"`
+
+	// the canary is promoted once it ran MinInvocations without exceeding the error rate of the stable
+	// version by more than MaxErrorRateIncrease, it's rolled back otherwise, 0 means manual decision only
+	MinInvocations       int64   `json:"min_invocations"`
+	MaxErrorRateIncrease float64 `json:"max_error_rate_increase"`
+
+	StableInvocations int64 `json:"stable_invocations"`
+	StableErrors      int64 `json:"stable_errors"`
+	CanaryInvocations int64 `json:"canary_invocations"`
+	CanaryErrors      int64 `json:"canary_errors"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// PluginScheduledUpgrade is an upgrade of an installation of a tenant which starts at RunAt, the next
+// activation of Schedule in the timezone of the tenant when it was created
+type PluginScheduledUpgrade struct {
+	Model
+	TenantID                       string         `json:"tenant_id" gorm:"index;type:uuid"`
+	OriginalPluginUniqueIdentifier string         `json:"original_plugin_unique_identifier" gorm:"size:255"`
+	NewPluginUniqueIdentifier      string         `json:"new_plugin_unique_identifier" gorm:"size:255"`
+	Source                         string         `json:"source" gorm:"size:64"`
+	Meta                           map[string]any `json:"meta" gorm:"serializer:json"`
+	Schedule                       string         `json:"schedule" gorm:"size:255"`
+	RunAt                          time.Time      `json:"run_at" gorm:"index"`
+}
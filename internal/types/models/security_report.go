@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// PluginSecurityReport is the latest dependency scan of a package, packages are scanned when they are uploaded
+type PluginSecurityReport struct {
+	Model
+	PluginUniqueIdentifier string                `json:"plugin_unique_identifier" gorm:"uniqueIndex;size:255"`
+	Scanner                string                `json:"scanner" gorm:"size:32"`
+	Dependencies           int                   `json:"dependencies"`
+	Unpinned               []string              `json:"unpinned" gorm:"column:unpinned;serializer:json"`
+	Vulnerabilities        []PluginVulnerability `json:"vulnerabilities" gorm:"column:vulnerabilities;serializer:json"`
+	Blocked                bool                  `json:"blocked"`
+	// set if the advisory database was unavailable and the package was accepted anyway
+	Error     string    `json:"error" gorm:"type:text"`
+	ScannedAt time.Time `json:"scanned_at"`
+}
+
+type PluginVulnerability struct {
+	ID        string   `json:"id"`
+	Aliases   []string `json:"aliases"`
+	Summary   string   `json:"summary"`
+	Severity  string   `json:"severity"`
+	Ecosystem string   `json:"ecosystem"`
+	Package   string   `json:"package"`
+	Version   string   `json:"version"`
+}
@@ -2,10 +2,31 @@ package models
 
 import "time"
 
+// PluginInvocation counts invocations of a plugin on a node within the minute of Timestamp,
+// each flush of a node is a new row
 type PluginInvocation struct {
 	Model
 	PluginUniqueIdentifier string    `json:"plugin_unique_identifier" gorm:"index;size:255"`
 	PluginID               string    `json:"plugin_id" gorm:"index;size:255"`
 	InvocationCount        int       `json:"invocation_count" gorm:"default:0"`
+	ErrorCount             int       `json:"error_count" gorm:"default:0"`
 	Timestamp              time.Time `json:"timestamp" gorm:"index"`
 }
+
+// PluginInvocationRollup aggregates the invocations of a plugin over an hour or a day,
+// rollups are recomputed from their source instead of incremented so that reruns are harmless
+type PluginInvocationRollup struct {
+	Model
+	Granularity     string    `json:"granularity" gorm:"uniqueIndex:idx_plugin_invocation_rollup_period;size:8"`
+	PluginID        string    `json:"plugin_id" gorm:"uniqueIndex:idx_plugin_invocation_rollup_period;size:255"`
+	PeriodStart     time.Time `json:"period_start" gorm:"uniqueIndex:idx_plugin_invocation_rollup_period"`
+	InvocationCount int64     `json:"invocation_count" gorm:"default:0"`
+	ErrorCount      int64     `json:"error_count" gorm:"default:0"`
+}
+
+// AnalyticsWatermark is the time up to which a rollup job has processed its source
+type AnalyticsWatermark struct {
+	Model
+	Job       string    `json:"job" gorm:"uniqueIndex;size:64"`
+	Watermark time.Time `json:"watermark"`
+}
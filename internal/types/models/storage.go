@@ -1,8 +1,22 @@
 package models
 
+import "time"
+
 type TenantStorage struct {
 	Model
 	TenantID string `gorm:"column:tenant_id;type:varchar(255);not null;index"`
 	PluginID string `gorm:"column:plugin_id;type:varchar(255);not null;index"`
 	Size     int64  `gorm:"column:size;type:bigint;not null"`
 }
+
+// PersistenceKey records a key a plugin stored on behalf of a tenant, keys stored before
+// it was introduced have no record until they are written again
+type PersistenceKey struct {
+	Model
+	TenantID   string `gorm:"column:tenant_id;type:varchar(255);not null;uniqueIndex:idx_persistence_key"`
+	PluginID   string `gorm:"column:plugin_id;type:varchar(255);not null;uniqueIndex:idx_persistence_key"`
+	StorageKey string `gorm:"column:storage_key;type:varchar(256);not null;uniqueIndex:idx_persistence_key"`
+	Size       int64  `gorm:"column:size;type:bigint;not null"`
+	// ExpiresAt is nil for keys kept until they are deleted
+	ExpiresAt *time.Time `gorm:"column:expires_at;index"`
+}
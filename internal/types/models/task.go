@@ -11,6 +11,15 @@ const (
 	InstallTaskStatusFailed  InstallTaskStatus = "failed"
 )
 
+type InstallTaskAction string
+
+const (
+	InstallTaskActionInstall InstallTaskAction = "install"
+	InstallTaskActionUpgrade InstallTaskAction = "upgrade"
+	// installs a version besides the installed one, see PluginVersionInstallation
+	InstallTaskActionInstallVersion InstallTaskAction = "install_version"
+)
+
 type InstallTaskPluginStatus struct {
 	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier"`
 	Labels                 plugin_entities.I18nObject             `json:"labels"`
@@ -19,6 +28,10 @@ type InstallTaskPluginStatus struct {
 	PluginID               string                                 `json:"plugin_id"`
 	Status                 InstallTaskStatus                      `json:"status"`
 	Message                string                                 `json:"message"`
+	Attempts               int                                    `json:"attempts"`
+	Meta                   map[string]any                         `json:"meta"`
+	// DependsOn are the plugins of the same task installed before this one
+	DependsOn []plugin_entities.PluginUniqueIdentifier `json:"depends_on,omitempty"`
 }
 
 type InstallTask struct {
@@ -28,4 +41,9 @@ type InstallTask struct {
 	TotalPlugins     int                       `json:"total_plugins" gorm:"not null"`
 	CompletedPlugins int                       `json:"completed_plugins" gorm:"not null"`
 	Plugins          []InstallTaskPluginStatus `json:"plugins" gorm:"serializer:json"`
+
+	// everything needed to resume the task after a restart
+	Action                         InstallTaskAction `json:"action" gorm:"size:32"`
+	Source                         string            `json:"source" gorm:"size:64"`
+	OriginalPluginUniqueIdentifier string            `json:"original_plugin_unique_identifier" gorm:"size:255"`
 }
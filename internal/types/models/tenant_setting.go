@@ -0,0 +1,9 @@
+package models
+
+// TenantSetting holds the preferences of a tenant, Timezone is the timezone of schedules the tenant
+// creates without a CRON_TZ prefix, SCHEDULE_TIMEZONE of the daemon is used if it's empty
+type TenantSetting struct {
+	Model
+	TenantID string `json:"tenant_id" gorm:"uniqueIndex;type:uuid"`
+	Timezone string `json:"timezone" gorm:"size:64"`
+}
@@ -0,0 +1,11 @@
+package models
+
+// TenantVariable is referenced as {{env.NAME}} or {{secret.NAME}} in endpoint settings and credentials,
+// values of secrets are encrypted by the daemon
+type TenantVariable struct {
+	Model
+	TenantID string `json:"tenant_id" gorm:"uniqueIndex:idx_tenant_variable;type:uuid;"`
+	Name     string `json:"name" gorm:"uniqueIndex:idx_tenant_variable;size:127"`
+	Value    string `json:"value" gorm:"type:text"`
+	Secret   bool   `json:"secret"`
+}
@@ -0,0 +1,282 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/redis/go-redis/v9"
+)
+
+// Client is the storage behind the package level functions, keys are already serialized.
+// Implementations return redis.Nil for missing keys like the redis client does
+type Client interface {
+	Get(key string) (string, error)
+	Set(key string, value any, expire time.Duration) error
+	SetNX(key string, value any, expire time.Duration) (bool, error)
+	Del(key string) (int64, error)
+	Exists(key string) (int64, error)
+	Incr(key string) (int64, error)
+	Decr(key string) (int64, error)
+	Expire(key string, expire time.Duration) (bool, error)
+	// CompareAndExpire and CompareAndDel only apply if key holds value, atomically
+	CompareAndExpire(key string, value any, expire time.Duration) (bool, error)
+	CompareAndDel(key string, value any) (bool, error)
+	// TakeTokens takes a token from each of the buckets only if all of them have one, atomically
+	TakeTokens(limits []TokenBucketLimit) ([]TokenBucket, error)
+
+	HSet(key string, values map[string]any) error
+	HGet(key string, field string) (string, error)
+	HDel(key string, field string) error
+	HGetAll(key string) (map[string]string, error)
+
+	// Scan and HScan follow the cursor semantics of redis SCAN and HSCAN
+	Scan(cursor uint64, match string, count int64) ([]string, uint64, error)
+	HScan(key string, cursor uint64, match string, count int64) ([]string, uint64, error)
+
+	Publish(channel string, message any) error
+	// Subscribe returns the payloads published to channel, it returns after the subscription is established
+	Subscribe(channel string) (<-chan string, func())
+
+	Transaction(fn func(redis.Pipeliner) error) error
+	Close() error
+}
+
+// TokenBucketLimit is a bucket at Key refilled with Rate tokens per second up to Burst
+type TokenBucketLimit struct {
+	Key   string
+	Rate  float64
+	Burst int64
+}
+
+// TokenBucket is the state of a bucket after taking a token from it
+type TokenBucket struct {
+	// Allowed reports whether the bucket had a token, TakeTokens only takes them if all buckets had one
+	Allowed   bool
+	Remaining int64
+	// RetryAfter is the time until the next token if none was taken
+	RetryAfter time.Duration
+	// Reset is the time until the bucket is full
+	Reset time.Duration
+}
+
+// redisClient adapts a redis connection, cmd is either the connection itself or a pipeline
+type redisClient struct {
+	cmd       redis.Cmdable
+	universal redis.UniversalClient
+}
+
+func newRedisClient(universal redis.UniversalClient) *redisClient {
+	return &redisClient{
+		cmd:       universal,
+		universal: universal,
+	}
+}
+
+func (r *redisClient) Get(key string) (string, error) {
+	return r.cmd.Get(ctx, key).Result()
+}
+
+func (r *redisClient) Set(key string, value any, expire time.Duration) error {
+	return r.cmd.Set(ctx, key, value, expire).Err()
+}
+
+func (r *redisClient) SetNX(key string, value any, expire time.Duration) (bool, error) {
+	return r.cmd.SetNX(ctx, key, value, expire).Result()
+}
+
+func (r *redisClient) Del(key string) (int64, error) {
+	return r.cmd.Del(ctx, key).Result()
+}
+
+func (r *redisClient) Exists(key string) (int64, error) {
+	return r.cmd.Exists(ctx, key).Result()
+}
+
+func (r *redisClient) Incr(key string) (int64, error) {
+	return r.cmd.Incr(ctx, key).Result()
+}
+
+func (r *redisClient) Decr(key string) (int64, error) {
+	return r.cmd.Decr(ctx, key).Result()
+}
+
+func (r *redisClient) Expire(key string, expire time.Duration) (bool, error) {
+	return r.cmd.Expire(ctx, key, expire).Result()
+}
+
+var (
+	compareAndExpireScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	compareAndDelScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+	// the clock of redis is shared by all nodes, the bucket expires once it's full again
+	// ARGV holds the rate and burst of each key, the buckets are refilled first and tokens are only
+	// taken if every bucket has one
+	takeTokensScript = redis.NewScript(`
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local refilled = {}
+local allowed = true
+for i, key in ipairs(KEYS) do
+	local rate = tonumber(ARGV[i * 2 - 1])
+	local burst = tonumber(ARGV[i * 2])
+	local bucket = redis.call("HMGET", key, "tokens", "ts")
+	local tokens = tonumber(bucket[1]) or burst
+	local ts = tonumber(bucket[2]) or now
+	refilled[i] = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+	if refilled[i] < 1 then
+		allowed = false
+	end
+end
+local result = {}
+for i, key in ipairs(KEYS) do
+	local rate = tonumber(ARGV[i * 2 - 1])
+	local burst = tonumber(ARGV[i * 2])
+	local tokens = refilled[i]
+	local available = 0
+	local retry = 0
+	if tokens >= 1 then
+		available = 1
+	else
+		retry = math.ceil((1 - tokens) * 1000 / rate)
+	end
+	if allowed then
+		tokens = tokens - 1
+	end
+	local reset = math.ceil((burst - tokens) * 1000 / rate)
+	redis.call("HSET", key, "tokens", tostring(tokens), "ts", now)
+	redis.call("PEXPIRE", key, math.max(reset, 1))
+	table.insert(result, available)
+	table.insert(result, math.floor(tokens))
+	table.insert(result, retry)
+	table.insert(result, reset)
+end
+return result`)
+)
+
+func (r *redisClient) CompareAndExpire(key string, value any, expire time.Duration) (bool, error) {
+	result, err := compareAndExpireScript.Run(ctx, r.cmd, []string{key}, value, expire.Milliseconds()).Int64()
+	return result == 1, err
+}
+
+func (r *redisClient) CompareAndDel(key string, value any) (bool, error) {
+	result, err := compareAndDelScript.Run(ctx, r.cmd, []string{key}, value).Int64()
+	return result == 1, err
+}
+
+func (r *redisClient) TakeTokens(limits []TokenBucketLimit) ([]TokenBucket, error) {
+	keys := make([]string, 0, len(limits))
+	args := make([]any, 0, len(limits)*2)
+	for _, limit := range limits {
+		keys = append(keys, limit.Key)
+		args = append(args, limit.Rate, limit.Burst)
+	}
+
+	result, err := takeTokensScript.Run(ctx, r.cmd, keys, args...).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+	if len(result) != len(limits)*4 {
+		return nil, fmt.Errorf("unexpected result of token buckets: %v", result)
+	}
+
+	buckets := make([]TokenBucket, 0, len(limits))
+	for i := 0; i < len(result); i += 4 {
+		buckets = append(buckets, TokenBucket{
+			Allowed:    result[i] == 1,
+			Remaining:  result[i+1],
+			RetryAfter: time.Duration(result[i+2]) * time.Millisecond,
+			Reset:      time.Duration(result[i+3]) * time.Millisecond,
+		})
+	}
+	return buckets, nil
+}
+
+func (r *redisClient) HSet(key string, values map[string]any) error {
+	return r.cmd.HMSet(ctx, key, values).Err()
+}
+
+func (r *redisClient) HGet(key string, field string) (string, error) {
+	return r.cmd.HGet(ctx, key, field).Result()
+}
+
+func (r *redisClient) HDel(key string, field string) error {
+	return r.cmd.HDel(ctx, key, field).Err()
+}
+
+func (r *redisClient) HGetAll(key string) (map[string]string, error) {
+	return r.cmd.HGetAll(ctx, key).Result()
+}
+
+func (r *redisClient) Scan(cursor uint64, match string, count int64) ([]string, uint64, error) {
+	return r.cmd.Scan(ctx, cursor, match, count).Result()
+}
+
+func (r *redisClient) HScan(key string, cursor uint64, match string, count int64) ([]string, uint64, error) {
+	return r.cmd.HScan(ctx, key, cursor, match, count).Result()
+}
+
+func (r *redisClient) Publish(channel string, message any) error {
+	return r.cmd.Publish(ctx, channel, message).Err()
+}
+
+func (r *redisClient) Subscribe(channel string) (<-chan string, func()) {
+	pubsub := r.universal.Subscribe(ctx, channel)
+	ch := make(chan string)
+	connectionEstablished := make(chan bool)
+
+	go func() {
+		defer close(ch)
+		defer close(connectionEstablished)
+
+		alive := true
+		for alive {
+			iface, err := pubsub.Receive(context.Background())
+			if err != nil {
+				log.Error("failed to receive message from redis: %s, will retry in 1 second", err.Error())
+				time.Sleep(1 * time.Second)
+				continue
+			}
+			switch data := iface.(type) {
+			case *redis.Subscription:
+				connectionEstablished <- true
+			case *redis.Message:
+				ch <- data.Payload
+			case *redis.Pong:
+			default:
+				alive = false
+			}
+		}
+	}()
+
+	// wait for the connection to be established
+	<-connectionEstablished
+
+	return ch, func() {
+		pubsub.Close()
+	}
+}
+
+func (r *redisClient) Transaction(fn func(redis.Pipeliner) error) error {
+	return r.universal.Watch(ctx, func(tx *redis.Tx) error {
+		_, err := tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			return fn(p)
+		})
+		if err == redis.Nil {
+			return nil
+		}
+		return err
+	})
+}
+
+func (r *redisClient) Close() error {
+	return r.universal.Close()
+}
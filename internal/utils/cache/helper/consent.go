@@ -0,0 +1,32 @@
+package helper
+
+import (
+	"github.com/langgenius/dify-plugin-daemon/internal/db"
+	"github.com/langgenius/dify-plugin-daemon/internal/types/models"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/cache"
+)
+
+// GetPluginPermissionConsent returns the consent of a tenant to a plugin, a consent without ID
+// is returned if nothing was recorded, so that the lookup is cached either way
+func GetPluginPermissionConsent(tenantId, pluginId string) (*models.PluginPermissionConsent, error) {
+	return cache.AutoGetWithGetter(
+		PluginPermissionConsentCacheKey(pluginId, tenantId),
+		func() (*models.PluginPermissionConsent, error) {
+			consent, err := db.GetOne[models.PluginPermissionConsent](
+				db.Equal("tenant_id", tenantId),
+				db.Equal("plugin_id", pluginId),
+			)
+			if err == db.ErrDatabaseNotFound {
+				return &models.PluginPermissionConsent{}, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			return &consent, nil
+		},
+	)
+}
+
+func InvalidatePluginPermissionConsent(tenantId, pluginId string) {
+	_, _ = cache.AutoDelete[models.PluginPermissionConsent](PluginPermissionConsentCacheKey(pluginId, tenantId))
+}
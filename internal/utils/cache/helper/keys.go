@@ -14,6 +14,20 @@ func PluginInstallationCacheKey(pluginId, tenantId string) string {
 	)
 }
 
+func PluginVersionInstallationCacheKey(pluginId, version, tenantId string) string {
+	return strings.Join(
+		[]string{
+			"plugin_id",
+			pluginId,
+			"version",
+			version,
+			"tenant_id",
+			tenantId,
+		},
+		":",
+	)
+}
+
 func EndpointCacheKey(hookId string) string {
 	return strings.Join(
 		[]string{
@@ -22,4 +36,16 @@ func EndpointCacheKey(hookId string) string {
 		},
 		":",
 	)
-}
\ No newline at end of file
+}
+func PluginPermissionConsentCacheKey(pluginId, tenantId string) string {
+	return strings.Join(
+		[]string{
+			"permission_consent",
+			"plugin_id",
+			pluginId,
+			"tenant_id",
+			tenantId,
+		},
+		":",
+	)
+}
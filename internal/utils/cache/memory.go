@@ -0,0 +1,680 @@
+package cache
+
+import (
+	"container/list"
+	"encoding"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	MEMORY_SUBSCRIPTION_BUFFER_SIZE = 1024
+	MEMORY_JANITOR_INTERVAL         = time.Minute
+)
+
+var (
+	errWrongType  = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	errNotInteger = errors.New("ERR value is not an integer or out of range")
+	errCacheFull  = errors.New("OOM command not allowed, the memory cache is full and no volatile key can be evicted")
+)
+
+type memoryEntry struct {
+	key      string
+	value    string
+	hash     map[string]string
+	expireAt time.Time
+	// lock is set for keys written by SetNX, they hold locks and ownerships which must never be evicted
+	lock bool
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expireAt.IsZero() && !now.Before(e.expireAt)
+}
+
+// memoryClient keeps everything in process with redis semantics for the commands the daemon uses,
+// once maxKeys is reached keys are evicted like redis volatile-lru, see insert
+type memoryClient struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	maxKeys int
+
+	subscribersLock sync.RWMutex
+	subscribers     map[string]map[chan string]struct{}
+
+	// transactions are serialized, their commands are queued and applied once they succeeded
+	txLock sync.Mutex
+	tx     *redis.Client
+
+	stop chan bool
+}
+
+// InitMemoryClient replaces redis with an in-process cache, it only works for a single node
+// as nothing is shared between processes, maxKeys <= 0 means unlimited
+func InitMemoryClient(maxKeys int) error {
+	m := &memoryClient{
+		entries:     map[string]*list.Element{},
+		lru:         list.New(),
+		maxKeys:     maxKeys,
+		subscribers: map[string]map[chan string]struct{}{},
+		stop:        make(chan bool),
+	}
+	m.tx = newMemoryTransactionClient(m)
+
+	go m.janitor()
+
+	client = m
+	return nil
+}
+
+// janitor removes expired keys which are never accessed again
+func (m *memoryClient) janitor() {
+	ticker := time.NewTicker(MEMORY_JANITOR_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.mu.Lock()
+			now := time.Now()
+			for element := m.lru.Back(); element != nil; {
+				prev := element.Prev()
+				if element.Value.(*memoryEntry).expired(now) {
+					m.remove(element)
+				}
+				element = prev
+			}
+			m.mu.Unlock()
+		case <-m.stop:
+			return
+		}
+	}
+}
+
+// lookup returns the live entry of key and marks it as recently used, caller must hold mu
+func (m *memoryClient) lookup(key string) *memoryEntry {
+	element, ok := m.entries[key]
+	if !ok {
+		return nil
+	}
+
+	entry := element.Value.(*memoryEntry)
+	if entry.expired(time.Now()) {
+		m.remove(element)
+		return nil
+	}
+
+	m.lru.MoveToFront(element)
+	return entry
+}
+
+// insert adds a new entry, caller must hold mu
+//
+// Once maxKeys is reached the least recently used keys with a ttl are evicted, keys without a ttl
+// and locks are kept, if nothing can be evicted the write is refused like redis noeviction
+func (m *memoryClient) insert(entry *memoryEntry) error {
+	if element, ok := m.entries[entry.key]; ok {
+		m.remove(element)
+	}
+
+	if m.maxKeys > 0 && m.lru.Len() >= m.maxKeys {
+		m.evict(m.lru.Len() - m.maxKeys + 1)
+		if m.lru.Len() >= m.maxKeys {
+			return errCacheFull
+		}
+	}
+
+	m.entries[entry.key] = m.lru.PushFront(entry)
+	return nil
+}
+
+// evict removes up to n expired or volatile keys which are not locks, least recently used first,
+// caller must hold mu
+func (m *memoryClient) evict(n int) {
+	now := time.Now()
+	for element := m.lru.Back(); element != nil && n > 0; {
+		prev := element.Prev()
+		entry := element.Value.(*memoryEntry)
+		if entry.expired(now) || !entry.expireAt.IsZero() && !entry.lock {
+			m.remove(element)
+			n--
+		}
+		element = prev
+	}
+}
+
+func (m *memoryClient) remove(element *list.Element) {
+	m.lru.Remove(element)
+	delete(m.entries, element.Value.(*memoryEntry).key)
+}
+
+func expireAt(expire time.Duration) time.Time {
+	if expire <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(expire)
+}
+
+func (m *memoryClient) Get(key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := m.lookup(key)
+	if entry == nil {
+		return "", redis.Nil
+	}
+	if entry.hash != nil {
+		return "", errWrongType
+	}
+
+	return entry.value, nil
+}
+
+func (m *memoryClient) Set(key string, value any, expire time.Duration) error {
+	v, err := formatValue(value)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.insert(&memoryEntry{key: key, value: v, expireAt: expireAt(expire)})
+}
+
+func (m *memoryClient) SetNX(key string, value any, expire time.Duration) (bool, error) {
+	v, err := formatValue(value)
+	if err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lookup(key) != nil {
+		return false, nil
+	}
+
+	if err := m.insert(&memoryEntry{key: key, value: v, expireAt: expireAt(expire), lock: true}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *memoryClient) Del(key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lookup(key) == nil {
+		return 0, nil
+	}
+
+	m.remove(m.entries[key])
+	return 1, nil
+}
+
+func (m *memoryClient) Exists(key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.lookup(key) == nil {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+func (m *memoryClient) incrBy(key string, delta int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := m.lookup(key)
+	if entry == nil {
+		entry = &memoryEntry{key: key, value: "0"}
+		if err := m.insert(entry); err != nil {
+			return 0, err
+		}
+	}
+	if entry.hash != nil {
+		return 0, errWrongType
+	}
+
+	value, err := strconv.ParseInt(entry.value, 10, 64)
+	if err != nil {
+		return 0, errNotInteger
+	}
+
+	value += delta
+	entry.value = strconv.FormatInt(value, 10)
+	return value, nil
+}
+
+func (m *memoryClient) Incr(key string) (int64, error) {
+	return m.incrBy(key, 1)
+}
+
+func (m *memoryClient) Decr(key string) (int64, error) {
+	return m.incrBy(key, -1)
+}
+
+func (m *memoryClient) Expire(key string, expire time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := m.lookup(key)
+	if entry == nil {
+		return false, nil
+	}
+
+	// a non-positive ttl deletes the key like redis does
+	if expire <= 0 {
+		m.remove(m.entries[key])
+		return true, nil
+	}
+
+	entry.expireAt = expireAt(expire)
+	return true, nil
+}
+
+func (m *memoryClient) CompareAndExpire(key string, value any, expire time.Duration) (bool, error) {
+	v, err := formatValue(value)
+	if err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := m.lookup(key)
+	if entry == nil || entry.hash != nil || entry.value != v {
+		return false, nil
+	}
+	if expire <= 0 {
+		m.remove(m.entries[key])
+		return true, nil
+	}
+	entry.expireAt = expireAt(expire)
+	return true, nil
+}
+
+func (m *memoryClient) CompareAndDel(key string, value any) (bool, error) {
+	v, err := formatValue(value)
+	if err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := m.lookup(key)
+	if entry == nil || entry.hash != nil || entry.value != v {
+		return false, nil
+	}
+	m.remove(m.entries[key])
+	return true, nil
+}
+
+func (m *memoryClient) TakeTokens(limits []TokenBucketLimit) ([]TokenBucket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	entries := make([]*memoryEntry, 0, len(limits))
+	refilled := make([]float64, 0, len(limits))
+	allowed := true
+	for _, limit := range limits {
+		tokens, last := float64(limit.Burst), now
+		entry := m.lookup(limit.Key)
+		if entry != nil {
+			if entry.hash == nil {
+				return nil, errWrongType
+			}
+			if v, err := strconv.ParseFloat(entry.hash["tokens"], 64); err == nil {
+				tokens = v
+			}
+			if v, err := strconv.ParseInt(entry.hash["ts"], 10, 64); err == nil {
+				last = time.UnixMilli(v)
+			}
+		} else {
+			entry = &memoryEntry{key: limit.Key, hash: map[string]string{}}
+			if err := m.insert(entry); err != nil {
+				return nil, err
+			}
+		}
+
+		if elapsed := now.Sub(last); elapsed > 0 {
+			tokens = min(float64(limit.Burst), tokens+elapsed.Seconds()*limit.Rate)
+		}
+		if tokens < 1 {
+			allowed = false
+		}
+		entries = append(entries, entry)
+		refilled = append(refilled, tokens)
+	}
+
+	buckets := make([]TokenBucket, 0, len(limits))
+	for i, limit := range limits {
+		tokens := refilled[i]
+		bucket := TokenBucket{}
+		if tokens >= 1 {
+			bucket.Allowed = true
+		} else {
+			bucket.RetryAfter = time.Duration(math.Ceil((1-tokens)*1000/limit.Rate)) * time.Millisecond
+		}
+		if allowed {
+			tokens--
+		}
+		bucket.Remaining = int64(tokens)
+		bucket.Reset = time.Duration(math.Ceil((float64(limit.Burst)-tokens)*1000/limit.Rate)) * time.Millisecond
+
+		entries[i].hash["tokens"] = strconv.FormatFloat(tokens, 'f', -1, 64)
+		entries[i].hash["ts"] = strconv.FormatInt(now.UnixMilli(), 10)
+		entries[i].expireAt = now.Add(max(bucket.Reset, time.Millisecond))
+		buckets = append(buckets, bucket)
+	}
+	return buckets, nil
+}
+
+// lookupHash returns the hash stored at key, creating it if create is set, caller must hold mu
+func (m *memoryClient) lookupHash(key string, create bool) (*memoryEntry, error) {
+	entry := m.lookup(key)
+	if entry == nil {
+		if !create {
+			return nil, nil
+		}
+		entry = &memoryEntry{key: key, hash: map[string]string{}}
+		if err := m.insert(entry); err != nil {
+			return nil, err
+		}
+	}
+	if entry.hash == nil {
+		return nil, errWrongType
+	}
+	return entry, nil
+}
+
+func (m *memoryClient) HSet(key string, values map[string]any) error {
+	formatted := make(map[string]string, len(values))
+	for field, value := range values {
+		v, err := formatValue(value)
+		if err != nil {
+			return err
+		}
+		formatted[field] = v
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, err := m.lookupHash(key, true)
+	if err != nil {
+		return err
+	}
+
+	for field, value := range formatted {
+		entry.hash[field] = value
+	}
+	return nil
+}
+
+func (m *memoryClient) HGet(key string, field string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, err := m.lookupHash(key, false)
+	if err != nil {
+		return "", err
+	}
+	if entry == nil {
+		return "", redis.Nil
+	}
+
+	value, ok := entry.hash[field]
+	if !ok {
+		return "", redis.Nil
+	}
+	return value, nil
+}
+
+func (m *memoryClient) HDel(key string, field string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, err := m.lookupHash(key, false)
+	if err != nil || entry == nil {
+		return err
+	}
+
+	delete(entry.hash, field)
+	if len(entry.hash) == 0 {
+		m.remove(m.entries[key])
+	}
+	return nil
+}
+
+func (m *memoryClient) HGetAll(key string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, err := m.lookupHash(key, false)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]string{}
+	if entry != nil {
+		for field, value := range entry.hash {
+			result[field] = value
+		}
+	}
+	return result, nil
+}
+
+// Scan returns all the matched keys at once, cursor is always 0
+func (m *memoryClient) Scan(cursor uint64, match string, count int64) ([]string, uint64, error) {
+	pattern, err := compilePattern(match)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	keys := []string{}
+	for key, element := range m.entries {
+		if element.Value.(*memoryEntry).expired(now) {
+			continue
+		}
+		if pattern.MatchString(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys, 0, nil
+}
+
+// HScan returns all the matched fields and values at once, cursor is always 0
+func (m *memoryClient) HScan(key string, cursor uint64, match string, count int64) ([]string, uint64, error) {
+	pattern, err := compilePattern(match)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, err := m.lookupHash(key, false)
+	if err != nil || entry == nil {
+		return []string{}, 0, err
+	}
+
+	kvs := []string{}
+	for field, value := range entry.hash {
+		if pattern.MatchString(field) {
+			kvs = append(kvs, field, value)
+		}
+	}
+	return kvs, 0, nil
+}
+
+func (m *memoryClient) Publish(channel string, message any) error {
+	payload, err := formatValue(message)
+	if err != nil {
+		return err
+	}
+
+	m.subscribersLock.RLock()
+	defer m.subscribersLock.RUnlock()
+
+	for subscriber := range m.subscribers[channel] {
+		select {
+		case subscriber <- payload:
+		default:
+			log.Warn("subscriber of %s is too slow, message dropped", channel)
+		}
+	}
+	return nil
+}
+
+func (m *memoryClient) Subscribe(channel string) (<-chan string, func()) {
+	ch := make(chan string, MEMORY_SUBSCRIPTION_BUFFER_SIZE)
+
+	m.subscribersLock.Lock()
+	if m.subscribers[channel] == nil {
+		m.subscribers[channel] = map[chan string]struct{}{}
+	}
+	m.subscribers[channel][ch] = struct{}{}
+	m.subscribersLock.Unlock()
+
+	once := sync.Once{}
+	return ch, func() {
+		once.Do(func() {
+			m.subscribersLock.Lock()
+			delete(m.subscribers[channel], ch)
+			if len(m.subscribers[channel]) == 0 {
+				delete(m.subscribers, channel)
+			}
+			m.subscribersLock.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Transaction queues the commands of fn on a pipeline like MULTI/EXEC, they are applied to the client
+// once fn returned and discarded if it failed
+func (m *memoryClient) Transaction(fn func(redis.Pipeliner) error) error {
+	m.txLock.Lock()
+	defer m.txLock.Unlock()
+
+	_, err := m.tx.TxPipelined(ctx, fn)
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
+
+func (m *memoryClient) Close() error {
+	select {
+	case <-m.stop:
+	default:
+		close(m.stop)
+		m.tx.Close()
+	}
+	return nil
+}
+
+// formatValue converts values to strings the same way redis arguments are encoded
+func formatValue(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case int:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int8:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int16:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case encoding.BinaryMarshaler:
+		b, err := v.MarshalBinary()
+		return string(b), err
+	default:
+		return "", fmt.Errorf("can't marshal %T (implement encoding.BinaryMarshaler)", value)
+	}
+}
+
+// compilePattern converts a redis glob-style pattern into a regular expression
+func compilePattern(match string) (*regexp.Regexp, error) {
+	builder := strings.Builder{}
+	builder.WriteString("^")
+	for i := 0; i < len(match); i++ {
+		switch c := match[i]; c {
+		case '*':
+			builder.WriteString(".*")
+		case '?':
+			builder.WriteString(".")
+		case '\\':
+			if i+1 < len(match) {
+				i++
+				builder.WriteString(regexp.QuoteMeta(string(match[i])))
+			}
+		case '[':
+			end := strings.IndexByte(match[i:], ']')
+			if end == -1 {
+				builder.WriteString(regexp.QuoteMeta(match[i:]))
+				i = len(match)
+				continue
+			}
+			class := match[i+1 : i+end]
+			if strings.HasPrefix(class, "^") || strings.HasPrefix(class, "!") {
+				class = "^" + regexp.QuoteMeta(class[1:])
+			} else {
+				class = regexp.QuoteMeta(class)
+			}
+			builder.WriteString("[" + class + "]")
+			i += end
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	builder.WriteString("$")
+
+	return regexp.Compile(builder.String())
+}
@@ -0,0 +1,336 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func initMemoryClient(t *testing.T, maxKeys int) {
+	if err := InitMemoryClient(maxKeys); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		Close()
+		client = nil
+	})
+}
+
+func TestMemoryStoreAndExpire(t *testing.T) {
+	initMemoryClient(t, 0)
+
+	type value struct {
+		A string
+	}
+
+	assert.NoError(t, Store("memory_a", value{A: "a"}, 50*time.Millisecond))
+	v, err := Get[value]("memory_a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", v.A)
+
+	time.Sleep(60 * time.Millisecond)
+	_, err = Get[value]("memory_a")
+	assert.Equal(t, ErrNotFound, err)
+
+	// keys without ttl never expire until removed
+	assert.NoError(t, Store("memory_b", "b", 0))
+	s, err := GetString("memory_b")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", s)
+
+	ok, err := Expire("memory_b", 50*time.Millisecond)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	time.Sleep(60 * time.Millisecond)
+	exists, err := Exist("memory_b")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), exists)
+}
+
+func TestMemoryLRUEviction(t *testing.T) {
+	initMemoryClient(t, 2)
+
+	assert.NoError(t, Store("lru_a", "a", time.Minute))
+	assert.NoError(t, Store("lru_b", "b", time.Minute))
+
+	// touch a, b becomes the least recently used one
+	_, err := GetString("lru_a")
+	assert.NoError(t, err)
+
+	assert.NoError(t, Store("lru_c", "c", time.Minute))
+
+	_, err = GetString("lru_b")
+	assert.Equal(t, ErrNotFound, err)
+	_, err = GetString("lru_a")
+	assert.NoError(t, err)
+	_, err = GetString("lru_c")
+	assert.NoError(t, err)
+}
+
+func TestMemoryEvictionKeepsLocks(t *testing.T) {
+	initMemoryClient(t, 3)
+
+	ok, err := SetNX("evict_lock", "owner", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NoError(t, Store("evict_persistent", "p", 0))
+	assert.NoError(t, Store("evict_volatile", "v", time.Minute))
+
+	// only the volatile key can make room, even though the lock is the least recently used one
+	assert.NoError(t, Store("evict_new", "n", time.Minute))
+	_, err = GetString("evict_volatile")
+	assert.Equal(t, ErrNotFound, err)
+	exists, err := Exist("evict_lock")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), exists)
+
+	// locks and keys without ttl are never evicted, the write is refused instead
+	assert.NoError(t, Store("evict_newer", "n", time.Minute))
+	_, err = SetNX("evict_other_lock", "owner", time.Minute)
+	assert.NoError(t, err)
+	ok, err = SetNX("evict_third_lock", "owner", time.Minute)
+	assert.Error(t, err)
+	assert.False(t, ok)
+	exists, err = Exist("evict_lock")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), exists)
+	_, err = GetString("evict_persistent")
+	assert.NoError(t, err)
+}
+
+func TestMemoryCounterAndLock(t *testing.T) {
+	initMemoryClient(t, 0)
+
+	n, err := Increase("memory_counter")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	n, err = Decrease("memory_counter")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+
+	assert.NoError(t, Lock("memory_lock", time.Second, 100*time.Millisecond))
+	assert.Equal(t, ErrLockTimeout, Lock("memory_lock", time.Second, 100*time.Millisecond))
+	assert.NoError(t, Unlock("memory_lock"))
+	assert.NoError(t, Lock("memory_lock", time.Second, 100*time.Millisecond))
+
+	ok, err := SetNX("memory_nx", "a", time.Second)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = SetNX("memory_nx", "b", time.Second)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// a lock is only renewed or released by its holder
+	ok, err = RenewLock("memory_nx", "b", time.Second)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = ReleaseLock("memory_nx", "b")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = RenewLock("memory_nx", "a", 50*time.Millisecond)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	time.Sleep(60 * time.Millisecond)
+	ok, err = RenewLock("memory_nx", "a", time.Second)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = SetNX("memory_nx", "a", time.Second)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = ReleaseLock("memory_nx", "a")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = SetNX("memory_nx", "b", time.Second)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestMemoryMapAndScan(t *testing.T) {
+	initMemoryClient(t, 0)
+
+	type node struct {
+		IP string `json:"ip"`
+	}
+
+	assert.NoError(t, SetMapOneField("memory_map", "node_1", node{IP: "1.1.1.1"}))
+	assert.NoError(t, SetMapOneField("memory_map", "node_2", node{IP: "2.2.2.2"}))
+	assert.NoError(t, SetMapOneField("memory_map", "other", node{IP: "3.3.3.3"}))
+
+	v, err := GetMapField[node]("memory_map", "node_1")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.1.1.1", v.IP)
+
+	all, err := GetMap[node]("memory_map")
+	assert.NoError(t, err)
+	assert.Len(t, all, 3)
+
+	matched, err := ScanMap[node]("memory_map", "node_*")
+	assert.NoError(t, err)
+	assert.Len(t, matched, 2)
+
+	assert.NoError(t, DelMapField("memory_map", "node_1"))
+	_, err = GetMapField[node]("memory_map", "node_1")
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.NoError(t, Store("scan:langgenius/openai:1", "1", 0))
+	assert.NoError(t, Store("scan:langgenius/openai:2", "2", 0))
+	assert.NoError(t, Store("scan:other", "3", 0))
+	keys, err := ScanKeys(serialKey("scan:langgenius/*"))
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+
+	deleted, err := DelByPattern("scan:langgenius/*")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	keys, err = ScanKeys(serialKey("scan:*"))
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+}
+
+func TestMemoryTransaction(t *testing.T) {
+	initMemoryClient(t, 0)
+
+	err := Transaction(func(p redis.Pipeliner) error {
+		if err := Store("memory_tx_a", "a", 0, p); err != nil {
+			return err
+		}
+		return Store("memory_tx_b", "b", 0, p)
+	})
+	assert.NoError(t, err)
+
+	for _, key := range []string{"memory_tx_a", "memory_tx_b"} {
+		_, err := GetString(key)
+		assert.NoError(t, err)
+	}
+
+	// the pipeline is usable directly, replies are there once the transaction is done
+	var incr *redis.IntCmd
+	var hash *redis.MapStringStringCmd
+	err = Transaction(func(p redis.Pipeliner) error {
+		p.Set(ctx, serialKey("memory_tx_c"), "c", time.Minute)
+		incr = p.Incr(ctx, serialKey("memory_tx_counter"))
+		p.HSet(ctx, serialKey("memory_tx_hash"), "field", "value")
+		hash = p.HGetAll(ctx, serialKey("memory_tx_hash"))
+		if _, err := SetNX("memory_tx_a", "again", time.Minute, p); err != nil {
+			return err
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), incr.Val())
+	assert.Equal(t, map[string]string{"field": "value"}, hash.Val())
+	value, err := GetString("memory_tx_c")
+	assert.NoError(t, err)
+	assert.Equal(t, "c", value)
+	value, err = GetString("memory_tx_a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", value)
+
+	// nothing is applied if the transaction failed
+	err = Transaction(func(p redis.Pipeliner) error {
+		if err := Store("memory_tx_d", "d", 0, p); err != nil {
+			return err
+		}
+		return errors.New("failed")
+	})
+	assert.Error(t, err)
+	_, err = GetString("memory_tx_d")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestMemoryPubSub(t *testing.T) {
+	initMemoryClient(t, 0)
+
+	type message struct {
+		Data string `json:"data"`
+	}
+
+	ch, cancel := Subscribe[message]("memory_channel")
+	defer cancel()
+
+	assert.NoError(t, Publish("memory_channel", message{Data: "hello"}))
+
+	select {
+	case m := <-ch:
+		assert.Equal(t, "hello", m.Data)
+	case <-time.After(time.Second):
+		t.Fatal("message not received")
+	}
+}
+
+func TestMemoryTakeToken(t *testing.T) {
+	initMemoryClient(t, 0)
+
+	for i := 0; i < 2; i++ {
+		bucket, err := TakeToken("memory_bucket", 20, 2)
+		assert.NoError(t, err)
+		assert.True(t, bucket.Allowed)
+		assert.Equal(t, int64(1-i), bucket.Remaining)
+	}
+
+	bucket, err := TakeToken("memory_bucket", 20, 2)
+	assert.NoError(t, err)
+	assert.False(t, bucket.Allowed)
+	assert.Greater(t, bucket.RetryAfter, time.Duration(0))
+	assert.LessOrEqual(t, bucket.RetryAfter, 50*time.Millisecond)
+
+	// refilled with a token every 50ms
+	time.Sleep(60 * time.Millisecond)
+	bucket, err = TakeToken("memory_bucket", 20, 2)
+	assert.NoError(t, err)
+	assert.True(t, bucket.Allowed)
+
+	// full buckets expire
+	time.Sleep(120 * time.Millisecond)
+	n, err := Exist("memory_bucket")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+}
+
+func TestMemoryTakeTokensAllOrNothing(t *testing.T) {
+	initMemoryClient(t, 0)
+
+	limits := []TokenBucketLimit{
+		{Key: "memory_wide", Rate: 0.001, Burst: 3},
+		{Key: "memory_narrow", Rate: 0.001, Burst: 1},
+	}
+	buckets, err := TakeTokens(limits)
+	assert.NoError(t, err)
+	assert.True(t, buckets[0].Allowed && buckets[1].Allowed)
+	assert.Equal(t, int64(2), buckets[0].Remaining)
+
+	// the narrow bucket is empty, the wide one keeps its tokens
+	buckets, err = TakeTokens(limits)
+	assert.NoError(t, err)
+	assert.True(t, buckets[0].Allowed)
+	assert.False(t, buckets[1].Allowed)
+	assert.Equal(t, int64(2), buckets[0].Remaining)
+	assert.Greater(t, buckets[1].RetryAfter, time.Duration(0))
+}
+
+func TestCompilePattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		key     string
+		match   bool
+	}{
+		{"a*", "abc", true},
+		{"a?c", "abc", true},
+		{"a?c", "abbc", false},
+		{"a[bc]d", "acd", true},
+		{"a[^bc]d", "acd", false},
+		{"a\\*", "a*", true},
+		{"a\\*", "ab", false},
+		{"plugin.(x)*", "plugin.(x)y", true},
+	}
+
+	for _, c := range cases {
+		pattern, err := compilePattern(c.pattern)
+		assert.NoError(t, err)
+		assert.Equal(t, c.match, pattern.MatchString(c.key), "%s %s", c.pattern, c.key)
+	}
+}
@@ -0,0 +1,288 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var errMemoryConnection = errors.New("the memory cache has no connection, commands only run inside transactions")
+
+// memoryTransactions runs MULTI/EXEC pipelines of a go-redis client on the memory client, commands are
+// queued by the pipeline and applied in order once fn returned, nothing is applied if it failed
+type memoryTransactions struct {
+	m *memoryClient
+}
+
+func newMemoryTransactionClient(m *memoryClient) *redis.Client {
+	// the address is never dialed, every pipeline is answered by the hook
+	tx := redis.NewClient(&redis.Options{Addr: "memory", MaxRetries: -1})
+	tx.AddHook(memoryTransactions{m: m})
+	return tx
+}
+
+func (memoryTransactions) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errMemoryConnection
+	}
+}
+
+func (memoryTransactions) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		cmd.SetErr(errMemoryConnection)
+		return errMemoryConnection
+	}
+}
+
+func (t memoryTransactions) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			t.process(cmd)
+		}
+		return nil
+	}
+}
+
+// process runs cmd on the memory client and stores the reply in it like a reply of redis
+func (t memoryTransactions) process(cmd redis.Cmder) {
+	args := cmd.Args()
+	key := func(i int) string {
+		if i >= len(args) {
+			return ""
+		}
+		return fmt.Sprint(args[i])
+	}
+
+	var value any
+	var err error
+	switch cmd.Name() {
+	case "multi":
+		value = "OK"
+	case "exec":
+		value = []any{}
+	case "get":
+		value, err = t.m.Get(key(1))
+	case "set":
+		value, err = t.set(args)
+	case "setnx":
+		value, err = t.m.SetNX(key(1), args[2], 0)
+	case "del":
+		var deleted int64
+		for i := 1; i < len(args) && err == nil; i++ {
+			var n int64
+			n, err = t.m.Del(key(i))
+			deleted += n
+		}
+		value = deleted
+	case "exists":
+		var exists int64
+		for i := 1; i < len(args) && err == nil; i++ {
+			var n int64
+			n, err = t.m.Exists(key(i))
+			exists += n
+		}
+		value = exists
+	case "incr":
+		value, err = t.m.Incr(key(1))
+	case "decr":
+		value, err = t.m.Decr(key(1))
+	case "expire", "pexpire":
+		var expire int64
+		if expire, err = argInt(args, 2); err == nil {
+			unit := time.Second
+			if cmd.Name() == "pexpire" {
+				unit = time.Millisecond
+			}
+			value, err = t.m.Expire(key(1), time.Duration(expire)*unit)
+		}
+	case "hset", "hmset":
+		values := map[string]any{}
+		for i := 2; i+1 < len(args); i += 2 {
+			values[key(i)] = args[i+1]
+		}
+		err = t.m.HSet(key(1), values)
+		value = int64(len(values))
+		if cmd.Name() == "hmset" {
+			value = true
+		}
+	case "hget":
+		value, err = t.m.HGet(key(1), key(2))
+	case "hdel":
+		err = t.m.HDel(key(1), key(2))
+		value = int64(1)
+	case "hgetall":
+		value, err = t.m.HGetAll(key(1))
+	case "publish":
+		err = t.m.Publish(key(1), args[2])
+		value = int64(0)
+	case "evalsha":
+		value, err = t.evalSha(args)
+	default:
+		err = fmt.Errorf("ERR %s is not supported in transactions of the memory cache", cmd.Name())
+	}
+
+	if err != nil {
+		cmd.SetErr(err)
+		return
+	}
+	if err := setReply(cmd, value); err != nil {
+		cmd.SetErr(err)
+	}
+}
+
+// set handles SET key value [EX seconds|PX milliseconds|KEEPTTL] [NX] as sent by Set and SetNX
+func (t memoryTransactions) set(args []any) (any, error) {
+	if len(args) < 3 {
+		return nil, fmt.Errorf("ERR wrong number of arguments for 'set' command")
+	}
+
+	key := fmt.Sprint(args[1])
+	var expire time.Duration
+	nx := false
+	for i := 3; i < len(args); i++ {
+		switch strings.ToLower(fmt.Sprint(args[i])) {
+		case "ex", "px":
+			n, err := argInt(args, i+1)
+			if err != nil {
+				return nil, err
+			}
+			expire = time.Duration(n) * time.Second
+			if strings.ToLower(fmt.Sprint(args[i])) == "px" {
+				expire = time.Duration(n) * time.Millisecond
+			}
+			i++
+		case "nx":
+			nx = true
+		case "keepttl":
+		default:
+			return nil, fmt.Errorf("ERR syntax error")
+		}
+	}
+
+	if nx {
+		return t.m.SetNX(key, args[2], expire)
+	}
+	return "OK", t.m.Set(key, args[2], expire)
+}
+
+// evalSha runs the scripts of the redis client, the memory client implements each of them natively
+func (t memoryTransactions) evalSha(args []any) (any, error) {
+	if len(args) < 4 {
+		return nil, fmt.Errorf("ERR wrong number of arguments for 'evalsha' command")
+	}
+	key := fmt.Sprint(args[3])
+
+	switch fmt.Sprint(args[1]) {
+	case compareAndExpireScript.Hash():
+		expire, err := argInt(args, 5)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := t.m.CompareAndExpire(key, args[4], time.Duration(expire)*time.Millisecond)
+		return boolInt(ok), err
+	case compareAndDelScript.Hash():
+		ok, err := t.m.CompareAndDel(key, args[4])
+		return boolInt(ok), err
+	case takeTokensScript.Hash():
+		numKeys, err := argInt(args, 2)
+		if err != nil {
+			return nil, err
+		}
+		if numKeys < 1 || int64(len(args)) < 3+numKeys*3 {
+			return nil, fmt.Errorf("ERR wrong number of arguments for 'evalsha' command")
+		}
+		limits := make([]TokenBucketLimit, 0, numKeys)
+		for i := int64(0); i < numKeys; i++ {
+			rate, err := formatValue(args[3+numKeys+i*2])
+			if err != nil {
+				return nil, err
+			}
+			parsedRate, err := strconv.ParseFloat(rate, 64)
+			if err != nil {
+				return nil, err
+			}
+			burst, err := argInt(args, int(4+numKeys+i*2))
+			if err != nil {
+				return nil, err
+			}
+			limits = append(limits, TokenBucketLimit{Key: fmt.Sprint(args[3+i]), Rate: parsedRate, Burst: burst})
+		}
+		buckets, err := t.m.TakeTokens(limits)
+		if err != nil {
+			return nil, err
+		}
+		result := make([]any, 0, len(buckets)*4)
+		for _, bucket := range buckets {
+			result = append(result, boolInt(bucket.Allowed), bucket.Remaining, bucket.RetryAfter.Milliseconds(), bucket.Reset.Milliseconds())
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("NOSCRIPT no matching script")
+}
+
+func argInt(args []any, i int) (int64, error) {
+	if i >= len(args) {
+		return 0, fmt.Errorf("ERR syntax error")
+	}
+	value, err := formatValue(args[i])
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, errNotInteger
+	}
+	return n, nil
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// setReply stores value in cmd as the reply of redis would be parsed into it
+func setReply(cmd redis.Cmder, value any) error {
+	switch cmd := cmd.(type) {
+	case *redis.StatusCmd:
+		cmd.SetVal(fmt.Sprint(value))
+	case *redis.StringCmd:
+		cmd.SetVal(fmt.Sprint(value))
+	case *redis.IntCmd:
+		n, ok := value.(int64)
+		if !ok {
+			return fmt.Errorf("unexpected reply %v of %s", value, cmd.Name())
+		}
+		cmd.SetVal(n)
+	case *redis.BoolCmd:
+		b, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("unexpected reply %v of %s", value, cmd.Name())
+		}
+		cmd.SetVal(b)
+	case *redis.MapStringStringCmd:
+		m, ok := value.(map[string]string)
+		if !ok {
+			return fmt.Errorf("unexpected reply %v of %s", value, cmd.Name())
+		}
+		cmd.SetVal(m)
+	case *redis.SliceCmd:
+		s, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("unexpected reply %v of %s", value, cmd.Name())
+		}
+		cmd.SetVal(s)
+	case *redis.Cmd:
+		cmd.SetVal(value)
+	default:
+		return fmt.Errorf("ERR replies of %s are not supported in transactions of the memory cache", cmd.Name())
+	}
+	return nil
+}
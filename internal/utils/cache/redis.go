@@ -7,13 +7,12 @@ import (
 	"strings"
 	"time"
 
-	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 	"github.com/redis/go-redis/v9"
 )
 
 var (
-	client redis.UniversalClient
+	client Client
 	ctx    = context.Background()
 
 	ErrDBNotInit = errors.New("redis client not init")
@@ -35,12 +34,13 @@ func getRedisOptions(addr, username, password string, useSsl bool, db int) *redi
 
 func InitRedisClient(addr, username, password string, useSsl bool, db int) error {
 	opts := getRedisOptions(addr, username, password, useSsl, db)
-	client = redis.NewClient(opts)
+	redisClient := redis.NewClient(opts)
 
-	if _, err := client.Ping(ctx).Result(); err != nil {
+	if _, err := redisClient.Ping(ctx).Result(); err != nil {
 		return err
 	}
 
+	client = newRedisClient(redisClient)
 	return nil
 }
 
@@ -63,12 +63,13 @@ func InitRedisSentinelClient(sentinels []string, masterName, username, password,
 		opts.DialTimeout = time.Duration(socketTimeout * float64(time.Second))
 	}
 
-	client = redis.NewFailoverClient(opts)
+	redisClient := redis.NewFailoverClient(opts)
 
-	if _, err := client.Ping(ctx).Result(); err != nil {
+	if _, err := redisClient.Ping(ctx).Result(); err != nil {
 		return err
 	}
 
+	client = newRedisClient(redisClient)
 	return nil
 }
 
@@ -81,9 +82,11 @@ func Close() error {
 	return client.Close()
 }
 
-func getCmdable(context ...redis.Cmdable) redis.Cmdable {
-	if len(context) > 0 {
-		return context[0]
+// getClient returns the client to run commands on, commands inside a transaction
+// are queued on its pipeline, a nil pipeline falls back to the default client
+func getClient(context ...redis.Cmdable) Client {
+	if len(context) > 0 && context[0] != nil {
+		return &redisClient{cmd: context[0]}
 	}
 
 	return client
@@ -115,7 +118,7 @@ func store(key string, value any, time time.Duration, context ...redis.Cmdable)
 		}
 	}
 
-	return getCmdable(context...).Set(ctx, key, value, time).Err()
+	return getClient(context...).Set(key, value, time)
 }
 
 // Get the value with key
@@ -128,7 +131,7 @@ func get[T any](key string, context ...redis.Cmdable) (*T, error) {
 		return nil, ErrDBNotInit
 	}
 
-	val, err := getCmdable(context...).Get(ctx, key).Bytes()
+	val, err := getClient(context...).Get(key)
 	if err != nil {
 		if err == redis.Nil {
 			return nil, ErrNotFound
@@ -140,7 +143,7 @@ func get[T any](key string, context ...redis.Cmdable) (*T, error) {
 		return nil, ErrNotFound
 	}
 
-	result, err := parser.UnmarshalCBOR[T](val)
+	result, err := parser.UnmarshalCBOR[T]([]byte(val))
 	return &result, err
 }
 
@@ -150,7 +153,7 @@ func GetString(key string, context ...redis.Cmdable) (string, error) {
 		return "", ErrDBNotInit
 	}
 
-	v, err := getCmdable(context...).Get(ctx, serialKey(key)).Result()
+	v, err := getClient(context...).Get(serialKey(key))
 	if err != nil {
 		if err == redis.Nil {
 			return "", ErrNotFound
@@ -170,7 +173,7 @@ func del(key string, context ...redis.Cmdable) (int64, error) {
 		return 0, ErrDBNotInit
 	}
 
-	v, err := getCmdable(context...).Del(ctx, key).Result()
+	v, err := getClient(context...).Del(key)
 	return v, err
 }
 
@@ -180,7 +183,7 @@ func Exist(key string, context ...redis.Cmdable) (int64, error) {
 		return 0, ErrDBNotInit
 	}
 
-	return getCmdable(context...).Exists(ctx, serialKey(key)).Result()
+	return getClient(context...).Exists(serialKey(key))
 }
 
 // Increase the key
@@ -189,7 +192,7 @@ func Increase(key string, context ...redis.Cmdable) (int64, error) {
 		return 0, ErrDBNotInit
 	}
 
-	num, err := getCmdable(context...).Incr(ctx, serialKey(key)).Result()
+	num, err := getClient(context...).Incr(serialKey(key))
 	if err != nil {
 		if err == redis.Nil {
 			return 0, ErrNotFound
@@ -206,7 +209,7 @@ func Decrease(key string, context ...redis.Cmdable) (int64, error) {
 		return 0, ErrDBNotInit
 	}
 
-	return getCmdable(context...).Decr(ctx, serialKey(key)).Result()
+	return getClient(context...).Decr(serialKey(key))
 }
 
 // SetExpire set the expire time for the key
@@ -215,7 +218,8 @@ func SetExpire(key string, time time.Duration, context ...redis.Cmdable) error {
 		return ErrDBNotInit
 	}
 
-	return getCmdable(context...).Expire(ctx, serialKey(key), time).Err()
+	_, err := getClient(context...).Expire(serialKey(key), time)
+	return err
 }
 
 // SetMapField set the map field with key
@@ -224,7 +228,7 @@ func SetMapField(key string, v map[string]any, context ...redis.Cmdable) error {
 		return ErrDBNotInit
 	}
 
-	return getCmdable(context...).HMSet(ctx, serialKey(key), v).Err()
+	return getClient(context...).HSet(serialKey(key), v)
 }
 
 // SetMapOneField set the map field with key
@@ -237,7 +241,7 @@ func SetMapOneField(key string, field string, value any, context ...redis.Cmdabl
 		value = parser.MarshalJson(value)
 	}
 
-	return getCmdable(context...).HSet(ctx, serialKey(key), field, value).Err()
+	return getClient(context...).HSet(serialKey(key), map[string]any{field: value})
 }
 
 // GetMapField get the map field with key
@@ -246,7 +250,7 @@ func GetMapField[T any](key string, field string, context ...redis.Cmdable) (*T,
 		return nil, ErrDBNotInit
 	}
 
-	val, err := getCmdable(context...).HGet(ctx, serialKey(key), field).Result()
+	val, err := getClient(context...).HGet(serialKey(key), field)
 	if err != nil {
 		if err == redis.Nil {
 			return nil, ErrNotFound
@@ -264,7 +268,7 @@ func GetMapFieldString(key string, field string, context ...redis.Cmdable) (stri
 		return "", ErrDBNotInit
 	}
 
-	val, err := getCmdable(context...).HGet(ctx, serialKey(key), field).Result()
+	val, err := getClient(context...).HGet(serialKey(key), field)
 	if err != nil {
 		if err == redis.Nil {
 			return "", ErrNotFound
@@ -281,7 +285,7 @@ func DelMapField(key string, field string, context ...redis.Cmdable) error {
 		return ErrDBNotInit
 	}
 
-	return getCmdable(context...).HDel(ctx, serialKey(key), field).Err()
+	return getClient(context...).HDel(serialKey(key), field)
 }
 
 // GetMap get the map with key
@@ -290,7 +294,7 @@ func GetMap[V any](key string, context ...redis.Cmdable) (map[string]V, error) {
 		return nil, ErrDBNotInit
 	}
 
-	val, err := getCmdable(context...).HGetAll(ctx, serialKey(key)).Result()
+	val, err := getClient(context...).HGetAll(serialKey(key))
 	if err != nil {
 		if err == redis.Nil {
 			return nil, ErrNotFound
@@ -338,7 +342,7 @@ func ScanKeysAsync(match string, fn func([]string) error, context ...redis.Cmdab
 	cursor := uint64(0)
 
 	for {
-		keys, newCursor, err := getCmdable(context...).Scan(ctx, cursor, match, 32).Result()
+		keys, newCursor, err := getClient(context...).Scan(cursor, match, 32)
 		if err != nil {
 			return err
 		}
@@ -357,6 +361,27 @@ func ScanKeysAsync(match string, fn func([]string) error, context ...redis.Cmdab
 	return nil
 }
 
+// DelByPattern deletes the keys matching pattern, format like "key*", returns the number of deleted keys
+func DelByPattern(match string, context ...redis.Cmdable) (int64, error) {
+	if client == nil {
+		return 0, ErrDBNotInit
+	}
+
+	deleted := int64(0)
+	err := ScanKeysAsync(serialKey(match), func(keys []string) error {
+		for _, key := range keys {
+			n, err := del(key, context...)
+			if err != nil && err != ErrNotFound {
+				return err
+			}
+			deleted += n
+		}
+		return nil
+	}, context...)
+
+	return deleted, err
+}
+
 // ScanMap scan the map with match pattern, format like "key*"
 func ScanMap[V any](key string, match string, context ...redis.Cmdable) (map[string]V, error) {
 	if client == nil {
@@ -385,9 +410,7 @@ func ScanMapAsync[V any](key string, match string, fn func(map[string]V) error,
 	cursor := uint64(0)
 
 	for {
-		kvs, newCursor, err := getCmdable(context...).
-			HScan(ctx, serialKey(key), cursor, match, 32).
-			Result()
+		kvs, newCursor, err := getClient(context...).HScan(serialKey(key), cursor, match, 32)
 
 		if err != nil {
 			return err
@@ -429,7 +452,64 @@ func SetNX[T any](key string, value T, expire time.Duration, context ...redis.Cm
 		return false, err
 	}
 
-	return getCmdable(context...).SetNX(ctx, serialKey(key), bytes, expire).Result()
+	return getClient(context...).SetNX(serialKey(key), bytes, expire)
+}
+
+// RenewLock extends the expiration of a key set by SetNX only if it still holds value,
+// a holder whose key expired and was taken by another one fails to renew it
+func RenewLock[T any](key string, value T, expire time.Duration, context ...redis.Cmdable) (bool, error) {
+	if client == nil {
+		return false, ErrDBNotInit
+	}
+
+	bytes, err := parser.MarshalCBOR(value)
+	if err != nil {
+		return false, err
+	}
+
+	return getClient(context...).CompareAndExpire(serialKey(key), bytes, expire)
+}
+
+// ReleaseLock deletes a key set by SetNX only if it still holds value
+func ReleaseLock[T any](key string, value T, context ...redis.Cmdable) (bool, error) {
+	if client == nil {
+		return false, ErrDBNotInit
+	}
+
+	bytes, err := parser.MarshalCBOR(value)
+	if err != nil {
+		return false, err
+	}
+
+	return getClient(context...).CompareAndDel(serialKey(key), bytes)
+}
+
+// TakeToken takes a token from the bucket at key, it's refilled with rate tokens per second up to burst
+func TakeToken(key string, rate float64, burst int64, context ...redis.Cmdable) (TokenBucket, error) {
+	if client == nil {
+		return TokenBucket{}, ErrDBNotInit
+	}
+
+	buckets, err := TakeTokens([]TokenBucketLimit{{Key: key, Rate: rate, Burst: burst}}, context...)
+	if err != nil {
+		return TokenBucket{}, err
+	}
+	return buckets[0], nil
+}
+
+// TakeTokens takes a token from each of the buckets only if all of them have one, a request counted
+// against several limits never spends tokens of one when another refuses it
+func TakeTokens(limits []TokenBucketLimit, context ...redis.Cmdable) ([]TokenBucket, error) {
+	if client == nil {
+		return nil, ErrDBNotInit
+	}
+
+	serialized := make([]TokenBucketLimit, 0, len(limits))
+	for _, limit := range limits {
+		limit.Key = serialKey(limit.Key)
+		serialized = append(serialized, limit)
+	}
+	return getClient(context...).TakeTokens(serialized)
 }
 
 var (
@@ -449,7 +529,7 @@ func Lock(key string, expire time.Duration, tryLockTimeout time.Duration, contex
 	defer ticker.Stop()
 
 	for range ticker.C {
-		if success, err := getCmdable(context...).SetNX(ctx, serialKey(key), "1", expire).Result(); err != nil {
+		if success, err := getClient(context...).SetNX(serialKey(key), "1", expire); err != nil {
 			return err
 		} else if success {
 			return nil
@@ -469,7 +549,8 @@ func Unlock(key string, context ...redis.Cmdable) error {
 		return ErrDBNotInit
 	}
 
-	return getCmdable(context...).Del(ctx, serialKey(key)).Err()
+	_, err := getClient(context...).Del(serialKey(key))
+	return err
 }
 
 func Expire(key string, time time.Duration, context ...redis.Cmdable) (bool, error) {
@@ -477,7 +558,7 @@ func Expire(key string, time time.Duration, context ...redis.Cmdable) (bool, err
 		return false, ErrDBNotInit
 	}
 
-	return getCmdable(context...).Expire(ctx, serialKey(key), time).Result()
+	return getClient(context...).Expire(serialKey(key), time)
 }
 
 func Transaction(fn func(redis.Pipeliner) error) error {
@@ -485,15 +566,7 @@ func Transaction(fn func(redis.Pipeliner) error) error {
 		return ErrDBNotInit
 	}
 
-	return client.Watch(ctx, func(tx *redis.Tx) error {
-		_, err := tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
-			return fn(p)
-		})
-		if err == redis.Nil {
-			return nil
-		}
-		return err
-	})
+	return client.Transaction(fn)
 }
 
 func Publish(channel string, message any, context ...redis.Cmdable) error {
@@ -505,47 +578,23 @@ func Publish(channel string, message any, context ...redis.Cmdable) error {
 		message = parser.MarshalJson(message)
 	}
 
-	return getCmdable(context...).Publish(ctx, channel, message).Err()
+	return getClient(context...).Publish(channel, message)
 }
 
 func Subscribe[T any](channel string) (<-chan T, func()) {
-	pubsub := client.Subscribe(ctx, channel)
+	payloads, cancel := client.Subscribe(channel)
 	ch := make(chan T)
-	connectionEstablished := make(chan bool)
 
 	go func() {
 		defer close(ch)
-		defer close(connectionEstablished)
-
-		alive := true
-		for alive {
-			iface, err := pubsub.Receive(context.Background())
+		for payload := range payloads {
+			v, err := parser.UnmarshalJson[T](payload)
 			if err != nil {
-				log.Error("failed to receive message from redis: %s, will retry in 1 second", err.Error())
-				time.Sleep(1 * time.Second)
 				continue
 			}
-			switch data := iface.(type) {
-			case *redis.Subscription:
-				connectionEstablished <- true
-			case *redis.Message:
-				v, err := parser.UnmarshalJson[T](data.Payload)
-				if err != nil {
-					continue
-				}
-
-				ch <- v
-			case *redis.Pong:
-			default:
-				alive = false
-			}
+			ch <- v
 		}
 	}()
 
-	// wait for the connection to be established
-	<-connectionEstablished
-
-	return ch, func() {
-		pubsub.Close()
-	}
+	return ch, cancel
 }
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrCrossSlotTransaction is returned by Transaction in cluster mode when its keys may live on
+// different shards, MULTI/EXEC is only atomic on a single one
+var ErrCrossSlotTransaction = errors.New("keys of a transaction must share a hash tag in redis cluster, e.g. {tenant}:key")
+
+// redisClusterClient runs commands across the shards of a Redis Cluster,
+// keys of a command are always on a single slot, SCAN walks every shard and
+// transactions are refused unless all their keys share a hash tag.
+// Pub/sub goes through PUBLISH, which keeps no backlog, so nothing has to be trimmed
+type redisClusterClient struct {
+	*redisClient
+	cluster *redis.ClusterClient
+}
+
+func InitRedisClusterClient(addrs []string, username, password string, useSsl bool) error {
+	opts := &redis.ClusterOptions{
+		Addrs:    addrs,
+		Username: username,
+		Password: password,
+	}
+	if useSsl {
+		opts.TLSConfig = &tls.Config{}
+	}
+
+	clusterClient := redis.NewClusterClient(opts)
+	clusterClient.AddHook(singleSlotTransactions{})
+
+	if _, err := clusterClient.Ping(ctx).Result(); err != nil {
+		return err
+	}
+
+	client = &redisClusterClient{
+		redisClient: newRedisClient(clusterClient),
+		cluster:     clusterClient,
+	}
+	return nil
+}
+
+// Scan walks every master to the end, the cursor of each shard is meaningless to the caller
+// so the whole result is returned at once along with a zero cursor
+func (r *redisClusterClient) Scan(cursor uint64, match string, count int64) ([]string, uint64, error) {
+	var mu sync.Mutex
+	result := []string{}
+
+	err := r.cluster.ForEachMaster(ctx, func(shardCtx context.Context, shard *redis.Client) error {
+		iter := shard.Scan(shardCtx, 0, match, count).Iterator()
+		keys := []string{}
+		for iter.Next(shardCtx) {
+			keys = append(keys, iter.Val())
+		}
+		if err := iter.Err(); err != nil {
+			return err
+		}
+
+		mu.Lock()
+		result = append(result, keys...)
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return result, 0, nil
+}
+
+// Transaction queues commands with MULTI/EXEC, it fails with ErrCrossSlotTransaction
+// before anything is sent if the keys don't share a hash tag
+func (r *redisClusterClient) Transaction(fn func(redis.Pipeliner) error) error {
+	_, err := r.cluster.TxPipelined(ctx, fn)
+	if err == redis.Nil {
+		return nil
+	}
+	return err
+}
+
+// singleSlotTransactions refuses MULTI/EXEC pipelines whose keys may be on different slots,
+// the cluster client would otherwise split them into one transaction per shard
+type singleSlotTransactions struct{}
+
+func (singleSlotTransactions) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (singleSlotTransactions) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		return next(ctx, cmd)
+	}
+}
+
+func (singleSlotTransactions) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if len(cmds) < 2 || cmds[0].Name() != "multi" || cmds[len(cmds)-1].Name() != "exec" {
+			return next(ctx, cmds)
+		}
+
+		tags := map[string]bool{}
+		for _, cmd := range cmds[1 : len(cmds)-1] {
+			if args := cmd.Args(); len(args) > 1 {
+				tags[hashTag(fmt.Sprint(args[1]))] = true
+			}
+		}
+		if len(tags) > 1 {
+			for _, cmd := range cmds {
+				cmd.SetErr(ErrCrossSlotTransaction)
+			}
+			return ErrCrossSlotTransaction
+		}
+
+		return next(ctx, cmds)
+	}
+}
+
+// hashTag is the part of key hashed to pick its slot, the content of the first {...} if not empty
+func hashTag(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return key
+	}
+	return key[start+1 : start+1+end]
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestHashTag(t *testing.T) {
+	cases := map[string]string{
+		"plugin:key":          "plugin:key",
+		"{tenant}:key":        "tenant",
+		"lock:{tenant}:a":     "tenant",
+		"{}:key":              "{}:key",
+		"{tenant:key":         "{tenant:key",
+		"{a}{b}":              "a",
+		"install_task_owner:": "install_task_owner:",
+	}
+	for key, expected := range cases {
+		if tag := hashTag(key); tag != expected {
+			t.Errorf("%s: expected hash tag %s, got %s", key, expected, tag)
+		}
+	}
+}
+
+func TestRedisClusterTransactionRefusesCrossSlotKeys(t *testing.T) {
+	// nothing listens there, transactions refused by the hook never dial
+	cluster := redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs:       []string{"127.0.0.1:1"},
+		DialTimeout: 100 * time.Millisecond,
+		MaxRetries:  -1,
+	})
+	cluster.AddHook(singleSlotTransactions{})
+	defer cluster.Close()
+	client := &redisClusterClient{redisClient: newRedisClient(cluster), cluster: cluster}
+
+	err := client.Transaction(func(p redis.Pipeliner) error {
+		p.Set(ctx, "{tenant-a}:key", "value", 0)
+		p.Set(ctx, "{tenant-b}:key", "value", 0)
+		return nil
+	})
+	if !errors.Is(err, ErrCrossSlotTransaction) {
+		t.Fatalf("keys with different hash tags should be refused, got %v", err)
+	}
+
+	err = client.Transaction(func(p redis.Pipeliner) error {
+		p.Set(ctx, "{tenant-a}:key", "value", 0)
+		p.Incr(ctx, "{tenant-a}:counter")
+		return nil
+	})
+	if errors.Is(err, ErrCrossSlotTransaction) {
+		t.Fatal("keys sharing a hash tag should be sent")
+	}
+}
+
+// TestRedisCluster runs against the cluster in REDIS_CLUSTERS, e.g. 127.0.0.1:7000,127.0.0.1:7001
+func TestRedisCluster(t *testing.T) {
+	addrs := os.Getenv("REDIS_CLUSTERS")
+	if addrs == "" {
+		t.Skip("REDIS_CLUSTERS is not set")
+	}
+	if err := InitRedisClusterClient(strings.Split(addrs, ","), "", os.Getenv("REDIS_CLUSTERS_PASSWORD"), false); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+
+	// keys spread over the shards are all found by a single scan
+	keys := []string{"test:cluster:a", "test:cluster:b", "test:cluster:c", "test:cluster:d"}
+	for _, key := range keys {
+		if err := Store(key, "value", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		for _, key := range keys {
+			Del(key)
+		}
+	}()
+
+	found, cursor, err := client.Scan(0, serialKey("test:cluster:*"), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != len(keys) || cursor != 0 {
+		t.Fatalf("expected %d keys and a zero cursor, got %v and %d", len(keys), found, cursor)
+	}
+
+	if err := Transaction(func(p redis.Pipeliner) error {
+		return Store("{test:cluster}:tx", "value", time.Minute, p)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defer Del("{test:cluster}:tx")
+
+	messages, cancel := Subscribe[string]("test:cluster:channel")
+	defer cancel()
+	if err := Publish("test:cluster:channel", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case message := <-messages:
+		if message != "hello" {
+			t.Fatalf("unexpected message %s", message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message is not received")
+	}
+}
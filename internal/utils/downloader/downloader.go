@@ -0,0 +1,384 @@
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_CONCURRENCY = 4
+	DEFAULT_CHUNK_SIZE  = 8 * 1024 * 1024
+	DEFAULT_RETRIES     = 3
+)
+
+var (
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrSizeExceeded     = errors.New("file size exceeds the maximum limit")
+)
+
+type Options struct {
+	Client *http.Client
+	Header http.Header
+
+	// Concurrency is the number of ranges downloaded at the same time
+	Concurrency int
+	// ChunkSize is the size of each range in bytes
+	ChunkSize int64
+	// Retries is the number of attempts for each range
+	Retries int
+	// MaxSize rejects files larger than it, 0 means unlimited
+	MaxSize int64
+	// Checksum is the expected hex encoded sha256 of the whole file, empty to skip verification
+	Checksum string
+}
+
+// progress is persisted next to the partial file, a download interrupted halfway
+// continues from the completed chunks as long as the remote file is unchanged
+type progress struct {
+	URL       string `json:"url"`
+	Size      int64  `json:"size"`
+	ETag      string `json:"etag"`
+	ChunkSize int64  `json:"chunk_size"`
+	Completed []bool `json:"completed"`
+}
+
+// Download fetches url into path, the file is split into byte ranges which are
+// downloaded concurrently if the server supports range requests
+func Download(ctx context.Context, url string, path string, options Options) error {
+	if options.Client == nil {
+		options.Client = http.DefaultClient
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = DEFAULT_CONCURRENCY
+	}
+	if options.ChunkSize <= 0 {
+		options.ChunkSize = DEFAULT_CHUNK_SIZE
+	}
+	if options.Retries <= 0 {
+		options.Retries = DEFAULT_RETRIES
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	partPath := path + ".part"
+	progressPath := path + ".progress"
+
+	size, etag, ranged, err := probe(ctx, url, options)
+	if err != nil {
+		return err
+	}
+
+	if options.MaxSize > 0 && size > options.MaxSize {
+		return ErrSizeExceeded
+	}
+
+	if ranged {
+		err = downloadRanges(ctx, url, partPath, progressPath, size, etag, options)
+	} else {
+		err = downloadWhole(ctx, url, partPath, options)
+	}
+	if err != nil {
+		return err
+	}
+
+	if options.Checksum != "" {
+		if err := verifyChecksum(partPath, options.Checksum); err != nil {
+			// the partial file is corrupted, start over next time
+			os.Remove(partPath)
+			os.Remove(progressPath)
+			return err
+		}
+	}
+
+	if err := os.Rename(partPath, path); err != nil {
+		return err
+	}
+	os.Remove(progressPath)
+
+	return nil
+}
+
+// probe returns the size and etag of the remote file and whether range requests are supported
+func probe(ctx context.Context, url string, options Options) (int64, string, bool, error) {
+	req, err := newRequest(ctx, http.MethodHead, url, options)
+	if err != nil {
+		return 0, "", false, err
+	}
+
+	resp, err := options.Client.Do(req)
+	if err != nil {
+		return 0, "", false, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// some servers do not allow HEAD, fallback to a plain GET
+		return -1, "", false, nil
+	}
+
+	ranged := strings.EqualFold(resp.Header.Get("Accept-Ranges"), "bytes") && resp.ContentLength > 0
+	return resp.ContentLength, resp.Header.Get("ETag"), ranged, nil
+}
+
+func downloadWhole(ctx context.Context, url string, partPath string, options Options) error {
+	req, err := newRequest(ctx, http.MethodGet, url, options)
+	if err != nil {
+		return err
+	}
+
+	resp, err := options.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	file, err := os.Create(partPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var reader io.Reader = resp.Body
+	if options.MaxSize > 0 {
+		reader = io.LimitReader(resp.Body, options.MaxSize+1)
+	}
+
+	written, err := io.Copy(file, reader)
+	if err != nil {
+		return err
+	}
+	if options.MaxSize > 0 && written > options.MaxSize {
+		os.Remove(partPath)
+		return ErrSizeExceeded
+	}
+
+	return nil
+}
+
+func downloadRanges(
+	ctx context.Context,
+	url string,
+	partPath string,
+	progressPath string,
+	size int64,
+	etag string,
+	options Options,
+) error {
+	state := loadProgress(progressPath)
+	if _, err := os.Stat(partPath); err != nil {
+		state = nil
+	}
+	if state == nil ||
+		state.URL != url ||
+		state.Size != size ||
+		state.ETag != etag ||
+		state.ChunkSize != options.ChunkSize {
+		chunks := (size + options.ChunkSize - 1) / options.ChunkSize
+		state = &progress{
+			URL:       url,
+			Size:      size,
+			ETag:      etag,
+			ChunkSize: options.ChunkSize,
+			Completed: make([]bool, chunks),
+		}
+		os.Remove(partPath)
+		saveProgress(progressPath, state)
+	}
+
+	file, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := file.Truncate(size); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+
+	chunks := make(chan int)
+	for i := 0; i < options.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunks {
+				err := downloadChunk(ctx, url, file, state, chunk, options)
+
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+				} else {
+					state.Completed[chunk] = true
+					saveProgress(progressPath, state)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for chunk, completed := range state.Completed {
+		if completed {
+			continue
+		}
+		select {
+		case chunks <- chunk:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(chunks)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	return ctx.Err()
+}
+
+func downloadChunk(
+	ctx context.Context,
+	url string,
+	file *os.File,
+	state *progress,
+	chunk int,
+	options Options,
+) error {
+	start := int64(chunk) * state.ChunkSize
+	end := min(start+state.ChunkSize, state.Size) - 1
+
+	var err error
+	for attempt := 0; attempt < options.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * time.Second):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if err = fetchRange(ctx, url, file, start, end, options); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("failed to download bytes %d-%d: %w", start, end, err)
+}
+
+func fetchRange(
+	ctx context.Context,
+	url string,
+	file *os.File,
+	start int64,
+	end int64,
+	options Options,
+) error {
+	req, err := newRequest(ctx, http.MethodGet, url, options)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	resp, err := options.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	length := end - start + 1
+	written, err := io.Copy(io.NewOffsetWriter(file, start), io.LimitReader(resp.Body, length))
+	if err != nil {
+		return err
+	}
+	if written != length {
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
+
+func newRequest(ctx context.Context, method string, url string, options Options) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range options.Header {
+		req.Header[k] = v
+	}
+	return req, nil
+}
+
+func verifyChecksum(path string, checksum string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(hex.EncodeToString(hash.Sum(nil)), checksum) {
+		return ErrChecksumMismatch
+	}
+
+	return nil
+}
+
+func loadProgress(path string) *progress {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	state := &progress{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil
+	}
+
+	return state
+}
+
+func saveProgress(path string, state *progress) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return
+	}
+	os.WriteFile(path, data, 0o644)
+}
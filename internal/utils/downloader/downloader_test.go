@@ -0,0 +1,149 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newRangeServer(t *testing.T, data []byte, failing *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing != nil && r.Header.Get("Range") != "" && failing.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		http.ServeContent(w, r, "plugin.difypkg", time.Unix(0, 0), bytes.NewReader(data))
+	}))
+}
+
+func randomData(t *testing.T, size int) ([]byte, string) {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("failed to generate data: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	return data, hex.EncodeToString(sum[:])
+}
+
+func TestDownloadRanges(t *testing.T) {
+	data, checksum := randomData(t, 1024*1024+123)
+	server := newRangeServer(t, data, nil)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "plugin.difypkg")
+	err := Download(context.Background(), server.URL, path, Options{
+		Concurrency: 3,
+		ChunkSize:   64 * 1024,
+		Checksum:    checksum,
+	})
+	if err != nil {
+		t.Fatalf("failed to download: %v", err)
+	}
+
+	downloaded, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read downloaded file: %v", err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Fatalf("downloaded data mismatch")
+	}
+
+	if _, err := os.Stat(path + ".progress"); !os.IsNotExist(err) {
+		t.Fatalf("progress file should be removed")
+	}
+}
+
+func TestDownloadChecksumMismatch(t *testing.T) {
+	data, _ := randomData(t, 200*1024)
+	server := newRangeServer(t, data, nil)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "plugin.difypkg")
+	err := Download(context.Background(), server.URL, path, Options{
+		ChunkSize: 64 * 1024,
+		Checksum:  strings.Repeat("0", 64),
+	})
+	if err != ErrChecksumMismatch {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("file should not exist after checksum mismatch")
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	data, checksum := randomData(t, 512*1024)
+
+	// every range request fails, the first attempt leaves nothing completed
+	failing := &atomic.Int32{}
+	failing.Store(1 << 20)
+	server := newRangeServer(t, data, failing)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "plugin.difypkg")
+	options := Options{
+		Concurrency: 1,
+		ChunkSize:   64 * 1024,
+		Retries:     1,
+		Checksum:    checksum,
+	}
+	if err := Download(context.Background(), server.URL, path, options); err == nil {
+		t.Fatalf("expected download to fail")
+	}
+
+	// mark half of the chunks as completed with the correct content
+	state := loadProgress(path + ".progress")
+	if state == nil {
+		t.Fatalf("progress should be persisted")
+	}
+	file, err := os.OpenFile(path+".part", os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("failed to open partial file: %v", err)
+	}
+	for i := 0; i < len(state.Completed)/2; i++ {
+		start := int64(i) * state.ChunkSize
+		if _, err := file.WriteAt(data[start:start+state.ChunkSize], start); err != nil {
+			t.Fatalf("failed to write partial file: %v", err)
+		}
+		state.Completed[i] = true
+	}
+	file.Close()
+	saveProgress(path+".progress", state)
+
+	// only the remaining chunks should be requested
+	requested := &atomic.Int32{}
+	failing.Store(0)
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			requested.Add(1)
+		}
+		http.ServeContent(w, r, "plugin.difypkg", time.Unix(0, 0), bytes.NewReader(data))
+	})
+
+	if err := Download(context.Background(), server.URL, path, options); err != nil {
+		t.Fatalf("failed to resume download: %v", err)
+	}
+
+	if int(requested.Load()) != len(state.Completed)-len(state.Completed)/2 {
+		t.Fatalf("expected %d range requests, got %d", len(state.Completed)/2, requested.Load())
+	}
+
+	downloaded, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read downloaded file: %v", err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Fatalf("downloaded data mismatch")
+	}
+}
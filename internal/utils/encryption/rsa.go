@@ -14,8 +14,14 @@ import (
 )
 
 func RSASign(rsaPrivateKey *rsa.PrivateKey, data []byte) ([]byte, error) {
+	return Sign(rsaPrivateKey, data)
+}
+
+// Sign signs data with a key which may never leave its device like a HSM, RSA signers produce
+// the same PKCS#1 v1.5 signatures as RSASign
+func Sign(signer crypto.Signer, data []byte) ([]byte, error) {
 	hashed := sha256.Sum256(data)
-	return rsa.SignPKCS1v15(rand.Reader, rsaPrivateKey, crypto.SHA256, hashed[:])
+	return signer.Sign(rand.Reader, hashed[:], crypto.SHA256)
 }
 
 func VerifySign(rsaPublicKey *rsa.PublicKey, data []byte, sign []byte) error {
@@ -24,6 +30,11 @@ func VerifySign(rsaPublicKey *rsa.PublicKey, data []byte, sign []byte) error {
 }
 
 func AESEncrypt(aesKey []byte, data []byte) ([]byte, error) {
+	return AESEncryptWithAAD(aesKey, data, nil)
+}
+
+// AESEncryptWithAAD binds the ciphertext to aad, it's only decrypted with the same aad
+func AESEncryptWithAAD(aesKey []byte, data []byte, aad []byte) ([]byte, error) {
 	block, err := aes.NewCipher(aesKey)
 	if err != nil {
 		return nil, err
@@ -39,11 +50,15 @@ func AESEncrypt(aesKey []byte, data []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	cipherText := aesGCM.Seal(nonce, nonce, data, nil)
+	cipherText := aesGCM.Seal(nonce, nonce, data, aad)
 	return cipherText, nil
 }
 
 func AESDecrypt(aesKey []byte, data []byte) ([]byte, error) {
+	return AESDecryptWithAAD(aesKey, data, nil)
+}
+
+func AESDecryptWithAAD(aesKey []byte, data []byte, aad []byte) ([]byte, error) {
 	block, err := aes.NewCipher(aesKey)
 	if err != nil {
 		return nil, err
@@ -60,7 +75,7 @@ func AESDecrypt(aesKey []byte, data []byte) ([]byte, error) {
 	}
 
 	nonce, cipherText := data[:nonceSize], data[nonceSize:]
-	plainText, err := aesGCM.Open(nil, nonce, cipherText, nil)
+	plainText, err := aesGCM.Open(nil, nonce, cipherText, aad)
 	if err != nil {
 		return nil, err
 	}
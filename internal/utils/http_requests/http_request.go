@@ -2,6 +2,7 @@ package http_requests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"mime/multipart"
@@ -80,6 +81,8 @@ func buildHttpRequest(method string, url string, options ...HttpOptions) (*http.
 			req.Header.Set("Content-Type", "application/json")
 		case "directReferer":
 			req.Header.Set("Referer", url)
+		case "context":
+			req = req.WithContext(option.Value.(context.Context))
 		}
 	}
 
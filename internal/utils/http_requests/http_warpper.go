@@ -14,6 +14,25 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/stream"
 )
 
+// StatusCodeError is returned by stream requests answered with a status other than 200
+type StatusCodeError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *StatusCodeError) Error() string {
+	return fmt.Sprintf("request failed with status code: %d and respond with: %s", e.StatusCode, e.Body)
+}
+
+// setRetCode reports the status code to the caller of HttpWithRetCode
+func setRetCode(resp *http.Response, options []HttpOptions) {
+	for _, option := range options {
+		if option.Type == HttpOptionTypeRetCode {
+			*option.Value.(*int) = resp.StatusCode
+		}
+	}
+}
+
 func parseJsonBody(resp *http.Response, ret interface{}) error {
 	defer resp.Body.Close()
 	jsonDecoder := json.NewDecoder(resp.Body)
@@ -32,6 +51,7 @@ func RequestAndParse[T any](client *http.Client, url string, method string, opti
 	if err != nil {
 		return nil, err
 	}
+	setRetCode(resp, options)
 
 	// get read timeout
 	readTimeout := int64(60000)
@@ -79,11 +99,12 @@ func RequestAndParseStream[T any](client *http.Client, url string, method string
 	if err != nil {
 		return nil, err
 	}
+	setRetCode(resp, options)
 
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
 		errorText, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("request failed with status code: %d and respond with: %s", resp.StatusCode, errorText)
+		return nil, &StatusCodeError{StatusCode: resp.StatusCode, Body: errorText}
 	}
 
 	ch := stream.NewStream[T](1024)
@@ -0,0 +1,161 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	definitionNameSanitizer = regexp.MustCompile(`[^A-Za-z0-9_.]+`)
+
+	timeType             = reflect.TypeOf(time.Time{})
+	rawMessageType       = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType    = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshallerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+	// modulePath is the path of this module, its types keep their field layout even with a custom
+	// encoding since it's only used for compatibility
+	modulePath = strings.TrimSuffix(reflect.TypeOf(Reflector{}).PkgPath(), "/internal/utils/json_schema")
+)
+
+// Reflector derives json schemas from go types, named structs are collected into Definitions
+// and referenced from "#/definitions/<package>.<name>"
+//
+// fields tagged with `validate:"required"` are required, unknown properties are always allowed so
+// that peers may add fields, types of other modules with a custom json encoding are accepted as is
+type Reflector struct {
+	Definitions map[string]any
+}
+
+func NewReflector() *Reflector {
+	return &Reflector{Definitions: map[string]any{}}
+}
+
+// DefinitionName is the key of a named type in Definitions
+func DefinitionName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	return definitionNameSanitizer.ReplaceAllString(pkg+"."+t.Name(), "_")
+}
+
+// Ref returns a reference to the definition of t, it's reflected first if needed
+func (r *Reflector) Ref(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	name := DefinitionName(t)
+	if _, ok := r.Definitions[name]; !ok {
+		// placeholder breaks recursion of self referencing types
+		r.Definitions[name] = map[string]any{}
+		r.Definitions[name] = r.structSchema(t)
+	}
+	return map[string]any{"$ref": "#/definitions/" + name}
+}
+
+// Reflect returns the schema of t
+func (r *Reflector) Reflect(t reflect.Type) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		return map[string]any{
+			"anyOf": []any{r.Reflect(t.Elem()), map[string]any{"type": "null"}},
+		}
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	case t.Kind() != reflect.String && !strings.HasPrefix(t.PkgPath(), modulePath) &&
+		(t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonUnmarshallerType)):
+		return map[string]any{"description": fmt.Sprintf("%s has a custom encoding", DefinitionName(t))}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		// nil slices are encoded as null
+		return map[string]any{"type": []any{"array", "null"}, "items": r.Reflect(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": []any{"object", "null"}, "additionalProperties": r.Reflect(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		return r.Ref(t)
+	default:
+		return map[string]any{}
+	}
+}
+
+func (r *Reflector) structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	required := []any{}
+	r.collectFields(t, properties, &required)
+
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (r *Reflector) collectFields(t reflect.Type, properties map[string]any, required *[]any) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		// embedded structs without a name are flattened just like encoding/json does
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			r.collectFields(fieldType, properties, required)
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = r.Reflect(field.Type)
+
+		for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+			if rule == "required" {
+				*required = append(*required, name)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package kms
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// awsProvider wraps data keys with a key of AWS KMS, credentials are resolved by the default
+// chain of the sdk like environment variables and instance roles
+type awsProvider struct {
+	keyID       string
+	region      string
+	endpoint    string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	client      *http.Client
+}
+
+// NewAWSProvider accepts the id, arn or alias of a key, endpoint is only set for
+// compatible services like localstack
+func NewAWSProvider(keyID string, region string, endpoint string) (Provider, error) {
+	if keyID == "" || region == "" {
+		return nil, errors.New("KMS_AWS_KEY_ID and KMS_AWS_REGION are required by the aws kms provider")
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+	if endpoint == "" {
+		endpoint = "https://kms." + region + ".amazonaws.com/"
+	}
+	return &awsProvider{
+		keyID:       keyID,
+		region:      region,
+		endpoint:    endpoint,
+		credentials: cfg.Credentials,
+		signer:      v4.NewSigner(),
+		client:      &http.Client{Timeout: REQUEST_TIMEOUT},
+	}, nil
+}
+
+func (p *awsProvider) Name() string {
+	return PROVIDER_AWS
+}
+
+func (p *awsProvider) call(ctx context.Context, action string, body map[string]string, result any) error {
+	return postJSON(ctx, p.client, p.endpoint, body, result, func(request *http.Request, payload []byte) error {
+		request.Header.Set("Content-Type", "application/x-amz-json-1.1")
+		request.Header.Set("X-Amz-Target", "TrentService."+action)
+
+		credentials, err := p.credentials.Retrieve(ctx)
+		if err != nil {
+			return err
+		}
+		hash := sha256.Sum256(payload)
+		return p.signer.SignHTTP(ctx, credentials, request, hex.EncodeToString(hash[:]), "kms", p.region, time.Now())
+	})
+}
+
+func (p *awsProvider) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	var result struct {
+		CiphertextBlob string `json:"CiphertextBlob"`
+	}
+	if err := p.call(ctx, "Encrypt", map[string]string{
+		"KeyId":     p.keyID,
+		"Plaintext": base64.StdEncoding.EncodeToString(key),
+	}, &result); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(result.CiphertextBlob)
+}
+
+func (p *awsProvider) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var result struct {
+		Plaintext string `json:"Plaintext"`
+	}
+	if err := p.call(ctx, "Decrypt", map[string]string{
+		"KeyId":          p.keyID,
+		"CiphertextBlob": base64.StdEncoding.EncodeToString(wrapped),
+	}, &result); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(result.Plaintext)
+}
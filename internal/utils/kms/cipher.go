@@ -0,0 +1,67 @@
+package kms
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/encryption"
+)
+
+// Cipher encrypts with the kms provider if one is configured and with a static key otherwise,
+// values encrypted with the static key are still decrypted after a provider is configured
+type Cipher struct {
+	staticKey []byte
+}
+
+// NewCipher accepts any string as the static key as it's hashed to an aes-256 key, empty leaves it unset
+func NewCipher(staticKey string) *Cipher {
+	if staticKey == "" {
+		return &Cipher{}
+	}
+	hashed := sha256.Sum256([]byte(staticKey))
+	return &Cipher{staticKey: hashed[:]}
+}
+
+func (c *Cipher) Available() bool {
+	return Enabled() || c.staticKey != nil
+}
+
+func (c *Cipher) Encrypt(plaintext []byte, aad []byte) (string, error) {
+	if Enabled() {
+		return Encrypt(plaintext, aad)
+	}
+	if c.staticKey == nil {
+		return "", ErrNotConfigured
+	}
+	encrypted, err := encryption.AESEncryptWithAAD(c.staticKey, plaintext, aad)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(encrypted), nil
+}
+
+func (c *Cipher) Decrypt(value string, aad []byte) ([]byte, error) {
+	if IsEnvelope(value) {
+		return Decrypt(value, aad)
+	}
+	if c.staticKey == nil {
+		return nil, ErrNotConfigured
+	}
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return decrypt(c.staticKey, decoded, aad)
+}
+
+// decrypt opens a ciphertext bound to aad, values stored before they were bound to their row are
+// opened without it until they are written again
+func decrypt(key []byte, ciphertext []byte, aad []byte) ([]byte, error) {
+	plaintext, err := encryption.AESDecryptWithAAD(key, ciphertext, aad)
+	if err != nil && aad != nil {
+		if unbound, unboundErr := encryption.AESDecrypt(key, ciphertext); unboundErr == nil {
+			return unbound, nil
+		}
+	}
+	return plaintext, err
+}
@@ -0,0 +1,66 @@
+package kms
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	GCP_KMS_ENDPOINT = "https://cloudkms.googleapis.com/v1/"
+	GCP_KMS_SCOPE    = "https://www.googleapis.com/auth/cloudkms"
+)
+
+// gcpProvider wraps data keys with a key of Google Cloud KMS, the daemon authenticates with
+// application default credentials
+type gcpProvider struct {
+	keyName string
+	client  *http.Client
+}
+
+// NewGCPProvider expects the resource name of a key like
+// projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>
+func NewGCPProvider(keyName string) (Provider, error) {
+	if keyName == "" {
+		return nil, errors.New("KMS_GCP_KEY_NAME is required by the gcp kms provider")
+	}
+	client, err := google.DefaultClient(context.Background(), GCP_KMS_SCOPE)
+	if err != nil {
+		return nil, err
+	}
+	client.Timeout = REQUEST_TIMEOUT
+	return &gcpProvider{keyName: keyName, client: client}, nil
+}
+
+func (p *gcpProvider) Name() string {
+	return PROVIDER_GCP
+}
+
+func (p *gcpProvider) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	var result struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	err := postJSON(ctx, p.client, GCP_KMS_ENDPOINT+p.keyName+":encrypt", map[string]string{
+		"plaintext": base64.StdEncoding.EncodeToString(key),
+	}, &result, nil)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(result.Ciphertext)
+}
+
+func (p *gcpProvider) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var result struct {
+		Plaintext string `json:"plaintext"`
+	}
+	err := postJSON(ctx, p.client, GCP_KMS_ENDPOINT+p.keyName+":decrypt", map[string]string{
+		"ciphertext": base64.StdEncoding.EncodeToString(wrapped),
+	}, &result, nil)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(result.Plaintext)
+}
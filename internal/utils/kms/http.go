@@ -0,0 +1,53 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// postJSON sends body to url and decodes the json response into result, prepare sets headers
+// or signs the request before it's sent
+func postJSON(
+	ctx context.Context,
+	client *http.Client,
+	url string,
+	body any,
+	result any,
+	prepare func(request *http.Request, payload []byte) error,
+) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	if prepare != nil {
+		if err := prepare(request, payload); err != nil {
+			return err
+		}
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %d: %s", response.StatusCode, string(data))
+	}
+	return json.Unmarshal(data, result)
+}
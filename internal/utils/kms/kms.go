@@ -0,0 +1,167 @@
+// Package kms encrypts credentials with envelope encryption, every value is encrypted with a data key
+// of its own which is wrapped by a key management service and stored along with the value
+package kms
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/encryption"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/mapping"
+)
+
+const (
+	PROVIDER_LOCAL = "local"
+	PROVIDER_AWS   = "aws"
+	PROVIDER_GCP   = "gcp"
+	PROVIDER_VAULT = "vault"
+
+	ENVELOPE_PREFIX = "kms:v1:"
+	DATA_KEY_SIZE   = 32
+	REQUEST_TIMEOUT = time.Second * 10
+)
+
+var (
+	ErrNotConfigured   = errors.New("kms provider is not configured")
+	ErrInvalidEnvelope = errors.New("invalid kms envelope")
+)
+
+// Provider wraps and unwraps data keys, the master key never leaves the provider except for local
+type Provider interface {
+	Name() string
+	WrapKey(ctx context.Context, key []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+type Config struct {
+	Provider string
+
+	LocalMasterKey string
+
+	AWSKeyID    string
+	AWSRegion   string
+	AWSEndpoint string
+
+	GCPKeyName string
+
+	VaultAddress      string
+	VaultToken        string
+	VaultTransitMount string
+	VaultKeyName      string
+}
+
+var (
+	provider Provider
+
+	// unwrapping takes a round trip to the provider, data keys are kept once unwrapped
+	dataKeys mapping.Map[string, []byte]
+)
+
+func NewProvider(config Config) (Provider, error) {
+	switch config.Provider {
+	case PROVIDER_LOCAL:
+		return NewLocalProvider(config.LocalMasterKey)
+	case PROVIDER_AWS:
+		return NewAWSProvider(config.AWSKeyID, config.AWSRegion, config.AWSEndpoint)
+	case PROVIDER_GCP:
+		return NewGCPProvider(config.GCPKeyName)
+	case PROVIDER_VAULT:
+		return NewVaultProvider(config.VaultAddress, config.VaultToken, config.VaultTransitMount, config.VaultKeyName)
+	}
+	return nil, fmt.Errorf("unknown kms provider %s", config.Provider)
+}
+
+// Init sets the provider new values are encrypted with, nil disables envelope encryption
+func Init(p Provider) {
+	provider = p
+	dataKeys.Clear()
+}
+
+func Enabled() bool {
+	return provider != nil
+}
+
+func IsEnvelope(value string) bool {
+	return strings.HasPrefix(value, ENVELOPE_PREFIX)
+}
+
+// AdditionalData binds a value to the row it's stored in, like the tenant and the id of the row, so it
+// can't be moved to another row undetected
+func AdditionalData(fields ...string) []byte {
+	return []byte(strings.Join(fields, "\x00"))
+}
+
+// Encrypt encrypts plaintext bound to aad with a new data key and returns the envelope
+// kms:v1:<provider>:<wrapped data key>:<ciphertext>
+func Encrypt(plaintext []byte, aad []byte) (string, error) {
+	if provider == nil {
+		return "", ErrNotConfigured
+	}
+
+	key := make([]byte, DATA_KEY_SIZE)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), REQUEST_TIMEOUT)
+	defer cancel()
+
+	wrapped, err := provider.WrapKey(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to wrap data key with %s: %s", provider.Name(), err.Error())
+	}
+
+	ciphertext, err := encryption.AESEncryptWithAAD(key, plaintext, aad)
+	if err != nil {
+		return "", err
+	}
+
+	return ENVELOPE_PREFIX + provider.Name() + ":" +
+		base64.StdEncoding.EncodeToString(wrapped) + ":" +
+		base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
+func Decrypt(envelope string, aad []byte) ([]byte, error) {
+	if !IsEnvelope(envelope) {
+		return nil, ErrInvalidEnvelope
+	}
+	parts := strings.Split(strings.TrimPrefix(envelope, ENVELOPE_PREFIX), ":")
+	if len(parts) != 3 {
+		return nil, ErrInvalidEnvelope
+	}
+	if provider == nil {
+		return nil, ErrNotConfigured
+	}
+	if parts[0] != provider.Name() {
+		return nil, fmt.Errorf("value was encrypted by kms provider %s but %s is configured", parts[0], provider.Name())
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidEnvelope
+	}
+
+	key, ok := dataKeys.Load(parts[1])
+	if !ok {
+		wrapped, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, ErrInvalidEnvelope
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), REQUEST_TIMEOUT)
+		defer cancel()
+
+		key, err = provider.UnwrapKey(ctx, wrapped)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unwrap data key with %s: %s", provider.Name(), err.Error())
+		}
+		dataKeys.Store(parts[1], key)
+	}
+
+	return decrypt(key, ciphertext, aad)
+}
@@ -0,0 +1,166 @@
+package kms
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLocalEnvelope(t *testing.T) {
+	provider, err := NewLocalProvider("master")
+	if err != nil {
+		t.Fatal(err)
+	}
+	Init(provider)
+	t.Cleanup(func() { Init(nil) })
+
+	first, err := Encrypt([]byte("sk-123"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := Encrypt([]byte("sk-123"), nil)
+	if !strings.HasPrefix(first, ENVELOPE_PREFIX+PROVIDER_LOCAL+":") || first == second {
+		t.Fatalf("expected every value to get a data key of its own, got %s and %s", first, second)
+	}
+
+	plaintext, err := Decrypt(first, nil)
+	if err != nil || string(plaintext) != "sk-123" {
+		t.Fatalf("unexpected plaintext %s %v", plaintext, err)
+	}
+
+	// a different master key can't unwrap the data key
+	other, _ := NewLocalProvider("other")
+	Init(other)
+	if _, err := Decrypt(first, nil); err == nil {
+		t.Fatal("expected decryption with another master key to fail")
+	}
+}
+
+func TestVaultProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		// the fake transit engine reverses the base64 encoded plaintext
+		data := map[string]string{}
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/credentials":
+			data["ciphertext"] = "vault:v1:" + reverse(body["plaintext"])
+		case "/v1/transit/decrypt/credentials":
+			data["plaintext"] = reverse(strings.TrimPrefix(body["ciphertext"], "vault:v1:"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	defer server.Close()
+
+	provider, err := NewProvider(Config{
+		Provider:     PROVIDER_VAULT,
+		VaultAddress: server.URL + "/",
+		VaultToken:   "token",
+		VaultKeyName: "credentials",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Init(provider)
+	t.Cleanup(func() { Init(nil) })
+
+	envelope, err := Encrypt([]byte("refresh-token"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := Decrypt(envelope, nil)
+	if err != nil || string(plaintext) != "refresh-token" {
+		t.Fatalf("unexpected plaintext %s %v", plaintext, err)
+	}
+}
+
+func TestCipherDecryptsStaticKeyValues(t *testing.T) {
+	Init(nil)
+	cipher := NewCipher("static")
+
+	legacy, err := cipher.Encrypt([]byte("secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsEnvelope(legacy) {
+		t.Fatal("expected the static key to be used without a provider")
+	}
+	if _, err := base64.StdEncoding.DecodeString(legacy); err != nil {
+		t.Fatal("expected values of the static key to stay base64 encoded")
+	}
+
+	provider, _ := NewLocalProvider("master")
+	Init(provider)
+	t.Cleanup(func() { Init(nil) })
+
+	envelope, err := cipher.Encrypt([]byte("secret"), nil)
+	if err != nil || !IsEnvelope(envelope) {
+		t.Fatalf("expected the provider to be used once configured, got %s %v", envelope, err)
+	}
+
+	for _, value := range []string{legacy, envelope} {
+		plaintext, err := cipher.Decrypt(value, nil)
+		if err != nil || string(plaintext) != "secret" {
+			t.Fatalf("unexpected plaintext %s %v", plaintext, err)
+		}
+	}
+
+	if !NewCipher("").Available() {
+		t.Fatal("expected a cipher without static key to be available with a provider")
+	}
+}
+
+func TestAdditionalDataBindsValues(t *testing.T) {
+	Init(nil)
+	cipher := NewCipher("static")
+	row := AdditionalData("tenant", "row")
+
+	unbound, _ := cipher.Encrypt([]byte("secret"), nil)
+	bound, err := cipher.Encrypt([]byte("secret"), row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := cipher.Decrypt(bound, row); err != nil || string(plaintext) != "secret" {
+		t.Fatalf("unexpected plaintext %s %v", plaintext, err)
+	}
+	for _, other := range [][]byte{AdditionalData("tenant", "other"), AdditionalData("other", "row"), nil} {
+		if _, err := cipher.Decrypt(bound, other); err == nil {
+			t.Fatalf("a value moved to %q should not be decrypted", other)
+		}
+	}
+	// values stored before they were bound stay readable
+	if plaintext, err := cipher.Decrypt(unbound, row); err != nil || string(plaintext) != "secret" {
+		t.Fatalf("unexpected plaintext %s %v", plaintext, err)
+	}
+
+	provider, _ := NewLocalProvider("master")
+	Init(provider)
+	t.Cleanup(func() { Init(nil) })
+
+	envelope, err := cipher.Encrypt([]byte("secret"), row)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cipher.Decrypt(envelope, AdditionalData("tenant", "other")); err == nil {
+		t.Fatal("an envelope moved to another row should not be decrypted")
+	}
+}
+
+func reverse(value string) string {
+	runes := []rune(value)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
@@ -0,0 +1,36 @@
+package kms
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/encryption"
+)
+
+// localProvider wraps data keys with a master key from the configuration, it's meant for
+// deployments without a key management service
+type localProvider struct {
+	masterKey []byte
+}
+
+// NewLocalProvider accepts any string as the master key as it's hashed to an aes-256 key
+func NewLocalProvider(masterKey string) (Provider, error) {
+	if masterKey == "" {
+		return nil, errors.New("KMS_LOCAL_MASTER_KEY is required by the local kms provider")
+	}
+	hashed := sha256.Sum256([]byte(masterKey))
+	return &localProvider{masterKey: hashed[:]}, nil
+}
+
+func (p *localProvider) Name() string {
+	return PROVIDER_LOCAL
+}
+
+func (p *localProvider) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	return encryption.AESEncrypt(p.masterKey, key)
+}
+
+func (p *localProvider) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	return encryption.AESDecrypt(p.masterKey, wrapped)
+}
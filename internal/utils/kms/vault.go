@@ -0,0 +1,84 @@
+package kms
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// vaultProvider wraps data keys with the transit secrets engine of HashiCorp Vault
+type vaultProvider struct {
+	address string
+	token   string
+	mount   string
+	key     string
+	client  *http.Client
+}
+
+func NewVaultProvider(address string, token string, mount string, key string) (Provider, error) {
+	if address == "" || token == "" || key == "" {
+		return nil, errors.New("KMS_VAULT_ADDRESS, KMS_VAULT_TOKEN and KMS_VAULT_KEY_NAME are required by the vault kms provider")
+	}
+	if mount == "" {
+		mount = "transit"
+	}
+	return &vaultProvider{
+		address: strings.TrimSuffix(address, "/"),
+		token:   token,
+		mount:   strings.Trim(mount, "/"),
+		key:     key,
+		client:  &http.Client{Timeout: REQUEST_TIMEOUT},
+	}, nil
+}
+
+func (p *vaultProvider) Name() string {
+	return PROVIDER_VAULT
+}
+
+func (p *vaultProvider) call(ctx context.Context, operation string, body map[string]string) (map[string]string, error) {
+	var result struct {
+		Data map[string]string `json:"data"`
+	}
+	err := postJSON(
+		ctx,
+		p.client,
+		fmt.Sprintf("%s/v1/%s/%s/%s", p.address, p.mount, operation, p.key),
+		body,
+		&result,
+		func(request *http.Request, payload []byte) error {
+			request.Header.Set("X-Vault-Token", p.token)
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+func (p *vaultProvider) WrapKey(ctx context.Context, key []byte) ([]byte, error) {
+	data, err := p.call(ctx, "encrypt", map[string]string{
+		"plaintext": base64.StdEncoding.EncodeToString(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if data["ciphertext"] == "" {
+		return nil, errors.New("vault returned no ciphertext")
+	}
+	// vault ciphertexts look like vault:v1:..., they are kept as they are
+	return []byte(data["ciphertext"]), nil
+}
+
+func (p *vaultProvider) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	data, err := p.call(ctx, "decrypt", map[string]string{
+		"ciphertext": string(wrapped),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(data["plaintext"])
+}
@@ -6,9 +6,18 @@ package log
 */
 
 import (
+	"context"
 	"fmt"
+	"io"
 	go_log "log"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 var show_log bool = true
@@ -22,46 +31,268 @@ const (
 	LOG_LEVEL_COLOR_END   = "\033[0m"
 )
 
-func writeLog(level string, format string, stdout bool, v ...interface{}) {
-	//write log
-	format = fmt.Sprintf("["+level+"]"+format, v...)
+const (
+	LOG_FORMAT_TEXT = "text"
+	LOG_FORMAT_JSON = "json"
+)
+
+const (
+	FIELD_PLUGIN_ID  = "plugin_id"
+	FIELD_TENANT_ID  = "tenant_id"
+	FIELD_SESSION_ID = "session_id"
+)
+
+// Fields are attached to a log line, FIELD_PLUGIN_ID also routes the line to the plugin log file
+type Fields map[string]any
+
+var (
+	// mu guards the settings below
+	mu          sync.Mutex
+	format      = LOG_FORMAT_TEXT
+	jsonHandler = newJSONHandler(os.Stdout)
+
+	pluginLogDir   string
+	pluginLogFiles = map[string]*pluginLogFile{}
+)
+
+type pluginLogFile struct {
+	file    *os.File
+	handler slog.Handler
+}
+
+var slogLevels = map[string]slog.Level{
+	"DEBUG": slog.LevelDebug,
+	"INFO":  slog.LevelInfo,
+	"WARN":  slog.LevelWarn,
+	"ERROR": slog.LevelError,
+	"PANIC": slog.LevelError + 4,
+}
+
+func newJSONHandler(w io.Writer) slog.Handler {
+	return slog.NewJSONHandler(w, &slog.HandlerOptions{
+		AddSource: true,
+		Level:     slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			switch a.Key {
+			case slog.LevelKey:
+				if level, ok := a.Value.Any().(slog.Level); ok && level == slogLevels["PANIC"] {
+					return slog.String(slog.LevelKey, "PANIC")
+				}
+			case slog.SourceKey:
+				if source, ok := a.Value.Any().(*slog.Source); ok {
+					return slog.String("caller", fmt.Sprintf("%s:%d", filepath.Base(source.File), source.Line))
+				}
+			}
+			return a
+		},
+	})
+}
+
+// record builds a slog record of the caller skip frames above writeLog
+func record(level string, message string, fields Fields, skip int) slog.Record {
+	var pcs [1]uintptr
+	runtime.Callers(skip+2, pcs[:])
+	r := slog.NewRecord(time.Now(), slogLevels[level], message, pcs[0])
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		r.AddAttrs(slog.Any(key, fields[key]))
+	}
+	return r
+}
+
+// textFields renders fields as sorted key=value pairs appended to text lines
+func textFields(fields Fields) string {
+	if len(fields) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	builder := strings.Builder{}
+	for _, key := range keys {
+		builder.WriteString(fmt.Sprintf(" %s=%v", key, fields[key]))
+	}
+	return builder.String()
+}
+
+func writeLog(level string, format string, stdout bool, fields Fields, v ...interface{}) {
+	message := fmt.Sprintf(format, v...)
+
+	if pluginID, ok := fields[FIELD_PLUGIN_ID].(string); ok && pluginID != "" {
+		writePluginLog(pluginID, record(level, message, fields, 2))
+	}
 
+	//write log
 	if show_log && stdout {
-		if level == "DEBUG" {
-			logger.Output(3, LOG_LEVEL_DEBUG_COLOR+format+LOG_LEVEL_COLOR_END)
-		} else if level == "INFO" {
-			logger.Output(3, LOG_LEVEL_INFO_COLOR+format+LOG_LEVEL_COLOR_END)
-		} else if level == "WARN" {
-			logger.Output(3, LOG_LEVEL_WARN_COLOR+format+LOG_LEVEL_COLOR_END)
-		} else if level == "ERROR" {
-			logger.Output(3, LOG_LEVEL_ERROR_COLOR+format+LOG_LEVEL_COLOR_END)
-		} else if level == "PANIC" {
-			logger.Output(3, LOG_LEVEL_ERROR_COLOR+format+LOG_LEVEL_COLOR_END)
-			panic(format)
+		writeOutput(level, message, fields)
+		if level == "PANIC" {
+			panic(message)
+		}
+	}
+}
+
+func writeOutput(level string, message string, fields Fields) {
+	mu.Lock()
+	f, handler := format, jsonHandler
+	mu.Unlock()
+
+	if f == LOG_FORMAT_JSON {
+		handler.Handle(context.Background(), record(level, message, fields, 3))
+		return
+	}
+
+	line := "[" + level + "]" + message + textFields(fields)
+	if level == "DEBUG" {
+		logger.Output(4, LOG_LEVEL_DEBUG_COLOR+line+LOG_LEVEL_COLOR_END)
+	} else if level == "INFO" {
+		logger.Output(4, LOG_LEVEL_INFO_COLOR+line+LOG_LEVEL_COLOR_END)
+	} else if level == "WARN" {
+		logger.Output(4, LOG_LEVEL_WARN_COLOR+line+LOG_LEVEL_COLOR_END)
+	} else if level == "ERROR" || level == "PANIC" {
+		logger.Output(4, LOG_LEVEL_ERROR_COLOR+line+LOG_LEVEL_COLOR_END)
+	}
+}
+
+// writePluginLog appends a json line to the file of the plugin if plugin log routing is enabled
+func writePluginLog(pluginID string, r slog.Record) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if pluginLogDir == "" {
+		return
+	}
+
+	f, ok := pluginLogFiles[pluginID]
+	if !ok {
+		name := strings.NewReplacer("/", "_", ":", "_", "@", "_").Replace(pluginID) + ".log"
+		file, err := os.OpenFile(filepath.Join(pluginLogDir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			logger.Output(2, LOG_LEVEL_ERROR_COLOR+"[ERROR]failed to open log file of plugin "+pluginID+": "+err.Error()+LOG_LEVEL_COLOR_END)
+			return
 		}
+		f = &pluginLogFile{file: file, handler: newJSONHandler(file)}
+		pluginLogFiles[pluginID] = f
 	}
+
+	f.handler.Handle(context.Background(), r)
 }
 
 func SetLogVisibility(show bool) {
 	show_log = show
 }
 
+// SetOutput redirects logs, they are written to stdout by default
+func SetOutput(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	logger.SetOutput(w)
+	jsonHandler = newJSONHandler(w)
+}
+
+// SetFormat switches between colored text lines and one json object per line
+func SetFormat(f string) error {
+	if f != LOG_FORMAT_TEXT && f != LOG_FORMAT_JSON {
+		return fmt.Errorf("unknown log format: %s", f)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	format = f
+	return nil
+}
+
+// SetPluginLogDir routes lines carrying FIELD_PLUGIN_ID to one json file per plugin in dir
+// as well, so a single plugin can be tailed, empty disables it
+func SetPluginLogDir(dir string) error {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, f := range pluginLogFiles {
+		f.file.Close()
+	}
+	pluginLogFiles = map[string]*pluginLogFile{}
+	pluginLogDir = dir
+	return nil
+}
+
 func Debug(format string, v ...interface{}) {
-	writeLog("DEBUG", format, true, v...)
+	writeLog("DEBUG", format, true, nil, v...)
 }
 
 func Info(format string, v ...interface{}) {
-	writeLog("INFO", format, true, v...)
+	writeLog("INFO", format, true, nil, v...)
 }
 
 func Warn(format string, v ...interface{}) {
-	writeLog("WARN", format, true, v...)
+	writeLog("WARN", format, true, nil, v...)
 }
 
 func Error(format string, v ...interface{}) {
-	writeLog("ERROR", format, true, v...)
+	writeLog("ERROR", format, true, nil, v...)
 }
 
 func Panic(format string, v ...interface{}) {
-	writeLog("PANIC", format, true, v...)
+	writeLog("PANIC", format, true, nil, v...)
+}
+
+// Entry logs with fields attached
+type Entry struct {
+	fields Fields
+}
+
+func With(fields Fields) *Entry {
+	return &Entry{fields: fields}
+}
+
+// WithPlugin is a shortcut of With for the most common field
+func WithPlugin(pluginID string) *Entry {
+	return With(Fields{FIELD_PLUGIN_ID: pluginID})
+}
+
+// With returns a new entry with fields merged into those of e
+func (e *Entry) With(fields Fields) *Entry {
+	merged := Fields{}
+	for key, value := range e.fields {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return &Entry{fields: merged}
+}
+
+func (e *Entry) Debug(format string, v ...interface{}) {
+	writeLog("DEBUG", format, true, e.fields, v...)
+}
+
+func (e *Entry) Info(format string, v ...interface{}) {
+	writeLog("INFO", format, true, e.fields, v...)
+}
+
+func (e *Entry) Warn(format string, v ...interface{}) {
+	writeLog("WARN", format, true, e.fields, v...)
+}
+
+func (e *Entry) Error(format string, v ...interface{}) {
+	writeLog("ERROR", format, true, e.fields, v...)
+}
+
+func (e *Entry) Panic(format string, v ...interface{}) {
+	writeLog("PANIC", format, true, e.fields, v...)
 }
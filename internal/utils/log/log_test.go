@@ -0,0 +1,54 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStructuredLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	SetOutput(buf)
+	defer SetOutput(os.Stdout)
+
+	With(Fields{FIELD_TENANT_ID: "tenant", FIELD_SESSION_ID: "session"}).Info("hello %s", "world")
+	assert.Contains(t, buf.String(), "[INFO]hello world session_id=session tenant_id=tenant")
+	assert.Contains(t, buf.String(), "log_test.go")
+
+	buf.Reset()
+	assert.NoError(t, SetFormat(LOG_FORMAT_JSON))
+	defer SetFormat(LOG_FORMAT_TEXT)
+
+	WithPlugin("langgenius/openai").With(Fields{FIELD_SESSION_ID: "session"}).Warn("slow")
+	line := map[string]any{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "WARN", line["level"])
+	assert.Equal(t, "slow", line["msg"])
+	assert.Equal(t, "langgenius/openai", line[FIELD_PLUGIN_ID])
+	assert.Equal(t, "session", line[FIELD_SESSION_ID])
+	assert.True(t, strings.HasPrefix(line["caller"].(string), "log_test.go:"))
+
+	assert.Error(t, SetFormat("xml"))
+}
+
+func TestPluginLogDir(t *testing.T) {
+	SetOutput(&bytes.Buffer{})
+	defer SetOutput(os.Stdout)
+
+	dir := t.TempDir()
+	assert.NoError(t, SetPluginLogDir(dir))
+	defer SetPluginLogDir("")
+
+	WithPlugin("langgenius/openai").Info("routed")
+	Info("not routed")
+
+	data, err := os.ReadFile(filepath.Join(dir, "langgenius_openai.log"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"msg":"routed"`)
+	assert.NotContains(t, string(data), "not routed")
+}
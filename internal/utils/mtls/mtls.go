@@ -0,0 +1,229 @@
+// Package mtls builds tls configs for mutual tls, the certificate, key and ca files are read again once
+// they change, so rotated certificates are picked up by new connections without a restart
+package mtls
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+var ErrNoCertificate = errors.New("no certificate is configured")
+
+type Files struct {
+	CertFile string
+	KeyFile  string
+	// CAFile verifies certificates of peers, the roots of the system are used if it's empty
+	CAFile string
+}
+
+// Loader keeps the files loaded, each handshake checks whether they changed
+type Loader struct {
+	files Files
+
+	mu                  sync.Mutex
+	certificate         *tls.Certificate
+	certificateModified time.Time
+	pool                *x509.CertPool
+	poolModified        time.Time
+}
+
+// NewLoader loads the files once to report broken ones at startup
+func NewLoader(files Files) (*Loader, error) {
+	if (files.CertFile == "") != (files.KeyFile == "") {
+		return nil, errors.New("both a cert file and a key file are needed")
+	}
+
+	loader := &Loader{files: files}
+	if files.CertFile != "" {
+		if _, err := loader.Certificate(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := loader.CertPool(); err != nil {
+		return nil, err
+	}
+	return loader, nil
+}
+
+func (l *Loader) Certificate() (*tls.Certificate, error) {
+	if l.files.CertFile == "" {
+		return nil, ErrNoCertificate
+	}
+
+	modified, err := lastModified(l.files.CertFile, l.files.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.certificate != nil && l.certificateModified.Equal(modified) {
+		return l.certificate, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(l.files.CertFile, l.files.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate %s: %v", l.files.CertFile, err)
+	}
+	l.certificate = &certificate
+	l.certificateModified = modified
+	return l.certificate, nil
+}
+
+// CertPool returns nil if no ca file is configured
+func (l *Loader) CertPool() (*x509.CertPool, error) {
+	if l.files.CAFile == "" {
+		return nil, nil
+	}
+
+	modified, err := lastModified(l.files.CAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.pool != nil && l.poolModified.Equal(modified) {
+		return l.pool, nil
+	}
+
+	content, err := os.ReadFile(l.files.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("no certificate found in %s", l.files.CAFile)
+	}
+	l.pool = pool
+	l.poolModified = modified
+	return l.pool, nil
+}
+
+// ServerConfig presents the certificate and verifies certificates of clients against the ca, clients
+// without certificate are accepted only if the certificate is optional, no client certificate is
+// requested without a ca
+//
+// clients are verified by VerifyPeerCertificate rather than ClientCAs to read the ca at each handshake,
+// so PeerCertificates of a connection are verified whenever they are present
+func (l *Loader) ServerConfig(optional bool) *tls.Config {
+	clientAuth := tls.RequireAnyClientCert
+	if l.files.CAFile == "" {
+		clientAuth = tls.NoClientCert
+	} else if optional {
+		clientAuth = tls.RequestClientCert
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: clientAuth,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return l.Certificate()
+		},
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return nil
+			}
+			return l.verify(rawCerts, "", x509.ExtKeyUsageClientAuth)
+		},
+	}
+}
+
+// ClientConfig presents the certificate if there is one and verifies the server against the ca and the
+// hostname, no hostname is verified if it's empty such as for other nodes of the cluster reached by ip
+func (l *Loader) ClientConfig(hostname string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			certificate, err := l.Certificate()
+			if err == ErrNoCertificate {
+				return &tls.Certificate{}, nil
+			}
+			return certificate, err
+		},
+		// the server is verified by VerifyConnection with the ca at the time of the handshake
+		InsecureSkipVerify: true,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			rawCerts := make([][]byte, 0, len(state.PeerCertificates))
+			for _, certificate := range state.PeerCertificates {
+				rawCerts = append(rawCerts, certificate.Raw)
+			}
+			return l.verify(rawCerts, hostname, x509.ExtKeyUsageServerAuth)
+		},
+	}
+}
+
+// PinnedClientConfig presents the certificate if there is one and only accepts servers presenting the
+// certificate of pinned, such as other nodes of a cluster sharing a certificate without a ca of their own
+func (l *Loader) PinnedClientConfig(pinned *Loader) *tls.Config {
+	config := l.ClientConfig("")
+	config.VerifyConnection = func(state tls.ConnectionState) error {
+		if len(state.PeerCertificates) == 0 {
+			return errors.New("no certificate presented")
+		}
+		certificate, err := pinned.Certificate()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(state.PeerCertificates[0].Raw, certificate.Certificate[0]) {
+			return errors.New("certificate does not match the pinned one")
+		}
+		return nil
+	}
+	return config
+}
+
+func (l *Loader) verify(rawCerts [][]byte, hostname string, usage x509.ExtKeyUsage) error {
+	if len(rawCerts) == 0 {
+		return errors.New("no certificate presented")
+	}
+
+	pool, err := l.CertPool()
+	if err != nil {
+		return err
+	}
+
+	certificates := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		certificate, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certificates = append(certificates, certificate)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, certificate := range certificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+
+	_, err = certificates[0].Verify(x509.VerifyOptions{
+		Roots:         pool,
+		Intermediates: intermediates,
+		DNSName:       hostname,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	})
+	return err
+}
+
+func lastModified(files ...string) (time.Time, error) {
+	var modified time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+	}
+	return modified, nil
+}
@@ -0,0 +1,189 @@
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testAuthority struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	pem         []byte
+}
+
+func newTestAuthority(t *testing.T) *testAuthority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(raw)
+	require.NoError(t, err)
+	return &testAuthority{
+		certificate: certificate,
+		key:         key,
+		pem:         pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw}),
+	}
+}
+
+// issue writes a certificate and its key to dir/name.crt and dir/name.key
+func (a *testAuthority) issue(t *testing.T, dir string, name string, serial int64) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, a.certificate, &key.PublicKey, a.key)
+	require.NoError(t, err)
+	keyRaw, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: raw}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyRaw}), 0o600))
+	return certFile, keyFile
+}
+
+func (a *testAuthority) write(t *testing.T, path string) string {
+	require.NoError(t, os.WriteFile(path, a.pem, 0o600))
+	return path
+}
+
+func serve(t *testing.T, config *tls.Config) string {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	require.NoError(t, err)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+		}
+	})}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	return "https://" + listener.Addr().String()
+}
+
+func get(url string, config *tls.Config) (string, error) {
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+	defer client.CloseIdleConnections()
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body := make([]byte, 64)
+	n, _ := resp.Body.Read(body)
+	return string(body[:n]), nil
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	authority := newTestAuthority(t)
+	caFile := authority.write(t, filepath.Join(dir, "ca.pem"))
+	serverCert, serverKey := authority.issue(t, dir, "server", 2)
+	clientCert, clientKey := authority.issue(t, dir, "client", 3)
+
+	serverLoader, err := NewLoader(Files{CertFile: serverCert, KeyFile: serverKey, CAFile: caFile})
+	require.NoError(t, err)
+	url := serve(t, serverLoader.ServerConfig(false))
+
+	clientLoader, err := NewLoader(Files{CertFile: clientCert, KeyFile: clientKey, CAFile: caFile})
+	require.NoError(t, err)
+	body, err := get(url, clientLoader.ClientConfig("127.0.0.1"))
+	require.NoError(t, err)
+	assert.Equal(t, "client", body)
+
+	// the server is verified against the ca and the hostname
+	_, err = get(url, clientLoader.ClientConfig("example.com"))
+	assert.Error(t, err)
+
+	anonymous, err := NewLoader(Files{CAFile: caFile})
+	require.NoError(t, err)
+	_, err = get(url, anonymous.ClientConfig("127.0.0.1"))
+	assert.Error(t, err)
+
+	// clients of another ca are rejected
+	otherDir := t.TempDir()
+	other := newTestAuthority(t)
+	otherCert, otherKey := other.issue(t, otherDir, "other", 4)
+	otherLoader, err := NewLoader(Files{CertFile: otherCert, KeyFile: otherKey, CAFile: caFile})
+	require.NoError(t, err)
+	_, err = get(url, otherLoader.ClientConfig("127.0.0.1"))
+	assert.Error(t, err)
+
+	// trusting the other ca takes effect without a restart
+	require.NoError(t, os.WriteFile(caFile, append(authority.pem, other.pem...), 0o600))
+	require.NoError(t, os.Chtimes(caFile, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+	body, err = get(url, otherLoader.ClientConfig(""))
+	require.NoError(t, err)
+	assert.Equal(t, "other", body)
+}
+
+func TestOptionalClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	authority := newTestAuthority(t)
+	caFile := authority.write(t, filepath.Join(dir, "ca.pem"))
+	serverCert, serverKey := authority.issue(t, dir, "server", 2)
+
+	serverLoader, err := NewLoader(Files{CertFile: serverCert, KeyFile: serverKey, CAFile: caFile})
+	require.NoError(t, err)
+	url := serve(t, serverLoader.ServerConfig(true))
+
+	anonymous, err := NewLoader(Files{CAFile: caFile})
+	require.NoError(t, err)
+	body, err := get(url, anonymous.ClientConfig("localhost"))
+	require.NoError(t, err)
+	assert.Empty(t, body)
+}
+
+func TestPinnedClientConfig(t *testing.T) {
+	dir := t.TempDir()
+	authority := newTestAuthority(t)
+	caFile := authority.write(t, filepath.Join(dir, "ca.pem"))
+	serverCert, serverKey := authority.issue(t, dir, "server", 2)
+	otherCert, otherKey := authority.issue(t, dir, "other", 3)
+
+	serverLoader, err := NewLoader(Files{CertFile: serverCert, KeyFile: serverKey, CAFile: caFile})
+	require.NoError(t, err)
+	url := serve(t, serverLoader.ServerConfig(true))
+
+	// nodes sharing the certificate accept each other, whatever the system roots or the ca trust
+	body, err := get(url, serverLoader.PinnedClientConfig(serverLoader))
+	require.NoError(t, err)
+	assert.Equal(t, "server", body)
+
+	otherLoader, err := NewLoader(Files{CertFile: otherCert, KeyFile: otherKey})
+	require.NoError(t, err)
+	_, err = get(url, otherLoader.PinnedClientConfig(otherLoader))
+	assert.Error(t, err)
+}
@@ -0,0 +1,180 @@
+package network
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PROXY_HEADER_TIMEOUT is how long a trusted proxy may take to send the PROXY header
+const PROXY_HEADER_TIMEOUT = 5 * time.Second
+
+var ErrIPNotAllowed = errors.New("ip is not allowed")
+
+// IPNets is a list of networks parsed from cidrs or single ips
+type IPNets []*net.IPNet
+
+func ParseIPNets(rules []string) (IPNets, error) {
+	nets := IPNets{}
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		if !strings.Contains(rule, "/") {
+			ip := net.ParseIP(rule)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip or cidr: %s", rule)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ip or cidr: %s", rule)
+		}
+		nets = append(nets, network)
+	}
+	return nets, nil
+}
+
+func (n IPNets) Contains(ip net.IP) bool {
+	for _, network := range n {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows is Contains for allow-lists, every ip is allowed by an empty list
+func (n IPNets) Allows(ip net.IP) bool {
+	return len(n) == 0 || n.Contains(ip)
+}
+
+// AddrIP returns the ip of a tcp address or of a `host:port` string
+func AddrIP(addr net.Addr) net.IP {
+	if addr == nil {
+		return nil
+	}
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		return tcp.IP
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	return net.ParseIP(host)
+}
+
+type ListenerConfig struct {
+	// ProxyProtocol reads a PROXY header from connections of TrustedProxies, the source of the header is
+	// the remote address of the connection
+	ProxyProtocol  bool
+	TrustedProxies IPNets
+	// AllowedIPs are matched against the remote address after the PROXY header and connections of other
+	// ips are closed, every ip is allowed if it's empty
+	AllowedIPs IPNets
+}
+
+type listener struct {
+	net.Listener
+	config ListenerConfig
+}
+
+// NewListener applies config to connections of l, the PROXY header is read by the goroutine serving a
+// connection at its first Read or RemoteAddr rather than by Accept, a slow client can't block others
+func NewListener(l net.Listener, config ListenerConfig) net.Listener {
+	if !config.ProxyProtocol && len(config.AllowedIPs) == 0 {
+		return l
+	}
+	return &listener{Listener: l, config: config}
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxiedConn{Conn: conn, config: &l.config, reader: bufio.NewReader(conn)}, nil
+}
+
+type proxiedConn struct {
+	net.Conn
+	config *ListenerConfig
+	reader *bufio.Reader
+
+	once   sync.Once
+	remote net.Addr
+	err    error
+}
+
+func (c *proxiedConn) init() {
+	c.once.Do(func() {
+		c.remote = c.Conn.RemoteAddr()
+		if c.config.ProxyProtocol && c.config.TrustedProxies.Contains(AddrIP(c.remote)) {
+			source, err := c.readProxyHeader()
+			if err != nil {
+				c.err = err
+			} else if source != nil {
+				c.remote = source
+			}
+		}
+		if c.err == nil && !c.config.AllowedIPs.Allows(AddrIP(c.remote)) {
+			c.err = ErrIPNotAllowed
+		}
+		if c.err != nil {
+			c.Conn.Close()
+		}
+	})
+}
+
+func (c *proxiedConn) readProxyHeader() (net.Addr, error) {
+	c.Conn.SetReadDeadline(time.Now().Add(PROXY_HEADER_TIMEOUT))
+	defer c.Conn.SetReadDeadline(time.Time{})
+
+	size := 1
+	for {
+		data, err := c.reader.Peek(size)
+		if err != nil {
+			return nil, err
+		}
+		// take everything received so far to need as few reads as possible
+		data, _ = c.reader.Peek(c.reader.Buffered())
+
+		source, length, err := ParseProxyHeader(data)
+		if err == ErrProxyHeaderIncomplete {
+			if len(data) >= PROXY_MAX_LENGTH {
+				return nil, ErrInvalidProxyHeader
+			}
+			size = len(data) + 1
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		_, err = c.reader.Discard(length)
+		return source, err
+	}
+}
+
+func (c *proxiedConn) Read(b []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+func (c *proxiedConn) RemoteAddr() net.Addr {
+	c.init()
+	return c.remote
+}
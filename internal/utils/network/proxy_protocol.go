@@ -0,0 +1,121 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PROXY_V1_MAX_LENGTH is the longest v1 header including the trailing CRLF
+	PROXY_V1_MAX_LENGTH = 107
+	// PROXY_MAX_LENGTH bounds v2 headers with their TLVs
+	PROXY_MAX_LENGTH = 4096
+)
+
+var (
+	ErrProxyHeaderIncomplete = errors.New("proxy protocol header is incomplete")
+	ErrInvalidProxyHeader    = errors.New("invalid proxy protocol header")
+
+	proxyV1Prefix    = []byte("PROXY ")
+	proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// ParseProxyHeader parses the PROXY protocol v1 or v2 header at the beginning of data and returns the
+// length of the header, source is nil for connections the proxy opened itself such as health checks
+func ParseProxyHeader(data []byte) (source net.Addr, length int, err error) {
+	switch {
+	case hasPrefix(data, proxyV2Signature):
+		return parseProxyV2(data)
+	case hasPrefix(data, proxyV1Prefix):
+		return parseProxyV1(data)
+	default:
+		return nil, 0, ErrInvalidProxyHeader
+	}
+}
+
+// hasPrefix is also true for data shorter than the prefix it begins with, the rest may not be received yet
+func hasPrefix(data []byte, prefix []byte) bool {
+	if len(data) < len(prefix) {
+		return bytes.HasPrefix(prefix, data)
+	}
+	return bytes.HasPrefix(data, prefix)
+}
+
+// parseProxyV1 parses `PROXY TCP4 <source> <destination> <source port> <destination port>\r\n`
+func parseProxyV1(data []byte) (net.Addr, int, error) {
+	end := bytes.Index(data, []byte("\r\n"))
+	if end < 0 {
+		if len(data) >= PROXY_V1_MAX_LENGTH {
+			return nil, 0, ErrInvalidProxyHeader
+		}
+		return nil, 0, ErrProxyHeaderIncomplete
+	}
+	length := end + 2
+	if length > PROXY_V1_MAX_LENGTH {
+		return nil, 0, ErrInvalidProxyHeader
+	}
+
+	fields := strings.Split(string(data[:end]), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, length, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, 0, ErrInvalidProxyHeader
+	}
+
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil || (fields[1] == "TCP4") != (ip.To4() != nil) {
+		return nil, 0, ErrInvalidProxyHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, length, nil
+}
+
+func parseProxyV2(data []byte) (net.Addr, int, error) {
+	if len(data) < 16 {
+		return nil, 0, ErrProxyHeaderIncomplete
+	}
+
+	version, command := data[12]>>4, data[12]&0x0f
+	if version != 2 || command > 1 {
+		return nil, 0, ErrInvalidProxyHeader
+	}
+
+	length := 16 + int(binary.BigEndian.Uint16(data[14:16]))
+	if length > PROXY_MAX_LENGTH {
+		return nil, 0, ErrInvalidProxyHeader
+	}
+	if len(data) < length {
+		return nil, 0, ErrProxyHeaderIncomplete
+	}
+
+	// LOCAL, the connection is not proxied
+	if command == 0 {
+		return nil, length, nil
+	}
+
+	addresses := data[16:length]
+	family := data[13] >> 4
+	switch {
+	case family == 1 && len(addresses) >= 12:
+		return &net.TCPAddr{
+			IP:   net.IP(bytes.Clone(addresses[0:4])),
+			Port: int(binary.BigEndian.Uint16(addresses[8:10])),
+		}, length, nil
+	case family == 2 && len(addresses) >= 36:
+		return &net.TCPAddr{
+			IP:   net.IP(bytes.Clone(addresses[0:16])),
+			Port: int(binary.BigEndian.Uint16(addresses[32:34])),
+		}, length, nil
+	case family == 0 || family == 3:
+		// unspecified or unix sockets, there is no ip to report
+		return nil, length, nil
+	default:
+		return nil, 0, fmt.Errorf("%w: address family %d", ErrInvalidProxyHeader, family)
+	}
+}
@@ -0,0 +1,110 @@
+package network
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func proxyV2Header(source net.IP, port uint16) []byte {
+	header := append([]byte{}, proxyV2Signature...)
+	header = append(header, 0x21, 0x11, 0, 12)
+	header = append(header, source.To4()...)
+	header = append(header, 10, 0, 0, 1)
+	header = binary.BigEndian.AppendUint16(header, port)
+	return binary.BigEndian.AppendUint16(header, 5003)
+}
+
+func TestParseProxyHeader(t *testing.T) {
+	source, length, err := ParseProxyHeader([]byte("PROXY TCP4 203.0.113.7 10.0.0.1 51234 5003\r\nhello"))
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.7:51234", source.String())
+	assert.Equal(t, 44, length)
+
+	source, _, err = ParseProxyHeader([]byte("PROXY TCP6 2001:db8::1 2001:db8::2 443 5003\r\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "[2001:db8::1]:443", source.String())
+
+	source, length, err = ParseProxyHeader([]byte("PROXY UNKNOWN\r\n"))
+	require.NoError(t, err)
+	assert.Nil(t, source)
+	assert.Equal(t, 15, length)
+
+	header := proxyV2Header(net.ParseIP("198.51.100.9"), 40000)
+	source, length, err = ParseProxyHeader(append(header, "hello"...))
+	require.NoError(t, err)
+	assert.Equal(t, "198.51.100.9:40000", source.String())
+	assert.Equal(t, len(header), length)
+
+	for _, partial := range [][]byte{[]byte("PRO"), []byte("PROXY TCP4 203.0"), header[:5], header[:20]} {
+		_, _, err = ParseProxyHeader(partial)
+		assert.ErrorIs(t, err, ErrProxyHeaderIncomplete, string(partial))
+	}
+
+	for _, invalid := range []string{"GET / HTTP/1.1\r\n", "PROXY TCP4 203.0.113.7\r\n", "PROXY TCP4 2001:db8::1 10.0.0.1 1 2\r\n"} {
+		_, _, err = ParseProxyHeader([]byte(invalid))
+		assert.ErrorIs(t, err, ErrInvalidProxyHeader, invalid)
+	}
+}
+
+func TestListener(t *testing.T) {
+	trusted, err := ParseIPNets([]string{"127.0.0.1"})
+	require.NoError(t, err)
+	allowed, err := ParseIPNets([]string{"203.0.113.0/24"})
+	require.NoError(t, err)
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	listener := NewListener(inner, ListenerConfig{ProxyProtocol: true, TrustedProxies: trusted, AllowedIPs: allowed})
+	t.Cleanup(func() { listener.Close() })
+
+	type accepted struct {
+		remote string
+		data   string
+		err    error
+	}
+	results := make(chan accepted)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				data := make([]byte, 5)
+				_, err := io.ReadFull(conn, data)
+				results <- accepted{remote: conn.RemoteAddr().String(), data: string(data), err: err}
+			}()
+		}
+	}()
+
+	send := func(data string) accepted {
+		conn, err := net.DialTimeout("tcp", listener.Addr().String(), time.Second)
+		require.NoError(t, err)
+		defer conn.Close()
+		// the header arrives in pieces
+		for _, part := range []string{data[:3], data[3:]} {
+			_, err = conn.Write([]byte(part))
+			require.NoError(t, err)
+			time.Sleep(10 * time.Millisecond)
+		}
+		return <-results
+	}
+
+	result := send("PROXY TCP4 203.0.113.7 10.0.0.1 51234 5003\r\nhello")
+	require.NoError(t, result.err)
+	assert.Equal(t, "203.0.113.7:51234", result.remote)
+	assert.Equal(t, "hello", result.data)
+
+	result = send(string(proxyV2Header(net.ParseIP("198.51.100.9"), 40000)) + "hello")
+	assert.ErrorIs(t, result.err, ErrIPNotAllowed)
+
+	result = send("hello")
+	assert.ErrorIs(t, result.err, ErrInvalidProxyHeader)
+}
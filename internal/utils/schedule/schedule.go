@@ -0,0 +1,108 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/routine"
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule returns the next activation time later than the given time
+type Schedule interface {
+	Next(time.Time) time.Time
+}
+
+var (
+	defaultLocation   = time.Local
+	defaultLocationMu sync.RWMutex
+
+	parser = cron.NewParser(
+		cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+	)
+)
+
+// SetDefaultTimezone sets the timezone of expressions without a CRON_TZ prefix,
+// an empty timezone keeps the local timezone of the process
+func SetDefaultTimezone(timezone string) error {
+	if timezone == "" {
+		return nil
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone %s: %w", timezone, err)
+	}
+
+	defaultLocationMu.Lock()
+	defaultLocation = location
+	defaultLocationMu.Unlock()
+	return nil
+}
+
+func DefaultLocation() *time.Location {
+	defaultLocationMu.RLock()
+	defer defaultLocationMu.RUnlock()
+	return defaultLocation
+}
+
+// Parse accepts standard 5-field cron expressions and descriptors like @daily and @every 1h,
+// expressions may be qualified with a timezone like "CRON_TZ=Asia/Shanghai 0 2 * * *",
+// unqualified ones are evaluated in the default timezone
+func Parse(expression string) (Schedule, error) {
+	return ParseIn(expression, DefaultLocation())
+}
+
+// ParseIn is Parse with unqualified expressions evaluated in location, like the timezone of a tenant
+func ParseIn(expression string, location *time.Location) (Schedule, error) {
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return nil, fmt.Errorf("empty schedule")
+	}
+
+	if !strings.HasPrefix(expression, "CRON_TZ=") && !strings.HasPrefix(expression, "TZ=") {
+		expression = fmt.Sprintf("CRON_TZ=%s %s", location.String(), expression)
+	}
+
+	schedule, err := parser.Parse(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %s: %w", expression, err)
+	}
+	return schedule, nil
+}
+
+// Every runs at a fixed interval regardless of timezones
+func Every(interval time.Duration) Schedule {
+	return cron.Every(interval)
+}
+
+// ParseOrEvery parses the expression of a job which may be configured with a cron expression,
+// it runs every interval if the expression is empty
+func ParseOrEvery(expression string, interval time.Duration) (Schedule, error) {
+	if strings.TrimSpace(expression) == "" {
+		return Every(interval), nil
+	}
+	return Parse(expression)
+}
+
+// Run calls fn at every activation of schedule in the background
+func Run(name string, schedule Schedule, fn func()) {
+	routine.Submit(map[string]string{
+		"module":   "schedule",
+		"function": "Run",
+		"schedule": name,
+	}, func() {
+		for {
+			now := time.Now()
+			next := schedule.Next(now)
+			// a schedule which never activates again, like Feb 30
+			if next.IsZero() {
+				return
+			}
+			time.Sleep(next.Sub(now))
+			fn()
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWithTimezone(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	assert.NoError(t, err)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	s, err := Parse("CRON_TZ=Asia/Shanghai 0 2 * * *")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 2, 2, 0, 0, 0, shanghai).Unix(), s.Next(now).Unix())
+
+	// unqualified expressions follow the default timezone
+	assert.NoError(t, SetDefaultTimezone("Asia/Shanghai"))
+	t.Cleanup(func() { defaultLocation = time.Local })
+	s, err = Parse("0 2 * * *")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 2, 2, 0, 0, 0, shanghai).Unix(), s.Next(now).Unix())
+
+	s, err = Parse("@every 1h")
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(time.Hour), s.Next(now))
+
+	_, err = Parse("0 2 * *")
+	assert.Error(t, err)
+	assert.Error(t, SetDefaultTimezone("Mars/Olympus"))
+}
+
+func TestParseInLocation(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(t, err)
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	s, err := ParseIn("0 2 * * *", berlin)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 2, 2, 0, 0, 0, berlin).Unix(), s.Next(now).Unix())
+
+	// a qualified expression keeps its own timezone
+	s, err = ParseIn("CRON_TZ=UTC 0 2 * * *", berlin)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 2, 2, 0, 0, 0, time.UTC).Unix(), s.Next(now).Unix())
+}
+
+func TestParseOrEvery(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	s, err := ParseOrEvery("", 5*time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(5*time.Minute), s.Next(now))
+
+	s, err = ParseOrEvery("CRON_TZ=UTC 30 * * * *", 5*time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(30*time.Minute), s.Next(now))
+
+	_, err = ParseOrEvery("30 * *", 5*time.Minute)
+	assert.Error(t, err)
+}
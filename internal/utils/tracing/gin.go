@@ -0,0 +1,46 @@
+package tracing
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GinMiddleware starts a server span for each request, the incoming traceparent
+// header is respected so that spans are attached to the trace of Dify API
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+
+		ctx, span := Start(
+			ctx,
+			fmt.Sprintf("%s %s", c.Request.Method, route),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(route),
+				attribute.String("tenant_id", c.Param("tenant_id")),
+			),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, fmt.Sprintf("status code %d", status))
+		}
+	}
+}
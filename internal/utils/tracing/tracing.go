@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	TRACER_NAME = "github.com/langgenius/dify-plugin-daemon"
+)
+
+type TracingConfig struct {
+	ServiceName string
+	// Endpoint is the url of OTLP/HTTP collector, e.g. http://localhost:4318
+	Endpoint   string
+	SampleRate float64
+}
+
+var (
+	propagator = propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	)
+)
+
+// Init installs a global tracer provider which exports spans to an OTLP collector,
+// returns a function to flush and shutdown the provider
+func Init(config TracingConfig) (func(context.Context) error, error) {
+	options := []otlptracehttp.Option{}
+	if config.Endpoint != "" {
+		options = append(options, otlptracehttp.WithEndpointURL(config.Endpoint))
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(), options...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(config.ServiceName),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRate))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer of plugin daemon, it's a no-op tracer if Init was never called
+func Tracer() trace.Tracer {
+	return otel.Tracer(TRACER_NAME)
+}
+
+// Start starts a new span as a child of the span in ctx
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, opts...)
+}
+
+// Inject serializes the trace context in ctx into a carrier map,
+// the result could be stored in cache or sent to plugins as headers
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// Extract restores the trace context from a carrier map produced by Inject
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.MapCarrier(carrier))
+}
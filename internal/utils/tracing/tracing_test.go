@@ -0,0 +1,36 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestInjectExtract(t *testing.T) {
+	provider := sdktrace.NewTracerProvider()
+	defer provider.Shutdown(context.Background())
+
+	ctx, span := provider.Tracer(TRACER_NAME).Start(context.Background(), "test")
+	defer span.End()
+
+	carrier := Inject(ctx)
+	if carrier["traceparent"] == "" {
+		t.Fatalf("traceparent not injected: %v", carrier)
+	}
+
+	restored := trace.SpanContextFromContext(Extract(context.Background(), carrier))
+	if restored.TraceID() != span.SpanContext().TraceID() {
+		t.Fatalf("trace id mismatch, expected %s, got %s", span.SpanContext().TraceID(), restored.TraceID())
+	}
+	if !restored.IsRemote() {
+		t.Fatalf("restored span context should be remote")
+	}
+}
+
+func TestInjectWithoutSpan(t *testing.T) {
+	if carrier := Inject(context.Background()); carrier != nil {
+		t.Fatalf("expected nil carrier, got %v", carrier)
+	}
+}
@@ -0,0 +1,199 @@
+package vault
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/log"
+)
+
+// Secrets are the secrets fetched from vault by the name of the setting they are for, they are only
+// kept in memory, the environment inherited by plugin processes never contains them
+type Secrets map[string]string
+
+// Load fetches the secrets listed in VAULT_SECRETS, the credentials of the daemon for vault are removed
+// from the environment once used, leases of the token and of dynamic secrets are renewed in the
+// background for as long as vault allows
+func Load() (Secrets, error) {
+	var config Config
+	if err := envconfig.Process("", &config); err != nil {
+		return nil, err
+	}
+	if config.Address == "" {
+		return nil, nil
+	}
+	for _, name := range []string{"VAULT_TOKEN", "VAULT_SECRET_ID"} {
+		os.Unsetenv(name)
+	}
+	if len(config.Secrets) == 0 {
+		return nil, nil
+	}
+
+	references, err := parseReferences(config.Secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &client{
+		address:   strings.TrimSuffix(config.Address, "/"),
+		namespace: config.Namespace,
+		http:      &http.Client{Timeout: REQUEST_TIMEOUT},
+	}
+
+	login, err := c.login(config)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, leases, err := c.fetch(references)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("loaded %d secrets from vault", len(secrets))
+
+	go c.renewToken(login)
+	for _, lease := range leases {
+		go c.renewLease(lease.LeaseID, lease.LeaseDuration)
+	}
+	return secrets, nil
+}
+
+// Apply sets the fields of spec whose envconfig name is the name of a secret, it's called after
+// envconfig.Process so that secrets take precedence over the environment and .env
+func (s Secrets) Apply(spec any) error {
+	if len(s) == 0 {
+		return nil
+	}
+
+	value := reflect.ValueOf(spec).Elem()
+	applied := map[string]bool{}
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name := field.Tag.Get("envconfig")
+		if name == "" {
+			name = strings.ToUpper(field.Name)
+		}
+		secret, ok := s[name]
+		if !ok {
+			continue
+		}
+		if err := setField(value.Field(i), secret); err != nil {
+			return fmt.Errorf("invalid vault secret %s: %s", name, err.Error())
+		}
+		applied[name] = true
+	}
+
+	for name := range s {
+		if !applied[name] {
+			log.Warn("vault secret %s is not a setting of the daemon, it's ignored", name)
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Pointer {
+		field.Set(reflect.New(field.Type().Elem()))
+		field = field.Elem()
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 0, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 0, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", field.Type())
+		}
+		parts := strings.Split(value, ",")
+		field.Set(reflect.ValueOf(parts).Convert(field.Type()))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+	return nil
+}
+
+// renewalDelay renews at two thirds of the lease to leave time for retries
+func renewalDelay(seconds int) time.Duration {
+	return time.Duration(seconds) * time.Second * 2 / 3
+}
+
+func (c *client) renewToken(login *secret) {
+	ttl := 0
+	if login != nil {
+		if !login.Auth.Renewable {
+			return
+		}
+		ttl = login.Auth.LeaseDuration
+	} else {
+		self, err := c.request(http.MethodGet, "auth/token/lookup-self", nil)
+		if err != nil {
+			log.Warn("failed to look up the vault token, it won't be renewed: %s", err.Error())
+			return
+		}
+		renewable, _ := self.Data["renewable"].(bool)
+		seconds, _ := self.Data["ttl"].(float64)
+		if !renewable || seconds <= 0 {
+			return
+		}
+		ttl = int(seconds)
+	}
+
+	for ttl > 0 {
+		time.Sleep(renewalDelay(ttl))
+		renewed, err := c.request(http.MethodPost, "auth/token/renew-self", map[string]any{})
+		if err != nil || renewed.Auth == nil {
+			log.Error("failed to renew the vault token: %v", err)
+			return
+		}
+		ttl = renewed.Auth.LeaseDuration
+	}
+}
+
+func (c *client) renewLease(leaseID string, duration int) {
+	for duration > 0 {
+		time.Sleep(renewalDelay(duration))
+		renewed, err := c.request(http.MethodPut, "sys/leases/renew", map[string]any{
+			"lease_id":  leaseID,
+			"increment": duration,
+		})
+		if err != nil {
+			log.Error("failed to renew vault lease %s, secrets of it stop working once it expires: %s", leaseID, err.Error())
+			return
+		}
+		if renewed.LeaseDuration < duration {
+			// the max ttl of the lease is reached, the secret has to be fetched again with a restart
+			log.Warn("vault lease %s expires in %d seconds and can't be extended further", leaseID, renewed.LeaseDuration)
+		}
+		duration = renewed.LeaseDuration
+	}
+}
@@ -0,0 +1,195 @@
+// Package vault fetches secrets of the daemon itself like DB_PASSWORD from HashiCorp Vault at startup,
+// they are applied to the configuration without going through the environment
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	REQUEST_TIMEOUT = time.Second * 10
+)
+
+type Config struct {
+	Address   string `envconfig:"VAULT_ADDR"`
+	Namespace string `envconfig:"VAULT_NAMESPACE"`
+	Token     string `envconfig:"VAULT_TOKEN"`
+	// RoleID and SecretID log in with approle if no token is set
+	RoleID       string `envconfig:"VAULT_ROLE_ID"`
+	SecretID     string `envconfig:"VAULT_SECRET_ID"`
+	AppRoleMount string `envconfig:"VAULT_APPROLE_MOUNT" default:"approle"`
+	// Secrets lists paths whose keys are names of settings like secret/data/daemon,
+	// or single fields as NAME=path#field like DB_PASSWORD=database/creds/daemon#password
+	Secrets []string `envconfig:"VAULT_SECRETS"`
+}
+
+// reference is a secret to fetch, all keys of the secret are used if name is empty
+type reference struct {
+	name  string
+	path  string
+	field string
+}
+
+// secret is the part of a vault response the daemon uses
+type secret struct {
+	LeaseID       string         `json:"lease_id"`
+	LeaseDuration int            `json:"lease_duration"`
+	Renewable     bool           `json:"renewable"`
+	Data          map[string]any `json:"data"`
+	Auth          *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+}
+
+type client struct {
+	address   string
+	namespace string
+	token     string
+	http      *http.Client
+}
+
+func parseReferences(entries []string) ([]reference, error) {
+	references := []reference{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, location, ok := strings.Cut(entry, "=")
+		if !ok {
+			references = append(references, reference{path: strings.Trim(entry, "/")})
+			continue
+		}
+
+		path, field, ok := strings.Cut(location, "#")
+		if name == "" || path == "" || !ok || field == "" {
+			return nil, fmt.Errorf("invalid vault secret %s, expected NAME=path#field or a path", entry)
+		}
+		references = append(references, reference{name: name, path: strings.Trim(path, "/"), field: field})
+	}
+	return references, nil
+}
+
+func (c *client) request(method string, path string, body any) (*secret, error) {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	request, err := http.NewRequest(method, c.address+"/v1/"+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		request.Header.Set("X-Vault-Token", c.token)
+	}
+	if c.namespace != "" {
+		request.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("vault responded %d to %s: %s", response.StatusCode, path, string(data))
+	}
+
+	result := &secret{}
+	if err := json.Unmarshal(data, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// login returns the lease of the token the daemon got, nil if the token was configured
+func (c *client) login(config Config) (*secret, error) {
+	if config.Token != "" {
+		c.token = config.Token
+		return nil, nil
+	}
+	if config.RoleID == "" {
+		return nil, errors.New("VAULT_TOKEN or VAULT_ROLE_ID is required to fetch secrets from vault")
+	}
+
+	result, err := c.request(http.MethodPost, "auth/"+strings.Trim(config.AppRoleMount, "/")+"/login", map[string]string{
+		"role_id":   config.RoleID,
+		"secret_id": config.SecretID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Auth == nil || result.Auth.ClientToken == "" {
+		return nil, errors.New("vault returned no token for the approle login")
+	}
+	c.token = result.Auth.ClientToken
+	return result, nil
+}
+
+// values returns the data of a secret, data of kv version 2 is nested in data.data
+func (s *secret) values() map[string]any {
+	if nested, ok := s.Data["data"].(map[string]any); ok {
+		if _, ok := s.Data["metadata"]; ok {
+			return nested
+		}
+	}
+	return s.Data
+}
+
+// fetch reads every path once and returns the secrets of the references by name
+func (c *client) fetch(references []reference) (map[string]string, []*secret, error) {
+	secrets := map[string]*secret{}
+	leases := []*secret{}
+	env := map[string]string{}
+
+	for _, ref := range references {
+		s, ok := secrets[ref.path]
+		if !ok {
+			var err error
+			s, err = c.request(http.MethodGet, ref.path, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			secrets[ref.path] = s
+			if s.LeaseID != "" && s.Renewable {
+				leases = append(leases, s)
+			}
+		}
+
+		values := s.values()
+		if ref.name == "" {
+			for key, value := range values {
+				env[key] = fmt.Sprint(value)
+			}
+			continue
+		}
+
+		value, ok := values[ref.field]
+		if !ok {
+			return nil, nil, fmt.Errorf("vault secret %s has no field %s", ref.path, ref.field)
+		}
+		env[ref.name] = fmt.Sprint(value)
+	}
+
+	return env, leases, nil
+}
@@ -0,0 +1,120 @@
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/kelseyhightower/envconfig"
+)
+
+func TestParseReferences(t *testing.T) {
+	references, err := parseReferences([]string{"secret/data/daemon/", " ", "DB_PASSWORD=database/creds/daemon#password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(references) != 2 ||
+		references[0] != (reference{path: "secret/data/daemon"}) ||
+		references[1] != (reference{name: "DB_PASSWORD", path: "database/creds/daemon", field: "password"}) {
+		t.Fatalf("unexpected references %+v", references)
+	}
+
+	for _, invalid := range []string{"DB_PASSWORD=database/creds/daemon", "=path#field", "NAME=path#"} {
+		if _, err := parseReferences([]string{invalid}); err == nil {
+			t.Fatalf("expected %s to be invalid", invalid)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		if r.URL.Path == "/v1/auth/approle/login" {
+			json.NewEncoder(w).Encode(map[string]any{
+				"auth": map[string]any{"client_token": "token", "lease_duration": 0},
+			})
+			return
+		}
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/data/daemon":
+			json.NewEncoder(w).Encode(map[string]any{
+				"data": map[string]any{
+					"data":     map[string]any{"DIFY_INNER_API_KEY": "inner", "S3_SECRET": "s3"},
+					"metadata": map[string]any{"version": 1},
+				},
+			})
+		case "/v1/database/creds/daemon":
+			json.NewEncoder(w).Encode(map[string]any{
+				"lease_id":       "database/creds/daemon/1",
+				"lease_duration": 0,
+				"data":           map[string]any{"username": "v-daemon", "password": "p4ss"},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_ROLE_ID", "role")
+	t.Setenv("VAULT_SECRET_ID", "secret")
+	t.Setenv("VAULT_SECRETS", "secret/data/daemon,DB_USERNAME=database/creds/daemon#username,DB_PASSWORD=database/creds/daemon#password")
+	t.Setenv("DB_PASSWORD", "from-env")
+	t.Setenv("DIFY_INNER_API_KEY", "")
+	t.Setenv("DB_USERNAME", "")
+	t.Setenv("S3_SECRET", "")
+
+	secrets, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config struct {
+		DifyInnerApiKey string  `envconfig:"DIFY_INNER_API_KEY"`
+		DBUsername      string  `envconfig:"DB_USERNAME"`
+		DBPassword      string  `envconfig:"DB_PASSWORD"`
+		S3Secret        *string `envconfig:"S3_SECRET"`
+	}
+	if err := envconfig.Process("", &config); err != nil {
+		t.Fatal(err)
+	}
+	if err := secrets.Apply(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	if config.DifyInnerApiKey != "inner" || config.DBUsername != "v-daemon" || config.DBPassword != "p4ss" ||
+		config.S3Secret == nil || *config.S3Secret != "s3" {
+		t.Fatalf("secrets were not applied, got %+v", config)
+	}
+	if requests["/v1/database/creds/daemon"] != 1 {
+		t.Fatalf("expected a path to be read once, got %d", requests["/v1/database/creds/daemon"])
+	}
+
+	// plugin processes inherit the environment, neither the secrets nor the credentials may be in it
+	if os.Getenv("DB_PASSWORD") != "from-env" || os.Getenv("DIFY_INNER_API_KEY") != "" {
+		t.Fatal("secrets must not be put into the environment")
+	}
+	if _, ok := os.LookupEnv("VAULT_SECRET_ID"); ok {
+		t.Fatal("the credential of the daemon for vault should be removed from the environment")
+	}
+}
+
+func TestApplyRejectsInvalidValues(t *testing.T) {
+	var config struct {
+		Port int `envconfig:"SERVER_PORT"`
+	}
+	if err := (Secrets{"SERVER_PORT": "http"}).Apply(&config); err == nil {
+		t.Fatal("expected an invalid number to be rejected")
+	}
+	if err := (Secrets{"SERVER_PORT": "5002"}).Apply(&config); err != nil || config.Port != 5002 {
+		t.Fatalf("expected the port to be applied, got %d, %v", config.Port, err)
+	}
+}
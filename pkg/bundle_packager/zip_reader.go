@@ -0,0 +1,54 @@
+package bundle_packager
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/bundle_entities"
+)
+
+// ZipBundleReader reads the manifest and assets of a zipped bundle from a random access reader,
+// unlike MemoryZipBundlePackager nothing is loaded into memory until it's opened
+type ZipBundleReader struct {
+	zipReader *zip.Reader
+}
+
+func NewZipBundleReader(reader io.ReaderAt, size int64) (*ZipBundleReader, error) {
+	zipReader, err := zip.NewReader(reader, size)
+	if err != nil {
+		return nil, errors.New(strings.ReplaceAll(err.Error(), "zip", "difybndl"))
+	}
+
+	return &ZipBundleReader{zipReader: zipReader}, nil
+}
+
+// Manifest returns the manifest of the bundle
+func (r *ZipBundleReader) Manifest() (*bundle_entities.Bundle, error) {
+	manifestFile, err := r.zipReader.Open("manifest.yaml")
+	if err != nil {
+		return nil, err
+	}
+	defer manifestFile.Close()
+
+	manifestBytes, err := io.ReadAll(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle, err := parser.UnmarshalYamlBytes[bundle_entities.Bundle](manifestBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bundle, nil
+}
+
+// OpenAsset opens an asset of the bundle for reading, caller must close it
+// NOTE: path is the relative path to _assets folder
+func (r *ZipBundleReader) OpenAsset(name string) (io.ReadCloser, error) {
+	return r.zipReader.Open(path.Join("_assets", name))
+}
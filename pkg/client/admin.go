@@ -0,0 +1,11 @@
+package client
+
+// FetchNodeStats returns the runtimes, sessions and install queue of the node serving the request
+func (c *Client) FetchNodeStats() (*NodeStats, error) {
+	options, err := c.adminOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	return request[NodeStats](c, "GET", c.adminPath("stats"), options...)
+}
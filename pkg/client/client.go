@@ -0,0 +1,103 @@
+// Package client is a typed client of the http api of the plugin daemon, it covers installing
+// plugins, invoking them with streamed responses, querying declarations and admin operations
+package client
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	X_PLUGIN_ID     = "X-Plugin-ID"
+	X_API_KEY       = "X-Api-Key"
+	X_ADMIN_API_KEY = "X-Admin-Api-Key"
+)
+
+var (
+	ErrAdminApiKeyRequired = errors.New("admin api key is required for admin operations")
+)
+
+type NewClientPayload struct {
+	BaseUrl string
+	// ApiKey is the SERVER_KEY of the daemon
+	ApiKey string
+	// AdminApiKey is only needed by admin operations
+	AdminApiKey string
+	// WriteTimeout bounds sending a request until the response headers arrive, ReadTimeout bounds
+	// reading the response, both are in milliseconds and zero means no limit
+	WriteTimeout int64
+	ReadTimeout  int64
+}
+
+// Client talks to the http api of a plugin daemon
+type Client struct {
+	baseurl      *url.URL
+	client       *http.Client
+	apiKey       string
+	adminApiKey  string
+	writeTimeout time.Duration
+	readTimeout  time.Duration
+}
+
+func NewClient(payload NewClientPayload) (*Client, error) {
+	baseurl, err := url.Parse(payload.BaseUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		baseurl: baseurl,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Dial: (&net.Dialer{
+					Timeout:   5 * time.Second,
+					KeepAlive: 120 * time.Second,
+				}).Dial,
+				IdleConnTimeout: 120 * time.Second,
+			},
+		},
+		apiKey:       payload.ApiKey,
+		adminApiKey:  payload.AdminApiKey,
+		writeTimeout: time.Duration(payload.WriteTimeout) * time.Millisecond,
+		readTimeout:  time.Duration(payload.ReadTimeout) * time.Millisecond,
+	}, nil
+}
+
+// WithHTTPClient replaces the http client, e.g. to route requests through a custom transport
+func (c *Client) WithHTTPClient(client *http.Client) *Client {
+	copied := *c
+	copied.client = client
+	return &copied
+}
+
+func (c *Client) pluginPath(tenantID string, path ...string) string {
+	path = append([]string{"plugin", tenantID}, path...)
+	return c.baseurl.JoinPath(path...).String()
+}
+
+func (c *Client) adminPath(path ...string) string {
+	path = append([]string{"admin"}, path...)
+	return c.baseurl.JoinPath(path...).String()
+}
+
+func (c *Client) options(headers map[string]string, options ...option) []option {
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	headers[X_API_KEY] = c.apiKey
+
+	return append(options, withHeaders(headers))
+}
+
+func (c *Client) adminOptions(options ...option) ([]option, error) {
+	if c.adminApiKey == "" {
+		return nil, ErrAdminApiKeyRequired
+	}
+
+	return c.options(map[string]string{
+		X_ADMIN_API_KEY: c.adminApiKey,
+	}, options...), nil
+}
@@ -0,0 +1,239 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/types/exception"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
+)
+
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(NewClientPayload{
+		BaseUrl:     server.URL,
+		ApiKey:      "server-key",
+		AdminApiKey: "admin-key",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func writeResponse(w http.ResponseWriter, status int, response any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(parser.MarshalJsonBytes(response))
+}
+
+func TestClientRequest(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/plugin/tenant/management/install/upload/package", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(X_API_KEY) != "server-key" {
+			writeResponse(w, 401, exception.UnauthorizedError().ToResponse())
+			return
+		}
+		file, _, err := r.FormFile("dify_pkg")
+		if err != nil {
+			writeResponse(w, 200, exception.BadRequestError(err).ToResponse())
+			return
+		}
+		content, _ := io.ReadAll(file)
+		if string(content) != "package" || r.FormValue("verify_signature") != "true" {
+			writeResponse(w, 200, exception.BadRequestError(errors.New("unexpected form")).ToResponse())
+			return
+		}
+		writeResponse(w, 200, entities.NewSuccessResponse(map[string]any{
+			"unique_identifier": "langgenius/test:1.0.0@0123456789abcdef0123456789abcdef",
+		}))
+	})
+	mux.HandleFunc("/plugin/tenant/management/list", func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, 200, exception.ErrPluginNotFound().ToResponse())
+	})
+	mux.HandleFunc("/plugin/tenant/management/uninstall", func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, 200, exception.ErrPluginReferenced("in use", map[string]any{"safe": false}).ToResponse())
+	})
+	mux.HandleFunc("/plugin/tenant/management/install/queue", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("status") != "pending" || r.URL.Query().Get("page_size") != "10" {
+			writeResponse(w, 200, exception.BadRequestError(errors.New("unexpected query")).ToResponse())
+			return
+		}
+		writeResponse(w, 200, entities.NewSuccessResponse(InstallQueue{
+			Tasks: []InstallTask{{ID: "task", Status: "pending"}},
+			Queue: InstallQueueStats{QueueSize: 100},
+		}))
+	})
+	mux.HandleFunc("/admin/stats", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(X_ADMIN_API_KEY) != "admin-key" {
+			writeResponse(w, 401, map[string]string{"message": "unauthorized"})
+			return
+		}
+		writeResponse(w, 200, entities.NewSuccessResponse(NodeStats{Sessions: 3}))
+	})
+	client := newTestClient(t, mux)
+
+	uploaded, err := client.UploadPackage("tenant", bytes.NewReader([]byte("package")), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uploaded.UniqueIdentifier.PluginID() != "langgenius/test" {
+		t.Fatalf("unexpected unique identifier %s", uploaded.UniqueIdentifier)
+	}
+
+	_, err = client.ListPlugins("tenant", 1, 10)
+	if !IsNotFound(err) {
+		t.Fatalf("expected plugin not found, got %v", err)
+	}
+
+	err = client.Uninstall("tenant", "installation", false)
+	var daemonErr *Error
+	if !errors.As(err, &daemonErr) || daemonErr.ErrorType != ERROR_TYPE_PLUGIN_REFERENCED || daemonErr.Code != -409 {
+		t.Fatalf("expected plugin referenced error, got %v", err)
+	}
+	if daemonErr.Args["safe"] != false {
+		t.Fatalf("expected args of the error to be kept, got %v", daemonErr.Args)
+	}
+
+	queue, err := client.FetchInstallQueue("tenant", "pending", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue.Tasks) != 1 || queue.Queue.QueueSize != 100 {
+		t.Fatalf("unexpected install queue %+v", queue)
+	}
+
+	stats, err := client.FetchNodeStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Sessions != 3 {
+		t.Fatalf("expected 3 sessions, got %d", stats.Sessions)
+	}
+
+	unauthorized := *client
+	unauthorized.adminApiKey = "wrong"
+	_, err = unauthorized.FetchNodeStats()
+	if !IsErrorType(err, ERROR_TYPE_UNAUTHORIZED) {
+		t.Fatalf("expected unauthorized, got %v", err)
+	}
+
+	unauthorized.adminApiKey = ""
+	if _, err := unauthorized.FetchNodeStats(); err != ErrAdminApiKeyRequired {
+		t.Fatalf("expected admin api key required, got %v", err)
+	}
+}
+
+func TestClientInvokeStream(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/plugin/tenant/dispatch/tool/invoke", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(X_PLUGIN_ID) != "langgenius/test" {
+			writeResponse(w, 404, exception.ErrPluginNotFound().ToResponse())
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, response := range []*entities.Response{
+			entities.NewSuccessResponse(tool_entities.ToolResponseChunk{
+				Type:    tool_entities.ToolResponseChunkTypeText,
+				Message: map[string]any{"text": "hello"},
+			}),
+			exception.InvokePluginError(errors.New("plugin crashed")).ToResponse(),
+		} {
+			w.Write([]byte("data: "))
+			w.Write(parser.MarshalJsonBytes(response))
+			w.Write([]byte("\n\n"))
+		}
+	})
+	client := newTestClient(t, mux)
+
+	request := &plugin_entities.InvokePluginRequest[requests.RequestInvokeTool]{
+		InvokePluginUserIdentity: plugin_entities.InvokePluginUserIdentity{TenantId: "tenant"},
+		BasePluginIdentifier:     plugin_entities.BasePluginIdentifier{PluginID: "langgenius/test"},
+	}
+
+	response, err := client.InvokeTool(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := 0
+	var streamErr error
+	for response.Next() {
+		chunk, err := response.Read()
+		if err != nil {
+			streamErr = err
+			break
+		}
+		if chunk.Message["text"] != "hello" {
+			t.Fatalf("unexpected chunk %v", chunk)
+		}
+		chunks++
+	}
+	if chunks != 1 {
+		t.Fatalf("expected 1 chunk, got %d", chunks)
+	}
+	if !IsErrorType(streamErr, ERROR_TYPE_PLUGIN_INVOKE) {
+		t.Fatalf("expected plugin invoke error, got %v", streamErr)
+	}
+
+	request.PluginID = "langgenius/missing"
+	if _, err := client.InvokeTool(request); !IsNotFound(err) {
+		t.Fatalf("expected plugin not found before streaming, got %v", err)
+	}
+}
+
+func TestClientStreamClose(t *testing.T) {
+	disconnected := make(chan bool)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/plugin/tenant/dispatch/tool/invoke", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: "))
+		w.Write(parser.MarshalJsonBytes(entities.NewSuccessResponse(tool_entities.ToolResponseChunk{
+			Type: tool_entities.ToolResponseChunkTypeText,
+		})))
+		w.Write([]byte("\n\n"))
+		w.(http.Flusher).Flush()
+
+		// the plugin keeps generating until the caller goes away
+		<-r.Context().Done()
+		close(disconnected)
+	})
+	client := newTestClient(t, mux)
+
+	response, err := client.InvokeTool(&plugin_entities.InvokePluginRequest[requests.RequestInvokeTool]{
+		InvokePluginUserIdentity: plugin_entities.InvokePluginUserIdentity{TenantId: "tenant"},
+		BasePluginIdentifier:     plugin_entities.BasePluginIdentifier{PluginID: "langgenius/test"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !response.Next() {
+		t.Fatal("expected a chunk")
+	}
+	if _, err := response.Read(); err != nil {
+		t.Fatal(err)
+	}
+
+	response.Close()
+	response.Close()
+	if response.Next() {
+		t.Fatal("a closed stream should end")
+	}
+	select {
+	case <-disconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("closing the stream should close the connection")
+	}
+}
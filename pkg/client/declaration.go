@@ -0,0 +1,35 @@
+package client
+
+func (c *Client) ListTools(tenantID string, page int, pageSize int) ([]ToolProvider, error) {
+	tools, err := request[[]ToolProvider](
+		c, "GET", c.pluginPath(tenantID, "management", "tools"),
+		c.options(nil, pageParams(page, pageSize))...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return *tools, nil
+}
+
+// GetTool fetches the tool provider declared by a plugin, it fails with ERROR_TYPE_PLUGIN_NOT_FOUND
+// if the plugin is not installed or declares another provider
+func (c *Client) GetTool(tenantID string, pluginID string, provider string) (*ToolProvider, error) {
+	return request[ToolProvider](
+		c, "GET", c.pluginPath(tenantID, "management", "tool"),
+		c.options(nil, withParams(map[string]string{
+			"plugin_id": pluginID,
+			"provider":  provider,
+		}))...,
+	)
+}
+
+func (c *Client) ListModels(tenantID string, page int, pageSize int) ([]ModelProvider, error) {
+	models, err := request[[]ModelProvider](
+		c, "GET", c.pluginPath(tenantID, "management", "models"),
+		c.options(nil, pageParams(page, pageSize))...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return *models, nil
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	ERROR_TYPE_INTERNAL_SERVER_ERROR = "PluginDaemonInternalServerError"
+	ERROR_TYPE_BAD_REQUEST           = "PluginDaemonBadRequestError"
+	ERROR_TYPE_NOT_FOUND             = "PluginDaemonNotFoundError"
+	ERROR_TYPE_UNAUTHORIZED          = "PluginDaemonUnauthorizedError"
+	ERROR_TYPE_PERMISSION_DENIED     = "PluginPermissionDeniedError"
+	ERROR_TYPE_UNIQUE_IDENTIFIER     = "PluginUniqueIdentifierError"
+	ERROR_TYPE_PLUGIN_NOT_FOUND      = "PluginNotFoundError"
+	ERROR_TYPE_PLUGIN_INVOKE         = "PluginInvokeError"
+	ERROR_TYPE_CONNECTION_CLOSED     = "ConnectionClosedError"
+	ERROR_TYPE_PLUGIN_REFERENCED     = "PluginReferencedError"
+	ERROR_TYPE_UNAVAILABLE           = "PluginDaemonUnavailableError"
+	ERROR_TYPE_TOO_MANY_REQUESTS     = "PluginDaemonTooManyRequestsError"
+
+	// ERROR_TYPE_UNKNOWN is used when the daemon answered without a structured error
+	ERROR_TYPE_UNKNOWN = "unknown"
+)
+
+// Error is an error reported by the daemon
+type Error struct {
+	// StatusCode is the http status, most errors are reported in the body of a 200 response
+	StatusCode int
+	// Code is the negated http status the error maps to, e.g. -404
+	Code      int
+	ErrorType string
+	Message   string
+	Args      map[string]any
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.ErrorType, e.Message)
+}
+
+// newError parses the message of a daemon response, it's a json encoded error for compatibility
+func newError(statusCode int, code int, message string) *Error {
+	e := &Error{
+		StatusCode: statusCode,
+		Code:       code,
+		ErrorType:  ERROR_TYPE_UNKNOWN,
+		Message:    message,
+	}
+
+	var detail struct {
+		Message   string         `json:"message"`
+		ErrorType string         `json:"error_type"`
+		Args      map[string]any `json:"args"`
+	}
+	if err := json.Unmarshal([]byte(message), &detail); err == nil && detail.ErrorType != "" {
+		e.ErrorType = detail.ErrorType
+		e.Message = detail.Message
+		e.Args = detail.Args
+	}
+
+	if e.Code == 0 {
+		e.Code = -statusCode
+	}
+	if e.ErrorType == ERROR_TYPE_UNKNOWN && statusCode == http.StatusUnauthorized {
+		e.ErrorType = ERROR_TYPE_UNAUTHORIZED
+	}
+	return e
+}
+
+// IsErrorType reports whether err is an error of the daemon with the given type
+func IsErrorType(err error, errorType string) bool {
+	var e *Error
+	return errors.As(err, &e) && e.ErrorType == errorType
+}
+
+// IsNotFound reports whether err means the plugin or the requested resource does not exist
+func IsNotFound(err error) bool {
+	return IsErrorType(err, ERROR_TYPE_NOT_FOUND) || IsErrorType(err, ERROR_TYPE_PLUGIN_NOT_FOUND)
+}
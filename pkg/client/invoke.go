@@ -0,0 +1,54 @@
+package client
+
+import (
+	"errors"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/model_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/requests"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/tool_entities"
+)
+
+// Invoke dispatches a request to a plugin, path is the dispatch route such as "tool/invoke",
+// the tenant and the plugin are taken from the request
+func Invoke[T any, R any](c *Client, path string, request *plugin_entities.InvokePluginRequest[T]) (
+	*Stream[R], error,
+) {
+	if request.TenantId == "" || request.PluginID == "" {
+		return nil, errors.New("tenant_id and plugin_id are required")
+	}
+
+	return streamResponse[R](
+		c, "POST", c.pluginPath(request.TenantId, "dispatch", path),
+		c.options(
+			map[string]string{X_PLUGIN_ID: request.PluginID},
+			withJSON(request),
+		)...,
+	)
+}
+
+func (c *Client) InvokeTool(request *plugin_entities.InvokePluginRequest[requests.RequestInvokeTool]) (
+	*Stream[tool_entities.ToolResponseChunk], error,
+) {
+	return Invoke[requests.RequestInvokeTool, tool_entities.ToolResponseChunk](c, "tool/invoke", request)
+}
+
+func (c *Client) InvokeLLM(request *plugin_entities.InvokePluginRequest[requests.RequestInvokeLLM]) (
+	*Stream[model_entities.LLMResultChunk], error,
+) {
+	return Invoke[requests.RequestInvokeLLM, model_entities.LLMResultChunk](c, "llm/invoke", request)
+}
+
+func (c *Client) InvokeTextEmbedding(request *plugin_entities.InvokePluginRequest[requests.RequestInvokeTextEmbedding]) (
+	*Stream[model_entities.TextEmbeddingResult], error,
+) {
+	return Invoke[requests.RequestInvokeTextEmbedding, model_entities.TextEmbeddingResult](
+		c, "text_embedding/invoke", request,
+	)
+}
+
+func (c *Client) InvokeRerank(request *plugin_entities.InvokePluginRequest[requests.RequestInvokeRerank]) (
+	*Stream[model_entities.RerankResult], error,
+) {
+	return Invoke[requests.RequestInvokeRerank, model_entities.RerankResult](c, "rerank/invoke", request)
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+)
+
+// UploadPackage uploads a .difypkg file, the returned identifier is then used to install it
+func (c *Client) UploadPackage(tenantID string, pkg io.Reader, verifySignature bool) (*UploadPackageResponse, error) {
+	return request[UploadPackageResponse](
+		c, "POST", c.pluginPath(tenantID, "management", "install", "upload", "package"),
+		c.options(nil, withMultipart(
+			map[string]string{"verify_signature": strconv.FormatBool(verifySignature)},
+			map[string]multipartFile{
+				"dify_pkg": {Filename: "plugin.difypkg", Reader: pkg},
+			},
+		))...,
+	)
+}
+
+// InstallFromIdentifiers installs uploaded plugins in background, metas is either empty or one per plugin,
+// the progress is polled with FetchInstallTask
+func (c *Client) InstallFromIdentifiers(
+	tenantID string,
+	identifiers []plugin_entities.PluginUniqueIdentifier,
+	source string,
+	metas []map[string]any,
+) (*InstallResponse, error) {
+	if len(metas) == 0 {
+		metas = make([]map[string]any, len(identifiers))
+		for i := range metas {
+			metas[i] = map[string]any{}
+		}
+	}
+
+	return request[InstallResponse](
+		c, "POST", c.pluginPath(tenantID, "management", "install", "identifiers"),
+		c.options(nil, withJSON(map[string]any{
+			"plugin_unique_identifiers": identifiers,
+			"source":                    source,
+			"metas":                     metas,
+		}))...,
+	)
+}
+
+func (c *Client) FetchInstallTask(tenantID string, taskID string) (*InstallTask, error) {
+	return request[InstallTask](
+		c, "GET", c.pluginPath(tenantID, "management", "install", "tasks", taskID),
+		c.options(nil)...,
+	)
+}
+
+func (c *Client) ListInstallTasks(tenantID string, page int, pageSize int) ([]InstallTask, error) {
+	tasks, err := request[[]InstallTask](
+		c, "GET", c.pluginPath(tenantID, "management", "install", "tasks"),
+		c.options(nil, pageParams(page, pageSize))...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return *tasks, nil
+}
+
+// FetchInstallQueue lists install tasks of the tenant with the state of the queue, an empty status
+// matches any
+func (c *Client) FetchInstallQueue(tenantID string, status string, page int, pageSize int) (*InstallQueue, error) {
+	params := map[string]string{
+		"page":      strconv.Itoa(page),
+		"page_size": strconv.Itoa(pageSize),
+	}
+	if status != "" {
+		params["status"] = status
+	}
+
+	return request[InstallQueue](
+		c, "GET", c.pluginPath(tenantID, "management", "install", "queue"),
+		c.options(nil, withParams(params))...,
+	)
+}
+
+func (c *Client) ListPlugins(tenantID string, page int, pageSize int) (*PluginInstallationList, error) {
+	return request[PluginInstallationList](
+		c, "GET", c.pluginPath(tenantID, "management", "list"),
+		c.options(nil, pageParams(page, pageSize))...,
+	)
+}
+
+// Uninstall removes a plugin from the tenant, without force it fails with ERROR_TYPE_PLUGIN_REFERENCED
+// while something still relies on the plugin
+func (c *Client) Uninstall(tenantID string, pluginInstallationID string, force bool) error {
+	_, err := request[bool](
+		c, "POST", c.pluginPath(tenantID, "management", "uninstall"),
+		c.options(nil, withJSON(map[string]any{
+			"plugin_installation_id": pluginInstallationID,
+			"force":                  force,
+		}))...,
+	)
+	return err
+}
+
+func pageParams(page int, pageSize int) option {
+	return withParams(map[string]string{
+		"page":      strconv.Itoa(page),
+		"page_size": strconv.Itoa(pageSize),
+	})
+}
@@ -0,0 +1,221 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities"
+)
+
+const (
+	// MAX_EVENT_SIZE is the largest server sent event a stream accepts
+	MAX_EVENT_SIZE = 30 * 1024 * 1024
+)
+
+// option sets up a request before it's sent
+type option func(req *http.Request) error
+
+func withHeaders(headers map[string]string) option {
+	return func(req *http.Request) error {
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		return nil
+	}
+}
+
+func withParams(params map[string]string) option {
+	return func(req *http.Request) error {
+		query := req.URL.Query()
+		for key, value := range params {
+			query.Add(key, value)
+		}
+		req.URL.RawQuery = query.Encode()
+		return nil
+	}
+}
+
+func withJSON(payload any) option {
+	return func(req *http.Request) error {
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		req.Header.Set("Content-Type", "application/json")
+		return nil
+	}
+}
+
+type multipartFile struct {
+	Filename string
+	Reader   io.Reader
+}
+
+func withMultipart(fields map[string]string, files map[string]multipartFile) option {
+	return func(req *http.Request) error {
+		buffer := new(bytes.Buffer)
+		writer := multipart.NewWriter(buffer)
+
+		for name, file := range files {
+			part, err := writer.CreateFormFile(name, file.Filename)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(part, file.Reader); err != nil {
+				return err
+			}
+		}
+		for key, value := range fields {
+			if err := writer.WriteField(key, value); err != nil {
+				return err
+			}
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+
+		req.Body = io.NopCloser(buffer)
+		req.ContentLength = int64(buffer.Len())
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return nil
+	}
+}
+
+// do sends the request, the response is returned once its headers arrived, the read timeout starts
+// then and the body is closed once it expires, the caller cancels the context after reading the body
+func (c *Client) do(method string, url string, options ...option) (*http.Response, context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	for _, option := range options {
+		if err := option(req); err != nil {
+			cancel()
+			return nil, nil, err
+		}
+	}
+
+	var timer *time.Timer
+	if c.writeTimeout > 0 {
+		timer = time.AfterFunc(c.writeTimeout, cancel)
+	}
+	resp, err := c.client.Do(req)
+	if timer != nil {
+		timer.Stop()
+	}
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+
+	if c.readTimeout > 0 {
+		timer := time.AfterFunc(c.readTimeout, cancel)
+		done := cancel
+		cancel = func() {
+			timer.Stop()
+			done()
+		}
+	}
+	return resp, cancel, nil
+}
+
+// request sends a request to the daemon and unwraps the data of the response
+func request[T any](c *Client, method string, url string, options ...option) (*T, error) {
+	resp, cancel, err := c.do(method, url, options...)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	defer resp.Body.Close()
+
+	var response entities.GenericResponse[*T]
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, newError(resp.StatusCode, 0, http.StatusText(resp.StatusCode))
+		}
+		return nil, err
+	}
+
+	if response.Code != 0 || resp.StatusCode != http.StatusOK {
+		return nil, newError(resp.StatusCode, response.Code, response.Message)
+	}
+
+	if response.Data == nil {
+		return nil, fmt.Errorf("data is nil")
+	}
+
+	return response.Data, nil
+}
+
+// streamResponse sends a request answered with server sent events, each event carries a response
+// of the daemon, the stream ends with an *Error once the daemon reports one
+func streamResponse[T any](c *Client, method string, url string, options ...option) (*Stream[T], error) {
+	resp, cancel, err := c.do(method, url, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer cancel()
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, statusCodeError(resp.StatusCode, body)
+	}
+
+	s := newStream[T](cancel)
+	go func() {
+		defer resp.Body.Close()
+		defer s.finish()
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), MAX_EVENT_SIZE)
+		for scanner.Scan() {
+			data := bytes.TrimSpace(scanner.Bytes())
+			if len(data) == 0 || bytes.HasPrefix(data, []byte("event:")) {
+				continue
+			}
+			data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("data:")))
+
+			var response entities.GenericResponse[*T]
+			if err := json.Unmarshal(data, &response); err != nil {
+				s.send(*new(T), err)
+				return
+			}
+			if response.Code != 0 {
+				s.send(*new(T), newError(http.StatusOK, response.Code, response.Message))
+				return
+			}
+			if response.Data == nil {
+				s.send(*new(T), fmt.Errorf("data is nil"))
+				return
+			}
+			if !s.send(*response.Data, nil) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			s.send(*new(T), err)
+		}
+	}()
+
+	return s, nil
+}
+
+func statusCodeError(statusCode int, body []byte) *Error {
+	var response entities.Response
+	if err := json.Unmarshal(body, &response); err != nil {
+		return newError(statusCode, 0, string(body))
+	}
+	return newError(statusCode, response.Code, response.Message)
+}
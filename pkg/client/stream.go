@@ -0,0 +1,72 @@
+package client
+
+import (
+	"sync"
+)
+
+type streamItem[T any] struct {
+	data T
+	err  error
+}
+
+// Stream is a streamed response of the daemon, items are read with Next and Read, the stream is
+// closed once Next returns false or by Close if the caller stops early
+type Stream[T any] struct {
+	items   chan streamItem[T]
+	current streamItem[T]
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	cancel    func()
+}
+
+func newStream[T any](cancel func()) *Stream[T] {
+	return &Stream[T]{
+		items:  make(chan streamItem[T], 1024),
+		closed: make(chan struct{}),
+		cancel: cancel,
+	}
+}
+
+// Next blocks until an item or an error is available, it returns false once the stream ended
+func (s *Stream[T]) Next() bool {
+	select {
+	case item, ok := <-s.items:
+		if !ok {
+			return false
+		}
+		s.current = item
+		return true
+	case <-s.closed:
+		return false
+	}
+}
+
+// Read returns the item Next advanced to, an error ends the stream
+func (s *Stream[T]) Read() (T, error) {
+	return s.current.data, s.current.err
+}
+
+// Close stops reading the response, it's safe to call it more than once
+func (s *Stream[T]) Close() {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		s.cancel()
+	})
+}
+
+// send returns false if the stream was closed by the caller
+func (s *Stream[T]) send(data T, err error) bool {
+	select {
+	case s.items <- streamItem[T]{data: data, err: err}:
+		return true
+	case <-s.closed:
+		return false
+	}
+}
+
+// finish is called by the reader of the response once it's done
+func (s *Stream[T]) finish() {
+	close(s.items)
+	s.cancel()
+}
@@ -0,0 +1,118 @@
+package client
+
+import (
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/manifest_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/secret_scanner"
+)
+
+type UploadPackageResponse struct {
+	UniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"unique_identifier"`
+	Manifest         *plugin_entities.PluginDeclaration     `json:"manifest"`
+	Verification     *decoder.Verification                  `json:"verification"`
+	SecretFindings   []secret_scanner.Finding               `json:"secret_findings"`
+}
+
+type InstallResponse struct {
+	AllInstalled bool `json:"all_installed"`
+	// TaskID is empty if all the plugins were installed already
+	TaskID string `json:"task_id"`
+}
+
+type InstallTaskPlugin struct {
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier"`
+	Labels                 plugin_entities.I18nObject             `json:"labels"`
+	Icon                   string                                 `json:"icon"`
+	IconDark               string                                 `json:"icon_dark"`
+	PluginID               string                                 `json:"plugin_id"`
+	Status                 string                                 `json:"status"`
+	Message                string                                 `json:"message"`
+	Attempts               int                                    `json:"attempts"`
+	Meta                   map[string]any                         `json:"meta"`
+}
+
+type InstallTask struct {
+	ID               string              `json:"id"`
+	CreatedAt        time.Time           `json:"created_at"`
+	UpdatedAt        time.Time           `json:"updated_at"`
+	Status           string              `json:"status"`
+	TenantID         string              `json:"tenant_id"`
+	TotalPlugins     int                 `json:"total_plugins"`
+	CompletedPlugins int                 `json:"completed_plugins"`
+	Plugins          []InstallTaskPlugin `json:"plugins"`
+}
+
+type PluginInstallation struct {
+	ID                     string                             `json:"id"`
+	Name                   string                             `json:"name"`
+	PluginID               string                             `json:"plugin_id"`
+	TenantID               string                             `json:"tenant_id"`
+	PluginUniqueIdentifier string                             `json:"plugin_unique_identifier"`
+	EndpointsActive        int                                `json:"endpoints_active"`
+	EndpointsSetups        int                                `json:"endpoints_setups"`
+	InstallationID         string                             `json:"installation_id"`
+	Declaration            *plugin_entities.PluginDeclaration `json:"declaration"`
+	RuntimeType            plugin_entities.PluginRuntimeType  `json:"runtime_type"`
+	Version                manifest_entities.Version          `json:"version"`
+	CreatedAt              time.Time                          `json:"created_at"`
+	UpdatedAt              time.Time                          `json:"updated_at"`
+	Source                 string                             `json:"source"`
+	Checksum               string                             `json:"checksum"`
+	Meta                   map[string]any                     `json:"meta"`
+}
+
+type PluginInstallationList struct {
+	List  []PluginInstallation `json:"list"`
+	Total int64                `json:"total"`
+}
+
+type ToolProvider struct {
+	ID                     string                                   `json:"id"`
+	TenantID               string                                   `json:"tenant_id"`
+	Provider               string                                   `json:"provider"`
+	PluginUniqueIdentifier string                                   `json:"plugin_unique_identifier"`
+	PluginID               string                                   `json:"plugin_id"`
+	Declaration            *plugin_entities.ToolProviderDeclaration `json:"declaration"`
+}
+
+type ModelProvider struct {
+	ID                     string                                    `json:"id"`
+	TenantID               string                                    `json:"tenant_id"`
+	Provider               string                                    `json:"provider"`
+	PluginUniqueIdentifier string                                    `json:"plugin_unique_identifier"`
+	PluginID               string                                    `json:"plugin_id"`
+	Declaration            *plugin_entities.ModelProviderDeclaration `json:"declaration"`
+}
+
+type InstallQueueStats struct {
+	Concurrency int   `json:"concurrency"`
+	Running     int32 `json:"running"`
+	Waiting     int32 `json:"waiting"`
+	QueueSize   int32 `json:"queue_size"`
+	OwnedTasks  int   `json:"owned_tasks"`
+}
+
+type InstallQueue struct {
+	Tasks []InstallTask     `json:"tasks"`
+	Queue InstallQueueStats `json:"queue"`
+}
+
+type RuntimeStats struct {
+	PluginUniqueIdentifier plugin_entities.PluginUniqueIdentifier `json:"plugin_unique_identifier"`
+	Type                   plugin_entities.PluginRuntimeType      `json:"type"`
+	Status                 string                                 `json:"status"`
+	Restarts               int                                    `json:"restarts"`
+	ActiveAt               *time.Time                             `json:"active_at"`
+	Sessions               int                                    `json:"sessions"`
+}
+
+type NodeStats struct {
+	Runtimes               []RuntimeStats    `json:"runtimes"`
+	Sessions               int               `json:"sessions"`
+	InstallQueue           InstallQueueStats `json:"install_queue"`
+	ActiveRequests         int32             `json:"active_requests"`
+	ActiveDispatchRequests int32             `json:"active_dispatch_requests"`
+}
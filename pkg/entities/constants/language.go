@@ -9,13 +9,14 @@ type Language string
 
 const (
 	Python Language = "python"
+	NodeJS Language = "nodejs"
 	Go     Language = "go" // not supported yet
 )
 
 func isAvailableLanguage(fl validator.FieldLevel) bool {
 	value := fl.Field().String()
 	switch value {
-	case string(Python):
+	case string(Python), string(NodeJS):
 		return true
 	}
 	return false
@@ -0,0 +1,171 @@
+package endpoint_entities
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
+)
+
+type EndpointMiddlewareType string
+
+const (
+	ENDPOINT_MIDDLEWARE_SET_HEADERS EndpointMiddlewareType = "set_headers"
+	ENDPOINT_MIDDLEWARE_BASIC_AUTH  EndpointMiddlewareType = "basic_auth"
+	ENDPOINT_MIDDLEWARE_BODY_LIMIT  EndpointMiddlewareType = "body_limit"
+	ENDPOINT_MIDDLEWARE_IP_FILTER   EndpointMiddlewareType = "ip_filter"
+	// ENDPOINT_MIDDLEWARE_HMAC_SIGNATURE verifies signatures webhook senders compute over the body
+	ENDPOINT_MIDDLEWARE_HMAC_SIGNATURE EndpointMiddlewareType = "hmac_signature"
+	// ENDPOINT_MIDDLEWARE_SET_RESPONSE_HEADERS sets headers on the response of the plugin
+	ENDPOINT_MIDDLEWARE_SET_RESPONSE_HEADERS EndpointMiddlewareType = "set_response_headers"
+
+	ENDPOINT_MIDDLEWARE_PASSWORD_MASK = "******"
+
+	HMAC_ALGORITHM_SHA1   = "sha1"
+	HMAC_ALGORITHM_SHA256 = "sha256"
+	HMAC_ALGORITHM_SHA512 = "sha512"
+
+	HMAC_ENCODING_HEX    = "hex"
+	HMAC_ENCODING_BASE64 = "base64"
+)
+
+// EndpointMiddleware is a step of the chain the daemon runs on requests to an endpoint before the
+// plugin is invoked, only the fields of its type apply, strings of tenants may reference tenant variables,
+// middlewares declared by plugins may only reference settings of the endpoint as {{settings.NAME}}
+type EndpointMiddleware struct {
+	Type EndpointMiddlewareType `json:"type" yaml:"type" validate:"required,oneof=set_headers basic_auth body_limit ip_filter hmac_signature set_response_headers"`
+
+	// Headers are set on the request or the response, an empty value removes the header
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty" validate:"omitempty,dive,keys,required,endkeys"`
+
+	// Username and Password are checked against the basic credential, which is then removed
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	Realm    string `json:"realm,omitempty" yaml:"realm,omitempty"`
+
+	MaxBodyBytes int64 `json:"max_body_bytes,omitempty" yaml:"max_body_bytes,omitempty" validate:"omitempty,min=1"`
+
+	// AllowedIPs and DeniedIPs are addresses or cidrs, denied wins and an empty allow list allows any
+	AllowedIPs []string `json:"allowed_ips,omitempty" yaml:"allowed_ips,omitempty" validate:"omitempty,dive,is_available_ip_rule"`
+	DeniedIPs  []string `json:"denied_ips,omitempty" yaml:"denied_ips,omitempty" validate:"omitempty,dive,is_available_ip_rule"`
+
+	// SignatureHeader carries the hmac of the body keyed with Secret, SignaturePrefix like `sha256=`
+	// is stripped from it, Algorithm defaults to sha256 and Encoding to hex
+	SignatureHeader string `json:"signature_header,omitempty" yaml:"signature_header,omitempty"`
+	SignaturePrefix string `json:"signature_prefix,omitempty" yaml:"signature_prefix,omitempty"`
+	Secret          string `json:"secret,omitempty" yaml:"secret,omitempty"`
+	Algorithm       string `json:"algorithm,omitempty" yaml:"algorithm,omitempty" validate:"omitempty,oneof=sha1 sha256 sha512"`
+	Encoding        string `json:"encoding,omitempty" yaml:"encoding,omitempty" validate:"omitempty,oneof=hex base64"`
+	// TimestampHeader makes the signed payload `<timestamp>.<body>`, requests whose unix timestamp is
+	// more than MaxSkewSeconds away are rejected to stop replays
+	TimestampHeader string `json:"timestamp_header,omitempty" yaml:"timestamp_header,omitempty"`
+	MaxSkewSeconds  int64  `json:"max_skew_seconds,omitempty" yaml:"max_skew_seconds,omitempty" validate:"omitempty,min=1"`
+}
+
+var (
+	referencePattern         = regexp.MustCompile(`\{\{[^{}]*\}\}`)
+	settingsReferencePattern = regexp.MustCompile(`^\{\{\s*settings\.[A-Za-z_][A-Za-z0-9_]*\s*\}\}$`)
+)
+
+// CheckDeclaredReferences rejects middlewares declared by a plugin which reference anything but
+// {{settings.NAME}}, tenant variables must not leak to plugins through their declarations
+func CheckDeclaredReferences(middlewares []EndpointMiddleware) error {
+	raw, err := json.Marshal(middlewares)
+	if err != nil {
+		return err
+	}
+	for _, reference := range referencePattern.FindAllString(string(raw), -1) {
+		if !settingsReferencePattern.MatchString(reference) {
+			return fmt.Errorf("declared middlewares may only reference settings of the endpoint, got %s", reference)
+		}
+	}
+	return nil
+}
+
+// ParseIPRule parses an address or a cidr into a network
+func ParseIPRule(rule string) (*net.IPNet, error) {
+	rule = strings.TrimSpace(rule)
+	if strings.Contains(rule, "/") {
+		_, network, err := net.ParseCIDR(rule)
+		return network, err
+	}
+
+	ip := net.ParseIP(rule)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid ip rule %s", rule)
+	}
+	bits := 8 * net.IPv6len
+	if ip.To4() != nil {
+		ip, bits = ip.To4(), 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// MatchIP reports whether ip is covered by any of the rules, invalid rules match nothing
+func MatchIP(ip net.IP, rules []string) bool {
+	for _, rule := range rules {
+		network, err := ParseIPRule(rule)
+		if err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func isAvailableIPRule(fl validator.FieldLevel) bool {
+	_, err := ParseIPRule(fl.Field().String())
+	return err == nil
+}
+
+// the fields of its type are required
+func validateEndpointMiddleware(sl validator.StructLevel) {
+	m := sl.Current().Interface().(EndpointMiddleware)
+	switch m.Type {
+	case ENDPOINT_MIDDLEWARE_SET_HEADERS, ENDPOINT_MIDDLEWARE_SET_RESPONSE_HEADERS:
+		if len(m.Headers) == 0 {
+			sl.ReportError(m.Headers, "Headers", "headers", "required", "")
+		}
+	case ENDPOINT_MIDDLEWARE_BASIC_AUTH:
+		if m.Username == "" {
+			sl.ReportError(m.Username, "Username", "username", "required", "")
+		}
+		if m.Password == "" {
+			sl.ReportError(m.Password, "Password", "password", "required", "")
+		}
+	case ENDPOINT_MIDDLEWARE_BODY_LIMIT:
+		if m.MaxBodyBytes == 0 {
+			sl.ReportError(m.MaxBodyBytes, "MaxBodyBytes", "max_body_bytes", "required", "")
+		}
+	case ENDPOINT_MIDDLEWARE_IP_FILTER:
+		if len(m.AllowedIPs) == 0 && len(m.DeniedIPs) == 0 {
+			sl.ReportError(m.AllowedIPs, "AllowedIPs", "allowed_ips", "required", "")
+		}
+	case ENDPOINT_MIDDLEWARE_HMAC_SIGNATURE:
+		if m.SignatureHeader == "" {
+			sl.ReportError(m.SignatureHeader, "SignatureHeader", "signature_header", "required", "")
+		}
+		if m.Secret == "" {
+			sl.ReportError(m.Secret, "Secret", "secret", "required", "")
+		}
+	}
+}
+
+func init() {
+	validators.GlobalEntitiesValidator.RegisterValidation("is_available_ip_rule", isAvailableIPRule)
+	validators.GlobalEntitiesValidator.RegisterStructValidation(validateEndpointMiddleware, EndpointMiddleware{})
+}
+
+// Masked hides the password and the secret unless they are references to tenant variables
+func (m EndpointMiddleware) Masked() EndpointMiddleware {
+	if m.Password != "" && !strings.Contains(m.Password, "{{") {
+		m.Password = ENDPOINT_MIDDLEWARE_PASSWORD_MASK
+	}
+	if m.Secret != "" && !strings.Contains(m.Secret, "{{") {
+		m.Secret = ENDPOINT_MIDDLEWARE_PASSWORD_MASK
+	}
+	return m
+}
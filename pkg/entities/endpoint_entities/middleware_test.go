@@ -0,0 +1,89 @@
+package endpoint_entities
+
+import (
+	"net"
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
+)
+
+func TestValidateEndpointMiddleware(t *testing.T) {
+	cases := []struct {
+		middleware EndpointMiddleware
+		valid      bool
+	}{
+		{EndpointMiddleware{Type: ENDPOINT_MIDDLEWARE_SET_HEADERS, Headers: map[string]string{"X-Token": "1"}}, true},
+		{EndpointMiddleware{Type: ENDPOINT_MIDDLEWARE_SET_HEADERS}, false},
+		{EndpointMiddleware{Type: ENDPOINT_MIDDLEWARE_BASIC_AUTH, Username: "dify", Password: "{{secret.hook}}"}, true},
+		{EndpointMiddleware{Type: ENDPOINT_MIDDLEWARE_BASIC_AUTH, Username: "dify"}, false},
+		{EndpointMiddleware{Type: ENDPOINT_MIDDLEWARE_BODY_LIMIT, MaxBodyBytes: 1024}, true},
+		{EndpointMiddleware{Type: ENDPOINT_MIDDLEWARE_BODY_LIMIT, MaxBodyBytes: -1}, false},
+		{EndpointMiddleware{Type: ENDPOINT_MIDDLEWARE_IP_FILTER, AllowedIPs: []string{"10.0.0.0/8", "::1"}}, true},
+		{EndpointMiddleware{Type: ENDPOINT_MIDDLEWARE_IP_FILTER, DeniedIPs: []string{"10.0.0.0/33"}}, false},
+		{EndpointMiddleware{Type: ENDPOINT_MIDDLEWARE_IP_FILTER}, false},
+		{EndpointMiddleware{Type: ENDPOINT_MIDDLEWARE_HMAC_SIGNATURE, SignatureHeader: "X-Hub-Signature-256", Secret: "{{settings.secret}}"}, true},
+		{EndpointMiddleware{Type: ENDPOINT_MIDDLEWARE_HMAC_SIGNATURE, SignatureHeader: "X-Signature"}, false},
+		{EndpointMiddleware{Type: ENDPOINT_MIDDLEWARE_HMAC_SIGNATURE, SignatureHeader: "X-Signature", Secret: "s", Algorithm: "md5"}, false},
+		{EndpointMiddleware{Type: ENDPOINT_MIDDLEWARE_SET_RESPONSE_HEADERS, Headers: map[string]string{"Cache-Control": "no-store"}}, true},
+		{EndpointMiddleware{Type: ENDPOINT_MIDDLEWARE_SET_RESPONSE_HEADERS}, false},
+		{EndpointMiddleware{Type: "rewrite"}, false},
+	}
+
+	for _, c := range cases {
+		err := validators.GlobalEntitiesValidator.Struct(c.middleware)
+		if (err == nil) != c.valid {
+			t.Errorf("middleware %+v: expected valid %v, got %v", c.middleware, c.valid, err)
+		}
+	}
+}
+
+func TestMatchIP(t *testing.T) {
+	rules := []string{"192.168.1.0/24", "10.1.2.3", "2001:db8::/32"}
+	for ip, expected := range map[string]bool{
+		"192.168.1.20": true,
+		"192.168.2.20": false,
+		"10.1.2.3":     true,
+		"10.1.2.4":     false,
+		"2001:db8::1":  true,
+		"::1":          false,
+	} {
+		if MatchIP(net.ParseIP(ip), rules) != expected {
+			t.Errorf("ip %s: expected match %v", ip, expected)
+		}
+	}
+}
+
+func TestMaskedKeepsReferences(t *testing.T) {
+	plain := EndpointMiddleware{Type: ENDPOINT_MIDDLEWARE_BASIC_AUTH, Username: "dify", Password: "hunter2"}
+	if plain.Masked().Password != ENDPOINT_MIDDLEWARE_PASSWORD_MASK {
+		t.Errorf("plain password should be masked")
+	}
+	if plain.Password != "hunter2" {
+		t.Errorf("masking must not modify the original")
+	}
+
+	reference := EndpointMiddleware{Type: ENDPOINT_MIDDLEWARE_BASIC_AUTH, Username: "dify", Password: "{{secret.hook}}"}
+	if reference.Masked().Password != "{{secret.hook}}" {
+		t.Errorf("references should be kept")
+	}
+}
+
+func TestCheckDeclaredReferences(t *testing.T) {
+	allowed := []EndpointMiddleware{
+		{Type: ENDPOINT_MIDDLEWARE_HMAC_SIGNATURE, SignatureHeader: "X-Signature", Secret: "{{ settings.secret }}"},
+		{Type: ENDPOINT_MIDDLEWARE_SET_HEADERS, Headers: map[string]string{"X-Origin": "dify-{{settings.origin}}"}},
+	}
+	if err := CheckDeclaredReferences(allowed); err != nil {
+		t.Errorf("references to settings should be allowed, got %v", err)
+	}
+
+	for _, middleware := range []EndpointMiddleware{
+		{Type: ENDPOINT_MIDDLEWARE_SET_HEADERS, Headers: map[string]string{"X-Leak": "{{secret.api_key}}"}},
+		{Type: ENDPOINT_MIDDLEWARE_HMAC_SIGNATURE, SignatureHeader: "X-Signature", Secret: "{{env.KEY}}"},
+		{Type: ENDPOINT_MIDDLEWARE_BASIC_AUTH, Username: "{{ settings.user }}{{secret.password}}", Password: "p"},
+	} {
+		if err := CheckDeclaredReferences([]EndpointMiddleware{middleware}); err == nil {
+			t.Errorf("middleware %+v should be rejected", middleware)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package manifest_entities
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/hashicorp/go-version"
+	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
+)
+
+// VersionConstraint is the versions a dependency accepts, same as the version part of bundle dependencies:
+// 1.0.0, ^1.0.0, ~1.0.0, 1.x.x, 1.0.0-2.0.0 or empty for any version
+type VersionConstraint string
+
+var (
+	VERSION_CONSTRAINT_PATTERN = fmt.Sprintf(
+		`([~^]?%s|%s(\.%s){2}|%s-%s)`,
+		VERSION_PATTERN,
+		VERSION_X_PATTERN,
+		VERSION_X_PATTERN,
+		VERSION_PATTERN,
+		VERSION_PATTERN,
+	)
+
+	versionConstraintRegex = regexp.MustCompile("^" + VERSION_CONSTRAINT_PATTERN + "$")
+	versionRangeRegex      = regexp.MustCompile("^(?P<lower>" + VERSION_PATTERN + ")-(?P<upper>" + VERSION_PATTERN + ")$")
+)
+
+func NewVersionConstraint(constraint string) (VersionConstraint, error) {
+	if constraint != "" && !versionConstraintRegex.MatchString(constraint) {
+		return "", fmt.Errorf("invalid version constraint: %s", constraint)
+	}
+	return VersionConstraint(constraint), nil
+}
+
+func (c VersionConstraint) String() string {
+	return string(c)
+}
+
+// Match reports whether v satisfies the constraint, an invalid version never does
+func (c VersionConstraint) Match(v Version) bool {
+	if c == "" {
+		return true
+	}
+
+	target, err := version.NewVersion(v.String())
+	if err != nil {
+		return false
+	}
+
+	constraint := c.String()
+	if matches := versionRangeRegex.FindStringSubmatch(constraint); matches != nil {
+		// both ends are included, 1.0.0-beta is a pre-release and never matches here
+		lower, errLower := version.NewVersion(matches[versionRangeRegex.SubexpIndex("lower")])
+		upper, errUpper := version.NewVersion(matches[versionRangeRegex.SubexpIndex("upper")])
+		if errLower == nil && errUpper == nil {
+			return !target.LessThan(lower) && !target.GreaterThan(upper)
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(constraint, "^"):
+		return c.matchPrefix(target, strings.TrimPrefix(constraint, "^"), 1)
+	case strings.HasPrefix(constraint, "~"):
+		return c.matchPrefix(target, strings.TrimPrefix(constraint, "~"), 2)
+	case strings.ContainsAny(constraint, "xX"):
+		parts := strings.Split(constraint, ".")
+		segments := target.Segments()
+		for i, part := range parts {
+			if part == "x" || part == "X" {
+				continue
+			}
+			segment, err := strconv.Atoi(part)
+			if err != nil || i >= len(segments) || segments[i] != segment {
+				return false
+			}
+		}
+		return target.Prerelease() == ""
+	default:
+		exact, err := version.NewVersion(constraint)
+		return err == nil && target.Equal(exact)
+	}
+}
+
+// matchPrefix accepts versions not lower than base sharing its first fixed segments,
+// ^1.2.0 accepts 1.x.x and ~1.2.0 accepts 1.2.x
+func (c VersionConstraint) matchPrefix(target *version.Version, base string, fixed int) bool {
+	lower, err := version.NewVersion(base)
+	if err != nil || target.LessThan(lower) {
+		return false
+	}
+
+	targetSegments, lowerSegments := target.Segments(), lower.Segments()
+	for i := 0; i < fixed; i++ {
+		if targetSegments[i] != lowerSegments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func isVersionConstraint(fl validator.FieldLevel) bool {
+	_, err := NewVersionConstraint(fl.Field().String())
+	return err == nil
+}
+
+func init() {
+	validators.GlobalEntitiesValidator.RegisterValidation("version_constraint", isVersionConstraint)
+}
@@ -0,0 +1,41 @@
+package manifest_entities
+
+import "testing"
+
+func TestVersionConstraintMatch(t *testing.T) {
+	cases := []struct {
+		constraint string
+		version    string
+		match      bool
+	}{
+		{"", "0.0.1", true},
+		{"1.0.0", "1.0.0", true},
+		{"1.0.0", "1.0.1", false},
+		{"^1.2.0", "1.9.0", true},
+		{"^1.2.0", "1.1.9", false},
+		{"^1.2.0", "2.0.0", false},
+		{"~1.2.0", "1.2.7", true},
+		{"~1.2.0", "1.3.0", false},
+		{"1.x.x", "1.4.2", true},
+		{"1.2.x", "1.3.0", false},
+		{"1.0.0-2.0.0", "1.5.0", true},
+		{"1.0.0-2.0.0", "2.0.0", true},
+		{"1.0.0-2.0.0", "2.0.1", false},
+		{"1.0.0-beta", "1.0.0-beta", true},
+		{"1.0.0-beta", "1.0.0", false},
+	}
+
+	for _, c := range cases {
+		constraint, err := NewVersionConstraint(c.constraint)
+		if err != nil {
+			t.Fatalf("NewVersionConstraint(%s) returned an error: %v", c.constraint, err)
+		}
+		if constraint.Match(Version(c.version)) != c.match {
+			t.Errorf("%s.Match(%s) = %v; want %v", c.constraint, c.version, !c.match, c.match)
+		}
+	}
+
+	if _, err := NewVersionConstraint(">=1.0.0"); err == nil {
+		t.Fatalf("NewVersionConstraint() returned nil error for invalid constraint")
+	}
+}
@@ -14,3 +14,8 @@ type OAuthRefreshCredentialsResult struct {
 	Credentials map[string]any `json:"credentials"`
 	ExpiresAt   int64          `json:"expires_at"`
 }
+
+type OAuthAuthorizeResult struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"state"`
+}
@@ -0,0 +1,115 @@
+package plugin_entities
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
+)
+
+const (
+	CORS_ANY = "*"
+)
+
+// EndpointCORS lets browsers call an endpoint from other origins, the daemon answers preflight
+// requests itself and rejects requests from origins not allowed
+type EndpointCORS struct {
+	// AllowedOrigins are `*`, origins like `https://app.example.com` or `https://*.example.com`
+	AllowedOrigins []string `json:"allowed_origins" yaml:"allowed_origins" validate:"required,min=1,dive,is_available_cors_origin"`
+	// AllowedMethods defaults to the method of the declaration
+	AllowedMethods []EndpointMethod `json:"allowed_methods,omitempty" yaml:"allowed_methods,omitempty" validate:"omitempty,dive,is_available_endpoint_method"`
+	// AllowedHeaders are request headers beyond the safelisted ones, `*` allows any
+	AllowedHeaders   []string `json:"allowed_headers,omitempty" yaml:"allowed_headers,omitempty" validate:"omitempty,dive,required"`
+	ExposedHeaders   []string `json:"exposed_headers,omitempty" yaml:"exposed_headers,omitempty" validate:"omitempty,dive,required"`
+	AllowCredentials bool     `json:"allow_credentials,omitempty" yaml:"allow_credentials,omitempty"`
+	// MaxAge is how long in seconds browsers may cache the result of a preflight request
+	MaxAge int `json:"max_age,omitempty" yaml:"max_age,omitempty" validate:"omitempty,min=0,max=86400"`
+}
+
+func isAvailableCORSOrigin(fl validator.FieldLevel) bool {
+	origin := fl.Field().String()
+	if origin == CORS_ANY {
+		return true
+	}
+
+	u, err := url.Parse(strings.Replace(origin, "://*.", "://", 1))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	// an origin has no path, query or credentials
+	return u.Path == "" && u.RawQuery == "" && u.Fragment == "" && u.User == nil
+}
+
+// credentials must never be shared with any origin, browsers refuse it as well
+func validateEndpointCORS(sl validator.StructLevel) {
+	cors := sl.Current().Interface().(EndpointCORS)
+	if cors.AllowCredentials && slices.Contains(cors.AllowedOrigins, CORS_ANY) {
+		sl.ReportError(cors.AllowedOrigins, "AllowedOrigins", "allowed_origins", "cors_credentials_with_any_origin", "")
+	}
+	if cors.AllowCredentials && slices.Contains(cors.AllowedHeaders, CORS_ANY) {
+		sl.ReportError(cors.AllowedHeaders, "AllowedHeaders", "allowed_headers", "cors_credentials_with_any_header", "")
+	}
+}
+
+func init() {
+	validators.GlobalEntitiesValidator.RegisterValidation("is_available_cors_origin", isAvailableCORSOrigin)
+	validators.GlobalEntitiesValidator.RegisterStructValidation(validateEndpointCORS, EndpointCORS{})
+}
+
+func (c *EndpointCORS) AllowOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range c.AllowedOrigins {
+		allowed = strings.ToLower(allowed)
+		if allowed == CORS_ANY || allowed == origin {
+			return true
+		}
+
+		scheme, host, ok := strings.Cut(allowed, "://*.")
+		if !ok {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(origin, scheme+"://"); ok && strings.HasSuffix(rest, "."+host) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowMethod checks a method against AllowedMethods, or against the method declared if unset
+func (c *EndpointCORS) AllowMethod(declared EndpointMethod, method string) bool {
+	for _, allowed := range c.Methods(declared) {
+		if strings.EqualFold(string(allowed), method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *EndpointCORS) Methods(declared EndpointMethod) []EndpointMethod {
+	if len(c.AllowedMethods) == 0 {
+		return []EndpointMethod{declared}
+	}
+	return c.AllowedMethods
+}
+
+// AllowHeaders checks the comma separated headers of Access-Control-Request-Headers
+func (c *EndpointCORS) AllowHeaders(requested string) bool {
+	if slices.Contains(c.AllowedHeaders, CORS_ANY) {
+		return true
+	}
+
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		if !slices.ContainsFunc(c.AllowedHeaders, func(allowed string) bool {
+			return strings.EqualFold(allowed, header)
+		}) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package plugin_entities
+
+import (
+	"testing"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
+)
+
+func TestEndpointCORSValidation(t *testing.T) {
+	valid := []EndpointCORS{
+		{AllowedOrigins: []string{"*"}},
+		{AllowedOrigins: []string{"https://app.example.com", "http://localhost:3000"}, AllowCredentials: true},
+		{AllowedOrigins: []string{"https://*.example.com"}, AllowedMethods: []EndpointMethod{EndpointMethodPost}, MaxAge: 600},
+	}
+	for _, cors := range valid {
+		if err := validators.GlobalEntitiesValidator.Struct(cors); err != nil {
+			t.Fatalf("%v should be valid: %s", cors, err)
+		}
+	}
+
+	invalid := []EndpointCORS{
+		{},
+		{AllowedOrigins: []string{"app.example.com"}},
+		{AllowedOrigins: []string{"https://app.example.com/path"}},
+		{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+		{AllowedOrigins: []string{"https://app.example.com"}, AllowedHeaders: []string{"*"}, AllowCredentials: true},
+		{AllowedOrigins: []string{"*"}, AllowedMethods: []EndpointMethod{"PATCHED"}},
+	}
+	for _, cors := range invalid {
+		if err := validators.GlobalEntitiesValidator.Struct(cors); err == nil {
+			t.Fatalf("%v should be invalid", cors)
+		}
+	}
+}
+
+func TestEndpointCORSAllow(t *testing.T) {
+	cors := EndpointCORS{
+		AllowedOrigins: []string{"https://app.example.com", "https://*.example.org"},
+		AllowedHeaders: []string{"Content-Type", "X-Api-Key"},
+	}
+
+	for origin, allowed := range map[string]bool{
+		"https://app.example.com":  true,
+		"HTTPS://APP.EXAMPLE.COM":  true,
+		"https://a.b.example.org":  true,
+		"https://example.org":      false,
+		"http://app.example.com":   false,
+		"https://evilexample.org":  false,
+		"https://app.example.com.": false,
+	} {
+		if cors.AllowOrigin(origin) != allowed {
+			t.Fatalf("origin %s: expected %v", origin, allowed)
+		}
+	}
+
+	if !cors.AllowMethod(EndpointMethodPost, "post") || cors.AllowMethod(EndpointMethodPost, "GET") {
+		t.Fatal("methods should default to the declared one")
+	}
+	if !cors.AllowHeaders("content-type, x-api-key") || !cors.AllowHeaders("") || cors.AllowHeaders("content-type, authorization") {
+		t.Fatal("unexpected result of header check")
+	}
+}
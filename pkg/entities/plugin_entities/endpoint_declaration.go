@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/endpoint_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
 	"gopkg.in/yaml.v3"
 )
@@ -33,14 +34,25 @@ func isAvailableMethod(fl validator.FieldLevel) bool {
 	return false
 }
 
+func isAvailableDeclaredMiddlewares(fl validator.FieldLevel) bool {
+	middlewares, ok := fl.Field().Interface().([]endpoint_entities.EndpointMiddleware)
+	return ok && endpoint_entities.CheckDeclaredReferences(middlewares) == nil
+}
+
 func init() {
 	validators.GlobalEntitiesValidator.RegisterValidation("is_available_endpoint_method", isAvailableMethod)
+	validators.GlobalEntitiesValidator.RegisterValidation("is_available_declared_middlewares", isAvailableDeclaredMiddlewares)
 }
 
 type EndpointDeclaration struct {
-	Path   string         `json:"path" yaml:"path" validate:"required"`
+	Path   string         `json:"path" yaml:"path" validate:"required,is_available_endpoint_path"`
 	Method EndpointMethod `json:"method" yaml:"method" validate:"required,is_available_endpoint_method"`
 	Hidden bool           `json:"hidden" yaml:"hidden" validate:"omitempty"`
+	// CORS is enforced by the daemon, requests from other origins are passed through if unset
+	CORS *EndpointCORS `json:"cors,omitempty" yaml:"cors,omitempty" validate:"omitempty"`
+	// Middlewares run after those the tenant set on the endpoint, so that webhook plugins leave checks
+	// like signature verification to the daemon
+	Middlewares []endpoint_entities.EndpointMiddleware `json:"middlewares,omitempty" yaml:"middlewares,omitempty" validate:"omitempty,max=16,is_available_declared_middlewares,dive"`
 }
 
 type EndpointProviderDeclaration struct {
@@ -0,0 +1,140 @@
+package plugin_entities
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
+)
+
+const (
+	ENDPOINT_PATH_PARAM_PREFIX    = ":"
+	ENDPOINT_PATH_WILDCARD_PREFIX = "*"
+	// ENDPOINT_PATH_WILDCARD_DEFAULT_NAME is the key of the rest of the path for an unnamed wildcard
+	ENDPOINT_PATH_WILDCARD_DEFAULT_NAME = "*"
+)
+
+var endpointPathParamNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func splitEndpointPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
+
+// ValidateEndpointPath checks the path parameters of a declared path, `:name` matches exactly
+// one segment and `*name` or `*` as the last segment matches the rest of the path
+func ValidateEndpointPath(path string) error {
+	segments := splitEndpointPath(path)
+	names := map[string]bool{}
+
+	for i, segment := range segments {
+		var name string
+		switch {
+		case strings.HasPrefix(segment, ENDPOINT_PATH_PARAM_PREFIX):
+			name = segment[len(ENDPOINT_PATH_PARAM_PREFIX):]
+		case strings.HasPrefix(segment, ENDPOINT_PATH_WILDCARD_PREFIX):
+			if i != len(segments)-1 {
+				return fmt.Errorf("wildcard %s of path %s must be the last segment", segment, path)
+			}
+			name = segment[len(ENDPOINT_PATH_WILDCARD_PREFIX):]
+			if name == "" {
+				continue
+			}
+		default:
+			continue
+		}
+
+		if !endpointPathParamNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid parameter name %s in path %s", name, path)
+		}
+		if names[name] {
+			return fmt.Errorf("parameter %s is declared more than once in path %s", name, path)
+		}
+		names[name] = true
+	}
+
+	return nil
+}
+
+func isAvailableEndpointPath(fl validator.FieldLevel) bool {
+	return ValidateEndpointPath(fl.Field().String()) == nil
+}
+
+func init() {
+	validators.GlobalEntitiesValidator.RegisterValidation("is_available_endpoint_path", isAvailableEndpointPath)
+}
+
+// Match reports whether a request matches the declaration and extracts its path parameters,
+// static segments count how specific the match is
+func (e *EndpointDeclaration) Match(method string, path string) (map[string]string, int, bool) {
+	if !strings.EqualFold(string(e.Method), method) {
+		return nil, 0, false
+	}
+
+	declared := splitEndpointPath(e.Path)
+	requested := splitEndpointPath(path)
+	params := map[string]string{}
+	static := 0
+
+	for i, segment := range declared {
+		if strings.HasPrefix(segment, ENDPOINT_PATH_WILDCARD_PREFIX) {
+			name := segment[len(ENDPOINT_PATH_WILDCARD_PREFIX):]
+			if name == "" {
+				name = ENDPOINT_PATH_WILDCARD_DEFAULT_NAME
+			}
+			if i > len(requested) {
+				return nil, 0, false
+			}
+			params[name] = strings.Join(requested[i:], "/")
+			return params, static, true
+		}
+
+		if i >= len(requested) {
+			return nil, 0, false
+		}
+
+		if strings.HasPrefix(segment, ENDPOINT_PATH_PARAM_PREFIX) {
+			if requested[i] == "" {
+				return nil, 0, false
+			}
+			params[segment[len(ENDPOINT_PATH_PARAM_PREFIX):]] = requested[i]
+			continue
+		}
+
+		if segment != requested[i] {
+			return nil, 0, false
+		}
+		static++
+	}
+
+	if len(declared) != len(requested) {
+		return nil, 0, false
+	}
+
+	// an exact match beats any match of a path with a wildcard of the same static prefix
+	return params, static + 1, true
+}
+
+// Match returns the most specific declaration matching a request and its path parameters,
+// nil if none matches, the plugin then handles the request on its own as before
+func (e *EndpointProviderDeclaration) Match(method string, path string) (*EndpointDeclaration, map[string]string) {
+	var (
+		matched     *EndpointDeclaration
+		params      map[string]string
+		specificity = -1
+	)
+
+	for i := range e.Endpoints {
+		p, s, ok := e.Endpoints[i].Match(method, path)
+		if ok && s > specificity {
+			matched, params, specificity = &e.Endpoints[i], p, s
+		}
+	}
+
+	return matched, params
+}
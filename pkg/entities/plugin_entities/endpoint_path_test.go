@@ -0,0 +1,66 @@
+package plugin_entities
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateEndpointPath(t *testing.T) {
+	for _, path := range []string{"/", "/webhook", "/items/:id", "/items/:id/tags/:tag", "/files/*path", "/proxy/*", "/<legacy>"} {
+		if err := ValidateEndpointPath(path); err != nil {
+			t.Fatalf("%s should be valid: %s", path, err)
+		}
+	}
+
+	for _, path := range []string{"/items/:", "/items/:1d", "/items/:id/:id", "/files/*path/more", "/a/*rest/:id", "/files/*p-ath"} {
+		if err := ValidateEndpointPath(path); err == nil {
+			t.Fatalf("%s should be invalid", path)
+		}
+	}
+}
+
+func TestEndpointDeclarationMatch(t *testing.T) {
+	provider := EndpointProviderDeclaration{
+		Endpoints: []EndpointDeclaration{
+			{Path: "/items/:id", Method: EndpointMethodGet},
+			{Path: "/items/new", Method: EndpointMethodGet},
+			{Path: "/items/:id/tags/:tag", Method: EndpointMethodDelete},
+			{Path: "/files/*path", Method: EndpointMethodGet},
+			{Path: "/proxy/*", Method: EndpointMethodPost},
+		},
+	}
+
+	cases := []struct {
+		method string
+		path   string
+		route  string
+		params map[string]string
+	}{
+		{"GET", "/items/42", "/items/:id", map[string]string{"id": "42"}},
+		{"GET", "/items/42/", "/items/:id", map[string]string{"id": "42"}},
+		{"GET", "/items/new", "/items/new", map[string]string{}},
+		{"delete", "/items/42/tags/red", "/items/:id/tags/:tag", map[string]string{"id": "42", "tag": "red"}},
+		{"GET", "/files/a/b/c.txt", "/files/*path", map[string]string{"path": "a/b/c.txt"}},
+		{"GET", "/files", "/files/*path", map[string]string{"path": ""}},
+		{"POST", "/proxy/v1/chat", "/proxy/*", map[string]string{"*": "v1/chat"}},
+		{"POST", "/items/42", "", nil},
+		{"GET", "/items/42/tags", "", nil},
+		{"GET", "/unknown", "", nil},
+	}
+
+	for _, c := range cases {
+		route, params := provider.Match(c.method, c.path)
+		if c.route == "" {
+			if route != nil {
+				t.Fatalf("%s %s should not match, matched %s", c.method, c.path, route.Path)
+			}
+			continue
+		}
+		if route == nil || route.Path != c.route {
+			t.Fatalf("%s %s should match %s, got %v", c.method, c.path, c.route, route)
+		}
+		if !reflect.DeepEqual(params, c.params) {
+			t.Fatalf("%s %s: unexpected params %v", c.method, c.path, params)
+		}
+	}
+}
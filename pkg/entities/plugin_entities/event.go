@@ -23,6 +23,8 @@ func ParsePluginUniversalEvent(
 	heartbeatHandler func(),
 	errorHandler func(err string),
 	infoHandler func(message string),
+	pongHandler func(id string),
+	handshakeHandler func(handshake PluginHandshakeEvent),
 ) {
 	// handle event
 	event, err := parser.UnmarshalJsonBytes[PluginUniversalEvent](data)
@@ -56,6 +58,26 @@ func ParsePluginUniversalEvent(
 		errorHandler(string(event.Data))
 	case PLUGIN_EVENT_HEARTBEAT:
 		heartbeatHandler()
+	case PLUGIN_EVENT_PONG:
+		if pongHandler == nil {
+			return
+		}
+		pong, err := parser.UnmarshalJsonBytes[PluginPingEvent](event.Data)
+		if err != nil {
+			log.Error("unmarshal json failed: %s", err.Error())
+			return
+		}
+		pongHandler(pong.ID)
+	case PLUGIN_EVENT_HANDSHAKE:
+		if handshakeHandler == nil {
+			return
+		}
+		handshake, err := parser.UnmarshalJsonBytes[PluginHandshakeEvent](event.Data)
+		if err != nil {
+			log.Error("unmarshal json failed: %s", err.Error())
+			return
+		}
+		handshakeHandler(handshake)
 	}
 }
 
@@ -66,8 +88,61 @@ const (
 	PLUGIN_EVENT_SESSION   PluginEventType = "session"
 	PLUGIN_EVENT_ERROR     PluginEventType = "error"
 	PLUGIN_EVENT_HEARTBEAT PluginEventType = "heartbeat"
+	// PLUGIN_EVENT_PONG answers a ping written to stdin, it's sent from the loop handling requests
+	// unlike heartbeats, so a plugin which is alive but stuck stops answering
+	PLUGIN_EVENT_PONG PluginEventType = "pong"
+	// PLUGIN_EVENT_HANDSHAKE reports the sdk of a plugin before anything else, plugins without it are
+	// treated as PLUGIN_PROTOCOL_VERSION_LEGACY
+	PLUGIN_EVENT_HANDSHAKE PluginEventType = "handshake"
+)
+
+// PLUGIN_IN_STREAM_EVENT_PING is written to stdin of a plugin, it must be answered with PLUGIN_EVENT_PONG
+// carrying the same id
+const PLUGIN_IN_STREAM_EVENT_PING = "ping"
+
+type PluginPingEvent struct {
+	ID string `json:"id"`
+}
+
+// PLUGIN_IN_STREAM_EVENT_HANDSHAKE answers PLUGIN_EVENT_HANDSHAKE with the protocol version and the
+// capabilities the daemon agreed on, plugins must not use anything else
+const PLUGIN_IN_STREAM_EVENT_HANDSHAKE = "handshake"
+
+const (
+	PLUGIN_PROTOCOL_VERSION_LEGACY = 1
+	PLUGIN_PROTOCOL_VERSION        = 2
 )
 
+type PluginCapability string
+
+const (
+	PLUGIN_CAPABILITY_HEARTBEAT    PluginCapability = "heartbeat"
+	PLUGIN_CAPABILITY_PING         PluginCapability = "ping"
+	PLUGIN_CAPABILITY_BLOB_CHANNEL PluginCapability = "blob_channel"
+	PLUGIN_CAPABILITY_SOCKET       PluginCapability = "socket"
+	PLUGIN_CAPABILITY_TRACING      PluginCapability = "tracing"
+	// PLUGIN_CAPABILITY_ABORT is declared by sdks handling the abort in-stream event
+	PLUGIN_CAPABILITY_ABORT PluginCapability = "abort"
+)
+
+type PluginHandshakeEvent struct {
+	SDKVersion      string             `json:"sdk_version"`
+	ProtocolVersion int                `json:"protocol_version" validate:"required,min=1"`
+	Capabilities    []PluginCapability `json:"capabilities"`
+	// Encodings the plugin can use instead of json lines in order of preference, the daemon answers
+	// with the one it picked in encoding
+	Encodings []string `json:"encodings"`
+}
+
+func (h *PluginHandshakeEvent) Supports(capability PluginCapability) bool {
+	for _, c := range h.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
 type PluginLogEvent struct {
 	Level     string  `json:"level"`
 	Message   string  `json:"message"`
@@ -83,6 +83,13 @@ func (p PluginUniqueIdentifier) String() string {
 	return string(p)
 }
 
+// SplitPluginVersion splits a plugin id qualified with a version like author/name:1.0.0,
+// the version is empty if the plugin id is not qualified
+func SplitPluginVersion(pluginId string) (string, manifest_entities.Version) {
+	id, version, _ := strings.Cut(pluginId, ":")
+	return id, manifest_entities.Version(version)
+}
+
 func (p PluginUniqueIdentifier) Validate() error {
 	return validators.GlobalEntitiesValidator.Var(p, "plugin_unique_identifier")
 }
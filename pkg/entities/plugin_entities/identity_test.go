@@ -47,3 +47,15 @@ func TestPluginUniqueIdentifier(t *testing.T) {
 		t.Fatalf("NewPluginUniqueIdentifier() returned nil error for invalid identifier")
 	}
 }
+
+func TestSplitPluginVersion(t *testing.T) {
+	id, version := SplitPluginVersion("langgenius/test:1.0.0")
+	if id != "langgenius/test" || version != "1.0.0" {
+		t.Fatalf("SplitPluginVersion() = %s, %s; want langgenius/test, 1.0.0", id, version)
+	}
+
+	id, version = SplitPluginVersion("langgenius/test")
+	if id != "langgenius/test" || version != "" {
+		t.Fatalf("SplitPluginVersion() = %s, %s; want langgenius/test and no version", id, version)
+	}
+}
@@ -0,0 +1,84 @@
+package plugin_entities
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+const (
+	// schemas are compiled against draft 2020-12 unless they declare another one with $schema
+	JSON_SCHEMA_RESOURCE   = "output_schema.json"
+	JSON_SCHEMA_CACHE_SIZE = 1024
+)
+
+type compiledJSONSchema struct {
+	schema *jsonschema.Schema
+	err    error
+}
+
+var (
+	// compiled schemas by their canonical json, plugins share most output schemas across versions
+	jsonSchemaCache     = map[string]compiledJSONSchema{}
+	jsonSchemaCacheLock sync.RWMutex
+)
+
+// refusingLoader keeps $ref from fetching remote or local schemas while validating a manifest
+type refusingLoader struct{}
+
+func (refusingLoader) Load(url string) (any, error) {
+	return nil, fmt.Errorf("loading referenced schema %s is not allowed", url)
+}
+
+// CompileJSONSchema validates the schema against its meta-schema, results are cached by the
+// canonical json of the schema so it's only compiled once, the compiled schema is safe for
+// concurrent use
+func CompileJSONSchema(schema map[string]any) (*jsonschema.Schema, error) {
+	// keys of maps are sorted by encoding/json, equal schemas share the key
+	canonical, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	key := string(canonical)
+
+	jsonSchemaCacheLock.RLock()
+	cached, ok := jsonSchemaCache[key]
+	jsonSchemaCacheLock.RUnlock()
+	if ok {
+		return cached.schema, cached.err
+	}
+
+	compiled, err := compile(canonical)
+
+	jsonSchemaCacheLock.Lock()
+	if len(jsonSchemaCache) >= JSON_SCHEMA_CACHE_SIZE {
+		jsonSchemaCache = map[string]compiledJSONSchema{}
+	}
+	jsonSchemaCache[key] = compiledJSONSchema{schema: compiled, err: err}
+	jsonSchemaCacheLock.Unlock()
+
+	return compiled, err
+}
+
+func compile(canonical []byte) (*jsonschema.Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(canonical))
+	if err != nil {
+		return nil, err
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.DefaultDraft(jsonschema.Draft2020)
+	compiler.UseLoader(refusingLoader{})
+	if err := compiler.AddResource(JSON_SCHEMA_RESOURCE, doc); err != nil {
+		return nil, err
+	}
+	compiled, err := compiler.Compile(JSON_SCHEMA_RESOURCE)
+	if err != nil {
+		return nil, errors.Join(err, fmt.Errorf("invalid json schema"))
+	}
+	return compiled, nil
+}
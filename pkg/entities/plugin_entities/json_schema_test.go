@@ -0,0 +1,47 @@
+package plugin_entities
+
+import (
+	"testing"
+)
+
+func TestCompileJSONSchema(t *testing.T) {
+	cases := []struct {
+		name   string
+		schema map[string]any
+		valid  bool
+	}{
+		{"object", map[string]any{"type": "object", "properties": map[string]any{"message": map[string]any{"type": "string"}}}, true},
+		{"unknown type", map[string]any{"type": "object", "properties": map[string]any{"message": map[string]any{"type": "strin"}}}, false},
+		{"negative length", map[string]any{"type": "object", "properties": map[string]any{"message": map[string]any{"type": "string", "minLength": -1}}}, false},
+		{"required is not a list", map[string]any{"type": "object", "required": "message"}, false},
+		{"prefix items", map[string]any{"type": "array", "prefixItems": []any{map[string]any{"type": "string"}}}, true},
+		{"local ref", map[string]any{"$defs": map[string]any{"s": map[string]any{"type": "string"}}, "$ref": "#/$defs/s"}, true},
+		{"remote ref", map[string]any{"$ref": "https://example.com/schema.json"}, false},
+		{"file ref", map[string]any{"$ref": "file:///etc/passwd"}, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := CompileJSONSchema(c.schema)
+			if (err == nil) != c.valid {
+				t.Fatalf("expected valid %v, got %v", c.valid, err)
+			}
+			// the cached result is the same
+			if _, cached := CompileJSONSchema(c.schema); (cached == nil) != c.valid {
+				t.Fatalf("expected cached valid %v, got %v", c.valid, cached)
+			}
+		})
+	}
+}
+
+func TestCompileJSONSchemaCacheIsBounded(t *testing.T) {
+	for i := 0; i < JSON_SCHEMA_CACHE_SIZE+10; i++ {
+		CompileJSONSchema(map[string]any{"type": "object", "maxProperties": i})
+	}
+
+	jsonSchemaCacheLock.RLock()
+	defer jsonSchemaCacheLock.RUnlock()
+	if len(jsonSchemaCache) > JSON_SCHEMA_CACHE_SIZE {
+		t.Fatalf("expected at most %d cached schemas, got %d", JSON_SCHEMA_CACHE_SIZE, len(jsonSchemaCache))
+	}
+}
@@ -113,6 +113,18 @@ type PluginPermissionStorageRequirement struct {
 type PluginResourceRequirement struct {
 	// Memory in bytes
 	Memory int64 `json:"memory" yaml:"memory" validate:"required"`
+	// CPU in cores, e.g. 0.5, zero means unlimited
+	CPU float64 `json:"cpu,omitempty" yaml:"cpu,omitempty" validate:"omitempty,min=0"`
+	// FileDescriptors limits open files of the plugin process, zero means unlimited
+	FileDescriptors uint64 `json:"file_descriptors,omitempty" yaml:"file_descriptors,omitempty"`
+	// MaxConcurrency limits the invocations the plugin runs at the same time on a node, zero means unlimited
+	MaxConcurrency int `json:"max_concurrency,omitempty" yaml:"max_concurrency,omitempty" validate:"omitempty,min=0"`
+	// ModelCache opts in to caching responses of backwards llm and text embedding invocations,
+	// enforced only if the daemon enables the invocation cache
+	ModelCache bool `json:"model_cache,omitempty" yaml:"model_cache,omitempty"`
+	// Egress lists the domains, wildcard domains like *.example.com and cidrs the plugin connects to,
+	// enforced only if the daemon enables egress policies
+	Egress []string `json:"egress,omitempty" yaml:"egress,omitempty" validate:"omitempty,max=128,dive,max=256"`
 	// Permission requirements
 	Permission *PluginPermissionRequirement `json:"permission,omitempty" yaml:"permission,omitempty" validate:"omitempty"`
 }
@@ -130,6 +142,16 @@ type PluginMeta struct {
 	Arch               []constants.Arch `json:"arch" yaml:"arch" validate:"required,dive,is_available_arch"`
 	Runner             PluginRunner     `json:"runner" yaml:"runner" validate:"required"`
 	MinimumDifyVersion *string          `json:"minimum_dify_version" yaml:"minimum_dify_version"`
+	// Dependencies are the plugins which must be installed to the tenant before the plugin
+	Dependencies []PluginDependencyDeclaration `json:"dependencies,omitempty" yaml:"dependencies,omitempty" validate:"omitempty,max=64,dive"`
+	// InvokeTimeout is how many seconds an invocation of the plugin may take, the max execution
+	// timeout of the daemon applies if it's longer or unset
+	InvokeTimeout int `json:"invoke_timeout,omitempty" yaml:"invoke_timeout,omitempty" validate:"omitempty,min=1"`
+}
+
+type PluginDependencyDeclaration struct {
+	PluginID string                              `json:"plugin_id" yaml:"plugin_id" validate:"required,max=255"`
+	Version  manifest_entities.VersionConstraint `json:"version" yaml:"version" validate:"omitempty,version_constraint"`
 }
 
 type PluginExtensions struct {
@@ -51,6 +51,8 @@ type (
 		SetScheduledAt(t time.Time)
 		// add restarts to the plugin
 		AddRestarts()
+		// set when the plugin will be restarted, nil once it's restarting
+		SetNextRestartAt(t *time.Time)
 		// Started
 		WaitStarted() <-chan bool
 		// Stopped
@@ -180,6 +182,10 @@ func (r *PluginRuntime) AddRestarts() {
 	r.State.Restarts++
 }
 
+func (r *PluginRuntime) SetNextRestartAt(t *time.Time) {
+	r.State.NextRestartAt = t
+}
+
 func (r *PluginRuntime) OnStop(f func()) {
 	r.onStopped = append(r.onStopped, f)
 }
@@ -217,8 +223,20 @@ type PluginRuntimeState struct {
 	ActiveAt    *time.Time `json:"active_at"`
 	StoppedAt   *time.Time `json:"stopped_at"`
 	Verified    bool       `json:"verified"`
+	TrustTier   string     `json:"trust_tier"`
 	ScheduledAt *time.Time `json:"scheduled_at"`
 	Logs        []string   `json:"logs"`
+	// LastPongAt is when the plugin last answered a health check ping
+	LastPongAt *time.Time `json:"last_pong_at"`
+	// Hangs counts the restarts caused by unanswered pings
+	Hangs int `json:"hangs"`
+	// NextRestartAt is set while a restart is delayed by backoff
+	NextRestartAt *time.Time `json:"next_restart_at"`
+	// what the plugin reported in its handshake and the daemon agreed on
+	SDKVersion      string   `json:"sdk_version"`
+	ProtocolVersion int      `json:"protocol_version"`
+	Capabilities    []string `json:"capabilities"`
+	Encoding        string   `json:"encoding"`
 }
 
 func (s *PluginRuntimeState) Hash() (uint64, error) {
@@ -12,7 +12,6 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/parser"
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/manifest_entities"
 	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
-	"github.com/xeipuuv/gojsonschema"
 	"gopkg.in/yaml.v3"
 )
 
@@ -136,6 +135,7 @@ type ToolParameter struct {
 	Max              *float64               `json:"max" yaml:"max" validate:"omitempty"`
 	Precision        *int                   `json:"precision" yaml:"precision" validate:"omitempty"`
 	Options          []ParameterOption      `json:"options" yaml:"options" validate:"omitempty,dive"`
+	Pattern          *string                `json:"pattern,omitempty" yaml:"pattern,omitempty" validate:"omitempty,max=1024,is_regex"`
 }
 
 type ToolDescription struct {
@@ -170,7 +170,7 @@ func isJSONSchema(fl validator.FieldLevel) bool {
 	}
 
 	// Create a deep copy of the schema map immediately to avoid concurrent map access issues
-	// when the gojsonschema library marshals the map to JSON
+	// when the map is marshaled to JSON
 	schemaMapCopy := make(map[string]any)
 	for k, v := range schemaMap {
 		schemaMapCopy[k] = deepCopyValue(v)
@@ -196,11 +196,7 @@ func isJSONSchema(fl validator.FieldLevel) bool {
 		}
 	}
 
-	_, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(schemaMapCopy))
-	if err != nil {
-		return false
-	}
-
+	_, err := CompileJSONSchema(schemaMapCopy)
 	return err == nil
 }
 
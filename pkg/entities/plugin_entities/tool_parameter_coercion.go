@@ -0,0 +1,197 @@
+package plugin_entities
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
+)
+
+func isRegex(fl validator.FieldLevel) bool {
+	_, err := regexp.Compile(fl.Field().String())
+	return err == nil
+}
+
+func init() {
+	validators.GlobalEntitiesValidator.RegisterValidation("is_regex", isRegex)
+}
+
+// ToolParameterError reports the parameter a tool invocation was rejected for
+type ToolParameterError struct {
+	Parameter string `json:"parameter"`
+	Reason    string `json:"reason"`
+}
+
+func (e *ToolParameterError) Error() string {
+	return fmt.Sprintf("invalid tool parameter %s: %s", e.Parameter, e.Reason)
+}
+
+// CoerceParameters converts the values of declared parameters to their types, fills in defaults
+// and checks options, min, max and pattern, parameters that aren't declared are kept as they are
+// since tools with runtime parameters receive them too
+func (t *ToolDeclaration) CoerceParameters(values map[string]any) (map[string]any, error) {
+	coerced := make(map[string]any, len(values))
+	for name, value := range values {
+		coerced[name] = value
+	}
+
+	for _, parameter := range t.Parameters {
+		value, ok := coerced[parameter.Name]
+		if !ok || value == nil || value == "" {
+			// an empty text is a value unless it's required
+			text := parameter.Type == TOOL_PARAMETER_TYPE_STRING || parameter.Type == TOOL_PARAMETER_TYPE_SECRET_INPUT
+			switch {
+			case parameter.Default != nil && !(text && value == ""):
+				value = parameter.Default
+			case parameter.Required:
+				return nil, &ToolParameterError{Parameter: parameter.Name, Reason: "required"}
+			default:
+				continue
+			}
+		}
+
+		value, err := parameter.coerce(value)
+		if err != nil {
+			return nil, &ToolParameterError{Parameter: parameter.Name, Reason: err.Error()}
+		}
+		coerced[parameter.Name] = value
+	}
+
+	return coerced, nil
+}
+
+func (p *ToolParameter) coerce(value any) (any, error) {
+	switch p.Type {
+	case TOOL_PARAMETER_TYPE_STRING, TOOL_PARAMETER_TYPE_SECRET_INPUT:
+		s, err := coerceString(value)
+		if err != nil {
+			return nil, err
+		}
+		if p.Pattern != nil {
+			pattern, err := regexp.Compile(*p.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %s", *p.Pattern)
+			}
+			if !pattern.MatchString(s) {
+				return nil, fmt.Errorf("does not match %s", *p.Pattern)
+			}
+		}
+		return s, nil
+	case TOOL_PARAMETER_TYPE_NUMBER:
+		n, err := coerceNumber(value)
+		if err != nil {
+			return nil, err
+		}
+		if p.Min != nil && n < *p.Min {
+			return nil, fmt.Errorf("must be at least %v", *p.Min)
+		}
+		if p.Max != nil && n > *p.Max {
+			return nil, fmt.Errorf("must be at most %v", *p.Max)
+		}
+		if p.Precision != nil {
+			scale := math.Pow10(*p.Precision)
+			n = math.Round(n*scale) / scale
+		}
+		return n, nil
+	case TOOL_PARAMETER_TYPE_BOOLEAN:
+		return coerceBool(value)
+	case TOOL_PARAMETER_TYPE_SELECT:
+		s, err := coerceString(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(p.Options) == 0 {
+			return s, nil
+		}
+		allowed := make([]string, 0, len(p.Options))
+		for _, option := range p.Options {
+			if option.Value == s {
+				return s, nil
+			}
+			allowed = append(allowed, option.Value)
+		}
+		return nil, fmt.Errorf("must be one of %s", strings.Join(allowed, ", "))
+	default:
+		// files, selectors and structured values are resolved by dify
+		return value, nil
+	}
+}
+
+func coerceString(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v), nil
+	default:
+		return "", fmt.Errorf("expected a string, got %T", value)
+	}
+}
+
+func coerceNumber(value any) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int8:
+		return float64(v), nil
+	case int16:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint:
+		return float64(v), nil
+	case uint8:
+		return float64(v), nil
+	case uint16:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+			return 0, fmt.Errorf("expected a number, got %q", v)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("expected a number, got %T", value)
+	}
+}
+
+func coerceBool(value any) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "1", "yes", "on":
+			return true, nil
+		case "false", "0", "no", "off":
+			return false, nil
+		}
+		return false, fmt.Errorf("expected a boolean, got %q", v)
+	default:
+		n, err := coerceNumber(value)
+		if err != nil || (n != 0 && n != 1) {
+			return false, fmt.Errorf("expected a boolean, got %v", value)
+		}
+		return n == 1, nil
+	}
+}
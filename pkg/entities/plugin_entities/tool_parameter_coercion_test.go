@@ -0,0 +1,72 @@
+package plugin_entities
+
+import (
+	"errors"
+	"testing"
+)
+
+func coercionTool() *ToolDeclaration {
+	min, max, precision := 1.0, 10.0, 1
+	pattern := `^[a-z]+$`
+	return &ToolDeclaration{
+		Parameters: []ToolParameter{
+			{Name: "query", Type: TOOL_PARAMETER_TYPE_STRING, Required: true, Pattern: &pattern},
+			{Name: "limit", Type: TOOL_PARAMETER_TYPE_NUMBER, Default: 5, Min: &min, Max: &max, Precision: &precision},
+			{Name: "safe", Type: TOOL_PARAMETER_TYPE_BOOLEAN, Default: "true"},
+			{Name: "engine", Type: TOOL_PARAMETER_TYPE_SELECT, Options: []ParameterOption{{Value: "google"}, {Value: "bing"}}},
+			{Name: "note", Type: TOOL_PARAMETER_TYPE_STRING, Default: "none"},
+		},
+	}
+}
+
+func TestCoerceParameters(t *testing.T) {
+	coerced, err := coercionTool().CoerceParameters(map[string]any{
+		"query":   "dify",
+		"limit":   "2.56",
+		"engine":  "bing",
+		"note":    "",
+		"runtime": []any{"kept"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if coerced["query"] != "dify" || coerced["limit"] != 2.6 || coerced["safe"] != true || coerced["engine"] != "bing" {
+		t.Fatalf("unexpected parameters %v", coerced)
+	}
+	if coerced["note"] != "" {
+		t.Fatalf("expected an empty text to be kept, got %v", coerced["note"])
+	}
+	if _, ok := coerced["runtime"]; !ok {
+		t.Fatalf("expected undeclared parameters to be kept, got %v", coerced)
+	}
+
+	coerced, err = coercionTool().CoerceParameters(map[string]any{"query": "dify"})
+	if err != nil || coerced["limit"] != 5.0 || coerced["note"] != "none" {
+		t.Fatalf("expected defaults to be filled in, got %v %v", coerced, err)
+	}
+}
+
+func TestCoerceParametersRejects(t *testing.T) {
+	cases := []struct {
+		values    map[string]any
+		parameter string
+	}{
+		{map[string]any{}, "query"},
+		{map[string]any{"query": ""}, "query"},
+		{map[string]any{"query": "Dify"}, "query"},
+		{map[string]any{"query": "dify", "limit": "many"}, "limit"},
+		{map[string]any{"query": "dify", "limit": 11}, "limit"},
+		{map[string]any{"query": "dify", "safe": "maybe"}, "safe"},
+		{map[string]any{"query": "dify", "engine": "yahoo"}, "engine"},
+		{map[string]any{"query": map[string]any{}}, "query"},
+	}
+
+	for _, c := range cases {
+		_, err := coercionTool().CoerceParameters(c.values)
+		var parameterError *ToolParameterError
+		if !errors.As(err, &parameterError) || parameterError.Parameter != c.parameter {
+			t.Errorf("%v: expected %s to be rejected, got %v", c.values, c.parameter, err)
+		}
+	}
+}
@@ -1,5 +1,6 @@
 package requests
 
 type RequestGetRemoteDebuggingKey struct {
-	TenantID string `uri:"tenant_id" validate:"required"`
+	TenantID  string `uri:"tenant_id" validate:"required"`
+	Namespace string `json:"namespace" form:"namespace"`
 }
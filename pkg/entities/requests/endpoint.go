@@ -3,4 +3,8 @@ package requests
 type RequestInvokeEndpoint struct {
 	RawHttpRequest string         `json:"raw_http_request" validate:"required"`
 	Settings       map[string]any `json:"settings" validate:"omitempty"`
+	// Route is the declared path the request matched, PathParams are extracted from it,
+	// both are empty if no declaration matched
+	Route      string            `json:"route,omitempty" validate:"omitempty"`
+	PathParams map[string]string `json:"path_params,omitempty" validate:"omitempty"`
 }
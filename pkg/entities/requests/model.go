@@ -6,6 +6,9 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/pkg/entities/model_entities"
 )
 
+// CREDENTIAL_TYPE_OAUTH2 credentials are injected by the daemon from what was stored by the oauth callback
+const CREDENTIAL_TYPE_OAUTH2 = "oauth2"
+
 type Credentials struct {
 	Credentials    map[string]any `json:"credentials" validate:"omitempty"`
 	CredentialType string         `json:"credential_type,omitempty" validate:"omitempty"`
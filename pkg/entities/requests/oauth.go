@@ -19,3 +19,12 @@ type RequestOAuthRefreshCredentials struct {
 	SystemCredentials map[string]any `json:"system_credentials" validate:"omitempty"`
 	Credentials       map[string]any `json:"credentials" validate:"required"`
 }
+
+// RequestOAuthAuthorize starts an authorization finished by the daemon itself, redirect_uri must lead
+// to /oauth/callback of the daemon which stores the credentials the provider grants
+type RequestOAuthAuthorize struct {
+	Provider          string         `json:"provider" validate:"required"`
+	RedirectURI       string         `json:"redirect_uri" validate:"required,url"`
+	SystemCredentials map[string]any `json:"system_credentials" validate:"omitempty"`
+	ReturnURL         string         `json:"return_url" validate:"omitempty,url"`
+}
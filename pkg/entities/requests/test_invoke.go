@@ -0,0 +1,13 @@
+package requests
+
+type RequestTestInvokePlugin struct {
+	// PluginPath is the unpacked plugin directory relative to PLUGIN_TEST_INVOKE_ROOT
+	PluginPath  string         `json:"plugin_path" validate:"required"`
+	Kind        string         `json:"kind" validate:"required,oneof=tool model"`
+	Provider    string         `json:"provider" validate:"required"`
+	Name        string         `json:"name" validate:"required"`
+	ModelType   string         `json:"model_type" validate:"omitempty"`
+	Parameters  map[string]any `json:"parameters" validate:"omitempty"`
+	Credentials map[string]any `json:"credentials" validate:"omitempty"`
+	Timeout     int            `json:"timeout" validate:"omitempty,min=1,max=1800"`
+}
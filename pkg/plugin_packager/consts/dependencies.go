@@ -0,0 +1,10 @@
+package consts
+
+const (
+	// DEPENDENCY_BUNDLE_DIR holds the wheels bundled into a package, the daemon installs from it first
+	// and only from it in offline mode
+	DEPENDENCY_BUNDLE_DIR = "wheels"
+	// DEPENDENCY_BUNDLE_MARKER is written into DEPENDENCY_BUNDLE_DIR by bundling, a directory without it
+	// belongs to the plugin and is never replaced
+	DEPENDENCY_BUNDLE_MARKER = ".bundle.dify"
+)
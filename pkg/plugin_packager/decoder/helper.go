@@ -426,22 +426,18 @@ func (p *PluginDecoderHelper) verified(decoder PluginDecoder) bool {
 
 	// verify signature
 	// for ZipPluginDecoder, use the third party signature verification if it is enabled
+	var config *ThirdPartySignatureVerificationConfig
 	if zipDecoder, ok := decoder.(*ZipPluginDecoder); ok {
-		config := zipDecoder.thirdPartySignatureVerificationConfig
-		if config != nil && config.Enabled && len(config.PublicKeyPaths) > 0 {
-			verified := VerifyPluginWithPublicKeyPaths(decoder, config.PublicKeyPaths) == nil
-			p.verifiedFlag = &verified
-			return verified
-		} else {
-			verified := VerifyPlugin(decoder) == nil
-			p.verifiedFlag = &verified
-			return verified
-		}
-	} else {
-		verified := VerifyPlugin(decoder) == nil
-		p.verifiedFlag = &verified
-		return verified
+		config = zipDecoder.thirdPartySignatureVerificationConfig
+	}
+
+	verified := false
+	keys, err := TrustedKeys(config)
+	if err == nil {
+		verified = VerifyPluginWithTrustedKeys(decoder, keys) == nil
 	}
+	p.verifiedFlag = &verified
+	return verified
 }
 
 var (
@@ -0,0 +1,169 @@
+package decoder
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/langgenius/dify-plugin-daemon/internal/core/license/public_key"
+	"github.com/langgenius/dify-plugin-daemon/internal/utils/encryption"
+	"gopkg.in/yaml.v3"
+)
+
+// TrustedKey is a public key plugins can be signed with
+type TrustedKey struct {
+	ID        string
+	PublicKey *rsa.PublicKey
+	// NotAfter retires the key, plugins signed before stay valid so rotating a key
+	// does not require re-signing installed plugins, zero means the key never retires
+	NotAfter time.Time
+}
+
+// PublicKeyID is the default id of a key, signers embed it into the signature block
+func PublicKeyID(publicKey *rsa.PublicKey) string {
+	der := x509.MarshalPKCS1PublicKey(publicKey)
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:8])
+}
+
+func NewTrustedKey(publicKey *rsa.PublicKey) TrustedKey {
+	return TrustedKey{
+		ID:        PublicKeyID(publicKey),
+		PublicKey: publicKey,
+	}
+}
+
+// accepts returns false if the key was retired before the plugin was signed
+func (k TrustedKey) accepts(createdAt int64) bool {
+	return k.NotAfter.IsZero() || createdAt <= k.NotAfter.Unix()
+}
+
+type keyringFile struct {
+	Keys []struct {
+		ID       string    `yaml:"id"`
+		Path     string    `yaml:"path"`
+		NotAfter time.Time `yaml:"not_after"`
+	} `yaml:"keys"`
+}
+
+// LoadKeyring reads a yaml file of trusted keys like
+//
+//	keys:
+//	  - id: enterprise-2024
+//	    path: /keys/enterprise-2024.pem
+//	    not_after: 2025-06-30T00:00:00Z
+//	  - path: /keys/enterprise-2025.pem
+//
+// the id defaults to PublicKeyID of the key
+func LoadKeyring(path string) ([]TrustedKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file keyringFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse keyring %s: %w", path, err)
+	}
+
+	keys := make([]TrustedKey, 0, len(file.Keys))
+	for _, entry := range file.Keys {
+		publicKey, err := loadPublicKeyFile(entry.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		key := NewTrustedKey(publicKey)
+		if entry.ID != "" {
+			key.ID = entry.ID
+		}
+		key.NotAfter = entry.NotAfter
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func loadPublicKeyFile(path string) (*rsa.PublicKey, error) {
+	// open file by trimming the spaces in path
+	keyBytes, err := os.ReadFile(strings.TrimSpace(path))
+	if err != nil {
+		return nil, err
+	}
+	return encryption.LoadPublicKey(keyBytes)
+}
+
+func officialTrustedKey() (TrustedKey, error) {
+	officialPublicKey, err := encryption.LoadPublicKey(public_key.PUBLIC_KEY)
+	if err != nil {
+		return TrustedKey{}, err
+	}
+	return NewTrustedKey(officialPublicKey), nil
+}
+
+// TrustedKeys returns the official key followed by the keys of config
+func TrustedKeys(config *ThirdPartySignatureVerificationConfig) ([]TrustedKey, error) {
+	official, err := officialTrustedKey()
+	if err != nil {
+		return nil, err
+	}
+	keys := []TrustedKey{official}
+
+	if config == nil || !config.Enabled {
+		return keys, nil
+	}
+
+	for _, publicKeyPath := range config.PublicKeyPaths {
+		publicKey, err := loadPublicKeyFile(publicKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, NewTrustedKey(publicKey))
+	}
+
+	if config.KeyringPath != "" {
+		keyring, err := LoadKeyring(config.KeyringPath)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, keyring...)
+	}
+
+	return keys, nil
+}
+
+// candidateKeys orders keys by whether their id matches keyID, the id is not covered by
+// the signature so it only decides which keys are tried first
+func candidateKeys(keys []TrustedKey, keyID string, createdAt int64) ([]TrustedKey, error) {
+	matched := []TrustedKey{}
+	others := []TrustedKey{}
+	retired := []string{}
+
+	for _, key := range keys {
+		if !key.accepts(createdAt) {
+			retired = append(retired, key.ID)
+			continue
+		}
+		if keyID != "" && key.ID == keyID {
+			matched = append(matched, key)
+		} else {
+			others = append(others, key)
+		}
+	}
+
+	candidates := append(matched, others...)
+	if len(candidates) == 0 {
+		if len(retired) > 0 {
+			return nil, fmt.Errorf("plugin was signed after keys %s were retired", strings.Join(retired, ", "))
+		}
+		return nil, errors.New("no trusted keys to verify the plugin")
+	}
+
+	return candidates, nil
+}
@@ -5,63 +5,52 @@ import (
 	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/base64"
-	"os"
 	"path"
 	"strconv"
-	"strings"
 
-	"github.com/langgenius/dify-plugin-daemon/internal/core/license/public_key"
 	"github.com/langgenius/dify-plugin-daemon/internal/utils/encryption"
 )
 
 // VerifyPlugin is a function that verifies the signature of a plugin
 // It takes a plugin decoder and verifies the signature with a bundled public key
 func VerifyPlugin(decoder PluginDecoder) error {
-	var publicKeys []*rsa.PublicKey
-
-	// load official public key
-	officialPublicKey, err := encryption.LoadPublicKey(public_key.PUBLIC_KEY)
+	keys, err := TrustedKeys(nil)
 	if err != nil {
 		return err
 	}
-	publicKeys = append(publicKeys, officialPublicKey)
 
 	// verify the plugin
-	return VerifyPluginWithPublicKeys(decoder, publicKeys)
+	return VerifyPluginWithTrustedKeys(decoder, keys)
 }
 
 // VerifyPluginWithPublicKeyPaths is a function that verifies the signature of a plugin
 // It takes a plugin decoder and a list of public key paths to verify the signature
 func VerifyPluginWithPublicKeyPaths(decoder PluginDecoder, publicKeyPaths []string) error {
-	var publicKeys []*rsa.PublicKey
-
-	// load official public key
-	officialPublicKey, err := encryption.LoadPublicKey(public_key.PUBLIC_KEY)
+	keys, err := TrustedKeys(&ThirdPartySignatureVerificationConfig{
+		Enabled:        true,
+		PublicKeyPaths: publicKeyPaths,
+	})
 	if err != nil {
 		return err
 	}
-	publicKeys = append(publicKeys, officialPublicKey)
 
-	// load keys provided in the arguments
-	for _, publicKeyPath := range publicKeyPaths {
-		// open file by trimming the spaces in path
-		keyBytes, err := os.ReadFile(strings.TrimSpace(publicKeyPath))
-		if err != nil {
-			return err
-		}
-		publicKey, err := encryption.LoadPublicKey(keyBytes)
-		if err != nil {
-			return err
-		}
-		publicKeys = append(publicKeys, publicKey)
-	}
-
-	return VerifyPluginWithPublicKeys(decoder, publicKeys)
+	return VerifyPluginWithTrustedKeys(decoder, keys)
 }
 
 // VerifyPluginWithPublicKeys is a function that verifies the signature of a plugin
 // It takes a plugin decoder and a list of public keys to verify the signature
 func VerifyPluginWithPublicKeys(decoder PluginDecoder, publicKeys []*rsa.PublicKey) error {
+	keys := make([]TrustedKey, 0, len(publicKeys))
+	for _, publicKey := range publicKeys {
+		keys = append(keys, NewTrustedKey(publicKey))
+	}
+
+	return VerifyPluginWithTrustedKeys(decoder, keys)
+}
+
+// VerifyPluginWithTrustedKeys verifies the signature of a plugin with the key named in its
+// signature block first, then with the rest of keys still valid when the plugin was signed
+func VerifyPluginWithTrustedKeys(decoder PluginDecoder, keys []TrustedKey) error {
 	data := new(bytes.Buffer)
 	// read one by one
 	err := decoder.Walk(func(filename, dir string) error {
@@ -103,10 +92,22 @@ func VerifyPluginWithPublicKeys(decoder PluginDecoder, publicKeys []*rsa.PublicK
 		return err
 	}
 
+	keyID := ""
+	if d, ok := decoder.(interface{ KeyID() (string, error) }); ok {
+		if keyID, err = d.KeyID(); err != nil {
+			return err
+		}
+	}
+
+	candidates, err := candidateKeys(keys, keyID, createdAt)
+	if err != nil {
+		return err
+	}
+
 	// verify signature
 	var lastErr error
-	for _, publicKey := range publicKeys {
-		lastErr = encryption.VerifySign(publicKey, data.Bytes(), sigBytes)
+	for _, key := range candidates {
+		lastErr = encryption.VerifySign(key.PublicKey, data.Bytes(), sigBytes)
 		if lastErr == nil {
 			return nil
 		}
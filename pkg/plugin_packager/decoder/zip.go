@@ -26,6 +26,7 @@ type ZipPluginDecoder struct {
 	err    error
 
 	sig          string
+	keyID        string
 	createTime   int64
 	verification *Verification
 
@@ -35,19 +36,36 @@ type ZipPluginDecoder struct {
 type ThirdPartySignatureVerificationConfig struct {
 	Enabled        bool
 	PublicKeyPaths []string
+	// KeyringPath is a yaml file of trusted keys with ids and retirement times, see LoadKeyring
+	KeyringPath string
 }
 
 func newZipPluginDecoder(
-	binary []byte,
+	reader io.ReaderAt,
+	size int64,
+	maxSize int64,
 	thirdPartySignatureVerificationConfig *ThirdPartySignatureVerificationConfig,
 ) (*ZipPluginDecoder, error) {
-	reader, err := zip.NewReader(bytes.NewReader(binary), int64(len(binary)))
+	zipReader, err := zip.NewReader(reader, size)
 	if err != nil {
 		return nil, errors.New(strings.ReplaceAll(err.Error(), "zip", "difypkg"))
 	}
 
+	if maxSize > 0 {
+		totalSize := int64(0)
+		for _, file := range zipReader.File {
+			totalSize += int64(file.UncompressedSize64)
+			if totalSize > maxSize {
+				return nil, errors.New(
+					"plugin package size is too large, please ensure the uncompressed size is less than " +
+						strconv.FormatInt(maxSize, 10) + " bytes",
+				)
+			}
+		}
+	}
+
 	decoder := &ZipPluginDecoder{
-		reader:                                reader,
+		reader:                                zipReader,
 		err:                                   err,
 		thirdPartySignatureVerificationConfig: thirdPartySignatureVerificationConfig,
 	}
@@ -66,7 +84,7 @@ func newZipPluginDecoder(
 
 // NewZipPluginDecoder is a helper function to create ZipPluginDecoder
 func NewZipPluginDecoder(binary []byte) (*ZipPluginDecoder, error) {
-	return newZipPluginDecoder(binary, nil)
+	return newZipPluginDecoder(bytes.NewReader(binary), int64(len(binary)), 0, nil)
 }
 
 // NewZipPluginDecoderWithThirdPartySignatureVerificationConfig is a helper function
@@ -75,29 +93,25 @@ func NewZipPluginDecoderWithThirdPartySignatureVerificationConfig(
 	binary []byte,
 	thirdPartySignatureVerificationConfig *ThirdPartySignatureVerificationConfig,
 ) (*ZipPluginDecoder, error) {
-	return newZipPluginDecoder(binary, thirdPartySignatureVerificationConfig)
+	return newZipPluginDecoder(bytes.NewReader(binary), int64(len(binary)), 0, thirdPartySignatureVerificationConfig)
 }
 
 // NewZipPluginDecoderWithSizeLimit is a helper function to create a ZipPluginDecoder with a size limit
 // It checks the total uncompressed size of the plugin package and returns an error if it exceeds the max size
 func NewZipPluginDecoderWithSizeLimit(binary []byte, maxSize int64) (*ZipPluginDecoder, error) {
-	reader, err := zip.NewReader(bytes.NewReader(binary), int64(len(binary)))
-	if err != nil {
-		return nil, errors.New(strings.ReplaceAll(err.Error(), "zip", "difypkg"))
-	}
-
-	totalSize := int64(0)
-	for _, file := range reader.File {
-		totalSize += int64(file.UncompressedSize64)
-		if totalSize > maxSize {
-			return nil, errors.New(
-				"plugin package size is too large, please ensure the uncompressed size is less than " +
-					strconv.FormatInt(maxSize, 10) + " bytes",
-			)
-		}
-	}
+	return newZipPluginDecoder(bytes.NewReader(binary), int64(len(binary)), maxSize, nil)
+}
 
-	return newZipPluginDecoder(binary, nil)
+// NewZipPluginDecoderWithReaderAt creates a ZipPluginDecoder which reads files of the package from reader
+// on demand, so that the package is never loaded into memory as a whole, e.g. an uploaded or downloaded file.
+// maxSize limits the total uncompressed size like NewZipPluginDecoderWithSizeLimit, 0 is unlimited
+func NewZipPluginDecoderWithReaderAt(
+	reader io.ReaderAt,
+	size int64,
+	maxSize int64,
+	thirdPartySignatureVerificationConfig *ThirdPartySignatureVerificationConfig,
+) (*ZipPluginDecoder, error) {
+	return newZipPluginDecoder(reader, size, maxSize, thirdPartySignatureVerificationConfig)
 }
 
 func (z *ZipPluginDecoder) Stat(filename string) (fs.FileInfo, error) {
@@ -187,6 +201,7 @@ func (z *ZipPluginDecoder) decode() error {
 	signatureData, err := parser.UnmarshalJson[struct {
 		Signature string `json:"signature"`
 		Time      int64  `json:"time"`
+		KeyID     string `json:"key_id"`
 	}](z.reader.Comment)
 
 	if err != nil {
@@ -218,6 +233,7 @@ func (z *ZipPluginDecoder) decode() error {
 	}
 
 	z.sig = pluginSig
+	z.keyID = signatureData.KeyID
 	z.createTime = pluginTime
 	z.verification = verification
 
@@ -241,6 +257,25 @@ func (z *ZipPluginDecoder) Signature() (string, error) {
 	return z.sig, nil
 }
 
+// KeyID returns the id of the key the plugin claims to be signed with, empty for plugins
+// signed before key ids were introduced
+func (z *ZipPluginDecoder) KeyID() (string, error) {
+	if z.sig != "" {
+		return z.keyID, nil
+	}
+
+	if z.reader == nil {
+		return "", z.err
+	}
+
+	err := z.decode()
+	if err != nil {
+		return "", err
+	}
+
+	return z.keyID, nil
+}
+
 func (z *ZipPluginDecoder) CreateTime() (int64, error) {
 	if z.createTime != 0 {
 		return z.createTime, nil
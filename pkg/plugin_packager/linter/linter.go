@@ -0,0 +1,378 @@
+// Package linter checks a plugin against the validators of plugin_entities and reports every problem
+// as a diagnostic pointing at the file and the yaml path it was found at
+package linter
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/langgenius/dify-plugin-daemon/pkg/entities/plugin_entities"
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+	"github.com/langgenius/dify-plugin-daemon/pkg/validators"
+)
+
+type Severity string
+
+const (
+	SEVERITY_ERROR   Severity = "error"
+	SEVERITY_WARNING Severity = "warning"
+)
+
+type Code string
+
+const (
+	CODE_MANIFEST_UNREADABLE  Code = "manifest_unreadable"
+	CODE_FIELD_REQUIRED       Code = "field_required"
+	CODE_FIELD_TOO_LONG       Code = "field_too_long"
+	CODE_FIELD_TOO_SHORT      Code = "field_too_short"
+	CODE_FIELD_INVALID        Code = "field_invalid"
+	CODE_CONFLICTING_PROVIDER Code = "conflicting_provider"
+	CODE_ASSET_MISSING        Code = "asset_missing"
+	CODE_I18N_INCOMPLETE      Code = "i18n_incomplete"
+)
+
+const MANIFEST_FILE = "manifest.yaml"
+
+type Diagnostic struct {
+	File       string   `json:"file"`
+	Path       string   `json:"path"`
+	Code       Code     `json:"code"`
+	Severity   Severity `json:"severity"`
+	Message    string   `json:"message"`
+	Suggestion string   `json:"suggestion,omitempty"`
+}
+
+type Result struct {
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	Errors      int          `json:"errors"`
+	Warnings    int          `json:"warnings"`
+}
+
+// translations every label and description is expected to have besides en_US
+var translations = []struct {
+	field string
+	name  string
+}{
+	{"ZhHans", "zh_Hans"},
+	{"JaJp", "ja_JP"},
+	{"PtBr", "pt_BR"},
+}
+
+// LintDir lints an unpacked plugin directory
+func LintDir(dir string) Result {
+	pluginDecoder, err := decoder.NewFSPluginDecoder(dir)
+	if err != nil {
+		return unreadable(err)
+	}
+	return Lint(pluginDecoder)
+}
+
+// LintPackage lints a packaged plugin
+func LintPackage(pkg []byte) Result {
+	pluginDecoder, err := decoder.NewZipPluginDecoder(pkg)
+	if err != nil {
+		return unreadable(err)
+	}
+	return Lint(pluginDecoder)
+}
+
+// Lint reads the manifest and the declarations it references and reports every problem found, a
+// manifest that can't be read is the only diagnostic in that case
+func Lint(pluginDecoder decoder.PluginDecoder) Result {
+	declaration, err := pluginDecoder.Manifest()
+	if err != nil {
+		return unreadable(err)
+	}
+
+	diagnostics := []Diagnostic{}
+	diagnostics = append(diagnostics, lintDeclaration(&declaration)...)
+
+	if err := declaration.ManifestValidate(); err != nil {
+		diagnostics = append(diagnostics, Diagnostic{
+			File:       MANIFEST_FILE,
+			Path:       "plugins",
+			Code:       CODE_CONFLICTING_PROVIDER,
+			Severity:   SEVERITY_ERROR,
+			Message:    err.Error(),
+			Suggestion: "split the providers into separate plugins",
+		})
+	}
+
+	if err := pluginDecoder.CheckAssetsValid(); err != nil {
+		diagnostics = append(diagnostics, Diagnostic{
+			File:       MANIFEST_FILE,
+			Code:       CODE_ASSET_MISSING,
+			Severity:   SEVERITY_ERROR,
+			Message:    err.Error(),
+			Suggestion: "add the icons to the _assets directory",
+		})
+	}
+
+	diagnostics = append(diagnostics, lintI18n(&declaration)...)
+	return summarize(diagnostics)
+}
+
+func unreadable(err error) Result {
+	return summarize([]Diagnostic{{
+		File:       MANIFEST_FILE,
+		Code:       CODE_MANIFEST_UNREADABLE,
+		Severity:   SEVERITY_ERROR,
+		Message:    err.Error(),
+		Suggestion: "make sure the manifest and the files listed in its plugins section exist and are valid yaml",
+	}})
+}
+
+func summarize(diagnostics []Diagnostic) Result {
+	result := Result{Diagnostics: diagnostics}
+	for _, diagnostic := range diagnostics {
+		switch diagnostic.Severity {
+		case SEVERITY_ERROR:
+			result.Errors++
+		case SEVERITY_WARNING:
+			result.Warnings++
+		}
+	}
+	return result
+}
+
+// lintDeclaration validates the declaration, models aren't validated along with their provider so
+// each of them is validated on its own
+func lintDeclaration(declaration *plugin_entities.PluginDeclaration) []Diagnostic {
+	diagnostics := validate(declaration, "")
+	if declaration.Model != nil {
+		for i := range declaration.Model.Models {
+			diagnostics = append(diagnostics, validate(&declaration.Model.Models[i], fmt.Sprintf("Model.Models[%d]", i))...)
+		}
+	}
+
+	for i := range diagnostics {
+		diagnostics[i].File, diagnostics[i].Path = locate(declaration, diagnostics[i].Path)
+	}
+	return diagnostics
+}
+
+// validate runs the entities validator, the path of a diagnostic is the go path of the field below
+// the declaration until it's located
+func validate(v any, prefix string) []Diagnostic {
+	err := validators.GlobalEntitiesValidator.Struct(v)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return []Diagnostic{{Path: prefix, Code: CODE_FIELD_INVALID, Severity: SEVERITY_ERROR, Message: err.Error()}}
+	}
+
+	diagnostics := []Diagnostic{}
+	for _, fieldError := range validationErrors {
+		// the namespace starts with the name of the validated type
+		_, path, _ := strings.Cut(fieldError.StructNamespace(), ".")
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		diagnostics = append(diagnostics, diagnose(fieldError, path))
+	}
+	return diagnostics
+}
+
+func diagnose(fieldError validator.FieldError, path string) Diagnostic {
+	diagnostic := Diagnostic{Path: path, Severity: SEVERITY_ERROR}
+	switch fieldError.Tag() {
+	case "required":
+		diagnostic.Code = CODE_FIELD_REQUIRED
+		diagnostic.Message = "field is required"
+		diagnostic.Suggestion = "add the field"
+	case "max", "lt", "lte":
+		diagnostic.Code = CODE_FIELD_TOO_LONG
+		diagnostic.Message = fmt.Sprintf("field must be %s %s", fieldError.Tag(), fieldError.Param())
+		diagnostic.Suggestion = fmt.Sprintf("shorten it to %s %s", fieldError.Tag(), fieldError.Param())
+	case "min", "gt", "gte":
+		diagnostic.Code = CODE_FIELD_TOO_SHORT
+		diagnostic.Message = fmt.Sprintf("field must be %s %s", fieldError.Tag(), fieldError.Param())
+		diagnostic.Suggestion = fmt.Sprintf("extend it to %s %s", fieldError.Tag(), fieldError.Param())
+	case "oneof", "eq":
+		diagnostic.Code = CODE_FIELD_INVALID
+		diagnostic.Message = fmt.Sprintf("%v is not allowed", fieldError.Value())
+		diagnostic.Suggestion = fmt.Sprintf("use %s", strings.Join(strings.Fields(fieldError.Param()), " or "))
+	case "version":
+		diagnostic.Code = CODE_FIELD_INVALID
+		diagnostic.Message = fmt.Sprintf("%v is not a version", fieldError.Value())
+		diagnostic.Suggestion = "use a semantic version like 0.0.1"
+	default:
+		diagnostic.Code = CODE_FIELD_INVALID
+		diagnostic.Message = fmt.Sprintf("%v failed on the %s rule", fieldError.Value(), fieldError.Tag())
+	}
+	return diagnostic
+}
+
+// locate turns the go path of a field into the file declaring it and its yaml path in that file
+func locate(declaration *plugin_entities.PluginDeclaration, goPath string) (string, string) {
+	segments := strings.Split(goPath, ".")
+	file, skip := MANIFEST_FILE, 0
+
+	index := func(segment string, field string, files []string) (string, bool) {
+		var i int
+		if _, err := fmt.Sscanf(segment, field+"[%d]", &i); err != nil || i >= len(files) {
+			return "", false
+		}
+		return files[i], true
+	}
+
+	if len(segments) > 1 {
+		switch segments[0] {
+		case "Tool":
+			file, skip = first(declaration.Plugins.Tools), 1
+			if f, ok := index(segments[1], "Tools", declaration.Tool.ToolFiles); ok {
+				file, skip = f, 2
+			}
+		case "Endpoint":
+			file, skip = first(declaration.Plugins.Endpoints), 1
+			if f, ok := index(segments[1], "Endpoints", declaration.Endpoint.EndpointFiles); ok {
+				file, skip = f, 2
+			}
+		case "AgentStrategy":
+			file, skip = first(declaration.Plugins.AgentStrategies), 1
+			if f, ok := index(segments[1], "Strategies", declaration.AgentStrategy.StrategyFiles); ok {
+				file, skip = f, 2
+			}
+		case "Model":
+			// model files are matched by patterns, the path keeps the index of the model
+			file, skip = first(declaration.Plugins.Models), 1
+		}
+	}
+
+	return file, yamlPath(reflect.TypeOf(*declaration), segments, skip)
+}
+
+func first(files []string) string {
+	if len(files) == 0 {
+		return MANIFEST_FILE
+	}
+	return files[0]
+}
+
+// yamlPath follows the go path through the declaration type and names every field by its yaml tag,
+// the first skip segments only move through the type
+func yamlPath(t reflect.Type, segments []string, skip int) string {
+	names := []string{}
+	for i, segment := range segments {
+		fieldName, subscript, _ := strings.Cut(segment, "[")
+		if subscript != "" {
+			subscript = "[" + subscript
+		}
+
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			names = append(names, segment)
+			continue
+		}
+
+		field, ok := t.FieldByName(fieldName)
+		if !ok {
+			names = append(names, segment)
+			continue
+		}
+		t = field.Type
+		if subscript != "" {
+			for t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			if t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+				t = t.Elem()
+			}
+		}
+
+		if field.Anonymous || i < skip {
+			continue
+		}
+		names = append(names, yamlName(field)+subscript)
+	}
+	return strings.Join(names, ".")
+}
+
+func yamlName(field reflect.StructField) string {
+	for _, tag := range []string{"yaml", "json"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return strings.ToLower(field.Name)
+}
+
+// lintI18n warns about labels and descriptions without translations, icons are left out and so are
+// the descriptions filled in from the manifest
+func lintI18n(declaration *plugin_entities.PluginDeclaration) []Diagnostic {
+	diagnostics := []Diagnostic{}
+	i18nType := reflect.TypeOf(plugin_entities.I18nObject{})
+
+	var walk func(v reflect.Value, path []string)
+	walk = func(v reflect.Value, path []string) {
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Interface:
+			if !v.IsNil() {
+				walk(v.Elem(), path)
+			}
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				last := path[len(path)-1]
+				walk(v.Index(i), append(path[:len(path)-1:len(path)-1], fmt.Sprintf("%s[%d]", last, i)))
+			}
+		case reflect.Struct:
+			if v.Type() == i18nType {
+				i18n(v, path, declaration, &diagnostics)
+				return
+			}
+			for i := 0; i < v.NumField(); i++ {
+				field := v.Type().Field(i)
+				if !field.IsExported() || strings.Contains(strings.ToLower(field.Name), "icon") {
+					continue
+				}
+				if field.Anonymous {
+					walk(v.Field(i), path)
+					continue
+				}
+				walk(v.Field(i), append(path[:len(path):len(path)], field.Name))
+			}
+		}
+	}
+	walk(reflect.ValueOf(*declaration), []string{})
+	return diagnostics
+}
+
+func i18n(v reflect.Value, path []string, declaration *plugin_entities.PluginDeclaration, diagnostics *[]Diagnostic) {
+	object := v.Interface().(plugin_entities.I18nObject)
+	goPath := strings.Join(path, ".")
+	if object.EnUS == "" {
+		return
+	}
+	if (goPath == "Tool.Identity.Description" || goPath == "Model.Description") && object == declaration.Description {
+		return
+	}
+
+	missing := []string{}
+	for _, translation := range translations {
+		if v.FieldByName(translation.field).String() == "" {
+			missing = append(missing, translation.name)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	file, yamlPath := locate(declaration, goPath)
+	*diagnostics = append(*diagnostics, Diagnostic{
+		File:       file,
+		Path:       yamlPath,
+		Code:       CODE_I18N_INCOMPLETE,
+		Severity:   SEVERITY_WARNING,
+		Message:    fmt.Sprintf("missing translations for %s", strings.Join(missing, ", ")),
+		Suggestion: fmt.Sprintf("translate it to %s", strings.Join(missing, ", ")),
+	})
+}
@@ -0,0 +1,100 @@
+package linter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const manifest = `version: 0.0.1
+type: plugin
+author: "author"
+name: "neko"
+icon: test.svg
+description:
+  en_US: "test"
+created_at: "2024-07-12T08:03:44.658609186Z"
+resource:
+  memory: 1048576
+plugins:
+  endpoints:
+    - "group/neko.yaml"
+meta:
+  version: 0.0.1
+  arch:
+    - "amd64"
+  runner:
+    language: "python"
+    version: "3.12"
+    entrypoint: "main"
+`
+
+const endpointProvider = `settings:
+  - type: secret-input
+    name: api_key
+    required: true
+    label:
+      en_US: API key
+      zh_Hans: API key
+      pt_BR: API key
+endpoints:
+  - group/hello.yaml
+`
+
+const endpoint = `path: "/hello"
+method: "FETCH"
+`
+
+func TestLint(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"manifest.yaml":    manifest,
+		"group/neko.yaml":  endpointProvider,
+		"group/hello.yaml": endpoint,
+		"_assets/test.svg": "<svg></svg>",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result := LintDir(dir)
+
+	expected := []Diagnostic{
+		{File: "manifest.yaml", Path: "label.en_US", Code: CODE_FIELD_REQUIRED, Severity: SEVERITY_ERROR},
+		{File: "group/hello.yaml", Path: "method", Code: CODE_FIELD_INVALID, Severity: SEVERITY_ERROR},
+		{File: "manifest.yaml", Path: "description", Code: CODE_I18N_INCOMPLETE, Severity: SEVERITY_WARNING},
+		{File: "group/neko.yaml", Path: "settings[0].label", Code: CODE_I18N_INCOMPLETE, Severity: SEVERITY_WARNING},
+	}
+	for _, e := range expected {
+		found := false
+		for _, d := range result.Diagnostics {
+			if d.File == e.File && d.Path == e.Path && d.Code == e.Code && d.Severity == e.Severity {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected %+v in %+v", e, result.Diagnostics)
+		}
+	}
+	if result.Errors != 2 {
+		t.Errorf("expected 2 errors, got %+v", result.Diagnostics)
+	}
+}
+
+func TestLintUnreadableManifest(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "manifest.yaml"), []byte("plugins:\n  tools:\n    - missing.yaml\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	result := LintDir(dir)
+	if result.Errors != 1 || result.Diagnostics[0].Code != CODE_MANIFEST_UNREADABLE {
+		t.Fatalf("expected the manifest to be unreadable, got %+v", result.Diagnostics)
+	}
+}
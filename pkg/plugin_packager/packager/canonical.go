@@ -0,0 +1,102 @@
+package packager
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+func isYAML(filename string) bool {
+	ext := strings.ToLower(path.Ext(filename))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// canonicalYAML re-serializes every document of a YAML file with comments dropped and 2 space
+// indentation, so formatting differences between editors don't change the package, scalars keep their
+// style as quotes decide whether YAML 1.1 readers see `"yes"` as a string or a boolean, files that can't
+// be parsed or would read differently are returned as is
+func canonicalYAML(content []byte) []byte {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+
+	var documents []*yaml.Node
+	for {
+		document := &yaml.Node{}
+		if err := decoder.Decode(document); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return content
+		}
+		canonicalNode(document)
+		documents = append(documents, document)
+	}
+
+	if len(documents) == 0 {
+		return content
+	}
+
+	buffer := new(bytes.Buffer)
+	encoder := yaml.NewEncoder(buffer)
+	encoder.SetIndent(2)
+	for _, document := range documents {
+		if err := encoder.Encode(document); err != nil {
+			return content
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return content
+	}
+
+	if bytes.Equal(buffer.Bytes(), content) || !sameValues(content, buffer.Bytes()) {
+		return content
+	}
+	return buffer.Bytes()
+}
+
+// canonicalNode drops comments and the flow style of collections, scalars are left as written
+func canonicalNode(node *yaml.Node) {
+	node.HeadComment = ""
+	node.LineComment = ""
+	node.FootComment = ""
+
+	if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+		node.Style = 0
+	}
+
+	for _, child := range node.Content {
+		canonicalNode(child)
+	}
+}
+
+// sameValues reports whether both files decode to the same documents
+func sameValues(a []byte, b []byte) bool {
+	decode := func(content []byte) ([]any, error) {
+		var values []any
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		for {
+			var value any
+			if err := decoder.Decode(&value); err != nil {
+				if errors.Is(err, io.EOF) {
+					return values, nil
+				}
+				return nil, err
+			}
+			values = append(values, value)
+		}
+	}
+
+	first, err := decode(a)
+	if err != nil {
+		return false
+	}
+	second, err := decode(b)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(first, second)
+}
@@ -36,6 +36,7 @@ func (p *Packager) Pack(maxSize int64) ([]byte, error) {
 	totalSize := int64(0)
 
 	var files []FileInfoWithPath
+	contents := map[string][]byte{}
 
 	err = p.decoder.Walk(func(filename, dir string) error {
 		fullPath := filepath.Join(dir, filename)
@@ -65,23 +66,40 @@ func (p *Packager) Pack(maxSize int64) ([]byte, error) {
 
 		// ISSUES: Windows path separator is \, but zip requires /, to avoid this we just simply replace all \ with / for now
 		// TODO: find a better solution
-		fullPath = strings.ReplaceAll(fullPath, "\\", "/")
+		contents[strings.ReplaceAll(fullPath, "\\", "/")] = file
+		return nil
+	})
 
-		zipFile, err := zipWriter.Create(fullPath)
-		if err != nil {
-			return err
+	if err != nil {
+		return nil, err
+	}
+
+	// entries are written in path order whatever order the decoder walks in, identical inputs
+	// produce identical packages which keeps checksums and signatures reproducible
+	paths := make([]string, 0, len(contents))
+	for path := range contents {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		file := contents[path]
+		if isYAML(path) {
+			file = canonicalYAML(file)
 		}
 
-		_, err = zipFile.Write(file)
+		// modification time is left zero, the archive doesn't depend on when it's packed
+		zipFile, err := zipWriter.CreateHeader(&zip.FileHeader{
+			Name:   path,
+			Method: zip.Deflate,
+		})
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		return nil
-	})
-
-	if err != nil {
-		return nil, err
+		if _, err := zipFile.Write(file); err != nil {
+			return nil, err
+		}
 	}
 
 	err = zipWriter.Close()
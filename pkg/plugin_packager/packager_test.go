@@ -1,9 +1,13 @@
 package plugin_packager
 
 import (
+	"archive/zip"
+	"bytes"
+	"crypto"
 	"crypto/rsa"
 	"embed"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -537,3 +541,220 @@ func TestVerifyPluginWithThirdPartyKeys(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyPluginWithKeyring(t *testing.T) {
+	tempDir := t.TempDir()
+	publicKey1Path := extractKeyFile(t, "test_key_pair_1.public.pem", tempDir)
+	publicKey2Path := extractKeyFile(t, "test_key_pair_2.public.pem", tempDir)
+	privateKey1 := loadPrivateKeyFile(t, "test_key_pair_1.private.pem")
+
+	zip := createMinimalPlugin(t)
+	if zip == nil {
+		return
+	}
+
+	signed, err := withkey.SignPluginWithKeyID(zip, decoder.DefaultVerification(), privateKey1, "enterprise-2024")
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err.Error())
+	}
+	signedDecoder, err := decoder.NewZipPluginDecoder(signed)
+	if err != nil {
+		t.Fatalf("failed to create zip decoder: %s", err.Error())
+	}
+
+	keyID, err := signedDecoder.KeyID()
+	if err != nil || keyID != "enterprise-2024" {
+		t.Fatalf("expected key id enterprise-2024, got %s", keyID)
+	}
+
+	tests := []struct {
+		name          string
+		keyring       string
+		expectSuccess bool
+	}{
+		{
+			name:          "key matched by id",
+			keyring:       fmt.Sprintf("keys:\n  - id: enterprise-2024\n    path: %s\n", publicKey1Path),
+			expectSuccess: true,
+		},
+		{
+			name:          "key rotated after the plugin was signed",
+			keyring:       fmt.Sprintf("keys:\n  - id: enterprise-2025\n    path: %s\n  - id: enterprise-2024\n    path: %s\n    not_after: 2999-01-01T00:00:00Z\n", publicKey2Path, publicKey1Path),
+			expectSuccess: true,
+		},
+		{
+			name:          "key retired before the plugin was signed",
+			keyring:       fmt.Sprintf("keys:\n  - id: enterprise-2024\n    path: %s\n    not_after: 2000-01-01T00:00:00Z\n", publicKey1Path),
+			expectSuccess: false,
+		},
+		{
+			name:          "id does not stop other keys from being tried",
+			keyring:       fmt.Sprintf("keys:\n  - id: enterprise-2024\n    path: %s\n  - path: %s\n", publicKey2Path, publicKey1Path),
+			expectSuccess: true,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyringPath := filepath.Join(tempDir, fmt.Sprintf("keyring_%d.yaml", i))
+			if err := os.WriteFile(keyringPath, []byte(tt.keyring), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			keys, err := decoder.TrustedKeys(&decoder.ThirdPartySignatureVerificationConfig{
+				Enabled:     true,
+				KeyringPath: keyringPath,
+			})
+			if err != nil {
+				t.Fatalf("failed to load keyring: %s", err.Error())
+			}
+
+			err = decoder.VerifyPluginWithTrustedKeys(signedDecoder, keys)
+			if tt.expectSuccess && err != nil {
+				t.Errorf("expected success but got error: %s", err.Error())
+			}
+			if !tt.expectSuccess && err == nil {
+				t.Errorf("expected failure but got success")
+			}
+		})
+	}
+}
+
+// opaqueSigner hides the private key like a hardware token does
+type opaqueSigner struct {
+	key *rsa.PrivateKey
+}
+
+func (s opaqueSigner) Public() crypto.PublicKey {
+	return s.key.Public()
+}
+
+func (s opaqueSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.key.Sign(rand, digest, opts)
+}
+
+func TestSignPluginWithSigner(t *testing.T) {
+	privateKey := loadPrivateKeyFile(t, "test_key_pair_1.private.pem")
+
+	zip := createMinimalPlugin(t)
+	if zip == nil {
+		return
+	}
+
+	signed, err := withkey.SignPluginWithSigner(zip, decoder.DefaultVerification(), opaqueSigner{key: privateKey}, "token")
+	if err != nil {
+		t.Fatalf("failed to sign with signer: %s", err.Error())
+	}
+
+	signedDecoder, err := decoder.NewZipPluginDecoder(signed)
+	if err != nil {
+		t.Fatalf("failed to create zip decoder: %s", err.Error())
+	}
+
+	err = decoder.VerifyPluginWithPublicKeys(signedDecoder, []*rsa.PublicKey{&privateKey.PublicKey})
+	if err != nil {
+		t.Errorf("expected signature of signer to be verified: %s", err.Error())
+	}
+}
+
+func TestPackIsReproducible(t *testing.T) {
+	first := createMinimalPlugin(t)
+	second := createMinimalPlugin(t)
+	if !bytes.Equal(first, second) {
+		t.Fatalf("packing identical inputs produced different packages")
+	}
+
+	// formatting-only differences of yaml files are canonicalized away
+	tempDir := t.TempDir()
+	reformatted := "# leading comment\n" + strings.ReplaceAll(string(manifest), "\n", "  \n")
+	if err := os.WriteFile(filepath.Join(tempDir, "manifest.yaml"), []byte(reformatted), 0644); err != nil {
+		t.Fatalf("failed to write manifest: %s", err.Error())
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "neko.yaml"), neko, 0644); err != nil {
+		t.Fatalf("failed to write neko: %s", err.Error())
+	}
+	if err := os.MkdirAll(filepath.Join(tempDir, "_assets"), 0755); err != nil {
+		t.Fatalf("failed to create _assets directory: %s", err.Error())
+	}
+	if err := os.WriteFile(filepath.Join(tempDir, "_assets/test.svg"), test_svg, 0644); err != nil {
+		t.Fatalf("failed to write test.svg: %s", err.Error())
+	}
+	originDecoder, err := decoder.NewFSPluginDecoder(tempDir)
+	if err != nil {
+		t.Fatalf("failed to create decoder: %s", err.Error())
+	}
+	third, err := packager.NewPackager(originDecoder).Pack(52428800)
+	if err != nil {
+		t.Fatalf("failed to pack: %s", err.Error())
+	}
+	if !bytes.Equal(first, third) {
+		t.Fatalf("reformatted yaml produced a different package")
+	}
+
+	// signatures are reproducible when SOURCE_DATE_EPOCH is set
+	privateKey := loadPrivateKeyFile(t, "test_key_pair_1.private.pem")
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	verification := &decoder.Verification{AuthorizedCategory: decoder.AUTHORIZED_CATEGORY_LANGGENIUS}
+	signedFirst, err := withkey.SignPluginWithPrivateKey(first, verification, privateKey)
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err.Error())
+	}
+	signedSecond, err := withkey.SignPluginWithPrivateKey(third, verification, privateKey)
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err.Error())
+	}
+	if !bytes.Equal(signedFirst, signedSecond) {
+		t.Fatalf("signing identical packages produced different signatures")
+	}
+}
+
+func TestPackKeepsYAMLScalarStyle(t *testing.T) {
+	tempDir := t.TempDir()
+	files := map[string]string{
+		"manifest.yaml":    string(manifest),
+		"neko.yaml":        string(neko),
+		"_assets/test.svg": string(test_svg),
+		"settings.yaml":    "# toggles\nenabled: \"yes\"\nmode: 'on'\nlevels: [low, high]\n",
+		"canonical.yaml":   "name: \"neko\"\ntags:\n  - cat\n",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(tempDir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	originDecoder, err := decoder.NewFSPluginDecoder(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := packager.NewPackager(originDecoder).Pack(52428800)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(pkg), int64(len(pkg)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	packed := map[string]string{}
+	for _, file := range reader.File {
+		opened, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(opened)
+		opened.Close()
+		packed[file.Name] = string(content)
+	}
+
+	// quotes keep yaml 1.1 readers from turning the strings into booleans
+	if packed["settings.yaml"] != "enabled: \"yes\"\nmode: 'on'\nlevels:\n  - low\n  - high\n" {
+		t.Fatalf("unexpected canonical yaml %q", packed["settings.yaml"])
+	}
+	// files which are canonical already are packed as they are
+	if packed["canonical.yaml"] != files["canonical.yaml"] {
+		t.Fatalf("canonical yaml should not be rewritten, got %q", packed["canonical.yaml"])
+	}
+}
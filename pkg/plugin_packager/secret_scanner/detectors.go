@@ -0,0 +1,59 @@
+package secret_scanner
+
+import "regexp"
+
+type regexDetector struct {
+	name    string
+	pattern *regexp.Regexp
+	// group is the submatch holding the secret, 0 for the whole match
+	group int
+}
+
+// NewRegexDetector creates a detector reporting every match of pattern
+func NewRegexDetector(name string, pattern string, group int) (Detector, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &regexDetector{name: name, pattern: re, group: group}, nil
+}
+
+func (d *regexDetector) Name() string {
+	return d.name
+}
+
+func (d *regexDetector) Detect(line string) []string {
+	matches := d.pattern.FindAllStringSubmatch(line, -1)
+	result := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if d.group < len(match) {
+			result = append(result, match[d.group])
+		}
+	}
+	return result
+}
+
+func init() {
+	for _, builtin := range []struct {
+		name    string
+		pattern string
+		group   int
+	}{
+		{"aws_access_key", `\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`, 1},
+		{"private_key", `-----BEGIN (?:RSA |EC |DSA |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY( BLOCK)?-----`, 0},
+		// legacy openai keys have no dash after sk-, so keys of other providers sharing the prefix such as
+		// sk-ant- only match their own detector
+		{"openai_api_key", `\b(sk-(?:(?:proj|svcacct|admin)-[A-Za-z0-9_-]{32,}|[A-Za-z0-9]{32,}))\b`, 1},
+		{"anthropic_api_key", `\b(sk-ant-(?:api|admin)[0-9]{2}-[A-Za-z0-9_-]{32,})\b`, 1},
+		{"github_token", `\b((?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{82})\b`, 1},
+		{"slack_token", `\b(xox[abprs]-[A-Za-z0-9-]{10,})\b`, 1},
+		{"google_api_key", `\b(AIza[0-9A-Za-z_-]{35})\b`, 1},
+		{"stripe_secret_key", `\b((?:sk|rk)_live_[0-9A-Za-z]{24,})\b`, 1},
+	} {
+		detector, err := NewRegexDetector(builtin.name, builtin.pattern, builtin.group)
+		if err != nil {
+			panic(err)
+		}
+		Register(detector)
+	}
+}
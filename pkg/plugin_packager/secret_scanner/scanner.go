@@ -0,0 +1,149 @@
+package secret_scanner
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
+)
+
+const (
+	// larger files are very unlikely to be source code or configuration
+	MAX_SCAN_FILE_SIZE = 1024 * 1024
+)
+
+type Policy string
+
+const (
+	POLICY_OFF   Policy = "off"
+	POLICY_WARN  Policy = "warn"
+	POLICY_BLOCK Policy = "block"
+)
+
+// Detector finds secrets in a single line of a file
+type Detector interface {
+	Name() string
+	Detect(line string) []string
+}
+
+type Finding struct {
+	Detector string `json:"detector"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	// Match is redacted, the secret itself is never exposed
+	Match string `json:"match"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d %s (%s)", f.File, f.Line, f.Match, f.Detector)
+}
+
+var (
+	detectors     = map[string]Detector{}
+	detectorsLock sync.RWMutex
+)
+
+// Register adds a detector, detectors with the same name are replaced
+func Register(detector Detector) {
+	detectorsLock.Lock()
+	defer detectorsLock.Unlock()
+	detectors[detector.Name()] = detector
+}
+
+// Detectors returns the registered detectors by names, all of them if names is empty
+func Detectors(names []string) ([]Detector, error) {
+	detectorsLock.RLock()
+	defer detectorsLock.RUnlock()
+
+	if len(names) == 0 {
+		result := make([]Detector, 0, len(detectors))
+		for _, detector := range detectors {
+			result = append(result, detector)
+		}
+		return result, nil
+	}
+
+	result := make([]Detector, 0, len(names))
+	for _, name := range names {
+		detector, ok := detectors[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown secret detector: %s", name)
+		}
+		result = append(result, detector)
+	}
+	return result, nil
+}
+
+// Scan walks through the plugin package and reports every secret found by detectors
+func Scan(pluginDecoder decoder.PluginDecoder, detectors []Detector) ([]Finding, error) {
+	findings := []Finding{}
+
+	err := pluginDecoder.Walk(func(filename string, dir string) error {
+		if filename == "" {
+			return nil
+		}
+		name := path.Join(dir, filename)
+
+		info, err := pluginDecoder.Stat(name)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || info.Size() > MAX_SCAN_FILE_SIZE {
+			return nil
+		}
+
+		content, err := pluginDecoder.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		// skip binary files
+		if bytes.IndexByte(content, 0) != -1 {
+			return nil
+		}
+
+		findings = append(findings, scanContent(name, content, detectors)...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return findings, nil
+}
+
+func scanContent(filename string, content []byte, detectors []Detector) []Finding {
+	findings := []Finding{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), MAX_SCAN_FILE_SIZE)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		for _, detector := range detectors {
+			for _, match := range detector.Detect(line) {
+				findings = append(findings, Finding{
+					Detector: detector.Name(),
+					File:     filename,
+					Line:     lineNumber,
+					Match:    redact(match),
+				})
+			}
+		}
+	}
+
+	return findings
+}
+
+func redact(secret string) string {
+	if len(secret) <= 8 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:4] + strings.Repeat("*", len(secret)-4)
+}
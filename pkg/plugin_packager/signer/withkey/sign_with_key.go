@@ -3,11 +3,13 @@ package withkey
 import (
 	"archive/zip"
 	"bytes"
+	"crypto"
 	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
 	"io"
+	"os"
 	"path"
 	"strconv"
 	"time"
@@ -18,6 +20,15 @@ import (
 	"github.com/langgenius/dify-plugin-daemon/pkg/plugin_packager/decoder"
 )
 
+// signingTime returns SOURCE_DATE_EPOCH when it's set so that signing the same package again
+// yields the same signature, the current time otherwise
+func signingTime() int64 {
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		return epoch
+	}
+	return time.Now().Unix()
+}
+
 // SignPluginWithPrivateKey is a function that signs a plugin
 // It takes a plugin as a stream of bytes and a private key to sign it with RSA-4096
 func SignPluginWithPrivateKey(
@@ -25,6 +36,31 @@ func SignPluginWithPrivateKey(
 	verification *decoder.Verification,
 	privateKey *rsa.PrivateKey,
 ) ([]byte, error) {
+	return SignPluginWithKeyID(plugin, verification, privateKey, decoder.PublicKeyID(&privateKey.PublicKey))
+}
+
+// SignPluginWithKeyID signs a plugin like SignPluginWithPrivateKey and names the key with keyID
+// in the signature block, so verifiers holding many keys know which one to use
+func SignPluginWithKeyID(
+	plugin []byte,
+	verification *decoder.Verification,
+	privateKey *rsa.PrivateKey,
+	keyID string,
+) ([]byte, error) {
+	return SignPluginWithSigner(plugin, verification, privateKey, keyID)
+}
+
+// SignPluginWithSigner signs a plugin with a key held by signer, e.g. a key on a hardware token
+func SignPluginWithSigner(
+	plugin []byte,
+	verification *decoder.Verification,
+	signer crypto.Signer,
+	keyID string,
+) ([]byte, error) {
+	if _, ok := signer.Public().(*rsa.PublicKey); !ok {
+		return nil, errors.New("only RSA keys are supported for signing plugins")
+	}
+
 	decoder, err := decoder.NewZipPluginDecoder(plugin)
 	if err != nil {
 		return nil, err
@@ -99,8 +135,8 @@ func SignPluginWithPrivateKey(
 		return nil, err
 	}
 
-	// get current time
-	ct := time.Now().Unix()
+	// get signing time
+	ct := signingTime()
 
 	// convert time to bytes
 	timeString := strconv.FormatInt(ct, 10)
@@ -109,7 +145,7 @@ func SignPluginWithPrivateKey(
 	data.Write([]byte(timeString))
 
 	// sign the data
-	signature, err := encryption.RSASign(privateKey, data.Bytes())
+	signature, err := encryption.Sign(signer, data.Bytes())
 	if err != nil {
 		return nil, err
 	}
@@ -118,6 +154,7 @@ func SignPluginWithPrivateKey(
 	comments := parser.MarshalJson(map[string]any{
 		"signature": base64.StdEncoding.EncodeToString(signature),
 		"time":      ct,
+		"key_id":    keyID,
 	})
 
 	// write signature
@@ -186,8 +223,8 @@ func TraditionalSignPlugin(
 		return nil, err
 	}
 
-	// get current time
-	ct := time.Now().Unix()
+	// get signing time
+	ct := signingTime()
 
 	// convert time to bytes
 	timeString := strconv.FormatInt(ct, 10)
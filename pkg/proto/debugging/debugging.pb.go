@@ -0,0 +1,127 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: debugging.proto
+
+package debugging
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Frame carries one json message of the tcp debugging protocol without its trailing newline
+type Frame struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Frame) Reset() {
+	*x = Frame{}
+	mi := &file_debugging_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Frame) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Frame) ProtoMessage() {}
+
+func (x *Frame) ProtoReflect() protoreflect.Message {
+	mi := &file_debugging_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Frame.ProtoReflect.Descriptor instead.
+func (*Frame) Descriptor() ([]byte, []int) {
+	return file_debugging_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Frame) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+var File_debugging_proto protoreflect.FileDescriptor
+
+const file_debugging_proto_rawDesc = "" +
+	"\n" +
+	"\x0fdebugging.proto\x12\x1cdify_plugin_daemon.debugging\"\x1b\n" +
+	"\x05Frame\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data2j\n" +
+	"\x0fRemoteDebugging\x12W\n" +
+	"\aConnect\x12#.dify_plugin_daemon.debugging.Frame\x1a#.dify_plugin_daemon.debugging.Frame(\x010\x01B>Z<github.com/langgenius/dify-plugin-daemon/pkg/proto/debuggingb\x06proto3"
+
+var (
+	file_debugging_proto_rawDescOnce sync.Once
+	file_debugging_proto_rawDescData []byte
+)
+
+func file_debugging_proto_rawDescGZIP() []byte {
+	file_debugging_proto_rawDescOnce.Do(func() {
+		file_debugging_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_debugging_proto_rawDesc), len(file_debugging_proto_rawDesc)))
+	})
+	return file_debugging_proto_rawDescData
+}
+
+var file_debugging_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_debugging_proto_goTypes = []any{
+	(*Frame)(nil), // 0: dify_plugin_daemon.debugging.Frame
+}
+var file_debugging_proto_depIdxs = []int32{
+	0, // 0: dify_plugin_daemon.debugging.RemoteDebugging.Connect:input_type -> dify_plugin_daemon.debugging.Frame
+	0, // 1: dify_plugin_daemon.debugging.RemoteDebugging.Connect:output_type -> dify_plugin_daemon.debugging.Frame
+	1, // [1:2] is the sub-list for method output_type
+	0, // [0:1] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_debugging_proto_init() }
+func file_debugging_proto_init() {
+	if File_debugging_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_debugging_proto_rawDesc), len(file_debugging_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_debugging_proto_goTypes,
+		DependencyIndexes: file_debugging_proto_depIdxs,
+		MessageInfos:      file_debugging_proto_msgTypes,
+	}.Build()
+	File_debugging_proto = out.File
+	file_debugging_proto_goTypes = nil
+	file_debugging_proto_depIdxs = nil
+}
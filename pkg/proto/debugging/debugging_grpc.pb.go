@@ -0,0 +1,119 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: debugging.proto
+
+package debugging
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	RemoteDebugging_Connect_FullMethodName = "/dify_plugin_daemon.debugging.RemoteDebugging/Connect"
+)
+
+// RemoteDebuggingClient is the client API for RemoteDebugging service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RemoteDebuggingClient interface {
+	// Connect is a debugging connection of a plugin, it sends the same registration frames as over tcp,
+	// the debugging key is sent as `authorization: Bearer <key>` metadata or in a handshake frame
+	Connect(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Frame, Frame], error)
+}
+
+type remoteDebuggingClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRemoteDebuggingClient(cc grpc.ClientConnInterface) RemoteDebuggingClient {
+	return &remoteDebuggingClient{cc}
+}
+
+func (c *remoteDebuggingClient) Connect(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[Frame, Frame], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RemoteDebugging_ServiceDesc.Streams[0], RemoteDebugging_Connect_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Frame, Frame]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RemoteDebugging_ConnectClient = grpc.BidiStreamingClient[Frame, Frame]
+
+// RemoteDebuggingServer is the server API for RemoteDebugging service.
+// All implementations must embed UnimplementedRemoteDebuggingServer
+// for forward compatibility.
+type RemoteDebuggingServer interface {
+	// Connect is a debugging connection of a plugin, it sends the same registration frames as over tcp,
+	// the debugging key is sent as `authorization: Bearer <key>` metadata or in a handshake frame
+	Connect(grpc.BidiStreamingServer[Frame, Frame]) error
+	mustEmbedUnimplementedRemoteDebuggingServer()
+}
+
+// UnimplementedRemoteDebuggingServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRemoteDebuggingServer struct{}
+
+func (UnimplementedRemoteDebuggingServer) Connect(grpc.BidiStreamingServer[Frame, Frame]) error {
+	return status.Errorf(codes.Unimplemented, "method Connect not implemented")
+}
+func (UnimplementedRemoteDebuggingServer) mustEmbedUnimplementedRemoteDebuggingServer() {}
+func (UnimplementedRemoteDebuggingServer) testEmbeddedByValue()                         {}
+
+// UnsafeRemoteDebuggingServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RemoteDebuggingServer will
+// result in compilation errors.
+type UnsafeRemoteDebuggingServer interface {
+	mustEmbedUnimplementedRemoteDebuggingServer()
+}
+
+func RegisterRemoteDebuggingServer(s grpc.ServiceRegistrar, srv RemoteDebuggingServer) {
+	// If the following call pancis, it indicates UnimplementedRemoteDebuggingServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&RemoteDebugging_ServiceDesc, srv)
+}
+
+func _RemoteDebugging_Connect_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RemoteDebuggingServer).Connect(&grpc.GenericServerStream[Frame, Frame]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RemoteDebugging_ConnectServer = grpc.BidiStreamingServer[Frame, Frame]
+
+// RemoteDebugging_ServiceDesc is the grpc.ServiceDesc for RemoteDebugging service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RemoteDebugging_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dify_plugin_daemon.debugging.RemoteDebugging",
+	HandlerType: (*RemoteDebuggingServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Connect",
+			Handler:       _RemoteDebugging_Connect_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "debugging.proto",
+}
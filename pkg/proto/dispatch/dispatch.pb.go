@@ -0,0 +1,483 @@
+// Code generated by controller generator. DO NOT EDIT.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: dispatch.proto
+
+package dispatch
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// DispatchRequest is the grpc equivalent of the json body of /plugin/{tenant_id}/dispatch/*
+type DispatchRequest struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	TenantId       string                 `protobuf:"bytes,1,opt,name=tenant_id,json=tenantId,proto3" json:"tenant_id,omitempty"`
+	PluginId       string                 `protobuf:"bytes,2,opt,name=plugin_id,json=pluginId,proto3" json:"plugin_id,omitempty"`
+	UserId         string                 `protobuf:"bytes,3,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	ConversationId *string                `protobuf:"bytes,4,opt,name=conversation_id,json=conversationId,proto3,oneof" json:"conversation_id,omitempty"`
+	MessageId      *string                `protobuf:"bytes,5,opt,name=message_id,json=messageId,proto3,oneof" json:"message_id,omitempty"`
+	AppId          *string                `protobuf:"bytes,6,opt,name=app_id,json=appId,proto3,oneof" json:"app_id,omitempty"`
+	EndpointId     *string                `protobuf:"bytes,7,opt,name=endpoint_id,json=endpointId,proto3,oneof" json:"endpoint_id,omitempty"`
+	Context        *structpb.Struct       `protobuf:"bytes,8,opt,name=context,proto3" json:"context,omitempty"`
+	Data           *structpb.Struct       `protobuf:"bytes,9,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *DispatchRequest) Reset() {
+	*x = DispatchRequest{}
+	mi := &file_dispatch_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DispatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DispatchRequest) ProtoMessage() {}
+
+func (x *DispatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DispatchRequest.ProtoReflect.Descriptor instead.
+func (*DispatchRequest) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *DispatchRequest) GetTenantId() string {
+	if x != nil {
+		return x.TenantId
+	}
+	return ""
+}
+
+func (x *DispatchRequest) GetPluginId() string {
+	if x != nil {
+		return x.PluginId
+	}
+	return ""
+}
+
+func (x *DispatchRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *DispatchRequest) GetConversationId() string {
+	if x != nil && x.ConversationId != nil {
+		return *x.ConversationId
+	}
+	return ""
+}
+
+func (x *DispatchRequest) GetMessageId() string {
+	if x != nil && x.MessageId != nil {
+		return *x.MessageId
+	}
+	return ""
+}
+
+func (x *DispatchRequest) GetAppId() string {
+	if x != nil && x.AppId != nil {
+		return *x.AppId
+	}
+	return ""
+}
+
+func (x *DispatchRequest) GetEndpointId() string {
+	if x != nil && x.EndpointId != nil {
+		return *x.EndpointId
+	}
+	return ""
+}
+
+func (x *DispatchRequest) GetContext() *structpb.Struct {
+	if x != nil {
+		return x.Context
+	}
+	return nil
+}
+
+func (x *DispatchRequest) GetData() *structpb.Struct {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+// DispatchResponse wraps a chunk of the plugin response, errors are returned as grpc status
+type DispatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          *structpb.Struct       `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DispatchResponse) Reset() {
+	*x = DispatchResponse{}
+	mi := &file_dispatch_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DispatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DispatchResponse) ProtoMessage() {}
+
+func (x *DispatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DispatchResponse.ProtoReflect.Descriptor instead.
+func (*DispatchResponse) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *DispatchResponse) GetData() *structpb.Struct {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+// EndpointRequest is the grpc equivalent of a request to /e/{hook_id}/*, path is below the hook
+// and may carry a query string
+type EndpointRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	HookId        string                 `protobuf:"bytes,1,opt,name=hook_id,json=hookId,proto3" json:"hook_id,omitempty"`
+	Method        string                 `protobuf:"bytes,2,opt,name=method,proto3" json:"method,omitempty"`
+	Path          string                 `protobuf:"bytes,3,opt,name=path,proto3" json:"path,omitempty"`
+	Headers       map[string]string      `protobuf:"bytes,4,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Body          []byte                 `protobuf:"bytes,5,opt,name=body,proto3" json:"body,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EndpointRequest) Reset() {
+	*x = EndpointRequest{}
+	mi := &file_dispatch_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EndpointRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EndpointRequest) ProtoMessage() {}
+
+func (x *EndpointRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EndpointRequest.ProtoReflect.Descriptor instead.
+func (*EndpointRequest) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *EndpointRequest) GetHookId() string {
+	if x != nil {
+		return x.HookId
+	}
+	return ""
+}
+
+func (x *EndpointRequest) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *EndpointRequest) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+func (x *EndpointRequest) GetHeaders() map[string]string {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *EndpointRequest) GetBody() []byte {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
+// EndpointResponse carries the status and headers in the first message, chunks of the body follow
+type EndpointResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        int32                  `protobuf:"varint,1,opt,name=status,proto3" json:"status,omitempty"`
+	Headers       map[string]string      `protobuf:"bytes,2,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Body          []byte                 `protobuf:"bytes,3,opt,name=body,proto3" json:"body,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EndpointResponse) Reset() {
+	*x = EndpointResponse{}
+	mi := &file_dispatch_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EndpointResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EndpointResponse) ProtoMessage() {}
+
+func (x *EndpointResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_dispatch_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EndpointResponse.ProtoReflect.Descriptor instead.
+func (*EndpointResponse) Descriptor() ([]byte, []int) {
+	return file_dispatch_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *EndpointResponse) GetStatus() int32 {
+	if x != nil {
+		return x.Status
+	}
+	return 0
+}
+
+func (x *EndpointResponse) GetHeaders() map[string]string {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+func (x *EndpointResponse) GetBody() []byte {
+	if x != nil {
+		return x.Body
+	}
+	return nil
+}
+
+var File_dispatch_proto protoreflect.FileDescriptor
+
+const file_dispatch_proto_rawDesc = "" +
+	"\n" +
+	"\x0edispatch.proto\x12\x1bdify_plugin_daemon.dispatch\x1a\x1cgoogle/protobuf/struct.proto\"\x96\x03\n" +
+	"\x0fDispatchRequest\x12\x1b\n" +
+	"\ttenant_id\x18\x01 \x01(\tR\btenantId\x12\x1b\n" +
+	"\tplugin_id\x18\x02 \x01(\tR\bpluginId\x12\x17\n" +
+	"\auser_id\x18\x03 \x01(\tR\x06userId\x12,\n" +
+	"\x0fconversation_id\x18\x04 \x01(\tH\x00R\x0econversationId\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"message_id\x18\x05 \x01(\tH\x01R\tmessageId\x88\x01\x01\x12\x1a\n" +
+	"\x06app_id\x18\x06 \x01(\tH\x02R\x05appId\x88\x01\x01\x12$\n" +
+	"\vendpoint_id\x18\a \x01(\tH\x03R\n" +
+	"endpointId\x88\x01\x01\x121\n" +
+	"\acontext\x18\b \x01(\v2\x17.google.protobuf.StructR\acontext\x12+\n" +
+	"\x04data\x18\t \x01(\v2\x17.google.protobuf.StructR\x04dataB\x12\n" +
+	"\x10_conversation_idB\r\n" +
+	"\v_message_idB\t\n" +
+	"\a_app_idB\x0e\n" +
+	"\f_endpoint_id\"?\n" +
+	"\x10DispatchResponse\x12+\n" +
+	"\x04data\x18\x01 \x01(\v2\x17.google.protobuf.StructR\x04data\"\xfb\x01\n" +
+	"\x0fEndpointRequest\x12\x17\n" +
+	"\ahook_id\x18\x01 \x01(\tR\x06hookId\x12\x16\n" +
+	"\x06method\x18\x02 \x01(\tR\x06method\x12\x12\n" +
+	"\x04path\x18\x03 \x01(\tR\x04path\x12S\n" +
+	"\aheaders\x18\x04 \x03(\v29.dify_plugin_daemon.dispatch.EndpointRequest.HeadersEntryR\aheaders\x12\x12\n" +
+	"\x04body\x18\x05 \x01(\fR\x04body\x1a:\n" +
+	"\fHeadersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xd0\x01\n" +
+	"\x10EndpointResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\x05R\x06status\x12T\n" +
+	"\aheaders\x18\x02 \x03(\v2:.dify_plugin_daemon.dispatch.EndpointResponse.HeadersEntryR\aheaders\x12\x12\n" +
+	"\x04body\x18\x03 \x01(\fR\x04body\x1a:\n" +
+	"\fHeadersEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x012\xa6\x13\n" +
+	"\x0ePluginDispatch\x12k\n" +
+	"\n" +
+	"InvokeTool\x12,.dify_plugin_daemon.dispatch.DispatchRequest\x1a-.dify_plugin_daemon.dispatch.DispatchResponse0\x01\x12x\n" +
+	"\x17ValidateToolCredentials\x12,.dify_plugin_daemon.dispatch.DispatchRequest\x1a-.dify_plugin_daemon.dispatch.DispatchResponse0\x01\x12y\n" +
+	"\x18GetToolRuntimeParameters\x12,.dify_plugin_daemon.dispatch.DispatchRequest\x1a-.dify_plugin_daemon.dispatch.DispatchResponse0\x01\x12j\n" +
+	"\tInvokeLLM\x12,.dify_plugin_daemon.dispatch.DispatchRequest\x1a-.dify_plugin_daemon.dispatch.DispatchResponse0\x01\x12p\n" +
+	"\x0fGetLLMNumTokens\x12,.dify_plugin_daemon.dispatch.DispatchRequest\x1a-.dify_plugin_daemon.dispatch.DispatchResponse0\x01\x12t\n" +
+	"\x13InvokeTextEmbedding\x12,.dify_plugin_daemon.dispatch.DispatchRequest\x1a-.dify_plugin_daemon.dispatch.DispatchResponse0\x01\x12z\n" +
+	"\x19GetTextEmbeddingNumTokens\x12,.dify_plugin_daemon.dispatch.DispatchRequest\x1a-.dify_plugin_daemon.dispatch.DispatchResponse0\x01\x12m\n" +
+	"\fInvokeRerank\x12,.dify_plugin_daemon.dispatch.DispatchRequest\x1a-.dify_plugin_daemon.dispatch.DispatchResponse0\x01\x12j\n" +
+	"\tInvokeTTS\x12,.dify_plugin_daemon.dispatch.DispatchRequest\x1a-.dify_plugin_daemon.dispatch.DispatchResponse0\x01\x12r\n" +
+	"\x11GetTTSModelVoices\x12,.dify_plugin_daemon.dispatch.DispatchRequest\x1a-.dify_plugin_daemon.dispatch.DispatchResponse0\x01\x12r\n" +
+	"\x11InvokeSpeech2Text\x12,.dify_plugin_daemon.dispatch.DispatchRequest\x1a-.dify_plugin_daemon.dispatch.DispatchResponse0\x01\x12q\n" +
+	"\x10InvokeModeration\x12,.dify_plugin_daemon.dispatch.DispatchRequest\x1a-.dify_plugin_daemon.dispatch.DispatchResponse0\x01\x12|\n" +
+	"\x1bValidateProviderCredentials\x12,.dify_plugin_daemon.dispatch.DispatchRequest\x1a-.dify_plugin_daemon.dispatch.DispatchResponse0\x01\x12y\n" +
+	"\x18ValidateModelCredentials\x12,.dify_plugin_daemon.dispatch.DispatchRequest\x1a-.dify_plugin_daemon.dispatch.DispatchResponse0\x01\x12q\n" +
+	"\x10GetAIModelSchema\x12,.dify_plugin_daemon.dispatch.DispatchRequest\x1a-.dify_plugin_daemon.dispatch.DispatchResponse0\x01\x12t\n" +
+	"\x13GetAuthorizationURL\x12,.dify_plugin_daemon.dispatch.DispatchRequest\x1a-.dify_plugin_daemon.dispatch.DispatchResponse0\x01\x12o\n" +
+	"\x0eGetCredentials\x12,.dify_plugin_daemon.dispatch.DispatchRequest\x1a-.dify_plugin_daemon.dispatch.DispatchResponse0\x01\x12s\n" +
+	"\x12RefreshCredentials\x12,.dify_plugin_daemon.dispatch.DispatchRequest\x1a-.dify_plugin_daemon.dispatch.DispatchResponse0\x01\x12}\n" +
+	"\x1cFetchDynamicParameterOptions\x12,.dify_plugin_daemon.dispatch.DispatchRequest\x1a-.dify_plugin_daemon.dispatch.DispatchResponse0\x01\x12t\n" +
+	"\x13InvokeAgentStrategy\x12,.dify_plugin_daemon.dispatch.DispatchRequest\x1a-.dify_plugin_daemon.dispatch.DispatchResponse0\x01\x12o\n" +
+	"\x0eInvokeEndpoint\x12,.dify_plugin_daemon.dispatch.EndpointRequest\x1a-.dify_plugin_daemon.dispatch.EndpointResponse0\x01B=Z;github.com/langgenius/dify-plugin-daemon/pkg/proto/dispatchb\x06proto3"
+
+var (
+	file_dispatch_proto_rawDescOnce sync.Once
+	file_dispatch_proto_rawDescData []byte
+)
+
+func file_dispatch_proto_rawDescGZIP() []byte {
+	file_dispatch_proto_rawDescOnce.Do(func() {
+		file_dispatch_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_dispatch_proto_rawDesc), len(file_dispatch_proto_rawDesc)))
+	})
+	return file_dispatch_proto_rawDescData
+}
+
+var file_dispatch_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_dispatch_proto_goTypes = []any{
+	(*DispatchRequest)(nil),  // 0: dify_plugin_daemon.dispatch.DispatchRequest
+	(*DispatchResponse)(nil), // 1: dify_plugin_daemon.dispatch.DispatchResponse
+	(*EndpointRequest)(nil),  // 2: dify_plugin_daemon.dispatch.EndpointRequest
+	(*EndpointResponse)(nil), // 3: dify_plugin_daemon.dispatch.EndpointResponse
+	nil,                      // 4: dify_plugin_daemon.dispatch.EndpointRequest.HeadersEntry
+	nil,                      // 5: dify_plugin_daemon.dispatch.EndpointResponse.HeadersEntry
+	(*structpb.Struct)(nil),  // 6: google.protobuf.Struct
+}
+var file_dispatch_proto_depIdxs = []int32{
+	6,  // 0: dify_plugin_daemon.dispatch.DispatchRequest.context:type_name -> google.protobuf.Struct
+	6,  // 1: dify_plugin_daemon.dispatch.DispatchRequest.data:type_name -> google.protobuf.Struct
+	6,  // 2: dify_plugin_daemon.dispatch.DispatchResponse.data:type_name -> google.protobuf.Struct
+	4,  // 3: dify_plugin_daemon.dispatch.EndpointRequest.headers:type_name -> dify_plugin_daemon.dispatch.EndpointRequest.HeadersEntry
+	5,  // 4: dify_plugin_daemon.dispatch.EndpointResponse.headers:type_name -> dify_plugin_daemon.dispatch.EndpointResponse.HeadersEntry
+	0,  // 5: dify_plugin_daemon.dispatch.PluginDispatch.InvokeTool:input_type -> dify_plugin_daemon.dispatch.DispatchRequest
+	0,  // 6: dify_plugin_daemon.dispatch.PluginDispatch.ValidateToolCredentials:input_type -> dify_plugin_daemon.dispatch.DispatchRequest
+	0,  // 7: dify_plugin_daemon.dispatch.PluginDispatch.GetToolRuntimeParameters:input_type -> dify_plugin_daemon.dispatch.DispatchRequest
+	0,  // 8: dify_plugin_daemon.dispatch.PluginDispatch.InvokeLLM:input_type -> dify_plugin_daemon.dispatch.DispatchRequest
+	0,  // 9: dify_plugin_daemon.dispatch.PluginDispatch.GetLLMNumTokens:input_type -> dify_plugin_daemon.dispatch.DispatchRequest
+	0,  // 10: dify_plugin_daemon.dispatch.PluginDispatch.InvokeTextEmbedding:input_type -> dify_plugin_daemon.dispatch.DispatchRequest
+	0,  // 11: dify_plugin_daemon.dispatch.PluginDispatch.GetTextEmbeddingNumTokens:input_type -> dify_plugin_daemon.dispatch.DispatchRequest
+	0,  // 12: dify_plugin_daemon.dispatch.PluginDispatch.InvokeRerank:input_type -> dify_plugin_daemon.dispatch.DispatchRequest
+	0,  // 13: dify_plugin_daemon.dispatch.PluginDispatch.InvokeTTS:input_type -> dify_plugin_daemon.dispatch.DispatchRequest
+	0,  // 14: dify_plugin_daemon.dispatch.PluginDispatch.GetTTSModelVoices:input_type -> dify_plugin_daemon.dispatch.DispatchRequest
+	0,  // 15: dify_plugin_daemon.dispatch.PluginDispatch.InvokeSpeech2Text:input_type -> dify_plugin_daemon.dispatch.DispatchRequest
+	0,  // 16: dify_plugin_daemon.dispatch.PluginDispatch.InvokeModeration:input_type -> dify_plugin_daemon.dispatch.DispatchRequest
+	0,  // 17: dify_plugin_daemon.dispatch.PluginDispatch.ValidateProviderCredentials:input_type -> dify_plugin_daemon.dispatch.DispatchRequest
+	0,  // 18: dify_plugin_daemon.dispatch.PluginDispatch.ValidateModelCredentials:input_type -> dify_plugin_daemon.dispatch.DispatchRequest
+	0,  // 19: dify_plugin_daemon.dispatch.PluginDispatch.GetAIModelSchema:input_type -> dify_plugin_daemon.dispatch.DispatchRequest
+	0,  // 20: dify_plugin_daemon.dispatch.PluginDispatch.GetAuthorizationURL:input_type -> dify_plugin_daemon.dispatch.DispatchRequest
+	0,  // 21: dify_plugin_daemon.dispatch.PluginDispatch.GetCredentials:input_type -> dify_plugin_daemon.dispatch.DispatchRequest
+	0,  // 22: dify_plugin_daemon.dispatch.PluginDispatch.RefreshCredentials:input_type -> dify_plugin_daemon.dispatch.DispatchRequest
+	0,  // 23: dify_plugin_daemon.dispatch.PluginDispatch.FetchDynamicParameterOptions:input_type -> dify_plugin_daemon.dispatch.DispatchRequest
+	0,  // 24: dify_plugin_daemon.dispatch.PluginDispatch.InvokeAgentStrategy:input_type -> dify_plugin_daemon.dispatch.DispatchRequest
+	2,  // 25: dify_plugin_daemon.dispatch.PluginDispatch.InvokeEndpoint:input_type -> dify_plugin_daemon.dispatch.EndpointRequest
+	1,  // 26: dify_plugin_daemon.dispatch.PluginDispatch.InvokeTool:output_type -> dify_plugin_daemon.dispatch.DispatchResponse
+	1,  // 27: dify_plugin_daemon.dispatch.PluginDispatch.ValidateToolCredentials:output_type -> dify_plugin_daemon.dispatch.DispatchResponse
+	1,  // 28: dify_plugin_daemon.dispatch.PluginDispatch.GetToolRuntimeParameters:output_type -> dify_plugin_daemon.dispatch.DispatchResponse
+	1,  // 29: dify_plugin_daemon.dispatch.PluginDispatch.InvokeLLM:output_type -> dify_plugin_daemon.dispatch.DispatchResponse
+	1,  // 30: dify_plugin_daemon.dispatch.PluginDispatch.GetLLMNumTokens:output_type -> dify_plugin_daemon.dispatch.DispatchResponse
+	1,  // 31: dify_plugin_daemon.dispatch.PluginDispatch.InvokeTextEmbedding:output_type -> dify_plugin_daemon.dispatch.DispatchResponse
+	1,  // 32: dify_plugin_daemon.dispatch.PluginDispatch.GetTextEmbeddingNumTokens:output_type -> dify_plugin_daemon.dispatch.DispatchResponse
+	1,  // 33: dify_plugin_daemon.dispatch.PluginDispatch.InvokeRerank:output_type -> dify_plugin_daemon.dispatch.DispatchResponse
+	1,  // 34: dify_plugin_daemon.dispatch.PluginDispatch.InvokeTTS:output_type -> dify_plugin_daemon.dispatch.DispatchResponse
+	1,  // 35: dify_plugin_daemon.dispatch.PluginDispatch.GetTTSModelVoices:output_type -> dify_plugin_daemon.dispatch.DispatchResponse
+	1,  // 36: dify_plugin_daemon.dispatch.PluginDispatch.InvokeSpeech2Text:output_type -> dify_plugin_daemon.dispatch.DispatchResponse
+	1,  // 37: dify_plugin_daemon.dispatch.PluginDispatch.InvokeModeration:output_type -> dify_plugin_daemon.dispatch.DispatchResponse
+	1,  // 38: dify_plugin_daemon.dispatch.PluginDispatch.ValidateProviderCredentials:output_type -> dify_plugin_daemon.dispatch.DispatchResponse
+	1,  // 39: dify_plugin_daemon.dispatch.PluginDispatch.ValidateModelCredentials:output_type -> dify_plugin_daemon.dispatch.DispatchResponse
+	1,  // 40: dify_plugin_daemon.dispatch.PluginDispatch.GetAIModelSchema:output_type -> dify_plugin_daemon.dispatch.DispatchResponse
+	1,  // 41: dify_plugin_daemon.dispatch.PluginDispatch.GetAuthorizationURL:output_type -> dify_plugin_daemon.dispatch.DispatchResponse
+	1,  // 42: dify_plugin_daemon.dispatch.PluginDispatch.GetCredentials:output_type -> dify_plugin_daemon.dispatch.DispatchResponse
+	1,  // 43: dify_plugin_daemon.dispatch.PluginDispatch.RefreshCredentials:output_type -> dify_plugin_daemon.dispatch.DispatchResponse
+	1,  // 44: dify_plugin_daemon.dispatch.PluginDispatch.FetchDynamicParameterOptions:output_type -> dify_plugin_daemon.dispatch.DispatchResponse
+	1,  // 45: dify_plugin_daemon.dispatch.PluginDispatch.InvokeAgentStrategy:output_type -> dify_plugin_daemon.dispatch.DispatchResponse
+	3,  // 46: dify_plugin_daemon.dispatch.PluginDispatch.InvokeEndpoint:output_type -> dify_plugin_daemon.dispatch.EndpointResponse
+	26, // [26:47] is the sub-list for method output_type
+	5,  // [5:26] is the sub-list for method input_type
+	5,  // [5:5] is the sub-list for extension type_name
+	5,  // [5:5] is the sub-list for extension extendee
+	0,  // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_dispatch_proto_init() }
+func file_dispatch_proto_init() {
+	if File_dispatch_proto != nil {
+		return
+	}
+	file_dispatch_proto_msgTypes[0].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_dispatch_proto_rawDesc), len(file_dispatch_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_dispatch_proto_goTypes,
+		DependencyIndexes: file_dispatch_proto_depIdxs,
+		MessageInfos:      file_dispatch_proto_msgTypes,
+	}.Build()
+	File_dispatch_proto = out.File
+	file_dispatch_proto_goTypes = nil
+	file_dispatch_proto_depIdxs = nil
+}
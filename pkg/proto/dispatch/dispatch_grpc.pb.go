@@ -0,0 +1,946 @@
+// Code generated by controller generator. DO NOT EDIT.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: dispatch.proto
+
+package dispatch
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	PluginDispatch_InvokeTool_FullMethodName                   = "/dify_plugin_daemon.dispatch.PluginDispatch/InvokeTool"
+	PluginDispatch_ValidateToolCredentials_FullMethodName      = "/dify_plugin_daemon.dispatch.PluginDispatch/ValidateToolCredentials"
+	PluginDispatch_GetToolRuntimeParameters_FullMethodName     = "/dify_plugin_daemon.dispatch.PluginDispatch/GetToolRuntimeParameters"
+	PluginDispatch_InvokeLLM_FullMethodName                    = "/dify_plugin_daemon.dispatch.PluginDispatch/InvokeLLM"
+	PluginDispatch_GetLLMNumTokens_FullMethodName              = "/dify_plugin_daemon.dispatch.PluginDispatch/GetLLMNumTokens"
+	PluginDispatch_InvokeTextEmbedding_FullMethodName          = "/dify_plugin_daemon.dispatch.PluginDispatch/InvokeTextEmbedding"
+	PluginDispatch_GetTextEmbeddingNumTokens_FullMethodName    = "/dify_plugin_daemon.dispatch.PluginDispatch/GetTextEmbeddingNumTokens"
+	PluginDispatch_InvokeRerank_FullMethodName                 = "/dify_plugin_daemon.dispatch.PluginDispatch/InvokeRerank"
+	PluginDispatch_InvokeTTS_FullMethodName                    = "/dify_plugin_daemon.dispatch.PluginDispatch/InvokeTTS"
+	PluginDispatch_GetTTSModelVoices_FullMethodName            = "/dify_plugin_daemon.dispatch.PluginDispatch/GetTTSModelVoices"
+	PluginDispatch_InvokeSpeech2Text_FullMethodName            = "/dify_plugin_daemon.dispatch.PluginDispatch/InvokeSpeech2Text"
+	PluginDispatch_InvokeModeration_FullMethodName             = "/dify_plugin_daemon.dispatch.PluginDispatch/InvokeModeration"
+	PluginDispatch_ValidateProviderCredentials_FullMethodName  = "/dify_plugin_daemon.dispatch.PluginDispatch/ValidateProviderCredentials"
+	PluginDispatch_ValidateModelCredentials_FullMethodName     = "/dify_plugin_daemon.dispatch.PluginDispatch/ValidateModelCredentials"
+	PluginDispatch_GetAIModelSchema_FullMethodName             = "/dify_plugin_daemon.dispatch.PluginDispatch/GetAIModelSchema"
+	PluginDispatch_GetAuthorizationURL_FullMethodName          = "/dify_plugin_daemon.dispatch.PluginDispatch/GetAuthorizationURL"
+	PluginDispatch_GetCredentials_FullMethodName               = "/dify_plugin_daemon.dispatch.PluginDispatch/GetCredentials"
+	PluginDispatch_RefreshCredentials_FullMethodName           = "/dify_plugin_daemon.dispatch.PluginDispatch/RefreshCredentials"
+	PluginDispatch_FetchDynamicParameterOptions_FullMethodName = "/dify_plugin_daemon.dispatch.PluginDispatch/FetchDynamicParameterOptions"
+	PluginDispatch_InvokeAgentStrategy_FullMethodName          = "/dify_plugin_daemon.dispatch.PluginDispatch/InvokeAgentStrategy"
+	PluginDispatch_InvokeEndpoint_FullMethodName               = "/dify_plugin_daemon.dispatch.PluginDispatch/InvokeEndpoint"
+)
+
+// PluginDispatchClient is the client API for PluginDispatch service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PluginDispatchClient interface {
+	InvokeTool(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error)
+	ValidateToolCredentials(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error)
+	GetToolRuntimeParameters(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error)
+	InvokeLLM(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error)
+	GetLLMNumTokens(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error)
+	InvokeTextEmbedding(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error)
+	GetTextEmbeddingNumTokens(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error)
+	InvokeRerank(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error)
+	InvokeTTS(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error)
+	GetTTSModelVoices(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error)
+	InvokeSpeech2Text(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error)
+	InvokeModeration(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error)
+	ValidateProviderCredentials(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error)
+	ValidateModelCredentials(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error)
+	GetAIModelSchema(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error)
+	GetAuthorizationURL(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error)
+	GetCredentials(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error)
+	RefreshCredentials(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error)
+	FetchDynamicParameterOptions(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error)
+	InvokeAgentStrategy(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error)
+	InvokeEndpoint(ctx context.Context, in *EndpointRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[EndpointResponse], error)
+}
+
+type pluginDispatchClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPluginDispatchClient(cc grpc.ClientConnInterface) PluginDispatchClient {
+	return &pluginDispatchClient{cc}
+}
+
+func (c *pluginDispatchClient) InvokeTool(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[0], PluginDispatch_InvokeTool_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DispatchRequest, DispatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_InvokeToolClient = grpc.ServerStreamingClient[DispatchResponse]
+
+func (c *pluginDispatchClient) ValidateToolCredentials(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[1], PluginDispatch_ValidateToolCredentials_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DispatchRequest, DispatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_ValidateToolCredentialsClient = grpc.ServerStreamingClient[DispatchResponse]
+
+func (c *pluginDispatchClient) GetToolRuntimeParameters(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[2], PluginDispatch_GetToolRuntimeParameters_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DispatchRequest, DispatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_GetToolRuntimeParametersClient = grpc.ServerStreamingClient[DispatchResponse]
+
+func (c *pluginDispatchClient) InvokeLLM(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[3], PluginDispatch_InvokeLLM_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DispatchRequest, DispatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_InvokeLLMClient = grpc.ServerStreamingClient[DispatchResponse]
+
+func (c *pluginDispatchClient) GetLLMNumTokens(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[4], PluginDispatch_GetLLMNumTokens_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DispatchRequest, DispatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_GetLLMNumTokensClient = grpc.ServerStreamingClient[DispatchResponse]
+
+func (c *pluginDispatchClient) InvokeTextEmbedding(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[5], PluginDispatch_InvokeTextEmbedding_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DispatchRequest, DispatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_InvokeTextEmbeddingClient = grpc.ServerStreamingClient[DispatchResponse]
+
+func (c *pluginDispatchClient) GetTextEmbeddingNumTokens(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[6], PluginDispatch_GetTextEmbeddingNumTokens_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DispatchRequest, DispatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_GetTextEmbeddingNumTokensClient = grpc.ServerStreamingClient[DispatchResponse]
+
+func (c *pluginDispatchClient) InvokeRerank(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[7], PluginDispatch_InvokeRerank_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DispatchRequest, DispatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_InvokeRerankClient = grpc.ServerStreamingClient[DispatchResponse]
+
+func (c *pluginDispatchClient) InvokeTTS(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[8], PluginDispatch_InvokeTTS_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DispatchRequest, DispatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_InvokeTTSClient = grpc.ServerStreamingClient[DispatchResponse]
+
+func (c *pluginDispatchClient) GetTTSModelVoices(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[9], PluginDispatch_GetTTSModelVoices_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DispatchRequest, DispatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_GetTTSModelVoicesClient = grpc.ServerStreamingClient[DispatchResponse]
+
+func (c *pluginDispatchClient) InvokeSpeech2Text(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[10], PluginDispatch_InvokeSpeech2Text_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DispatchRequest, DispatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_InvokeSpeech2TextClient = grpc.ServerStreamingClient[DispatchResponse]
+
+func (c *pluginDispatchClient) InvokeModeration(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[11], PluginDispatch_InvokeModeration_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DispatchRequest, DispatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_InvokeModerationClient = grpc.ServerStreamingClient[DispatchResponse]
+
+func (c *pluginDispatchClient) ValidateProviderCredentials(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[12], PluginDispatch_ValidateProviderCredentials_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DispatchRequest, DispatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_ValidateProviderCredentialsClient = grpc.ServerStreamingClient[DispatchResponse]
+
+func (c *pluginDispatchClient) ValidateModelCredentials(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[13], PluginDispatch_ValidateModelCredentials_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DispatchRequest, DispatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_ValidateModelCredentialsClient = grpc.ServerStreamingClient[DispatchResponse]
+
+func (c *pluginDispatchClient) GetAIModelSchema(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[14], PluginDispatch_GetAIModelSchema_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DispatchRequest, DispatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_GetAIModelSchemaClient = grpc.ServerStreamingClient[DispatchResponse]
+
+func (c *pluginDispatchClient) GetAuthorizationURL(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[15], PluginDispatch_GetAuthorizationURL_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DispatchRequest, DispatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_GetAuthorizationURLClient = grpc.ServerStreamingClient[DispatchResponse]
+
+func (c *pluginDispatchClient) GetCredentials(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[16], PluginDispatch_GetCredentials_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DispatchRequest, DispatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_GetCredentialsClient = grpc.ServerStreamingClient[DispatchResponse]
+
+func (c *pluginDispatchClient) RefreshCredentials(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[17], PluginDispatch_RefreshCredentials_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DispatchRequest, DispatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_RefreshCredentialsClient = grpc.ServerStreamingClient[DispatchResponse]
+
+func (c *pluginDispatchClient) FetchDynamicParameterOptions(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[18], PluginDispatch_FetchDynamicParameterOptions_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DispatchRequest, DispatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_FetchDynamicParameterOptionsClient = grpc.ServerStreamingClient[DispatchResponse]
+
+func (c *pluginDispatchClient) InvokeAgentStrategy(ctx context.Context, in *DispatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[DispatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[19], PluginDispatch_InvokeAgentStrategy_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[DispatchRequest, DispatchResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_InvokeAgentStrategyClient = grpc.ServerStreamingClient[DispatchResponse]
+
+func (c *pluginDispatchClient) InvokeEndpoint(ctx context.Context, in *EndpointRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[EndpointResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &PluginDispatch_ServiceDesc.Streams[20], PluginDispatch_InvokeEndpoint_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[EndpointRequest, EndpointResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_InvokeEndpointClient = grpc.ServerStreamingClient[EndpointResponse]
+
+// PluginDispatchServer is the server API for PluginDispatch service.
+// All implementations must embed UnimplementedPluginDispatchServer
+// for forward compatibility.
+type PluginDispatchServer interface {
+	InvokeTool(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error
+	ValidateToolCredentials(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error
+	GetToolRuntimeParameters(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error
+	InvokeLLM(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error
+	GetLLMNumTokens(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error
+	InvokeTextEmbedding(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error
+	GetTextEmbeddingNumTokens(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error
+	InvokeRerank(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error
+	InvokeTTS(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error
+	GetTTSModelVoices(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error
+	InvokeSpeech2Text(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error
+	InvokeModeration(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error
+	ValidateProviderCredentials(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error
+	ValidateModelCredentials(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error
+	GetAIModelSchema(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error
+	GetAuthorizationURL(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error
+	GetCredentials(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error
+	RefreshCredentials(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error
+	FetchDynamicParameterOptions(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error
+	InvokeAgentStrategy(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error
+	InvokeEndpoint(*EndpointRequest, grpc.ServerStreamingServer[EndpointResponse]) error
+	mustEmbedUnimplementedPluginDispatchServer()
+}
+
+// UnimplementedPluginDispatchServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPluginDispatchServer struct{}
+
+func (UnimplementedPluginDispatchServer) InvokeTool(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method InvokeTool not implemented")
+}
+func (UnimplementedPluginDispatchServer) ValidateToolCredentials(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ValidateToolCredentials not implemented")
+}
+func (UnimplementedPluginDispatchServer) GetToolRuntimeParameters(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method GetToolRuntimeParameters not implemented")
+}
+func (UnimplementedPluginDispatchServer) InvokeLLM(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method InvokeLLM not implemented")
+}
+func (UnimplementedPluginDispatchServer) GetLLMNumTokens(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method GetLLMNumTokens not implemented")
+}
+func (UnimplementedPluginDispatchServer) InvokeTextEmbedding(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method InvokeTextEmbedding not implemented")
+}
+func (UnimplementedPluginDispatchServer) GetTextEmbeddingNumTokens(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method GetTextEmbeddingNumTokens not implemented")
+}
+func (UnimplementedPluginDispatchServer) InvokeRerank(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method InvokeRerank not implemented")
+}
+func (UnimplementedPluginDispatchServer) InvokeTTS(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method InvokeTTS not implemented")
+}
+func (UnimplementedPluginDispatchServer) GetTTSModelVoices(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method GetTTSModelVoices not implemented")
+}
+func (UnimplementedPluginDispatchServer) InvokeSpeech2Text(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method InvokeSpeech2Text not implemented")
+}
+func (UnimplementedPluginDispatchServer) InvokeModeration(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method InvokeModeration not implemented")
+}
+func (UnimplementedPluginDispatchServer) ValidateProviderCredentials(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ValidateProviderCredentials not implemented")
+}
+func (UnimplementedPluginDispatchServer) ValidateModelCredentials(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ValidateModelCredentials not implemented")
+}
+func (UnimplementedPluginDispatchServer) GetAIModelSchema(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method GetAIModelSchema not implemented")
+}
+func (UnimplementedPluginDispatchServer) GetAuthorizationURL(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method GetAuthorizationURL not implemented")
+}
+func (UnimplementedPluginDispatchServer) GetCredentials(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method GetCredentials not implemented")
+}
+func (UnimplementedPluginDispatchServer) RefreshCredentials(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method RefreshCredentials not implemented")
+}
+func (UnimplementedPluginDispatchServer) FetchDynamicParameterOptions(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method FetchDynamicParameterOptions not implemented")
+}
+func (UnimplementedPluginDispatchServer) InvokeAgentStrategy(*DispatchRequest, grpc.ServerStreamingServer[DispatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method InvokeAgentStrategy not implemented")
+}
+func (UnimplementedPluginDispatchServer) InvokeEndpoint(*EndpointRequest, grpc.ServerStreamingServer[EndpointResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method InvokeEndpoint not implemented")
+}
+func (UnimplementedPluginDispatchServer) mustEmbedUnimplementedPluginDispatchServer() {}
+func (UnimplementedPluginDispatchServer) testEmbeddedByValue()                        {}
+
+// UnsafePluginDispatchServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PluginDispatchServer will
+// result in compilation errors.
+type UnsafePluginDispatchServer interface {
+	mustEmbedUnimplementedPluginDispatchServer()
+}
+
+func RegisterPluginDispatchServer(s grpc.ServiceRegistrar, srv PluginDispatchServer) {
+	// If the following call pancis, it indicates UnimplementedPluginDispatchServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&PluginDispatch_ServiceDesc, srv)
+}
+
+func _PluginDispatch_InvokeTool_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DispatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).InvokeTool(m, &grpc.GenericServerStream[DispatchRequest, DispatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_InvokeToolServer = grpc.ServerStreamingServer[DispatchResponse]
+
+func _PluginDispatch_ValidateToolCredentials_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DispatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).ValidateToolCredentials(m, &grpc.GenericServerStream[DispatchRequest, DispatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_ValidateToolCredentialsServer = grpc.ServerStreamingServer[DispatchResponse]
+
+func _PluginDispatch_GetToolRuntimeParameters_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DispatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).GetToolRuntimeParameters(m, &grpc.GenericServerStream[DispatchRequest, DispatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_GetToolRuntimeParametersServer = grpc.ServerStreamingServer[DispatchResponse]
+
+func _PluginDispatch_InvokeLLM_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DispatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).InvokeLLM(m, &grpc.GenericServerStream[DispatchRequest, DispatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_InvokeLLMServer = grpc.ServerStreamingServer[DispatchResponse]
+
+func _PluginDispatch_GetLLMNumTokens_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DispatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).GetLLMNumTokens(m, &grpc.GenericServerStream[DispatchRequest, DispatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_GetLLMNumTokensServer = grpc.ServerStreamingServer[DispatchResponse]
+
+func _PluginDispatch_InvokeTextEmbedding_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DispatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).InvokeTextEmbedding(m, &grpc.GenericServerStream[DispatchRequest, DispatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_InvokeTextEmbeddingServer = grpc.ServerStreamingServer[DispatchResponse]
+
+func _PluginDispatch_GetTextEmbeddingNumTokens_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DispatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).GetTextEmbeddingNumTokens(m, &grpc.GenericServerStream[DispatchRequest, DispatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_GetTextEmbeddingNumTokensServer = grpc.ServerStreamingServer[DispatchResponse]
+
+func _PluginDispatch_InvokeRerank_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DispatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).InvokeRerank(m, &grpc.GenericServerStream[DispatchRequest, DispatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_InvokeRerankServer = grpc.ServerStreamingServer[DispatchResponse]
+
+func _PluginDispatch_InvokeTTS_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DispatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).InvokeTTS(m, &grpc.GenericServerStream[DispatchRequest, DispatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_InvokeTTSServer = grpc.ServerStreamingServer[DispatchResponse]
+
+func _PluginDispatch_GetTTSModelVoices_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DispatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).GetTTSModelVoices(m, &grpc.GenericServerStream[DispatchRequest, DispatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_GetTTSModelVoicesServer = grpc.ServerStreamingServer[DispatchResponse]
+
+func _PluginDispatch_InvokeSpeech2Text_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DispatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).InvokeSpeech2Text(m, &grpc.GenericServerStream[DispatchRequest, DispatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_InvokeSpeech2TextServer = grpc.ServerStreamingServer[DispatchResponse]
+
+func _PluginDispatch_InvokeModeration_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DispatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).InvokeModeration(m, &grpc.GenericServerStream[DispatchRequest, DispatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_InvokeModerationServer = grpc.ServerStreamingServer[DispatchResponse]
+
+func _PluginDispatch_ValidateProviderCredentials_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DispatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).ValidateProviderCredentials(m, &grpc.GenericServerStream[DispatchRequest, DispatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_ValidateProviderCredentialsServer = grpc.ServerStreamingServer[DispatchResponse]
+
+func _PluginDispatch_ValidateModelCredentials_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DispatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).ValidateModelCredentials(m, &grpc.GenericServerStream[DispatchRequest, DispatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_ValidateModelCredentialsServer = grpc.ServerStreamingServer[DispatchResponse]
+
+func _PluginDispatch_GetAIModelSchema_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DispatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).GetAIModelSchema(m, &grpc.GenericServerStream[DispatchRequest, DispatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_GetAIModelSchemaServer = grpc.ServerStreamingServer[DispatchResponse]
+
+func _PluginDispatch_GetAuthorizationURL_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DispatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).GetAuthorizationURL(m, &grpc.GenericServerStream[DispatchRequest, DispatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_GetAuthorizationURLServer = grpc.ServerStreamingServer[DispatchResponse]
+
+func _PluginDispatch_GetCredentials_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DispatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).GetCredentials(m, &grpc.GenericServerStream[DispatchRequest, DispatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_GetCredentialsServer = grpc.ServerStreamingServer[DispatchResponse]
+
+func _PluginDispatch_RefreshCredentials_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DispatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).RefreshCredentials(m, &grpc.GenericServerStream[DispatchRequest, DispatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_RefreshCredentialsServer = grpc.ServerStreamingServer[DispatchResponse]
+
+func _PluginDispatch_FetchDynamicParameterOptions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DispatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).FetchDynamicParameterOptions(m, &grpc.GenericServerStream[DispatchRequest, DispatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_FetchDynamicParameterOptionsServer = grpc.ServerStreamingServer[DispatchResponse]
+
+func _PluginDispatch_InvokeAgentStrategy_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DispatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).InvokeAgentStrategy(m, &grpc.GenericServerStream[DispatchRequest, DispatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_InvokeAgentStrategyServer = grpc.ServerStreamingServer[DispatchResponse]
+
+func _PluginDispatch_InvokeEndpoint_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(EndpointRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PluginDispatchServer).InvokeEndpoint(m, &grpc.GenericServerStream[EndpointRequest, EndpointResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type PluginDispatch_InvokeEndpointServer = grpc.ServerStreamingServer[EndpointResponse]
+
+// PluginDispatch_ServiceDesc is the grpc.ServiceDesc for PluginDispatch service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PluginDispatch_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dify_plugin_daemon.dispatch.PluginDispatch",
+	HandlerType: (*PluginDispatchServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "InvokeTool",
+			Handler:       _PluginDispatch_InvokeTool_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ValidateToolCredentials",
+			Handler:       _PluginDispatch_ValidateToolCredentials_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetToolRuntimeParameters",
+			Handler:       _PluginDispatch_GetToolRuntimeParameters_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "InvokeLLM",
+			Handler:       _PluginDispatch_InvokeLLM_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetLLMNumTokens",
+			Handler:       _PluginDispatch_GetLLMNumTokens_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "InvokeTextEmbedding",
+			Handler:       _PluginDispatch_InvokeTextEmbedding_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetTextEmbeddingNumTokens",
+			Handler:       _PluginDispatch_GetTextEmbeddingNumTokens_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "InvokeRerank",
+			Handler:       _PluginDispatch_InvokeRerank_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "InvokeTTS",
+			Handler:       _PluginDispatch_InvokeTTS_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetTTSModelVoices",
+			Handler:       _PluginDispatch_GetTTSModelVoices_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "InvokeSpeech2Text",
+			Handler:       _PluginDispatch_InvokeSpeech2Text_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "InvokeModeration",
+			Handler:       _PluginDispatch_InvokeModeration_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ValidateProviderCredentials",
+			Handler:       _PluginDispatch_ValidateProviderCredentials_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ValidateModelCredentials",
+			Handler:       _PluginDispatch_ValidateModelCredentials_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetAIModelSchema",
+			Handler:       _PluginDispatch_GetAIModelSchema_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetAuthorizationURL",
+			Handler:       _PluginDispatch_GetAuthorizationURL_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetCredentials",
+			Handler:       _PluginDispatch_GetCredentials_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "RefreshCredentials",
+			Handler:       _PluginDispatch_RefreshCredentials_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "FetchDynamicParameterOptions",
+			Handler:       _PluginDispatch_FetchDynamicParameterOptions_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "InvokeAgentStrategy",
+			Handler:       _PluginDispatch_InvokeAgentStrategy_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "InvokeEndpoint",
+			Handler:       _PluginDispatch_InvokeEndpoint_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "dispatch.proto",
+}
@@ -0,0 +1,10 @@
+// Package schema publishes the json schema of the events exchanged between the daemon, plugins and
+// callers, it's generated by cmd/codegen from the go entities
+package schema
+
+import (
+	_ "embed"
+)
+
+//go:embed events.schema.json
+var Events []byte